@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// adaptiveCPUHysteresis is how far (in percent) CPU usage must drop below
+	// the target before the compression level is stepped back up
+	adaptiveCPUHysteresis = 15.0
+
+	// adaptiveThroughputDrop steps the level down when a file's throughput falls
+	// below this fraction of the running average (compression became the bottleneck)
+	adaptiveThroughputDrop = 0.5
+
+	// adaptiveThroughputSteady is the fraction of the running average a file
+	// must reach before the level is allowed to step up
+	adaptiveThroughputSteady = 0.9
+
+	// adaptiveThroughputWeight is the EWMA weight given to the newest sample
+	adaptiveThroughputWeight = 0.3
+)
+
+var errCPUStatUnavailable = errors.New("CPU statistics unavailable")
+
+// cpuSample holds cumulative CPU jiffies from /proc/stat
+type cpuSample struct {
+	total uint64
+	idle  uint64
+}
+
+// readCPUSample reads the aggregate CPU counters from /proc/stat. On platforms
+// without procfs an error is returned and the controller relies on throughput alone.
+func readCPUSample() (cpuSample, error) {
+	file, err := os.Open("/proc/stat")
+	if err != nil {
+		return cpuSample{}, fmt.Errorf("%w: %w", errCPUStatUnavailable, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		return cpuSample{}, errCPUStatUnavailable
+	}
+	return parseCPUStatLine(scanner.Text())
+}
+
+// parseCPUStatLine parses the "cpu" line of /proc/stat:
+// cpu user nice system idle iowait irq softirq steal ...
+func parseCPUStatLine(line string) (cpuSample, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return cpuSample{}, fmt.Errorf("%w: unexpected line %q", errCPUStatUnavailable, line)
+	}
+
+	var sample cpuSample
+	for i, field := range fields[1:] {
+		// Guest time is already included in user/nice
+		if i >= 8 {
+			break
+		}
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return cpuSample{}, fmt.Errorf("%w: %w", errCPUStatUnavailable, err)
+		}
+		sample.total += value
+		// idle and iowait
+		if i == 3 || i == 4 {
+			sample.idle += value
+		}
+	}
+	return sample, nil
+}
+
+// cpuBusyPercent returns the share of non-idle CPU time between two samples
+func cpuBusyPercent(prev, cur cpuSample) (float64, bool) {
+	if cur.total <= prev.total {
+		return 0, false
+	}
+	total := float64(cur.total - prev.total)
+	idle := float64(cur.idle - prev.idle)
+	return (1 - idle/total) * 100, true
+}
+
+// compressionController steps the compression level within [minLevel, maxLevel]
+// based on host CPU usage and archive throughput observed after each file
+type compressionController struct {
+	mu         sync.Mutex
+	level      int
+	minLevel   int
+	maxLevel   int
+	cpuTarget  float64
+	readCPU    func() (cpuSample, error)
+	lastCPU    cpuSample
+	haveCPU    bool
+	throughput float64 // EWMA of uncompressed bytes per second
+}
+
+// newCompressionController creates a controller starting at the configured level,
+// clamped to the band. A zero max level means the configured level is the ceiling.
+func newCompressionController(config *Config) *compressionController {
+	maxLevel := config.CompressionLevelMax
+	if maxLevel == 0 {
+		maxLevel = config.CompressionLevel
+	}
+	ac := &compressionController{
+		level:     clampLevel(config.CompressionLevel, config.CompressionLevelMin, maxLevel),
+		minLevel:  config.CompressionLevelMin,
+		maxLevel:  maxLevel,
+		cpuTarget: float64(config.AdaptiveCPUTarget),
+		readCPU:   readCPUSample,
+	}
+	if sample, err := ac.readCPU(); err == nil {
+		ac.lastCPU = sample
+		ac.haveCPU = true
+	}
+	return ac
+}
+
+// Level returns the compression level to use for the next file
+func (ac *compressionController) Level() int {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	return ac.level
+}
+
+// observe records the throughput of a finished file and adjusts the level.
+// It returns the previous and new levels.
+func (ac *compressionController) observe(uncompressedBytes int64, elapsed time.Duration) (int, int) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	previous := ac.level
+	if elapsed <= 0 || uncompressedBytes <= 0 {
+		return previous, previous
+	}
+
+	cpuBusy, cpuKnown := 0.0, false
+	if ac.haveCPU {
+		if sample, err := ac.readCPU(); err == nil {
+			cpuBusy, cpuKnown = cpuBusyPercent(ac.lastCPU, sample)
+			ac.lastCPU = sample
+		}
+	}
+
+	throughput := float64(uncompressedBytes) / elapsed.Seconds()
+	ac.level = nextCompressionLevel(ac.level, ac.minLevel, ac.maxLevel, ac.cpuTarget, cpuBusy, cpuKnown, throughput, ac.throughput)
+
+	if ac.throughput == 0 {
+		ac.throughput = throughput
+	} else {
+		ac.throughput = adaptiveThroughputWeight*throughput + (1-adaptiveThroughputWeight)*ac.throughput
+	}
+
+	return previous, ac.level
+}
+
+// nextCompressionLevel decides the next level. CPU above the target or a sharp
+// throughput drop steps down; CPU comfortably below the target with steady
+// throughput steps up. Without CPU statistics only throughput is considered.
+func nextCompressionLevel(level, minLevel, maxLevel int, cpuTarget, cpuBusy float64, cpuKnown bool, throughput, avgThroughput float64) int {
+	switch {
+	case cpuKnown && cpuBusy > cpuTarget:
+		level--
+	case avgThroughput > 0 && throughput < avgThroughput*adaptiveThroughputDrop:
+		level--
+	case (!cpuKnown || cpuBusy < cpuTarget-adaptiveCPUHysteresis) &&
+		(avgThroughput == 0 || throughput >= avgThroughput*adaptiveThroughputSteady):
+		level++
+	}
+	return clampLevel(level, minLevel, maxLevel)
+}
+
+func clampLevel(level, minLevel, maxLevel int) int {
+	if level < minLevel {
+		return minLevel
+	}
+	if level > maxLevel {
+		return maxLevel
+	}
+	return level
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseCPUStatLine(t *testing.T) {
+	sample, err := parseCPUStatLine("cpu  100 10 50 800 40 0 0 0 5 0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sample.total != 1000 || sample.idle != 840 {
+		t.Errorf("unexpected sample: %+v", sample)
+	}
+
+	if _, err := parseCPUStatLine("intr 12345"); !errors.Is(err, errCPUStatUnavailable) {
+		t.Errorf("expected errCPUStatUnavailable, got %v", err)
+	}
+
+	busy, ok := cpuBusyPercent(cpuSample{total: 1000, idle: 840}, cpuSample{total: 1100, idle: 860})
+	if !ok || busy != 80 {
+		t.Errorf("expected 80%% busy, got %v (ok=%v)", busy, ok)
+	}
+}
+
+func TestNextCompressionLevel(t *testing.T) {
+	tests := []struct {
+		name          string
+		level         int
+		cpuBusy       float64
+		cpuKnown      bool
+		throughput    float64
+		avgThroughput float64
+		want          int
+	}{
+		{"CPUAboveTarget", 10, 90, true, 100, 100, 9},
+		{"CPUWithinHysteresis", 10, 70, true, 100, 100, 10},
+		{"CPULowSteadyThroughput", 10, 20, true, 95, 100, 11},
+		{"ThroughputCollapsed", 10, 20, true, 40, 100, 9},
+		{"ClampedAtMin", 3, 95, true, 100, 100, 3},
+		{"ClampedAtMax", 19, 10, true, 100, 100, 19},
+		{"NoCPUFirstFile", 10, 0, false, 100, 0, 11},
+		{"NoCPUThroughputDrop", 10, 0, false, 30, 100, 9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nextCompressionLevel(tt.level, 3, 19, 75, tt.cpuBusy, tt.cpuKnown, tt.throughput, tt.avgThroughput)
+			if got != tt.want {
+				t.Errorf("expected level %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCompressionControllerObserve(t *testing.T) {
+	controller := newCompressionController(&Config{
+		CompressionLevel:    19,
+		CompressionLevelMin: 3,
+		AdaptiveCPUTarget:   75,
+	})
+
+	// Simulate a saturated host: 95% busy between samples
+	samples := []cpuSample{{total: 1000, idle: 500}, {total: 2000, idle: 550}, {total: 3000, idle: 600}}
+	next := 0
+	controller.readCPU = func() (cpuSample, error) {
+		s := samples[next]
+		next++
+		return s, nil
+	}
+	controller.lastCPU, _ = controller.readCPU()
+	controller.haveCPU = true
+
+	if controller.Level() != 19 {
+		t.Fatalf("expected starting level 19, got %d", controller.Level())
+	}
+	if prev, cur := controller.observe(1<<20, time.Second); prev != 19 || cur != 18 {
+		t.Errorf("expected 19 → 18, got %d → %d", prev, cur)
+	}
+	if _, cur := controller.observe(1<<20, time.Second); cur != 17 {
+		t.Errorf("expected 17, got %d", cur)
+	}
+
+	// Empty files don't move the level
+	if prev, cur := controller.observe(0, time.Second); prev != cur {
+		t.Errorf("expected no change for empty file, got %d → %d", prev, cur)
+	}
+}
+
+func TestValidateAdaptiveCompression(t *testing.T) {
+	base := Config{Compression: "zstd", CompressionLevel: 19, CompressionLevelMin: 3, AdaptiveCPUTarget: 75}
+
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr error
+	}{
+		{"Valid", func(_ *Config) {}, nil},
+		{"NoCompression", func(c *Config) { c.Compression = "none"; c.CompressionLevel = 0 }, ErrAdaptiveCompressionNone},
+		{"MinAboveMax", func(c *Config) { c.CompressionLevelMin = 20 }, ErrAdaptiveLevelBand},
+		{"MaxOutOfRange", func(c *Config) { c.Compression = "gzip"; c.CompressionLevel = 6; c.CompressionLevelMax = 12 }, ErrAdaptiveLevelBand},
+		{"CPUTarget", func(c *Config) { c.AdaptiveCPUTarget = 0 }, ErrAdaptiveCPUTarget},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := base
+			tt.modify(&config)
+			if err := config.validateAdaptiveCompression(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
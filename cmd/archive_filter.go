@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/spf13/viper"
+)
+
+// Static errors for archive row filters
+var (
+	ErrFilterInvalid  = errors.New("--filter must be a single SQL condition without semicolons, comments, $ parameters, backslashes, escape strings or unbalanced parentheses")
+	ErrFilterConflict = errors.New("--filter cannot be combined with a custom query; filter the rows in the query instead")
+)
+
+var archiveFilter string
+
+func init() {
+	archiveCmd.Flags().StringVar(&archiveFilter, "filter", "", "SQL condition rows must match to be archived (e.g. \"deleted_at IS NULL AND tenant_id = 42\")")
+	_ = viper.BindPFlag("filter", archiveCmd.Flags().Lookup("filter"))
+}
+
+// extractionFilter is a parsed --filter. Conditions in the --where subset of
+// SQL (comparisons with literals, IN, BETWEEN, IS NULL, AND, OR, NOT) are
+// rebuilt with their literals as query parameters; any other condition is
+// used as written, once checked to be a single expression.
+type extractionFilter struct {
+	text      string
+	predicate filterNode // nil when the condition is used as written
+}
+
+// newExtractionFilter parses a --filter condition, returning nil when there is none
+func newExtractionFilter(text string) (*extractionFilter, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, nil
+	}
+	parser := &filterParser{input: text}
+	if predicate, err := parser.parse(); err == nil {
+		return &extractionFilter{text: text, predicate: predicate}, nil
+	}
+	if err := checkRawFilter(text); err != nil {
+		return nil, err
+	}
+	return &extractionFilter{text: text}, nil
+}
+
+// checkRawFilter checks that a condition used as written cannot end the
+// statement, comment out the rest of the query, reference its parameters or
+// close the parentheses it is wrapped in. Backslashes and E'...' strings are
+// rejected, since an escaped quote would end a literal somewhere else than
+// where it is tracked here.
+func checkRawFilter(text string) error {
+	if strings.ContainsRune(text, '\\') {
+		return fmt.Errorf("%w: backslash in %q", ErrFilterInvalid, text)
+	}
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '\'', '"':
+			if c == '\'' && isEscapeStringPrefix(text, i) {
+				return fmt.Errorf("%w: escape string in %q", ErrFilterInvalid, text)
+			}
+			end := strings.IndexByte(text[i+1:], c)
+			if end < 0 {
+				return fmt.Errorf("%w: unterminated %c in %q", ErrFilterInvalid, c, text)
+			}
+			// A doubled quote scans as two adjacent literals
+			i += end + 1
+		case ';', '$':
+			return fmt.Errorf("%w: unexpected %c in %q", ErrFilterInvalid, c, text)
+		case '-', '/':
+			if i+1 < len(text) && (c == '-' && text[i+1] == '-' || c == '/' && text[i+1] == '*') {
+				return fmt.Errorf("%w: comment in %q", ErrFilterInvalid, text)
+			}
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth < 0 {
+				return fmt.Errorf("%w: unbalanced ) in %q", ErrFilterInvalid, text)
+			}
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("%w: unbalanced ( in %q", ErrFilterInvalid, text)
+	}
+	return nil
+}
+
+// isEscapeStringPrefix reports whether the quote at i opens an E'...' escape
+// string: it follows an E that does not end a longer identifier
+func isEscapeStringPrefix(text string, i int) bool {
+	if i == 0 || (text[i-1] != 'E' && text[i-1] != 'e') {
+		return false
+	}
+	if i == 1 {
+		return true
+	}
+	prev := text[i-2]
+	return !(prev == '_' || prev == '$' || prev == '"' || prev >= '0' && prev <= '9' || prev >= 'a' && prev <= 'z' || prev >= 'A' && prev <= 'Z' || prev >= 0x80)
+}
+
+// condition returns the filter as a parenthesized WHERE condition, with its
+// parameters numbered after args and appended to them. Literals are passed as
+// text, so PostgreSQL reads them as the type of the column they are compared with.
+func (f *extractionFilter) condition(args []interface{}) (string, []interface{}) {
+	if f.predicate == nil {
+		return "(" + f.text + ")", args
+	}
+	return "(" + filterSQL(f.predicate, &args) + ")", args
+}
+
+// filterSQL renders a parsed predicate as SQL, appending its literals to args
+func filterSQL(node filterNode, args *[]interface{}) string {
+	switch n := node.(type) {
+	case filterAnd:
+		return joinFilterSQL(n, " AND ", args)
+	case filterOr:
+		return joinFilterSQL(n, " OR ", args)
+	case filterNot:
+		return "NOT (" + filterSQL(n.node, args) + ")"
+	case filterIsNull:
+		return pq.QuoteIdentifier(n.column) + " IS NULL"
+	case filterCompare:
+		*args = append(*args, n.value.text)
+		return fmt.Sprintf("%s %s $%d", pq.QuoteIdentifier(n.column), n.op, len(*args))
+	default:
+		panic(fmt.Sprintf("unexpected filter node %T", node))
+	}
+}
+
+func joinFilterSQL(nodes []filterNode, separator string, args *[]interface{}) string {
+	parts := make([]string, len(nodes))
+	for i, node := range nodes {
+		parts[i] = "(" + filterSQL(node, args) + ")"
+	}
+	return strings.Join(parts, separator)
+}
+
+// validateFilter checks the --filter condition
+func (c *Config) validateFilter() error {
+	if strings.TrimSpace(c.Filter) == "" {
+		return nil
+	}
+	if c.customQuery() != "" {
+		return ErrFilterConflict
+	}
+	_, err := newExtractionFilter(c.Filter)
+	return err
+}
+
+// filterQuery adds the --filter condition to query, whose WHERE clause (if
+// hasWhere) must be its last clause, taking args as the query's arguments
+func filterQuery(filter string, query string, args []interface{}, hasWhere bool) (string, []interface{}) {
+	f, err := newExtractionFilter(filter)
+	if err != nil || f == nil {
+		return query, args
+	}
+	condition, args := f.condition(args)
+	if hasWhere {
+		return query + " AND " + condition, args
+	}
+	return query + " WHERE " + condition, args
+}
+
+// setFilter records the --filter condition a file's rows were selected with
+func (c *PartitionCache) setFilter(tablePartition string, filter string) {
+	if filter == "" {
+		return
+	}
+	entry := c.Entries[tablePartition]
+	entry.Filter = filter
+	c.Entries[tablePartition] = entry
+	c.markDirty(tablePartition)
+}
+
+// countFilteredRows counts the rows of an entry's source table that its file
+// was archived from: those in its key range, increment or time range that
+// match the --filter it was archived with
+func countFilteredRows(ctx context.Context, db *sql.DB, entry PartitionCacheEntry, dateColumn DateColumnSpec) (int64, error) {
+	//nolint:gosec // G201: identifiers are quoted via pq.QuoteIdentifier
+	query := fmt.Sprintf("SELECT count(*) FROM %s", pq.QuoteIdentifier(entry.SourceTable))
+	var condition string
+	var args []interface{}
+	switch {
+	case entry.WatermarkColumn != "":
+		condition, args = Increment{Column: entry.WatermarkColumn, After: entry.WatermarkAfter, Through: entry.WatermarkThrough}.condition()
+	case entry.KeyColumn != "":
+		condition, args = KeyRange{Column: entry.KeyColumn, Start: entry.KeyStart, End: entry.KeyEnd}.condition()
+	case !entry.RangeStart.IsZero() && !entry.RangeEnd.IsZero():
+		if dateColumn.Name == "" {
+			return 0, fmt.Errorf("file covers %s to %s but no --date-column was given", entry.RangeStart.Format(time.RFC3339), entry.RangeEnd.Format(time.RFC3339))
+		}
+		condition, args = dateColumn.rangeCondition(entry.RangeStart, entry.RangeEnd)
+	}
+	if condition != "" {
+		query += " WHERE " + condition
+	}
+	query, args = filterQuery(entry.Filter, query, args, condition != "")
+
+	var count int64
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count query on %s failed: %w", entry.SourceTable, err)
+	}
+	return count, nil
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestValidateFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   error
+	}{
+		{"", nil},
+		{"deleted_at IS NULL AND tenant_id = 42", nil},
+		{"created_at > now() - interval '30 days'", nil},
+		{"note <> 'a;b -- c'", nil},
+		{"tenant_id = 42; DROP TABLE events", ErrFilterInvalid},
+		{"tenant_id = 42) OR (true", ErrFilterInvalid},
+		{"lower(name) = 'x' -- and more", ErrFilterInvalid},
+		{"id = $1", ErrFilterInvalid},
+		{"name = 'unterminated", ErrFilterInvalid},
+		{`x = E'\'') ; DROP TABLE t; SELECT '"' --"`, ErrFilterInvalid},
+		{`lower(name) = e'a\nb'`, ErrFilterInvalid},
+		{`lower(name) = 'a\'`, ErrFilterInvalid},
+		{"lower(name) = 'née'", nil},
+	}
+	for _, tt := range tests {
+		cfg := &Config{Filter: tt.filter}
+		if err := cfg.validateFilter(); !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+			t.Errorf("validateFilter(%q) = %v, want %v", tt.filter, err, tt.want)
+		}
+	}
+
+	cfg := &Config{Table: "events", Filter: "tenant_id = 42", TableQueries: map[string]string{"events": "SELECT * FROM {table} WHERE ts >= {start} AND ts < {end}"}}
+	if err := cfg.validateFilter(); !errors.Is(err, ErrFilterConflict) {
+		t.Errorf("validateFilter() with a custom query = %v, want ErrFilterConflict", err)
+	}
+}
+
+func TestFilterQueryParameterizesLiterals(t *testing.T) {
+	query, args := filterQuery("deleted_at IS NULL AND tenant_id = 42 AND region IN ('eu', 'us')", `SELECT "id" FROM "events" WHERE "id" >= $1 AND "id" < $2`, []interface{}{int64(0), int64(10)}, true)
+	want := `SELECT "id" FROM "events" WHERE "id" >= $1 AND "id" < $2 AND (("deleted_at" IS NULL) AND ("tenant_id" = $3) AND (("region" = $4) OR ("region" = $5)))`
+	if query != want {
+		t.Errorf("query = %s\nwant    %s", query, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{int64(0), int64(10), "42", "eu", "us"}) {
+		t.Errorf("args = %v", args)
+	}
+
+	// Conditions outside the --where subset are used as written
+	query, args = filterQuery("created_at > now() - interval '30 days'", `SELECT "id" FROM "events"`, nil, false)
+	if query != `SELECT "id" FROM "events" WHERE (created_at > now() - interval '30 days')` || len(args) != 0 {
+		t.Errorf("query = %s, args = %v", query, args)
+	}
+
+	if query, _ = filterQuery("", `SELECT "id" FROM "events"`, nil, false); query != `SELECT "id" FROM "events"` {
+		t.Errorf("query without --filter = %s", query)
+	}
+}
+
+func TestExtractPartitionDataStreamingAppliesFilter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	archiver := NewArchiver(&Config{
+		Table:        "events",
+		OutputFormat: "jsonl",
+		Compression:  "none",
+		DateColumn:   "created_at",
+		Filter:       "tenant_id = 42",
+	}, newTestLogger())
+	archiver.db = db
+	archiver.ctx = context.Background()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events_20240101").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).
+			AddRow("id", "bigint", "int8").
+			AddRow("tenant_id", "integer", "int4"))
+	mock.ExpectQuery(`SELECT "id", "tenant_id" FROM "events_20240101" WHERE "created_at" >= \$1 AND "created_at" < \$2 AND \("tenant_id" = \$3\)`).
+		WithArgs(start, end, "42").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id"}).AddRow(int64(1), int64(42)))
+
+	cache := &PartitionCache{Entries: make(map[string]PartitionCacheEntry)}
+	partition := PartitionInfo{TableName: "events_20240101", RowCount: 5}
+	path, _, _, _, rows, err := archiver.extractPartitionDataStreaming(partition, nil, cache, func(string) {}, start, end, 0, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("extraction failed: %v", err)
+	}
+	defer cleanupTempFile(path)
+	if rows != 1 {
+		t.Errorf("extracted %d rows, want 1", rows)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCountFilteredRows(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "events" WHERE "id" >= \$1 AND "id" < \$2 AND \("tenant_id" = \$3\)$`).
+		WithArgs(int64(0), int64(10), "42").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	entry := PartitionCacheEntry{SourceTable: "events", KeyColumn: "id", KeyStart: 0, KeyEnd: 10, Filter: "tenant_id = 42"}
+	count, err := countFilteredRows(context.Background(), db, entry, DateColumnSpec{})
+	if err != nil || count != 3 {
+		t.Errorf("countFilteredRows() = %d, %v", count, err)
+	}
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "events_20240101" WHERE \("deleted_at" IS NULL\)$`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(8))
+	entry = PartitionCacheEntry{SourceTable: "events_20240101", Filter: "deleted_at IS NULL"}
+	if count, err := countFilteredRows(context.Background(), db, entry, DateColumnSpec{}); err != nil || count != 8 {
+		t.Errorf("countFilteredRows() = %d, %v", count, err)
+	}
+}
@@ -0,0 +1,511 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	_ "modernc.org/sqlite" // Registers the "sqlite" database/sql driver
+)
+
+// Kinds of archived files in the index
+const (
+	IndexKindFile       = "file"
+	IndexKindPart       = "part"
+	IndexKindManifest   = "manifest"
+	IndexKindDictionary = "dictionary" // zstd dictionary stored alongside an archive
+)
+
+// indexSuffix names the uploaded index: <table>-index.sqlite next to the
+// table's schema artifacts
+const indexSuffix = "-index.sqlite"
+
+// Static errors for the archive index
+var (
+	ErrIndexEmpty = errors.New("no archived files found under the path template")
+)
+
+var (
+	indexTable  string
+	indexOutput string
+	indexUpload bool
+)
+
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Write a SQLite database listing the archived files of a table",
+	Long: `Build an inventory of the archived files of a table as a SQLite database, so the archive can be
+queried locally with standard SQL instead of S3 listings. Each file under the path template gets a row
+with the period it holds, its key, size, row count, checksums, format and schema version. Row counts and
+checksums come from split-archive manifests and from the local archive cache; values neither knows are NULL.
+With --upload, the database is also uploaded next to the table's schema artifacts.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		runIndex(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(indexCmd)
+
+	// S3 flags
+	indexCmd.Flags().StringVar(&s3Endpoint, "s3-endpoint", "", "S3-compatible endpoint URL")
+	indexCmd.Flags().StringVar(&s3Bucket, "s3-bucket", "", "S3 bucket name")
+	indexCmd.Flags().StringVar(&s3AccessKey, "s3-access-key", "", "S3 access key")
+	indexCmd.Flags().StringVar(&s3SecretKey, "s3-secret-key", "", "S3 secret key")
+	indexCmd.Flags().StringVar(&s3Region, "s3-region", "auto", "S3 region")
+
+	// Index-specific flags
+	indexCmd.Flags().StringVar(&indexTable, "table", "", "base table name (required)")
+	indexCmd.Flags().StringVar(&pathTemplate, "path-template", "", "S3 path template used when archiving (required)")
+	indexCmd.Flags().StringVar(&indexOutput, "output", "", "path of the SQLite database to write (default: <table>-index.sqlite)")
+	indexCmd.Flags().BoolVar(&indexUpload, "upload", false, "also upload the database as <table>-index.sqlite under the path template")
+
+	_ = viper.BindPFlag("index.output", indexCmd.Flags().Lookup("output"))
+	_ = viper.BindPFlag("index.upload", indexCmd.Flags().Lookup("upload"))
+}
+
+// IndexEntry is one archived file in the index
+type IndexEntry struct {
+	Key              string
+	Kind             string // file, part or manifest
+	PeriodStart      time.Time
+	PeriodEnd        time.Time
+	Size             int64
+	LastModified     time.Time
+	ETag             string
+	StorageClass     string
+	MD5              string        // Empty when unknown
+	Rows             sql.NullInt64 // Invalid when unknown; manifests have none, so sums count rows once
+	UncompressedSize int64         // 0 when unknown
+	Format           string
+	Compression      string
+	SchemaVersion    string
+}
+
+// buildArchiveIndex lists the table's archived files and describes each with
+// what the split manifests and the archive cache know about it. cache may be nil.
+func buildArchiveIndex(ctx context.Context, client StorageBackend, bucket, template, table string, cache *PartitionCache) ([]IndexEntry, error) {
+	matcher, err := newArchiveObjectMatcher(template, table)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []IndexEntry
+	err = client.List(ctx, bucket, matcher.prefix, func(object ObjectInfo) bool {
+		if start, end, ok := matcher.period(object.Key); ok {
+			entries = append(entries, newIndexEntry(object, start, end))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archived files: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+
+	byKey := make(map[string]*IndexEntry, len(entries))
+	for i := range entries {
+		byKey[entries[i].Key] = &entries[i]
+	}
+	if cache != nil {
+		for _, cached := range cache.Entries {
+			entry := byKey[cached.S3Key]
+			if entry == nil || !cached.S3Uploaded {
+				continue
+			}
+			entry.describe(cached)
+		}
+	}
+	for i := range entries {
+		if entries[i].Kind != IndexKindManifest {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		manifest, err := readSplitManifest(ctx, client, bucket, entries[i].Key)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range manifest.Parts {
+			if entry := byKey[part.Key]; entry != nil {
+				entry.describePart(manifest, part)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// newIndexEntry describes a listed archive object holding the period from
+// start to end with what its key and listing tell
+func newIndexEntry(object ObjectInfo, start, end time.Time) IndexEntry {
+	key := object.Key
+	entry := IndexEntry{
+		Key:          key,
+		Kind:         IndexKindFile,
+		PeriodStart:  start,
+		PeriodEnd:    end,
+		Size:         object.Size,
+		LastModified: object.LastModified,
+		ETag:         strings.Trim(object.ETag, "\""),
+		StorageClass: object.StorageClass,
+	}
+	switch {
+	case strings.HasSuffix(key, manifestSuffix):
+		entry.Kind = IndexKindManifest
+	case strings.HasSuffix(key, zstdDictionarySuffix):
+		entry.Kind = IndexKindDictionary
+	case partManifestKey(key) != "":
+		entry.Kind = IndexKindPart
+	}
+	if entry.StorageClass == "" {
+		entry.StorageClass = s3.StorageClassStandard
+	}
+	if entry.Kind != IndexKindManifest && entry.Kind != IndexKindDictionary {
+		if format, compression, err := detectFormatAndCompression(key, "", ""); err == nil {
+			entry.Format, entry.Compression = format, compression
+		}
+	}
+	// A single-part upload's ETag is its MD5
+	if entry.Kind != IndexKindManifest && entry.ETag != "" && !strings.Contains(entry.ETag, "-") {
+		entry.MD5 = entry.ETag
+	}
+	return entry
+}
+
+// describe fills in what the archive cache recorded about the file
+func (e *IndexEntry) describe(cached PartitionCacheEntry) {
+	if cached.FileMD5 != "" {
+		e.MD5 = cached.FileMD5
+	}
+	if cached.UncompressedSize > 0 {
+		e.UncompressedSize = cached.UncompressedSize
+	}
+	if cached.SourceTable != "" && e.Kind != IndexKindManifest {
+		e.Rows = sql.NullInt64{Int64: cached.ArchivedRowCount, Valid: true}
+	}
+	if cached.Format != "" {
+		e.Format, e.Compression = cached.Format, cached.Compression
+	}
+	e.SchemaVersion = cached.SchemaVersion
+}
+
+// describePart fills in what a split archive's manifest records about a part
+func (e *IndexEntry) describePart(manifest splitManifest, part manifestPart) {
+	e.Kind = IndexKindPart // --split-by-column files carry no -part- suffix
+	e.Rows = sql.NullInt64{Int64: part.Rows, Valid: true}
+	if part.MD5 != "" {
+		e.MD5 = part.MD5
+	}
+	if part.UncompressedSize > 0 {
+		e.UncompressedSize = part.UncompressedSize
+	}
+	e.Format, e.Compression = manifest.Format, manifest.Compression
+}
+
+// readSplitManifest downloads and decodes a split archive's manifest
+func readSplitManifest(ctx context.Context, client StorageBackend, bucket, key string) (splitManifest, error) {
+	var manifest splitManifest
+	body, _, err := client.Get(ctx, bucket, key, GetOptions{})
+	if err != nil {
+		return manifest, fmt.Errorf("failed to read manifest %s: %w", key, err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to read manifest %s: %w", key, err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("failed to parse manifest %s: %w", key, err)
+	}
+	return manifest, nil
+}
+
+// indexSchema creates the index database: a files table with one row per
+// archived file and an index_info table describing the run
+const indexSchema = `
+CREATE TABLE index_info (
+  name  TEXT PRIMARY KEY,
+  value TEXT NOT NULL
+);
+CREATE TABLE files (
+  key               TEXT PRIMARY KEY,
+  kind              TEXT NOT NULL,
+  period_start      TEXT NOT NULL,
+  period_end        TEXT NOT NULL,
+  size              INTEGER NOT NULL,
+  last_modified     TEXT,
+  etag              TEXT,
+  storage_class     TEXT,
+  md5               TEXT,
+  rows              INTEGER,
+  uncompressed_size INTEGER,
+  format            TEXT,
+  compression       TEXT,
+  schema_version    TEXT
+);
+CREATE INDEX files_period ON files (period_start, period_end);
+`
+
+// writeIndexDatabase writes the index of entries into a new SQLite database
+// at path, replacing it only once every row is written
+func writeIndexDatabase(ctx context.Context, path, table, bucket, template string, entries []IndexEntry, generated time.Time) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create index database: %w", err)
+	}
+	tempPath := temp.Name()
+	_ = temp.Close()
+	defer os.Remove(tempPath)
+
+	db, err := sql.Open("sqlite", tempPath)
+	if err != nil {
+		return fmt.Errorf("failed to open index database: %w", err)
+	}
+	if err := fillIndexDatabase(ctx, db, table, bucket, template, entries, generated); err != nil {
+		_ = db.Close()
+		return fmt.Errorf("failed to write index database: %w", err)
+	}
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to write index database: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		return fmt.Errorf("failed to write index database: %w", err)
+	}
+	return nil
+}
+
+// fillIndexDatabase creates the index tables in db and inserts the run's
+// description and one row per entry in a single transaction
+func fillIndexDatabase(ctx context.Context, db *sql.DB, table, bucket, template string, entries []IndexEntry, generated time.Time) error {
+	if _, err := db.ExecContext(ctx, "PRAGMA journal_mode = OFF"); err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, indexSchema); err != nil {
+		return err
+	}
+	info := [][2]string{
+		{"table", table},
+		{"bucket", bucket},
+		{"path_template", template},
+		{"generated_at", generated.UTC().Format(time.RFC3339)},
+		{"generator", "data-archiver " + Version},
+	}
+	for _, row := range info {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO index_info VALUES (?, ?)", row[0], row[1]); err != nil {
+			return err
+		}
+	}
+
+	insert, err := tx.PrepareContext(ctx, "INSERT INTO files VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	for _, e := range entries {
+		lastModified := ""
+		if !e.LastModified.IsZero() {
+			lastModified = e.LastModified.UTC().Format(time.RFC3339)
+		}
+		_, err := insert.ExecContext(ctx,
+			e.Key, e.Kind, e.PeriodStart.Format(time.RFC3339), e.PeriodEnd.Format(time.RFC3339),
+			e.Size, nullText(lastModified), nullText(e.ETag), nullText(e.StorageClass), nullText(e.MD5),
+			e.Rows, sql.NullInt64{Int64: e.UncompressedSize, Valid: e.UncompressedSize > 0},
+			nullText(e.Format), nullText(e.Compression), nullText(e.SchemaVersion))
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.Key, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// nullText returns s, or NULL when it is empty
+func nullText(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// uploadIndexDatabase uploads the database at path to key
+func uploadIndexDatabase(ctx context.Context, client StorageBackend, bucket, key, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open index database: %w", err)
+	}
+	defer file.Close()
+	_, err = client.Put(ctx, bucket, key, file, PutOptions{ContentType: "application/vnd.sqlite3"})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return nil
+}
+
+// schemaVersion returns a short fingerprint of the columns a partition was
+// extracted with, or "" when its schema was not queried this run. Files
+// written with the same column names and types share a version.
+func (a *Archiver) schemaVersion(table string) string {
+	schema, ok := a.schemas.get(table)
+	if !ok || schema == nil {
+		return ""
+	}
+	hash := sha256.New()
+	for _, column := range schema.Columns {
+		fmt.Fprintf(hash, "%s %s\n", column.Name, column.UDTName)
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:12]
+}
+
+// summarizeIndex returns the number of data files, their rows (when every
+// file's count is known) and their total size
+func summarizeIndex(entries []IndexEntry) (files int, rows int64, rowsKnown bool, size int64) {
+	rowsKnown = true
+	for _, e := range entries {
+		if e.Kind == IndexKindManifest || e.Kind == IndexKindDictionary {
+			continue
+		}
+		files++
+		size += e.Size
+		if !e.Rows.Valid {
+			rowsKnown = false
+		}
+		rows += e.Rows.Int64
+	}
+	return files, rows, rowsKnown, size
+}
+
+func runIndex(cmd *cobra.Command) {
+	getStringConfig := func(flagValue string, flagName string, viperKey string) string {
+		if flag := cmd.Flags().Lookup(flagName); flag != nil && flag.Changed {
+			return flagValue
+		}
+		if viperValue := viper.GetString(viperKey); viperValue != "" {
+			return viperValue
+		}
+		return flagValue
+	}
+
+	s3Config, s3Err := newS3Config(S3Config{
+		Endpoint:     getStringConfig(s3Endpoint, "s3-endpoint", "s3.endpoint"),
+		Bucket:       getStringConfig(s3Bucket, "s3-bucket", "s3.bucket"),
+		AccessKey:    getStringConfig(s3AccessKey, "s3-access-key", "s3.access_key"),
+		SecretKey:    getStringConfig(s3SecretKey, "s3-secret-key", "s3.secret_key"),
+		Region:       getStringConfig(s3Region, "s3-region", "s3.region"),
+		PathTemplate: getStringConfig(pathTemplate, "path-template", "s3.path_template"),
+	}, endpointProfileName("index"))
+	table := getStringConfig(indexTable, "table", "table")
+	output := viper.GetString("index.output")
+	if output == "" {
+		output = objectKeyComponent(table) + indexSuffix
+	}
+	upload := viper.GetBool("index.upload") && !viper.GetBool("dry_run")
+
+	initLogger(viper.GetBool("debug"), viper.GetString("log_format"))
+
+	logger.Info("")
+	logger.Info(fmt.Sprintf("🗂️  Archive Index v%s", Version))
+	logger.Info("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+	if s3Err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", s3Err.Error()))
+		os.Exit(1)
+	}
+	if err := validateIndexConfig(s3Config, table); err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	ctx := signalContext
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	client, err := newStorageClient(s3Config)
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ Failed to create storage client: %v", err))
+		os.Exit(1)
+	}
+
+	// The archive cache of the same table and bucket knows row counts and
+	// checksums of files archived from this machine
+	cache, err := loadPartitionCache(NewCacheScope("archive", &Config{Table: table, S3: s3Config}))
+	if err != nil {
+		logger.Debug(fmt.Sprintf("No archive cache to describe files with: %v", err))
+		cache = nil
+	}
+
+	logger.Info(fmt.Sprintf("Listing archived files of %s...", table))
+	entries, err := buildArchiveIndex(ctx, client, s3Config.Bucket, s3Config.PathTemplate, table, cache)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			logger.Info("")
+			logger.Info("⚠️  Index cancelled by user")
+			os.Exit(130)
+		}
+		logger.Error(fmt.Sprintf("❌ %v", err))
+		os.Exit(1)
+	}
+	if len(entries) == 0 {
+		logger.Error(fmt.Sprintf("❌ %v", ErrIndexEmpty))
+		os.Exit(1)
+	}
+
+	if err := writeIndexDatabase(ctx, output, table, s3Config.Bucket, s3Config.PathTemplate, entries, time.Now()); err != nil {
+		logger.Error(fmt.Sprintf("❌ %v", err))
+		os.Exit(1)
+	}
+	files, rows, rowsKnown, size := summarizeIndex(entries)
+	rowText := "rows unknown for some files"
+	if rowsKnown {
+		rowText = fmt.Sprintf("%s rows", formatNumberForSummary(rows))
+	}
+	logger.Info(fmt.Sprintf("  🗂️  Wrote %s: %d files, %s, %s", output, files, rowText, formatBytesForSummary(size)))
+
+	if upload {
+		key := schemaArtifactKey(s3Config.PathTemplate, table, indexSuffix)
+		if err := uploadIndexDatabase(ctx, client, s3Config.Bucket, key, output); err != nil {
+			logger.Error(fmt.Sprintf("❌ %v", err))
+			os.Exit(1)
+		}
+		logger.Info(fmt.Sprintf("  📤 Uploaded index to %s", key))
+	}
+
+	logger.Info("")
+	logger.Info("✅ Index completed successfully!")
+}
+
+// validateIndexConfig checks the S3 settings and the table
+func validateIndexConfig(s3Config S3Config, table string) error {
+	if s3Config.Bucket == "" {
+		return ErrS3BucketRequired
+	}
+	if err := s3Config.validateCredentials(); err != nil {
+		return err
+	}
+	if table == "" {
+		return ErrTableNameRequired
+	}
+	if s3Config.PathTemplate == "" {
+		return ErrPathTemplateRequired
+	}
+	if !strings.Contains(s3Config.PathTemplate, "{table}") {
+		return ErrRetentionTemplateInvalid
+	}
+	return nil
+}
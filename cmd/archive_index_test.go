@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newIndexTestStore(t *testing.T) *fakeObjectStore {
+	t.Helper()
+	manifest, err := splitManifest{
+		Table:       "events_20240102",
+		Format:      "jsonl",
+		Compression: "zstd",
+		TotalRows:   5,
+		Parts: []manifestPart{
+			{Part: 1, Key: "events/2024/01/02/events-2024-01-02-part-0001.jsonl.zst", Rows: 3, MD5: "part1md5"},
+			{Part: 2, Key: "events/2024/01/02/events-2024-01-02-part-0002.jsonl.zst", Rows: 2, MD5: "part2md5"},
+		},
+	}.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeObjectStore{objects: map[string][]byte{
+		"events/2024/01/01/events-2024-01-01.jsonl.zst":           []byte("day one"),
+		"events/2024/01/02/events-2024-01-02.manifest.json":       manifest,
+		"events/2024/01/02/events-2024-01-02-part-0001.jsonl.zst": []byte("part one"),
+		"events/2024/01/02/events-2024-01-02-part-0002.jsonl.zst": []byte("part two"),
+		"events/2024/01/03/other-2024-01-03.jsonl.zst":            []byte("not events"),
+		"events/events-schema.dump":                               []byte("CREATE TABLE"),
+	}}
+}
+
+func TestBuildArchiveIndex(t *testing.T) {
+	store := newIndexTestStore(t)
+	cache := &PartitionCache{Entries: map[string]PartitionCacheEntry{
+		"events_20240101": {
+			S3Key:            "events/2024/01/01/events-2024-01-01.jsonl.zst",
+			S3Uploaded:       true,
+			FileMD5:          "cachedmd5",
+			UncompressedSize: 700,
+			SourceTable:      "events_20240101",
+			ArchivedRowCount: 10,
+			SchemaVersion:    "0123456789ab",
+		},
+	}}
+
+	entries, err := buildArchiveIndex(context.Background(), newS3ObjectStore(store), "bucket", "{table}/{YYYY}/{MM}/{DD}", "events", cache)
+	if err != nil {
+		t.Fatalf("buildArchiveIndex() error = %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries: %+v", len(entries), entries)
+	}
+
+	day := entries[0]
+	if day.Kind != IndexKindFile || day.MD5 != "cachedmd5" || day.Rows.Int64 != 10 || !day.Rows.Valid ||
+		day.UncompressedSize != 700 || day.SchemaVersion != "0123456789ab" || day.Format != "jsonl" || day.Compression != "zstd" {
+		t.Errorf("cached file = %+v", day)
+	}
+	if !day.PeriodStart.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) || !day.PeriodEnd.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("period = %v - %v", day.PeriodStart, day.PeriodEnd)
+	}
+
+	// Parts are described by their manifest, which carries no rows itself
+	part, manifest := entries[1], entries[3]
+	if part.Kind != IndexKindPart || part.Rows.Int64 != 3 || part.MD5 != "part1md5" {
+		t.Errorf("part = %+v", part)
+	}
+	if manifest.Kind != IndexKindManifest || manifest.Rows.Valid || manifest.MD5 != "" || manifest.Format != "" {
+		t.Errorf("manifest = %+v", manifest)
+	}
+
+	files, rows, rowsKnown, _ := summarizeIndex(entries)
+	if files != 3 || rows != 15 || !rowsKnown {
+		t.Errorf("summarizeIndex() = %d files, %d rows, known %v", files, rows, rowsKnown)
+	}
+
+	// Without a cache, a file's checksum still comes from its ETag
+	entries, err = buildArchiveIndex(context.Background(), newS3ObjectStore(store), "bucket", "{table}/{YYYY}/{MM}/{DD}", "events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[0].MD5 == "" || entries[0].Rows.Valid {
+		t.Errorf("uncached file = %+v", entries[0])
+	}
+	if _, _, rowsKnown, _ := summarizeIndex(entries); rowsKnown {
+		t.Error("rows should be unknown for a file the cache does not describe")
+	}
+
+	if _, err := buildArchiveIndex(context.Background(), newS3ObjectStore(store), "bucket", "archives/{YYYY}", "events", nil); !errors.Is(err, ErrRetentionTemplateInvalid) {
+		t.Errorf("expected ErrRetentionTemplateInvalid, got %v", err)
+	}
+}
+
+func TestBuildArchiveIndexSplitByColumn(t *testing.T) {
+	manifest, err := splitManifest{
+		Table:       "events_20240102",
+		Format:      "jsonl",
+		Compression: "zstd",
+		TotalRows:   7,
+		Parts: []manifestPart{
+			{Part: 1, Key: "events/2024/01/02/tenant_id=42/events-2024-01-02.jsonl.zst", Split: "tenant_id=42", Rows: 4, MD5: "tenant42md5"},
+			{Part: 2, Key: "events/2024/01/02/tenant_id=7/events-2024-01-02.jsonl.zst", Split: "tenant_id=7", Rows: 3, MD5: "tenant7md5"},
+		},
+	}.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &fakeObjectStore{objects: map[string][]byte{
+		"events/2024/01/02/events-2024-01-02.manifest.json":          manifest,
+		"events/2024/01/02/tenant_id=42/events-2024-01-02.jsonl.zst": []byte("tenant 42"),
+		"events/2024/01/02/tenant_id=7/events-2024-01-02.jsonl.zst":  []byte("tenant 7"),
+	}}
+
+	entries, err := buildArchiveIndex(context.Background(), newS3ObjectStore(store), "bucket", "{table}/{YYYY}/{MM}/{DD}", "events", nil)
+	if err != nil {
+		t.Fatalf("buildArchiveIndex() error = %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries: %+v", len(entries), entries)
+	}
+	tenant := entries[1]
+	if tenant.Key != "events/2024/01/02/tenant_id=42/events-2024-01-02.jsonl.zst" || tenant.Kind != IndexKindPart || tenant.Rows.Int64 != 4 || tenant.MD5 != "tenant42md5" {
+		t.Errorf("per-tenant part = %+v", tenant)
+	}
+	if files, rows, rowsKnown, _ := summarizeIndex(entries); files != 2 || rows != 7 || !rowsKnown {
+		t.Errorf("summarizeIndex() = %d files, %d rows, known %v", files, rows, rowsKnown)
+	}
+}
+
+func TestWriteIndexDatabase(t *testing.T) {
+	store := newIndexTestStore(t)
+	entries, err := buildArchiveIndex(context.Background(), newS3ObjectStore(store), "bucket", "{table}/{YYYY}/{MM}/{DD}", "events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries[0].Key = "events/2024/01/01/o'brien.jsonl"
+
+	path := filepath.Join(t.TempDir(), "events-index.sqlite")
+	if err := writeIndexDatabase(context.Background(), path, "events", "bucket", "{table}/{YYYY}/{MM}/{DD}", entries, time.Now()); err != nil {
+		t.Fatalf("writeIndexDatabase() error = %v", err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files, md5s int
+	var rows sql.NullInt64
+	if err := db.QueryRow("SELECT count(*), sum(rows), count(md5) FROM files WHERE kind <> 'manifest'").Scan(&files, &rows, &md5s); err != nil {
+		t.Fatal(err)
+	}
+	if files != 3 || rows.Int64 != 5 || md5s != 3 {
+		t.Errorf("files = %d, rows = %v, md5s = %d", files, rows, md5s)
+	}
+	var key, table string
+	if err := db.QueryRow("SELECT key FROM files WHERE period_start = ?", "2024-01-01T00:00:00Z").Scan(&key); err != nil || key != "events/2024/01/01/o'brien.jsonl" {
+		t.Errorf("key = %q, %v", key, err)
+	}
+	if err := db.QueryRow("SELECT value FROM index_info WHERE name = 'table'").Scan(&table); err != nil || table != "events" {
+		t.Errorf("table = %q, %v", table, err)
+	}
+
+	// The upload lands next to the schema artifacts
+	key = schemaArtifactKey("{table}/{YYYY}/{MM}/{DD}", "events", indexSuffix)
+	if err := uploadIndexDatabase(context.Background(), newS3ObjectStore(store), "bucket", key, path); err != nil {
+		t.Fatal(err)
+	}
+	if data := store.objects["events/events-index.sqlite"]; !strings.HasPrefix(string(data), "SQLite format 3") {
+		t.Errorf("uploaded index starts with %q", data[:min(len(data), 16)])
+	}
+
+	// A failed write leaves the previous database in place
+	db.Close()
+	entries = append(entries, entries[0])
+	if err := writeIndexDatabase(context.Background(), path, "events", "bucket", "{table}/{YYYY}/{MM}/{DD}", entries, time.Now()); err == nil {
+		t.Error("expected duplicate keys to fail")
+	}
+	if db, err = sql.Open("sqlite", path); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.QueryRow("SELECT count(*) FROM files").Scan(&files); err != nil || files != len(entries)-1 {
+		t.Errorf("previous database has %d files, %v", files, err)
+	}
+}
+
+func TestArchiverSchemaVersion(t *testing.T) {
+	archiver := NewArchiver(&Config{Table: "events"}, newTestLogger())
+	if got := archiver.schemaVersion("events_20240101"); got != "" {
+		t.Errorf("schemaVersion() of an unknown schema = %q", got)
+	}
+	archiver.schemas.put("events_20240101", &TableSchema{Columns: []ColumnInfo{{Name: "id", UDTName: "int8"}}})
+	archiver.schemas.put("events_20240102", &TableSchema{Columns: []ColumnInfo{{Name: "id", UDTName: "int8"}}})
+	archiver.schemas.put("events_20240103", &TableSchema{Columns: []ColumnInfo{{Name: "id", UDTName: "text"}}})
+	first := archiver.schemaVersion("events_20240101")
+	if len(first) != 12 || first != archiver.schemaVersion("events_20240102") || first == archiver.schemaVersion("events_20240103") {
+		t.Errorf("schema versions = %q, %q, %q", first, archiver.schemaVersion("events_20240102"), archiver.schemaVersion("events_20240103"))
+	}
+}
@@ -3,26 +3,19 @@ package cmd
 import (
 	"bytes"
 	"context"
-	"crypto/md5" //nolint:gosec // MD5 used for checksums, not cryptography
 	"database/sql"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"hash"
 	"io"
 	"log/slog"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/airframesio/data-archiver/cmd/compressors"
 	"github.com/airframesio/data-archiver/cmd/formatters"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/lib/pq"
@@ -40,7 +33,6 @@ var (
 	ErrInsufficientPermissions         = errors.New("insufficient permissions to read table")
 	ErrPartitionNoPermissions          = errors.New("partition tables exist but you don't have SELECT permissions")
 	ErrS3ClientNotInitialized          = errors.New("S3 client not initialized")
-	ErrS3UploaderNotInitialized        = errors.New("S3 uploader not initialized")
 	errPartitionlessDateColumnRequired = errors.New("partitionless tables require --date-column")
 	errPartitionlessDateRangeRequired  = errors.New("partitionless tables require both --start-date and --end-date")
 )
@@ -124,21 +116,45 @@ func createTempFile() (*os.File, error) {
 	return os.CreateTemp(getTempDir(), "data-archiver-*.tmp")
 }
 
-// cleanupTempFile removes a temporary file, ignoring errors if it doesn't exist
+// cleanupTempFile removes a temporary file and its zstd dictionary, ignoring
+// errors if they don't exist
 func cleanupTempFile(path string) {
 	if path != "" {
 		_ = os.Remove(path) // Ignore error - file might already be removed
+		_ = os.Remove(path + zstdDictionarySuffix)
 	}
 }
 
 type Archiver struct {
 	config       *Config
 	db           *sql.DB
-	s3Client     *s3.S3
-	s3Uploader   *s3manager.Uploader
+	s3Client     StorageBackend
 	progressChan chan tea.Cmd
 	logger       *slog.Logger
-	ctx          context.Context // Context for cancellation
+	ctx          context.Context        // Context for cancellation
+	compression  *compressionController // Non-nil when --adaptive-compression is enabled
+	bandwidth    *bandwidthLimiter      // Non-nil when the table's quota or --max-upload-rate limits upload bandwidth
+	rowRate      *bandwidthLimiter      // Rows read per second across the run (nil = --max-db-rows-per-sec off)
+	results      *resultsLog            // ndjson log of every finished partition (nil = not recording)
+	cpu          *cpuAccountant         // Per-stage CPU time (nil when the platform can't report it)
+	usage        *usageTally            // Uploads not yet added to the usage ledger (nil = --usage-ledger off)
+	integrity    *integrityLedger       // Hash-chained record of uploaded files (nil = --integrity-ledger off)
+	manifest     *runManifest           // Files uploaded this run (nil = --run-manifest off)
+	plain        *plainProgress         // --progress plain output (nil = TUI or logs only)
+	events       *progressEventLog      // --progress-file events (nil = not recording)
+	migration    *formatMigrationPlan   // Objects kept or re-archived by --format-migration (nil = none)
+	hooks        *invalidationHooks     // Told about each date's uploads (nil = no hooks configured)
+	heads        *objectHeadCache       // Existence checks made this run
+	etags        *tempFileETags         // Multipart ETags computed while temp files were written
+	labels       *uploadLabels          // Tag and metadata placeholders of the files being uploaded
+	s3Requests   *s3RequestCounts       // Requests sent to S3 (nil = not an S3 client)
+	ratios       *ratioNorm             // Compression ratios of the table's files, to flag anomalies
+	controls     operatorControls       // The terminal UI's skip and retry keys
+	schemas      *partitionSchemaCache  // Column lists of the partitions extracted this run
+	largeObjects sync.Map               // OIDs exported or found in S3 this run (--include-large-objects)
+
+	permissionDenied []PartitionInfo // Discovered partitions skipped for lack of SELECT permission
+	permissionLogged int             // permissionDenied entries already recorded in the results log
 }
 
 type PartitionInfo struct {
@@ -147,6 +163,9 @@ type PartitionInfo struct {
 	RowCount   int64
 	RangeStart time.Time
 	RangeEnd   time.Time
+	Keys       KeyRange  // Key range of a table sliced by --split-column (zero = not a key range)
+	Increment  Increment // Rows past the table's watermark with --watermark-column (zero = not an increment)
+	Members    []string  // Partitions combined into one longer --output-duration period (nil = not combined)
 }
 
 func (p PartitionInfo) HasCustomRange() bool {
@@ -154,31 +173,72 @@ func (p PartitionInfo) HasCustomRange() bool {
 }
 
 type ProcessResult struct {
-	Partition    PartitionInfo
-	Compressed   bool
-	Uploaded     bool
-	Skipped      bool
-	SkipReason   string
-	Error        error
-	BytesWritten int64
-	Stage        string
-	S3Key        string        // S3 object key for uploaded file
-	StartTime    time.Time     // When partition processing started
-	Duration     time.Duration // How long partition processing took
+	Partition        PartitionInfo
+	Compressed       bool
+	Uploaded         bool
+	Skipped          bool
+	SkipReason       string
+	Error            error
+	BytesWritten     int64
+	Stage            string
+	S3Key            string              // S3 object key for uploaded file
+	DateCheck        *partitionDateCheck // Partition date cross-check (nil when not run)
+	PermissionDenied bool                // Skipped because the partition lacks SELECT permission
+	ValueIssues      valueIssueCounts    // Values fixed or rows quarantined by --invalid-values
+	Queries          []extractionQuery   // Extraction SQL of each archive file (--record-sql)
+	Ratios           []compressionRatio  // Compression ratio of each archive file written
+	StartTime        time.Time           // When partition processing started
+	Duration         time.Duration       // How long partition processing took
 }
 
 func NewArchiver(config *Config, logger *slog.Logger) *Archiver {
 	if (config.CacheScope == CacheScope{}) {
 		config.CacheScope = NewCacheScope("archive", config)
 	}
-	return &Archiver{
+	archiver := &Archiver{
 		config:       config,
 		progressChan: make(chan tea.Cmd, 100),
 		logger:       logger,
+		cpu:          cpuUsage,
+		heads:        newObjectHeadCache(),
+		etags:        newTempFileETags(),
+		labels:       newUploadLabels(),
+		schemas:      newPartitionSchemaCache(),
 	}
+	archiver.plain = newArchivePlainProgress(config)
+	archiver.ratios = newRatioNorm(config.RatioAnomalyFactor, archiver.archiveFormat())
+	if config.AdaptiveCompression {
+		archiver.compression = newCompressionController(config)
+	}
+	archiver.bandwidth = archiver.uploadLimiter()
+	archiver.rowRate = sharedLimiter(&processRateLimit.rows, config.MaxDBRowsPerSec)
+	if config.UsageLedger {
+		archiver.usage = newUsageTally()
+	}
+	if config.IntegrityLedger {
+		archiver.integrity = newIntegrityLedger(config)
+	}
+	if config.RunManifest {
+		archiver.manifest = newRunManifest(config, time.Now())
+	}
+	if config.Hooks.enabled() {
+		archiver.hooks = newInvalidationHooks(config, logger)
+	}
+	return archiver
+}
+
+// compressionLevel returns the level to compress the next file with, or 0 when
+// no external compression is applied
+func (a *Archiver) compressionLevel() int {
+	if a.config.Compression == "none" || formatters.UsesInternalCompression(a.config.OutputFormat) {
+		return 0
+	}
+	if a.compression != nil {
+		return a.compression.Level()
+	}
+	return a.config.CompressionLevel
 }
 
-//nolint:gocognit // complex orchestration function
 func (a *Archiver) Run(ctx context.Context) error {
 	// Store context for cancellation checks during processing
 	a.ctx = ctx
@@ -191,6 +251,44 @@ func (a *Archiver) Run(ctx context.Context) error {
 		_ = RemovePIDFile()
 	}()
 
+	return a.archiveTable(ctx, a.runWithProgress)
+}
+
+// archiveTable wraps one table's archive with the steps every run shares,
+// whether it archives a single table or one of several with --tables
+func (a *Archiver) archiveTable(ctx context.Context, archive func(context.Context) error) (runErr error) {
+	// Record each result as it completes so a crashed run keeps its partial summary
+	a.startResultsLog("archive")
+	a.emitEvent(progressEvent{Type: progressEventRunStart})
+	defer func() {
+		a.flushUsageLedger()
+		a.flushIntegrityLedger()
+		a.writeRunManifest()
+		a.updateTableFormat()
+		a.purgeExpiredTrash()
+		a.auditCache()
+		a.finishResultsLog(runErr)
+		a.emitRunEnd(runErr)
+	}()
+
+	// Objects archived in another format are reported, converted, kept, or
+	// queued for re-archiving before any partition is processed
+	if err := a.prepareFormatMigration(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if runErr == nil {
+			a.finishFormatMigration(ctx)
+		}
+	}()
+
+	return archive(ctx)
+}
+
+// runWithProgress archives the table, showing progress in the TUI or as plain output
+//
+//nolint:gocognit // complex orchestration function
+func (a *Archiver) runWithProgress(ctx context.Context) error {
 	// Initialize task info
 	taskInfo := &TaskInfo{
 		PID:         os.Getpid(),
@@ -209,9 +307,11 @@ func (a *Archiver) Run(ctx context.Context) error {
 	errChan := make(chan error, 1)
 	resultsChan := make(chan []ProcessResult, 1)
 
-	// In debug mode, skip the TUI and run with simple text output
-	if a.config.Debug {
-		a.logger.Info("Running in debug mode - TUI disabled for better log visibility")
+	// In debug, quiet and plain progress modes, skip the TUI and run with simple text output
+	if !a.config.useTUI() {
+		if a.config.Debug {
+			a.logger.Info("Running in debug mode - TUI disabled for better log visibility")
+		}
 
 		// Track goroutine completion separately from errors
 		done := make(chan struct{})
@@ -330,6 +430,7 @@ func (a *Archiver) runArchivalProcess(ctx context.Context, _ *tea.Program, _ *Ta
 	}()
 
 	a.logger.Debug("Connecting to database...")
+	a.emitPhase(PhaseConnecting)
 	if err := a.connect(ctx); err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -339,6 +440,7 @@ func (a *Archiver) runArchivalProcess(ctx context.Context, _ *tea.Program, _ *Ta
 	}
 
 	a.logger.Debug("Checking table permissions...")
+	a.emitPhase(PhaseCheckingPermissions)
 	if err := a.checkTablePermissions(ctx); err != nil {
 		return fmt.Errorf("permission check failed: %w", err)
 	}
@@ -346,9 +448,210 @@ func (a *Archiver) runArchivalProcess(ctx context.Context, _ *tea.Program, _ *Ta
 		a.logger.Info("✅ Table permissions verified")
 	}
 
+	// The schema goes up before any data, so every archive has one to restore with
+	status, err := a.exportSchema(ctx)
+	if err != nil {
+		return fmt.Errorf("schema export failed: %w", err)
+	}
+	if status != "" {
+		a.logger.Info(status)
+	}
+
+	a.logTimezones()
+	a.logRowLimit()
+
 	a.logger.Debug("Discovering partitions...")
+	a.emitPhase(PhaseDiscovering)
+	partitions, err := a.findPartitions(ctx)
+	if err != nil {
+		return err
+	}
+
+	a.logger.Info(fmt.Sprintf("✅ Found %d partitions", len(partitions)))
+	planned, err := a.applyStartFrom(partitions)
+	if err != nil {
+		return err
+	}
+	if skipped := len(partitions) - len(planned); skipped > 0 {
+		a.logger.Info(fmt.Sprintf("⏩ Starting from %s: skipping %d planned partition(s)", a.config.startFromLabel(), skipped))
+	}
+	partitions = planned
+	a.plain.setTotal(len(partitions))
+
+	a.logger.Debug("Processing partitions...")
+	a.emitPhase(PhaseProcessing)
+	results := make([]ProcessResult, 0, len(partitions))
+	var resultsMu sync.Mutex
+	startTime := time.Now()
+
+	// Ensure summary is printed even on cancellation
+	defer func() {
+		resultsMu.Lock()
+		defer resultsMu.Unlock()
+		if len(results) > 0 {
+			a.printSummary(results, startTime, len(partitions))
+		}
+	}()
+
+	// The table's quota bounds how many partitions are processed at once
+	parallel := max(a.config.Quota.MaxParallelPartitions, 1)
+	if parallel > 1 {
+		a.logger.Info(fmt.Sprintf("Processing up to %d partitions in parallel", parallel))
+	}
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	rediscovery := a.newPartitionRediscovery(partitions)
+
+	for i := 0; i < len(partitions); i++ {
+		partition := partitions[i]
+		// Hold new partitions back while the run is paused
+		if err := a.waitWhilePaused(ctx, partition.TableName); err != nil {
+			a.logger.Info("⚠️  Stopping partition processing due to cancellation")
+			wg.Wait()
+			return err
+		}
+		// Check if context was cancelled
+		select {
+		case <-ctx.Done():
+			a.logger.Info("⚠️  Stopping partition processing due to cancellation")
+			wg.Wait()
+			return ctx.Err()
+		case slots <- struct{}{}:
+			// Continue processing
+		}
+
+		wg.Add(1)
+		go func(partition PartitionInfo) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			a.logger.Info(fmt.Sprintf("Processing partition: %s", partition.TableName))
+			result := a.ProcessPartitionWithProgress(partition, nil)
+
+			resultsMu.Lock()
+			results = append(results, result)
+			resultsMu.Unlock()
+
+			if result.Error != nil {
+				a.logger.Error(fmt.Sprintf("   ❌ %s failed: %v", partition.TableName, result.Error))
+			} else if result.Skipped {
+				a.logger.Info(fmt.Sprintf("   ⏭️  %s skipped: %s", partition.TableName, result.SkipReason))
+			} else {
+				a.logger.Info(fmt.Sprintf("   ✅ %s: %d bytes", partition.TableName, result.BytesWritten))
+			}
+		}(partition)
+
+		// Partitions created since discovery join the run. The final pass
+		// waits for in-flight partitions so it looks as late as possible.
+		final := i == len(partitions)-1
+		if final && rediscovery != nil {
+			wg.Wait()
+		}
+		found, err := a.rediscoverPartitions(ctx, rediscovery, final)
+		if err != nil {
+			wg.Wait()
+			return err
+		}
+		partitions = append(partitions, found...)
+		if len(found) > 0 {
+			a.plain.setTotal(len(partitions))
+		}
+	}
+	wg.Wait()
+
+	a.emitPhase(PhaseComplete)
+	a.logger.Info("✅ All partitions processed")
+	return nil
+}
+
+// findPartitions discovers the partitions of the base table, falling back to
+// a single date-range partition when the table is not partitioned
+func (a *Archiver) findPartitions(ctx context.Context) ([]PartitionInfo, error) {
+	if a.config.WatermarkColumn != "" {
+		return a.findIncrement(ctx)
+	}
+
+	partitions, err := a.listPartitions(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.finishPermissionChecks(); err != nil {
+		return nil, err
+	}
+	if err := a.prepareDiscovered(ctx, partitions); err != nil {
+		a.logger.Info("No partitions found to archive")
+		return nil, fmt.Errorf("partitionless fallback unavailable: %w", err)
+	}
+
+	if a.config.SplitColumn != "" {
+		if len(partitions) == 0 {
+			return a.findKeyRanges(ctx)
+		}
+		a.logger.Warn(fmt.Sprintf("⚠️  Table %s is partitioned; --split-column only slices tables that are not", a.config.Table))
+	}
+
+	if len(partitions) == 0 {
+		fallbackPartitions, fallbackErr := a.buildDateRangePartition()
+		if fallbackErr != nil {
+			a.logger.Info("No partitions found to archive")
+			return nil, fmt.Errorf("partitionless fallback unavailable: %w", fallbackErr)
+		}
+		partitions = fallbackPartitions
+		inclusiveEnd := partitions[0].RangeEnd.Add(-24 * time.Hour).Format("2006-01-02")
+		sliceBy := a.config.DateColumn
+		if a.config.customQuery() != "" {
+			sliceBy = "the custom query"
+		}
+		a.logger.Info(fmt.Sprintf("ℹ️  Table %s is not partitioned; slicing %s → %s via %s windows using %s",
+			a.config.Table,
+			partitions[0].RangeStart.Format("2006-01-02"),
+			inclusiveEnd,
+			a.config.OutputDuration,
+			sliceBy))
+	}
+
+	return a.aggregatePartitions(ctx, partitions)
+}
+
+// prepareDiscovered resolves --output-duration auto for the discovered
+// partitions and finds the date column they need: the one to slice a
+// partitionless table by, or the one to split partitions longer than the
+// output duration by. Both discovery paths call it before planning any work.
+func (a *Archiver) prepareDiscovered(ctx context.Context, partitions []PartitionInfo) error {
+	a.resolveOutputDuration(ctx, partitions)
+	if len(partitions) == 0 {
+		if a.config.SplitColumn == "" && a.config.Table != "" && a.config.StartDate != "" && a.config.EndDate != "" {
+			return a.ensureDateColumn(ctx, "")
+		}
+		return nil
+	}
+	if a.config.canSliceByTime() {
+		return nil
+	}
+	for _, partition := range partitions {
+		if !a.shouldSplitPartition(partition) {
+			continue
+		}
+		if err := a.ensureDateColumn(ctx, partition.TableName); err != nil {
+			a.logger.Warn(fmt.Sprintf("⚠️  Partitions need splitting into %s files: %v", a.config.OutputDuration, err))
+		}
+		break
+	}
+	return nil
+}
+
+// listPartitions lists the base table's readable partitions (and matching
+// non-partition tables when enabled), skipping tables in known. Unreadable
+// partitions are noted for finishPermissionChecks. Tables outside the date
+// range are added to known, since no later pass of this run can archive them.
+func (a *Archiver) listPartitions(ctx context.Context, known map[string]bool) ([]PartitionInfo, error) {
 	var partitions []PartitionInfo
 	seenTables := make(map[string]bool) // Track tables to avoid duplicates
+	outOfRange := 0
+	for tableName := range known {
+		seenTables[tableName] = true
+	}
 
 	// Helper function to process tables from a query result
 	processTableRows := func(rows *sql.Rows, sourceType string) error {
@@ -379,6 +682,29 @@ func (a *Archiver) runArchivalProcess(ctx context.Context, _ *tea.Program, _ *Ta
 				continue
 			}
 
+			// Filter by date before the per-table queries, so a run for one
+			// week doesn't check years of partitions
+			if !a.inDateRange(date) {
+				if known != nil {
+					known[tableName] = true
+				}
+				outOfRange++
+				continue
+			}
+
+			// Check if we have SELECT permission on the table. This comes before
+			// the schema check because information_schema hides the columns of
+			// tables we cannot read.
+			hasPermission, err := a.partitionReadable(ctx, tableName)
+			if err != nil {
+				a.logger.Debug(fmt.Sprintf("Skipping table %s (permission check failed: %v)", tableName, err))
+				continue
+			}
+			if !hasPermission {
+				a.notePermissionDenied(PartitionInfo{TableName: tableName, Date: date})
+				continue
+			}
+
 			// Validate that the table actually has columns before adding it
 			// This prevents errors later when trying to process tables that exist
 			// but have no columns or aren't valid tables
@@ -392,18 +718,6 @@ func (a *Archiver) runArchivalProcess(ctx context.Context, _ *tea.Program, _ *Ta
 				continue
 			}
 
-			// Check if we have SELECT permission on the table
-			var hasPermission bool
-			checkPermissionQuery := `SELECT has_table_privilege('public.' || $1, 'SELECT')`
-			if err := a.db.QueryRowContext(ctx, checkPermissionQuery, tableName).Scan(&hasPermission); err != nil {
-				a.logger.Debug(fmt.Sprintf("Skipping table %s (permission check failed: %v)", tableName, err))
-				continue
-			}
-			if !hasPermission {
-				a.logger.Debug(fmt.Sprintf("Skipping table %s (no SELECT permission)", tableName))
-				continue
-			}
-
 			partitions = append(partitions, PartitionInfo{
 				TableName: tableName,
 				Date:      date,
@@ -413,90 +727,41 @@ func (a *Archiver) runArchivalProcess(ctx context.Context, _ *tea.Program, _ *Ta
 	}
 
 	// Query actual partitions
-	rows, err := a.db.QueryContext(ctx, leafPartitionListSQL, defaultTableSchema, a.config.Table)
+	rows, err := a.db.QueryContext(ctx, leafPartitionListSQL, defaultTableSchema, a.config.Table, pq.Array(a.config.partitionRelkinds()))
 	if err != nil {
 		// Check if error is due to cancellation or closed connection
 		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || isConnectionError(err) {
 			a.logger.Info("⚠️  Query cancelled or connection closed")
-			return context.Canceled
+			return nil, context.Canceled
 		}
-		return fmt.Errorf("failed to query partitions: %w", err)
+		return nil, fmt.Errorf("failed to query partitions: %w", err)
 	}
 	if err := processTableRows(rows, "partition"); err != nil {
-		return fmt.Errorf("error iterating over partition rows: %w", err)
+		return nil, fmt.Errorf("error iterating over partition rows: %w", err)
 	}
 
-	// If enabled, also query non-partition tables matching the pattern
-	if a.config.IncludeNonPartitionTables {
-		a.logger.Debug("Including non-partition tables matching pattern...")
-		nonPartitionRows, err := a.db.QueryContext(ctx, nonPartitionTableListSQL, defaultTableSchema, a.config.Table)
+	// If enabled, also query non-partition tables, materialized views, and
+	// foreign tables matching the pattern
+	if relkinds := a.config.standaloneRelkinds(); len(relkinds) > 0 {
+		a.logger.Debug("Including non-partition relations matching pattern...")
+		nonPartitionRows, err := a.db.QueryContext(ctx, nonPartitionTableListSQL, defaultTableSchema, a.config.Table, pq.Array(relkinds))
 		if err != nil {
 			// Check if error is due to cancellation or closed connection
 			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || isConnectionError(err) {
 				a.logger.Info("⚠️  Query cancelled or connection closed")
-				return context.Canceled
+				return nil, context.Canceled
 			}
-			return fmt.Errorf("failed to query non-partition tables: %w", err)
+			return nil, fmt.Errorf("failed to query non-partition tables: %w", err)
 		}
 		if err := processTableRows(nonPartitionRows, "non-partition table"); err != nil {
-			return fmt.Errorf("error iterating over non-partition table rows: %w", err)
-		}
-	}
-
-	if len(partitions) == 0 {
-		fallbackPartitions, fallbackErr := a.buildDateRangePartition()
-		if fallbackErr != nil {
-			a.logger.Info("No partitions found to archive")
-			return fmt.Errorf("partitionless fallback unavailable: %w", fallbackErr)
+			return nil, fmt.Errorf("error iterating over non-partition table rows: %w", err)
 		}
-		partitions = fallbackPartitions
-		inclusiveEnd := partitions[0].RangeEnd.Add(-24 * time.Hour).Format("2006-01-02")
-		a.logger.Info(fmt.Sprintf("ℹ️  Table %s is not partitioned; slicing %s → %s via %s windows using %s",
-			a.config.Table,
-			partitions[0].RangeStart.Format("2006-01-02"),
-			inclusiveEnd,
-			a.config.OutputDuration,
-			a.config.DateColumn))
 	}
 
-	a.logger.Info(fmt.Sprintf("✅ Found %d partitions", len(partitions)))
-
-	a.logger.Debug("Processing partitions...")
-	results := make([]ProcessResult, 0, len(partitions))
-	startTime := time.Now()
-
-	// Ensure summary is printed even on cancellation
-	defer func() {
-		if len(results) > 0 {
-			a.printSummary(results, startTime, len(partitions))
-		}
-	}()
-
-	for _, partition := range partitions {
-		// Check if context was cancelled
-		select {
-		case <-ctx.Done():
-			a.logger.Info("⚠️  Stopping partition processing due to cancellation")
-			return ctx.Err()
-		default:
-			// Continue processing
-		}
-
-		a.logger.Info(fmt.Sprintf("Processing partition: %s", partition.TableName))
-		result := a.ProcessPartitionWithProgress(partition, nil)
-		results = append(results, result)
-
-		if result.Error != nil {
-			a.logger.Error(fmt.Sprintf("   ❌ Failed: %v", result.Error))
-		} else if result.Skipped {
-			a.logger.Info(fmt.Sprintf("   ⏭️  Skipped: %s", result.SkipReason))
-		} else {
-			a.logger.Info(fmt.Sprintf("   ✅ Success: %d bytes", result.BytesWritten))
-		}
+	if outOfRange > 0 {
+		a.logger.Debug(fmt.Sprintf("Skipped %d table(s) outside the date range", outOfRange))
 	}
-
-	a.logger.Info("✅ All partitions processed")
-	return nil
+	return partitions, nil
 }
 
 func (a *Archiver) connect(ctx context.Context) error {
@@ -522,6 +787,10 @@ func (a *Archiver) connect(ctx context.Context) error {
 		a.logger.Debug(fmt.Sprintf("  📝 Configured statement timeout: %d seconds (%d ms)",
 			a.config.Database.StatementTimeout, timeoutMs))
 	}
+	if params := a.config.Database.sessionParams(); params != "" {
+		connStr += params
+		a.logger.Debug(fmt.Sprintf("  📝 Session settings:%s", params))
+	}
 
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
@@ -535,20 +804,23 @@ func (a *Archiver) connect(ctx context.Context) error {
 
 	a.db = db
 
-	sess, err := session.NewSession(&aws.Config{
-		Endpoint:         aws.String(a.config.S3.Endpoint),
-		Region:           aws.String(a.config.S3.Region),
-		Credentials:      credentials.NewStaticCredentials(a.config.S3.AccessKey, a.config.S3.SecretKey, ""),
-		S3ForcePathStyle: aws.Bool(true),
-	})
+	client, err := newStorageClient(a.config.S3)
 	if err != nil {
 		db.Close()
 		a.db = nil
-		return fmt.Errorf("failed to create S3 session: %w", err)
+		return fmt.Errorf("failed to create storage client: %w", err)
 	}
 
-	a.s3Client = s3.New(sess)
-	a.s3Uploader = s3manager.NewUploader(sess)
+	a.s3Client = client
+	a.s3Requests = countS3Requests(client)
+
+	if a.hooks != nil {
+		if err := a.hooks.connect(a.config.S3); err != nil {
+			db.Close()
+			a.db = nil
+			return err
+		}
+	}
 
 	return nil
 }
@@ -604,14 +876,7 @@ func (a *Archiver) checkTablePermissions(ctx context.Context) error {
 	// Check if we have permission to SELECT from the base table (if it exists)
 	// First check if the table exists
 	var tableExists bool
-	existsQuery := `
-		SELECT EXISTS (
-			SELECT 1 FROM pg_tables
-			WHERE schemaname = 'public'
-			AND tablename = $1
-		)
-	`
-	err := a.db.QueryRowContext(ctx, existsQuery, a.config.Table).Scan(&tableExists)
+	err := a.db.QueryRowContext(ctx, relationExistsSQL, defaultTableSchema, a.config.Table, pq.Array(a.config.baseRelkinds())).Scan(&tableExists)
 	if err != nil {
 		return fmt.Errorf("failed to check if table exists: %w", err)
 	}
@@ -619,7 +884,7 @@ func (a *Archiver) checkTablePermissions(ctx context.Context) error {
 	// If table exists, check permissions
 	if tableExists {
 		var hasPermission bool
-		checkPermissionQuery := `SELECT has_table_privilege('public.' || $1, 'SELECT')`
+		checkPermissionQuery := `SELECT has_table_privilege('public.' || quote_ident($1), 'SELECT')`
 		err = a.db.QueryRowContext(ctx, checkPermissionQuery, a.config.Table).Scan(&hasPermission)
 		if err != nil {
 			return fmt.Errorf("failed to check table permissions: %w", err)
@@ -632,7 +897,7 @@ func (a *Archiver) checkTablePermissions(ctx context.Context) error {
 
 	// Check if we can see and access partition tables
 	var samplePartition string
-	err = a.db.QueryRowContext(ctx, leafPartitionPermissionSQL, defaultTableSchema, a.config.Table).Scan(&samplePartition)
+	err = a.db.QueryRowContext(ctx, leafPartitionPermissionSQL, defaultTableSchema, a.config.Table, pq.Array(a.config.partitionRelkinds())).Scan(&samplePartition)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		// Only fail if it's not a "no rows" error
 		return fmt.Errorf("failed to check partition table permissions: %w", err)
@@ -642,7 +907,7 @@ func (a *Archiver) checkTablePermissions(ctx context.Context) error {
 	if errors.Is(err, sql.ErrNoRows) {
 		// Let's see if partitions exist but we can't access them
 		var partitionExists bool
-		if err := a.db.QueryRowContext(ctx, leafPartitionExistsSQL, defaultTableSchema, a.config.Table).Scan(&partitionExists); err != nil {
+		if err := a.db.QueryRowContext(ctx, leafPartitionExistsSQL, defaultTableSchema, a.config.Table, pq.Array(a.config.partitionRelkinds())).Scan(&partitionExists); err != nil {
 			return fmt.Errorf("failed to check for partition existence: %w", err)
 		}
 
@@ -683,10 +948,10 @@ func (a *Archiver) discoverPartitionsWithUI(program *tea.Program) ([]PartitionIn
 
 	var startDate, endDate time.Time
 	if a.config.StartDate != "" {
-		startDate, _ = time.Parse("2006-01-02", a.config.StartDate)
+		startDate, _ = a.parseDate(a.config.StartDate)
 	}
 	if a.config.EndDate != "" {
-		endDate, _ = time.Parse("2006-01-02", a.config.EndDate)
+		endDate, _ = a.parseDate(a.config.EndDate)
 	}
 
 	for rows.Next() {
@@ -874,56 +1139,25 @@ func (a *Archiver) discoverPartitions() ([]PartitionInfo, error) {
 */
 
 func (a *Archiver) extractDateFromTableName(tableName string) (time.Time, bool) {
-	baseTableLen := len(a.config.Table)
-	if len(tableName) <= baseTableLen+1 {
-		return time.Time{}, false
-	}
-
-	// Remove base table name and underscore
-	suffix := tableName[baseTableLen+1:]
-
-	// Format 1: {base_table}_YYYYMMDD (8 digits)
-	if len(suffix) == 8 {
-		if date, err := time.Parse("20060102", suffix); err == nil {
-			return date, true
-		}
-	}
-
-	// Format 2: {base_table}_pYYYYMMDD (p + 8 digits)
-	if len(suffix) == 9 && suffix[0] == 'p' {
-		if date, err := time.Parse("20060102", suffix[1:]); err == nil {
-			return date, true
-		}
-	}
-
-	// Format 3: {base_table}_YYYY_MM (7 chars with underscore)
-	if len(suffix) == 7 && suffix[4] == '_' {
-		yearMonth := suffix[:4] + suffix[5:]
-		// Use first day of the month for monthly partitions
-		if date, err := time.Parse("200601", yearMonth); err == nil {
-			return date, true
-		}
-	}
-
-	return time.Time{}, false
+	return partitionNameDate(a.config.Table, tableName, a.config.Timezone.sliceLocation())
 }
 
 func (a *Archiver) buildDateRangePartition() ([]PartitionInfo, error) {
 	if a.config.Table == "" {
 		return nil, ErrTableNameRequired
 	}
-	if a.config.DateColumn == "" {
+	if !a.config.canSliceByTime() {
 		return nil, errPartitionlessDateColumnRequired
 	}
 	if a.config.StartDate == "" || a.config.EndDate == "" {
 		return nil, errPartitionlessDateRangeRequired
 	}
 
-	start, err := time.Parse("2006-01-02", a.config.StartDate)
+	start, err := a.parseDate(a.config.StartDate)
 	if err != nil {
 		return nil, fmt.Errorf("invalid start date %s: %w", a.config.StartDate, err)
 	}
-	end, err := time.Parse("2006-01-02", a.config.EndDate)
+	end, err := a.parseDate(a.config.EndDate)
 	if err != nil {
 		return nil, fmt.Errorf("invalid end date %s: %w", a.config.EndDate, err)
 	}
@@ -931,8 +1165,8 @@ func (a *Archiver) buildDateRangePartition() ([]PartitionInfo, error) {
 		return nil, fmt.Errorf("end date %s cannot be before start date %s", a.config.EndDate, a.config.StartDate)
 	}
 
-	rangeStart := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
-	rangeEnd := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	rangeStart := start
+	rangeEnd := addLocalDate(end, 0, 0, 1)
 
 	partition := PartitionInfo{
 		TableName:  a.config.Table,
@@ -968,56 +1202,56 @@ func (a *Archiver) processPartitionsWithProgress(partitions []PartitionInfo, pro
 */
 
 func (a *Archiver) ProcessPartitionWithProgress(partition PartitionInfo, program *tea.Program) ProcessResult {
+	a.emitEvent(progressEvent{Type: progressEventPartitionStart, Partition: partition.TableName, Total: max(partition.RowCount, 0)})
+	dateCheck := a.runPartitionDateCheck(partition)
+
 	// Check if we need to split this partition based on output_duration and date_column
 	if a.shouldSplitPartition(partition) {
-		if a.config.DateColumn == "" {
+		if !a.config.canSliceByTime() {
 			// Need date column for splitting
 			a.logger.Warn(fmt.Sprintf("⚠️  Partition %s should be split into %s files but --date-column not specified. Processing as single file.",
 				partition.TableName, a.config.OutputDuration))
 		} else {
-			return a.processPartitionWithSplit(partition, program)
+			result := a.processPartitionWithSplit(partition, program)
+			result.DateCheck = dateCheck
+			a.controls.settle(&result)
+			a.recordResult(result)
+			a.emitResult(progressEventPartitionComplete, result, progressEvent{})
+			return result
 		}
 	}
 
-	// Process as a single file (original behavior)
-	return a.processSinglePartition(partition, program, partition.Date)
+	// Process as a single file (original behavior); key ranges and increments are slices of their table
+	var result ProcessResult
+	if partition.HasKeyRange() || partition.HasIncrement() {
+		result = a.processSinglePartitionSlice(partition, program, time.Time{}, time.Time{})
+	} else {
+		result = a.processSinglePartition(partition, program, partition.Date)
+	}
+	result.DateCheck = dateCheck
+	if result.Error != nil {
+		a.controls.fail(failedSlice{partition: partition})
+	}
+	a.controls.settle(&result)
+	a.recordResult(result)
+	a.emitResult(progressEventPartitionComplete, result, progressEvent{})
+	return result
 }
 
 // shouldSplitPartition determines if a partition needs to be split based on output_duration
 func (a *Archiver) shouldSplitPartition(partition PartitionInfo) bool {
-	if partition.HasCustomRange() {
-		return true
-	}
-
-	// Determine partition period from table name
-	// For now, we'll check if it's a monthly partition and output is smaller
-	// Monthly partitions end with _YYYY_MM or _YYYYMM
-	tableName := partition.TableName
-	baseTable := a.config.Table + "_"
-
-	if !strings.HasPrefix(tableName, baseTable) {
+	if partition.HasKeyRange() || partition.HasIncrement() {
 		return false
 	}
-
-	suffix := strings.TrimPrefix(tableName, baseTable)
-
-	// Check for monthly partition patterns
-	isMonthly := len(suffix) == 7 && suffix[4] == '_' // YYYY_MM
-	if !isMonthly {
-		isMonthly = len(suffix) == 6 // YYYYMM
-	}
-
-	// If it's monthly and output duration is smaller, split
-	if isMonthly {
-		switch a.config.OutputDuration {
-		case DurationDaily, DurationWeekly, DurationHourly:
-			return true
-		}
+	if partition.HasCustomRange() {
+		return true
 	}
 
-	// Add more cases as needed (daily -> hourly, etc.)
-
-	return false
+	// Split partitions into output files shorter than their period, such as
+	// monthly partitions into daily files or daily partitions into hourly ones
+	period := durationSpan(a.partitionPeriod(partition))
+	output := durationSpan(a.config.OutputDuration)
+	return period > 0 && output > 0 && output < period
 }
 
 // processPartitionWithSplit splits a partition into multiple output files based on date_column
@@ -1030,20 +1264,28 @@ func (a *Archiver) processPartitionWithSplit(partition PartitionInfo, program *t
 
 	a.logger.Debug(fmt.Sprintf("Splitting partition %s by %s", partition.TableName, a.config.OutputDuration))
 
-	var partitionStart time.Time
-	var partitionEnd time.Time
-	if partition.HasCustomRange() {
-		partitionStart = partition.RangeStart
-		partitionEnd = partition.RangeEnd
-	} else {
-		partitionStart = time.Date(partition.Date.Year(), partition.Date.Month(), 1, 0, 0, 0, 0, partition.Date.Location())
-		partitionEnd = partitionStart.AddDate(0, 1, 0) // Start of next month
-	}
+	partitionStart, partitionEnd := a.partitionPeriodRange(partition)
 
 	a.logger.Debug(fmt.Sprintf("  Partition time range: %s to %s", partitionStart.Format("2006-01-02"), partitionEnd.Format("2006-01-02")))
 
 	// Split into time ranges based on output duration
 	ranges := SplitPartitionByDuration(partitionStart, partitionEnd, a.config.OutputDuration)
+	if len(ranges) > 0 && ranges[0].Start.Before(partitionStart) {
+		// A combined or date-range partition can start mid-period
+		ranges[0].Start = partitionStart
+	}
+
+	// --start-from-date skips the slices that end before it
+	planned := ranges[:0]
+	for _, timeRange := range ranges {
+		if !a.beforeStartFrom(timeRange.End) {
+			planned = append(planned, timeRange)
+		}
+	}
+	if skipped := len(ranges) - len(planned); skipped > 0 {
+		a.logger.Debug(fmt.Sprintf("  Skipping %d slice(s) before %s", skipped, a.config.startFromLabel()))
+	}
+	ranges = planned
 
 	// Only log in debug mode - in TUI mode this corrupts the display
 	if a.config.Debug {
@@ -1060,6 +1302,9 @@ func (a *Archiver) processPartitionWithSplit(partition PartitionInfo, program *t
 		}
 	}
 
+	// Check which slices are already in S3 before processing them
+	a.prefetchSliceHeads(partition, ranges)
+
 	// Process each time range separately
 	totalBytes := int64(0)
 	successCount := 0
@@ -1067,8 +1312,16 @@ func (a *Archiver) processPartitionWithSplit(partition PartitionInfo, program *t
 	failCount := 0
 	var firstError error
 	var failedSliceDates []string
+	var calendarSkips []string
 
 	for i, timeRange := range ranges {
+		// Hold the next slice back while the run is paused
+		if err := a.waitWhilePaused(a.ctx, fmt.Sprintf("%s slice %s", partition.TableName, timeRange.Start.Format("2006-01-02"))); err != nil {
+			result.Error = err
+			result.Stage = StageCancelled
+			return result
+		}
+
 		// Check for cancellation
 		select {
 		case <-a.ctx.Done():
@@ -1079,6 +1332,15 @@ func (a *Archiver) processPartitionWithSplit(partition PartitionInfo, program *t
 		}
 
 		// Send slice start message to TUI
+		sliceEvent := progressEvent{
+			Partition:   partition.TableName,
+			Slice:       timeRange.Start.Format("2006-01-02"),
+			SliceNumber: i + 1,
+			SliceCount:  len(ranges),
+		}
+		startEvent := sliceEvent
+		startEvent.Type = progressEventSliceStart
+		a.emitEvent(startEvent)
 		if program != nil {
 			program.Send(sliceStartMsg{
 				partitionIndex: 0, // Will be set by TUI based on current partition
@@ -1091,10 +1353,26 @@ func (a *Archiver) processPartitionWithSplit(partition PartitionInfo, program *t
 			a.logger.Info(fmt.Sprintf("    Processing slice: %s", timeRange.Start.Format("2006-01-02")))
 		}
 
-		// Process this time slice
-		sliceResult := a.processSinglePartitionSlice(partition, program, timeRange.Start, timeRange.End)
+		// Process this time slice, unless the calendar says it has no data
+		var sliceResult ProcessResult
+		if reason, skip := a.config.Calendar.skipReason(timeRange.Start, timeRange.End); skip {
+			sliceResult = ProcessResult{
+				Partition:  partition,
+				Skipped:    true,
+				SkipReason: "Calendar: " + reason,
+				Stage:      StageSkipped,
+			}
+			calendarSkips = append(calendarSkips, fmt.Sprintf("%s %s", timeRange.Start.Format("2006-01-02"), reason))
+		} else {
+			sliceResult = a.processSinglePartitionSlice(partition, program, timeRange.Start, timeRange.End)
+		}
+
+		result.ValueIssues.add(sliceResult.ValueIssues)
+		result.Queries = append(result.Queries, sliceResult.Queries...)
+		result.Ratios = append(result.Ratios, sliceResult.Ratios...)
 
 		// Send slice complete message to TUI
+		a.emitResult(progressEventSliceComplete, sliceResult, sliceEvent)
 		if program != nil {
 			program.Send(sliceCompleteMsg{
 				partitionIndex: 0,
@@ -1112,6 +1390,7 @@ func (a *Archiver) processPartitionWithSplit(partition PartitionInfo, program *t
 				firstError = sliceResult.Error
 			}
 			failedSliceDates = append(failedSliceDates, timeRange.Start.Format("2006-01-02"))
+			a.controls.fail(failedSlice{partition: partition, start: timeRange.Start, end: timeRange.End})
 			// Log errors in debug mode
 			if a.config.Debug {
 				a.logger.Error(fmt.Sprintf("      ❌ Error processing slice %s: %v", timeRange.Start.Format("2006-01-02"), sliceResult.Error))
@@ -1119,7 +1398,7 @@ func (a *Archiver) processPartitionWithSplit(partition PartitionInfo, program *t
 		} else if sliceResult.Skipped {
 			skipCount++
 			if a.config.Debug {
-				a.logger.Debug(fmt.Sprintf("      No data for %s, skipping", timeRange.Start.Format("2006-01-02")))
+				a.logger.Debug(fmt.Sprintf("      %s for %s, skipping", sliceResult.SkipReason, timeRange.Start.Format("2006-01-02")))
 			}
 		} else {
 			totalBytes += sliceResult.BytesWritten
@@ -1157,6 +1436,18 @@ func (a *Archiver) processPartitionWithSplit(partition PartitionInfo, program *t
 			result.Skipped = true
 			result.SkipReason = "All slices skipped (no data in time ranges)"
 		}
+
+		// Record which slices the calendar skipped, and why
+		if len(calendarSkips) > 0 {
+			calendarNote := fmt.Sprintf("%d slice(s) skipped by calendar: %s", len(calendarSkips), strings.Join(calendarSkips, ", "))
+			if result.Skipped && len(calendarSkips) == skipCount {
+				result.SkipReason = "All slices skipped by calendar: " + strings.Join(calendarSkips, ", ")
+			} else if result.SkipReason != "" {
+				result.SkipReason += "; " + calendarNote
+			} else {
+				result.SkipReason = calendarNote
+			}
+		}
 	} else if failCount > 0 {
 		// All slices failed - mark partition as failed
 		result.Error = firstError
@@ -1192,14 +1483,15 @@ func (a *Archiver) processSinglePartition(partition PartitionInfo, program *tea.
 			taskInfo.CurrentPartition = partition.TableName
 			_ = WriteTaskInfo(taskInfo)
 		}
-		// Also send to program if available
-		if program != nil {
-			program.Send(updateProgress(stage, 0, 0))
-		}
+		// Also send to program and progress file if available
+		a.sendProgress(program, partition.TableName, stage, 0, 0)
 	}
 
+	// Paths and filenames are keyed in the key time zone
+	outputDate = a.keyTime(outputDate)
+
 	// Generate object key using path template (use outputDate for path)
-	pathTemplate := NewPathTemplate(a.config.S3.PathTemplate)
+	pathTemplate := NewPathTemplate(a.config.S3.PathTemplate).WithOutput(a.config.OutputFormat, a.config.Compression)
 	basePath := pathTemplate.Generate(a.config.Table, outputDate)
 
 	// Get formatter with compression support
@@ -1233,6 +1525,13 @@ func (a *Archiver) processSinglePartition(partition PartitionInfo, program *tea.
 
 	objectKey := basePath + "/" + filename
 
+	// A split archive is tracked by its manifest, which lists every part
+	ext := formatter.Extension() + compressionExt
+	archiveKey := objectKey
+	if a.config.splitsArchives() {
+		objectKey = manifestObjectKey(archiveKey, ext)
+	}
+
 	// Small delay to ensure UI can update
 	time.Sleep(50 * time.Millisecond)
 
@@ -1256,7 +1555,12 @@ func (a *Archiver) processSinglePartition(partition PartitionInfo, program *tea.
 	}
 
 	// Extract data with streaming (includes compression and MD5 calculation)
-	tempFilePath, fileSize, md5Hash, uncompressedSize, err := a.extractPartitionDataWithRetry(partition, program, cache, updateTaskStage)
+	level := a.compressionLevel()
+	doneCPU := a.cpu.track(cpuStageExtract)
+	var parts []archivePart
+	var query extractionQuery
+	tempFilePath, fileSize, md5Hash, uncompressedSize, rowCount, err := a.extractPartitionDataWithRetry(partition, program, cache, updateTaskStage, level, &result.ValueIssues, &parts, &query)
+	doneCPU()
 	if err != nil {
 		result.Error = err
 		result.Stage = "Extracting"
@@ -1265,10 +1569,32 @@ func (a *Archiver) processSinglePartition(partition PartitionInfo, program *tea.
 	}
 	// Ensure temp file cleanup on error
 	defer cleanupTempFile(tempFilePath)
+	defer cleanupParts(parts)
+	if query.SQL != "" {
+		query.S3Key = objectKey
+		result.Queries = []extractionQuery{query}
+	}
 
 	result.Compressed = true
 	result.BytesWritten = fileSize
 
+	// The manifest's size and MD5 stand in for the split archive's
+	var manifest splitManifest
+	var manifestData []byte
+	if len(parts) > 0 {
+		manifest = a.newSplitManifest(partition, archiveKey, ext, parts)
+		manifest.Query = query.manifestEntry()
+		if manifestData, err = manifest.encode(); err != nil {
+			result.Error = err
+			result.Duration = time.Since(startTime)
+			return result
+		}
+		result.BytesWritten = totalPartSize(parts)
+		fileSize, md5Hash = manifestChecksum(manifestData)
+	}
+
+	a.recordRatio(&result, cache, objectKey, uncompressedSize, result.BytesWritten)
+
 	// Check for cancellation before upload
 	select {
 	case <-a.ctx.Done():
@@ -1282,24 +1608,38 @@ func (a *Archiver) processSinglePartition(partition PartitionInfo, program *tea.
 	// Upload to S3
 	if !a.config.DryRun {
 		updateTaskStage("Uploading to S3...")
-		if program != nil {
-			program.Send(updateProgress("Uploading to S3...", 0, 100))
-		}
+		a.sendProgress(program, partition.TableName, "Uploading to S3...", 0, 100)
 		result.Stage = "Uploading"
-		if err := a.uploadTempFileToS3(tempFilePath, objectKey); err != nil {
+		doneCPU := a.cpu.track(cpuStageUpload)
+		a.labels.set(objectKey, a.partitionLabels(partition, partition.Date, rowCount, uncompressedSize))
+		if len(parts) > 0 {
+			err = a.uploadParts(objectKey, manifest, manifestData, parts)
+		} else {
+			err = a.uploadTempFileToS3(tempFilePath, objectKey)
+		}
+		a.labels.forget(objectKey)
+		doneCPU()
+		if err != nil {
 			result.Error = fmt.Errorf("upload failed: %w", err)
 			result.Duration = time.Since(startTime)
 			return result
 		}
 		result.Uploaded = true
-		if program != nil {
-			program.Send(updateProgress("Uploading to S3...", 100, 100))
+		if len(parts) == 0 {
+			a.usage.add(time.Now(), fileSize, uncompressedSize, rowCount)
+			if err := a.recordIntegrity(IntegrityEventArchived, objectKey, md5Hash, fileSize, rowCount); err != nil {
+				result.Error = err
+				result.Duration = time.Since(startTime)
+				return result
+			}
 		}
+		a.sendProgress(program, partition.TableName, "Uploading to S3...", 100, 100)
 
 		// Calculate multipart ETag if file is large enough for multipart upload
 		multipartETag := ""
 		if fileSize > 100*1024*1024 {
-			etag, err := a.calculateMultipartETagFromFile(tempFilePath)
+			etag, err := a.tempFileMultipartETag(tempFilePath)
+			a.etags.forget(tempFilePath)
 			if err != nil {
 				a.logger.Warn(fmt.Sprintf("   ⚠️  Failed to calculate multipart ETag: %v", err))
 			} else {
@@ -1307,14 +1647,23 @@ func (a *Archiver) processSinglePartition(partition PartitionInfo, program *tea.
 				a.logger.Debug(fmt.Sprintf("   🔐 Calculated multipart ETag: %s", multipartETag))
 			}
 		}
+		if len(parts) == 0 {
+			a.recordRunManifest(objectKey, md5Hash, multipartETag, fileSize, uncompressedSize, rowCount)
+		}
 
 		// Save metadata to cache immediately after successful upload
 		cache.setFileMetadataWithETagAndStartTime(partition.TableName, objectKey, fileSize, uncompressedSize, md5Hash, multipartETag, true, startTime)
+		if multipartETag != "" {
+			cache.setPartSize(partition.TableName, a.objectPartSize(fileSize))
+		}
+		cache.setArchivedContent(partition.TableName, partition.TableName, time.Time{}, time.Time{}, rowCount, level, a.archiveFormat(), a.config.LimitRowsPerSlice)
+		cache.setSchemaVersion(partition.TableName, a.schemaVersion(partition.TableName))
 		if err := cache.save(a.config.CacheScope); err != nil {
 			a.logger.Warn(fmt.Sprintf("   ⚠️  Failed to save cache metadata: %v", err))
 		} else {
 			a.logger.Debug(fmt.Sprintf("   💾 Saved file metadata to cache: compressed=%d, uncompressed=%d, md5=%s, multipartETag=%s", fileSize, uncompressedSize, md5Hash, multipartETag))
 		}
+		a.notifyHooks(outputDate, uploadedKeys(objectKey, manifest))
 	}
 
 	result.Stage = "Complete"
@@ -1332,9 +1681,17 @@ func (a *Archiver) processSinglePartitionSlice(partition PartitionInfo, _ *tea.P
 		StartTime: sliceStartTime,
 	}
 
-	// Generate object key using path template (use slice start time)
-	pathTemplate := NewPathTemplate(a.config.S3.PathTemplate)
-	basePath := pathTemplate.Generate(a.config.Table, startTime)
+	// Generate object key using path template (use slice start time in the key time zone)
+	keyTime := a.keyTime(startTime)
+	if partition.HasIncrement() {
+		// Increments are keyed by when they were archived
+		keyTime = a.keyTime(partition.Date)
+	}
+	if a.config.Timezone.converts() {
+		a.logger.Debug(fmt.Sprintf("      Slice %s keyed as %s", startTime.Format(time.RFC3339), keyTime.Format(time.RFC3339)))
+	}
+	pathTemplate := NewPathTemplate(a.config.S3.PathTemplate).WithOutput(a.config.OutputFormat, a.config.Compression)
+	basePath := pathTemplate.Generate(a.config.Table, keyTime)
 
 	// Get formatter with compression support
 	formatter := formatters.GetFormatterWithCompression(a.config.OutputFormat, a.config.Compression)
@@ -1368,14 +1725,27 @@ func (a *Archiver) processSinglePartitionSlice(partition PartitionInfo, _ *tea.P
 	// Generate filename (use slice start time)
 	filename := GenerateFilename(
 		a.config.Table,
-		startTime,
+		keyTime,
 		a.config.OutputDuration,
 		formatter.Extension(),
 		compressionExt,
 	)
+	if partition.HasKeyRange() {
+		filename = keyRangeFilename(a.config.Table, partition.Keys, formatter.Extension(), compressionExt)
+	}
+	if partition.HasIncrement() {
+		filename = incrementFilename(a.config.Table, formatter.Extension(), compressionExt)
+	}
 
 	objectKey := basePath + "/" + filename
 
+	// A split archive is tracked by its manifest, which lists every part
+	ext := formatter.Extension() + compressionExt
+	archiveKey := objectKey
+	if a.config.splitsArchives() {
+		objectKey = manifestObjectKey(archiveKey, ext)
+	}
+
 	// Load cache
 	cache, _ := loadPartitionCache(a.config.CacheScope)
 
@@ -1401,20 +1771,41 @@ func (a *Archiver) processSinglePartitionSlice(partition PartitionInfo, _ *tea.P
 	}
 
 	// Use streaming extraction to avoid loading all rows into memory
-	tempFilePath, fileSize, md5Hash, uncompressedSize, extractErr := a.extractPartitionDataStreaming(partition, nil, cache, updateTaskStage, startTime, endTime)
+	level := a.compressionLevel()
+	doneCPU := a.cpu.track(cpuStageExtract)
+	var parts []archivePart
+	var query extractionQuery
+	tempFilePath, fileSize, md5Hash, uncompressedSize, rowCount, extractErr := a.extractPartitionDataStreaming(partition, nil, cache, updateTaskStage, startTime, endTime, level, &result.ValueIssues, &parts, &query)
+	doneCPU()
 	if extractErr != nil {
 		result.Error = fmt.Errorf("failed to extract data: %w", extractErr)
 		result.Stage = "Extracting"
 		result.Duration = time.Since(sliceStartTime)
 		return result
 	}
+	defer cleanupParts(parts)
+	if query.SQL != "" {
+		query.S3Key = objectKey
+		result.Queries = []extractionQuery{query}
+	}
 
 	// Check if file was created and has content (very small files likely have no data rows)
 	// Format-specific minimum sizes: CSV header ~100 bytes, Parquet footer ~1000 bytes, JSONL empty
-	if tempFilePath == "" || fileSize < 100 {
-		a.logger.Debug(fmt.Sprintf("      No data for %s, skipping", startTime.Format("2006-01-02")))
+	// Split output counts rows instead, as its last part may legitimately be that small
+	noData := tempFilePath == "" || fileSize < 100
+	if len(parts) > 0 {
+		noData = rowCount == 0
+	}
+	if noData {
 		result.Skipped = true
 		result.SkipReason = "No data in time range"
+		if partition.HasKeyRange() {
+			result.SkipReason = "No data in key range"
+		}
+		if partition.HasIncrement() {
+			result.SkipReason = "No rows past the watermark"
+		}
+		a.logger.Debug(fmt.Sprintf("      %s for %s, skipping", result.SkipReason, filename))
 		if tempFilePath != "" {
 			cleanupTempFile(tempFilePath)
 		}
@@ -1432,6 +1823,22 @@ func (a *Archiver) processSinglePartitionSlice(partition PartitionInfo, _ *tea.P
 	result.Compressed = true
 	result.BytesWritten = fileSize
 
+	// The manifest's size and MD5 stand in for the split archive's
+	var manifest splitManifest
+	var manifestData []byte
+	if len(parts) > 0 {
+		manifest = a.newSplitManifest(partition, archiveKey, ext, parts)
+		manifest.Query = query.manifestEntry()
+		var encodeErr error
+		if manifestData, encodeErr = manifest.encode(); encodeErr != nil {
+			result.Error = encodeErr
+			result.Duration = time.Since(sliceStartTime)
+			return result
+		}
+		result.BytesWritten = totalPartSize(parts)
+		fileSize, md5Hash = manifestChecksum(manifestData)
+	}
+
 	// Check if we can skip upload by comparing with S3
 	exists, s3Size, s3ETag := a.checkObjectExists(objectKey)
 	if exists && s3Size == fileSize {
@@ -1447,6 +1854,11 @@ func (a *Archiver) processSinglePartitionSlice(partition PartitionInfo, _ *tea.P
 			cleanupTempFile(tempFilePath)
 			// Save to cache immediately - use objectKey as cache key for slices
 			cache.setFileMetadataWithETagAndStartTime(objectKey, objectKey, fileSize, uncompressedSize, md5Hash, "", true, sliceStartTime)
+			cache.setArchivedContent(objectKey, partition.TableName, startTime, endTime, rowCount, level, a.archiveFormat(), a.config.LimitRowsPerSlice)
+			cache.setSchemaVersion(objectKey, a.schemaVersion(partition.TableName))
+			cache.setKeyRange(objectKey, partition.Keys)
+			cache.setIncrement(objectKey, partition.Increment)
+			cache.setFilter(objectKey, a.config.Filter)
 			if err := cache.save(a.config.CacheScope); err != nil {
 				a.logger.Warn(fmt.Sprintf("      ⚠️  Failed to save cache metadata: %v", err))
 			}
@@ -1455,16 +1867,24 @@ func (a *Archiver) processSinglePartitionSlice(partition PartitionInfo, _ *tea.P
 		}
 
 		if isMultipart {
-			// Calculate multipart ETag from file
-			multipartETag, err := a.calculateMultipartETagFromFile(tempFilePath)
+			// Calculate multipart ETag from file, with the part size the
+			// object was uploaded with if the cache recorded one
+			multipartETag, partSize, err := a.existingObjectETag(tempFilePath, cache.Entries[objectKey].PartSize, s3ETag)
 			if err == nil && s3ETag == multipartETag {
 				result.Skipped = true
 				result.SkipReason = fmt.Sprintf("Already exists with matching size (%d bytes) and multipart ETag (%s)", s3Size, s3ETag)
 				result.Stage = StageSkipped
 				// Clean up temp file
+				a.etags.forget(tempFilePath)
 				cleanupTempFile(tempFilePath)
 				// Save to cache immediately with multipart ETag - use objectKey as cache key for slices
 				cache.setFileMetadataWithETagAndStartTime(objectKey, objectKey, fileSize, uncompressedSize, md5Hash, multipartETag, true, sliceStartTime)
+				cache.setPartSize(objectKey, partSize)
+				cache.setArchivedContent(objectKey, partition.TableName, startTime, endTime, rowCount, level, a.archiveFormat(), a.config.LimitRowsPerSlice)
+				cache.setSchemaVersion(objectKey, a.schemaVersion(partition.TableName))
+				cache.setKeyRange(objectKey, partition.Keys)
+				cache.setIncrement(objectKey, partition.Increment)
+				cache.setFilter(objectKey, a.config.Filter)
 				if err := cache.save(a.config.CacheScope); err != nil {
 					a.logger.Warn(fmt.Sprintf("      ⚠️  Failed to save cache metadata: %v", err))
 				}
@@ -1485,21 +1905,43 @@ func (a *Archiver) processSinglePartitionSlice(partition PartitionInfo, _ *tea.P
 	default:
 	}
 
+	a.recordRatio(&result, cache, objectKey, uncompressedSize, result.BytesWritten)
+
 	// Upload to S3
 	if !a.config.DryRun {
 		result.Stage = "Uploading"
-		if err := a.uploadTempFileToS3(tempFilePath, objectKey); err != nil {
+		doneCPU := a.cpu.track(cpuStageUpload)
+		var err error
+		a.labels.set(objectKey, a.partitionLabels(partition, startTime, rowCount, uncompressedSize))
+		if len(parts) > 0 {
+			err = a.uploadParts(objectKey, manifest, manifestData, parts)
+		} else {
+			err = a.uploadTempFileToS3(tempFilePath, objectKey)
+		}
+		a.labels.forget(objectKey)
+		doneCPU()
+		if err != nil {
 			cleanupTempFile(tempFilePath)
 			result.Error = fmt.Errorf("upload failed: %w", err)
 			result.Duration = time.Since(sliceStartTime)
 			return result
 		}
 		result.Uploaded = true
+		if len(parts) == 0 {
+			a.usage.add(time.Now(), fileSize, uncompressedSize, rowCount)
+			if err := a.recordIntegrity(IntegrityEventArchived, objectKey, md5Hash, fileSize, rowCount); err != nil {
+				cleanupTempFile(tempFilePath)
+				result.Error = err
+				result.Duration = time.Since(sliceStartTime)
+				return result
+			}
+		}
 
 		// Calculate multipart ETag if file is large enough
 		multipartETag := ""
 		if fileSize > 100*1024*1024 {
-			etag, err := a.calculateMultipartETagFromFile(tempFilePath)
+			etag, err := a.tempFileMultipartETag(tempFilePath)
+			a.etags.forget(tempFilePath)
 			if err != nil {
 				a.logger.Debug(fmt.Sprintf("      ⚠️  Failed to calculate multipart ETag: %v", err))
 			} else {
@@ -1507,13 +1949,25 @@ func (a *Archiver) processSinglePartitionSlice(partition PartitionInfo, _ *tea.P
 				a.logger.Debug(fmt.Sprintf("      🔐 Calculated multipart ETag: %s", multipartETag))
 			}
 		}
+		if len(parts) == 0 {
+			a.recordRunManifest(objectKey, md5Hash, multipartETag, fileSize, uncompressedSize, rowCount)
+		}
 
 		// Save metadata to cache immediately after successful upload
 		// Use objectKey as cache key for slices so each slice has its own entry
 		cache.setFileMetadataWithETagAndStartTime(objectKey, objectKey, fileSize, uncompressedSize, md5Hash, multipartETag, true, sliceStartTime)
+		if multipartETag != "" {
+			cache.setPartSize(objectKey, a.objectPartSize(fileSize))
+		}
+		cache.setArchivedContent(objectKey, partition.TableName, startTime, endTime, rowCount, level, a.archiveFormat(), a.config.LimitRowsPerSlice)
+		cache.setSchemaVersion(objectKey, a.schemaVersion(partition.TableName))
+		cache.setKeyRange(objectKey, partition.Keys)
+		cache.setIncrement(objectKey, partition.Increment)
+		cache.setFilter(objectKey, a.config.Filter)
 		if err := cache.save(a.config.CacheScope); err != nil {
 			a.logger.Warn(fmt.Sprintf("      ⚠️  Failed to save cache metadata: %v", err))
 		}
+		a.notifyHooks(keyTime, uploadedKeys(objectKey, manifest))
 
 		// Only log in debug mode when TUI is disabled
 		if a.config.Debug {
@@ -1539,10 +1993,37 @@ func (a *Archiver) checkCachedMetadata(partition PartitionInfo, objectKey string
 	// For slices (custom range partitions), use objectKey as cache key so each slice has its own entry
 	// For regular partitions, use partition.TableName
 	cacheKey := partition.TableName
-	if partition.HasCustomRange() {
+	if partition.HasCustomRange() || partition.HasKeyRange() || partition.HasIncrement() {
 		cacheKey = objectKey
 	}
 
+	// --format-migration keep leaves the object in its old format in place
+	if kept, ok := a.migration.keeps(objectKey); ok {
+		result.Skipped = true
+		result.SkipReason = fmt.Sprintf("Kept as %s (%s)", kept.From, kept.OldKey)
+		result.Stage = StageSkipped
+		return true, result
+	}
+
+	// --limit-rows-per-slice writes samples, which never replace a full archive
+	if a.keepsFullArchive(objectKey, cache.Entries[cacheKey]) {
+		result.Skipped = true
+		result.SkipReason = "Full archive in place; not replaced by a row-limited sample"
+		result.Stage = StageSkipped
+		return true, result
+	}
+
+	// Samples and full archives of the same slice differ, so a cached file
+	// written with another row limit does not match this run's
+	if cache.Entries[cacheKey].RowLimit != a.config.LimitRowsPerSlice {
+		return false, result
+	}
+
+	// The key range holding the largest key may have gained rows since
+	if partition.Keys.Open {
+		return false, result
+	}
+
 	cachedSize, cachedMD5, cachedMultipartETag, hasCached := cache.getFileMetadataWithETag(cacheKey, objectKey, partition.Date)
 	if !hasCached {
 		return false, result
@@ -1591,123 +2072,6 @@ func (a *Archiver) checkCachedMetadata(partition PartitionInfo, objectKey string
 	return false, result
 }
 
-// compressPartitionData compresses the extracted data using configured compressor
-func (a *Archiver) compressPartitionData(data []byte, partition PartitionInfo, program *tea.Program, cache *PartitionCache, updateTaskStage func(string)) ([]byte, string, error) {
-	compressStart := time.Now()
-	updateTaskStage("Compressing data...")
-	if program != nil {
-		program.Send(updateProgress("Compressing data...", 50, 100))
-	}
-
-	// Get compressor based on configuration
-	compressor, err := compressors.GetCompressor(a.config.Compression)
-	if err != nil {
-		cache.setError(partition.TableName, fmt.Sprintf("Compressor setup failed: %v", err))
-		_ = cache.save(a.config.CacheScope)
-		return nil, "", fmt.Errorf("compressor setup failed: %w", err)
-	}
-
-	// Apply compression
-	compressed, err := compressor.Compress(data, a.config.CompressionLevel)
-	if err != nil {
-		// Save error to cache
-		cache.setError(partition.TableName, fmt.Sprintf("Compression failed: %v", err))
-		_ = cache.save(a.config.CacheScope)
-		return nil, "", fmt.Errorf("compression failed: %w", err)
-	}
-
-	if program != nil {
-		program.Send(updateProgress("Compressing data...", 100, 100))
-	}
-
-	compressDuration := time.Since(compressStart)
-	if len(compressed) < len(data) {
-		a.logger.Debug(fmt.Sprintf("  ⏱️  Compression took %v for %s (%.1fx ratio)",
-			compressDuration, partition.TableName, float64(len(data))/float64(len(compressed))))
-	} else {
-		a.logger.Debug(fmt.Sprintf("  ⏱️  Compression took %v for %s (no compression applied)",
-			compressDuration, partition.TableName))
-	}
-
-	// Calculate MD5 hash of compressed data
-	hasher := md5.New() //nolint:gosec // MD5 used for checksums, not cryptography
-	hasher.Write(compressed)
-	localMD5 := hex.EncodeToString(hasher.Sum(nil))
-
-	return compressed, localMD5, nil
-}
-
-// checkExistingFile checks if file exists in S3 and matches local version
-func (a *Archiver) checkExistingFile(partition PartitionInfo, objectKey string, compressed []byte, localMD5 string, localSize, uncompressedSize int64, cache *PartitionCache, updateTaskStage func(string)) (bool, ProcessResult) {
-	result := ProcessResult{
-		Partition:    partition,
-		Compressed:   true,
-		BytesWritten: localSize,
-	}
-
-	updateTaskStage("Checking if file exists...")
-	exists, s3Size, s3ETag := a.checkObjectExists(objectKey)
-	if !exists {
-		a.logger.Debug(fmt.Sprintf("📊 File does not exist in S3: %s", objectKey))
-		return false, result
-	}
-
-	// Remove quotes from ETag if present
-	s3ETag = strings.Trim(s3ETag, "\"")
-	isMultipart := strings.Contains(s3ETag, "-")
-
-	// Always log comparison details in debug mode
-	a.logger.Debug(fmt.Sprintf("📊 Comparing files for %s:", partition.TableName))
-	a.logger.Debug(fmt.Sprintf("   S3:     size=%d, etag=%s (multipart=%v)", s3Size, s3ETag, isMultipart))
-	a.logger.Debug(fmt.Sprintf("   Local:  size=%d, md5=%s", localSize, localMD5))
-
-	if s3Size != localSize {
-		a.logger.Debug(fmt.Sprintf("   ❌ Size mismatch: S3=%d, Local=%d", s3Size, localSize))
-		return false, result
-	}
-
-	// Size matches, check hash
-	if !isMultipart && s3ETag == localMD5 {
-		// Single-part upload with matching MD5
-		result.Skipped = true
-		result.SkipReason = fmt.Sprintf("Already exists with matching size (%d bytes) and MD5 (%s)", s3Size, s3ETag)
-		result.Stage = StageSkipped
-		a.logger.Debug("   ✅ Skipping: Size and MD5 match")
-		// Save to cache immediately for future runs
-		cache.setFileMetadata(partition.TableName, objectKey, localSize, uncompressedSize, localMD5, true)
-		if err := cache.save(a.config.CacheScope); err != nil {
-			a.logger.Warn(fmt.Sprintf("   ⚠️  Failed to save cache metadata: %v", err))
-		}
-		return true, result
-	}
-
-		if isMultipart {
-			// For multipart uploads, calculate the multipart ETag
-			localMultipartETag := a.calculateMultipartETag(compressed)
-			a.logger.Debug(fmt.Sprintf("   Local multipart ETag: %s", localMultipartETag))
-			if s3ETag == localMultipartETag {
-				result.Skipped = true
-				result.SkipReason = fmt.Sprintf("Already exists with matching size (%d bytes) and multipart ETag (%s)", s3Size, s3ETag)
-				result.Stage = StageSkipped
-				a.logger.Debug("   ✅ Skipping: Size and multipart ETag match")
-				// Save to cache immediately for future runs with multipart ETag
-				cache.setFileMetadataWithETagAndStartTime(partition.TableName, objectKey, localSize, uncompressedSize, localMD5, localMultipartETag, true, time.Time{})
-				if err := cache.save(a.config.CacheScope); err != nil {
-					a.logger.Warn(fmt.Sprintf("   ⚠️  Failed to save cache metadata: %v", err))
-				}
-				return true, result
-			}
-			a.logger.Debug(fmt.Sprintf("   ❌ Multipart ETag mismatch: S3=%s, Local=%s", s3ETag, localMultipartETag))
-	} else {
-		a.logger.Debug(fmt.Sprintf("   ❌ MD5 mismatch: S3=%s, Local=%s", s3ETag, localMD5))
-	}
-
-	// Size or hash doesn't match, we'll re-upload
-	a.logger.Debug("   🔄 Will re-upload due to differences")
-
-	return false, result
-}
-
 func (a *Archiver) checkObjectExists(key string) (bool, int64, string) {
 	// Check if S3 client is initialized
 	if a.s3Client == nil {
@@ -1715,73 +2079,24 @@ func (a *Archiver) checkObjectExists(key string) (bool, int64, string) {
 		return false, 0, ""
 	}
 
-	headInput := &s3.HeadObjectInput{
-		Bucket: aws.String(a.config.S3.Bucket),
-		Key:    aws.String(key),
-	}
-
-	result, err := a.s3Client.HeadObject(headInput)
-	if err != nil {
-		return false, 0, ""
-	}
-
-	var size int64
-	var etag string
-
-	if result.ContentLength != nil {
-		size = *result.ContentLength
+	// Slices of split partitions are usually checked up front
+	if head, ok := a.heads.get(key); ok {
+		return head.Exists, head.Size, head.ETag
 	}
 
-	if result.ETag != nil {
-		etag = *result.ETag
+	ctx := a.ctx
+	if ctx == nil {
+		ctx = context.Background()
 	}
-
-	return true, size, etag
-}
-
-// calculateMultipartETag calculates the ETag for a multipart upload
-// This matches S3's algorithm for multipart uploads
-// Uses 5MB part size to match s3manager.Uploader default
-func (a *Archiver) calculateMultipartETag(data []byte) string {
-	const partSize = 5 * 1024 * 1024 // 5MB part size (s3manager default)
-
-	// Calculate number of parts
-	numParts := (len(data) + partSize - 1) / partSize
-
-	// If it would be a single part, just return regular MD5
-	if numParts == 1 {
-		hasher := md5.New() //nolint:gosec // MD5 used for checksums, not cryptography
-		hasher.Write(data)
-		return hex.EncodeToString(hasher.Sum(nil))
-	}
-
-	// Calculate MD5 of each part and concatenate
-	var partMD5s []byte
-	for i := 0; i < numParts; i++ {
-		start := i * partSize
-		end := start + partSize
-		if end > len(data) {
-			end = len(data)
-		}
-
-		partHasher := md5.New() //nolint:gosec // MD5 used for checksums, not cryptography
-		partHasher.Write(data[start:end])
-		partMD5s = append(partMD5s, partHasher.Sum(nil)...)
+	head, definite, _ := headObject(ctx, a.s3Client, a.config.S3.Bucket, key)
+	if definite {
+		a.heads.set(key, head)
 	}
-
-	// Calculate MD5 of concatenated MD5s
-	finalHasher := md5.New() //nolint:gosec // MD5 used for checksums, not cryptography
-	finalHasher.Write(partMD5s)
-	finalMD5 := hex.EncodeToString(finalHasher.Sum(nil))
-
-	// Return in S3 multipart format: MD5-numParts
-	return fmt.Sprintf("%s-%d", finalMD5, numParts)
+	return head.Exists, head.Size, head.ETag
 }
 
 // calculateMultipartETagFromFile calculates multipart ETag from a file
 func (a *Archiver) calculateMultipartETagFromFile(filePath string) (string, error) {
-	const partSize = 5 * 1024 * 1024 // 5MB part size (s3manager default)
-
 	file, err := os.Open(filePath)
 	if err != nil {
 		return "", fmt.Errorf("failed to open file: %w", err)
@@ -1793,84 +2108,39 @@ func (a *Archiver) calculateMultipartETagFromFile(filePath string) (string, erro
 	if err != nil {
 		return "", fmt.Errorf("failed to stat file: %w", err)
 	}
-	fileSize := fileInfo.Size()
-
-	// Calculate number of parts
-	numParts := int((fileSize + partSize - 1) / partSize)
-
-	// If it would be a single part, just return regular MD5
-	if numParts == 1 {
-		hasher := md5.New() //nolint:gosec // MD5 used for checksums, not cryptography
-		if _, err := io.Copy(hasher, file); err != nil {
-			return "", fmt.Errorf("failed to hash file: %w", err)
-		}
-		return hex.EncodeToString(hasher.Sum(nil)), nil
-	}
-
-	// Calculate MD5 of each part
-	var partMD5s []byte
-	buffer := make([]byte, partSize)
-
-	for i := 0; i < numParts; i++ {
-		partHasher := md5.New() //nolint:gosec // MD5 used for checksums, not cryptography
 
-		// Read one part
-		n, err := io.ReadFull(file, buffer)
-		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
-			return "", fmt.Errorf("failed to read part %d: %w", i, err)
-		}
-
-		// Hash the part data
-		partHasher.Write(buffer[:n])
-		partMD5s = append(partMD5s, partHasher.Sum(nil)...)
+	// Same part size as uploadTempFileToS3
+	hasher := newMultipartHasher(a.objectPartSize(fileInfo.Size()))
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", fmt.Errorf("failed to hash file: %w", err)
 	}
-
-	// Calculate MD5 of concatenated MD5s
-	finalHasher := md5.New() //nolint:gosec // MD5 used for checksums, not cryptography
-	finalHasher.Write(partMD5s)
-	finalMD5 := hex.EncodeToString(finalHasher.Sum(nil))
-
-	// Return in S3 multipart format: MD5-numParts
-	return fmt.Sprintf("%s-%d", finalMD5, numParts), nil
+	etag, _ := hasher.multipartETag()
+	return etag, nil
 }
 
 func (a *Archiver) uploadToS3(key string, data []byte) error {
+	a.heads.forget(key)
 	a.logger.Debug(fmt.Sprintf("   ☁️  Uploading to s3://%s/%s (size: %d bytes)",
 		a.config.S3.Bucket, key, len(data)))
 
-	// Use multipart upload for files larger than 100MB
-	if len(data) > 100*1024*1024 {
-		// Check if S3 uploader is initialized
-		if a.s3Uploader == nil {
-			return ErrS3UploaderNotInitialized
-		}
-
-		// Use S3 manager for automatic multipart upload handling
-		uploadInput := &s3manager.UploadInput{
-			Bucket:      aws.String(a.config.S3.Bucket),
-			Key:         aws.String(key),
-			Body:        bytes.NewReader(data),
-			ContentType: aws.String("application/zstd"),
-		}
-
-		_, err := a.s3Uploader.Upload(uploadInput)
-		return err
-	}
-
 	// Check if S3 client is initialized
 	if a.s3Client == nil {
 		return ErrS3ClientNotInitialized
 	}
+	ctx := a.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	body := throttleUpload(a.ctx, bytes.NewReader(data), a.bandwidth)
 
-	// Use simple PutObject for smaller files
-	putInput := &s3.PutObjectInput{
-		Bucket:      aws.String(a.config.S3.Bucket),
-		Key:         aws.String(key),
-		Body:        bytes.NewReader(data),
-		ContentType: aws.String("application/zstd"),
+	// Use multipart upload for files larger than 100MB
+	if len(data) > 100*1024*1024 {
+		_, err := a.s3Client.Upload(ctx, a.config.S3.Bucket, key, body, a.uploadOptions(key, int64(len(data))))
+		return err
 	}
 
-	_, err := a.s3Client.PutObject(putInput)
+	// Use a single PUT for smaller files
+	_, err := a.s3Client.Put(ctx, a.config.S3.Bucket, key, body, a.putOptions(key))
 	return err
 }
 
@@ -1880,9 +2150,16 @@ func (a *Archiver) printSummary(results []ProcessResult, startTime time.Time, to
 	var totalRows int64
 	var totalDuration time.Duration
 	var failedResults []ProcessResult
+	var dateMismatches []ProcessResult
+	var valueIssues valueIssueCounts
 	var minDate, maxDate *time.Time
+	log := summaryLogger(a.logger)
 
 	for _, r := range results {
+		valueIssues.add(r.ValueIssues)
+		if r.DateCheck.Mismatch() {
+			dateMismatches = append(dateMismatches, r)
+		}
 		if r.Error != nil {
 			failed++
 			failedResults = append(failedResults, r)
@@ -1920,69 +2197,124 @@ func (a *Archiver) printSummary(results []ProcessResult, startTime time.Time, to
 		successRate = float64(successful) / float64(totalProcessed) * 100
 	}
 
-	a.logger.Info("")
-	a.logger.Info("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-	a.logger.Info("📈 Summary")
-	a.logger.Info(fmt.Sprintf("   Total Partitions: %d", totalPartitions))
-	a.logger.Info(fmt.Sprintf("   ✅ Successful: %d", successful))
+	log.Info("")
+	log.Info("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	log.Info("📈 Summary")
+	log.Info(fmt.Sprintf("   Run ID: %s", currentRunID))
+	log.Info(fmt.Sprintf("   Total Partitions: %d", totalPartitions))
+	log.Info(fmt.Sprintf("   ✅ Successful: %d", successful))
 	if skipped > 0 {
-		a.logger.Info(fmt.Sprintf("   ⏭️  Skipped: %d", skipped))
+		log.Info(fmt.Sprintf("   ⏭️  Skipped: %d", skipped))
+	}
+	if len(a.permissionDenied) > 0 {
+		log.Warn(fmt.Sprintf("   🔒 Permission Denied: %d", len(a.permissionDenied)))
 	}
 	if failed > 0 {
-		a.logger.Info(fmt.Sprintf("   ❌ Failed: %d", failed))
+		log.Info(fmt.Sprintf("   ❌ Failed: %d", failed))
 	}
 
 	// Show archive rate
 	if totalProcessed > 0 {
 		rateStr := fmt.Sprintf("%.1f%%", successRate)
-		a.logger.Info(fmt.Sprintf("   Archive Rate: %s", rateStr))
+		log.Info(fmt.Sprintf("   Archive Rate: %s", rateStr))
 	}
 
 	// Show total rows transferred
 	if totalRows > 0 {
-		a.logger.Info(fmt.Sprintf("   Total Transferred: %s rows", formatNumberForSummary(totalRows)))
+		log.Info(fmt.Sprintf("   Total Transferred: %s rows", formatNumberForSummary(totalRows)))
 	}
 
 	// Show total bytes if any uploads occurred
 	if totalBytes > 0 {
-		a.logger.Info(fmt.Sprintf("   Total Data Uploaded: %s", formatBytesForSummary(totalBytes)))
+		log.Info(fmt.Sprintf("   Total Data Uploaded: %s", formatBytesForSummary(totalBytes)))
+	}
+
+	// Show the overall compression ratio of the files written
+	ratioTotal, ratioAnomalies := summarizeRatios(results)
+	if ratioTotal.Ratio > 0 {
+		log.Info(fmt.Sprintf("   Compression: %s", ratioTotal))
 	}
 
 	// Show duration and throughput
 	if totalElapsed > 0 {
-		a.logger.Info(fmt.Sprintf("   Total Duration: %s", formatDurationForSummary(totalElapsed)))
+		log.Info(fmt.Sprintf("   Total Duration: %s", formatDurationForSummary(totalElapsed)))
 
 		// Calculate throughput
 		if totalRows > 0 && totalElapsed.Seconds() > 0 {
 			rowsPerSec := float64(totalRows) / totalElapsed.Seconds()
-			a.logger.Info(fmt.Sprintf("   Throughput: %s rows/sec", formatFloatForSummary(rowsPerSec)))
+			log.Info(fmt.Sprintf("   Throughput: %s rows/sec", formatFloatForSummary(rowsPerSec)))
 			if totalBytes > 0 {
 				mbPerSec := float64(totalBytes) / (1024 * 1024) / totalElapsed.Seconds()
-				a.logger.Info(fmt.Sprintf("   Throughput: %s MB/sec", formatFloatForSummary(mbPerSec)))
+				log.Info(fmt.Sprintf("   Throughput: %s MB/sec", formatFloatForSummary(mbPerSec)))
 			}
 		}
 
 		// Show average time per partition
 		if successful > 0 && totalDuration > 0 {
 			avgDuration := totalDuration / time.Duration(successful)
-			a.logger.Info(fmt.Sprintf("   Avg Time per Partition: %s", formatDurationForSummary(avgDuration)))
+			log.Info(fmt.Sprintf("   Avg Time per Partition: %s", formatDurationForSummary(avgDuration)))
 		}
 	}
 
+	// Show S3 API usage, which is what S3 bills per request
+	if a.s3Requests.total() > 0 {
+		log.Info(fmt.Sprintf("   S3 Requests: %s", a.s3Requests))
+	}
+
+	// Show CPU time per stage (process-wide, so it includes any tables archived concurrently)
+	if usage := a.cpu.usage(); len(usage) > 0 {
+		log.Info(fmt.Sprintf("   CPU Time: %s", formatCPUUsage(usage)))
+	}
+
 	// Show date range
 	if minDate != nil && maxDate != nil {
 		if minDate.Equal(*maxDate) {
-			a.logger.Info(fmt.Sprintf("   Date Range: %s", minDate.Format("2006-01-02")))
+			log.Info(fmt.Sprintf("   Date Range: %s", minDate.Format("2006-01-02")))
 		} else {
-			a.logger.Info(fmt.Sprintf("   Date Range: %s to %s", minDate.Format("2006-01-02"), maxDate.Format("2006-01-02")))
+			log.Info(fmt.Sprintf("   Date Range: %s to %s", minDate.Format("2006-01-02"), maxDate.Format("2006-01-02")))
 		}
 	}
 
+	// List partitions holding rows outside the date in their name
+	if len(dateMismatches) > 0 {
+		log.Warn("")
+		log.Warn("   Partition Date Mismatches:")
+		log.Warn("")
+		for _, result := range dateMismatches {
+			log.Warn(fmt.Sprintf("   ⚠️  %s: %s", result.Partition.TableName, result.DateCheck))
+		}
+	}
+
+	// List files whose compression ratio is far off the table's norm
+	if len(ratioAnomalies) > 0 {
+		log.Warn("")
+		log.Warn("   Compression Ratio Anomalies (possibly truncated or corrupted extractions):")
+		log.Warn("")
+		for _, ratio := range ratioAnomalies {
+			log.Warn(fmt.Sprintf("   ⚠️  %s: %s, %s against the table's usual %.1fx", ratio.S3Key, ratio, ratio.Anomaly, ratio.Norm))
+		}
+	}
+
+	// Report values fixed or quarantined by --invalid-values
+	if valueIssues.any() {
+		log.Warn(fmt.Sprintf("   🧹 Invalid Values: %s", valueIssues))
+		if valueIssues.Quarantined > 0 {
+			dir := a.config.QuarantineDir
+			if dir == "" {
+				dir = getQuarantineDir()
+			}
+			log.Warn(fmt.Sprintf("   🧹 Quarantined rows written under %s", dir))
+		}
+	}
+
+	// List partitions the run could not read
+	a.printPermissionDenied()
+
 	// List failures with details
 	if len(failedResults) > 0 {
-		a.logger.Error("")
-		a.logger.Error("   Failed Partitions:")
-		a.logger.Error("")
+		log.Error("")
+		log.Error("   Failed Partitions:")
+		log.Error("")
 		for _, result := range failedResults {
 			partitionName := result.Partition.TableName
 			errorMsg := result.Error.Error()
@@ -1990,7 +2322,7 @@ func (a *Archiver) printSummary(results []ProcessResult, startTime time.Time, to
 			if len(errorMsg) > 80 {
 				errorMsg = errorMsg[:77] + "..."
 			}
-			a.logger.Error(fmt.Sprintf("   ❌ %s: %s", partitionName, errorMsg))
+			log.Error(fmt.Sprintf("   ❌ %s: %s", partitionName, errorMsg))
 		}
 	}
 }
@@ -2061,18 +2393,19 @@ func formatFloatForSummary(f float64) string {
 // extractRowsWithDateFilter extracts rows from partition with date range filtering
 func (a *Archiver) extractRowsWithDateFilter(partition PartitionInfo, startTime, endTime time.Time) ([]map[string]interface{}, error) {
 	quotedTable := pq.QuoteIdentifier(partition.TableName)
-	quotedDateColumn := pq.QuoteIdentifier(a.config.DateColumn)
+	condition, args := a.config.dateColumnSpec().rangeCondition(startTime, endTime)
 
 	// Build query with date range filter
-	query := fmt.Sprintf(
-		"SELECT row_to_json(t) FROM %s t WHERE %s >= $1 AND %s < $2",
-		quotedTable,
-		quotedDateColumn,
-		quotedDateColumn,
-	)
+	query := fmt.Sprintf("SELECT row_to_json(t) FROM %s t WHERE %s", quotedTable, condition)
+
+	release, err := a.acquireQuerySlot(a.ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	// Use queryWithRetry for automatic retry on timeout/connection errors
-	rows, err := a.queryWithRetry(a.ctx, query, startTime, endTime)
+	rows, err := a.queryWithRetry(a.ctx, a.tagQuery(query, partition.TableName), args...)
 	if err != nil {
 		// Check if error is due to cancellation or closed connection
 		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || isConnectionError(err) {
@@ -2250,14 +2583,31 @@ func calculateUncompressedRowSize(row map[string]interface{}, format string, col
 // extractPartitionDataStreaming extracts partition data using streaming architecture
 // This streams data in chunks to a temp file, avoiding loading everything into memory
 // If startTime and endTime are provided (not zero), adds a WHERE clause to filter by date column
+// compressionLevel is the level passed to the external compressor (ignored for Parquet)
+// When parts is non-nil and --max-rows-per-file is set, every output file is appended to
+// parts and the returned path, size and MD5 describe the last one
 //
 //nolint:nakedret,gocognit,gocyclo // Complex streaming function with named returns for clarity, high complexity unavoidable
-func (a *Archiver) extractPartitionDataStreaming(partition PartitionInfo, program *tea.Program, cache *PartitionCache, updateTaskStage func(string), startTime, endTime time.Time) (tempFilePath string, fileSize int64, md5Hash string, uncompressedSize int64, err error) {
+func (a *Archiver) extractPartitionDataStreaming(partition PartitionInfo, program *tea.Program, cache *PartitionCache, updateTaskStage func(string), startTime, endTime time.Time, compressionLevel int, issues *valueIssueCounts, parts *[]archivePart, recorded *extractionQuery) (tempFilePath string, fileSize int64, md5Hash string, uncompressedSize int64, rowCount int64, err error) {
 	extractStart := time.Now()
 	updateTaskStage("Getting table schema...")
 
-	// Get table schema for streaming formatters
-	schema, schemaErr := a.getTableSchema(a.ctx, partition.TableName)
+	// Get table schema for streaming formatters. A custom query's schema comes
+	// from the query itself, run over this partition's (or slice's) time range.
+	var query string
+	var queryArgs []interface{}
+	var schema *TableSchema
+	var schemaErr error
+	if customSQL := a.config.customQuery(); customSQL != "" {
+		rangeStart, rangeEnd := startTime, endTime
+		if rangeStart.IsZero() || rangeEnd.IsZero() {
+			rangeStart, rangeEnd = a.partitionTimeRange(partition)
+		}
+		query, queryArgs = renderCustomQuery(customSQL, partition.TableName, rangeStart, rangeEnd)
+		schema, schemaErr = a.getQuerySchema(a.ctx, partition.TableName, query, queryArgs)
+	} else {
+		schema, schemaErr = a.partitionSchema(a.ctx, partition.TableName)
+	}
 	if schemaErr != nil {
 		cache.setError(partition.TableName, fmt.Sprintf("Schema query failed: %v", schemaErr))
 		_ = cache.save(a.config.CacheScope)
@@ -2265,112 +2615,264 @@ func (a *Archiver) extractPartitionDataStreaming(partition PartitionInfo, progra
 		return
 	}
 
+	// Mask --mask columns before rows leave the extraction loop (nil = no masking)
+	masker, maskErr := a.newColumnMasker(schema)
+	if maskErr != nil {
+		err = maskErr
+		return
+	}
+	outputSchema := masker.outputSchema(schema)
+
+	// Field names must map back to their columns on restore
+	if mapErr := checkFieldMappingColumns(a.config.FieldMapping, schema); mapErr != nil {
+		err = mapErr
+		return
+	}
+
 	// Determine chunk size (use config or default)
 	chunkSize := a.config.ChunkSize
 	if chunkSize <= 0 {
 		chunkSize = 10000 // Default chunk size
 	}
 
-	// Create temp file for output
-	tempFile, tempErr := createTempFile()
-	if tempErr != nil {
-		err = fmt.Errorf("failed to create temp file: %w", tempErr)
-		return
+	// Build column list for SELECT query
+	columns := schema.GetColumns()
+	columnNames := make([]string, len(columns))
+	unquotedColumnNames := make([]string, len(columns))
+	for i, col := range columns {
+		unquotedColumnNames[i] = col.GetName()
+		columnNames[i] = pq.QuoteIdentifier(col.GetName())
 	}
-	tempFilePath = tempFile.Name()
 
-	// Ensure cleanup on error
-	defer func() {
-		if err != nil {
-			tempFile.Close()
-			cleanupTempFile(tempFilePath)
-			tempFilePath = ""
+	// With --max-rows-per-file, output rotates to a new temp file whenever
+	// the current one holds that many rows; every file is appended to parts
+	var maxRows int64
+	if parts != nil {
+		maxRows = a.config.MaxRowsPerFile
+	}
+
+	// With --split-by-column, rows fan out to a file per value of the column,
+	// every file is appended to parts, and fanOut stands in for the stream writer
+	var fanOut *fanOutWriter
+	if parts != nil && a.config.SplitByColumn != "" {
+		if fanOut, err = a.newFanOutWriter(schema, outputSchema, compressionLevel, parts); err != nil {
+			return
 		}
-	}()
+		maxRows = 0
+	}
 
 	updateTaskStage("Setting up streaming pipeline...")
 
 	// Get streaming formatter
-	formatter := formatters.GetStreamingFormatter(a.config.OutputFormat)
+	formatter := a.streamingFormatter()
 
 	// Set up streaming pipeline based on format's compression handling
+	var tempFile *os.File
 	var streamWriter formatters.StreamWriter
 	var compressorWriter io.WriteCloser
-	var hasher hash.Hash
-	var multiWriter io.Writer
+	var hasher *multipartHasher
+	var partStart, partUncompressed int64
 
-	if formatters.UsesInternalCompression(a.config.OutputFormat) {
-		// Parquet handles compression internally
-		// Pipeline: formatter → hasher → tempFile
-		hasher = md5.New() //nolint:gosec // MD5 used for checksums, not cryptography
-		multiWriter = io.MultiWriter(tempFile, hasher)
-		streamWriter, err = formatter.NewWriter(multiWriter, schema)
+	// Ensure cleanup on error
+	defer func() {
 		if err != nil {
-			err = fmt.Errorf("failed to create streaming formatter: %w", err)
-			return
+			if tempFile != nil {
+				tempFile.Close()
+			}
+			if fanOut != nil {
+				fanOut.abort()
+			}
+			cleanupTempFile(tempFilePath)
+			tempFilePath = ""
+			if parts != nil {
+				for _, part := range *parts {
+					cleanupTempFile(part.Path)
+				}
+				*parts = nil
+			}
 		}
-	} else {
-		// External compression needed (JSONL, CSV)
-		// Pipeline: formatter → compressor → hasher → tempFile
-		compressor, compErr := compressors.GetCompressor(a.config.Compression)
-		if compErr != nil {
-			err = fmt.Errorf("failed to get compressor: %w", compErr)
-			return
+	}()
+
+	// openOutput creates a temp file and the formatter pipeline writing to it
+	openOutput := func() error {
+		if fanOut != nil {
+			streamWriter = fanOut
+			return nil
+		}
+		file, createErr := createTempFile()
+		if createErr != nil {
+			return fmt.Errorf("failed to create temp file: %w", createErr)
+		}
+		tempFile = file
+		tempFilePath = file.Name()
+		hasher = newMultipartHasher(a.config.S3.basePartSize())
+		multiWriter := io.MultiWriter(tempFile, hasher)
+		compressorWriter = nil
+
+		var writerErr error
+		if formatters.UsesInternalCompression(a.config.OutputFormat) {
+			// Parquet handles compression internally
+			// Pipeline: formatter → hasher → tempFile
+			streamWriter, writerErr = formatter.NewWriter(multiWriter, outputSchema)
+		} else {
+			// External compression needed (JSONL, CSV)
+			// Pipeline: formatter → compressor → hasher → tempFile
+			var compErr error
+			compressorWriter, compErr = a.newCompressorWriter(multiWriter, compressionLevel, tempFilePath)
+			if compErr != nil {
+				return compErr
+			}
+			streamWriter, writerErr = formatter.NewWriter(compressorWriter, outputSchema)
+		}
+		if writerErr != nil {
+			if compressorWriter != nil {
+				compressorWriter.Close()
+			}
+			return fmt.Errorf("failed to create streaming formatter: %w", writerErr)
 		}
 
-		hasher = md5.New() //nolint:gosec // MD5 used for checksums, not cryptography
-		multiWriter = io.MultiWriter(tempFile, hasher)
-		compressorWriter = compressor.NewWriter(multiWriter, a.config.CompressionLevel)
+		// Rename/flatten fields before they reach the formatter
+		streamWriter = formatters.NewMappedStreamWriter(streamWriter, a.config.FieldMapping)
 
-		streamWriter, err = formatter.NewWriter(compressorWriter, schema)
-		if err != nil {
+		partStart, partUncompressed = rowCount, 0
+
+		// Account for CSV header row in uncompressed size
+		if a.config.OutputFormat == formatters.FormatCSV {
+			// CSV header: column names separated by commas + newline
+			headerSize := int64(len(strings.Join(unquotedColumnNames, ",")) + 1) // commas + newline
+			uncompressedSize += headerSize
+			partUncompressed += headerSize
+		}
+		return nil
+	}
+
+	// closeOutput finalizes the current temp file and records its size and MD5
+	closeOutput := func() error {
+		if fanOut != nil {
+			if closeErr := fanOut.Close(); closeErr != nil {
+				return closeErr
+			}
+			uncompressedSize += fanOut.uncompressed
+			last := (*parts)[len(*parts)-1]
+			tempFilePath, fileSize, md5Hash = last.Path, last.Size, last.MD5
+			return nil
+		}
+		// Close stream writer (this flushes formatters and writes footers)
+		if closeErr := streamWriter.Close(); closeErr != nil {
 			if compressorWriter != nil {
 				compressorWriter.Close()
 			}
-			err = fmt.Errorf("failed to create streaming formatter: %w", err)
-			return
+			return fmt.Errorf("failed to close stream writer: %w", closeErr)
+		}
+
+		// Close compressor (if used)
+		if compressorWriter != nil {
+			if closeErr := compressorWriter.Close(); closeErr != nil {
+				return fmt.Errorf("failed to close compressor: %w", closeErr)
+			}
+		}
+
+		// Close temp file to flush all writes
+		closeErr := tempFile.Close()
+		tempFile = nil
+		if closeErr != nil {
+			return fmt.Errorf("failed to close temp file: %w", closeErr)
+		}
+
+		// Get file size
+		fileInfo, statErr := os.Stat(tempFilePath)
+		if statErr != nil {
+			return fmt.Errorf("failed to stat temp file: %w", statErr)
 		}
+		fileSize = fileInfo.Size()
+
+		// Get MD5 hash, and the multipart ETag of a file that will be uploaded in parts
+		md5Hash = hasher.md5()
+		if maxRows == 0 && fileSize > multipartUploadThreshold {
+			if etag, ok := hasher.multipartETag(); ok {
+				a.etags.set(tempFilePath, etag)
+			}
+		}
+
+		if maxRows > 0 {
+			*parts = append(*parts, archivePart{
+				Number:           len(*parts) + 1,
+				Path:             tempFilePath,
+				Size:             fileSize,
+				MD5:              md5Hash,
+				UncompressedSize: partUncompressed,
+				FirstRow:         partStart + 1,
+				LastRow:          rowCount,
+			})
+		}
+		return nil
 	}
 
-	// Stream data in chunks
-	updateTaskStage("Extracting data...")
-	if program != nil && partition.RowCount > 0 {
-		program.Send(updateProgress("Extracting data...", 0, partition.RowCount))
+	if openErr := openOutput(); openErr != nil {
+		err = openErr
+		return
 	}
 
-	// Build column list for SELECT query
-	columns := schema.GetColumns()
-	columnNames := make([]string, len(columns))
-	unquotedColumnNames := make([]string, len(columns))
-	for i, col := range columns {
-		unquotedColumnNames[i] = col.GetName()
-		columnNames[i] = pq.QuoteIdentifier(col.GetName())
+	// Fix or quarantine values the formatter cannot encode (nil = --invalid-values off)
+	sanitizer := a.newValueSanitizer(partition, startTime)
+	defer sanitizer.close()
+
+	// Stream data in chunks
+	updateTaskStage("Extracting data...")
+	if partition.RowCount > 0 {
+		a.sendProgress(program, partition.TableName, "Extracting data...", 0, partition.RowCount)
 	}
 
-	quotedTable := pq.QuoteIdentifier(partition.TableName)
-	//nolint:gosec // G201: SQL string formatting is safe here - all identifiers are properly quoted via pq.QuoteIdentifier
-	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(columnNames, ", "), quotedTable)
-	var queryArgs []interface{}
+	if query == "" {
+		quotedTable := pq.QuoteIdentifier(partition.TableName)
+		//nolint:gosec // G201: SQL string formatting is safe here - all identifiers are properly quoted via pq.QuoteIdentifier
+		query = fmt.Sprintf("SELECT %s FROM %s", strings.Join(columnNames, ", "), quotedTable)
 
-	// Add date filtering if startTime and endTime are provided
-	if !startTime.IsZero() && !endTime.IsZero() && a.config.DateColumn != "" {
-		quotedDateColumn := pq.QuoteIdentifier(a.config.DateColumn)
-		query += fmt.Sprintf(" WHERE %s >= $1 AND %s < $2", quotedDateColumn, quotedDateColumn)
-		queryArgs = []interface{}{startTime, endTime}
+		// Add date filtering if startTime and endTime are provided
+		if !startTime.IsZero() && !endTime.IsZero() && a.config.DateColumn != "" {
+			var condition string
+			condition, queryArgs = a.config.dateColumnSpec().rangeCondition(startTime, endTime)
+			query += " WHERE " + condition
+		}
+		if partition.HasKeyRange() {
+			var condition string
+			condition, queryArgs = partition.Keys.condition()
+			query += " WHERE " + condition
+		}
+		if partition.HasIncrement() {
+			var condition string
+			condition, queryArgs = partition.Increment.condition()
+			query += " WHERE " + condition
+		}
+		// Every slice condition above has arguments
+		query, queryArgs = filterQuery(a.config.Filter, query, queryArgs, len(queryArgs) > 0)
 	}
 
-	// Account for CSV header row in uncompressed size
-	if a.config.OutputFormat == formatters.FormatCSV {
-		// CSV header: column names separated by commas + newline
-		headerSize := int64(len(strings.Join(unquotedColumnNames, ",")) + 1) // commas + newline
-		uncompressedSize += headerSize
+	// The query slot is held until the rows are read and the file is written
+	updateTaskStage("Waiting for a database query slot...")
+	release, slotErr := a.acquireQuerySlot(a.ctx)
+	if slotErr != nil {
+		streamWriter.Close()
+		if compressorWriter != nil {
+			compressorWriter.Close()
+		}
+		err = slotErr
+		return
 	}
+	defer release()
 
-	var rows *sql.Rows
+	var rows extractRows
 	var queryErr error
-	if len(queryArgs) > 0 {
+	query = a.tagQuery(a.limitQuery(query), partition.TableName)
+	a.recordQuery(recorded, partition.TableName, query, queryArgs)
+	switch {
+	case a.config.ExtractMethod == extractMethodCopy:
+		// COPY streams rows in text format through psql, skipping the
+		// per-row protocol overhead of a SELECT
+		rows, queryErr = a.queryCopy(a.ctx, query, queryArgs, columns)
+	case len(queryArgs) > 0:
 		rows, queryErr = a.db.QueryContext(a.ctx, query, queryArgs...)
-	} else {
+	default:
 		rows, queryErr = a.db.QueryContext(a.ctx, query)
 	}
 	if queryErr != nil {
@@ -2383,16 +2885,15 @@ func (a *Archiver) extractPartitionDataStreaming(partition PartitionInfo, progra
 	}
 	defer rows.Close()
 
-	// Prepare scan targets - use interface{} to let database/sql handle type conversion
-	scanValues := make([]interface{}, len(columns))
-	scanPointers := make([]interface{}, len(columns))
-	for i := range scanValues {
-		scanPointers[i] = &scanValues[i]
-	}
+	// Prepare scan targets - use interface{} to let database/sql handle type conversion.
+	// Scan targets and row maps are reused, which matters for wide tables.
+	buffer := newRowBuffer(columns)
+
+	// Large objects the rows reference are exported once the file is written
+	largeObjects := a.newLargeObjectRefs(columns)
 
 	// Process rows in chunks
 	chunk := make([]map[string]interface{}, 0, chunkSize)
-	rowCount := int64(0)
 	updateInterval := int64(1000)
 
 	if partition.RowCount > 0 {
@@ -2415,10 +2916,18 @@ func (a *Archiver) extractPartitionDataStreaming(partition PartitionInfo, progra
 				return
 			default:
 			}
+			if paceErr := a.paceRows(); paceErr != nil {
+				streamWriter.Close()
+				if compressorWriter != nil {
+					compressorWriter.Close()
+				}
+				err = paceErr
+				return
+			}
 		}
 
-		// Scan row columns into scanValues
-		if scanErr := rows.Scan(scanPointers...); scanErr != nil {
+		// Scan row columns into the shared scan targets
+		if scanErr := buffer.scan(rows); scanErr != nil {
 			streamWriter.Close()
 			if compressorWriter != nil {
 				compressorWriter.Close()
@@ -2428,17 +2937,41 @@ func (a *Archiver) extractPartitionDataStreaming(partition PartitionInfo, progra
 		}
 
 		// Convert to map[string]interface{} with type conversion
-		rowData := make(map[string]interface{}, len(columns))
-		for i, col := range columns {
-			// Convert PostgreSQL driver types to appropriate Go types for formatters
-			rowData[col.GetName()] = convertPostgreSQLValue(scanValues[i], col.GetType())
+		rowData := buffer.row()
+		masker.mask(rowData)
+
+		keep, sanitizeErr := sanitizer.sanitize(rowData)
+		if sanitizeErr != nil {
+			streamWriter.Close()
+			if compressorWriter != nil {
+				compressorWriter.Close()
+			}
+			err = sanitizeErr
+			return
+		}
+		if !keep {
+			buffer.recycle(rowData)
+			continue // Quarantined
+		}
+		largeObjects.collect(rowData)
+
+		// Start the next part once the current one holds --max-rows-per-file rows
+		if maxRows > 0 && rowCount-partStart == maxRows {
+			if rotateErr := closeOutput(); rotateErr != nil {
+				err = rotateErr
+				return
+			}
+			if rotateErr := openOutput(); rotateErr != nil {
+				err = rotateErr
+				return
+			}
 		}
 
 		chunk = append(chunk, rowData)
 		rowCount++
 
-		// Write chunk when full
-		if len(chunk) >= chunkSize {
+		// Write chunk when full, or when it completes the current part
+		if len(chunk) >= chunkSize || (maxRows > 0 && rowCount-partStart == maxRows) {
 			if writeErr := streamWriter.WriteChunk(chunk); writeErr != nil {
 				streamWriter.Close()
 				if compressorWriter != nil {
@@ -2450,14 +2983,17 @@ func (a *Archiver) extractPartitionDataStreaming(partition PartitionInfo, progra
 
 			// Track uncompressed size based on output format
 			for _, row := range chunk {
-				uncompressedSize += calculateUncompressedRowSize(row, a.config.OutputFormat, unquotedColumnNames)
+				rowSize := calculateUncompressedRowSize(row, a.config.OutputFormat, unquotedColumnNames)
+				uncompressedSize += rowSize
+				partUncompressed += rowSize
 			}
 
+			buffer.recycle(chunk...)
 			chunk = chunk[:0] // Reset slice, keeping capacity
 
 			// Update progress
-			if program != nil && partition.RowCount > 0 && rowCount%updateInterval == 0 {
-				program.Send(updateProgress("Extracting data...", rowCount, partition.RowCount))
+			if partition.RowCount > 0 && rowCount%updateInterval == 0 {
+				a.sendProgress(program, partition.TableName, "Extracting data...", rowCount, partition.RowCount)
 			}
 		}
 	}
@@ -2485,57 +3021,57 @@ func (a *Archiver) extractPartitionDataStreaming(partition PartitionInfo, progra
 
 		// Track uncompressed size of final chunk
 		for _, row := range chunk {
-			uncompressedSize += calculateUncompressedRowSize(row, a.config.OutputFormat, unquotedColumnNames)
+			rowSize := calculateUncompressedRowSize(row, a.config.OutputFormat, unquotedColumnNames)
+			uncompressedSize += rowSize
+			partUncompressed += rowSize
 		}
 	}
 
-	// Close stream writer (this flushes formatters and writes footers)
-	if closeErr := streamWriter.Close(); closeErr != nil {
-		if compressorWriter != nil {
-			compressorWriter.Close()
-		}
-		err = fmt.Errorf("failed to close stream writer: %w", closeErr)
+	if closeErr := closeOutput(); closeErr != nil {
+		err = closeErr
 		return
 	}
 
-	// Close compressor (if used)
-	if compressorWriter != nil {
-		if closeErr := compressorWriter.Close(); closeErr != nil {
-			err = fmt.Errorf("failed to close compressor: %w", closeErr)
+	// Report values fixed or quarantined by --invalid-values
+	if sanitizer != nil {
+		if closeErr := sanitizer.close(); closeErr != nil {
+			err = fmt.Errorf("failed to close quarantine file: %w", closeErr)
 			return
 		}
+		if sanitizer.counts.any() {
+			a.logger.Debug(fmt.Sprintf("   🧹 %s: %s", partition.TableName, sanitizer.counts))
+		}
+		if sanitizer.counts.Quarantined > 0 {
+			a.logger.Debug(fmt.Sprintf("   🧹 Quarantined rows written to %s", sanitizer.path))
+		}
+		if issues != nil {
+			*issues = sanitizer.counts
+		}
 	}
 
-	// Close temp file to flush all writes
-	if closeErr := tempFile.Close(); closeErr != nil {
-		err = fmt.Errorf("failed to close temp file: %w", closeErr)
-		return
-	}
-
-	// Get file size
-	fileInfo, statErr := os.Stat(tempFilePath)
-	if statErr != nil {
-		err = fmt.Errorf("failed to stat temp file: %w", statErr)
+	if exportErr := a.exportLargeObjects(a.ctx, partition.TableName, largeObjects); exportErr != nil {
+		err = exportErr
 		return
 	}
-	fileSize = fileInfo.Size()
-
-	// Get MD5 hash
-	md5Hash = hex.EncodeToString(hasher.Sum(nil))
 
 	extractDuration := time.Since(extractStart)
 	a.logger.Debug(fmt.Sprintf("   ⏱️  Streaming extraction took %v for %s (%d rows, %d bytes)",
 		extractDuration, partition.TableName, rowCount, fileSize))
 
-	// Update progress
-	if program != nil {
-		if partition.RowCount > 0 {
-			program.Send(updateProgress("Extraction complete", partition.RowCount, partition.RowCount))
-		} else {
-			program.Send(updateProgress(fmt.Sprintf("Extraction complete (%d rows)", rowCount), 0, 0))
+	// Feed throughput back to the adaptive compression controller
+	if a.compression != nil && compressionLevel > 0 {
+		if previous, next := a.compression.observe(uncompressedSize, extractDuration); previous != next {
+			a.logger.Debug(fmt.Sprintf("   🎚️  Adaptive compression level %d → %d", previous, next))
 		}
 	}
 
+	// Update progress
+	if partition.RowCount > 0 {
+		a.sendProgress(program, partition.TableName, "Extraction complete", partition.RowCount, partition.RowCount)
+	} else {
+		a.sendProgress(program, partition.TableName, fmt.Sprintf("Extraction complete (%d rows)", rowCount), 0, 0)
+	}
+
 	// Save row count to cache immediately if it was unknown
 	if partition.RowCount <= 0 && rowCount > 0 {
 		cache.setRowCount(partition.TableName, rowCount)
@@ -2547,20 +3083,20 @@ func (a *Archiver) extractPartitionDataStreaming(partition PartitionInfo, progra
 		}
 	}
 
-	return tempFilePath, fileSize, md5Hash, uncompressedSize, nil
+	return tempFilePath, fileSize, md5Hash, uncompressedSize, rowCount, nil
 }
 
 // extractPartitionDataWithRetry wraps extractPartitionDataStreaming with retry logic
-func (a *Archiver) extractPartitionDataWithRetry(partition PartitionInfo, program *tea.Program, cache *PartitionCache, updateTaskStage func(string)) (tempFilePath string, fileSize int64, md5Hash string, uncompressedSize int64, err error) {
+func (a *Archiver) extractPartitionDataWithRetry(partition PartitionInfo, program *tea.Program, cache *PartitionCache, updateTaskStage func(string), compressionLevel int, issues *valueIssueCounts, parts *[]archivePart, recorded *extractionQuery) (tempFilePath string, fileSize int64, md5Hash string, uncompressedSize int64, rowCount int64, err error) {
 	maxRetries := a.config.Database.MaxRetries
 	retryDelay := time.Duration(a.config.Database.RetryDelay) * time.Second
 
 	var lastErr error
 	for attempt := 0; attempt <= maxRetries; attempt++ {
-		tempPath, size, hash, uncompSize, extractErr := a.extractPartitionDataStreaming(partition, program, cache, updateTaskStage, time.Time{}, time.Time{})
+		tempPath, size, hash, uncompSize, rows, extractErr := a.extractPartitionDataStreaming(partition, program, cache, updateTaskStage, time.Time{}, time.Time{}, compressionLevel, issues, parts, recorded)
 
 		if extractErr == nil {
-			return tempPath, size, hash, uncompSize, nil
+			return tempPath, size, hash, uncompSize, rows, nil
 		}
 
 		lastErr = extractErr
@@ -2572,7 +3108,7 @@ func (a *Archiver) extractPartitionDataWithRetry(partition PartitionInfo, progra
 
 		// Check if error is retryable
 		if !isRetryableError(extractErr) {
-			return "", 0, "", 0, extractErr
+			return "", 0, "", 0, 0, extractErr
 		}
 
 		// If we've exhausted retries, return the error
@@ -2589,15 +3125,16 @@ func (a *Archiver) extractPartitionDataWithRetry(partition PartitionInfo, progra
 		case <-time.After(retryDelay):
 			continue
 		case <-a.ctx.Done():
-			return "", 0, "", 0, a.ctx.Err()
+			return "", 0, "", 0, 0, a.ctx.Err()
 		}
 	}
 
-	return "", 0, "", 0, fmt.Errorf("extraction failed after %d attempts: %w", maxRetries+1, lastErr)
+	return "", 0, "", 0, 0, fmt.Errorf("extraction failed after %d attempts: %w", maxRetries+1, lastErr)
 }
 
 // uploadTempFileToS3 uploads a temp file to S3, using multipart upload for large files
 func (a *Archiver) uploadTempFileToS3(tempFilePath, objectKey string) error {
+	a.heads.forget(objectKey)
 	// Open temp file for reading
 	file, err := os.Open(tempFilePath)
 	if err != nil {
@@ -2615,38 +3152,30 @@ func (a *Archiver) uploadTempFileToS3(tempFilePath, objectKey string) error {
 	a.logger.Debug(fmt.Sprintf("   ☁️  Uploading to s3://%s/%s (size: %d bytes)",
 		a.config.S3.Bucket, objectKey, fileSize))
 
-	// Use multipart upload for files larger than 100MB
-	if fileSize > 100*1024*1024 {
-		// Check if S3 uploader is initialized
-		if a.s3Uploader == nil {
-			return ErrS3UploaderNotInitialized
-		}
-
-		// Use S3 manager for automatic multipart upload handling
-		uploadInput := &s3manager.UploadInput{
-			Bucket:      aws.String(a.config.S3.Bucket),
-			Key:         aws.String(objectKey),
-			Body:        file,
-			ContentType: aws.String("application/octet-stream"),
-		}
-
-		_, err := a.s3Uploader.Upload(uploadInput)
-		return err
-	}
-
 	// Check if S3 client is initialized
 	if a.s3Client == nil {
 		return ErrS3ClientNotInitialized
 	}
+	if err := a.uploadZstdDictionary(tempFilePath, objectKey); err != nil {
+		return err
+	}
+
+	ctx := a.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
 
-	// Use simple PutObject for smaller files
-	putInput := &s3.PutObjectInput{
-		Bucket:      aws.String(a.config.S3.Bucket),
-		Key:         aws.String(objectKey),
-		Body:        file,
-		ContentType: aws.String("application/octet-stream"),
+	// Use a resumable multipart upload for files larger than 100MB, so an
+	// interrupted upload continues from its last completed part on the next run
+	if fileSize > multipartUploadThreshold {
+		uploader := newResumableUploader(a.s3Client, a.config.S3.Bucket, a.bandwidth, a.logger)
+		uploader.options = a.putOptions(objectKey)
+		uploader.partSize = a.objectPartSize
+		uploader.parallel = a.config.S3.uploadConcurrency()
+		return uploader.Upload(ctx, tempFilePath, objectKey)
 	}
 
-	_, err = a.s3Client.PutObject(putInput)
+	// Use a single PUT for smaller files
+	_, err = a.s3Client.Put(ctx, a.config.S3.Bucket, objectKey, throttleUpload(a.ctx, file, a.bandwidth), a.putOptions(objectKey))
 	return err
 }
@@ -7,6 +7,7 @@ import (
 	"io"
 	"log/slog"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -59,6 +60,15 @@ func TestExtractDateFromTableName(t *testing.T) {
 			expectMon:     3,
 			expectYr:      2024,
 		},
+		{
+			name:          "compact monthly partition YYYYMM",
+			baseName:      "flights",
+			partitionName: "flights_202403",
+			expectOk:      true,
+			expectDay:     1,
+			expectMon:     3,
+			expectYr:      2024,
+		},
 		{
 			name:          "invalid table name - too short",
 			baseName:      "flights",
@@ -164,8 +174,11 @@ func TestCompressData(t *testing.T) {
 			archiver.config.Compression = "zstd"
 			archiver.config.CompressionLevel = 3
 
-			cache := &PartitionCache{Entries: make(map[string]PartitionCacheEntry)}
-			compressed, _, err := archiver.compressPartitionData(tt.data, PartitionInfo{TableName: "test"}, nil, cache, func(string) {})
+			zstd, err := compressors.GetCompressor(archiver.config.Compression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			compressed, err := zstd.Compress(tt.data, archiver.compressionLevel())
 
 			if err != nil && tt.expectSuccess {
 				t.Fatalf("unexpected error: %v", err)
@@ -195,43 +208,6 @@ func TestCompressData(t *testing.T) {
 	}
 }
 
-func TestCalculateMultipartETag(t *testing.T) {
-	archiver := NewArchiver(&Config{}, newTestLogger())
-
-	tests := []struct {
-		name   string
-		data   []byte
-		minLen int
-	}{
-		{
-			name:   "empty data",
-			data:   []byte{},
-			minLen: 1,
-		},
-		{
-			name:   "small data",
-			data:   []byte("test data"),
-			minLen: 1,
-		},
-		{
-			name:   "medium data",
-			data:   bytes.Repeat([]byte("test"), 10000),
-			minLen: 1,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			etag := archiver.calculateMultipartETag(tt.data)
-
-			// Just verify it returns a string
-			if len(etag) < tt.minLen {
-				t.Fatalf("ETag length %d is less than expected minimum %d", len(etag), tt.minLen)
-			}
-		})
-	}
-}
-
 func TestNewArchiver(t *testing.T) {
 	config := &Config{
 		Table:   "test_table",
@@ -350,7 +326,7 @@ func TestProcessResultStruct(t *testing.T) {
 		BytesWritten: 1024,
 	}
 
-	if result.Partition != info {
+	if !reflect.DeepEqual(result.Partition, info) {
 		t.Fatal("partition not properly assigned")
 	}
 
@@ -397,16 +373,6 @@ func BenchmarkCompressData(b *testing.B) {
 	}
 }
 
-func BenchmarkCalculateMultipartETag(b *testing.B) {
-	archiver := NewArchiver(&Config{}, newTestLogger())
-	data := bytes.Repeat([]byte("test"), 100000)
-
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		_ = archiver.calculateMultipartETag(data)
-	}
-}
-
 func BenchmarkExtractDateFromTableName(b *testing.B) {
 	archiver := NewArchiver(&Config{}, newTestLogger())
 
@@ -546,6 +512,16 @@ func TestGenerateFilename(t *testing.T) {
 			wantPrefix:  "events-2024-W02",
 			wantSuffix:  ".csv",
 		},
+		{
+			name:        "quarterly jsonl with zstd",
+			table:       "events",
+			date:        time.Date(2024, 8, 20, 0, 0, 0, 0, time.UTC),
+			duration:    "quarterly",
+			format:      ".jsonl",
+			compression: ".zst",
+			wantPrefix:  "events-2024-Q3",
+			wantSuffix:  ".jsonl.zst",
+		},
 	}
 
 	for _, tt := range tests {
@@ -585,6 +561,20 @@ func TestPathTemplate(t *testing.T) {
 			date:     time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
 			want:     "events/202412",
 		},
+		{
+			name:     "week template uses the ISO year",
+			template: "{table}/{YYYY}/W{WW}",
+			table:    "events",
+			date:     time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC),
+			want:     "events/2025/W01",
+		},
+		{
+			name:     "quarter template",
+			template: "{table}/{YYYY}/Q{Q}/{MM}",
+			table:    "events",
+			date:     time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC),
+			want:     "events/2024/Q4/12",
+		},
 		{
 			name:     "default template",
 			template: "",
@@ -631,6 +621,13 @@ func TestSplitPartitionByDuration(t *testing.T) {
 			firstStart:    time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC), // Monday before March 1
 			firstEnd:      time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
 		},
+		{
+			name:          "split monthly to quarterly",
+			duration:      "quarterly",
+			expectedCount: 1,
+			firstStart:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			firstEnd:      time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+		},
 		{
 			name:          "split monthly to monthly",
 			duration:      "monthly",
@@ -660,6 +657,51 @@ func TestSplitPartitionByDuration(t *testing.T) {
 	}
 }
 
+func TestGeneratePartitionName(t *testing.T) {
+	wednesday := time.Date(2024, 12, 31, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		partitionRange string
+		template       string
+		want           string
+	}{
+		{"daily", "", "events_20241231"},
+		{"weekly", "", "events_2025W01"},
+		{"weekly", "{table}_{YYYY}w{WW}", "events_2025w01"},
+		{"weekly", "{table}_{YYYY}{MM}{DD}", "events_20241230"}, // Named after the week's Monday
+		{"monthly", "{table}_{YYYY}_{MM}_{DD}", "events_2024_12_01"},
+		{"quarterly", "", "events_2024Q4"},
+		{"quarterly", "{table}_{YYYY}_{MM}", "events_2024_10"},
+		{"yearly", "{table}_y{YYYY}", "events_y2024"},
+	}
+	for _, tt := range tests {
+		if got := generatePartitionName("events", wednesday, tt.partitionRange, tt.template); got != tt.want {
+			t.Errorf("generatePartitionName(%s, %q) = %s, want %s", tt.partitionRange, tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestExtractDateFromFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     time.Time
+		ok       bool
+	}{
+		{"events-2024-03-15.jsonl.zst", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), true},
+		{"events-2024-03-15-07.csv", time.Date(2024, 3, 15, 7, 0, 0, 0, time.UTC), true},
+		{"events-2024-03.parquet", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), true},
+		{"events-2025-W01.jsonl", time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC), true},
+		{"events-2020-W53-part-0001.jsonl.zst", time.Date(2020, 12, 28, 0, 0, 0, 0, time.UTC), true},
+		{"events-2024-Q3.csv.gz", time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), true},
+		{"events.jsonl", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := extractDateFromFilename(tt.filename)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("extractDateFromFilename(%q) = %v, %v; want %v, %v", tt.filename, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
 func TestIsConnectionError(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,812 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5" //nolint:gosec // MD5 matches S3 ETags, not used for security
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// azureAPIVersion is the Blob service REST API version of every request
+const azureAPIVersion = "2021-12-02"
+
+// azureETagMetadata is the blob metadata name holding the S3-style ETag the
+// archiver gave a blob: the MD5 of a single upload, or the multipart ETag of
+// one committed from parts
+const azureETagMetadata = "s3etag"
+
+// azureCopyPollInterval is how often a pending blob copy is checked
+const azureCopyPollInterval = time.Second
+
+// Static errors for Azure Blob Storage
+var (
+	ErrAzureCredentialsRequired = errors.New("azure blob storage needs the storage account name as --s3-access-key and its key as --s3-secret-key, or AZURE_STORAGE_ACCOUNT and AZURE_STORAGE_KEY")
+	ErrAzureAccountKeyInvalid   = errors.New("azure storage account key must be base64")
+	ErrAzureMetadataName        = errors.New("azure blob metadata keys must start with a letter and may not contain '_'")
+	ErrAzureStorageClass        = errors.New("storage class has no azure access tier; use STANDARD (Hot), STANDARD_IA (Cool), GLACIER_IR (Cold) or GLACIER (Archive)")
+	ErrAzureCopyFailed          = errors.New("azure blob copy failed")
+)
+
+// azureAccessTiers maps S3 storage classes to the blob access tiers they are
+// stored in; listings report tiers by the same names
+var azureAccessTiers = map[string]string{
+	s3.StorageClassStandard:   "Hot",
+	s3.StorageClassStandardIa: "Cool",
+	s3.StorageClassGlacierIr:  "Cold",
+	s3.StorageClassGlacier:    "Archive",
+}
+
+// azureObjectStore stores objects as block blobs in an Azure storage account,
+// with each request's bucket naming the container. Requests are signed with
+// the account's shared key. Multipart uploads stage their parts as
+// uncommitted blocks and commit them as a block list; ETags are kept in blob
+// metadata, so skip logic and download verification work as with S3.
+type azureObjectStore struct {
+	account  string
+	key      []byte
+	endpoint *url.URL
+	client   *http.Client
+}
+
+var _ StorageBackend = (*azureObjectStore)(nil)
+
+// azureCredentials returns the storage account name and key: the S3 keys,
+// or else the environment variables the Azure CLI reads
+func azureCredentials(cfg S3Config) (string, string) {
+	if cfg.AccessKey == "" && cfg.SecretKey == "" {
+		return os.Getenv("AZURE_STORAGE_ACCOUNT"), os.Getenv("AZURE_STORAGE_KEY")
+	}
+	return cfg.AccessKey, cfg.SecretKey
+}
+
+// newAzureObjectStore returns the store for the storage account in cfg. The
+// endpoint defaults to the account's public blob endpoint; set it for the
+// Azurite emulator or another cloud.
+func newAzureObjectStore(cfg S3Config) (*azureObjectStore, error) {
+	account, secret := azureCredentials(cfg)
+	if account == "" || secret == "" {
+		return nil, ErrAzureCredentialsRequired
+	}
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrAzureAccountKeyInvalid, err)
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://" + account + ".blob.core.windows.net"
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid azure endpoint %q: %w", endpoint, err)
+	}
+	return &azureObjectStore{account: account, key: key, endpoint: parsed, client: cfg.HTTP.newHTTPClient()}, nil
+}
+
+// blobURL returns the URL of key in container, or of the container when key
+// is empty
+func (a *azureObjectStore) blobURL(container, key string, query url.Values) *url.URL {
+	u := *a.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + container
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = ""
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+// stringToSign returns what a request's shared key signature covers
+func (a *azureObjectStore) stringToSign(req *http.Request) string {
+	length := ""
+	if req.ContentLength > 0 {
+		length = strconv.FormatInt(req.ContentLength, 10)
+	}
+	var b strings.Builder
+	b.WriteString(req.Method + "\n")
+	for _, name := range []string{"Content-Encoding", "Content-Language"} {
+		b.WriteString(req.Header.Get(name) + "\n")
+	}
+	b.WriteString(length + "\n")
+	for _, name := range []string{"Content-MD5", "Content-Type", "Date", "If-Modified-Since", "If-Match", "If-None-Match", "If-Unmodified-Since", "Range"} {
+		b.WriteString(req.Header.Get(name) + "\n")
+	}
+
+	var names []string
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-ms-") {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.WriteString(name + ":" + strings.TrimSpace(strings.Join(req.Header.Values(name), ",")) + "\n")
+	}
+
+	b.WriteString("/" + a.account + req.URL.EscapedPath())
+	query := req.URL.Query()
+	params := make([]string, 0, len(query))
+	for name := range query {
+		params = append(params, name)
+	}
+	sort.Strings(params)
+	for _, name := range params {
+		values := query[name]
+		sort.Strings(values)
+		b.WriteString("\n" + strings.ToLower(name) + ":" + strings.Join(values, ","))
+	}
+	return b.String()
+}
+
+// sign adds the shared key authorization header to req
+func (a *azureObjectStore) sign(req *http.Request) {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(a.stringToSign(req)))
+	req.Header.Set("Authorization", "SharedKey "+a.account+":"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+// do sends a signed request. Error responses are returned as AWS errors so
+// throttling is retried like S3's; missing blobs are marked ErrObjectNotFound.
+func (a *azureObjectStore) do(ctx context.Context, method string, u *url.URL, header http.Header, body io.Reader, length int64) (*http.Response, error) {
+	if body == nil {
+		body = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = length
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	a.sign(req)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+		return nil, azureResponseError(method, resp)
+	}
+	return resp, nil
+}
+
+// azureResponseError converts an error response to the AWS error S3 would
+// have returned
+func azureResponseError(method string, resp *http.Response) error {
+	code := resp.Header.Get("x-ms-error-code")
+	message := resp.Status
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024)); err == nil && xml.Unmarshal(data, &body) == nil {
+		if body.Code != "" {
+			code = body.Code
+		}
+		if body.Message != "" {
+			message = strings.TrimSpace(body.Message)
+		}
+	}
+	switch {
+	case method == http.MethodHead && resp.StatusCode == http.StatusNotFound:
+		code = "NotFound"
+	case code == "BlobNotFound":
+		code = s3.ErrCodeNoSuchKey
+	case code == "ContainerNotFound":
+		code = s3.ErrCodeNoSuchBucket
+	case code == "":
+		code = http.StatusText(resp.StatusCode)
+	}
+	err := awserr.NewRequestFailure(awserr.New(code, message, nil), resp.StatusCode, resp.Header.Get("x-ms-request-id"))
+	if code == "NotFound" || code == s3.ErrCodeNoSuchKey {
+		return fmt.Errorf("%w: %w", ErrObjectNotFound, err)
+	}
+	return err
+}
+
+// azureMetadataName returns the blob metadata name of an S3 metadata key.
+// Metadata names are C# identifiers, so '-' becomes '_'; keys holding '_'
+// could not be told apart when read back and are rejected.
+func azureMetadataName(key string) (string, error) {
+	if key == "" || key[0] < 'a' || key[0] > 'z' || strings.Contains(key, "_") {
+		return "", fmt.Errorf("%w: '%s'", ErrAzureMetadataName, key)
+	}
+	name := strings.ReplaceAll(key, "-", "_")
+	if name == azureETagMetadata {
+		return "", fmt.Errorf("%w: '%s'", ErrObjectMetadataInUse, key)
+	}
+	return name, nil
+}
+
+// s3MetadataKey reverses azureMetadataName
+func s3MetadataKey(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+}
+
+// validateAzureMetadata checks that every configured metadata key can be
+// stored as a blob metadata name
+func validateAzureMetadata(metadata map[string]string) error {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, err := azureMetadataName(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// azureBlobSettings are the PutOptions a blob is written with. A multipart
+// upload carries them in its upload ID until its block list is committed.
+type azureBlobSettings struct {
+	ContentType     string            `json:"content_type,omitempty"`
+	ContentEncoding string            `json:"content_encoding,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	Tagging         string            `json:"tagging,omitempty"`
+	StorageClass    string            `json:"storage_class,omitempty"`
+}
+
+// header returns the request headers that write the settings and etag
+func (s azureBlobSettings) header(etag string) (http.Header, error) {
+	header := http.Header{}
+	if s.ContentType != "" {
+		header.Set("x-ms-blob-content-type", s.ContentType)
+	}
+	if s.ContentEncoding != "" {
+		header.Set("x-ms-blob-content-encoding", s.ContentEncoding)
+	}
+	if s.Tagging != "" {
+		header.Set("x-ms-tags", s.Tagging)
+	}
+	if s.StorageClass != "" {
+		tier, ok := azureAccessTiers[s.StorageClass]
+		if !ok {
+			return nil, fmt.Errorf("%w: '%s'", ErrAzureStorageClass, s.StorageClass)
+		}
+		header.Set("x-ms-access-tier", tier)
+	}
+	for key, value := range s.Metadata {
+		name, err := azureMetadataName(key)
+		if err != nil {
+			return nil, err
+		}
+		header.Set("x-ms-meta-"+name, value)
+	}
+	header.Set("x-ms-meta-"+azureETagMetadata, strings.Trim(etag, `"`))
+	return header, nil
+}
+
+// azureStorageClass returns the S3 storage class of an access tier
+func azureStorageClass(tier string) string {
+	for class, t := range azureAccessTiers {
+		if strings.EqualFold(t, tier) {
+			return class
+		}
+	}
+	return s3.StorageClassStandard
+}
+
+// azureBlobETag returns a blob's ETag as S3 would report it: the one the
+// archiver recorded, or else the MD5 the service keeps, or else the service's
+// own ETag
+func azureBlobETag(recorded, contentMD5, serviceETag string) string {
+	if recorded != "" {
+		return `"` + recorded + `"`
+	}
+	if sum, err := base64.StdEncoding.DecodeString(contentMD5); err == nil && len(sum) == md5.Size {
+		return `"` + hex.EncodeToString(sum) + `"`
+	}
+	return serviceETag
+}
+
+// azureHeaderMetadata returns the user metadata of a blob response
+func azureHeaderMetadata(header http.Header) map[string]string {
+	metadata := make(map[string]string)
+	for name, values := range header {
+		lower := strings.ToLower(name)
+		key, ok := strings.CutPrefix(lower, "x-ms-meta-")
+		if !ok || key == azureETagMetadata || len(values) == 0 {
+			continue
+		}
+		metadata[s3MetadataKey(key)] = values[0]
+	}
+	return metadata
+}
+
+// azureObjectInfo describes the blob of a response. A ranged read's
+// Content-MD5 is that of the range, so only a whole read's is used.
+func azureObjectInfo(key string, resp *http.Response) ObjectInfo {
+	contentMD5 := resp.Header.Get("x-ms-blob-content-md5")
+	if contentMD5 == "" && resp.StatusCode == http.StatusOK {
+		contentMD5 = resp.Header.Get("Content-MD5")
+	}
+	info := ObjectInfo{
+		Key:             key,
+		Size:            resp.ContentLength,
+		ETag:            azureBlobETag(resp.Header.Get("x-ms-meta-"+azureETagMetadata), contentMD5, resp.Header.Get("ETag")),
+		StorageClass:    azureStorageClass(resp.Header.Get("x-ms-access-tier")),
+		ContentType:     resp.Header.Get("Content-Type"),
+		ContentEncoding: resp.Header.Get("Content-Encoding"),
+		Metadata:        azureHeaderMetadata(resp.Header),
+	}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.LastModified = modified
+	}
+	return info
+}
+
+func (a *azureObjectStore) Head(ctx context.Context, bucket, key string) (ObjectInfo, error) {
+	resp, err := a.do(ctx, http.MethodHead, a.blobURL(bucket, key, nil), nil, nil, 0)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	resp.Body.Close()
+	return azureObjectInfo(key, resp), nil
+}
+
+func (a *azureObjectStore) Get(ctx context.Context, bucket, key string, opts GetOptions) (io.ReadCloser, ObjectInfo, error) {
+	header := http.Header{}
+	if opts.Range != "" {
+		header.Set("x-ms-range", opts.Range)
+	}
+	if opts.IfMatch != "" {
+		// The service's ETag is not the archiver's, so the match is checked here
+		head, err := a.Head(ctx, bucket, key)
+		if err != nil {
+			return nil, ObjectInfo{}, err
+		}
+		if head.ETag != opts.IfMatch {
+			return nil, ObjectInfo{}, fmt.Errorf("%s changed: ETag %s, expected %s", key, head.ETag, opts.IfMatch)
+		}
+	}
+	resp, err := a.do(ctx, http.MethodGet, a.blobURL(bucket, key, nil), header, nil, 0)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	return resp.Body, azureObjectInfo(key, resp), nil
+}
+
+// putBlob writes body as a whole block blob
+func (a *azureObjectStore) putBlob(ctx context.Context, container, key string, body io.Reader, length int64, sum []byte, settings azureBlobSettings) (string, error) {
+	etag := `"` + hex.EncodeToString(sum) + `"`
+	header, err := settings.header(etag)
+	if err != nil {
+		return "", err
+	}
+	header.Set("x-ms-blob-type", "BlockBlob")
+	header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum))
+	resp, err := a.do(ctx, http.MethodPut, a.blobURL(container, key, nil), header, body, length)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return etag, nil
+}
+
+func (a *azureObjectStore) Put(ctx context.Context, bucket, key string, body io.ReadSeeker, opts PutOptions) (string, error) {
+	if body == nil {
+		body = bytes.NewReader(nil)
+	}
+	sum, length, err := readSeekerMD5(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read object body: %w", err)
+	}
+	return a.putBlob(ctx, bucket, key, body, length, sum, azureBlobSettings(opts))
+}
+
+func (a *azureObjectStore) Delete(ctx context.Context, bucket, key string) error {
+	header := http.Header{}
+	header.Set("x-ms-delete-snapshots", "include")
+	resp, err := a.do(ctx, http.MethodDelete, a.blobURL(bucket, key, nil), header, nil, 0)
+	if err != nil {
+		// Like S3, deleting a missing key succeeds
+		if errors.Is(err, ErrObjectNotFound) {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// setAccessTier moves a blob to the access tier of storageClass
+func (a *azureObjectStore) setAccessTier(ctx context.Context, container, key, storageClass string) error {
+	tier, ok := azureAccessTiers[storageClass]
+	if !ok {
+		return fmt.Errorf("%w: '%s'", ErrAzureStorageClass, storageClass)
+	}
+	header := http.Header{}
+	header.Set("x-ms-access-tier", tier)
+	resp, err := a.do(ctx, http.MethodPut, a.blobURL(container, key, url.Values{"comp": {"tier"}}), header, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Copy copies a blob with its metadata. A copy onto itself only changes the
+// access tier.
+func (a *azureObjectStore) Copy(ctx context.Context, bucket, from, to, storageClass string) error {
+	if from == to {
+		if storageClass == "" {
+			return nil
+		}
+		return a.setAccessTier(ctx, bucket, to, storageClass)
+	}
+
+	header := http.Header{}
+	header.Set("x-ms-copy-source", a.blobURL(bucket, from, nil).String())
+	if storageClass != "" {
+		tier, ok := azureAccessTiers[storageClass]
+		if !ok {
+			return fmt.Errorf("%w: '%s'", ErrAzureStorageClass, storageClass)
+		}
+		header.Set("x-ms-access-tier", tier)
+	}
+	resp, err := a.do(ctx, http.MethodPut, a.blobURL(bucket, to, nil), header, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	// Copies within an account usually finish before the response; others are
+	// polled until the service is done
+	status := resp.Header.Get("x-ms-copy-status")
+	for status == "pending" {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(azureCopyPollInterval):
+		}
+		head, err := a.do(ctx, http.MethodHead, a.blobURL(bucket, to, nil), nil, nil, 0)
+		if err != nil {
+			return err
+		}
+		head.Body.Close()
+		status = head.Header.Get("x-ms-copy-status")
+		if status == "failed" || status == "aborted" {
+			return fmt.Errorf("%w: %s: %s", ErrAzureCopyFailed, status, head.Header.Get("x-ms-copy-status-description"))
+		}
+	}
+	return nil
+}
+
+// azureBlobList is a page of the List Blobs response
+type azureBlobList struct {
+	Blobs []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			LastModified  string `xml:"Last-Modified"`
+			ETag          string `xml:"Etag"`
+			ContentLength int64  `xml:"Content-Length"`
+			ContentMD5    string `xml:"Content-MD5"`
+			AccessTier    string `xml:"AccessTier"`
+		} `xml:"Properties"`
+		Metadata struct {
+			Items []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:"Metadata"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// List pages through the container's blobs with their metadata, which holds
+// the ETags the archiver recorded
+func (a *azureObjectStore) List(ctx context.Context, bucket, prefix string, fn func(ObjectInfo) bool) error {
+	query := url.Values{"restype": {"container"}, "comp": {"list"}, "include": {"metadata"}}
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+	for {
+		page, err := a.listPage(ctx, bucket, query)
+		if err != nil {
+			return err
+		}
+		for _, blob := range page.Blobs {
+			var recorded string
+			for _, item := range blob.Metadata.Items {
+				if strings.EqualFold(item.XMLName.Local, azureETagMetadata) {
+					recorded = item.Value
+				}
+			}
+			object := ObjectInfo{
+				Key:          blob.Name,
+				Size:         blob.Properties.ContentLength,
+				ETag:         azureBlobETag(recorded, blob.Properties.ContentMD5, blob.Properties.ETag),
+				StorageClass: azureStorageClass(blob.Properties.AccessTier),
+			}
+			if modified, err := http.ParseTime(blob.Properties.LastModified); err == nil {
+				object.LastModified = modified
+			}
+			if !fn(object) {
+				return nil
+			}
+		}
+		if page.NextMarker == "" {
+			return nil
+		}
+		query.Set("marker", page.NextMarker)
+	}
+}
+
+// listPage fetches one page of a List Blobs request
+func (a *azureObjectStore) listPage(ctx context.Context, bucket string, query url.Values) (*azureBlobList, error) {
+	resp, err := a.do(ctx, http.MethodGet, a.blobURL(bucket, "", query), nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var page azureBlobList
+	if err := xml.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to parse blob listing: %w", err)
+	}
+	return &page, nil
+}
+
+// azureUpload is an open multipart upload. Its ID is a random prefix for the
+// IDs of its blocks followed by the settings the blob is committed with.
+type azureUpload struct {
+	prefix   string
+	settings azureBlobSettings
+}
+
+func newAzureUpload(settings azureBlobSettings) (azureUpload, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return azureUpload{}, err
+	}
+	return azureUpload{prefix: hex.EncodeToString(id), settings: settings}, nil
+}
+
+func (u azureUpload) id() (string, error) {
+	data, err := json.Marshal(u.settings)
+	if err != nil {
+		return "", err
+	}
+	return u.prefix + "." + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// parseAzureUpload reads an upload ID; IDs it cannot read are uploads S3
+// would not know
+func parseAzureUpload(uploadID string) (azureUpload, error) {
+	noSuchUpload := fmt.Errorf("%w: %q", ErrUploadNotFound, uploadID)
+	prefix, encoded, ok := strings.Cut(uploadID, ".")
+	if _, err := hex.DecodeString(prefix); !ok || err != nil || len(prefix) != 16 {
+		return azureUpload{}, noSuchUpload
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return azureUpload{}, noSuchUpload
+	}
+	upload := azureUpload{prefix: prefix}
+	if err := json.Unmarshal(data, &upload.settings); err != nil {
+		return azureUpload{}, noSuchUpload
+	}
+	return upload, nil
+}
+
+// blockID names part number of the upload. Block IDs carry the part's MD5,
+// so the parts of an interrupted upload can be listed with their ETags; all
+// IDs have the same length, as a blob's blocks must.
+func (u azureUpload) blockID(number int64, sum string) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s-%05d-%s", u.prefix, number, sum)))
+}
+
+// parseBlockID returns the part number and MD5 of one of the upload's blocks
+func (u azureUpload) parseBlockID(id string) (int64, string, bool) {
+	data, err := base64.StdEncoding.DecodeString(id)
+	if err != nil {
+		return 0, "", false
+	}
+	rest, ok := strings.CutPrefix(string(data), u.prefix+"-")
+	if !ok {
+		return 0, "", false
+	}
+	number, sum, ok := strings.Cut(rest, "-")
+	n, err := strconv.ParseInt(number, 10, 64)
+	if !ok || err != nil || len(sum) != 2*md5.Size {
+		return 0, "", false
+	}
+	return n, sum, true
+}
+
+// putBlock stages one uncommitted block
+func (a *azureObjectStore) putBlock(ctx context.Context, container, key, blockID string, body io.Reader, length int64, sum []byte) error {
+	header := http.Header{}
+	header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum))
+	query := url.Values{"comp": {"block"}, "blockid": {blockID}}
+	resp, err := a.do(ctx, http.MethodPut, a.blobURL(container, key, query), header, body, length)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// commitBlocks commits the blocks as the blob's content, with the multipart
+// ETag of their MD5s
+func (a *azureObjectStore) commitBlocks(ctx context.Context, container, key string, blockIDs []string, sums []byte, settings azureBlobSettings) (string, error) {
+	total := md5.Sum(sums) //nolint:gosec // MD5 matches S3 ETags
+	etag := fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(total[:]), len(blockIDs))
+
+	var body bytes.Buffer
+	body.WriteString(xml.Header + "<BlockList>")
+	for _, id := range blockIDs {
+		body.WriteString("<Latest>" + id + "</Latest>")
+	}
+	body.WriteString("</BlockList>")
+	header, err := settings.header(etag)
+	if err != nil {
+		return "", err
+	}
+	header.Set("Content-Type", "application/xml")
+	resp, err := a.do(ctx, http.MethodPut, a.blobURL(container, key, url.Values{"comp": {"blocklist"}}), header, &body, int64(body.Len()))
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return etag, nil
+}
+
+func (a *azureObjectStore) CreateMultipartUpload(_ context.Context, _, _ string, opts PutOptions) (string, error) {
+	settings := azureBlobSettings(opts)
+	// Catch settings the blob can't be written with before any part is sent
+	if _, err := settings.header(""); err != nil {
+		return "", err
+	}
+	upload, err := newAzureUpload(settings)
+	if err != nil {
+		return "", err
+	}
+	return upload.id()
+}
+
+func (a *azureObjectStore) UploadPart(ctx context.Context, bucket, key, uploadID string, number int64, body io.ReadSeeker) (string, error) {
+	upload, err := parseAzureUpload(uploadID)
+	if err != nil {
+		return "", err
+	}
+	sum, length, err := readSeekerMD5(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read part body: %w", err)
+	}
+	blockID := upload.blockID(number, hex.EncodeToString(sum))
+	if err := a.putBlock(ctx, bucket, key, blockID, body, length, sum); err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(sum) + `"`, nil
+}
+
+func (a *azureObjectStore) ListParts(ctx context.Context, bucket, key, uploadID string) ([]CompletedPart, error) {
+	upload, err := parseAzureUpload(uploadID)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"comp": {"blocklist"}, "blocklisttype": {"uncommitted"}}
+	resp, err := a.do(ctx, http.MethodGet, a.blobURL(bucket, key, query), nil, nil, 0)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			return nil, fmt.Errorf("%w: %w", ErrUploadNotFound, err)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var list struct {
+		Blocks []struct {
+			Name string `xml:"Name"`
+		} `xml:"UncommittedBlocks>Block"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to parse block list: %w", err)
+	}
+
+	// Uncommitted blocks are discarded when a blob is committed and after a
+	// week, so an upload without any is one S3 would no longer know
+	var parts []CompletedPart
+	for _, block := range list.Blocks {
+		if number, sum, ok := upload.parseBlockID(block.Name); ok {
+			parts = append(parts, CompletedPart{Number: number, ETag: `"` + sum + `"`})
+		}
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrUploadNotFound, uploadID)
+	}
+	return parts, nil
+}
+
+func (a *azureObjectStore) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []CompletedPart) (string, error) {
+	upload, err := parseAzureUpload(uploadID)
+	if err != nil {
+		return "", err
+	}
+	var blockIDs []string
+	var sums []byte
+	for _, part := range parts {
+		sum, err := hex.DecodeString(strings.Trim(part.ETag, `"`))
+		if err != nil || len(sum) != md5.Size {
+			return "", fmt.Errorf("%w: part %d has an invalid ETag", ErrUploadNotFound, part.Number)
+		}
+		blockIDs = append(blockIDs, upload.blockID(part.Number, hex.EncodeToString(sum)))
+		sums = append(sums, sum...)
+	}
+	return a.commitBlocks(ctx, bucket, key, blockIDs, sums, upload.settings)
+}
+
+// AbortMultipartUpload succeeds without a request: blocks can't be deleted,
+// and the service discards uncommitted ones after a week
+func (a *azureObjectStore) AbortMultipartUpload(_ context.Context, _, _, uploadID string) error {
+	_, err := parseAzureUpload(uploadID)
+	return err
+}
+
+// Upload writes a stream of unknown size: a stream shorter than one part is
+// written whole, and longer ones are staged as blocks of the part size and
+// committed together
+func (a *azureObjectStore) Upload(ctx context.Context, bucket, key string, body io.Reader, opts UploadOptions) (string, error) {
+	partSize := opts.PartSize
+	if partSize <= 0 {
+		partSize = s3manager.DefaultUploadPartSize
+	}
+	settings := azureBlobSettings(opts.PutOptions)
+	upload, err := newAzureUpload(settings)
+	if err != nil {
+		return "", err
+	}
+
+	reader := contextReader{ctx: ctx, r: body}
+	buf := make([]byte, partSize)
+	var blockIDs []string
+	var sums []byte
+	for number := int64(1); ; number++ {
+		n, err := io.ReadFull(reader, buf)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return "", err
+		}
+		if number == 1 && n < len(buf) {
+			sum := md5.Sum(buf[:n]) //nolint:gosec // MD5 matches S3 ETags
+			return a.putBlob(ctx, bucket, key, bytes.NewReader(buf[:n]), int64(n), sum[:], settings)
+		}
+		if n == 0 {
+			break
+		}
+		sum := md5.Sum(buf[:n]) //nolint:gosec // MD5 matches S3 ETags
+		blockID := upload.blockID(number, hex.EncodeToString(sum[:]))
+		if err := a.putBlock(ctx, bucket, key, blockID, bytes.NewReader(buf[:n]), int64(n), sum[:]); err != nil {
+			return "", err
+		}
+		blockIDs = append(blockIDs, blockID)
+		sums = append(sums, sum[:]...)
+		if n < len(buf) {
+			break
+		}
+	}
+	return a.commitBlocks(ctx, bucket, key, blockIDs, sums, settings)
+}
@@ -0,0 +1,424 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// fakeAzureBlob is a committed blob of fakeAzure
+type fakeAzureBlob struct {
+	data   []byte
+	header http.Header
+	tier   string
+}
+
+// fakeAzure serves the Blob service requests azureObjectStore makes from
+// memory, rejecting requests whose shared key signature doesn't match
+type fakeAzure struct {
+	store *azureObjectStore
+
+	mu        sync.Mutex
+	blobs     map[string]*fakeAzureBlob
+	blocks    map[string]map[string][]byte
+	sent      []string // Block IDs staged, in order
+	failBlock int      // Part number whose blocks are refused
+	pageSize  int      // Names per List Blobs page; 0 lists them all
+}
+
+func newFakeAzure(t *testing.T) *fakeAzure {
+	t.Helper()
+	fake := &fakeAzure{blobs: make(map[string]*fakeAzureBlob), blocks: make(map[string]map[string][]byte)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	store, err := newAzureObjectStore(S3Config{Endpoint: server.URL + "/account", AccessKey: "account", SecretKey: base64.StdEncoding.EncodeToString([]byte("secret"))})
+	if err != nil {
+		t.Fatalf("newAzureObjectStore() error = %v", err)
+	}
+	fake.store = store
+	return fake
+}
+
+func fakeAzureError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("x-ms-error-code", code)
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"utf-8\"?><Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (f *fakeAzure) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	r.Header.Del("Authorization")
+	f.store.sign(r)
+	if auth == "" || auth != r.Header.Get("Authorization") {
+		fakeAzureError(w, http.StatusForbidden, "AuthenticationFailed")
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := strings.TrimPrefix(r.URL.Path, "/account/")
+	container, _, _ := strings.Cut(name, "/")
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodGet && query.Get("comp") == "list":
+		f.list(w, container, query)
+	case r.Method == http.MethodPut && query.Get("comp") == "block":
+		if sum := md5.Sum(body); r.Header.Get("Content-MD5") != base64.StdEncoding.EncodeToString(sum[:]) {
+			fakeAzureError(w, http.StatusBadRequest, "Md5Mismatch")
+			return
+		}
+		id := query.Get("blockid")
+		decoded, _ := base64.StdEncoding.DecodeString(id)
+		if f.failBlock > 0 && strings.Contains(string(decoded), fmt.Sprintf("-%05d-", f.failBlock)) {
+			fakeAzureError(w, http.StatusInternalServerError, "InternalError")
+			return
+		}
+		if f.blocks[name] == nil {
+			f.blocks[name] = make(map[string][]byte)
+		}
+		f.blocks[name][id] = body
+		f.sent = append(f.sent, string(decoded))
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && query.Get("comp") == "blocklist":
+		var list struct {
+			Latest []string `xml:"Latest"`
+		}
+		if err := xml.Unmarshal(body, &list); err != nil {
+			fakeAzureError(w, http.StatusBadRequest, "InvalidXmlDocument")
+			return
+		}
+		var data []byte
+		for _, id := range list.Latest {
+			block, ok := f.blocks[name][id]
+			if !ok {
+				fakeAzureError(w, http.StatusBadRequest, "InvalidBlockList")
+				return
+			}
+			data = append(data, block...)
+		}
+		delete(f.blocks, name)
+		f.blobs[name] = &fakeAzureBlob{data: data, header: r.Header.Clone(), tier: "Hot"}
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet && query.Get("comp") == "blocklist":
+		if len(f.blocks[name]) == 0 && f.blobs[name] == nil {
+			fakeAzureError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		var ids []string
+		for id := range f.blocks[name] {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		fmt.Fprint(w, "<BlockList><CommittedBlocks /><UncommittedBlocks>")
+		for _, id := range ids {
+			fmt.Fprintf(w, "<Block><Name>%s</Name><Size>%d</Size></Block>", id, len(f.blocks[name][id]))
+		}
+		fmt.Fprint(w, "</UncommittedBlocks></BlockList>")
+	case r.Method == http.MethodPut && query.Get("comp") == "tier":
+		blob := f.blobs[name]
+		if blob == nil {
+			fakeAzureError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		blob.tier = r.Header.Get("x-ms-access-tier")
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut && r.Header.Get("x-ms-copy-source") != "":
+		source, _ := url.Parse(r.Header.Get("x-ms-copy-source"))
+		blob := f.blobs[strings.TrimPrefix(source.Path, "/account/")]
+		if blob == nil {
+			fakeAzureError(w, http.StatusNotFound, "CannotVerifyCopySource")
+			return
+		}
+		copied := *blob
+		f.blobs[name] = &copied
+		w.Header().Set("x-ms-copy-status", "success")
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPut:
+		if r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+			fakeAzureError(w, http.StatusBadRequest, "MissingRequiredHeader")
+			return
+		}
+		tier := r.Header.Get("x-ms-access-tier")
+		if tier == "" {
+			tier = "Hot"
+		}
+		f.blobs[name] = &fakeAzureBlob{data: body, header: r.Header.Clone(), tier: tier}
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodDelete:
+		if f.blobs[name] == nil {
+			fakeAzureError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		delete(f.blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		f.read(w, r, name)
+	default:
+		fakeAzureError(w, http.StatusBadRequest, "UnsupportedHttpVerb")
+	}
+}
+
+// read answers Get Blob and Get Blob Properties
+func (f *fakeAzure) read(w http.ResponseWriter, r *http.Request, name string) {
+	blob := f.blobs[name]
+	if blob == nil {
+		fakeAzureError(w, http.StatusNotFound, "BlobNotFound")
+		return
+	}
+	for header, values := range blob.header {
+		if strings.HasPrefix(strings.ToLower(header), "x-ms-meta-") {
+			w.Header()[header] = values
+		}
+	}
+	sum := md5.Sum(blob.data)
+	w.Header().Set("Content-Type", blob.header.Get("x-ms-blob-content-type"))
+	w.Header().Set("ETag", `"0x8DC0000000000"`)
+	w.Header().Set("Last-Modified", "Mon, 01 Jan 2024 00:00:00 GMT")
+	w.Header().Set("x-ms-access-tier", blob.tier)
+	data := blob.data
+	status := http.StatusOK
+	if spec := r.Header.Get("x-ms-range"); spec != "" {
+		start, end, err := localRange(spec, int64(len(data)))
+		if err != nil {
+			fakeAzureError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		w.Header().Set("x-ms-blob-content-md5", base64.StdEncoding.EncodeToString(sum[:]))
+		data = data[start : end+1]
+		status = http.StatusPartialContent
+	} else {
+		w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	}
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(data)
+	}
+}
+
+// list answers List Blobs, one page of pageSize names at a time
+func (f *fakeAzure) list(w http.ResponseWriter, container string, query url.Values) {
+	var names []string
+	for name := range f.blobs {
+		if key, ok := strings.CutPrefix(name, container+"/"); ok && strings.HasPrefix(key, query.Get("prefix")) && key >= query.Get("marker") {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	next := ""
+	if f.pageSize > 0 && len(names) > f.pageSize {
+		next = names[f.pageSize]
+		names = names[:f.pageSize]
+	}
+	fmt.Fprint(w, "<EnumerationResults><Blobs>")
+	for _, key := range names {
+		blob := f.blobs[container+"/"+key]
+		sum := md5.Sum(blob.data)
+		fmt.Fprintf(w, "<Blob><Name>%s</Name><Properties><Last-Modified>Mon, 01 Jan 2024 00:00:00 GMT</Last-Modified><Etag>0x8DC0000000000</Etag><Content-Length>%d</Content-Length><Content-MD5>%s</Content-MD5><AccessTier>%s</AccessTier></Properties><Metadata>",
+			key, len(blob.data), base64.StdEncoding.EncodeToString(sum[:]), blob.tier)
+		if etag := blob.header.Get("x-ms-meta-" + azureETagMetadata); etag != "" {
+			fmt.Fprintf(w, "<%s>%s</%s>", azureETagMetadata, etag, azureETagMetadata)
+		}
+		fmt.Fprint(w, "</Metadata></Blob>")
+	}
+	fmt.Fprintf(w, "</Blobs><NextMarker>%s</NextMarker></EnumerationResults>", next)
+}
+
+func TestAzureStringToSign(t *testing.T) {
+	store := &azureObjectStore{account: "account"}
+	req, err := http.NewRequest(http.MethodPut, "https://account.blob.core.windows.net/archive/events/a%20b.jsonl?comp=block&blockid=YmxvY2s%3D", strings.NewReader("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-MD5", "eB5eJF1ptWaXm4bijSPyxw==")
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	req.Header.Set("x-ms-date", "Mon, 01 Jan 2024 00:00:00 GMT")
+	want := strings.Join([]string{
+		"PUT", "", "", "10", "eB5eJF1ptWaXm4bijSPyxw==", "", "", "", "", "", "", "",
+		"x-ms-date:Mon, 01 Jan 2024 00:00:00 GMT",
+		"x-ms-version:" + azureAPIVersion,
+		"/account/archive/events/a%20b.jsonl",
+		"blockid:YmxvY2s=",
+		"comp:block",
+	}, "\n")
+	if got := store.stringToSign(req); got != want {
+		t.Errorf("stringToSign() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestAzureMetadataName(t *testing.T) {
+	for _, key := range []string{maskedColumnsMetadataKey, runIDMetadataKey, rowLimitMetadataKey, "team", "cost-center-2"} {
+		name, err := azureMetadataName(key)
+		if err != nil || strings.Contains(name, "-") || s3MetadataKey(name) != key {
+			t.Errorf("azureMetadataName(%q) = %q, %v, restored as %q", key, name, err, s3MetadataKey(name))
+		}
+	}
+	for _, key := range []string{"1st", "cost_center"} {
+		if _, err := azureMetadataName(key); !errors.Is(err, ErrAzureMetadataName) {
+			t.Errorf("azureMetadataName(%q) error = %v, want ErrAzureMetadataName", key, err)
+		}
+	}
+	if _, err := azureMetadataName(azureETagMetadata); !errors.Is(err, ErrObjectMetadataInUse) {
+		t.Errorf("azureMetadataName(%s) error = %v, want ErrObjectMetadataInUse", azureETagMetadata, err)
+	}
+	if err := validateAzureMetadata(map[string]string{"team": "data", "cost_center": "42"}); !errors.Is(err, ErrAzureMetadataName) {
+		t.Errorf("validateAzureMetadata() error = %v, want ErrAzureMetadataName", err)
+	}
+}
+
+func TestAzureObjectStore(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeAzure(t)
+	store := fake.store
+	bucket := "archive"
+	put := func(key, body string) {
+		t.Helper()
+		if _, err := store.Put(ctx, bucket, key, strings.NewReader(body), PutOptions{
+			ContentType: "application/x-ndjson",
+			Metadata:    map[string]string{maskedColumnsMetadataKey: "email"},
+		}); err != nil {
+			t.Fatalf("Put(%s) error = %v", key, err)
+		}
+	}
+	put("events/2024/01/events 2024-01-01.jsonl", "0123456789")
+	put("events/2024/01/events-2024-01-02.jsonl", "second")
+	put("logs/2024/logs-2024.jsonl", "other table")
+
+	head, err := store.Head(ctx, bucket, "events/2024/01/events 2024-01-01.jsonl")
+	if err != nil {
+		t.Fatalf("Head() error = %v", err)
+	}
+	if head.Size != 10 || head.ETag != quotedMD5([]byte("0123456789")) ||
+		maskedColumns(head.Metadata) != "email" || head.ContentType != "application/x-ndjson" {
+		t.Errorf("head = %d bytes, ETag %s, metadata %v", head.Size, head.ETag, head.Metadata)
+	}
+	if _, err := store.Head(ctx, bucket, "missing"); !isObjectNotFound(err) {
+		t.Errorf("missing object error = %v, want ErrObjectNotFound", err)
+	}
+	if _, _, err := store.Get(ctx, bucket, "missing", GetOptions{}); !isObjectNotFound(err) {
+		t.Errorf("missing object error = %v, want ErrObjectNotFound", err)
+	}
+
+	get, info, err := store.Get(ctx, bucket, "events/2024/01/events 2024-01-01.jsonl", GetOptions{Range: "bytes=2-5"})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	body, _ := io.ReadAll(get)
+	get.Close()
+	if string(body) != "2345" || info.ETag != quotedMD5([]byte("0123456789")) {
+		t.Errorf("ranged body = %q, ETag %s", body, info.ETag)
+	}
+
+	fake.pageSize = 1
+	var keys []string
+	if err := store.List(ctx, bucket, "events/", func(object ObjectInfo) bool {
+		keys = append(keys, object.Key)
+		return true
+	}); err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	fake.pageSize = 0
+	if strings.Join(keys, ",") != "events/2024/01/events 2024-01-01.jsonl,events/2024/01/events-2024-01-02.jsonl" {
+		t.Errorf("listed keys = %v", keys)
+	}
+
+	if err := store.Copy(ctx, bucket, "logs/2024/logs-2024.jsonl", "trash/logs-2024.jsonl", ""); err != nil {
+		t.Fatalf("Copy() error = %v", err)
+	}
+	if err := store.Delete(ctx, bucket, "logs/2024/logs-2024.jsonl"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := store.Delete(ctx, bucket, "logs/2024/logs-2024.jsonl"); err != nil {
+		t.Errorf("deleting a missing object error = %v", err)
+	}
+	if blob := fake.blobs[bucket+"/trash/logs-2024.jsonl"]; blob == nil || string(blob.data) != "other table" {
+		t.Errorf("copied object = %v", blob)
+	}
+
+	// A copy onto itself moves the blob to the storage class's access tier
+	key := "trash/logs-2024.jsonl"
+	if err := transitionObject(ctx, store, bucket, RetentionResult{Key: key}, s3.StorageClassGlacier); err != nil {
+		t.Fatalf("transitionObject() error = %v", err)
+	}
+	var listed []ObjectInfo
+	err = store.List(ctx, bucket, "trash/", func(object ObjectInfo) bool {
+		listed = append(listed, object)
+		return true
+	})
+	if err != nil || len(listed) != 1 || listed[0].StorageClass != s3.StorageClassGlacier || fake.blobs[bucket+"/"+key].tier != "Archive" {
+		t.Errorf("transitioned listing = %v, %v", listed, err)
+	}
+	if err := store.Copy(ctx, bucket, key, key, s3.StorageClassDeepArchive); !errors.Is(err, ErrAzureStorageClass) {
+		t.Errorf("DEEP_ARCHIVE error = %v, want ErrAzureStorageClass", err)
+	}
+
+	// Streams shorter than a part are written whole; longer ones as blocks
+	etag, err := store.Upload(ctx, bucket, "stream/short.jsonl", strings.NewReader("streamed"), UploadOptions{})
+	if err != nil || etag != quotedMD5([]byte("streamed")) {
+		t.Errorf("short upload = %s, %v", etag, err)
+	}
+	etag, err = store.Upload(ctx, bucket, "stream/long.jsonl", strings.NewReader("0123456789"), UploadOptions{PartSize: 4})
+	if err != nil || etag != `"`+expectedMultipartETag([]byte("0123456789"), 4)+`"` {
+		t.Errorf("long upload = %s, %v", etag, err)
+	}
+	if blob := fake.blobs[bucket+"/stream/long.jsonl"]; blob == nil || string(blob.data) != "0123456789" {
+		t.Errorf("long upload stored %v", blob)
+	}
+}
+
+func TestAzureResumableUpload(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx := context.Background()
+	fake := newFakeAzure(t)
+	uploader := newResumableUploader(fake.store, "archive", nil, newTestLogger())
+	uploader.partSize = func(int64) int64 { return 4 }
+	key := "events/events-2024-01-01.jsonl.zst"
+	path := filepath.Join(t.TempDir(), "upload.tmp")
+	data := []byte("aaaabbbbcc")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The third part fails; the next run sends only that part again
+	fake.failBlock = 3
+	if err := uploader.Upload(ctx, path, key); err == nil {
+		t.Fatal("expected the upload to fail")
+	}
+	fake.failBlock = 0
+	fake.sent = nil
+	if err := uploader.Upload(ctx, path, key); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if len(fake.sent) != 1 || !strings.Contains(fake.sent[0], "-00003-") {
+		t.Errorf("expected only part 3 sent again, got %v", fake.sent)
+	}
+
+	head, err := fake.store.Head(ctx, "archive", key)
+	if err != nil {
+		t.Fatalf("Head() error = %v", err)
+	}
+	if !bytes.Equal(fake.blobs["archive/"+key].data, data) || head.ETag != `"`+expectedMultipartETag(data, 4)+`"` {
+		t.Errorf("uploaded %q with ETag %s", fake.blobs["archive/"+key].data, head.ETag)
+	}
+	if head.Metadata[runIDMetadataKey] == "" || head.ContentType != "application/octet-stream" {
+		t.Errorf("upload settings not committed: metadata %v, content type %s", head.Metadata, head.ContentType)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -112,7 +113,7 @@ func (s CacheScope) fileIdentifier() string {
 	normalized := s.normalize()
 
 	commandComponent := sanitizeCacheComponent(normalized.Command, "cmd")
-	tableComponent := sanitizeCacheComponent(normalized.Table, "table")
+	tableComponent := tableFileComponent(normalized.Table, "table")
 
 	hashSource := normalized.OutputPath
 	if hashSource == "" {
@@ -126,7 +127,33 @@ func (s CacheScope) fileIdentifier() string {
 
 // PartitionCache stores both row counts and file metadata
 type PartitionCache struct {
-	Entries map[string]PartitionCacheEntry `json:"entries"`
+	Table      string                         `json:"table,omitempty"` // Exact table name; the file name only has a sanitized form
+	Command    string                         `json:"command,omitempty"`
+	OutputPath string                         `json:"output_path,omitempty"` // Scope the file belongs to, so it can be exported
+	Entries    map[string]PartitionCacheEntry `json:"entries"`
+	Watermark  *TableWatermark                `json:"watermark,omitempty"` // --watermark-column value the archives reach
+
+	// loaded caches merge their changed entries into the file on save, so
+	// partitions processed in parallel do not overwrite each other's updates
+	loaded         bool
+	dirty          map[string]bool
+	watermarkDirty bool
+}
+
+// cacheFileMu serializes read-merge-write cycles on cache files
+var cacheFileMu sync.Mutex
+
+// markDirty records that an entry was changed or deleted since the cache was
+// loaded, and stamps a changed entry with the run that wrote it
+func (c *PartitionCache) markDirty(tablePartition string) {
+	if c.dirty == nil {
+		c.dirty = make(map[string]bool)
+	}
+	c.dirty[tablePartition] = true
+	if entry, ok := c.Entries[tablePartition]; ok {
+		entry.RunID = currentRunID
+		c.Entries[tablePartition] = entry
+	}
 }
 
 type PartitionCacheEntry struct {
@@ -139,6 +166,7 @@ type PartitionCacheEntry struct {
 	UncompressedSize int64     `json:"uncompressed_size,omitempty"` // Original size
 	FileMD5          string    `json:"file_md5,omitempty"`
 	MultipartETag    string    `json:"multipart_etag,omitempty"` // S3 multipart ETag for files >100MB
+	PartSize         int64     `json:"part_size,omitempty"`      // Part size MultipartETag was computed with
 	FileTime         time.Time `json:"file_time,omitempty"`
 
 	// S3 information
@@ -146,12 +174,39 @@ type PartitionCacheEntry struct {
 	S3Uploaded   bool      `json:"s3_uploaded,omitempty"`
 	S3UploadTime time.Time `json:"s3_upload_time,omitempty"`
 
+	// Archived content (what the uploaded file holds, used by verify)
+	ArchivedRowCount int64     `json:"archived_row_count,omitempty"`
+	SourceTable      string    `json:"source_table,omitempty"`
+	RangeStart       time.Time `json:"range_start,omitempty"` // Zero for whole-partition files
+	RangeEnd         time.Time `json:"range_end,omitempty"`
+	CompressionLevel int       `json:"compression_level,omitempty"` // Effective level the file was compressed with
+	Format           string    `json:"format,omitempty"`            // Output format the file was written in (empty = inferred from S3Key)
+	Compression      string    `json:"compression,omitempty"`       // Compression the file was written with
+	RowLimit         int64     `json:"row_limit,omitempty"`         // --limit-rows-per-slice the file was sampled with (0 = full archive)
+	KeyColumn        string    `json:"key_column,omitempty"`        // --split-column of a key-range file (empty = not a key range)
+	KeyStart         int64     `json:"key_start,omitempty"`         // First key of a key-range file
+	KeyEnd           int64     `json:"key_end,omitempty"`           // Key after the last of a key-range file
+	WatermarkColumn  string    `json:"watermark_column,omitempty"`  // --watermark-column of an increment file (empty = not an increment)
+	WatermarkAfter   string    `json:"watermark_after,omitempty"`   // Watermark the increment starts after (empty = first increment)
+	WatermarkThrough string    `json:"watermark_through,omitempty"` // Largest watermark value the increment holds
+	SchemaVersion    string    `json:"schema_version,omitempty"`    // Fingerprint of the columns the file was written with
+	Filter           string    `json:"filter,omitempty"`            // --filter condition the file's rows matched
+
+	// Partition date cross-check (--check-partition-dates)
+	DataMinDate    time.Time `json:"data_min_date,omitempty"` // Range of the date column in the partition
+	DataMaxDate    time.Time `json:"data_max_date,omitempty"`
+	OutOfRangeRows int64     `json:"out_of_range_rows,omitempty"` // Rows outside the date in the partition name
+	DateCheckTime  time.Time `json:"date_check_time,omitempty"`
+
 	// Error tracking
 	LastError string    `json:"last_error,omitempty"`
 	ErrorTime time.Time `json:"error_time,omitempty"`
 
 	// Processing time tracking
 	ProcessStartTime time.Time `json:"process_start_time,omitempty"` // When processing started for this job
+
+	// Run that last wrote this entry
+	RunID string `json:"run_id,omitempty"`
 }
 
 // Legacy support - keep old structure for backward compatibility
@@ -237,6 +292,7 @@ func loadPartitionCache(scope CacheScope) (*PartitionCache, error) {
 	if cache.Entries == nil {
 		cache.Entries = make(map[string]PartitionCacheEntry)
 	}
+	cache.loaded = true
 
 	return &cache, nil
 }
@@ -312,7 +368,18 @@ func loadCache(tableName string) (*RowCountCache, error) {
 func (c *PartitionCache) save(scope CacheScope) error {
 	cachePath := getCachePath(scope)
 
-	data, err := json.MarshalIndent(c, "", "  ")
+	cacheFileMu.Lock()
+	defer cacheFileMu.Unlock()
+
+	toWrite := c
+	if c.loaded {
+		toWrite = c.mergeInto(cachePath)
+	}
+	toWrite.Table = scope.Table
+	toWrite.Command = scope.Command
+	toWrite.OutputPath = scope.OutputPath
+
+	data, err := json.MarshalIndent(toWrite, "", "  ")
 	if err != nil {
 		return err
 	}
@@ -320,6 +387,33 @@ func (c *PartitionCache) save(scope CacheScope) error {
 	return os.WriteFile(cachePath, data, 0o600)
 }
 
+// mergeInto re-reads the cache file and applies only the entries this cache
+// changed, keeping updates other writers saved since it was loaded
+func (c *PartitionCache) mergeInto(cachePath string) *PartitionCache {
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		return c
+	}
+	var current PartitionCache
+	if err := json.Unmarshal(data, &current); err != nil || current.Entries == nil {
+		return c
+	}
+
+	for key := range c.dirty {
+		if entry, exists := c.Entries[key]; exists {
+			current.Entries[key] = entry
+		} else {
+			delete(current.Entries, key)
+		}
+	}
+	c.dirty = nil
+	if c.watermarkDirty {
+		current.Watermark = c.Watermark
+		c.watermarkDirty = false
+	}
+	return &current
+}
+
 // Backward compatibility wrapper - kept for potential future use
 /*
 func (c *RowCountCache) save(tableName string) error {
@@ -361,6 +455,7 @@ func (c *PartitionCache) getFileMetadata(tablePartition string, s3Key string, pa
 		entry.FileMD5 = ""
 		entry.FileTime = time.Time{}
 		c.Entries[tablePartition] = entry
+		c.markDirty(tablePartition)
 		return 0, "", false
 	}
 
@@ -372,6 +467,7 @@ func (c *PartitionCache) getFileMetadata(tablePartition string, s3Key string, pa
 		entry.FileTime = time.Time{}
 		entry.S3Key = s3Key
 		c.Entries[tablePartition] = entry
+		c.markDirty(tablePartition)
 		return 0, "", false
 	}
 
@@ -400,8 +496,10 @@ func (c *PartitionCache) getFileMetadataWithETag(tablePartition string, s3Key st
 		entry.FileSize = 0
 		entry.FileMD5 = ""
 		entry.MultipartETag = ""
+		entry.PartSize = 0
 		entry.FileTime = time.Time{}
 		c.Entries[tablePartition] = entry
+		c.markDirty(tablePartition)
 		return 0, "", "", false
 	}
 
@@ -411,9 +509,11 @@ func (c *PartitionCache) getFileMetadataWithETag(tablePartition string, s3Key st
 		entry.FileSize = 0
 		entry.FileMD5 = ""
 		entry.MultipartETag = ""
+		entry.PartSize = 0
 		entry.FileTime = time.Time{}
 		entry.S3Key = s3Key
 		c.Entries[tablePartition] = entry
+		c.markDirty(tablePartition)
 		return 0, "", "", false
 	}
 
@@ -431,6 +531,7 @@ func (c *PartitionCache) setFileMetadataWithETagAndStartTime(tablePartition stri
 	entry.UncompressedSize = uncompressedSize
 	entry.FileMD5 = md5
 	entry.MultipartETag = multipartETag
+	entry.PartSize = 0
 	entry.FileTime = time.Now()
 	entry.S3Key = s3Key
 	entry.S3Uploaded = s3Uploaded
@@ -445,6 +546,56 @@ func (c *PartitionCache) setFileMetadataWithETagAndStartTime(tablePartition stri
 	entry.LastError = ""
 	entry.ErrorTime = time.Time{}
 	c.Entries[tablePartition] = entry
+	c.markDirty(tablePartition)
+}
+
+// setArchivedContent records which table and date range an uploaded file was
+// extracted from, along with the number of rows written, the format, compression,
+// and compression level used, and the row limit of a sample (0 = full archive)
+func (c *PartitionCache) setArchivedContent(tablePartition string, sourceTable string, rangeStart, rangeEnd time.Time, rowCount int64, compressionLevel int, format archiveFormat, rowLimit int64) {
+	entry := c.Entries[tablePartition]
+	entry.SourceTable = sourceTable
+	entry.RangeStart = rangeStart
+	entry.RangeEnd = rangeEnd
+	entry.ArchivedRowCount = rowCount
+	entry.CompressionLevel = compressionLevel
+	entry.Format = format.Format
+	entry.Compression = format.Compression
+	entry.RowLimit = rowLimit
+	c.Entries[tablePartition] = entry
+	c.markDirty(tablePartition)
+}
+
+// setPartSize records the part size the entry's multipart ETag was computed with
+func (c *PartitionCache) setPartSize(tablePartition string, partSize int64) {
+	entry := c.Entries[tablePartition]
+	entry.PartSize = partSize
+	c.Entries[tablePartition] = entry
+	c.markDirty(tablePartition)
+}
+
+// setSchemaVersion records the fingerprint of the columns a file was written with
+func (c *PartitionCache) setSchemaVersion(tablePartition string, version string) {
+	if version == "" {
+		return
+	}
+	entry := c.Entries[tablePartition]
+	entry.SchemaVersion = version
+	c.Entries[tablePartition] = entry
+	c.markDirty(tablePartition)
+}
+
+// setKeyRange records the key range a key-range file holds
+func (c *PartitionCache) setKeyRange(tablePartition string, keys KeyRange) {
+	if keys.Column == "" {
+		return
+	}
+	entry := c.Entries[tablePartition]
+	entry.KeyColumn = keys.Column
+	entry.KeyStart = keys.Start
+	entry.KeyEnd = keys.End
+	c.Entries[tablePartition] = entry
+	c.markDirty(tablePartition)
 }
 
 // Set error in cache
@@ -453,6 +604,7 @@ func (c *PartitionCache) setError(tablePartition string, errMsg string) {
 	entry.LastError = errMsg
 	entry.ErrorTime = time.Now()
 	c.Entries[tablePartition] = entry
+	c.markDirty(tablePartition)
 }
 
 // Get row count from cache
@@ -465,6 +617,7 @@ func (c *PartitionCache) getRowCount(tablePartition string, partitionDate time.T
 	// Check if cache is expired (24 hours)
 	if time.Since(entry.CountTime) > 24*time.Hour {
 		delete(c.Entries, tablePartition)
+		c.markDirty(tablePartition)
 		return 0, false
 	}
 
@@ -472,6 +625,7 @@ func (c *PartitionCache) getRowCount(tablePartition string, partitionDate time.T
 	today := time.Now().Truncate(24 * time.Hour)
 	if partitionDate.Equal(today) || partitionDate.After(today) {
 		delete(c.Entries, tablePartition)
+		c.markDirty(tablePartition)
 		return 0, false
 	}
 
@@ -487,6 +641,7 @@ func (c *PartitionCache) setRowCount(tablePartition string, count int64) {
 	entry.RowCount = count
 	entry.CountTime = time.Now()
 	c.Entries[tablePartition] = entry
+	c.markDirty(tablePartition)
 }
 
 // Backward compatibility wrappers - kept for potential future use
@@ -547,8 +702,10 @@ func (c *PartitionCache) cleanExpired() {
 			// Only delete entry if it has no useful data
 			if entry.RowCount == 0 && entry.FileSize == 0 && entry.LastError == "" {
 				delete(c.Entries, partition)
+				c.markDirty(partition)
 			} else {
 				c.Entries[partition] = entry
+				c.markDirty(partition)
 			}
 		}
 	}
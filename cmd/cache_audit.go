@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ErrCacheAuditInvalid is returned for an unknown --cache-audit mode
+var ErrCacheAuditInvalid = errors.New("cache audit must be one of: off, report, repair")
+
+// Cache audit modes
+const (
+	CacheAuditOff    = "off"    // No audit
+	CacheAuditReport = "report" // Log discrepancies between the cache and the bucket
+	CacheAuditRepair = "repair" // Log them and bring the cache in line with the bucket
+)
+
+// cacheAuditSampleSize is how many discrepancies of each kind are logged
+// outside debug mode
+const cacheAuditSampleSize = 10
+
+// cacheAuditMissingError is recorded on entries whose object was not found
+const cacheAuditMissingError = "object missing from bucket (cache audit)"
+
+var cacheAudit string
+
+func init() {
+	archiveCmd.Flags().StringVar(&cacheAudit, "cache-audit", CacheAuditOff, "after the run, list the table's objects in S3 and compare them with the cache: off, report, repair")
+	_ = viper.BindPFlag("cache_audit", archiveCmd.Flags().Lookup("cache-audit"))
+}
+
+// validateCacheAudit checks a --cache-audit mode ("" = off)
+func validateCacheAudit(mode string) error {
+	switch mode {
+	case "", CacheAuditOff, CacheAuditReport, CacheAuditRepair:
+		return nil
+	default:
+		return fmt.Errorf("%w, got '%s'", ErrCacheAuditInvalid, mode)
+	}
+}
+
+// staleCacheEntry is a cache entry that records an upload whose object is gone
+type staleCacheEntry struct {
+	CacheKey string
+	S3Key    string
+}
+
+// untrackedObject is an archive object the cache has no entry for
+type untrackedObject struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+}
+
+// cacheAuditReport lists the differences between a table's cache and its
+// archive objects under one prefix
+type cacheAuditReport struct {
+	Prefix    string
+	Objects   int // Archive objects listed under Prefix
+	Entries   int // Uploaded cache entries under Prefix
+	Missing   []staleCacheEntry
+	Untracked []untrackedObject
+}
+
+// consistent reports whether the cache and the bucket agree
+func (r *cacheAuditReport) consistent() bool {
+	return len(r.Missing) == 0 && len(r.Untracked) == 0
+}
+
+// auditCacheObjects lists the table's archive objects under the static part of
+// the path template and compares them with the cache entries recording an
+// upload under it. Parts of a split archive are covered by their manifest.
+func auditCacheObjects(ctx context.Context, client StorageBackend, bucket, template, table string, cache *PartitionCache) (*cacheAuditReport, error) {
+	matcher, err := newArchiveObjectMatcher(template, table)
+	if err != nil {
+		return nil, err
+	}
+	report := &cacheAuditReport{Prefix: matcher.prefix}
+
+	tracked := make(map[string]bool)
+	for cacheKey, entry := range cache.Entries {
+		if !entry.S3Uploaded || entry.S3Key == "" || !strings.HasPrefix(entry.S3Key, matcher.prefix) {
+			continue
+		}
+		if _, ok := matcher.periodEnd(entry.S3Key); !ok {
+			continue
+		}
+		tracked[entry.S3Key] = true
+		report.Entries++
+		report.Missing = append(report.Missing, staleCacheEntry{CacheKey: cacheKey, S3Key: entry.S3Key})
+	}
+
+	found := make(map[string]bool)
+	err = client.List(ctx, bucket, matcher.prefix, func(object ObjectInfo) bool {
+		key := object.Key
+		if _, ok := matcher.periodEnd(key); !ok {
+			return true
+		}
+		report.Objects++
+		found[key] = true
+		// A zstd dictionary is covered by the archive stored next to it
+		archive := strings.TrimSuffix(key, zstdDictionarySuffix)
+		if tracked[archive] || tracked[partManifestKey(archive)] {
+			return true
+		}
+		report.Untracked = append(report.Untracked, untrackedObject{
+			Key:          key,
+			Size:         object.Size,
+			ETag:         strings.Trim(object.ETag, "\""),
+			LastModified: object.LastModified,
+		})
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archived objects: %w", err)
+	}
+
+	missing := report.Missing[:0]
+	for _, entry := range report.Missing {
+		if !found[entry.S3Key] {
+			missing = append(missing, entry)
+		}
+	}
+	report.Missing = missing
+	sort.Slice(report.Missing, func(i, j int) bool { return report.Missing[i].S3Key < report.Missing[j].S3Key })
+	sort.Slice(report.Untracked, func(i, j int) bool { return report.Untracked[i].Key < report.Untracked[j].Key })
+	return report, nil
+}
+
+// partManifestKey returns the manifest key of a split archive part
+// (name-part-NNNN.ext), or "" when key is not a part
+func partManifestKey(key string) string {
+	i := strings.LastIndex(key, "-part-")
+	if i < 0 {
+		return ""
+	}
+	digits := key[i+len("-part-"):]
+	if j := strings.Index(digits, "."); j >= 0 {
+		digits = digits[:j]
+	}
+	if !isDigits(digits) {
+		return ""
+	}
+	return key[:i] + manifestSuffix
+}
+
+// repair brings the cache in line with the bucket. Entries whose object is
+// missing lose their upload record and carry an error, so the next run
+// archives them again. Untracked objects are adopted with their size and
+// upload time but no checksum, so the next run still extracts and compares
+// them before skipping.
+func (r *cacheAuditReport) repair(cache *PartitionCache) {
+	for _, stale := range r.Missing {
+		entry := cache.Entries[stale.CacheKey]
+		entry.FileSize = 0
+		entry.FileMD5 = ""
+		entry.MultipartETag = ""
+		entry.S3Uploaded = false
+		entry.S3UploadTime = time.Time{}
+		entry.LastError = cacheAuditMissingError
+		entry.ErrorTime = time.Now()
+		cache.Entries[stale.CacheKey] = entry
+		cache.markDirty(stale.CacheKey)
+	}
+	for _, object := range r.Untracked {
+		entry := cache.Entries[object.Key]
+		entry.FileSize = object.Size
+		entry.FileTime = time.Now()
+		entry.S3Key = object.Key
+		entry.S3Uploaded = true
+		entry.S3UploadTime = object.LastModified
+		cache.Entries[object.Key] = entry
+		cache.markDirty(object.Key)
+	}
+}
+
+// auditCache compares the cache with the bucket at the end of a run and logs
+// the discrepancies, repairing them with --cache-audit repair. Dry runs only
+// report. A failed audit is logged; the run's archives are already in place.
+func (a *Archiver) auditCache() {
+	mode := a.config.CacheAudit
+	if mode == "" || mode == CacheAuditOff || a.s3Client == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	cache, err := loadPartitionCache(a.config.CacheScope)
+	if err != nil {
+		a.logger.Warn(fmt.Sprintf("⚠️  Cache audit skipped: %v", err))
+		return
+	}
+	report, err := auditCacheObjects(ctx, a.s3Client, a.config.S3.Bucket, a.config.S3.PathTemplate, a.config.Table, cache)
+	if err != nil {
+		a.logger.Warn(fmt.Sprintf("⚠️  Cache audit failed: %v", err))
+		return
+	}
+	if report.consistent() {
+		a.logger.Info(fmt.Sprintf("🔎 Cache audit: %d cache entries match %d objects under s3://%s/%s", report.Entries, report.Objects, a.config.S3.Bucket, report.Prefix))
+		return
+	}
+
+	a.logger.Warn(fmt.Sprintf("⚠️  Cache audit: %d cache entries without objects, %d objects without cache entries under s3://%s/%s",
+		len(report.Missing), len(report.Untracked), a.config.S3.Bucket, report.Prefix))
+	for i, stale := range report.Missing {
+		if i < cacheAuditSampleSize || a.config.Debug {
+			a.logger.Warn(fmt.Sprintf("   Missing object: %s (cache entry %s)", stale.S3Key, stale.CacheKey))
+		}
+	}
+	for i, object := range report.Untracked {
+		if i < cacheAuditSampleSize || a.config.Debug {
+			a.logger.Warn(fmt.Sprintf("   Untracked object: %s (%s)", object.Key, formatBytes(object.Size)))
+		}
+	}
+	if hidden := max(len(report.Missing)-cacheAuditSampleSize, 0) + max(len(report.Untracked)-cacheAuditSampleSize, 0); hidden > 0 && !a.config.Debug {
+		a.logger.Warn(fmt.Sprintf("   ... and %d more (use --debug to list all)", hidden))
+	}
+
+	if mode != CacheAuditRepair {
+		return
+	}
+	if a.config.DryRun {
+		a.logger.Info("   Dry run: cache not repaired")
+		return
+	}
+	report.repair(cache)
+	if err := cache.save(a.config.CacheScope); err != nil {
+		a.logger.Warn(fmt.Sprintf("⚠️  Cache audit repair not saved: %v", err))
+		return
+	}
+	a.logger.Info(fmt.Sprintf("🔧 Cache repaired: %d entries marked for re-archiving, %d objects adopted", len(report.Missing), len(report.Untracked)))
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestValidateCacheAudit(t *testing.T) {
+	for _, mode := range []string{"", CacheAuditOff, CacheAuditReport, CacheAuditRepair} {
+		if err := validateCacheAudit(mode); err != nil {
+			t.Errorf("validateCacheAudit(%q) = %v", mode, err)
+		}
+	}
+	if err := validateCacheAudit("fix"); !errors.Is(err, ErrCacheAuditInvalid) {
+		t.Errorf("expected ErrCacheAuditInvalid, got %v", err)
+	}
+}
+
+func TestPartManifestKey(t *testing.T) {
+	tests := map[string]string{
+		"events/2024/events-2024-01-05-part-0001.jsonl.zst": "events/2024/events-2024-01-05" + manifestSuffix,
+		"events/2024/events-2024-01-05.jsonl.zst":           "",
+		"events/2024/events-part-x.jsonl":                   "",
+	}
+	for key, want := range tests {
+		if got := partManifestKey(key); got != want {
+			t.Errorf("partManifestKey(%s) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func newAuditFixture() (*fakeObjectStore, *PartitionCache) {
+	store := &fakeObjectStore{objects: map[string][]byte{
+		"archives/events/2024/01/events-2024-01-01.jsonl.zst":        []byte("day one"),
+		"archives/events/2024/01/events-2024-01-03.jsonl.zst":        []byte("untracked"),
+		"archives/events/2024/01/events-2024-01-04.manifest.json":    []byte("{}"),
+		"archives/events/2024/01/events-2024-01-04-part-0001.jsonl":  []byte("part"),
+		"archives/events/_delta_log/00000000000000000000.json":       []byte("{}"),
+		"archives/eventsarchive/2024/01/events-2024-01-09.jsonl.zst": []byte("other table"),
+	}}
+	cache := &PartitionCache{Entries: map[string]PartitionCacheEntry{
+		"events_20240101": {S3Key: "archives/events/2024/01/events-2024-01-01.jsonl.zst", S3Uploaded: true, FileSize: 7, FileMD5: "abc"},
+		"archives/events/2024/01/events-2024-01-02.jsonl.zst": {
+			S3Key: "archives/events/2024/01/events-2024-01-02.jsonl.zst", S3Uploaded: true, FileSize: 5, FileMD5: "def", RowCount: 12,
+		},
+		"archives/events/2024/01/events-2024-01-04.manifest.json": {S3Key: "archives/events/2024/01/events-2024-01-04.manifest.json", S3Uploaded: true},
+		// Slices checked and found empty were never uploaded
+		"archives/events/2024/01/events-2024-01-06.jsonl.zst": {S3Key: "archives/events/2024/01/events-2024-01-06.jsonl.zst"},
+	}}
+	return store, cache
+}
+
+func TestAuditCacheObjects(t *testing.T) {
+	store, cache := newAuditFixture()
+	report, err := auditCacheObjects(context.Background(), newS3ObjectStore(store), "bucket", "archives/{table}/{YYYY}/{MM}", "events", cache)
+	if err != nil {
+		t.Fatalf("auditCacheObjects() error = %v", err)
+	}
+	if report.Prefix != "archives/events/" || report.Objects != 4 || report.Entries != 3 {
+		t.Errorf("report = %+v", report)
+	}
+	if len(report.Missing) != 1 || report.Missing[0].S3Key != "archives/events/2024/01/events-2024-01-02.jsonl.zst" {
+		t.Errorf("missing = %+v", report.Missing)
+	}
+	if len(report.Untracked) != 1 || report.Untracked[0].Key != "archives/events/2024/01/events-2024-01-03.jsonl.zst" || report.Untracked[0].Size != 9 {
+		t.Errorf("untracked = %+v", report.Untracked)
+	}
+	if report.consistent() {
+		t.Error("report should not be consistent")
+	}
+
+	if _, err := auditCacheObjects(context.Background(), newS3ObjectStore(store), "bucket", "archives/{YYYY}", "events", cache); !errors.Is(err, ErrRetentionTemplateInvalid) {
+		t.Errorf("expected ErrRetentionTemplateInvalid, got %v", err)
+	}
+}
+
+func TestCacheAuditRepair(t *testing.T) {
+	store, cache := newAuditFixture()
+	report, err := auditCacheObjects(context.Background(), newS3ObjectStore(store), "bucket", "archives/{table}/{YYYY}/{MM}", "events", cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report.repair(cache)
+
+	stale := cache.Entries["archives/events/2024/01/events-2024-01-02.jsonl.zst"]
+	if stale.S3Uploaded || stale.FileSize != 0 || stale.FileMD5 != "" || stale.LastError != cacheAuditMissingError || stale.RowCount != 12 {
+		t.Errorf("stale entry = %+v", stale)
+	}
+	adopted := cache.Entries["archives/events/2024/01/events-2024-01-03.jsonl.zst"]
+	if !adopted.S3Uploaded || adopted.FileSize != 9 || adopted.FileMD5 != "" {
+		t.Errorf("adopted entry = %+v", adopted)
+	}
+	if !cache.dirty["archives/events/2024/01/events-2024-01-02.jsonl.zst"] || !cache.dirty["archives/events/2024/01/events-2024-01-03.jsonl.zst"] {
+		t.Error("repaired entries should be saved")
+	}
+
+	report, err = auditCacheObjects(context.Background(), newS3ObjectStore(store), "bucket", "archives/{table}/{YYYY}/{MM}", "events", cache)
+	if err != nil || !report.consistent() {
+		t.Errorf("repaired cache should be consistent, got %+v, %v", report, err)
+	}
+}
+
+func TestAuditCacheSaves(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store, cache := newAuditFixture()
+	config := &Config{
+		Table:      "events",
+		CacheAudit: CacheAuditRepair,
+		S3:         S3Config{Bucket: "bucket", PathTemplate: "archives/{table}/{YYYY}/{MM}"},
+	}
+	config.CacheScope = NewCacheScope("archive", config)
+	if err := cache.save(config.CacheScope); err != nil {
+		t.Fatal(err)
+	}
+
+	archiver := NewArchiver(config, newTestLogger())
+	archiver.s3Client = newS3ObjectStore(store)
+	archiver.auditCache()
+
+	saved, err := loadPartitionCache(config.CacheScope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := saved.Entries["archives/events/2024/01/events-2024-01-02.jsonl.zst"]; entry.S3Uploaded {
+		t.Errorf("stale entry not repaired: %+v", entry)
+	}
+	if entry, ok := saved.Entries["archives/events/2024/01/events-2024-01-03.jsonl.zst"]; !ok || !entry.S3Uploaded {
+		t.Errorf("untracked object not adopted: %+v", entry)
+	}
+
+	// Dry runs only report
+	config.DryRun = true
+	delete(store.objects, "archives/events/2024/01/events-2024-01-01.jsonl.zst")
+	archiver.auditCache()
+	saved, _ = loadPartitionCache(config.CacheScope)
+	if entry := saved.Entries["events_20240101"]; !entry.S3Uploaded {
+		t.Errorf("dry run repaired the cache: %+v", entry)
+	}
+}
@@ -225,6 +225,8 @@ func runCacheServer(_ *cobra.Command, _ []string) error {
 	http.HandleFunc("/", serveCacheViewer)
 	http.HandleFunc("/api/cache", serveCacheData)
 	http.HandleFunc("/api/status", serveStatusData)
+	http.HandleFunc("/api/fleet", serveFleetData)
+	http.HandleFunc("/metrics", serveFleetMetrics)
 	http.HandleFunc("/ws", handleWebSocket)
 	http.HandleFunc("/ws/logs", handleLogsWebSocket)
 
@@ -424,6 +426,29 @@ func serveStatusData(w http.ResponseWriter, _ *http.Request) {
 	_ = json.NewEncoder(w).Encode(response)
 }
 
+func serveFleetData(w http.ResponseWriter, _ *http.Request) {
+	// Enable CORS for local development
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	report, err := buildLocalFleetReport(defaultFleetStaleAfter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+func serveFleetMetrics(w http.ResponseWriter, _ *http.Request) {
+	report, err := buildLocalFleetReport(defaultFleetStaleAfter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeFleetMetrics(w, report)
+}
+
 func getCacheViewerHTML() string {
 	return cacheViewerHTML
 }
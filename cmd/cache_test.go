@@ -405,3 +405,34 @@ func TestCachePathGeneration(t *testing.T) {
 		t.Fatal("cache directory should be created")
 	}
 }
+
+func TestPartitionCache_SaveMergesConcurrentWriters(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	scope := buildTestScope("merge_table")
+
+	seed := &PartitionCache{Entries: make(map[string]PartitionCacheEntry)}
+	seed.setRowCount("merge_table_20240101", 10)
+	seed.setRowCount("merge_table_20240102", 20)
+	if err := seed.save(scope); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two partitions load the cache, then each records its own result
+	first, _ := loadPartitionCache(scope)
+	second, _ := loadPartitionCache(scope)
+	first.setFileMetadata("merge_table_20240101", "a.jsonl.zst", 100, 1000, "md5a", true)
+	second.setFileMetadata("merge_table_20240102", "b.jsonl.zst", 200, 2000, "md5b", true)
+	if err := first.save(scope); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.save(scope); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, _ := loadPartitionCache(scope)
+	for key, md5 := range map[string]string{"merge_table_20240101": "md5a", "merge_table_20240102": "md5b"} {
+		if got := merged.Entries[key].FileMD5; got != md5 {
+			t.Errorf("expected %s to keep md5 %s, got %q", key, md5, got)
+		}
+	}
+}
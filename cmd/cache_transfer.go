@@ -0,0 +1,521 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// cacheBundleVersion is the format version written by cache export
+const cacheBundleVersion = 1
+
+// Static errors for cache export and import
+var (
+	ErrCacheBundleVersion  = errors.New("unsupported cache bundle version")
+	ErrCacheRemapInvalid   = errors.New("--remap-output must be OLD=NEW")
+	ErrCacheBundleFileName = errors.New("cache bundle holds an invalid cache file name")
+)
+
+var (
+	cacheExportOutput  string
+	cacheExportTable   string
+	cacheImportRemaps  []string
+	cacheImportDryRun  bool
+	cacheImportNoCheck bool
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Export and import the local partition cache",
+	Long: `Move the local partition cache between machines, e.g. when replacing the archive host, so
+the new host neither redoes work the old one finished nor skips work it did not.`,
+}
+
+var cacheExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write the local partition cache to a bundle file",
+	Args:  cobra.NoArgs,
+	Run: func(_ *cobra.Command, _ []string) {
+		runCacheExport()
+	},
+}
+
+var cacheImportCmd = &cobra.Command{
+	Use:   "import <bundle>",
+	Short: "Merge a cache bundle into the local partition cache",
+	Long: `Merge a bundle written by 'cache export' into the local partition cache. Every uploaded file
+in the bundle is checked against the destination bucket's listing first: entries whose object is
+missing or differs in size or ETag are reset so the file is archived again, instead of being
+skipped on the strength of the old host's cache. Local entries newer than the imported ones are kept.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runCacheImport(cmd, args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheExportCmd)
+	cacheCmd.AddCommand(cacheImportCmd)
+
+	cacheExportCmd.Flags().StringVarP(&cacheExportOutput, "output", "o", "-", "bundle file to write (- for stdout)")
+	cacheExportCmd.Flags().StringVar(&cacheExportTable, "table", "", "only export caches of this table")
+
+	// S3 flags for validating imported entries against the destination bucket
+	cacheImportCmd.Flags().StringVar(&s3Endpoint, "s3-endpoint", "", "S3-compatible endpoint URL")
+	cacheImportCmd.Flags().StringVar(&s3Bucket, "s3-bucket", "", "S3 bucket name (used when a cache's output path names no bucket)")
+	cacheImportCmd.Flags().StringVar(&s3AccessKey, "s3-access-key", "", "S3 access key")
+	cacheImportCmd.Flags().StringVar(&s3SecretKey, "s3-secret-key", "", "S3 secret key")
+	cacheImportCmd.Flags().StringVar(&s3Region, "s3-region", "auto", "S3 region")
+
+	cacheImportCmd.Flags().StringArrayVar(&cacheImportRemaps, "remap-output", nil, "rewrite a cache output path prefix, and the S3 keys under it, e.g. s3://old-bucket/archive=s3://new-bucket/archive (repeatable)")
+	cacheImportCmd.Flags().BoolVar(&cacheImportDryRun, "dry-run", false, "report what would be imported without writing the cache")
+	cacheImportCmd.Flags().BoolVar(&cacheImportNoCheck, "skip-validation", false, "import entries without checking them against the bucket")
+}
+
+// CacheBundle is the portable form of the local partition cache
+type CacheBundle struct {
+	Version    int                `json:"version"`
+	ExportedAt time.Time          `json:"exported_at"`
+	Hostname   string             `json:"hostname,omitempty"`
+	Caches     []CacheBundleScope `json:"caches"`
+}
+
+// CacheBundleScope is one cache file. Files saved before caches recorded
+// their scope have no Command or OutputPath and are imported under File.
+type CacheBundleScope struct {
+	File       string                         `json:"file"`
+	Command    string                         `json:"command,omitempty"`
+	Table      string                         `json:"table,omitempty"`
+	OutputPath string                         `json:"output_path,omitempty"`
+	Entries    map[string]PartitionCacheEntry `json:"entries"`
+}
+
+// scope returns the cache scope of the file, and false if it is unknown
+func (c CacheBundleScope) scope() (CacheScope, bool) {
+	if c.Command == "" || c.OutputPath == "" {
+		return CacheScope{}, false
+	}
+	return CacheScope{Command: c.Command, Table: c.Table, OutputPath: c.OutputPath}, true
+}
+
+// cacheImportReport counts what happened to one imported cache file
+type cacheImportReport struct {
+	Entries   int // Entries in the bundle
+	Imported  int // Written to the local cache
+	KeptLocal int // Local entry was newer and kept
+	Verified  int // Uploaded files found in the bucket as cached
+	Missing   int // Uploaded files not in the bucket, reset to be archived again
+	Changed   int // Uploaded files whose size or ETag differ, reset to be archived again
+}
+
+// getCacheDir returns the directory holding cache files
+func getCacheDir() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".data-archiver", "cache")
+}
+
+// exportCacheBundle reads every cache file in dir, optionally only those of table
+func exportCacheBundle(dir, table string) (*CacheBundle, error) {
+	bundle := &CacheBundle{Version: cacheBundleVersion, ExportedAt: time.Now().UTC(), Caches: []CacheBundleScope{}}
+	bundle.Hostname, _ = os.Hostname()
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return bundle, nil
+		}
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), "_metadata.json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cache file %s: %w", file.Name(), err)
+		}
+		var cache PartitionCache
+		if err := json.Unmarshal(data, &cache); err != nil || cache.Entries == nil {
+			continue // Not a partition cache
+		}
+		if table != "" && cache.Table != table {
+			continue
+		}
+		bundle.Caches = append(bundle.Caches, CacheBundleScope{
+			File:       file.Name(),
+			Command:    cache.Command,
+			Table:      cache.Table,
+			OutputPath: cache.OutputPath,
+			Entries:    cache.Entries,
+		})
+	}
+	return bundle, nil
+}
+
+// cacheRemap rewrites output paths starting with From to start with To
+type cacheRemap struct {
+	From string
+	To   string
+}
+
+// parseCacheRemaps parses --remap-output values
+func parseCacheRemaps(values []string) ([]cacheRemap, error) {
+	remaps := make([]cacheRemap, 0, len(values))
+	for _, value := range values {
+		from, to, ok := strings.Cut(value, "=")
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("%w, got '%s'", ErrCacheRemapInvalid, value)
+		}
+		remaps = append(remaps, cacheRemap{From: from, To: to})
+	}
+	return remaps, nil
+}
+
+// remap applies the first remap whose prefix matches the output path. When
+// both paths are s3:// paths with different key prefixes, cached S3 keys (and
+// slice entries, which are named by their key) move to the new prefix too.
+func (c *CacheBundleScope) remap(remaps []cacheRemap) {
+	for _, remap := range remaps {
+		if c.OutputPath != remap.From && !strings.HasPrefix(c.OutputPath, strings.TrimSuffix(remap.From, "/")+"/") {
+			continue
+		}
+		c.OutputPath = remap.To + strings.TrimPrefix(c.OutputPath, remap.From)
+
+		fromKey, toKey := outputPathKeyPrefix(remap.From), outputPathKeyPrefix(remap.To)
+		if outputPathBucket(remap.From) == "" || outputPathBucket(remap.To) == "" || fromKey == toKey {
+			return
+		}
+		entries := make(map[string]PartitionCacheEntry, len(c.Entries))
+		for name, entry := range c.Entries {
+			if rest, ok := strings.CutPrefix(entry.S3Key, fromKey); ok {
+				if name == entry.S3Key {
+					name = toKey + rest
+				}
+				entry.S3Key = toKey + rest
+			}
+			entries[name] = entry
+		}
+		c.Entries = entries
+		return
+	}
+}
+
+// outputPathBucket returns the bucket of an s3:// output path, or ""
+func outputPathBucket(outputPath string) string {
+	rest, ok := strings.CutPrefix(outputPath, "s3://")
+	if !ok {
+		return ""
+	}
+	bucket, _, _ := strings.Cut(rest, "/")
+	return bucket
+}
+
+// outputPathKeyPrefix returns the key prefix of an s3:// output path, ending
+// in "/" unless empty
+func outputPathKeyPrefix(outputPath string) string {
+	rest, _ := strings.CutPrefix(outputPath, "s3://")
+	_, key, _ := strings.Cut(rest, "/")
+	key = strings.Trim(key, "/")
+	if key == "" {
+		return ""
+	}
+	return key + "/"
+}
+
+// validateCacheEntries checks each uploaded file in entries against the
+// bucket listing and resets those that are missing or differ, using the same
+// size and ETag comparison as the archiver's cached skip
+func validateCacheEntries(ctx context.Context, client StorageBackend, bucket string, entries map[string]PartitionCacheEntry, report *cacheImportReport) error {
+	var keys []string
+	for _, entry := range entries {
+		if entry.S3Uploaded && entry.S3Key != "" {
+			keys = append(keys, entry.S3Key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	// List once under the longest directory prefix shared by all keys
+	sort.Strings(keys)
+	prefix := keys[0]
+	for _, key := range keys[1:] {
+		for !strings.HasPrefix(key, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	prefix = prefix[:strings.LastIndex(prefix, "/")+1]
+
+	objects := make(map[string]ObjectInfo)
+	err := client.List(ctx, bucket, prefix, func(object ObjectInfo) bool {
+		objects[object.Key] = object
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list s3://%s/%s: %w", bucket, prefix, err)
+	}
+
+	for name, entry := range entries {
+		if !entry.S3Uploaded || entry.S3Key == "" {
+			continue
+		}
+		object, exists := objects[entry.S3Key]
+		switch {
+		case !exists:
+			report.Missing++
+		case cachedObjectMatches(entry, object.Size, object.ETag):
+			report.Verified++
+			continue
+		default:
+			report.Changed++
+		}
+		entry.S3Uploaded = false
+		entry.S3UploadTime = time.Time{}
+		entry.FileSize = 0
+		entry.FileMD5 = ""
+		entry.MultipartETag = ""
+		entry.FileTime = time.Time{}
+		entries[name] = entry
+	}
+	return nil
+}
+
+// cachedObjectMatches reports whether an object matches the cached file, so
+// the archiver would skip it
+func cachedObjectMatches(entry PartitionCacheEntry, size int64, etag string) bool {
+	if size != entry.FileSize {
+		return false
+	}
+	etag = strings.Trim(etag, "\"")
+	if strings.Contains(etag, "-") {
+		return entry.MultipartETag != "" && etag == entry.MultipartETag
+	}
+	return etag == entry.FileMD5
+}
+
+// latestCacheActivity returns when an entry was last updated
+func latestCacheActivity(entry PartitionCacheEntry) time.Time {
+	latest := entry.CountTime
+	for _, t := range []time.Time{entry.FileTime, entry.S3UploadTime, entry.ErrorTime, entry.DateCheckTime, entry.ProcessStartTime} {
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// importCacheScope merges an imported cache file into the local one, keeping
+// local entries that are newer. With dryRun nothing is written.
+func importCacheScope(dir string, imported CacheBundleScope, dryRun bool, report *cacheImportReport) error {
+	var cachePath string
+	if scope, ok := imported.scope(); ok {
+		cachePath = filepath.Join(dir, fmt.Sprintf("%s_metadata.json", scope.fileIdentifier()))
+	} else {
+		name := filepath.Base(imported.File)
+		if name != imported.File || !strings.HasSuffix(name, "_metadata.json") {
+			return fmt.Errorf("%w: '%s'", ErrCacheBundleFileName, imported.File)
+		}
+		cachePath = filepath.Join(dir, name)
+	}
+
+	cacheFileMu.Lock()
+	defer cacheFileMu.Unlock()
+
+	merged := PartitionCache{Entries: make(map[string]PartitionCacheEntry)}
+	if data, err := os.ReadFile(cachePath); err == nil {
+		if err := json.Unmarshal(data, &merged); err != nil {
+			return fmt.Errorf("failed to parse local cache %s: %w", cachePath, err)
+		}
+		if merged.Entries == nil {
+			merged.Entries = make(map[string]PartitionCacheEntry)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read local cache %s: %w", cachePath, err)
+	}
+
+	for name, entry := range imported.Entries {
+		if local, exists := merged.Entries[name]; exists && latestCacheActivity(local).After(latestCacheActivity(entry)) {
+			report.KeptLocal++
+			continue
+		}
+		merged.Entries[name] = entry
+		report.Imported++
+	}
+	if dryRun {
+		return nil
+	}
+
+	merged.Table = imported.Table
+	merged.Command = imported.Command
+	merged.OutputPath = imported.OutputPath
+	data, err := json.MarshalIndent(&merged, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return os.WriteFile(cachePath, data, 0o600)
+}
+
+func runCacheExport() {
+	initLogger(viper.GetBool("debug"), viper.GetString("log_format"))
+
+	bundle, err := exportCacheBundle(getCacheDir(), cacheExportTable)
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ %v", err))
+		os.Exit(1)
+	}
+
+	var w io.Writer = os.Stdout
+	if cacheExportOutput != "-" {
+		file, err := os.OpenFile(cacheExportOutput, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			logger.Error(fmt.Sprintf("❌ Failed to create bundle: %v", err))
+			os.Exit(1)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(bundle); err != nil {
+		logger.Error(fmt.Sprintf("❌ Failed to write bundle: %v", err))
+		os.Exit(1)
+	}
+
+	entries := 0
+	for _, cache := range bundle.Caches {
+		entries += len(cache.Entries)
+	}
+	logger.Info(fmt.Sprintf("✅ Exported %d cache files (%d entries)", len(bundle.Caches), entries))
+}
+
+func runCacheImport(cmd *cobra.Command, bundlePath string) {
+	getStringConfig := func(flagValue string, flagName string, viperKey string) string {
+		if flag := cmd.Flags().Lookup(flagName); flag != nil && flag.Changed {
+			return flagValue
+		}
+		if viperValue := viper.GetString(viperKey); viperValue != "" {
+			return viperValue
+		}
+		return flagValue
+	}
+
+	s3Config, s3Err := newS3Config(S3Config{
+		Endpoint:  getStringConfig(s3Endpoint, "s3-endpoint", "s3.endpoint"),
+		Bucket:    getStringConfig(s3Bucket, "s3-bucket", "s3.bucket"),
+		AccessKey: getStringConfig(s3AccessKey, "s3-access-key", "s3.access_key"),
+		SecretKey: getStringConfig(s3SecretKey, "s3-secret-key", "s3.secret_key"),
+		Region:    getStringConfig(s3Region, "s3-region", "s3.region"),
+	}, endpointProfileName("cache"))
+
+	initLogger(viper.GetBool("debug"), viper.GetString("log_format"))
+
+	if s3Err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", s3Err.Error()))
+		os.Exit(1)
+	}
+
+	remaps, err := parseCacheRemaps(cacheImportRemaps)
+	if err == nil && !cacheImportNoCheck {
+		err = validateCacheImportConfig(s3Config)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	data, err := os.ReadFile(bundlePath)
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ Failed to read bundle: %v", err))
+		os.Exit(1)
+	}
+	var bundle CacheBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		logger.Error(fmt.Sprintf("❌ Failed to parse bundle: %v", err))
+		os.Exit(1)
+	}
+	if bundle.Version != cacheBundleVersion {
+		logger.Error(fmt.Sprintf("❌ %v, got %d", ErrCacheBundleVersion, bundle.Version))
+		os.Exit(1)
+	}
+
+	ctx := signalContext
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var client StorageBackend
+	if !cacheImportNoCheck {
+		var err error
+		if client, err = newStorageClient(s3Config); err != nil {
+			logger.Error(fmt.Sprintf("❌ Failed to create storage client: %v", err))
+			os.Exit(1)
+		}
+	}
+
+	dir := getCacheDir()
+	failed := false
+	for _, cache := range bundle.Caches {
+		if cache.Entries == nil {
+			continue
+		}
+		cache.remap(remaps)
+		label := cache.File
+		if _, ok := cache.scope(); ok {
+			label = fmt.Sprintf("%s %s (%s)", cache.Command, cache.Table, cache.OutputPath)
+		}
+
+		report := cacheImportReport{Entries: len(cache.Entries)}
+		if client != nil {
+			bucket := outputPathBucket(cache.OutputPath)
+			if bucket == "" {
+				bucket = s3Config.Bucket
+			}
+			if err := validateCacheEntries(ctx, client, bucket, cache.Entries, &report); err != nil {
+				logger.Error(fmt.Sprintf("❌ %s: %v", label, err))
+				failed = true
+				continue
+			}
+		}
+		if err := importCacheScope(dir, cache, cacheImportDryRun, &report); err != nil {
+			logger.Error(fmt.Sprintf("❌ %s: %v", label, err))
+			failed = true
+			continue
+		}
+
+		logger.Info(fmt.Sprintf("✅ %s: %d imported, %d newer local entries kept", label, report.Imported, report.KeptLocal))
+		if client != nil {
+			logger.Info(fmt.Sprintf("   %d uploads verified, %d missing and %d changed in the bucket (reset to be archived again)",
+				report.Verified, report.Missing, report.Changed))
+		}
+	}
+
+	if cacheImportDryRun {
+		logger.Info("ℹ️  Dry run: local cache not modified")
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// validateCacheImportConfig checks the S3 settings used to validate imported entries
+func validateCacheImportConfig(s3Config S3Config) error {
+	if err := s3Config.validateCredentials(); err != nil {
+		return err
+	}
+	return s3Config.HTTP.Validate()
+}
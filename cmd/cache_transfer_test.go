@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheExportImportRoundTrip(t *testing.T) {
+	sourceDir := t.TempDir()
+	destDir := t.TempDir()
+	uploaded := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	scope := CacheScope{Command: "archive", Table: "flights", OutputPath: "s3://old-bucket/archive/{table}"}
+	source := PartitionCache{
+		Table:      scope.Table,
+		Command:    scope.Command,
+		OutputPath: scope.OutputPath,
+		Entries: map[string]PartitionCacheEntry{
+			"flights_20240101": {RowCount: 10, FileSize: 4, FileMD5: "abc", S3Key: "archive/flights/2024-01-01.jsonl.zst", S3Uploaded: true, S3UploadTime: uploaded},
+		},
+	}
+	writeTestCacheFile(t, filepath.Join(sourceDir, scope.fileIdentifier()+"_metadata.json"), source)
+	writeTestCacheFile(t, filepath.Join(sourceDir, "legacy_metadata.json"), PartitionCache{
+		Table:   "legacy",
+		Entries: map[string]PartitionCacheEntry{"legacy_20240101": {RowCount: 5}},
+	})
+
+	bundle, err := exportCacheBundle(sourceDir, "flights")
+	if err != nil {
+		t.Fatalf("exportCacheBundle() error = %v", err)
+	}
+	if len(bundle.Caches) != 1 || bundle.Caches[0].OutputPath != scope.OutputPath {
+		t.Fatalf("expected only the flights cache with its scope, got %+v", bundle.Caches)
+	}
+
+	// The destination already has a newer entry for the same partition, and one of its own
+	newer := PartitionCacheEntry{RowCount: 11, CountTime: uploaded.Add(time.Hour)}
+	remapped := bundle.Caches[0]
+	remapped.remap([]cacheRemap{{From: "s3://old-bucket/archive", To: "s3://new-bucket/cold/archive"}})
+	if remapped.OutputPath != "s3://new-bucket/cold/archive/{table}" {
+		t.Fatalf("unexpected remapped output path %s", remapped.OutputPath)
+	}
+	if key := remapped.Entries["flights_20240101"].S3Key; key != "cold/archive/flights/2024-01-01.jsonl.zst" {
+		t.Fatalf("expected S3 key under the new prefix, got %s", key)
+	}
+	destScope, _ := remapped.scope()
+	destPath := filepath.Join(destDir, destScope.fileIdentifier()+"_metadata.json")
+	writeTestCacheFile(t, destPath, PartitionCache{Entries: map[string]PartitionCacheEntry{
+		"flights_20240101": newer,
+		"flights_20240102": {RowCount: 7},
+	}})
+
+	var report cacheImportReport
+	if err := importCacheScope(destDir, remapped, false, &report); err != nil {
+		t.Fatalf("importCacheScope() error = %v", err)
+	}
+	if report.Imported != 0 || report.KeptLocal != 1 {
+		t.Errorf("expected the newer local entry to be kept, got %+v", report)
+	}
+
+	data, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatalf("failed to read imported cache: %v", err)
+	}
+	var imported PartitionCache
+	if err := json.Unmarshal(data, &imported); err != nil {
+		t.Fatalf("failed to parse imported cache: %v", err)
+	}
+	if imported.OutputPath != remapped.OutputPath || imported.Entries["flights_20240101"].RowCount != 11 || len(imported.Entries) != 2 {
+		t.Errorf("unexpected imported cache: %+v", imported)
+	}
+}
+
+func TestValidateCacheEntries(t *testing.T) {
+	store := &fakeObjectStore{objects: map[string][]byte{
+		"archive/a.jsonl": []byte("aaaa"),
+		"archive/b.jsonl": []byte("bbbbbb"),
+	}}
+	sumA := md5.Sum([]byte("aaaa"))
+	entries := map[string]PartitionCacheEntry{
+		"a":       {FileSize: 4, FileMD5: hex.EncodeToString(sumA[:]), S3Key: "archive/a.jsonl", S3Uploaded: true},
+		"b":       {FileSize: 4, FileMD5: "stale", S3Key: "archive/b.jsonl", S3Uploaded: true},
+		"c":       {FileSize: 9, FileMD5: "gone", S3Key: "archive/c.jsonl", S3Uploaded: true},
+		"counted": {RowCount: 3},
+	}
+
+	var report cacheImportReport
+	if err := validateCacheEntries(context.Background(), newS3ObjectStore(store), "bucket", entries, &report); err != nil {
+		t.Fatalf("validateCacheEntries() error = %v", err)
+	}
+	if report.Verified != 1 || report.Changed != 1 || report.Missing != 1 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if !entries["a"].S3Uploaded || entries["a"].FileSize != 4 {
+		t.Errorf("matching entry should be kept, got %+v", entries["a"])
+	}
+	for _, name := range []string{"b", "c"} {
+		if entry := entries[name]; entry.S3Uploaded || entry.FileSize != 0 || entry.FileMD5 != "" {
+			t.Errorf("entry %s should be reset, got %+v", name, entry)
+		}
+	}
+	if entries["counted"].RowCount != 3 {
+		t.Error("entries without uploads should be untouched")
+	}
+}
+
+func TestParseCacheRemaps(t *testing.T) {
+	if _, err := parseCacheRemaps([]string{"s3://a"}); !errors.Is(err, ErrCacheRemapInvalid) {
+		t.Errorf("expected ErrCacheRemapInvalid, got %v", err)
+	}
+	remaps, err := parseCacheRemaps([]string{"s3://a/x=s3://b/x"})
+	if err != nil || len(remaps) != 1 || remaps[0].To != "s3://b/x" {
+		t.Errorf("unexpected remaps %+v, %v", remaps, err)
+	}
+
+	// Remaps only match whole path components
+	scope := CacheBundleScope{OutputPath: "s3://a-other/x"}
+	scope.remap([]cacheRemap{{From: "s3://a", To: "s3://b"}})
+	if scope.OutputPath != "s3://a-other/x" {
+		t.Errorf("remap should not match a bucket prefix, got %s", scope.OutputPath)
+	}
+}
+
+func writeTestCacheFile(t *testing.T, path string, cache PartitionCache) {
+	t.Helper()
+	data, err := json.Marshal(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
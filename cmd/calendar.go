@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Static errors for skip calendars
+var ErrCalendarEntryInvalid = errors.New("calendar entry must be a date (2006-01-02), a yearly date (01-02), a range (2006-01-02..2006-01-31), or a weekday name")
+
+var (
+	skipWeekends bool
+	skipCalendar string
+)
+
+func init() {
+	archiveCmd.Flags().BoolVar(&skipWeekends, "skip-weekends", false, "skip output slices that fall entirely on Saturdays and Sundays without querying them")
+	archiveCmd.Flags().StringVar(&skipCalendar, "skip-calendar", "", "file of holidays, seasons, or weekdays whose slices are skipped (one date, MM-DD, range, or weekday per line)")
+	_ = viper.BindPFlag("calendar.skip_weekends", archiveCmd.Flags().Lookup("skip-weekends"))
+	_ = viper.BindPFlag("calendar.file", archiveCmd.Flags().Lookup("skip-calendar"))
+}
+
+// calendarRange is an inclusive range of skipped days
+type calendarRange struct {
+	Start time.Time
+	End   time.Time
+	Label string
+}
+
+// SkipCalendar lists the days on which a table has no data, so slices that
+// fall entirely on them are skipped instead of queried
+type SkipCalendar struct {
+	weekdays map[time.Weekday]string // Weekday -> label
+	dates    map[string]string       // YYYY-MM-DD -> label
+	yearly   map[string]string       // MM-DD -> label
+	ranges   []calendarRange
+}
+
+// newSkipCalendar returns an empty calendar
+func newSkipCalendar() *SkipCalendar {
+	return &SkipCalendar{
+		weekdays: make(map[time.Weekday]string),
+		dates:    make(map[string]string),
+		yearly:   make(map[string]string),
+	}
+}
+
+// loadSkipCalendar builds the archive calendar from --skip-weekends,
+// --skip-calendar, and the calendar.dates config list. It returns nil when no
+// days are skipped.
+func loadSkipCalendar() (*SkipCalendar, error) {
+	calendar := newSkipCalendar()
+	if viper.GetBool("calendar.skip_weekends") {
+		calendar.weekdays[time.Saturday] = "weekend"
+		calendar.weekdays[time.Sunday] = "weekend"
+	}
+
+	if path := viper.GetString("calendar.file"); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open calendar: %w", err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for line := 1; scanner.Scan(); line++ {
+			if err := calendar.addEntry(scanner.Text()); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read calendar: %w", err)
+		}
+	}
+
+	for _, entry := range viper.GetStringSlice("calendar.dates") {
+		if err := calendar.addEntry(entry); err != nil {
+			return nil, err
+		}
+	}
+
+	if calendar.empty() {
+		return nil, nil // No days skipped
+	}
+	return calendar, nil
+}
+
+// addEntry parses one calendar line: a day, range, or weekday followed by an
+// optional label. Blank lines and # comments are ignored.
+func (c *SkipCalendar) addEntry(entry string) error {
+	if i := strings.Index(entry, "#"); i >= 0 {
+		entry = entry[:i]
+	}
+	fields := strings.Fields(entry)
+	if len(fields) == 0 {
+		return nil
+	}
+	spec := fields[0]
+	label := strings.Join(fields[1:], " ")
+
+	if weekday, ok := parseWeekday(spec); ok {
+		if label == "" {
+			label = "weekday"
+		}
+		c.weekdays[weekday] = label
+		return nil
+	}
+	if label == "" {
+		label = "holiday"
+	}
+
+	if from, to, ok := strings.Cut(spec, ".."); ok {
+		start, startErr := time.Parse("2006-01-02", from)
+		end, endErr := time.Parse("2006-01-02", to)
+		if startErr != nil || endErr != nil || end.Before(start) {
+			return fmt.Errorf("%w: '%s'", ErrCalendarEntryInvalid, spec)
+		}
+		c.ranges = append(c.ranges, calendarRange{Start: start, End: end, Label: label})
+		return nil
+	}
+	if day, err := time.Parse("2006-01-02", spec); err == nil {
+		c.dates[day.Format("2006-01-02")] = label
+		return nil
+	}
+	if day, err := time.Parse("01-02", spec); err == nil {
+		c.yearly[day.Format("01-02")] = label
+		return nil
+	}
+	return fmt.Errorf("%w: '%s'", ErrCalendarEntryInvalid, spec)
+}
+
+// parseWeekday matches full and three-letter English weekday names
+func parseWeekday(name string) (time.Weekday, bool) {
+	name = strings.ToLower(name)
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		full := strings.ToLower(weekday.String())
+		if name == full || name == full[:3] {
+			return weekday, true
+		}
+	}
+	return 0, false
+}
+
+// empty reports whether the calendar skips no days
+func (c *SkipCalendar) empty() bool {
+	return len(c.weekdays) == 0 && len(c.dates) == 0 && len(c.yearly) == 0 && len(c.ranges) == 0
+}
+
+// dayReason returns why day is skipped, or false if it is a working day.
+// Specific dates take precedence over ranges, yearly dates, and weekdays.
+func (c *SkipCalendar) dayReason(day time.Time) (string, bool) {
+	date := day.Format("2006-01-02")
+	if label, ok := c.dates[date]; ok {
+		return label, true
+	}
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	for _, r := range c.ranges {
+		if !midnight.Before(r.Start) && !midnight.After(r.End) {
+			return r.Label, true
+		}
+	}
+	if label, ok := c.yearly[day.Format("01-02")]; ok {
+		return label, true
+	}
+	if label, ok := c.weekdays[day.Weekday()]; ok {
+		return fmt.Sprintf("%s (%s)", label, day.Weekday()), true
+	}
+	return "", false
+}
+
+// skipReason returns why the slice [start, end) is skipped: every day it
+// touches must be a skipped day. A nil calendar skips nothing.
+func (c *SkipCalendar) skipReason(start, end time.Time) (string, bool) {
+	if c == nil || !end.After(start) {
+		return "", false
+	}
+	seen := make(map[string]bool)
+	var reasons []string
+	for day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location()); day.Before(end); day = day.AddDate(0, 0, 1) {
+		reason, ok := c.dayReason(day)
+		if !ok {
+			return "", false
+		}
+		if !seen[reason] {
+			seen[reason] = true
+			reasons = append(reasons, reason)
+		}
+	}
+	sort.Strings(reasons)
+	return strings.Join(reasons, ", "), true
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/spf13/viper"
+)
+
+func TestSkipCalendarSkipReason(t *testing.T) {
+	calendar := newSkipCalendar()
+	for _, entry := range []string{
+		"saturday weekend",
+		"Sun weekend",
+		"12-25 Christmas",
+		"2024-01-15 MLK Day # observed",
+		"2024-08-05..2024-08-09 Summer shutdown",
+		"",
+		"# comment only",
+	} {
+		if err := calendar.addEntry(entry); err != nil {
+			t.Fatalf("addEntry(%q) error = %v", entry, err)
+		}
+	}
+
+	day := func(s string) time.Time {
+		parsed, _ := time.Parse("2006-01-02", s)
+		return parsed
+	}
+	tests := []struct {
+		name       string
+		start, end time.Time
+		want       string
+		wantSkip   bool
+	}{
+		{"saturday", day("2024-01-06"), day("2024-01-07"), "weekend (Saturday)", true},
+		{"monday", day("2024-01-08"), day("2024-01-09"), "", false},
+		{"yearly holiday", day("2023-12-25"), day("2023-12-26"), "Christmas", true},
+		{"dated holiday", day("2024-01-15"), day("2024-01-16"), "MLK Day", true},
+		{"hour on a range day", day("2024-08-07").Add(3 * time.Hour), day("2024-08-07").Add(4 * time.Hour), "Summer shutdown", true},
+		{"whole weekend", day("2024-01-06"), day("2024-01-08"), "weekend (Saturday), weekend (Sunday)", true},
+		{"week with working days", day("2024-01-08"), day("2024-01-15"), "", false},
+		{"shutdown week and weekend", day("2024-08-05"), day("2024-08-12"), "Summer shutdown, weekend (Saturday), weekend (Sunday)", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, skip := calendar.skipReason(tt.start, tt.end)
+			if got != tt.want || skip != tt.wantSkip {
+				t.Errorf("skipReason() = %q, %v; want %q, %v", got, skip, tt.want, tt.wantSkip)
+			}
+		})
+	}
+
+	var none *SkipCalendar
+	if _, skip := none.skipReason(day("2024-01-06"), day("2024-01-07")); skip {
+		t.Error("nil calendar should skip nothing")
+	}
+}
+
+func TestSkipCalendarInvalidEntries(t *testing.T) {
+	for _, entry := range []string{"2024-13-01", "2024-02-10..2024-02-01", "someday", "2024-01-01..x"} {
+		if err := newSkipCalendar().addEntry(entry); !errors.Is(err, ErrCalendarEntryInvalid) {
+			t.Errorf("addEntry(%q) expected ErrCalendarEntryInvalid, got %v", entry, err)
+		}
+	}
+}
+
+func TestLoadSkipCalendar(t *testing.T) {
+	defer viper.Reset()
+
+	calendar, err := loadSkipCalendar()
+	if err != nil || calendar != nil {
+		t.Fatalf("expected no calendar by default, got %+v, %v", calendar, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "holidays.txt")
+	if err := os.WriteFile(path, []byte("07-04 Independence Day\nbogus\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("calendar.file", path)
+	if _, err := loadSkipCalendar(); !errors.Is(err, ErrCalendarEntryInvalid) || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("expected the bad line to be reported, got %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("07-04 Independence Day\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("calendar.skip_weekends", true)
+	viper.Set("calendar.dates", []string{"2024-11-28 Thanksgiving"})
+	calendar, err = loadSkipCalendar()
+	if err != nil {
+		t.Fatalf("loadSkipCalendar() error = %v", err)
+	}
+	for date, want := range map[string]string{"2024-07-04": "Independence Day", "2024-11-28": "Thanksgiving", "2024-11-30": "weekend (Saturday)"} {
+		day, _ := time.Parse("2006-01-02", date)
+		if got, ok := calendar.dayReason(day); !ok || got != want {
+			t.Errorf("dayReason(%s) = %q, %v; want %q", date, got, ok, want)
+		}
+	}
+}
+
+func TestProcessPartitionWithSplitCalendar(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	calendar := newSkipCalendar()
+	if err := calendar.addEntry("2024-03-01..2024-03-31 Closed season"); err != nil {
+		t.Fatal(err)
+	}
+	archiver := NewArchiver(&Config{
+		Table:          "events",
+		DateColumn:     "created_at",
+		OutputDuration: DurationDaily,
+		Calendar:       calendar,
+	}, newTestLogger())
+	archiver.db = db
+	archiver.ctx = context.Background()
+
+	// Every slice falls in the closed season, so nothing is queried
+	result := archiver.processPartitionWithSplit(PartitionInfo{
+		TableName: "events_2024_03",
+		Date:      time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}, nil)
+	if !result.Skipped || !strings.HasPrefix(result.SkipReason, "All slices skipped by calendar: 2024-03-01 Closed season") {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unexpected queries: %v", err)
+	}
+}
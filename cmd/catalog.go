@@ -0,0 +1,385 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// catalogGapsShown is how many gaps the text output lists per table
+const catalogGapsShown = 10
+
+// Static errors for the archive catalog
+var ErrCatalogOutputFormatInvalid = errors.New("catalog output format must be one of: text, json, csv")
+
+var (
+	catalogTables       []string
+	catalogOutputFormat string
+)
+
+var catalogCmd = &cobra.Command{
+	Use:     "catalog",
+	Aliases: []string{"list"},
+	Short:   "List what has been archived for each table under a path template",
+	Long: `Walk the path template and print an inventory of the archived objects of each table found
+under it: the dates covered, gaps in that range, file counts, total size, formats and compression.
+Tables are discovered from the {table} part of the template, or limited with --table. Only keys
+following the archive file naming ({table}-{period}, including split-archive parts and manifests)
+are counted.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		runCatalog(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(catalogCmd)
+
+	// S3 flags
+	catalogCmd.Flags().StringVar(&s3Endpoint, "s3-endpoint", "", "S3-compatible endpoint URL")
+	catalogCmd.Flags().StringVar(&s3Bucket, "s3-bucket", "", "S3 bucket name")
+	catalogCmd.Flags().StringVar(&s3AccessKey, "s3-access-key", "", "S3 access key")
+	catalogCmd.Flags().StringVar(&s3SecretKey, "s3-secret-key", "", "S3 secret key")
+	catalogCmd.Flags().StringVar(&s3Region, "s3-region", "auto", "S3 region")
+
+	// Catalog-specific flags
+	catalogCmd.Flags().StringVar(&pathTemplate, "path-template", "", "S3 path template used when archiving (required)")
+	catalogCmd.Flags().StringSliceVar(&catalogTables, "table", nil, "only list these tables (repeatable or comma-separated; default: every table found)")
+	catalogCmd.Flags().StringVar(&catalogOutputFormat, "output-format", "text", "Output format: text, json, csv")
+}
+
+// CatalogGap is a stretch of time without archived data, from Start up to End
+type CatalogGap struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// CatalogTable is the inventory of one table's archive
+type CatalogTable struct {
+	Table        string         `json:"table"`
+	Files        int            `json:"files"` // Data files, counting each split-archive part
+	Manifests    int            `json:"manifests"`
+	Bytes        int64          `json:"bytes"` // Size of every object, manifests included
+	FirstDate    time.Time      `json:"first_date"`
+	LastDate     time.Time      `json:"last_date"` // End of the latest period archived
+	Gaps         []CatalogGap   `json:"gaps"`
+	Formats      map[string]int `json:"formats"`      // Data files by format
+	Compressions map[string]int `json:"compressions"` // Data files by compression
+
+	periods []CatalogGap // Periods the objects hold
+}
+
+// add counts one archive object
+func (t *CatalogTable) add(entry IndexEntry) {
+	t.Bytes += entry.Size
+	t.periods = append(t.periods, CatalogGap{Start: entry.PeriodStart, End: entry.PeriodEnd})
+	if entry.Kind == IndexKindManifest {
+		t.Manifests++
+		return
+	}
+	if entry.Kind == IndexKindDictionary {
+		return
+	}
+	t.Files++
+	if entry.Format != "" {
+		t.Formats[entry.Format]++
+		t.Compressions[entry.Compression]++
+	}
+}
+
+// finish works out the dates covered and the gaps between them
+func (t *CatalogTable) finish() {
+	sort.Slice(t.periods, func(i, j int) bool { return t.periods[i].Start.Before(t.periods[j].Start) })
+	t.Gaps = []CatalogGap{}
+	for i, period := range t.periods {
+		if i == 0 {
+			t.FirstDate, t.LastDate = period.Start, period.End
+			continue
+		}
+		if period.Start.After(t.LastDate) {
+			t.Gaps = append(t.Gaps, CatalogGap{Start: t.LastDate, End: period.Start})
+		}
+		if period.End.After(t.LastDate) {
+			t.LastDate = period.End
+		}
+	}
+	t.periods = nil
+}
+
+// catalogTableMatcher finds the table a key belongs to from the {table} part
+// of the path template's directories
+type catalogTableMatcher struct {
+	prefix string
+	dir    *regexp.Regexp
+}
+
+func newCatalogTableMatcher(template string) (*catalogTableMatcher, error) {
+	if !strings.Contains(template, "{table}") {
+		return nil, ErrRetentionTemplateInvalid
+	}
+	template = strings.Trim(template, "/")
+	dir := regexp.QuoteMeta(template)
+	dir = strings.Replace(dir, regexp.QuoteMeta("{table}"), `([^/]+)`, 1)
+	dir = strings.ReplaceAll(dir, regexp.QuoteMeta("{table}"), `[^/]+`)
+	dir = templateDatePattern(dir)
+	return &catalogTableMatcher{
+		prefix: template[:strings.Index(template, "{")],
+		dir:    regexp.MustCompile(`^` + dir + `$`),
+	}, nil
+}
+
+// table returns the table component of key's directory
+func (m *catalogTableMatcher) table(key string) (string, bool) {
+	match := m.dir.FindStringSubmatch(strings.TrimSuffix(path.Dir(key), "/"))
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// buildCatalog lists the archive objects under template and returns the
+// inventory of each table, sorted by table. tables limits the tables listed
+// (nil = every table found).
+func buildCatalog(ctx context.Context, client StorageBackend, bucket, template string, tables []string) ([]CatalogTable, error) {
+	tableMatcher, err := newCatalogTableMatcher(template)
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		wanted[objectKeyComponent(table)] = true
+	}
+
+	matchers := make(map[string]*archiveObjectMatcher)
+	catalog := make(map[string]*CatalogTable)
+	var matchErr error
+	err = client.List(ctx, bucket, tableMatcher.prefix, func(object ObjectInfo) bool {
+		table, ok := tableMatcher.table(object.Key)
+		if !ok || (len(wanted) > 0 && !wanted[table]) {
+			return true
+		}
+		matcher := matchers[table]
+		if matcher == nil {
+			if matcher, matchErr = newArchiveObjectMatcher(template, table); matchErr != nil {
+				return false
+			}
+			matchers[table] = matcher
+		}
+		start, end, ok := matcher.period(object.Key)
+		if !ok {
+			return true
+		}
+		entry := catalog[table]
+		if entry == nil {
+			entry = &CatalogTable{Table: table, Formats: map[string]int{}, Compressions: map[string]int{}}
+			catalog[table] = entry
+		}
+		entry.add(newIndexEntry(object, start, end))
+		return true
+	})
+	if err == nil {
+		err = matchErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archived objects: %w", err)
+	}
+
+	result := make([]CatalogTable, 0, len(catalog))
+	for _, table := range catalog {
+		table.finish()
+		result = append(result, *table)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Table < result[j].Table })
+	return result, nil
+}
+
+// formatCatalogTime shows a period boundary as a date, or with the hour when
+// it does not fall on midnight
+func formatCatalogTime(t time.Time) string {
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+// formatCatalogCounts lists counts by name, most common first: jsonl=10;csv=2
+func formatCatalogCounts(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s=%d", name, counts[name])
+	}
+	return strings.Join(parts, ";")
+}
+
+// writeCatalogText renders one line per table, then the gaps of each table
+func writeCatalogText(w io.Writer, tables []CatalogTable) {
+	if len(tables) == 0 {
+		fmt.Fprintln(w, "No archived objects found")
+		return
+	}
+
+	fmt.Fprintf(w, "%-30s %8s %12s %-16s %-16s %6s  %-20s %s\n",
+		"TABLE", "FILES", "SIZE", "FIRST", "LAST", "GAPS", "FORMATS", "COMPRESSION")
+	var files int
+	var bytes int64
+	for _, table := range tables {
+		files += table.Files
+		bytes += table.Bytes
+		fmt.Fprintf(w, "%-30s %8d %12s %-16s %-16s %6d  %-20s %s\n",
+			table.Table, table.Files, formatBytes(table.Bytes),
+			formatCatalogTime(table.FirstDate), formatCatalogTime(table.LastDate), len(table.Gaps),
+			formatCatalogCounts(table.Formats), formatCatalogCounts(table.Compressions))
+	}
+	fmt.Fprintf(w, "%d tables, %d files, %s\n", len(tables), files, formatBytes(bytes))
+
+	for _, table := range tables {
+		if len(table.Gaps) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s: %d gaps\n", table.Table, len(table.Gaps))
+		for i, gap := range table.Gaps {
+			if i == catalogGapsShown {
+				fmt.Fprintf(w, "  ... and %d more (see --output-format json)\n", len(table.Gaps)-catalogGapsShown)
+				break
+			}
+			fmt.Fprintf(w, "  missing from %s until %s\n", formatCatalogTime(gap.Start), formatCatalogTime(gap.End))
+		}
+	}
+}
+
+// writeCatalogCSV writes one row per table. Gaps are start/end intervals
+// separated by semicolons.
+func writeCatalogCSV(w io.Writer, tables []CatalogTable) error {
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{"table", "files", "manifests", "bytes", "first_date", "last_date", "gap_count", "gaps", "formats", "compressions"})
+	for _, table := range tables {
+		gaps := make([]string, len(table.Gaps))
+		for i, gap := range table.Gaps {
+			gaps[i] = gap.Start.Format(time.RFC3339) + "/" + gap.End.Format(time.RFC3339)
+		}
+		_ = writer.Write([]string{
+			table.Table,
+			strconv.Itoa(table.Files),
+			strconv.Itoa(table.Manifests),
+			strconv.FormatInt(table.Bytes, 10),
+			table.FirstDate.Format(time.RFC3339),
+			table.LastDate.Format(time.RFC3339),
+			strconv.Itoa(len(table.Gaps)),
+			strings.Join(gaps, ";"),
+			formatCatalogCounts(table.Formats),
+			formatCatalogCounts(table.Compressions),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func runCatalog(cmd *cobra.Command) {
+	getStringConfig := func(flagValue string, flagName string, viperKey string) string {
+		if flag := cmd.Flags().Lookup(flagName); flag != nil && flag.Changed {
+			return flagValue
+		}
+		if viperValue := viper.GetString(viperKey); viperValue != "" {
+			return viperValue
+		}
+		return flagValue
+	}
+
+	s3Config, s3Err := newS3Config(S3Config{
+		Endpoint:     getStringConfig(s3Endpoint, "s3-endpoint", "s3.endpoint"),
+		Bucket:       getStringConfig(s3Bucket, "s3-bucket", "s3.bucket"),
+		AccessKey:    getStringConfig(s3AccessKey, "s3-access-key", "s3.access_key"),
+		SecretKey:    getStringConfig(s3SecretKey, "s3-secret-key", "s3.secret_key"),
+		Region:       getStringConfig(s3Region, "s3-region", "s3.region"),
+		PathTemplate: getStringConfig(pathTemplate, "path-template", "s3.path_template"),
+	}, endpointProfileName("catalog"))
+
+	initLogger(viper.GetBool("debug"), viper.GetString("log_format"))
+
+	if s3Err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", s3Err.Error()))
+		os.Exit(1)
+	}
+	if err := validateCatalogConfig(s3Config); err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	ctx := signalContext
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	client, err := newStorageClient(s3Config)
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ Failed to create storage client: %v", err))
+		os.Exit(1)
+	}
+	tables, err := buildCatalog(ctx, client, s3Config.Bucket, s3Config.PathTemplate, catalogTables)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(130)
+		}
+		logger.Error(fmt.Sprintf("❌ %v", err))
+		os.Exit(1)
+	}
+
+	switch catalogOutputFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(tables)
+	case "csv":
+		if err := writeCatalogCSV(os.Stdout, tables); err != nil {
+			logger.Error(fmt.Sprintf("❌ %v", err))
+			os.Exit(1)
+		}
+	default:
+		writeCatalogText(os.Stdout, tables)
+	}
+}
+
+// validateCatalogConfig checks the S3 settings and report options
+func validateCatalogConfig(s3Config S3Config) error {
+	if s3Config.Bucket == "" {
+		return ErrS3BucketRequired
+	}
+	if err := s3Config.validateCredentials(); err != nil {
+		return err
+	}
+	if s3Config.PathTemplate == "" {
+		return ErrPathTemplateRequired
+	}
+	if !strings.Contains(s3Config.PathTemplate, "{table}") {
+		return ErrRetentionTemplateInvalid
+	}
+	switch catalogOutputFormat {
+	case "text", "json", "csv":
+	default:
+		return fmt.Errorf("%w: '%s'", ErrCatalogOutputFormatInvalid, catalogOutputFormat)
+	}
+	return s3Config.HTTP.Validate()
+}
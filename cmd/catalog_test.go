@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newCatalogTestStore() *fakeObjectStore {
+	return &fakeObjectStore{objects: map[string][]byte{
+		"archives/events/2024/01/events-2024-01-01.jsonl.zst":           []byte("1"),
+		"archives/events/2024/01/events-2024-01-02.jsonl.zst":           []byte("22"),
+		"archives/events/2024/01/events-2024-01-05.manifest.json":       []byte("{}"),
+		"archives/events/2024/01/events-2024-01-05-part-0001.csv.gz":    []byte("333"),
+		"archives/events/2024/01/events-2024-01-05-part-0002.csv.gz":    []byte("4444"),
+		"archives/events/2024/01/events-2024-01-07.jsonl.zst":           []byte("55555"),
+		"archives/events/2024/01/notes.txt":                             []byte("not an archive"),
+		"archives/flights/2023/12/flights-2023-12.parquet":              []byte("666666"),
+		"archives/flights/2024/01/flights-2024-01.parquet":              []byte("7777777"),
+		"archives/flights/flights-schema.dump":                          []byte("CREATE TABLE"),
+		"archives/positions/2024/01/positions-2024-01-01-part-0001.csv": []byte("8"),
+	}}
+}
+
+func TestBuildCatalog(t *testing.T) {
+	store := newCatalogTestStore()
+	tables, err := buildCatalog(context.Background(), newS3ObjectStore(store), "bucket", "archives/{table}/{YYYY}/{MM}", nil)
+	if err != nil {
+		t.Fatalf("buildCatalog() error = %v", err)
+	}
+	if len(tables) != 3 || tables[0].Table != "events" || tables[1].Table != "flights" || tables[2].Table != "positions" {
+		t.Fatalf("tables = %+v", tables)
+	}
+
+	events := tables[0]
+	if events.Files != 5 || events.Manifests != 1 || events.Bytes != 1+2+2+3+4+5 {
+		t.Errorf("events counts = %+v", events)
+	}
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	if !events.FirstDate.Equal(day(1)) || !events.LastDate.Equal(day(8)) {
+		t.Errorf("events coverage = %v - %v", events.FirstDate, events.LastDate)
+	}
+	if len(events.Gaps) != 2 || !events.Gaps[0].Start.Equal(day(3)) || !events.Gaps[0].End.Equal(day(5)) ||
+		!events.Gaps[1].Start.Equal(day(6)) || !events.Gaps[1].End.Equal(day(7)) {
+		t.Errorf("events gaps = %+v", events.Gaps)
+	}
+	if formatCatalogCounts(events.Formats) != "jsonl=3;csv=2" || formatCatalogCounts(events.Compressions) != "zstd=3;gzip=2" {
+		t.Errorf("events formats = %v, compressions = %v", events.Formats, events.Compressions)
+	}
+
+	// Consecutive months leave no gap
+	flights := tables[1]
+	if flights.Files != 2 || len(flights.Gaps) != 0 || !flights.LastDate.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("flights = %+v", flights)
+	}
+
+	tables, err = buildCatalog(context.Background(), newS3ObjectStore(store), "bucket", "archives/{table}/{YYYY}/{MM}", []string{"flights"})
+	if err != nil || len(tables) != 1 || tables[0].Table != "flights" {
+		t.Errorf("buildCatalog(flights) = %+v, %v", tables, err)
+	}
+
+	if _, err := buildCatalog(context.Background(), newS3ObjectStore(store), "bucket", "archives/{YYYY}", nil); !errors.Is(err, ErrRetentionTemplateInvalid) {
+		t.Errorf("expected ErrRetentionTemplateInvalid, got %v", err)
+	}
+}
+
+func TestWriteCatalog(t *testing.T) {
+	tables, err := buildCatalog(context.Background(), newS3ObjectStore(newCatalogTestStore()), "bucket", "archives/{table}/{YYYY}/{MM}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var text bytes.Buffer
+	writeCatalogText(&text, tables)
+	for _, want := range []string{"3 tables, 8 files", "events: 2 gaps", "missing from 2024-01-03 until 2024-01-05", "jsonl=3;csv=2"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("text output missing %q:\n%s", want, text.String())
+		}
+	}
+
+	var csvOut bytes.Buffer
+	if err := writeCatalogCSV(&csvOut, tables); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "table,files,manifests,bytes") {
+		t.Fatalf("csv output:\n%s", csvOut.String())
+	}
+	if want := "events,5,1,17,2024-01-01T00:00:00Z,2024-01-08T00:00:00Z,2,2024-01-03T00:00:00Z/2024-01-05T00:00:00Z;2024-01-06T00:00:00Z/2024-01-07T00:00:00Z,jsonl=3;csv=2,zstd=3;gzip=2"; lines[1] != want {
+		t.Errorf("csv row = %q\nwant      %q", lines[1], want)
+	}
+
+	var empty bytes.Buffer
+	writeCatalogText(&empty, nil)
+	if empty.String() != "No archived objects found\n" {
+		t.Errorf("empty output = %q", empty.String())
+	}
+}
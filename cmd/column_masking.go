@@ -0,0 +1,285 @@
+package cmd
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Column masking transforms (--mask column=transform)
+const (
+	MaskHash     = "hash"     // HMAC-SHA256 of the value, as hex
+	MaskRedact   = "redact"   // NULL
+	MaskTruncate = "truncate" // truncate:<hour|day|month|year> for date and timestamp columns
+	MaskConstant = "constant" // constant:<value>
+)
+
+// maskedColumnsMetadataKey is the user metadata key (x-amz-meta-masked-columns)
+// listing the masked columns of an archive file
+const maskedColumnsMetadataKey = "masked-columns"
+
+// Static errors for column masking
+var (
+	ErrMaskInvalid        = errors.New("mask must be hash, redact, truncate:<hour|day|month|year>, or constant:<value>")
+	ErrMaskColumnInvalid  = errors.New("masked column is not a valid column name")
+	ErrMaskKeyRequired    = errors.New("hash masking requires --mask-key-file")
+	ErrMaskKeyEmpty       = errors.New("mask key file is empty")
+	ErrMaskColumnMissing  = errors.New("masked column is not in the table")
+	ErrMaskTruncateColumn = errors.New("truncate masking needs a date or timestamp column")
+)
+
+// maskTruncateUnits are the units timestamps can be truncated to
+var maskTruncateUnits = map[string]bool{"hour": true, "day": true, "month": true, "year": true}
+
+// maskTimeTypes are the column types truncate masking applies to
+var maskTimeTypes = map[string]bool{"date": true, "timestamp": true, "timestamptz": true}
+
+var (
+	maskColumns map[string]string
+	maskKeyFile string
+)
+
+func init() {
+	archiveCmd.Flags().StringToStringVar(&maskColumns, "mask", nil, "mask columns before they are written, as column=transform pairs: hash, redact, truncate:<hour|day|month|year>, constant:<value> (e.g. user_id=hash,email=redact)")
+	archiveCmd.Flags().StringVar(&maskKeyFile, "mask-key-file", "", "file holding the secret key of hash masking")
+	_ = viper.BindPFlag("masking.columns", archiveCmd.Flags().Lookup("mask"))
+	_ = viper.BindPFlag("masking.key_file", archiveCmd.Flags().Lookup("mask-key-file"))
+}
+
+// columnMask is one column's parsed transform
+type columnMask struct {
+	kind  string
+	unit  string // Truncation unit
+	value string // Constant written instead of the value
+}
+
+// parseColumnMask parses a --mask transform
+func parseColumnMask(spec string) (columnMask, error) {
+	kind, arg, hasArg := strings.Cut(spec, ":")
+	switch {
+	case (kind == MaskHash || kind == MaskRedact) && !hasArg:
+		return columnMask{kind: kind}, nil
+	case kind == MaskTruncate && maskTruncateUnits[arg]:
+		return columnMask{kind: kind, unit: arg}, nil
+	case kind == MaskConstant && hasArg:
+		return columnMask{kind: kind, value: arg}, nil
+	}
+	return columnMask{}, fmt.Errorf("%w, got '%s'", ErrMaskInvalid, spec)
+}
+
+// loadMaskKey reads the secret key of hash masking
+func loadMaskKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mask key file: %w", err)
+	}
+	key := []byte(strings.TrimSpace(string(data)))
+	if len(key) == 0 {
+		return nil, fmt.Errorf("%w: '%s'", ErrMaskKeyEmpty, path)
+	}
+	return key, nil
+}
+
+// validateMasking checks the --mask transforms and that hash masking has a key
+func (c *Config) validateMasking() error {
+	needsKey := false
+	for column, spec := range c.Masking {
+		if !validPostgreSQLIdentifier.MatchString(column) {
+			return fmt.Errorf("%w: '%s'", ErrMaskColumnInvalid, column)
+		}
+		mask, err := parseColumnMask(spec)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", column, err)
+		}
+		needsKey = needsKey || mask.kind == MaskHash
+	}
+	if !needsKey {
+		return nil
+	}
+	if c.MaskKeyFile == "" {
+		return ErrMaskKeyRequired
+	}
+	_, err := loadMaskKey(c.MaskKeyFile)
+	return err
+}
+
+// maskingRecord is the masking of an archive as recorded in its manifests,
+// so readers know the masked columns don't hold the original values
+type maskingRecord struct {
+	Columns map[string]string `json:"columns"`          // Column → transform
+	KeyID   string            `json:"key_id,omitempty"` // Fingerprint of the hash key, so pseudonyms from different keys aren't joined
+}
+
+// maskingRecord returns the masking to record with archives, or nil without --mask
+func (c *Config) maskingRecord() *maskingRecord {
+	if len(c.Masking) == 0 {
+		return nil
+	}
+	record := &maskingRecord{Columns: c.Masking}
+	if c.MaskKeyFile != "" {
+		if key, err := loadMaskKey(c.MaskKeyFile); err == nil {
+			sum := sha256.Sum256(key)
+			record.KeyID = hex.EncodeToString(sum[:8])
+		}
+	}
+	return record
+}
+
+// maskedColumnsMetadata returns the masked columns as listed in object metadata
+func (c *Config) maskedColumnsMetadata() string {
+	columns := make([]string, 0, len(c.Masking))
+	for column := range c.Masking {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return strings.Join(columns, ",")
+}
+
+// columnMasker applies the --mask transforms to the rows of one partition
+// before they reach the formatter
+type columnMasker struct {
+	masks map[string]columnMask
+	key   []byte
+}
+
+// newColumnMasker returns the masker for a partition's rows, or nil without
+// --mask. Every masked column must be in the schema: a misspelled column
+// would otherwise be archived unmasked.
+func (a *Archiver) newColumnMasker(schema *TableSchema) (*columnMasker, error) {
+	if len(a.config.Masking) == 0 {
+		return nil, nil
+	}
+	types := make(map[string]string, len(schema.Columns))
+	for _, col := range schema.Columns {
+		types[col.Name] = col.UDTName
+	}
+
+	m := &columnMasker{masks: make(map[string]columnMask, len(a.config.Masking))}
+	for column, spec := range a.config.Masking {
+		mask, err := parseColumnMask(spec)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", column, err)
+		}
+		udtName, ok := types[column]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMaskColumnMissing, column)
+		}
+		if mask.kind == MaskTruncate && !maskTimeTypes[udtName] {
+			return nil, fmt.Errorf("%w: %s is %s", ErrMaskTruncateColumn, column, udtName)
+		}
+		if mask.kind == MaskHash && m.key == nil {
+			if m.key, err = loadMaskKey(a.config.MaskKeyFile); err != nil {
+				return nil, err
+			}
+		}
+		m.masks[column] = mask
+	}
+	return m, nil
+}
+
+// outputSchema returns the schema files are written with: columns replaced
+// by a hash or constant hold text, whatever their type in the table
+func (m *columnMasker) outputSchema(schema *TableSchema) *TableSchema {
+	if m == nil {
+		return schema
+	}
+	output := &TableSchema{TableName: schema.TableName, Columns: make([]ColumnInfo, len(schema.Columns))}
+	copy(output.Columns, schema.Columns)
+	for i, col := range output.Columns {
+		if mask, ok := m.masks[col.Name]; ok && (mask.kind == MaskHash || mask.kind == MaskConstant) {
+			output.Columns[i].DataType = "text"
+			output.Columns[i].UDTName = "text"
+		}
+	}
+	return output
+}
+
+// mask transforms the masked columns of row in place. NULLs stay NULL.
+func (m *columnMasker) mask(row map[string]interface{}) {
+	if m == nil {
+		return
+	}
+	for column, mask := range m.masks {
+		value, ok := row[column]
+		if !ok || value == nil {
+			continue
+		}
+		switch mask.kind {
+		case MaskHash:
+			mac := hmac.New(sha256.New, m.key)
+			mac.Write(maskHashInput(value))
+			row[column] = hex.EncodeToString(mac.Sum(nil))
+		case MaskRedact:
+			row[column] = nil
+		case MaskTruncate:
+			if t, ok := value.(time.Time); ok {
+				row[column] = truncateTime(t, mask.unit)
+			}
+		case MaskConstant:
+			row[column] = mask.value
+		}
+	}
+}
+
+// maskHashInput returns the bytes a value is hashed as, so equal values
+// hash alike across runs and output formats
+func maskHashInput(value interface{}) []byte {
+	switch v := value.(type) {
+	case string:
+		return []byte(v)
+	case []byte:
+		return v
+	case time.Time:
+		return []byte(v.UTC().Format(time.RFC3339Nano))
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
+
+// truncateTime truncates t to the start of its hour, day, month or year in its own time zone
+func truncateTime(t time.Time, unit string) time.Time {
+	switch unit {
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case "day":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// maskedColumns returns the masked columns an archive file's metadata lists
+func maskedColumns(metadata map[string]string) string {
+	for key, value := range metadata {
+		if strings.EqualFold(key, maskedColumnsMetadataKey) {
+			return value
+		}
+	}
+	return ""
+}
+
+// warnMasked warns once per set of masked columns that restored files hold
+// masked values rather than the originals
+func (r *Restorer) warnMasked(key string) {
+	if r.downloader == nil || r.shared == nil {
+		return
+	}
+	columns := r.downloader.maskedColumns(key)
+	if columns == "" {
+		return
+	}
+	if _, warned := r.shared.maskWarnings.LoadOrStore(columns, true); warned {
+		return
+	}
+	r.logger.Warn(fmt.Sprintf("⚠️  %s was archived with masked columns (%s); they hold hashes, constants or truncated values, not the original data", key, columns))
+}
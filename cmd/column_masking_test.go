@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// writeMaskKey writes a hash masking key file
+func writeMaskKey(t *testing.T, key string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mask.key")
+	if err := os.WriteFile(path, []byte(key+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateMasking(t *testing.T) {
+	keyFile := writeMaskKey(t, "secret")
+	tests := []struct {
+		masking map[string]string
+		keyFile string
+		want    error
+	}{
+		{nil, "", nil},
+		{map[string]string{"email": "redact", "seen_at": "truncate:day", "name": "constant:anonymous"}, "", nil},
+		{map[string]string{"user_id": "hash"}, keyFile, nil},
+		{map[string]string{"user_id": "hash"}, "", ErrMaskKeyRequired},
+		{map[string]string{"user_id": "hash"}, writeMaskKey(t, " "), ErrMaskKeyEmpty},
+		{map[string]string{"seen_at": "truncate:week"}, "", ErrMaskInvalid},
+		{map[string]string{"email": "redact:all"}, "", ErrMaskInvalid},
+		{map[string]string{"email": "scramble"}, "", ErrMaskInvalid},
+		{map[string]string{"e mail": "redact"}, "", ErrMaskColumnInvalid},
+	}
+	for _, tt := range tests {
+		cfg := &Config{Masking: tt.masking, MaskKeyFile: tt.keyFile}
+		if err := cfg.validateMasking(); !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+			t.Errorf("validateMasking(%v) = %v, want %v", tt.masking, err, tt.want)
+		}
+	}
+}
+
+func TestColumnMaskerRejectsUnknownColumns(t *testing.T) {
+	schema := &TableSchema{Columns: []ColumnInfo{{Name: "id", UDTName: "int8"}, {Name: "email", UDTName: "text"}}}
+
+	archiver := NewArchiver(&Config{Masking: map[string]string{"emial": "redact"}}, newTestLogger())
+	if _, err := archiver.newColumnMasker(schema); !errors.Is(err, ErrMaskColumnMissing) {
+		t.Errorf("expected ErrMaskColumnMissing, got %v", err)
+	}
+	archiver.config.Masking = map[string]string{"email": "truncate:day"}
+	if _, err := archiver.newColumnMasker(schema); !errors.Is(err, ErrMaskTruncateColumn) {
+		t.Errorf("expected ErrMaskTruncateColumn, got %v", err)
+	}
+	archiver.config.Masking = nil
+	if masker, err := archiver.newColumnMasker(schema); masker != nil || err != nil {
+		t.Errorf("newColumnMasker() without --mask = %v, %v", masker, err)
+	}
+}
+
+func TestExtractPartitionDataStreamingMasksColumns(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	archiver := NewArchiver(&Config{
+		Table:        "events",
+		OutputFormat: "parquet",
+		Compression:  "none",
+		Masking:      map[string]string{"user_id": "hash", "email": "redact", "seen_at": "truncate:day", "note": "constant:x"},
+		MaskKeyFile:  writeMaskKey(t, "secret"),
+	}, newTestLogger())
+	archiver.db = db
+	archiver.ctx = context.Background()
+
+	seenAt := time.Date(2024, 1, 1, 13, 45, 0, 0, time.UTC)
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events_20240101").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).
+			AddRow("user_id", "bigint", "int8").
+			AddRow("email", "text", "text").
+			AddRow("seen_at", "timestamp with time zone", "timestamptz").
+			AddRow("note", "text", "text").
+			AddRow("score", "integer", "int4"))
+	mock.ExpectQuery(`SELECT "user_id", "email", "seen_at", "note", "score" FROM "events_20240101"`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "email", "seen_at", "note", "score"}).
+			AddRow(int64(42), "pilot@example.com", seenAt, "private", int64(7)).
+			AddRow(nil, nil, nil, nil, int64(8)))
+
+	cache := &PartitionCache{Entries: make(map[string]PartitionCacheEntry)}
+	partition := PartitionInfo{TableName: "events_20240101", RowCount: 2}
+	path, _, _, _, _, err := archiver.extractPartitionDataStreaming(partition, nil, cache, func(string) {}, time.Time{}, time.Time{}, 0, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("extraction failed: %v", err)
+	}
+	defer cleanupTempFile(path)
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	stream, err := NewRestorer(newTestConfig(), newTestLogger()).openFileRows(file, "parquet", "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	rows, err := stream.peek()
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("peek() = %v, %v", rows, err)
+	}
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("42"))
+	masked := rows[0]
+	if masked["user_id"] != hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("user_id = %v, want its HMAC", masked["user_id"])
+	}
+	if masked["email"] != nil || masked["note"] != "x" || masked["score"] != int32(7) {
+		t.Errorf("masked row = %v", masked)
+	}
+	// Parquet timestamps read back as microseconds
+	if masked["seen_at"] != time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMicro() {
+		t.Errorf("seen_at = %v, want the start of its day", masked["seen_at"])
+	}
+	if rows[1]["user_id"] != nil || rows[1]["note"] != nil {
+		t.Errorf("NULLs should stay NULL, got %v", rows[1])
+	}
+}
+
+func TestMaskingRecordedWithArchives(t *testing.T) {
+	archiver := NewArchiver(&Config{
+		Table:       "events",
+		Masking:     map[string]string{"user_id": "hash", "email": "redact"},
+		MaskKeyFile: writeMaskKey(t, "secret"),
+	}, newTestLogger())
+
+	metadata := archiver.objectMetadata("events/events-2024-01-01.jsonl.zst")
+	if got := metadata[maskedColumnsMetadataKey]; got != "email,user_id" {
+		t.Errorf("masked-columns metadata = %q", got)
+	}
+	// S3 returns metadata keys capitalized
+	if got := maskedColumns(map[string]string{"Masked-Columns": "email,user_id"}); got != "email,user_id" {
+		t.Errorf("maskedColumns() = %q", got)
+	}
+
+	record := archiver.config.maskingRecord()
+	if record == nil || record.Columns["user_id"] != "hash" || len(record.KeyID) != 16 {
+		t.Errorf("maskingRecord() = %+v", record)
+	}
+	if manifest := newRunManifest(archiver.config, time.Now()); manifest.Masking == nil || manifest.Masking.KeyID != record.KeyID {
+		t.Errorf("run manifest masking = %+v", manifest.Masking)
+	}
+	if manifest := archiver.newSplitManifest(PartitionInfo{TableName: "events_20240101"}, "events/events-2024-01-01.jsonl.zst", ".jsonl.zst", nil); manifest.Masking == nil {
+		t.Error("split manifest does not record the masking")
+	}
+	if (&Config{}).maskingRecord() != nil {
+		t.Error("maskingRecord() without --mask should be nil")
+	}
+}
@@ -17,15 +17,9 @@ import (
 	"sort"
 	"strings"
 	"syscall"
-	"time"
 
 	"github.com/airframesio/data-archiver/cmd/compressors"
 	"github.com/airframesio/data-archiver/cmd/formatters"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/lib/pq"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -33,45 +27,70 @@ import (
 
 var (
 	// Source 1 flags
-	compareSource1Type         string
-	compareSource1DbHost       string
-	compareSource1DbPort       int
-	compareSource1DbUser       string
-	compareSource1DbPassword   string
-	compareSource1DbName       string
-	compareSource1DbSSLMode    string
-	compareSource1S3Endpoint   string
-	compareSource1S3Bucket     string
-	compareSource1S3AccessKey  string
-	compareSource1S3SecretKey  string
-	compareSource1S3Region     string
-	compareSource1SchemaPath   string
-	compareSource1DataPath     string
-	compareSource1SchemaSource string // pg_dump, inferred, auto
+	compareSource1Type          string
+	compareSource1DbHost        string
+	compareSource1DbPort        int
+	compareSource1DbUser        string
+	compareSource1DbPassword    string
+	compareSource1DbName        string
+	compareSource1DbSSLMode     string
+	compareSource1S3Endpoint    string
+	compareSource1S3Bucket      string
+	compareSource1S3AccessKey   string
+	compareSource1S3SecretKey   string
+	compareSource1S3Region      string
+	compareSource1S3Profile     string
+	compareSource1SchemaPath    string
+	compareSource1DataPath      string
+	compareSource1SchemaSource  string // pg_dump, inferred, auto
+	compareSource1SSHHost       string
+	compareSource1SSHPort       int
+	compareSource1SSHUser       string
+	compareSource1SSHKey        string
+	compareSource1SSHKnownHosts string
 
 	// Source 2 flags
-	compareSource2Type         string
-	compareSource2DbHost       string
-	compareSource2DbPort       int
-	compareSource2DbUser       string
-	compareSource2DbPassword   string
-	compareSource2DbName       string
-	compareSource2DbSSLMode    string
-	compareSource2S3Endpoint   string
-	compareSource2S3Bucket     string
-	compareSource2S3AccessKey  string
-	compareSource2S3SecretKey  string
-	compareSource2S3Region     string
-	compareSource2SchemaPath   string
-	compareSource2DataPath     string
-	compareSource2SchemaSource string // pg_dump, inferred, auto
+	compareSource2Type          string
+	compareSource2DbHost        string
+	compareSource2DbPort        int
+	compareSource2DbUser        string
+	compareSource2DbPassword    string
+	compareSource2DbName        string
+	compareSource2DbSSLMode     string
+	compareSource2S3Endpoint    string
+	compareSource2S3Bucket      string
+	compareSource2S3AccessKey   string
+	compareSource2S3SecretKey   string
+	compareSource2S3Region      string
+	compareSource2S3Profile     string
+	compareSource2SchemaPath    string
+	compareSource2DataPath      string
+	compareSource2SchemaSource  string // pg_dump, inferred, auto
+	compareSource2SSHHost       string
+	compareSource2SSHPort       int
+	compareSource2SSHUser       string
+	compareSource2SSHKey        string
+	compareSource2SSHKnownHosts string
 
 	// Comparison flags
 	compareMode     string // schema-only, data-only, schema-and-data
-	dataCompareType string // row-count, row-by-row, sample
+	dataCompareType string // row-count, row-by-row, sample, aggregate
 	sampleSize      int
 	compareTables   string // comma-separated table names
 
+	// Aggregate comparison flags
+	compareAggregateDateColumn string
+	compareAggregateSumColumn  string
+
+	// Header-less CSV flags
+	compareCSVNoHeader   bool
+	compareCSVColumns    string
+	compareCSVSchemaFile string
+
+	// Column selection flags
+	compareColumns       string
+	compareIgnoreColumns string
+
 	// Output flags
 	compareOutputFormat string // text, json
 	compareOutputFile   string
@@ -102,9 +121,15 @@ func init() {
 	compareCmd.Flags().StringVar(&compareSource1S3AccessKey, "source1-s3-access-key", "", "Source1 S3 access key")
 	compareCmd.Flags().StringVar(&compareSource1S3SecretKey, "source1-s3-secret-key", "", "Source1 S3 secret key")
 	compareCmd.Flags().StringVar(&compareSource1S3Region, "source1-s3-region", "auto", "Source1 S3 region")
+	compareCmd.Flags().StringVar(&compareSource1S3Profile, "source1-s3-endpoint-profile", "", "Source1 named endpoint from the s3_profiles config section")
 	compareCmd.Flags().StringVar(&compareSource1SchemaPath, "source1-schema-path", "", "Source1 S3 path for schemas")
 	compareCmd.Flags().StringVar(&compareSource1DataPath, "source1-data-path", "", "Source1 S3 path for data")
 	compareCmd.Flags().StringVar(&compareSource1SchemaSource, "source1-schema-source", "auto", "Source1 S3 schema source: pg_dump, inferred, auto")
+	compareCmd.Flags().StringVar(&compareSource1SSHHost, "source1-ssh-host", "", "Source1 SSH jump host for reaching the database (empty = direct connection)")
+	compareCmd.Flags().IntVar(&compareSource1SSHPort, "source1-ssh-port", 22, "Source1 SSH port")
+	compareCmd.Flags().StringVar(&compareSource1SSHUser, "source1-ssh-user", "", "Source1 SSH user")
+	compareCmd.Flags().StringVar(&compareSource1SSHKey, "source1-ssh-key", "", "Source1 SSH private key path")
+	compareCmd.Flags().StringVar(&compareSource1SSHKnownHosts, "source1-ssh-known-hosts", "", "Source1 SSH known_hosts file (default: ~/.ssh/known_hosts)")
 
 	// Source 2 flags
 	compareCmd.Flags().StringVar(&compareSource2Type, "source2-type", "", "Type of source2: db or s3 (required)")
@@ -119,19 +144,34 @@ func init() {
 	compareCmd.Flags().StringVar(&compareSource2S3AccessKey, "source2-s3-access-key", "", "Source2 S3 access key")
 	compareCmd.Flags().StringVar(&compareSource2S3SecretKey, "source2-s3-secret-key", "", "Source2 S3 secret key")
 	compareCmd.Flags().StringVar(&compareSource2S3Region, "source2-s3-region", "auto", "Source2 S3 region")
+	compareCmd.Flags().StringVar(&compareSource2S3Profile, "source2-s3-endpoint-profile", "", "Source2 named endpoint from the s3_profiles config section")
 	compareCmd.Flags().StringVar(&compareSource2SchemaPath, "source2-schema-path", "", "Source2 S3 path for schemas")
 	compareCmd.Flags().StringVar(&compareSource2DataPath, "source2-data-path", "", "Source2 S3 path for data")
 	compareCmd.Flags().StringVar(&compareSource2SchemaSource, "source2-schema-source", "auto", "Source2 S3 schema source: pg_dump, inferred, auto")
+	compareCmd.Flags().StringVar(&compareSource2SSHHost, "source2-ssh-host", "", "Source2 SSH jump host for reaching the database (empty = direct connection)")
+	compareCmd.Flags().IntVar(&compareSource2SSHPort, "source2-ssh-port", 22, "Source2 SSH port")
+	compareCmd.Flags().StringVar(&compareSource2SSHUser, "source2-ssh-user", "", "Source2 SSH user")
+	compareCmd.Flags().StringVar(&compareSource2SSHKey, "source2-ssh-key", "", "Source2 SSH private key path")
+	compareCmd.Flags().StringVar(&compareSource2SSHKnownHosts, "source2-ssh-known-hosts", "", "Source2 SSH known_hosts file (default: ~/.ssh/known_hosts)")
 
 	// Comparison flags
 	compareCmd.Flags().StringVar(&compareMode, "compare-mode", "schema-and-data", "Comparison mode: schema-only, data-only, schema-and-data")
-	compareCmd.Flags().StringVar(&dataCompareType, "data-compare-type", "row-count", "Data comparison type: row-count, row-by-row, sample")
+	compareCmd.Flags().StringVar(&dataCompareType, "data-compare-type", "row-count", "Data comparison type: row-count, row-by-row, sample, aggregate")
 	compareCmd.Flags().IntVar(&sampleSize, "sample-size", 100, "Number of rows for sample comparison")
+	compareCmd.Flags().StringVar(&compareAggregateDateColumn, "aggregate-date-column", "", "Timestamp or date column that groups rows into days for the aggregate data-compare-type")
+	compareCmd.Flags().StringVar(&compareAggregateSumColumn, "aggregate-sum-column", "", "Numeric column summed per day for the aggregate data-compare-type (optional)")
 	compareCmd.Flags().StringVar(&compareTables, "tables", "", "Comma-separated table names to compare (empty = all tables)")
+	compareCmd.Flags().BoolVar(&compareCSVNoHeader, "csv-no-header", false, "S3 CSV files have no header row (requires --csv-columns or --csv-schema-file)")
+	compareCmd.Flags().StringVar(&compareCSVColumns, "csv-columns", "", "Comma-separated column names for header-less CSV files, in file order")
+	compareCmd.Flags().StringVar(&compareCSVSchemaFile, "csv-schema-file", "", "File listing column names for header-less CSV files, one per line")
+	compareCmd.Flags().StringVar(&compareColumns, "compare-columns", "", "Comma-separated columns to compare (empty = all columns)")
+	compareCmd.Flags().StringVar(&compareIgnoreColumns, "ignore-columns", "", "Comma-separated columns to leave out of schema and data comparison (e.g. updated_at)")
 
 	// Output flags
 	compareCmd.Flags().StringVar(&compareOutputFormat, "output-format", "text", "Output format: text, json")
 	compareCmd.Flags().StringVar(&compareOutputFile, "output-file", "", "Output file path (default: stdout)")
+
+	_ = viper.BindPFlag("compare.csv_no_header", compareCmd.Flags().Lookup("csv-no-header"))
 }
 
 // ComparisonSource represents a source for comparison (database or S3)
@@ -173,9 +213,10 @@ type ColumnTypeMismatch struct {
 
 // DataComparisonResult contains data comparison results
 type DataComparisonResult struct {
-	RowCountDiffs map[string]*RowCountDiff `json:"row_count_diffs,omitempty"`
-	RowByRowDiffs map[string]*RowByRowDiff `json:"row_by_row_diffs,omitempty"`
-	SampleDiffs   map[string]*SampleDiff   `json:"sample_diffs,omitempty"`
+	RowCountDiffs  map[string]*RowCountDiff  `json:"row_count_diffs,omitempty"`
+	RowByRowDiffs  map[string]*RowByRowDiff  `json:"row_by_row_diffs,omitempty"`
+	SampleDiffs    map[string]*SampleDiff    `json:"sample_diffs,omitempty"`
+	AggregateDiffs map[string]*AggregateDiff `json:"aggregate_diffs,omitempty"`
 }
 
 // RowCountDiff contains row count differences
@@ -210,22 +251,32 @@ type Comparer struct {
 	ctx           context.Context
 	db1           *sql.DB
 	db2           *sql.DB
-	s3Client1     *s3.S3
-	s3Client2     *s3.S3
-	s3Downloader1 *s3manager.Downloader
-	s3Downloader2 *s3manager.Downloader
+	s3Client1     StorageBackend
+	s3Client2     StorageBackend
+	s3Downloader1 *rangeDownloader
+	s3Downloader2 *rangeDownloader
+	tunnels       []*sshTunnel
+	progress      *compareProgress  // Periodic progress logs and --progress-file events (nil = off)
+	downloadRate  *bandwidthLimiter // Shared by both sources' downloads (nil = unlimited)
 }
 
 // CompareConfig contains comparison configuration
 type CompareConfig struct {
-	Mode            string // schema-only, data-only, schema-and-data
-	DataCompareType string // row-count, row-by-row, sample
-	SampleSize      int
-	Tables          []string // Empty = all tables
-	OutputFormat    string   // text, json
-	OutputFile      string
-	Debug           bool
-	DryRun          bool
+	Mode                string // schema-only, data-only, schema-and-data
+	DataCompareType     string // row-count, row-by-row, sample, aggregate
+	SampleSize          int
+	AggregateDateColumn string   // Column grouping rows into days (aggregate)
+	AggregateSumColumn  string   // Column summed per day (aggregate, optional)
+	Tables              []string // Empty = all tables
+	OutputFormat        string   // text, json
+	OutputFile          string
+	CSVColumns          []string // Column names for header-less CSV files (nil = use header row)
+	CompareColumns      []string // Columns to compare (empty = all columns)
+	IgnoreColumns       []string // Columns left out of the comparison
+	MaxDownloadRate     int64    // Download bytes per second from S3 sources (0 = unlimited)
+	columns             *columnFilter
+	Debug               bool
+	DryRun              bool
 }
 
 // NewComparer creates a new Comparer instance
@@ -235,6 +286,8 @@ func NewComparer(source1, source2 *ComparisonSource, config *CompareConfig, logg
 		source2: source2,
 		config:  config,
 		logger:  logger,
+
+		downloadRate: newBandwidthLimiter(config.MaxDownloadRate),
 	}
 }
 
@@ -278,6 +331,13 @@ func runCompare(cmd *cobra.Command) {
 			Password: getStringConfig(compareSource1DbPassword, "source1-db-password", "compare.source1.db.password"),
 			Name:     getStringConfig(compareSource1DbName, "source1-db-name", "compare.source1.db.name"),
 			SSLMode:  getStringConfig(compareSource1DbSSLMode, "source1-db-sslmode", "compare.source1.db.sslmode"),
+			SSHTunnel: SSHTunnelConfig{
+				Host:           getStringConfig(compareSource1SSHHost, "source1-ssh-host", "compare.source1.ssh.host"),
+				Port:           getIntConfig(compareSource1SSHPort, "source1-ssh-port", "compare.source1.ssh.port"),
+				User:           getStringConfig(compareSource1SSHUser, "source1-ssh-user", "compare.source1.ssh.user"),
+				KeyPath:        getStringConfig(compareSource1SSHKey, "source1-ssh-key", "compare.source1.ssh.key"),
+				KnownHostsPath: getStringConfig(compareSource1SSHKnownHosts, "source1-ssh-known-hosts", "compare.source1.ssh.known_hosts"),
+			},
 		},
 		S3: S3Config{
 			Endpoint:  getStringConfig(compareSource1S3Endpoint, "source1-s3-endpoint", "compare.source1.s3.endpoint"),
@@ -300,6 +360,13 @@ func runCompare(cmd *cobra.Command) {
 			Password: getStringConfig(compareSource2DbPassword, "source2-db-password", "compare.source2.db.password"),
 			Name:     getStringConfig(compareSource2DbName, "source2-db-name", "compare.source2.db.name"),
 			SSLMode:  getStringConfig(compareSource2DbSSLMode, "source2-db-sslmode", "compare.source2.db.sslmode"),
+			SSHTunnel: SSHTunnelConfig{
+				Host:           getStringConfig(compareSource2SSHHost, "source2-ssh-host", "compare.source2.ssh.host"),
+				Port:           getIntConfig(compareSource2SSHPort, "source2-ssh-port", "compare.source2.ssh.port"),
+				User:           getStringConfig(compareSource2SSHUser, "source2-ssh-user", "compare.source2.ssh.user"),
+				KeyPath:        getStringConfig(compareSource2SSHKey, "source2-ssh-key", "compare.source2.ssh.key"),
+				KnownHostsPath: getStringConfig(compareSource2SSHKnownHosts, "source2-ssh-known-hosts", "compare.source2.ssh.known_hosts"),
+			},
 		},
 		S3: S3Config{
 			Endpoint:  getStringConfig(compareSource2S3Endpoint, "source2-s3-endpoint", "compare.source2.s3.endpoint"),
@@ -313,6 +380,11 @@ func runCompare(cmd *cobra.Command) {
 		SchemaSource: getStringConfig(compareSource2SchemaSource, "source2-schema-source", "compare.source2.schema_source"),
 	}
 
+	// Each source picks its own endpoint profile; neither falls back to s3.endpoint_profile
+	var source1S3Err, source2S3Err error
+	source1.S3, source1S3Err = newS3Config(source1.S3, getStringConfig(compareSource1S3Profile, "source1-s3-endpoint-profile", "compare.source1.s3.endpoint_profile"))
+	source2.S3, source2S3Err = newS3Config(source2.S3, getStringConfig(compareSource2S3Profile, "source2-s3-endpoint-profile", "compare.source2.s3.endpoint_profile"))
+
 	// Parse tables
 	var tables []string
 	if compareTables != "" {
@@ -332,6 +404,16 @@ func runCompare(cmd *cobra.Command) {
 		Debug:           viper.GetBool("debug"),
 		DryRun:          viper.GetBool("dry_run"),
 	}
+	config.AggregateDateColumn = getStringConfig(compareAggregateDateColumn, "aggregate-date-column", "compare.aggregate_date_column")
+	config.AggregateSumColumn = getStringConfig(compareAggregateSumColumn, "aggregate-sum-column", "compare.aggregate_sum_column")
+	csvColumns, csvErr := resolveCSVColumns(viper.GetBool("compare.csv_no_header"),
+		getStringConfig(compareCSVColumns, "csv-columns", "compare.csv_columns"),
+		getStringConfig(compareCSVSchemaFile, "csv-schema-file", "compare.csv_schema_file"))
+	config.CSVColumns = csvColumns
+	config.CompareColumns = parseTableList([]string{getStringConfig(compareColumns, "compare-columns", "compare.compare_columns")})
+	config.IgnoreColumns = parseTableList([]string{getStringConfig(compareIgnoreColumns, "ignore-columns", "compare.ignore_columns")})
+	var rateErr error
+	config.MaxDownloadRate, rateErr = parseByteRate(getStringConfig(compareMaxRate, "max-download-rate", "compare.max_download_rate"))
 
 	// Initialize logger
 	initLogger(config.Debug, viper.GetString("log_format"))
@@ -344,10 +426,26 @@ func runCompare(cmd *cobra.Command) {
 	printCompareConfig(source1, source2, config)
 
 	// Validate configuration
+	if source1S3Err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: source1: %s", source1S3Err.Error()))
+		os.Exit(1)
+	}
+	if source2S3Err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: source2: %s", source2S3Err.Error()))
+		os.Exit(1)
+	}
 	if err := validateCompareConfig(source1, source2, config); err != nil {
 		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
 		os.Exit(1)
 	}
+	if csvErr != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", csvErr.Error()))
+		os.Exit(1)
+	}
+	if rateErr != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", rateErr.Error()))
+		os.Exit(1)
+	}
 
 	ctx := signalContext
 	if ctx == nil {
@@ -356,7 +454,19 @@ func runCompare(cmd *cobra.Command) {
 		defer stop()
 	}
 
+	stopStopFileWatcher := startStopFileWatcher("compare", "")
+	defer stopStopFileWatcher()
+	logStopFileHint("compare")
+
+	events, eventsErr := openProgressEventLog(viper.GetString("compare.progress_file"))
+	if eventsErr != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", eventsErr.Error()))
+		os.Exit(1)
+	}
+	defer events.close()
+
 	comparer := NewComparer(source1, source2, config, logger)
+	comparer.progress = newCompareProgress(logger, events, viper.GetDuration("compare.progress_interval"))
 
 	err := comparer.Run(ctx)
 	if err != nil {
@@ -388,6 +498,9 @@ func printCompareConfig(source1, source2 *ComparisonSource, config *CompareConfi
 		logger.Info(fmt.Sprintf("    User:              %s", maskString(source1.Database.User)))
 		logger.Info(fmt.Sprintf("    Database:          %s", source1.Database.Name))
 		logger.Info(fmt.Sprintf("    SSL Mode:          %s", source1.Database.SSLMode))
+		if source1.Database.SSHTunnel.Enabled() {
+			logger.Info(fmt.Sprintf("    SSH Tunnel:        %s@%s", source1.Database.SSHTunnel.User, source1.Database.SSHTunnel.address()))
+		}
 	} else {
 		logger.Info(fmt.Sprintf("    Endpoint:          %s", source1.S3.Endpoint))
 		logger.Info(fmt.Sprintf("    Bucket:            %s", source1.S3.Bucket))
@@ -411,6 +524,9 @@ func printCompareConfig(source1, source2 *ComparisonSource, config *CompareConfi
 		logger.Info(fmt.Sprintf("    User:              %s", maskString(source2.Database.User)))
 		logger.Info(fmt.Sprintf("    Database:          %s", source2.Database.Name))
 		logger.Info(fmt.Sprintf("    SSL Mode:          %s", source2.Database.SSLMode))
+		if source2.Database.SSHTunnel.Enabled() {
+			logger.Info(fmt.Sprintf("    SSH Tunnel:        %s@%s", source2.Database.SSHTunnel.User, source2.Database.SSHTunnel.address()))
+		}
 	} else {
 		logger.Info(fmt.Sprintf("    Endpoint:          %s", source2.S3.Endpoint))
 		logger.Info(fmt.Sprintf("    Bucket:            %s", source2.S3.Bucket))
@@ -433,12 +549,27 @@ func printCompareConfig(source1, source2 *ComparisonSource, config *CompareConfi
 		if config.DataCompareType == "sample" {
 			logger.Info(fmt.Sprintf("    Sample Size:       %d", config.SampleSize))
 		}
+		if config.DataCompareType == "aggregate" {
+			logger.Info(fmt.Sprintf("    Date Column:       %s", config.AggregateDateColumn))
+			if config.AggregateSumColumn != "" {
+				logger.Info(fmt.Sprintf("    Sum Column:        %s", config.AggregateSumColumn))
+			}
+		}
 	}
 	if len(config.Tables) > 0 {
 		logger.Info(fmt.Sprintf("    Tables:            %s", strings.Join(config.Tables, ", ")))
 	} else {
 		logger.Info("    Tables:            (all tables)")
 	}
+	if len(config.CSVColumns) > 0 {
+		logger.Info(fmt.Sprintf("    CSV Columns:       %s (no header)", strings.Join(config.CSVColumns, ", ")))
+	}
+	if len(config.CompareColumns) > 0 {
+		logger.Info(fmt.Sprintf("    Compare Columns:   %s", strings.Join(config.CompareColumns, ", ")))
+	}
+	if len(config.IgnoreColumns) > 0 {
+		logger.Info(fmt.Sprintf("    Ignore Columns:    %s", strings.Join(config.IgnoreColumns, ", ")))
+	}
 
 	// Output configuration
 	logger.Info("  Output:")
@@ -479,6 +610,9 @@ func validateCompareConfig(source1, source2 *ComparisonSource, config *CompareCo
 		if source1.Database.Name == "" {
 			return errors.New("source1-db-name is required when source1-type is db")
 		}
+		if err := source1.Database.SSHTunnel.Validate(); err != nil {
+			return fmt.Errorf("source1: %w", err)
+		}
 	}
 	if source2.Type == "db" {
 		if source2.Database.User == "" {
@@ -487,6 +621,9 @@ func validateCompareConfig(source1, source2 *ComparisonSource, config *CompareCo
 		if source2.Database.Name == "" {
 			return errors.New("source2-db-name is required when source2-type is db")
 		}
+		if err := source2.Database.SSHTunnel.Validate(); err != nil {
+			return fmt.Errorf("source2: %w", err)
+		}
 	}
 
 	if source1.Type == "s3" {
@@ -518,6 +655,20 @@ func validateCompareConfig(source1, source2 *ComparisonSource, config *CompareCo
 		}
 	}
 
+	for i, source := range []*ComparisonSource{source1, source2} {
+		if source.Type != "s3" {
+			continue
+		}
+		for _, path := range []string{source.SchemaPath, source.DataPath} {
+			if _, err := parseTemplate("path template", path, pathTemplatePlaceholders); err != nil {
+				return fmt.Errorf("source%d: %w", i+1, err)
+			}
+		}
+		if err := source.S3.HTTP.Validate(); err != nil {
+			return fmt.Errorf("source%d: %w", i+1, err)
+		}
+	}
+
 	validModes := map[string]bool{
 		"schema-only":     true,
 		"data-only":       true,
@@ -533,14 +684,23 @@ func validateCompareConfig(source1, source2 *ComparisonSource, config *CompareCo
 			"row-count":  true,
 			"row-by-row": true,
 			"sample":     true,
+			"aggregate":  true,
 		}
 		if !validDataCompareTypes[config.DataCompareType] {
-			return fmt.Errorf("invalid data-compare-type: %s (must be row-count, row-by-row, or sample)", config.DataCompareType)
+			return fmt.Errorf("invalid data-compare-type: %s (must be row-count, row-by-row, sample, or aggregate)", config.DataCompareType)
 		}
 
 		if config.DataCompareType == "sample" && config.SampleSize < 1 {
 			return errors.New("sample-size must be at least 1")
 		}
+		if config.DataCompareType == "aggregate" {
+			if config.AggregateDateColumn == "" {
+				return ErrAggregateDateColumnRequired
+			}
+			if source1.Type != "db" && source2.Type != "db" {
+				return ErrAggregateNeedsDatabase
+			}
+		}
 	}
 
 	validOutputFormats := map[string]bool{
@@ -551,18 +711,26 @@ func validateCompareConfig(source1, source2 *ComparisonSource, config *CompareCo
 		return fmt.Errorf("invalid output-format: %s (must be text or json)", config.OutputFormat)
 	}
 
+	columns, err := newColumnFilter(config.CompareColumns, config.IgnoreColumns)
+	if err != nil {
+		return err
+	}
+	config.columns = columns
+
 	return nil
 }
 
 // Run executes the comparison
-func (c *Comparer) Run(ctx context.Context) error {
+func (c *Comparer) Run(ctx context.Context) (err error) {
 	c.ctx = ctx
+	c.progress.start()
+	defer func() { c.progress.finish(err) }()
 
 	// Connect to sources
+	defer c.cleanup()
 	if err := c.connect(ctx); err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
-	defer c.cleanup()
 
 	result := &ComparisonResult{}
 
@@ -624,12 +792,21 @@ func (c *Comparer) connectDatabase(ctx context.Context, source *ComparisonSource
 		sslMode = "disable"
 	}
 
+	// Route through an SSH tunnel when the database is only reachable via a jump host
+	tunnel, host, port, err := openDatabaseTunnel(ctx, source.Database)
+	if err != nil {
+		return fmt.Errorf("failed to open SSH tunnel: %w", err)
+	}
+	if tunnel != nil {
+		c.tunnels = append(c.tunnels, tunnel)
+	}
+
 	// Build connection string
 	// Note: lib/pq handles password escaping internally, so we don't need URL encoding
 	// Set search_path to ensure we can find tables in the public schema
 	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s search_path=public",
-		source.Database.Host,
-		source.Database.Port,
+		host,
+		port,
 		source.Database.User,
 		source.Database.Password,
 		source.Database.Name,
@@ -685,19 +862,16 @@ func (c *Comparer) connectDatabase(ctx context.Context, source *ComparisonSource
 }
 
 // connectS3 connects to S3
-func (c *Comparer) connectS3(source *ComparisonSource, client **s3.S3, downloader **s3manager.Downloader) error {
-	sess, err := session.NewSession(&aws.Config{
-		Endpoint:         aws.String(source.S3.Endpoint),
-		Region:           aws.String(source.S3.Region),
-		Credentials:      credentials.NewStaticCredentials(source.S3.AccessKey, source.S3.SecretKey, ""),
-		S3ForcePathStyle: aws.Bool(true),
-	})
+func (c *Comparer) connectS3(source *ComparisonSource, client *StorageBackend, downloader **rangeDownloader) error {
+	s3Client, err := newStorageClient(source.S3)
 	if err != nil {
-		return fmt.Errorf("failed to create S3 session: %w", err)
+		return fmt.Errorf("failed to create storage client: %w", err)
 	}
 
-	*client = s3.New(sess)
-	*downloader = s3manager.NewDownloader(sess)
+	*client = s3Client
+	// Downloads are checked against the S3 ETag and retried when corrupt
+	*downloader = newRangeDownloader(*client, source.S3.Bucket, "", defaultDownloadPartSizeMB, defaultDownloadRetries, c.logger)
+	(*downloader).rate = c.downloadRate
 	return nil
 }
 
@@ -709,10 +883,15 @@ func (c *Comparer) cleanup() {
 	if c.db2 != nil {
 		c.db2.Close()
 	}
+	for _, tunnel := range c.tunnels {
+		tunnel.Close()
+	}
 }
 
 // compareSchemas compares schemas between sources
 func (c *Comparer) compareSchemas(ctx context.Context) (*SchemaComparisonResult, error) {
+	c.progress.startPhase("schemas", 0)
+
 	// Extract schemas from both sources
 	schemas1, err := c.extractSchemas(ctx, c.source1)
 	if err != nil {
@@ -1110,8 +1289,8 @@ func (c *Comparer) getTableSchema(ctx context.Context, db *sql.DB, tableName str
 
 // extractSchemasFromS3 extracts schemas from S3
 func (c *Comparer) extractSchemasFromS3(ctx context.Context, source *ComparisonSource) (map[string]*TableSchema, error) {
-	var client *s3.S3
-	var downloader *s3manager.Downloader
+	var client StorageBackend
+	var downloader *rangeDownloader
 	if source == c.source1 {
 		client = c.s3Client1
 		downloader = c.s3Downloader1
@@ -1154,7 +1333,7 @@ func (c *Comparer) extractSchemasFromS3(ctx context.Context, source *ComparisonS
 }
 
 // extractSchemasFromPgDump extracts schemas from pg_dump files in S3
-func (c *Comparer) extractSchemasFromPgDump(ctx context.Context, source *ComparisonSource, client *s3.S3, downloader *s3manager.Downloader) (map[string]*TableSchema, error) {
+func (c *Comparer) extractSchemasFromPgDump(ctx context.Context, source *ComparisonSource, client StorageBackend, downloader *rangeDownloader) (map[string]*TableSchema, error) {
 	schemaPath := source.SchemaPath
 	if schemaPath == "" {
 		schemaPath = source.S3.PathTemplate
@@ -1167,24 +1346,20 @@ func (c *Comparer) extractSchemasFromPgDump(ctx context.Context, source *Compari
 	}
 
 	// Look for schema dump files
-	listInput := &s3.ListObjectsV2Input{
-		Bucket: aws.String(source.S3.Bucket),
-		Prefix: aws.String(prefix),
-	}
-
-	result, err := client.ListObjectsV2WithContext(ctx, listInput)
+	var keys []string
+	err := client.List(ctx, source.S3.Bucket, prefix, func(object ObjectInfo) bool {
+		if strings.Contains(object.Key, "schema") && strings.HasSuffix(object.Key, ".dump") {
+			keys = append(keys, object.Key)
+		}
+		return true
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list S3 objects: %w", err)
 	}
 
 	schemas := make(map[string]*TableSchema)
 
-	for _, obj := range result.Contents {
-		key := aws.StringValue(obj.Key)
-		if !strings.Contains(key, "schema") || !strings.HasSuffix(key, ".dump") {
-			continue
-		}
-
+	for _, key := range keys {
 		// Download and parse pg_dump file
 		schema, err := c.parsePgDumpSchema(ctx, source, key, downloader)
 		if err != nil {
@@ -1201,27 +1376,16 @@ func (c *Comparer) extractSchemasFromPgDump(ctx context.Context, source *Compari
 }
 
 // parsePgDumpSchema parses a pg_dump schema file
-func (c *Comparer) parsePgDumpSchema(ctx context.Context, source *ComparisonSource, key string, downloader *s3manager.Downloader) (*TableSchema, error) {
-	// Download file to temp location
-	tempFile, err := os.CreateTemp("", "pg_dump-*.tmp")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %w", err)
-	}
-	defer os.Remove(tempFile.Name())
-	defer tempFile.Close()
-
-	_, err = downloader.DownloadWithContext(ctx, tempFile, &s3.GetObjectInput{
-		Bucket: aws.String(source.S3.Bucket),
-		Key:    aws.String(key),
-	})
+func (c *Comparer) parsePgDumpSchema(ctx context.Context, source *ComparisonSource, key string, downloader *rangeDownloader) (*TableSchema, error) {
+	// Download file, verified against its S3 ETag
+	tempPath, _, err := downloader.Download(ctx, key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to download file: %w", err)
 	}
-
-	tempFile.Close()
+	defer os.Remove(tempPath)
 
 	// Use pg_restore -l to list schema objects
-	cmd := exec.CommandContext(ctx, "pg_restore", "-l", tempFile.Name())
+	cmd := exec.CommandContext(ctx, "pg_restore", "-l", tempPath)
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("failed to run pg_restore -l: %w", err)
@@ -1231,7 +1395,7 @@ func (c *Comparer) parsePgDumpSchema(ctx context.Context, source *ComparisonSour
 	// This is a simplified parser - pg_restore -l outputs lines like:
 	// "1234; 1259 16384 TABLE public table_name user"
 	// We'll extract table names and then use pg_restore to get DDL
-	return c.parsePgRestoreList(string(output), tempFile.Name())
+	return c.parsePgRestoreList(string(output), tempPath)
 }
 
 // parsePgRestoreList parses pg_restore -l output
@@ -1243,7 +1407,7 @@ func (c *Comparer) parsePgRestoreList(_ string, _ string) (*TableSchema, error)
 }
 
 // extractSchemasFromDataFiles extracts schemas by inferring from data files
-func (c *Comparer) extractSchemasFromDataFiles(ctx context.Context, source *ComparisonSource, client *s3.S3, downloader *s3manager.Downloader) (map[string]*TableSchema, error) {
+func (c *Comparer) extractSchemasFromDataFiles(ctx context.Context, source *ComparisonSource, client StorageBackend, downloader *rangeDownloader) (map[string]*TableSchema, error) {
 	dataPath := source.DataPath
 	if dataPath == "" {
 		dataPath = source.S3.PathTemplate
@@ -1285,12 +1449,15 @@ func (c *Comparer) extractSchemasFromDataFiles(ctx context.Context, source *Comp
 
 	// Infer schema from first file of each table
 	schemas := make(map[string]*TableSchema)
+	inferred := 0
 	for tableName, files := range tableFiles {
 		if len(files) == 0 {
 			continue
 		}
 
 		// Use first file to infer schema
+		inferred++
+		c.progress.startFile(files[0].Key, inferred, len(tableFiles))
 		schema, err := c.inferSchemaFromS3File(ctx, source, files[0], downloader, tableName)
 		if err != nil {
 			c.logger.Warn(fmt.Sprintf("Failed to infer schema for table %s: %v", tableName, err))
@@ -1304,64 +1471,50 @@ func (c *Comparer) extractSchemasFromDataFiles(ctx context.Context, source *Comp
 }
 
 // discoverS3DataFiles discovers data files in S3
-func (c *Comparer) discoverS3DataFiles(ctx context.Context, source *ComparisonSource, client *s3.S3, dataPath string) ([]S3File, error) {
-	// Remove date placeholders for listing
-	listPrefix := regexp.MustCompile(`\{YYYY\}|\{MM\}|\{DD\}|\{HH\}|\{table\}`).ReplaceAllString(dataPath, "")
+func (c *Comparer) discoverS3DataFiles(ctx context.Context, source *ComparisonSource, client StorageBackend, dataPath string) ([]S3File, error) {
+	// List from the static part of the path, up to its first placeholder
+	listPrefix := dataPath
+	if i := strings.Index(listPrefix, "{"); i >= 0 {
+		listPrefix = listPrefix[:i]
+	}
 	listPrefix = regexp.MustCompile(`/+`).ReplaceAllString(listPrefix, "/")
 	listPrefix = strings.TrimSuffix(listPrefix, "/")
 
 	var files []S3File
-	var continuationToken *string
+	err := client.List(ctx, source.S3.Bucket, listPrefix, func(obj ObjectInfo) bool {
+		key := obj.Key
+		if strings.HasSuffix(key, "/") {
+			return true
+		}
 
-	for {
-		listInput := &s3.ListObjectsV2Input{
-			Bucket:            aws.String(source.S3.Bucket),
-			Prefix:            aws.String(listPrefix),
-			ContinuationToken: continuationToken,
+		// Skip schema files
+		if strings.Contains(key, "schema") && strings.HasSuffix(key, ".dump") {
+			return true
 		}
 
-		result, err := client.ListObjectsV2WithContext(ctx, listInput)
+		filename := filepath.Base(key)
+		format, compression, err := detectFormatAndCompression(filename, "", "")
 		if err != nil {
-			return nil, fmt.Errorf("failed to list S3 objects: %w", err)
+			return true
 		}
 
-		for _, obj := range result.Contents {
-			key := aws.StringValue(obj.Key)
-			if strings.HasSuffix(key, "/") {
-				continue
-			}
-
-			// Skip schema files
-			if strings.Contains(key, "schema") && strings.HasSuffix(key, ".dump") {
-				continue
-			}
-
-			filename := filepath.Base(key)
-			format, compression, err := detectFormatAndCompression(filename, "", "")
-			if err != nil {
-				continue
-			}
-
-			fileDate, ok := extractDateFromFilename(filename)
-			if !ok || fileDate.IsZero() {
-				fileDate = aws.TimeValue(obj.LastModified)
-			}
-			_ = time.Time{} // Ensure time package is used (S3File.Date is time.Time)
-
-			files = append(files, S3File{
-				Key:                 key,
-				Size:                aws.Int64Value(obj.Size),
-				LastModified:        aws.TimeValue(obj.LastModified),
-				DetectedFormat:      format,
-				DetectedCompression: compression,
-				Date:                fileDate,
-			})
+		fileDate, ok := extractDateFromFilename(filename)
+		if !ok || fileDate.IsZero() {
+			fileDate = obj.LastModified
 		}
 
-		if !aws.BoolValue(result.IsTruncated) {
-			break
-		}
-		continuationToken = result.NextContinuationToken
+		files = append(files, S3File{
+			Key:                 key,
+			Size:                obj.Size,
+			LastModified:        obj.LastModified,
+			DetectedFormat:      format,
+			DetectedCompression: compression,
+			Date:                fileDate,
+		})
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list S3 objects: %w", err)
 	}
 
 	return files, nil
@@ -1392,6 +1545,8 @@ func (c *Comparer) extractTableNameFromPath(key, pathTemplate string) string {
 	filename = strings.TrimSuffix(filename, ".zst")
 	filename = strings.TrimSuffix(filename, ".lz4")
 	filename = strings.TrimSuffix(filename, ".gz")
+	filename = strings.TrimSuffix(filename, ".br")
+	filename = strings.TrimSuffix(filename, ".xz")
 	filename = strings.TrimSuffix(filename, ".jsonl")
 	filename = strings.TrimSuffix(filename, ".csv")
 	filename = strings.TrimSuffix(filename, ".parquet")
@@ -1404,31 +1559,23 @@ func (c *Comparer) extractTableNameFromPath(key, pathTemplate string) string {
 }
 
 // inferSchemaFromS3File infers schema from an S3 data file
-func (c *Comparer) inferSchemaFromS3File(ctx context.Context, source *ComparisonSource, file S3File, downloader *s3manager.Downloader, tableName string) (*TableSchema, error) {
-	// Download file
-	tempFile, err := os.CreateTemp("", "compare-*.tmp")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %w", err)
-	}
-	defer os.Remove(tempFile.Name())
-	defer tempFile.Close()
-
-	_, err = downloader.DownloadWithContext(ctx, tempFile, &s3.GetObjectInput{
-		Bucket: aws.String(source.S3.Bucket),
-		Key:    aws.String(file.Key),
-	})
+func (c *Comparer) inferSchemaFromS3File(ctx context.Context, source *ComparisonSource, file S3File, downloader *rangeDownloader, tableName string) (*TableSchema, error) {
+	// Download file, verified against its S3 ETag
+	tempPath, _, err := downloader.Download(ctx, file.Key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to download file: %w", err)
 	}
-
-	tempFile.Close()
+	defer os.Remove(tempPath)
 
 	// Reopen for reading
-	fileReader, err := os.Open(tempFile.Name())
+	fileReader, err := os.Open(tempPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open temp file: %w", err)
 	}
 	defer fileReader.Close()
+	if err := downloader.loadZstdDictionary(ctx, file.Key, fileReader); err != nil {
+		return nil, err
+	}
 
 	// Decompress
 	compressor, err := compressors.GetCompressor(file.DetectedCompression)
@@ -1452,7 +1599,7 @@ func (c *Comparer) inferSchemaFromS3File(ctx context.Context, source *Comparison
 			return nil, fmt.Errorf("failed to read JSONL: %w", err)
 		}
 	case "csv":
-		reader, err := formatters.NewCSVReaderWithCloser(decompressedReader)
+		reader, err := newCSVReader(decompressedReader, c.config.CSVColumns)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create CSV reader: %w", err)
 		}
@@ -1555,25 +1702,29 @@ func (c *Comparer) compareTableSchemas(schema1, schema2 *TableSchema) *TableSche
 		TypeMismatches:       []ColumnTypeMismatch{},
 	}
 
+	// Ignored and unselected columns are not compared
+	columns1 := c.config.columns.filterColumns(schema1.Columns)
+	columns2 := c.config.columns.filterColumns(schema2.Columns)
+
 	// Special case: if one table has no columns and the other has columns, this is a difference
-	if len(schema1.Columns) == 0 && len(schema2.Columns) > 0 {
+	if len(columns1) == 0 && len(columns2) > 0 {
 		// Source1 has no columns, source2 has columns - all source2 columns are differences
-		diff.ColumnsOnlyInSource2 = schema2.Columns
+		diff.ColumnsOnlyInSource2 = columns2
 		return diff
 	}
-	if len(schema1.Columns) > 0 && len(schema2.Columns) == 0 {
+	if len(columns1) > 0 && len(columns2) == 0 {
 		// Source1 has columns, source2 has no columns - all source1 columns are differences
-		diff.ColumnsOnlyInSource1 = schema1.Columns
+		diff.ColumnsOnlyInSource1 = columns1
 		return diff
 	}
 
 	// Build column maps
 	colMap1 := make(map[string]ColumnInfo)
 	colMap2 := make(map[string]ColumnInfo)
-	for _, col := range schema1.Columns {
+	for _, col := range columns1 {
 		colMap1[col.Name] = col
 	}
-	for _, col := range schema2.Columns {
+	for _, col := range columns2 {
 		colMap2[col.Name] = col
 	}
 
@@ -1617,9 +1768,10 @@ func (c *Comparer) compareTableSchemas(schema1, schema2 *TableSchema) *TableSche
 // compareData compares data between sources
 func (c *Comparer) compareData(ctx context.Context) (*DataComparisonResult, error) {
 	result := &DataComparisonResult{
-		RowCountDiffs: make(map[string]*RowCountDiff),
-		RowByRowDiffs: make(map[string]*RowByRowDiff),
-		SampleDiffs:   make(map[string]*SampleDiff),
+		RowCountDiffs:  make(map[string]*RowCountDiff),
+		RowByRowDiffs:  make(map[string]*RowByRowDiff),
+		SampleDiffs:    make(map[string]*SampleDiff),
+		AggregateDiffs: make(map[string]*AggregateDiff),
 	}
 
 	// Get list of tables to compare
@@ -1644,40 +1796,46 @@ func (c *Comparer) compareData(ctx context.Context) (*DataComparisonResult, erro
 	}
 
 	// Compare each table
+	c.progress.startPhase("data", len(tables))
 	for _, tableName := range tables {
 		c.logger.Info(fmt.Sprintf("Comparing data for table: %s", tableName))
+		c.progress.startTable(tableName)
 
+		var err error
 		switch c.config.DataCompareType {
 		case "row-count":
-			diff, err := c.compareRowCounts(ctx, tableName)
-			if err != nil {
+			var diff *RowCountDiff
+			if diff, err = c.compareRowCounts(ctx, tableName); err != nil {
 				c.logger.Warn(fmt.Sprintf("Failed to compare row counts for %s: %v", tableName, err))
-				continue
-			}
-			if diff != nil {
+			} else if diff != nil {
 				result.RowCountDiffs[tableName] = diff
 			}
 
 		case "row-by-row":
-			diff, err := c.compareRowByRow(ctx, tableName)
-			if err != nil {
+			var diff *RowByRowDiff
+			if diff, err = c.compareRowByRow(ctx, tableName); err != nil {
 				c.logger.Warn(fmt.Sprintf("Failed to compare row-by-row for %s: %v", tableName, err))
-				continue
-			}
-			if diff != nil {
+			} else if diff != nil {
 				result.RowByRowDiffs[tableName] = diff
 			}
 
 		case "sample":
-			diff, err := c.compareSamples(ctx, tableName)
-			if err != nil {
+			var diff *SampleDiff
+			if diff, err = c.compareSamples(ctx, tableName); err != nil {
 				c.logger.Warn(fmt.Sprintf("Failed to compare samples for %s: %v", tableName, err))
-				continue
-			}
-			if diff != nil {
+			} else if diff != nil {
 				result.SampleDiffs[tableName] = diff
 			}
+
+		case "aggregate":
+			var diff *AggregateDiff
+			if diff, err = c.compareAggregates(ctx, tableName); err != nil {
+				c.logger.Warn(fmt.Sprintf("Failed to compare aggregates for %s: %v", tableName, err))
+			} else if diff != nil {
+				result.AggregateDiffs[tableName] = diff
+			}
 		}
+		c.progress.finishTable(tableName, err)
 	}
 
 	return result, nil
@@ -1737,7 +1895,7 @@ func (c *Comparer) getTablesToCompare(ctx context.Context) ([]string, error) {
 
 // listTablesFromS3 lists tables from S3 by discovering data files
 func (c *Comparer) listTablesFromS3(ctx context.Context, source *ComparisonSource) ([]string, error) {
-	var client *s3.S3
+	var client StorageBackend
 	if source == c.source1 {
 		client = c.s3Client1
 	} else {
@@ -1825,8 +1983,8 @@ func (c *Comparer) getRowCountFromDatabase(ctx context.Context, db *sql.DB, tabl
 
 // getRowCountFromS3 gets row count from S3 data files
 func (c *Comparer) getRowCountFromS3(ctx context.Context, source *ComparisonSource, tableName string) (int64, error) {
-	var client *s3.S3
-	var downloader *s3manager.Downloader
+	var client StorageBackend
+	var downloader *rangeDownloader
 	if source == c.source1 {
 		client = c.s3Client1
 		downloader = c.s3Downloader1
@@ -1841,7 +1999,7 @@ func (c *Comparer) getRowCountFromS3(ctx context.Context, source *ComparisonSour
 	}
 
 	// Replace {table} placeholder
-	dataPath = strings.ReplaceAll(dataPath, "{table}", tableName)
+	dataPath = strings.ReplaceAll(dataPath, "{table}", objectKeyComponent(tableName))
 
 	// Discover files for this table
 	files, err := c.discoverS3DataFiles(ctx, source, client, dataPath)
@@ -1850,7 +2008,8 @@ func (c *Comparer) getRowCountFromS3(ctx context.Context, source *ComparisonSour
 	}
 
 	var totalCount int64
-	for _, file := range files {
+	for i, file := range files {
+		c.progress.startFile(file.Key, i+1, len(files))
 		count, err := c.countRowsInS3File(ctx, source, file, downloader)
 		if err != nil {
 			c.logger.Warn(fmt.Sprintf("Failed to count rows in %s: %v", file.Key, err))
@@ -1863,31 +2022,23 @@ func (c *Comparer) getRowCountFromS3(ctx context.Context, source *ComparisonSour
 }
 
 // countRowsInS3File counts rows in an S3 data file
-func (c *Comparer) countRowsInS3File(ctx context.Context, source *ComparisonSource, file S3File, downloader *s3manager.Downloader) (int64, error) {
-	// Download file
-	tempFile, err := os.CreateTemp("", "compare-count-*.tmp")
-	if err != nil {
-		return 0, fmt.Errorf("failed to create temp file: %w", err)
-	}
-	defer os.Remove(tempFile.Name())
-	defer tempFile.Close()
-
-	_, err = downloader.DownloadWithContext(ctx, tempFile, &s3.GetObjectInput{
-		Bucket: aws.String(source.S3.Bucket),
-		Key:    aws.String(file.Key),
-	})
+func (c *Comparer) countRowsInS3File(ctx context.Context, source *ComparisonSource, file S3File, downloader *rangeDownloader) (int64, error) {
+	// Download file, verified against its S3 ETag
+	tempPath, _, err := downloader.Download(ctx, file.Key)
 	if err != nil {
 		return 0, fmt.Errorf("failed to download file: %w", err)
 	}
-
-	tempFile.Close()
+	defer os.Remove(tempPath)
 
 	// Reopen for reading
-	fileReader, err := os.Open(tempFile.Name())
+	fileReader, err := os.Open(tempPath)
 	if err != nil {
 		return 0, fmt.Errorf("failed to open temp file: %w", err)
 	}
 	defer fileReader.Close()
+	if err := downloader.loadZstdDictionary(ctx, file.Key, fileReader); err != nil {
+		return 0, err
+	}
 
 	// Decompress
 	compressor, err := compressors.GetCompressor(file.DetectedCompression)
@@ -1920,7 +2071,7 @@ func (c *Comparer) countRowsInS3File(ctx context.Context, source *ComparisonSour
 			}
 		}
 	case "csv":
-		reader, err := formatters.NewCSVReaderWithCloser(decompressedReader)
+		reader, err := newCSVReader(decompressedReader, c.config.CSVColumns)
 		if err != nil {
 			return 0, fmt.Errorf("failed to create CSV reader: %w", err)
 		}
@@ -2016,7 +2167,7 @@ func (c *Comparer) getRowHashes(ctx context.Context, source *ComparisonSource, t
 			return nil, err
 		}
 
-		for _, row := range rows {
+		for _, row := range c.config.columns.filterRows(rows) {
 			hash := hashRow(row)
 			hashes[hash] = true
 		}
@@ -2026,7 +2177,7 @@ func (c *Comparer) getRowHashes(ctx context.Context, source *ComparisonSource, t
 			return nil, err
 		}
 
-		for _, row := range rows {
+		for _, row := range c.config.columns.filterRows(rows) {
 			hash := hashRow(row)
 			hashes[hash] = true
 		}
@@ -2085,8 +2236,8 @@ func (c *Comparer) getRowsFromDatabase(ctx context.Context, db *sql.DB, tableNam
 
 // getRowsFromS3 gets all rows from S3 data files
 func (c *Comparer) getRowsFromS3(ctx context.Context, source *ComparisonSource, tableName string) ([]map[string]interface{}, error) {
-	var client *s3.S3
-	var downloader *s3manager.Downloader
+	var client StorageBackend
+	var downloader *rangeDownloader
 	if source == c.source1 {
 		client = c.s3Client1
 		downloader = c.s3Downloader1
@@ -2101,7 +2252,7 @@ func (c *Comparer) getRowsFromS3(ctx context.Context, source *ComparisonSource,
 	}
 
 	// Replace {table} placeholder
-	dataPath = strings.ReplaceAll(dataPath, "{table}", tableName)
+	dataPath = strings.ReplaceAll(dataPath, "{table}", objectKeyComponent(tableName))
 
 	// Discover files for this table
 	files, err := c.discoverS3DataFiles(ctx, source, client, dataPath)
@@ -2110,7 +2261,8 @@ func (c *Comparer) getRowsFromS3(ctx context.Context, source *ComparisonSource,
 	}
 
 	var allRows []map[string]interface{}
-	for _, file := range files {
+	for i, file := range files {
+		c.progress.startFile(file.Key, i+1, len(files))
 		rows, err := c.readRowsFromS3File(ctx, source, file, downloader)
 		if err != nil {
 			c.logger.Warn(fmt.Sprintf("Failed to read rows from %s: %v", file.Key, err))
@@ -2123,31 +2275,23 @@ func (c *Comparer) getRowsFromS3(ctx context.Context, source *ComparisonSource,
 }
 
 // readRowsFromS3File reads all rows from an S3 data file
-func (c *Comparer) readRowsFromS3File(ctx context.Context, source *ComparisonSource, file S3File, downloader *s3manager.Downloader) ([]map[string]interface{}, error) {
-	// Download file
-	tempFile, err := os.CreateTemp("", "compare-read-*.tmp")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %w", err)
-	}
-	defer os.Remove(tempFile.Name())
-	defer tempFile.Close()
-
-	_, err = downloader.DownloadWithContext(ctx, tempFile, &s3.GetObjectInput{
-		Bucket: aws.String(source.S3.Bucket),
-		Key:    aws.String(file.Key),
-	})
+func (c *Comparer) readRowsFromS3File(ctx context.Context, source *ComparisonSource, file S3File, downloader *rangeDownloader) ([]map[string]interface{}, error) {
+	// Download file, verified against its S3 ETag
+	tempPath, _, err := downloader.Download(ctx, file.Key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to download file: %w", err)
 	}
-
-	tempFile.Close()
+	defer os.Remove(tempPath)
 
 	// Reopen for reading
-	fileReader, err := os.Open(tempFile.Name())
+	fileReader, err := os.Open(tempPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open temp file: %w", err)
 	}
 	defer fileReader.Close()
+	if err := downloader.loadZstdDictionary(ctx, file.Key, fileReader); err != nil {
+		return nil, err
+	}
 
 	// Decompress
 	compressor, err := compressors.GetCompressor(file.DetectedCompression)
@@ -2171,7 +2315,7 @@ func (c *Comparer) readRowsFromS3File(ctx context.Context, source *ComparisonSou
 			return nil, fmt.Errorf("failed to read JSONL: %w", err)
 		}
 	case "csv":
-		reader, err := formatters.NewCSVReaderWithCloser(decompressedReader)
+		reader, err := newCSVReader(decompressedReader, c.config.CSVColumns)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create CSV reader: %w", err)
 		}
@@ -2262,9 +2406,9 @@ func (c *Comparer) getSampleRows(ctx context.Context, source *ComparisonSource,
 
 	// Take first N rows
 	if len(allRows) > sampleSize {
-		return allRows[:sampleSize], nil
+		allRows = allRows[:sampleSize]
 	}
-	return allRows, nil
+	return c.config.columns.filterRows(allRows), nil
 }
 
 // rowsEqual checks if two rows are equal
@@ -2434,10 +2578,25 @@ func (c *Comparer) outputTextFormat(result *ComparisonResult, w io.Writer) error
 			}
 		}
 
+		// Aggregate differences
+		if len(result.Data.AggregateDiffs) > 0 {
+			fmt.Fprintf(w, "\n⚠️  Aggregate Differences:\n")
+			for tableName, diff := range result.Data.AggregateDiffs {
+				fmt.Fprintf(w, "  Table: %s\n", tableName)
+				fmt.Fprintf(w, "    Matching days: %d of %d\n", diff.MatchingDays, diff.Days)
+				fmt.Fprintf(w, "    Mismatched days:\n")
+				for _, day := range diff.MismatchedDays {
+					fmt.Fprintf(w, "      • %s: source1 %s, source2 %s\n", day.Day, day.Source1, day.Source2)
+				}
+				fmt.Fprintf(w, "\n")
+			}
+		}
+
 		// Check if no differences
 		hasDifferences := len(result.Data.RowCountDiffs) > 0 ||
 			len(result.Data.RowByRowDiffs) > 0 ||
-			len(result.Data.SampleDiffs) > 0
+			len(result.Data.SampleDiffs) > 0 ||
+			len(result.Data.AggregateDiffs) > 0
 
 		if !hasDifferences {
 			fmt.Fprintf(w, "✅ No data differences found\n")
@@ -0,0 +1,468 @@
+package cmd
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Static errors for aggregate data comparison
+var (
+	ErrAggregateDateColumnRequired = errors.New("--aggregate-date-column is required for the aggregate data-compare-type")
+	ErrAggregateNeedsDatabase      = errors.New("the aggregate data-compare-type needs at least one db source to compute aggregates on")
+	ErrAggregateColumnMissing      = errors.New("aggregate column not found in table")
+	ErrAggregateDateColumnType     = errors.New("aggregate date column must be a timestamp, timestamptz, or date column")
+	ErrAggregateSumColumnType      = errors.New("aggregate sum column must be an integer, float, or numeric column")
+)
+
+// aggregateHashModulus keeps the summed 60-bit row fingerprints within range
+// in both SQL (numeric) and Go (uint64)
+const aggregateHashModulus = uint64(1) << 60
+
+// aggregateNull stands in for NULL in a row fingerprint
+const aggregateNull = "(null)"
+
+// AggregateDiff lists the days whose aggregates differ between the sources
+type AggregateDiff struct {
+	Days           int                `json:"days"` // Days present in either source
+	MatchingDays   int                `json:"matching_days"`
+	HashColumns    []string           `json:"hash_columns"`
+	MismatchedDays []AggregateDayDiff `json:"mismatched_days"`
+}
+
+// AggregateDayDiff is one day whose aggregates differ
+type AggregateDayDiff struct {
+	Day     string        `json:"day"`
+	Source1 *DayAggregate `json:"source1,omitempty"` // nil = no rows on this day
+	Source2 *DayAggregate `json:"source2,omitempty"`
+}
+
+// DayAggregate is the fingerprint of one day of rows
+type DayAggregate struct {
+	Rows int64   `json:"rows"`
+	Sum  float64 `json:"sum,omitempty"`
+	Hash string  `json:"hash"` // Sum of the row fingerprints modulo 2^60, in hex
+}
+
+func (d *DayAggregate) String() string {
+	if d == nil {
+		return "no rows"
+	}
+	return fmt.Sprintf("%d rows, sum %g, hash %s", d.Rows, d.Sum, d.Hash)
+}
+
+// dayAggregate accumulates a DayAggregate
+type dayAggregate struct {
+	rows int64
+	sum  float64
+	hash uint64
+}
+
+// aggregateTypeClass groups PostgreSQL types by how their values are
+// fingerprinted. Types outside these classes (floats, numeric, json, uuid,
+// bytea, arrays) don't have a text form that survives every output format,
+// so they're left out of the row fingerprint.
+func aggregateTypeClass(udtName string) string {
+	switch udtName {
+	case "int2", "int4", "int8":
+		return "integer"
+	case "text", "varchar", "bpchar", "char", "name", "citext":
+		return "text"
+	case "bool":
+		return "bool"
+	case "timestamp", "timestamptz", "date":
+		return "time"
+	case "float4", "float8", "numeric":
+		return "number"
+	default:
+		return ""
+	}
+}
+
+// aggregatePlan describes the per-day aggregates of one table
+type aggregatePlan struct {
+	dateColumn  ColumnInfo
+	sumColumn   *ColumnInfo  // nil = no sum
+	hashColumns []ColumnInfo // Sorted by name
+}
+
+// newAggregatePlan picks the date, sum, and fingerprint columns from schema
+func newAggregatePlan(schema *TableSchema, dateColumn, sumColumn string, filter *columnFilter) (*aggregatePlan, error) {
+	plan := &aggregatePlan{}
+	var foundDate bool
+	for _, col := range schema.Columns {
+		switch col.Name {
+		case dateColumn:
+			if aggregateTypeClass(col.UDTName) != "time" {
+				return nil, fmt.Errorf("%w, got '%s' (%s)", ErrAggregateDateColumnType, col.Name, col.UDTName)
+			}
+			plan.dateColumn = col
+			foundDate = true
+		case sumColumn:
+			if class := aggregateTypeClass(col.UDTName); class != "integer" && class != "number" {
+				return nil, fmt.Errorf("%w, got '%s' (%s)", ErrAggregateSumColumnType, col.Name, col.UDTName)
+			}
+			sum := col
+			plan.sumColumn = &sum
+		}
+	}
+	if !foundDate {
+		return nil, fmt.Errorf("%w: '%s'", ErrAggregateColumnMissing, dateColumn)
+	}
+	if sumColumn != "" && plan.sumColumn == nil {
+		return nil, fmt.Errorf("%w: '%s'", ErrAggregateColumnMissing, sumColumn)
+	}
+
+	for _, col := range filter.filterColumns(schema.Columns) {
+		if class := aggregateTypeClass(col.UDTName); class != "" && class != "number" {
+			plan.hashColumns = append(plan.hashColumns, col)
+		}
+	}
+	sort.Slice(plan.hashColumns, func(i, j int) bool { return plan.hashColumns[i].Name < plan.hashColumns[j].Name })
+	return plan, nil
+}
+
+// hashColumnNames returns the names of the fingerprinted columns
+func (p *aggregatePlan) hashColumnNames() []string {
+	names := make([]string, len(p.hashColumns))
+	for i, col := range p.hashColumns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+// sqlValue renders the SQL expression producing col's fingerprint text, the
+// same text aggregateValue produces from an archived value
+func sqlValue(col ColumnInfo) string {
+	quoted := pq.QuoteIdentifier(col.Name)
+	var expr string
+	switch aggregateTypeClass(col.UDTName) {
+	case "bool":
+		expr = fmt.Sprintf("CASE WHEN %s THEN 't' ELSE 'f' END", quoted)
+	case "time":
+		expr = fmt.Sprintf("floor(extract(epoch FROM %s))::bigint::text", quoted)
+	default:
+		expr = quoted + "::text"
+	}
+	return fmt.Sprintf("COALESCE(%s, %s)", expr, pq.QuoteLiteral(aggregateNull))
+}
+
+// sqlDay renders the SQL expression for a row's UTC day
+func sqlDay(col ColumnInfo) string {
+	quoted := pq.QuoteIdentifier(col.Name)
+	if col.UDTName == "timestamptz" {
+		quoted += " AT TIME ZONE 'UTC'"
+	}
+	return fmt.Sprintf("COALESCE(to_char(%s, 'YYYY-MM-DD'), %s)", quoted, pq.QuoteLiteral(aggregateNull))
+}
+
+// query renders the SELECT computing the per-day aggregates of table
+func (p *aggregatePlan) query(table string) string {
+	fingerprint := "''"
+	if len(p.hashColumns) > 0 {
+		parts := make([]string, len(p.hashColumns))
+		for i, col := range p.hashColumns {
+			parts[i] = fmt.Sprintf("%s || %s", pq.QuoteLiteral(col.Name+"="), sqlValue(col))
+		}
+		fingerprint = strings.Join(parts, " || '|' || ")
+	}
+	sum := "0::float8"
+	if p.sumColumn != nil {
+		sum = fmt.Sprintf("COALESCE(sum(%s)::float8, 0)", pq.QuoteIdentifier(p.sumColumn.Name))
+	}
+	day := sqlDay(p.dateColumn)
+
+	//nolint:gosec // G201: identifiers are quoted via pq.QuoteIdentifier
+	return fmt.Sprintf(`SELECT %s AS day, count(*), %s,
+		(COALESCE(sum(('x' || substr(md5(%s), 1, 15))::bit(60)::bigint::numeric), 0) %% %d)::bigint
+		FROM %s GROUP BY 1 ORDER BY 1`, day, sum, fingerprint, aggregateHashModulus, pq.QuoteIdentifier(table))
+}
+
+// aggregatesFromDatabase runs the aggregate query on db
+func (p *aggregatePlan) aggregatesFromDatabase(ctx context.Context, db *sql.DB, table string) (map[string]*dayAggregate, error) {
+	rows, err := db.QueryContext(ctx, p.query(table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := make(map[string]*dayAggregate)
+	for rows.Next() {
+		var day string
+		var agg dayAggregate
+		var hash int64
+		if err := rows.Scan(&day, &agg.rows, &agg.sum, &hash); err != nil {
+			return nil, err
+		}
+		agg.hash = uint64(hash) //nolint:gosec // G115: reduced modulo 2^60 in SQL, never negative
+		days[day] = &agg
+	}
+	return days, rows.Err()
+}
+
+// addRows folds archived rows into days
+func (p *aggregatePlan) addRows(days map[string]*dayAggregate, rows []map[string]interface{}) {
+	var b strings.Builder
+	for _, row := range rows {
+		day := aggregateNull
+		if t, ok := aggregateTime(row[p.dateColumn.Name], p.dateColumn.UDTName); ok {
+			day = t.UTC().Format("2006-01-02")
+		} else if row[p.dateColumn.Name] != nil {
+			day = "(invalid)"
+		}
+		agg := days[day]
+		if agg == nil {
+			agg = &dayAggregate{}
+			days[day] = agg
+		}
+		agg.rows++
+		if p.sumColumn != nil {
+			if f, ok := aggregateNumber(row[p.sumColumn.Name]); ok {
+				agg.sum += f
+			}
+		}
+
+		b.Reset()
+		for i, col := range p.hashColumns {
+			if i > 0 {
+				b.WriteByte('|')
+			}
+			b.WriteString(col.Name)
+			b.WriteByte('=')
+			b.WriteString(aggregateValue(row[col.Name], col.UDTName))
+		}
+		agg.hash = (agg.hash + rowFingerprint(b.String())) % aggregateHashModulus
+	}
+}
+
+// rowFingerprint returns the first 60 bits of the md5 of text, like
+// ('x' || substr(md5(text), 1, 15))::bit(60)::bigint
+func rowFingerprint(text string) uint64 {
+	sum := md5.Sum([]byte(text)) //nolint:gosec // MD5 used for fingerprints, not cryptography
+	fingerprint, _ := strconv.ParseUint(hex.EncodeToString(sum[:])[:15], 16, 64)
+	return fingerprint
+}
+
+// aggregateValue renders an archived value the way sqlValue renders it in
+// PostgreSQL. Readers return values in format-specific types: JSONL numbers
+// are float64, CSV values are parsed from text, Parquet timestamps are
+// microseconds and dates are days since the epoch.
+func aggregateValue(value interface{}, udtName string) string {
+	if value == nil {
+		return aggregateNull
+	}
+	switch aggregateTypeClass(udtName) {
+	case "integer":
+		switch v := value.(type) {
+		case float64:
+			return strconv.FormatInt(int64(v), 10)
+		case float32:
+			return strconv.FormatInt(int64(v), 10)
+		case json.Number:
+			return v.String()
+		}
+	case "bool":
+		switch v := value.(type) {
+		case bool:
+			if v {
+				return "t"
+			}
+			return "f"
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil && b {
+				return "t"
+			}
+			return "f"
+		}
+	case "time":
+		if t, ok := aggregateTime(value, udtName); ok {
+			return strconv.FormatInt(t.Unix(), 10)
+		}
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+// aggregateTimeLayouts are the text forms of archived timestamps: JSONL
+// (RFC 3339) and CSV (Go's default time formatting)
+var aggregateTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999 -0700 -0700",
+	"2006-01-02 15:04:05.999999999-07",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// aggregateTime converts an archived timestamp or date value to a time
+func aggregateTime(value interface{}, udtName string) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		for _, layout := range aggregateTimeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, true
+			}
+		}
+	case int64:
+		if udtName == "date" {
+			return time.Unix(v*86400, 0).UTC(), true
+		}
+		return time.UnixMicro(v).UTC(), true // Parquet timestamp
+	case int32:
+		return time.Unix(int64(v)*86400, 0).UTC(), true // Parquet date
+	}
+	return time.Time{}, false
+}
+
+// aggregateNumber converts an archived numeric value to a float
+func aggregateNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// sumsMatch compares per-day sums, allowing for float rounding differences
+// between PostgreSQL and Go
+func sumsMatch(a, b float64) bool {
+	scale := math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+	return math.Abs(a-b) <= 1e-9*scale
+}
+
+// dayAggregateResult converts an accumulator to its reported form
+func dayAggregateResult(agg *dayAggregate) *DayAggregate {
+	if agg == nil {
+		return nil
+	}
+	return &DayAggregate{Rows: agg.rows, Sum: agg.sum, Hash: fmt.Sprintf("%015x", agg.hash)}
+}
+
+// diffAggregates compares two sources' days, returning nil when every day matches
+func diffAggregates(days1, days2 map[string]*dayAggregate, plan *aggregatePlan) *AggregateDiff {
+	daySet := make(map[string]bool, len(days1))
+	for day := range days1 {
+		daySet[day] = true
+	}
+	for day := range days2 {
+		daySet[day] = true
+	}
+	allDays := make([]string, 0, len(daySet))
+	for day := range daySet {
+		allDays = append(allDays, day)
+	}
+	sort.Strings(allDays)
+
+	diff := &AggregateDiff{Days: len(allDays), HashColumns: plan.hashColumnNames(), MismatchedDays: []AggregateDayDiff{}}
+	for _, day := range allDays {
+		agg1, agg2 := days1[day], days2[day]
+		if agg1 != nil && agg2 != nil && agg1.rows == agg2.rows && agg1.hash == agg2.hash && sumsMatch(agg1.sum, agg2.sum) {
+			diff.MatchingDays++
+			continue
+		}
+		diff.MismatchedDays = append(diff.MismatchedDays, AggregateDayDiff{
+			Day:     day,
+			Source1: dayAggregateResult(agg1),
+			Source2: dayAggregateResult(agg2),
+		})
+	}
+	if len(diff.MismatchedDays) == 0 {
+		return nil
+	}
+	return diff
+}
+
+// compareAggregates compares per-day row counts, sums, and row fingerprints.
+// Database sources compute them in SQL; S3 sources compute them file by file
+// so only one file's rows are held in memory.
+func (c *Comparer) compareAggregates(ctx context.Context, tableName string) (*AggregateDiff, error) {
+	db := c.db1
+	if db == nil {
+		db = c.db2
+	}
+	if db == nil {
+		return nil, ErrAggregateNeedsDatabase
+	}
+	schema, err := c.getTableSchema(ctx, db, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schema: %w", err)
+	}
+	plan, err := newAggregatePlan(schema, c.config.AggregateDateColumn, c.config.AggregateSumColumn, c.config.columns)
+	if err != nil {
+		return nil, err
+	}
+
+	days1, err := c.aggregatesFromSource(ctx, c.source1, tableName, plan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate source1: %w", err)
+	}
+	days2, err := c.aggregatesFromSource(ctx, c.source2, tableName, plan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate source2: %w", err)
+	}
+	return diffAggregates(days1, days2, plan), nil
+}
+
+// aggregatesFromSource computes the per-day aggregates of one source
+func (c *Comparer) aggregatesFromSource(ctx context.Context, source *ComparisonSource, tableName string, plan *aggregatePlan) (map[string]*dayAggregate, error) {
+	if source.Type == "db" {
+		db := c.db1
+		if source == c.source2 {
+			db = c.db2
+		}
+		return plan.aggregatesFromDatabase(ctx, db, tableName)
+	}
+
+	client, downloader := c.s3Client1, c.s3Downloader1
+	if source == c.source2 {
+		client, downloader = c.s3Client2, c.s3Downloader2
+	}
+	dataPath := source.DataPath
+	if dataPath == "" {
+		dataPath = source.S3.PathTemplate
+	}
+	dataPath = strings.ReplaceAll(dataPath, "{table}", objectKeyComponent(tableName))
+
+	files, err := c.discoverS3DataFiles(ctx, source, client, dataPath)
+	if err != nil {
+		return nil, err
+	}
+	days := make(map[string]*dayAggregate)
+	for i, file := range files {
+		c.progress.startFile(file.Key, i+1, len(files))
+		rows, err := c.readRowsFromS3File(ctx, source, file, downloader)
+		if err != nil {
+			c.logger.Warn(fmt.Sprintf("Failed to read rows from %s: %v", file.Key, err))
+			continue
+		}
+		plan.addRows(days, rows)
+	}
+	return days, nil
+}
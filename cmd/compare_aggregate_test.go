@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func aggregateTestSchema() *TableSchema {
+	return &TableSchema{
+		TableName: "events",
+		Columns: []ColumnInfo{
+			{Name: "id", DataType: "bigint", UDTName: "int8"},
+			{Name: "name", DataType: "text", UDTName: "text"},
+			{Name: "active", DataType: "boolean", UDTName: "bool"},
+			{Name: "amount", DataType: "numeric", UDTName: "numeric"},
+			{Name: "payload", DataType: "jsonb", UDTName: "jsonb"},
+			{Name: "created_at", DataType: "timestamp with time zone", UDTName: "timestamptz"},
+		},
+	}
+}
+
+func TestNewAggregatePlan(t *testing.T) {
+	plan, err := newAggregatePlan(aggregateTestSchema(), "created_at", "amount", nil)
+	if err != nil {
+		t.Fatalf("newAggregatePlan failed: %v", err)
+	}
+	if got := strings.Join(plan.hashColumnNames(), ","); got != "active,created_at,id,name" {
+		t.Errorf("hash columns = %s", got)
+	}
+
+	query := plan.query("events")
+	for _, want := range []string{
+		`to_char("created_at" AT TIME ZONE 'UTC', 'YYYY-MM-DD')`,
+		`COALESCE(sum("amount")::float8, 0)`,
+		`'active=' || COALESCE(CASE WHEN "active" THEN 't' ELSE 'f' END, '(null)')`,
+		`'created_at=' || COALESCE(floor(extract(epoch FROM "created_at"))::bigint::text, '(null)')`,
+		`'id=' || COALESCE("id"::text, '(null)') || '|' || 'name=' || COALESCE("name"::text, '(null)')`,
+		`% 1152921504606846976`,
+		`FROM "events" GROUP BY 1`,
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query missing %q:\n%s", want, query)
+		}
+	}
+
+	// Ignored columns drop out of the fingerprint
+	filter, _ := newColumnFilter(nil, []string{"name"})
+	plan, _ = newAggregatePlan(aggregateTestSchema(), "created_at", "", filter)
+	if got := strings.Join(plan.hashColumnNames(), ","); got != "active,created_at,id" || plan.sumColumn != nil {
+		t.Errorf("unexpected plan with ignored column: %s, %v", got, plan.sumColumn)
+	}
+
+	if _, err := newAggregatePlan(aggregateTestSchema(), "name", "", nil); !errors.Is(err, ErrAggregateDateColumnType) {
+		t.Errorf("expected ErrAggregateDateColumnType, got %v", err)
+	}
+	if _, err := newAggregatePlan(aggregateTestSchema(), "created_at", "name", nil); !errors.Is(err, ErrAggregateSumColumnType) {
+		t.Errorf("expected ErrAggregateSumColumnType, got %v", err)
+	}
+	if _, err := newAggregatePlan(aggregateTestSchema(), "updated_at", "", nil); !errors.Is(err, ErrAggregateColumnMissing) {
+		t.Errorf("expected ErrAggregateColumnMissing, got %v", err)
+	}
+}
+
+func TestAggregatePlanAddRowsAcrossFormats(t *testing.T) {
+	plan, err := newAggregatePlan(aggregateTestSchema(), "created_at", "amount", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2024, 1, 1, 23, 30, 0, 0, time.FixedZone("", -2*3600))
+
+	// The same row as read back from JSONL, CSV, and Parquet archives
+	rows := map[string]map[string]interface{}{
+		"jsonl":   {"id": float64(42), "name": nil, "active": true, "amount": "12.5", "created_at": created.UTC().Format(time.RFC3339)},
+		"csv":     {"id": int64(42), "name": nil, "active": "true", "amount": float64(12.5), "created_at": created.UTC().String()},
+		"parquet": {"id": int64(42), "name": nil, "active": true, "amount": float64(12.5), "created_at": created.UnixMicro()},
+	}
+	want := rowFingerprint(`active=t|created_at=1704159000|id=42|name=(null)`)
+	for format, row := range rows {
+		days := make(map[string]*dayAggregate)
+		plan.addRows(days, []map[string]interface{}{row})
+		agg := days["2024-01-02"]
+		if agg == nil {
+			t.Errorf("%s: row not counted on its UTC day: %v", format, days)
+			continue
+		}
+		if agg.rows != 1 || agg.sum != 12.5 || agg.hash != want {
+			t.Errorf("%s: aggregate = %+v, want hash %x", format, agg, want)
+		}
+	}
+
+	// Fingerprints sum, so row order doesn't matter
+	days := make(map[string]*dayAggregate)
+	plan.addRows(days, []map[string]interface{}{rows["jsonl"], rows["csv"]})
+	if days["2024-01-02"].hash != (2*want)%aggregateHashModulus {
+		t.Errorf("unexpected summed hash %x", days["2024-01-02"].hash)
+	}
+}
+
+func TestAggregatesFromDatabase(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	plan, err := newAggregatePlan(aggregateTestSchema(), "created_at", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery(`SELECT COALESCE\(to_char\("created_at" AT TIME ZONE 'UTC'`).
+		WillReturnRows(sqlmock.NewRows([]string{"day", "count", "sum", "hash"}).
+			AddRow("2024-01-01", int64(10), float64(0), int64(255)).
+			AddRow("2024-01-02", int64(3), float64(0), int64(16)))
+
+	days, err := plan.aggregatesFromDatabase(context.Background(), db, "events")
+	if err != nil {
+		t.Fatalf("aggregatesFromDatabase failed: %v", err)
+	}
+	if len(days) != 2 || days["2024-01-01"].rows != 10 || days["2024-01-02"].hash != 16 {
+		t.Errorf("unexpected days %+v", days)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestDiffAggregates(t *testing.T) {
+	plan := &aggregatePlan{hashColumns: []ColumnInfo{{Name: "id", UDTName: "int8"}}}
+	days1 := map[string]*dayAggregate{
+		"2024-01-01": {rows: 10, sum: 100.1, hash: 255},
+		"2024-01-02": {rows: 5, sum: 50, hash: 16},
+		"2024-01-03": {rows: 1, hash: 1},
+	}
+	days2 := map[string]*dayAggregate{
+		"2024-01-01": {rows: 10, sum: 100.1 + 1e-12, hash: 255},
+		"2024-01-02": {rows: 5, sum: 50, hash: 17},
+	}
+
+	diff := diffAggregates(days1, days2, plan)
+	if diff == nil {
+		t.Fatal("expected differences")
+	}
+	if diff.Days != 3 || diff.MatchingDays != 1 || len(diff.MismatchedDays) != 2 {
+		t.Fatalf("unexpected diff %+v", diff)
+	}
+	if day := diff.MismatchedDays[0]; day.Day != "2024-01-02" || day.Source1.Hash != "000000000000010" || day.Source2.Hash != "000000000000011" {
+		t.Errorf("unexpected hash mismatch %+v", day)
+	}
+	if day := diff.MismatchedDays[1]; day.Day != "2024-01-03" || day.Source2 != nil || day.Source2.String() != "no rows" {
+		t.Errorf("unexpected missing day %+v", day)
+	}
+
+	if diff := diffAggregates(days1, days1, plan); diff != nil {
+		t.Errorf("expected no diff for identical sources, got %+v", diff)
+	}
+}
+
+func TestValidateCompareConfigAggregate(t *testing.T) {
+	db := &ComparisonSource{Type: "db", Database: DatabaseConfig{User: "u", Name: "d"}}
+	s3 := &ComparisonSource{Type: "s3", S3: S3Config{Endpoint: "e", Bucket: "b", AccessKey: "a", SecretKey: "s"}}
+	config := func(dateColumn string) *CompareConfig {
+		return &CompareConfig{Mode: "data-only", DataCompareType: "aggregate", AggregateDateColumn: dateColumn, OutputFormat: "text"}
+	}
+
+	if err := validateCompareConfig(db, s3, config("created_at")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validateCompareConfig(db, s3, config("")); !errors.Is(err, ErrAggregateDateColumnRequired) {
+		t.Errorf("expected ErrAggregateDateColumnRequired, got %v", err)
+	}
+	if err := validateCompareConfig(s3, s3, config("created_at")); !errors.Is(err, ErrAggregateNeedsDatabase) {
+		t.Errorf("expected ErrAggregateNeedsDatabase, got %v", err)
+	}
+}
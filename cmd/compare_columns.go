@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrCompareColumnsConflict is returned when a column is both selected and ignored
+var ErrCompareColumnsConflict = errors.New("column listed in both --compare-columns and --ignore-columns")
+
+// columnFilter selects the columns a comparison looks at. An empty include set
+// means every column; excluded columns are dropped either way.
+type columnFilter struct {
+	include map[string]bool
+	exclude map[string]bool
+}
+
+// newColumnFilter builds a filter from --compare-columns and --ignore-columns
+func newColumnFilter(compareColumns, ignoreColumns []string) (*columnFilter, error) {
+	filter := &columnFilter{
+		include: make(map[string]bool, len(compareColumns)),
+		exclude: make(map[string]bool, len(ignoreColumns)),
+	}
+	for _, column := range compareColumns {
+		filter.include[column] = true
+	}
+	for _, column := range ignoreColumns {
+		if filter.include[column] {
+			return nil, fmt.Errorf("%w: '%s'", ErrCompareColumnsConflict, column)
+		}
+		filter.exclude[column] = true
+	}
+	return filter, nil
+}
+
+// active reports whether the filter drops any column
+func (f *columnFilter) active() bool {
+	return f != nil && (len(f.include) > 0 || len(f.exclude) > 0)
+}
+
+// includes reports whether column takes part in the comparison
+func (f *columnFilter) includes(column string) bool {
+	if !f.active() {
+		return true
+	}
+	if f.exclude[column] {
+		return false
+	}
+	return len(f.include) == 0 || f.include[column]
+}
+
+// filterColumns returns the schema columns that take part in the comparison
+func (f *columnFilter) filterColumns(columns []ColumnInfo) []ColumnInfo {
+	if !f.active() {
+		return columns
+	}
+	filtered := make([]ColumnInfo, 0, len(columns))
+	for _, col := range columns {
+		if f.includes(col.Name) {
+			filtered = append(filtered, col)
+		}
+	}
+	return filtered
+}
+
+// filterRows drops the columns that don't take part in the comparison from
+// every row, in place
+func (f *columnFilter) filterRows(rows []map[string]interface{}) []map[string]interface{} {
+	if !f.active() {
+		return rows
+	}
+	for _, row := range rows {
+		for column := range row {
+			if !f.includes(column) {
+				delete(row, column)
+			}
+		}
+	}
+	return rows
+}
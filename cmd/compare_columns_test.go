@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestColumnFilterIncludes(t *testing.T) {
+	tests := []struct {
+		name    string
+		compare []string
+		ignore  []string
+		want    map[string]bool
+	}{
+		{"NoFilter", nil, nil, map[string]bool{"id": true, "updated_at": true}},
+		{"Ignore", nil, []string{"updated_at"}, map[string]bool{"id": true, "updated_at": false}},
+		{"Subset", []string{"id"}, nil, map[string]bool{"id": true, "updated_at": false, "name": false}},
+		{"SubsetAndIgnore", []string{"id", "name"}, []string{"updated_at"}, map[string]bool{"id": true, "name": true, "updated_at": false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := newColumnFilter(tt.compare, tt.ignore)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for column, want := range tt.want {
+				if got := filter.includes(column); got != want {
+					t.Errorf("includes(%q) = %v, want %v", column, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestColumnFilterConflict(t *testing.T) {
+	_, err := newColumnFilter([]string{"id", "updated_at"}, []string{"updated_at"})
+	if !errors.Is(err, ErrCompareColumnsConflict) {
+		t.Fatalf("expected ErrCompareColumnsConflict, got %v", err)
+	}
+}
+
+func TestCompareTableSchemasIgnoresColumns(t *testing.T) {
+	schema1 := &TableSchema{Columns: []ColumnInfo{
+		{Name: "id", UDTName: "int8"},
+		{Name: "updated_at", UDTName: "timestamptz"},
+		{Name: "etl_loaded_at", UDTName: "timestamptz"},
+	}}
+	schema2 := &TableSchema{Columns: []ColumnInfo{
+		{Name: "id", UDTName: "int8"},
+		{Name: "updated_at", UDTName: "text"},
+	}}
+
+	unfiltered := &Comparer{config: &CompareConfig{}}
+	if diff := unfiltered.compareTableSchemas(schema1, schema2); diff == nil {
+		t.Fatal("expected differences without a column filter")
+	}
+
+	filter, err := newColumnFilter(nil, []string{"updated_at", "etl_loaded_at"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	filtered := &Comparer{config: &CompareConfig{columns: filter}}
+	if diff := filtered.compareTableSchemas(schema1, schema2); diff != nil {
+		t.Errorf("expected no differences with volatile columns ignored, got %+v", diff)
+	}
+}
+
+func TestColumnFilterRowsMatchIgnoringVolatileColumns(t *testing.T) {
+	row1 := map[string]interface{}{"id": int64(1), "name": "a", "updated_at": "2024-01-01"}
+	row2 := map[string]interface{}{"id": int64(1), "name": "a", "updated_at": "2024-06-01"}
+	if hashRow(row1) == hashRow(row2) {
+		t.Fatal("rows should differ before filtering")
+	}
+
+	filter, err := newColumnFilter(nil, []string{"updated_at"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rows := filter.filterRows([]map[string]interface{}{row1, row2})
+	if hashRow(rows[0]) != hashRow(rows[1]) || !rowsEqual(rows[0], rows[1]) {
+		t.Errorf("rows should match with updated_at ignored: %v vs %v", rows[0], rows[1])
+	}
+
+	subset, err := newColumnFilter([]string{"id"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rows = subset.filterRows([]map[string]interface{}{{"id": int64(2), "name": "b"}})
+	if len(rows[0]) != 1 || rows[0]["id"] != int64(2) {
+		t.Errorf("expected only id to remain, got %v", rows[0])
+	}
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Progress event types written by compare to --progress-file
+const (
+	progressEventTableStart    = "table_start"
+	progressEventTableComplete = "table_complete"
+	progressEventFile          = "file"
+)
+
+// defaultCompareProgressInterval is how often compare logs its progress
+const defaultCompareProgressInterval = 30 * time.Second
+
+func init() {
+	compareCmd.Flags().String("progress-file", "", "append progress events (phases, tables, files, errors) to this file as JSON lines for external dashboards")
+	compareCmd.Flags().Duration("progress-interval", defaultCompareProgressInterval, "how often to log percent complete and the current table and file (0 = never)")
+	_ = viper.BindPFlag("compare.progress_file", compareCmd.Flags().Lookup("progress-file"))
+	_ = viper.BindPFlag("compare.progress_interval", compareCmd.Flags().Lookup("progress-interval"))
+}
+
+// compareProgress tracks how far a comparison has got: the phase, how many of
+// its tables are done, and the table and S3 file being read. It logs that
+// state every interval, so long comparisons show where they are even while a
+// single large file downloads, and writes events to the same --progress-file
+// format as archive runs.
+type compareProgress struct {
+	mu        sync.Mutex
+	logger    *slog.Logger
+	events    *progressEventLog
+	interval  time.Duration
+	now       func() time.Time
+	phase     string
+	total     int
+	done      int
+	failed    int
+	table     string
+	file      string
+	fileIndex int
+	fileCount int
+	started   time.Time // Phase start
+	tableAt   time.Time // Current table start
+	stop      chan struct{}
+	stopped   sync.WaitGroup
+}
+
+// newCompareProgress returns a tracker logging every interval (0 = never)
+func newCompareProgress(logger *slog.Logger, events *progressEventLog, interval time.Duration) *compareProgress {
+	return &compareProgress{logger: logger, events: events, interval: interval, now: time.Now}
+}
+
+// start begins periodic logging and reports the run start
+func (p *compareProgress) start() {
+	if p == nil {
+		return
+	}
+	p.events.emit(progressEvent{Type: progressEventRunStart})
+	if p.interval <= 0 {
+		return
+	}
+	p.stop = make(chan struct{})
+	p.stopped.Add(1)
+	go func() {
+		defer p.stopped.Done()
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.logger.Info(p.status())
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// finish stops periodic logging and reports the run's final status
+func (p *compareProgress) finish(runErr error) {
+	if p == nil {
+		return
+	}
+	if p.stop != nil {
+		close(p.stop)
+		p.stopped.Wait()
+		p.stop = nil
+	}
+	event := progressEvent{Type: progressEventRunEnd, Status: runStatusFromError(runErr)}
+	if runErr != nil {
+		event.Error = runErr.Error()
+	}
+	p.events.emit(event)
+}
+
+// startPhase begins a phase comparing total tables
+func (p *compareProgress) startPhase(phase string, total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.phase, p.total, p.done, p.failed = phase, total, 0, 0
+	p.table, p.file, p.fileIndex, p.fileCount = "", "", 0, 0
+	p.started = p.now()
+	p.mu.Unlock()
+	p.events.emit(progressEvent{Type: progressEventPhase, Phase: phase, Total: int64(total)})
+}
+
+// startTable marks table as the one being compared
+func (p *compareProgress) startTable(table string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.table, p.file, p.fileIndex, p.fileCount = table, "", 0, 0
+	p.tableAt = p.now()
+	event := progressEvent{Type: progressEventTableStart, Phase: p.phase, Table: table, Current: int64(p.done), Total: int64(p.total)}
+	p.mu.Unlock()
+	p.events.emit(event)
+}
+
+// finishTable counts table as done, failed when err is set
+func (p *compareProgress) finishTable(table string, err error) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.done++
+	if err != nil {
+		p.failed++
+	}
+	p.table, p.file, p.fileIndex, p.fileCount = "", "", 0, 0
+	event := progressEvent{
+		Type:       progressEventTableComplete,
+		Phase:      p.phase,
+		Table:      table,
+		Current:    int64(p.done),
+		Total:      int64(p.total),
+		Status:     runStatusCompleted,
+		DurationMs: p.now().Sub(p.tableAt).Milliseconds(),
+	}
+	p.mu.Unlock()
+	if err != nil {
+		event.Status = runStatusFailed
+		event.Error = err.Error()
+	}
+	p.events.emit(event)
+}
+
+// startFile marks the index-th (1-based) of count S3 files as being read
+func (p *compareProgress) startFile(key string, index, count int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.file, p.fileIndex, p.fileCount = key, index, count
+	event := progressEvent{Type: progressEventFile, Phase: p.phase, Table: p.table, S3Key: key, Current: int64(index), Total: int64(count)}
+	p.mu.Unlock()
+	p.events.emit(event)
+}
+
+// status describes the current progress on one line
+func (p *compareProgress) status() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.phase == "" {
+		return "⏳ Comparison starting..."
+	}
+	line := "⏳ Comparing " + p.phase
+	if p.total > 0 {
+		line += fmt.Sprintf(": %d/%d tables (%.1f%%)", p.done, p.total, float64(p.done)*100/float64(p.total))
+	}
+	if p.failed > 0 {
+		line += fmt.Sprintf(", %d failed", p.failed)
+	}
+	if p.table != "" {
+		line += ", current table " + p.table
+	}
+	if p.file != "" {
+		line += fmt.Sprintf(", file %d/%d %s", p.fileIndex, p.fileCount, p.file)
+	}
+	elapsed := p.now().Sub(p.started)
+	line += ", elapsed " + elapsed.Round(time.Second).String()
+	if p.done > 0 && p.done < p.total {
+		remaining := time.Duration(float64(elapsed) / float64(p.done) * float64(p.total-p.done))
+		line += ", about " + remaining.Round(time.Second).String() + " left"
+	}
+	return line
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompareProgressStatus(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	progress := newCompareProgress(newTestLogger(), nil, 0)
+	progress.now = func() time.Time { return now }
+
+	if got := progress.status(); got != "⏳ Comparison starting..." {
+		t.Errorf("status before any phase = %q", got)
+	}
+
+	progress.startPhase("data", 4)
+	progress.startTable("events")
+	progress.finishTable("events", nil)
+	progress.startTable("flights")
+	progress.finishTable("flights", errors.New("boom"))
+	progress.startTable("logs")
+	progress.startFile("logs/2024/logs-2024-01.jsonl", 3, 12)
+	now = now.Add(time.Minute)
+
+	want := "⏳ Comparing data: 2/4 tables (50.0%), 1 failed, current table logs, file 3/12 logs/2024/logs-2024-01.jsonl, elapsed 1m0s, about 1m0s left"
+	if got := progress.status(); got != want {
+		t.Errorf("status = %q\nwant %q", got, want)
+	}
+
+	// Schema inference has no table count
+	progress.startPhase("schemas", 0)
+	progress.startFile("events/2024/events-2024-01.jsonl", 1, 3)
+	if got := progress.status(); !strings.HasPrefix(got, "⏳ Comparing schemas, file 1/3 events/") {
+		t.Errorf("schema status = %q", got)
+	}
+
+	// A nil tracker is a no-op
+	var off *compareProgress
+	off.start()
+	off.startPhase("data", 1)
+	off.startTable("events")
+	off.startFile("key", 1, 1)
+	off.finishTable("events", nil)
+	off.finish(nil)
+}
+
+func TestCompareProgressEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	events, err := openProgressEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress := newCompareProgress(newTestLogger(), events, time.Millisecond)
+	progress.start()
+	progress.startPhase("data", 1)
+	progress.startTable("events")
+	progress.startFile("events/2024/events-2024-01.jsonl", 1, 1)
+	progress.finishTable("events", nil)
+	progress.finish(nil)
+	if err := events.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var types []string
+	var complete progressEvent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event progressEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid event %q: %v", scanner.Text(), err)
+		}
+		types = append(types, event.Type)
+		if event.Type == progressEventTableComplete {
+			complete = event
+		}
+	}
+	want := "run_start,phase,table_start,file,table_complete,run_end"
+	if got := strings.Join(types, ","); got != want {
+		t.Errorf("event types = %s, want %s", got, want)
+	}
+	if complete.Table != "events" || complete.Current != 1 || complete.Total != 1 || complete.Status != runStatusCompleted {
+		t.Errorf("table_complete event = %+v", complete)
+	}
+}
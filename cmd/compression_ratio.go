@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// ErrRatioAnomalyFactorInvalid is returned for a --ratio-anomaly-factor that cannot flag anything
+var ErrRatioAnomalyFactorInvalid = errors.New("ratio anomaly factor must be 0 (off) or > 1")
+
+const (
+	defaultRatioAnomalyFactor = 4.0
+
+	// ratioNormMinSamples files of a table are needed before its norm means anything
+	ratioNormMinSamples = 5
+
+	// ratioMinBytes keeps small files, whose ratio is dominated by headers
+	// and framing, out of the norm and from being flagged
+	ratioMinBytes = 64 * 1024
+)
+
+// Anomaly directions
+const (
+	ratioAnomalyHigh = "high" // Compresses far better than usual: often a file of repeated or empty values
+	ratioAnomalyLow  = "low"  // Compresses far worse than usual: often binary garbage or a cut-off stream
+)
+
+var ratioAnomalyFactor float64
+
+func init() {
+	archiveCmd.Flags().Float64Var(&ratioAnomalyFactor, "ratio-anomaly-factor", defaultRatioAnomalyFactor, "flag files whose compression ratio is this many times above or below the table's median (0 = off)")
+	_ = viper.BindPFlag("ratio_anomaly_factor", archiveCmd.Flags().Lookup("ratio-anomaly-factor"))
+}
+
+// compressionRatio is the uncompressed and compressed size of one archive
+// file. A split archive is measured over all of its parts.
+type compressionRatio struct {
+	S3Key             string  `json:"s3_key"`
+	UncompressedBytes int64   `json:"uncompressed_bytes"`
+	CompressedBytes   int64   `json:"compressed_bytes"`
+	Ratio             float64 `json:"ratio"`
+	Norm              float64 `json:"norm,omitempty"`    // The table's median ratio, when there was one
+	Anomaly           string  `json:"anomaly,omitempty"` // high or low when the ratio is off the norm
+}
+
+func (r compressionRatio) String() string {
+	return fmt.Sprintf("%.1fx (%s → %s)", r.Ratio, formatBytesForSummary(r.UncompressedBytes), formatBytesForSummary(r.CompressedBytes))
+}
+
+// validateRatioAnomalies checks --ratio-anomaly-factor
+func (c *Config) validateRatioAnomalies() error {
+	if c.RatioAnomalyFactor != 0 && c.RatioAnomalyFactor <= 1 {
+		return fmt.Errorf("%w, got %g", ErrRatioAnomalyFactorInvalid, c.RatioAnomalyFactor)
+	}
+	return nil
+}
+
+// ratioNorm holds the compression ratios of a table's archive files, from
+// the cache of earlier runs and the files written so far in this one
+type ratioNorm struct {
+	mu     sync.Mutex
+	factor float64
+	format archiveFormat
+	seeded bool
+	ratios []float64
+}
+
+func newRatioNorm(factor float64, format archiveFormat) *ratioNorm {
+	return &ratioNorm{factor: factor, format: format}
+}
+
+// seed adds the ratios of the files earlier runs wrote in the same format.
+// Manifests stand in for split archives in the cache, and samples hold too
+// few rows, so neither is counted.
+func (n *ratioNorm) seed(cache *PartitionCache) {
+	n.seeded = true
+	if cache == nil {
+		return
+	}
+	for _, entry := range cache.Entries {
+		if !entry.S3Uploaded || entry.RunID == currentRunID || entry.RowLimit != 0 || strings.HasSuffix(entry.S3Key, manifestSuffix) {
+			continue
+		}
+		if entry.Format != "" && (entry.Format != n.format.Format || entry.Compression != n.format.Compression) {
+			continue
+		}
+		if entry.FileSize >= ratioMinBytes && entry.UncompressedSize > 0 {
+			n.ratios = append(n.ratios, float64(entry.UncompressedSize)/float64(entry.FileSize))
+		}
+	}
+}
+
+// median returns the middle ratio, or 0 with too few files for a norm
+func (n *ratioNorm) median() float64 {
+	if len(n.ratios) < ratioNormMinSamples {
+		return 0
+	}
+	sorted := append([]float64(nil), n.ratios...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// observe measures a file against the norm. Flagged files are kept out of
+// the norm, so a run of bad files does not become the new normal.
+func (n *ratioNorm) observe(cache *PartitionCache, key string, uncompressed, compressed int64) compressionRatio {
+	ratio := compressionRatio{S3Key: key, UncompressedBytes: uncompressed, CompressedBytes: compressed}
+	if n == nil || compressed <= 0 || uncompressed <= 0 {
+		return ratio
+	}
+	ratio.Ratio = float64(uncompressed) / float64(compressed)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.seeded {
+		n.seed(cache)
+	}
+	if compressed < ratioMinBytes {
+		return ratio
+	}
+	ratio.Norm = n.median()
+	if ratio.Norm > 0 && n.factor > 0 {
+		switch {
+		case ratio.Ratio > ratio.Norm*n.factor:
+			ratio.Anomaly = ratioAnomalyHigh
+		case ratio.Ratio < ratio.Norm/n.factor:
+			ratio.Anomaly = ratioAnomalyLow
+		}
+	}
+	if ratio.Anomaly == "" {
+		n.ratios = append(n.ratios, ratio.Ratio)
+	}
+	return ratio
+}
+
+// recordRatio measures the file about to be uploaded and notes it on the result
+func (a *Archiver) recordRatio(result *ProcessResult, cache *PartitionCache, key string, uncompressed, compressed int64) {
+	ratio := a.ratios.observe(cache, key, uncompressed, compressed)
+	if ratio.Ratio == 0 {
+		return
+	}
+	result.Ratios = append(result.Ratios, ratio)
+	if ratio.Anomaly != "" {
+		a.logger.Debug(fmt.Sprintf("      ⚠️  %s compressed %.1fx, the table's files usually %.1fx", key, ratio.Ratio, ratio.Norm))
+	}
+}
+
+// summarizeRatios totals the run's files and collects those whose ratio
+// was far off their table's norm
+func summarizeRatios(results []ProcessResult) (compressionRatio, []compressionRatio) {
+	var total compressionRatio
+	var anomalies []compressionRatio
+	for _, result := range results {
+		for _, ratio := range result.Ratios {
+			total.UncompressedBytes += ratio.UncompressedBytes
+			total.CompressedBytes += ratio.CompressedBytes
+			if ratio.Anomaly != "" {
+				anomalies = append(anomalies, ratio)
+			}
+		}
+	}
+	if total.CompressedBytes > 0 {
+		total.Ratio = float64(total.UncompressedBytes) / float64(total.CompressedBytes)
+	}
+	return total, anomalies
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRatioNormFlagsAnomalies(t *testing.T) {
+	format := archiveFormat{Format: "jsonl", Compression: "zstd"}
+	cache := &PartitionCache{Entries: map[string]PartitionCacheEntry{}}
+	for i := 0; i < 4; i++ {
+		cache.Entries[fmt.Sprintf("events_%d", i)] = PartitionCacheEntry{S3Uploaded: true, FileSize: 1 << 20, UncompressedSize: 8 << 20, Format: "jsonl", Compression: "zstd"}
+	}
+	// Other formats, samples, manifests and small files are not part of the norm
+	cache.Entries["csv"] = PartitionCacheEntry{S3Uploaded: true, FileSize: 1 << 20, UncompressedSize: 2 << 20, Format: "csv", Compression: "zstd"}
+	cache.Entries["sample"] = PartitionCacheEntry{S3Uploaded: true, FileSize: 1 << 20, UncompressedSize: 2 << 20, RowLimit: 10}
+	cache.Entries["split"] = PartitionCacheEntry{S3Uploaded: true, S3Key: "events/events.manifest.json", FileSize: 1 << 20, UncompressedSize: 2 << 20}
+	cache.Entries["small"] = PartitionCacheEntry{S3Uploaded: true, FileSize: 100, UncompressedSize: 2000}
+
+	norm := newRatioNorm(defaultRatioAnomalyFactor, format)
+
+	// Four files are too few for a norm, so the fifth is not judged
+	if ratio := norm.observe(cache, "a", 7<<20, 1<<20); ratio.Anomaly != "" || ratio.Norm != 0 || ratio.Ratio != 7 {
+		t.Fatalf("observe() without a norm = %+v", ratio)
+	}
+	if ratio := norm.observe(cache, "b", 9<<20, 1<<20); ratio.Anomaly != "" || ratio.Norm != 8 {
+		t.Errorf("observe() of a normal file = %+v", ratio)
+	}
+	if ratio := norm.observe(cache, "truncated", 1<<20, 1<<20); ratio.Anomaly != ratioAnomalyLow {
+		t.Errorf("observe() of a file that barely compressed = %+v", ratio)
+	}
+	if ratio := norm.observe(cache, "empty", 100<<20, 1<<20); ratio.Anomaly != ratioAnomalyHigh {
+		t.Errorf("observe() of a file that compressed far better = %+v", ratio)
+	}
+	if ratio := norm.observe(cache, "tiny", 100, 100); ratio.Anomaly != "" {
+		t.Errorf("observe() of a small file = %+v", ratio)
+	}
+	if len(norm.ratios) != 6 {
+		t.Errorf("norm holds %d ratios, want the 6 unflagged ones", len(norm.ratios))
+	}
+
+	off := newRatioNorm(0, format)
+	off.ratios = []float64{8, 8, 8, 8, 8}
+	off.seeded = true
+	if ratio := off.observe(nil, "truncated", 1<<20, 1<<20); ratio.Anomaly != "" {
+		t.Errorf("observe() with flagging off = %+v", ratio)
+	}
+}
+
+func TestSummarizeRatios(t *testing.T) {
+	results := []ProcessResult{
+		{Ratios: []compressionRatio{{S3Key: "a", UncompressedBytes: 800, CompressedBytes: 100}}},
+		{Ratios: []compressionRatio{{S3Key: "b", UncompressedBytes: 100, CompressedBytes: 100, Anomaly: ratioAnomalyLow}}},
+		{Skipped: true},
+	}
+	total, anomalies := summarizeRatios(results)
+	if total.Ratio != 4.5 || total.CompressedBytes != 200 {
+		t.Errorf("total = %+v", total)
+	}
+	if len(anomalies) != 1 || anomalies[0].S3Key != "b" {
+		t.Errorf("anomalies = %+v", anomalies)
+	}
+}
+
+func TestValidateRatioAnomalies(t *testing.T) {
+	for factor, want := range map[float64]error{0: nil, 4: nil, 1: ErrRatioAnomalyFactorInvalid, -2: ErrRatioAnomalyFactorInvalid} {
+		config := newTestConfig()
+		config.RatioAnomalyFactor = factor
+		if err := config.validateRatioAnomalies(); !errors.Is(err, want) && (want != nil || err != nil) {
+			t.Errorf("validateRatioAnomalies(%g) = %v, want %v", factor, err, want)
+		}
+	}
+}
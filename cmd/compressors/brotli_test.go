@@ -0,0 +1,16 @@
+package compressors
+
+import "testing"
+
+func TestBrotliCompressor(t *testing.T) {
+	c, err := GetCompressor("brotli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Extension() != ".br" {
+		t.Errorf("Extension() = %q, want .br", c.Extension())
+	}
+
+	// Levels outside Brotli's 0-11 quality range fall back to the default
+	testRoundTrip(t, c, 0, c.DefaultLevel(), 11, -1, 99)
+}
@@ -20,7 +20,7 @@ type Compressor interface {
 	// NewReader creates a streaming decompression reader
 	NewReader(r io.Reader) (io.ReadCloser, error)
 
-	// Extension returns the file extension for this compression (e.g., ".zst", ".lz4", ".gz")
+	// Extension returns the file extension for this compression (e.g., ".zst", ".lz4", ".gz", ".br", ".xz")
 	Extension() string
 
 	// DefaultLevel returns the default compression level
@@ -36,6 +36,10 @@ func GetCompressor(compression string) (Compressor, error) {
 		return NewLZ4Compressor(), nil
 	case "gzip":
 		return NewGzipCompressor(), nil
+	case "brotli":
+		return NewBrotliCompressor(), nil
+	case "xz":
+		return NewXZCompressor(), nil
 	case "none":
 		return NewNoneCompressor(), nil
 	default:
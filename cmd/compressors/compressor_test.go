@@ -0,0 +1,56 @@
+package compressors
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testRoundTrip checks that c decompresses what it compresses, both through
+// Compress and through its streaming writer, at the given levels
+func testRoundTrip(t *testing.T, c Compressor, levels ...int) {
+	t.Helper()
+	data := []byte(strings.Repeat(`{"id": 1, "name": "flight", "origin": "KSFO"}`+"\n", 500))
+
+	for _, level := range levels {
+		compressed, err := c.Compress(data, level)
+		if err != nil {
+			t.Fatalf("Compress(level %d) error = %v", level, err)
+		}
+		if len(compressed) >= len(data) {
+			t.Errorf("Compress(level %d) did not shrink %d bytes, got %d", level, len(data), len(compressed))
+		}
+		if got := decompress(t, c, compressed); !bytes.Equal(got, data) {
+			t.Errorf("Compress(level %d) round trip returned %d bytes, want %d", level, len(got), len(data))
+		}
+
+		var streamed bytes.Buffer
+		writer := c.NewWriter(&streamed, level)
+		for _, chunk := range [][]byte{data[:100], data[100:]} {
+			if _, err := writer.Write(chunk); err != nil {
+				t.Fatalf("NewWriter(level %d) write error = %v", level, err)
+			}
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatalf("NewWriter(level %d) close error = %v", level, err)
+		}
+		if got := decompress(t, c, streamed.Bytes()); !bytes.Equal(got, data) {
+			t.Errorf("NewWriter(level %d) round trip returned %d bytes, want %d", level, len(got), len(data))
+		}
+	}
+}
+
+func decompress(t *testing.T, c Compressor, compressed []byte) []byte {
+	t.Helper()
+	reader, err := c.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read error = %v", err)
+	}
+	return data
+}
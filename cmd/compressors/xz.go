@@ -0,0 +1,82 @@
+package compressors
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/ulikunitz/xz"
+)
+
+// xzDictionarySizes are the LZMA2 dictionary sizes of xz's -1 to -9 presets
+var xzDictionarySizes = [...]int{
+	1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20,
+}
+
+// XZCompressor handles xz (LZMA2) compression
+type XZCompressor struct{}
+
+// NewXZCompressor creates a new xz compressor
+func NewXZCompressor() *XZCompressor {
+	return &XZCompressor{}
+}
+
+// xzWriterConfig maps a compression level (1-9) to the dictionary size of
+// the matching xz preset; larger dictionaries find repeats further apart
+func xzWriterConfig(level int) xz.WriterConfig {
+	if level < 1 || level > len(xzDictionarySizes) {
+		level = 6 // xz's default preset
+	}
+	return xz.WriterConfig{DictCap: xzDictionarySizes[level-1]}
+}
+
+// Compress compresses data using xz
+func (c *XZCompressor) Compress(data []byte, level int) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	writer, err := xzWriterConfig(level).NewWriter(&buffer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create xz writer: %w", err)
+	}
+
+	if _, err := writer.Write(data); err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("failed to compress data: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close xz writer: %w", err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// Extension returns the file extension for xz compression
+func (c *XZCompressor) Extension() string {
+	return ".xz"
+}
+
+// NewWriter creates a streaming xz compression writer
+func (c *XZCompressor) NewWriter(w io.Writer, level int) io.WriteCloser {
+	writer, err := xzWriterConfig(level).NewWriter(w)
+	if err != nil {
+		// The preset configurations are valid, so this shouldn't happen in
+		// practice; fall back to xz's defaults
+		writer, _ = xz.NewWriter(w)
+	}
+	return writer
+}
+
+// DefaultLevel returns the default compression level for xz
+func (c *XZCompressor) DefaultLevel() int {
+	return 6
+}
+
+// NewReader creates a streaming xz decompression reader
+func (c *XZCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	reader, err := xz.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create xz reader: %w", err)
+	}
+	return io.NopCloser(reader), nil
+}
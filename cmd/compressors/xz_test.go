@@ -0,0 +1,28 @@
+package compressors
+
+import "testing"
+
+func TestXZCompressor(t *testing.T) {
+	c, err := GetCompressor("xz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Extension() != ".xz" {
+		t.Errorf("Extension() = %q, want .xz", c.Extension())
+	}
+
+	// Levels outside xz's 1-9 presets fall back to the default
+	testRoundTrip(t, c, 1, c.DefaultLevel(), 9, 0, 99)
+}
+
+func TestXZWriterConfig(t *testing.T) {
+	if got := xzWriterConfig(1).DictCap; got != 1<<20 {
+		t.Errorf("level 1 dictionary = %d, want 1 MiB", got)
+	}
+	if got := xzWriterConfig(9).DictCap; got != 64<<20 {
+		t.Errorf("level 9 dictionary = %d, want 64 MiB", got)
+	}
+	if got, want := xzWriterConfig(0).DictCap, xzWriterConfig(6).DictCap; got != want {
+		t.Errorf("invalid level dictionary = %d, want the default %d", got, want)
+	}
+}
@@ -10,7 +10,9 @@ import (
 
 // ZstdCompressor handles Zstandard compression
 type ZstdCompressor struct {
-	workers int
+	workers    int
+	windowLog  int    // log2 of the match window (0 = chosen by the level)
+	dictionary []byte // Dictionary to compress with (nil = none)
 }
 
 // NewZstdCompressor creates a new Zstandard compressor
@@ -26,26 +28,55 @@ func (c *ZstdCompressor) WithWorkers(workers int) *ZstdCompressor {
 	return c
 }
 
-// Compress compresses data using Zstandard
-func (c *ZstdCompressor) Compress(data []byte, level int) ([]byte, error) {
-	var buffer bytes.Buffer
+// WithWindowLog sets the match window to 2^windowLog bytes, so repeats far
+// apart in large files are still found (long-range mode). Decoding needs as
+// much memory as the window.
+func (c *ZstdCompressor) WithWindowLog(windowLog int) *ZstdCompressor {
+	c.windowLog = windowLog
+	return c
+}
 
-	// Map level to zstd encoder level
-	var encoderLevel zstd.EncoderLevel
+// WithDictionary compresses with a dictionary built by TrainZstdDictionary.
+// Decompression needs the same dictionary, see RegisterZstdDictionary.
+func (c *ZstdCompressor) WithDictionary(dictionary []byte) *ZstdCompressor {
+	c.dictionary = dictionary
+	return c
+}
+
+// zstdEncoderLevel maps a compression level to a zstd encoder level
+func zstdEncoderLevel(level int) zstd.EncoderLevel {
 	switch {
 	case level <= 0:
-		encoderLevel = zstd.SpeedFastest
+		return zstd.SpeedFastest
 	case level <= 3:
-		encoderLevel = zstd.SpeedDefault
+		return zstd.SpeedDefault
 	case level <= 7:
-		encoderLevel = zstd.SpeedBetterCompression
+		return zstd.SpeedBetterCompression
 	default:
-		encoderLevel = zstd.SpeedBestCompression
+		return zstd.SpeedBestCompression
 	}
+}
 
-	encoder, err := zstd.NewWriter(&buffer,
-		zstd.WithEncoderLevel(encoderLevel),
-		zstd.WithEncoderConcurrency(c.workers))
+// newEncoder creates an encoder with the compressor's options
+func (c *ZstdCompressor) newEncoder(w io.Writer, level int) (*zstd.Encoder, error) {
+	options := []zstd.EOption{
+		zstd.WithEncoderLevel(zstdEncoderLevel(level)),
+		zstd.WithEncoderConcurrency(c.workers),
+	}
+	if c.windowLog > 0 {
+		options = append(options, zstd.WithWindowSize(1<<c.windowLog))
+	}
+	if c.dictionary != nil {
+		options = append(options, zstd.WithEncoderDict(c.dictionary))
+	}
+	return zstd.NewWriter(w, options...)
+}
+
+// Compress compresses data using Zstandard
+func (c *ZstdCompressor) Compress(data []byte, level int) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	encoder, err := c.newEncoder(&buffer, level)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
 	}
@@ -69,22 +100,7 @@ func (c *ZstdCompressor) Extension() string {
 
 // NewWriter creates a streaming zstd compression writer
 func (c *ZstdCompressor) NewWriter(w io.Writer, level int) io.WriteCloser {
-	// Map level to zstd encoder level
-	var encoderLevel zstd.EncoderLevel
-	switch {
-	case level <= 0:
-		encoderLevel = zstd.SpeedFastest
-	case level <= 3:
-		encoderLevel = zstd.SpeedDefault
-	case level <= 7:
-		encoderLevel = zstd.SpeedBetterCompression
-	default:
-		encoderLevel = zstd.SpeedBestCompression
-	}
-
-	encoder, err := zstd.NewWriter(w,
-		zstd.WithEncoderLevel(encoderLevel),
-		zstd.WithEncoderConcurrency(c.workers))
+	encoder, err := c.newEncoder(w, level)
 	if err != nil {
 		// Return a no-op writer if we can't create the encoder
 		// This shouldn't happen in practice, but provides a fallback
@@ -98,9 +114,10 @@ func (c *ZstdCompressor) DefaultLevel() int {
 	return 3 // SpeedDefault
 }
 
-// NewReader creates a streaming zstd decompression reader
+// NewReader creates a streaming zstd decompression reader. Frames compressed
+// with a dictionary are decoded with the registered one of the same ID.
 func (c *ZstdCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
-	decoder, err := zstd.NewReader(r)
+	decoder, err := zstd.NewReader(r, zstd.WithDecoderDicts(registeredZstdDictionaries()...))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
 	}
@@ -0,0 +1,218 @@
+package compressors
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Zstandard dictionary training settings
+const (
+	// DefaultZstdDictionarySize matches the zstd CLI's default dictionary size
+	DefaultZstdDictionarySize = 112 * 1024
+
+	// zstdSampleRatio is how much input is sampled per byte of dictionary;
+	// zstd recommends about 100 times the dictionary size
+	zstdSampleRatio = 100
+
+	// zstdTableSampleRatio is how much of the samples, in dictionary sizes,
+	// the dictionary's entropy tables are built from
+	zstdTableSampleRatio = 16
+
+	// zstdMinTrainingRatio is the least input, in dictionary sizes, worth a
+	// dictionary; smaller files are compressed without one
+	zstdMinTrainingRatio = 10
+
+	// minZstdDictionaryID is the first ID outside the range zstd reserves
+	minZstdDictionaryID = 32768
+)
+
+// Static errors for dictionary training
+var (
+	ErrZstdSamplesTooSmall  = errors.New("too little data to train a zstd dictionary")
+	ErrZstdSamplesUntrained = errors.New("samples cannot train a zstd dictionary")
+)
+
+// zstdDictionaries holds the dictionaries decoders may use, by ID
+var zstdDictionaries = struct {
+	mu   sync.Mutex
+	byID map[uint32][]byte
+}{byID: make(map[uint32][]byte)}
+
+// TrainZstdDictionary builds a dictionary of at most size bytes from samples
+// of newline-separated records, tuned for the given compression level. The
+// dictionary's ID is derived from its content, so the same samples always
+// give the same dictionary and the same compressed output.
+func TrainZstdDictionary(samples []byte, size, level int) (dictionary []byte, err error) {
+	var records [][]byte
+	for _, record := range bytes.SplitAfter(samples, []byte("\n")) {
+		if len(record) > 0 {
+			records = append(records, record)
+		}
+	}
+	if len(samples) < size || len(records) == 0 {
+		return nil, fmt.Errorf("%w: %d bytes", ErrZstdSamplesTooSmall, len(samples))
+	}
+
+	// Records spread evenly through the samples make up the dictionary's content
+	step := max(1, len(samples)/size)
+	var history []byte
+	for i := 0; i < len(records); i += step {
+		history = append(history, records[i]...)
+	}
+	if len(history) > size {
+		history = history[len(history)-size:]
+	}
+
+	// Building the entropy tables compresses every record given, so a spread
+	// of them is used
+	contents := records
+	if stride := len(samples) / (size * zstdTableSampleRatio); stride > 1 {
+		contents = nil
+		for i := 0; i < len(records); i += stride {
+			contents = append(contents, records[i])
+		}
+	}
+
+	// BuildDict panics on samples that leave it no literals to build tables
+	// from, such as records the content already holds in full
+	defer func() {
+		if r := recover(); r != nil {
+			dictionary, err = nil, fmt.Errorf("%w: %v", ErrZstdSamplesUntrained, r)
+		}
+	}()
+
+	id := minZstdDictionaryID + crc32.ChecksumIEEE(history)%(1<<31-minZstdDictionaryID)
+	dictionary, err = zstd.BuildDict(zstd.BuildDictOptions{
+		ID:       id,
+		Contents: contents,
+		History:  history,
+		Offsets:  [3]int{1, 4, 8},
+		Level:    zstdEncoderLevel(level),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build zstd dictionary: %w", err)
+	}
+	return dictionary, nil
+}
+
+// ZstdFrameDictionaryID returns the dictionary ID in the frame header that
+// starts header, or 0 for frames without one and for data that isn't zstd
+func ZstdFrameDictionaryID(header []byte) uint32 {
+	var h zstd.Header
+	if err := h.Decode(header); err != nil {
+		return 0
+	}
+	return h.DictionaryID
+}
+
+// RegisterZstdDictionary makes a dictionary available to every zstd decoder
+// created afterwards and returns its ID
+func RegisterZstdDictionary(dictionary []byte) (uint32, error) {
+	info, err := zstd.InspectDictionary(dictionary)
+	if err != nil {
+		return 0, fmt.Errorf("invalid zstd dictionary: %w", err)
+	}
+	zstdDictionaries.mu.Lock()
+	defer zstdDictionaries.mu.Unlock()
+	zstdDictionaries.byID[info.ID()] = dictionary
+	return info.ID(), nil
+}
+
+// HasZstdDictionary reports whether the dictionary with the given ID is registered
+func HasZstdDictionary(id uint32) bool {
+	zstdDictionaries.mu.Lock()
+	defer zstdDictionaries.mu.Unlock()
+	_, ok := zstdDictionaries.byID[id]
+	return ok
+}
+
+func registeredZstdDictionaries() [][]byte {
+	zstdDictionaries.mu.Lock()
+	defer zstdDictionaries.mu.Unlock()
+	dictionaries := make([][]byte, 0, len(zstdDictionaries.byID))
+	for _, dictionary := range zstdDictionaries.byID {
+		dictionaries = append(dictionaries, dictionary)
+	}
+	return dictionaries
+}
+
+// ZstdTrainingWriter compresses a stream with a dictionary trained on its
+// own beginning: it holds back the first 100 dictionary sizes of input,
+// trains on them, then compresses them and everything after with the
+// dictionary. Input too small to train on is compressed without one.
+type ZstdTrainingWriter struct {
+	w              io.Writer
+	level          int
+	compressor     *ZstdCompressor
+	dictionarySize int
+	samples        bytes.Buffer
+	encoder        io.WriteCloser
+	dictionary     []byte
+}
+
+// NewTrainingWriter creates a writer that trains a dictionary of at most
+// dictionarySize bytes on the start of the stream
+func (c *ZstdCompressor) NewTrainingWriter(w io.Writer, level, dictionarySize int) *ZstdTrainingWriter {
+	if dictionarySize <= 0 {
+		dictionarySize = DefaultZstdDictionarySize
+	}
+	return &ZstdTrainingWriter{w: w, level: level, compressor: c, dictionarySize: dictionarySize}
+}
+
+// Write compresses p, or holds it back while the dictionary's samples are collected
+func (t *ZstdTrainingWriter) Write(p []byte) (int, error) {
+	if t.encoder != nil {
+		return t.encoder.Write(p)
+	}
+	t.samples.Write(p)
+	if t.samples.Len() >= t.dictionarySize*zstdSampleRatio {
+		if err := t.start(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// start trains the dictionary on the held-back input and compresses it
+func (t *ZstdTrainingWriter) start() error {
+	compressor := *t.compressor
+	if t.samples.Len() >= t.dictionarySize*zstdMinTrainingRatio {
+		// A dictionary that fails to train only costs compression ratio
+		if dictionary, err := TrainZstdDictionary(t.samples.Bytes(), t.dictionarySize, t.level); err == nil {
+			t.dictionary = dictionary
+			compressor.dictionary = dictionary
+		}
+	}
+	encoder, err := compressor.newEncoder(t.w, t.level)
+	if err != nil {
+		return fmt.Errorf("failed to create zstd encoder: %w", err)
+	}
+	t.encoder = encoder
+	if _, err := encoder.Write(t.samples.Bytes()); err != nil {
+		return fmt.Errorf("failed to compress data: %w", err)
+	}
+	t.samples = bytes.Buffer{}
+	return nil
+}
+
+// Close compresses any held-back input and finishes the stream
+func (t *ZstdTrainingWriter) Close() error {
+	if t.encoder == nil {
+		if err := t.start(); err != nil {
+			return err
+		}
+	}
+	return t.encoder.Close()
+}
+
+// Dictionary returns the dictionary the stream was compressed with, or nil.
+// It is set once the samples are collected, at the latest by Close.
+func (t *ZstdTrainingWriter) Dictionary() []byte {
+	return t.dictionary
+}
@@ -4,7 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/airframesio/data-archiver/cmd/formatters"
 )
 
 // Static errors for configuration validation
@@ -21,7 +26,7 @@ var (
 	ErrS3SecretKeyRequired     = errors.New("S3 secret key is required")
 	ErrS3RegionInvalid         = errors.New("S3 region contains invalid characters or is too long")
 	ErrTableNameRequired       = errors.New("table name is required")
-	ErrTableNameInvalid        = errors.New("table name is invalid: must be 1-63 characters, start with a letter or underscore, and contain only letters, numbers, and underscores")
+	ErrTableNameInvalid        = errors.New("table name is invalid: must be 1-63 bytes, start with a letter or underscore, and contain only letters, digits, underscores, dollar signs, and dots")
 	ErrStartDateFormatInvalid  = errors.New("invalid start date format")
 	ErrEndDateFormatInvalid    = errors.New("invalid end date format")
 	ErrWorkersMinimum          = errors.New("workers must be at least 1")
@@ -30,38 +35,112 @@ var (
 	ErrChunkSizeMaximum        = errors.New("chunk size must not exceed 1000000")
 	ErrPathTemplateRequired    = errors.New("path template is required")
 	ErrPathTemplateInvalid     = errors.New("path template must contain {table} placeholder")
-	ErrOutputDurationInvalid   = errors.New("output duration must be one of: hourly, daily, weekly, monthly, yearly")
+	ErrOutputDurationInvalid   = errors.New("output duration must be one of: hourly, daily, weekly, monthly, quarterly, yearly")
 	ErrOutputFormatInvalid     = errors.New("output format must be one of: jsonl, csv, parquet")
-	ErrCompressionInvalid      = errors.New("compression must be one of: zstd, lz4, gzip, none")
-	ErrCompressionLevelInvalid = errors.New("compression level must be between 1 and 22 (zstd), 1-9 (lz4/gzip)")
+	ErrCompressionInvalid      = errors.New("compression must be one of: zstd, lz4, gzip, brotli, xz, none")
+	ErrCompressionLevelInvalid = errors.New("compression level must be between 1 and 22 (zstd), 1-9 (lz4/gzip/xz), 0-11 (brotli)")
 	ErrDateColumnInvalid       = errors.New("date column is invalid: must start with a letter or underscore, and contain only letters, numbers, and underscores")
 	ErrDumpModeInvalid         = errors.New("dump mode must be one of: schema-only, data-only, schema-and-data")
+	ErrAdaptiveCompressionNone = errors.New("adaptive compression requires a compression type other than none")
+	ErrAdaptiveLevelBand       = errors.New("adaptive compression level band is invalid")
+	ErrAdaptiveCPUTarget       = errors.New("adaptive CPU target must be between 1 and 100")
+	ErrFieldMappingFormat      = errors.New("field mapping is only supported with the jsonl output format")
+	ErrFieldMappingInvalid     = errors.New("field mapping is invalid")
+	ErrOutputTargetInvalid     = errors.New("output must be '-' (stdout) or empty (S3)")
 )
 
 const regionAuto = "auto"
 
 type Config struct {
-	Debug                    bool
-	LogFormat                string
-	DryRun                   bool
-	Workers                  int
-	SkipCount                bool
-	CacheViewer              bool
-	ViewerPort               int
-	ChunkSize                int // Number of rows to process in each chunk (streaming mode)
-	IncludeNonPartitionTables bool // Include regular tables matching partition naming pattern
-	Database                 DatabaseConfig
-	S3                       S3Config
-	Table                    string
-	StartDate                string
-	EndDate                  string
-	OutputDuration           string
-	OutputFormat             string
-	Compression              string
-	CompressionLevel         int
-	DateColumn               string
-	DumpMode                 string // pg_dump mode: schema-only, data-only, schema-and-data
-	CacheScope               CacheScope
+	Debug                     bool
+	LogFormat                 string
+	Quiet                     int    // -q: warnings, errors and the summary only; -qq: nothing
+	Progress                  string // Progress display: tui, plain, none
+	DryRun                    bool
+	Workers                   int
+	SkipCount                 bool
+	CacheViewer               bool
+	ViewerPort                int
+	ChunkSize                 int      // Number of rows to process in each chunk (streaming mode)
+	IncludeNonPartitionTables bool     // Include regular tables matching partition naming pattern
+	IncludeRelationKinds      []string // Also archive matching materialized views and foreign tables: matview, foreign
+	Database                  DatabaseConfig
+	S3                        S3Config
+	Table                     string
+	StartDate                 string
+	EndDate                   string
+	StartFrom                 string // Skip the planned partitions before this one
+	StartFromDate             string // Skip the planned partitions and slices ending on or before this date
+	OutputDuration            string
+	OutputFormat              string
+	Compression               string
+	CompressionLevel          int
+	AdaptiveCompression       bool // Step compression level within [CompressionLevelMin, CompressionLevelMax] based on CPU load
+	CompressionLevelMin       int
+	CompressionLevelMax       int                      // 0 = use CompressionLevel as the ceiling
+	AdaptiveCPUTarget         int                      // Host CPU usage (percent) above which the level is stepped down
+	FieldMapping              *formatters.FieldMapping // JSONL field renaming/flattening (nil = write columns as-is)
+	Tables                    []string                 // Tables archived in one multi-table run (mutually exclusive with Table)
+	TableConcurrency          int                      // Tables archived at once in a multi-table run
+	Quota                     TableQuota               // Resource limits for Table
+	Timezone                  TableTimezone            // Zones Table is sliced and keyed in (zero value = UTC)
+	TableTimezones            map[string]TableTimezone // Per-table zones for multi-table runs (table_timezones)
+	TableQueries              map[string]string        // Custom extraction SELECT per table (table_queries)
+	Output                    string                   // "-" streams a single partition/slice to stdout instead of uploading to S3
+	DateColumn                string
+	DateColumnType            string                    // How DateColumn stores time: timestamp, date, epoch, epoch_ms, text
+	DateColumnFormat          string                    // Go time layout of a text DateColumn
+	SplitColumn               string                    // Integer column slicing tables that are not partitioned into key ranges
+	SplitSize                 int64                     // Keys per range with SplitColumn
+	WatermarkColumn           string                    // Column whose largest archived value each run continues after
+	RatioAnomalyFactor        float64                   // Flag files whose compression ratio is this many times off the table's median (0 = off)
+	TUILogLines               int                       // Events kept for the terminal UI's log pane
+	IncludeSchema             bool                      // Upload a pg_dump --schema-only dump of the table with each run
+	IncludeComments           bool                      // Upload the table and column comments as <table>-schema.json with each run
+	IncludeLargeObjects       bool                      // Upload the large objects oid and lo columns reference as side files
+	SchemaPathTemplate        string                    // S3 path template for schema dumps (empty = path template without dates)
+	CheckPartitionDates       bool                      // Cross-check the date column range of each partition against its name
+	UsageLedger               bool                      // Record uploads per calendar month in a usage ledger in the bucket
+	UsagePrefix               string                    // Bucket prefix for usage ledgers
+	Calendar                  *SkipCalendar             // Days whose slices are skipped without querying (nil = none)
+	FailOnPermissionDenied    bool                      // Fail instead of skipping partitions without SELECT permission
+	InvalidValues             string                    // Policy for invalid UTF-8 and NaN/Inf values: off, replace, quarantine
+	QuarantineDir             string                    // Directory for rows quarantined by InvalidValues (default ~/.data-archiver/quarantine)
+	FormatMigration           string                    // Objects archived in another format: keep, convert, rearchive ("" = stop with a plan)
+	CacheAudit                string                    // Compare the cache with the bucket after the run: off, report, repair
+	RediscoverInterval        time.Duration             // Look for partitions created since discovery this often (0 = discover once)
+	MaxParallelQueries        int                       // Most extraction queries running at once across tables (0 = no limit)
+	IntegrityLedger           bool                      // Append uploaded files to a hash-chained integrity ledger
+	IntegrityPrefix           string                    // Bucket prefix for integrity ledger copies
+	IntegrityKeyFile          string                    // Secret for signing integrity ledger entries ("" = unsigned)
+	RunManifest               bool                      // Write a manifest of the run's uploads to the bucket
+	RunManifestPrefix         string                    // Bucket prefix for run manifests
+	ContentType               string                    // Content-Type of uploaded archive files ("" = from the format)
+	ContentEncoding           string                    // Content-Encoding of uploaded archive files: auto, none, or a literal value
+	MaxUploadRate             int64                     // Upload bytes per second across every table of the run (0 = unlimited)
+	MaxDBRowsPerSec           int64                     // Rows read from the database per second across the run (0 = unlimited)
+	ZstdWindowLog             int                       // log2 of the zstd match window for long-range mode (0 = chosen by the level)
+	ZstdTrainDictionary       bool                      // Compress each file with a zstd dictionary trained on its start
+	ZstdDictionarySizeKB      int                       // Largest trained zstd dictionary
+	Parquet                   formatters.ParquetOptions // Row group, page, codec and encoding settings of Parquet files
+	Masking                   map[string]string         // Column → masking transform applied before rows are written
+	MaskKeyFile               string                    // Secret key of hash masking
+	Filter                    string                    // SQL condition rows must match to be archived
+	SplitByColumn             string                    // Column whose values each get their own archive files
+	SplitMaxWriters           int                       // Most --split-by-column files open at once
+	SoftDeleteDays            int                       // Days deleted archive files stay in the trash (0 = delete immediately)
+	TrashPrefix               string                    // Bucket prefix for soft-deleted archive files
+	IntentPrefix              string                    // Bucket prefix for prune intent records
+	MaxRowsPerFile            int64                     // Split archives into numbered parts of at most this many rows (0 = no split)
+	LimitRowsPerSlice         int64                     // Smoke test: archive at most this many rows per slice (0 = no limit)
+	ExtractMethod             string                    // select or copy ("" = select)
+	HeadConcurrency           int                       // Existence checks run at once for a split partition's slices (0 = inline)
+	RecordSQL                 bool                      // Log and record the extraction SQL of every archive file
+	TableFormat               string                    // Table metadata written after each run: iceberg, delta, or "" (none)
+	TableCatalogPath          string                    // Bucket prefix of the table root ("" = derived from the path template)
+	Hooks                     InvalidationHooksConfig
+	DumpMode                  string // pg_dump mode: schema-only, data-only, schema-and-data
+	CacheScope                CacheScope
 }
 
 type DatabaseConfig struct {
@@ -74,15 +153,29 @@ type DatabaseConfig struct {
 	StatementTimeout int // Statement timeout in seconds (0 = no timeout, default 300)
 	MaxRetries       int // Maximum number of retry attempts for failed queries (default 3)
 	RetryDelay       int // Delay in seconds between retry attempts (default 5)
+	SSHTunnel        SSHTunnelConfig
+
+	// Per-session settings that limit the archiver's load on the server
+	WorkMem            string // work_mem for archiver sessions (empty = server default)
+	MaxParallelWorkers int    // max_parallel_workers_per_gather (-1 = server default)
+	ApplicationName    string // application_name shown in pg_stat_activity
+	QueryComments      bool   // Prefix extraction queries with a table/partition comment
 }
 
 type S3Config struct {
-	Endpoint     string
-	Bucket       string
-	AccessKey    string
-	SecretKey    string
-	Region       string
-	PathTemplate string
+	Endpoint          string
+	Bucket            string
+	AccessKey         string
+	SecretKey         string
+	Region            string
+	Profile           string // AWS shared config profile, used without static keys
+	Backend           string // Storage backend: s3, gcs, azure, or local
+	PathTemplate      string
+	HTTP              S3HTTPConfig
+	PartSizeMB        int               // Multipart upload part size in MB (0 = 5MB)
+	UploadConcurrency int               // Parts of one upload sent at once (0 = s3manager default)
+	Tags              map[string]string // Tags added to uploaded objects, with placeholders
+	Metadata          map[string]string // User metadata added to uploaded objects, with placeholders
 }
 
 // validPostgreSQLIdentifier checks if a string is a valid PostgreSQL identifier
@@ -92,12 +185,21 @@ var validPostgreSQLIdentifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
 // isValidTableName validates that a table name is safe to use in SQL queries
 func isValidTableName(name string) bool {
 	// Check for empty or excessively long names
-	if name == "" || len(name) > 63 {
+	if name == "" || len(name) > maxIdentifierBytes || !utf8.ValidString(name) {
 		return false
 	}
 
-	// Must match PostgreSQL identifier rules
-	return validPostgreSQLIdentifier.MatchString(name)
+	// Names are always quoted in SQL, so mixed case, dots, and non-ASCII
+	// letters are allowed; quotes, whitespace, and separators are not
+	for i, r := range name {
+		if i == 0 && !unicode.IsLetter(r) && r != '_' {
+			return false
+		}
+		if !isTableNameRune(r) {
+			return false
+		}
+	}
+	return true
 }
 
 // isValidRegion validates that an S3 region is reasonable
@@ -129,34 +231,30 @@ func isValidPathTemplate(template string) bool {
 // isValidOutputDuration validates the output duration
 func isValidOutputDuration(duration string) bool {
 	validDurations := map[string]bool{
-		"hourly":  true,
-		"daily":   true,
-		"weekly":  true,
-		"monthly": true,
-		"yearly":  true,
+		"hourly":    true,
+		"daily":     true,
+		"weekly":    true,
+		"monthly":   true,
+		"quarterly": true,
+		"yearly":    true,
 	}
 	return validDurations[duration]
 }
 
+// Output formats and compressions this build supports
+var (
+	supportedOutputFormats = []string{"jsonl", "csv", "parquet"}
+	supportedCompressions  = []string{"zstd", "lz4", "gzip", "brotli", "xz", "none"}
+)
+
 // isValidOutputFormat validates the output format
 func isValidOutputFormat(format string) bool {
-	validFormats := map[string]bool{
-		"jsonl":   true,
-		"csv":     true,
-		"parquet": true,
-	}
-	return validFormats[format]
+	return containsString(supportedOutputFormats, format)
 }
 
 // isValidCompression validates the compression type
 func isValidCompression(compression string) bool {
-	validCompressions := map[string]bool{
-		"zstd": true,
-		"lz4":  true,
-		"gzip": true,
-		"none": true,
-	}
-	return validCompressions[compression]
+	return containsString(supportedCompressions, compression)
 }
 
 // isValidCompressionLevel validates compression level based on compression type
@@ -164,8 +262,10 @@ func isValidCompressionLevel(compression string, level int) bool {
 	switch compression {
 	case "zstd":
 		return level >= 1 && level <= 22
-	case "lz4", "gzip":
+	case "lz4", "gzip", "xz":
 		return level >= 1 && level <= 9
+	case "brotli":
+		return level >= 0 && level <= 11
 	case "none":
 		return level == 0 // no compression, level should be 0
 	default:
@@ -173,6 +273,29 @@ func isValidCompressionLevel(compression string, level int) bool {
 	}
 }
 
+// validateAdaptiveCompression checks the level band and CPU target used by --adaptive-compression
+func (c *Config) validateAdaptiveCompression() error {
+	if c.Compression == "none" {
+		return ErrAdaptiveCompressionNone
+	}
+
+	maxLevel := c.CompressionLevelMax
+	if maxLevel == 0 {
+		maxLevel = c.CompressionLevel
+	}
+	if !isValidCompressionLevel(c.Compression, c.CompressionLevelMin) || !isValidCompressionLevel(c.Compression, maxLevel) {
+		return fmt.Errorf("%w: levels %d-%d are not valid for compression %s", ErrAdaptiveLevelBand, c.CompressionLevelMin, maxLevel, c.Compression)
+	}
+	if c.CompressionLevelMin > maxLevel {
+		return fmt.Errorf("%w: min level %d is greater than max level %d", ErrAdaptiveLevelBand, c.CompressionLevelMin, maxLevel)
+	}
+
+	if c.AdaptiveCPUTarget < 1 || c.AdaptiveCPUTarget > 100 {
+		return fmt.Errorf("%w: got %d", ErrAdaptiveCPUTarget, c.AdaptiveCPUTarget)
+	}
+	return nil
+}
+
 // isValidDumpMode validates the dump mode
 func isValidDumpMode(mode string) bool {
 	validModes := map[string]bool{
@@ -212,18 +335,27 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("%w, got %d", ErrRetryDelayInvalid, c.Database.RetryDelay)
 	}
 
-	// Validate S3 configuration
-	if c.S3.Endpoint == "" {
-		return ErrS3EndpointRequired
+	if err := c.S3.HTTP.Validate(); err != nil {
+		return err
 	}
-	if c.S3.Bucket == "" {
-		return ErrS3BucketRequired
+	if err := c.dateColumnSpec().Validate(); err != nil {
+		return err
 	}
-	if c.S3.AccessKey == "" {
-		return ErrS3AccessKeyRequired
+
+	// Validate output target; streaming to stdout needs no S3 configuration
+	if c.Output != "" && c.Output != StdoutOutput {
+		return fmt.Errorf("%w, got '%s'", ErrOutputTargetInvalid, c.Output)
 	}
-	if c.S3.SecretKey == "" {
-		return ErrS3SecretKeyRequired
+	toStdout := c.Output == StdoutOutput
+
+	// Validate S3 configuration
+	if !toStdout {
+		if c.S3.Bucket == "" {
+			return ErrS3BucketRequired
+		}
+		if err := c.S3.validateCredentials(); err != nil {
+			return err
+		}
 	}
 
 	// Validate S3 region
@@ -274,8 +406,11 @@ func (c *Config) Validate() error {
 		}
 
 		// Validate output duration
-		if !isValidOutputDuration(c.OutputDuration) {
-			return fmt.Errorf("%w: '%s'", ErrOutputDurationInvalid, c.OutputDuration)
+		if err := c.validateOutputDuration(); err != nil {
+			return err
+		}
+		if err := c.validateTimezone(); err != nil {
+			return err
 		}
 
 		// Validate output format
@@ -293,10 +428,150 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("%w for compression %s: got %d", ErrCompressionLevelInvalid, c.Compression, c.CompressionLevel)
 		}
 
+		// Validate adaptive compression band
+		if c.AdaptiveCompression {
+			if err := c.validateAdaptiveCompression(); err != nil {
+				return err
+			}
+		}
+
+		// Validate per-table quota (zero value = defaults)
+		if c.Quota != (TableQuota{}) {
+			if err := c.Quota.validate(); err != nil {
+				return err
+			}
+		}
+
+		// Validate JSONL field mapping
+		if c.FieldMapping != nil {
+			if c.OutputFormat != "jsonl" {
+				return fmt.Errorf("%w, got '%s'", ErrFieldMappingFormat, c.OutputFormat)
+			}
+			if err := validateFieldMapping(c.FieldMapping); err != nil {
+				return err
+			}
+		}
+
 		// Validate date column (if provided, must be valid identifier)
 		if c.DateColumn != "" && !validPostgreSQLIdentifier.MatchString(c.DateColumn) {
 			return fmt.Errorf("%w: '%s'", ErrDateColumnInvalid, c.DateColumn)
 		}
+		if c.CheckPartitionDates && c.DateColumn == "" {
+			return ErrPartitionDateCheckColumn
+		}
+		if c.UsageLedger && strings.Trim(c.UsagePrefix, "/") == "" {
+			return ErrUsagePrefixRequired
+		}
+		if err := validateInvalidValuesPolicy(c.InvalidValues); err != nil {
+			return err
+		}
+		if err := validateFormatMigration(c.FormatMigration); err != nil {
+			return err
+		}
+		if err := validateRelationKinds(c.IncludeRelationKinds); err != nil {
+			return err
+		}
+		if err := validateCacheAudit(c.CacheAudit); err != nil {
+			return err
+		}
+		if err := c.validateStartFrom(); err != nil {
+			return err
+		}
+		if err := c.validateKeyRanges(); err != nil {
+			return err
+		}
+		if err := c.validateWatermark(); err != nil {
+			return err
+		}
+		if err := c.validateRatioAnomalies(); err != nil {
+			return err
+		}
+		if c.TUILogLines < 0 {
+			return fmt.Errorf("%w, got %d", ErrTUILogLinesInvalid, c.TUILogLines)
+		}
+		if err := c.validateSchemaExport(); err != nil {
+			return err
+		}
+		if c.RediscoverInterval < 0 {
+			return fmt.Errorf("%w, got %s", ErrRediscoverIntervalInvalid, c.RediscoverInterval)
+		}
+		if err := c.validateGuardrails(); err != nil {
+			return err
+		}
+		if c.IntegrityLedger {
+			if strings.Trim(c.IntegrityPrefix, "/") == "" {
+				return ErrIntegrityPrefixRequired
+			}
+			if _, err := loadIntegrityKey(c.IntegrityKeyFile); err != nil {
+				return err
+			}
+		}
+		if c.RunManifest && strings.Trim(c.RunManifestPrefix, "/") == "" {
+			return ErrRunManifestPrefixRequired
+		}
+		if err := c.S3.validateUploadTuning(); err != nil {
+			return err
+		}
+		if err := validateObjectLabels(c.S3.Tags, c.S3.Metadata); err != nil {
+			return err
+		}
+		if c.S3.Backend == StorageBackendAzure {
+			if err := validateAzureMetadata(c.S3.Metadata); err != nil {
+				return err
+			}
+		}
+		if err := validateContentHeaders(c.ContentType); err != nil {
+			return err
+		}
+		if err := c.validateRateLimits(); err != nil {
+			return err
+		}
+		if err := c.validateMasking(); err != nil {
+			return err
+		}
+		if err := c.validateFilter(); err != nil {
+			return err
+		}
+		if err := c.validateSplitByColumn(); err != nil {
+			return err
+		}
+		if err := c.validateParquetOptions(); err != nil {
+			return err
+		}
+		if err := c.validateZstdTuning(); err != nil {
+			return err
+		}
+		if c.SoftDeleteDays < 0 {
+			return fmt.Errorf("%w, got %d", ErrSoftDeleteDaysInvalid, c.SoftDeleteDays)
+		}
+		if c.SoftDeleteDays > 0 && strings.Trim(c.TrashPrefix, "/") == "" {
+			return ErrTrashPrefixRequired
+		}
+		if c.MaxRowsPerFile < 0 {
+			return fmt.Errorf("%w, got %d", ErrMaxRowsPerFileInvalid, c.MaxRowsPerFile)
+		}
+		if c.LimitRowsPerSlice < 0 {
+			return fmt.Errorf("%w, got %d", ErrRowLimitInvalid, c.LimitRowsPerSlice)
+		}
+		if c.HeadConcurrency < 0 {
+			return fmt.Errorf("%w, got %d", ErrHeadConcurrencyInvalid, c.HeadConcurrency)
+		}
+		if err := c.validateExtractMethod(); err != nil {
+			return err
+		}
+		if err := c.validateTableFormat(); err != nil {
+			return err
+		}
+		if err := c.Hooks.Validate(); err != nil {
+			return err
+		}
+
+		// Validate the custom extraction query for this table
+		if query := c.customQuery(); query != "" {
+			if err := validateCustomQuery(query); err != nil {
+				return fmt.Errorf("table_queries.%s: %w", c.Table, err)
+			}
+		}
 	}
 
 	// Common validations for both modes
@@ -310,17 +585,22 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("%w, got %d", ErrWorkersMaximum, c.Workers)
 	}
 
-	// Validate path template (required for both modes)
-	if c.S3.PathTemplate == "" {
+	// Validate path template (required for both modes, unless streaming to stdout)
+	if c.S3.PathTemplate == "" && !toStdout {
 		return ErrPathTemplateRequired
 	}
 	// For schema-only mode, {table} placeholder is optional (table name goes in filename)
 	// For other modes, {table} placeholder is required
-	if c.DumpMode != "schema-only" {
+	if c.DumpMode != "schema-only" && c.S3.PathTemplate != "" {
 		if !isValidPathTemplate(c.S3.PathTemplate) {
 			return fmt.Errorf("%w: '%s'", ErrPathTemplateInvalid, c.S3.PathTemplate)
 		}
 	}
+	if c.S3.PathTemplate != "" {
+		if _, err := parseTemplate("path template", c.S3.PathTemplate, pathTemplatePlaceholders); err != nil {
+			return err
+		}
+	}
 
 	// Validate dump mode (if provided)
 	if c.DumpMode != "" && !isValidDumpMode(c.DumpMode) {
@@ -74,16 +74,12 @@ func TestConfigValidation_MissingDatabaseFields(t *testing.T) {
 }
 
 func TestConfigValidation_MissingS3Fields(t *testing.T) {
-	t.Run("MissingS3Endpoint", func(t *testing.T) {
+	t.Run("MissingS3EndpointUsesAWS", func(t *testing.T) {
 		config := newTestConfig()
 		config.S3.Endpoint = "" // Clear the endpoint field
 
-		err := config.Validate()
-		if err == nil {
-			t.Fatal("should return error for missing S3 endpoint")
-		}
-		if err.Error() != "S3 endpoint is required" {
-			t.Fatalf("unexpected error: %v", err)
+		if err := config.Validate(); err != nil {
+			t.Fatalf("missing endpoint should select AWS S3, got %v", err)
 		}
 	})
 
@@ -386,6 +382,11 @@ func TestTableNameValidation(t *testing.T) {
 			"a",
 			"_",
 			"table_with_multiple_underscores",
+			"table.name",
+			"Flights.V2",
+			"événements",
+			"航班_2024",
+			"price$history",
 		}
 
 		for _, name := range validNames {
@@ -404,7 +405,9 @@ func TestTableNameValidation(t *testing.T) {
 			"table;drop",
 			"table'name",
 			"table\"name",
-			"table.name",
+			"table/name",
+			".hidden",
+			"table\tname",
 			string(make([]byte, 64)), // 64 characters - too long
 		}
 
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Content-Encoding settings (--content-encoding) besides a literal value
+const (
+	ContentEncodingAuto = "auto" // gzip or br for gzip- or brotli-compressed files, none otherwise
+	ContentEncodingNone = "none"
+)
+
+// ErrContentTypeInvalid is returned for a --content-type that isn't a media type
+var ErrContentTypeInvalid = errors.New("content type must be a media type such as text/csv")
+
+// compressionEncodings are the Content-Encodings of files compressed with
+// codecs HTTP clients decode transparently
+var compressionEncodings = map[string]string{
+	".gz": "gzip",
+	".br": "br",
+}
+
+// compressionContentTypes are the Content-Types of compressed files sent
+// without a Content-Encoding: those compressed with codecs HTTP clients can't
+// decode transparently, and gzip and brotli files of unknown contents
+var compressionContentTypes = map[string]string{
+	".gz":  "application/gzip",
+	".br":  "application/x-brotli",
+	".zst": "application/zstd",
+	".lz4": "application/x-lz4",
+	".xz":  "application/x-xz",
+}
+
+// formatContentTypes are the Content-Types of uncompressed files by extension
+var formatContentTypes = map[string]string{
+	".jsonl":   "application/x-ndjson",
+	".csv":     "text/csv",
+	".parquet": "application/vnd.apache.parquet",
+	".json":    "application/json",
+	".sql":     "application/sql",
+}
+
+var (
+	contentTypeOverride string
+	contentEncoding     string
+)
+
+func init() {
+	archiveCmd.Flags().StringVar(&contentTypeOverride, "content-type", "", "Content-Type of uploaded archive files (default: from the output format and compression, e.g. application/x-ndjson or text/csv)")
+	archiveCmd.Flags().StringVar(&contentEncoding, "content-encoding", ContentEncodingAuto, "Content-Encoding of uploaded archive files: auto (gzip or br for gzip- or brotli-compressed files), none, or a value to send as is")
+	_ = viper.BindPFlag("content_type", archiveCmd.Flags().Lookup("content-type"))
+	_ = viper.BindPFlag("content_encoding", archiveCmd.Flags().Lookup("content-encoding"))
+}
+
+// validateContentHeaders checks the --content-type override
+func validateContentHeaders(contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+	if _, _, err := mime.ParseMediaType(contentType); err != nil {
+		return fmt.Errorf("%w, got %q", ErrContentTypeInvalid, contentType)
+	}
+	return nil
+}
+
+// resolveContentHeaders returns the Content-Type and Content-Encoding of an
+// object from its key's extensions, and whether it is an archive data file.
+// gzip and brotli files keep the type of their contents with a gzip or br
+// Content-Encoding; zstd, lz4 and xz files are typed as the compressed file,
+// since few clients decode those encodings.
+func resolveContentHeaders(key string) (contentType, encoding string, archive bool) {
+	name := strings.ToLower(path.Base(key))
+	compression := ""
+	for _, ext := range []string{".zst", ".lz4", ".gz", ".br", ".xz"} {
+		if strings.HasSuffix(name, ext) {
+			name = strings.TrimSuffix(name, ext)
+			compression = ext
+			break
+		}
+	}
+	ext := path.Ext(name)
+	archive = ext == ".jsonl" || ext == ".csv" || ext == ".parquet"
+	contentType, ok := formatContentTypes[ext]
+	if !ok {
+		contentType = "application/octet-stream"
+	}
+	if compression == "" {
+		return contentType, "", archive
+	}
+	if encoding, transparent := compressionEncodings[compression]; transparent && ok {
+		return contentType, encoding, archive
+	}
+	return compressionContentTypes[compression], "", archive
+}
+
+// contentHeaders returns the Content-Type and Content-Encoding ("" = none)
+// of the object uploaded to key. --content-type and --content-encoding
+// override them for archive files; manifests and schema dumps keep theirs.
+func (a *Archiver) contentHeaders(key string) (string, string) {
+	contentType, encoding, archive := resolveContentHeaders(key)
+	if archive {
+		if a.config.ContentType != "" {
+			contentType = a.config.ContentType
+		}
+		switch a.config.ContentEncoding {
+		case "", ContentEncodingAuto:
+		case ContentEncodingNone:
+			encoding = ""
+		default:
+			encoding = a.config.ContentEncoding
+		}
+	}
+	return contentType, encoding
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestResolveContentHeaders(t *testing.T) {
+	tests := []struct {
+		key      string
+		wantType string
+		wantEnc  string
+		archive  bool
+	}{
+		{"events/events-2024-01-01.jsonl.zst", "application/zstd", "", true},
+		{"events/events-2024-01-01.jsonl.gz", "application/x-ndjson", "gzip", true},
+		{"events/events-2024-01-01.jsonl", "application/x-ndjson", "", true},
+		{"events/events-2024-01-01.csv.gz", "text/csv", "gzip", true},
+		{"events/events-2024-01-01.csv.lz4", "application/x-lz4", "", true},
+		{"events/events-2024-01-01.parquet", "application/vnd.apache.parquet", "", true},
+		{"events/events-2024-01-01.manifest.json", "application/json", "", false},
+		{"events/events-schema.sql.gz", "application/sql", "gzip", false},
+		{"events/events-schema.dump", "application/octet-stream", "", false},
+		{"events/blob.bin.gz", "application/gzip", "", false},
+		{"events/events-2024-01-01.jsonl.br", "application/x-ndjson", "br", true},
+		{"events/events-2024-01-01.csv.xz", "application/x-xz", "", true},
+		{"events/blob.bin.br", "application/x-brotli", "", false},
+	}
+	for _, tt := range tests {
+		contentType, encoding, archive := resolveContentHeaders(tt.key)
+		if contentType != tt.wantType || encoding != tt.wantEnc || archive != tt.archive {
+			t.Errorf("resolveContentHeaders(%q) = %q, %q, %v, want %q, %q, %v", tt.key, contentType, encoding, archive, tt.wantType, tt.wantEnc, tt.archive)
+		}
+	}
+}
+
+func TestContentHeaderOverrides(t *testing.T) {
+	if err := validateContentHeaders("text/plain; charset=utf-8"); err != nil {
+		t.Errorf("validateContentHeaders() = %v", err)
+	}
+	if err := validateContentHeaders("not a type"); !errors.Is(err, ErrContentTypeInvalid) {
+		t.Errorf("expected ErrContentTypeInvalid, got %v", err)
+	}
+
+	archiver := NewArchiver(&Config{ContentType: "text/plain", ContentEncoding: ContentEncodingNone}, newTestLogger())
+	contentType, encoding := archiver.contentHeaders("events/events.jsonl.gz")
+	if contentType != "text/plain" || encoding != "" {
+		t.Errorf("overridden headers = %q, %q", contentType, encoding)
+	}
+	// Manifests keep their own type
+	if contentType, _ := archiver.contentHeaders("events/events.manifest.json"); contentType != "application/json" {
+		t.Errorf("manifest Content-Type = %q", contentType)
+	}
+	archiver.config.ContentEncoding = "br"
+	if _, encoding := archiver.contentHeaders("events/events.csv"); encoding != "br" {
+		t.Errorf("Content-Encoding = %q, want br", encoding)
+	}
+}
+
+func TestUploadsCarryContentHeaders(t *testing.T) {
+	store := newFakeObjectStore(nil)
+	archiver := NewArchiver(&Config{S3: S3Config{Bucket: "bucket"}}, newTestLogger())
+	archiver.s3Client = newS3ObjectStore(store)
+
+	tempFile := filepath.Join(t.TempDir(), "events.csv.gz")
+	if err := os.WriteFile(tempFile, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.uploadTempFileToS3(tempFile, "events/events.csv.gz"); err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.uploadToS3("events/events.manifest.json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if put := store.puts["events/events.csv.gz"]; aws.StringValue(put.ContentType) != "text/csv" || aws.StringValue(put.ContentEncoding) != "gzip" {
+		t.Errorf("csv.gz headers = %q, %q", aws.StringValue(put.ContentType), aws.StringValue(put.ContentEncoding))
+	}
+	if put := store.puts["events/events.manifest.json"]; aws.StringValue(put.ContentType) != "application/json" || put.ContentEncoding != nil {
+		t.Errorf("manifest headers = %q, %v", aws.StringValue(put.ContentType), put.ContentEncoding)
+	}
+}
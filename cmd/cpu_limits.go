@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// CPU accounting stages. Extraction covers the query, encoding, and
+// compression, which run as one streaming pipeline.
+const (
+	cpuStageExtract = "extract"
+	cpuStageUpload  = "upload"
+	cpuStageOther   = "other" // Discovery, counting, the TUI, and anything between stages
+)
+
+// I/O scheduling classes accepted by --ionice-class
+const (
+	ioniceClassBestEffort = "best-effort"
+	ioniceClassIdle       = "idle"
+)
+
+// Static errors for CPU limit configuration
+var (
+	ErrMaxProcsInvalid            = errors.New("max procs must be >= 0")
+	ErrNiceInvalid                = errors.New("nice must be between -20 and 19")
+	ErrIONiceClassInvalid         = errors.New("ionice class must be one of: best-effort, idle")
+	ErrIONiceLevelInvalid         = errors.New("ionice level must be between 0 and 7")
+	ErrProcessPriorityUnsupported = errors.New("--nice and --ionice-class are only supported on Linux")
+	errCPUTimeUnavailable         = errors.New("process CPU time unavailable")
+)
+
+// CPULimits constrains the archiver's CPU and I/O scheduling, so compression-heavy
+// runs co-located with the database leave it cores and disk bandwidth
+type CPULimits struct {
+	MaxProcs    int    // GOMAXPROCS (0 = Go default: all cores)
+	Nice        int    // Scheduling priority (0 = unchanged)
+	IONiceClass string // "best-effort" or "idle" ("" = unchanged)
+	IONiceLevel int    // Priority within the best-effort class (0 = highest, 7 = lowest)
+}
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.Int("max-procs", 0, "maximum CPUs running Go code at once, including compression (GOMAXPROCS; 0 = all cores)")
+	flags.Int("nice", 0, "CPU scheduling priority on Linux, 1-19 lowers it (0 = unchanged)")
+	flags.String("ionice-class", "", "I/O scheduling class on Linux: best-effort, idle (empty = unchanged)")
+	flags.Int("ionice-level", 4, "I/O priority within the best-effort class (0-7, 7 = lowest)")
+
+	_ = viper.BindPFlag("cpu.max_procs", flags.Lookup("max-procs"))
+	_ = viper.BindPFlag("cpu.nice", flags.Lookup("nice"))
+	_ = viper.BindPFlag("cpu.ionice_class", flags.Lookup("ionice-class"))
+	_ = viper.BindPFlag("cpu.ionice_level", flags.Lookup("ionice-level"))
+}
+
+// loadCPULimits reads the CPU limits shared by every command
+func loadCPULimits() CPULimits {
+	return CPULimits{
+		MaxProcs:    viper.GetInt("cpu.max_procs"),
+		Nice:        viper.GetInt("cpu.nice"),
+		IONiceClass: viper.GetString("cpu.ionice_class"),
+		IONiceLevel: viper.GetInt("cpu.ionice_level"),
+	}
+}
+
+// Validate checks the limits are within the ranges the kernel accepts
+func (l CPULimits) Validate() error {
+	if l.MaxProcs < 0 {
+		return fmt.Errorf("%w, got %d", ErrMaxProcsInvalid, l.MaxProcs)
+	}
+	if l.Nice < -20 || l.Nice > 19 {
+		return fmt.Errorf("%w, got %d", ErrNiceInvalid, l.Nice)
+	}
+	if l.IONiceClass != "" && l.IONiceClass != ioniceClassBestEffort && l.IONiceClass != ioniceClassIdle {
+		return fmt.Errorf("%w, got '%s'", ErrIONiceClassInvalid, l.IONiceClass)
+	}
+	if l.IONiceLevel < 0 || l.IONiceLevel > 7 {
+		return fmt.Errorf("%w, got %d", ErrIONiceLevelInvalid, l.IONiceLevel)
+	}
+	return nil
+}
+
+// applyCPULimits sets GOMAXPROCS and the process priority before a command runs
+func applyCPULimits(limits CPULimits) error {
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+	if limits.MaxProcs > 0 {
+		runtime.GOMAXPROCS(limits.MaxProcs)
+	}
+	if limits.Nice != 0 || limits.IONiceClass != "" {
+		return setProcessPriority(limits)
+	}
+	return nil
+}
+
+// cpuUsage accounts the CPU time of the whole process, shared by every
+// archiver in a multi-table run. Nil when the platform can't report CPU time.
+var cpuUsage = newCPUAccountant(processCPUTime)
+
+// cpuAccountant attributes the process's CPU time to archive stages. CPU time
+// is only available for the whole process, so at every stage start and end the
+// CPU used since the previous one is split between the stages running at the
+// time, in proportion to how many workers were in each.
+type cpuAccountant struct {
+	mu       sync.Mutex
+	read     func() (time.Duration, error)
+	last     time.Duration
+	active   map[string]int
+	perStage map[string]time.Duration
+}
+
+func newCPUAccountant(read func() (time.Duration, error)) *cpuAccountant {
+	now, err := read()
+	if err != nil {
+		return nil
+	}
+	return &cpuAccountant{
+		read:     read,
+		last:     now,
+		active:   make(map[string]int),
+		perStage: make(map[string]time.Duration),
+	}
+}
+
+// track marks a worker as entering stage and returns the function that marks it leaving
+func (c *cpuAccountant) track(stage string) func() {
+	if c == nil {
+		return func() {}
+	}
+	c.transition(stage, 1)
+	return func() { c.transition(stage, -1) }
+}
+
+func (c *cpuAccountant) transition(stage string, delta int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flush()
+	c.active[stage] += delta
+}
+
+// flush attributes the CPU used since the last flush; callers hold c.mu
+func (c *cpuAccountant) flush() {
+	now, err := c.read()
+	if err != nil {
+		return
+	}
+	used := now - c.last
+	c.last = now
+
+	running := 0
+	for _, workers := range c.active {
+		running += workers
+	}
+	if running == 0 {
+		c.perStage[cpuStageOther] += used
+		return
+	}
+	for stage, workers := range c.active {
+		if workers > 0 {
+			c.perStage[stage] += used * time.Duration(workers) / time.Duration(running)
+		}
+	}
+}
+
+// usage returns the CPU time used by each stage so far
+func (c *cpuAccountant) usage() map[string]time.Duration {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flush()
+
+	usage := make(map[string]time.Duration, len(c.perStage))
+	for stage, used := range c.perStage {
+		usage[stage] = used
+	}
+	return usage
+}
+
+// formatCPUUsage renders per-stage CPU time as "12.3s (extract 10.1s, upload 1.2s, other 1.0s)"
+func formatCPUUsage(usage map[string]time.Duration) string {
+	var total time.Duration
+	var stages []string
+	for _, stage := range []string{cpuStageExtract, cpuStageUpload, cpuStageOther} {
+		if used, ok := usage[stage]; ok {
+			total += used
+			stages = append(stages, fmt.Sprintf("%s %.1fs", stage, used.Seconds()))
+		}
+	}
+	return fmt.Sprintf("%.1fs (%s)", total.Seconds(), strings.Join(stages, ", "))
+}
@@ -0,0 +1,81 @@
+//go:build linux
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// ioprio_set(2) constants
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+	ioprioClassBE    = 2
+	ioprioClassIdle  = 3
+)
+
+// setProcessPriority applies --nice and --ionice-class to every thread of the
+// process. Linux schedules threads individually, so setting the calling thread
+// alone would miss the Go runtime's other threads; threads created later
+// inherit the priority of the thread that creates them.
+func setProcessPriority(limits CPULimits) error {
+	ioprio := 0
+	switch limits.IONiceClass {
+	case ioniceClassBestEffort:
+		ioprio = ioprioClassBE<<ioprioClassShift | limits.IONiceLevel
+	case ioniceClassIdle:
+		ioprio = ioprioClassIdle << ioprioClassShift
+	}
+
+	// Repeat until a pass finds no new threads, in case one was started from
+	// a thread that hadn't been updated yet
+	done := make(map[int]bool)
+	for {
+		tids, err := processThreadIDs()
+		if err != nil {
+			return err
+		}
+		updated := false
+		for _, tid := range tids {
+			if done[tid] {
+				continue
+			}
+			done[tid] = true
+			updated = true
+
+			if limits.Nice != 0 {
+				if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, limits.Nice); err != nil && !errors.Is(err, syscall.ESRCH) {
+					return fmt.Errorf("failed to set nice %d: %w", limits.Nice, err)
+				}
+			}
+			if ioprio != 0 {
+				_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(ioprio))
+				if errno != 0 && errno != syscall.ESRCH {
+					return fmt.Errorf("failed to set ionice class %s: %w", limits.IONiceClass, errno)
+				}
+			}
+		}
+		if !updated {
+			return nil
+		}
+	}
+}
+
+// processThreadIDs lists the threads of the current process
+func processThreadIDs() ([]int, error) {
+	entries, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list process threads: %w", err)
+	}
+	tids := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		if tid, err := strconv.Atoi(entry.Name()); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+	return tids, nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package cmd
+
+// setProcessPriority is only implemented on Linux
+func setProcessPriority(CPULimits) error {
+	return ErrProcessPriorityUnsupported
+}
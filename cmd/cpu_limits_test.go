@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCPULimitsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  CPULimits
+		wantErr error
+	}{
+		{"Defaults", CPULimits{IONiceLevel: 4}, nil},
+		{"AllSet", CPULimits{MaxProcs: 2, Nice: 10, IONiceClass: ioniceClassIdle, IONiceLevel: 7}, nil},
+		{"NegativeMaxProcs", CPULimits{MaxProcs: -1}, ErrMaxProcsInvalid},
+		{"NiceTooHigh", CPULimits{Nice: 20}, ErrNiceInvalid},
+		{"NiceTooLow", CPULimits{Nice: -21}, ErrNiceInvalid},
+		{"UnknownIONiceClass", CPULimits{IONiceClass: "realtime"}, ErrIONiceClassInvalid},
+		{"IONiceLevelTooHigh", CPULimits{IONiceClass: ioniceClassBestEffort, IONiceLevel: 8}, ErrIONiceLevelInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.limits.Validate(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCPUAccountant(t *testing.T) {
+	var now time.Duration
+	accountant := newCPUAccountant(func() (time.Duration, error) { return now, nil })
+
+	// Nothing running: CPU goes to "other"
+	now += time.Second
+	doneExtract1 := accountant.track(cpuStageExtract)
+
+	// Two extracting workers and one uploading: 3s split 2:1
+	doneExtract2 := accountant.track(cpuStageExtract)
+	doneUpload := accountant.track(cpuStageUpload)
+	now += 3 * time.Second
+	doneUpload()
+	doneExtract1()
+	doneExtract2()
+
+	usage := accountant.usage()
+	if usage[cpuStageOther] != time.Second || usage[cpuStageExtract] != 2*time.Second || usage[cpuStageUpload] != time.Second {
+		t.Errorf("unexpected usage: %v", usage)
+	}
+	if got := formatCPUUsage(usage); got != "4.0s (extract 2.0s, upload 1.0s, other 1.0s)" {
+		t.Errorf("formatCPUUsage() = %s", got)
+	}
+}
+
+func TestCPUAccountantUnavailable(t *testing.T) {
+	accountant := newCPUAccountant(func() (time.Duration, error) { return 0, errCPUTimeUnavailable })
+	if accountant != nil {
+		t.Fatal("expected no accountant when CPU time is unavailable")
+	}
+	accountant.track(cpuStageExtract)()
+	if usage := accountant.usage(); usage != nil {
+		t.Errorf("expected no usage, got %v", usage)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package cmd
+
+import "time"
+
+// processCPUTime is only implemented on Unix; CPU accounting is disabled elsewhere
+func processCPUTime() (time.Duration, error) {
+	return 0, errCPUTimeUnavailable
+}
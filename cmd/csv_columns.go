@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/airframesio/data-archiver/cmd/formatters"
+)
+
+// Static errors for header-less CSV configuration
+var (
+	ErrCSVColumnsRequired = errors.New("--csv-no-header requires --csv-columns or --csv-schema-file")
+	ErrCSVColumnsConflict = errors.New("--csv-columns and --csv-schema-file are mutually exclusive")
+	ErrCSVNoHeaderNotSet  = errors.New("--csv-columns and --csv-schema-file require --csv-no-header")
+	ErrCSVColumnEmpty     = errors.New("CSV column name is empty")
+	ErrCSVColumnDuplicate = errors.New("CSV column name is duplicated")
+)
+
+// resolveCSVColumns returns the explicit column list for header-less CSV files,
+// or nil when CSV files carry their own header row. Names are used as given,
+// so any name PostgreSQL accepts works; ones the target table lacks are
+// reported by the restore schema check.
+func resolveCSVColumns(noHeader bool, columnList, schemaFile string) ([]string, error) {
+	if !noHeader {
+		if columnList != "" || schemaFile != "" {
+			return nil, ErrCSVNoHeaderNotSet
+		}
+		return nil, nil
+	}
+
+	var columns []string
+	switch {
+	case columnList != "" && schemaFile != "":
+		return nil, ErrCSVColumnsConflict
+	case columnList != "":
+		columns = strings.Split(columnList, ",")
+	case schemaFile != "":
+		var err error
+		columns, err = readCSVSchemaFile(schemaFile)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrCSVColumnsRequired
+	}
+
+	seen := make(map[string]bool, len(columns))
+	for i, column := range columns {
+		column = strings.TrimSpace(column)
+		if column == "" {
+			return nil, fmt.Errorf("%w: column %d", ErrCSVColumnEmpty, i+1)
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("%w: '%s'", ErrCSVColumnDuplicate, column)
+		}
+		seen[column] = true
+		columns[i] = column
+	}
+	return columns, nil
+}
+
+// readCSVSchemaFile reads column names from a schema file with one column per
+// line, in file order. Anything after the name (such as a type) is ignored,
+// as are blank lines and lines starting with #.
+func readCSVSchemaFile(path string) ([]string, error) {
+	file, err := os.Open(expandHomePath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CSV schema file: %w", err)
+	}
+	defer file.Close()
+
+	var columns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if fields := strings.Fields(strings.ReplaceAll(line, ",", " ")); len(fields) > 0 {
+			columns = append(columns, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read CSV schema file: %w", err)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%w: %s has no columns", ErrCSVColumnsRequired, path)
+	}
+	return columns, nil
+}
+
+// newCSVReader creates a CSV reader that uses the header row, or the explicit
+// column list when one is configured
+func newCSVReader(r io.ReadCloser, columns []string) (*formatters.CSVReader, error) {
+	if len(columns) > 0 {
+		return formatters.NewCSVReaderWithColumns(r, columns), nil
+	}
+	return formatters.NewCSVReaderWithCloser(r)
+}
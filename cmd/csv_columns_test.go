@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveCSVColumns(t *testing.T) {
+	schemaPath := filepath.Join(t.TempDir(), "columns.txt")
+	schema := "# legacy export layout\nid bigint\n\ncreated_at timestamptz\nmessage,text\n"
+	if err := os.WriteFile(schemaPath, []byte(schema), 0o600); err != nil {
+		t.Fatalf("failed to write schema file: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		noHeader   bool
+		columnList string
+		schemaFile string
+		want       []string
+		wantErr    error
+	}{
+		{"HeaderRow", false, "", "", nil, nil},
+		{"ColumnList", true, "id, created_at ,message", "", []string{"id", "created_at", "message"}, nil},
+		{"SchemaFile", true, "", schemaPath, []string{"id", "created_at", "message"}, nil},
+		{"MissingColumns", true, "", "", nil, ErrCSVColumnsRequired},
+		{"BothSources", true, "id", schemaPath, nil, ErrCSVColumnsConflict},
+		{"ColumnsWithoutNoHeader", false, "id", "", nil, ErrCSVNoHeaderNotSet},
+		{"QuotedNames", true, "id,flight.no,seen-at,Départ heure", "", []string{"id", "flight.no", "seen-at", "Départ heure"}, nil},
+		{"EmptyName", true, "id,,message", "", nil, ErrCSVColumnEmpty},
+		{"TrailingComma", true, "id,", "", nil, ErrCSVColumnEmpty},
+		{"Duplicate", true, "id,id", "", nil, ErrCSVColumnDuplicate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveCSVColumns(tt.noHeader, tt.columnList, tt.schemaFile)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewCSVReaderWithoutHeader(t *testing.T) {
+	data := "1,hello\n2,world\n"
+	reader, err := newCSVReader(io.NopCloser(strings.NewReader(data)), []string{"id", "message"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer reader.Close()
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows (first line is data, not a header), got %d", len(rows))
+	}
+	if rows[0]["id"] != int64(1) || rows[0]["message"] != "hello" {
+		t.Errorf("unexpected first row: %v", rows[0])
+	}
+}
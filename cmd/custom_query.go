@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/spf13/viper"
+)
+
+// Static errors for custom extraction queries
+var (
+	ErrCustomQueryNotSelect       = errors.New("custom query must be a single SELECT or WITH statement")
+	ErrCustomQueryPlaceholders    = errors.New("custom query must filter on both {start} and {end}")
+	ErrCustomQueryDuplicateColumn = errors.New("custom query returns duplicate column names")
+	ErrCustomQueryNoColumns       = errors.New("custom query returns no columns")
+)
+
+// loadTableQueries reads table_queries from the config file: a custom
+// extraction SELECT per table that replaces the generated query
+func loadTableQueries() map[string]string {
+	queries := viper.GetStringMapString("table_queries")
+	if len(queries) == 0 {
+		return nil
+	}
+	return queries
+}
+
+// customQuery returns the custom extraction query for c.Table, or "" to use the
+// generated SELECT. Config keys are case-insensitive, so names are matched
+// exactly first and then by their lowercase form.
+func (c *Config) customQuery() string {
+	if query, ok := c.TableQueries[c.Table]; ok {
+		return query
+	}
+	return c.TableQueries[strings.ToLower(c.Table)]
+}
+
+// canSliceByTime reports whether extraction can be limited to a time range:
+// with --date-column, or with a custom query, which filters on {start}/{end}
+func (c *Config) canSliceByTime() bool {
+	return c.DateColumn != "" || c.customQuery() != ""
+}
+
+// validateCustomQuery checks that query is one SELECT that uses both range
+// placeholders, so every partition or slice only extracts its own rows
+func validateCustomQuery(query string) error {
+	trimmed := strings.TrimSuffix(strings.TrimSpace(query), ";")
+	words := strings.Fields(trimmed)
+	if len(words) == 0 || strings.Contains(trimmed, ";") {
+		return ErrCustomQueryNotSelect
+	}
+	if firstWord := strings.ToUpper(words[0]); firstWord != "SELECT" && firstWord != "WITH" {
+		return ErrCustomQueryNotSelect
+	}
+	if !strings.Contains(trimmed, "{start}") || !strings.Contains(trimmed, "{end}") {
+		return ErrCustomQueryPlaceholders
+	}
+	return nil
+}
+
+// renderCustomQuery substitutes the placeholders of a custom query: {table}
+// becomes the quoted partition (or table) being archived, and {start} and
+// {end} become bind parameters for the half-open time range [start, end).
+func renderCustomQuery(query, tableName string, start, end time.Time) (string, []interface{}) {
+	query = strings.TrimSuffix(strings.TrimSpace(query), ";")
+	query = strings.NewReplacer(
+		"{table}", pq.QuoteIdentifier(tableName),
+		"{start}", "$1",
+		"{end}", "$2",
+	).Replace(query)
+	return query, []interface{}{start, end}
+}
+
+// partitionTimeRange returns the time range a partition covers, for custom
+// queries run on a whole partition: its custom range if set, otherwise a
+// month for monthly partitions and a day for all others
+func (a *Archiver) partitionTimeRange(partition PartitionInfo) (time.Time, time.Time) {
+	if partition.HasCustomRange() {
+		return partition.RangeStart, partition.RangeEnd
+	}
+	start := partition.Date
+	if suffix, ok := partitionSuffix(a.config.Table, partition.TableName); ok {
+		if (len(suffix) == 7 && suffix[4] == '_') || len(suffix) == 6 {
+			return start, addLocalDate(start, 0, 1, 0)
+		}
+	}
+	return start, addLocalDate(start, 0, 0, 1)
+}
+
+// getQuerySchema derives the output schema of a custom query by running it
+// with LIMIT 0, so joined and computed columns get their real types
+func (a *Archiver) getQuerySchema(ctx context.Context, tableName, query string, args []interface{}) (*TableSchema, error) {
+	//nolint:gosec // G201: the custom query comes from the operator's config file
+	rows, err := a.db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM (%s) AS custom_query LIMIT 0", query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("custom query for %s failed: %w", tableName, err)
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read custom query columns: %w", err)
+	}
+	if len(columnTypes) == 0 {
+		return nil, ErrCustomQueryNoColumns
+	}
+
+	schema := &TableSchema{TableName: tableName, Columns: make([]ColumnInfo, 0, len(columnTypes))}
+	seen := make(map[string]bool, len(columnTypes))
+	for _, ct := range columnTypes {
+		if seen[ct.Name()] {
+			return nil, fmt.Errorf("%w: '%s' (alias one of them)", ErrCustomQueryDuplicateColumn, ct.Name())
+		}
+		seen[ct.Name()] = true
+
+		// The driver reports type names in upper case; the formatters expect
+		// udt_name spelling. Types it doesn't know (e.g. enums) are written as text.
+		typeName := strings.ToLower(ct.DatabaseTypeName())
+		if typeName == "" {
+			typeName = "text"
+		}
+		schema.Columns = append(schema.Columns, ColumnInfo{Name: ct.Name(), DataType: typeName, UDTName: typeName})
+	}
+	return schema, rows.Err()
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestValidateCustomQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr error
+	}{
+		{"Select", "SELECT f.*, a.name AS airline FROM {table} f JOIN airlines a ON a.id = f.airline_id WHERE f.created_at >= {start} AND f.created_at < {end}", nil},
+		{"WithTrailingSemicolon", "select * from {table} where ts >= {start} and ts < {end};", nil},
+		{"CTE", "WITH recent AS (SELECT * FROM events WHERE ts >= {start} AND ts < {end})\nSELECT * FROM recent", nil},
+		{"MissingEnd", "SELECT * FROM {table} WHERE ts >= {start}", ErrCustomQueryPlaceholders},
+		{"NotSelect", "DELETE FROM flights WHERE ts >= {start} AND ts < {end}", ErrCustomQueryNotSelect},
+		{"MultipleStatements", "SELECT 1 WHERE {start} < {end}; DROP TABLE flights", ErrCustomQueryNotSelect},
+		{"Empty", "  ", ErrCustomQueryNotSelect},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateCustomQuery(tt.query); !errors.Is(err, tt.wantErr) {
+				t.Errorf("validateCustomQuery() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRenderCustomQuery(t *testing.T) {
+	start := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 1)
+
+	query, args := renderCustomQuery("SELECT * FROM {table} WHERE ts >= {start} AND ts < {end} AND ts <> {start};", "Flights_20240315", start, end)
+	want := `SELECT * FROM "Flights_20240315" WHERE ts >= $1 AND ts < $2 AND ts <> $1`
+	if query != want {
+		t.Errorf("query = %s, want %s", query, want)
+	}
+	if len(args) != 2 || args[0] != start || args[1] != end {
+		t.Errorf("args = %v, want [start end]", args)
+	}
+}
+
+func TestConfigCustomQuery(t *testing.T) {
+	config := newTestConfig()
+	config.Table = "Flights"
+	config.TableQueries = map[string]string{"flights": "SELECT * FROM {table} WHERE ts >= {start} AND ts < {end}"}
+
+	if config.customQuery() == "" {
+		t.Error("expected the lowercase config key to match")
+	}
+	if !config.canSliceByTime() {
+		t.Error("a custom query should allow time slicing without --date-column")
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	config.TableQueries["flights"] = "SELECT * FROM {table}"
+	if err := config.Validate(); !errors.Is(err, ErrCustomQueryPlaceholders) {
+		t.Errorf("Validate() = %v, want ErrCustomQueryPlaceholders", err)
+	}
+
+	config.Table = "events"
+	if config.customQuery() != "" || config.canSliceByTime() {
+		t.Error("tables without a custom query should use the generated SELECT")
+	}
+}
+
+func TestPartitionTimeRange(t *testing.T) {
+	archiver := NewArchiver(&Config{Table: "flights"}, newTestLogger())
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	start, end := archiver.partitionTimeRange(PartitionInfo{TableName: "flights_2024_03", Date: date})
+	if !start.Equal(date) || !end.Equal(date.AddDate(0, 1, 0)) {
+		t.Errorf("monthly: %v - %v", start, end)
+	}
+	start, end = archiver.partitionTimeRange(PartitionInfo{TableName: "flights_20240301", Date: date})
+	if !start.Equal(date) || !end.Equal(date.AddDate(0, 0, 1)) {
+		t.Errorf("daily: %v - %v", start, end)
+	}
+	custom := PartitionInfo{TableName: "flights", Date: date, RangeStart: date, RangeEnd: date.AddDate(0, 0, 7)}
+	if start, end = archiver.partitionTimeRange(custom); !start.Equal(custom.RangeStart) || !end.Equal(custom.RangeEnd) {
+		t.Errorf("custom range: %v - %v", start, end)
+	}
+}
+
+func TestGetQuerySchema(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	archiver := NewArchiver(&Config{Table: "flights"}, newTestLogger())
+	archiver.db = db
+	start := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	query, args := renderCustomQuery("SELECT f.id, a.name AS airline FROM {table} f JOIN airlines a ON a.id = f.airline_id WHERE f.ts >= {start} AND f.ts < {end}", "flights_20240315", start, start.AddDate(0, 0, 1))
+
+	mock.ExpectQuery(`SELECT \* FROM \(SELECT f\.id, a\.name AS airline FROM "flights_20240315" .*\) AS custom_query LIMIT 0`).
+		WithArgs(start, start.AddDate(0, 0, 1)).
+		WillReturnRows(sqlmock.NewRowsWithColumnDefinition(
+			sqlmock.NewColumn("id").OfType("INT8", int64(0)),
+			sqlmock.NewColumn("airline").OfType("VARCHAR", ""),
+		))
+
+	schema, err := archiver.getQuerySchema(context.Background(), "flights_20240315", query, args)
+	if err != nil {
+		t.Fatalf("getQuerySchema() error = %v", err)
+	}
+	if len(schema.Columns) != 2 || schema.Columns[0] != (ColumnInfo{Name: "id", DataType: "int8", UDTName: "int8"}) || schema.Columns[1].UDTName != "varchar" {
+		t.Errorf("columns = %+v", schema.Columns)
+	}
+
+	mock.ExpectQuery(`AS custom_query LIMIT 0`).
+		WillReturnRows(sqlmock.NewRowsWithColumnDefinition(
+			sqlmock.NewColumn("id").OfType("INT8", int64(0)),
+			sqlmock.NewColumn("id").OfType("INT8", int64(0)),
+		))
+	if _, err := archiver.getQuerySchema(context.Background(), "flights_20240315", query, args); !errors.Is(err, ErrCustomQueryDuplicateColumn) {
+		t.Errorf("expected ErrCustomQueryDuplicateColumn, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestVerifierCountsCustomQueryRows(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	config := &Config{Table: "flights", TableQueries: map[string]string{
+		"flights": "SELECT * FROM {table} f JOIN airlines a ON a.id = f.airline_id WHERE f.ts >= {start} AND f.ts < {end}",
+	}}
+	verifier := NewVerifier(config, true, newTestLogger())
+	day := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT to_regclass`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM \(SELECT \* FROM "flights_20240315" f JOIN airlines .*\) AS custom_query`).
+		WithArgs(day, day.AddDate(0, 0, 1)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+
+	result := verifier.verifyCounts(context.Background(), db, PartitionCacheEntry{S3Key: "k", SourceTable: "flights_20240315", ArchivedRowCount: 10})
+	if result.Status != VerifyStatusOK {
+		t.Errorf("expected %s, got %s (%s)", VerifyStatusOK, result.Status, result.Message)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
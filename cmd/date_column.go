@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Date column types accepted by --date-column-type
+const (
+	DateColumnTimestamp   = "timestamp" // timestamp or timestamptz (default)
+	DateColumnDate        = "date"
+	DateColumnEpoch       = "epoch"    // Integer seconds since 1970-01-01 UTC
+	DateColumnEpochMillis = "epoch_ms" // Integer milliseconds since 1970-01-01 UTC
+	DateColumnText        = "text"     // Text in the layout given by --date-column-format
+)
+
+// Static errors for date column types
+var (
+	ErrDateColumnTypeInvalid    = errors.New("date column type must be one of: timestamp, date, epoch, epoch_ms, text")
+	ErrDateColumnFormatRequired = errors.New("--date-column-format is required for text date columns")
+	ErrDateColumnFormatUnused   = errors.New("--date-column-format only applies to text date columns")
+	ErrDateColumnFormatUnsorted = errors.New("date column format must list year, month, day, hour, minute, second in that order with zero-padded fields (e.g. 2006-01-02 15:04:05)")
+)
+
+var (
+	dateColumnType   string
+	dateColumnFormat string
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{archiveCmd, dumpCmd, dumpHybridCmd, verifyCmd} {
+		cmd.Flags().StringVar(&dateColumnType, "date-column-type", DateColumnTimestamp, "type of --date-column: timestamp, date, epoch (seconds), epoch_ms, text")
+		cmd.Flags().StringVar(&dateColumnFormat, "date-column-format", "", "Go time layout of a text --date-column, e.g. 2006-01-02 15:04:05 (in --timezone, zero-padded)")
+	}
+	for _, cmd := range []*cobra.Command{archiveCmd, dumpCmd, dumpHybridCmd} {
+		_ = viper.BindPFlag("date_column_type", cmd.Flags().Lookup("date-column-type"))
+		_ = viper.BindPFlag("date_column_format", cmd.Flags().Lookup("date-column-format"))
+	}
+}
+
+// DateColumnSpec describes the column that time ranges are filtered on
+type DateColumnSpec struct {
+	Name   string
+	Type   string // One of the DateColumn* types ("" = timestamp)
+	Format string // Go time layout for text columns
+}
+
+// dateColumnSpec returns the configured date column
+func (c *Config) dateColumnSpec() DateColumnSpec {
+	return DateColumnSpec{Name: c.DateColumn, Type: c.DateColumnType, Format: c.DateColumnFormat}
+}
+
+// Validate checks the type, and for text columns that the format sorts as text
+// in time order, so range filters can compare strings without casting
+func (s DateColumnSpec) Validate() error {
+	switch s.Type {
+	case "", DateColumnTimestamp, DateColumnDate, DateColumnEpoch, DateColumnEpochMillis:
+		if s.Format != "" {
+			return ErrDateColumnFormatUnused
+		}
+		return nil
+	case DateColumnText:
+		if s.Format == "" {
+			return ErrDateColumnFormatRequired
+		}
+		if _, err := sortableLayoutStep(s.Format); err != nil {
+			return err
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w, got '%s'", ErrDateColumnTypeInvalid, s.Type)
+	}
+}
+
+// rangeCondition returns a WHERE condition selecting rows in [start, end) on
+// the column, using $1 and $2, and the arguments for them. The column is
+// compared in its own type, so indexes on it are used and malformed text
+// values never make the query fail.
+func (s DateColumnSpec) rangeCondition(start, end time.Time) (string, []interface{}) {
+	column := pq.QuoteIdentifier(s.Name)
+	switch s.Type {
+	case DateColumnDate:
+		// Compare as timestamps so sub-day slices of a day keep its rows
+		// together; dates are wall-clock days in the slice time zone
+		const layout = "2006-01-02 15:04:05.999999"
+		return fmt.Sprintf("%s >= $1::timestamp AND %s < $2::timestamp", column, column),
+			[]interface{}{start.Format(layout), end.Format(layout)}
+	case DateColumnEpoch:
+		return fmt.Sprintf("%s >= $1 AND %s < $2", column, column),
+			[]interface{}{ceilEpoch(start, time.Second), ceilEpoch(end, time.Second)}
+	case DateColumnEpochMillis:
+		return fmt.Sprintf("%s >= $1 AND %s < $2", column, column),
+			[]interface{}{ceilEpoch(start, time.Millisecond), ceilEpoch(end, time.Millisecond)}
+	case DateColumnText:
+		return fmt.Sprintf("%s >= $1 AND %s < $2", column, column),
+			[]interface{}{s.ceilText(start), s.ceilText(end)}
+	default:
+		return fmt.Sprintf("%s >= $1 AND %s < $2", column, column), []interface{}{start, end}
+	}
+}
+
+// aggregateExpr wraps min()/max() of the column so the result scans as a
+// timestamp (or, for text columns, as text parsed by boundTime)
+func (s DateColumnSpec) aggregateExpr(fn string) string {
+	column := pq.QuoteIdentifier(s.Name)
+	switch s.Type {
+	case DateColumnDate:
+		return fmt.Sprintf("%s(%s)::timestamp", fn, column)
+	case DateColumnEpoch:
+		return fmt.Sprintf("to_timestamp(%s(%s))", fn, column)
+	case DateColumnEpochMillis:
+		return fmt.Sprintf("to_timestamp(%s(%s) / 1000.0)", fn, column)
+	default:
+		return fmt.Sprintf("%s(%s)", fn, column)
+	}
+}
+
+// boundTime converts a value scanned from aggregateExpr to a time; NULL and
+// text not in the declared format give the zero time
+func (s DateColumnSpec) boundTime(value interface{}) time.Time {
+	var text string
+	switch v := value.(type) {
+	case time.Time:
+		return v
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return time.Time{}
+	}
+	if parsed, err := time.ParseInLocation(s.Format, text, time.UTC); err == nil {
+		return parsed
+	}
+	return time.Time{}
+}
+
+// ceilEpoch converts t to an integer count of unit since the epoch, rounding
+// up so that integer values v satisfy v >= result exactly when v >= t
+func ceilEpoch(t time.Time, unit time.Duration) int64 {
+	nanos := t.UnixNano()
+	value := nanos / int64(unit)
+	if nanos%int64(unit) > 0 {
+		value++
+	}
+	return value
+}
+
+// ceilText formats t in the column's layout, rounding up to the next value the
+// layout can represent. For stored values v, v >= result exactly when v >= t,
+// so slices finer than the layout never select the same rows twice. Text is
+// read as wall-clock time in t's zone (the slice time zone).
+func (s DateColumnSpec) ceilText(t time.Time) string {
+	formatted := t.Format(s.Format)
+	truncated, err := time.ParseInLocation(s.Format, formatted, t.Location())
+	if err != nil || !truncated.Before(t) {
+		return formatted
+	}
+	step, err := sortableLayoutStep(s.Format)
+	if err != nil {
+		return formatted
+	}
+	return step(truncated).Format(s.Format)
+}
+
+// sortableLayoutFields are the layout fields a sortable text format may use,
+// from most to least significant, with the step to the next representable value
+var sortableLayoutFields = []struct {
+	token string
+	step  func(time.Time) time.Time
+}{
+	{"2006", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+	{"01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+	{"02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+	{"15", func(t time.Time) time.Time { return t.Add(time.Hour) }},
+	{"04", func(t time.Time) time.Time { return t.Add(time.Minute) }},
+	{"05", func(t time.Time) time.Time { return t.Add(time.Second) }},
+}
+
+// sortableLayoutStep checks that layout formats times as fixed-width text
+// ordered from year down (so text order is time order), and returns the step
+// to the next value it can represent
+func sortableLayoutStep(layout string) (func(time.Time) time.Time, error) {
+	next := 0
+	var step func(time.Time) time.Time
+	for i := 0; i < len(layout); {
+		c := layout[i]
+		switch {
+		case next < len(sortableLayoutFields) && strings.HasPrefix(layout[i:], sortableLayoutFields[next].token):
+			step = sortableLayoutFields[next].step
+			i += len(sortableLayoutFields[next].token)
+			next++
+		case (c == '.' || c == ',') && next == len(sortableLayoutFields) && i+1 < len(layout) && layout[i+1] == '0':
+			// Fractional seconds: fixed-width zeros only (nines trim trailing zeros)
+			digits := len(layout[i+1:]) - len(strings.TrimLeft(layout[i+1:], "0"))
+			if digits > 9 {
+				return nil, fmt.Errorf("%w, got '%s'", ErrDateColumnFormatUnsorted, layout)
+			}
+			unit := time.Duration(math.Pow10(9 - digits))
+			step = func(t time.Time) time.Time { return t.Add(unit) }
+			i += 1 + digits
+			next++
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z' && c != 'T' && c != 'Z':
+			// Any other field (month names, 12-hour clock, zones, ...) breaks text ordering
+			return nil, fmt.Errorf("%w, got '%s'", ErrDateColumnFormatUnsorted, layout)
+		default:
+			i++ // Separator
+		}
+	}
+	if step == nil {
+		return nil, fmt.Errorf("%w, got '%s'", ErrDateColumnFormatUnsorted, layout)
+	}
+	return step, nil
+}
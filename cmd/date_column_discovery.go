@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Static errors for date column discovery
+var (
+	ErrDateColumnNotFound        = errors.New("no timestamp or date column found to slice by; pass --date-column")
+	ErrDateColumnAmbiguous       = errors.New("several columns could slice by time; pass --date-column with one of them")
+	ErrDateColumnTypeNotInferred = errors.New("--date-column must be given for epoch and text date columns")
+)
+
+// wellKnownDateColumns are column names that conventionally hold a row's
+// creation time, which never changes and so keeps rows in one slice
+var wellKnownDateColumns = []string{
+	"created_at", "inserted_at", "created", "created_on", "creation_time", "creation_date",
+	"timestamp", "ts", "event_time", "occurred_at", "recorded_at", "logged_at",
+}
+
+// mutableDateColumnWords mark columns that change after a row is written;
+// slicing by them moves rows between archives, so they are never picked
+var mutableDateColumnWords = []string{"updated", "modified", "changed", "deleted", "expires", "expired"}
+
+// dateColumnCandidate is a column that could slice a table by time
+type dateColumnCandidate struct {
+	Name         string
+	Type         string // DateColumnTimestamp or DateColumnDate
+	PartitionKey bool   // Referenced by the table's partition key
+}
+
+// String describes the candidate for logs and errors
+func (c dateColumnCandidate) String() string {
+	if c.PartitionKey {
+		return fmt.Sprintf("%s (%s, partition key)", c.Name, c.Type)
+	}
+	return fmt.Sprintf("%s (%s)", c.Name, c.Type)
+}
+
+// dateColumnCandidates returns the timestamp and date columns of schema,
+// partition key columns first, then well-known names, then the rest in
+// column order
+func dateColumnCandidates(schema *TableSchema, partitionKey string) []dateColumnCandidate {
+	keyColumns := make(map[string]bool)
+	for _, identifier := range partitionKeyIdentifiers(partitionKey) {
+		keyColumns[identifier] = true
+	}
+
+	var keyed, known, other []dateColumnCandidate
+	for _, column := range schema.Columns {
+		var columnType string
+		switch strings.ToLower(column.UDTName) {
+		case "timestamp", "timestamptz":
+			columnType = DateColumnTimestamp
+		case "date":
+			columnType = DateColumnDate
+		default:
+			continue
+		}
+		candidate := dateColumnCandidate{Name: column.Name, Type: columnType, PartitionKey: keyColumns[column.Name]}
+		switch {
+		case candidate.PartitionKey:
+			keyed = append(keyed, candidate)
+		case wellKnownDateColumnRank(column.Name) >= 0:
+			known = append(known, candidate)
+		default:
+			other = append(other, candidate)
+		}
+	}
+
+	// Well-known names in the order of wellKnownDateColumns
+	for i := 1; i < len(known); i++ {
+		for j := i; j > 0 && wellKnownDateColumnRank(known[j].Name) < wellKnownDateColumnRank(known[j-1].Name); j-- {
+			known[j], known[j-1] = known[j-1], known[j]
+		}
+	}
+
+	candidates := append(keyed, known...)
+	return append(candidates, other...)
+}
+
+// chooseDateColumn picks the candidate to slice by when the choice is clear:
+// the only partition key column, else the only well-known creation column,
+// else the only column that does not change after insert. It returns false
+// when the candidates are ambiguous.
+func chooseDateColumn(candidates []dateColumnCandidate) (dateColumnCandidate, bool) {
+	var keyed, known, stable []dateColumnCandidate
+	for _, candidate := range candidates {
+		if isMutableDateColumn(candidate.Name) {
+			continue
+		}
+		stable = append(stable, candidate)
+		if candidate.PartitionKey {
+			keyed = append(keyed, candidate)
+		} else if wellKnownDateColumnRank(candidate.Name) >= 0 {
+			known = append(known, candidate)
+		}
+	}
+
+	for _, group := range [][]dateColumnCandidate{keyed, known, stable} {
+		if len(group) == 1 {
+			return group[0], true
+		}
+		if len(group) > 1 {
+			return dateColumnCandidate{}, false
+		}
+	}
+	return dateColumnCandidate{}, false
+}
+
+// wellKnownDateColumnRank returns the position of name in wellKnownDateColumns, or -1
+func wellKnownDateColumnRank(name string) int {
+	for i, known := range wellKnownDateColumns {
+		if strings.EqualFold(name, known) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isMutableDateColumn reports whether name looks like a column updated after insert
+func isMutableDateColumn(name string) bool {
+	lower := strings.ToLower(name)
+	for _, word := range mutableDateColumnWords {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// partitionKeyIdentifiers returns the identifiers in a partition key
+// definition as printed by pg_get_partkeydef, e.g. created_at in
+// "RANGE (date_trunc('day'::text, created_at))". String literals are skipped
+// and quoted identifiers are unquoted.
+func partitionKeyIdentifiers(definition string) []string {
+	var identifiers []string
+	for i := 0; i < len(definition); {
+		c := definition[i]
+		switch {
+		case c == '\'':
+			// String literal, with '' as an escaped quote
+			i++
+			for i < len(definition) {
+				if definition[i] == '\'' {
+					if i+1 < len(definition) && definition[i+1] == '\'' {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i++
+		case c == '"':
+			var name strings.Builder
+			i++
+			for i < len(definition) {
+				if definition[i] == '"' {
+					if i+1 < len(definition) && definition[i+1] == '"' {
+						name.WriteByte('"')
+						i += 2
+						continue
+					}
+					break
+				}
+				name.WriteByte(definition[i])
+				i++
+			}
+			i++
+			identifiers = append(identifiers, name.String())
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80:
+			start := i
+			for i < len(definition) && (definition[i] == '_' || definition[i] == '$' || definition[i] >= 0x80 ||
+				definition[i] >= 'a' && definition[i] <= 'z' || definition[i] >= 'A' && definition[i] <= 'Z' ||
+				definition[i] >= '0' && definition[i] <= '9') {
+				i++
+			}
+			identifiers = append(identifiers, definition[start:i])
+		default:
+			i++
+		}
+	}
+	return identifiers
+}
+
+// partitionKeyDefinition returns the base table's partition key, or "" if the
+// table is not a partitioned table
+func (a *Archiver) partitionKeyDefinition(ctx context.Context) string {
+	query := `
+		SELECT pg_get_partkeydef(c.oid)
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND c.relname = $2 AND c.relkind = 'p'
+	`
+	var definition string
+	if err := a.db.QueryRowContext(ctx, query, defaultTableSchema, a.config.Table).Scan(&definition); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			a.logger.Debug(fmt.Sprintf("Failed to read partition key of %s: %v", a.config.Table, err))
+		}
+		return ""
+	}
+	return definition
+}
+
+// ensureDateColumn picks a --date-column from the schema when slicing by time
+// needs one and none was given. Columns are read from the base table, or from
+// sampleTable (one of its partitions) when the base table has none.
+func (a *Archiver) ensureDateColumn(ctx context.Context, sampleTable string) error {
+	if a.config.canSliceByTime() {
+		return nil
+	}
+	switch a.config.DateColumnType {
+	case "", DateColumnTimestamp, DateColumnDate:
+	default:
+		return ErrDateColumnTypeNotInferred
+	}
+
+	inspected := a.config.Table
+	schema, err := a.getTableSchema(ctx, inspected)
+	if (err != nil || schema == nil || len(schema.Columns) == 0) && sampleTable != "" && sampleTable != inspected {
+		inspected = sampleTable
+		schema, err = a.getTableSchema(ctx, inspected)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s for a date column: %w", inspected, err)
+	}
+	if schema == nil {
+		return fmt.Errorf("%w (table %s)", ErrDateColumnNotFound, inspected)
+	}
+
+	candidates := dateColumnCandidates(schema, a.partitionKeyDefinition(ctx))
+	if len(candidates) == 0 {
+		return fmt.Errorf("%w (table %s)", ErrDateColumnNotFound, inspected)
+	}
+	chosen, ok := chooseDateColumn(candidates)
+	if !ok {
+		names := make([]string, len(candidates))
+		for i, candidate := range candidates {
+			names[i] = candidate.String()
+		}
+		return fmt.Errorf("%w: %s", ErrDateColumnAmbiguous, strings.Join(names, ", "))
+	}
+
+	a.config.DateColumn = chosen.Name
+	if chosen.Type == DateColumnDate {
+		a.config.DateColumnType = DateColumnDate
+	}
+	a.logger.Info(fmt.Sprintf("ℹ️  No --date-column given; slicing %s by %s. Pass --date-column to use another column.",
+		a.config.Table, chosen))
+	return nil
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPartitionKeyIdentifiers(t *testing.T) {
+	tests := []struct {
+		definition string
+		want       []string
+	}{
+		{"RANGE (created_at)", []string{"RANGE", "created_at"}},
+		{"RANGE (date_trunc('day'::text, inserted_at))", []string{"RANGE", "date_trunc", "text", "inserted_at"}},
+		{`RANGE ("Event Time", 'it''s')`, []string{"RANGE", "Event Time"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := partitionKeyIdentifiers(tt.definition); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("partitionKeyIdentifiers(%q) = %q, want %q", tt.definition, got, tt.want)
+		}
+	}
+}
+
+func TestChooseDateColumn(t *testing.T) {
+	schema := func(columns ...ColumnInfo) *TableSchema { return &TableSchema{Columns: columns} }
+	ts := func(name string) ColumnInfo { return ColumnInfo{Name: name, UDTName: "timestamptz"} }
+
+	tests := []struct {
+		name         string
+		schema       *TableSchema
+		partitionKey string
+		want         string
+		wantType     string
+		wantOK       bool
+	}{
+		{
+			name:         "partition key wins over well-known names",
+			schema:       schema(ts("created_at"), ts("observed_at"), ColumnInfo{Name: "id", UDTName: "int8"}),
+			partitionKey: "RANGE (observed_at)",
+			want:         "observed_at", wantType: DateColumnTimestamp, wantOK: true,
+		},
+		{
+			name:   "single well-known name",
+			schema: schema(ts("updated_at"), ts("created_at"), ts("seen_at"), ts("last_seen")),
+			want:   "created_at", wantType: DateColumnTimestamp, wantOK: true,
+		},
+		{
+			name:   "only stable column, of type date",
+			schema: schema(ts("modified_at"), ColumnInfo{Name: "day", UDTName: "date"}),
+			want:   "day", wantType: DateColumnDate, wantOK: true,
+		},
+		{
+			name:   "two well-known names are ambiguous",
+			schema: schema(ts("created_at"), ts("inserted_at")),
+		},
+		{
+			name:   "two unknown columns are ambiguous",
+			schema: schema(ts("seen_at"), ts("last_seen")),
+		},
+		{
+			name:   "only mutable columns",
+			schema: schema(ts("updated_at")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := chooseDateColumn(dateColumnCandidates(tt.schema, tt.partitionKey))
+			if ok != tt.wantOK || got.Name != tt.want || (ok && got.Type != tt.wantType) {
+				t.Errorf("chooseDateColumn() = %+v, %v; want %s (%s), %v", got, ok, tt.want, tt.wantType, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestDateColumnCandidatesOrder(t *testing.T) {
+	candidates := dateColumnCandidates(&TableSchema{Columns: []ColumnInfo{
+		{Name: "seen_at", UDTName: "timestamp"},
+		{Name: "inserted_at", UDTName: "timestamp"},
+		{Name: "created_at", UDTName: "timestamp"},
+		{Name: "day", UDTName: "date"},
+	}}, "RANGE (day)")
+
+	var names []string
+	for _, candidate := range candidates {
+		names = append(names, candidate.Name)
+	}
+	if want := []string{"day", "created_at", "inserted_at", "seen_at"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("candidates = %v, want %v", names, want)
+	}
+}
+
+func TestEnsureDateColumn(t *testing.T) {
+	schemaRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).
+			AddRow("id", "bigint", "int8").
+			AddRow("created_at", "timestamp with time zone", "timestamptz").
+			AddRow("inserted_at", "timestamp with time zone", "timestamptz")
+	}
+
+	t.Run("picks the partition key", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		archiver := NewArchiver(&Config{Table: "events"}, newTestLogger())
+		archiver.db = db
+
+		mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events").WillReturnRows(schemaRows())
+		mock.ExpectQuery(`SELECT pg_get_partkeydef`).WithArgs("public", "events").
+			WillReturnRows(sqlmock.NewRows([]string{"pg_get_partkeydef"}).AddRow("RANGE (inserted_at)"))
+
+		if err := archiver.ensureDateColumn(context.Background(), "events_2024_01"); err != nil {
+			t.Fatalf("ensureDateColumn() error = %v", err)
+		}
+		if archiver.config.DateColumn != "inserted_at" {
+			t.Errorf("expected inserted_at, got %q", archiver.config.DateColumn)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("lists candidates when ambiguous", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		archiver := NewArchiver(&Config{Table: "events"}, newTestLogger())
+		archiver.db = db
+
+		mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events").WillReturnRows(schemaRows())
+		mock.ExpectQuery(`SELECT pg_get_partkeydef`).WithArgs("public", "events").
+			WillReturnRows(sqlmock.NewRows([]string{"pg_get_partkeydef"}))
+
+		err = archiver.ensureDateColumn(context.Background(), "")
+		if !errors.Is(err, ErrDateColumnAmbiguous) {
+			t.Fatalf("expected ErrDateColumnAmbiguous, got %v", err)
+		}
+		if want := "created_at (timestamp), inserted_at (timestamp)"; !strings.Contains(err.Error(), want) {
+			t.Errorf("expected candidates %q in error, got %v", want, err)
+		}
+		if archiver.config.DateColumn != "" {
+			t.Errorf("expected no date column, got %q", archiver.config.DateColumn)
+		}
+	})
+
+	t.Run("configured column is kept", func(t *testing.T) {
+		archiver := NewArchiver(&Config{Table: "events", DateColumn: "ts"}, newTestLogger())
+		if err := archiver.ensureDateColumn(context.Background(), ""); err != nil || archiver.config.DateColumn != "ts" {
+			t.Errorf("expected ts to be kept, got %q, %v", archiver.config.DateColumn, err)
+		}
+	})
+
+	t.Run("epoch columns are not inferred", func(t *testing.T) {
+		archiver := NewArchiver(&Config{Table: "events", DateColumnType: DateColumnEpoch}, newTestLogger())
+		if err := archiver.ensureDateColumn(context.Background(), ""); !errors.Is(err, ErrDateColumnTypeNotInferred) {
+			t.Errorf("expected ErrDateColumnTypeNotInferred, got %v", err)
+		}
+	})
+}
+
+func TestDoDiscoverFindsDateColumn(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	config := &Config{Table: "events", StartDate: "2024-01-01", EndDate: "2024-01-31", OutputDuration: DurationDaily}
+	archiver := NewArchiver(config, newTestLogger())
+	archiver.db = db
+	m := &progressModel{config: config, archiver: archiver, log: newLogPane(10)}
+
+	// A table without partitions is sliced by the column the TUI found
+	mock.ExpectQuery(`SELECT tablename`).WithArgs("public", "events", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename"}))
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).
+			AddRow("id", "bigint", "int8").
+			AddRow("created_at", "timestamp with time zone", "timestamptz"))
+	mock.ExpectQuery(`SELECT pg_get_partkeydef`).WithArgs("public", "events").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_get_partkeydef"}))
+
+	msg, ok := m.doDiscover()().(partitionsFoundMsg)
+	if !ok {
+		t.Fatalf("expected partitionsFoundMsg, got %#v", msg)
+	}
+	if config.DateColumn != "created_at" {
+		t.Errorf("expected created_at, got %q", config.DateColumn)
+	}
+	if len(msg.partitions) != 1 || msg.partitions[0].TableName != "events" || !msg.partitions[0].HasCustomRange() {
+		t.Errorf("expected one sliced range over events, got %+v", msg.partitions)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDateColumnSpecValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    DateColumnSpec
+		wantErr error
+	}{
+		{"Default", DateColumnSpec{Name: "created_at"}, nil},
+		{"Epoch", DateColumnSpec{Name: "ts", Type: DateColumnEpochMillis}, nil},
+		{"TextISO", DateColumnSpec{Name: "ts", Type: DateColumnText, Format: "2006-01-02T15:04:05.000Z"}, nil},
+		{"TextCompactDate", DateColumnSpec{Name: "day", Type: DateColumnText, Format: "20060102"}, nil},
+		{"UnknownType", DateColumnSpec{Name: "ts", Type: "datetime"}, ErrDateColumnTypeInvalid},
+		{"TextWithoutFormat", DateColumnSpec{Name: "ts", Type: DateColumnText}, ErrDateColumnFormatRequired},
+		{"FormatWithoutText", DateColumnSpec{Name: "ts", Type: DateColumnDate, Format: "2006-01-02"}, ErrDateColumnFormatUnused},
+		{"DayFirst", DateColumnSpec{Name: "ts", Type: DateColumnText, Format: "02/01/2006"}, ErrDateColumnFormatUnsorted},
+		{"SkipsMonth", DateColumnSpec{Name: "ts", Type: DateColumnText, Format: "2006-02"}, ErrDateColumnFormatUnsorted},
+		{"MonthName", DateColumnSpec{Name: "ts", Type: DateColumnText, Format: "2006-Jan-02"}, ErrDateColumnFormatUnsorted},
+		{"Unpadded", DateColumnSpec{Name: "ts", Type: DateColumnText, Format: "2006-1-2"}, ErrDateColumnFormatUnsorted},
+		{"Zone", DateColumnSpec{Name: "ts", Type: DateColumnText, Format: "2006-01-02 15:04:05-07"}, ErrDateColumnFormatUnsorted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.spec.Validate(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDateColumnSpecRangeCondition(t *testing.T) {
+	start := time.Date(2024, 3, 15, 1, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		spec      DateColumnSpec
+		condition string
+		args      []interface{}
+	}{
+		{"Timestamp", DateColumnSpec{Name: "ts"}, `"ts" >= $1 AND "ts" < $2`, []interface{}{start, end}},
+		{"Date", DateColumnSpec{Name: "day", Type: DateColumnDate}, `"day" >= $1::timestamp AND "day" < $2::timestamp`,
+			[]interface{}{"2024-03-15 01:00:00", "2024-03-15 02:00:00"}},
+		{"Epoch", DateColumnSpec{Name: "ts", Type: DateColumnEpoch}, `"ts" >= $1 AND "ts" < $2`,
+			[]interface{}{start.Unix(), end.Unix()}},
+		{"EpochMillis", DateColumnSpec{Name: "ts", Type: DateColumnEpochMillis}, `"ts" >= $1 AND "ts" < $2`,
+			[]interface{}{start.UnixMilli(), end.UnixMilli()}},
+		{"Text", DateColumnSpec{Name: "ts", Type: DateColumnText, Format: "2006-01-02 15:04"}, `"ts" >= $1 AND "ts" < $2`,
+			[]interface{}{"2024-03-15 01:00", "2024-03-15 02:00"}},
+		// Hourly slices of a day-granular text column: the first hour holds the day, later hours are empty
+		{"TextCoarserThanSlice", DateColumnSpec{Name: "day", Type: DateColumnText, Format: "2006-01-02"}, `"day" >= $1 AND "day" < $2`,
+			[]interface{}{"2024-03-16", "2024-03-16"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			condition, args := tt.spec.rangeCondition(start, end)
+			if condition != tt.condition {
+				t.Errorf("condition = %s, want %s", condition, tt.condition)
+			}
+			if len(args) != len(tt.args) || args[0] != tt.args[0] || args[1] != tt.args[1] {
+				t.Errorf("args = %v, want %v", args, tt.args)
+			}
+		})
+	}
+
+	// The first hour of the day-granular column selects the whole day
+	dayStart := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	_, args := DateColumnSpec{Name: "day", Type: DateColumnText, Format: "2006-01-02"}.rangeCondition(dayStart, dayStart.Add(time.Hour))
+	if args[0] != "2024-03-15" || args[1] != "2024-03-16" {
+		t.Errorf("first hour args = %v", args)
+	}
+}
+
+func TestCeilEpoch(t *testing.T) {
+	at := time.Unix(100, 1)
+	if got := ceilEpoch(at, time.Second); got != 101 {
+		t.Errorf("ceilEpoch(seconds) = %d, want 101", got)
+	}
+	if got := ceilEpoch(time.Unix(100, 0), time.Second); got != 100 {
+		t.Errorf("ceilEpoch(exact) = %d, want 100", got)
+	}
+	if got := ceilEpoch(at, time.Millisecond); got != 100001 {
+		t.Errorf("ceilEpoch(millis) = %d, want 100001", got)
+	}
+}
+
+func TestCheckPartitionDatesEpochColumn(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	archiver := NewArchiver(&Config{Table: "events", DateColumn: "ts", DateColumnType: DateColumnEpochMillis}, newTestLogger())
+	archiver.db = db
+	day := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT to_timestamp\(min\("ts"\) / 1000\.0\), to_timestamp\(max\("ts"\) / 1000\.0\), count\(\*\) FILTER \(WHERE NOT \("ts" >= \$1 AND "ts" < \$2\)\) FROM "events_20240105"`).
+		WithArgs(day.UnixMilli(), day.AddDate(0, 0, 1).UnixMilli()).
+		WillReturnRows(sqlmock.NewRows([]string{"min", "max", "count"}).AddRow(day, day.Add(time.Hour), int64(0)))
+
+	check, err := archiver.checkPartitionDates(context.Background(), PartitionInfo{TableName: "events_20240105", Date: day})
+	if err != nil {
+		t.Fatalf("checkPartitionDates() error = %v", err)
+	}
+	if check.Mismatch() || !check.MaxDate.Equal(day.Add(time.Hour)) {
+		t.Errorf("unexpected check: %+v", check)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestDateColumnSpecBoundTime(t *testing.T) {
+	spec := DateColumnSpec{Name: "ts", Type: DateColumnText, Format: "2006-01-02"}
+	if got := spec.boundTime([]byte("2024-03-15")); !got.Equal(time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("boundTime(text) = %v", got)
+	}
+	if got := spec.boundTime("garbage"); !got.IsZero() {
+		t.Errorf("boundTime(malformed) = %v, want zero", got)
+	}
+	if got := spec.boundTime(nil); !got.IsZero() {
+		t.Errorf("boundTime(NULL) = %v, want zero", got)
+	}
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// Static errors for database guardrails
+var (
+	ErrMaxParallelQueriesInvalid = errors.New("max parallel queries must be >= 0")
+	ErrWorkMemInvalid            = errors.New("work_mem must be a PostgreSQL memory size such as 64MB")
+	ErrMaxParallelWorkersInvalid = errors.New("max parallel workers must be >= -1")
+)
+
+// defaultApplicationName identifies archiver sessions in pg_stat_activity
+const defaultApplicationName = "data-archiver"
+
+// workMemPattern matches PostgreSQL memory sizes (a bare number is in kB)
+var workMemPattern = regexp.MustCompile(`^[0-9]+\s*(kB|MB|GB|TB)?$`)
+
+var (
+	maxParallelQueries   int
+	dbWorkMem            string
+	dbMaxParallelWorkers int
+	dbApplicationName    string
+	dbQueryComments      bool
+)
+
+func init() {
+	archiveCmd.Flags().IntVar(&maxParallelQueries, "max-parallel-queries", 0, "most extraction queries running at once across all partitions and tables; uploads don't hold a slot (0 = no limit)")
+	archiveCmd.Flags().StringVar(&dbWorkMem, "db-work-mem", "", "work_mem for archiver sessions, e.g. 64MB (empty = server default)")
+	archiveCmd.Flags().IntVar(&dbMaxParallelWorkers, "db-max-parallel-workers", -1, "max_parallel_workers_per_gather for archiver sessions; 0 disables parallel query (-1 = server default)")
+	archiveCmd.Flags().StringVar(&dbApplicationName, "db-application-name", defaultApplicationName, "application_name reported for archiver sessions in pg_stat_activity")
+	archiveCmd.Flags().BoolVar(&dbQueryComments, "db-query-comments", false, "prefix extraction queries with a /* data-archiver table=... partition=... */ comment")
+
+	_ = viper.BindPFlag("max_parallel_queries", archiveCmd.Flags().Lookup("max-parallel-queries"))
+	_ = viper.BindPFlag("db.work_mem", archiveCmd.Flags().Lookup("db-work-mem"))
+	_ = viper.BindPFlag("db.max_parallel_workers", archiveCmd.Flags().Lookup("db-max-parallel-workers"))
+	_ = viper.BindPFlag("db.application_name", archiveCmd.Flags().Lookup("db-application-name"))
+	_ = viper.BindPFlag("db.query_comments", archiveCmd.Flags().Lookup("db-query-comments"))
+}
+
+// validateGuardrails checks the session settings and query limit
+func (c *Config) validateGuardrails() error {
+	if c.MaxParallelQueries < 0 {
+		return fmt.Errorf("%w, got %d", ErrMaxParallelQueriesInvalid, c.MaxParallelQueries)
+	}
+	if c.Database.WorkMem != "" && !workMemPattern.MatchString(c.Database.WorkMem) {
+		return fmt.Errorf("%w, got '%s'", ErrWorkMemInvalid, c.Database.WorkMem)
+	}
+	if c.Database.MaxParallelWorkers < -1 {
+		return fmt.Errorf("%w, got %d", ErrMaxParallelWorkersInvalid, c.Database.MaxParallelWorkers)
+	}
+	return nil
+}
+
+// sessionParams returns the run-time parameters added to the connection
+// string, so every pooled session starts with them
+func (d DatabaseConfig) sessionParams() string {
+	var params []string
+	if d.ApplicationName != "" {
+		params = append(params, "application_name="+quoteConnValue(d.ApplicationName))
+	}
+	if d.WorkMem != "" {
+		params = append(params, "work_mem="+quoteConnValue(d.WorkMem))
+	}
+	if d.MaxParallelWorkers >= 0 {
+		params = append(params, fmt.Sprintf("max_parallel_workers_per_gather=%d", d.MaxParallelWorkers))
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return " " + strings.Join(params, " ")
+}
+
+// quoteConnValue quotes a value for a key=value connection string
+func quoteConnValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// tagQuery prefixes an extraction query with a comment naming the table and
+// partition when --db-query-comments is set, so DBAs can attribute it in
+// pg_stat_activity and slow query logs
+func (a *Archiver) tagQuery(query, partition string) string {
+	if !a.config.Database.QueryComments {
+		return query
+	}
+	comment := fmt.Sprintf("data-archiver table=%s partition=%s", a.config.Table, partition)
+	return "/* " + strings.ReplaceAll(comment, "*/", "* /") + " */ " + query
+}
+
+// querySlots bounds the extraction queries running at once. It is shared by
+// every table of a multi-table run, since they query the same database.
+var querySlots struct {
+	mu    sync.Mutex
+	slots chan struct{}
+}
+
+// acquireQuerySlot waits for one of --max-parallel-queries slots and returns
+// the function that releases it. Without a limit it returns immediately.
+func (a *Archiver) acquireQuerySlot(ctx context.Context) (func(), error) {
+	limit := a.config.MaxParallelQueries
+	if limit <= 0 {
+		return func() {}, nil
+	}
+
+	querySlots.mu.Lock()
+	if querySlots.slots == nil || cap(querySlots.slots) != limit {
+		querySlots.slots = make(chan struct{}, limit)
+	}
+	slots := querySlots.slots
+	querySlots.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+	default:
+		a.logger.Debug(fmt.Sprintf("Waiting for one of %d database query slots", limit))
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return func() { <-slots }, nil
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessionParams(t *testing.T) {
+	db := DatabaseConfig{MaxParallelWorkers: -1}
+	if params := db.sessionParams(); params != "" {
+		t.Errorf("expected no session settings by default, got %q", params)
+	}
+
+	db = DatabaseConfig{ApplicationName: "archiver's job", WorkMem: "64MB", MaxParallelWorkers: 0}
+	want := ` application_name='archiver\'s job' work_mem='64MB' max_parallel_workers_per_gather=0`
+	if params := db.sessionParams(); params != want {
+		t.Errorf("sessionParams() = %q, want %q", params, want)
+	}
+}
+
+func TestValidateGuardrails(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   error
+	}{
+		{"defaults", func(c *Config) {}, nil},
+		{"work_mem with unit", func(c *Config) { c.Database.WorkMem = "256 MB" }, nil},
+		{"work_mem in kB", func(c *Config) { c.Database.WorkMem = "4096" }, nil},
+		{"bad work_mem", func(c *Config) { c.Database.WorkMem = "lots" }, ErrWorkMemInvalid},
+		{"negative query limit", func(c *Config) { c.MaxParallelQueries = -1 }, ErrMaxParallelQueriesInvalid},
+		{"bad parallel workers", func(c *Config) { c.Database.MaxParallelWorkers = -2 }, ErrMaxParallelWorkersInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig()
+			config.Database.MaxParallelWorkers = -1
+			tt.modify(config)
+			err := config.Validate()
+			if tt.want == nil && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestTagQuery(t *testing.T) {
+	archiver := NewArchiver(&Config{Table: "events"}, newTestLogger())
+	if got := archiver.tagQuery("SELECT 1", "events_20240101"); got != "SELECT 1" {
+		t.Errorf("expected untagged query, got %q", got)
+	}
+	archiver.config.Database.QueryComments = true
+	if got := archiver.tagQuery("SELECT 1", "events_*/_x"); got != "/* data-archiver table=events partition=events_* /_x */ SELECT 1" {
+		t.Errorf("unexpected tagged query %q", got)
+	}
+}
+
+func TestAcquireQuerySlot(t *testing.T) {
+	first := NewArchiver(&Config{Table: "events", MaxParallelQueries: 1}, newTestLogger())
+	second := NewArchiver(&Config{Table: "orders", MaxParallelQueries: 1}, newTestLogger())
+
+	release, err := first.acquireQuerySlot(context.Background())
+	if err != nil {
+		t.Fatalf("acquireQuerySlot() error = %v", err)
+	}
+
+	// Another table's archiver waits for the shared slot
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := second.acquireQuerySlot(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected to wait for the slot, got %v", err)
+	}
+
+	release()
+	releaseSecond, err := second.acquireQuerySlot(context.Background())
+	if err != nil {
+		t.Fatalf("expected the released slot, got %v", err)
+	}
+	releaseSecond()
+
+	// Without a limit no slot is taken
+	unlimited := NewArchiver(&Config{Table: "events"}, newTestLogger())
+	for i := 0; i < 3; i++ {
+		if _, err := unlimited.acquireQuerySlot(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/airframesio/data-archiver/cmd/formatters"
+	pq "github.com/lib/pq"
+	"github.com/spf13/viper"
+)
+
+// Extraction methods
+const (
+	extractMethodSelect = "select" // SELECT, scanning each row through database/sql (default)
+	extractMethodCopy   = "copy"   // COPY (...) TO STDOUT, streamed through psql
+)
+
+// Static errors for COPY extraction
+var (
+	ErrExtractMethodInvalid = errors.New("extract method must be one of: select, copy")
+	ErrPsqlNotFound         = errors.New("psql not found in PATH; --extract-method=copy streams COPY output through psql (install the PostgreSQL client tools or use --extract-method=select)")
+	ErrCopyRowInvalid       = errors.New("invalid COPY row")
+)
+
+var extractMethod string
+
+// psqlCommand is the client that runs COPY; lib/pq does not support COPY TO
+var psqlCommand = "psql"
+
+func init() {
+	archiveCmd.Flags().StringVar(&extractMethod, "extract-method", extractMethodSelect, "how rows are read: select (row by row through the driver) or copy (COPY TO STDOUT through psql, faster for wide tables)")
+	_ = viper.BindPFlag("extract_method", archiveCmd.Flags().Lookup("extract-method"))
+}
+
+// validateExtractMethod checks --extract-method, and for copy that psql is
+// installed, so a run fails before any partition rather than on each one
+func (c *Config) validateExtractMethod() error {
+	switch c.ExtractMethod {
+	case "", extractMethodSelect:
+		return nil
+	case extractMethodCopy:
+		if detectPgClientTools(exec.LookPath).Psql == "" {
+			return ErrPsqlNotFound
+		}
+		return nil
+	}
+	return fmt.Errorf("%w, got '%s'", ErrExtractMethodInvalid, c.ExtractMethod)
+}
+
+// extractRows is the row stream read by extractPartitionDataStreaming.
+// *sql.Rows implements it for SELECT extraction, copyRows for COPY.
+type extractRows interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+	Close() error
+}
+
+// queryArgPattern matches the $N placeholders of extraction queries
+var queryArgPattern = regexp.MustCompile(`\$([0-9]+)`)
+
+// inlineQueryArgs replaces $N placeholders with quoted literals, since COPY
+// takes no parameters. Values are written the way lib/pq sends parameters: as
+// text of unknown type, so PostgreSQL resolves them exactly as it would $N.
+func inlineQueryArgs(query string, args []interface{}) string {
+	if len(args) == 0 {
+		return query
+	}
+	return queryArgPattern.ReplaceAllStringFunc(query, func(placeholder string) string {
+		n, err := strconv.Atoi(placeholder[1:])
+		if err != nil || n < 1 || n > len(args) {
+			return placeholder
+		}
+		var text string
+		switch v := args[n-1].(type) {
+		case time.Time:
+			text = string(pq.FormatTimestamp(v))
+		case []byte:
+			text = string(v)
+		default:
+			text = fmt.Sprint(v)
+		}
+		return pq.QuoteLiteral(text)
+	})
+}
+
+// psqlConnInfo returns the libpq connection string for psql, with the same
+// session settings as the archiver's own connections. The password is passed
+// in PGPASSWORD so it doesn't show up in the process list.
+func (d DatabaseConfig) psqlConnInfo(timeZone string) string {
+	sslMode := d.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	params := []string{
+		"host=" + quoteConnValue(d.Host),
+		fmt.Sprintf("port=%d", d.Port),
+		"user=" + quoteConnValue(d.User),
+		"dbname=" + quoteConnValue(d.Name),
+		"sslmode=" + quoteConnValue(sslMode),
+	}
+	if d.ApplicationName != "" {
+		params = append(params, "application_name="+quoteConnValue(d.ApplicationName))
+	}
+
+	// Run-time settings go in options as -c name=value, with spaces escaped
+	var options []string
+	setting := func(name, value string) {
+		options = append(options, "-c "+name+"="+strings.ReplaceAll(value, " ", `\ `))
+	}
+	if d.StatementTimeout > 0 {
+		setting("statement_timeout", strconv.Itoa(d.StatementTimeout*1000))
+	}
+	if d.WorkMem != "" {
+		setting("work_mem", d.WorkMem)
+	}
+	if d.MaxParallelWorkers >= 0 {
+		setting("max_parallel_workers_per_gather", strconv.Itoa(d.MaxParallelWorkers))
+	}
+	if timeZone != "" {
+		setting("TimeZone", timeZone)
+	}
+	if len(options) > 0 {
+		params = append(params, "options="+quoteConnValue(strings.Join(options, " ")))
+	}
+	return strings.Join(params, " ")
+}
+
+// copyRows streams the rows of COPY (query) TO STDOUT from psql in text format
+// and decodes each value to the type lib/pq would have returned for it, so the
+// formatters see the same values whichever extraction method is used
+type copyRows struct {
+	cmd      *exec.Cmd
+	cancel   context.CancelFunc
+	stdout   *bufio.Reader
+	stderr   bytes.Buffer
+	types    []string
+	location *time.Location // Session time zone, for timestamptz values
+	values   []interface{}
+	err      error
+	done     bool
+}
+
+// queryCopy starts COPY for an extraction query. The session time zone of the
+// archiver's connection is reused so timestamptz values match SELECT output.
+func (a *Archiver) queryCopy(ctx context.Context, query string, args []interface{}, columns []formatters.ColumnSchema) (*copyRows, error) {
+	path := detectPgClientTools(exec.LookPath).Psql
+	if path == "" {
+		return nil, ErrPsqlNotFound
+	}
+
+	var timeZone string
+	if err := a.db.QueryRowContext(ctx, "SELECT current_setting('TimeZone')").Scan(&timeZone); err != nil {
+		return nil, fmt.Errorf("failed to read session time zone: %w", err)
+	}
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		location = nil // ParseTimestamp then keeps each value's own offset
+	}
+
+	types := make([]string, len(columns))
+	for i, col := range columns {
+		types[i] = col.GetType()
+	}
+	return startCopy(ctx, path, a.config.Database, timeZone, inlineQueryArgs(query, args), types, location)
+}
+
+func startCopy(ctx context.Context, path string, database DatabaseConfig, timeZone, query string, types []string, location *time.Location) (*copyRows, error) {
+	copyCtx, cancel := context.WithCancel(ctx)
+	//nolint:gosec // G204: psql is run with a fixed argument layout; the query is built from quoted identifiers and literals
+	cmd := exec.CommandContext(copyCtx, path,
+		"-X", "-q", "-w",
+		"-v", "ON_ERROR_STOP=1",
+		"-d", database.psqlConnInfo(timeZone),
+		"-c", "COPY ("+query+") TO STDOUT",
+	)
+	cmd.Env = append(os.Environ(), "PGPASSWORD="+database.Password)
+
+	rows := &copyRows{cmd: cmd, cancel: cancel, types: types, location: location}
+	cmd.Stderr = &rows.stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to start psql: %w", err)
+	}
+	rows.stdout = bufio.NewReaderSize(stdout, 1024*1024)
+	return rows, nil
+}
+
+// Next reads and decodes the next row
+func (r *copyRows) Next() bool {
+	if r.done {
+		return false
+	}
+	line, err := r.stdout.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		// Rows longer than the buffer are read in pieces
+		long := append([]byte(nil), line...)
+		for errors.Is(err, bufio.ErrBufferFull) {
+			line, err = r.stdout.ReadSlice('\n')
+			long = append(long, line...)
+		}
+		line = long
+	}
+	if err != nil {
+		if err == io.EOF && len(line) == 0 {
+			r.finish(nil)
+		} else {
+			r.finish(fmt.Errorf("failed to read COPY output: %w", err))
+		}
+		return false
+	}
+
+	values, err := decodeCopyRow(line[:len(line)-1], r.types, r.location)
+	if err != nil {
+		r.finish(err)
+		return false
+	}
+	r.values = values
+	return true
+}
+
+// Scan copies the current row into dest, which must be *interface{} values
+func (r *copyRows) Scan(dest ...interface{}) error {
+	if len(dest) != len(r.values) {
+		return fmt.Errorf("%w: expected %d destination arguments, got %d", ErrCopyRowInvalid, len(r.values), len(dest))
+	}
+	for i, d := range dest {
+		p, ok := d.(*interface{})
+		if !ok {
+			return fmt.Errorf("%w: destination %d is %T, not *interface{}", ErrCopyRowInvalid, i, d)
+		}
+		*p = r.values[i]
+	}
+	return nil
+}
+
+// Err returns the error that ended the rows, including psql's message
+func (r *copyRows) Err() error {
+	return r.err
+}
+
+// Close stops psql if the rows were not read to the end
+func (r *copyRows) Close() error {
+	if !r.done {
+		r.done = true
+		r.cancel()
+		_ = r.cmd.Wait()
+	}
+	return nil
+}
+
+// finish waits for psql and records why the rows ended
+func (r *copyRows) finish(err error) {
+	r.done = true
+	if err != nil {
+		r.cancel()
+	}
+	waitErr := r.cmd.Wait()
+	r.cancel()
+	if err == nil && waitErr != nil {
+		err = fmt.Errorf("psql failed: %w", waitErr)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(r.stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+	}
+	r.err = err
+}
+
+// decodeCopyRow splits one line of COPY text output into its values
+func decodeCopyRow(line []byte, types []string, location *time.Location) ([]interface{}, error) {
+	fields := bytes.Split(line, []byte{'\t'})
+	if len(fields) != len(types) {
+		return nil, fmt.Errorf("%w: expected %d columns, got %d", ErrCopyRowInvalid, len(types), len(fields))
+	}
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		if len(field) == 2 && field[0] == '\\' && field[1] == 'N' {
+			continue // NULL
+		}
+		value, err := decodeCopyValue(unescapeCopyText(field), types[i], location)
+		if err != nil {
+			return nil, fmt.Errorf("%w: column %d (%s): %v", ErrCopyRowInvalid, i+1, types[i], err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// unescapeCopyText undoes the backslash escapes of COPY text format
+func unescapeCopyText(field []byte) []byte {
+	if bytes.IndexByte(field, '\\') < 0 {
+		return field
+	}
+	out := make([]byte, 0, len(field))
+	for i := 0; i < len(field); i++ {
+		c := field[i]
+		if c != '\\' || i+1 == len(field) {
+			out = append(out, c)
+			continue
+		}
+		i++
+		switch c = field[i]; c {
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'v':
+			out = append(out, '\v')
+		case 'x':
+			// \x followed by one or two hex digits
+			j := i + 1
+			for j < len(field) && j < i+3 && isHexDigit(field[j]) {
+				j++
+			}
+			if j == i+1 {
+				out = append(out, 'x')
+				continue
+			}
+			v, _ := strconv.ParseUint(string(field[i+1:j]), 16, 8)
+			out = append(out, byte(v))
+			i = j - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			// One to three octal digits
+			j := i
+			for j < len(field) && j < i+3 && field[j] >= '0' && field[j] <= '7' {
+				j++
+			}
+			v, _ := strconv.ParseUint(string(field[i:j]), 8, 16)
+			out = append(out, byte(v))
+			i = j - 1
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// decodeCopyValue converts the text of a value to the Go type lib/pq returns
+// for its column type; types lib/pq leaves undecoded stay []byte
+func decodeCopyValue(text []byte, pgType string, location *time.Location) (interface{}, error) {
+	switch pgType {
+	case "char", "varchar", "text":
+		return string(text), nil
+	case "bytea":
+		return decodeCopyBytea(text)
+	case "timestamptz":
+		return pq.ParseTimestamp(location, string(text))
+	case "timestamp", "date":
+		return pq.ParseTimestamp(nil, string(text))
+	case "time":
+		return time.Parse("15:04:05", string(text))
+	case "timetz":
+		layout := "15:04:05-07"
+		// Offsets may carry minutes and seconds, e.g. +05:30
+		for n := 3; n <= 6 && len(text) > n && text[len(text)-n] == ':'; n += 3 {
+			layout += ":00"
+		}
+		return time.Parse(layout, string(text))
+	case "bool":
+		return len(text) > 0 && text[0] == 't', nil
+	case "int2", "int4", "int8":
+		return strconv.ParseInt(string(text), 10, 64)
+	case "float4", "float8":
+		return strconv.ParseFloat(string(text), 64)
+	}
+	return append([]byte(nil), text...), nil
+}
+
+// decodeCopyBytea decodes bytea output in hex (\x...) or escape format
+func decodeCopyBytea(text []byte) ([]byte, error) {
+	if bytes.HasPrefix(text, []byte(`\x`)) {
+		out := make([]byte, hex.DecodedLen(len(text)-2))
+		if _, err := hex.Decode(out, text[2:]); err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+	out := make([]byte, 0, len(text))
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' {
+			out = append(out, text[i])
+			continue
+		}
+		if i+1 < len(text) && text[i+1] == '\\' {
+			out = append(out, '\\')
+			i++
+			continue
+		}
+		if i+3 >= len(text) {
+			return nil, fmt.Errorf("invalid bytea escape %q", text[i:])
+		}
+		v, err := strconv.ParseUint(string(text[i+1:i+4]), 8, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bytea escape %q", text[i:i+4])
+		}
+		out = append(out, byte(v))
+		i += 3
+	}
+	return out, nil
+}
@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDecodeCopyRow(t *testing.T) {
+	types := []string{"int4", "float8", "bool", "text", "bytea", "timestamptz", "timestamp", "date", "numeric", "jsonb", "text"}
+	line := []byte("42\t1.5\tt\ta\\tb\\\\c\\nd\\101\\x42\t\\\\x0aff\t2024-01-02 03:04:05.5+00\t2024-01-02 03:04:05\t2024-01-02\t12.50\t{\"k\": \"v\"}\t\\N")
+
+	values, err := decodeCopyRow(line, types, time.UTC)
+	if err != nil {
+		t.Fatalf("decodeCopyRow() error = %v", err)
+	}
+	want := []interface{}{
+		int64(42),
+		1.5,
+		true,
+		"a\tb\\c\ndAB",
+		[]byte{0x0a, 0xff},
+		time.Date(2024, 1, 2, 3, 4, 5, 500000000, time.UTC),
+		time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("", 0)),
+		time.Date(2024, 1, 2, 0, 0, 0, 0, time.FixedZone("", 0)),
+		[]byte("12.50"),
+		[]byte(`{"k": "v"}`),
+		nil,
+	}
+	for i := range want {
+		if fmt.Sprintf("%#v", values[i]) != fmt.Sprintf("%#v", want[i]) {
+			if tw, ok := want[i].(time.Time); ok && tw.Equal(values[i].(time.Time)) {
+				continue
+			}
+			t.Errorf("column %d (%s) = %#v, want %#v", i+1, types[i], values[i], want[i])
+		}
+	}
+	if ts := values[5].(time.Time); ts.Location() != time.UTC {
+		t.Errorf("timestamptz should be in the session time zone, got %v", ts.Location())
+	}
+
+	if _, err := decodeCopyRow([]byte("1\t2"), []string{"int4"}, nil); !errors.Is(err, ErrCopyRowInvalid) {
+		t.Errorf("expected ErrCopyRowInvalid for a column count mismatch, got %v", err)
+	}
+	if _, err := decodeCopyRow([]byte("abc"), []string{"int8"}, nil); !errors.Is(err, ErrCopyRowInvalid) {
+		t.Errorf("expected ErrCopyRowInvalid for a bad integer, got %v", err)
+	}
+}
+
+func TestDecodeCopyBytea(t *testing.T) {
+	got, err := decodeCopyBytea([]byte(`a\\b\001`))
+	if err != nil || !bytes.Equal(got, []byte("a\\b\x01")) {
+		t.Errorf("decodeCopyBytea(escape) = %q, %v", got, err)
+	}
+	if _, err := decodeCopyBytea([]byte(`\x0g`)); err == nil {
+		t.Error("expected an error for invalid hex")
+	}
+}
+
+func TestInlineQueryArgs(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	query := `SELECT "id" FROM "events" WHERE "created_at" >= $1 AND "created_at" < $2 AND "kind" = $3 AND "n" > $10`
+	got := inlineQueryArgs(query, []interface{}{start, int64(1704153600), "it's"})
+	want := `SELECT "id" FROM "events" WHERE "created_at" >= '2024-01-01 00:00:00Z' AND "created_at" < '1704153600' AND "kind" = 'it''s' AND "n" > $10`
+	if got != want {
+		t.Errorf("inlineQueryArgs() =\n%s\nwant\n%s", got, want)
+	}
+	if got := inlineQueryArgs(query, nil); got != query {
+		t.Errorf("inlineQueryArgs() without args changed the query: %s", got)
+	}
+}
+
+func TestPsqlConnInfo(t *testing.T) {
+	db := DatabaseConfig{
+		Host:               "db.internal",
+		Port:               5433,
+		User:               "archiver",
+		Password:           "secret",
+		Name:               "events",
+		StatementTimeout:   300,
+		WorkMem:            "64 MB",
+		MaxParallelWorkers: 0,
+		ApplicationName:    "data-archiver",
+	}
+	got := db.psqlConnInfo("Europe/Paris")
+	want := `host='db.internal' port=5433 user='archiver' dbname='events' sslmode='disable' application_name='data-archiver' ` +
+		`options='-c statement_timeout=300000 -c work_mem=64\\ MB -c max_parallel_workers_per_gather=0 -c TimeZone=Europe/Paris'`
+	if got != want {
+		t.Errorf("psqlConnInfo() =\n%s\nwant\n%s", got, want)
+	}
+	if strings.Contains(got, "secret") {
+		t.Error("the password must not be passed on the command line")
+	}
+}
+
+func TestExtractMethodConfig(t *testing.T) {
+	config := newTestConfig()
+	config.ExtractMethod = "binary"
+	if err := config.Validate(); !errors.Is(err, ErrExtractMethodInvalid) {
+		t.Errorf("expected ErrExtractMethodInvalid, got %v", err)
+	}
+
+	// COPY needs psql, which is checked before the run starts
+	original := psqlCommand
+	psqlCommand = "data-archiver-missing-psql"
+	config.ExtractMethod = extractMethodCopy
+	if err := config.Validate(); !errors.Is(err, ErrPsqlNotFound) {
+		t.Errorf("expected ErrPsqlNotFound, got %v", err)
+	}
+	psqlCommand = original
+
+	fakePsql(t, "exit 0\n")
+	for _, method := range []string{"", extractMethodSelect, extractMethodCopy} {
+		config.ExtractMethod = method
+		if err := config.Validate(); err != nil {
+			t.Errorf("%q: unexpected error: %v", method, err)
+		}
+	}
+}
+
+// fakePsql points psqlCommand at a script standing in for psql
+func fakePsql(t *testing.T, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake psql is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "psql")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil { //nolint:gosec // test script must be executable
+		t.Fatal(err)
+	}
+	original := psqlCommand
+	psqlCommand = path
+	t.Cleanup(func() { psqlCommand = original })
+}
+
+func TestExtractPartitionDataStreamingCopy(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	argsFile := filepath.Join(t.TempDir(), "args")
+	fakePsql(t, `printf '%s\n' "$@" > `+argsFile+`
+printf '1\tfirst\n2\t\\N\n3\ttab\\there\n'
+`)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	archiver := NewArchiver(&Config{
+		Table:         "events",
+		OutputFormat:  "jsonl",
+		Compression:   "none",
+		ExtractMethod: extractMethodCopy,
+		DateColumn:    "created_at",
+		Database:      DatabaseConfig{Host: "localhost", Port: 5432, Name: "events", MaxParallelWorkers: -1},
+	}, newTestLogger())
+	archiver.db = db
+	archiver.ctx = context.Background()
+
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events_20240101").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).
+			AddRow("id", "bigint", "int8").
+			AddRow("note", "text", "text"))
+	mock.ExpectQuery(`current_setting\('TimeZone'\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"current_setting"}).AddRow("UTC"))
+
+	cache := &PartitionCache{Entries: make(map[string]PartitionCacheEntry)}
+	partition := PartitionInfo{TableName: "events_20240101", RowCount: 3}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	path, _, _, _, rowCount, err := archiver.extractPartitionDataStreaming(partition, nil, cache, func(string) {}, start, start.AddDate(0, 0, 1), 0, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("extraction failed: %v", err)
+	}
+	defer os.Remove(path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\"id\":1,\"note\":\"first\"}\n{\"id\":2,\"note\":null}\n{\"id\":3,\"note\":\"tab\\there\"}\n"
+	if rowCount != 3 || string(data) != want {
+		t.Errorf("got %d rows:\n%s\nwant:\n%s", rowCount, data, want)
+	}
+
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(args), `COPY (SELECT "id", "note" FROM "events_20240101" WHERE "created_at" >= '2024-01-01 00:00:00Z' AND "created_at" < '2024-01-02 00:00:00Z') TO STDOUT`) ||
+		!strings.Contains(string(args), "TimeZone=UTC") {
+		t.Errorf("unexpected psql arguments:\n%s", args)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExtractPartitionDataStreamingCopyError(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	fakePsql(t, `printf '1\n'
+echo 'ERROR:  canceling statement due to statement timeout' >&2
+exit 1
+`)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	archiver := NewArchiver(&Config{Table: "events", OutputFormat: "jsonl", Compression: "none", ExtractMethod: extractMethodCopy}, newTestLogger())
+	archiver.db = db
+	archiver.ctx = context.Background()
+
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events_20240101").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).AddRow("id", "bigint", "int8"))
+	mock.ExpectQuery(`current_setting\('TimeZone'\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"current_setting"}).AddRow("UTC"))
+
+	cache := &PartitionCache{Entries: make(map[string]PartitionCacheEntry)}
+	_, _, _, _, _, err = archiver.extractPartitionDataStreaming(PartitionInfo{TableName: "events_20240101"}, nil, cache, func(string) {}, time.Time{}, time.Time{}, 0, nil, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "statement timeout") {
+		t.Errorf("expected psql's error, got %v", err)
+	}
+}
+
+func BenchmarkDecodeCopyRow(b *testing.B) {
+	types := []string{"int8", "int4", "float8", "bool", "text", "varchar", "timestamptz", "numeric", "jsonb", "bytea"}
+	line := []byte("123456789\t42\t3.14159\tt\tsome text value\tanother value\t2024-01-02 03:04:05.123456+00\t12345.6789\t{\"key\": \"value\", \"n\": 1}\t\\\\xdeadbeef")
+	b.SetBytes(int64(len(line)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := decodeCopyRow(line, types, time.UTC); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkExtractMethod compares SELECT and COPY extraction of a wide table.
+// It needs a PostgreSQL server and psql, configured with the standard PGHOST,
+// PGPORT, PGUSER, PGPASSWORD and PGDATABASE variables:
+//
+//	PGHOST=localhost PGUSER=postgres go test ./cmd -run '^$' -bench ExtractMethod
+func BenchmarkExtractMethod(b *testing.B) {
+	host := os.Getenv("PGHOST")
+	if host == "" {
+		b.Skip("PGHOST not set")
+	}
+	if _, err := exec.LookPath(psqlCommand); err != nil {
+		b.Skip("psql not found")
+	}
+
+	database := DatabaseConfig{
+		Host:               host,
+		Port:               5432,
+		User:               os.Getenv("PGUSER"),
+		Password:           os.Getenv("PGPASSWORD"),
+		Name:               os.Getenv("PGDATABASE"),
+		SSLMode:            os.Getenv("PGSSLMODE"),
+		MaxParallelWorkers: -1,
+	}
+	if port := os.Getenv("PGPORT"); port != "" {
+		fmt.Sscanf(port, "%d", &database.Port) //nolint:errcheck // falls back to 5432
+	}
+	db, err := sql.Open("postgres", database.psqlConnInfo("")+" password="+quoteConnValue(database.Password))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+
+	const table = "data_archiver_extract_bench"
+	const rows = 200000
+	columns := []string{"id bigint"}
+	selects := []string{"g"}
+	for i := 1; i <= 10; i++ {
+		columns = append(columns, fmt.Sprintf("n%d int4", i), fmt.Sprintf("s%d text", i), fmt.Sprintf("t%d timestamptz", i))
+		selects = append(selects, fmt.Sprintf("g %% 1000 + %d", i), fmt.Sprintf("md5((g + %d)::text)", i), fmt.Sprintf("now() - (g || ' seconds')::interval - '%d days'::interval", i))
+	}
+	if _, err := db.Exec(fmt.Sprintf(`DROP TABLE IF EXISTS %s; CREATE TABLE %s (%s); INSERT INTO %s SELECT %s FROM generate_series(1, %d) g`,
+		table, table, strings.Join(columns, ", "), table, strings.Join(selects, ", "), rows)); err != nil {
+		b.Fatal(err)
+	}
+	defer db.Exec("DROP TABLE " + table) //nolint:errcheck // best-effort cleanup
+
+	b.Setenv("HOME", b.TempDir())
+	for _, method := range []string{extractMethodSelect, extractMethodCopy} {
+		b.Run(method, func(b *testing.B) {
+			archiver := NewArchiver(&Config{Table: table, OutputFormat: "jsonl", Compression: "none", ExtractMethod: method, Database: database}, newTestLogger())
+			archiver.db = db
+			archiver.ctx = context.Background()
+			cache := &PartitionCache{Entries: make(map[string]PartitionCacheEntry)}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				path, _, _, _, n, err := archiver.extractPartitionDataStreaming(PartitionInfo{TableName: table}, nil, cache, func(string) {}, time.Time{}, time.Time{}, 0, nil, nil, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				os.Remove(path)
+				if n != rows {
+					b.Fatalf("extracted %d rows, want %d", n, rows)
+				}
+			}
+			b.ReportMetric(float64(rows)*float64(b.N)/b.Elapsed().Seconds(), "rows/s")
+		})
+	}
+}
@@ -0,0 +1,363 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/airframesio/data-archiver/cmd/formatters"
+	"github.com/spf13/viper"
+)
+
+// Fan-out writer limits
+const (
+	defaultSplitMaxWriters = 32
+	maxSplitMaxWriters     = 1024
+)
+
+// splitNullDirValue stands for NULL in the directory of a --split-by-column file
+const splitNullDirValue = "__null__"
+
+// Static errors for fan-out archiving
+var (
+	ErrSplitByColumnInvalid   = errors.New("split-by column is invalid: must start with a letter or underscore, and contain only letters, numbers, and underscores")
+	ErrSplitByColumnConflict  = errors.New("--split-by-column cannot be combined with --output -")
+	ErrSplitByColumnMissing   = errors.New("split-by column is not in the table")
+	ErrSplitMaxWritersInvalid = errors.New("split max writers must be between 1 and 1024")
+)
+
+var (
+	splitByColumn   string
+	splitMaxWriters int
+)
+
+func init() {
+	archiveCmd.Flags().StringVar(&splitByColumn, "split-by-column", "", "write each archive's rows into a file per value of this column, under <column>=<value>/ next to the archive's key, listed in a manifest")
+	archiveCmd.Flags().IntVar(&splitMaxWriters, "split-max-writers", defaultSplitMaxWriters, "most --split-by-column files open at once; a value whose file was closed for another continues in a new file")
+	_ = viper.BindPFlag("split_by_column", archiveCmd.Flags().Lookup("split-by-column"))
+	_ = viper.BindPFlag("split_max_writers", archiveCmd.Flags().Lookup("split-max-writers"))
+}
+
+// validateSplitByColumn checks the --split-by-column settings
+func (c *Config) validateSplitByColumn() error {
+	if c.SplitByColumn == "" {
+		return nil
+	}
+	if !validPostgreSQLIdentifier.MatchString(c.SplitByColumn) {
+		return fmt.Errorf("%w: '%s'", ErrSplitByColumnInvalid, c.SplitByColumn)
+	}
+	if c.Output == StdoutOutput {
+		return ErrSplitByColumnConflict
+	}
+	if c.SplitMaxWriters < 1 || c.SplitMaxWriters > maxSplitMaxWriters {
+		return fmt.Errorf("%w, got %d", ErrSplitMaxWritersInvalid, c.SplitMaxWriters)
+	}
+	return nil
+}
+
+// splitsArchives reports whether archives are written as parts listed in a
+// manifest: with --max-rows-per-file or --split-by-column
+func (c *Config) splitsArchives() bool {
+	return c.MaxRowsPerFile > 0 || c.SplitByColumn != ""
+}
+
+// fanOutDir returns the directory (column=value) of the files holding a
+// --split-by-column value. Values are path-escaped so they can't add
+// directories of their own.
+func fanOutDir(column string, value interface{}) string {
+	if value == nil {
+		return column + "=" + splitNullDirValue
+	}
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	case time.Time:
+		text = v.UTC().Format(time.RFC3339Nano)
+	default:
+		text = fmt.Sprint(v)
+	}
+	return column + "=" + url.PathEscape(text)
+}
+
+// fanOutObjectKey returns the key of a --split-by-column file: the archive's
+// filename in the value's directory, numbered from the value's second file on.
+// events/2024/01/events-2024-01-01.jsonl.zst → events/2024/01/tenant_id=42/events-2024-01-01.jsonl.zst
+func fanOutObjectKey(objectKey, ext, dir string, number int) string {
+	prefix, name := "", objectKey
+	if i := strings.LastIndex(objectKey, "/"); i >= 0 {
+		prefix, name = objectKey[:i+1], objectKey[i+1:]
+	}
+	if number > 1 {
+		name = fmt.Sprintf("%s-%04d%s", strings.TrimSuffix(name, ext), number, ext)
+	}
+	return prefix + dir + "/" + name
+}
+
+// fanOutFile is the open output file of one --split-by-column value
+type fanOutFile struct {
+	dir          string // column=value directory
+	number       int    // Number of the file among the value's files
+	path         string
+	file         *os.File
+	writer       formatters.StreamWriter
+	compressor   io.WriteCloser
+	hasher       *multipartHasher
+	firstRow     int64 // First of the value's rows the file holds, numbered from 1
+	rows         int64
+	uncompressed int64
+	lastWrite    int64 // Chunk that last wrote to the file, for closing the least recently written
+}
+
+// fanOutWriter is the stream writer of a --split-by-column archive: it
+// routes each row to the file of its value of the column. At most maxOpen
+// files are open at once; opening another closes the least recently written,
+// and that value's later rows go to a new file. With --max-rows-per-file, a
+// value's file is also closed once it holds that many rows. Every closed file
+// is appended to parts.
+type fanOutWriter struct {
+	archiver     *Archiver
+	schema       *TableSchema // Schema the files are written with
+	column       string
+	level        int
+	maxRows      int64
+	maxOpen      int
+	columnNames  []string
+	open         map[string]*fanOutFile
+	files        map[string]int   // Files started per directory
+	written      map[string]int64 // Rows written per directory
+	chunks       int64
+	parts        *[]archivePart
+	uncompressed int64 // Bytes of the files' CSV headers, which the extraction loop doesn't count
+}
+
+// newFanOutWriter returns the fan-out writer of a partition's rows. The
+// column must be in the schema: rows without it would all land in one file.
+func (a *Archiver) newFanOutWriter(schema, outputSchema *TableSchema, level int, parts *[]archivePart) (*fanOutWriter, error) {
+	found := false
+	names := make([]string, len(schema.Columns))
+	for i, col := range schema.Columns {
+		names[i] = col.Name
+		found = found || col.Name == a.config.SplitByColumn
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrSplitByColumnMissing, a.config.SplitByColumn)
+	}
+	maxOpen := a.config.SplitMaxWriters
+	if maxOpen <= 0 {
+		maxOpen = defaultSplitMaxWriters
+	}
+	return &fanOutWriter{
+		archiver:    a,
+		schema:      outputSchema,
+		column:      a.config.SplitByColumn,
+		level:       level,
+		maxRows:     a.config.MaxRowsPerFile,
+		maxOpen:     maxOpen,
+		columnNames: names,
+		open:        make(map[string]*fanOutFile),
+		files:       make(map[string]int),
+		written:     make(map[string]int64),
+		parts:       parts,
+	}, nil
+}
+
+// WriteChunk writes each row to the file of its value, keeping the rows'
+// order within every value. Rows are not retained once it returns.
+func (w *fanOutWriter) WriteChunk(rows []map[string]interface{}) error {
+	w.chunks++
+	var dirs []string
+	groups := make(map[string][]map[string]interface{})
+	for _, row := range rows {
+		dir := fanOutDir(w.column, row[w.column])
+		if _, ok := groups[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		groups[dir] = append(groups[dir], row)
+	}
+	for _, dir := range dirs {
+		group := groups[dir]
+		for len(group) > 0 {
+			f, err := w.file(dir)
+			if err != nil {
+				return err
+			}
+			n := int64(len(group))
+			if w.maxRows > 0 && f.rows+n > w.maxRows {
+				n = w.maxRows - f.rows
+			}
+			if err := f.writer.WriteChunk(group[:n]); err != nil {
+				return fmt.Errorf("failed to write %s: %w", dir, err)
+			}
+			for _, row := range group[:n] {
+				f.uncompressed += calculateUncompressedRowSize(row, w.archiver.config.OutputFormat, w.columnNames)
+			}
+			f.rows += n
+			w.written[dir] += n
+			f.lastWrite = w.chunks
+			group = group[n:]
+			if w.maxRows > 0 && f.rows == w.maxRows {
+				if err := w.closeFile(f); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// file returns the open file of a directory, opening one (and closing the
+// least recently written file when maxOpen are open) if there is none
+func (w *fanOutWriter) file(dir string) (*fanOutFile, error) {
+	if f, ok := w.open[dir]; ok {
+		return f, nil
+	}
+	if len(w.open) >= w.maxOpen {
+		var oldest *fanOutFile
+		for _, f := range w.open {
+			if oldest == nil || f.lastWrite < oldest.lastWrite || f.lastWrite == oldest.lastWrite && f.dir < oldest.dir {
+				oldest = f
+			}
+		}
+		if err := w.closeFile(oldest); err != nil {
+			return nil, err
+		}
+	}
+	return w.openFile(dir)
+}
+
+// openFile starts the next file of a directory, with the same formatter
+// pipeline as an unsplit archive
+func (w *fanOutWriter) openFile(dir string) (*fanOutFile, error) {
+	a := w.archiver
+	file, err := createTempFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	f := &fanOutFile{
+		dir:      dir,
+		number:   w.files[dir] + 1,
+		path:     file.Name(),
+		file:     file,
+		hasher:   newMultipartHasher(a.config.S3.basePartSize()),
+		firstRow: w.written[dir] + 1,
+	}
+	fail := func(err error) (*fanOutFile, error) {
+		if f.compressor != nil {
+			f.compressor.Close()
+		}
+		file.Close()
+		cleanupTempFile(f.path)
+		return nil, err
+	}
+	output := io.MultiWriter(file, f.hasher)
+
+	formatter := a.streamingFormatter()
+	if formatters.UsesInternalCompression(a.config.OutputFormat) {
+		f.writer, err = formatter.NewWriter(output, w.schema)
+	} else {
+		if f.compressor, err = a.newCompressorWriter(output, w.level, f.path); err != nil {
+			return fail(err)
+		}
+		f.writer, err = formatter.NewWriter(f.compressor, w.schema)
+	}
+	if err != nil {
+		return fail(fmt.Errorf("failed to create streaming formatter: %w", err))
+	}
+	f.writer = formatters.NewMappedStreamWriter(f.writer, a.config.FieldMapping)
+	w.files[dir] = f.number
+	w.open[dir] = f
+
+	if a.config.OutputFormat == formatters.FormatCSV {
+		header := int64(len(strings.Join(w.columnNames, ",")) + 1)
+		f.uncompressed += header
+		w.uncompressed += header
+	}
+	return f, nil
+}
+
+// closeFile finalizes a file and appends it to parts. A file that fails to
+// close stays open, for abort to remove.
+func (w *fanOutWriter) closeFile(f *fanOutFile) error {
+	if err := f.writer.Close(); err != nil {
+		return fmt.Errorf("failed to close stream writer: %w", err)
+	}
+	if f.compressor != nil {
+		if err := f.compressor.Close(); err != nil {
+			return fmt.Errorf("failed to close compressor: %w", err)
+		}
+	}
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat temp file: %w", err)
+	}
+	delete(w.open, f.dir)
+	*w.parts = append(*w.parts, archivePart{
+		Number:           len(*w.parts) + 1,
+		Path:             f.path,
+		Size:             info.Size(),
+		MD5:              f.hasher.md5(),
+		UncompressedSize: f.uncompressed,
+		FirstRow:         f.firstRow,
+		LastRow:          f.firstRow + f.rows - 1,
+		SplitDir:         f.dir,
+		SplitFile:        f.number,
+	})
+	return nil
+}
+
+// Close finalizes the open files and orders the parts by value and file
+// number, so the manifest doesn't depend on the order files were closed in.
+// An archive without rows gets a single empty part, as a split archive does.
+func (w *fanOutWriter) Close() error {
+	if len(w.files) == 0 {
+		if _, err := w.openFile(""); err != nil {
+			return err
+		}
+	}
+	dirs := make([]string, 0, len(w.open))
+	for dir := range w.open {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		if err := w.closeFile(w.open[dir]); err != nil {
+			return err
+		}
+	}
+
+	parts := *w.parts
+	sort.SliceStable(parts, func(i, j int) bool {
+		if parts[i].SplitDir != parts[j].SplitDir {
+			return parts[i].SplitDir < parts[j].SplitDir
+		}
+		return parts[i].SplitFile < parts[j].SplitFile
+	})
+	for i := range parts {
+		parts[i].Number = i + 1
+	}
+	return nil
+}
+
+// abort closes and removes the files still open after a failed extraction
+func (w *fanOutWriter) abort() {
+	for dir, f := range w.open {
+		f.writer.Close()
+		if f.compressor != nil {
+			f.compressor.Close()
+		}
+		f.file.Close()
+		cleanupTempFile(f.path)
+		delete(w.open, dir)
+	}
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestFanOutObjectKeys(t *testing.T) {
+	objectKey := "events/2024/01/events-2024-01-01.jsonl.zst"
+	if got := fanOutObjectKey(objectKey, ".jsonl.zst", "tenant_id=42", 1); got != "events/2024/01/tenant_id=42/events-2024-01-01.jsonl.zst" {
+		t.Errorf("fanOutObjectKey() = %s", got)
+	}
+	if got := fanOutObjectKey(objectKey, ".jsonl.zst", "tenant_id=42", 3); got != "events/2024/01/tenant_id=42/events-2024-01-01-0003.jsonl.zst" {
+		t.Errorf("fanOutObjectKey() = %s", got)
+	}
+	if got := fanOutDir("tenant", "acme/eu west"); got != "tenant=acme%2Feu%20west" {
+		t.Errorf("fanOutDir() = %s", got)
+	}
+	if got := fanOutDir("tenant", nil); got != "tenant=__null__" {
+		t.Errorf("fanOutDir(nil) = %s", got)
+	}
+}
+
+func TestValidateSplitByColumn(t *testing.T) {
+	tests := []struct {
+		config Config
+		want   error
+	}{
+		{Config{}, nil},
+		{Config{SplitByColumn: "tenant_id", SplitMaxWriters: 32}, nil},
+		{Config{SplitByColumn: "tenant id", SplitMaxWriters: 32}, ErrSplitByColumnInvalid},
+		{Config{SplitByColumn: "tenant_id", SplitMaxWriters: 0}, ErrSplitMaxWritersInvalid},
+		{Config{SplitByColumn: "tenant_id", SplitMaxWriters: 32, Output: StdoutOutput}, ErrSplitByColumnConflict},
+	}
+	for _, tt := range tests {
+		if err := tt.config.validateSplitByColumn(); !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+			t.Errorf("validateSplitByColumn(%+v) = %v, want %v", tt.config, err, tt.want)
+		}
+	}
+}
+
+func TestExtractPartitionDataStreamingFansOut(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	// Tenants 1 and 2 interleave, so with one writer open each switch
+	// closes a file; tenant 3 has more rows than --max-rows-per-file
+	tests := []struct {
+		name       string
+		maxWriters int
+		maxRows    int64
+		wantKeys   []string
+		wantRows   []int64
+	}{
+		{"OneFilePerValue", 8, 0,
+			[]string{"tenant_id=1/events-2024-01-01.jsonl", "tenant_id=2/events-2024-01-01.jsonl", "tenant_id=3/events-2024-01-01.jsonl", "tenant_id=__null__/events-2024-01-01.jsonl"},
+			[]int64{2, 1, 3, 1}},
+		{"BoundedWriters", 1, 0,
+			[]string{"tenant_id=1/events-2024-01-01.jsonl", "tenant_id=1/events-2024-01-01-0002.jsonl", "tenant_id=2/events-2024-01-01.jsonl", "tenant_id=3/events-2024-01-01.jsonl", "tenant_id=__null__/events-2024-01-01.jsonl"},
+			[]int64{1, 1, 1, 3, 1}},
+		{"MaxRowsPerFile", 8, 2,
+			[]string{"tenant_id=1/events-2024-01-01.jsonl", "tenant_id=2/events-2024-01-01.jsonl", "tenant_id=3/events-2024-01-01.jsonl", "tenant_id=3/events-2024-01-01-0002.jsonl", "tenant_id=__null__/events-2024-01-01.jsonl"},
+			[]int64{2, 1, 2, 1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			archiver := NewArchiver(&Config{
+				Table:           "events",
+				OutputFormat:    "jsonl",
+				Compression:     "none",
+				ChunkSize:       2,
+				SplitByColumn:   "tenant_id",
+				SplitMaxWriters: tt.maxWriters,
+				MaxRowsPerFile:  tt.maxRows,
+			}, newTestLogger())
+			archiver.db = db
+			archiver.ctx = context.Background()
+
+			mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events_20240101").
+				WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).
+					AddRow("id", "bigint", "int8").
+					AddRow("tenant_id", "bigint", "int8"))
+			mock.ExpectQuery(`SELECT "id", "tenant_id" FROM "events_20240101"`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id"}).
+					AddRow(int64(1), int64(1)).
+					AddRow(int64(2), int64(2)).
+					AddRow(int64(3), int64(1)).
+					AddRow(int64(4), int64(3)).
+					AddRow(int64(5), int64(3)).
+					AddRow(int64(6), int64(3)).
+					AddRow(int64(7), nil))
+
+			var parts []archivePart
+			cache := &PartitionCache{Entries: make(map[string]PartitionCacheEntry)}
+			partition := PartitionInfo{TableName: "events_20240101", RowCount: 7}
+			_, _, _, _, total, err := archiver.extractPartitionDataStreaming(partition, nil, cache, func(string) {}, time.Time{}, time.Time{}, 0, nil, &parts, nil)
+			if err != nil {
+				t.Fatalf("extraction failed: %v", err)
+			}
+			defer cleanupParts(parts)
+			if total != 7 {
+				t.Errorf("extracted %d rows, want 7", total)
+			}
+
+			manifest := archiver.newSplitManifest(partition, "events/events-2024-01-01.jsonl", ".jsonl", parts)
+			if len(manifest.Parts) != len(tt.wantKeys) || manifest.TotalRows != 7 || manifest.SplitByColumn != "tenant_id" {
+				t.Fatalf("manifest = %+v", manifest)
+			}
+			for i, part := range manifest.Parts {
+				data, err := os.ReadFile(parts[i].Path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if part.Part != i+1 || part.Key != "events/"+tt.wantKeys[i] || part.Rows != tt.wantRows[i] || int64(bytes.Count(data, []byte("\n"))) != tt.wantRows[i] {
+					t.Errorf("part %d = %+v holding %q", i+1, part, data)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractPartitionDataStreamingFanOutWithoutRows(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	archiver := NewArchiver(&Config{Table: "events", OutputFormat: "jsonl", Compression: "none", SplitByColumn: "tenant_id"}, newTestLogger())
+	archiver.db = db
+	archiver.ctx = context.Background()
+
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events_20240101").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).AddRow("tenant_id", "bigint", "int8"))
+	mock.ExpectQuery(`SELECT "tenant_id" FROM "events_20240101"`).WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}))
+
+	var parts []archivePart
+	cache := &PartitionCache{Entries: make(map[string]PartitionCacheEntry)}
+	partition := PartitionInfo{TableName: "events_20240101"}
+	if _, _, _, _, _, err := archiver.extractPartitionDataStreaming(partition, nil, cache, func(string) {}, time.Time{}, time.Time{}, 0, nil, &parts, nil); err != nil {
+		t.Fatalf("extraction failed: %v", err)
+	}
+	defer cleanupParts(parts)
+	manifest := archiver.newSplitManifest(partition, "events/events-2024-01-01.jsonl", ".jsonl", parts)
+	if len(manifest.Parts) != 1 || manifest.Parts[0].Key != "events/events-2024-01-01-part-0001.jsonl" || manifest.Parts[0].Rows != 0 {
+		t.Errorf("empty fan-out manifest = %+v", manifest)
+	}
+
+	// A column the table lacks fails the partition
+	archiver.config.SplitByColumn = "tenant"
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events_20240102").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).AddRow("tenant_id", "bigint", "int8"))
+	parts = nil
+	if _, _, _, _, _, err := archiver.extractPartitionDataStreaming(PartitionInfo{TableName: "events_20240102"}, nil, cache, func(string) {}, time.Time{}, time.Time{}, 0, nil, &parts, nil); !errors.Is(err, ErrSplitByColumnMissing) {
+		t.Errorf("expected ErrSplitByColumnMissing, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/airframesio/data-archiver/cmd/formatters"
+)
+
+// buildFieldMapping creates the JSONL field mapping from the --field-rename,
+// --camel-case-fields, --flatten-fields and --flatten-separator flags.
+// It returns nil when no mapping was requested.
+func buildFieldMapping(rename map[string]string, camelCase bool, flatten string, separator string) *formatters.FieldMapping {
+	var columns []string
+	for _, column := range strings.Split(flatten, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			columns = append(columns, column)
+		}
+	}
+	return formatters.NewFieldMapping(rename, camelCase, columns, separator)
+}
+
+// validateFieldMapping checks that renamed and flattened names are usable and
+// that the mapping can be reversed on restore
+func validateFieldMapping(m *formatters.FieldMapping) error {
+	fields := make(map[string]string, len(m.Rename))
+	for column, field := range m.Rename {
+		if !validPostgreSQLIdentifier.MatchString(column) {
+			return fmt.Errorf("%w: rename source '%s' is not a valid column name", ErrFieldMappingInvalid, column)
+		}
+		if field == "" {
+			return fmt.Errorf("%w: rename target for '%s' is empty", ErrFieldMappingInvalid, column)
+		}
+		if other, exists := fields[field]; exists {
+			return fmt.Errorf("%w: columns '%s' and '%s' are both renamed to '%s'", ErrFieldMappingInvalid, other, column, field)
+		}
+		fields[field] = column
+	}
+
+	for column := range m.Flatten {
+		if !validPostgreSQLIdentifier.MatchString(column) {
+			return fmt.Errorf("%w: flatten column '%s' is not a valid column name", ErrFieldMappingInvalid, column)
+		}
+		if strings.Contains(m.FieldName(column), m.Separator) {
+			return fmt.Errorf("%w: flattened field '%s' contains the separator '%s'", ErrFieldMappingInvalid, m.FieldName(column), m.Separator)
+		}
+	}
+	return nil
+}
+
+// checkFieldMappingColumns checks that every column's field name maps back to
+// the column on restore. camelCase does not invert for every name: address_1
+// and a__b are written as address1 and aB, which restore to address1 and a_b,
+// and an uppercase column such as Flights restores in lowercase.
+func checkFieldMappingColumns(m *formatters.FieldMapping, schema *TableSchema) error {
+	if m == nil || schema == nil {
+		return nil
+	}
+	for _, col := range schema.Columns {
+		field := m.FieldName(col.Name)
+		if restored := m.ColumnName(field); restored != col.Name {
+			return fmt.Errorf("%w: column '%s' is written as '%s', which restores to '%s'; name its field with --field-rename",
+				ErrFieldMappingInvalid, col.Name, field, restored)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/airframesio/data-archiver/cmd/formatters"
+)
+
+func TestBuildFieldMappingNone(t *testing.T) {
+	if m := buildFieldMapping(map[string]string{}, false, "", "."); m != nil {
+		t.Errorf("expected nil mapping when nothing is configured, got %+v", m)
+	}
+}
+
+func TestFieldMappingRoundTrip(t *testing.T) {
+	m := buildFieldMapping(map[string]string{"id": "messageId"}, true, " metadata ,", "")
+
+	row := map[string]interface{}{
+		"id":          int64(7),
+		"flight_id":   "UAL123",
+		"received_at": "2024-01-15T10:00:00Z",
+		"metadata":    []byte(`{"source":"acars","position":{"lat":51.5,"lon":-0.1}}`),
+	}
+
+	mapped := m.Apply(row)
+	want := map[string]interface{}{
+		"messageId":             int64(7),
+		"flightId":              "UAL123",
+		"receivedAt":            "2024-01-15T10:00:00Z",
+		"metadata.source":       "acars",
+		"metadata.position.lat": 51.5,
+		"metadata.position.lon": -0.1,
+	}
+	if !reflect.DeepEqual(mapped, want) {
+		t.Fatalf("Apply mismatch:\n got %v\nwant %v", mapped, want)
+	}
+
+	restored := m.Reverse(mapped)
+	wantRestored := map[string]interface{}{
+		"id":          int64(7),
+		"flight_id":   "UAL123",
+		"received_at": "2024-01-15T10:00:00Z",
+		"metadata": map[string]interface{}{
+			"source":   "acars",
+			"position": map[string]interface{}{"lat": 51.5, "lon": -0.1},
+		},
+	}
+	if !reflect.DeepEqual(restored, wantRestored) {
+		t.Fatalf("Reverse mismatch:\n got %v\nwant %v", restored, wantRestored)
+	}
+
+	if got := convertValueForPostgreSQL(restored["metadata"], "jsonb"); got != `{"position":{"lat":51.5,"lon":-0.1},"source":"acars"}` {
+		t.Errorf("expected re-nested object to be marshaled to JSON, got %v", got)
+	}
+}
+
+func TestFieldMappingLeavesNonObjectsAlone(t *testing.T) {
+	m := buildFieldMapping(nil, false, "metadata", "__")
+
+	mapped := m.Apply(map[string]interface{}{"metadata": nil, "payload": "x"})
+	if v, ok := mapped["metadata"]; !ok || v != nil {
+		t.Errorf("expected null flatten column to be kept as-is, got %v", mapped)
+	}
+
+	mapped = m.Apply(map[string]interface{}{"metadata": `{"a":{"b":1}}`})
+	if mapped["metadata__a__b"] != float64(1) {
+		t.Errorf("expected custom separator to be used, got %v", mapped)
+	}
+}
+
+func TestMappedStreamWriter(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := formatters.NewJSONLStreamingFormatter().NewWriter(&buf, nil)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+
+	m := buildFieldMapping(nil, true, "", "")
+	writer = formatters.NewMappedStreamWriter(writer, m)
+	if err := writer.WriteChunk([]map[string]interface{}{{"flight_id": "UAL123"}}); err != nil {
+		t.Fatalf("WriteChunk failed: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if got := strings.TrimSpace(buf.String()); got != `{"flightId":"UAL123"}` {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestValidateFieldMapping(t *testing.T) {
+	tests := []struct {
+		name      string
+		rename    map[string]string
+		flatten   string
+		separator string
+		wantErr   error
+	}{
+		{"Valid", map[string]string{"id": "messageId"}, "metadata", ".", nil},
+		{"InvalidSource", map[string]string{"bad-name": "x"}, "", ".", ErrFieldMappingInvalid},
+		{"EmptyTarget", map[string]string{"id": ""}, "", ".", ErrFieldMappingInvalid},
+		{"DuplicateTarget", map[string]string{"id": "key", "uuid": "key"}, "", ".", ErrFieldMappingInvalid},
+		{"InvalidFlatten", nil, "meta data", ".", ErrFieldMappingInvalid},
+		{"FlattenNameHasSeparator", map[string]string{"metadata": "meta.data"}, "metadata", ".", ErrFieldMappingInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateFieldMapping(buildFieldMapping(tt.rename, false, tt.flatten, tt.separator))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfigValidateFieldMappingFormat(t *testing.T) {
+	config := newTestConfig()
+	config.FieldMapping = buildFieldMapping(nil, true, "", "")
+	if err := config.Validate(); err != nil {
+		t.Fatalf("expected jsonl field mapping to be valid, got %v", err)
+	}
+
+	config.OutputFormat = "csv"
+	if err := config.Validate(); !errors.Is(err, ErrFieldMappingFormat) {
+		t.Errorf("expected ErrFieldMappingFormat, got %v", err)
+	}
+}
+
+func TestCheckFieldMappingColumns(t *testing.T) {
+	m := buildFieldMapping(map[string]string{"address_2": "addressLine2"}, true, "", "")
+	for _, column := range []string{"flight_id", "id", "address_2", "received_at"} {
+		schema := &TableSchema{Columns: []ColumnInfo{{Name: column}}}
+		if err := checkFieldMappingColumns(m, schema); err != nil {
+			t.Errorf("%s: %v", column, err)
+		}
+		if restored := m.Reverse(m.Apply(map[string]interface{}{column: 1})); restored[column] != 1 {
+			t.Errorf("%s restored as %v", column, restored)
+		}
+	}
+
+	// Digits after an underscore, double underscores and uppercase letters
+	// do not survive camelCase and back
+	for _, column := range []string{"address_1", "a__b", "Flights", "flightID"} {
+		schema := &TableSchema{Columns: []ColumnInfo{{Name: "id"}, {Name: column}}}
+		if err := checkFieldMappingColumns(m, schema); !errors.Is(err, ErrFieldMappingInvalid) {
+			t.Errorf("%s: expected ErrFieldMappingInvalid, got %v", column, err)
+		}
+	}
+
+	if err := checkFieldMappingColumns(nil, &TableSchema{Columns: []ColumnInfo{{Name: "a__b"}}}); err != nil {
+		t.Errorf("no mapping: %v", err)
+	}
+}
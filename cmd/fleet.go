@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const defaultFleetStaleAfter = 48 * time.Hour
+
+// cacheFileNamePattern matches <command>_<table>_<hash>_metadata.json as written by getCachePath
+var cacheFileNamePattern = regexp.MustCompile(`^([a-z0-9-]+)_(.+)_([0-9a-f]{16})_metadata\.json$`)
+
+// FleetTableStatus summarizes the archive state of a single table across all
+// of its cache scopes
+type FleetTableStatus struct {
+	Table          string      `json:"table"`
+	Files          int         `json:"files"`
+	LatestArchived *time.Time  `json:"latest_archived,omitempty"` // Most recent data date archived
+	LastUpload     *time.Time  `json:"last_upload,omitempty"`
+	BehindHours    float64     `json:"behind_hours"`
+	UpToDate       bool        `json:"up_to_date"`
+	Errors         int         `json:"errors"`
+	BytesLast24h   int64       `json:"bytes_last_24h"`
+	LastRun        *RunSummary `json:"last_run,omitempty"` // Most recent run, reconstructed from its results log
+}
+
+// FleetReport is the consolidated status of every table archived on this host
+type FleetReport struct {
+	GeneratedAt     time.Time          `json:"generated_at"`
+	StaleAfterHours float64            `json:"stale_after_hours"`
+	ArchiverRunning bool               `json:"archiver_running"`
+	PID             int                `json:"pid,omitempty"`
+	CurrentTable    string             `json:"current_table,omitempty"`
+	TablesUpToDate  int                `json:"tables_up_to_date"`
+	TablesBehind    int                `json:"tables_behind"`
+	FurthestBehind  string             `json:"furthest_behind,omitempty"`
+	BytesLast24h    int64              `json:"bytes_last_24h"`
+	InterruptedRuns int                `json:"interrupted_runs"`
+	Tables          []FleetTableStatus `json:"tables"`
+}
+
+// parseCacheFileName extracts the command and table from a scoped cache file name
+func parseCacheFileName(name string) (command, table string, ok bool) {
+	matches := cacheFileNamePattern.FindStringSubmatch(name)
+	if matches == nil {
+		return "", "", false
+	}
+	return matches[1], matches[2], true
+}
+
+// archivedDataDate returns the data date an uploaded cache entry of table
+// covers
+func archivedDataDate(table, key string, entry PartitionCacheEntry) (time.Time, bool) {
+	if !entry.RangeStart.IsZero() {
+		return entry.RangeStart, true
+	}
+	if entry.SourceTable != "" {
+		if date, ok := partitionNameDate(table, entry.SourceTable, time.UTC); ok {
+			return date, true
+		}
+	}
+	return partitionNameDate(table, key, time.UTC)
+}
+
+// buildFleetReport reads every archive cache in cacheDir and summarizes each table.
+// A table is up to date when its latest archived data date is within staleAfter of now.
+func buildFleetReport(cacheDir string, now time.Time, staleAfter time.Duration) (FleetReport, error) {
+	report := FleetReport{
+		GeneratedAt:     now,
+		StaleAfterHours: staleAfter.Hours(),
+		Tables:          []FleetTableStatus{},
+	}
+
+	files, err := os.ReadDir(cacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return report, nil
+		}
+		return report, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	tables := make(map[string]*FleetTableStatus)
+	dayAgo := now.Add(-24 * time.Hour)
+
+	for _, file := range files {
+		command, table, ok := parseCacheFileName(file.Name())
+		if !ok || command != "archive" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(cacheDir, file.Name()))
+		if err != nil {
+			continue
+		}
+		var cache PartitionCache
+		if err := json.Unmarshal(data, &cache); err != nil || cache.Entries == nil {
+			continue
+		}
+		if cache.Table != "" {
+			table = cache.Table
+		}
+
+		status, exists := tables[table]
+		if !exists {
+			status = &FleetTableStatus{Table: table}
+			tables[table] = status
+		}
+
+		for key, entry := range cache.Entries {
+			if entry.LastError != "" && entry.ErrorTime.After(entry.S3UploadTime) {
+				status.Errors++
+			}
+			if !entry.S3Uploaded {
+				continue
+			}
+			status.Files++
+
+			if !entry.S3UploadTime.IsZero() {
+				if status.LastUpload == nil || entry.S3UploadTime.After(*status.LastUpload) {
+					uploaded := entry.S3UploadTime
+					status.LastUpload = &uploaded
+				}
+				if entry.S3UploadTime.After(dayAgo) {
+					status.BytesLast24h += entry.FileSize
+				}
+			}
+
+			if date, ok := archivedDataDate(table, key, entry); ok {
+				if status.LatestArchived == nil || date.After(*status.LatestArchived) {
+					latest := date
+					status.LatestArchived = &latest
+				}
+			}
+		}
+	}
+
+	var furthestLag time.Duration
+	for _, status := range tables {
+		lag := staleAfter + 1
+		if status.LatestArchived != nil {
+			lag = now.Sub(*status.LatestArchived)
+			status.BehindHours = lag.Hours()
+		}
+		status.UpToDate = status.LatestArchived != nil && lag <= staleAfter
+
+		if status.UpToDate {
+			report.TablesUpToDate++
+		} else {
+			report.TablesBehind++
+			if report.FurthestBehind == "" || lag > furthestLag {
+				report.FurthestBehind = status.Table
+				furthestLag = lag
+			}
+		}
+		report.BytesLast24h += status.BytesLast24h
+		report.Tables = append(report.Tables, *status)
+	}
+
+	// Furthest behind first so the tables that need attention are at the top
+	sort.Slice(report.Tables, func(i, j int) bool {
+		if report.Tables[i].UpToDate != report.Tables[j].UpToDate {
+			return !report.Tables[i].UpToDate
+		}
+		if report.Tables[i].BehindHours != report.Tables[j].BehindHours {
+			return report.Tables[i].BehindHours > report.Tables[j].BehindHours
+		}
+		return report.Tables[i].Table < report.Tables[j].Table
+	})
+
+	return report, nil
+}
+
+// buildLocalFleetReport builds the fleet report from the default cache
+// directory and attaches the running archiver, if any
+func buildLocalFleetReport(staleAfter time.Duration) (FleetReport, error) {
+	homeDir, _ := os.UserHomeDir()
+	cacheDir := filepath.Join(homeDir, ".data-archiver", "cache")
+
+	report, err := buildFleetReport(cacheDir, time.Now(), staleAfter)
+	if err != nil {
+		return report, err
+	}
+
+	runs, err := listRunSummaries(getResultsLogDir(), "", IsProcessRunning)
+	if err != nil {
+		return report, err
+	}
+	attachRunSummaries(&report, runs)
+
+	if pid, err := ReadPIDFile(); err == nil && IsProcessRunning(pid) {
+		report.ArchiverRunning = true
+		report.PID = pid
+		if taskInfo, err := ReadTaskInfo(); err == nil {
+			report.CurrentTable = taskInfo.Table
+		}
+	}
+	return report, nil
+}
+
+// attachRunSummaries sets each table's most recent archive run. runs must be
+// sorted newest first, as returned by listRunSummaries.
+func attachRunSummaries(report *FleetReport, runs []RunSummary) {
+	latest := make(map[string]RunSummary)
+	for _, run := range runs {
+		if run.Command != "archive" {
+			continue
+		}
+		if _, seen := latest[run.Table]; !seen {
+			latest[run.Table] = run
+		}
+	}
+
+	for i := range report.Tables {
+		if run, ok := latest[report.Tables[i].Table]; ok {
+			report.Tables[i].LastRun = &run
+			if run.Status == runStatusInterrupted {
+				report.InterruptedRuns++
+			}
+		}
+	}
+}
+
+// writeFleetMetrics writes the fleet report in Prometheus text exposition format
+func writeFleetMetrics(w io.Writer, report FleetReport) {
+	fmt.Fprintln(w, "# HELP data_archiver_fleet_tables Number of archived tables by freshness.")
+	fmt.Fprintln(w, "# TYPE data_archiver_fleet_tables gauge")
+	fmt.Fprintf(w, "data_archiver_fleet_tables{state=\"up_to_date\"} %d\n", report.TablesUpToDate)
+	fmt.Fprintf(w, "data_archiver_fleet_tables{state=\"behind\"} %d\n", report.TablesBehind)
+
+	fmt.Fprintln(w, "# HELP data_archiver_fleet_bytes_last_24h Compressed bytes uploaded in the last 24 hours.")
+	fmt.Fprintln(w, "# TYPE data_archiver_fleet_bytes_last_24h gauge")
+	fmt.Fprintf(w, "data_archiver_fleet_bytes_last_24h %d\n", report.BytesLast24h)
+
+	fmt.Fprintln(w, "# HELP data_archiver_table_behind_hours Hours between now and the latest archived data date.")
+	fmt.Fprintln(w, "# TYPE data_archiver_table_behind_hours gauge")
+	for _, table := range report.Tables {
+		if table.LatestArchived != nil {
+			fmt.Fprintf(w, "data_archiver_table_behind_hours{table=%q} %.2f\n", table.Table, table.BehindHours)
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP data_archiver_table_up_to_date Whether the table's latest archived data is within the stale threshold.")
+	fmt.Fprintln(w, "# TYPE data_archiver_table_up_to_date gauge")
+	for _, table := range report.Tables {
+		upToDate := 0
+		if table.UpToDate {
+			upToDate = 1
+		}
+		fmt.Fprintf(w, "data_archiver_table_up_to_date{table=%q} %d\n", table.Table, upToDate)
+	}
+
+	fmt.Fprintln(w, "# HELP data_archiver_table_bytes_last_24h Compressed bytes uploaded per table in the last 24 hours.")
+	fmt.Fprintln(w, "# TYPE data_archiver_table_bytes_last_24h gauge")
+	for _, table := range report.Tables {
+		fmt.Fprintf(w, "data_archiver_table_bytes_last_24h{table=%q} %d\n", table.Table, table.BytesLast24h)
+	}
+
+	fmt.Fprintln(w, "# HELP data_archiver_table_last_run_info Run ID and status of each table's most recent run.")
+	fmt.Fprintln(w, "# TYPE data_archiver_table_last_run_info gauge")
+	for _, table := range report.Tables {
+		if table.LastRun != nil {
+			fmt.Fprintf(w, "data_archiver_table_last_run_info{table=%q,run_id=%q,status=%q} 1\n", table.Table, table.LastRun.RunID, table.LastRun.Status)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestCache(t *testing.T, dir string, scope CacheScope, cache PartitionCache) {
+	t.Helper()
+	data, err := json.Marshal(cache)
+	if err != nil {
+		t.Fatalf("failed to marshal cache: %v", err)
+	}
+	name := scope.fileIdentifier() + "_metadata.json"
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+		t.Fatalf("failed to write cache: %v", err)
+	}
+}
+
+func TestParseCacheFileName(t *testing.T) {
+	scope := CacheScope{Command: "archive", Table: "flight_events", OutputPath: "s3://bucket/archive/{table}"}
+	command, table, ok := parseCacheFileName(scope.fileIdentifier() + "_metadata.json")
+	if !ok || command != "archive" || table != "flight_events" {
+		t.Errorf("unexpected parse result: %q %q %v", command, table, ok)
+	}
+
+	if _, _, ok := parseCacheFileName("flights_metadata.json"); ok {
+		t.Error("legacy cache files should not match")
+	}
+}
+
+func TestArchivedDataDate(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"flight_events_20240115", "2024-01-15"},
+		{"flight_events_p20240115", "2024-01-15"},
+		{"flight_events_2024_03", "2024-03-01"},
+		{"flight_events_202403", "2024-03-01"},
+		{"flight_events", ""},
+		{"flight_events_archive", ""},
+	}
+
+	for _, tt := range tests {
+		date, ok := archivedDataDate("flight_events", tt.name, PartitionCacheEntry{})
+		got := ""
+		if ok {
+			got = date.Format("2006-01-02")
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestBuildFleetReport(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	writeTestCache(t, dir, CacheScope{Command: "archive", Table: "flights", OutputPath: "s3://b/flights"}, PartitionCache{
+		Entries: map[string]PartitionCacheEntry{
+			"flights_20240609": {S3Uploaded: true, FileSize: 1000, S3UploadTime: now.Add(-2 * time.Hour)},
+			"flights_20240608": {S3Uploaded: true, FileSize: 500, S3UploadTime: now.Add(-30 * time.Hour)},
+		},
+	})
+	writeTestCache(t, dir, CacheScope{Command: "archive", Table: "messages", OutputPath: "s3://b/messages"}, PartitionCache{
+		Entries: map[string]PartitionCacheEntry{
+			"messages/2024/05/01.jsonl.zst": {
+				S3Uploaded: true, FileSize: 200, S3UploadTime: now.Add(-time.Hour),
+				SourceTable: "messages", RangeStart: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			},
+			"messages_20240502": {LastError: "boom", ErrorTime: now.Add(-time.Hour)},
+		},
+	})
+	// Non-archive scopes are ignored
+	writeTestCache(t, dir, CacheScope{Command: "dump", Table: "flights", OutputPath: "s3://b/dump"}, PartitionCache{
+		Entries: map[string]PartitionCacheEntry{"flights_20200101": {S3Uploaded: true, FileSize: 1}},
+	})
+
+	report, err := buildFleetReport(dir, now, 48*time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(report.Tables) != 2 {
+		t.Fatalf("expected 2 tables, got %d", len(report.Tables))
+	}
+	if report.TablesUpToDate != 1 || report.TablesBehind != 1 || report.FurthestBehind != "messages" {
+		t.Errorf("unexpected summary: %+v", report)
+	}
+	if report.BytesLast24h != 1200 {
+		t.Errorf("expected 1200 bytes in last 24h, got %d", report.BytesLast24h)
+	}
+
+	// Behind tables sort first
+	messages := report.Tables[0]
+	if messages.Table != "messages" || messages.UpToDate || messages.Errors != 1 {
+		t.Errorf("unexpected messages status: %+v", messages)
+	}
+	flights := report.Tables[1]
+	if !flights.UpToDate || flights.Files != 2 || flights.LatestArchived.Format("2006-01-02") != "2024-06-09" {
+		t.Errorf("unexpected flights status: %+v", flights)
+	}
+
+	var metrics bytes.Buffer
+	report.Tables[1].LastRun = &RunSummary{RunID: "01HZX3K8M2Q7R4T6V8W0Y2A4C6", Status: runStatusCompleted}
+	writeFleetMetrics(&metrics, report)
+	if !strings.Contains(metrics.String(), `data_archiver_table_up_to_date{table="flights"} 1`) ||
+		!strings.Contains(metrics.String(), "data_archiver_fleet_bytes_last_24h 1200") ||
+		!strings.Contains(metrics.String(), `data_archiver_table_last_run_info{table="flights",run_id="01HZX3K8M2Q7R4T6V8W0Y2A4C6",status="completed"} 1`) {
+		t.Errorf("unexpected metrics output:\n%s", metrics.String())
+	}
+}
+
+func TestBuildFleetReportMissingDir(t *testing.T) {
+	report, err := buildFleetReport(filepath.Join(t.TempDir(), "missing"), time.Now(), time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Tables) != 0 {
+		t.Errorf("expected empty report, got %d tables", len(report.Tables))
+	}
+}
@@ -0,0 +1,627 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/airframesio/data-archiver/cmd/compressors"
+	"github.com/airframesio/data-archiver/cmd/formatters"
+	"github.com/spf13/viper"
+)
+
+// Static errors for format migration
+var (
+	ErrFormatMigrationPolicy  = errors.New("format migration must be one of: keep, convert, rearchive")
+	ErrFormatChanged          = errors.New("archived objects use a different output format or compression; choose --format-migration keep, convert, or rearchive")
+	ErrFormatConversionFailed = errors.New("format conversion failed")
+)
+
+// Policies for objects archived in a format other than the configured one
+const (
+	FormatMigrationKeep      = "keep"      // Leave them in place and skip their partitions
+	FormatMigrationConvert   = "convert"   // Rewrite them from S3 in the new format, then delete the originals
+	FormatMigrationRearchive = "rearchive" // Extract them again from the database, then delete the originals
+)
+
+var formatMigration string
+
+func init() {
+	archiveCmd.Flags().StringVar(&formatMigration, "format-migration", "", "what to do with objects archived in another --output-format or --compression: keep, convert (rewrite from S3), rearchive (extract again); default stops with a migration plan")
+	_ = viper.BindPFlag("format_migration", archiveCmd.Flags().Lookup("format-migration"))
+}
+
+// validateFormatMigration checks a --format-migration policy ("" = stop with a plan)
+func validateFormatMigration(policy string) error {
+	switch policy {
+	case "", FormatMigrationKeep, FormatMigrationConvert, FormatMigrationRearchive:
+		return nil
+	default:
+		return fmt.Errorf("%w, got '%s'", ErrFormatMigrationPolicy, policy)
+	}
+}
+
+// archiveFormat is the output format and compression an object was written with
+type archiveFormat struct {
+	Format      string
+	Compression string
+}
+
+// archiveFormat returns the format new objects are written with
+func (a *Archiver) archiveFormat() archiveFormat {
+	return archiveFormat{Format: a.config.OutputFormat, Compression: a.config.Compression}
+}
+
+func (f archiveFormat) String() string {
+	if formatters.UsesInternalCompression(f.Format) || f.Compression == "" {
+		return f.Format
+	}
+	return f.Format + "+" + f.Compression
+}
+
+// matches reports whether objects written in f are in the current format.
+// Internally compressed formats are written with the same codec whatever the
+// configured compression, so only their format is compared.
+func (f archiveFormat) matches(current archiveFormat) bool {
+	if f.Format != current.Format {
+		return false
+	}
+	return formatters.UsesInternalCompression(f.Format) || f.Compression == current.Compression
+}
+
+// suffix returns the extensions object keys in this format end with
+func (f archiveFormat) suffix() (string, error) {
+	suffix := formatters.GetFormatter(f.Format).Extension()
+	if formatters.UsesInternalCompression(f.Format) {
+		return suffix, nil
+	}
+	compressor, err := compressors.GetCompressor(f.Compression)
+	if err != nil {
+		return "", err
+	}
+	return suffix + compressor.Extension(), nil
+}
+
+// readCompression returns the compression to undo when reading the object
+func (f archiveFormat) readCompression() string {
+	if formatters.UsesInternalCompression(f.Format) {
+		return "none"
+	}
+	return f.Compression
+}
+
+// entryFormat returns the format of an uploaded object, inferring it from the
+// key for entries cached before formats were recorded
+func entryFormat(entry PartitionCacheEntry) (archiveFormat, bool) {
+	if entry.Format != "" {
+		return archiveFormat{Format: entry.Format, Compression: entry.Compression}, true
+	}
+	format, compression, err := detectFormatAndCompression(entry.S3Key, "", "")
+	if err != nil {
+		return archiveFormat{}, false
+	}
+	return archiveFormat{Format: format, Compression: compression}, true
+}
+
+// formatMigrationItem is one object archived in an old format
+type formatMigrationItem struct {
+	CacheKey string // Cache entry of the old object
+	OldKey   string
+	NewKey   string // Key the object has in the current format
+	From     archiveFormat
+}
+
+// isSlice reports whether the object is a slice, whose cache entry is keyed by object key
+func (i formatMigrationItem) isSlice() bool {
+	return i.CacheKey == i.OldKey
+}
+
+// newCacheKey returns the cache entry the object has in the current format
+func (i formatMigrationItem) newCacheKey() string {
+	if i.isSlice() {
+		return i.NewKey
+	}
+	return i.CacheKey
+}
+
+// formatMigrationPlan lists the objects whose format differs from the configured one
+type formatMigrationPlan struct {
+	policy string
+	to     archiveFormat
+	items  []formatMigrationItem
+	kept   map[string]formatMigrationItem // By new key, when policy is keep
+}
+
+// planFormatMigration finds the uploaded objects in cache written in a format
+// other than the configured one
+func (a *Archiver) planFormatMigration(cache *PartitionCache) (*formatMigrationPlan, error) {
+	current := a.archiveFormat()
+	newSuffix, err := current.suffix()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &formatMigrationPlan{policy: a.config.FormatMigration, to: current}
+	for key, entry := range cache.Entries {
+		if !entry.S3Uploaded || entry.S3Key == "" {
+			continue
+		}
+		from, ok := entryFormat(entry)
+		if !ok || from.matches(current) {
+			continue
+		}
+		oldSuffix, err := from.suffix()
+		if err != nil || !strings.HasSuffix(entry.S3Key, oldSuffix) {
+			continue
+		}
+		plan.items = append(plan.items, formatMigrationItem{
+			CacheKey: key,
+			OldKey:   entry.S3Key,
+			NewKey:   strings.TrimSuffix(entry.S3Key, oldSuffix) + newSuffix,
+			From:     from,
+		})
+	}
+	sort.Slice(plan.items, func(i, j int) bool { return plan.items[i].OldKey < plan.items[j].OldKey })
+
+	if plan.policy == FormatMigrationKeep {
+		plan.kept = make(map[string]formatMigrationItem, len(plan.items))
+		for _, item := range plan.items {
+			plan.kept[item.NewKey] = item
+		}
+	}
+	return plan, nil
+}
+
+// describe logs the plan and the available policies
+func (p *formatMigrationPlan) describe(a *Archiver) {
+	a.logger.Warn(fmt.Sprintf("⚠️  %d archived object(s) are not in the configured format (%s):", len(p.items), p.to))
+	byFormat := make(map[string][]formatMigrationItem)
+	var formats []string
+	for _, item := range p.items {
+		name := item.From.String()
+		if byFormat[name] == nil {
+			formats = append(formats, name)
+		}
+		byFormat[name] = append(byFormat[name], item)
+	}
+	sort.Strings(formats)
+	for _, name := range formats {
+		items := byFormat[name]
+		a.logger.Warn(fmt.Sprintf("   %s: %d object(s), e.g. %s", name, len(items), items[0].OldKey))
+	}
+	a.logger.Warn("   --format-migration keep       leave them in place and skip their partitions")
+	a.logger.Warn(fmt.Sprintf("   --format-migration convert    rewrite them as %s from S3, then delete the originals", p.to))
+	a.logger.Warn(fmt.Sprintf("   --format-migration rearchive  extract them again from the database as %s, then delete the originals", p.to))
+}
+
+// keeps returns the old object kept in place of objectKey by --format-migration keep
+func (p *formatMigrationPlan) keeps(objectKey string) (formatMigrationItem, bool) {
+	if p == nil || p.kept == nil {
+		return formatMigrationItem{}, false
+	}
+	item, ok := p.kept[objectKey]
+	return item, ok
+}
+
+// prepareFormatMigration detects objects archived in another format before the
+// run starts. Without a policy the run stops with the plan; convert rewrites
+// the objects now, while keep and rearchive take effect as partitions are
+// processed (a changed format always changes the object key, so the old
+// objects are never mistaken for current ones).
+func (a *Archiver) prepareFormatMigration(ctx context.Context) error {
+	cache, err := loadPartitionCache(a.config.CacheScope)
+	if err != nil {
+		return fmt.Errorf("failed to load cache for format migration check: %w", err)
+	}
+	plan, err := a.planFormatMigration(cache)
+	if err != nil {
+		return err
+	}
+	if len(plan.items) == 0 {
+		return nil
+	}
+
+	plan.describe(a)
+	switch {
+	case a.config.DryRun:
+		a.logger.Info("   Dry run: no objects migrated")
+		return nil
+	case plan.policy == "":
+		return fmt.Errorf("%w (%d object(s), configured %s)", ErrFormatChanged, len(plan.items), plan.to)
+	case plan.policy == FormatMigrationConvert:
+		return a.convertArchivedObjects(ctx, plan, cache)
+	}
+	a.migration = plan
+	return nil
+}
+
+// finishFormatMigration deletes the old objects that --format-migration
+// rearchive replaced during the run. Objects outside the run's date range keep
+// their old format until a run covers them.
+func (a *Archiver) finishFormatMigration(ctx context.Context) {
+	if a.migration == nil || a.migration.policy != FormatMigrationRearchive || a.s3Client == nil {
+		return
+	}
+	cache, err := loadPartitionCache(a.config.CacheScope)
+	if err != nil {
+		return
+	}
+
+	var replaced, remaining int
+	for _, item := range a.migration.items {
+		entry, ok := cache.Entries[item.newCacheKey()]
+		if format, known := entryFormat(entry); !ok || !known || !entry.S3Uploaded || entry.S3Key != item.NewKey || !format.matches(a.migration.to) {
+			remaining++
+			continue
+		}
+		if err := a.deleteObject(ctx, item.OldKey); err != nil {
+			a.logger.Warn(fmt.Sprintf("   ⚠️  Failed to delete %s: %v", item.OldKey, err))
+			remaining++
+			continue
+		}
+		if item.isSlice() {
+			delete(cache.Entries, item.CacheKey)
+			cache.markDirty(item.CacheKey)
+		}
+		replaced++
+	}
+	if err := cache.save(a.config.CacheScope); err != nil {
+		a.logger.Warn(fmt.Sprintf("⚠️  Failed to save cache: %v", err))
+	}
+	a.logger.Info(fmt.Sprintf("🔁 Re-archived %d object(s) as %s", replaced, a.migration.to))
+	if remaining > 0 {
+		a.logger.Info(fmt.Sprintf("   %d object(s) outside this run keep their old format", remaining))
+	}
+}
+
+// convertArchivedObjects rewrites every object in the plan in the current format
+func (a *Archiver) convertArchivedObjects(ctx context.Context, plan *formatMigrationPlan, cache *PartitionCache) error {
+	// The database is only needed for column types; processing reconnects
+	if err := a.connect(ctx); err != nil {
+		return fmt.Errorf("failed to connect for format conversion: %w", err)
+	}
+	defer func() {
+		a.db.Close()
+		a.db = nil
+	}()
+
+	var failed int
+	for i, item := range plan.items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		a.logger.Info(fmt.Sprintf("🔄 Converting %s → %s (%d/%d)", item.OldKey, item.NewKey, i+1, len(plan.items)))
+		if err := a.convertArchivedObject(ctx, item, cache); err != nil {
+			a.logger.Error(fmt.Sprintf("   ❌ %s: %v", item.OldKey, err))
+			failed++
+			continue
+		}
+		if err := cache.save(a.config.CacheScope); err != nil {
+			a.logger.Warn(fmt.Sprintf("   ⚠️  Failed to save cache: %v", err))
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d object(s)", ErrFormatConversionFailed, failed, len(plan.items))
+	}
+	a.logger.Info(fmt.Sprintf("✅ Converted %d object(s) to %s", len(plan.items), plan.to))
+	return nil
+}
+
+// convertArchivedObject downloads one old object, writes its rows in the
+// current format under the new key, and deletes the original
+func (a *Archiver) convertArchivedObject(ctx context.Context, item formatMigrationItem, cache *PartitionCache) error {
+	out, _, err := a.s3Client.Get(ctx, a.config.S3.Bucket, item.OldKey, GetOptions{})
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+	body, usesDictionary, err := loadStreamZstdDictionary(ctx, a.s3Client, a.config.S3.Bucket, item.OldKey, out)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	defer out.Close()
+	reader := &Restorer{fieldMapping: a.config.FieldMapping}
+	stream, err := reader.openFileRows(body, item.From.Format, item.From.readCompression())
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	first, err := stream.peek()
+	if err != nil {
+		return err
+	}
+
+	entry := cache.Entries[item.CacheKey]
+	sourceTable := entry.SourceTable
+	if sourceTable == "" {
+		sourceTable = a.config.Table
+	}
+	table, err := a.getTableSchema(ctx, sourceTable)
+	if err != nil {
+		a.logger.Debug(fmt.Sprintf("   No schema for %s, typing columns from their values: %v", sourceTable, err))
+		table = nil
+	}
+	schema := conversionSchema(table, first)
+	if err := checkFieldMappingColumns(a.config.FieldMapping, schema); err != nil {
+		return err
+	}
+
+	tempFilePath, fileSize, md5Hash, uncompressedSize, rowCount, err := a.writeConvertedFile(stream, schema, item.From.Format)
+	if err != nil {
+		return err
+	}
+	defer cleanupTempFile(tempFilePath)
+	defer a.etags.forget(tempFilePath)
+	a.labels.set(item.NewKey, objectLabels{Table: a.config.Table, Rows: rowCount, UncompressedSize: uncompressedSize})
+	defer a.labels.forget(item.NewKey)
+	if err := a.uploadTempFileToS3(tempFilePath, item.NewKey); err != nil {
+		return fmt.Errorf("upload failed: %w", err)
+	}
+	if err := a.recordIntegrity(IntegrityEventArchived, item.NewKey, md5Hash, fileSize, rowCount); err != nil {
+		return err
+	}
+	multipartETag := ""
+	if fileSize > 100*1024*1024 {
+		if multipartETag, err = a.tempFileMultipartETag(tempFilePath); err != nil {
+			multipartETag = ""
+		}
+	}
+	a.recordRunManifest(item.NewKey, md5Hash, multipartETag, fileSize, uncompressedSize, rowCount)
+
+	newCacheKey := item.newCacheKey()
+	cache.setFileMetadataWithETagAndStartTime(newCacheKey, item.NewKey, fileSize, uncompressedSize, md5Hash, multipartETag, true, entry.ProcessStartTime)
+	if multipartETag != "" {
+		cache.setPartSize(newCacheKey, a.objectPartSize(fileSize))
+	}
+	cache.setArchivedContent(newCacheKey, entry.SourceTable, entry.RangeStart, entry.RangeEnd, rowCount, a.compressionLevel(), a.archiveFormat(), entry.RowLimit)
+	if err := a.deleteObject(ctx, item.OldKey); err != nil {
+		a.logger.Warn(fmt.Sprintf("   ⚠️  Converted, but failed to delete %s: %v", item.OldKey, err))
+	} else if usesDictionary {
+		if err := a.deleteObject(ctx, zstdDictionaryKey(item.OldKey)); err != nil {
+			a.logger.Warn(fmt.Sprintf("   ⚠️  Converted, but failed to delete %s: %v", zstdDictionaryKey(item.OldKey), err))
+		}
+	}
+	if item.isSlice() {
+		delete(cache.Entries, item.CacheKey)
+		cache.markDirty(item.CacheKey)
+	}
+	// The new object sits next to the old one, in a partition Athena already knows
+	a.notifyHooks(time.Time{}, []string{item.NewKey, item.OldKey})
+	return nil
+}
+
+// writeConvertedFile writes the rows of stream to a temp file in the current
+// format a chunk at a time, coercing each value to its column's type
+func (a *Archiver) writeConvertedFile(stream *fileRowStream, schema *TableSchema, fromFormat string) (tempFilePath string, fileSize int64, md5Hash string, uncompressedSize, rowCount int64, err error) {
+	tempFile, err := createTempFile()
+	if err != nil {
+		return "", 0, "", 0, 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tempFilePath = tempFile.Name()
+	defer func() {
+		if err != nil {
+			tempFile.Close()
+			cleanupTempFile(tempFilePath)
+			tempFilePath = ""
+		}
+	}()
+
+	hasher := newMultipartHasher(a.config.S3.basePartSize())
+	var sink io.Writer = io.MultiWriter(tempFile, hasher)
+	var compressorWriter io.WriteCloser
+	if !formatters.UsesInternalCompression(a.config.OutputFormat) {
+		compressorWriter, err = a.newCompressorWriter(sink, a.compressionLevel(), tempFilePath)
+		if err != nil {
+			return "", 0, "", 0, 0, err
+		}
+		sink = compressorWriter
+	}
+
+	streamWriter, err := a.streamingFormatter().NewWriter(sink, schema)
+	if err != nil {
+		return "", 0, "", 0, 0, fmt.Errorf("failed to create streaming formatter: %w", err)
+	}
+	streamWriter = formatters.NewMappedStreamWriter(streamWriter, a.config.FieldMapping)
+	columnNames := make([]string, len(schema.Columns))
+	for i, col := range schema.Columns {
+		columnNames[i] = col.Name
+	}
+	err = stream.each(func(rows []map[string]interface{}) error {
+		coerceArchivedRows(rows, schema, fromFormat)
+		if err := streamWriter.WriteChunk(rows); err != nil {
+			return fmt.Errorf("failed to write rows: %w", err)
+		}
+		for _, row := range rows {
+			uncompressedSize += calculateUncompressedRowSize(row, a.config.OutputFormat, columnNames)
+		}
+		rowCount += int64(len(rows))
+		return nil
+	})
+	if err != nil {
+		streamWriter.Close()
+		return "", 0, "", 0, 0, err
+	}
+	if err = streamWriter.Close(); err != nil {
+		return "", 0, "", 0, 0, fmt.Errorf("failed to close stream writer: %w", err)
+	}
+	if compressorWriter != nil {
+		if err = compressorWriter.Close(); err != nil {
+			return "", 0, "", 0, 0, fmt.Errorf("failed to close compressor: %w", err)
+		}
+	}
+	if err = tempFile.Close(); err != nil {
+		return "", 0, "", 0, 0, fmt.Errorf("failed to close temp file: %w", err)
+	}
+
+	info, err := os.Stat(tempFilePath)
+	if err != nil {
+		return "", 0, "", 0, 0, fmt.Errorf("failed to stat temp file: %w", err)
+	}
+	if info.Size() > multipartUploadThreshold {
+		if etag, ok := hasher.multipartETag(); ok {
+			a.etags.set(tempFilePath, etag)
+		}
+	}
+	return tempFilePath, info.Size(), hasher.md5(), uncompressedSize, rowCount, nil
+}
+
+// deleteObject removes an object from the bucket, or with --soft-delete-days
+// moves it to the trash, and records the deletion in the integrity ledger
+func (a *Archiver) deleteObject(ctx context.Context, key string) error {
+	trashed, err := removeArchiveObject(ctx, a.s3Client, a.config.S3.Bucket, a.config.TrashPrefix, a.config.Table, key, a.config.SoftDeleteDays, time.Now())
+	if err != nil {
+		return err
+	}
+	if trashed != "" {
+		a.logger.Debug(fmt.Sprintf("   🗑️  Moved %s to %s", key, trashed))
+	}
+	if err := a.recordIntegrity(IntegrityEventDeleted, key, "", 0, 0); err != nil {
+		a.logger.Warn(fmt.Sprintf("   ⚠️  %v", err))
+	}
+	return nil
+}
+
+// conversionSchema types the columns of converted rows from the source table,
+// falling back to the values themselves for columns the table lacks (custom
+// query output, or a table that no longer exists). rows is the file's first
+// chunk; archive files carry every column in every row.
+func conversionSchema(table *TableSchema, rows []map[string]interface{}) *TableSchema {
+	types := make(map[string]string)
+	if table != nil {
+		for _, col := range table.Columns {
+			types[col.Name] = col.UDTName
+		}
+	}
+
+	schema := &TableSchema{}
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		for name, value := range row {
+			if seen[name] {
+				continue
+			}
+			udtName, ok := types[name]
+			if !ok {
+				if value == nil {
+					continue // Typed by a later row, or text if every value is null
+				}
+				udtName = inferPostgreSQLType(value)
+			}
+			seen[name] = true
+			schema.Columns = append(schema.Columns, ColumnInfo{Name: name, UDTName: udtName})
+		}
+	}
+	for _, row := range rows {
+		for name := range row {
+			if !seen[name] {
+				seen[name] = true
+				schema.Columns = append(schema.Columns, ColumnInfo{Name: name, UDTName: "text"})
+			}
+		}
+	}
+	if table != nil {
+		schema.TableName = table.TableName
+	}
+	sortColumns(schema.Columns)
+	return schema
+}
+
+// coerceArchivedRows converts the values of rows read back from an archive
+// in place to the Go types of their schema columns
+func coerceArchivedRows(rows []map[string]interface{}, schema *TableSchema, fromFormat string) {
+	for _, row := range rows {
+		for _, col := range schema.Columns {
+			row[col.Name] = coerceArchivedValue(row[col.Name], col.UDTName, fromFormat)
+		}
+	}
+}
+
+// coerceArchivedValue converts a value read back from an archive to the Go
+// type the extraction produces for udtName (see convertPostgreSQLValue), so
+// it's written in the new format as if it came from the database
+func coerceArchivedValue(value interface{}, udtName, fromFormat string) interface{} {
+	if value == nil {
+		return nil
+	}
+	switch udtName {
+	case "int2", "int4":
+		if n, ok := archivedInt(value); ok {
+			return int32(n) //nolint:gosec // G115: value came from an int2/int4 column
+		}
+	case "int8":
+		if n, ok := archivedInt(value); ok {
+			return n
+		}
+	case "float4":
+		if f, ok := aggregateNumber(value); ok {
+			return float32(f)
+		}
+	case "float8", "numeric", "decimal":
+		if f, ok := aggregateNumber(value); ok {
+			return f
+		}
+	case "bool":
+		switch v := value.(type) {
+		case bool:
+			return v
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b
+			}
+		}
+	case "timestamp", "timestamptz", "date":
+		if t, ok := aggregateTime(value, udtName); ok {
+			return t
+		}
+	case "bytea":
+		if s, ok := value.(string); ok {
+			// JSONL encodes bytes as base64
+			if fromFormat == formatters.FormatJSONL {
+				if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+					return b
+				}
+			}
+			return []byte(s)
+		}
+		return value
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case map[string]interface{}, []interface{}:
+		if data, err := json.Marshal(v); err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+// archivedInt converts an archived integer value, which JSONL reads back as a float
+func archivedInt(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int32:
+		return int64(v), true
+	case float64:
+		return int64(v), true
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateFormatMigration(t *testing.T) {
+	for _, policy := range []string{"", FormatMigrationKeep, FormatMigrationConvert, FormatMigrationRearchive} {
+		if err := validateFormatMigration(policy); err != nil {
+			t.Errorf("validateFormatMigration(%q) = %v", policy, err)
+		}
+	}
+	if err := validateFormatMigration("delete"); !errors.Is(err, ErrFormatMigrationPolicy) {
+		t.Errorf("expected ErrFormatMigrationPolicy, got %v", err)
+	}
+}
+
+// formatMigrationTestCache holds objects written before and after a switch to parquet
+func formatMigrationTestCache() *PartitionCache {
+	cache := &PartitionCache{Entries: make(map[string]PartitionCacheEntry)}
+	// Legacy partition entry without a recorded format
+	cache.Entries["events_20240101"] = PartitionCacheEntry{S3Key: "events/2024/01/events-2024-01-01.jsonl.gz", S3Uploaded: true, FileSize: 10, FileMD5: "a"}
+	// Slice entry keyed by its object key
+	cache.Entries["events/2024/01/events-2024-01-02.csv.zst"] = PartitionCacheEntry{
+		S3Key: "events/2024/01/events-2024-01-02.csv.zst", S3Uploaded: true, Format: "csv", Compression: "zstd", SourceTable: "events_2024_01",
+	}
+	// Already in the current format, whatever compression was configured
+	cache.Entries["events_20240103"] = PartitionCacheEntry{S3Key: "events/2024/01/events-2024-01-03.parquet", S3Uploaded: true, Format: "parquet", Compression: "snappy"}
+	// Never uploaded
+	cache.Entries["events_20240104"] = PartitionCacheEntry{S3Key: "events/2024/01/events-2024-01-04.jsonl.zst", RowCount: 5}
+	return cache
+}
+
+func TestPlanFormatMigration(t *testing.T) {
+	archiver := NewArchiver(&Config{Table: "events", OutputFormat: "parquet", Compression: "zstd", FormatMigration: FormatMigrationKeep}, newTestLogger())
+	plan, err := archiver.planFormatMigration(formatMigrationTestCache())
+	if err != nil {
+		t.Fatalf("planFormatMigration failed: %v", err)
+	}
+	if len(plan.items) != 2 {
+		t.Fatalf("expected 2 items, got %+v", plan.items)
+	}
+
+	partition, slice := plan.items[0], plan.items[1]
+	if partition.NewKey != "events/2024/01/events-2024-01-01.parquet" || partition.From.String() != "jsonl+gzip" || partition.isSlice() {
+		t.Errorf("unexpected partition item %+v", partition)
+	}
+	if partition.newCacheKey() != "events_20240101" {
+		t.Errorf("partition keeps its cache key, got %s", partition.newCacheKey())
+	}
+	if slice.NewKey != "events/2024/01/events-2024-01-02.parquet" || slice.From.String() != "csv+zstd" || !slice.isSlice() || slice.newCacheKey() != slice.NewKey {
+		t.Errorf("unexpected slice item %+v", slice)
+	}
+
+	// keep skips the partition or slice that would be written under the new key
+	archiver.migration = plan
+	skip, result := archiver.checkCachedMetadata(PartitionInfo{TableName: "events_20240101"}, partition.NewKey, &PartitionCache{Entries: map[string]PartitionCacheEntry{}}, func(string) {})
+	if !skip || result.Stage != StageSkipped || result.SkipReason != "Kept as jsonl+gzip (events/2024/01/events-2024-01-01.jsonl.gz)" {
+		t.Errorf("expected kept partition to be skipped, got %v %+v", skip, result)
+	}
+}
+
+func TestPrepareFormatMigrationWithoutPolicy(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	config := &Config{Table: "events", OutputFormat: "parquet", Compression: "zstd", S3: S3Config{Bucket: "archive", PathTemplate: "events/{YYYY}/{MM}"}}
+	archiver := NewArchiver(config, newTestLogger())
+	if err := formatMigrationTestCache().save(config.CacheScope); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := archiver.prepareFormatMigration(context.Background()); !errors.Is(err, ErrFormatChanged) {
+		t.Errorf("expected ErrFormatChanged, got %v", err)
+	}
+
+	// A dry run only reports the plan
+	config.DryRun = true
+	if err := archiver.prepareFormatMigration(context.Background()); err != nil {
+		t.Errorf("dry run should not fail: %v", err)
+	}
+
+	// keep records the plan for partition processing
+	config.DryRun = false
+	config.FormatMigration = FormatMigrationKeep
+	if err := archiver.prepareFormatMigration(context.Background()); err != nil || len(archiver.migration.kept) != 2 {
+		t.Errorf("keep: err=%v, plan=%+v", err, archiver.migration)
+	}
+
+	// Nothing to migrate once the format matches
+	config.OutputFormat, config.Compression = "jsonl", "gzip"
+	archiver.migration = nil
+	config.FormatMigration = ""
+	cache := &PartitionCache{Entries: map[string]PartitionCacheEntry{
+		"events_20240101": {S3Key: "events/2024/01/events-2024-01-01.jsonl.gz", S3Uploaded: true},
+	}}
+	if err := cache.save(config.CacheScope); err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.prepareFormatMigration(context.Background()); err != nil || archiver.migration != nil {
+		t.Errorf("expected no migration, got %v, %+v", err, archiver.migration)
+	}
+
+	// An unreadable cache fails the check instead of skipping it
+	if err := os.Remove(getCachePath(config.CacheScope)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(getCachePath(config.CacheScope), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.prepareFormatMigration(context.Background()); err == nil {
+		t.Error("expected an error for an unreadable cache")
+	}
+}
+
+func TestRunTablesPreparesFormatMigration(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	config := &Config{Table: "events", OutputFormat: "parquet", Compression: "zstd", S3: S3Config{Bucket: "archive", PathTemplate: "events/{YYYY}/{MM}"}}
+	config.CacheScope = NewCacheScope("archive", config)
+	if err := formatMigrationTestCache().save(config.CacheScope); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each table of a multi-table run checks its archived format before any
+	// partition is processed, as a single-table run does
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	if err := runTables(context.Background(), []*Config{config}, 1, nil, logger); !errors.Is(err, ErrTablesFailed) {
+		t.Fatalf("expected ErrTablesFailed, got %v", err)
+	}
+	if !strings.Contains(logs.String(), ErrFormatChanged.Error()) {
+		t.Errorf("expected the table to fail with %v, got logs:\n%s", ErrFormatChanged, logs.String())
+	}
+}
+
+func TestConvertedFileRoundTrip(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	created := time.Date(2024, 1, 1, 6, 30, 0, 0, time.UTC)
+
+	// A JSONL archive whose first row has no score; the table types it
+	jsonl := `{"id":1,"name":"alpha","active":true,"score":null,"created_at":"` + created.Format(time.RFC3339) + `","raw":"AQI="}` + "\n" +
+		`{"id":2,"name":null,"active":false,"score":2.5,"created_at":null,"raw":null}` + "\n" +
+		`{"id":3,"name":"gamma","active":true,"score":null,"created_at":null,"raw":null}` + "\n"
+	table := &TableSchema{TableName: "events_20240101", Columns: []ColumnInfo{
+		{Name: "id", UDTName: "int4"},
+		{Name: "name", UDTName: "text"},
+		{Name: "active", UDTName: "bool"},
+		{Name: "score", UDTName: "float8"},
+		{Name: "created_at", UDTName: "timestamptz"},
+		{Name: "raw", UDTName: "bytea"},
+	}}
+
+	// Read one row at a time, as a file larger than a chunk is
+	stream, err := (&Restorer{readChunkRows: 1}).openFileRows(strings.NewReader(jsonl), "jsonl", "none")
+	if err != nil {
+		t.Fatalf("openFileRows failed: %v", err)
+	}
+	defer stream.Close()
+	first, err := stream.peek()
+	if err != nil || len(first) != 1 {
+		t.Fatalf("peek() = %v, %v", first, err)
+	}
+	schema := conversionSchema(table, first)
+	if len(schema.Columns) != 6 || schema.Columns[0].Name != "active" {
+		t.Fatalf("unexpected schema %+v", schema.Columns)
+	}
+	coerced := []map[string]interface{}{{"id": float64(1), "created_at": created.Format(time.RFC3339), "raw": "AQI="}}
+	coerceArchivedRows(coerced, schema, "jsonl")
+	if coerced[0]["id"] != int32(1) || !coerced[0]["created_at"].(time.Time).Equal(created) || string(coerced[0]["raw"].([]byte)) != "\x01\x02" {
+		t.Fatalf("unexpected coerced row %+v", coerced[0])
+	}
+
+	archiver := NewArchiver(&Config{Table: "events", OutputFormat: "parquet", Compression: "zstd"}, newTestLogger())
+	path, size, md5Hash, uncompressed, rowCount, err := archiver.writeConvertedFile(stream, schema, "jsonl")
+	if err != nil {
+		t.Fatalf("writeConvertedFile failed: %v", err)
+	}
+	defer cleanupTempFile(path)
+	if size == 0 || len(md5Hash) != 32 || uncompressed == 0 || rowCount != 3 {
+		t.Errorf("unexpected file metadata: size=%d md5=%s uncompressed=%d rows=%d", size, md5Hash, uncompressed, rowCount)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	got, err := (&Restorer{}).readFileRows(file, "parquet", "none")
+	if err != nil {
+		t.Fatalf("failed to read converted file: %v", err)
+	}
+	if len(got) != 3 || got[0]["name"] != "alpha" || got[0]["id"] != int32(1) || got[0]["created_at"] != created.UnixMicro() ||
+		got[1]["name"] != nil || got[1]["score"] != 2.5 {
+		t.Errorf("unexpected converted rows %+v", got)
+	}
+}
+
+func TestCoerceArchivedValue(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		udtName string
+		from    string
+		want    interface{}
+	}{
+		{"42", "int8", "csv", int64(42)},
+		{float64(7), "int2", "jsonl", int32(7)},
+		{"true", "bool", "csv", true},
+		{int32(19723), "date", "parquet", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"2.5", "float4", "csv", float32(2.5)},
+		{int64(12), "text", "csv", "12"},
+		{map[string]interface{}{"a": float64(1)}, "jsonb", "jsonl", `{"a":1}`},
+		{"raw", "bytea", "parquet", []byte("raw")},
+	}
+	for _, tt := range tests {
+		got := coerceArchivedValue(tt.value, tt.udtName, tt.from)
+		if b, ok := tt.want.([]byte); ok {
+			if string(got.([]byte)) != string(b) {
+				t.Errorf("coerce(%v, %s) = %v", tt.value, tt.udtName, got)
+			}
+			continue
+		}
+		if wantTime, ok := tt.want.(time.Time); ok {
+			if gotTime, ok := got.(time.Time); !ok || !gotTime.Equal(wantTime) {
+				t.Errorf("coerce(%v, %s) = %v, want %v", tt.value, tt.udtName, got, wantTime)
+			}
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("coerce(%v, %s) = %#v, want %#v", tt.value, tt.udtName, got, tt.want)
+		}
+	}
+}
@@ -118,12 +118,16 @@ func (f *CSVStreamingFormatter) MIMEType() string {
 type csvStreamWriter struct {
 	writer  *csv.Writer
 	columns []string
+	record  []string // Reused for every row; csv.Writer does not keep it
 }
 
 // WriteChunk writes a chunk of rows in CSV format
 func (w *csvStreamWriter) WriteChunk(rows []map[string]interface{}) error {
+	if w.record == nil {
+		w.record = make([]string, len(w.columns))
+	}
+	record := w.record
 	for _, row := range rows {
-		record := make([]string, len(w.columns))
 		for i, col := range w.columns {
 			val := row[col]
 			if val == nil {
@@ -36,6 +36,17 @@ func NewCSVReaderWithCloser(r io.ReadCloser) (*CSVReader, error) {
 	}, nil
 }
 
+// NewCSVReaderWithColumns creates a CSV reader for files without a header row.
+// Fields are mapped to the given column names in order.
+func NewCSVReaderWithColumns(r io.ReadCloser, columns []string) *CSVReader {
+	return &CSVReader{
+		reader:   csv.NewReader(r),
+		closer:   r,
+		headers:  columns,
+		readOnce: true,
+	}
+}
+
 // readHeaders reads the header row if not already read
 func (r *CSVReader) readHeaders() error {
 	if r.readOnce {
@@ -0,0 +1,217 @@
+package formatters
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+)
+
+// DefaultFlattenSeparator joins a flattened column name with its nested keys
+const DefaultFlattenSeparator = "."
+
+// FieldMapping renames and flattens row fields in the formatter layer.
+// Apply is used when writing rows; Reverse restores the original column
+// names and nested objects when the rows are read back.
+type FieldMapping struct {
+	Rename    map[string]string // Column name → output field name (takes precedence over CamelCase)
+	CamelCase bool              // Convert snake_case column names to camelCase
+	Flatten   map[string]bool   // JSON object columns whose keys are lifted to top-level fields
+	Separator string            // Separator between a flattened column and its keys (default ".")
+
+	reverse map[string]string // Output field name → column name for Rename
+}
+
+// NewFieldMapping creates a field mapping. It returns nil when the mapping
+// would leave rows unchanged so callers can skip it entirely.
+func NewFieldMapping(rename map[string]string, camelCase bool, flatten []string, separator string) *FieldMapping {
+	if len(rename) == 0 && !camelCase && len(flatten) == 0 {
+		return nil
+	}
+	if separator == "" {
+		separator = DefaultFlattenSeparator
+	}
+
+	m := &FieldMapping{
+		Rename:    rename,
+		CamelCase: camelCase,
+		Flatten:   make(map[string]bool, len(flatten)),
+		Separator: separator,
+		reverse:   make(map[string]string, len(rename)),
+	}
+	for _, column := range flatten {
+		m.Flatten[column] = true
+	}
+	for column, field := range rename {
+		m.reverse[field] = column
+	}
+	return m
+}
+
+// FieldName returns the output field name for a column
+func (m *FieldMapping) FieldName(column string) string {
+	if field, ok := m.Rename[column]; ok {
+		return field
+	}
+	if m.CamelCase {
+		return snakeToCamel(column)
+	}
+	return column
+}
+
+// ColumnName returns the column name for an output field (inverse of FieldName)
+func (m *FieldMapping) ColumnName(field string) string {
+	if column, ok := m.reverse[field]; ok {
+		return column
+	}
+	if m.CamelCase {
+		return camelToSnake(field)
+	}
+	return field
+}
+
+// Apply returns a copy of row with renamed fields and flattened JSON objects
+func (m *FieldMapping) Apply(row map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(row))
+	for column, value := range row {
+		field := m.FieldName(column)
+		if m.Flatten[column] {
+			if object, ok := jsonObject(value); ok {
+				flattenInto(out, field, object, m.Separator)
+				continue
+			}
+		}
+		out[field] = value
+	}
+	return out
+}
+
+// Reverse returns a copy of row with original column names and flattened
+// fields nested back into their JSON object columns
+func (m *FieldMapping) Reverse(row map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(row))
+	for field, value := range row {
+		if prefix, rest, found := strings.Cut(field, m.Separator); found {
+			if column := m.ColumnName(prefix); m.Flatten[column] {
+				object, _ := out[column].(map[string]interface{})
+				if object == nil {
+					object = make(map[string]interface{})
+					out[column] = object
+				}
+				nestInto(object, strings.Split(rest, m.Separator), value)
+				continue
+			}
+		}
+		out[m.ColumnName(field)] = value
+	}
+	return out
+}
+
+// ReverseRows applies Reverse to each row in place
+func (m *FieldMapping) ReverseRows(rows []map[string]interface{}) {
+	for i, row := range rows {
+		rows[i] = m.Reverse(row)
+	}
+}
+
+// NewMappedStreamWriter wraps a StreamWriter so every row is mapped before it is written
+func NewMappedStreamWriter(w StreamWriter, m *FieldMapping) StreamWriter {
+	if m == nil {
+		return w
+	}
+	return &mappedStreamWriter{writer: w, mapping: m}
+}
+
+type mappedStreamWriter struct {
+	writer  StreamWriter
+	mapping *FieldMapping
+}
+
+// WriteChunk maps and writes a chunk of rows
+func (w *mappedStreamWriter) WriteChunk(rows []map[string]interface{}) error {
+	mapped := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		mapped[i] = w.mapping.Apply(row)
+	}
+	return w.writer.WriteChunk(mapped)
+}
+
+// Close closes the underlying writer
+func (w *mappedStreamWriter) Close() error {
+	return w.writer.Close()
+}
+
+// jsonObject returns value as a JSON object, decoding json/jsonb text when needed
+func jsonObject(value interface{}) (map[string]interface{}, bool) {
+	var raw []byte
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return nil, false
+	}
+
+	var object map[string]interface{}
+	if err := json.Unmarshal(raw, &object); err != nil || object == nil {
+		return nil, false
+	}
+	return object, true
+}
+
+func flattenInto(out map[string]interface{}, prefix string, object map[string]interface{}, separator string) {
+	for key, value := range object {
+		name := prefix + separator + key
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			flattenInto(out, name, nested, separator)
+			continue
+		}
+		out[name] = value
+	}
+}
+
+func nestInto(object map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		child, _ := object[key].(map[string]interface{})
+		if child == nil {
+			child = make(map[string]interface{})
+			object[key] = child
+		}
+		object = child
+	}
+	object[path[len(path)-1]] = value
+}
+
+// snakeToCamel converts snake_case to camelCase (flight_id → flightId)
+func snakeToCamel(name string) string {
+	parts := strings.Split(name, "_")
+	var b strings.Builder
+	b.WriteString(parts[0])
+	for _, part := range parts[1:] {
+		if part == "" {
+			continue
+		}
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	return b.String()
+}
+
+// camelToSnake converts camelCase to snake_case (flightId → flight_id)
+func camelToSnake(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -10,7 +10,7 @@ import (
 // JSONLReader reads JSONL format (one JSON object per line)
 type JSONLReader struct {
 	scanner *bufio.Scanner
-	reader io.ReadCloser
+	reader  io.ReadCloser
 }
 
 // NewJSONLReader creates a new JSONL reader
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"strings"
+	"sync"
 
 	"github.com/parquet-go/parquet-go"
 )
@@ -43,23 +45,8 @@ func (f *ParquetFormatter) Format(rows []map[string]interface{}) ([]byte, error)
 	// Build schema by scanning all rows to find actual types
 	schema, _ := buildSchemaFromRows(rows)
 
-	// Map compression type to parquet compression codec and create writer
-	var writer *parquet.GenericWriter[map[string]any]
-	switch f.compression {
-	case "zstd":
-		writer = parquet.NewGenericWriter[map[string]any](&buffer, schema, parquet.Compression(&parquet.Zstd))
-	case "gzip":
-		writer = parquet.NewGenericWriter[map[string]any](&buffer, schema, parquet.Compression(&parquet.Gzip))
-	case "lz4":
-		writer = parquet.NewGenericWriter[map[string]any](&buffer, schema, parquet.Compression(&parquet.Lz4Raw))
-	case "snappy":
-		writer = parquet.NewGenericWriter[map[string]any](&buffer, schema, parquet.Compression(&parquet.Snappy))
-	case "none":
-		writer = parquet.NewGenericWriter[map[string]any](&buffer, schema, parquet.Compression(&parquet.Uncompressed))
-	default:
-		// Default to Snappy (standard for Parquet)
-		writer = parquet.NewGenericWriter[map[string]any](&buffer, schema, parquet.Compression(&parquet.Snappy))
-	}
+	// Map compression type to parquet compression codec (Snappy by default) and create writer
+	writer := parquet.NewGenericWriter[map[string]any](&buffer, schema, parquet.Compression(parquetCodec(f.compression)))
 	defer writer.Close()
 
 	// Write rows directly - GenericWriter handles the conversion
@@ -151,23 +138,27 @@ func (f *ParquetFormatter) MIMEType() string {
 
 // ParquetStreamingFormatter handles Parquet format output in streaming mode
 type ParquetStreamingFormatter struct {
-	compression string
+	options ParquetOptions
 }
 
 // NewParquetStreamingFormatter creates a new Parquet streaming formatter with default compression
 func NewParquetStreamingFormatter() *ParquetStreamingFormatter {
-	return &ParquetStreamingFormatter{
-		compression: "snappy", // Default Parquet compression
-	}
+	return &ParquetStreamingFormatter{}
 }
 
 // NewParquetStreamingFormatterWithCompression creates a Parquet streaming formatter with specified compression
 func NewParquetStreamingFormatterWithCompression(compression string) *ParquetStreamingFormatter {
 	return &ParquetStreamingFormatter{
-		compression: compression,
+		options: ParquetOptions{Compression: compression},
 	}
 }
 
+// NewParquetStreamingFormatterWithOptions creates a Parquet streaming formatter
+// with the given row group, page, codec and encoding settings
+func NewParquetStreamingFormatterWithOptions(options ParquetOptions) *ParquetStreamingFormatter {
+	return &ParquetStreamingFormatter{options: options}
+}
+
 // NewWriter creates a new Parquet stream writer
 // For Parquet, we need to build the schema from the TableSchema
 func (f *ParquetStreamingFormatter) NewWriter(w io.Writer, tableSchema TableSchema) (StreamWriter, error) {
@@ -180,6 +171,39 @@ func (f *ParquetStreamingFormatter) NewWriter(w io.Writer, tableSchema TableSche
 	if len(columns) == 0 {
 		return nil, ErrNoColumns
 	}
+	schema := parquetSchemaFor(columns, &f.options)
+
+	// Create writer with the configured compression and layout
+	options := append([]parquet.WriterOption{schema}, f.options.writerOptions()...)
+	writer := parquet.NewGenericWriter[map[string]any](w, options...)
+
+	return &parquetStreamWriter{
+		writer:       writer,
+		rowGroupSize: f.options.RowGroupSize,
+	}, nil
+}
+
+// parquetSchemas caches the Parquet schemas built by NewWriter, keyed by
+// their columns. Every slice and file of a partition has the same columns, and
+// building the schema of a table with hundreds of columns is slow.
+var parquetSchemas sync.Map // Column signature -> *parquet.Schema
+
+// parquetSchemaFor returns the Parquet schema of columns with the column
+// settings of options, building it the first time those columns are seen
+func parquetSchemaFor(columns []ColumnSchema, options *ParquetOptions) *parquet.Schema {
+	var signature strings.Builder
+	signature.WriteString(options.signature())
+	signature.WriteByte(0)
+	for _, col := range columns {
+		signature.WriteString(col.GetName())
+		signature.WriteByte(0)
+		signature.WriteString(col.GetType())
+		signature.WriteByte(0)
+	}
+	key := signature.String()
+	if cached, ok := parquetSchemas.Load(key); ok {
+		return cached.(*parquet.Schema)
+	}
 
 	// Sort columns for consistency
 	columnNames := make([]string, len(columns))
@@ -196,31 +220,12 @@ func (f *ParquetStreamingFormatter) NewWriter(w io.Writer, tableSchema TableSche
 	for _, colName := range columnNames {
 		col := columnMap[colName]
 		field := mapPostgreSQLTypeToParquetNode(col.GetType())
-		fields[colName] = field
+		fields[colName] = options.columnNode(colName, field)
 	}
 
 	schema := parquet.NewSchema("postgresql_export", fields)
-
-	// Create writer with appropriate compression
-	var writer *parquet.GenericWriter[map[string]any]
-	switch f.compression {
-	case "zstd":
-		writer = parquet.NewGenericWriter[map[string]any](w, schema, parquet.Compression(&parquet.Zstd))
-	case "gzip":
-		writer = parquet.NewGenericWriter[map[string]any](w, schema, parquet.Compression(&parquet.Gzip))
-	case "lz4":
-		writer = parquet.NewGenericWriter[map[string]any](w, schema, parquet.Compression(&parquet.Lz4Raw))
-	case "snappy":
-		writer = parquet.NewGenericWriter[map[string]any](w, schema, parquet.Compression(&parquet.Snappy))
-	case "none":
-		writer = parquet.NewGenericWriter[map[string]any](w, schema, parquet.Compression(&parquet.Uncompressed))
-	default:
-		writer = parquet.NewGenericWriter[map[string]any](w, schema, parquet.Compression(&parquet.Snappy))
-	}
-
-	return &parquetStreamWriter{
-		writer: writer,
-	}, nil
+	parquetSchemas.Store(key, schema)
+	return schema
 }
 
 // Extension returns the file extension for Parquet files
@@ -291,7 +296,9 @@ func mapPostgreSQLTypeToParquetNode(udtName string) parquet.Node {
 
 // parquetStreamWriter implements StreamWriter for Parquet format
 type parquetStreamWriter struct {
-	writer *parquet.GenericWriter[map[string]any]
+	writer       *parquet.GenericWriter[map[string]any]
+	rowGroupSize int64 // Rows per row group (0 = one row group per file)
+	groupRows    int64 // Rows written to the current row group
 }
 
 // WriteChunk writes a chunk of rows to the Parquet file
@@ -300,10 +307,24 @@ func (w *parquetStreamWriter) WriteChunk(rows []map[string]interface{}) error {
 		return nil
 	}
 
-	// Write rows to Parquet writer
-	_, err := w.writer.Write(rows)
-	if err != nil {
-		return fmt.Errorf("failed to write parquet chunk: %w", err)
+	// Write rows to Parquet writer, ending a row group every rowGroupSize rows
+	for len(rows) > 0 {
+		batch := rows
+		if w.rowGroupSize > 0 && int64(len(batch)) > w.rowGroupSize-w.groupRows {
+			batch = rows[:w.rowGroupSize-w.groupRows]
+		}
+		if _, err := w.writer.Write(batch); err != nil {
+			return fmt.Errorf("failed to write parquet chunk: %w", err)
+		}
+		rows = rows[len(batch):]
+
+		w.groupRows += int64(len(batch))
+		if w.rowGroupSize > 0 && w.groupRows == w.rowGroupSize {
+			if err := w.writer.Flush(); err != nil {
+				return fmt.Errorf("failed to flush parquet row group: %w", err)
+			}
+			w.groupRows = 0
+		}
 	}
 
 	return nil
@@ -0,0 +1,121 @@
+package formatters
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+)
+
+// DefaultParquetCompression is the codec of Parquet columns without one set
+const DefaultParquetCompression = "snappy"
+
+// ParquetCodecs are the column compression codecs Parquet files can use
+var ParquetCodecs = []string{"snappy", "zstd", "gzip", "lz4", "brotli", "none"}
+
+// ParquetOptions tunes the layout of the Parquet files written by the
+// streaming formatter. The zero value writes files as before: snappy
+// columns, one row group per file, plain encoding and no page statistics.
+type ParquetOptions struct {
+	Compression       string            // Codec of every column not in ColumnCompression (default snappy)
+	ColumnCompression map[string]string // Column name → codec
+	RowGroupSize      int64             // Most rows per row group (0 = one row group per file)
+	PageSize          int               // Bytes of column values buffered per data page (0 = parquet-go default)
+	Dictionary        bool              // Dictionary-encode every string, JSON, UUID and bytea column
+	DictionaryColumns []string          // Columns dictionary-encoded whatever their type
+	Statistics        bool              // Write min/max statistics into every data page header too
+}
+
+// parquetCodec returns the compression codec named name, defaulting to snappy
+func parquetCodec(name string) compress.Codec {
+	switch name {
+	case "zstd":
+		return &parquet.Zstd
+	case "gzip":
+		return &parquet.Gzip
+	case "lz4":
+		return &parquet.Lz4Raw
+	case "brotli":
+		return &parquet.Brotli
+	case "none":
+		return &parquet.Uncompressed
+	default:
+		return &parquet.Snappy
+	}
+}
+
+// IsParquetCodec reports whether name is a Parquet column codec
+func IsParquetCodec(name string) bool {
+	for _, codec := range ParquetCodecs {
+		if name == codec {
+			return true
+		}
+	}
+	return false
+}
+
+// writerOptions returns the parquet-go writer options for the file-wide
+// settings. RowGroupSize is applied by parquetStreamWriter, which flushes a
+// row group every RowGroupSize rows: parquet-go's own row limit fails on
+// writes that cross it.
+func (o *ParquetOptions) writerOptions() []parquet.WriterOption {
+	compression := o.Compression
+	if compression == "" {
+		compression = DefaultParquetCompression
+	}
+	options := []parquet.WriterOption{
+		parquet.Compression(parquetCodec(compression)),
+		parquet.DataPageStatistics(o.Statistics),
+	}
+	if o.PageSize > 0 {
+		options = append(options, parquet.PageBufferSize(o.PageSize))
+	}
+	return options
+}
+
+// columnNode applies the column's codec and dictionary settings to its node
+func (o *ParquetOptions) columnNode(name string, node parquet.Node) parquet.Node {
+	if o.dictionaryEncoded(name, node) {
+		node = parquet.Encoded(node, &parquet.RLEDictionary)
+	}
+	if codec, ok := o.ColumnCompression[name]; ok {
+		node = parquet.Compressed(node, parquetCodec(codec))
+	}
+	return node
+}
+
+// dictionaryEncoded reports whether a column is dictionary-encoded
+func (o *ParquetOptions) dictionaryEncoded(name string, node parquet.Node) bool {
+	if o.Dictionary && node.Type().Kind() == parquet.ByteArray {
+		return true
+	}
+	for _, column := range o.DictionaryColumns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+// signature identifies the column settings, for the schema cache
+func (o *ParquetOptions) signature() string {
+	if len(o.ColumnCompression) == 0 && !o.Dictionary && len(o.DictionaryColumns) == 0 {
+		return ""
+	}
+	columns := make([]string, 0, len(o.ColumnCompression))
+	for column := range o.ColumnCompression {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	var signature strings.Builder
+	for _, column := range columns {
+		signature.WriteString(column + "=" + o.ColumnCompression[column] + ",")
+	}
+	if o.Dictionary {
+		signature.WriteString("dictionary,")
+	}
+	signature.WriteString(strings.Join(o.DictionaryColumns, ","))
+	return signature.String()
+}
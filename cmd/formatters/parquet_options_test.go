@@ -0,0 +1,104 @@
+package formatters
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+type testColumn struct{ name, udt string }
+
+func (c testColumn) GetName() string { return c.name }
+func (c testColumn) GetType() string { return c.udt }
+
+type testSchema []ColumnSchema
+
+func (s testSchema) GetColumns() []ColumnSchema { return s }
+
+// writeTestParquet writes rows of an id, callsign and altitude table with
+// options and opens the result
+func writeTestParquet(t *testing.T, options ParquetOptions, rows int) *parquet.File {
+	t.Helper()
+	schema := testSchema{testColumn{"id", "int8"}, testColumn{"callsign", "text"}, testColumn{"altitude", "float8"}}
+	var buffer bytes.Buffer
+	writer, err := NewParquetStreamingFormatterWithOptions(options).NewWriter(&buffer, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := make([]map[string]interface{}, rows)
+	for i := range chunk {
+		chunk[i] = map[string]interface{}{"id": int64(i), "callsign": fmt.Sprintf("UAL%d", i%5), "altitude": float64(i)}
+	}
+	if err := writer.WriteChunk(chunk); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file, err := parquet.OpenFile(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestParquetOptionsApplied(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    ParquetOptions
+		rowGroups  []int64
+		codec      format.CompressionCodec
+		dictionary map[string]bool
+	}{
+		{
+			name:       "defaults",
+			rowGroups:  []int64{100},
+			codec:      format.Snappy,
+			dictionary: map[string]bool{},
+		},
+		{
+			name:       "row groups, codec and dictionary",
+			options:    ParquetOptions{Compression: "zstd", RowGroupSize: 40, Dictionary: true},
+			rowGroups:  []int64{40, 40, 20},
+			codec:      format.Zstd,
+			dictionary: map[string]bool{"callsign": true},
+		},
+		{
+			name:       "dictionary columns",
+			options:    ParquetOptions{Compression: "none", DictionaryColumns: []string{"id"}},
+			rowGroups:  []int64{100},
+			codec:      format.Uncompressed,
+			dictionary: map[string]bool{"id": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata := writeTestParquet(t, tt.options, 100).Metadata()
+			if len(metadata.RowGroups) != len(tt.rowGroups) {
+				t.Fatalf("row groups = %d, want %d", len(metadata.RowGroups), len(tt.rowGroups))
+			}
+			for i, group := range metadata.RowGroups {
+				if group.NumRows != tt.rowGroups[i] {
+					t.Errorf("row group %d has %d rows, want %d", i, group.NumRows, tt.rowGroups[i])
+				}
+				for _, chunk := range group.Columns {
+					name := chunk.MetaData.PathInSchema[0]
+					if chunk.MetaData.Codec != tt.codec {
+						t.Errorf("row group %d column %s codec = %v, want %v", i, name, chunk.MetaData.Codec, tt.codec)
+					}
+					dictionary := false
+					for _, encoding := range chunk.MetaData.Encoding {
+						dictionary = dictionary || encoding == format.RLEDictionary
+					}
+					if dictionary != tt.dictionary[name] {
+						t.Errorf("row group %d column %s dictionary-encoded = %v, want %v", i, name, dictionary, tt.dictionary[name])
+					}
+				}
+			}
+		})
+	}
+}
@@ -8,10 +8,16 @@ import (
 	"github.com/parquet-go/parquet-go"
 )
 
+// parquetReadBatch is how many rows are decoded from a row group at a time
+const parquetReadBatch = 1000
+
 // ParquetReader reads Parquet format
 type ParquetReader struct {
-	file   *parquet.File
-	closer io.ReadCloser
+	file        *parquet.File
+	closer      io.Closer
+	columnNames []string
+	rowGroup    int          // Next row group to open
+	rows        parquet.Rows // Row group being read (nil between row groups)
 }
 
 // NewParquetReader creates a new Parquet reader
@@ -24,18 +30,7 @@ func NewParquetReader(r io.Reader) (*ParquetReader, error) {
 	}
 
 	// Use bytes.Reader which implements io.ReaderAt
-	readerAt := bytes.NewReader(data)
-
-	// Open the parquet file to read its schema
-	file, err := parquet.OpenFile(readerAt, int64(len(data)))
-	if err != nil {
-		return nil, fmt.Errorf("failed to open parquet file: %w", err)
-	}
-
-	return &ParquetReader{
-		file:   file,
-		closer: nil,
-	}, nil
+	return NewParquetReaderAt(bytes.NewReader(data), int64(len(data)), nil)
 }
 
 // NewParquetReaderWithCloser creates a new Parquet reader with a closable reader
@@ -48,23 +43,43 @@ func NewParquetReaderWithCloser(r io.ReadCloser) (*ParquetReader, error) {
 		return nil, fmt.Errorf("failed to read parquet data: %w", err)
 	}
 
-	// Use bytes.Reader which implements io.ReaderAt
-	readerAt := bytes.NewReader(data)
+	reader, err := NewParquetReaderAt(bytes.NewReader(data), int64(len(data)), r)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return reader, nil
+}
 
+// NewParquetReaderAt creates a Parquet reader over a file of the given size,
+// such as an *os.File, reading row groups from it as rows are requested
+// instead of loading the file into memory. closer, if not nil, is closed by
+// Close.
+func NewParquetReaderAt(r io.ReaderAt, size int64, closer io.Closer) (*ParquetReader, error) {
 	// Open the parquet file to read its schema
-	file, err := parquet.OpenFile(readerAt, int64(len(data)))
+	file, err := parquet.OpenFile(r, size)
 	if err != nil {
-		r.Close()
 		return nil, fmt.Errorf("failed to open parquet file: %w", err)
 	}
 
+	// Build column name map (flatten nested paths)
+	columnPaths := file.Schema().Columns() // Returns [][]string, each []string is a column path
+	columnNames := make([]string, len(columnPaths))
+	for i, path := range columnPaths {
+		if len(path) > 0 {
+			columnNames[i] = path[len(path)-1] // Use last component as column name
+		}
+	}
+
 	return &ParquetReader{
-		file:   file,
-		closer: r,
+		file:        file,
+		closer:      closer,
+		columnNames: columnNames,
 	}, nil
 }
 
-// ReadChunk reads a chunk of rows from the Parquet stream
+// ReadChunk reads the next chunk of rows from the Parquet stream, continuing
+// where the previous call stopped. It returns no rows once the file is read.
 func (r *ParquetReader) ReadChunk(chunkSize int) ([]map[string]interface{}, error) {
 	return r.readRows(chunkSize)
 }
@@ -74,108 +89,83 @@ func (r *ParquetReader) ReadAll() ([]map[string]interface{}, error) {
 	return r.readRows(0) // 0 means read all
 }
 
-// readRows reads rows from the parquet file by iterating through row groups
+// readRows reads up to maxRows rows (0 = all remaining), moving through the
+// row groups in order
 func (r *ParquetReader) readRows(maxRows int) ([]map[string]interface{}, error) {
 	var rows []map[string]interface{}
+	batch := make([]parquet.Row, parquetReadBatch)
 
-	// Get schema to know column names
-	schema := r.file.Schema()
-	columnPaths := schema.Columns() // Returns [][]string, each []string is a column path
-
-	// Build column name map (flatten nested paths)
-	columnNames := make([]string, len(columnPaths))
-	for i, path := range columnPaths {
-		// Join path components with dot for nested columns, or use last component
-		if len(path) > 0 {
-			columnNames[i] = path[len(path)-1] // Use last component as column name
+	for maxRows <= 0 || len(rows) < maxRows {
+		if r.rows == nil {
+			rowGroups := r.file.RowGroups()
+			if r.rowGroup >= len(rowGroups) {
+				break // All row groups read
+			}
+			r.rows = rowGroups[r.rowGroup].Rows()
+			r.rowGroup++
 		}
-	}
 
-	// Read all row groups
-	rowCount := 0
-	for _, rowGroup := range r.file.RowGroups() {
-		// Limit rows if maxRows is specified
-		if maxRows > 0 && rowCount >= maxRows {
-			break
+		batchSize := len(batch)
+		if maxRows > 0 && maxRows-len(rows) < batchSize {
+			batchSize = maxRows - len(rows)
 		}
 
-		// Get row reader
-		rowReader := rowGroup.Rows()
-		defer rowReader.Close()
-
-		// Read rows from this row group
-		batchSize := 1000
-		if maxRows > 0 && maxRows-rowCount < batchSize {
-			batchSize = maxRows - rowCount
+		// The last batch of a row group may return rows together with io.EOF
+		n, err := r.rows.ReadRows(batch[:batchSize])
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read parquet rows: %w", err)
+		}
+		for _, parquetRow := range batch[:n] {
+			rows = append(rows, r.convertRow(parquetRow))
 		}
 
-		for {
-			if maxRows > 0 && rowCount >= maxRows {
-				break
-			}
+		if n == 0 || err == io.EOF {
+			r.rows.Close()
+			r.rows = nil
+		}
+	}
 
-			// Read a batch of rows
-			batch := make([]parquet.Row, batchSize)
-			n, err := rowReader.ReadRows(batch)
-			if err == io.EOF || n == 0 {
-				break
-			}
-			if err != nil {
-				return nil, fmt.Errorf("failed to read parquet rows: %w", err)
-			}
+	return rows, nil
+}
 
-			// Convert each parquet.Row to map[string]interface{}
-			for rowIdx := 0; rowIdx < n; rowIdx++ {
-				if maxRows > 0 && rowCount >= maxRows {
-					break
-				}
-
-				parquetRow := batch[rowIdx]
-				row := make(map[string]interface{})
-
-				// Map values to column names
-				// The row values are ordered by column index
-				for i, val := range parquetRow {
-					if i < len(columnNames) {
-						if val.IsNull() {
-							row[columnNames[i]] = nil
-						} else {
-							// Convert parquet.Value to Go value based on type
-							switch {
-							case val.Kind() == parquet.Boolean:
-								row[columnNames[i]] = val.Boolean()
-							case val.Kind() == parquet.Int32:
-								row[columnNames[i]] = val.Int32()
-							case val.Kind() == parquet.Int64:
-								row[columnNames[i]] = val.Int64()
-							case val.Kind() == parquet.Float:
-								row[columnNames[i]] = val.Float()
-							case val.Kind() == parquet.Double:
-								row[columnNames[i]] = val.Double()
-							case val.Kind() == parquet.ByteArray:
-								row[columnNames[i]] = string(val.ByteArray())
-							default:
-								row[columnNames[i]] = string(val.ByteArray())
-							}
-						}
-					}
-				}
-
-				rows = append(rows, row)
-				rowCount++
-			}
+// convertRow maps a parquet.Row's values, ordered by column index, to their
+// column names
+func (r *ParquetReader) convertRow(parquetRow parquet.Row) map[string]interface{} {
+	row := make(map[string]interface{}, len(r.columnNames))
+	for i, val := range parquetRow {
+		if i >= len(r.columnNames) {
+			continue
+		}
+		if val.IsNull() {
+			row[r.columnNames[i]] = nil
+			continue
+		}
 
-			if n < batchSize {
-				break // Last batch
-			}
+		// Convert parquet.Value to Go value based on type
+		switch val.Kind() {
+		case parquet.Boolean:
+			row[r.columnNames[i]] = val.Boolean()
+		case parquet.Int32:
+			row[r.columnNames[i]] = val.Int32()
+		case parquet.Int64:
+			row[r.columnNames[i]] = val.Int64()
+		case parquet.Float:
+			row[r.columnNames[i]] = val.Float()
+		case parquet.Double:
+			row[r.columnNames[i]] = val.Double()
+		default:
+			row[r.columnNames[i]] = string(val.ByteArray())
 		}
 	}
-
-	return rows, nil
+	return row
 }
 
-// Close closes the underlying reader
+// Close closes the row group being read and the underlying reader
 func (r *ParquetReader) Close() error {
+	if r.rows != nil {
+		r.rows.Close()
+		r.rows = nil
+	}
 	if r.closer != nil {
 		return r.closer.Close()
 	}
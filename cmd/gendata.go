@@ -0,0 +1,420 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Static errors for the test-data generator
+var (
+	ErrGendataColumnInvalid       = errors.New("column kind must be one of: integer, bigint, double, numeric, boolean, text, varchar, date, timestamptz, jsonb, uuid, bytea, huge-text")
+	ErrGendataDistributionInvalid = errors.New("distribution must be one of: uniform, skewed, sequential")
+	ErrGendataNullFractionInvalid = errors.New("null fraction must be between 0 and 1")
+	ErrGendataSeedInvalid         = errors.New("seed must be between -1 and 1")
+	ErrGendataRowsInvalid         = errors.New("--days and --rows-per-day must be positive")
+	ErrGendataTableExists         = errors.New("table already exists (use --drop-existing to replace it)")
+)
+
+var (
+	gendataTable        string
+	gendataStartDate    string
+	gendataDays         int
+	gendataRowsPerDay   int64
+	gendataColumns      string
+	gendataNullFraction float64
+	gendataDistribution string
+	gendataHugeTextSize int
+	gendataSeed         float64
+	gendataBatchSize    int64
+	gendataDropExisting bool
+)
+
+var gendataCmd = &cobra.Command{
+	Use:   "gendata",
+	Short: "Create a partitioned demo table filled with generated rows",
+	Long: `Create a table partitioned by day on created_at, with one {table}_YYYYMMDD partition per day, and
+fill it with generated rows. Column kinds, NULL-heavy columns, huge text values, and the distribution
+of timestamps and values are configurable, so bugs can be reproduced and settings benchmarked without
+production data. Rows are generated inside PostgreSQL with generate_series, and --seed makes a run
+repeatable.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		runGendata(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(gendataCmd)
+
+	// Database flags
+	gendataCmd.Flags().StringVar(&dbHost, "db-host", "localhost", "PostgreSQL host")
+	gendataCmd.Flags().IntVar(&dbPort, "db-port", 5432, "PostgreSQL port")
+	gendataCmd.Flags().StringVar(&dbUser, "db-user", "", "PostgreSQL user")
+	gendataCmd.Flags().StringVar(&dbPassword, "db-password", "", "PostgreSQL password")
+	gendataCmd.Flags().StringVar(&dbName, "db-name", "", "PostgreSQL database name")
+	gendataCmd.Flags().StringVar(&dbSSLMode, "db-sslmode", "disable", "PostgreSQL SSL mode (disable, require, verify-ca, verify-full)")
+
+	// Generator flags
+	gendataCmd.Flags().StringVar(&gendataTable, "table", "demo_events", "name of the partitioned table to create")
+	gendataCmd.Flags().StringVar(&gendataStartDate, "start-date", "", "first day to generate (YYYY-MM-DD, default: --days before today)")
+	gendataCmd.Flags().IntVar(&gendataDays, "days", 7, "number of daily partitions to create")
+	gendataCmd.Flags().Int64Var(&gendataRowsPerDay, "rows-per-day", 10000, "rows to generate in each partition")
+	gendataCmd.Flags().StringVar(&gendataColumns, "columns", "integer,double,text,boolean,jsonb", "comma-separated column kinds, each optionally with its own NULL fraction (e.g. text:0.9,huge-text)")
+	gendataCmd.Flags().Float64Var(&gendataNullFraction, "null-fraction", 0, "fraction of NULL values in columns without their own (0-1)")
+	gendataCmd.Flags().StringVar(&gendataDistribution, "distribution", "uniform", "distribution of timestamps and values: uniform, skewed (most rows early in the day and near zero), sequential")
+	gendataCmd.Flags().IntVar(&gendataHugeTextSize, "huge-text-size", 1024*1024, "approximate size in bytes of each huge-text value")
+	gendataCmd.Flags().Float64Var(&gendataSeed, "seed", 0, "seed for PostgreSQL's random() between -1 and 1, for repeatable data (default: unseeded)")
+	gendataCmd.Flags().Int64Var(&gendataBatchSize, "batch-size", 10000, "rows inserted per statement")
+	gendataCmd.Flags().BoolVar(&gendataDropExisting, "drop-existing", false, "drop the table and its partitions first if it already exists")
+}
+
+// gendataKind is a column kind with its type and an expression generating a
+// value from the unit variable u in [0, 1)
+type gendataKind struct {
+	Type string
+	Expr string
+}
+
+// gendataKinds maps each --columns kind to its column type and generator.
+// {u} is replaced by the distribution's expression and {g} by the row number.
+var gendataKinds = map[string]gendataKind{
+	"integer":     {"integer", "floor({u} * 1000000)::integer"},
+	"bigint":      {"bigint", "floor({u} * 9000000000000000)::bigint"},
+	"double":      {"double precision", "({u} - 0.5) * 2000000"},
+	"numeric":     {"numeric(20,4)", "round(({u} * 100000)::numeric, 4)"},
+	"boolean":     {"boolean", "{u} < 0.5"},
+	"text":        {"text", "md5({g}::text || random()::text)"},
+	"varchar":     {"varchar(32)", "'status-' || floor({u} * 10)::integer"},
+	"date":        {"date", "date '2000-01-01' + floor({u} * 10000)::integer"},
+	"timestamptz": {"timestamptz", "timestamptz '2000-01-01 00:00:00+00' + {u} * interval '10000 days'"},
+	"jsonb":       {"jsonb", "jsonb_build_object('n', floor({u} * 1000), 'tag', md5({g}::text), 'tags', jsonb_build_array('a', 'b'))"},
+	"uuid":        {"uuid", "md5({g}::text || random()::text)::uuid"},
+	"bytea":       {"bytea", "decode(md5({g}::text || random()::text), 'hex')"},
+	"huge-text":   {"text", "repeat(md5({g}::text), {size})"},
+}
+
+// gendataUnit returns the unit variable for a --distribution; n is the
+// number of rows in the partition
+func gendataUnit(distribution string, n int64) string {
+	switch distribution {
+	case "skewed":
+		return "power(random(), 4)"
+	case "sequential":
+		return fmt.Sprintf("((g - 1)::float8 / %d)", n)
+	default:
+		return "random()"
+	}
+}
+
+// gendataColumn is a generated column with the fraction of its rows that are NULL
+type gendataColumn struct {
+	Name         string
+	Kind         string
+	NullFraction float64
+}
+
+// parseGendataColumns parses --columns. Repeated kinds get numbered names.
+func parseGendataColumns(value string, nullFraction float64) ([]gendataColumn, error) {
+	var columns []gendataColumn
+	seen := make(map[string]int)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		kind, fraction := item, nullFraction
+		if i := strings.IndexByte(item, ':'); i >= 0 {
+			kind = item[:i]
+			parsed, err := strconv.ParseFloat(item[i+1:], 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				return nil, fmt.Errorf("%w, got '%s'", ErrGendataNullFractionInvalid, item)
+			}
+			fraction = parsed
+		}
+		if _, ok := gendataKinds[kind]; !ok {
+			return nil, fmt.Errorf("%w, got '%s'", ErrGendataColumnInvalid, kind)
+		}
+
+		seen[kind]++
+		name := "c_" + strings.ReplaceAll(kind, "-", "_")
+		if seen[kind] > 1 {
+			name = fmt.Sprintf("%s_%d", name, seen[kind])
+		}
+		columns = append(columns, gendataColumn{Name: name, Kind: kind, NullFraction: fraction})
+	}
+	return columns, nil
+}
+
+// gendataPartitionName names the partition for day the way archive discovers it
+func gendataPartitionName(table string, day time.Time) string {
+	return table + "_" + day.Format("20060102")
+}
+
+// gendataCreateSQL creates the parent table, partitioned by day on created_at
+func gendataCreateSQL(table string, columns []gendataColumn) string {
+	definitions := []string{"id bigint NOT NULL", "created_at timestamptz NOT NULL"}
+	for _, column := range columns {
+		definitions = append(definitions, fmt.Sprintf("%s %s", pq.QuoteIdentifier(column.Name), gendataKinds[column.Kind].Type))
+	}
+	return fmt.Sprintf("CREATE TABLE %s (%s) PARTITION BY RANGE (created_at)", pq.QuoteIdentifier(table), strings.Join(definitions, ", "))
+}
+
+// gendataPartitionSQL creates the partition holding day
+func gendataPartitionSQL(table string, day time.Time) string {
+	return fmt.Sprintf("CREATE TABLE %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+		pq.QuoteIdentifier(gendataPartitionName(table, day)), pq.QuoteIdentifier(table),
+		day.Format("2006-01-02"), day.AddDate(0, 0, 1).Format("2006-01-02"))
+}
+
+// gendataInsertSQL inserts rows $1 through $2 of the n rows of day's
+// partition. Ids are unique across partitions: dayIndex * n + g.
+func gendataInsertSQL(table string, day time.Time, dayIndex int, n int64, columns []gendataColumn, distribution string, hugeTextSize int) string {
+	unit := gendataUnit(distribution, n)
+	names := []string{"id", "created_at"}
+	values := []string{
+		fmt.Sprintf("%d + g", int64(dayIndex)*n),
+		fmt.Sprintf("timestamptz '%s 00:00:00+00' + %s * interval '1 day'", day.Format("2006-01-02"), unit),
+	}
+	repeats := max(hugeTextSize/32, 1)
+	for _, column := range columns {
+		expr := strings.NewReplacer("{u}", unit, "{g}", "g", "{size}", strconv.Itoa(repeats)).Replace(gendataKinds[column.Kind].Expr)
+		if column.NullFraction >= 1 {
+			expr = "NULL"
+		} else if column.NullFraction > 0 {
+			expr = fmt.Sprintf("CASE WHEN random() < %g THEN NULL ELSE %s END", column.NullFraction, expr)
+		}
+		names = append(names, pq.QuoteIdentifier(column.Name))
+		values = append(values, expr)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM generate_series($1::bigint, $2::bigint) AS g",
+		pq.QuoteIdentifier(gendataPartitionName(table, day)), strings.Join(names, ", "), strings.Join(values, ", "))
+}
+
+// dataGenerator creates and fills the demo table over a single connection,
+// so a seed set with setseed applies to every statement
+type dataGenerator struct {
+	conn         *sql.Conn
+	table        string
+	start        time.Time
+	days         int
+	rowsPerDay   int64
+	columns      []gendataColumn
+	distribution string
+	hugeTextSize int
+	batchSize    int64
+}
+
+// create drops the table when asked, then creates it and its partitions
+func (g *dataGenerator) create(ctx context.Context, dropExisting bool) error {
+	var exists bool
+	if err := g.conn.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", pq.QuoteIdentifier(g.table)).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check for table: %w", err)
+	}
+	if exists {
+		if !dropExisting {
+			return fmt.Errorf("%w: %s", ErrGendataTableExists, g.table)
+		}
+		if _, err := g.conn.ExecContext(ctx, fmt.Sprintf("DROP TABLE %s CASCADE", pq.QuoteIdentifier(g.table))); err != nil {
+			return fmt.Errorf("failed to drop table: %w", err)
+		}
+	}
+
+	statements := []string{gendataCreateSQL(g.table, g.columns)}
+	for i := 0; i < g.days; i++ {
+		statements = append(statements, gendataPartitionSQL(g.table, g.start.AddDate(0, 0, i)))
+	}
+	for _, statement := range statements {
+		if _, err := g.conn.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to create table: %w", err)
+		}
+	}
+	return nil
+}
+
+// fill inserts the rows of each partition in batches
+func (g *dataGenerator) fill(ctx context.Context, progress func(partition string, rows int64)) error {
+	for i := 0; i < g.days; i++ {
+		day := g.start.AddDate(0, 0, i)
+		query := gendataInsertSQL(g.table, day, i, g.rowsPerDay, g.columns, g.distribution, g.hugeTextSize)
+		for first := int64(1); first <= g.rowsPerDay; first += g.batchSize {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			last := min(first+g.batchSize-1, g.rowsPerDay)
+			if _, err := g.conn.ExecContext(ctx, query, first, last); err != nil {
+				return fmt.Errorf("failed to fill %s: %w", gendataPartitionName(g.table, day), err)
+			}
+		}
+		progress(gendataPartitionName(g.table, day), g.rowsPerDay)
+	}
+	return nil
+}
+
+func runGendata(cmd *cobra.Command) {
+	getStringConfig := func(flagValue string, flagName string, viperKey string) string {
+		if flag := cmd.Flags().Lookup(flagName); flag != nil && flag.Changed {
+			return flagValue
+		}
+		if viperValue := viper.GetString(viperKey); viperValue != "" {
+			return viperValue
+		}
+		return flagValue
+	}
+	getIntConfig := func(flagValue int, flagName string, viperKey string) int {
+		if flag := cmd.Flags().Lookup(flagName); flag != nil && flag.Changed {
+			return flagValue
+		}
+		if viperValue := viper.GetInt(viperKey); viperValue != 0 {
+			return viperValue
+		}
+		return flagValue
+	}
+
+	config := &Config{
+		Debug:     viper.GetBool("debug"),
+		LogFormat: viper.GetString("log_format"),
+		Database: DatabaseConfig{
+			Host:     getStringConfig(dbHost, "db-host", "db.host"),
+			Port:     getIntConfig(dbPort, "db-port", "db.port"),
+			User:     getStringConfig(dbUser, "db-user", "db.user"),
+			Password: getStringConfig(dbPassword, "db-password", "db.password"),
+			Name:     getStringConfig(dbName, "db-name", "db.name"),
+			SSLMode:  getStringConfig(dbSSLMode, "db-sslmode", "db.sslmode"),
+		},
+		Table: gendataTable,
+	}
+
+	initLogger(config.Debug, config.LogFormat)
+
+	logger.Info("")
+	logger.Info(fmt.Sprintf("🧬 Data Archiver Test-Data Generator v%s", Version))
+	logger.Info("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+	seeded := cmd.Flags().Changed("seed")
+	columns, start, err := validateGendataConfig(config, seeded)
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	ctx := signalContext
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	restorer := NewRestorer(config, logger)
+	if err := restorer.connect(ctx); err != nil {
+		logger.Error(fmt.Sprintf("❌ Failed to connect: %v", err))
+		os.Exit(1)
+	}
+	defer restorer.db.Close()
+	if restorer.tunnel != nil {
+		defer restorer.tunnel.Close()
+	}
+
+	conn, err := restorer.db.Conn(ctx)
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ Failed to connect: %v", err))
+		os.Exit(1)
+	}
+	defer conn.Close()
+	if seeded {
+		if _, err := conn.ExecContext(ctx, "SELECT setseed($1)", gendataSeed); err != nil {
+			logger.Error(fmt.Sprintf("❌ Failed to set seed: %v", err))
+			os.Exit(1)
+		}
+	}
+
+	generator := &dataGenerator{
+		conn:         conn,
+		table:        config.Table,
+		start:        start,
+		days:         gendataDays,
+		rowsPerDay:   gendataRowsPerDay,
+		columns:      columns,
+		distribution: gendataDistribution,
+		hugeTextSize: gendataHugeTextSize,
+		batchSize:    gendataBatchSize,
+	}
+	if err := generator.create(ctx, gendataDropExisting); err != nil {
+		logger.Error(fmt.Sprintf("❌ %v", err))
+		os.Exit(1)
+	}
+	logger.Info(fmt.Sprintf("Created %s with %d daily partitions from %s", config.Table, gendataDays, start.Format("2006-01-02")))
+
+	began := time.Now()
+	err = generator.fill(ctx, func(partition string, rows int64) {
+		logger.Info(fmt.Sprintf("   ✅ %s: %d rows", partition, rows))
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			logger.Info("Generation cancelled; the table is partially filled")
+			os.Exit(130)
+		}
+		logger.Error(fmt.Sprintf("❌ %v", err))
+		os.Exit(1)
+	}
+
+	logger.Info("")
+	logger.Info(fmt.Sprintf("✅ Generated %d rows in %v", int64(gendataDays)*gendataRowsPerDay, time.Since(began).Round(time.Millisecond)))
+	logger.Info(fmt.Sprintf("   Archive it with: data-archiver archive --table %s --date-column created_at ...", config.Table))
+}
+
+// validateGendataConfig checks the generator flags and returns the parsed
+// columns and the first day to generate
+func validateGendataConfig(config *Config, seeded bool) ([]gendataColumn, time.Time, error) {
+	if config.Database.User == "" {
+		return nil, time.Time{}, ErrDatabaseUserRequired
+	}
+	if config.Database.Name == "" {
+		return nil, time.Time{}, ErrDatabaseNameRequired
+	}
+	if config.Table == "" {
+		return nil, time.Time{}, ErrTableNameRequired
+	}
+	if !isValidTableName(config.Table) {
+		return nil, time.Time{}, fmt.Errorf("%w: '%s'", ErrTableNameInvalid, config.Table)
+	}
+	if gendataDays <= 0 || gendataRowsPerDay <= 0 {
+		return nil, time.Time{}, ErrGendataRowsInvalid
+	}
+	if err := checkIdentifierLength(gendataPartitionName(config.Table, time.Time{})); err != nil {
+		return nil, time.Time{}, err
+	}
+	if gendataDistribution != "uniform" && gendataDistribution != "skewed" && gendataDistribution != "sequential" {
+		return nil, time.Time{}, fmt.Errorf("%w, got '%s'", ErrGendataDistributionInvalid, gendataDistribution)
+	}
+	if gendataNullFraction < 0 || gendataNullFraction > 1 {
+		return nil, time.Time{}, ErrGendataNullFractionInvalid
+	}
+	if seeded && (gendataSeed < -1 || gendataSeed > 1) {
+		return nil, time.Time{}, ErrGendataSeedInvalid
+	}
+	if gendataBatchSize <= 0 {
+		gendataBatchSize = 10000
+	}
+
+	columns, err := parseGendataColumns(gendataColumns, gendataNullFraction)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	start := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -gendataDays)
+	if gendataStartDate != "" {
+		start, err = time.Parse("2006-01-02", gendataStartDate)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("%w: %w", ErrStartDateFormatInvalid, err)
+		}
+	}
+	return columns, start, nil
+}
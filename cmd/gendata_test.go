@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGendataColumns(t *testing.T) {
+	columns, err := parseGendataColumns(" integer, text:0.9,TEXT,huge-text", 0.1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []gendataColumn{
+		{Name: "c_integer", Kind: "integer", NullFraction: 0.1},
+		{Name: "c_text", Kind: "text", NullFraction: 0.9},
+		{Name: "c_text_2", Kind: "text", NullFraction: 0.1},
+		{Name: "c_huge_text", Kind: "huge-text", NullFraction: 0.1},
+	}
+	if len(columns) != len(want) {
+		t.Fatalf("expected %d columns, got %+v", len(want), columns)
+	}
+	for i := range want {
+		if columns[i] != want[i] {
+			t.Errorf("column %d = %+v, want %+v", i, columns[i], want[i])
+		}
+	}
+
+	if _, err := parseGendataColumns("integer,xml", 0); !errors.Is(err, ErrGendataColumnInvalid) {
+		t.Errorf("expected ErrGendataColumnInvalid, got %v", err)
+	}
+	if _, err := parseGendataColumns("text:1.5", 0); !errors.Is(err, ErrGendataNullFractionInvalid) {
+		t.Errorf("expected ErrGendataNullFractionInvalid, got %v", err)
+	}
+}
+
+func TestGendataSQL(t *testing.T) {
+	day := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	columns := []gendataColumn{
+		{Name: "c_integer", Kind: "integer"},
+		{Name: "c_text", Kind: "text", NullFraction: 0.25},
+		{Name: "c_jsonb", Kind: "jsonb", NullFraction: 1},
+		{Name: "c_huge_text", Kind: "huge-text"},
+	}
+
+	create := gendataCreateSQL("demo", columns)
+	if !strings.Contains(create, `"c_huge_text" text`) || !strings.HasSuffix(create, "PARTITION BY RANGE (created_at)") {
+		t.Errorf("unexpected create: %s", create)
+	}
+
+	partition := gendataPartitionSQL("demo", day)
+	if partition != `CREATE TABLE "demo_20240305" PARTITION OF "demo" FOR VALUES FROM ('2024-03-05') TO ('2024-03-06')` {
+		t.Errorf("unexpected partition: %s", partition)
+	}
+
+	insert := gendataInsertSQL("demo", day, 2, 1000, columns, "sequential", 64)
+	for _, want := range []string{
+		`INSERT INTO "demo_20240305" (id, created_at, "c_integer", "c_text", "c_jsonb", "c_huge_text")`,
+		"2000 + g",
+		"timestamptz '2024-03-05 00:00:00+00' + ((g - 1)::float8 / 1000) * interval '1 day'",
+		"CASE WHEN random() < 0.25 THEN NULL ELSE md5(g::text || random()::text) END",
+		", NULL, repeat(md5(g::text), 2)",
+		"FROM generate_series($1::bigint, $2::bigint) AS g",
+	} {
+		if !strings.Contains(insert, want) {
+			t.Errorf("insert missing %q: %s", want, insert)
+		}
+	}
+	if strings.Contains(insert, "{") {
+		t.Errorf("insert has unreplaced placeholders: %s", insert)
+	}
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Static errors for the history command
+var (
+	ErrHistoryOutputFormatInvalid = errors.New("history output format must be one of: text, json")
+	ErrHistoryRunNotFound         = errors.New("run not found")
+)
+
+var (
+	historyTable        string
+	historyLimit        int
+	historyRunID        string
+	historyOutputFormat string
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show past runs reconstructed from their results logs",
+	Long: `Show past archive runs, newest first. Every partition result is appended to a results log
+(~/.data-archiver/runs) as soon as it completes, so runs that were killed or crashed still show
+how far they got. Use --run to list the failed partitions of a single run.`,
+	RunE: runHistory,
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+
+	historyCmd.Flags().StringVar(&historyTable, "table", "", "only show runs for this table")
+	historyCmd.Flags().IntVar(&historyLimit, "limit", 20, "maximum number of runs to show (0 = all)")
+	historyCmd.Flags().StringVar(&historyRunID, "run", "", "show details for a single run ID")
+	historyCmd.Flags().StringVar(&historyOutputFormat, "output-format", "text", "Output format: text, json")
+
+	_ = viper.BindPFlag("history.table", historyCmd.Flags().Lookup("table"))
+	_ = viper.BindPFlag("history.limit", historyCmd.Flags().Lookup("limit"))
+	_ = viper.BindPFlag("history.run", historyCmd.Flags().Lookup("run"))
+	_ = viper.BindPFlag("history.output_format", historyCmd.Flags().Lookup("output-format"))
+}
+
+func runHistory(_ *cobra.Command, _ []string) error {
+	outputFormat := viper.GetString("history.output_format")
+	if outputFormat != "text" && outputFormat != "json" {
+		return fmt.Errorf("%w: '%s'", ErrHistoryOutputFormatInvalid, outputFormat)
+	}
+
+	runs, err := listRunSummaries(getResultsLogDir(), viper.GetString("history.table"), IsProcessRunning)
+	if err != nil {
+		return err
+	}
+
+	if runID := viper.GetString("history.run"); runID != "" {
+		run, ok := findRun(runs, runID)
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrHistoryRunNotFound, runID)
+		}
+		if outputFormat == "json" {
+			return writeHistoryJSON(os.Stdout, run)
+		}
+		writeRunDetail(os.Stdout, run)
+		return nil
+	}
+
+	if limit := viper.GetInt("history.limit"); limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+	if outputFormat == "json" {
+		if runs == nil {
+			runs = []RunSummary{}
+		}
+		return writeHistoryJSON(os.Stdout, runs)
+	}
+	writeHistoryText(os.Stdout, runs)
+	return nil
+}
+
+// findRun returns the run with the given ID. A run ID shared by several
+// tables of a multi-table run matches the first (newest) one.
+func findRun(runs []RunSummary, runID string) (RunSummary, bool) {
+	for _, run := range runs {
+		if run.RunID == runID {
+			return run, true
+		}
+	}
+	return RunSummary{}, false
+}
+
+func writeHistoryJSON(w io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// writeHistoryText renders one line per run
+func writeHistoryText(w io.Writer, runs []RunSummary) {
+	if len(runs) == 0 {
+		fmt.Fprintf(w, "No runs recorded in %s\n", getResultsLogDir())
+		return
+	}
+
+	fmt.Fprintf(w, "%-26s %-8s %-24s %-12s %-16s %9s %8s %7s %6s %10s\n",
+		"RUN", "COMMAND", "TABLE", "STATUS", "STARTED", "DURATION", "OK", "SKIPPED", "FAILED", "BYTES")
+	for _, run := range runs {
+		fmt.Fprintf(w, "%-26s %-8s %-24s %-12s %-16s %9s %8d %7d %6d %10s\n",
+			run.RunID, run.Command, run.Table, run.Status,
+			run.StartTime.Local().Format("2006-01-02 15:04"), formatRunDuration(run),
+			run.Successful, run.Skipped, run.Failed, formatBytes(run.Bytes))
+	}
+}
+
+// writeRunDetail renders a single run with its failed partitions
+func writeRunDetail(w io.Writer, run RunSummary) {
+	fmt.Fprintf(w, "Run:        %s\n", run.RunID)
+	fmt.Fprintf(w, "Command:    %s\n", run.Command)
+	fmt.Fprintf(w, "Table:      %s\n", run.Table)
+	fmt.Fprintf(w, "Status:     %s\n", run.Status)
+	fmt.Fprintf(w, "Started:    %s\n", run.StartTime.Local().Format(time.RFC3339))
+	if run.EndTime != nil {
+		fmt.Fprintf(w, "Ended:      %s\n", run.EndTime.Local().Format(time.RFC3339))
+	} else {
+		fmt.Fprintf(w, "Last seen:  %s\n", run.LastUpdate.Local().Format(time.RFC3339))
+	}
+	fmt.Fprintf(w, "Duration:   %s\n", formatRunDuration(run))
+	fmt.Fprintf(w, "Partitions: %d processed (%d ok, %d skipped, %d failed)\n",
+		run.Processed(), run.Successful, run.Skipped, run.Failed)
+	if len(run.Denied) > 0 {
+		fmt.Fprintf(w, "Denied:     %d partitions without SELECT permission\n", len(run.Denied))
+	}
+	fmt.Fprintf(w, "Rows:       %d\n", run.Rows)
+	fmt.Fprintf(w, "Bytes:      %s\n", formatBytes(run.Bytes))
+	if run.Error != "" {
+		fmt.Fprintf(w, "Error:      %s\n", run.Error)
+	}
+	fmt.Fprintf(w, "Log:        %s\n", run.Path)
+
+	if len(run.Failures) > 0 {
+		fmt.Fprintf(w, "\nFailed partitions:\n")
+		for _, failure := range run.Failures {
+			if failure.Stage != "" {
+				fmt.Fprintf(w, "  ❌ %s (%s): %s\n", failure.Partition, failure.Stage, failure.Error)
+			} else {
+				fmt.Fprintf(w, "  ❌ %s: %s\n", failure.Partition, failure.Error)
+			}
+		}
+	}
+
+	if len(run.Denied) > 0 {
+		fmt.Fprintf(w, "\nPermission denied:\n")
+		for _, partition := range run.Denied {
+			fmt.Fprintf(w, "  🔒 %s\n", partition)
+		}
+	}
+
+	if len(run.DateMismatches) > 0 {
+		fmt.Fprintf(w, "\nPartition date mismatches:\n")
+		for _, partition := range run.DateMismatches {
+			fmt.Fprintf(w, "  ⚠️  %s\n", partition)
+		}
+	}
+}
+
+// formatRunDuration returns the run's duration, up to its last record when it never finished
+func formatRunDuration(run RunSummary) string {
+	end := run.LastUpdate
+	if run.EndTime != nil {
+		end = *run.EndTime
+	}
+	return end.Sub(run.StartTime).Round(time.Second).String()
+}
@@ -51,8 +51,14 @@ func runHybridDump() {
 		EndDate:        viper.GetString("end_date"),
 		OutputDuration: viper.GetString("output_duration"),
 		DateColumn:     viper.GetString("date_column"),
+
+		DateColumnType:   viper.GetString("date_column_type"),
+		DateColumnFormat: viper.GetString("date_column_format"),
 	}
 
+	var s3Err error
+	config.S3, s3Err = newS3Config(config.S3, endpointProfileName("dump_hybrid"))
+
 	config.CacheScope = NewCacheScope("dump-hybrid", config)
 
 	if config.OutputDuration == "" {
@@ -63,8 +69,13 @@ func runHybridDump() {
 
 	logger.Info("")
 	logger.Info(fmt.Sprintf("🚀 Data Archiver v%s - hybrid pg_dump", Version))
+	logger.Info(fmt.Sprintf("   Run ID: %s", currentRunID))
 	logger.Info("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 
+	if s3Err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", s3Err.Error()))
+		os.Exit(1)
+	}
 	if err := validateHybridConfig(config); err != nil {
 		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
 		os.Exit(1)
@@ -85,6 +96,9 @@ func runHybridDump() {
 		logger.Error(fmt.Sprintf("❌ Data dump configuration error: %s", err.Error()))
 		os.Exit(1)
 	}
+	for _, warning := range dataConfig.pathTemplateWarnings() {
+		logger.Warn(fmt.Sprintf("⚠️  %s", warning))
+	}
 
 	ctx := signalContext
 	if ctx == nil {
@@ -94,6 +108,10 @@ func runHybridDump() {
 		defer stop()
 	}
 
+	stopStopFileWatcher := startStopFileWatcher("dump-hybrid", config.Table)
+	defer stopStopFileWatcher()
+	logStopFileHint("dump")
+
 	logger.Info("Step 1/2: dumping schema (excluding partitions)...")
 	schemaExecutor := NewPgDumpExecutor(&schemaConfig, logger)
 	if err := schemaExecutor.Run(ctx); err != nil {
@@ -129,6 +147,9 @@ func validateHybridConfig(config *Config) error {
 	if !validPostgreSQLIdentifier.MatchString(config.DateColumn) {
 		return fmt.Errorf("%w: '%s'", ErrDateColumnInvalid, config.DateColumn)
 	}
+	if err := config.dateColumnSpec().Validate(); err != nil {
+		return err
+	}
 
 	if config.StartDate == "" && config.EndDate == "" {
 		return errHybridDateRangeRequired
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// maxIdentifierBytes is PostgreSQL's identifier length limit (NAMEDATALEN - 1).
+// Longer names are silently truncated by the server.
+const maxIdentifierBytes = 63
+
+// ErrIdentifierTooLong is returned when a generated table name would be truncated
+var ErrIdentifierTooLong = errors.New("name exceeds PostgreSQL's 63-byte identifier limit")
+
+// isTableNameRune reports whether r may appear in a table name after the
+// first character. Any letter or digit is accepted, so mixed-case and
+// non-ASCII names work, as are dots; every SQL use quotes the name. Quotes,
+// semicolons, slashes, hyphens, whitespace, and control characters are rejected.
+func isTableNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) ||
+		r == '_' || r == '$' || r == '.'
+}
+
+// partitionSuffix returns what follows "<base>_" in a partition name, or false
+// when the name does not belong to base. Matching is exact and case-sensitive,
+// as PostgreSQL compares quoted identifiers.
+func partitionSuffix(base, tableName string) (string, bool) {
+	prefix := base + "_"
+	if len(tableName) <= len(prefix) || !strings.HasPrefix(tableName, prefix) {
+		return "", false
+	}
+	return tableName[len(prefix):], true
+}
+
+// partitionNameDate returns the date in the name of a partition of base, at
+// midnight in loc: the day of {base}_YYYYMMDD and {base}_pYYYYMMDD, or the
+// first of the month of {base}_YYYY_MM and {base}_YYYYMM.
+func partitionNameDate(base, tableName string, loc *time.Location) (time.Time, bool) {
+	suffix, ok := partitionSuffix(base, tableName)
+	if !ok {
+		return time.Time{}, false
+	}
+	var layout, value string
+	switch {
+	case len(suffix) == 8:
+		layout, value = "20060102", suffix
+	case len(suffix) == 9 && suffix[0] == 'p':
+		layout, value = "20060102", suffix[1:]
+	case len(suffix) == 7 && suffix[4] == '_':
+		layout, value = "200601", suffix[:4]+suffix[5:]
+	case len(suffix) == 6:
+		layout, value = "200601", suffix
+	default:
+		return time.Time{}, false
+	}
+	if !isDigits(value) {
+		return time.Time{}, false
+	}
+	date, err := parseLocalDate(layout, value, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// qualifiedTableName returns the schema-qualified, quoted name of a table in
+// the default schema. The result is also a literal pg_dump -t pattern: quoting
+// keeps dots, case, and pattern characters from being interpreted.
+func qualifiedTableName(tableName string) string {
+	return pq.QuoteIdentifier(defaultTableSchema) + "." + pq.QuoteIdentifier(tableName)
+}
+
+// checkIdentifierLength rejects names PostgreSQL would truncate
+func checkIdentifierLength(name string) error {
+	if len(name) > maxIdentifierBytes {
+		return fmt.Errorf("%w: '%s' is %d bytes", ErrIdentifierTooLong, name, len(name))
+	}
+	return nil
+}
+
+// objectKeyComponent makes a table name safe to use in S3 keys and filenames.
+// Slashes, backslashes, whitespace, and control characters become
+// underscores; everything else, including case and dots, is kept, so ordinary
+// names are unchanged.
+func objectKeyComponent(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || unicode.IsSpace(r) || unicode.IsControl(r) || r == utf8.RuneError {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// tableFileComponent returns a component for local file names (cache files,
+// restore ledgers, stop files) that is unique per exact table name. Names that
+// sanitizeCacheComponent changes, such as "Flights" or "events.v2", get a
+// short hash of the original so they never share a file with "flights" or
+// "events_v2".
+func tableFileComponent(table, fallback string) string {
+	component := sanitizeCacheComponent(table, fallback)
+	if table == "" || component == table {
+		return component
+	}
+	sum := sha1.Sum([]byte(table))
+	return component + "-" + hex.EncodeToString(sum[:])[:8]
+}
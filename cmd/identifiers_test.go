@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPartitionSuffix(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      string
+		tableName string
+		want      string
+		wantOk    bool
+	}{
+		{"Simple", "flights", "flights_20240315", "20240315", true},
+		{"MixedCase", "Flights", "Flights_20240315", "20240315", true},
+		{"CaseMismatch", "Flights", "flights_20240315", "", false},
+		{"Dotted", "events.v2", "events.v2_2024_03", "2024_03", true},
+		{"DotNotWildcard", "events.v2", "eventsxv2_2024_03", "", false},
+		{"NonASCII", "航班", "航班_20240315", "20240315", true},
+		{"BaseOnly", "flights", "flights_", "", false},
+		{"OtherTable", "flights", "flights2_20240315", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := partitionSuffix(tt.base, tt.tableName)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("partitionSuffix(%q, %q) = %q, %v; want %q, %v", tt.base, tt.tableName, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestExtractDateFromExoticTableNames(t *testing.T) {
+	tests := []struct {
+		base      string
+		partition string
+		want      time.Time
+	}{
+		{"Flights", "Flights_20240315", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"events.v2", "events.v2_2024_03", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"événements", "événements_p20240315", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.partition, func(t *testing.T) {
+			archiver := NewArchiver(&Config{Table: tt.base}, newTestLogger())
+			got, ok := archiver.extractDateFromTableName(tt.partition)
+			if !ok {
+				t.Fatalf("extractDateFromTableName(%q) failed", tt.partition)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("extractDateFromTableName(%q) = %v, want %v", tt.partition, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQualifiedTableName(t *testing.T) {
+	tests := map[string]string{
+		"flights":   `"public"."flights"`,
+		"Flights":   `"public"."Flights"`,
+		"events.v2": `"public"."events.v2"`,
+		`odd"name`:  `"public"."odd""name"`,
+	}
+	for name, want := range tests {
+		if got := qualifiedTableName(name); got != want {
+			t.Errorf("qualifiedTableName(%q) = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestCheckIdentifierLength(t *testing.T) {
+	if err := checkIdentifierLength(strings.Repeat("a", maxIdentifierBytes)); err != nil {
+		t.Errorf("63-byte name rejected: %v", err)
+	}
+	// 22 three-byte runes are 66 bytes, although only 22 characters
+	if err := checkIdentifierLength(strings.Repeat("航", 22)); !errors.Is(err, ErrIdentifierTooLong) {
+		t.Errorf("expected ErrIdentifierTooLong, got %v", err)
+	}
+}
+
+func TestObjectKeyComponent(t *testing.T) {
+	tests := map[string]string{
+		"flights":    "flights",
+		"Flights.V2": "Flights.V2",
+		"événements": "événements",
+		"a/b":        "a_b",
+		`a\b`:        "a_b",
+		"a b\tc":     "a_b_c",
+		"a\x00b":     "a_b",
+	}
+	for name, want := range tests {
+		if got := objectKeyComponent(name); got != want {
+			t.Errorf("objectKeyComponent(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestGenerateFilenameExoticTableNames(t *testing.T) {
+	date := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	got := GenerateFilename("Flights.V2", date, DurationDaily, ".jsonl", ".zst")
+	if got != "Flights.V2-2024-03-15.jsonl.zst" {
+		t.Errorf("GenerateFilename() = %q", got)
+	}
+
+	path := NewPathTemplate("archives/{table}/{YYYY}").Generate("a/b", date)
+	if path != "archives/a_b/2024" {
+		t.Errorf("Generate() = %q, want archives/a_b/2024", path)
+	}
+}
+
+func TestTableFileComponent(t *testing.T) {
+	if got := tableFileComponent("flights", "table"); got != "flights" {
+		t.Errorf("plain name changed: %q", got)
+	}
+	if got := tableFileComponent("", "table"); got != "table" {
+		t.Errorf("empty name = %q, want fallback", got)
+	}
+
+	// Names that sanitize to the same component must stay distinct
+	names := []string{"flights", "Flights", "FLIGHTS", "events_v2", "events.v2", "Events.V2"}
+	seen := make(map[string]string)
+	for _, name := range names {
+		component := tableFileComponent(name, "table")
+		if other, ok := seen[component]; ok {
+			t.Errorf("%q and %q share component %q", name, other, component)
+		}
+		seen[component] = name
+	}
+}
+
+func TestCacheScopeDistinguishesTableCase(t *testing.T) {
+	lower := CacheScope{Command: "archive", Table: "flights", OutputPath: "s3://bucket/{table}"}
+	upper := CacheScope{Command: "archive", Table: "Flights", OutputPath: "s3://bucket/{table}"}
+	if lower.fileIdentifier() == upper.fileIdentifier() {
+		t.Errorf("flights and Flights share cache file %s", lower.fileIdentifier())
+	}
+}
@@ -0,0 +1,392 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// defaultIntegrityPrefix is where integrity ledgers are copied in the bucket
+const defaultIntegrityPrefix = "_data-archiver/integrity"
+
+// Integrity ledger events
+const (
+	IntegrityEventArchived = "archived" // An archive file was uploaded
+	IntegrityEventDeleted  = "deleted"  // An archive file was removed on purpose
+)
+
+// Static errors for the integrity ledger
+var (
+	ErrIntegrityPrefixRequired   = errors.New("integrity ledger prefix is required")
+	ErrIntegrityKeyEmpty         = errors.New("integrity signing key file is empty")
+	ErrIntegrityLedgerDiverged   = errors.New("local and S3 copies of the integrity ledger differ")
+	ErrIntegrityLedgerTampered   = errors.New("integrity ledger failed verification")
+	ErrIntegrityLedgerNoS3Client = errors.New("integrity ledger needs an S3 connection")
+)
+
+var (
+	integrityLedgerEnabled bool
+	integrityPrefix        string
+	integrityKeyFile       string
+)
+
+func init() {
+	archiveCmd.Flags().BoolVar(&integrityLedgerEnabled, "integrity-ledger", false, "append every uploaded file (key, MD5, size, rows) to a hash-chained integrity ledger kept locally and copied to the bucket")
+	archiveCmd.Flags().StringVar(&integrityPrefix, "integrity-prefix", defaultIntegrityPrefix, "bucket prefix for integrity ledgers")
+	archiveCmd.Flags().StringVar(&integrityKeyFile, "integrity-key-file", "", "file holding a secret used to sign integrity ledger entries with HMAC-SHA256 (empty = unsigned)")
+	_ = viper.BindPFlag("integrity.enabled", archiveCmd.Flags().Lookup("integrity-ledger"))
+	_ = viper.BindPFlag("integrity.prefix", archiveCmd.Flags().Lookup("integrity-prefix"))
+	_ = viper.BindPFlag("integrity.key_file", archiveCmd.Flags().Lookup("integrity-key-file"))
+}
+
+// integrityEntry is one line of an integrity ledger. Hash covers the entry's
+// fields and the previous entry's hash, so editing, reordering, or removing an
+// entry breaks the chain from that point on. Signature is an HMAC of Hash, so
+// without the key the chain can't be rebuilt after a change either.
+type integrityEntry struct {
+	Seq        int64     `json:"seq"`
+	Event      string    `json:"event"`
+	Key        string    `json:"key"`
+	MD5        string    `json:"md5"`
+	Size       int64     `json:"size"`
+	Rows       int64     `json:"rows"`
+	RecordedAt time.Time `json:"recorded_at"`
+	Prev       string    `json:"prev"`
+	Hash       string    `json:"hash"`
+	Signature  string    `json:"signature,omitempty"`
+}
+
+// digest returns the hash the entry should have
+func (e integrityEntry) digest() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		strconv.FormatInt(e.Seq, 10),
+		e.Event,
+		e.Key,
+		e.MD5,
+		strconv.FormatInt(e.Size, 10),
+		strconv.FormatInt(e.Rows, 10),
+		e.RecordedAt.UTC().Format(time.RFC3339Nano),
+		e.Prev,
+	}, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// signIntegrityHash returns the HMAC-SHA256 signature of an entry hash
+func signIntegrityHash(key []byte, hash string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(hash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// loadIntegrityKey reads the signing key from path, or returns nil without one
+func loadIntegrityKey(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read integrity key file: %w", err)
+	}
+	key := bytes.TrimSpace(data)
+	if len(key) == 0 {
+		return nil, fmt.Errorf("%w: '%s'", ErrIntegrityKeyEmpty, path)
+	}
+	return key, nil
+}
+
+// integrityLedgerKey returns the object key of a table's integrity ledger
+func integrityLedgerKey(prefix, table string) string {
+	return path.Join(strings.Trim(prefix, "/"), objectKeyComponent(table)+".jsonl")
+}
+
+// getIntegrityLedgerPath returns the local copy of a table's integrity ledger
+// for bucket
+func getIntegrityLedgerPath(bucket, table string) string {
+	name := fmt.Sprintf("%s_%s.jsonl", sanitizeCacheComponent(bucket, "bucket"), tableFileComponent(table, "table"))
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".data-archiver", "integrity", name)
+}
+
+// parseIntegrityEntries reads ledger lines
+func parseIntegrityEntries(data []byte) ([]integrityEntry, error) {
+	var entries []integrityEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry integrityEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// readLocalIntegrityLedger reads the local copy; a missing file has no entries
+func readLocalIntegrityLedger(path string) ([]byte, []integrityEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to read integrity ledger: %w", err)
+	}
+	entries, err := parseIntegrityEntries(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse integrity ledger %s: %w", path, err)
+	}
+	return data, entries, nil
+}
+
+// readS3IntegrityLedger reads the bucket copy. found is false when there is none.
+func readS3IntegrityLedger(ctx context.Context, client StorageBackend, bucket, key string) (data []byte, entries []integrityEntry, found bool, err error) {
+	body, _, err := client.Get(ctx, bucket, key, GetOptions{})
+	if err != nil {
+		if isObjectNotFound(err) {
+			return nil, nil, false, nil
+		}
+		return nil, nil, false, fmt.Errorf("failed to read integrity ledger %s: %w", key, err)
+	}
+	defer body.Close()
+	data, err = io.ReadAll(body)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to read integrity ledger %s: %w", key, err)
+	}
+	entries, err = parseIntegrityEntries(data)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to parse integrity ledger %s: %w", key, err)
+	}
+	return data, entries, true, nil
+}
+
+// sameAs reports whether two copies of an entry are identical
+func (e integrityEntry) sameAs(other integrityEntry) bool {
+	return e.Hash == other.Hash && e.Signature == other.Signature && e.digest() == other.digest()
+}
+
+// isIntegrityPrefix reports whether prefix's entries begin entries
+func isIntegrityPrefix(prefix, entries []integrityEntry) bool {
+	if len(prefix) > len(entries) {
+		return false
+	}
+	for i := range prefix {
+		if !prefix[i].sameAs(entries[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// integrityLedger appends entries for one table's archive files. Entries go to
+// the local file as files are uploaded; the bucket copy is rewritten from it
+// when the run ends. Concurrent runs for the same table from different hosts
+// fork the chain, so run one archiver per table.
+type integrityLedger struct {
+	mu       sync.Mutex
+	bucket   string
+	s3Key    string
+	path     string
+	key      []byte // HMAC signing key (nil = unsigned)
+	opened   bool
+	lastSeq  int64
+	lastHash string
+	dirty    bool // Local entries not yet in the bucket copy
+}
+
+func newIntegrityLedger(config *Config) *integrityLedger {
+	return &integrityLedger{
+		bucket: config.S3.Bucket,
+		s3Key:  integrityLedgerKey(config.IntegrityPrefix, config.Table),
+		path:   getIntegrityLedgerPath(config.S3.Bucket, config.Table),
+	}
+}
+
+// open reconciles the local and bucket copies before the first append. The
+// longer copy wins when the other is a prefix of it, so a run on a new host
+// continues the bucket's chain and entries from a crashed run still reach the
+// bucket. A chain that fails verification is never appended to.
+func (l *integrityLedger) open(ctx context.Context, client StorageBackend, keyFile string) error {
+	key, err := loadIntegrityKey(keyFile)
+	if err != nil {
+		return err
+	}
+	l.key = key
+
+	_, local, err := readLocalIntegrityLedger(l.path)
+	if err != nil {
+		return err
+	}
+	remoteData, remote, _, err := readS3IntegrityLedger(ctx, client, l.bucket, l.s3Key)
+	if err != nil {
+		return err
+	}
+
+	entries := local
+	switch {
+	case isIntegrityPrefix(remote, local):
+		l.dirty = len(local) > len(remote)
+	case isIntegrityPrefix(local, remote):
+		if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(l.path, remoteData, 0o600); err != nil {
+			return fmt.Errorf("failed to write integrity ledger: %w", err)
+		}
+		entries = remote
+	default:
+		return fmt.Errorf("%w: %s and s3://%s/%s; run ledger verify", ErrIntegrityLedgerDiverged, l.path, l.bucket, l.s3Key)
+	}
+
+	if issues := checkIntegrityChain(entries, nil); len(issues) > 0 {
+		return fmt.Errorf("%w: %s; run ledger verify", ErrIntegrityLedgerTampered, issues[0])
+	}
+	if len(entries) > 0 {
+		last := entries[len(entries)-1]
+		l.lastSeq, l.lastHash = last.Seq, last.Hash
+	}
+	l.opened = true
+	return nil
+}
+
+// openExistingIntegrityLedger opens the integrity ledger of a table that has
+// one, locally or in the bucket, so commands other than archive can record
+// their changes. It returns nil when the table has no ledger.
+func openExistingIntegrityLedger(ctx context.Context, client StorageBackend, bucket, prefix, table, keyFile string) (*integrityLedger, error) {
+	ledger := newIntegrityLedger(&Config{Table: table, S3: S3Config{Bucket: bucket}, IntegrityPrefix: prefix})
+	_, local, err := readLocalIntegrityLedger(ledger.path)
+	if err != nil {
+		return nil, err
+	}
+	_, _, found, err := readS3IntegrityLedger(ctx, client, bucket, ledger.s3Key)
+	if err != nil {
+		return nil, err
+	}
+	if len(local) == 0 && !found {
+		return nil, nil
+	}
+	if err := ledger.open(ctx, client, keyFile); err != nil {
+		return nil, err
+	}
+	return ledger, nil
+}
+
+// append chains entry onto the ledger and writes it to the local file
+func (l *integrityLedger) append(ctx context.Context, client StorageBackend, keyFile string, entry integrityEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.opened {
+		if err := l.open(ctx, client, keyFile); err != nil {
+			return err
+		}
+	}
+
+	entry.Seq = l.lastSeq + 1
+	entry.RecordedAt = time.Now().UTC()
+	entry.Prev = l.lastHash
+	entry.Hash = entry.digest()
+	if l.key != nil {
+		entry.Signature = signIntegrityHash(l.key, entry.Hash)
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode integrity entry: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open integrity ledger: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write integrity ledger: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write integrity ledger: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write integrity ledger: %w", err)
+	}
+
+	l.lastSeq, l.lastHash = entry.Seq, entry.Hash
+	l.dirty = true
+	return nil
+}
+
+// flush copies the local ledger to the bucket when it has new entries
+func (l *integrityLedger) flush(ctx context.Context, client StorageBackend) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.dirty {
+		return nil
+	}
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to read integrity ledger: %w", err)
+	}
+	_, err = client.Put(ctx, l.bucket, l.s3Key, bytes.NewReader(data), PutOptions{ContentType: "application/x-ndjson"})
+	if err != nil {
+		return fmt.Errorf("failed to write integrity ledger %s: %w", l.s3Key, err)
+	}
+	l.dirty = false
+	return nil
+}
+
+// recordIntegrity appends an event for an archive file to the integrity
+// ledger. Without --integrity-ledger it does nothing.
+func (a *Archiver) recordIntegrity(event, objectKey, md5Hash string, size, rows int64) error {
+	if a.integrity == nil {
+		return nil
+	}
+	if a.s3Client == nil {
+		return ErrIntegrityLedgerNoS3Client
+	}
+	ctx := a.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	entry := integrityEntry{Event: event, Key: objectKey, MD5: md5Hash, Size: size, Rows: rows}
+	if err := a.integrity.append(ctx, a.s3Client, a.config.IntegrityKeyFile, entry); err != nil {
+		return fmt.Errorf("integrity ledger not updated: %w", err)
+	}
+	return nil
+}
+
+// flushIntegrityLedger copies the run's ledger entries to the bucket. A
+// failure is logged; the local copy keeps them for the next run to copy.
+func (a *Archiver) flushIntegrityLedger() {
+	if a.integrity == nil || a.s3Client == nil {
+		return
+	}
+
+	// Copy the entries even if the run was cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := a.integrity.flush(ctx, a.s3Client); err != nil {
+		a.logger.Warn(fmt.Sprintf("⚠️  Integrity ledger not copied to S3 (kept in %s): %v", a.integrity.path, err))
+		return
+	}
+	a.logger.Debug(fmt.Sprintf("Integrity ledger at s3://%s/%s", a.config.S3.Bucket, a.integrity.s3Key))
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// putArchiveObject stores an archive file and returns its MD5
+func putArchiveObject(store *fakeObjectStore, key, content string) string {
+	store.objects[key] = []byte(content)
+	sum := md5.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestIntegrityLedger(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	keyFile := filepath.Join(t.TempDir(), "ledger.key")
+	if err := os.WriteFile(keyFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	store := &fakeObjectStore{objects: map[string][]byte{}}
+	config := &Config{Table: "events", S3: S3Config{Bucket: "archive"}, IntegrityPrefix: defaultIntegrityPrefix}
+
+	ledger := newIntegrityLedger(config)
+	for _, key := range []string{"events/2024/01/events-2024-01-01.jsonl.zst", "events/2024/01/events-2024-01-02.jsonl.zst"} {
+		md5Hash := putArchiveObject(store, key, "rows of "+key)
+		entry := integrityEntry{Event: IntegrityEventArchived, Key: key, MD5: md5Hash, Size: int64(len("rows of " + key)), Rows: 10}
+		if err := ledger.append(ctx, newS3ObjectStore(store), keyFile, entry); err != nil {
+			t.Fatalf("append() error = %v", err)
+		}
+	}
+	if err := ledger.flush(ctx, newS3ObjectStore(store)); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+
+	key, _ := loadIntegrityKey(keyFile)
+	opts := ledgerVerifyOptions{Bucket: "archive", Table: "events", Prefix: defaultIntegrityPrefix, Key: key, LocalPath: ledger.path}
+	report, err := verifyIntegrityLedger(ctx, newS3ObjectStore(store), opts)
+	if err != nil {
+		t.Fatalf("verifyIntegrityLedger() error = %v", err)
+	}
+	if report.Entries != 2 || report.Signed != 2 || len(report.Issues) != 0 {
+		t.Fatalf("expected a clean signed ledger, got %+v", report)
+	}
+
+	// A host without the local copy continues the bucket's chain
+	if err := os.Remove(ledger.path); err != nil {
+		t.Fatal(err)
+	}
+	md5Hash := putArchiveObject(store, "events/2024/01/events-2024-01-03.jsonl.zst", "third")
+	ledger = newIntegrityLedger(config)
+	if err := ledger.append(ctx, newS3ObjectStore(store), keyFile, integrityEntry{Event: IntegrityEventArchived, Key: "events/2024/01/events-2024-01-03.jsonl.zst", MD5: md5Hash, Size: 5}); err != nil {
+		t.Fatalf("append() error = %v", err)
+	}
+	if ledger.lastSeq != 3 {
+		t.Errorf("expected the third entry, got %d", ledger.lastSeq)
+	}
+
+	// Until the run ends, the bucket copy is two entries behind the local one
+	report, _ = verifyIntegrityLedger(ctx, newS3ObjectStore(store), opts)
+	if len(report.Issues) != 1 || report.Issues[0].Kind != IntegrityIssueMissingEntry {
+		t.Errorf("expected the S3 copy to be reported short, got %+v", report.Issues)
+	}
+	if err := ledger.flush(ctx, newS3ObjectStore(store)); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+
+	// A changed file and a removed one are both reported
+	store.objects["events/2024/01/events-2024-01-01.jsonl.zst"] = []byte("rows of events/2024/01/events-2024-01-01.jsonl.zsT")
+	delete(store.objects, "events/2024/01/events-2024-01-02.jsonl.zst")
+	store.objects["events/2024/01/events-2024-01-04.jsonl.zst"] = []byte("unrecorded")
+	opts.PathTemplate = "{table}/{YYYY}/{MM}"
+	report, err = verifyIntegrityLedger(ctx, newS3ObjectStore(store), opts)
+	if err != nil {
+		t.Fatalf("verifyIntegrityLedger() error = %v", err)
+	}
+	kinds := issueKinds(report.Issues)
+	if kinds != "object-changed,object-missing,unrecorded-object" {
+		t.Errorf("unexpected issues %s: %+v", kinds, report.Issues)
+	}
+}
+
+func TestIntegrityLedgerTampering(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx := context.Background()
+	store := &fakeObjectStore{objects: map[string][]byte{}}
+	config := &Config{Table: "events", S3: S3Config{Bucket: "archive"}, IntegrityPrefix: defaultIntegrityPrefix}
+	keyFile := filepath.Join(t.TempDir(), "ledger.key")
+	if err := os.WriteFile(keyFile, []byte("s3cret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ledger := newIntegrityLedger(config)
+	for i := 0; i < 3; i++ {
+		if err := ledger.append(ctx, newS3ObjectStore(store), keyFile, integrityEntry{Event: IntegrityEventArchived, Key: string(rune('a' + i)), Rows: 10}); err != nil {
+			t.Fatalf("append() error = %v", err)
+		}
+	}
+	if err := ledger.flush(ctx, newS3ObjectStore(store)); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+	s3Key := integrityLedgerKey(defaultIntegrityPrefix, "events")
+	original := store.objects[s3Key]
+	lines := strings.SplitAfter(string(original), "\n")
+	opts := ledgerVerifyOptions{Bucket: "archive", Table: "events", Prefix: defaultIntegrityPrefix, SkipObjects: true}
+
+	tests := []struct {
+		name  string
+		data  string
+		key   []byte
+		kinds string
+	}{
+		{"row count edited", strings.Replace(string(original), `"rows":10`, `"rows":11`, 1), nil, "entry-modified"},
+		{"entry removed", lines[0] + lines[2], nil, "missing-entries,chain-broken"},
+		{"wrong signing key", string(original), []byte("other"), "bad-signature,bad-signature,bad-signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store.objects[s3Key] = []byte(tt.data)
+			opts.Key = tt.key
+			report, err := verifyIntegrityLedger(ctx, newS3ObjectStore(store), opts)
+			if err != nil {
+				t.Fatalf("verifyIntegrityLedger() error = %v", err)
+			}
+			if kinds := issueKinds(report.Issues); kinds != tt.kinds {
+				t.Errorf("expected %s, got %s", tt.kinds, kinds)
+			}
+		})
+	}
+
+	// The local copy no longer matches the edited bucket copy, so the next
+	// run refuses to append
+	store.objects[s3Key] = bytes.Replace(original, []byte(`"rows":10`), []byte(`"rows":11`), 1)
+	opts.LocalPath = ledger.path
+	opts.Key = nil
+	report, _ := verifyIntegrityLedger(ctx, newS3ObjectStore(store), opts)
+	if !strings.HasPrefix(issueKinds(report.Issues), "copies-differ") {
+		t.Errorf("expected copies-differ, got %+v", report.Issues)
+	}
+	if err := newIntegrityLedger(config).append(ctx, newS3ObjectStore(store), keyFile, integrityEntry{Key: "d"}); !errors.Is(err, ErrIntegrityLedgerDiverged) {
+		t.Errorf("expected ErrIntegrityLedgerDiverged, got %v", err)
+	}
+
+	if _, err := verifyIntegrityLedger(ctx, newS3ObjectStore(store), ledgerVerifyOptions{Bucket: "archive", Table: "orders", Prefix: defaultIntegrityPrefix}); !errors.Is(err, ErrIntegrityLedgerNotFound) {
+		t.Errorf("expected ErrIntegrityLedgerNotFound, got %v", err)
+	}
+}
+
+func TestIntegrityLedgerConfig(t *testing.T) {
+	config := newTestConfig()
+	config.IntegrityLedger = true
+	config.IntegrityPrefix = defaultIntegrityPrefix
+	if err := config.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	config.IntegrityPrefix = "/"
+	if err := config.Validate(); !errors.Is(err, ErrIntegrityPrefixRequired) {
+		t.Errorf("expected ErrIntegrityPrefixRequired, got %v", err)
+	}
+	config.IntegrityPrefix = defaultIntegrityPrefix
+	config.IntegrityKeyFile = filepath.Join(t.TempDir(), "empty.key")
+	if err := os.WriteFile(config.IntegrityKeyFile, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Validate(); !errors.Is(err, ErrIntegrityKeyEmpty) {
+		t.Errorf("expected ErrIntegrityKeyEmpty, got %v", err)
+	}
+}
+
+func issueKinds(issues []integrityIssue) string {
+	kinds := make([]string, len(issues))
+	for i, issue := range issues {
+		kinds[i] = issue.Kind
+	}
+	return strings.Join(kinds, ",")
+}
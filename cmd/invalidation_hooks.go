@@ -0,0 +1,359 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/athena"
+	"github.com/aws/aws-sdk-go/service/athena/athenaiface"
+	"github.com/aws/aws-sdk-go/service/cloudfront"
+	"github.com/aws/aws-sdk-go/service/cloudfront/cloudfrontiface"
+	"github.com/spf13/viper"
+)
+
+// Static errors for invalidation hooks
+var (
+	ErrAthenaTableInvalid      = errors.New("athena table must be <database>.<table>")
+	ErrAthenaOutputRequired    = errors.New("athena output location or workgroup is required")
+	ErrAthenaOutputInvalid     = errors.New("athena output location must be an s3:// URL")
+	ErrAthenaQueryFailed       = errors.New("athena query failed")
+	ErrWebhookURLInvalid       = errors.New("invalidation webhook must be an http or https URL")
+	ErrWebhookFailed           = errors.New("invalidation webhook failed")
+	ErrCloudFrontPrefixInvalid = errors.New("cloudfront origin path must not contain '*'")
+)
+
+const (
+	webhookTimeout = 30 * time.Second
+	// webhookEventArchived is the event type posted to the invalidation webhook
+	webhookEventArchived = "archive.updated"
+)
+
+// athenaPollInterval is how often a running Athena query is checked
+var athenaPollInterval = time.Second
+
+// athenaTablePattern matches <database>.<table> with Athena's identifier characters
+var athenaTablePattern = regexp.MustCompile(`^[A-Za-z0-9_]+\.[A-Za-z0-9_]+$`)
+
+// InvalidationHooksConfig lists the downstream systems told about each date's
+// newly uploaded or rewritten objects. Each hook is off while its setting is empty.
+type InvalidationHooksConfig struct {
+	CloudFrontDistributionID string // Distribution whose cached copies of the objects are invalidated
+	CloudFrontOriginPath     string // Bucket prefix the distribution serves from, removed from invalidation paths
+	AthenaTable              string // <database>.<table> whose partition for the date is added (or repaired)
+	AthenaPartition          string // Partition spec template, e.g. dt='{YYYY}-{MM}-{DD}' (empty = MSCK REPAIR TABLE)
+	AthenaWorkgroup          string
+	AthenaOutputLocation     string // s3:// URL for query results (empty = the workgroup's setting)
+	WebhookURL               string // Receives a JSON POST for each date
+}
+
+var (
+	cloudFrontDistributionID string
+	cloudFrontOriginPath     string
+	athenaTable              string
+	athenaPartition          string
+	athenaWorkgroup          string
+	athenaOutputLocation     string
+	invalidationWebhook      string
+)
+
+func init() {
+	archiveCmd.Flags().StringVar(&cloudFrontDistributionID, "cloudfront-distribution-id", "", "CloudFront distribution to invalidate each date's uploaded objects in")
+	archiveCmd.Flags().StringVar(&cloudFrontOriginPath, "cloudfront-origin-path", "", "bucket prefix the CloudFront distribution serves from, removed from invalidation paths")
+	archiveCmd.Flags().StringVar(&athenaTable, "athena-table", "", "Athena table (<database>.<table>) to register each date's partition in")
+	archiveCmd.Flags().StringVar(&athenaPartition, "athena-partition", "", "partition spec added for each date, with {table}, {YYYY}, {MM}, {DD}, {HH} placeholders, e.g. dt='{YYYY}-{MM}-{DD}' (empty = MSCK REPAIR TABLE)")
+	archiveCmd.Flags().StringVar(&athenaWorkgroup, "athena-workgroup", "", "Athena workgroup to run partition queries in")
+	archiveCmd.Flags().StringVar(&athenaOutputLocation, "athena-output-location", "", "s3:// URL for Athena query results (default: the workgroup's setting)")
+	archiveCmd.Flags().StringVar(&invalidationWebhook, "invalidation-webhook", "", "URL that receives a JSON POST listing each date's uploaded objects")
+
+	_ = viper.BindPFlag("hooks.cloudfront.distribution_id", archiveCmd.Flags().Lookup("cloudfront-distribution-id"))
+	_ = viper.BindPFlag("hooks.cloudfront.origin_path", archiveCmd.Flags().Lookup("cloudfront-origin-path"))
+	_ = viper.BindPFlag("hooks.athena.table", archiveCmd.Flags().Lookup("athena-table"))
+	_ = viper.BindPFlag("hooks.athena.partition", archiveCmd.Flags().Lookup("athena-partition"))
+	_ = viper.BindPFlag("hooks.athena.workgroup", archiveCmd.Flags().Lookup("athena-workgroup"))
+	_ = viper.BindPFlag("hooks.athena.output_location", archiveCmd.Flags().Lookup("athena-output-location"))
+	_ = viper.BindPFlag("hooks.webhook_url", archiveCmd.Flags().Lookup("invalidation-webhook"))
+}
+
+// loadInvalidationHooksConfig reads the hook settings
+func loadInvalidationHooksConfig() InvalidationHooksConfig {
+	return InvalidationHooksConfig{
+		CloudFrontDistributionID: viper.GetString("hooks.cloudfront.distribution_id"),
+		CloudFrontOriginPath:     viper.GetString("hooks.cloudfront.origin_path"),
+		AthenaTable:              viper.GetString("hooks.athena.table"),
+		AthenaPartition:          viper.GetString("hooks.athena.partition"),
+		AthenaWorkgroup:          viper.GetString("hooks.athena.workgroup"),
+		AthenaOutputLocation:     viper.GetString("hooks.athena.output_location"),
+		WebhookURL:               viper.GetString("hooks.webhook_url"),
+	}
+}
+
+// enabled reports whether any hook is configured
+func (c InvalidationHooksConfig) enabled() bool {
+	return c.CloudFrontDistributionID != "" || c.AthenaTable != "" || c.WebhookURL != ""
+}
+
+// Validate checks the Athena table and query settings and the webhook URL
+func (c InvalidationHooksConfig) Validate() error {
+	if strings.Contains(c.CloudFrontOriginPath, "*") {
+		return fmt.Errorf("%w, got '%s'", ErrCloudFrontPrefixInvalid, c.CloudFrontOriginPath)
+	}
+	if c.AthenaTable != "" {
+		if !athenaTablePattern.MatchString(c.AthenaTable) {
+			return fmt.Errorf("%w, got '%s'", ErrAthenaTableInvalid, c.AthenaTable)
+		}
+		if c.AthenaOutputLocation == "" && c.AthenaWorkgroup == "" {
+			return ErrAthenaOutputRequired
+		}
+		if c.AthenaOutputLocation != "" && !strings.HasPrefix(c.AthenaOutputLocation, "s3://") {
+			return fmt.Errorf("%w, got '%s'", ErrAthenaOutputInvalid, c.AthenaOutputLocation)
+		}
+	}
+	if c.WebhookURL != "" {
+		u, err := url.Parse(c.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w, got '%s'", ErrWebhookURLInvalid, c.WebhookURL)
+		}
+	}
+	return nil
+}
+
+// invalidationHooks tells downstream systems about a date's new or changed
+// objects once they are in the bucket
+type invalidationHooks struct {
+	config     InvalidationHooksConfig
+	bucket     string
+	table      string
+	cloudfront cloudfrontiface.CloudFrontAPI
+	athena     athenaiface.AthenaAPI
+	http       *http.Client
+	logger     *slog.Logger
+}
+
+// invalidationWebhookPayload is the body POSTed to the invalidation webhook
+type invalidationWebhookPayload struct {
+	Event  string   `json:"event"`
+	Table  string   `json:"table"`
+	Date   string   `json:"date,omitempty"` // YYYY-MM-DD of the archived data
+	Bucket string   `json:"bucket"`
+	Keys   []string `json:"keys"`
+}
+
+func newInvalidationHooks(config *Config, logger *slog.Logger) *invalidationHooks {
+	return &invalidationHooks{
+		config: config.Hooks,
+		bucket: config.S3.Bucket,
+		table:  config.Table,
+		http:   &http.Client{Timeout: webhookTimeout},
+		logger: logger,
+	}
+}
+
+// connect creates the CloudFront and Athena clients. They talk to AWS with
+// the S3 credentials and region, whatever S3 endpoint the archives go to.
+func (h *invalidationHooks) connect(cfg S3Config) error {
+	if h.config.CloudFrontDistributionID == "" && h.config.AthenaTable == "" {
+		return nil
+	}
+	awsConfig := &aws.Config{HTTPClient: cfg.HTTP.newHTTPClient()}
+	if cfg.Region != "" && cfg.Region != regionAuto {
+		awsConfig.Region = aws.String(cfg.Region)
+	}
+	if cfg.AccessKey != "" {
+		awsConfig.Credentials = credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, "")
+	}
+	sess, err := newAWSSession(awsConfig, cfg.Profile)
+	if err != nil {
+		return fmt.Errorf("failed to create AWS session for invalidation hooks: %w", err)
+	}
+	if h.config.CloudFrontDistributionID != "" {
+		h.cloudfront = cloudfront.New(sess)
+	}
+	if h.config.AthenaTable != "" {
+		h.athena = athena.New(sess)
+	}
+	return nil
+}
+
+// run calls every configured hook for the objects of one date. A zero date
+// means the objects replaced others in place (a format conversion), so no
+// Athena partition needs adding. All hooks run even when one fails.
+func (h *invalidationHooks) run(ctx context.Context, date time.Time, keys []string) error {
+	var errs []error
+	if h.cloudfront != nil {
+		if err := h.invalidateCloudFront(ctx, date, keys); err != nil {
+			errs = append(errs, fmt.Errorf("cloudfront: %w", err))
+		}
+	}
+	if h.athena != nil && (!date.IsZero() || h.config.AthenaPartition == "") {
+		if err := h.updateAthena(ctx, date, keys); err != nil {
+			errs = append(errs, fmt.Errorf("athena: %w", err))
+		}
+	}
+	if h.config.WebhookURL != "" {
+		if err := h.postWebhook(ctx, date, keys); err != nil {
+			errs = append(errs, fmt.Errorf("webhook: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// invalidationPaths returns the CloudFront paths serving keys
+func (h *invalidationHooks) invalidationPaths(keys []string) []*string {
+	prefix := strings.Trim(h.config.CloudFrontOriginPath, "/")
+	paths := make([]*string, 0, len(keys))
+	for _, key := range keys {
+		if prefix != "" {
+			key = strings.TrimPrefix(key, prefix+"/")
+		}
+		paths = append(paths, aws.String("/"+key))
+	}
+	return paths
+}
+
+func (h *invalidationHooks) invalidateCloudFront(ctx context.Context, date time.Time, keys []string) error {
+	paths := h.invalidationPaths(keys)
+	out, err := h.cloudfront.CreateInvalidationWithContext(ctx, &cloudfront.CreateInvalidationInput{
+		DistributionId: aws.String(h.config.CloudFrontDistributionID),
+		InvalidationBatch: &cloudfront.InvalidationBatch{
+			// Unique per request, so CloudFront never mistakes one for a retry of another
+			CallerReference: aws.String(fmt.Sprintf("data-archiver-%s-%s-%d", h.table, date.Format("20060102"), time.Now().UnixNano())),
+			Paths:           &cloudfront.Paths{Items: paths, Quantity: aws.Int64(int64(len(paths)))},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if out.Invalidation != nil {
+		h.logger.Debug(fmt.Sprintf("   🌐 CloudFront invalidation %s for %d paths", aws.StringValue(out.Invalidation.Id), len(paths)))
+	}
+	return nil
+}
+
+// athenaQuery returns the statement registering a date's objects: ADD
+// PARTITION at the objects' prefix, or MSCK REPAIR TABLE without a spec
+func (h *invalidationHooks) athenaQuery(date time.Time, keys []string) string {
+	database, table, _ := strings.Cut(h.config.AthenaTable, ".")
+	name := "`" + database + "`.`" + table + "`"
+	if h.config.AthenaPartition == "" || len(keys) == 0 {
+		return "MSCK REPAIR TABLE " + name
+	}
+	spec := NewPathTemplate(h.config.AthenaPartition).Generate(h.table, date)
+	location := fmt.Sprintf("s3://%s/", h.bucket)
+	if dir := path.Dir(keys[0]); dir != "." {
+		location += dir + "/"
+	}
+	return fmt.Sprintf("ALTER TABLE %s ADD IF NOT EXISTS PARTITION (%s) LOCATION '%s'", name, spec, strings.ReplaceAll(location, "'", "\\'"))
+}
+
+// updateAthena runs the partition statement and waits for it to finish
+func (h *invalidationHooks) updateAthena(ctx context.Context, date time.Time, keys []string) error {
+	database, _, _ := strings.Cut(h.config.AthenaTable, ".")
+	input := &athena.StartQueryExecutionInput{
+		QueryString:           aws.String(h.athenaQuery(date, keys)),
+		QueryExecutionContext: &athena.QueryExecutionContext{Database: aws.String(database)},
+	}
+	if h.config.AthenaWorkgroup != "" {
+		input.WorkGroup = aws.String(h.config.AthenaWorkgroup)
+	}
+	if h.config.AthenaOutputLocation != "" {
+		input.ResultConfiguration = &athena.ResultConfiguration{OutputLocation: aws.String(h.config.AthenaOutputLocation)}
+	}
+	started, err := h.athena.StartQueryExecutionWithContext(ctx, input)
+	if err != nil {
+		return err
+	}
+	id := started.QueryExecutionId
+
+	ticker := time.NewTicker(athenaPollInterval)
+	defer ticker.Stop()
+	for {
+		out, err := h.athena.GetQueryExecutionWithContext(ctx, &athena.GetQueryExecutionInput{QueryExecutionId: id})
+		if err != nil {
+			return err
+		}
+		status := out.QueryExecution.Status
+		switch aws.StringValue(status.State) {
+		case athena.QueryExecutionStateSucceeded:
+			h.logger.Debug(fmt.Sprintf("   📇 Athena: %s", aws.StringValue(input.QueryString)))
+			return nil
+		case athena.QueryExecutionStateFailed, athena.QueryExecutionStateCancelled:
+			return fmt.Errorf("%w: %s %s", ErrAthenaQueryFailed, aws.StringValue(status.State), aws.StringValue(status.StateChangeReason))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *invalidationHooks) postWebhook(ctx context.Context, date time.Time, keys []string) error {
+	payload := invalidationWebhookPayload{
+		Event:  webhookEventArchived,
+		Table:  h.table,
+		Bucket: h.bucket,
+		Keys:   keys,
+	}
+	if !date.IsZero() {
+		payload.Date = date.Format("2006-01-02")
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.config.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "data-archiver/"+Version)
+	resp, err := h.http.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%w: %s", ErrWebhookFailed, resp.Status)
+	}
+	return nil
+}
+
+// notifyHooks runs the invalidation hooks for a date's uploaded objects. A
+// failing hook is logged; the archives themselves are already in place.
+func (a *Archiver) notifyHooks(date time.Time, keys []string) {
+	if a.hooks == nil || len(keys) == 0 {
+		return
+	}
+	ctx := a.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := a.hooks.run(ctx, date, keys); err != nil {
+		a.logger.Warn(fmt.Sprintf("   ⚠️  Invalidation hook failed for %s: %v", strings.Join(keys, ", "), err))
+	}
+}
+
+// uploadedKeys returns the objects an upload wrote: each part and the
+// manifest of a split archive, or the single archive
+func uploadedKeys(objectKey string, manifest splitManifest) []string {
+	if len(manifest.Parts) == 0 {
+		return []string{objectKey}
+	}
+	keys := make([]string, 0, len(manifest.Parts)+1)
+	for _, part := range manifest.Parts {
+		keys = append(keys, part.Key)
+	}
+	return append(keys, objectKey)
+}
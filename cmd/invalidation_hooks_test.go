@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/athena"
+	"github.com/aws/aws-sdk-go/service/athena/athenaiface"
+	"github.com/aws/aws-sdk-go/service/cloudfront"
+	"github.com/aws/aws-sdk-go/service/cloudfront/cloudfrontiface"
+)
+
+type fakeCloudFront struct {
+	cloudfrontiface.CloudFrontAPI
+	inputs []*cloudfront.CreateInvalidationInput
+}
+
+func (f *fakeCloudFront) CreateInvalidationWithContext(_ aws.Context, input *cloudfront.CreateInvalidationInput, _ ...request.Option) (*cloudfront.CreateInvalidationOutput, error) {
+	f.inputs = append(f.inputs, input)
+	return &cloudfront.CreateInvalidationOutput{Invalidation: &cloudfront.Invalidation{Id: aws.String("I1")}}, nil
+}
+
+type fakeAthena struct {
+	athenaiface.AthenaAPI
+	queries []string
+	states  []string // States returned by successive GetQueryExecution calls
+}
+
+func (f *fakeAthena) StartQueryExecutionWithContext(_ aws.Context, input *athena.StartQueryExecutionInput, _ ...request.Option) (*athena.StartQueryExecutionOutput, error) {
+	f.queries = append(f.queries, aws.StringValue(input.QueryString))
+	return &athena.StartQueryExecutionOutput{QueryExecutionId: aws.String("q1")}, nil
+}
+
+func (f *fakeAthena) GetQueryExecutionWithContext(_ aws.Context, _ *athena.GetQueryExecutionInput, _ ...request.Option) (*athena.GetQueryExecutionOutput, error) {
+	state := f.states[0]
+	if len(f.states) > 1 {
+		f.states = f.states[1:]
+	}
+	return &athena.GetQueryExecutionOutput{QueryExecution: &athena.QueryExecution{
+		Status: &athena.QueryExecutionStatus{State: aws.String(state), StateChangeReason: aws.String("HIVE_METASTORE_ERROR")},
+	}}, nil
+}
+
+func TestInvalidationHooksConfigValidate(t *testing.T) {
+	tests := []struct {
+		config  InvalidationHooksConfig
+		wantErr error
+	}{
+		{InvalidationHooksConfig{}, nil},
+		{InvalidationHooksConfig{AthenaTable: "logs.events", AthenaWorkgroup: "primary"}, nil},
+		{InvalidationHooksConfig{AthenaTable: "events", AthenaWorkgroup: "primary"}, ErrAthenaTableInvalid},
+		{InvalidationHooksConfig{AthenaTable: "logs.events"}, ErrAthenaOutputRequired},
+		{InvalidationHooksConfig{AthenaTable: "logs.events", AthenaOutputLocation: "/tmp/results"}, ErrAthenaOutputInvalid},
+		{InvalidationHooksConfig{WebhookURL: "https://hooks.example.com/archive"}, nil},
+		{InvalidationHooksConfig{WebhookURL: "ftp://hooks.example.com"}, ErrWebhookURLInvalid},
+		{InvalidationHooksConfig{CloudFrontDistributionID: "E123", CloudFrontOriginPath: "archive/*"}, ErrCloudFrontPrefixInvalid},
+	}
+	for _, tt := range tests {
+		if err := tt.config.Validate(); !errors.Is(err, tt.wantErr) {
+			t.Errorf("Validate(%+v) = %v, want %v", tt.config, err, tt.wantErr)
+		}
+	}
+
+	config := newTestConfig()
+	config.Hooks.AthenaTable = "events"
+	if err := config.Validate(); !errors.Is(err, ErrAthenaTableInvalid) {
+		t.Errorf("expected Config.Validate to check hooks, got %v", err)
+	}
+}
+
+func TestInvalidationHooksRun(t *testing.T) {
+	var payload invalidationWebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	originalPoll := athenaPollInterval
+	athenaPollInterval = time.Millisecond
+	defer func() { athenaPollInterval = originalPoll }()
+
+	config := newTestConfig()
+	config.Table = "events"
+	config.Hooks = InvalidationHooksConfig{
+		CloudFrontDistributionID: "E123",
+		CloudFrontOriginPath:     "/archive/",
+		AthenaTable:              "logs.events",
+		AthenaPartition:          "dt='{YYYY}-{MM}-{DD}'",
+		AthenaWorkgroup:          "primary",
+		WebhookURL:               server.URL,
+	}
+	hooks := newInvalidationHooks(config, newTestLogger())
+	cf := &fakeCloudFront{}
+	ath := &fakeAthena{states: []string{athena.QueryExecutionStateRunning, athena.QueryExecutionStateSucceeded}}
+	hooks.cloudfront, hooks.athena = cf, ath
+
+	manifest := splitManifest{Parts: []manifestPart{
+		{Key: "archive/events/2024/01/events-2024-01-01-part-0001.jsonl.zst"},
+		{Key: "archive/events/2024/01/events-2024-01-01-part-0002.jsonl.zst"},
+	}}
+	keys := uploadedKeys("archive/events/2024/01/events-2024-01-01.manifest.json", manifest)
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := hooks.run(context.Background(), date, keys); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	if len(cf.inputs) != 1 || aws.Int64Value(cf.inputs[0].InvalidationBatch.Paths.Quantity) != 3 ||
+		aws.StringValue(cf.inputs[0].InvalidationBatch.Paths.Items[2]) != "/events/2024/01/events-2024-01-01.manifest.json" {
+		t.Errorf("unexpected CloudFront invalidation: %+v", cf.inputs)
+	}
+	wantQuery := "ALTER TABLE `logs`.`events` ADD IF NOT EXISTS PARTITION (dt='2024-01-01') LOCATION 's3://test-bucket/archive/events/2024/01/'"
+	if len(ath.queries) != 1 || ath.queries[0] != wantQuery {
+		t.Errorf("Athena queries = %q, want %q", ath.queries, wantQuery)
+	}
+	if payload.Event != webhookEventArchived || payload.Table != "events" || payload.Date != "2024-01-01" || payload.Bucket != "test-bucket" || len(payload.Keys) != 3 {
+		t.Errorf("unexpected webhook payload: %+v", payload)
+	}
+
+	// A failing hook doesn't stop the others; a conversion (zero date) skips ADD PARTITION
+	ath.states = []string{athena.QueryExecutionStateFailed}
+	payload = invalidationWebhookPayload{}
+	err := hooks.run(context.Background(), date, keys[:1])
+	if !errors.Is(err, ErrAthenaQueryFailed) || !strings.Contains(err.Error(), "HIVE_METASTORE_ERROR") || len(payload.Keys) != 1 {
+		t.Errorf("expected the Athena failure reported and the webhook still called, got %v, %+v", err, payload)
+	}
+	payload = invalidationWebhookPayload{}
+	if err := hooks.run(context.Background(), time.Time{}, []string{"archive/events/a.jsonl.zst"}); err != nil || len(ath.queries) != 2 || payload.Date != "" {
+		t.Errorf("expected no Athena query for a conversion, got %v after %d queries", err, len(ath.queries))
+	}
+
+	hooks.config.AthenaPartition = ""
+	ath.states = []string{athena.QueryExecutionStateSucceeded}
+	_ = hooks.run(context.Background(), date, keys)
+	if ath.queries[len(ath.queries)-1] != "MSCK REPAIR TABLE `logs`.`events`" {
+		t.Errorf("expected MSCK REPAIR TABLE without a partition spec, got %q", ath.queries[len(ath.queries)-1])
+	}
+}
+
+func TestInvalidationWebhookStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	config := newTestConfig()
+	config.Hooks.WebhookURL = server.URL
+	hooks := newInvalidationHooks(config, newTestLogger())
+	if err := hooks.run(context.Background(), time.Now(), []string{"a"}); !errors.Is(err, ErrWebhookFailed) {
+		t.Errorf("expected ErrWebhookFailed, got %v", err)
+	}
+}
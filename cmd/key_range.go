@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/spf13/viper"
+)
+
+// Static errors for slicing tables by key range
+var (
+	ErrSplitColumnInvalid      = errors.New("split column is invalid: must start with a letter or underscore, and contain only letters, numbers, and underscores")
+	ErrSplitSizeInvalid        = errors.New("split size must be > 0 with --split-column")
+	ErrSplitSizeUnused         = errors.New("--split-size requires --split-column")
+	ErrSplitColumnConflict     = errors.New("--split-column cannot be combined with --date-column, a custom query, or --start-from-date")
+	ErrSplitColumnPathTemplate = errors.New("with --split-column the path template may only use {table}")
+)
+
+// keyRangePathPlaceholders are the path template placeholders that mean
+// something for key-range archives, which have no date
+var keyRangePathPlaceholders = []string{"table"}
+
+var (
+	splitColumn string
+	splitSize   int64
+)
+
+func init() {
+	archiveCmd.Flags().StringVar(&splitColumn, "split-column", "", "integer column (e.g. id) that slices tables that are not partitioned into key ranges, one file each")
+	archiveCmd.Flags().Int64Var(&splitSize, "split-size", 0, "keys per range with --split-column, e.g. 1000000")
+	_ = viper.BindPFlag("split_column", archiveCmd.Flags().Lookup("split-column"))
+	_ = viper.BindPFlag("split_size", archiveCmd.Flags().Lookup("split-size"))
+}
+
+// KeyRange is a slice of a table that is not partitioned, holding the rows
+// whose SplitColumn is in [Start, End)
+type KeyRange struct {
+	Column string
+	Start  int64
+	End    int64
+	Open   bool // Holds the table's largest key, so rows may still be added
+}
+
+// HasKeyRange reports whether the partition is a key range of its table
+func (p PartitionInfo) HasKeyRange() bool {
+	return p.Keys.Column != ""
+}
+
+// condition returns the WHERE condition selecting the range's rows
+func (r KeyRange) condition() (string, []interface{}) {
+	column := pq.QuoteIdentifier(r.Column)
+	return fmt.Sprintf("%s >= $1 AND %s < $2", column, column), []interface{}{r.Start, r.End}
+}
+
+// String names the range by its first and last key, for logs
+func (r KeyRange) String() string {
+	return fmt.Sprintf("%s %d-%d", r.Column, r.Start, r.End-1)
+}
+
+// keyRangeFilename names a key range's archive file by its first and last
+// key, zero-padded so the files of a table list in key order
+func keyRangeFilename(tableName string, keys KeyRange, formatExt, compressionExt string) string {
+	return fmt.Sprintf("%s-%s-%012d-%012d%s%s", objectKeyComponent(tableName), objectKeyComponent(keys.Column), keys.Start, keys.End-1, formatExt, compressionExt)
+}
+
+// validateKeyRanges checks --split-column and --split-size
+func (c *Config) validateKeyRanges() error {
+	if c.SplitColumn == "" {
+		if c.SplitSize != 0 {
+			return ErrSplitSizeUnused
+		}
+		return nil
+	}
+	if !validPostgreSQLIdentifier.MatchString(c.SplitColumn) {
+		return fmt.Errorf("%w: '%s'", ErrSplitColumnInvalid, c.SplitColumn)
+	}
+	if c.SplitSize <= 0 {
+		return fmt.Errorf("%w, got %d", ErrSplitSizeInvalid, c.SplitSize)
+	}
+	if c.DateColumn != "" || c.customQuery() != "" || c.StartFromDate != "" {
+		return ErrSplitColumnConflict
+	}
+	if c.S3.PathTemplate != "" {
+		if _, err := parseTemplate("path template", c.S3.PathTemplate, keyRangePathPlaceholders); err != nil {
+			return fmt.Errorf("%w: %w", ErrSplitColumnPathTemplate, err)
+		}
+	}
+	return nil
+}
+
+// planKeyRanges splits the keys from minKey to maxKey into ranges of size
+// keys. Ranges start at multiples of size, so every run plans the same ranges
+// and a growing table only adds new ones at the end.
+func planKeyRanges(table, column string, minKey, maxKey, size int64) []PartitionInfo {
+	first := minKey / size * size
+	if minKey < 0 && minKey%size != 0 {
+		first -= size
+	}
+
+	var partitions []PartitionInfo
+	for start := first; start <= maxKey; start += size {
+		keys := KeyRange{Column: column, Start: start, End: start + size}
+		keys.Open = maxKey < keys.End-1
+		partitions = append(partitions, PartitionInfo{TableName: table, RowCount: -1, Keys: keys})
+	}
+	return partitions
+}
+
+// buildKeyRangePartitions plans the key ranges of a table that is not
+// partitioned from the smallest and largest key of --split-column. An empty
+// table has none.
+func (a *Archiver) buildKeyRangePartitions(ctx context.Context) ([]PartitionInfo, error) {
+	if a.config.Table == "" {
+		return nil, ErrTableNameRequired
+	}
+
+	column := pq.QuoteIdentifier(a.config.SplitColumn)
+	//nolint:gosec // G201: identifiers are quoted via pq.QuoteIdentifier
+	query := fmt.Sprintf("SELECT MIN(%s)::bigint, MAX(%s)::bigint FROM %s", column, column, pq.QuoteIdentifier(a.config.Table))
+	var minKey, maxKey sql.NullInt64
+	if err := a.db.QueryRowContext(ctx, query).Scan(&minKey, &maxKey); err != nil {
+		return nil, fmt.Errorf("failed to find the key range of %s.%s: %w", a.config.Table, a.config.SplitColumn, err)
+	}
+	if !minKey.Valid || !maxKey.Valid {
+		return nil, nil
+	}
+	return planKeyRanges(a.config.Table, a.config.SplitColumn, minKey.Int64, maxKey.Int64, a.config.SplitSize), nil
+}
+
+// findKeyRanges is findPartitions for a table that is not partitioned and is
+// sliced by --split-column
+func (a *Archiver) findKeyRanges(ctx context.Context) ([]PartitionInfo, error) {
+	partitions, err := a.buildKeyRangePartitions(ctx)
+	if err != nil {
+		a.logger.Info("No partitions found to archive")
+		return nil, fmt.Errorf("partitionless fallback unavailable: %w", err)
+	}
+	if len(partitions) == 0 {
+		a.logger.Info(fmt.Sprintf("ℹ️  Table %s is not partitioned and has no rows to slice by %s", a.config.Table, a.config.SplitColumn))
+		return nil, nil
+	}
+
+	last := partitions[len(partitions)-1].Keys
+	a.logger.Info(fmt.Sprintf("ℹ️  Table %s is not partitioned; slicing %s %d → %d into %d range(s) of %d keys",
+		a.config.Table,
+		a.config.SplitColumn,
+		partitions[0].Keys.Start,
+		last.End-1,
+		len(partitions),
+		a.config.SplitSize))
+	return partitions, nil
+}
+
+// countKeyRangeRows counts the rows of table in a key range, for verify
+func countKeyRangeRows(ctx context.Context, db *sql.DB, table string, keys KeyRange) (int64, error) {
+	condition, args := keys.condition()
+	//nolint:gosec // G201: identifiers are quoted via pq.QuoteIdentifier
+	query := fmt.Sprintf("SELECT count(*) FROM %s WHERE %s", pq.QuoteIdentifier(table), condition)
+
+	var count int64
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count query on %s failed: %w", table, err)
+	}
+	return count, nil
+}
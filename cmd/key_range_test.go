@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPlanKeyRanges(t *testing.T) {
+	ranges := planKeyRanges("events", "id", 5, 2_500_000, 1_000_000)
+	if len(ranges) != 3 {
+		t.Fatalf("planKeyRanges() = %d ranges, want 3", len(ranges))
+	}
+	for i, partition := range ranges {
+		keys := partition.Keys
+		if keys.Start != int64(i)*1_000_000 || keys.End != keys.Start+1_000_000 || keys.Open != (i == 2) {
+			t.Errorf("range %d = %+v", i, keys)
+		}
+		if !partition.HasKeyRange() || partition.HasCustomRange() || partition.TableName != "events" {
+			t.Errorf("range %d partition = %+v", i, partition)
+		}
+	}
+
+	// A full last range is closed
+	if ranges := planKeyRanges("events", "id", 0, 1_999_999, 1_000_000); len(ranges) != 2 || ranges[1].Keys.Open {
+		t.Errorf("planKeyRanges() = %+v", ranges)
+	}
+	if ranges := planKeyRanges("events", "id", -5, 3, 10); len(ranges) != 2 || ranges[0].Keys.Start != -10 || ranges[1].Keys.Start != 0 {
+		t.Errorf("planKeyRanges() with negative keys = %+v", ranges)
+	}
+}
+
+func TestKeyRangeFilename(t *testing.T) {
+	keys := KeyRange{Column: "id", Start: 1_000_000, End: 2_000_000}
+	if got := keyRangeFilename("events", keys, ".jsonl", ".zst"); got != "events-id-000001000000-000001999999.jsonl.zst" {
+		t.Errorf("keyRangeFilename() = %q", got)
+	}
+	condition, args := keys.condition()
+	if condition != `"id" >= $1 AND "id" < $2` || len(args) != 2 || args[0] != int64(1_000_000) || args[1] != int64(2_000_000) {
+		t.Errorf("condition() = %q, %v", condition, args)
+	}
+}
+
+func TestValidateKeyRanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   error
+	}{
+		{"valid", func(c *Config) { c.SplitColumn, c.SplitSize = "id", 1000 }, nil},
+		{"size without column", func(c *Config) { c.SplitSize = 1000 }, ErrSplitSizeUnused},
+		{"invalid column", func(c *Config) { c.SplitColumn, c.SplitSize = "id;", 1000 }, ErrSplitColumnInvalid},
+		{"missing size", func(c *Config) { c.SplitColumn = "id" }, ErrSplitSizeInvalid},
+		{"date column", func(c *Config) { c.SplitColumn, c.SplitSize, c.DateColumn = "id", 1000, "created_at" }, ErrSplitColumnConflict},
+		{"dated path", func(c *Config) {
+			c.SplitColumn, c.SplitSize = "id", 1000
+			c.S3.PathTemplate = "archives/{table}/{YYYY}"
+		}, ErrSplitColumnPathTemplate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig()
+			config.S3.PathTemplate = "archives/{table}"
+			tt.modify(config)
+			if err := config.Validate(); !errors.Is(err, tt.want) && (tt.want != nil || err != nil) {
+				t.Errorf("Validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenKeyRangeIsRechecked(t *testing.T) {
+	store := &fakeObjectStore{objects: map[string][]byte{}}
+	objectKey := "archives/events/events-id-000000000000-000000000009.jsonl"
+	md5Hash := putArchiveObject(store, objectKey, "rows")
+	archiver := NewArchiver(&Config{Table: "events", S3: S3Config{Bucket: "bucket"}}, newTestLogger())
+	archiver.s3Client = newS3ObjectStore(store)
+	archiver.ctx = context.Background()
+
+	cache := &PartitionCache{Entries: map[string]PartitionCacheEntry{}}
+	cache.setFileMetadataWithETagAndStartTime(objectKey, objectKey, 4, 4, md5Hash, "", true, time.Time{})
+
+	partition := PartitionInfo{TableName: "events", Keys: KeyRange{Column: "id", Start: 0, End: 10}}
+	if skip, _ := archiver.checkCachedMetadata(partition, objectKey, cache, func(string) {}); !skip {
+		t.Error("a cached full key range should be skipped")
+	}
+	partition.Keys.Open = true
+	if skip, _ := archiver.checkCachedMetadata(partition, objectKey, cache, func(string) {}); skip {
+		t.Error("the open key range should be extracted again")
+	}
+}
@@ -0,0 +1,310 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/lib/pq"
+	"github.com/spf13/viper"
+
+	"github.com/airframesio/data-archiver/cmd/formatters"
+)
+
+// Static errors for large objects
+var ErrIncludeLargeObjectsConflict = errors.New("--include-large-objects uploads to S3, so it cannot be used with --output -")
+
+const (
+	// largeObjectChunkSize is how much of a large object is read or written per statement
+	largeObjectChunkSize = 1 << 20
+	// undefinedObject is the SQLSTATE of lo_get on an OID that is not a large object
+	undefinedObject = "42704"
+)
+
+var includeLargeObjects bool
+
+func init() {
+	archiveCmd.Flags().BoolVar(&includeLargeObjects, "include-large-objects", false, "upload the large objects that oid and lo columns reference as side files keyed by OID; restore --large-objects recreates them")
+	_ = viper.BindPFlag("include_large_objects", archiveCmd.Flags().Lookup("include-large-objects"))
+}
+
+// largeObjectKey returns where the large object oid of table is kept under template
+func largeObjectKey(template, table string, oid uint32) string {
+	return schemaArtifactKey(template, table, fmt.Sprintf("-largeobjects/%d", oid))
+}
+
+// isLargeObjectType reports whether a column of type udtName can reference a
+// large object. The lo extension's domain is reported as its base type, oid.
+func isLargeObjectType(udtName string) bool {
+	return udtName == "oid" || udtName == "lo"
+}
+
+// parseOID returns the OID a row value holds, as the driver or a decoded
+// archive file represents it
+func parseOID(value interface{}) (uint32, bool) {
+	var n int64
+	switch v := value.(type) {
+	case int64:
+		n = v
+	case int32:
+		n = int64(v)
+	case float64:
+		n = int64(v)
+	case string:
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		n = parsed
+	case []byte:
+		parsed, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		n = parsed
+	default:
+		return 0, false
+	}
+	if n <= 0 || n > int64(^uint32(0)) {
+		return 0, false
+	}
+	return uint32(n), true
+}
+
+// largeObjectRefs collects the OIDs an archive file's rows reference
+type largeObjectRefs struct {
+	columns []string
+	oids    map[uint32]struct{}
+}
+
+// newLargeObjectRefs returns a collector for the oid and lo columns, or nil
+// when --include-large-objects is off or there are none
+func (a *Archiver) newLargeObjectRefs(columns []formatters.ColumnSchema) *largeObjectRefs {
+	if !a.config.IncludeLargeObjects {
+		return nil
+	}
+	refs := &largeObjectRefs{oids: make(map[uint32]struct{})}
+	for _, col := range columns {
+		if isLargeObjectType(col.GetType()) {
+			refs.columns = append(refs.columns, col.GetName())
+		}
+	}
+	if len(refs.columns) == 0 {
+		return nil
+	}
+	return refs
+}
+
+// collect records the OIDs row references; a nil collector records nothing
+func (l *largeObjectRefs) collect(row map[string]interface{}) {
+	if l == nil {
+		return
+	}
+	for _, name := range l.columns {
+		if oid, ok := parseOID(row[name]); ok {
+			l.oids[oid] = struct{}{}
+		}
+	}
+}
+
+// sorted returns the collected OIDs in ascending order
+func (l *largeObjectRefs) sorted() []uint32 {
+	if l == nil {
+		return nil
+	}
+	oids := make([]uint32, 0, len(l.oids))
+	for oid := range l.oids {
+		oids = append(oids, oid)
+	}
+	sort.Slice(oids, func(i, j int) bool { return oids[i] < oids[j] })
+	return oids
+}
+
+// exportLargeObjects uploads the large objects refs collected from a file's
+// rows. An OID is exported once: objects already in S3 or exported earlier in
+// the run are left alone, and OIDs that are not large objects are skipped.
+func (a *Archiver) exportLargeObjects(ctx context.Context, table string, refs *largeObjectRefs) error {
+	oids := refs.sorted()
+	if len(oids) == 0 {
+		return nil
+	}
+	if a.config.DryRun {
+		a.logger.Debug(fmt.Sprintf("   📎 %s: would export up to %d large objects", table, len(oids)))
+		return nil
+	}
+
+	var exported, existing, missing int
+	var exportedBytes int64
+	for _, oid := range oids {
+		if _, done := a.largeObjects.Load(oid); done {
+			continue
+		}
+		key := largeObjectKey(a.config.schemaTemplate(), a.config.Table, oid)
+		if exists, _, _ := a.checkObjectExists(key); exists {
+			a.largeObjects.Store(oid, true)
+			existing++
+			continue
+		}
+
+		size, err := a.exportLargeObject(ctx, oid, key)
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == undefinedObject {
+				a.logger.Debug(fmt.Sprintf("   📎 %s: OID %d is not a large object, skipped", table, oid))
+				a.largeObjects.Store(oid, false)
+				missing++
+				continue
+			}
+			return fmt.Errorf("failed to export large object %d: %w", oid, err)
+		}
+		a.largeObjects.Store(oid, true)
+		exported++
+		exportedBytes += size
+	}
+
+	if exported+existing+missing > 0 {
+		a.logger.Debug(fmt.Sprintf("   📎 %s: exported %d large objects (%s), %d already archived, %d not large objects",
+			table, exported, formatBytes(exportedBytes), existing, missing))
+	}
+	return nil
+}
+
+// exportLargeObject reads a large object in chunks into a temp file and
+// uploads it to key, returning its size
+func (a *Archiver) exportLargeObject(ctx context.Context, oid uint32, key string) (int64, error) {
+	file, err := createTempFile()
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer cleanupTempFile(file.Name())
+	defer file.Close()
+
+	var size int64
+	for {
+		var chunk []byte
+		if err := a.db.QueryRowContext(ctx, "SELECT lo_get($1, $2, $3)", oid, size, largeObjectChunkSize).Scan(&chunk); err != nil {
+			return 0, err
+		}
+		if _, err := file.Write(chunk); err != nil {
+			return 0, fmt.Errorf("failed to write temp file: %w", err)
+		}
+		size += int64(len(chunk))
+		if len(chunk) < largeObjectChunkSize {
+			break
+		}
+	}
+	if err := file.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := a.uploadTempFileToS3(file.Name(), key); err != nil {
+		return 0, fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return size, nil
+}
+
+// restoreLargeObjects recreates, with their original OIDs, the archived large
+// objects that rows reference through the target table's oid and lo columns.
+// Objects already in the database are left alone, so rows restored again
+// keep pointing at them. It returns the number of objects created.
+func (r *Restorer) restoreLargeObjects(ctx context.Context, rows []map[string]interface{}) (int, error) {
+	if !r.largeObjects {
+		return 0, nil
+	}
+	target, err := r.loadTargetColumns(ctx, r.config.Table)
+	if err != nil {
+		return 0, err
+	}
+	var columns []string
+	for _, col := range target {
+		if isLargeObjectType(col.UDTName) {
+			columns = append(columns, col.Name)
+		}
+	}
+
+	created := 0
+	for _, row := range rows {
+		for _, name := range columns {
+			oid, ok := parseOID(row[name])
+			if !ok {
+				continue
+			}
+			if _, seen := r.shared.largeObjects.LoadOrStore(oid, true); seen {
+				continue
+			}
+			restored, err := r.restoreLargeObject(ctx, oid)
+			if err != nil {
+				r.shared.largeObjects.Delete(oid)
+				return created, fmt.Errorf("failed to restore large object %d: %w", oid, err)
+			}
+			if restored {
+				created++
+			}
+		}
+	}
+	return created, nil
+}
+
+// restoreLargeObject creates large object oid from its archived side file in
+// one transaction, so a failed download leaves no partial object. It returns
+// false when the object already exists or was not archived.
+func (r *Restorer) restoreLargeObject(ctx context.Context, oid uint32) (bool, error) {
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_largeobject_metadata WHERE oid = $1)", oid).Scan(&exists); err != nil {
+		return false, err
+	}
+	if exists {
+		r.logger.Debug(fmt.Sprintf("Large object %d already exists", oid))
+		return false, nil
+	}
+
+	key := largeObjectKey(r.largeObjectPath, r.config.Table, oid)
+	body, _, err := r.s3Client.Get(ctx, r.config.S3.Bucket, key, GetOptions{})
+	if err != nil {
+		if isObjectNotFound(err) {
+			r.logger.Debug(fmt.Sprintf("No archived large object at %s", key))
+			return false, nil
+		}
+		return false, err
+	}
+	defer body.Close()
+
+	if r.config.DryRun {
+		r.logger.Info(fmt.Sprintf("[DRY RUN] Would create large object %d from %s", oid, key))
+		return false, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, "SELECT lo_create($1)", oid); err != nil {
+		return false, err
+	}
+	buf := make([]byte, largeObjectChunkSize)
+	var offset int64
+	for {
+		n, readErr := io.ReadFull(body, buf)
+		if n > 0 {
+			if _, err := tx.ExecContext(ctx, "SELECT lo_put($1, $2, $3)", oid, offset, buf[:n]); err != nil {
+				return false, err
+			}
+			offset += int64(n)
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			break
+		}
+		if readErr != nil {
+			return false, fmt.Errorf("failed to download %s: %w", key, readErr)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	r.logger.Debug(fmt.Sprintf("Created large object %d (%s)", oid, formatBytes(offset)))
+	return true, nil
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestParseOID(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  uint32
+		ok    bool
+	}{
+		{int64(16401), 16401, true},
+		{float64(16401), 16401, true}, // JSONL numbers
+		{"16401", 16401, true},        // COPY text
+		{[]byte("16401"), 16401, true},
+		{int64(0), 0, false},
+		{int64(1 << 32), 0, false},
+		{nil, 0, false},
+		{"abc", 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := parseOID(tt.value); got != tt.want || ok != tt.ok {
+			t.Errorf("parseOID(%#v) = %d, %v", tt.value, got, ok)
+		}
+	}
+}
+
+func TestExportLargeObjects(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := &fakeObjectStore{objects: map[string][]byte{
+		"archives/documents/documents-largeobjects/16403": []byte("archived earlier"),
+	}}
+	archiver := NewArchiver(&Config{
+		Table:               "documents",
+		IncludeLargeObjects: true,
+		S3:                  S3Config{Bucket: "bucket", PathTemplate: "archives/{table}/{YYYY}/{MM}"},
+	}, newTestLogger())
+	archiver.db = db
+	archiver.s3Client = newS3ObjectStore(store)
+
+	schema := &TableSchema{Columns: []ColumnInfo{{Name: "id", UDTName: "int8"}, {Name: "scan", UDTName: "oid"}}}
+	refs := archiver.newLargeObjectRefs(schema.GetColumns())
+	for _, oid := range []interface{}{int64(16401), int64(16402), int64(16403), int64(16401), nil} {
+		refs.collect(map[string]interface{}{"id": int64(1), "scan": oid})
+	}
+
+	mock.ExpectQuery(`SELECT lo_get`).WithArgs(uint32(16401), int64(0), largeObjectChunkSize).
+		WillReturnRows(sqlmock.NewRows([]string{"lo_get"}).AddRow([]byte("%PDF-1.7")))
+	mock.ExpectQuery(`SELECT lo_get`).WithArgs(uint32(16402), int64(0), largeObjectChunkSize).
+		WillReturnError(&pq.Error{Code: undefinedObject, Message: "large object 16402 does not exist"})
+	if err := archiver.exportLargeObjects(context.Background(), "documents_2024", refs); err != nil {
+		t.Fatalf("exportLargeObjects() = %v", err)
+	}
+	if got := string(store.objects["archives/documents/documents-largeobjects/16401"]); got != "%PDF-1.7" {
+		t.Errorf("exported object = %q", got)
+	}
+	if _, ok := store.objects["archives/documents/documents-largeobjects/16402"]; ok {
+		t.Error("an OID that is not a large object should not be exported")
+	}
+
+	// OIDs handled earlier in the run are not read again
+	if err := archiver.exportLargeObjects(context.Background(), "documents_2024", refs); err != nil {
+		t.Fatalf("exportLargeObjects() again = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	// Tables without oid or lo columns collect nothing
+	if refs := archiver.newLargeObjectRefs((&TableSchema{Columns: []ColumnInfo{{Name: "id", UDTName: "int8"}}}).GetColumns()); refs != nil {
+		t.Errorf("newLargeObjectRefs() without oid columns = %+v", refs)
+	}
+}
+
+func TestRestoreLargeObjects(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	config := newTestConfig()
+	config.Table = "documents"
+	r := NewRestorer(config, newTestLogger())
+	r.db = db
+	r.s3Client = newS3ObjectStore(&fakeObjectStore{objects: map[string][]byte{
+		"documents/documents-largeobjects/16401": []byte("%PDF-1.7"),
+	}})
+	r.largeObjects = true
+	r.largeObjectPath = config.S3.PathTemplate
+
+	mock.ExpectQuery(`FROM information_schema.columns`).
+		WithArgs("documents").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "udt_name", "nullable", "has_default", "generated"}).
+			AddRow("id", "int8", false, false, false).
+			AddRow("scan", "oid", true, false, false))
+	mock.ExpectQuery(`FROM pg_largeobject_metadata`).WithArgs(uint32(16401)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT lo_create`).WithArgs(uint32(16401)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`SELECT lo_put`).WithArgs(uint32(16401), int64(0), []byte("%PDF-1.7")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`FROM pg_largeobject_metadata`).WithArgs(uint32(16402)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	rows := []map[string]interface{}{
+		{"id": float64(1), "scan": float64(16401)},
+		{"id": float64(2), "scan": float64(16401)},
+		{"id": float64(3), "scan": float64(16402)},
+		{"id": float64(4), "scan": nil},
+	}
+	created, err := r.restoreLargeObjects(context.Background(), rows)
+	if err != nil || created != 1 {
+		t.Errorf("restoreLargeObjects() = %d, %v", created, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	// Without --large-objects nothing is looked up
+	r.largeObjects = false
+	if created, err := r.restoreLargeObjects(context.Background(), rows); created != 0 || err != nil {
+		t.Errorf("restoreLargeObjects() when off = %d, %v", created, err)
+	}
+}
+
+func TestValidateIncludeLargeObjects(t *testing.T) {
+	config := newTestConfig()
+	config.IncludeLargeObjects = true
+	config.Output = StdoutOutput
+	if err := config.validateSchemaExport(); !errors.Is(err, ErrIncludeLargeObjectsConflict) {
+		t.Errorf("expected ErrIncludeLargeObjectsConflict, got %v", err)
+	}
+}
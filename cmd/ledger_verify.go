@@ -0,0 +1,414 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Integrity issues reported by ledger verify
+const (
+	IntegrityIssueModified      = "entry-modified"    // An entry's fields don't match its hash
+	IntegrityIssueChainBroken   = "chain-broken"      // An entry doesn't follow the one before it
+	IntegrityIssueMissingEntry  = "missing-entries"   // Sequence numbers are skipped, or a copy is cut short
+	IntegrityIssueBadSignature  = "bad-signature"     // The signature doesn't match the signing key
+	IntegrityIssueUnsigned      = "unsigned"          // A key was given but the entry isn't signed
+	IntegrityIssueCopiesDiffer  = "copies-differ"     // The local and S3 copies fork
+	IntegrityIssueObjectMissing = "object-missing"    // A recorded file is gone from the bucket
+	IntegrityIssueObjectChanged = "object-changed"    // A recorded file's size or checksum changed
+	IntegrityIssueUnrecorded    = "unrecorded-object" // A file archived since the ledger began has no entry
+)
+
+// Static errors for ledger verify
+var (
+	ErrIntegrityLedgerNotFound = errors.New("no integrity ledger found")
+	ErrLedgerVerifyFailed      = errors.New("integrity ledger verification found problems")
+	ErrLedgerOutputFormat      = errors.New("ledger output format must be one of: text, json")
+)
+
+var (
+	ledgerTable        string
+	ledgerSkipObjects  bool
+	ledgerOutputFormat string
+)
+
+var ledgerCmd = &cobra.Command{
+	Use:   "ledger",
+	Short: "Inspect the integrity ledger of archived files",
+}
+
+var ledgerVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check an integrity ledger and the files it records for tampering",
+	Long: `Check the integrity ledger that archive runs with --integrity-ledger keep for a table. The
+hash chain must be intact, with no entry changed, reordered, or removed; with --integrity-key-file
+every entry must carry a valid signature; and the local copy on this host and the S3 copy must
+agree. Each recorded file is then checked in the bucket for its recorded size and MD5, and with
+--path-template, files archived since the ledger began that it doesn't record are reported.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		runLedgerVerify(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(ledgerCmd)
+	ledgerCmd.AddCommand(ledgerVerifyCmd)
+
+	// S3 flags
+	ledgerVerifyCmd.Flags().StringVar(&s3Endpoint, "s3-endpoint", "", "S3-compatible endpoint URL")
+	ledgerVerifyCmd.Flags().StringVar(&s3Bucket, "s3-bucket", "", "S3 bucket name")
+	ledgerVerifyCmd.Flags().StringVar(&s3AccessKey, "s3-access-key", "", "S3 access key")
+	ledgerVerifyCmd.Flags().StringVar(&s3SecretKey, "s3-secret-key", "", "S3 secret key")
+	ledgerVerifyCmd.Flags().StringVar(&s3Region, "s3-region", "auto", "S3 region")
+
+	// Ledger-specific flags
+	ledgerVerifyCmd.Flags().StringVar(&ledgerTable, "table", "", "base table name (required)")
+	ledgerVerifyCmd.Flags().StringVar(&integrityPrefix, "integrity-prefix", defaultIntegrityPrefix, "bucket prefix for integrity ledgers")
+	ledgerVerifyCmd.Flags().StringVar(&integrityKeyFile, "integrity-key-file", "", "file holding the secret entries were signed with (empty = signatures not checked)")
+	ledgerVerifyCmd.Flags().StringVar(&pathTemplate, "path-template", "", "S3 path template used when archiving; also reports archived files missing from the ledger")
+	ledgerVerifyCmd.Flags().BoolVar(&ledgerSkipObjects, "skip-objects", false, "only check the ledger itself, not the files it records")
+	ledgerVerifyCmd.Flags().StringVar(&ledgerOutputFormat, "output-format", "text", "Output format: text, json")
+}
+
+// integrityIssue is one problem found by ledger verify
+type integrityIssue struct {
+	Kind    string `json:"kind"`
+	Seq     int64  `json:"seq,omitempty"`
+	Key     string `json:"key,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i integrityIssue) String() string {
+	if i.Seq > 0 {
+		return fmt.Sprintf("entry %d: %s", i.Seq, i.Message)
+	}
+	return i.Message
+}
+
+// checkIntegrityChain checks each entry's hash and link to the one before it,
+// and with key, its signature
+func checkIntegrityChain(entries []integrityEntry, key []byte) []integrityIssue {
+	var issues []integrityIssue
+	prevHash := ""
+	for i, entry := range entries {
+		if want := int64(i + 1); entry.Seq != want {
+			issues = append(issues, integrityIssue{Kind: IntegrityIssueMissingEntry, Seq: entry.Seq, Key: entry.Key,
+				Message: fmt.Sprintf("expected entry %d here, found %d", want, entry.Seq)})
+		}
+		if entry.Prev != prevHash {
+			issues = append(issues, integrityIssue{Kind: IntegrityIssueChainBroken, Seq: entry.Seq, Key: entry.Key,
+				Message: "does not follow the previous entry"})
+		}
+		if entry.digest() != entry.Hash {
+			issues = append(issues, integrityIssue{Kind: IntegrityIssueModified, Seq: entry.Seq, Key: entry.Key,
+				Message: fmt.Sprintf("fields for %s do not match the entry hash", entry.Key)})
+		}
+		if key != nil {
+			switch {
+			case entry.Signature == "":
+				issues = append(issues, integrityIssue{Kind: IntegrityIssueUnsigned, Seq: entry.Seq, Key: entry.Key, Message: "not signed"})
+			case signIntegrityHash(key, entry.Hash) != entry.Signature:
+				issues = append(issues, integrityIssue{Kind: IntegrityIssueBadSignature, Seq: entry.Seq, Key: entry.Key,
+					Message: "signature does not match the signing key"})
+			}
+		}
+		prevHash = entry.Hash
+	}
+	return issues
+}
+
+// compareIntegrityCopies reports where the local copy and the bucket copy
+// disagree. Either may be ahead of the other, e.g. after a run that couldn't
+// copy its entries to S3, but only while the shorter one is a prefix.
+func compareIntegrityCopies(local, remote []integrityEntry, localPath, remoteKey string) []integrityIssue {
+	switch {
+	case isIntegrityPrefix(local, remote), isIntegrityPrefix(remote, local):
+		if len(local) > len(remote) {
+			return []integrityIssue{{Kind: IntegrityIssueMissingEntry, Message: fmt.Sprintf(
+				"S3 copy %s has %d entries, %d fewer than %s (entries from an interrupted run are copied by the next one)",
+				remoteKey, len(remote), len(local)-len(remote), localPath)}}
+		}
+		return nil
+	}
+	for i := range local {
+		if i >= len(remote) || !local[i].sameAs(remote[i]) {
+			return []integrityIssue{{Kind: IntegrityIssueCopiesDiffer, Seq: int64(i + 1), Message: fmt.Sprintf(
+				"%s and S3 copy %s differ starting here", localPath, remoteKey)}}
+		}
+	}
+	return []integrityIssue{{Kind: IntegrityIssueCopiesDiffer, Message: fmt.Sprintf("%s and S3 copy %s differ", localPath, remoteKey)}}
+}
+
+// latestIntegrityEntries returns the last entry recorded for each key, in
+// ledger order
+func latestIntegrityEntries(entries []integrityEntry) []integrityEntry {
+	last := make(map[string]int, len(entries))
+	for i, entry := range entries {
+		last[entry.Key] = i
+	}
+	latest := make([]integrityEntry, 0, len(last))
+	for i, entry := range entries {
+		if last[entry.Key] == i {
+			latest = append(latest, entry)
+		}
+	}
+	return latest
+}
+
+// checkIntegrityObjects checks that each file the ledger last recorded as
+// archived is in the bucket with its recorded size and, for single-part
+// uploads, its recorded MD5
+func checkIntegrityObjects(ctx context.Context, client StorageBackend, bucket string, entries []integrityEntry) ([]integrityIssue, error) {
+	var issues []integrityIssue
+	for _, entry := range latestIntegrityEntries(entries) {
+		if entry.Event != IntegrityEventArchived {
+			continue
+		}
+		head, err := client.Head(ctx, bucket, entry.Key)
+		if err != nil {
+			if isObjectNotFound(err) {
+				issues = append(issues, integrityIssue{Kind: IntegrityIssueObjectMissing, Seq: entry.Seq, Key: entry.Key,
+					Message: fmt.Sprintf("%s is not in the bucket", entry.Key)})
+				continue
+			}
+			return issues, fmt.Errorf("failed to check %s: %w", entry.Key, err)
+		}
+		size := head.Size
+		etag := strings.Trim(head.ETag, `"`)
+		switch {
+		case size != entry.Size:
+			issues = append(issues, integrityIssue{Kind: IntegrityIssueObjectChanged, Seq: entry.Seq, Key: entry.Key,
+				Message: fmt.Sprintf("%s is %d bytes, recorded as %d", entry.Key, size, entry.Size)})
+		case !strings.Contains(etag, "-") && entry.MD5 != "" && etag != entry.MD5:
+			issues = append(issues, integrityIssue{Kind: IntegrityIssueObjectChanged, Seq: entry.Seq, Key: entry.Key,
+				Message: fmt.Sprintf("%s has MD5 %s, recorded as %s", entry.Key, etag, entry.MD5)})
+		}
+	}
+	return issues, nil
+}
+
+// findUnrecordedObjects lists the table's files under the path template's
+// fixed prefix and reports those uploaded since the ledger's first entry that
+// it has never recorded
+func findUnrecordedObjects(ctx context.Context, client StorageBackend, bucket, template, table string, entries []integrityEntry) ([]integrityIssue, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	since := entries[0].RecordedAt
+	recorded := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		recorded[entry.Key] = true
+	}
+
+	prefix := strings.ReplaceAll(template, "{table}", objectKeyComponent(table))
+	if i := strings.Index(prefix, "{"); i >= 0 {
+		prefix = prefix[:i]
+	}
+	filePrefix := objectKeyComponent(table) + "-"
+
+	var issues []integrityIssue
+	err := client.List(ctx, bucket, prefix, func(object ObjectInfo) bool {
+		key := object.Key
+		if recorded[key] || !strings.HasPrefix(path.Base(key), filePrefix) {
+			return true
+		}
+		if !object.LastModified.IsZero() && object.LastModified.Before(since) {
+			return true
+		}
+		issues = append(issues, integrityIssue{Kind: IntegrityIssueUnrecorded, Key: key,
+			Message: fmt.Sprintf("%s is not recorded in the ledger", key)})
+		return true
+	})
+	if err != nil {
+		return issues, fmt.Errorf("failed to list archived files: %w", err)
+	}
+	return issues, nil
+}
+
+// ledgerVerifyOptions selects what verifyIntegrityLedger checks
+type ledgerVerifyOptions struct {
+	Bucket       string
+	Table        string
+	Prefix       string
+	Key          []byte // Signing key (nil = signatures not checked)
+	LocalPath    string // Local copy to compare ("" = none)
+	PathTemplate string // Report unrecorded files under this template ("" = don't)
+	SkipObjects  bool
+}
+
+// ledgerVerifyReport is the outcome of ledger verify
+type ledgerVerifyReport struct {
+	Table   string           `json:"table"`
+	Entries int              `json:"entries"`
+	Signed  int              `json:"signed"`
+	Issues  []integrityIssue `json:"issues"`
+}
+
+// verifyIntegrityLedger checks a table's ledger copies and recorded files
+func verifyIntegrityLedger(ctx context.Context, client StorageBackend, opts ledgerVerifyOptions) (*ledgerVerifyReport, error) {
+	s3Key := integrityLedgerKey(opts.Prefix, opts.Table)
+	_, remote, found, err := readS3IntegrityLedger(ctx, client, opts.Bucket, s3Key)
+	if err != nil {
+		return nil, err
+	}
+	var local []integrityEntry
+	if opts.LocalPath != "" {
+		if _, local, err = readLocalIntegrityLedger(opts.LocalPath); err != nil {
+			return nil, err
+		}
+	}
+	if !found && len(local) == 0 {
+		return nil, fmt.Errorf("%w for table '%s' at s3://%s/%s", ErrIntegrityLedgerNotFound, opts.Table, opts.Bucket, s3Key)
+	}
+
+	report := &ledgerVerifyReport{Table: opts.Table, Issues: []integrityIssue{}}
+	entries := remote
+	if opts.LocalPath != "" && len(local) > 0 {
+		report.Issues = append(report.Issues, compareIntegrityCopies(local, remote, opts.LocalPath, s3Key)...)
+		if len(local) > len(remote) {
+			entries = local
+		}
+	}
+	report.Entries = len(entries)
+	for _, entry := range entries {
+		if entry.Signature != "" {
+			report.Signed++
+		}
+	}
+	report.Issues = append(report.Issues, checkIntegrityChain(entries, opts.Key)...)
+
+	if !opts.SkipObjects {
+		issues, err := checkIntegrityObjects(ctx, client, opts.Bucket, entries)
+		report.Issues = append(report.Issues, issues...)
+		if err != nil {
+			return report, err
+		}
+	}
+	if opts.PathTemplate != "" {
+		issues, err := findUnrecordedObjects(ctx, client, opts.Bucket, opts.PathTemplate, opts.Table, entries)
+		report.Issues = append(report.Issues, issues...)
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// writeLedgerVerifyText renders a report for the terminal
+func writeLedgerVerifyText(w io.Writer, report *ledgerVerifyReport) {
+	fmt.Fprintf(w, "%s: %d entries (%d signed)\n", report.Table, report.Entries, report.Signed)
+	if len(report.Issues) == 0 {
+		fmt.Fprintln(w, "  ✅ No problems found")
+		return
+	}
+	for _, issue := range report.Issues {
+		fmt.Fprintf(w, "  ❌ %-18s %s\n", issue.Kind, issue)
+	}
+}
+
+func runLedgerVerify(cmd *cobra.Command) {
+	getStringConfig := func(flagValue string, flagName string, viperKey string) string {
+		if flag := cmd.Flags().Lookup(flagName); flag != nil && flag.Changed {
+			return flagValue
+		}
+		if viperValue := viper.GetString(viperKey); viperValue != "" {
+			return viperValue
+		}
+		return flagValue
+	}
+
+	s3Config, s3Err := newS3Config(S3Config{
+		Endpoint:  getStringConfig(s3Endpoint, "s3-endpoint", "s3.endpoint"),
+		Bucket:    getStringConfig(s3Bucket, "s3-bucket", "s3.bucket"),
+		AccessKey: getStringConfig(s3AccessKey, "s3-access-key", "s3.access_key"),
+		SecretKey: getStringConfig(s3SecretKey, "s3-secret-key", "s3.secret_key"),
+		Region:    getStringConfig(s3Region, "s3-region", "s3.region"),
+	}, endpointProfileName("ledger"))
+	table := getStringConfig(ledgerTable, "table", "table")
+	prefix := getStringConfig(integrityPrefix, "integrity-prefix", "integrity.prefix")
+	keyFile := getStringConfig(integrityKeyFile, "integrity-key-file", "integrity.key_file")
+
+	initLogger(viper.GetBool("debug"), viper.GetString("log_format"))
+
+	if s3Err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", s3Err.Error()))
+		os.Exit(1)
+	}
+	if err := validateLedgerVerifyConfig(s3Config, table, prefix); err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+		os.Exit(1)
+	}
+	key, err := loadIntegrityKey(keyFile)
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+		os.Exit(1)
+	}
+	if key == nil {
+		logger.Warn("⚠️  No --integrity-key-file given; entry signatures are not checked")
+	}
+
+	ctx := signalContext
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	client, err := newStorageClient(s3Config)
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ Failed to create storage client: %v", err))
+		os.Exit(1)
+	}
+	report, err := verifyIntegrityLedger(ctx, client, ledgerVerifyOptions{
+		Bucket:       s3Config.Bucket,
+		Table:        table,
+		Prefix:       prefix,
+		Key:          key,
+		LocalPath:    getIntegrityLedgerPath(s3Config.Bucket, table),
+		PathTemplate: getStringConfig(pathTemplate, "path-template", "s3.path_template"),
+		SkipObjects:  ledgerSkipObjects,
+	})
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ %v", err))
+		os.Exit(1)
+	}
+
+	if ledgerOutputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(report)
+	} else {
+		writeLedgerVerifyText(os.Stdout, report)
+	}
+	if len(report.Issues) > 0 {
+		logger.Error(fmt.Sprintf("❌ %v: %d", ErrLedgerVerifyFailed, len(report.Issues)))
+		os.Exit(1)
+	}
+}
+
+// validateLedgerVerifyConfig checks the S3 settings and report options
+func validateLedgerVerifyConfig(s3Config S3Config, table, prefix string) error {
+	if s3Config.Bucket == "" {
+		return ErrS3BucketRequired
+	}
+	if err := s3Config.validateCredentials(); err != nil {
+		return err
+	}
+	if table == "" {
+		return ErrTableNameRequired
+	}
+	if strings.Trim(prefix, "/") == "" {
+		return ErrIntegrityPrefixRequired
+	}
+	if ledgerOutputFormat != "text" && ledgerOutputFormat != "json" {
+		return fmt.Errorf("%w: '%s'", ErrLedgerOutputFormat, ledgerOutputFormat)
+	}
+	return s3Config.HTTP.Validate()
+}
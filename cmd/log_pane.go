@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/viper"
+)
+
+const (
+	// defaultTUILogLines is how many events the log pane keeps
+	defaultTUILogLines = 5000
+
+	// recentMessages is how many messages the main view shows
+	recentMessages = 10
+
+	// logPaneKey opens and closes the log pane
+	logPaneKey = "l"
+)
+
+// ErrTUILogLinesInvalid is returned for a negative --tui-log-lines
+var ErrTUILogLinesInvalid = errors.New("tui log lines must be >= 0 (0 = default)")
+
+var tuiLogLines int
+
+func init() {
+	archiveCmd.Flags().IntVar(&tuiLogLines, "tui-log-lines", defaultTUILogLines, "events kept for the scrollable log pane of the terminal UI (press l to open it)")
+	_ = viper.BindPFlag("tui_log_lines", archiveCmd.Flags().Lookup("tui-log-lines"))
+}
+
+var logPaneMatchStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FDFF8C")).Bold(true)
+
+// logEntry is one event in the log pane
+type logEntry struct {
+	time time.Time
+	text string
+}
+
+// logPane keeps the run's events for the terminal UI's scrollable log, which
+// unlike the main view's recent messages still holds errors from hours ago.
+// The oldest events are dropped past capacity. The progress model is copied on
+// every update, so it holds the pane by pointer.
+type logPane struct {
+	entries  []logEntry
+	capacity int
+	open     bool
+	viewport viewport.Model
+
+	searching bool   // Typing a search
+	input     string // Search being typed
+	query     string // Search the events are filtered by (empty = all)
+	matches   int
+}
+
+func newLogPane(capacity int) *logPane {
+	if capacity <= 0 {
+		capacity = defaultTUILogLines
+	}
+	return &logPane{capacity: capacity, viewport: viewport.New(80, 20)}
+}
+
+// add records an event, dropping the oldest past capacity
+func (p *logPane) add(text string, now time.Time) {
+	if p == nil {
+		return
+	}
+	p.entries = append(p.entries, logEntry{time: now, text: text})
+	if len(p.entries) > p.capacity {
+		p.entries = p.entries[len(p.entries)-p.capacity:]
+	}
+	if p.open {
+		p.refresh()
+	}
+}
+
+// filtered returns the events matching the search, case-insensitively
+func (p *logPane) filtered() []logEntry {
+	if p.query == "" {
+		return p.entries
+	}
+	query := strings.ToLower(p.query)
+	var matched []logEntry
+	for _, entry := range p.entries {
+		if strings.Contains(strings.ToLower(entry.text), query) {
+			matched = append(matched, entry)
+		}
+	}
+	return matched
+}
+
+// refresh renders the events into the viewport, following the newest ones
+// unless the view was scrolled up
+func (p *logPane) refresh() {
+	follow := p.viewport.AtBottom() || p.viewport.TotalLineCount() == 0
+	entries := p.filtered()
+	p.matches = len(entries)
+
+	lines := make([]string, len(entries))
+	for i, entry := range entries {
+		text := entry.text
+		if p.query != "" {
+			text = highlightMatches(text, p.query)
+		}
+		lines[i] = fmt.Sprintf("%s  %s", entry.time.Format("15:04:05"), text)
+	}
+	p.viewport.SetContent(strings.Join(lines, "\n"))
+	if follow {
+		p.viewport.GotoBottom()
+	}
+}
+
+// highlightMatches marks every case-insensitive occurrence of query in text
+func highlightMatches(text, query string) string {
+	lower := strings.ToLower(text)
+	query = strings.ToLower(query)
+	if len(lower) != len(text) {
+		// Lowercasing changed the byte length, so offsets would not line up
+		return text
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(lower, query)
+		if i < 0 {
+			b.WriteString(text)
+			return b.String()
+		}
+		b.WriteString(text[:i])
+		b.WriteString(logPaneMatchStyle.Render(text[i : i+len(query)]))
+		text, lower = text[i+len(query):], lower[i+len(query):]
+	}
+}
+
+// resize fits the pane to the window, leaving room for its header and footer
+func (p *logPane) resize(width, height int) {
+	if p == nil {
+		return
+	}
+	p.viewport.Width = max(width-6, 20)
+	p.viewport.Height = max(height-6, 5)
+	if p.open {
+		p.refresh()
+	}
+}
+
+// toggle opens or closes the pane
+func (p *logPane) toggle() {
+	if p == nil {
+		return
+	}
+	p.open = !p.open
+	if p.open {
+		p.refresh()
+		p.viewport.GotoBottom()
+	}
+}
+
+// handleKey scrolls the open pane, or starts a search with /
+func (p *logPane) handleKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "/":
+		p.searching = true
+		p.input = p.query
+		return nil
+	case "esc":
+		if p.query != "" {
+			p.query = ""
+			p.refresh()
+			p.viewport.GotoBottom()
+			return nil
+		}
+		p.open = false
+		return nil
+	case "g", "home":
+		p.viewport.GotoTop()
+		return nil
+	case "G", "end":
+		p.viewport.GotoBottom()
+		return nil
+	}
+	var cmd tea.Cmd
+	p.viewport, cmd = p.viewport.Update(msg)
+	return cmd
+}
+
+// handleSearchKey edits the search being typed. Enter filters the events by
+// it, and Esc leaves the previous search in place.
+func (p *logPane) handleSearchKey(msg tea.KeyMsg) {
+	switch msg.Type { //nolint:exhaustive // Other keys are ignored while typing
+	case tea.KeyEnter:
+		p.searching = false
+		p.query = strings.TrimSpace(p.input)
+		p.refresh()
+		p.viewport.GotoBottom()
+	case tea.KeyEsc:
+		p.searching = false
+	case tea.KeyBackspace:
+		if runes := []rune(p.input); len(runes) > 0 {
+			p.input = string(runes[:len(runes)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		p.input += string(msg.Runes)
+	}
+}
+
+// view renders the pane in place of the main view
+func (p *logPane) view() []string {
+	header := fmt.Sprintf("   Log: %d event(s)", len(p.entries))
+	if len(p.entries) == p.capacity {
+		header += fmt.Sprintf(" (last %d kept)", p.capacity)
+	}
+	if p.query != "" {
+		header += fmt.Sprintf(", %d matching %q", p.matches, p.query)
+	}
+
+	sections := []string{"", stageStyle.Render(header), ""}
+	for _, line := range strings.Split(p.viewport.View(), "\n") {
+		sections = append(sections, "   "+line)
+	}
+	sections = append(sections, "")
+	if p.searching {
+		sections = append(sections, "   Search: "+p.input+"█")
+		sections = append(sections, helpStyle.Render("   Enter to filter, Esc to cancel"))
+		return sections
+	}
+	sections = append(sections, helpStyle.Render(fmt.Sprintf("   ↑/↓ scroll, pgup/pgdn page, g/G top/bottom, / search, Esc clear search or close, %s close, q quit", logPaneKey)))
+	return sections
+}
+
+// addMessage shows a message in the main view's recent messages and records
+// it in the log pane
+func (m *progressModel) addMessage(text string) {
+	m.messages = append(m.messages, text)
+	if len(m.messages) > recentMessages {
+		m.messages = m.messages[len(m.messages)-recentMessages:]
+	}
+	m.log.add(text, time.Now())
+}
+
+// logResult records a finished partition or failed slice in the log pane,
+// which is where errors can still be found after the main view moved on
+func (m *progressModel) logResult(name string, result ProcessResult) {
+	switch {
+	case result.Error != nil:
+		m.log.add(fmt.Sprintf("❌ %s failed: %v", name, result.Error), time.Now())
+	case result.Skipped:
+		m.log.add(fmt.Sprintf("⏭️  %s skipped: %s", name, result.SkipReason), time.Now())
+	default:
+		m.log.add(fmt.Sprintf("✅ %s archived (%s)", name, formatBytes(result.BytesWritten)), time.Now())
+	}
+}
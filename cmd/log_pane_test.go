@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func typeKeys(m progressModel, keys ...tea.KeyMsg) progressModel {
+	for _, key := range keys {
+		model, _ := m.handleKeyMsg(key)
+		m = model.(progressModel)
+	}
+	return m
+}
+
+func runeKey(text string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text)}
+}
+
+func TestLogPaneKeepsEventsPastRecentMessages(t *testing.T) {
+	m := progressModel{config: &Config{}, log: newLogPane(25)}
+	for i := 1; i <= 30; i++ {
+		m.addMessage(fmt.Sprintf("event %d", i))
+	}
+	if len(m.messages) != recentMessages || m.messages[0] != "event 21" {
+		t.Errorf("recent messages = %v", m.messages)
+	}
+	if len(m.log.entries) != 25 || m.log.entries[0].text != "event 6" {
+		t.Errorf("log pane holds %d events from %q", len(m.log.entries), m.log.entries[0].text)
+	}
+
+	m.logResult("flights_20240101", ProcessResult{Error: fmt.Errorf("connection reset")}) //nolint:err113 // test error
+	if last := m.log.entries[len(m.log.entries)-1].text; last != "❌ flights_20240101 failed: connection reset" {
+		t.Errorf("logged result = %q", last)
+	}
+
+	// A model without a pane still keeps its recent messages
+	bare := progressModel{}
+	bare.addMessage("hello")
+	if len(bare.messages) != 1 {
+		t.Errorf("messages = %v", bare.messages)
+	}
+}
+
+func TestLogPaneSearch(t *testing.T) {
+	m := progressModel{config: &Config{}, log: newLogPane(100)}
+	m.log.resize(120, 40)
+	m.addMessage("✅ Connected to PostgreSQL at db")
+	m.addMessage("❌ flights_20240102 failed: Upload timed out")
+	m.addMessage("✅ flights_20240103 archived")
+
+	m = typeKeys(m, runeKey(logPaneKey))
+	if !m.log.open || !strings.Contains(strings.Join(m.log.view(), "\n"), "3 event(s)") {
+		t.Fatalf("log pane not opened: %v", m.log.view())
+	}
+
+	// While typing a search, q is part of the search rather than quit
+	m = typeKeys(m, runeKey("/"), runeKey("q"), tea.KeyMsg{Type: tea.KeyBackspace}, runeKey("UPLOAD"), tea.KeyMsg{Type: tea.KeyEnter})
+	if m.done || m.log.searching || m.log.query != "UPLOAD" || m.log.matches != 1 {
+		t.Fatalf("search = %+v, done = %v", m.log, m.done)
+	}
+	if view := strings.Join(m.log.view(), "\n"); !strings.Contains(view, "flights_20240102") || strings.Contains(view, "PostgreSQL") {
+		t.Errorf("filtered view = %s", view)
+	}
+
+	// Esc clears the search, then closes the pane
+	m = typeKeys(m, tea.KeyMsg{Type: tea.KeyEsc})
+	if m.log.query != "" || m.log.matches != 3 || !m.log.open {
+		t.Errorf("after clearing the search = %+v", m.log)
+	}
+	m = typeKeys(m, tea.KeyMsg{Type: tea.KeyEsc})
+	if m.log.open {
+		t.Error("Esc without a search should close the pane")
+	}
+}
+
+func TestHighlightMatches(t *testing.T) {
+	got := highlightMatches("Upload failed: upload timed out", "upload")
+	if strings.Count(got, "pload") != 2 || !strings.Contains(got, "failed: ") {
+		t.Errorf("highlightMatches() = %q", got)
+	}
+	if got := highlightMatches("no match here", "upload"); got != "no match here" {
+		t.Errorf("highlightMatches() = %q", got)
+	}
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// Log targets (--log-target)
+const (
+	LogTargetStdout   = "stdout"
+	LogTargetFile     = "file"
+	LogTargetSyslog   = "syslog"
+	LogTargetJournald = "journald"
+)
+
+// Static errors for log targets
+var (
+	ErrLogTargetInvalid     = errors.New("log target must be stdout, file, syslog, or journald")
+	ErrLogFileRequired      = errors.New("--log-file is required with --log-target file")
+	ErrLogTargetUnsupported = errors.New("log target is not supported on this platform")
+)
+
+// syslogTag identifies data-archiver's messages in syslog and the journal
+const syslogTag = "data-archiver"
+
+// journalSocket is where journald accepts entries in its native protocol
+const journalSocket = "/run/systemd/journal/socket"
+
+// Syslog severities, as used by both syslog and journald
+const (
+	syslogPriorityErr     = 3
+	syslogPriorityWarning = 4
+	syslogPriorityInfo    = 6
+	syslogPriorityDebug   = 7
+)
+
+var (
+	logTarget string
+	logFile   string
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&logTarget, "log-target", LogTargetStdout, "where logs go: stdout, file (see --log-file), syslog, or journald; syslog and journald also receive progress events")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "file to append logs to with --log-target file")
+	_ = viper.BindPFlag("log_target", rootCmd.PersistentFlags().Lookup("log-target"))
+	_ = viper.BindPFlag("log_file", rootCmd.PersistentFlags().Lookup("log-file"))
+}
+
+// validateLogTarget checks the --log-target and --log-file settings
+func validateLogTarget(target, path string) error {
+	switch target {
+	case "", LogTargetStdout, LogTargetSyslog, LogTargetJournald:
+		return nil
+	case LogTargetFile:
+		if path == "" {
+			return ErrLogFileRequired
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w, got %q", ErrLogTargetInvalid, target)
+	}
+}
+
+// logSink receives formatted log lines and progress events with their syslog
+// severity. Implementations must be safe for concurrent use.
+type logSink interface {
+	writeEntry(priority int, line []byte) error
+	Close() error
+}
+
+// Log target state. initLogger runs more than once per process, so the file
+// or connection is opened once and reused while the settings are unchanged.
+var (
+	logTargetMu     sync.Mutex
+	logTargetOpened string   // target and path of the open file or sink
+	logTargetFile   *os.File // --log-target file
+	activeLogSink   logSink  // --log-target syslog or journald
+)
+
+// openLogTarget returns the writer or sink logs go to. Stdout logs use
+// logOutput, which is stderr when archive data is streamed to stdout.
+func openLogTarget(target, path string) (io.Writer, logSink, error) {
+	if err := validateLogTarget(target, path); err != nil {
+		return logOutput, nil, err
+	}
+	if target == "" || target == LogTargetStdout {
+		return logOutput, nil, nil
+	}
+
+	logTargetMu.Lock()
+	defer logTargetMu.Unlock()
+	opened := target + ":" + path
+	if opened != logTargetOpened {
+		closeLogTargetLocked()
+		var err error
+		switch target {
+		case LogTargetFile:
+			logTargetFile, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		case LogTargetSyslog:
+			activeLogSink, err = dialSyslog()
+		case LogTargetJournald:
+			activeLogSink, err = dialJournald(journalSocket)
+		}
+		if err != nil {
+			closeLogTargetLocked()
+			return logOutput, nil, fmt.Errorf("failed to open %s log target: %w", target, err)
+		}
+		logTargetOpened = opened
+	}
+	if logTargetFile != nil {
+		return logTargetFile, nil, nil
+	}
+	return nil, activeLogSink, nil
+}
+
+// closeLogTargetLocked closes the open log file or sink; logTargetMu must be held
+func closeLogTargetLocked() {
+	if logTargetFile != nil {
+		_ = logTargetFile.Close()
+		logTargetFile = nil
+	}
+	if activeLogSink != nil {
+		_ = activeLogSink.Close()
+		activeLogSink = nil
+	}
+	logTargetOpened = ""
+}
+
+// currentLogSink returns the syslog or journald sink logs go to, if any
+func currentLogSink() logSink {
+	logTargetMu.Lock()
+	defer logTargetMu.Unlock()
+	return activeLogSink
+}
+
+// logPriority maps a log level to its syslog severity
+func logPriority(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return syslogPriorityErr
+	case level >= slog.LevelWarn:
+		return syslogPriorityWarning
+	case level >= slog.LevelInfo:
+		return syslogPriorityInfo
+	default:
+		return syslogPriorityDebug
+	}
+}
+
+// sinkOutput is the buffer a sinkHandler formats each record into before
+// handing it to the sink. Handlers derived with WithAttrs share it.
+type sinkOutput struct {
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	sink logSink
+}
+
+// sinkHandler writes each record to a log sink as one JSON (or logfmt) line
+// with the record's syslog severity. Syslog and the journal stamp entries
+// themselves, but the line keeps its own time so it can be parsed on its own.
+type sinkHandler struct {
+	handler slog.Handler
+	out     *sinkOutput
+}
+
+func newSinkHandler(sink logSink, format string, opts *slog.HandlerOptions) *sinkHandler {
+	out := &sinkOutput{sink: sink}
+	var handler slog.Handler = slog.NewJSONHandler(&out.buf, opts)
+	if format == "logfmt" {
+		handler = slog.NewTextHandler(&out.buf, opts)
+	}
+	return &sinkHandler{handler: handler, out: out}
+}
+
+func (h *sinkHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *sinkHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+	h.out.buf.Reset()
+	if err := h.handler.Handle(ctx, r); err != nil {
+		return err
+	}
+	return h.out.sink.writeEntry(logPriority(r.Level), bytes.TrimSuffix(h.out.buf.Bytes(), []byte("\n")))
+}
+
+func (h *sinkHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sinkHandler{handler: h.handler.WithAttrs(attrs), out: h.out}
+}
+
+func (h *sinkHandler) WithGroup(name string) slog.Handler {
+	return &sinkHandler{handler: h.handler.WithGroup(name), out: h.out}
+}
+
+// journalEntry encodes a journald native protocol datagram. MESSAGE uses the
+// length-prefixed form when the line contains a newline.
+func journalEntry(priority int, line []byte) []byte {
+	var entry bytes.Buffer
+	entry.WriteString("PRIORITY=" + strconv.Itoa(priority) + "\n")
+	entry.WriteString("SYSLOG_IDENTIFIER=" + syslogTag + "\n")
+	entry.WriteString("DATA_ARCHIVER_RUN_ID=" + currentRunID + "\n")
+	if bytes.IndexByte(line, '\n') < 0 {
+		entry.WriteString("MESSAGE=")
+		entry.Write(line)
+		entry.WriteByte('\n')
+		return entry.Bytes()
+	}
+	entry.WriteString("MESSAGE\n")
+	_ = binary.Write(&entry, binary.LittleEndian, uint64(len(line)))
+	entry.Write(line)
+	entry.WriteByte('\n')
+	return entry.Bytes()
+}
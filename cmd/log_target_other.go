@@ -0,0 +1,15 @@
+//go:build !unix
+
+package cmd
+
+import "fmt"
+
+// dialSyslog is only implemented on Unix
+func dialSyslog() (logSink, error) {
+	return nil, fmt.Errorf("%w: %s", ErrLogTargetUnsupported, LogTargetSyslog)
+}
+
+// dialJournald is only implemented on Unix
+func dialJournald(string) (logSink, error) {
+	return nil, fmt.Errorf("%w: %s", ErrLogTargetUnsupported, LogTargetJournald)
+}
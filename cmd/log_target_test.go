@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink keeps the entries written to it
+type recordingSink struct {
+	mu         sync.Mutex
+	priorities []int
+	lines      []string
+}
+
+func (r *recordingSink) writeEntry(priority int, line []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.priorities = append(r.priorities, priority)
+	r.lines = append(r.lines, string(line))
+	return nil
+}
+
+func (r *recordingSink) Close() error { return nil }
+
+func TestValidateLogTarget(t *testing.T) {
+	for _, target := range []string{"", LogTargetStdout, LogTargetSyslog, LogTargetJournald} {
+		if err := validateLogTarget(target, ""); err != nil {
+			t.Errorf("validateLogTarget(%q) = %v", target, err)
+		}
+	}
+	if err := validateLogTarget(LogTargetFile, ""); !errors.Is(err, ErrLogFileRequired) {
+		t.Errorf("file without path = %v", err)
+	}
+	if err := validateLogTarget(LogTargetFile, "archiver.log"); err != nil {
+		t.Errorf("file with path = %v", err)
+	}
+	if err := validateLogTarget("kafka", ""); !errors.Is(err, ErrLogTargetInvalid) {
+		t.Errorf("unknown target = %v", err)
+	}
+}
+
+func TestSinkHandler(t *testing.T) {
+	sink := &recordingSink{}
+	log := slog.New(newSinkHandler(sink, "text", &slog.HandlerOptions{Level: slog.LevelDebug})).With("run_id", "01TEST")
+	log.Debug("counting")
+	log.Info("archived", "rows", 10)
+	log.Warn("slow")
+	log.Error("failed")
+
+	if want := []int{syslogPriorityDebug, syslogPriorityInfo, syslogPriorityWarning, syslogPriorityErr}; len(sink.priorities) != 4 ||
+		sink.priorities[0] != want[0] || sink.priorities[1] != want[1] || sink.priorities[2] != want[2] || sink.priorities[3] != want[3] {
+		t.Fatalf("priorities = %v, want %v", sink.priorities, want)
+	}
+	// Text format becomes JSON, one line per entry
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(sink.lines[1]), &entry); err != nil || strings.Contains(sink.lines[1], "\n") {
+		t.Fatalf("entry %q is not a JSON line: %v", sink.lines[1], err)
+	}
+	if entry["msg"] != "archived" || entry["rows"] != float64(10) || entry["run_id"] != "01TEST" {
+		t.Errorf("entry = %v", entry)
+	}
+
+	sink = &recordingSink{}
+	slog.New(newSinkHandler(sink, "logfmt", nil)).Info("archived", "rows", 10)
+	if len(sink.lines) != 1 || !strings.Contains(sink.lines[0], "msg=archived rows=10") {
+		t.Errorf("logfmt lines = %q", sink.lines)
+	}
+}
+
+func TestJournalEntry(t *testing.T) {
+	entry := string(journalEntry(syslogPriorityWarning, []byte(`{"msg":"slow"}`)))
+	for _, field := range []string{"PRIORITY=4\n", "SYSLOG_IDENTIFIER=data-archiver\n", "DATA_ARCHIVER_RUN_ID=" + currentRunID + "\n", "MESSAGE={\"msg\":\"slow\"}\n"} {
+		if !strings.Contains(entry, field) {
+			t.Errorf("entry %q lacks %q", entry, field)
+		}
+	}
+
+	// A multi-line message is length-prefixed
+	multi := journalEntry(syslogPriorityInfo, []byte("a\nb"))
+	i := bytes.Index(multi, []byte("MESSAGE\n"))
+	if i < 0 {
+		t.Fatalf("entry %q has no binary MESSAGE", multi)
+	}
+	body := multi[i+len("MESSAGE\n"):]
+	if size := binary.LittleEndian.Uint64(body[:8]); size != 3 || string(body[8:]) != "a\nb\n" {
+		t.Errorf("binary MESSAGE = %d %q", size, body[8:])
+	}
+}
+
+func TestJournaldSink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("journald is only available on Unix")
+	}
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	listener, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets unavailable: %v", err)
+	}
+	defer listener.Close()
+
+	sink, err := dialJournald(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if err := sink.writeEntry(syslogPriorityErr, []byte(`{"msg":"failed"}`)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4096)
+	_ = listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := listener.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); !strings.HasPrefix(got, "PRIORITY=3\n") || !strings.HasSuffix(got, "MESSAGE={\"msg\":\"failed\"}\n") {
+		t.Errorf("datagram = %q", got)
+	}
+}
+
+func TestOpenLogTargetFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archiver.log")
+	defer func() {
+		logTargetMu.Lock()
+		closeLogTargetLocked()
+		logTargetMu.Unlock()
+	}()
+
+	output, sink, err := openLogTarget(LogTargetFile, path)
+	if err != nil || sink != nil {
+		t.Fatalf("openLogTarget() = %v, %v", sink, err)
+	}
+	again, _, _ := openLogTarget(LogTargetFile, path)
+	if again != output {
+		t.Error("the log file should be opened once")
+	}
+	if _, err := output.Write([]byte("line\n")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "line\n" {
+		t.Errorf("log file = %q", data)
+	}
+
+	if output, _, err := openLogTarget("kafka", ""); !errors.Is(err, ErrLogTargetInvalid) || output != logOutput {
+		t.Errorf("invalid target should fall back to the terminal, got %v", err)
+	}
+}
+
+func TestProgressEventsGoToLogSink(t *testing.T) {
+	sink := &recordingSink{}
+	logTargetMu.Lock()
+	activeLogSink = sink
+	logTargetMu.Unlock()
+	defer func() {
+		logTargetMu.Lock()
+		activeLogSink = nil
+		logTargetMu.Unlock()
+	}()
+
+	events, err := openProgressEventLog("")
+	if err != nil || events == nil {
+		t.Fatalf("openProgressEventLog() = %v, %v", events, err)
+	}
+	events.emit(progressEvent{Type: progressEventPhase, Phase: "processing"})
+	events.emit(progressEvent{Type: progressEventRunEnd, Status: runStatusFailed, Error: "boom"})
+	if err := events.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sink.lines) != 2 || sink.priorities[0] != syslogPriorityInfo || sink.priorities[1] != syslogPriorityErr {
+		t.Fatalf("sink = %v %q", sink.priorities, sink.lines)
+	}
+	var event progressEvent
+	if err := json.Unmarshal([]byte(sink.lines[0]), &event); err != nil || event.Phase != "processing" {
+		t.Errorf("event = %+v, %v", event, err)
+	}
+}
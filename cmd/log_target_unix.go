@@ -0,0 +1,62 @@
+//go:build unix
+
+package cmd
+
+import (
+	"log/syslog"
+	"net"
+)
+
+// syslogSink sends entries to the local syslog daemon
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+func dialSyslog() (logSink, error) {
+	writer, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, syslogTag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{writer: writer}, nil
+}
+
+func (s *syslogSink) writeEntry(priority int, line []byte) error {
+	message := string(line)
+	switch priority {
+	case syslogPriorityErr:
+		return s.writer.Err(message)
+	case syslogPriorityWarning:
+		return s.writer.Warning(message)
+	case syslogPriorityDebug:
+		return s.writer.Debug(message)
+	default:
+		return s.writer.Info(message)
+	}
+}
+
+func (s *syslogSink) Close() error {
+	return s.writer.Close()
+}
+
+// journaldSink sends entries to journald over its native protocol, which
+// keeps the severity and adds the run ID as a journal field
+type journaldSink struct {
+	conn *net.UnixConn
+}
+
+func dialJournald(socket string) (logSink, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &journaldSink{conn: conn}, nil
+}
+
+func (j *journaldSink) writeEntry(priority int, line []byte) error {
+	_, err := j.conn.Write(journalEntry(priority, line))
+	return err
+}
+
+func (j *journaldSink) Close() error {
+	return j.conn.Close()
+}
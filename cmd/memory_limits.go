@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	runtimedebug "runtime/debug"
+
+	"github.com/spf13/viper"
+)
+
+// Static errors for memory limit configuration
+var (
+	ErrGOGCInvalid        = errors.New("gogc must be -1 (off), 0 (unchanged) or a positive percentage")
+	ErrMemoryLimitInvalid = errors.New("memory limit must be a size such as 512MB or 2GB")
+	ErrGOGCOffNeedsLimit  = errors.New("--gogc -1 turns garbage collection off and needs --memory-limit")
+)
+
+// MemoryLimits tunes the garbage collector for hosts with little memory to spare
+type MemoryLimits struct {
+	GOGC        int   // GC target percentage (0 = unchanged, -1 = collect only to stay under MemoryLimit)
+	MemoryLimit int64 // Soft limit on the memory the Go runtime uses, in bytes (0 = unchanged)
+}
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.Int("gogc", 0, "garbage collection target percentage (sets GOGC; -1 = only collect near --memory-limit, 0 = unchanged)")
+	flags.String("memory-limit", "", "soft memory limit for the Go runtime, e.g. 512MB or 2GB (sets GOMEMLIMIT; empty = unchanged)")
+
+	_ = viper.BindPFlag("memory.gogc", flags.Lookup("gogc"))
+	_ = viper.BindPFlag("memory.limit", flags.Lookup("memory-limit"))
+}
+
+// loadMemoryLimits reads the memory limits shared by every command
+func loadMemoryLimits() (MemoryLimits, error) {
+	limits := MemoryLimits{GOGC: viper.GetInt("memory.gogc")}
+	if value := viper.GetString("memory.limit"); value != "" {
+		limit, err := parseByteRate(value)
+		if err != nil {
+			return MemoryLimits{}, fmt.Errorf("%w, got '%s'", ErrMemoryLimitInvalid, value)
+		}
+		limits.MemoryLimit = limit
+	}
+	return limits, limits.Validate()
+}
+
+// Validate checks the limits are values the runtime accepts
+func (l MemoryLimits) Validate() error {
+	if l.GOGC < -1 {
+		return fmt.Errorf("%w, got %d", ErrGOGCInvalid, l.GOGC)
+	}
+	if l.MemoryLimit < 0 {
+		return fmt.Errorf("%w, got %d", ErrMemoryLimitInvalid, l.MemoryLimit)
+	}
+	if l.GOGC == -1 && l.MemoryLimit == 0 {
+		return ErrGOGCOffNeedsLimit
+	}
+	return nil
+}
+
+// applyMemoryLimits sets the GC percentage and soft memory limit before a
+// command runs. The limit makes a memory ballast unnecessary: with a high (or
+// disabled) GOGC the heap grows freely until it nears the limit, and only then
+// does the collector work harder.
+func applyMemoryLimits(limits MemoryLimits) error {
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+	if limits.GOGC != 0 {
+		runtimedebug.SetGCPercent(limits.GOGC)
+	}
+	if limits.MemoryLimit > 0 {
+		runtimedebug.SetMemoryLimit(limits.MemoryLimit)
+	}
+	return nil
+}
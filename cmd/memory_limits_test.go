@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestMemoryLimitsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  MemoryLimits
+		wantErr error
+	}{
+		{"Defaults", MemoryLimits{}, nil},
+		{"AllSet", MemoryLimits{GOGC: 400, MemoryLimit: 512 << 20}, nil},
+		{"GCOffWithLimit", MemoryLimits{GOGC: -1, MemoryLimit: 1 << 30}, nil},
+		{"GCOffWithoutLimit", MemoryLimits{GOGC: -1}, ErrGOGCOffNeedsLimit},
+		{"GOGCTooLow", MemoryLimits{GOGC: -2}, ErrGOGCInvalid},
+		{"NegativeLimit", MemoryLimits{MemoryLimit: -1}, ErrMemoryLimitInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.limits.Validate(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadMemoryLimits(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Set("memory.gogc", 0)
+		viper.Set("memory.limit", "")
+	})
+
+	viper.Set("memory.gogc", 200)
+	viper.Set("memory.limit", "1.5GB")
+	limits, err := loadMemoryLimits()
+	if err != nil || limits.GOGC != 200 || limits.MemoryLimit != 3<<29 {
+		t.Errorf("loadMemoryLimits() = %+v, %v", limits, err)
+	}
+
+	viper.Set("memory.limit", "lots")
+	if _, err := loadMemoryLimits(); !errors.Is(err, ErrMemoryLimitInvalid) {
+		t.Errorf("expected ErrMemoryLimitInvalid, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTablesFailed is returned when one or more tables of a multi-table run fail
+var ErrTablesFailed = errors.New("one or more tables failed to archive")
+
+// buildTableConfigs derives and validates one archive config per table of a
+// multi-table run, each with its own cache scope, quota and time zones
+func buildTableConfigs(base *Config, quotas map[string]TableQuota, defaults TableQuota) ([]*Config, error) {
+	if base.Table != "" {
+		return nil, ErrTablesConflict
+	}
+	if base.Output == StdoutOutput {
+		return nil, ErrStdoutTables
+	}
+	if base.TableConcurrency < 1 {
+		return nil, fmt.Errorf("%w, got %d", ErrTableConcurrencyInvalid, base.TableConcurrency)
+	}
+
+	// --start-from names a partition of one table; the others start from the beginning
+	owner := ""
+	if base.StartFrom != "" {
+		if owner = startFromOwner(base.Tables, base.StartFrom); owner == "" {
+			return nil, fmt.Errorf("%w: %s", ErrStartFromNotFound, base.StartFrom)
+		}
+	}
+
+	configs := make([]*Config, 0, len(base.Tables))
+	for _, table := range orderTablesByNice(base.Tables, quotas, defaults) {
+		cfg := *base
+		cfg.Table = table
+		cfg.Tables = nil
+		if table != owner {
+			cfg.StartFrom = ""
+		}
+		cfg.Quota = quotaForTable(quotas, defaults, table)
+		cfg.Timezone = timezoneForTable(base.TableTimezones, base.Timezone, table)
+		cfg.CacheScope = NewCacheScope("archive", &cfg)
+		if err := cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("table %s: %w", table, err)
+		}
+		configs = append(configs, &cfg)
+	}
+	return configs, nil
+}
+
+// runTables archives several tables in one run. Up to concurrency tables are
+// archived at once, started in priority (nice) order; each table is bounded by
+// its own quota so a large table cannot take every slot and all of the bandwidth.
+// Multi-table runs use plain log output instead of the TUI; events from every
+// table share the one progress file.
+func runTables(ctx context.Context, configs []*Config, concurrency int, events *progressEventLog, logger *slog.Logger) error {
+	if err := WritePIDFile(); err != nil {
+		return fmt.Errorf("failed to write PID file: %w", err)
+	}
+	defer func() {
+		_ = RemovePIDFile()
+	}()
+
+	tables := make([]string, len(configs))
+	for i, cfg := range configs {
+		tables[i] = cfg.Table
+	}
+	_ = WriteTaskInfo(&TaskInfo{
+		PID:         os.Getpid(),
+		StartTime:   time.Now(),
+		Table:       strings.Join(tables, ","),
+		StartDate:   configs[0].StartDate,
+		EndDate:     configs[0].EndDate,
+		CurrentTask: "Archiving tables",
+	})
+	defer func() {
+		_ = RemoveTaskFile()
+	}()
+
+	logger.Info(fmt.Sprintf("Archiving %d tables, %d at a time: %s", len(configs), concurrency, strings.Join(tables, ", ")))
+
+	var mu sync.Mutex
+	var failed []string
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for _, cfg := range configs {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		case slots <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(cfg *Config) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			tableLogger := logger.With("table", cfg.Table)
+			tableLogger.Info(fmt.Sprintf("▶️  Starting table %s", cfg.Table))
+
+			archiver := NewArchiver(cfg, tableLogger)
+			archiver.ctx = ctx
+			archiver.events = events
+			err := archiver.archiveTable(ctx, func(ctx context.Context) error {
+				return archiver.runArchivalProcess(ctx, nil, nil)
+			})
+			if err == nil {
+				tableLogger.Info(fmt.Sprintf("✅ Table %s completed", cfg.Table))
+				return
+			}
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+
+			tableLogger.Error(fmt.Sprintf("❌ Table %s failed: %v", cfg.Table, err))
+			mu.Lock()
+			failed = append(failed, cfg.Table)
+			mu.Unlock()
+		}(cfg)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %s", ErrTablesFailed, strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"crypto/md5" //nolint:gosec // MD5 used for checksums, not cryptography
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sync"
+)
+
+// multipartHasher computes a file's MD5 and its S3 multipart ETag as the file
+// is written, so neither needs the file read back or held in memory. Parts are
+// hashed at a fixed size; partSizeFor only grows it for files too large for
+// 10,000 parts of that size.
+type multipartHasher struct {
+	whole    hash.Hash
+	part     hash.Hash
+	partSize int64
+	partLen  int64 // Bytes in the part being hashed
+	size     int64
+	sums     []byte // MD5s of the completed parts
+}
+
+func newMultipartHasher(partSize int64) *multipartHasher {
+	return &multipartHasher{
+		whole:    md5.New(), //nolint:gosec // MD5 used for checksums, not cryptography
+		part:     md5.New(), //nolint:gosec // MD5 used for checksums, not cryptography
+		partSize: partSize,
+	}
+}
+
+func (h *multipartHasher) Write(p []byte) (int, error) {
+	h.whole.Write(p)
+	h.size += int64(len(p))
+	written := len(p)
+	for len(p) > 0 {
+		n := int(min(int64(len(p)), h.partSize-h.partLen))
+		h.part.Write(p[:n])
+		h.partLen += int64(n)
+		p = p[n:]
+		if h.partLen == h.partSize {
+			h.sums = h.part.Sum(h.sums)
+			h.part.Reset()
+			h.partLen = 0
+		}
+	}
+	return written, nil
+}
+
+// md5 returns the hex MD5 of everything written
+func (h *multipartHasher) md5() string {
+	return hex.EncodeToString(h.whole.Sum(nil))
+}
+
+// multipartETag returns the ETag S3 gives the file when it's uploaded in
+// parts: the MD5 of the part MD5s, then the part count. A single part's ETag
+// is its plain MD5. ok is false when the upload would use a larger part size
+// than the one hashed.
+func (h *multipartHasher) multipartETag() (etag string, ok bool) {
+	if partSizeFor(h.size, h.partSize) != h.partSize {
+		return "", false
+	}
+	sums := h.sums
+	if h.partLen > 0 {
+		sums = h.part.Sum(sums)
+	}
+	parts := len(sums) / md5.Size
+	if parts <= 1 {
+		return h.md5(), true
+	}
+	final := md5.Sum(sums) //nolint:gosec // MD5 used for checksums, not cryptography
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(final[:]), parts), true
+}
+
+// tempFileETags keeps the multipart ETags computed while temp files were
+// written, until the file is uploaded
+type tempFileETags struct {
+	mu    sync.Mutex
+	etags map[string]string
+}
+
+func newTempFileETags() *tempFileETags {
+	return &tempFileETags{etags: make(map[string]string)}
+}
+
+func (t *tempFileETags) set(path, etag string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.etags[path] = etag
+}
+
+func (t *tempFileETags) get(path string) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	etag, ok := t.etags[path]
+	return etag, ok
+}
+
+func (t *tempFileETags) forget(path string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.etags, path)
+}
+
+// tempFileMultipartETag returns the multipart ETag of a temp file, computed
+// while it was written when possible, otherwise by reading it back
+func (a *Archiver) tempFileMultipartETag(filePath string) (string, error) {
+	if etag, ok := a.etags.get(filePath); ok {
+		return etag, nil
+	}
+	return a.calculateMultipartETagFromFile(filePath)
+}
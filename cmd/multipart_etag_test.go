@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/md5" //nolint:gosec // MD5 used for checksums, not cryptography
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// expectedMultipartETag computes an ETag the way S3 does, from the whole file
+func expectedMultipartETag(data []byte, partSize int) string {
+	var sums []byte
+	for start := 0; start < len(data); start += partSize {
+		sum := md5.Sum(data[start:min(start+partSize, len(data))]) //nolint:gosec // MD5 used for checksums, not cryptography
+		sums = append(sums, sum[:]...)
+	}
+	final := md5.Sum(sums) //nolint:gosec // MD5 used for checksums, not cryptography
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(final[:]), len(sums)/md5.Size)
+}
+
+func TestMultipartHasher(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), (12*1024*1024)/16+7)
+	whole := md5.Sum(data) //nolint:gosec // MD5 used for checksums, not cryptography
+
+	// Writes of uneven sizes straddle part boundaries
+	hasher := newMultipartHasher(minUploadPartSize)
+	for rest := data; len(rest) > 0; {
+		n := min(len(rest), 1_000_003)
+		hasher.Write(rest[:n])
+		rest = rest[n:]
+	}
+	if hasher.md5() != hex.EncodeToString(whole[:]) {
+		t.Errorf("md5() = %s", hasher.md5())
+	}
+	etag, ok := hasher.multipartETag()
+	if want := expectedMultipartETag(data, minUploadPartSize); !ok || etag != want {
+		t.Errorf("multipartETag() = %s, %v, want %s", etag, ok, want)
+	}
+
+	// Reading the file back gives the same ETag
+	path := filepath.Join(t.TempDir(), "archive.jsonl.zst")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	archiver := NewArchiver(&Config{}, newTestLogger())
+	if fromFile, err := archiver.calculateMultipartETagFromFile(path); err != nil || fromFile != etag {
+		t.Errorf("calculateMultipartETagFromFile() = %s, %v", fromFile, err)
+	}
+
+	// A file of one part (or an exact multiple) has no partial part left over
+	single := newMultipartHasher(minUploadPartSize)
+	single.Write([]byte("small"))
+	if etag, ok := single.multipartETag(); !ok || etag != single.md5() {
+		t.Errorf("single part multipartETag() = %s, %v", etag, ok)
+	}
+	exact := newMultipartHasher(minUploadPartSize)
+	exact.Write(data[:2*minUploadPartSize])
+	if etag, _ := exact.multipartETag(); etag != expectedMultipartETag(data[:2*minUploadPartSize], minUploadPartSize) {
+		t.Errorf("exact multiple multipartETag() = %s", etag)
+	}
+
+	// Any --s3-part-size is hashed as configured
+	custom := newMultipartHasher(6 * 1024 * 1024)
+	custom.Write(data)
+	if etag, ok := custom.multipartETag(); !ok || etag != expectedMultipartETag(data, 6*1024*1024) {
+		t.Errorf("custom part size multipartETag() = %s, %v", etag, ok)
+	}
+
+	// Hashed at a part size the upload wouldn't use: too many parts
+	small := newMultipartHasher(16)
+	small.Write(data[:16*maxUploadParts+1])
+	if _, ok := small.multipartETag(); ok {
+		t.Error("expected no ETag when the part size doesn't match the upload's")
+	}
+}
+
+func TestTempFileMultipartETag(t *testing.T) {
+	archiver := NewArchiver(&Config{}, newTestLogger())
+	path := filepath.Join(t.TempDir(), "archive.csv.gz")
+	if err := os.WriteFile(path, []byte("id\n1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ETag recorded while the file was written is used as is
+	archiver.etags.set(path, "streamed-2")
+	if etag, err := archiver.tempFileMultipartETag(path); err != nil || etag != "streamed-2" {
+		t.Errorf("tempFileMultipartETag() = %s, %v", etag, err)
+	}
+
+	archiver.etags.forget(path)
+	sum := md5.Sum([]byte("id\n1\n")) //nolint:gosec // MD5 used for checksums, not cryptography
+	if etag, err := archiver.tempFileMultipartETag(path); err != nil || etag != hex.EncodeToString(sum[:]) {
+		t.Errorf("tempFileMultipartETag() after forget = %s, %v", etag, err)
+	}
+}
+
+func BenchmarkMultipartHasher(b *testing.B) {
+	data := bytes.Repeat([]byte("test"), 100000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hasher := newMultipartHasher(minUploadPartSize)
+		hasher.Write(data)
+		_, _ = hasher.multipartETag()
+	}
+}
@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// S3 limits on object tags
+const (
+	maxObjectTags        = 10
+	maxObjectTagKeyLen   = 128
+	maxObjectTagValueLen = 256
+)
+
+// Static errors for object tags and metadata
+var (
+	ErrObjectTagsTooMany    = errors.New("S3 objects take at most 10 tags")
+	ErrObjectTagInvalid     = errors.New("S3 tag keys must be 1-128 characters and values at most 256")
+	ErrObjectMetadataKey    = errors.New("S3 metadata keys may only use lowercase letters, digits, '-' and '_'")
+	ErrObjectMetadataInUse  = errors.New("S3 metadata key is set by the archiver")
+	ErrObjectLabelPlacehold = errors.New("unknown placeholder in S3 tag or metadata value")
+)
+
+// validMetadataKey matches user metadata keys that survive HTTP header
+// normalization unchanged
+var validMetadataKey = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// labelPlaceholder matches the placeholders of tag and metadata values
+var labelPlaceholder = regexp.MustCompile(`\{[A-Za-z_]+\}`)
+
+// objectLabelPlaceholders are the placeholders tag and metadata values take,
+// besides the date placeholders of path templates
+var objectLabelPlaceholders = map[string]bool{
+	"{table}": true, "{partition}": true, "{date}": true, "{rows}": true, "{uncompressed_size}": true,
+	"{format}": true, "{compression}": true, "{version}": true, "{run_id}": true,
+	"{YYYY}": true, "{MM}": true, "{DD}": true, "{HH}": true, "{WW}": true, "{Q}": true,
+}
+
+var (
+	s3Tags     map[string]string
+	s3Metadata map[string]string
+)
+
+func init() {
+	archiveCmd.Flags().StringToStringVar(&s3Tags, "s3-tags", nil, "tags added to every uploaded object, as key=value pairs; values take placeholders such as {table}, {date}, {rows} and {version}")
+	archiveCmd.Flags().StringToStringVar(&s3Metadata, "s3-metadata", nil, "user metadata added to every uploaded object, as key=value pairs with the same placeholders as --s3-tags")
+	_ = viper.BindPFlag("s3.tags", archiveCmd.Flags().Lookup("s3-tags"))
+	_ = viper.BindPFlag("s3.metadata", archiveCmd.Flags().Lookup("s3-metadata"))
+}
+
+// validateObjectLabels checks configured tags and metadata against S3's limits
+func validateObjectLabels(tags, metadata map[string]string) error {
+	if len(tags) > maxObjectTags {
+		return fmt.Errorf("%w, got %d", ErrObjectTagsTooMany, len(tags))
+	}
+	for key, value := range tags {
+		if key == "" || len(key) > maxObjectTagKeyLen || len(value) > maxObjectTagValueLen {
+			return fmt.Errorf("%w: '%s'", ErrObjectTagInvalid, key)
+		}
+		if err := checkLabelPlaceholders(value); err != nil {
+			return err
+		}
+	}
+	for key, value := range metadata {
+		if !validMetadataKey.MatchString(key) {
+			return fmt.Errorf("%w: '%s'", ErrObjectMetadataKey, key)
+		}
+		if key == runIDMetadataKey || key == rowLimitMetadataKey || key == maskedColumnsMetadataKey {
+			return fmt.Errorf("%w: '%s'", ErrObjectMetadataInUse, key)
+		}
+		if err := checkLabelPlaceholders(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkLabelPlaceholders(value string) error {
+	for _, placeholder := range labelPlaceholder.FindAllString(value, -1) {
+		if !objectLabelPlaceholders[placeholder] {
+			return fmt.Errorf("%w: %s in '%s'", ErrObjectLabelPlacehold, placeholder, value)
+		}
+	}
+	return nil
+}
+
+// objectLabels describes an uploaded object for its tag and metadata
+// placeholders. Zero fields leave their placeholders empty.
+type objectLabels struct {
+	Table            string
+	Partition        string
+	Date             time.Time
+	Rows             int64
+	UncompressedSize int64
+}
+
+// render replaces the placeholders of a tag or metadata value
+func (l objectLabels) render(value string, format archiveFormat) string {
+	if l.Date.IsZero() {
+		value = labelPlaceholder.ReplaceAllStringFunc(value, func(placeholder string) string {
+			switch placeholder {
+			case "{date}", "{YYYY}", "{MM}", "{DD}", "{HH}", "{WW}", "{Q}":
+				return ""
+			}
+			return placeholder
+		})
+	} else {
+		value = strings.ReplaceAll(value, "{date}", l.Date.Format("2006-01-02"))
+		value = renderDatePlaceholders(value, l.Date)
+	}
+	count := func(n int64) string {
+		if n <= 0 {
+			return ""
+		}
+		return strconv.FormatInt(n, 10)
+	}
+	return strings.NewReplacer(
+		"{table}", l.Table,
+		"{partition}", l.Partition,
+		"{rows}", count(l.Rows),
+		"{uncompressed_size}", count(l.UncompressedSize),
+		"{format}", format.Format,
+		"{compression}", format.Compression,
+		"{version}", Version,
+		"{run_id}", currentRunID,
+	).Replace(value)
+}
+
+// uploadLabels holds the labels of objects about to be uploaded, until the
+// upload has read them
+type uploadLabels struct {
+	mu     sync.Mutex
+	labels map[string]objectLabels
+}
+
+func newUploadLabels() *uploadLabels {
+	return &uploadLabels{labels: make(map[string]objectLabels)}
+}
+
+func (u *uploadLabels) set(key string, labels objectLabels) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.labels[key] = labels
+}
+
+func (u *uploadLabels) get(key string) (objectLabels, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	labels, ok := u.labels[key]
+	return labels, ok
+}
+
+func (u *uploadLabels) forget(key string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.labels, key)
+}
+
+// labelsFor returns the labels of the object being uploaded to key. Objects
+// uploaded without labels, such as schema dumps, are labelled with the table.
+func (a *Archiver) labelsFor(key string) objectLabels {
+	if labels, ok := a.labels.get(key); ok {
+		return labels
+	}
+	return objectLabels{Table: a.config.Table}
+}
+
+// objectTagging returns the URL-encoded tag set of the object at key, or ""
+// without --s3-tags. Tags that render empty are left off.
+func (a *Archiver) objectTagging(key string) string {
+	if len(a.config.S3.Tags) == 0 {
+		return ""
+	}
+	labels := a.labelsFor(key)
+	tags := url.Values{}
+	for name, value := range a.config.S3.Tags {
+		if rendered := labels.render(value, a.archiveFormat()); rendered != "" {
+			tags.Set(name, rendered)
+		}
+	}
+	return tags.Encode()
+}
+
+// addObjectMetadata adds the configured metadata of the object at key.
+// Values that render empty are left off.
+func (a *Archiver) addObjectMetadata(metadata map[string]string, key string) {
+	if len(a.config.S3.Metadata) == 0 {
+		return
+	}
+	labels := a.labelsFor(key)
+	names := make([]string, 0, len(a.config.S3.Metadata))
+	for name := range a.config.S3.Metadata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if rendered := labels.render(a.config.S3.Metadata[name], a.archiveFormat()); rendered != "" {
+			metadata[name] = rendered
+		}
+	}
+}
+
+// partitionLabels returns the labels of the archive of a partition, or of its
+// slice starting at date
+func (a *Archiver) partitionLabels(partition PartitionInfo, date time.Time, rows, uncompressedSize int64) objectLabels {
+	return objectLabels{
+		Table:            a.config.Table,
+		Partition:        partition.TableName,
+		Date:             date,
+		Rows:             rows,
+		UncompressedSize: uncompressedSize,
+	}
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestValidateObjectLabels(t *testing.T) {
+	tooMany := make(map[string]string)
+	for _, key := range strings.Split("abcdefghijk", "") {
+		tooMany[key] = "v"
+	}
+	tests := []struct {
+		name     string
+		tags     map[string]string
+		metadata map[string]string
+		want     error
+	}{
+		{"Valid", map[string]string{"Table": "{table}", "expires": "{YYYY}-{Q}"}, map[string]string{"archiver-version": "{version}", "rows": "{rows}"}, nil},
+		{"TooManyTags", tooMany, nil, ErrObjectTagsTooMany},
+		{"LongTagValue", map[string]string{"table": strings.Repeat("x", 257)}, nil, ErrObjectTagInvalid},
+		{"UnknownPlaceholder", map[string]string{"table": "{tabel}"}, nil, ErrObjectLabelPlacehold},
+		{"MetadataKeyCase", nil, map[string]string{"Table": "{table}"}, ErrObjectMetadataKey},
+		{"MetadataKeyReserved", nil, map[string]string{runIDMetadataKey: "x"}, ErrObjectMetadataInUse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateObjectLabels(tt.tags, tt.metadata); !errors.Is(err, tt.want) {
+				t.Errorf("validateObjectLabels() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestObjectLabelsRender(t *testing.T) {
+	format := archiveFormat{Format: "jsonl", Compression: "zstd"}
+	labels := objectLabels{
+		Table:            "events",
+		Partition:        "events_20240315",
+		Date:             time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+		Rows:             1500,
+		UncompressedSize: 4096,
+	}
+	got := labels.render("{table}/{partition}/{date}/{YYYY}-{MM}/{rows}/{uncompressed_size}/{format}.{compression}", format)
+	if want := "events/events_20240315/2024-03-15/2024-03/1500/4096/jsonl.zstd"; got != want {
+		t.Errorf("render() = %q, want %q", got, want)
+	}
+	// Objects without a date or counts, such as schema dumps, leave them empty
+	if got := (objectLabels{Table: "events"}).render("{table}:{date}:{YYYY}:{rows}", format); got != "events:::" {
+		t.Errorf("render() without a date = %q", got)
+	}
+}
+
+func TestUploadsCarryTagsAndMetadata(t *testing.T) {
+	store := newFakeObjectStore(nil)
+	archiver := NewArchiver(&Config{Table: "events", S3: S3Config{
+		Bucket:   "bucket",
+		Tags:     map[string]string{"table": "{table}", "date": "{date}", "tier": "cold"},
+		Metadata: map[string]string{"rows": "{rows}", "partition-date": "{date}"},
+	}}, newTestLogger())
+	archiver.s3Client = newS3ObjectStore(store)
+
+	tempFile := filepath.Join(t.TempDir(), "events.jsonl.zst")
+	if err := os.WriteFile(tempFile, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	key := "events/events-2024-03-15.jsonl.zst"
+	partition := PartitionInfo{TableName: "events_20240315", Date: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)}
+	archiver.labels.set(key, archiver.partitionLabels(partition, partition.Date, 1500, 4096))
+	if err := archiver.uploadTempFileToS3(tempFile, key); err != nil {
+		t.Fatal(err)
+	}
+	archiver.labels.forget(key)
+	if err := archiver.uploadToS3("events/schema.json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err := url.ParseQuery(aws.StringValue(store.puts[key].Tagging))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags.Get("table") != "events" || tags.Get("date") != "2024-03-15" || tags.Get("tier") != "cold" {
+		t.Errorf("tags = %v", tags)
+	}
+	metadata := aws.StringValueMap(store.puts[key].Metadata)
+	if metadata["rows"] != "1500" || metadata["partition-date"] != "2024-03-15" || metadata[runIDMetadataKey] != currentRunID {
+		t.Errorf("metadata = %v", metadata)
+	}
+
+	// An object uploaded without labels leaves out what renders empty
+	tags, _ = url.ParseQuery(aws.StringValue(store.puts["events/schema.json"].Tagging))
+	if tags.Has("date") || tags.Get("table") != "events" {
+		t.Errorf("schema tags = %v", tags)
+	}
+	if _, ok := store.puts["events/schema.json"].Metadata["rows"]; ok {
+		t.Errorf("schema metadata = %v", aws.StringValueMap(store.puts["events/schema.json"].Metadata))
+	}
+}
@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/md5" //nolint:gosec // MD5 used for checksums, not cryptography
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+var errFakeNetwork = errors.New("connection reset by peer")
+
+// fakeObjectStore is the in-memory bucket shared by tests that talk to S3
+type fakeObjectStore struct {
+	s3iface.S3API
+	mu      sync.Mutex // Requests may arrive concurrently
+	objects map[string][]byte
+	puts    map[string]*s3.PutObjectInput // Last PutObject request per key
+	etags   map[string]string             // ETags that are not the MD5 of the object, by key
+	sse     string                        // ServerSideEncryption reported by HEAD
+	sseC    string                        // SSECustomerAlgorithm reported by HEAD
+
+	heads    atomic.Int64
+	lists    atomic.Int64
+	throttle bool // HEAD requests fail with SlowDown
+
+	gets      []string     // Range of every GET, in order
+	failGets  map[int]bool // 1-based GET numbers that fail
+	truncated map[int]bool // 1-based GET numbers that return a short body
+
+	uploads  map[string]map[int64][]byte // Upload ID → part number → bytes
+	nextID   int
+	sent     []int64 // Part numbers received, in order
+	failPart int64   // Part number whose upload fails (0 = none)
+}
+
+// newFakeObjectStore returns a store holding objects, ready for injected GET failures
+func newFakeObjectStore(objects map[string][]byte) *fakeObjectStore {
+	if objects == nil {
+		objects = map[string][]byte{}
+	}
+	return &fakeObjectStore{
+		objects:   objects,
+		failGets:  map[int]bool{},
+		truncated: map[int]bool{},
+	}
+}
+
+func md5Hex(data []byte) string {
+	sum := md5.Sum(data) //nolint:gosec // MD5 used for checksums, not cryptography
+	return hex.EncodeToString(sum[:])
+}
+
+func quotedMD5(data []byte) string {
+	return `"` + md5Hex(data) + `"`
+}
+
+// etag returns the quoted ETag of key; the caller holds f.mu
+func (f *fakeObjectStore) etag(key string) string {
+	if etag, ok := f.etags[key]; ok {
+		return `"` + etag + `"`
+	}
+	return quotedMD5(f.objects[key])
+}
+
+func (f *fakeObjectStore) put(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	var data []byte
+	if input.Body != nil {
+		var err error
+		if data, err = io.ReadAll(input.Body); err != nil {
+			return nil, err
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := aws.StringValue(input.Key)
+	if f.puts == nil {
+		f.puts = map[string]*s3.PutObjectInput{}
+	}
+	f.objects[key] = data
+	f.puts[key] = input
+	return &s3.PutObjectOutput{ETag: aws.String(quotedMD5(data))}, nil
+}
+
+func (f *fakeObjectStore) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	return f.put(input)
+}
+
+func (f *fakeObjectStore) PutObjectWithContext(_ aws.Context, input *s3.PutObjectInput, _ ...request.Option) (*s3.PutObjectOutput, error) {
+	return f.put(input)
+}
+
+func (f *fakeObjectStore) HeadObjectWithContext(_ aws.Context, input *s3.HeadObjectInput, _ ...request.Option) (*s3.HeadObjectOutput, error) {
+	f.heads.Add(1)
+	if f.throttle {
+		return nil, awserr.NewRequestFailure(awserr.New("SlowDown", "reduce your request rate", nil), http.StatusServiceUnavailable, "")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := aws.StringValue(input.Key)
+	data, ok := f.objects[key]
+	if !ok {
+		return nil, awserr.New("NotFound", "not found", nil)
+	}
+	head := &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(int64(len(data))),
+		ETag:          aws.String(f.etag(key)),
+	}
+	if f.sse != "" {
+		head.ServerSideEncryption = aws.String(f.sse)
+	}
+	if f.sseC != "" {
+		head.SSECustomerAlgorithm = aws.String(f.sseC)
+	}
+	return head, nil
+}
+
+func (f *fakeObjectStore) GetObjectWithContext(_ aws.Context, input *s3.GetObjectInput, _ ...request.Option) (*s3.GetObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rangeHeader := aws.StringValue(input.Range)
+	f.gets = append(f.gets, rangeHeader)
+	n := len(f.gets)
+	if f.failGets[n] {
+		return nil, errFakeNetwork
+	}
+	key := aws.StringValue(input.Key)
+	data, ok := f.objects[key]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "not found", nil)
+	}
+	if input.IfMatch != nil && aws.StringValue(input.IfMatch) != f.etag(key) {
+		return nil, fmt.Errorf("precondition failed for %s", aws.StringValue(input.IfMatch))
+	}
+	if rangeHeader != "" {
+		var start, end int
+		if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-%d", &start, &end); err != nil {
+			return nil, err
+		}
+		data = data[start:min(end+1, len(data))]
+	}
+	if f.truncated[n] {
+		data = data[:len(data)/2]
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (f *fakeObjectStore) ListObjectsV2PagesWithContext(_ aws.Context, input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool, _ ...request.Option) error {
+	f.lists.Add(1)
+	f.mu.Lock()
+	page := &s3.ListObjectsV2Output{}
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		if strings.HasPrefix(key, aws.StringValue(input.Prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		page.Contents = append(page.Contents, &s3.Object{
+			Key:  aws.String(key),
+			Size: aws.Int64(int64(len(f.objects[key]))),
+			ETag: aws.String(f.etag(key)),
+		})
+	}
+	f.mu.Unlock()
+	fn(page, true)
+	return nil
+}
+
+func (f *fakeObjectStore) CopyObjectWithContext(_ aws.Context, input *s3.CopyObjectInput, _ ...request.Option) (*s3.CopyObjectOutput, error) {
+	source, err := url.PathUnescape(aws.StringValue(input.CopySource))
+	if err != nil {
+		return nil, err
+	}
+	_, key, _ := strings.Cut(source, "/")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.objects[key]
+	if !ok {
+		return nil, errors.New("no such source " + key)
+	}
+	f.objects[aws.StringValue(input.Key)] = data
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func (f *fakeObjectStore) DeleteObjectWithContext(_ aws.Context, input *s3.DeleteObjectInput, _ ...request.Option) (*s3.DeleteObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.objects, aws.StringValue(input.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (f *fakeObjectStore) CreateMultipartUploadWithContext(_ aws.Context, _ *s3.CreateMultipartUploadInput, _ ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.uploads == nil {
+		f.uploads = map[string]map[int64][]byte{}
+	}
+	f.nextID++
+	id := fmt.Sprintf("upload-%d", f.nextID)
+	f.uploads[id] = map[int64][]byte{}
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String(id)}, nil
+}
+
+func (f *fakeObjectStore) UploadPartWithContext(_ aws.Context, input *s3.UploadPartInput, _ ...request.Option) (*s3.UploadPartOutput, error) {
+	data, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parts, ok := f.uploads[aws.StringValue(input.UploadId)]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchUpload, "no such upload", nil)
+	}
+	number := aws.Int64Value(input.PartNumber)
+	if number == f.failPart {
+		return nil, errFakeNetwork
+	}
+	parts[number] = data
+	f.sent = append(f.sent, number)
+	return &s3.UploadPartOutput{ETag: aws.String(quotedMD5(data))}, nil
+}
+
+func (f *fakeObjectStore) ListPartsPagesWithContext(_ aws.Context, input *s3.ListPartsInput, fn func(*s3.ListPartsOutput, bool) bool, _ ...request.Option) error {
+	f.mu.Lock()
+	parts, ok := f.uploads[aws.StringValue(input.UploadId)]
+	if !ok {
+		f.mu.Unlock()
+		return awserr.New(s3.ErrCodeNoSuchUpload, "no such upload", nil)
+	}
+	page := &s3.ListPartsOutput{}
+	for number, data := range parts {
+		page.Parts = append(page.Parts, &s3.Part{PartNumber: aws.Int64(number), ETag: aws.String(quotedMD5(data))})
+	}
+	f.mu.Unlock()
+	fn(page, true)
+	return nil
+}
+
+func (f *fakeObjectStore) CompleteMultipartUploadWithContext(_ aws.Context, input *s3.CompleteMultipartUploadInput, _ ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := aws.StringValue(input.UploadId)
+	parts, ok := f.uploads[id]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchUpload, "no such upload", nil)
+	}
+	var data []byte
+	for i, part := range input.MultipartUpload.Parts {
+		if aws.Int64Value(part.PartNumber) != int64(i+1) || aws.StringValue(part.ETag) != quotedMD5(parts[int64(i+1)]) {
+			return nil, fmt.Errorf("invalid part %d", i+1)
+		}
+		data = append(data, parts[int64(i+1)]...)
+	}
+	f.objects[aws.StringValue(input.Key)] = data
+	delete(f.uploads, id)
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (f *fakeObjectStore) AbortMultipartUploadWithContext(_ aws.Context, input *s3.AbortMultipartUploadInput, _ ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.uploads, aws.StringValue(input.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Keys of the terminal UI's operator controls, available while processing
+const (
+	skipPartitionKey = "s"
+	retrySliceKey    = "r"
+	pauseKey         = "p"
+)
+
+// skippedByUserReason is the skip reason of a partition the operator skipped
+const skippedByUserReason = "Skipped by user"
+
+// failedSlice is a slice that failed, or a partition archived as one file
+type failedSlice struct {
+	partition  PartitionInfo
+	start, end time.Time // Zero for a partition archived as one file
+}
+
+func (f failedSlice) String() string {
+	if f.start.IsZero() {
+		return f.partition.TableName
+	}
+	return fmt.Sprintf("%s slice %s", f.partition.TableName, f.start.Format("2006-01-02"))
+}
+
+// operatorControls carries the terminal UI's skip and retry keys to the
+// partition being processed. The UI processes one partition at a time.
+type operatorControls struct {
+	mu      sync.Mutex
+	current string             // Partition being processed
+	cancel  context.CancelFunc // Stops the current partition
+	skipped bool               // The operator skipped the current partition
+	failed  *failedSlice       // Last slice that failed
+	retry   bool               // The operator asked to retry the last failed slice
+}
+
+// begin starts a partition under a context the skip key cancels
+func (c *operatorControls) begin(ctx context.Context, partition string) context.Context {
+	ctx, cancel := context.WithCancel(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current, c.cancel, c.skipped = partition, cancel, false
+	return ctx
+}
+
+// finish ends the current partition
+func (c *operatorControls) finish() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.current, c.cancel, c.skipped = "", nil, false
+}
+
+// skip stops the current partition and returns its name, or "" when no
+// partition is being processed
+func (c *operatorControls) skip() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel == nil || c.skipped {
+		return ""
+	}
+	c.skipped = true
+	c.cancel()
+	return c.current
+}
+
+// settle marks result skipped by the user when the operator skipped its
+// partition. Slices uploaded before the skip stay in place.
+func (c *operatorControls) settle(result *ProcessResult) {
+	c.mu.Lock()
+	skipped := c.skipped && c.current == result.Partition.TableName
+	c.mu.Unlock()
+	if !skipped {
+		return
+	}
+	result.Error = nil
+	result.Skipped = true
+	result.SkipReason = skippedByUserReason
+	result.Stage = StageSkipped
+}
+
+// fail records a failed slice for the retry key. Slices stopped by a skip
+// did not fail.
+func (c *operatorControls) fail(failed failedSlice) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.skipped && c.current == failed.partition.TableName {
+		return
+	}
+	c.failed = &failed
+}
+
+// requestRetry asks for the last failed slice to be retried and returns it
+func (c *operatorControls) requestRetry() (failedSlice, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failed == nil {
+		return failedSlice{}, false
+	}
+	c.retry = true
+	return *c.failed, true
+}
+
+// takeRetry returns the slice to retry, if the operator asked for one
+func (c *operatorControls) takeRetry() (failedSlice, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.retry || c.failed == nil {
+		return failedSlice{}, false
+	}
+	failed := *c.failed
+	c.retry, c.failed = false, nil
+	return failed, true
+}
+
+// processControlledPartition processes partition under a context the skip
+// key cancels
+func (a *Archiver) processControlledPartition(partition PartitionInfo, program *tea.Program) ProcessResult {
+	parent := a.ctx
+	a.ctx = a.controls.begin(parent, partition.TableName)
+	defer func() {
+		a.controls.finish()
+		a.ctx = parent
+	}()
+	return a.ProcessPartitionWithProgress(partition, program)
+}
+
+// retrySlice processes a failed slice again. A partition archived as one file
+// is processed again as a whole.
+func (a *Archiver) retrySlice(failed failedSlice, program *tea.Program) ProcessResult {
+	if failed.start.IsZero() {
+		return a.processControlledPartition(failed.partition, program)
+	}
+
+	parent := a.ctx
+	a.ctx = a.controls.begin(parent, failed.partition.TableName)
+	defer func() {
+		a.controls.finish()
+		a.ctx = parent
+	}()
+	result := a.processSinglePartitionSlice(failed.partition, program, failed.start, failed.end)
+	if result.Error != nil {
+		a.controls.fail(failed)
+	}
+	a.controls.settle(&result)
+	a.recordResult(result)
+	a.emitResult(progressEventSliceComplete, result, progressEvent{Slice: failed.start.Format("2006-01-02")})
+	return result
+}
+
+// togglePause pauses the run after the running partitions and slices, or
+// lifts the pause the key set, and returns a line describing the outcome
+func togglePause() string {
+	if archivePause.heldBy(pauseSourceKeyboard) {
+		archivePause.set(pauseSourceKeyboard, false)
+		if archivePause.paused() {
+			return "▶️  Keyboard pause lifted; still paused by a signal or the pause file"
+		}
+		return "▶️  Resumed"
+	}
+	archivePause.set(pauseSourceKeyboard, true)
+	return fmt.Sprintf("⏸️  Pausing after the current slice (press '%s' to resume)", pauseKey)
+}
+
+// handleControlKey handles the operator controls while partitions are processed
+func (m progressModel) handleControlKey(key string) (tea.Model, tea.Cmd) {
+	switch key {
+	case skipPartitionKey:
+		if partition := m.archiver.controls.skip(); partition != "" {
+			m.addMessage(fmt.Sprintf("⏭️  Skipping %s", partition))
+		}
+	case retrySliceKey:
+		failed, ok := m.archiver.controls.requestRetry()
+		if !ok {
+			m.addMessage("No failed slice to retry")
+			break
+		}
+		m.addMessage(fmt.Sprintf("🔁 Retrying %s after the current partition", failed))
+	case pauseKey:
+		m.addMessage(togglePause())
+		if taskInfo, err := ReadTaskInfo(); err == nil {
+			_ = WriteTaskInfo(taskInfo)
+		}
+	}
+	return m, nil
+}
+
+// sliceRetriedMsg carries the result of retrying a failed slice
+type sliceRetriedMsg struct {
+	failed failedSlice
+	result ProcessResult
+}
+
+// retryNext retries the slice the operator asked for, if any
+func (m *progressModel) retryNext() tea.Cmd {
+	failed, ok := m.archiver.controls.takeRetry()
+	if !ok {
+		return nil
+	}
+	m.currentStage = fmt.Sprintf("Retrying %s", failed)
+	m.processingStartTime = time.Now()
+	m.updateTaskInfo()
+	return func() tea.Msg {
+		return sliceRetriedMsg{failed: failed, result: m.archiver.retrySlice(failed, m.program)}
+	}
+}
+
+// handleSliceRetriedMsg reports a retried slice. A retried partition replaces
+// its failed result; a retried slice is added to the results next to the
+// partition it belongs to.
+func (m progressModel) handleSliceRetriedMsg(msg sliceRetriedMsg) (tea.Model, tea.Cmd) {
+	m.logResult(fmt.Sprintf("%s (retry)", msg.failed), msg.result)
+	replaced := false
+	if msg.failed.start.IsZero() {
+		for i := len(m.results) - 1; i >= 0; i-- {
+			if m.results[i].Partition.TableName == msg.failed.partition.TableName && m.results[i].Error != nil {
+				m.results[i] = msg.result
+				replaced = true
+				break
+			}
+		}
+	}
+	if !replaced {
+		m.results = append(m.results, msg.result)
+	}
+	m.currentStage = ""
+	m.processingStartTime = time.Time{}
+	return m, m.processNext()
+}
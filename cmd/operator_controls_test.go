@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOperatorControlsSkip(t *testing.T) {
+	var controls operatorControls
+	if got := controls.skip(); got != "" {
+		t.Errorf("skip() without a partition = %q", got)
+	}
+
+	ctx := controls.begin(context.Background(), "flights_20240101")
+	if got := controls.skip(); got != "flights_20240101" {
+		t.Errorf("skip() = %q", got)
+	}
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Error("skip() should cancel the partition's context")
+	}
+
+	// The cancelled slice is not a failure to retry, and the partition is skipped
+	partition := PartitionInfo{TableName: "flights_20240101"}
+	controls.fail(failedSlice{partition: partition, start: time.Now()})
+	if _, ok := controls.requestRetry(); ok {
+		t.Error("a slice stopped by a skip should not be retried")
+	}
+	result := ProcessResult{Partition: partition, Error: context.Canceled, Stage: StageCancelled, BytesWritten: 100}
+	controls.settle(&result)
+	if result.Error != nil || !result.Skipped || result.SkipReason != skippedByUserReason || result.BytesWritten != 100 {
+		t.Errorf("settled result = %+v", result)
+	}
+
+	// The next partition runs normally
+	controls.finish()
+	ctx = controls.begin(context.Background(), "flights_20240102")
+	other := ProcessResult{Partition: PartitionInfo{TableName: "flights_20240102"}}
+	controls.settle(&other)
+	if ctx.Err() != nil || other.Skipped {
+		t.Errorf("next partition: ctx error %v, result %+v", ctx.Err(), other)
+	}
+	controls.finish()
+}
+
+func TestOperatorControlsRetry(t *testing.T) {
+	var controls operatorControls
+	if _, ok := controls.takeRetry(); ok {
+		t.Error("takeRetry() without a request")
+	}
+
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	controls.fail(failedSlice{partition: PartitionInfo{TableName: "flights_20240101"}})
+	controls.fail(failedSlice{partition: PartitionInfo{TableName: "flights_202401"}, start: start, end: start.AddDate(0, 0, 1)})
+	if _, ok := controls.takeRetry(); ok {
+		t.Error("takeRetry() before the operator asked")
+	}
+	failed, ok := controls.requestRetry()
+	if !ok || failed.String() != "flights_202401 slice 2024-01-02" {
+		t.Fatalf("requestRetry() = %v, %v", failed, ok)
+	}
+	if failed, ok := controls.takeRetry(); !ok || !failed.start.Equal(start) {
+		t.Errorf("takeRetry() = %v, %v", failed, ok)
+	}
+	if _, ok := controls.requestRetry(); ok {
+		t.Error("a retried slice should not be retried again until it fails again")
+	}
+}
+
+func TestTogglePause(t *testing.T) {
+	t.Cleanup(func() { archivePause.set(pauseSourceKeyboard, false) })
+	togglePause()
+	if !archivePause.paused() || !archivePause.heldBy(pauseSourceKeyboard) {
+		t.Fatal("the pause key should pause the run")
+	}
+	if got := togglePause(); got != "▶️  Resumed" || archivePause.paused() {
+		t.Errorf("togglePause() = %q, paused = %v", got, archivePause.paused())
+	}
+}
+
+func TestControlKeysAndRetriedResults(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	archiver := NewArchiver(&Config{Table: "flights"}, newTestLogger())
+	m := progressModel{config: &Config{}, archiver: archiver, phase: PhaseProcessing, log: newLogPane(10)}
+
+	m = typeKeys(m, runeKey(retrySliceKey))
+	if last := m.messages[len(m.messages)-1]; last != "No failed slice to retry" {
+		t.Errorf("message = %q", last)
+	}
+
+	// A retried partition replaces its failed result; a retried slice is added
+	failed := PartitionInfo{TableName: "flights_20240101"}
+	m.results = []ProcessResult{{Partition: failed, Error: errors.New("upload failed")}} //nolint:err113 // test error
+	model, _ := m.handleSliceRetriedMsg(sliceRetriedMsg{failed: failedSlice{partition: failed}, result: ProcessResult{Partition: failed, Uploaded: true}})
+	m = model.(progressModel)
+	if len(m.results) != 1 || !m.results[0].Uploaded {
+		t.Errorf("results after a partition retry = %+v", m.results)
+	}
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	model, _ = m.handleSliceRetriedMsg(sliceRetriedMsg{failed: failedSlice{partition: failed, start: start}, result: ProcessResult{Partition: failed, Uploaded: true}})
+	m = model.(progressModel)
+	if len(m.results) != 2 {
+		t.Errorf("results after a slice retry = %+v", m.results)
+	}
+}
@@ -0,0 +1,332 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DurationAuto matches --output-duration to the period of the discovered partitions
+const DurationAuto = "auto"
+
+// Static errors for output durations
+var (
+	ErrOutputIntervalInvalid   = errors.New("custom output duration must be a number of hours that divides a day (1h-12h) or a number of days (1d-31d)")
+	ErrOutputDurationAggregate = errors.New("combining partitions into longer output files reads them through the base table, which needs a date column")
+	ErrOutputDurationGap       = errors.New("partitions combined into one output file must cover a continuous range")
+)
+
+// outputIntervalPattern matches a custom --output-duration such as 6h or 3d
+var outputIntervalPattern = regexp.MustCompile(`^([0-9]+)([hd])$`)
+
+// outputInterval is a custom --output-duration of a number of hours or days.
+// Hour intervals restart at every midnight and day intervals on the 1st of
+// every month, so files never span two partitions and a month's last day
+// interval may be shorter.
+type outputInterval struct {
+	hours int
+	days  int
+}
+
+// parseOutputInterval parses a custom --output-duration; ok is false for
+// anything else, including the named durations
+func parseOutputInterval(duration string) (outputInterval, bool) {
+	match := outputIntervalPattern.FindStringSubmatch(duration)
+	if match == nil {
+		return outputInterval{}, false
+	}
+	n, err := strconv.Atoi(match[1])
+	if err != nil {
+		return outputInterval{}, false
+	}
+	if match[2] == "h" {
+		return outputInterval{hours: n}, true
+	}
+	return outputInterval{days: n}, true
+}
+
+// validate checks that hour intervals divide a day and day intervals fit a month
+func (i outputInterval) validate(duration string) error {
+	if i.hours > 0 && i.hours < 24 && 24%i.hours == 0 || i.days > 0 && i.days <= 31 {
+		return nil
+	}
+	return fmt.Errorf("%w, got '%s'", ErrOutputIntervalInvalid, duration)
+}
+
+// timeRange returns the interval holding baseTime, on the wall clock of its zone
+func (i outputInterval) timeRange(baseTime time.Time) (time.Time, time.Time) {
+	year, month, day := baseTime.Date()
+	loc := baseTime.Location()
+	if i.hours > 0 {
+		hour := baseTime.Hour() / i.hours * i.hours
+		return wallClockTime(year, month, day, hour, loc), wallClockTime(year, month, day, hour+i.hours, loc)
+	}
+	first := (day-1)/i.days*i.days + 1
+	last := min(first+i.days, time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()+1)
+	return wallClockTime(year, month, first, 0, loc), wallClockTime(year, month, last, 0, loc)
+}
+
+// keyedDuration returns the named duration whose filenames and placeholders a
+// duration uses: hourly for hour intervals, daily for day intervals, and
+// named durations unchanged
+func keyedDuration(duration string) string {
+	if interval, ok := parseOutputInterval(duration); ok {
+		if interval.hours > 0 {
+			return DurationHourly
+		}
+		return DurationDaily
+	}
+	return duration
+}
+
+// durationSpan returns the nominal length of an output duration or partition
+// period, for telling whether files are shorter or longer than partitions,
+// or 0 when it is not known
+func durationSpan(duration string) time.Duration {
+	const day = 24 * time.Hour
+	if interval, ok := parseOutputInterval(duration); ok {
+		return time.Duration(interval.hours)*time.Hour + time.Duration(interval.days)*day
+	}
+	switch duration {
+	case DurationHourly:
+		return time.Hour
+	case DurationDaily:
+		return day
+	case DurationWeekly:
+		return 7 * day
+	case DurationMonthly:
+		return 31 * day
+	case DurationQuarterly:
+		return 92 * day
+	case DurationYearly:
+		return 366 * day
+	}
+	return 0
+}
+
+// validateOutputDuration checks --output-duration: a named duration, auto,
+// or a custom interval
+func (c *Config) validateOutputDuration() error {
+	if c.OutputDuration == DurationAuto || isValidOutputDuration(c.OutputDuration) {
+		return nil
+	}
+	if interval, ok := parseOutputInterval(c.OutputDuration); ok {
+		return interval.validate(c.OutputDuration)
+	}
+	return fmt.Errorf("%w, auto, or a custom interval such as 6h or 3d: '%s'", ErrOutputDurationInvalid, c.OutputDuration)
+}
+
+// partitionPeriodRange returns the time range a partition covers: its custom
+// range, or the day or month in its name
+func (a *Archiver) partitionPeriodRange(partition PartitionInfo) (time.Time, time.Time) {
+	if partition.HasCustomRange() {
+		return partition.RangeStart, partition.RangeEnd
+	}
+	start := partition.Date
+	if a.partitionPeriod(partition) == DurationMonthly {
+		start = wallClockTime(start.Year(), start.Month(), 1, 0, start.Location())
+	}
+	return start, a.partitionPeriodEnd(partition)
+}
+
+// aggregatePartitions combines partitions shorter than --output-duration into
+// one partition per output period, such as a week of daily partitions for
+// weekly files. A combined partition reads the base table by the date column
+// over the range its partitions cover, which PostgreSQL prunes to them; a
+// period cut by --start-date or --end-date reads only the part in range. A
+// period missing a partition in the middle, such as one without SELECT
+// permission, is refused, since reading through the base table would include
+// its rows. Other partitions are returned unchanged.
+func (a *Archiver) aggregatePartitions(ctx context.Context, partitions []PartitionInfo) ([]PartitionInfo, error) {
+	output := durationSpan(a.config.OutputDuration)
+	var combined []PartitionInfo
+	groups := make(map[int64]int)           // Period start → index in combined
+	covered := make(map[int][][2]time.Time) // Index in combined → member ranges
+	for _, partition := range partitions {
+		if !a.combinable(partition, output) {
+			combined = append(combined, partition)
+			continue
+		}
+		if len(groups) == 0 {
+			if err := a.checkAggregation(ctx, partition.TableName); err != nil {
+				return nil, err
+			}
+		}
+
+		start, _ := GetTimeRangeForDuration(partition.Date, a.config.OutputDuration)
+		i, ok := groups[start.Unix()]
+		if !ok {
+			i = len(combined)
+			groups[start.Unix()] = i
+			combined = append(combined, PartitionInfo{TableName: a.config.Table, Date: start})
+		}
+		group := &combined[i]
+		group.Members = append(group.Members, partition.TableName)
+		memberStart, memberEnd := a.partitionPeriodRange(partition)
+		covered[i] = append(covered[i], [2]time.Time{memberStart, memberEnd})
+		if group.RowCount >= 0 {
+			if partition.RowCount < 0 {
+				group.RowCount = -1
+			} else {
+				group.RowCount += partition.RowCount
+			}
+		}
+	}
+
+	for i, spans := range covered {
+		sort.Slice(spans, func(x, y int) bool { return spans[x][0].Before(spans[y][0]) })
+		group := &combined[i]
+		group.RangeStart, group.RangeEnd = spans[0][0], spans[0][1]
+		for _, span := range spans[1:] {
+			if span[0].After(group.RangeEnd) {
+				return nil, fmt.Errorf("%w: no partition holds %s to %s of the %s file starting %s; archive that period with a shorter --output-duration",
+					ErrOutputDurationGap, group.RangeEnd.Format("2006-01-02"), span[0].Format("2006-01-02"), a.config.OutputDuration, group.Date.Format("2006-01-02"))
+			}
+			if span[1].After(group.RangeEnd) {
+				group.RangeEnd = span[1]
+			}
+		}
+	}
+
+	for _, group := range combined {
+		if len(group.Members) > 0 {
+			a.logger.Debug(fmt.Sprintf("  %s %s file from %s", group.Date.Format("2006-01-02"), a.config.OutputDuration, strings.Join(group.Members, ", ")))
+		}
+	}
+	if len(groups) > 0 {
+		a.logger.Info(fmt.Sprintf("🧩 Combining %d partitions into %d %s files through %s",
+			len(partitions)-len(combined)+len(groups), len(groups), a.config.OutputDuration, a.config.Table))
+	}
+	return combined, nil
+}
+
+// combinesPartitions reports whether aggregatePartitions combines any of the
+// partitions
+func (a *Archiver) combinesPartitions(partitions []PartitionInfo) bool {
+	output := durationSpan(a.config.OutputDuration)
+	for _, partition := range partitions {
+		if a.combinable(partition, output) {
+			return true
+		}
+	}
+	return false
+}
+
+// combinable reports whether a partition is shorter than the output span
+func (a *Archiver) combinable(partition PartitionInfo, output time.Duration) bool {
+	if partition.HasCustomRange() || partition.HasKeyRange() || partition.HasIncrement() {
+		return false
+	}
+	period := durationSpan(a.partitionPeriod(partition))
+	return period > 0 && period < output
+}
+
+// checkAggregation checks that partitions can be combined through the base
+// table: it exists and has a date column to select each period by
+func (a *Archiver) checkAggregation(ctx context.Context, sampleTable string) error {
+	schema, err := a.getTableSchema(ctx, a.config.Table)
+	if err != nil || schema == nil || len(schema.Columns) == 0 {
+		return fmt.Errorf("%w: base table %s not found", ErrOutputDurationAggregate, a.config.Table)
+	}
+	if err := a.ensureDateColumn(ctx, sampleTable); err != nil {
+		return fmt.Errorf("%w: %w", ErrOutputDurationAggregate, err)
+	}
+	return nil
+}
+
+// partitionPeriod returns the period a partition covers, judged from its name:
+// DurationDaily for _YYYYMMDD and _pYYYYMMDD, DurationMonthly for _YYYY_MM and
+// _YYYYMM, and "" for anything else
+func (a *Archiver) partitionPeriod(partition PartitionInfo) string {
+	suffix, ok := partitionSuffix(a.config.Table, partition.TableName)
+	if !ok {
+		return ""
+	}
+	switch {
+	case len(suffix) == 8 && isDigits(suffix):
+		return DurationDaily
+	case len(suffix) == 9 && suffix[0] == 'p' && isDigits(suffix[1:]):
+		return DurationDaily
+	case len(suffix) == 7 && suffix[4] == '_' && isDigits(suffix[:4]) && isDigits(suffix[5:]):
+		return DurationMonthly
+	case len(suffix) == 6 && isDigits(suffix):
+		return DurationMonthly
+	}
+	return ""
+}
+
+// isDigits reports whether s is made only of ASCII digits
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// inferOutputDuration returns the output duration for partitions of the given
+// periods. Daily partitions become one daily file each. Monthly partitions
+// are split into daily files when they can be sliced by a date column
+// (given or inferred), and otherwise kept as one monthly file each. Anything else, including an unpartitioned
+// table sliced by --date-column, gets daily files.
+func inferOutputDuration(periods []string, canSlice bool) string {
+	monthly := false
+	for _, period := range periods {
+		switch period {
+		case DurationDaily:
+			return DurationDaily
+		case DurationMonthly:
+			monthly = true
+		}
+	}
+	if monthly && !canSlice {
+		return DurationMonthly
+	}
+	return DurationDaily
+}
+
+// resolveOutputDuration replaces --output-duration auto with the duration
+// matching the discovered partitions. Explicit durations are left alone.
+func (a *Archiver) resolveOutputDuration(ctx context.Context, partitions []PartitionInfo) {
+	if a.config.OutputDuration != DurationAuto {
+		return
+	}
+	periods := make([]string, len(partitions))
+	for i, partition := range partitions {
+		periods[i] = a.partitionPeriod(partition)
+	}
+	canSlice := a.config.canSliceByTime()
+	if !canSlice && inferOutputDuration(periods, true) != inferOutputDuration(periods, false) {
+		// Daily files from monthly partitions need a date column; look for one
+		// before settling on monthly files
+		if err := a.ensureDateColumn(ctx, partitions[0].TableName); err != nil {
+			a.logger.Debug(fmt.Sprintf("No date column to split partitions by: %v", err))
+		}
+		canSlice = a.config.canSliceByTime()
+	}
+	a.config.OutputDuration = inferOutputDuration(periods, canSlice)
+
+	// Name the partition period when every partition shares one
+	period := ""
+	for i, p := range periods {
+		if i > 0 && p != period {
+			period = ""
+			break
+		}
+		period = p
+	}
+	if period != "" {
+		a.logger.Info(fmt.Sprintf("📅 Output duration auto: %s partitions → %s files", period, a.config.OutputDuration))
+	} else {
+		a.logger.Info(fmt.Sprintf("📅 Output duration auto: writing %s files", a.config.OutputDuration))
+	}
+	for _, warning := range a.config.pathTemplateWarnings() {
+		a.logger.Warn(fmt.Sprintf("⚠️  %s", warning))
+	}
+}
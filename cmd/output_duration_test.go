@@ -0,0 +1,341 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPartitionPeriod(t *testing.T) {
+	archiver := NewArchiver(&Config{Table: "flights"}, newTestLogger())
+	tests := map[string]string{
+		"flights_20240315":  DurationDaily,
+		"flights_p20240315": DurationDaily,
+		"flights_2024_03":   DurationMonthly,
+		"flights_202403":    DurationMonthly,
+		"flights_backup":    "",
+		"flights_2024_ab":   "",
+		"flights":           "",
+		"messages_20240315": "",
+	}
+	for name, want := range tests {
+		if got := archiver.partitionPeriod(PartitionInfo{TableName: name}); got != want {
+			t.Errorf("partitionPeriod(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestInferOutputDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		periods  []string
+		canSlice bool
+		want     string
+	}{
+		{"daily partitions", []string{DurationDaily, DurationDaily}, false, DurationDaily},
+		{"monthly partitions sliced by date column", []string{DurationMonthly}, true, DurationDaily},
+		{"monthly partitions without date column", []string{DurationMonthly, DurationMonthly}, false, DurationMonthly},
+		{"mixed partitions", []string{DurationMonthly, DurationDaily}, false, DurationDaily},
+		{"unrecognized partitions", []string{""}, false, DurationDaily},
+		{"unpartitioned table", nil, true, DurationDaily},
+	}
+	for _, tt := range tests {
+		if got := inferOutputDuration(tt.periods, tt.canSlice); got != tt.want {
+			t.Errorf("%s: inferOutputDuration() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolveOutputDuration(t *testing.T) {
+	monthly := []PartitionInfo{{TableName: "flights_2024_01"}, {TableName: "flights_2024_02"}}
+
+	ctx := context.Background()
+
+	// Epoch date columns are never inferred, so no date column is found
+	archiver := NewArchiver(&Config{Table: "flights", OutputDuration: DurationAuto, DateColumnType: DateColumnEpoch}, newTestLogger())
+	archiver.resolveOutputDuration(ctx, monthly)
+	if archiver.config.OutputDuration != DurationMonthly {
+		t.Errorf("without a date column got %q, want monthly", archiver.config.OutputDuration)
+	}
+
+	archiver = NewArchiver(&Config{Table: "flights", OutputDuration: DurationAuto, DateColumn: "created_at"}, newTestLogger())
+	archiver.resolveOutputDuration(ctx, monthly)
+	if archiver.config.OutputDuration != DurationDaily || !archiver.shouldSplitPartition(monthly[0]) {
+		t.Errorf("with a date column got %q, want daily files split from each partition", archiver.config.OutputDuration)
+	}
+
+	// Explicit durations are honored
+	archiver = NewArchiver(&Config{Table: "flights", OutputDuration: DurationWeekly}, newTestLogger())
+	archiver.resolveOutputDuration(ctx, monthly)
+	if archiver.config.OutputDuration != DurationWeekly {
+		t.Errorf("explicit duration changed to %q", archiver.config.OutputDuration)
+	}
+}
+
+func TestDoDiscoverResolvesOutputDuration(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	config := &Config{Table: "flights", OutputDuration: DurationAuto, DateColumn: "created_at"}
+	archiver := NewArchiver(config, newTestLogger())
+	archiver.db = db
+	m := &progressModel{config: config, archiver: archiver, log: newLogPane(10)}
+
+	// The TUI resolves auto the same way as plain discovery, so its monthly
+	// partitions are split into daily files
+	mock.ExpectQuery(`SELECT tablename`).WithArgs("public", "flights", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename"}).AddRow("flights_2024_01"))
+	mock.ExpectQuery(`has_table_privilege`).WithArgs("flights_2024_01").
+		WillReturnRows(sqlmock.NewRows([]string{"has_table_privilege"}).AddRow(true))
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("flights_2024_01").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).AddRow("created_at", "date", "date"))
+
+	msg, ok := m.doDiscover()().(discoveredTablesMsg)
+	if !ok || len(msg.tables) != 1 {
+		t.Fatalf("expected one discovered table, got %#v", msg)
+	}
+	if config.OutputDuration != DurationDaily {
+		t.Errorf("expected auto to resolve to daily, got %q", config.OutputDuration)
+	}
+	if !archiver.shouldSplitPartition(PartitionInfo{TableName: msg.tables[0].name, Date: msg.tables[0].date}) {
+		t.Error("expected the monthly partition to be split")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestCountNextTableAggregatesPartitions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	config := &Config{Table: "events", OutputDuration: DurationWeekly, DateColumn: "created_at", StartFromDate: "2024-01-14"}
+	archiver := NewArchiver(config, newTestLogger())
+	archiver.db = db
+	m := &progressModel{config: config, archiver: archiver, log: newLogPane(10)}
+	for _, name := range []string{"events_20240113", "events_20240114", "events_20240115"} {
+		date, _ := archiver.extractDateFromTableName(name)
+		m.countedPartitions = append(m.countedPartitions, PartitionInfo{TableName: name, Date: date, RowCount: 10})
+	}
+
+	// Once every table is counted, daily partitions become weekly files, and
+	// --start-from-date keeps the whole week it falls in
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).AddRow("created_at", "timestamp with time zone", "timestamptz"))
+	msg, ok := m.countNextTable()().(partitionsFoundMsg)
+	if !ok {
+		t.Fatalf("expected partitionsFoundMsg, got %#v", msg)
+	}
+	if len(msg.partitions) != 2 || !reflect.DeepEqual(msg.partitions[0].Members, []string{"events_20240113", "events_20240114"}) ||
+		!reflect.DeepEqual(msg.partitions[1].Members, []string{"events_20240115"}) {
+		t.Errorf("expected two weekly files, got %+v", msg.partitions)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestValidateOutputDurationAuto(t *testing.T) {
+	config := newTestConfig()
+	config.OutputDuration = DurationAuto
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate() with auto = %v", err)
+	}
+	if warnings := config.pathTemplateWarnings(); warnings != nil {
+		t.Errorf("auto duration should not be checked before it is resolved: %v", warnings)
+	}
+}
+
+func TestOutputIntervals(t *testing.T) {
+	tests := []struct {
+		duration string
+		want     error
+	}{
+		{"6h", nil},
+		{"1h", nil},
+		{"3d", nil},
+		{"31d", nil},
+		{"5h", ErrOutputIntervalInvalid},
+		{"24h", ErrOutputIntervalInvalid},
+		{"0d", ErrOutputIntervalInvalid},
+		{"32d", ErrOutputIntervalInvalid},
+		{"6w", ErrOutputDurationInvalid},
+		{"fortnightly", ErrOutputDurationInvalid},
+	}
+	for _, tt := range tests {
+		config := &Config{OutputDuration: tt.duration}
+		if err := config.validateOutputDuration(); !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+			t.Errorf("validateOutputDuration(%s) = %v, want %v", tt.duration, err, tt.want)
+		}
+	}
+
+	// Hour intervals restart at midnight and day intervals on the 1st
+	day := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	ranges := SplitPartitionByDuration(day, day.AddDate(0, 0, 1), "6h")
+	if len(ranges) != 4 || ranges[1].Start.Hour() != 6 || ranges[3].End != day.AddDate(0, 0, 1) {
+		t.Errorf("6h slices = %v", ranges)
+	}
+	month := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	ranges = SplitPartitionByDuration(month, month.AddDate(0, 1, 0), "10d")
+	if len(ranges) != 3 || ranges[2].Start.Day() != 21 || ranges[2].End != month.AddDate(0, 1, 0) {
+		t.Errorf("10d slices = %v", ranges)
+	}
+	if start, end := GetTimeRangeForDuration(time.Date(2024, 1, 18, 9, 0, 0, 0, time.UTC), "3d"); start.Day() != 16 || end.Day() != 19 {
+		t.Errorf("3d range = %s to %s", start, end)
+	}
+
+	if got := GenerateFilename("events", ranges[1].Start.Add(6*time.Hour), "6h", ".jsonl", ""); got != "events-2024-02-11-06.jsonl" {
+		t.Errorf("GenerateFilename(6h) = %s", got)
+	}
+	if got := GenerateFilename("events", ranges[1].Start, "10d", ".jsonl", ""); got != "events-2024-02-11.jsonl" {
+		t.Errorf("GenerateFilename(10d) = %s", got)
+	}
+}
+
+func TestShouldSplitPartitionByPeriod(t *testing.T) {
+	daily := PartitionInfo{TableName: "events_20240115"}
+	monthly := PartitionInfo{TableName: "events_2024_01"}
+	tests := []struct {
+		duration  string
+		partition PartitionInfo
+		want      bool
+	}{
+		{DurationHourly, daily, true},
+		{"6h", daily, true},
+		{DurationDaily, daily, false},
+		{DurationWeekly, daily, false},
+		{DurationWeekly, monthly, true},
+		{"10d", monthly, true},
+		{DurationMonthly, monthly, false},
+		{DurationQuarterly, monthly, false},
+	}
+	for _, tt := range tests {
+		archiver := NewArchiver(&Config{Table: "events", OutputDuration: tt.duration}, newTestLogger())
+		if got := archiver.shouldSplitPartition(tt.partition); got != tt.want {
+			t.Errorf("shouldSplitPartition(%s, %s) = %v, want %v", tt.partition.TableName, tt.duration, got, tt.want)
+		}
+	}
+
+	archiver := NewArchiver(&Config{Table: "events", OutputDuration: DurationHourly}, newTestLogger())
+	date, _ := archiver.extractDateFromTableName(daily.TableName)
+	daily.Date = date
+	if start, end := archiver.partitionPeriodRange(daily); !start.Equal(date) || end.Sub(start) != 24*time.Hour {
+		t.Errorf("partitionPeriodRange() = %s to %s", start, end)
+	}
+}
+
+func TestAggregatePartitions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	archiver := NewArchiver(&Config{Table: "events", OutputDuration: DurationWeekly, DateColumn: "created_at"}, newTestLogger())
+	archiver.db = db
+	var partitions []PartitionInfo
+	for _, name := range []string{"events_20240113", "events_20240114", "events_20240115", "events_20240116"} {
+		date, _ := archiver.extractDateFromTableName(name)
+		partitions = append(partitions, PartitionInfo{TableName: name, Date: date, RowCount: 10})
+	}
+	partitions = append(partitions, PartitionInfo{TableName: "events_2024_02", Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), RowCount: 5})
+
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).AddRow("created_at", "timestamp with time zone", "timestamptz"))
+	combined, err := archiver.aggregatePartitions(context.Background(), partitions)
+	if err != nil {
+		t.Fatalf("aggregatePartitions() error = %v", err)
+	}
+
+	// Saturday and Sunday close ISO week 2; Monday and Tuesday open week 3.
+	// Each week reads only the days its partitions hold. The monthly
+	// partition is longer than a week and is split instead.
+	if len(combined) != 3 {
+		t.Fatalf("aggregatePartitions() = %+v", combined)
+	}
+	week := combined[0]
+	if week.TableName != "events" || !week.RangeStart.Equal(time.Date(2024, 1, 13, 0, 0, 0, 0, time.UTC)) ||
+		!week.RangeEnd.Equal(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)) || week.RowCount != 20 ||
+		!reflect.DeepEqual(week.Members, []string{"events_20240113", "events_20240114"}) {
+		t.Errorf("first week = %+v", week)
+	}
+	if len(combined[1].Members) != 2 || combined[2].TableName != "events_2024_02" {
+		t.Errorf("combined = %+v", combined)
+	}
+	if !archiver.shouldSplitPartition(week) || !archiver.shouldSplitPartition(combined[2]) {
+		t.Error("combined weeks and the monthly partition should be sliced by week")
+	}
+
+	if next := combined[1]; !next.Date.Equal(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)) ||
+		!next.RangeEnd.Equal(time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("second week = %+v", next)
+	}
+
+	// Without a base table the partitions can't be combined
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}))
+	mock.ExpectQuery(`pg_attribute`).WillReturnError(errors.New("no such table"))
+	if _, err := archiver.aggregatePartitions(context.Background(), partitions[:2]); !errors.Is(err, ErrOutputDurationAggregate) {
+		t.Errorf("expected ErrOutputDurationAggregate, got %v", err)
+	}
+}
+
+func TestAggregatePartitionsMidWeekStart(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	config := &Config{Table: "events", OutputDuration: DurationWeekly, DateColumn: "created_at", StartDate: "2024-01-17"}
+	archiver := NewArchiver(config, newTestLogger())
+	archiver.db = db
+	var partitions []PartitionInfo
+	for _, name := range []string{"events_20240117", "events_20240118", "events_20240119", "events_20240120", "events_20240121"} {
+		date, _ := archiver.extractDateFromTableName(name)
+		partitions = append(partitions, PartitionInfo{TableName: name, Date: date, RowCount: 10})
+	}
+
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).AddRow("created_at", "timestamp with time zone", "timestamptz"))
+	combined, err := archiver.aggregatePartitions(context.Background(), partitions)
+	if err != nil {
+		t.Fatalf("aggregatePartitions() error = %v", err)
+	}
+
+	// Monday and Tuesday are before --start-date, so the week is read from
+	// Wednesday and holds just the rows of the partitions it combines
+	if len(combined) != 1 {
+		t.Fatalf("aggregatePartitions() = %+v", combined)
+	}
+	week := combined[0]
+	if !week.RangeStart.Equal(time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC)) ||
+		!week.RangeEnd.Equal(time.Date(2024, 1, 22, 0, 0, 0, 0, time.UTC)) || week.RowCount != 50 {
+		t.Errorf("week = %+v", week)
+	}
+	units := archiver.outputUnits(combined)
+	if len(units) != 1 || !units[0].Start.Equal(week.RangeStart) || !units[0].End.Equal(week.RangeEnd) {
+		t.Errorf("outputUnits() = %+v", units)
+	}
+	if name := GenerateFilename("events", units[0].Start, DurationWeekly, ".jsonl", ""); name != "events-2024-W03.jsonl" {
+		t.Errorf("filename = %s", name)
+	}
+
+	// A partition missing mid-week can't be left out of a base table read
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).AddRow("created_at", "timestamp with time zone", "timestamptz"))
+	gapped := append(append([]PartitionInfo{}, partitions[:2]...), partitions[3:]...)
+	if _, err := archiver.aggregatePartitions(context.Background(), gapped); !errors.Is(err, ErrOutputDurationGap) {
+		t.Errorf("expected ErrOutputDurationGap, got %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Progress display modes (--progress)
+const (
+	ProgressTUI   = "tui"   // Full-screen progress display (the default)
+	ProgressPlain = "plain" // One line per percent of progress, for CI logs
+	ProgressNone  = "none"  // No progress display; logs only
+)
+
+// Static errors for output modes
+var (
+	ErrProgressModeInvalid = errors.New("progress must be tui, plain, or none")
+	ErrQuietWithDebug      = errors.New("--quiet and --debug cannot be used together")
+)
+
+// summaryAttr marks log records that belong to a command's final summary,
+// which --quiet still shows
+const summaryAttr = "summary"
+
+var (
+	quietLevel   int
+	progressMode string
+)
+
+func init() {
+	rootCmd.PersistentFlags().CountVarP(&quietLevel, "quiet", "q", "print only warnings, errors and the final summary; -qq prints nothing (check the exit code)")
+	rootCmd.PersistentFlags().StringVar(&progressMode, "progress", ProgressTUI, "progress display: tui, plain (single-line percentage updates for CI logs), or none")
+	_ = viper.BindPFlag("quiet", rootCmd.PersistentFlags().Lookup("quiet"))
+	_ = viper.BindPFlag("progress", rootCmd.PersistentFlags().Lookup("progress"))
+}
+
+// validateOutputMode checks the --quiet and --progress settings
+func validateOutputMode(quiet int, progress string, debug bool) error {
+	switch progress {
+	case "", ProgressTUI, ProgressPlain, ProgressNone:
+	default:
+		return fmt.Errorf("%w, got %q", ErrProgressModeInvalid, progress)
+	}
+	if quiet > 0 && debug {
+		return ErrQuietWithDebug
+	}
+	return nil
+}
+
+// useTUI reports whether the archive run shows the full-screen progress
+// display. Debug logging, --quiet and the other progress modes all run
+// without it.
+func (c *Config) useTUI() bool {
+	return !c.Debug && c.Quiet == 0 && (c.Progress == "" || c.Progress == ProgressTUI)
+}
+
+// quietHandler drops log records below warnings with -q, keeping those of
+// the final summary, and every record with -qq
+type quietHandler struct {
+	handler slog.Handler
+	level   int
+	summary bool // Records carry the summary attribute
+}
+
+func newQuietHandler(handler slog.Handler, level int) slog.Handler {
+	if level <= 0 {
+		return handler
+	}
+	return &quietHandler{handler: handler, level: level}
+}
+
+func (h *quietHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.level > 1 {
+		return false
+	}
+	if level < slog.LevelWarn && !h.summary {
+		return false
+	}
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *quietHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *quietHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	summary := h.summary
+	for _, attr := range attrs {
+		if attr.Key == summaryAttr {
+			summary = true
+		}
+	}
+	return &quietHandler{handler: h.handler.WithAttrs(attrs), level: h.level, summary: summary}
+}
+
+func (h *quietHandler) WithGroup(name string) slog.Handler {
+	return &quietHandler{handler: h.handler.WithGroup(name), level: h.level, summary: h.summary}
+}
+
+// summaryLogger returns the logger for a command's final summary
+func summaryLogger(l *slog.Logger) *slog.Logger {
+	return l.With(summaryAttr, true)
+}
+
+// plainProgress prints a run's progress as single lines, one for each
+// percent it advances, instead of the TUI. Partitions in progress count by
+// the share of their current stage that's done, so long partitions still
+// move the percentage; it never goes backwards.
+type plainProgress struct {
+	mu      sync.Mutex
+	out     io.Writer
+	table   string
+	total   int                // Partitions planned
+	done    int                // Partitions finished, skipped or failed
+	active  map[string]float64 // Share done of each partition in progress
+	rows    int64
+	bytes   int64
+	printed int // Last percentage printed (-1 = none yet)
+	now     func() time.Time
+}
+
+func newPlainProgress(out io.Writer, table string) *plainProgress {
+	return &plainProgress{out: out, table: table, active: make(map[string]float64), printed: -1, now: time.Now}
+}
+
+// setTotal sets the number of partitions the run processes
+func (p *plainProgress) setTotal(total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total = total
+	p.report()
+}
+
+// observe updates the progress from an archive progress event
+func (p *plainProgress) observe(event progressEvent) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch event.Type {
+	case progressEventPartitionStart:
+		p.active[event.Partition] = 0
+	case progressEventProgress:
+		if _, ok := p.active[event.Partition]; ok && event.Total > 0 {
+			p.active[event.Partition] = min(float64(event.Current)/float64(event.Total), 1)
+		}
+	case progressEventPartitionComplete:
+		delete(p.active, event.Partition)
+		p.done++
+		p.rows += event.Rows
+		p.bytes += event.Bytes
+	case progressEventPhase:
+		if event.Phase == PhaseComplete.String() {
+			p.done = max(p.done, p.total)
+		}
+	default:
+		return
+	}
+	p.report()
+}
+
+// report prints a line when the percentage has advanced
+func (p *plainProgress) report() {
+	if p.total <= 0 {
+		return
+	}
+	share := float64(p.done)
+	for _, partial := range p.active {
+		share += partial
+	}
+	percent := min(int(share/float64(p.total)*100), 100)
+	if percent <= p.printed {
+		return
+	}
+	p.printed = percent
+	fmt.Fprintf(p.out, "%s [%s] %3d%% (%d/%d partitions, %s rows, %s)\n",
+		p.now().Format("2006-01-02 15:04:05"), p.table, percent, min(p.done, p.total), p.total,
+		formatNumberForSummary(p.rows), formatBytesForSummary(p.bytes))
+}
+
+// newArchivePlainProgress returns the progress printer of an archive run, or
+// nil unless --progress plain is set. Lines go to stderr so they never mix
+// with data archived to stdout.
+func newArchivePlainProgress(config *Config) *plainProgress {
+	if config.Progress != ProgressPlain {
+		return nil
+	}
+	return newPlainProgress(os.Stderr, config.Table)
+}
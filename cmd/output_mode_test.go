@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateOutputMode(t *testing.T) {
+	for _, progress := range []string{"", ProgressTUI, ProgressPlain, ProgressNone} {
+		if err := validateOutputMode(1, progress, false); err != nil {
+			t.Errorf("validateOutputMode(%q) = %v", progress, err)
+		}
+	}
+	if err := validateOutputMode(0, "bar", false); !errors.Is(err, ErrProgressModeInvalid) {
+		t.Errorf("expected ErrProgressModeInvalid, got %v", err)
+	}
+	if err := validateOutputMode(1, ProgressTUI, true); !errors.Is(err, ErrQuietWithDebug) {
+		t.Errorf("expected ErrQuietWithDebug, got %v", err)
+	}
+
+	tests := []struct {
+		config Config
+		want   bool
+	}{
+		{Config{}, true},
+		{Config{Progress: ProgressTUI}, true},
+		{Config{Progress: ProgressPlain}, false},
+		{Config{Progress: ProgressNone}, false},
+		{Config{Quiet: 1}, false},
+		{Config{Debug: true}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.config.useTUI(); got != tt.want {
+			t.Errorf("useTUI(%+v) = %v, want %v", tt.config, got, tt.want)
+		}
+	}
+}
+
+func TestQuietHandler(t *testing.T) {
+	log := func(level int) string {
+		var out bytes.Buffer
+		logger := slog.New(newQuietHandler(newTextOnlyHandler(&out, nil), level))
+		logger.Info("working")
+		logger.Warn("careful")
+		logger.Error("broken")
+		summaryLogger(logger).Info("summary")
+		return out.String()
+	}
+
+	for _, tt := range []struct {
+		level int
+		want  []string
+		not   []string
+	}{
+		{0, []string{"working", "careful", "broken", "summary"}, nil},
+		{1, []string{"careful", "broken", "summary"}, []string{"working"}},
+		{2, nil, []string{"working", "careful", "broken", "summary"}},
+	} {
+		got := log(tt.level)
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("level %d: output missing %q:\n%s", tt.level, want, got)
+			}
+		}
+		for _, not := range tt.not {
+			if strings.Contains(got, not) {
+				t.Errorf("level %d: output has %q:\n%s", tt.level, not, got)
+			}
+		}
+	}
+}
+
+func TestPlainProgress(t *testing.T) {
+	var out bytes.Buffer
+	progress := newPlainProgress(&out, "events")
+	progress.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	progress.observe(progressEvent{Type: progressEventPartitionStart, Partition: "events_1"}) // No total yet
+	progress.setTotal(4)
+	progress.observe(progressEvent{Type: progressEventProgress, Partition: "events_1", Current: 50, Total: 100})
+	progress.observe(progressEvent{Type: progressEventProgress, Partition: "events_1", Current: 10, Total: 100}) // A new stage starts over
+	progress.observe(progressEvent{Type: progressEventPartitionComplete, Partition: "events_1", Rows: 1500, Bytes: 2048})
+	progress.observe(progressEvent{Type: progressEventMessage, Message: "ignored"})
+	progress.observe(progressEvent{Type: progressEventPhase, Phase: PhaseComplete.String()})
+
+	want := []string{
+		"2024-01-02 03:04:05 [events]   0% (0/4 partitions, 0 rows, 0 B)",
+		"2024-01-02 03:04:05 [events]  12% (0/4 partitions, 0 rows, 0 B)",
+		"2024-01-02 03:04:05 [events]  25% (1/4 partitions, 1,500 rows, 2.0 KB)",
+		"2024-01-02 03:04:05 [events] 100% (4/4 partitions, 1,500 rows, 2.0 KB)",
+	}
+	if got := strings.Split(strings.TrimSpace(out.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("output:\n%s\nwant:\n%s", out.String(), strings.Join(want, "\n"))
+	}
+
+	var none *plainProgress
+	none.setTotal(1) // Without --progress plain nothing is printed
+	none.observe(progressEvent{Type: progressEventPartitionComplete})
+}
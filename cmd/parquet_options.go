@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/airframesio/data-archiver/cmd/formatters"
+	"github.com/spf13/viper"
+)
+
+// maxParquetPageSizeKB bounds --parquet-page-size, which is buffered in memory per column
+const maxParquetPageSizeKB = 1024 * 1024
+
+// Static errors for Parquet writer tuning
+var (
+	ErrParquetCodecInvalid        = errors.New("parquet compression must be one of: snappy, zstd, gzip, lz4, brotli, none")
+	ErrParquetRowGroupSizeInvalid = errors.New("parquet row group size must be >= 0")
+	ErrParquetPageSizeInvalid     = errors.New("parquet page size must be between 1 and 1048576 KB")
+	ErrParquetColumnInvalid       = errors.New("parquet column setting is invalid")
+)
+
+var (
+	parquetCompression       string
+	parquetColumnCompression map[string]string
+	parquetRowGroupSize      int64
+	parquetPageSize          int
+	parquetDictionary        bool
+	parquetDictionaryColumns string
+	parquetStatistics        bool
+)
+
+func init() {
+	archiveCmd.Flags().StringVar(&parquetCompression, "parquet-compression", formatters.DefaultParquetCompression, "codec of Parquet columns: snappy, zstd, gzip, lz4, brotli, none")
+	archiveCmd.Flags().StringToStringVar(&parquetColumnCompression, "parquet-column-compression", nil, "codec of individual Parquet columns as column=codec pairs (e.g. payload=zstd)")
+	archiveCmd.Flags().Int64Var(&parquetRowGroupSize, "parquet-row-group-size", 0, "most rows per Parquet row group (0 = one row group per file)")
+	archiveCmd.Flags().IntVar(&parquetPageSize, "parquet-page-size", 0, "KB of column values buffered per Parquet data page (0 = 256)")
+	archiveCmd.Flags().BoolVar(&parquetDictionary, "parquet-dictionary", false, "dictionary-encode Parquet string, json, uuid and bytea columns")
+	archiveCmd.Flags().StringVar(&parquetDictionaryColumns, "parquet-dictionary-columns", "", "comma-separated columns to dictionary-encode whatever their type")
+	archiveCmd.Flags().BoolVar(&parquetStatistics, "parquet-statistics", false, "also write min/max statistics into each Parquet data page header")
+	_ = viper.BindPFlag("formatters.parquet.compression", archiveCmd.Flags().Lookup("parquet-compression"))
+	_ = viper.BindPFlag("formatters.parquet.column_compression", archiveCmd.Flags().Lookup("parquet-column-compression"))
+	_ = viper.BindPFlag("formatters.parquet.row_group_size", archiveCmd.Flags().Lookup("parquet-row-group-size"))
+	_ = viper.BindPFlag("formatters.parquet.page_size", archiveCmd.Flags().Lookup("parquet-page-size"))
+	_ = viper.BindPFlag("formatters.parquet.dictionary", archiveCmd.Flags().Lookup("parquet-dictionary"))
+	_ = viper.BindPFlag("formatters.parquet.dictionary_columns", archiveCmd.Flags().Lookup("parquet-dictionary-columns"))
+	_ = viper.BindPFlag("formatters.parquet.statistics", archiveCmd.Flags().Lookup("parquet-statistics"))
+}
+
+// loadParquetOptions reads the formatters.parquet.* settings
+func loadParquetOptions() formatters.ParquetOptions {
+	var dictionaryColumns []string
+	for _, column := range strings.Split(viper.GetString("formatters.parquet.dictionary_columns"), ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			dictionaryColumns = append(dictionaryColumns, column)
+		}
+	}
+	return formatters.ParquetOptions{
+		Compression:       viper.GetString("formatters.parquet.compression"),
+		ColumnCompression: viper.GetStringMapString("formatters.parquet.column_compression"),
+		RowGroupSize:      viper.GetInt64("formatters.parquet.row_group_size"),
+		PageSize:          viper.GetInt("formatters.parquet.page_size") * 1024,
+		Dictionary:        viper.GetBool("formatters.parquet.dictionary"),
+		DictionaryColumns: dictionaryColumns,
+		Statistics:        viper.GetBool("formatters.parquet.statistics"),
+	}
+}
+
+// validateParquetOptions checks the Parquet codecs, sizes and column names
+func (c *Config) validateParquetOptions() error {
+	p := c.Parquet
+	if p.Compression != "" && !formatters.IsParquetCodec(p.Compression) {
+		return fmt.Errorf("%w, got '%s'", ErrParquetCodecInvalid, p.Compression)
+	}
+	for column, codec := range p.ColumnCompression {
+		if !validPostgreSQLIdentifier.MatchString(column) {
+			return fmt.Errorf("%w: '%s' is not a valid column name", ErrParquetColumnInvalid, column)
+		}
+		if !formatters.IsParquetCodec(codec) {
+			return fmt.Errorf("%w for column %s, got '%s'", ErrParquetCodecInvalid, column, codec)
+		}
+	}
+	for _, column := range p.DictionaryColumns {
+		if !validPostgreSQLIdentifier.MatchString(column) {
+			return fmt.Errorf("%w: '%s' is not a valid column name", ErrParquetColumnInvalid, column)
+		}
+	}
+	if p.RowGroupSize < 0 {
+		return fmt.Errorf("%w, got %d", ErrParquetRowGroupSizeInvalid, p.RowGroupSize)
+	}
+	if p.PageSize < 0 || p.PageSize > maxParquetPageSizeKB*1024 {
+		return fmt.Errorf("%w, got %d", ErrParquetPageSizeInvalid, p.PageSize/1024)
+	}
+	return nil
+}
+
+// streamingFormatter returns the formatter archive files are written with,
+// applying the Parquet settings to Parquet output
+func (a *Archiver) streamingFormatter() formatters.StreamingFormatter {
+	if a.config.OutputFormat == formatters.FormatParquet {
+		return formatters.NewParquetStreamingFormatterWithOptions(a.config.Parquet)
+	}
+	return formatters.GetStreamingFormatter(a.config.OutputFormat)
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/airframesio/data-archiver/cmd/formatters"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+func TestStreamingFormatterAppliesParquetOptions(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.OutputFormat = "parquet"
+	cfg.Parquet = formatters.ParquetOptions{
+		Compression:       "zstd",
+		ColumnCompression: map[string]string{"payload": "gzip"},
+		RowGroupSize:      100,
+		DictionaryColumns: []string{"id"},
+		Dictionary:        true,
+	}
+	archiver := NewArchiver(cfg, newTestLogger())
+
+	schema := &TableSchema{Columns: []ColumnInfo{
+		{Name: "id", UDTName: "int8"},
+		{Name: "callsign", UDTName: "text"},
+		{Name: "payload", UDTName: "jsonb"},
+		{Name: "altitude", UDTName: "float8"},
+	}}
+	var buffer bytes.Buffer
+	writer, err := archiver.streamingFormatter().NewWriter(&buffer, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := make([]map[string]interface{}, 250)
+	for i := range rows {
+		rows[i] = map[string]interface{}{"id": int64(i), "callsign": fmt.Sprintf("UAL%d", i%5), "payload": `{"a":1}`, "altitude": float64(i)}
+	}
+	if err := writer.WriteChunk(rows); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := parquet.OpenFile(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(file.Metadata().RowGroups); got != 3 {
+		t.Errorf("row groups = %d, want 3", got)
+	}
+
+	codecs := map[string]format.CompressionCodec{}
+	dictionaries := map[string]bool{}
+	for _, chunk := range file.Metadata().RowGroups[0].Columns {
+		name := chunk.MetaData.PathInSchema[0]
+		codecs[name] = chunk.MetaData.Codec
+		for _, encoding := range chunk.MetaData.Encoding {
+			if encoding == format.RLEDictionary {
+				dictionaries[name] = true
+			}
+		}
+	}
+	if codecs["id"] != format.Zstd || codecs["payload"] != format.Gzip {
+		t.Errorf("codecs = %v", codecs)
+	}
+	if !dictionaries["id"] || !dictionaries["callsign"] || dictionaries["altitude"] {
+		t.Errorf("dictionary-encoded columns = %v", dictionaries)
+	}
+
+	// Restore reads every row group
+	stream, err := NewRestorer(newTestConfig(), newTestLogger()).openFileRows(writeArchiveFile(t, buffer.Bytes(), "none"), "parquet", "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	restored := 0
+	for _, size := range chunkSizes(t, stream) {
+		restored += size
+	}
+	if restored != len(rows) {
+		t.Errorf("restored %d rows, want %d", restored, len(rows))
+	}
+}
+
+func TestValidateParquetOptions(t *testing.T) {
+	tests := []struct {
+		options formatters.ParquetOptions
+		want    error
+	}{
+		{formatters.ParquetOptions{}, nil},
+		{formatters.ParquetOptions{Compression: "zstd", ColumnCompression: map[string]string{"payload": "none"}, RowGroupSize: 100000, PageSize: 64 * 1024}, nil},
+		{formatters.ParquetOptions{Compression: "xz"}, ErrParquetCodecInvalid},
+		{formatters.ParquetOptions{ColumnCompression: map[string]string{"payload": "lzo"}}, ErrParquetCodecInvalid},
+		{formatters.ParquetOptions{ColumnCompression: map[string]string{"bad name": "zstd"}}, ErrParquetColumnInvalid},
+		{formatters.ParquetOptions{DictionaryColumns: []string{"a;b"}}, ErrParquetColumnInvalid},
+		{formatters.ParquetOptions{RowGroupSize: -1}, ErrParquetRowGroupSizeInvalid},
+		{formatters.ParquetOptions{PageSize: -1024}, ErrParquetPageSizeInvalid},
+	}
+	for _, tt := range tests {
+		cfg := &Config{Parquet: tt.options}
+		if err := cfg.validateParquetOptions(); !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+			t.Errorf("validateParquetOptions(%+v) = %v, want %v", tt.options, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrPartitionDateCheckColumn is returned when --check-partition-dates is set without a date column
+var ErrPartitionDateCheckColumn = errors.New("--check-partition-dates requires --date-column")
+
+// partitionDateCheck compares the values of the date column in a partition
+// with the time range its name implies. Rows outside that range mean the
+// partition bounds (or the data loaded into it) are wrong.
+type partitionDateCheck struct {
+	ExpectedStart time.Time
+	ExpectedEnd   time.Time
+	MinDate       time.Time // Zero when the partition is empty
+	MaxDate       time.Time
+	OutOfRange    int64 // Rows with a date outside [ExpectedStart, ExpectedEnd)
+}
+
+// Mismatch reports whether the partition holds rows outside its expected range
+func (c *partitionDateCheck) Mismatch() bool {
+	return c != nil && c.OutOfRange > 0
+}
+
+func (c *partitionDateCheck) String() string {
+	return fmt.Sprintf("%d rows outside %s to %s (data spans %s to %s)",
+		c.OutOfRange,
+		c.ExpectedStart.Format(time.RFC3339), c.ExpectedEnd.Format(time.RFC3339),
+		c.MinDate.Format(time.RFC3339), c.MaxDate.Format(time.RFC3339))
+}
+
+// checkPartitionDates reads the min/max of the date column in a partition and
+// counts the rows outside the range implied by its name. This scans the
+// partition once, so it is opt-in.
+func (a *Archiver) checkPartitionDates(ctx context.Context, partition PartitionInfo) (*partitionDateCheck, error) {
+	start, end := a.partitionTimeRange(partition)
+	spec := a.config.dateColumnSpec()
+	condition, args := spec.rangeCondition(start, end)
+	//nolint:gosec // G201: identifiers are quoted via pq.QuoteIdentifier
+	query := fmt.Sprintf(
+		"SELECT %s, %s, count(*) FILTER (WHERE NOT (%s)) FROM %s",
+		spec.aggregateExpr("min"), spec.aggregateExpr("max"), condition, pq.QuoteIdentifier(partition.TableName),
+	)
+
+	var minDate, maxDate interface{}
+	check := &partitionDateCheck{ExpectedStart: start, ExpectedEnd: end}
+	if err := a.db.QueryRowContext(ctx, query, args...).Scan(&minDate, &maxDate, &check.OutOfRange); err != nil {
+		return nil, fmt.Errorf("partition date check on %s failed: %w", partition.TableName, err)
+	}
+	check.MinDate = spec.boundTime(minDate)
+	check.MaxDate = spec.boundTime(maxDate)
+	return check, nil
+}
+
+// runPartitionDateCheck runs the optional partition date check and records
+// the outcome in the cache. Partitions without a date in their name are not
+// checked, and a failed check is logged rather than failing the partition.
+func (a *Archiver) runPartitionDateCheck(partition PartitionInfo) *partitionDateCheck {
+	if !a.config.CheckPartitionDates || partition.HasCustomRange() {
+		return nil
+	}
+
+	check, err := a.checkPartitionDates(a.ctx, partition)
+	if err != nil {
+		a.logger.Warn(fmt.Sprintf("⚠️  %v", err))
+		return nil
+	}
+	if check.Mismatch() {
+		a.logger.Warn(fmt.Sprintf("⚠️  Partition %s date mismatch: %s", partition.TableName, check))
+	}
+
+	if cache, err := loadPartitionCache(a.config.CacheScope); err == nil {
+		cache.setDateCheck(partition.TableName, check)
+		_ = cache.save(a.config.CacheScope)
+	}
+	return check
+}
+
+// setDateCheck records a partition date check on the partition's cache entry
+func (c *PartitionCache) setDateCheck(tablePartition string, check *partitionDateCheck) {
+	entry := c.Entries[tablePartition]
+	entry.DataMinDate = check.MinDate
+	entry.DataMaxDate = check.MaxDate
+	entry.OutOfRangeRows = check.OutOfRange
+	entry.DateCheckTime = time.Now()
+	c.Entries[tablePartition] = entry
+	c.markDirty(tablePartition)
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCheckPartitionDates(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	archiver := NewArchiver(&Config{Table: "flights", DateColumn: "created_at"}, newTestLogger())
+	archiver.db = db
+	day := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+	partition := PartitionInfo{TableName: "flights_20240105", Date: day}
+
+	mock.ExpectQuery(`SELECT min\("created_at"\), max\("created_at"\), count\(\*\) FILTER \(WHERE NOT \("created_at" >= \$1 AND "created_at" < \$2\)\) FROM "flights_20240105"`).
+		WithArgs(day, day.AddDate(0, 0, 1)).
+		WillReturnRows(sqlmock.NewRows([]string{"min", "max", "count"}).
+			AddRow(day.Add(-2*time.Hour), day.Add(23*time.Hour), int64(42)))
+
+	check, err := archiver.checkPartitionDates(context.Background(), partition)
+	if err != nil {
+		t.Fatalf("checkPartitionDates() error = %v", err)
+	}
+	if !check.Mismatch() || check.OutOfRange != 42 {
+		t.Errorf("expected 42 out-of-range rows, got %+v", check)
+	}
+	if !check.MinDate.Equal(day.Add(-2*time.Hour)) || !check.ExpectedEnd.Equal(day.AddDate(0, 0, 1)) {
+		t.Errorf("unexpected range: %+v", check)
+	}
+
+	// An empty partition has NULL min/max and nothing out of range
+	mock.ExpectQuery(`FROM "flights_20240105"`).
+		WillReturnRows(sqlmock.NewRows([]string{"min", "max", "count"}).AddRow(nil, nil, int64(0)))
+	if check, err = archiver.checkPartitionDates(context.Background(), partition); err != nil || check.Mismatch() || !check.MinDate.IsZero() {
+		t.Errorf("empty partition: check = %+v, err = %v", check, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestPartitionDateCheckRequiresDateColumn(t *testing.T) {
+	config := newTestConfig()
+	config.CheckPartitionDates = true
+	config.DateColumn = ""
+	if err := config.Validate(); !errors.Is(err, ErrPartitionDateCheckColumn) {
+		t.Errorf("Validate() = %v, want ErrPartitionDateCheckColumn", err)
+	}
+
+	config.DateColumn = "created_at"
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestPartitionDateCheckMismatch(t *testing.T) {
+	var check *partitionDateCheck
+	if check.Mismatch() {
+		t.Error("a nil check should not report a mismatch")
+	}
+	if (&partitionDateCheck{}).Mismatch() {
+		t.Error("a check without out-of-range rows should not report a mismatch")
+	}
+	if !(&partitionDateCheck{OutOfRange: 1}).Mismatch() {
+		t.Error("out-of-range rows should report a mismatch")
+	}
+}
+
+func TestResultsLogRecordsDateMismatches(t *testing.T) {
+	log, err := openResultsLog(t.TempDir(), "archive", currentRunID, newTestConfig(), time.Now())
+	if err != nil {
+		t.Fatalf("openResultsLog failed: %v", err)
+	}
+	day := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+	_ = log.appendResult(ProcessResult{
+		Partition: PartitionInfo{TableName: "flights_20240105", RowCount: 100},
+		DateCheck: &partitionDateCheck{ExpectedStart: day, ExpectedEnd: day.AddDate(0, 0, 1), MinDate: day.AddDate(0, 0, -1), MaxDate: day, OutOfRange: 7},
+	})
+	_ = log.appendResult(ProcessResult{
+		Partition: PartitionInfo{TableName: "flights_20240106", RowCount: 100},
+		DateCheck: &partitionDateCheck{MinDate: day.AddDate(0, 0, 1), MaxDate: day.AddDate(0, 0, 1)},
+	})
+	_ = log.close(runStatusFromError(nil), nil)
+
+	summary, err := readRunSummary(log.path, notRunning)
+	if err != nil {
+		t.Fatalf("readRunSummary failed: %v", err)
+	}
+	if len(summary.DateMismatches) != 1 || summary.DateMismatches[0] != "flights_20240105" {
+		t.Errorf("expected one date mismatch, got %v", summary.DateMismatches)
+	}
+}
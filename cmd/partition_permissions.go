@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ErrPartitionPermissionDenied is returned by --fail-on-permission-denied
+var ErrPartitionPermissionDenied = errors.New("no SELECT permission on partitions")
+
+// permissionDeniedReason is the skip reason recorded for unreadable partitions
+const permissionDeniedReason = "Permission denied (no SELECT privilege)"
+
+var failOnPermissionDenied bool
+
+func init() {
+	archiveCmd.Flags().BoolVar(&failOnPermissionDenied, "fail-on-permission-denied", false, "fail the run before archiving when any partition lacks SELECT permission instead of skipping it")
+	_ = viper.BindPFlag("fail_on_permission_denied", archiveCmd.Flags().Lookup("fail-on-permission-denied"))
+}
+
+// partitionReadable reports whether the current user can SELECT from table
+func (a *Archiver) partitionReadable(ctx context.Context, table string) (bool, error) {
+	var hasPermission bool
+	checkPermissionQuery := `SELECT has_table_privilege('public.' || quote_ident($1), 'SELECT')`
+	if err := a.db.QueryRowContext(ctx, checkPermissionQuery, table).Scan(&hasPermission); err != nil {
+		return false, err
+	}
+	return hasPermission, nil
+}
+
+// notePermissionDenied adds a partition the run cannot read to the
+// permission-denied list shown in the summary and results log
+func (a *Archiver) notePermissionDenied(partition PartitionInfo) {
+	a.permissionDenied = append(a.permissionDenied, partition)
+	a.logger.Warn(fmt.Sprintf("⚠️  Skipping %s: no SELECT permission", partition.TableName))
+}
+
+// permissionDeniedNames returns the unreadable partitions' table names
+func (a *Archiver) permissionDeniedNames() []string {
+	names := make([]string, len(a.permissionDenied))
+	for i, partition := range a.permissionDenied {
+		names[i] = partition.TableName
+	}
+	return names
+}
+
+// finishPermissionChecks records unreadable partitions noted since the last
+// call in the results log, and with --fail-on-permission-denied returns an
+// error naming them
+func (a *Archiver) finishPermissionChecks() error {
+	if len(a.permissionDenied) == a.permissionLogged {
+		return nil
+	}
+	if a.config.FailOnPermissionDenied {
+		return fmt.Errorf("%w: %s", ErrPartitionPermissionDenied, strings.Join(a.permissionDeniedNames()[a.permissionLogged:], ", "))
+	}
+	pending := a.permissionDenied[a.permissionLogged:]
+	a.permissionLogged = len(a.permissionDenied)
+	for _, partition := range pending {
+		a.recordResult(ProcessResult{
+			Partition:        partition,
+			Skipped:          true,
+			PermissionDenied: true,
+			SkipReason:       permissionDeniedReason,
+			Stage:            StageSkipped,
+		})
+	}
+	return nil
+}
+
+// printPermissionDenied lists the unreadable partitions in the run summary
+func (a *Archiver) printPermissionDenied() {
+	if len(a.permissionDenied) == 0 {
+		return
+	}
+	a.logger.Warn("")
+	a.logger.Warn(fmt.Sprintf("   Permission Denied (%d, not archived):", len(a.permissionDenied)))
+	a.logger.Warn("")
+	for _, name := range a.permissionDeniedNames() {
+		a.logger.Warn(fmt.Sprintf("   🔒 %s", name))
+	}
+	a.logger.Warn("   Grant SELECT on these partitions and rerun, or pass --fail-on-permission-denied to stop on them")
+}
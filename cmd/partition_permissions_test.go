@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// expectPartitionDiscovery mocks discovery of two daily partitions, the
+// second of which the user cannot read
+func expectPartitionDiscovery(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT tablename`).WithArgs("public", "events", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename"}).AddRow("events_20240101").AddRow("events_20240102"))
+	mock.ExpectQuery(regexp.QuoteMeta("has_table_privilege")).WithArgs("events_20240101").
+		WillReturnRows(sqlmock.NewRows([]string{"has_table_privilege"}).AddRow(true))
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events_20240101").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).AddRow("id", "bigint", "int8"))
+	mock.ExpectQuery(regexp.QuoteMeta("has_table_privilege")).WithArgs("events_20240102").
+		WillReturnRows(sqlmock.NewRows([]string{"has_table_privilege"}).AddRow(false))
+}
+
+func TestFindPartitionsPermissionDenied(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	archiver := NewArchiver(&Config{Table: "events"}, newTestLogger())
+	archiver.db = db
+	log, err := openResultsLog(t.TempDir(), "archive", currentRunID, archiver.config, time.Now())
+	if err != nil {
+		t.Fatalf("openResultsLog failed: %v", err)
+	}
+	archiver.results = log
+
+	expectPartitionDiscovery(mock)
+	partitions, err := archiver.findPartitions(context.Background())
+	if err != nil {
+		t.Fatalf("findPartitions() error = %v", err)
+	}
+	if len(partitions) != 1 || partitions[0].TableName != "events_20240101" {
+		t.Errorf("expected only the readable partition, got %+v", partitions)
+	}
+	if names := archiver.permissionDeniedNames(); len(names) != 1 || names[0] != "events_20240102" {
+		t.Errorf("expected events_20240102 to be denied, got %v", names)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+
+	// The denied partition is its own category in the run summary
+	if err := log.close(runStatusFromError(nil), nil); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	summary, err := readRunSummary(log.path, notRunning)
+	if err != nil {
+		t.Fatalf("readRunSummary failed: %v", err)
+	}
+	if len(summary.Denied) != 1 || summary.Denied[0] != "events_20240102" || summary.Skipped != 0 || summary.Processed() != 0 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+}
+
+func TestFindPartitionsFailOnPermissionDenied(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	archiver := NewArchiver(&Config{Table: "events", FailOnPermissionDenied: true}, newTestLogger())
+	archiver.db = db
+
+	expectPartitionDiscovery(mock)
+	if _, err := archiver.findPartitions(context.Background()); !errors.Is(err, ErrPartitionPermissionDenied) {
+		t.Fatalf("expected ErrPartitionPermissionDenied, got %v", err)
+	}
+}
@@ -15,7 +15,7 @@ WITH leaf_partitions AS (
 	WHERE parent_ns.nspname = $1
 		AND child_ns.nspname = $1
 		AND parent.relname = $2
-		AND child.relkind = 'r'
+		AND child.relkind::text = ANY($3::text[])
 		AND NOT EXISTS (
 			SELECT 1 FROM pg_inherits WHERE inhparent = child.oid
 		)
@@ -40,21 +40,33 @@ WHERE has_table_privilege(table_oid, 'SELECT')
 LIMIT 1;
 `
 
-// nonPartitionTableListSQL finds regular tables (not partitions) that match
-// the partition naming pattern. This is useful when tables follow partition
-// naming conventions but aren't actually PostgreSQL partitions.
+// nonPartitionTableListSQL finds relations outside any partition tree whose
+// names follow the partition naming pattern, limited to the relkinds in $3:
+// regular tables that aren't actually PostgreSQL partitions, materialized
+// views, and foreign tables.
 const nonPartitionTableListSQL = `
-SELECT t.tablename::text
-FROM pg_tables t
-	JOIN pg_class c ON c.relname = t.tablename
+SELECT c.relname::text
+FROM pg_class c
 	JOIN pg_namespace n ON n.oid = c.relnamespace
-WHERE t.schemaname = $1
-	AND n.nspname = $1
-	AND t.tablename LIKE $2 || '_%'
-	AND t.tablename != $2
-	AND c.relkind = 'r'
+WHERE n.nspname = $1
+	AND length(c.relname) > length($2) + 1
+	AND left(c.relname, length($2) + 1) = $2 || '_'
+	AND c.relkind::text = ANY($3::text[])
 	AND NOT EXISTS (
 		SELECT 1 FROM pg_inherits WHERE inhrelid = c.oid
 	)
-ORDER BY t.tablename;
+ORDER BY c.relname;
+`
+
+// relationExistsSQL reports whether relation $2 of one of the relkinds in $3
+// exists in schema $1
+const relationExistsSQL = `
+SELECT EXISTS (
+	SELECT 1
+	FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname = $1
+		AND c.relname = $2
+		AND c.relkind::text = ANY($3::text[])
+);
 `
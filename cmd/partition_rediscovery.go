@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ErrRediscoverIntervalInvalid is returned for a negative --rediscover-interval
+var ErrRediscoverIntervalInvalid = errors.New("rediscover interval must be >= 0")
+
+var rediscoverInterval time.Duration
+
+func init() {
+	archiveCmd.Flags().DurationVar(&rediscoverInterval, "rediscover-interval", 0, "during long runs, look for partitions created since discovery this often and once more before finishing (0 = discover once)")
+	_ = viper.BindPFlag("rediscover_interval", archiveCmd.Flags().Lookup("rediscover-interval"))
+}
+
+// partitionRediscovery tracks the tables a run has already discovered and when
+// it last looked for new partitions
+type partitionRediscovery struct {
+	interval time.Duration
+	last     time.Time
+	known    map[string]bool
+	now      func() time.Time
+}
+
+// newPartitionRediscovery starts tracking after the initial discovery, or
+// returns nil when rediscovery is off
+func (a *Archiver) newPartitionRediscovery(partitions []PartitionInfo) *partitionRediscovery {
+	if a.config.RediscoverInterval <= 0 {
+		return nil
+	}
+	known := make(map[string]bool, len(partitions)+len(a.permissionDenied))
+	for _, partition := range partitions {
+		known[partition.TableName] = true
+		for _, member := range partition.Members {
+			known[member] = true
+		}
+	}
+	for _, partition := range a.permissionDenied {
+		known[partition.TableName] = true
+	}
+	return &partitionRediscovery{interval: a.config.RediscoverInterval, last: time.Now(), known: known, now: time.Now}
+}
+
+// due reports whether the interval has passed since the last discovery
+func (d *partitionRediscovery) due() bool {
+	return d != nil && d.now().Sub(d.last) >= d.interval
+}
+
+// rangeOpen reports whether partitions for the date range could still appear
+// after since: the range has no end date, or its last day hadn't ended yet
+func (a *Archiver) rangeOpen(since time.Time) bool {
+	if a.config.EndDate == "" {
+		return true
+	}
+	end, err := a.parseDate(a.config.EndDate)
+	if err != nil {
+		return true
+	}
+	return since.Before(end.AddDate(0, 0, 1))
+}
+
+// inDateRange reports whether a partition date falls within the date range
+func (a *Archiver) inDateRange(date time.Time) bool {
+	if start, err := a.parseDate(a.config.StartDate); err == nil && date.Before(start) {
+		return false
+	}
+	if end, err := a.parseDate(a.config.EndDate); err == nil && date.After(end) {
+		return false
+	}
+	return true
+}
+
+// rediscoverPartitions returns partitions within the date range that were
+// created since the last discovery. Periodic passes run once the interval has
+// passed; the final pass, made when every known partition is processed, always
+// runs. Neither runs once the date range has closed. Listing errors are
+// logged rather than failing the run, which already has its partitions.
+func (a *Archiver) rediscoverPartitions(ctx context.Context, d *partitionRediscovery, final bool) ([]PartitionInfo, error) {
+	if d == nil || (!final && !d.due()) {
+		return nil, nil
+	}
+	since := d.last
+	d.last = d.now()
+	if !a.rangeOpen(since) {
+		return nil, nil
+	}
+
+	found, err := a.listPartitions(ctx, d.known)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		a.logger.Warn(fmt.Sprintf("⚠️  Failed to look for new partitions: %v", err))
+		return nil, nil
+	}
+	for _, partition := range a.permissionDenied {
+		d.known[partition.TableName] = true
+	}
+	if err := a.finishPermissionChecks(); err != nil {
+		return nil, err
+	}
+
+	// listPartitions only returns partitions within the date range
+	added := found
+	for _, partition := range added {
+		d.known[partition.TableName] = true
+	}
+	if len(added) == 0 {
+		a.logger.Debug("No new partitions since the last discovery")
+		return nil, nil
+	}
+
+	names := make([]string, len(added))
+	for i, partition := range added {
+		names[i] = partition.TableName
+	}
+	message := fmt.Sprintf("🔎 Found %d new partitions since the last discovery: %s", len(added), strings.Join(names, ", "))
+	a.logger.Info(message)
+	a.emitEvent(progressEvent{Type: progressEventMessage, Message: message})
+	return a.aggregatePartitions(ctx, added)
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRediscoverPartitions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	config := &Config{Table: "events", StartDate: "2024-01-01", EndDate: "2024-01-31", RediscoverInterval: time.Hour}
+	archiver := NewArchiver(config, newTestLogger())
+	archiver.db = db
+
+	expectPartitionDiscovery(mock)
+	partitions, err := archiver.findPartitions(context.Background())
+	if err != nil {
+		t.Fatalf("findPartitions() error = %v", err)
+	}
+	rediscovery := archiver.newPartitionRediscovery(partitions)
+	now := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	rediscovery.last = now
+	rediscovery.now = func() time.Time { return now }
+
+	// Not due yet, and not the final pass
+	if found, err := archiver.rediscoverPartitions(context.Background(), rediscovery, false); err != nil || found != nil {
+		t.Fatalf("expected no rediscovery before the interval, got %v, %v", found, err)
+	}
+
+	// Known tables, including the unreadable one, are not checked again
+	now = now.Add(time.Hour)
+	mock.ExpectQuery(`SELECT tablename`).WithArgs("public", "events", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename"}).
+			AddRow("events_20240101").AddRow("events_20240102").AddRow("events_20240131").AddRow("events_20240201"))
+	mock.ExpectQuery(regexp.QuoteMeta("has_table_privilege")).WithArgs("events_20240131").
+		WillReturnRows(sqlmock.NewRows([]string{"has_table_privilege"}).AddRow(true))
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events_20240131").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).AddRow("id", "bigint", "int8"))
+	found, err := archiver.rediscoverPartitions(context.Background(), rediscovery, false)
+	if err != nil {
+		t.Fatalf("rediscoverPartitions() error = %v", err)
+	}
+	if len(found) != 1 || found[0].TableName != "events_20240131" {
+		t.Errorf("expected only the new partition within the date range, got %+v", found)
+	}
+	if !rediscovery.known["events_20240201"] || len(archiver.permissionDenied) != 1 || archiver.permissionLogged != 1 {
+		t.Errorf("unexpected rediscovery state: known=%v denied=%v", rediscovery.known, archiver.permissionDenied)
+	}
+
+	// The final pass looks once more since the last look was within the range...
+	now = time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT tablename`).WithArgs("public", "events", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename"}).AddRow("events_20240101").AddRow("events_20240131"))
+	if found, err := archiver.rediscoverPartitions(context.Background(), rediscovery, true); err != nil || found != nil {
+		t.Fatalf("expected no new partitions, got %v, %v", found, err)
+	}
+	// ...but once the range ended before the last look, nothing is queried
+	if found, err := archiver.rediscoverPartitions(context.Background(), rediscovery, true); err != nil || found != nil {
+		t.Fatalf("expected no rediscovery after the range closed, got %v, %v", found, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRediscoverPartitionsFailOnPermissionDenied(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	archiver := NewArchiver(&Config{Table: "events", RediscoverInterval: time.Minute, FailOnPermissionDenied: true}, newTestLogger())
+	archiver.db = db
+	rediscovery := archiver.newPartitionRediscovery([]PartitionInfo{{TableName: "events_20240101"}})
+
+	mock.ExpectQuery(`SELECT tablename`).WithArgs("public", "events", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename"}).AddRow("events_20240101").AddRow("events_20240102"))
+	mock.ExpectQuery(regexp.QuoteMeta("has_table_privilege")).WithArgs("events_20240102").
+		WillReturnRows(sqlmock.NewRows([]string{"has_table_privilege"}).AddRow(false))
+	if _, err := archiver.rediscoverPartitions(context.Background(), rediscovery, true); !errors.Is(err, ErrPartitionPermissionDenied) {
+		t.Errorf("expected ErrPartitionPermissionDenied, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestNewPartitionRediscoveryDisabled(t *testing.T) {
+	archiver := NewArchiver(&Config{Table: "events"}, newTestLogger())
+	rediscovery := archiver.newPartitionRediscovery(nil)
+	if rediscovery != nil || rediscovery.due() {
+		t.Errorf("expected rediscovery to be off without an interval, got %+v", rediscovery)
+	}
+	config := newTestConfig()
+	config.RediscoverInterval = -time.Minute
+	if err := config.Validate(); !errors.Is(err, ErrRediscoverIntervalInvalid) {
+		t.Errorf("expected ErrRediscoverIntervalInvalid, got %v", err)
+	}
+}
+
+func TestRediscoverPartitionsIgnoresOutOfRange(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	config := &Config{Table: "events", StartDate: "2024-01-01", EndDate: "2024-01-31", RediscoverInterval: time.Minute}
+	archiver := NewArchiver(config, newTestLogger())
+	archiver.db = db
+	rediscovery := archiver.newPartitionRediscovery([]PartitionInfo{{TableName: "events_20240101"}})
+	now := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	rediscovery.last = now
+	rediscovery.now = func() time.Time { return now }
+
+	// A partition created past --end-date can never be archived by this run,
+	// so rediscovery remembers it without permission or schema queries, and
+	// neither reports it as new nor as unreadable on the next pass
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery(`SELECT tablename`).WithArgs("public", "events", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"tablename"}).AddRow("events_20240101").AddRow("events_20240201"))
+		found, err := archiver.rediscoverPartitions(context.Background(), rediscovery, true)
+		if err != nil || found != nil {
+			t.Fatalf("pass %d: expected no new partitions, got %v, %v", i+1, found, err)
+		}
+	}
+	if !rediscovery.known["events_20240201"] || len(archiver.permissionDenied) != 0 {
+		t.Errorf("unexpected rediscovery state: known=%v denied=%v", rediscovery.known, archiver.permissionDenied)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestListPartitionsFiltersByDateFirst(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	config := &Config{Table: "events", StartDate: "2024-01-02", EndDate: "2024-01-02"}
+	archiver := NewArchiver(config, newTestLogger())
+	archiver.db = db
+
+	// Only the partition within the range gets permission and schema checks;
+	// the unreadable one outside it is not reported
+	mock.ExpectQuery(`SELECT tablename`).WithArgs("public", "events", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename"}).
+			AddRow("events_20231231").AddRow("events_20240102").AddRow("events_20240103"))
+	mock.ExpectQuery(regexp.QuoteMeta("has_table_privilege")).WithArgs("events_20240102").
+		WillReturnRows(sqlmock.NewRows([]string{"has_table_privilege"}).AddRow(true))
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events_20240102").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).AddRow("id", "bigint", "int8"))
+
+	partitions, err := archiver.listPartitions(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("listPartitions() error = %v", err)
+	}
+	if len(partitions) != 1 || partitions[0].TableName != "events_20240102" {
+		t.Errorf("expected only events_20240102, got %+v", partitions)
+	}
+	if len(archiver.permissionDenied) != 0 {
+		t.Errorf("unexpected permission denied partitions: %+v", archiver.permissionDenied)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
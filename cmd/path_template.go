@@ -2,22 +2,26 @@ package cmd
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 )
 
 // Duration constants for output splitting
 const (
-	DurationHourly  = "hourly"
-	DurationDaily   = "daily"
-	DurationWeekly  = "weekly"
-	DurationMonthly = "monthly"
-	DurationYearly  = "yearly"
+	DurationHourly    = "hourly"
+	DurationDaily     = "daily"
+	DurationWeekly    = "weekly"
+	DurationMonthly   = "monthly"
+	DurationQuarterly = "quarterly"
+	DurationYearly    = "yearly"
 )
 
 // PathTemplate provides functionality to generate S3 paths from templates
 type PathTemplate struct {
-	template string
+	template    string
+	format      string
+	compression string
 }
 
 // NewPathTemplate creates a new PathTemplate instance
@@ -25,28 +29,122 @@ func NewPathTemplate(template string) *PathTemplate {
 	return &PathTemplate{template: template}
 }
 
+// WithOutput sets the values of the {format} and {compression} placeholders
+func (pt *PathTemplate) WithOutput(format, compression string) *PathTemplate {
+	pt.format = format
+	pt.compression = compression
+	return pt
+}
+
 // Generate replaces placeholders in the template with actual values
-// Supports: {table}, {YYYY}, {MM}, {DD}, {HH}
+// Supports: {table}, {format}, {compression}, {YYYY}, {MM}, {DD}, {HH}, {WW},
+// {Q} and the lowercase date aliases {yyyy}, {mm}, {dd}, {hh}, {ww}, {q}
 func (pt *PathTemplate) Generate(tableName string, timestamp time.Time) string {
 	result := pt.template
 
 	// Replace table placeholder
-	result = strings.ReplaceAll(result, "{table}", tableName)
+	result = strings.ReplaceAll(result, "{table}", objectKeyComponent(tableName))
+	result = strings.ReplaceAll(result, "{format}", objectKeyComponent(pt.format))
+	result = strings.ReplaceAll(result, "{compression}", objectKeyComponent(pt.compression))
+
+	return renderDatePlaceholders(result, timestamp)
+}
 
-	// Replace date/time placeholders
-	result = strings.ReplaceAll(result, "{YYYY}", timestamp.Format("2006"))
+// datePlaceholderAliases maps the lowercase spellings of the date
+// placeholders, as used in Hive-style segments like dt={yyyy}-{mm}-{dd}, to
+// the placeholders they stand for
+var datePlaceholderAliases = [][2]string{
+	{"{yyyy}", "{YYYY}"},
+	{"{mm}", "{MM}"},
+	{"{dd}", "{DD}"},
+	{"{hh}", "{HH}"},
+	{"{ww}", "{WW}"},
+	{"{q}", "{Q}"},
+}
+
+// outputPlaceholders are the placeholders filled from the output format and
+// compression of the archive
+var outputPlaceholders = []string{"{format}", "{compression}"}
+
+// canonicalDatePlaceholders replaces the lowercase date aliases of a template
+// with the placeholders they stand for
+func canonicalDatePlaceholders(template string) string {
+	for _, alias := range datePlaceholderAliases {
+		template = strings.ReplaceAll(template, alias[0], alias[1])
+	}
+	return template
+}
+
+// renderDatePlaceholders fills in the date placeholders of a template. {WW}
+// is the ISO week, and a template using it gets the ISO week's year for
+// {YYYY}, so the days around New Year share one name with their week.
+func renderDatePlaceholders(template string, timestamp time.Time) string {
+	template = canonicalDatePlaceholders(template)
+	year, week := timestamp.ISOWeek()
+	if !strings.Contains(template, "{WW}") {
+		year = timestamp.Year()
+	}
+	result := strings.ReplaceAll(template, "{YYYY}", fmt.Sprintf("%04d", year))
 	result = strings.ReplaceAll(result, "{MM}", timestamp.Format("01"))
 	result = strings.ReplaceAll(result, "{DD}", timestamp.Format("02"))
 	result = strings.ReplaceAll(result, "{HH}", timestamp.Format("15"))
-
+	result = strings.ReplaceAll(result, "{WW}", fmt.Sprintf("%02d", week))
+	result = strings.ReplaceAll(result, "{Q}", fmt.Sprintf("%d", quarterOf(timestamp)))
 	return result
 }
 
+// templateDatePattern replaces the date and output placeholders of a
+// regexp-quoted template with patterns matching the values they render
+func templateDatePattern(quoted string) string {
+	for _, alias := range datePlaceholderAliases {
+		quoted = strings.ReplaceAll(quoted, regexp.QuoteMeta(alias[0]), regexp.QuoteMeta(alias[1]))
+	}
+	for _, placeholder := range outputPlaceholders {
+		quoted = strings.ReplaceAll(quoted, regexp.QuoteMeta(placeholder), `[^/]+`)
+	}
+	quoted = strings.ReplaceAll(quoted, regexp.QuoteMeta("{YYYY}"), `\d{4}`)
+	for _, placeholder := range []string{"{MM}", "{DD}", "{HH}", "{WW}"} {
+		quoted = strings.ReplaceAll(quoted, regexp.QuoteMeta(placeholder), `\d{2}`)
+	}
+	return strings.ReplaceAll(quoted, regexp.QuoteMeta("{Q}"), `[1-4]`)
+}
+
+// quarterOf returns the quarter (1-4) of the year timestamp falls in
+func quarterOf(timestamp time.Time) int {
+	return (int(timestamp.Month())-1)/3 + 1
+}
+
+// isoWeekStart returns the Monday starting an ISO week: the week holding
+// January 4th is week 1
+func isoWeekStart(year, week int, loc *time.Location) time.Time {
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, loc)
+	return jan4.AddDate(0, 0, -((int(jan4.Weekday())+6)%7)+(week-1)*7)
+}
+
+// stripDatePlaceholders removes the date and output placeholders and their
+// surrounding slashes from a path template, for files that are not tied to a
+// date or an output format
+func stripDatePlaceholders(template string) string {
+	template = canonicalDatePlaceholders(template)
+	for _, placeholder := range append([]string{"{YYYY}", "{MM}", "{DD}", "{HH}", "{WW}", "{Q}"}, outputPlaceholders...) {
+		template = strings.ReplaceAll(template, "/"+placeholder, "")
+		template = strings.ReplaceAll(template, placeholder+"/", "")
+		template = strings.ReplaceAll(template, placeholder, "")
+	}
+	// Clean up any double slashes
+	for strings.Contains(template, "//") {
+		template = strings.ReplaceAll(template, "//", "/")
+	}
+	return strings.TrimSuffix(template, "/")
+}
+
 // GenerateFilename creates a filename based on duration and timestamp
 func GenerateFilename(tableName string, timestamp time.Time, duration string, formatExt string, compressionExt string) string {
 	var basename string
+	tableName = objectKeyComponent(tableName)
 
-	switch duration {
+	// Custom intervals are named by their start hour or day
+	switch keyedDuration(duration) {
 	case DurationHourly:
 		basename = fmt.Sprintf("%s-%s", tableName, timestamp.Format("2006-01-02-15"))
 	case DurationDaily:
@@ -57,6 +155,9 @@ func GenerateFilename(tableName string, timestamp time.Time, duration string, fo
 		basename = fmt.Sprintf("%s-%04d-W%02d", tableName, year, week)
 	case DurationMonthly:
 		basename = fmt.Sprintf("%s-%s", tableName, timestamp.Format("2006-01"))
+	case DurationQuarterly:
+		// Quarter format: YYYY-Qn (e.g., 2024-Q1)
+		basename = fmt.Sprintf("%s-%04d-Q%d", tableName, timestamp.Year(), quarterOf(timestamp))
 	case DurationYearly:
 		basename = fmt.Sprintf("%s-%s", tableName, timestamp.Format("2006"))
 	default:
@@ -75,20 +176,25 @@ func GenerateFilename(tableName string, timestamp time.Time, duration string, fo
 	return filename
 }
 
-// GetTimeRangeForDuration returns the start and end time for a given duration
+// GetTimeRangeForDuration returns the start and end time for a given duration.
+// Periods follow the wall clock of baseTime's zone: across a DST transition a
+// day lasts 23 or 25 hours, the repeated hour is one two-hour period, and a
+// skipped hour has no period (see wallClockTime).
 func GetTimeRangeForDuration(baseTime time.Time, duration string) (time.Time, time.Time) {
 	var start, end time.Time
+	year, month, day := baseTime.Date()
+	loc := baseTime.Location()
 
 	switch duration {
 	case DurationHourly:
 		// Start at beginning of hour, end at beginning of next hour
-		start = time.Date(baseTime.Year(), baseTime.Month(), baseTime.Day(), baseTime.Hour(), 0, 0, 0, baseTime.Location())
-		end = start.Add(time.Hour)
+		start = wallClockTime(year, month, day, baseTime.Hour(), loc)
+		end = wallClockTime(year, month, day, baseTime.Hour()+1, loc)
 
 	case DurationDaily:
 		// Start at beginning of day, end at beginning of next day
-		start = time.Date(baseTime.Year(), baseTime.Month(), baseTime.Day(), 0, 0, 0, 0, baseTime.Location())
-		end = start.AddDate(0, 0, 1)
+		start = wallClockTime(year, month, day, 0, loc)
+		end = wallClockTime(year, month, day+1, 0, loc)
 
 	case DurationWeekly:
 		// Start at beginning of week (Monday), end at beginning of next week
@@ -98,23 +204,32 @@ func GetTimeRangeForDuration(baseTime time.Time, duration string) (time.Time, ti
 			weekday = 7
 		}
 		daysToMonday := weekday - 1
-		start = time.Date(baseTime.Year(), baseTime.Month(), baseTime.Day()-daysToMonday, 0, 0, 0, 0, baseTime.Location())
-		end = start.AddDate(0, 0, 7)
+		start = wallClockTime(year, month, day-daysToMonday, 0, loc)
+		end = wallClockTime(year, month, day-daysToMonday+7, 0, loc)
 
 	case DurationMonthly:
 		// Start at beginning of month, end at beginning of next month
-		start = time.Date(baseTime.Year(), baseTime.Month(), 1, 0, 0, 0, 0, baseTime.Location())
-		end = start.AddDate(0, 1, 0)
+		start = wallClockTime(year, month, 1, 0, loc)
+		end = wallClockTime(year, month+1, 1, 0, loc)
+
+	case DurationQuarterly:
+		// Start at beginning of quarter (Jan, Apr, Jul, Oct), end at beginning of next quarter
+		firstMonth := time.Month((quarterOf(baseTime)-1)*3 + 1)
+		start = wallClockTime(year, firstMonth, 1, 0, loc)
+		end = wallClockTime(year, firstMonth+3, 1, 0, loc)
 
 	case DurationYearly:
 		// Start at beginning of year, end at beginning of next year
-		start = time.Date(baseTime.Year(), time.January, 1, 0, 0, 0, 0, baseTime.Location())
-		end = start.AddDate(1, 0, 0)
+		start = wallClockTime(year, time.January, 1, 0, loc)
+		end = wallClockTime(year+1, time.January, 1, 0, loc)
 
 	default:
+		if interval, ok := parseOutputInterval(duration); ok {
+			return interval.timeRange(baseTime)
+		}
 		// Default to daily
-		start = time.Date(baseTime.Year(), baseTime.Month(), baseTime.Day(), 0, 0, 0, 0, baseTime.Location())
-		end = start.AddDate(0, 0, 1)
+		start = wallClockTime(year, month, day, 0, loc)
+		end = wallClockTime(year, month, day+1, 0, loc)
 	}
 
 	return start, end
@@ -147,21 +262,9 @@ func SplitPartitionByDuration(partitionStart, partitionEnd time.Time, duration s
 			}{Start: start, End: end})
 		}
 
-		// Move to next period
-		switch duration {
-		case DurationHourly:
-			current = current.Add(time.Hour)
-		case DurationDaily:
-			current = current.AddDate(0, 0, 1)
-		case DurationWeekly:
-			current = current.AddDate(0, 0, 7)
-		case DurationMonthly:
-			current = current.AddDate(0, 1, 0)
-		case DurationYearly:
-			current = current.AddDate(1, 0, 0)
-		default:
-			current = current.AddDate(0, 0, 1)
-		}
+		// Move to the next period. Its start is this period's end, which
+		// steps over DST gaps and repeated hours.
+		current = end
 	}
 
 	return ranges
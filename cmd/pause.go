@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// pauseFilePollInterval is how often the pause file is checked for
+const pauseFilePollInterval = time.Second
+
+// Sources that can pause a run; it resumes once neither holds it paused
+const (
+	pauseSourceSignal   = "signal"
+	pauseSourceFile     = "pause file"
+	pauseSourceKeyboard = "keyboard" // The terminal UI's pause key
+)
+
+var pauseFileFlag string
+
+// archivePause is shared by every table of the process, since the pause
+// signals and file apply to the whole run
+var archivePause = newPauseGate()
+
+func init() {
+	archiveCmd.Flags().StringVar(&pauseFileFlag, "pause-file", "", "pause file path; while it exists, no new partitions or slices are started (default: <tmp>/data-archiver/archive-<table>.pause)")
+	_ = viper.BindPFlag("pause_file", archiveCmd.Flags().Lookup("pause-file"))
+}
+
+// pauseGate holds new work back while a run is paused. Partitions and slices
+// already started finish normally; the next one waits until the run resumes.
+type pauseGate struct {
+	mu      sync.Mutex
+	sources map[string]bool
+	resumed chan struct{} // Closed when the run resumes; nil while running
+}
+
+func newPauseGate() *pauseGate {
+	return &pauseGate{sources: make(map[string]bool)}
+}
+
+// set pauses or resumes on behalf of source and reports whether the run as a
+// whole changed between paused and running
+func (g *pauseGate) set(source string, paused bool) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	was := g.resumed != nil
+	if paused {
+		g.sources[source] = true
+	} else {
+		delete(g.sources, source)
+	}
+
+	switch now := len(g.sources) > 0; {
+	case now && !was:
+		g.resumed = make(chan struct{})
+		return true
+	case !now && was:
+		close(g.resumed)
+		g.resumed = nil
+		return true
+	}
+	return false
+}
+
+// paused reports whether the run is paused
+func (g *pauseGate) paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.resumed != nil
+}
+
+// heldBy reports whether source holds the run paused
+func (g *pauseGate) heldBy(source string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.sources[source]
+}
+
+// wait blocks while the run is paused
+func (g *pauseGate) wait(ctx context.Context) error {
+	g.mu.Lock()
+	resumed := g.resumed
+	g.mu.Unlock()
+	if resumed == nil {
+		return nil
+	}
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// getPauseFilePath returns the well-known pause file path for a table, next to
+// its stop file: <tmp>/data-archiver/archive[-<table>].pause
+func getPauseFilePath(table string) string {
+	stopPath := getStopFilePath("archive", table)
+	return stopPath[:len(stopPath)-len(filepath.Ext(stopPath))] + ".pause"
+}
+
+// startPauseWatcher pauses the run on SIGUSR1 or while the pause file exists,
+// and resumes it on SIGUSR2 or when the file is removed. Signals are only
+// available on Unix. The returned function stops the watcher.
+func startPauseWatcher(table string) func() {
+	path := viper.GetString("pause_file")
+	if path == "" {
+		path = getPauseFilePath(table)
+	}
+
+	signals := make(chan os.Signal, 1)
+	stopSignals := notifyPauseSignals(signals)
+	done := make(chan struct{})
+
+	update := func(source string, paused bool) {
+		if !archivePause.set(source, paused) {
+			return
+		}
+		if paused {
+			logger.Info(fmt.Sprintf("⏸️  Paused by %s: running partitions and slices will finish, then the archiver waits", source))
+		} else {
+			logger.Info("▶️  Resumed")
+		}
+		// WriteTaskInfo records the pause state with the rest of the task
+		if taskInfo, err := ReadTaskInfo(); err == nil {
+			_ = WriteTaskInfo(taskInfo)
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(pauseFilePollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-signals:
+				update(pauseSourceSignal, !isResumeSignal(sig))
+			case <-ticker.C:
+				_, err := os.Stat(path)
+				update(pauseSourceFile, err == nil)
+			}
+		}
+	}()
+
+	return func() {
+		stopSignals()
+		close(done)
+		archivePause.set(pauseSourceSignal, false)
+		archivePause.set(pauseSourceFile, false)
+		archivePause.set(pauseSourceKeyboard, false)
+	}
+}
+
+// waitWhilePaused holds back the start of next (a partition or slice) while
+// the run is paused
+func (a *Archiver) waitWhilePaused(ctx context.Context, next string) error {
+	if !archivePause.paused() {
+		return nil
+	}
+	a.logger.Info(fmt.Sprintf("⏸️  Paused before %s", next))
+	if err := archivePause.wait(ctx); err != nil {
+		return err
+	}
+	a.logger.Debug(fmt.Sprintf("▶️  Continuing with %s", next))
+	return nil
+}
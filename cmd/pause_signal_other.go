@@ -0,0 +1,16 @@
+//go:build !unix
+
+package cmd
+
+import "os"
+
+// notifyPauseSignals is a no-op where SIGUSR1 and SIGUSR2 don't exist; only
+// the pause file can pause a run
+func notifyPauseSignals(chan os.Signal) func() {
+	return func() {}
+}
+
+// isResumeSignal is never called without pause signals
+func isResumeSignal(os.Signal) bool {
+	return false
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyPauseSignals delivers SIGUSR1 (pause) and SIGUSR2 (resume) to ch and
+// returns a function that stops the delivery
+func notifyPauseSignals(ch chan os.Signal) func() {
+	signal.Notify(ch, syscall.SIGUSR1, syscall.SIGUSR2)
+	return func() { signal.Stop(ch) }
+}
+
+// isResumeSignal reports whether sig resumes a paused run
+func isResumeSignal(sig os.Signal) bool {
+	return sig == syscall.SIGUSR2
+}
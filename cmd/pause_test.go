@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPauseGate(t *testing.T) {
+	gate := newPauseGate()
+	ctx := context.Background()
+	if err := gate.wait(ctx); err != nil {
+		t.Fatalf("wait() on a running gate = %v", err)
+	}
+
+	if !gate.set(pauseSourceSignal, true) || !gate.paused() {
+		t.Fatal("expected the signal to pause the run")
+	}
+	// The pause file joins the pause; the run resumes only when both let go
+	if gate.set(pauseSourceFile, true) || gate.set(pauseSourceSignal, false) || !gate.paused() {
+		t.Fatal("expected the run to stay paused while the pause file exists")
+	}
+
+	waited := make(chan error, 1)
+	go func() { waited <- gate.wait(ctx) }()
+	select {
+	case err := <-waited:
+		t.Fatalf("wait() returned %v while paused", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if !gate.set(pauseSourceFile, false) {
+		t.Fatal("expected removing the pause file to resume the run")
+	}
+	select {
+	case err := <-waited:
+		if err != nil {
+			t.Errorf("wait() = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("wait() did not return after resume")
+	}
+
+	gate.set(pauseSourceSignal, true)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := gate.wait(cancelled); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation while paused, got %v", err)
+	}
+}
+
+func TestPausedTaskInfo(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	defer archivePause.set(pauseSourceSignal, false)
+
+	archivePause.set(pauseSourceSignal, true)
+	if err := WriteTaskInfo(&TaskInfo{Table: "events"}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := ReadTaskInfo()
+	if err != nil || !info.Paused {
+		t.Fatalf("expected a paused task, got %+v, %v", info, err)
+	}
+
+	archivePause.set(pauseSourceSignal, false)
+	_ = WriteTaskInfo(info)
+	if info, _ := ReadTaskInfo(); info.Paused {
+		t.Error("expected the task to be running again")
+	}
+}
+
+func TestGetPauseFilePath(t *testing.T) {
+	dir := filepath.Join(os.TempDir(), "data-archiver")
+	if got := getPauseFilePath("flights"); got != filepath.Join(dir, "archive-flights.pause") {
+		t.Errorf("unexpected path: %s", got)
+	}
+	if got := getPauseFilePath(""); got != filepath.Join(dir, "archive.pause") {
+		t.Errorf("unexpected path without table: %s", got)
+	}
+}
@@ -0,0 +1,296 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Static errors for restoring schema dumps
+var (
+	ErrPgRestoreMissing   = errors.New("schema dump is in pg_dump custom format, which needs pg_restore, but pg_restore is not installed (install the PostgreSQL client tools or use --schema-source inferred)")
+	ErrSchemaDumpNoTables = errors.New("schema dump has no CREATE TABLE statements")
+)
+
+// customDumpMagic starts every pg_dump custom-format archive
+var customDumpMagic = []byte("PGDMP")
+
+// pgClientTools holds the paths of the PostgreSQL client tools restore and
+// COPY extraction shell out to; an empty path means the tool is not installed
+type pgClientTools struct {
+	PgRestore string
+	Psql      string
+}
+
+// detectPgClientTools looks each tool up with lookPath (exec.LookPath outside tests)
+func detectPgClientTools(lookPath func(string) (string, error)) pgClientTools {
+	var tools pgClientTools
+	if path, err := lookPath("pg_restore"); err == nil {
+		tools.PgRestore = path
+	}
+	if path, err := lookPath(psqlCommand); err == nil {
+		tools.Psql = path
+	}
+	return tools
+}
+
+// clientTools detects the client tools on first use
+func (r *Restorer) clientTools() pgClientTools {
+	if r.pgTools == nil {
+		tools := detectPgClientTools(exec.LookPath)
+		r.pgTools = &tools
+	}
+	return *r.pgTools
+}
+
+// logClientTools reports at startup how pg_dump schemas will be restored
+// when pg_restore or psql is missing
+func (r *Restorer) logClientTools() {
+	tools := r.clientTools()
+	switch {
+	case tools.PgRestore == "" && tools.Psql == "":
+		r.logger.Warn("⚠️  pg_restore and psql are not installed: text-format schema dumps will be restored with the built-in parser, custom-format dumps cannot be restored")
+	case tools.PgRestore == "":
+		r.logger.Warn("⚠️  pg_restore is not installed: custom-format schema dumps cannot be restored")
+	case tools.Psql == "":
+		r.logger.Debug("psql is not installed: text-format schema dumps will be restored with the built-in parser")
+	}
+}
+
+// isCustomFormatDump reports whether the dump at path is a pg_dump custom-format archive
+func isCustomFormatDump(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	header := make([]byte, len(customDumpMagic))
+	if _, err := io.ReadFull(file, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return false, err
+	}
+	return bytes.Equal(header, customDumpMagic), nil
+}
+
+// restoreSchemaWithoutClientTools restores a text-format schema dump without
+// psql, running the statements parseSchemaDump keeps in one transaction
+func (r *Restorer) restoreSchemaWithoutClientTools(ctx context.Context, dumpFile string) (*TableSchema, error) {
+	r.logger.Info("Restoring schema with the built-in parser (psql is not installed)...")
+
+	file, err := os.Open(dumpFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dump file: %w", err)
+	}
+	defer file.Close()
+
+	statements, err := parseSchemaDump(file)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			_ = tx.Rollback()
+			return nil, fmt.Errorf("failed to run schema statement %q: %w", firstLine(statement), err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit schema: %w", err)
+	}
+	r.logger.Info(fmt.Sprintf("✅ Schema restored successfully with the built-in parser (%d statements)", len(statements)))
+
+	schema, err := r.getTableSchema(ctx, r.config.Table)
+	if err != nil {
+		r.logger.Warn(fmt.Sprintf("Could not get schema after restore: %v", err))
+		return &TableSchema{
+			TableName: r.config.Table,
+			Columns:   []ColumnInfo{},
+		}, nil
+	}
+	return schema, nil
+}
+
+// parseSchemaDump returns the statements of a text-format pg_dump that
+// define tables: CREATE TABLE, CREATE TYPE, CREATE SEQUENCE, CREATE INDEX,
+// and ALTER TABLE / ALTER SEQUENCE other than ownership changes. Session
+// settings, comments, grants, COPY data, and psql meta-commands are skipped.
+func parseSchemaDump(r io.Reader) ([]string, error) {
+	statements, err := splitSQLStatements(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var kept []string
+	tables := 0
+	for _, statement := range statements {
+		upper := strings.ToUpper(strings.Join(strings.Fields(statement), " "))
+		switch {
+		case strings.HasPrefix(upper, "CREATE TABLE "), strings.HasPrefix(upper, "CREATE UNLOGGED TABLE "):
+			tables++
+		case strings.HasPrefix(upper, "CREATE TYPE "),
+			strings.HasPrefix(upper, "CREATE SEQUENCE "),
+			strings.HasPrefix(upper, "CREATE INDEX "),
+			strings.HasPrefix(upper, "CREATE UNIQUE INDEX "):
+		case strings.HasPrefix(upper, "ALTER TABLE "), strings.HasPrefix(upper, "ALTER SEQUENCE "):
+			if strings.Contains(upper, " OWNER TO ") {
+				continue
+			}
+		default:
+			continue
+		}
+		kept = append(kept, statement)
+	}
+	if tables == 0 {
+		return nil, ErrSchemaDumpNoTables
+	}
+	return kept, nil
+}
+
+// splitSQLStatements splits SQL text on semicolons outside comments, quoted
+// strings, quoted identifiers, and dollar-quoted bodies. The data lines of
+// COPY ... FROM stdin blocks and psql meta-commands are dropped.
+func splitSQLStatements(r io.Reader) ([]string, error) {
+	reader := bufio.NewReader(r)
+	var statements []string
+	var current strings.Builder
+	var quote byte       // ' or " while inside a quoted string or identifier
+	var dollarTag string // $tag$ while inside a dollar-quoted body
+	inBlockComment := false
+
+	for {
+		line, err := reader.ReadString('\n')
+		if line == "" && err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		// psql meta-commands (\connect, \restrict) only appear between statements
+		if quote == 0 && dollarTag == "" && !inBlockComment && strings.TrimSpace(current.String()) == "" &&
+			strings.HasPrefix(strings.TrimSpace(line), `\`) {
+			current.Reset()
+			continue
+		}
+
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			switch {
+			case inBlockComment:
+				if c == '*' && i+1 < len(line) && line[i+1] == '/' {
+					inBlockComment = false
+					i++
+				}
+				continue
+			case dollarTag != "":
+				if strings.HasPrefix(line[i:], dollarTag) {
+					current.WriteString(dollarTag)
+					i += len(dollarTag) - 1
+					dollarTag = ""
+					continue
+				}
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+			case c == '-' && i+1 < len(line) && line[i+1] == '-':
+				i = len(line) // Line comment
+				current.WriteByte('\n')
+				continue
+			case c == '/' && i+1 < len(line) && line[i+1] == '*':
+				inBlockComment = true
+				i++
+				continue
+			case c == '\'' || c == '"':
+				quote = c
+			case c == '$':
+				if end := strings.IndexByte(line[i+1:], '$'); end >= 0 && isDollarTag(line[i+1:i+1+end]) {
+					dollarTag = line[i : i+end+2]
+					current.WriteString(dollarTag)
+					i += end + 1
+					continue
+				}
+			case c == ';':
+				statement := strings.TrimSpace(current.String())
+				current.Reset()
+				if statement == "" {
+					continue
+				}
+				statements = append(statements, statement)
+				if isCopyFromStdin(statement) {
+					if err := skipCopyData(reader); err != nil {
+						return nil, err
+					}
+					i = len(line)
+				}
+				continue
+			}
+			current.WriteByte(c)
+		}
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+	}
+
+	if statement := strings.TrimSpace(current.String()); statement != "" {
+		statements = append(statements, statement)
+	}
+	return statements, nil
+}
+
+// isDollarTag reports whether tag is a valid dollar-quote tag (possibly empty)
+func isDollarTag(tag string) bool {
+	for i, r := range tag {
+		if r != '_' && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// isCopyFromStdin reports whether statement is a COPY whose data follows inline
+func isCopyFromStdin(statement string) bool {
+	upper := strings.ToUpper(strings.Join(strings.Fields(statement), " "))
+	return strings.HasPrefix(upper, "COPY ") && strings.HasSuffix(upper, "FROM STDIN")
+}
+
+// skipCopyData discards COPY data lines up to and including the \. terminator
+func skipCopyData(reader *bufio.Reader) error {
+	for {
+		line, err := reader.ReadString('\n')
+		if strings.TrimRight(line, "\r\n") == `\.` {
+			return nil
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// firstLine returns the first line of a statement for error messages
+func firstLine(statement string) string {
+	if i := strings.IndexByte(statement, '\n'); i >= 0 {
+		return statement[:i] + " ..."
+	}
+	return statement
+}
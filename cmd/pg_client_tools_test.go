@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectPgClientTools(t *testing.T) {
+	lookPath := func(name string) (string, error) {
+		if name == "psql" {
+			return "/usr/bin/psql", nil
+		}
+		return "", errors.New("not found")
+	}
+	tools := detectPgClientTools(lookPath)
+	if tools.PgRestore != "" || tools.Psql != "/usr/bin/psql" {
+		t.Errorf("unexpected tools: %+v", tools)
+	}
+}
+
+func TestIsCustomFormatDump(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"custom.dump": "PGDMP\x01\x0e\x00",
+		"text.sql":    "--\n-- PostgreSQL database dump\n--\n",
+		"short.sql":   "PG",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, want := range map[string]bool{"custom.dump": true, "text.sql": false, "short.sql": false} {
+		got, err := isCustomFormatDump(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if got != want {
+			t.Errorf("%s: expected %v, got %v", name, want, got)
+		}
+	}
+}
+
+const testSchemaDump = `--
+-- PostgreSQL database dump
+--
+\restrict abc123
+
+SET statement_timeout = 0;
+SELECT pg_catalog.set_config('search_path', '', false);
+
+CREATE TYPE public.flight_status AS ENUM (
+    'scheduled',
+    'landed'
+);
+
+CREATE TABLE public.flights (
+    id bigint NOT NULL,
+    callsign text DEFAULT 'N/A; unknown'::text, -- a comment; with a semicolon
+    status public.flight_status,
+    note text DEFAULT $$it's; fine$$
+);
+
+ALTER TABLE public.flights OWNER TO archiver;
+
+CREATE SEQUENCE public.flights_id_seq
+    START WITH 1
+    INCREMENT BY 1;
+
+ALTER SEQUENCE public.flights_id_seq OWNED BY public.flights.id;
+
+/* block comment; with a semicolon */
+COMMENT ON TABLE public.flights IS 'Flights; all of them';
+
+COPY public.flights (id, callsign) FROM stdin;
+1	CREATE TABLE nope;
+\.
+
+ALTER TABLE ONLY public.flights
+    ADD CONSTRAINT flights_pkey PRIMARY KEY (id);
+
+CREATE INDEX flights_callsign_idx ON public.flights USING btree (callsign);
+
+GRANT SELECT ON TABLE public.flights TO reader;
+
+\unrestrict abc123
+`
+
+func TestParseSchemaDump(t *testing.T) {
+	statements, err := parseSchemaDump(strings.NewReader(testSchemaDump))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	prefixes := []string{
+		"CREATE TYPE public.flight_status",
+		"CREATE TABLE public.flights",
+		"CREATE SEQUENCE public.flights_id_seq",
+		"ALTER SEQUENCE public.flights_id_seq OWNED BY",
+		"ALTER TABLE ONLY public.flights",
+		"CREATE INDEX flights_callsign_idx",
+	}
+	if len(statements) != len(prefixes) {
+		t.Fatalf("expected %d statements, got %d:\n%s", len(prefixes), len(statements), strings.Join(statements, "\n---\n"))
+	}
+	for i, prefix := range prefixes {
+		if !strings.HasPrefix(statements[i], prefix) {
+			t.Errorf("statement %d = %q, want prefix %q", i, statements[i], prefix)
+		}
+	}
+	if table := statements[1]; !strings.Contains(table, "'N/A; unknown'") || !strings.Contains(table, "$$it's; fine$$") || strings.Contains(table, "a comment") {
+		t.Errorf("CREATE TABLE not kept intact: %s", table)
+	}
+
+	if _, err := parseSchemaDump(strings.NewReader("SET statement_timeout = 0;\n")); !errors.Is(err, ErrSchemaDumpNoTables) {
+		t.Errorf("expected ErrSchemaDumpNoTables, got %v", err)
+	}
+}
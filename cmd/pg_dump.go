@@ -15,12 +15,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	pq "github.com/lib/pq"
 )
 
@@ -28,8 +22,7 @@ import (
 type PgDumpExecutor struct {
 	config     *Config
 	db         *sql.DB
-	s3Client   *s3.S3
-	s3Uploader *s3manager.Uploader
+	s3Client   StorageBackend
 	logger     *slog.Logger
 	ctx        context.Context
 	partitions []string // Cached list of partitions to exclude for schema-only mode
@@ -134,30 +127,16 @@ func (e *PgDumpExecutor) fetchS3Metadata(ctx context.Context, objectKey string)
 		return 0, "", errors.New("s3 client is not initialized")
 	}
 
-	input := &s3.HeadObjectInput{
-		Bucket: aws.String(e.config.S3.Bucket),
-		Key:    aws.String(objectKey),
-	}
-
-	result, err := e.s3Client.HeadObjectWithContext(ctx, input)
+	result, err := e.s3Client.Head(ctx, e.config.S3.Bucket, objectKey)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			if aerr.Code() == s3.ErrCodeNoSuchKey || aerr.Code() == "NotFound" {
-				return 0, "", errS3ObjectMissing
-			}
+		if isObjectNotFound(err) {
+			return 0, "", errS3ObjectMissing
 		}
 		return 0, "", err
 	}
 
-	size := int64(0)
-	if result.ContentLength != nil {
-		size = *result.ContentLength
-	}
-
-	etag := ""
-	if result.ETag != nil {
-		etag = strings.Trim(*result.ETag, "\"")
-	}
+	size := result.Size
+	etag := strings.Trim(result.ETag, "\"")
 
 	return size, etag, nil
 }
@@ -385,16 +364,9 @@ func (e *PgDumpExecutor) dumpTable(ctx context.Context, tableName string) error
 	} else {
 		go func() {
 			// Custom format (-Fc) is always binary, regardless of dump mode
-			contentType := "application/octet-stream"
-			uploadInput := &s3manager.UploadInput{
-				Bucket:      aws.String(e.config.S3.Bucket),
-				Key:         aws.String(objectKey),
-				Body:        pr,
-				ContentType: aws.String(contentType),
-			}
-			result, uploadErr := e.s3Uploader.UploadWithContext(ctx, uploadInput)
-			if uploadErr == nil && result != nil {
-				e.logger.Debug(fmt.Sprintf("Successfully uploaded to S3: %s", result.Location))
+			_, uploadErr := e.s3Client.Upload(ctx, e.config.S3.Bucket, objectKey, pr, e.uploadOptions())
+			if uploadErr == nil {
+				e.logger.Debug(fmt.Sprintf("Successfully uploaded to S3: %s", objectKey))
 			}
 			uploadDone <- uploadErr
 		}()
@@ -566,35 +538,22 @@ func (e *PgDumpExecutor) dumpTableToFile(ctx context.Context, tableName string,
 	}
 	defer file.Close()
 
-	uploadInput := &s3manager.UploadInput{
-		Bucket:      aws.String(e.config.S3.Bucket),
-		Key:         aws.String(objectKey),
-		Body:        file,
-		ContentType: aws.String("application/octet-stream"),
-	}
-
-	result, err := e.s3Uploader.UploadWithContext(ctx, uploadInput)
-	if err != nil {
+	if _, err := e.s3Client.Upload(ctx, e.config.S3.Bucket, objectKey, file, e.uploadOptions()); err != nil {
 		return fmt.Errorf("S3 upload failed: %w", err)
 	}
-
-	if result != nil {
-		e.logger.Debug(fmt.Sprintf("Successfully uploaded to S3: %s", result.Location))
-	}
+	e.logger.Debug(fmt.Sprintf("Successfully uploaded to S3: %s", objectKey))
 
 	return nil
 }
 
 // extractDateFromTableName extracts date from partition table name
 func (e *PgDumpExecutor) extractDateFromTableName(tableName string) (time.Time, bool) {
-	baseTableLen := len(e.config.Table)
-	if len(tableName) <= baseTableLen+1 {
+	// Remove base table name and underscore
+	suffix, ok := partitionSuffix(e.config.Table, tableName)
+	if !ok {
 		return time.Time{}, false
 	}
 
-	// Remove base table name and underscore
-	suffix := tableName[baseTableLen+1:]
-
 	// Format 1: {base_table}_YYYYMMDD (8 digits)
 	if len(suffix) == 8 {
 		if date, err := time.Parse("20060102", suffix); err == nil {
@@ -650,7 +609,7 @@ func (e *PgDumpExecutor) dumpPartitionsByDateRange(ctx context.Context) error {
 	}
 
 	// Discover partitions that inherit from the target table
-	rows, err := e.db.QueryContext(ctx, leafPartitionListSQL, defaultTableSchema, e.config.Table)
+	rows, err := e.db.QueryContext(ctx, leafPartitionListSQL, defaultTableSchema, e.config.Table, pq.Array(e.config.partitionRelkinds()))
 	if err != nil {
 		return fmt.Errorf("failed to query partitions: %w", err)
 	}
@@ -810,6 +769,8 @@ func (e *PgDumpExecutor) getGroupKeyForDuration(date time.Time, duration string)
 		return monday.Format("2006-01-02")
 	case DurationMonthly:
 		return date.Format("2006-01")
+	case DurationQuarterly:
+		return fmt.Sprintf("%04d-Q%d", date.Year(), quarterOf(date))
 	case DurationYearly:
 		return date.Format("2006")
 	default:
@@ -1000,6 +961,8 @@ func addDuration(t time.Time, duration string) time.Time {
 		return t.AddDate(0, 0, 7)
 	case DurationMonthly:
 		return t.AddDate(0, 1, 0)
+	case DurationQuarterly:
+		return t.AddDate(0, 3, 0)
 	case DurationYearly:
 		return t.AddDate(1, 0, 0)
 	default: // daily
@@ -1023,7 +986,7 @@ func truncateIdentifier(identifier string) string {
 func (e *PgDumpExecutor) createStagingTable(ctx context.Context, stagingName string, window dateWindow) (int64, error) {
 	quotedStaging := fmt.Sprintf("public.%s", pq.QuoteIdentifier(stagingName))
 	quotedBase := fmt.Sprintf("public.%s", pq.QuoteIdentifier(e.config.Table))
-	quotedColumn := pq.QuoteIdentifier(e.config.DateColumn)
+	condition, args := e.config.dateColumnSpec().rangeCondition(window.start, window.end)
 
 	// Ensure leftover tables from previous runs don't block creation
 	if _, err := e.db.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", quotedStaging)); err != nil {
@@ -1033,9 +996,9 @@ func (e *PgDumpExecutor) createStagingTable(ctx context.Context, stagingName str
 	query := fmt.Sprintf(`CREATE UNLOGGED TABLE %s AS
 SELECT *
 FROM %s
-WHERE %s >= $1 AND %s < $2`, quotedStaging, quotedBase, quotedColumn, quotedColumn)
+WHERE %s`, quotedStaging, quotedBase, condition)
 
-	result, err := e.db.ExecContext(ctx, query, window.start, window.end)
+	result, err := e.db.ExecContext(ctx, query, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -1143,21 +1106,10 @@ func (e *PgDumpExecutor) dumpMultiplePartitionsToFile(ctx context.Context, parti
 	}
 
 	e.logger.Info(fmt.Sprintf("Uploading to s3://%s/%s", e.config.S3.Bucket, objectKey))
-	uploadInput := &s3manager.UploadInput{
-		Bucket:      aws.String(e.config.S3.Bucket),
-		Key:         aws.String(objectKey),
-		Body:        file,
-		ContentType: aws.String("application/octet-stream"),
-	}
-
-	result, err := e.s3Uploader.UploadWithContext(ctx, uploadInput)
-	if err != nil {
+	if _, err := e.s3Client.Upload(ctx, e.config.S3.Bucket, objectKey, file, e.uploadOptions()); err != nil {
 		return 0, "", "", fmt.Errorf("S3 upload failed: %w", err)
 	}
-
-	if result != nil {
-		e.logger.Debug(fmt.Sprintf("Successfully uploaded to S3: %s", result.Location))
-	}
+	e.logger.Debug(fmt.Sprintf("Successfully uploaded to S3: %s", objectKey))
 
 	s3Size, s3ETag, err := e.fetchS3Metadata(ctx, objectKey)
 	if err != nil {
@@ -1171,12 +1123,14 @@ func (e *PgDumpExecutor) dumpMultiplePartitionsToFile(ctx context.Context, parti
 
 // generateObjectKeyWithDuration generates the S3 object key with date placeholders filled in based on output duration
 func (e *PgDumpExecutor) generateObjectKeyWithDuration(tableName string, dumpDate time.Time, duration string) string {
-	pathTemplate := NewPathTemplate(e.config.S3.PathTemplate)
+	// pg_dump writes custom-format files, compressed by pg_dump itself
+	pathTemplate := NewPathTemplate(e.config.S3.PathTemplate).WithOutput("dump", "none")
 
 	// Use provided table name, or database name if empty
 	if tableName == "" {
 		tableName = e.config.Database.Name
 	}
+	tableName = objectKeyComponent(tableName)
 
 	// Generate path with date placeholders filled in
 	basePath := pathTemplate.Generate(tableName, dumpDate)
@@ -1199,6 +1153,8 @@ func (e *PgDumpExecutor) generateObjectKeyWithDuration(tableName string, dumpDat
 		filename = fmt.Sprintf("%s-%s.dump", tableName, monday.Format("2006-01-02"))
 	case DurationMonthly:
 		filename = fmt.Sprintf("%s-%s.dump", tableName, dumpDate.Format("2006-01"))
+	case DurationQuarterly:
+		filename = fmt.Sprintf("%s-%04d-Q%d.dump", tableName, dumpDate.Year(), quarterOf(dumpDate))
 	case DurationYearly:
 		filename = fmt.Sprintf("%s-%s.dump", tableName, dumpDate.Format("2006"))
 	default:
@@ -1277,15 +1233,13 @@ func (e *PgDumpExecutor) buildPgDumpCommand(tableName string) (*exec.Cmd, error)
 
 // qualifyTableName ensures pg_dump gets a schema-qualified table identifier
 func (e *PgDumpExecutor) qualifyTableName(tableName string) string {
-	if strings.Contains(tableName, ".") {
-		return tableName
-	}
-	return fmt.Sprintf("public.%s", tableName)
+	return qualifiedTableName(tableName)
 }
 
 // generateObjectKey generates the S3 object key based on path template and dump mode
 func (e *PgDumpExecutor) generateObjectKey(tableName string) string {
-	pathTemplate := NewPathTemplate(e.config.S3.PathTemplate)
+	// pg_dump writes custom-format files, compressed by pg_dump itself
+	pathTemplate := NewPathTemplate(e.config.S3.PathTemplate).WithOutput("dump", "none")
 
 	// For schema-only dumps, use a simpler path without dates
 	if e.config.DumpMode == "schema-only" {
@@ -1293,6 +1247,7 @@ func (e *PgDumpExecutor) generateObjectKey(tableName string) string {
 		if tableName == "" {
 			tableName = e.config.Database.Name
 		}
+		tableName = objectKeyComponent(tableName)
 
 		// Check if path template contains {table} placeholder
 		hasTablePlaceholder := strings.Contains(e.config.S3.PathTemplate, "{table}")
@@ -1303,26 +1258,7 @@ func (e *PgDumpExecutor) generateObjectKey(tableName string) string {
 			// Replace {table} with actual table name
 			basePath = strings.ReplaceAll(basePath, "{table}", tableName)
 		}
-		// Remove date placeholders with their surrounding slashes
-		basePath = strings.ReplaceAll(basePath, "/{YYYY}", "")
-		basePath = strings.ReplaceAll(basePath, "{YYYY}/", "")
-		basePath = strings.ReplaceAll(basePath, "/{MM}", "")
-		basePath = strings.ReplaceAll(basePath, "{MM}/", "")
-		basePath = strings.ReplaceAll(basePath, "/{DD}", "")
-		basePath = strings.ReplaceAll(basePath, "{DD}/", "")
-		basePath = strings.ReplaceAll(basePath, "/{HH}", "")
-		basePath = strings.ReplaceAll(basePath, "{HH}/", "")
-		// Also handle standalone placeholders
-		basePath = strings.ReplaceAll(basePath, "{YYYY}", "")
-		basePath = strings.ReplaceAll(basePath, "{MM}", "")
-		basePath = strings.ReplaceAll(basePath, "{DD}", "")
-		basePath = strings.ReplaceAll(basePath, "{HH}", "")
-		// Clean up any double slashes
-		for strings.Contains(basePath, "//") {
-			basePath = strings.ReplaceAll(basePath, "//", "/")
-		}
-		// Remove trailing slash
-		basePath = strings.TrimSuffix(basePath, "/")
+		basePath = stripDatePlaceholders(basePath)
 
 		// Filename: if {table} was in path, use schema.dump; otherwise prefix with table name
 		var filename string
@@ -1344,6 +1280,7 @@ func (e *PgDumpExecutor) generateObjectKey(tableName string) string {
 	if tableName == "" {
 		tableName = e.config.Database.Name
 	}
+	tableName = objectKeyComponent(tableName)
 	basePath := pathTemplate.Generate(tableName, now)
 
 	// Determine filename based on dump mode
@@ -1525,20 +1462,20 @@ func (e *PgDumpExecutor) discoverPartitionsByPattern(ctx context.Context) ([]str
 	return partitions, nil
 }
 
-// initS3 initializes the S3 client and uploader
+// initS3 initializes the S3 client
 func (e *PgDumpExecutor) initS3() error {
-	sess, err := session.NewSession(&aws.Config{
-		Endpoint:         aws.String(e.config.S3.Endpoint),
-		Region:           aws.String(e.config.S3.Region),
-		Credentials:      credentials.NewStaticCredentials(e.config.S3.AccessKey, e.config.S3.SecretKey, ""),
-		S3ForcePathStyle: aws.Bool(true),
-	})
+	client, err := newStorageClient(e.config.S3)
 	if err != nil {
-		return fmt.Errorf("failed to create S3 session: %w", err)
+		return fmt.Errorf("failed to create storage client: %w", err)
 	}
 
-	e.s3Client = s3.New(sess)
-	e.s3Uploader = s3manager.NewUploader(sess)
+	e.s3Client = client
 
 	return nil
 }
+
+// uploadOptions returns how dumps are uploaded: as binary files tagged with
+// the run ID
+func (e *PgDumpExecutor) uploadOptions() UploadOptions {
+	return UploadOptions{PutOptions: PutOptions{ContentType: "application/octet-stream", Metadata: runIDMetadata()}}
+}
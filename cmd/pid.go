@@ -33,6 +33,8 @@ type TaskInfo struct {
 	PartitionsCounted   int `json:"partitions_counted,omitempty"`   // Partitions that have been counted
 	PartitionsProcessed int `json:"partitions_processed,omitempty"` // Partitions that have been processed
 	SlicesProcessed     int `json:"slices_processed,omitempty"`     // Total slices processed across all partitions
+	// Paused is set while a pause signal, the pause file, or the pause key holds the run
+	Paused bool `json:"paused,omitempty"`
 }
 
 // GetPIDFilePath returns the path to the PID file
@@ -110,6 +112,7 @@ func WriteTaskInfo(info *TaskInfo) error {
 	}
 
 	info.LastUpdate = time.Now()
+	info.Paused = archivePause.paused()
 
 	data, err := json.MarshalIndent(info, "", "  ")
 	if err != nil {
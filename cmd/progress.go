@@ -126,6 +126,7 @@ type progressModel struct {
 	results         []ProcessResult
 	startTime       time.Time
 	messages        []string
+	log             *logPane // Scrollable log of the run's events, opened with l
 	countProgress   int
 	countTotal      int
 	config          *Config
@@ -152,6 +153,8 @@ type progressModel struct {
 	sliceProgress        progress.Model
 	sliceResults         *safeSliceResults
 	totalSlicesProcessed int // Track total slices processed across all partitions
+	// Partitions created after discovery (nil = --rediscover-interval off)
+	rediscovery *partitionRediscovery
 }
 
 type progressMsg struct {
@@ -176,6 +179,12 @@ type partitionsFoundMsg struct {
 	partitions []PartitionInfo
 }
 
+// partitionsRediscoveredMsg carries partitions created since the last discovery
+type partitionsRediscoveredMsg struct {
+	partitions []PartitionInfo
+	final      bool // Every known partition was processed before looking
+}
+
 type discoveredTablesMsg struct {
 	tables []struct {
 		name string
@@ -248,6 +257,14 @@ var (
 				Margin(0, 2)
 )
 
+// setPhase moves the TUI to phase and reports it to the progress file
+func (m *progressModel) setPhase(phase Phase) {
+	m.phase = phase
+	if m.archiver != nil {
+		m.archiver.emitPhase(phase)
+	}
+}
+
 // updateTaskInfo updates the task info file with current progress
 func (m *progressModel) updateTaskInfo() {
 	if m.taskInfo != nil {
@@ -332,6 +349,7 @@ func newProgressModelWithArchiver(ctx context.Context, cancel context.CancelFunc
 		currentStage:    "Initializing...",
 		results:         make([]ProcessResult, 0),
 		messages:        make([]string, 0),
+		log:             newLogPane(config.TUILogLines),
 		startTime:       time.Now(),
 		config:          config,
 		archiver:        archiver,
@@ -410,6 +428,18 @@ func (m *progressModel) doCheckPermissions() tea.Cmd {
 			return messageMsg(fmt.Sprintf("❌ Permission check failed: %v", err))
 		}
 
+		// The schema goes up before any data, so every archive has one to restore with
+		status, err := m.archiver.exportSchema(m.ctx)
+		if err != nil {
+			if m.errChan != nil {
+				m.errChan <- fmt.Errorf("schema export failed: %w", err)
+			}
+			return messageMsg(fmt.Sprintf("❌ Schema export failed: %v", err))
+		}
+		if status != "" {
+			return messageMsg("✅ Table permissions verified, " + status)
+		}
+
 		// Permissions verified - return success message
 		return messageMsg("✅ Table permissions verified")
 	}
@@ -421,20 +451,20 @@ func (m *progressModel) doDiscover() tea.Cmd {
 			return messageMsg("❌ Database not connected")
 		}
 
+		if m.config.WatermarkColumn != "" {
+			partitions, err := m.archiver.buildIncrement(context.Background())
+			if err != nil {
+				return messageMsg(fmt.Sprintf("❌ %v", err))
+			}
+			return partitionsFoundMsg{partitions: partitions}
+		}
+
 		// First, collect all matching table names
 		var matchingTables []struct {
 			name string
 			date time.Time
 		}
 
-		var startDate, endDate time.Time
-		if m.config.StartDate != "" {
-			startDate, _ = time.Parse("2006-01-02", m.config.StartDate)
-		}
-		if m.config.EndDate != "" {
-			endDate, _ = time.Parse("2006-01-02", m.config.EndDate)
-		}
-
 		discoveredCount := 0
 		skippedCount := 0
 		seenTables := make(map[string]bool) // Track tables to avoid duplicates
@@ -461,11 +491,20 @@ func (m *progressModel) doDiscover() tea.Cmd {
 					continue
 				}
 
-				if m.config.StartDate != "" && date.Before(startDate) {
+				if !m.archiver.inDateRange(date) {
 					skippedCount++
 					continue
 				}
-				if m.config.EndDate != "" && date.After(endDate) {
+
+				// Check if we have SELECT permission on the table (before the
+				// schema check, which sees no columns in unreadable tables)
+				hasPermission, err := m.archiver.partitionReadable(context.Background(), tableName)
+				if err != nil {
+					skippedCount++
+					continue
+				}
+				if !hasPermission {
+					m.archiver.notePermissionDenied(PartitionInfo{TableName: tableName, Date: date})
 					skippedCount++
 					continue
 				}
@@ -483,18 +522,6 @@ func (m *progressModel) doDiscover() tea.Cmd {
 					continue
 				}
 
-				// Check if we have SELECT permission on the table
-				var hasPermission bool
-				checkPermissionQuery := `SELECT has_table_privilege('public.' || $1, 'SELECT')`
-				if err := m.archiver.db.QueryRow(checkPermissionQuery, tableName).Scan(&hasPermission); err != nil {
-					skippedCount++
-					continue
-				}
-				if !hasPermission {
-					skippedCount++
-					continue
-				}
-
 				discoveredCount++
 				matchingTables = append(matchingTables, struct {
 					name string
@@ -506,7 +533,7 @@ func (m *progressModel) doDiscover() tea.Cmd {
 
 		// Query for leaf partitions only (not intermediate parent partitions)
 		// This handles hierarchical partitioning like: flights -> flights_2024 -> flights_2024_01 -> flights_2024_01_01
-		rows, err := m.archiver.db.Query(leafPartitionListSQL, defaultTableSchema, m.config.Table)
+		rows, err := m.archiver.db.Query(leafPartitionListSQL, defaultTableSchema, m.config.Table, pq.Array(m.config.partitionRelkinds()))
 		if err != nil {
 			return messageMsg(fmt.Sprintf("❌ Failed to query partitions: %v", err))
 		}
@@ -514,9 +541,10 @@ func (m *progressModel) doDiscover() tea.Cmd {
 			return messageMsg(fmt.Sprintf("❌ Failed to scan partitions: %v", err))
 		}
 
-		// If enabled, also query non-partition tables matching the pattern
-		if m.config.IncludeNonPartitionTables {
-			nonPartitionRows, err := m.archiver.db.Query(nonPartitionTableListSQL, defaultTableSchema, m.config.Table)
+		// If enabled, also query non-partition tables, materialized views, and
+		// foreign tables matching the pattern
+		if relkinds := m.config.standaloneRelkinds(); len(relkinds) > 0 {
+			nonPartitionRows, err := m.archiver.db.Query(nonPartitionTableListSQL, defaultTableSchema, m.config.Table, pq.Array(relkinds))
 			if err != nil {
 				return messageMsg(fmt.Sprintf("❌ Failed to query non-partition tables: %v", err))
 			}
@@ -525,20 +553,70 @@ func (m *progressModel) doDiscover() tea.Cmd {
 			}
 		}
 
+		if err := m.archiver.finishPermissionChecks(); err != nil {
+			if m.errChan != nil {
+				m.errChan <- fmt.Errorf("permission check failed: %w", err)
+			}
+			return messageMsg(fmt.Sprintf("❌ Permission check failed: %v", err))
+		}
+
 		if len(matchingTables) == 0 {
-			partitions, err := m.archiver.buildDateRangePartition()
+			if err := m.archiver.prepareDiscovered(context.Background(), nil); err != nil {
+				return messageMsg(fmt.Sprintf("❌ Partitionless fallback unavailable: %v", err))
+			}
+			var partitions []PartitionInfo
+			if m.config.SplitColumn != "" {
+				partitions, err = m.archiver.buildKeyRangePartitions(context.Background())
+			} else {
+				partitions, err = m.archiver.buildDateRangePartition()
+			}
 			if err != nil {
 				return messageMsg(fmt.Sprintf("❌ %v", err))
 			}
+			if partitions, err = m.archiver.applyStartFrom(partitions); err != nil {
+				return m.startFromFailed(err)
+			}
 			return partitionsFoundMsg{partitions: partitions}
 		}
 
+		// Skip ahead before counting, so skipped partitions cost nothing.
+		// Partitions combined into longer files are skipped once combined,
+		// after counting, as plain discovery does.
+		planned := make([]PartitionInfo, len(matchingTables))
+		for i, table := range matchingTables {
+			planned[i] = PartitionInfo{TableName: table.name, Date: table.date}
+		}
+		if err := m.archiver.prepareDiscovered(context.Background(), planned); err != nil {
+			return messageMsg(fmt.Sprintf("❌ %v", err))
+		}
+		if !m.archiver.combinesPartitions(planned) {
+			planned, err = m.archiver.applyStartFrom(planned)
+			if err != nil {
+				return m.startFromFailed(err)
+			}
+		}
+		matchingTables = matchingTables[:0]
+		for _, partition := range planned {
+			matchingTables = append(matchingTables, struct {
+				name string
+				date time.Time
+			}{name: partition.TableName, date: partition.Date})
+		}
+
 		return discoveredTablesMsg{
 			tables: matchingTables,
 		}
 	}
 }
 
+// startFromFailed stops the run when --start-from is not in the planned work
+func (m *progressModel) startFromFailed(err error) tea.Msg {
+	if m.errChan != nil {
+		m.errChan <- err
+	}
+	return messageMsg(fmt.Sprintf("❌ %v", err))
+}
+
 // startCounting was replaced by countNextTable logic
 /*
 func (m *progressModel) startCounting(tables []struct {
@@ -570,7 +648,21 @@ func (m *progressModel) countNextTable() tea.Cmd {
 			_ = m.partitionCache.save(m.config.CacheScope)
 		}
 		return func() tea.Msg {
-			return partitionsFoundMsg{partitions: m.countedPartitions}
+			partitions := m.countedPartitions
+			if m.archiver.combinesPartitions(partitions) {
+				// Combined partitions were not skipped before counting
+				var err error
+				if partitions, err = m.archiver.aggregatePartitions(context.Background(), partitions); err != nil {
+					if m.errChan != nil {
+						m.errChan <- err
+					}
+					return messageMsg(fmt.Sprintf("❌ %v", err))
+				}
+				if partitions, err = m.archiver.applyStartFrom(partitions); err != nil {
+					return m.startFromFailed(err)
+				}
+			}
+			return partitionsFoundMsg{partitions: partitions}
 		}
 	}
 
@@ -615,14 +707,21 @@ func (m *progressModel) countNextTable() tea.Cmd {
 }
 
 func (m *progressModel) processNext() tea.Cmd {
-	if m.currentIndex >= len(m.partitions) {
-		// Done processing all partitions
-		if m.resultsChan != nil {
-			m.resultsChan <- m.results
+	// A failed slice the operator asked to retry goes before the next partition
+	if m.archiver != nil && m.archiver.db != nil {
+		if cmd := m.retryNext(); cmd != nil {
+			return cmd
 		}
-		return func() tea.Msg {
-			return allCompleteMsg{}
+	}
+	if m.currentIndex >= len(m.partitions) {
+		// Look once more for partitions created while the run was processing
+		if m.rediscovery != nil {
+			return m.rediscover(true)
 		}
+		return m.finishProcessing()
+	}
+	if m.rediscovery.due() {
+		return m.rediscover(false)
 	}
 
 	// Get current partition
@@ -637,7 +736,14 @@ func (m *progressModel) processNext() tea.Cmd {
 	return func() tea.Msg {
 		// Actually process the partition using the archiver
 		if m.archiver != nil && m.archiver.db != nil {
-			result := m.archiver.ProcessPartitionWithProgress(partition, m.program)
+			// Hold the partition back while the run is paused
+			if err := m.archiver.waitWhilePaused(m.ctx, partition.TableName); err != nil {
+				return partitionCompleteMsg{
+					index:  index,
+					result: ProcessResult{Partition: partition, Error: err, Stage: StageCancelled},
+				}
+			}
+			result := m.archiver.processControlledPartition(partition, m.program)
 
 			// Debug: log any errors
 			if result.Error != nil && m.config.Debug {
@@ -662,6 +768,30 @@ func (m *progressModel) processNext() tea.Cmd {
 	}
 }
 
+// finishProcessing hands the results to Run once every partition is processed
+func (m *progressModel) finishProcessing() tea.Cmd {
+	if m.resultsChan != nil {
+		m.resultsChan <- m.results
+	}
+	return func() tea.Msg {
+		return allCompleteMsg{}
+	}
+}
+
+// rediscover looks for partitions created since the last discovery
+func (m *progressModel) rediscover(final bool) tea.Cmd {
+	return func() tea.Msg {
+		partitions, err := m.archiver.rediscoverPartitions(m.ctx, m.rediscovery, final)
+		if errors.Is(err, ErrPartitionPermissionDenied) {
+			if m.errChan != nil {
+				m.errChan <- fmt.Errorf("permission check failed: %w", err)
+			}
+			return messageMsg(fmt.Sprintf("❌ Permission check failed: %v", err))
+		}
+		return partitionsRediscoveredMsg{partitions: partitions, final: final}
+	}
+}
+
 func (m progressModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
@@ -685,6 +815,8 @@ func (m progressModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.handleDiscoveredTablesMsg(msg)
 	case partitionsFoundMsg:
 		return m.handlePartitionsFoundMsg(msg)
+	case partitionsRediscoveredMsg:
+		return m.handlePartitionsRediscoveredMsg(msg)
 	case tableCountedMsg:
 		return m.handleTableCountedMsg(msg)
 	case countProgressMsg:
@@ -701,6 +833,8 @@ func (m progressModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.handleStageTickMsg(msg)
 	case sliceStartMsg:
 		return m.handleSliceStartMsg(msg)
+	case sliceRetriedMsg:
+		return m.handleSliceRetriedMsg(msg)
 	case sliceCompleteMsg:
 		return m.handleSliceCompleteMsg(msg)
 	}
@@ -708,6 +842,10 @@ func (m progressModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m progressModel) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.log != nil && m.log.searching && msg.String() != "ctrl+c" {
+		m.log.handleSearchKey(msg)
+		return m, nil
+	}
 	if msg.String() == "ctrl+c" || msg.String() == "q" {
 		m.done = true
 		// Cancel the context to stop all archival operations
@@ -716,6 +854,16 @@ func (m progressModel) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, tea.Quit
 	}
+	if msg.String() == logPaneKey {
+		m.log.toggle()
+		return m, nil
+	}
+	if m.log != nil && m.log.open {
+		return m, m.log.handleKey(msg)
+	}
+	if m.phase == PhaseProcessing && m.archiver != nil {
+		return m.handleControlKey(msg.String())
+	}
 	return m, nil
 }
 
@@ -724,6 +872,7 @@ func (m progressModel) handleWindowSizeMsg(msg tea.WindowSizeMsg) (tea.Model, te
 	m.height = msg.Height
 	m.currentProgress.Width = msg.Width - 10
 	m.overallProgress.Width = msg.Width - 10
+	m.log.resize(msg.Width, msg.Height)
 	return m, nil
 }
 
@@ -753,7 +902,7 @@ func (m progressModel) handleProgressFrameMsg(msg progress.FrameMsg) (tea.Model,
 }
 
 func (m progressModel) handlePhaseMsg(msg phaseMsg) (tea.Model, tea.Cmd) {
-	m.phase = msg.phase
+	m.setPhase(msg.phase)
 	m.currentStage = msg.message
 
 	// Handle phase transitions
@@ -785,32 +934,29 @@ func (m progressModel) handlePhaseMsg(msg phaseMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m progressModel) handleMessageMsg(msg messageMsg) (tea.Model, tea.Cmd) {
-	m.messages = append(m.messages, string(msg))
-	if len(m.messages) > 10 {
-		m.messages = m.messages[len(m.messages)-10:]
-	}
+	m.addMessage(string(msg))
 
 	msgStr := string(msg)
+	if m.archiver != nil {
+		m.archiver.emitEvent(progressEvent{Type: progressEventMessage, Message: msgStr})
+	}
 
 	if strings.Contains(msgStr, "Starting archive process") {
-		m.phase = PhaseConnecting
+		m.setPhase(PhaseConnecting)
 		m.currentStage = "Connecting to database..."
 		m.updateTaskInfo()
 		return m, m.doConnect()
 	}
 
 	if strings.Contains(msgStr, "✅ Table permissions verified") && m.phase == PhaseCheckingPermissions {
-		m.messages = append(m.messages, fmt.Sprintf("✅ Connected to S3 at s3://%s", m.config.S3.Bucket))
-		if len(m.messages) > 10 {
-			m.messages = m.messages[len(m.messages)-10:]
-		}
-		m.phase = PhaseDiscovering
+		m.addMessage(fmt.Sprintf("✅ Connected to S3 at s3://%s", m.config.S3.Bucket))
+		m.setPhase(PhaseDiscovering)
 		m.currentStage = "Discovering partitions..."
 		m.updateTaskInfo()
 		return m, m.doDiscover()
 	}
 
-	if strings.Contains(msgStr, "❌ Permission check failed") {
+	if strings.Contains(msgStr, "❌ Permission check failed") || strings.Contains(msgStr, "❌ Schema export failed") {
 		return m, tea.Sequence(tea.ExitAltScreen, tea.Quit)
 	}
 
@@ -818,19 +964,15 @@ func (m progressModel) handleMessageMsg(msg messageMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m progressModel) handleConnectedMsg(msg connectedMsg) (tea.Model, tea.Cmd) {
-	m.messages = append(m.messages, fmt.Sprintf("✅ Connected to PostgreSQL at %s", msg.host))
+	m.addMessage(fmt.Sprintf("✅ Connected to PostgreSQL at %s", msg.host))
 
 	if m.config.CacheViewer {
 		startBackgroundServices()
 		go m.startCacheViewerServer()
-		m.messages = append(m.messages, fmt.Sprintf("🌐 Cache viewer started at http://localhost:%d", m.config.ViewerPort))
-	}
-
-	if len(m.messages) > 10 {
-		m.messages = m.messages[len(m.messages)-10:]
+		m.addMessage(fmt.Sprintf("🌐 Cache viewer started at http://localhost:%d", m.config.ViewerPort))
 	}
 
-	m.phase = PhaseCheckingPermissions
+	m.setPhase(PhaseCheckingPermissions)
 	m.currentStage = "Checking table permissions..."
 	m.updateTaskInfo()
 	return m, m.doCheckPermissions()
@@ -858,10 +1000,7 @@ func (m progressModel) startCacheViewerServer() {
 }
 
 func (m progressModel) handleDiscoveredTablesMsg(msg discoveredTablesMsg) (tea.Model, tea.Cmd) {
-	m.messages = append(m.messages, fmt.Sprintf("📊 Found %d partitions to process", len(msg.tables)))
-	if len(m.messages) > 10 {
-		m.messages = m.messages[len(m.messages)-10:]
-	}
+	m.addMessage(fmt.Sprintf("📊 Found %d partitions to process", len(msg.tables)))
 
 	// Update task info with total partitions discovered
 	if m.taskInfo != nil {
@@ -900,14 +1039,14 @@ func (m progressModel) handleDiscoveredTablesMsg(msg discoveredTablesMsg) (tea.M
 func (m progressModel) handlePartitionsFoundMsg(msg partitionsFoundMsg) (tea.Model, tea.Cmd) {
 	if m.phase == PhaseCounting {
 		m.countProgress = m.countTotal
-		m.messages = append(m.messages, fmt.Sprintf("✅ Finished counting rows in %d partitions", len(msg.partitions)))
-		if len(m.messages) > 10 {
-			m.messages = m.messages[len(m.messages)-10:]
-		}
+		m.addMessage(fmt.Sprintf("✅ Finished counting rows in %d partitions", len(msg.partitions)))
 	}
 
 	m.partitions = msg.partitions
-	m.phase = PhaseProcessing
+	if m.archiver != nil {
+		m.rediscovery = m.archiver.newPartitionRediscovery(msg.partitions)
+	}
+	m.setPhase(PhaseProcessing)
 	m.results = make([]ProcessResult, 0, len(msg.partitions))
 	m.currentIndex = 0
 	m.currentStage = ""
@@ -917,21 +1056,15 @@ func (m progressModel) handlePartitionsFoundMsg(msg partitionsFoundMsg) (tea.Mod
 		_ = WriteTaskInfo(m.taskInfo)
 	}
 
-	m.messages = append(m.messages, fmt.Sprintf("🚀 Starting to process %d partitions", len(msg.partitions)))
-	if len(m.messages) > 10 {
-		m.messages = m.messages[len(m.messages)-10:]
-	}
+	m.addMessage(fmt.Sprintf("🚀 Starting to process %d partitions", len(msg.partitions)))
 
 	if len(msg.partitions) == 1 && msg.partitions[0].HasCustomRange() {
 		inclusiveEnd := msg.partitions[0].RangeEnd.Add(-24 * time.Hour).Format("2006-01-02")
-		m.messages = append(m.messages, fmt.Sprintf("📆 %s is not partitioned; archiving rows from %s to %s via %s windows",
+		m.addMessage(fmt.Sprintf("📆 %s is not partitioned; archiving rows from %s to %s via %s windows",
 			msg.partitions[0].TableName,
 			msg.partitions[0].RangeStart.Format("2006-01-02"),
 			inclusiveEnd,
 			m.config.OutputDuration))
-		if len(m.messages) > 10 {
-			m.messages = m.messages[len(m.messages)-10:]
-		}
 	}
 
 	if len(msg.partitions) > 0 {
@@ -945,6 +1078,23 @@ func (m progressModel) handlePartitionsFoundMsg(msg partitionsFoundMsg) (tea.Mod
 	return m, nil
 }
 
+func (m progressModel) handlePartitionsRediscoveredMsg(msg partitionsRediscoveredMsg) (tea.Model, tea.Cmd) {
+	if len(msg.partitions) == 0 {
+		if msg.final {
+			return m, m.finishProcessing()
+		}
+		return m, m.processNext()
+	}
+
+	m.partitions = append(m.partitions, msg.partitions...)
+	m.addMessage(fmt.Sprintf("🔎 Found %d new partitions since the last discovery", len(msg.partitions)))
+	if m.taskInfo != nil {
+		m.taskInfo.TotalPartitions = len(m.partitions)
+		_ = WriteTaskInfo(m.taskInfo)
+	}
+	return m, m.processNext()
+}
+
 func (m progressModel) handleTableCountedMsg(msg tableCountedMsg) (tea.Model, tea.Cmd) {
 	if msg.count >= 0 {
 		m.countedPartitions = append(m.countedPartitions, PartitionInfo{
@@ -984,6 +1134,7 @@ func (m progressModel) handleProgressMsg(msg progressMsg) (tea.Model, tea.Cmd) {
 
 func (m progressModel) handlePartitionCompleteMsg(msg partitionCompleteMsg) (tea.Model, tea.Cmd) {
 	m.results = append(m.results, msg.result)
+	m.logResult(msg.result.Partition.TableName, msg.result)
 	m.currentIndex = msg.index + 1
 	m.updateTaskInfo()
 
@@ -1009,7 +1160,7 @@ func (m progressModel) handlePartitionCompleteMsg(msg partitionCompleteMsg) (tea
 }
 
 func (m progressModel) handleAllCompleteMsg(_ allCompleteMsg) (tea.Model, tea.Cmd) {
-	m.phase = PhaseComplete
+	m.setPhase(PhaseComplete)
 	m.done = true
 	// Don't use ExitAltScreen so the completion summary stays visible
 	return m, tea.Quit
@@ -1055,6 +1206,7 @@ func (m progressModel) handleSliceStartMsg(msg sliceStartMsg) (tea.Model, tea.Cm
 func (m progressModel) handleSliceCompleteMsg(msg sliceCompleteMsg) (tea.Model, tea.Cmd) {
 	// Store slice result for display in Recent Results
 	m.sliceResults.append(msg.sliceDate, msg.result)
+	m.logResult(fmt.Sprintf("%s slice %s", msg.result.Partition.TableName, msg.sliceDate), msg.result)
 
 	// Increment total slices processed if slice was successfully processed
 	if msg.success {
@@ -1216,6 +1368,11 @@ func (m progressModel) renderProcessingPhase() []string {
 			sections = append(sections, "   "+viewSliceProgress)
 		}
 
+		if archivePause.paused() {
+			sections = append(sections, "")
+			sections = append(sections, stageStyle.Render(fmt.Sprintf("   ⏸️  Paused: no new partition or slice starts until the run resumes ('%s' to resume a keyboard pause)", pauseKey)))
+		}
+
 		if m.currentStage != "" {
 			stageInfo := fmt.Sprintf("   %s %s", m.currentSpinner.View(), m.currentStage)
 			sections = append(sections, "")
@@ -1282,7 +1439,7 @@ func (m progressModel) renderProcessingSummary() []string {
 		}
 
 		// Show recent slice results (current partition's in-progress work) only if date-column is configured
-		if m.config.DateColumn != "" {
+		if m.config.canSliceByTime() {
 			recentSlices := m.sliceResults.getRecent(maxRecentSlices)
 			for _, sliceRes := range recentSlices {
 				line := m.formatResultLine(sliceRes.date, sliceRes.result)
@@ -1453,7 +1610,13 @@ func (m progressModel) renderCompletionSummary() []string {
 		}
 		if m.config.Compression != "" {
 			compStr := strings.ToUpper(m.config.Compression)
-			if m.config.CompressionLevel > 0 {
+			if m.config.AdaptiveCompression {
+				maxLevel := m.config.CompressionLevelMax
+				if maxLevel == 0 {
+					maxLevel = m.config.CompressionLevel
+				}
+				compStr += fmt.Sprintf(" (adaptive %d-%d)", m.config.CompressionLevelMin, maxLevel)
+			} else if m.config.CompressionLevel > 0 {
 				compStr += fmt.Sprintf(" (level %d)", m.config.CompressionLevel)
 			}
 			configParts = append(configParts, fmt.Sprintf("Compression: %s", compStr))
@@ -1546,6 +1709,11 @@ func formatFloat(f float64) string {
 func (m progressModel) View() string {
 	var sections []string
 
+	// The log pane takes the place of the main view while open
+	if m.log != nil && m.log.open && !m.done {
+		return lipgloss.JoinVertical(lipgloss.Left, m.log.view()...)
+	}
+
 	// Render banner
 	sections = append(sections, m.renderBanner()...)
 
@@ -1570,7 +1738,10 @@ func (m progressModel) View() string {
 
 		// Help text (only show during processing, not on completion)
 		sections = append(sections, "")
-		sections = append(sections, helpStyle.Render("   Press Ctrl+C or 'q' to quit"))
+		if m.phase == PhaseProcessing {
+			sections = append(sections, helpStyle.Render(fmt.Sprintf("   Press '%s' to skip the partition, '%s' to retry the last failed slice, '%s' to pause or resume", skipPartitionKey, retrySliceKey, pauseKey)))
+		}
+		sections = append(sections, helpStyle.Render(fmt.Sprintf("   Press '%s' for the full log, Ctrl+C or 'q' to quit", logPaneKey)))
 	}
 
 	return lipgloss.JoinVertical(lipgloss.Left, sections...)
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/viper"
+)
+
+// Progress event types written to --progress-file
+const (
+	progressEventRunStart          = "run_start"
+	progressEventRunEnd            = "run_end"
+	progressEventPhase             = "phase"
+	progressEventMessage           = "message"
+	progressEventProgress          = "progress"
+	progressEventPartitionStart    = "partition_start"
+	progressEventPartitionComplete = "partition_complete"
+	progressEventSliceStart        = "slice_start"
+	progressEventSliceComplete     = "slice_complete"
+)
+
+var progressFile string
+
+func init() {
+	archiveCmd.Flags().StringVar(&progressFile, "progress-file", "", "append progress events (phases, partitions, slices, bytes, errors) to this file as JSON lines for external dashboards")
+	_ = viper.BindPFlag("progress_file", archiveCmd.Flags().Lookup("progress-file"))
+}
+
+// progressEvent is one line of a progress file. It mirrors what the TUI shows
+// so a run can be followed by tailing the file, with or without the TUI.
+type progressEvent struct {
+	Time        time.Time `json:"time"`
+	Type        string    `json:"type"`
+	RunID       string    `json:"run_id,omitempty"`
+	Table       string    `json:"table,omitempty"`
+	Phase       string    `json:"phase,omitempty"`
+	Message     string    `json:"message,omitempty"`
+	Partition   string    `json:"partition,omitempty"`
+	Slice       string    `json:"slice,omitempty"`        // Start date of the slice
+	SliceNumber int       `json:"slice_number,omitempty"` // 1-based position within the partition
+	SliceCount  int       `json:"slice_count,omitempty"`
+	Stage       string    `json:"stage,omitempty"`
+	Current     int64     `json:"current,omitempty"`
+	Total       int64     `json:"total,omitempty"`
+	Rows        int64     `json:"rows,omitempty"`
+	Bytes       int64     `json:"bytes,omitempty"`
+	S3Key       string    `json:"s3_key,omitempty"`
+	Skipped     bool      `json:"skipped,omitempty"`
+	SkipReason  string    `json:"skip_reason,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Status      string    `json:"status,omitempty"`
+	DurationMs  int64     `json:"duration_ms,omitempty"`
+}
+
+// progressEventLog appends progress events to an ndjson file. Each event is
+// written with a single write so concurrent tables never interleave lines.
+// With --log-target syslog or journald, events are also sent there.
+type progressEventLog struct {
+	mu   sync.Mutex
+	file *os.File // nil when events only go to the log sink
+	sink logSink
+}
+
+// openProgressEventLog opens path for appending. It returns nil when path is
+// empty and logs do not go to syslog or journald.
+func openProgressEventLog(path string) (*progressEventLog, error) {
+	sink := currentLogSink()
+	if path == "" {
+		if sink == nil {
+			return nil, nil
+		}
+		return &progressEventLog{sink: sink}, nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open progress file: %w", err)
+	}
+	return &progressEventLog{file: file, sink: sink}, nil
+}
+
+// eventPriority returns the syslog severity of a progress event
+func eventPriority(event progressEvent) int {
+	if event.Error != "" {
+		return syslogPriorityErr
+	}
+	return syslogPriorityInfo
+}
+
+// emit appends an event, stamping it with the current time and run ID.
+// Progress events are best effort, so write errors are ignored.
+func (l *progressEventLog) emit(event progressEvent) {
+	if l == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	if event.RunID == "" {
+		event.RunID = currentRunID
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		_, _ = l.file.Write(data)
+	}
+	if l.sink != nil {
+		_ = l.sink.writeEntry(eventPriority(event), data[:len(data)-1])
+	}
+}
+
+// close closes the progress file
+func (l *progressEventLog) close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil // The sink belongs to the logger
+	}
+	return l.file.Close()
+}
+
+// String returns the phase name used in progress events
+func (p Phase) String() string {
+	switch p {
+	case PhaseConnecting:
+		return "connecting"
+	case PhaseCheckingPermissions:
+		return "checking_permissions"
+	case PhaseDiscovering:
+		return "discovering"
+	case PhaseCounting:
+		return "counting"
+	case PhaseProcessing:
+		return "processing"
+	case PhaseComplete:
+		return "complete"
+	default:
+		return fmt.Sprintf("phase_%d", int(p))
+	}
+}
+
+// emitEvent writes an event for the archiver's table to the progress file
+// and to --progress plain output
+func (a *Archiver) emitEvent(event progressEvent) {
+	a.plain.observe(event)
+	if a.events == nil {
+		return
+	}
+	event.Table = a.config.Table
+	a.events.emit(event)
+}
+
+// emitPhase reports a phase change
+func (a *Archiver) emitPhase(phase Phase) {
+	a.emitEvent(progressEvent{Type: progressEventPhase, Phase: phase.String()})
+}
+
+// emitRunEnd reports the run's final status
+func (a *Archiver) emitRunEnd(runErr error) {
+	event := progressEvent{Type: progressEventRunEnd, Status: runStatusFromError(runErr)}
+	if runErr != nil {
+		event.Error = runErr.Error()
+	}
+	a.emitEvent(event)
+}
+
+// emitResult reports a finished partition or slice
+func (a *Archiver) emitResult(eventType string, result ProcessResult, event progressEvent) {
+	event.Type = eventType
+	event.Partition = result.Partition.TableName
+	event.Bytes = result.BytesWritten
+	event.S3Key = result.S3Key
+	event.Skipped = result.Skipped
+	event.SkipReason = result.SkipReason
+	event.Stage = result.Stage
+	event.DurationMs = result.Duration.Milliseconds()
+	if eventType == progressEventPartitionComplete {
+		event.Rows = result.Partition.RowCount
+	}
+	if result.Error != nil {
+		event.Error = result.Error.Error()
+	}
+	a.emitEvent(event)
+}
+
+// sendProgress reports a partition's stage and row progress to the TUI, when
+// one is running, and to the progress file
+func (a *Archiver) sendProgress(program *tea.Program, partition, stage string, current, total int64) {
+	a.emitEvent(progressEvent{Type: progressEventProgress, Partition: partition, Stage: stage, Current: current, Total: total})
+	if program != nil {
+		program.Send(updateProgress(stage, current, total))
+	}
+}
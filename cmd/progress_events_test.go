@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// readProgressEvents parses every line of a progress file
+func readProgressEvents(t *testing.T, path string) []progressEvent {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open progress file: %v", err)
+	}
+	defer file.Close()
+
+	var events []progressEvent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event progressEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid progress line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestProgressEventLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	events, err := openProgressEventLog(path)
+	if err != nil {
+		t.Fatalf("openProgressEventLog failed: %v", err)
+	}
+
+	archiver := NewArchiver(&Config{Table: "events"}, newTestLogger())
+	archiver.events = events
+	archiver.emitEvent(progressEvent{Type: progressEventRunStart})
+	archiver.emitPhase(PhaseDiscovering)
+	archiver.sendProgress(nil, "events_20240101", "Extracting data...", 1000, 5000)
+	archiver.emitResult(progressEventPartitionComplete, ProcessResult{
+		Partition:    PartitionInfo{TableName: "events_20240101", RowCount: 5000},
+		BytesWritten: 2048,
+		S3Key:        "events/2024/01/01.jsonl.zst",
+		Stage:        "Complete",
+		Duration:     1500 * time.Millisecond,
+	}, progressEvent{})
+	archiver.emitRunEnd(errors.New("upload failed"))
+	if err := events.close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	got := readProgressEvents(t, path)
+	if len(got) != 5 {
+		t.Fatalf("expected 5 events, got %d: %+v", len(got), got)
+	}
+	for _, event := range got {
+		if event.Table != "events" || event.Time.IsZero() {
+			t.Errorf("event missing table or time: %+v", event)
+		}
+	}
+	if got[1].Type != progressEventPhase || got[1].Phase != "discovering" {
+		t.Errorf("unexpected phase event %+v", got[1])
+	}
+	if got[2].Type != progressEventProgress || got[2].Current != 1000 || got[2].Total != 5000 || got[2].Partition != "events_20240101" {
+		t.Errorf("unexpected progress event %+v", got[2])
+	}
+	if got[3].Bytes != 2048 || got[3].Rows != 5000 || got[3].DurationMs != 1500 || got[3].S3Key == "" {
+		t.Errorf("unexpected partition event %+v", got[3])
+	}
+	if got[4].Type != progressEventRunEnd || got[4].Status != runStatusFromError(errors.New("x")) || got[4].Error != "upload failed" {
+		t.Errorf("unexpected run end event %+v", got[4])
+	}
+
+	// Reopening appends rather than truncating
+	events, err = openProgressEventLog(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	events.emit(progressEvent{Type: progressEventMessage, Message: "again"})
+	_ = events.close()
+	if got := readProgressEvents(t, path); len(got) != 6 {
+		t.Errorf("expected 6 events after reopening, got %d", len(got))
+	}
+}
+
+func TestProgressEventLogDisabled(t *testing.T) {
+	events, err := openProgressEventLog("")
+	if err != nil || events != nil {
+		t.Fatalf("expected no log for an empty path, got %v, %v", events, err)
+	}
+	events.emit(progressEvent{Type: progressEventMessage})
+	if err := events.close(); err != nil {
+		t.Errorf("close on nil log = %v", err)
+	}
+
+	// An archiver without a progress file ignores events
+	archiver := NewArchiver(&Config{Table: "events"}, newTestLogger())
+	archiver.emitPhase(PhaseProcessing)
+	archiver.sendProgress(nil, "events_20240101", "Uploading to S3...", 0, 100)
+
+	if _, err := openProgressEventLog(filepath.Join(t.TempDir(), "missing", "events.ndjson")); err == nil {
+		t.Error("expected an error for an unwritable path")
+	}
+}
+
+func TestPhaseString(t *testing.T) {
+	for phase, want := range map[Phase]string{
+		PhaseConnecting:          "connecting",
+		PhaseCheckingPermissions: "checking_permissions",
+		PhaseDiscovering:         "discovering",
+		PhaseCounting:            "counting",
+		PhaseProcessing:          "processing",
+		PhaseComplete:            "complete",
+	} {
+		if got := phase.String(); got != want {
+			t.Errorf("Phase(%d).String() = %q, want %q", int(phase), got, want)
+		}
+	}
+}
+
+func TestProcessPartitionWithSplitEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	calendar := newSkipCalendar()
+	if err := calendar.addEntry("2024-03-01..2024-03-02 Closed"); err != nil {
+		t.Fatal(err)
+	}
+	archiver := NewArchiver(&Config{
+		Table:          "events",
+		DateColumn:     "created_at",
+		OutputDuration: DurationDaily,
+		Calendar:       calendar,
+		StartDate:      "2024-03-01",
+		EndDate:        "2024-03-02",
+	}, newTestLogger())
+	archiver.db = db
+	archiver.ctx = context.Background()
+
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	archiver.events, err = openProgressEventLog(path)
+	if err != nil {
+		t.Fatalf("openProgressEventLog failed: %v", err)
+	}
+
+	// Slice events are written even without the TUI
+	archiver.processPartitionWithSplit(PartitionInfo{
+		TableName: "events_2024_03",
+		Date:      time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}, nil)
+	_ = archiver.events.close()
+
+	var starts, completes []progressEvent
+	for _, event := range readProgressEvents(t, path) {
+		switch event.Type {
+		case progressEventSliceStart:
+			starts = append(starts, event)
+		case progressEventSliceComplete:
+			completes = append(completes, event)
+		}
+	}
+	if len(starts) == 0 || len(starts) != len(completes) {
+		t.Fatalf("expected matching slice events, got %d starts and %d completes", len(starts), len(completes))
+	}
+	first := completes[0]
+	if first.Partition != "events_2024_03" || first.Slice != "2024-03-01" || first.SliceNumber != 1 ||
+		first.SliceCount != len(starts) || !first.Skipped || first.SkipReason != "Calendar: Closed" {
+		t.Errorf("unexpected slice complete event %+v", first)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unexpected queries: %v", err)
+	}
+}
@@ -0,0 +1,589 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Prune statuses
+const (
+	PruneStatusDropped            = "dropped"
+	PruneStatusTruncated          = "truncated"
+	PruneStatusPlanned            = "planned"
+	PruneStatusWithinRetention    = "within-retention"
+	PruneStatusAlreadyGone        = "already-gone"
+	PruneStatusVerificationFailed = "verification-failed"
+	PruneStatusError              = "error"
+)
+
+// Prune actions
+const (
+	PruneActionDrop     = "drop"
+	PruneActionTruncate = "truncate"
+)
+
+// Static errors for prune
+var (
+	ErrPruneRetentionRequired = errors.New("--retention-days must be at least 1")
+	ErrPruneActionInvalid     = errors.New("prune action must be one of: drop, truncate")
+	ErrPruneCustomQuery       = errors.New("prune cannot verify tables archived with a custom query")
+	ErrPruneFailed            = errors.New("prune left partitions in place")
+	ErrPruneRowsChanged       = errors.New("partition rows changed after verification")
+	ErrPruneOutcomeUnknown    = errors.New("commit failed, the action may or may not have committed")
+)
+
+var (
+	pruneRetentionDays int
+	pruneAction        string
+	pruneConfirm       bool
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Drop or truncate source partitions that are archived and verified",
+	Long: `Free space by removing source partitions once they are archived. Partitions are found in the archive
+cache for the table and path template, and only those entirely older than --retention-days are pruned.
+Before pruning, every archived file of a partition is verified like the verify command does (row counts
+against the live partition and a HEAD of each object), and the partition's total row count must match the
+rows archived from it. Attached partitions are detached from their parent before they are dropped.
+Nothing is changed without --confirm; with --dry-run or without --confirm, the plan is printed.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		runPrune(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+
+	// Database flags
+	pruneCmd.Flags().StringVar(&dbHost, "db-host", "localhost", "PostgreSQL host")
+	pruneCmd.Flags().IntVar(&dbPort, "db-port", 5432, "PostgreSQL port")
+	pruneCmd.Flags().StringVar(&dbUser, "db-user", "", "PostgreSQL user")
+	pruneCmd.Flags().StringVar(&dbPassword, "db-password", "", "PostgreSQL password")
+	pruneCmd.Flags().StringVar(&dbName, "db-name", "", "PostgreSQL database name")
+	pruneCmd.Flags().StringVar(&dbSSLMode, "db-sslmode", "disable", "PostgreSQL SSL mode (disable, require, verify-ca, verify-full)")
+	pruneCmd.Flags().IntVar(&dbStatementTimeout, "db-statement-timeout", 300, "PostgreSQL statement timeout in seconds (0 = no timeout)")
+
+	// S3 flags
+	pruneCmd.Flags().StringVar(&s3Endpoint, "s3-endpoint", "", "S3-compatible endpoint URL")
+	pruneCmd.Flags().StringVar(&s3Bucket, "s3-bucket", "", "S3 bucket name")
+	pruneCmd.Flags().StringVar(&s3AccessKey, "s3-access-key", "", "S3 access key")
+	pruneCmd.Flags().StringVar(&s3SecretKey, "s3-secret-key", "", "S3 secret key")
+	pruneCmd.Flags().StringVar(&s3Region, "s3-region", "auto", "S3 region")
+
+	// Prune-specific flags
+	pruneCmd.Flags().StringVar(&baseTable, "table", "", "base table name (required)")
+	pruneCmd.Flags().StringVar(&pathTemplate, "path-template", "", "S3 path template used when archiving (required, selects the archive cache)")
+	pruneCmd.Flags().StringVar(&dateColumn, "date-column", "", "timestamp column used when archiving sliced files")
+	pruneCmd.Flags().IntVar(&pruneRetentionDays, "retention-days", 0, "keep partitions holding data from the last N days (required)")
+	pruneCmd.Flags().StringVar(&pruneAction, "action", PruneActionDrop, "what to do with a verified partition: drop (detach and drop) or truncate")
+	pruneCmd.Flags().BoolVar(&pruneConfirm, "confirm", false, "actually drop or truncate partitions (without it, only the plan is printed)")
+
+	_ = viper.BindPFlag("prune.retention_days", pruneCmd.Flags().Lookup("retention-days"))
+	_ = viper.BindPFlag("prune.action", pruneCmd.Flags().Lookup("action"))
+	_ = viper.BindPFlag("prune.confirm", pruneCmd.Flags().Lookup("confirm"))
+}
+
+// PruneResult is the outcome for one source partition
+type PruneResult struct {
+	Partition    string
+	Files        int
+	ArchivedRows int64
+	LiveRows     int64
+	Status       string
+	Message      string
+}
+
+// Pruner drops or truncates archived partitions after verifying them
+type Pruner struct {
+	config        *Config
+	verifier      *Verifier
+	retentionDays int
+	action        string
+	execute       bool // Drop or truncate; otherwise only plan
+	intents       *intentLog
+	now           func() time.Time
+	logger        *slog.Logger
+}
+
+// NewPruner creates a new Pruner instance
+func NewPruner(config *Config, retentionDays int, action string, execute bool, logger *slog.Logger) *Pruner {
+	return &Pruner{
+		config:        config,
+		verifier:      NewVerifier(config, false, logger),
+		retentionDays: retentionDays,
+		action:        action,
+		execute:       execute,
+		intents:       newIntentLog(config),
+		now:           time.Now,
+		logger:        logger,
+	}
+}
+
+func runPrune(cmd *cobra.Command) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "\n❌ PANIC: %v\n", r)
+			os.Exit(1)
+		}
+	}()
+
+	// Helper function to get config value: use flag if set, otherwise use viper
+	getStringConfig := func(flagValue string, flagName string, viperKey string) string {
+		if flag := cmd.Flags().Lookup(flagName); flag != nil && flag.Changed {
+			return flagValue
+		}
+		if viperValue := viper.GetString(viperKey); viperValue != "" {
+			return viperValue
+		}
+		return flagValue
+	}
+	getIntConfig := func(flagValue int, flagName string, viperKey string) int {
+		if flag := cmd.Flags().Lookup(flagName); flag != nil && flag.Changed {
+			return flagValue
+		}
+		if viperValue := viper.GetInt(viperKey); viperValue != 0 {
+			return viperValue
+		}
+		return flagValue
+	}
+
+	config := &Config{
+		Debug:     viper.GetBool("debug"),
+		LogFormat: viper.GetString("log_format"),
+		DryRun:    viper.GetBool("dry_run"),
+		Database: DatabaseConfig{
+			Host:             getStringConfig(dbHost, "db-host", "db.host"),
+			Port:             getIntConfig(dbPort, "db-port", "db.port"),
+			User:             getStringConfig(dbUser, "db-user", "db.user"),
+			Password:         getStringConfig(dbPassword, "db-password", "db.password"),
+			Name:             getStringConfig(dbName, "db-name", "db.name"),
+			SSLMode:          getStringConfig(dbSSLMode, "db-sslmode", "db.sslmode"),
+			StatementTimeout: getIntConfig(dbStatementTimeout, "db-statement-timeout", "db.statement_timeout"),
+		},
+		S3: S3Config{
+			Endpoint:     getStringConfig(s3Endpoint, "s3-endpoint", "s3.endpoint"),
+			Bucket:       getStringConfig(s3Bucket, "s3-bucket", "s3.bucket"),
+			AccessKey:    getStringConfig(s3AccessKey, "s3-access-key", "s3.access_key"),
+			SecretKey:    getStringConfig(s3SecretKey, "s3-secret-key", "s3.secret_key"),
+			Region:       getStringConfig(s3Region, "s3-region", "s3.region"),
+			PathTemplate: getStringConfig(pathTemplate, "path-template", "s3.path_template"),
+		},
+		Table:        getStringConfig(baseTable, "table", "table"),
+		DateColumn:   getStringConfig(dateColumn, "date-column", "date_column"),
+		TableQueries: loadTableQueries(),
+		IntentPrefix: viper.GetString("prune.intent_prefix"),
+
+		DateColumnType:   getStringConfig(dateColumnType, "date-column-type", "date_column_type"),
+		DateColumnFormat: getStringConfig(dateColumnFormat, "date-column-format", "date_column_format"),
+	}
+	retentionDays := viper.GetInt("prune.retention_days")
+	action := viper.GetString("prune.action")
+	execute := viper.GetBool("prune.confirm") && !config.DryRun
+
+	var s3Err error
+	config.S3, s3Err = newS3Config(config.S3, endpointProfileName("prune"))
+
+	// Prune reads the cache written by the archive command
+	config.CacheScope = NewCacheScope("archive", config)
+
+	initLogger(config.Debug, config.LogFormat)
+
+	logger.Info("")
+	logger.Info(fmt.Sprintf("✂️  Partition Pruner v%s", Version))
+	logger.Info("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+	if s3Err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", s3Err.Error()))
+		os.Exit(1)
+	}
+	if err := validatePruneConfig(config, retentionDays, action); err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+		os.Exit(1)
+	}
+	if !execute {
+		logger.Info("Dry run: no partition will be changed (pass --confirm to prune)")
+	}
+
+	ctx := signalContext
+	if ctx == nil {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+	}
+
+	stopStopFileWatcher := startStopFileWatcher("prune", config.Table)
+	defer stopStopFileWatcher()
+	logStopFileHint("prune")
+
+	pruner := NewPruner(config, retentionDays, action, execute, logger)
+	results, err := pruner.Run(ctx)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			logger.Info("")
+			logger.Info("⚠️  Prune cancelled by user")
+			os.Exit(130)
+		}
+		logger.Error(fmt.Sprintf("❌ Prune failed: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	if err := printPruneSummary(results, execute); err != nil {
+		logger.Error(fmt.Sprintf("❌ %s", err.Error()))
+		os.Exit(1)
+	}
+
+	logger.Info("")
+	logger.Info("✅ Prune completed successfully!")
+}
+
+// validatePruneConfig validates the verify settings plus the retention window and action
+func validatePruneConfig(config *Config, retentionDays int, action string) error {
+	if err := validateVerifyConfig(config); err != nil {
+		return err
+	}
+	if config.customQuery() != "" {
+		return ErrPruneCustomQuery
+	}
+	if retentionDays < 1 {
+		return ErrPruneRetentionRequired
+	}
+	if action != PruneActionDrop && action != PruneActionTruncate {
+		return fmt.Errorf("%w, got '%s'", ErrPruneActionInvalid, action)
+	}
+	if strings.Trim(config.IntentPrefix, "/") == "" {
+		return ErrIntentPrefixRequired
+	}
+	return nil
+}
+
+// Run verifies and prunes each partition recorded in the archive cache
+func (p *Pruner) Run(ctx context.Context) ([]PruneResult, error) {
+	archiver := p.verifier.archiver
+	archiver.ctx = ctx
+
+	cache, err := loadPartitionCache(p.config.CacheScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load archive cache: %w", err)
+	}
+
+	if err := archiver.connect(ctx); err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer archiver.db.Close()
+	p.verifier.client = archiver.s3Client
+
+	// Actions a crashed run left unfinished are settled first
+	if err := p.resolveIntents(ctx, archiver.db); err != nil {
+		return nil, err
+	}
+
+	partitions := groupEntriesByPartition(cache)
+	if len(partitions) == 0 {
+		p.logger.Info("No archived partitions found in cache for this table and path template")
+		return nil, nil
+	}
+
+	cutoff := p.now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -p.retentionDays)
+	p.logger.Info(fmt.Sprintf("Checking %d archived partition(s) older than %s...", len(partitions), cutoff.Format("2006-01-02")))
+
+	names := make([]string, 0, len(partitions))
+	for name := range partitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]PruneResult, 0, len(names))
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		result := p.prunePartition(ctx, archiver.db, name, partitions[name], cutoff)
+		results = append(results, result)
+
+		switch result.Status {
+		case PruneStatusVerificationFailed, PruneStatusError:
+			p.logger.Warn(fmt.Sprintf("  ❌ %s: %s", name, result.Message))
+		case PruneStatusDropped, PruneStatusTruncated, PruneStatusPlanned:
+			p.logger.Info(fmt.Sprintf("  ✂️  %s: %s", name, result.Message))
+		default:
+			p.logger.Debug(fmt.Sprintf("  ⏭️  %s: %s", name, result.Message))
+		}
+	}
+	return results, nil
+}
+
+// groupEntriesByPartition collects the uploaded cache entries of each source partition
+func groupEntriesByPartition(cache *PartitionCache) map[string][]PartitionCacheEntry {
+	partitions := make(map[string][]PartitionCacheEntry)
+	for _, entry := range cache.Entries {
+		if entry.S3Uploaded && entry.S3Key != "" && entry.SourceTable != "" {
+			partitions[entry.SourceTable] = append(partitions[entry.SourceTable], entry)
+		}
+	}
+	for _, entries := range partitions {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].S3Key < entries[j].S3Key })
+	}
+	return partitions
+}
+
+// partitionEnd returns the end of the day or month a partition holds, or
+// false when its name carries no date
+func (p *Pruner) partitionEnd(name string) (time.Time, bool) {
+	date, ok := p.verifier.archiver.extractDateFromTableName(name)
+	if !ok {
+		return time.Time{}, false
+	}
+	if suffix, _ := partitionSuffix(p.config.Table, name); len(suffix) == 7 {
+		return date.AddDate(0, 1, 0), true // {table}_YYYY_MM
+	}
+	return date.AddDate(0, 0, 1), true
+}
+
+// prunePartition checks the retention window, verifies every archived file
+// of the partition and its total row count, and then drops or truncates it
+func (p *Pruner) prunePartition(ctx context.Context, db *sql.DB, name string, entries []PartitionCacheEntry, cutoff time.Time) PruneResult {
+	result := PruneResult{Partition: name, Files: len(entries)}
+	for _, entry := range entries {
+		result.ArchivedRows += entry.ArchivedRowCount
+	}
+
+	end, ok := p.partitionEnd(name)
+	if !ok || end.After(cutoff) {
+		result.Status = PruneStatusWithinRetention
+		result.Message = fmt.Sprintf("holds data newer than %s", cutoff.Format("2006-01-02"))
+		if !ok {
+			result.Message = "partition name has no date"
+		}
+		return result
+	}
+
+	var exists bool
+	if err := db.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", pq.QuoteIdentifier(name)).Scan(&exists); err != nil {
+		result.Status = PruneStatusError
+		result.Message = fmt.Sprintf("failed to check partition: %v", err)
+		return result
+	}
+	if !exists {
+		result.Status = PruneStatusAlreadyGone
+		result.Message = "partition no longer exists"
+		return result
+	}
+
+	for _, entry := range entries {
+		verified := p.verifier.verifyCounts(ctx, db, entry)
+		if verified.Status == VerifyStatusOK {
+			p.verifier.verifyObject(ctx, entry, &verified)
+		}
+		if verified.Status != VerifyStatusOK {
+			result.Status = PruneStatusVerificationFailed
+			result.Message = fmt.Sprintf("%s: %s %s", entry.S3Key, verified.Status, verified.Message)
+			return result
+		}
+	}
+
+	liveRows, err := countLiveRows(ctx, db, name, DateColumnSpec{}, time.Time{}, time.Time{})
+	if err != nil {
+		result.Status = PruneStatusError
+		result.Message = err.Error()
+		return result
+	}
+	result.LiveRows = liveRows
+	if liveRows != result.ArchivedRows {
+		result.Status = PruneStatusVerificationFailed
+		result.Message = fmt.Sprintf("partition has %d rows but its archived files hold %d", liveRows, result.ArchivedRows)
+		return result
+	}
+
+	if !p.execute {
+		result.Status = PruneStatusPlanned
+		result.Message = fmt.Sprintf("would %s (%d rows in %d verified files)", p.action, liveRows, len(entries))
+		return result
+	}
+
+	// The intent is recorded before the action and its outcome after, so an
+	// interrupted action can be audited and resolved by the next run
+	intent := newPruneIntent(p.config.Table, name, p.action, liveRows, entries, p.now())
+	if err := p.intents.record(ctx, p.verifier.client, intent); err != nil {
+		result.Status = PruneStatusError
+		result.Message = fmt.Sprintf("nothing changed: %v", err)
+		return result
+	}
+	applyErr := p.apply(ctx, db, name, liveRows)
+	intent.State, intent.Message = p.outcome(ctx, db, intent, applyErr)
+	if intent.State == IntentStatePending {
+		result.Status = PruneStatusError
+		result.Message = fmt.Sprintf("%v; the intent stays pending and the next run resolves it", applyErr)
+		return result
+	}
+	intent.UpdatedAt = p.now().UTC()
+	if err := p.intents.record(ctx, p.verifier.client, intent); err != nil {
+		p.logger.Warn(fmt.Sprintf("  ⚠️  %s: failed to record the outcome, the next run resolves it: %v", name, err))
+	}
+	if intent.State != IntentStateCompleted {
+		result.Status = PruneStatusError
+		result.Message = applyErr.Error()
+		return result
+	}
+	result.Status = PruneStatusDropped
+	if p.action == PruneActionTruncate {
+		result.Status = PruneStatusTruncated
+	}
+	result.Message = fmt.Sprintf("%s (%d rows in %d verified files)", result.Status, liveRows, len(entries))
+	return result
+}
+
+// outcome returns the state to record for an applied intent. A failed
+// commit, or one cut short by cancellation, may have committed on the server,
+// so it is resolved from the partition itself; when that is not possible the
+// intent stays pending.
+func (p *Pruner) outcome(ctx context.Context, db *sql.DB, intent pruneIntent, applyErr error) (string, string) {
+	if applyErr == nil {
+		return IntentStateCompleted, ""
+	}
+	if !errors.Is(applyErr, ErrPruneOutcomeUnknown) && ctx.Err() == nil {
+		return IntentStateRolledBack, applyErr.Error()
+	}
+	if ctx.Err() != nil {
+		return IntentStatePending, ""
+	}
+	state, message, err := resolveIntent(ctx, db, intent)
+	if err != nil {
+		p.logger.Warn(fmt.Sprintf("  ⚠️  %s: %v", intent.Partition, err))
+		return IntentStatePending, ""
+	}
+	return state, "resolved after failed commit: " + message
+}
+
+// commitAction commits an action's transaction. The server may have
+// committed even when the commit fails, so its outcome is then unknown.
+func commitAction(tx *sql.Tx) error {
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %w", ErrPruneOutcomeUnknown, err)
+	}
+	return nil
+}
+
+// apply truncates the partition, or detaches it from its parent and drops
+// it, in one transaction. Materialized views and foreign tables are dropped
+// with their own statements; materialized views cannot be truncated.
+// Tables are locked and recounted first, so rows written since verification
+// roll the action back instead of being lost.
+func (p *Pruner) apply(ctx context.Context, db *sql.DB, name string, verifiedRows int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var relkind string
+	if err := tx.QueryRowContext(ctx, `SELECT relkind::text FROM pg_class WHERE oid = to_regclass($1)`, pq.QuoteIdentifier(name)).Scan(&relkind); err != nil {
+		return fmt.Errorf("failed to look up relation kind: %w", err)
+	}
+
+	// LOCK TABLE only accepts tables; materialized views and foreign tables
+	// are still recounted
+	if relkind == relkindTable || relkind == relkindPartitionedTable {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("LOCK TABLE %s IN ACCESS EXCLUSIVE MODE", pq.QuoteIdentifier(name))); err != nil {
+			return fmt.Errorf("failed to lock: %w", err)
+		}
+	}
+	var rows int64
+	//nolint:gosec // G201: identifiers are quoted via pq.QuoteIdentifier
+	if err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM %s", pq.QuoteIdentifier(name))).Scan(&rows); err != nil {
+		return fmt.Errorf("failed to recount rows: %w", err)
+	}
+	if rows != verifiedRows {
+		return fmt.Errorf("%w: %d rows now, %d verified", ErrPruneRowsChanged, rows, verifiedRows)
+	}
+
+	if p.action == PruneActionTruncate {
+		if relkind == relkindMatview {
+			return ErrMatviewTruncate
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("TRUNCATE %s", pq.QuoteIdentifier(name))); err != nil {
+			return fmt.Errorf("failed to truncate: %w", err)
+		}
+		return commitAction(tx)
+	}
+
+	var parent string
+	err = tx.QueryRowContext(ctx, `
+		SELECT p.relname
+		FROM pg_inherits i
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE i.inhrelid = to_regclass($1)`, pq.QuoteIdentifier(name)).Scan(&parent)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return fmt.Errorf("failed to look up parent table: %w", err)
+	default:
+		detach := fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s", pq.QuoteIdentifier(parent), pq.QuoteIdentifier(name))
+		if _, err := tx.ExecContext(ctx, detach); err != nil {
+			return fmt.Errorf("failed to detach from %s: %w", parent, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, dropRelationSQL(relkind, name)); err != nil {
+		return fmt.Errorf("failed to drop: %w", err)
+	}
+	return commitAction(tx)
+}
+
+// printPruneSummary logs per-status totals and returns an error if any old
+// enough partition could not be verified or pruned
+func printPruneSummary(results []PruneResult, execute bool) error {
+	counts := make(map[string]int)
+	var failed []string
+	var freedRows int64
+	for _, result := range results {
+		counts[result.Status]++
+		switch result.Status {
+		case PruneStatusVerificationFailed, PruneStatusError:
+			failed = append(failed, result.Partition)
+		case PruneStatusDropped, PruneStatusTruncated, PruneStatusPlanned:
+			freedRows += result.LiveRows
+		}
+	}
+
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	logger.Info("")
+	logger.Info("📊 Prune Summary")
+	logger.Info("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	logger.Info(fmt.Sprintf("  Partitions checked: %d", len(results)))
+	for _, status := range statuses {
+		logger.Info(fmt.Sprintf("  %-19s %d", status+":", counts[status]))
+	}
+	if execute {
+		logger.Info(fmt.Sprintf("  Rows removed:       %d", freedRows))
+	} else {
+		logger.Info(fmt.Sprintf("  Rows to remove:     %d", freedRows))
+	}
+
+	if len(failed) > 0 {
+		logger.Info("")
+		logger.Info("  Partitions left in place:")
+		for _, name := range failed {
+			logger.Info(fmt.Sprintf("    • %s", name))
+		}
+		return fmt.Errorf("%w: %d of %d partition(s)", ErrPruneFailed, len(failed), len(results))
+	}
+	return nil
+}
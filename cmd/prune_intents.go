@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/spf13/viper"
+)
+
+// defaultIntentPrefix is where prune intent records are kept in the bucket
+const defaultIntentPrefix = "_data-archiver/intents"
+
+// Intent states
+const (
+	IntentStatePending    = "pending"     // Recorded before the action, which may or may not have run
+	IntentStateCompleted  = "completed"   // The action committed
+	IntentStateRolledBack = "rolled-back" // The action failed or was interrupted and its transaction rolled back
+)
+
+// ErrIntentPrefixRequired is returned for an empty --intent-prefix
+var ErrIntentPrefixRequired = errors.New("intent prefix is required")
+
+var pruneIntentPrefix string
+
+func init() {
+	pruneCmd.Flags().StringVar(&pruneIntentPrefix, "intent-prefix", defaultIntentPrefix, "bucket prefix for the intent records written before and after each drop or truncate")
+	_ = viper.BindPFlag("prune.intent_prefix", pruneCmd.Flags().Lookup("intent-prefix"))
+}
+
+// intentArchive is an archive file that justifies a destructive action
+type intentArchive struct {
+	Key  string `json:"key"`
+	MD5  string `json:"md5"`
+	Size int64  `json:"size"`
+	Rows int64  `json:"rows"`
+}
+
+// pruneIntent is written before a partition is dropped or truncated, naming
+// the partition, the verified archives that justify the action, and their
+// checksums. It is written again with the outcome, so an action interrupted
+// between the two is found and resolved by the next run.
+type pruneIntent struct {
+	RunID     string          `json:"run_id"`
+	Table     string          `json:"table"`
+	Partition string          `json:"partition"`
+	Action    string          `json:"action"`
+	Rows      int64           `json:"rows"` // Live rows, verified against the archives
+	Archives  []intentArchive `json:"archives"`
+	State     string          `json:"state"`
+	Message   string          `json:"message,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// newPruneIntent records the plan to prune a verified partition
+func newPruneIntent(table, partition, action string, rows int64, entries []PartitionCacheEntry, now time.Time) pruneIntent {
+	intent := pruneIntent{
+		RunID:     currentRunID,
+		Table:     table,
+		Partition: partition,
+		Action:    action,
+		Rows:      rows,
+		State:     IntentStatePending,
+		CreatedAt: now.UTC(),
+		UpdatedAt: now.UTC(),
+	}
+	for _, entry := range entries {
+		intent.Archives = append(intent.Archives, intentArchive{Key: entry.S3Key, MD5: entry.FileMD5, Size: entry.FileSize, Rows: entry.ArchivedRowCount})
+	}
+	return intent
+}
+
+// name identifies the intent within its table's folders
+func (i pruneIntent) name() string {
+	return objectKeyComponent(i.Partition) + "-" + i.RunID + ".json"
+}
+
+// intentLog keeps a table's intent records: every state is appended to a
+// local log, and the bucket holds the latest state of each intent, under
+// pending/ until the outcome is known and under done/ after
+type intentLog struct {
+	bucket string
+	prefix string // <intent prefix>/<table>
+	path   string
+}
+
+func newIntentLog(config *Config) *intentLog {
+	return &intentLog{
+		bucket: config.S3.Bucket,
+		prefix: path.Join(strings.Trim(config.IntentPrefix, "/"), objectKeyComponent(config.Table)),
+		path:   getIntentLogPath(config.S3.Bucket, config.Table),
+	}
+}
+
+// getIntentLogPath returns the local intent log of a table for bucket
+func getIntentLogPath(bucket, table string) string {
+	name := fmt.Sprintf("%s_%s.jsonl", sanitizeCacheComponent(bucket, "bucket"), tableFileComponent(table, "table"))
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".data-archiver", "intents", name)
+}
+
+func (l *intentLog) pendingKey(intent pruneIntent) string {
+	return path.Join(l.prefix, "pending", intent.name())
+}
+
+func (l *intentLog) doneKey(intent pruneIntent) string {
+	return path.Join(l.prefix, "done", intent.name())
+}
+
+// record appends the intent's state to the local log and writes it to the
+// bucket. A finished intent replaces its pending record.
+func (l *intentLog) record(ctx context.Context, client StorageBackend, intent pruneIntent) error {
+	line, err := json.Marshal(intent)
+	if err != nil {
+		return fmt.Errorf("failed to encode intent: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open intent log: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write intent log: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write intent log: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write intent log: %w", err)
+	}
+
+	data, err := json.MarshalIndent(intent, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode intent: %w", err)
+	}
+	key := l.pendingKey(intent)
+	if intent.State != IntentStatePending {
+		key = l.doneKey(intent)
+	}
+	_, err = client.Put(ctx, l.bucket, key, bytes.NewReader(data), PutOptions{ContentType: "application/json"})
+	if err != nil {
+		return fmt.Errorf("failed to write intent %s: %w", key, err)
+	}
+	if intent.State == IntentStatePending {
+		return nil
+	}
+	if err := client.Delete(ctx, l.bucket, l.pendingKey(intent)); err != nil {
+		return fmt.Errorf("failed to remove pending intent %s: %w", l.pendingKey(intent), err)
+	}
+	return nil
+}
+
+// pending returns the table's intents whose outcome was never recorded,
+// oldest first
+func (l *intentLog) pending(ctx context.Context, client StorageBackend) ([]pruneIntent, error) {
+	var keys []string
+	err := client.List(ctx, l.bucket, path.Join(l.prefix, "pending")+"/", func(object ObjectInfo) bool {
+		keys = append(keys, object.Key)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending intents: %w", err)
+	}
+
+	intents := make([]pruneIntent, 0, len(keys))
+	for _, key := range keys {
+		body, _, err := client.Get(ctx, l.bucket, key, GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read intent %s: %w", key, err)
+		}
+		data, err := io.ReadAll(body)
+		body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read intent %s: %w", key, err)
+		}
+		var intent pruneIntent
+		if err := json.Unmarshal(data, &intent); err != nil {
+			return nil, fmt.Errorf("failed to parse intent %s: %w", key, err)
+		}
+		intents = append(intents, intent)
+	}
+	sort.SliceStable(intents, func(i, j int) bool { return intents[i].CreatedAt.Before(intents[j].CreatedAt) })
+	return intents, nil
+}
+
+// resolveIntent works out how an interrupted action ended. Each action runs
+// in one transaction, so it either committed or rolled back: a dropped
+// partition is gone, and a truncated one is empty.
+func resolveIntent(ctx context.Context, db *sql.DB, intent pruneIntent) (string, string, error) {
+	var exists bool
+	if err := db.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", pq.QuoteIdentifier(intent.Partition)).Scan(&exists); err != nil {
+		return "", "", fmt.Errorf("failed to check partition %s: %w", intent.Partition, err)
+	}
+	if !exists {
+		return IntentStateCompleted, "partition no longer exists", nil
+	}
+	if intent.Action != PruneActionTruncate {
+		return IntentStateRolledBack, "partition still exists", nil
+	}
+
+	rows, err := countLiveRows(ctx, db, intent.Partition, DateColumnSpec{}, time.Time{}, time.Time{})
+	if err != nil {
+		return "", "", err
+	}
+	if rows == 0 && intent.Rows > 0 {
+		return IntentStateCompleted, "partition is empty", nil
+	}
+	return IntentStateRolledBack, fmt.Sprintf("partition still holds %d rows", rows), nil
+}
+
+// resolveIntents finishes the intents left pending by an interrupted run
+// before anything else is pruned. Without --confirm they are only reported.
+func (p *Pruner) resolveIntents(ctx context.Context, db *sql.DB) error {
+	intents, err := p.intents.pending(ctx, p.verifier.client)
+	if err != nil {
+		return err
+	}
+	if len(intents) == 0 {
+		return nil
+	}
+	if !p.execute {
+		p.logger.Warn(fmt.Sprintf("⚠️  %d interrupted prune action(s) pending in s3://%s/%s/pending; pass --confirm to resolve them", len(intents), p.intents.bucket, p.intents.prefix))
+		return nil
+	}
+
+	for _, intent := range intents {
+		state, message, err := resolveIntent(ctx, db, intent)
+		if err != nil {
+			return fmt.Errorf("failed to resolve interrupted %s of %s: %w", intent.Action, intent.Partition, err)
+		}
+		intent.State = state
+		intent.Message = "resolved after interruption: " + message
+		intent.UpdatedAt = p.now().UTC()
+		if err := p.intents.record(ctx, p.verifier.client, intent); err != nil {
+			return err
+		}
+		p.logger.Info(fmt.Sprintf("🧾 Interrupted %s of %s (run %s): %s, %s", intent.Action, intent.Partition, intent.RunID, state, message))
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// storedIntent decodes the intent record at key
+func storedIntent(t *testing.T, store *fakeObjectStore, key string) pruneIntent {
+	t.Helper()
+	data, ok := store.objects[key]
+	if !ok {
+		t.Fatalf("intent %s not written", key)
+	}
+	var intent pruneIntent
+	if err := json.Unmarshal(data, &intent); err != nil {
+		t.Fatalf("invalid intent %s: %v", key, err)
+	}
+	return intent
+}
+
+func TestPruneRecordsIntents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	data := []byte("{\"id\":1}\n{\"id\":2}\n")
+	pruner := newTestPruner(t, true, map[string][]byte{"flights/2024-01-05.jsonl": data})
+	store := pruner.verifier.client.(*s3ObjectStore).client.(*fakeObjectStore)
+	entries := []PartitionCacheEntry{{S3Key: "flights/2024-01-05.jsonl", SourceTable: "flights_20240105", FileSize: int64(len(data)), FileMD5: md5Hex(data), ArchivedRowCount: 2, S3Uploaded: true}}
+
+	mock.ExpectQuery(`SELECT to_regclass`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT to_regclass`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "flights_20240105"$`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "flights_20240105"$`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT relkind`).WillReturnRows(sqlmock.NewRows([]string{"relkind"}).AddRow("r"))
+	expectLockedCount(mock, 2)
+	mock.ExpectQuery(`FROM pg_inherits`).WillReturnRows(sqlmock.NewRows([]string{"relname"}).AddRow("flights"))
+	mock.ExpectExec(`DETACH PARTITION`).WillReturnError(errors.New("lock timeout"))
+	mock.ExpectRollback()
+
+	result := pruner.prunePartition(context.Background(), db, "flights_20240105", entries, time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC))
+	if result.Status != PruneStatusError {
+		t.Fatalf("expected %s, got %+v", PruneStatusError, result)
+	}
+
+	name := "flights_20240105-" + currentRunID + ".json"
+	if _, ok := store.objects[defaultIntentPrefix+"/flights/pending/"+name]; ok {
+		t.Error("pending intent should be replaced by its outcome")
+	}
+	intent := storedIntent(t, store, defaultIntentPrefix+"/flights/done/"+name)
+	if intent.State != IntentStateRolledBack || intent.Rows != 2 || len(intent.Archives) != 1 || intent.Archives[0].MD5 != md5Hex(data) {
+		t.Errorf("intent = %+v", intent)
+	}
+
+	// The local log keeps both states
+	local, err := os.ReadFile(pruner.intents.path)
+	if err != nil || bytes.Count(local, []byte("\n")) != 2 || !strings.Contains(string(local), `"state":"pending"`) {
+		t.Errorf("local intent log = %q, %v", local, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestPruneFailedCommitOutcome(t *testing.T) {
+	data := []byte("{\"id\":1}\n{\"id\":2}\n")
+	entries := []PartitionCacheEntry{{S3Key: "flights/2024-01-05.jsonl", SourceTable: "flights_20240105", FileSize: int64(len(data)), FileMD5: md5Hex(data), ArchivedRowCount: 2, S3Uploaded: true}}
+	cutoff := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	name := "flights_20240105-" + currentRunID + ".json"
+
+	// expectFailedCommit verifies the partition and fails the drop's commit
+	expectFailedCommit := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(`SELECT to_regclass`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(`SELECT to_regclass`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "flights_20240105"$`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "flights_20240105"$`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT relkind`).WillReturnRows(sqlmock.NewRows([]string{"relkind"}).AddRow("r"))
+		expectLockedCount(mock, 2)
+		mock.ExpectQuery(`FROM pg_inherits`).WillReturnError(sql.ErrNoRows)
+		mock.ExpectExec(`DROP TABLE "flights_20240105"`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit().WillReturnError(errors.New("connection reset"))
+	}
+
+	t.Run("ResolvedFromPartition", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+		pruner := newTestPruner(t, true, map[string][]byte{"flights/2024-01-05.jsonl": data})
+		store := pruner.verifier.client.(*s3ObjectStore).client.(*fakeObjectStore)
+
+		expectFailedCommit(mock)
+		mock.ExpectQuery(`SELECT to_regclass`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		result := pruner.prunePartition(context.Background(), db, "flights_20240105", entries, cutoff)
+		if result.Status != PruneStatusDropped {
+			t.Fatalf("expected %s, got %+v", PruneStatusDropped, result)
+		}
+		if intent := storedIntent(t, store, defaultIntentPrefix+"/flights/done/"+name); intent.State != IntentStateCompleted {
+			t.Errorf("intent = %+v", intent)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("LeftPending", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+		pruner := newTestPruner(t, true, map[string][]byte{"flights/2024-01-05.jsonl": data})
+		store := pruner.verifier.client.(*s3ObjectStore).client.(*fakeObjectStore)
+
+		expectFailedCommit(mock)
+		mock.ExpectQuery(`SELECT to_regclass`).WillReturnError(errors.New("connection refused"))
+
+		result := pruner.prunePartition(context.Background(), db, "flights_20240105", entries, cutoff)
+		if result.Status != PruneStatusError || !strings.Contains(result.Message, "pending") {
+			t.Fatalf("expected %s left pending, got %+v", PruneStatusError, result)
+		}
+		if intent := storedIntent(t, store, defaultIntentPrefix+"/flights/pending/"+name); intent.State != IntentStatePending {
+			t.Errorf("intent = %+v", intent)
+		}
+		if _, ok := store.objects[defaultIntentPrefix+"/flights/done/"+name]; ok {
+			t.Error("an unknown outcome must not be recorded as done")
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestResolveInterruptedIntents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	pruner := newTestPruner(t, true, map[string][]byte{})
+	store := pruner.verifier.client.(*s3ObjectStore).client.(*fakeObjectStore)
+	dropped := pruneIntent{RunID: "run1", Table: "flights", Partition: "flights_20240101", Action: PruneActionDrop, Rows: 5, State: IntentStatePending}
+	truncated := pruneIntent{RunID: "run1", Table: "flights", Partition: "flights_20240102", Action: PruneActionTruncate, Rows: 5, State: IntentStatePending, CreatedAt: time.Now()}
+	for _, intent := range []pruneIntent{dropped, truncated} {
+		if err := pruner.intents.record(ctx, newS3ObjectStore(store), intent); err != nil {
+			t.Fatalf("record() = %v", err)
+		}
+	}
+
+	// Without --confirm, pending intents are only reported
+	pruner.execute = false
+	if err := pruner.resolveIntents(ctx, db); err != nil || len(store.objects) != 2 {
+		t.Fatalf("resolveIntents() dry run = %v, %d objects", err, len(store.objects))
+	}
+
+	pruner.execute = true
+	mock.ExpectQuery(`SELECT to_regclass`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`SELECT to_regclass`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "flights_20240102"$`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+	if err := pruner.resolveIntents(ctx, db); err != nil {
+		t.Fatalf("resolveIntents() = %v", err)
+	}
+
+	if intent := storedIntent(t, store, pruner.intents.doneKey(dropped)); intent.State != IntentStateCompleted {
+		t.Errorf("interrupted drop = %+v", intent)
+	}
+	if intent := storedIntent(t, store, pruner.intents.doneKey(truncated)); intent.State != IntentStateRolledBack {
+		t.Errorf("interrupted truncate = %+v", intent)
+	}
+	if pending, _ := pruner.intents.pending(ctx, newS3ObjectStore(store)); len(pending) != 0 {
+		t.Errorf("pending after resolving = %+v", pending)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
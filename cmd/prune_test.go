@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func newTestPruner(t *testing.T, execute bool, objects map[string][]byte) *Pruner {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	config := &Config{Table: "flights", S3: S3Config{Bucket: "bucket"}, IntentPrefix: defaultIntentPrefix}
+	pruner := NewPruner(config, 30, PruneActionDrop, execute, newTestLogger())
+	pruner.verifier.client = newS3ObjectStore(&fakeObjectStore{objects: objects})
+	pruner.now = func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) }
+	return pruner
+}
+
+// expectLockedCount expects the lock and recount apply runs before acting
+func expectLockedCount(mock sqlmock.Sqlmock, rows int) {
+	mock.ExpectExec(`LOCK TABLE "flights_20240105" IN ACCESS EXCLUSIVE MODE`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "flights_20240105"$`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(rows))
+}
+
+func TestPrunerPrunePartition(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	data := []byte("{\"id\":1}\n{\"id\":2}\n")
+	objects := map[string][]byte{"flights/2024-01-05.jsonl": data}
+	entries := []PartitionCacheEntry{{
+		S3Key:            "flights/2024-01-05.jsonl",
+		SourceTable:      "flights_20240105",
+		FileSize:         int64(len(data)),
+		FileMD5:          md5Hex(data),
+		ArchivedRowCount: 2,
+		S3Uploaded:       true,
+	}}
+	cutoff := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	expectVerified := func(liveRows int) {
+		mock.ExpectQuery(`SELECT to_regclass`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(`SELECT to_regclass`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "flights_20240105"$`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "flights_20240105"$`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(liveRows))
+	}
+
+	t.Run("WithinRetention", func(t *testing.T) {
+		pruner := newTestPruner(t, true, objects)
+		recent := []PartitionCacheEntry{{S3Key: "k", SourceTable: "flights_20240215", ArchivedRowCount: 1}}
+		result := pruner.prunePartition(ctx, db, "flights_20240215", recent, cutoff)
+		if result.Status != PruneStatusWithinRetention {
+			t.Errorf("expected %s, got %s", PruneStatusWithinRetention, result.Status)
+		}
+	})
+
+	t.Run("MonthlyPartitionEndsAfterCutoff", func(t *testing.T) {
+		pruner := newTestPruner(t, true, objects)
+		result := pruner.prunePartition(ctx, db, "flights_2024_01", nil, time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC))
+		if result.Status != PruneStatusWithinRetention {
+			t.Errorf("expected %s, got %s", PruneStatusWithinRetention, result.Status)
+		}
+	})
+
+	t.Run("Planned", func(t *testing.T) {
+		pruner := newTestPruner(t, false, objects)
+		expectVerified(2)
+		result := pruner.prunePartition(ctx, db, "flights_20240105", entries, cutoff)
+		if result.Status != PruneStatusPlanned {
+			t.Errorf("expected %s, got %s (%s)", PruneStatusPlanned, result.Status, result.Message)
+		}
+	})
+
+	t.Run("RowsAddedAfterArchive", func(t *testing.T) {
+		pruner := newTestPruner(t, true, objects)
+		expectVerified(3)
+		result := pruner.prunePartition(ctx, db, "flights_20240105", entries, cutoff)
+		if result.Status != PruneStatusVerificationFailed {
+			t.Errorf("expected %s, got %s", PruneStatusVerificationFailed, result.Status)
+		}
+	})
+
+	t.Run("ObjectMissing", func(t *testing.T) {
+		pruner := newTestPruner(t, true, map[string][]byte{})
+		mock.ExpectQuery(`SELECT to_regclass`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(`SELECT to_regclass`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "flights_20240105"$`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		result := pruner.prunePartition(ctx, db, "flights_20240105", entries, cutoff)
+		if result.Status != PruneStatusVerificationFailed {
+			t.Errorf("expected %s, got %s", PruneStatusVerificationFailed, result.Status)
+		}
+	})
+
+	t.Run("RowsInsertedBeforeAction", func(t *testing.T) {
+		pruner := newTestPruner(t, true, objects)
+		expectVerified(2)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT relkind`).WillReturnRows(sqlmock.NewRows([]string{"relkind"}).AddRow("r"))
+		expectLockedCount(mock, 3)
+		mock.ExpectRollback()
+
+		result := pruner.prunePartition(ctx, db, "flights_20240105", entries, cutoff)
+		if result.Status != PruneStatusError || !strings.Contains(result.Message, ErrPruneRowsChanged.Error()) {
+			t.Errorf("expected %s for rows changed, got %+v", PruneStatusError, result)
+		}
+	})
+
+	t.Run("DetachAndDrop", func(t *testing.T) {
+		pruner := newTestPruner(t, true, objects)
+		expectVerified(2)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT relkind`).WillReturnRows(sqlmock.NewRows([]string{"relkind"}).AddRow("r"))
+		expectLockedCount(mock, 2)
+		mock.ExpectQuery(`FROM pg_inherits`).WillReturnRows(sqlmock.NewRows([]string{"relname"}).AddRow("flights"))
+		mock.ExpectExec(`ALTER TABLE "flights" DETACH PARTITION "flights_20240105"`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`DROP TABLE "flights_20240105"`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		result := pruner.prunePartition(ctx, db, "flights_20240105", entries, cutoff)
+		if result.Status != PruneStatusDropped || result.LiveRows != 2 {
+			t.Errorf("expected %s with 2 rows, got %+v", PruneStatusDropped, result)
+		}
+	})
+
+	t.Run("Truncate", func(t *testing.T) {
+		pruner := newTestPruner(t, true, objects)
+		pruner.action = PruneActionTruncate
+		expectVerified(2)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT relkind`).WillReturnRows(sqlmock.NewRows([]string{"relkind"}).AddRow("r"))
+		expectLockedCount(mock, 2)
+		mock.ExpectExec(`TRUNCATE "flights_20240105"`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		result := pruner.prunePartition(ctx, db, "flights_20240105", entries, cutoff)
+		if result.Status != PruneStatusTruncated {
+			t.Errorf("expected %s, got %s (%s)", PruneStatusTruncated, result.Status, result.Message)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestValidatePruneConfig(t *testing.T) {
+	config := &Config{
+		Table:    "flights",
+		Database: DatabaseConfig{User: "u", Name: "db"},
+		S3:       S3Config{PathTemplate: "archives/{table}"},
+
+		IntentPrefix: defaultIntentPrefix,
+	}
+	if err := validatePruneConfig(config, 30, PruneActionDrop); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validatePruneConfig(config, 0, PruneActionDrop); !errors.Is(err, ErrPruneRetentionRequired) {
+		t.Errorf("expected ErrPruneRetentionRequired, got %v", err)
+	}
+	if err := validatePruneConfig(config, 30, "delete"); !errors.Is(err, ErrPruneActionInvalid) {
+		t.Errorf("expected ErrPruneActionInvalid, got %v", err)
+	}
+}
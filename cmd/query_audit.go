@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func init() {
+	archiveCmd.Flags().Bool("record-sql", false, "log the exact extraction SQL and parameter values of every archive file and record them in the results log and split manifests")
+	_ = viper.BindPFlag("record_sql", archiveCmd.Flags().Lookup("record-sql"))
+}
+
+// extractionQuery is the SQL an archive file was extracted with, exactly as
+// sent to PostgreSQL (including any query comment), and its parameter values
+// in order ($1, $2, ...). With --extract-method copy the parameters are
+// already inlined into the COPY statement psql runs.
+type extractionQuery struct {
+	S3Key  string   `json:"s3_key,omitempty"`
+	SQL    string   `json:"sql"`
+	Params []string `json:"params,omitempty"`
+}
+
+// newExtractionQuery records query and args as executed
+func newExtractionQuery(query string, args []interface{}, method string) extractionQuery {
+	if method == extractMethodCopy {
+		return extractionQuery{SQL: "COPY (" + inlineQueryArgs(query, args) + ") TO STDOUT"}
+	}
+	recorded := extractionQuery{SQL: query}
+	for _, arg := range args {
+		recorded.Params = append(recorded.Params, formatQueryParam(arg))
+	}
+	return recorded
+}
+
+// formatQueryParam renders a parameter value the way it can be pasted back
+// into psql; times keep their full precision and offset
+func formatQueryParam(arg interface{}) string {
+	switch v := arg.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case nil:
+		return "NULL"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// String renders the query on one line for the log
+func (q extractionQuery) String() string {
+	sql := strings.Join(strings.Fields(q.SQL), " ")
+	if len(q.Params) == 0 {
+		return sql
+	}
+	params := make([]string, len(q.Params))
+	for i, param := range q.Params {
+		params[i] = fmt.Sprintf("$%d=%s", i+1, param)
+	}
+	return sql + " [" + strings.Join(params, ", ") + "]"
+}
+
+// manifestEntry returns the query for a split manifest, which already names
+// its archive (nil when the query was not recorded)
+func (q extractionQuery) manifestEntry() *extractionQuery {
+	if q.SQL == "" {
+		return nil
+	}
+	return &extractionQuery{SQL: q.SQL, Params: q.Params}
+}
+
+// recordQuery keeps the query of an archive file with --record-sql
+func (a *Archiver) recordQuery(recorded *extractionQuery, partition, query string, args []interface{}) {
+	if recorded == nil || !a.config.RecordSQL {
+		return
+	}
+	*recorded = newExtractionQuery(query, args, a.config.ExtractMethod)
+	a.logger.Info(fmt.Sprintf("🔎 Extraction SQL for %s: %s", partition, recorded))
+}
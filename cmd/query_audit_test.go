@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestNewExtractionQuery(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	query := `SELECT "id" FROM "events" WHERE "created_at" >= $1 AND "created_at" < $2`
+	args := []interface{}{start, start.AddDate(0, 0, 1)}
+
+	recorded := newExtractionQuery(query, args, extractMethodSelect)
+	if recorded.SQL != query || strings.Join(recorded.Params, ",") != "2024-01-01T00:00:00Z,2024-01-02T00:00:00Z" {
+		t.Errorf("select query = %+v", recorded)
+	}
+	if got := recorded.String(); !strings.HasSuffix(got, "[$1=2024-01-01T00:00:00Z, $2=2024-01-02T00:00:00Z]") {
+		t.Errorf("String() = %q", got)
+	}
+
+	recorded = newExtractionQuery(query, args, extractMethodCopy)
+	if !strings.HasPrefix(recorded.SQL, "COPY (") || strings.Contains(recorded.SQL, "$1") || len(recorded.Params) != 0 {
+		t.Errorf("copy query = %+v", recorded)
+	}
+
+	if (extractionQuery{}).manifestEntry() != nil {
+		t.Error("an unrecorded query should be left out of the manifest")
+	}
+}
+
+func TestExtractionRecordsSQL(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	archiver := NewArchiver(&Config{
+		Table:        "events",
+		OutputFormat: "jsonl",
+		Compression:  "none",
+		DateColumn:   "created_at",
+		RecordSQL:    true,
+	}, newTestLogger())
+	archiver.db = db
+	archiver.ctx = context.Background()
+
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).
+			AddRow("id", "bigint", "int8"))
+	mock.ExpectQuery(`SELECT "id" FROM "events" WHERE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)))
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := &PartitionCache{Entries: make(map[string]PartitionCacheEntry)}
+	var recorded extractionQuery
+	path, _, _, _, _, err := archiver.extractPartitionDataStreaming(PartitionInfo{TableName: "events"}, nil, cache, func(string) {}, start, start.AddDate(0, 0, 1), 0, nil, nil, &recorded)
+	if err != nil {
+		t.Fatalf("extraction failed: %v", err)
+	}
+	cleanupTempFile(path)
+
+	if !strings.HasPrefix(recorded.SQL, `SELECT "id" FROM "events" WHERE`) || len(recorded.Params) != 2 || recorded.Params[0] != "2024-01-01T00:00:00Z" {
+		t.Fatalf("recorded query = %+v", recorded)
+	}
+
+	// The query is written to the results log with the archive's key
+	recorded.S3Key = "events/events-2024-01-01.jsonl"
+	record := resultsRecord{Type: resultsRecordResult, Queries: []extractionQuery{recorded}}
+	data, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"queries":[{"s3_key":"events/events-2024-01-01.jsonl","sql":"SELECT`) {
+		t.Errorf("results record = %s", data)
+	}
+}
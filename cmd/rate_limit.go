@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// ErrRowRateInvalid is returned for a negative --max-db-rows-per-sec
+var ErrRowRateInvalid = errors.New("max db rows per sec must be >= 0")
+
+// rowRateBatch is how many extracted rows are paced at once, matching the
+// extraction loop's cancellation checks
+const rowRateBatch = 100
+
+var (
+	maxUploadRate    string
+	maxDBRowsPerSec  int64
+	maxDownloadRate  string
+	compareMaxRate   string
+	processRateLimit struct {
+		mu     sync.Mutex
+		upload *bandwidthLimiter
+		rows   *bandwidthLimiter
+	}
+)
+
+func init() {
+	archiveCmd.Flags().StringVar(&maxUploadRate, "max-upload-rate", "", "upload bandwidth limit shared by every table of the run, e.g. 50MB/s (empty = unlimited)")
+	archiveCmd.Flags().Int64Var(&maxDBRowsPerSec, "max-db-rows-per-sec", 0, "most rows read from the database per second across the run (0 = unlimited)")
+	restoreCmd.Flags().StringVar(&maxDownloadRate, "max-download-rate", "", "download bandwidth limit shared by every restore worker, e.g. 50MB/s (empty = unlimited)")
+	compareCmd.Flags().StringVar(&compareMaxRate, "max-download-rate", "", "download bandwidth limit for archive files read from S3, e.g. 50MB/s (empty = unlimited)")
+	_ = viper.BindPFlag("max_upload_rate", archiveCmd.Flags().Lookup("max-upload-rate"))
+	_ = viper.BindPFlag("max_db_rows_per_sec", archiveCmd.Flags().Lookup("max-db-rows-per-sec"))
+	_ = viper.BindPFlag("restore.download.max_rate", restoreCmd.Flags().Lookup("max-download-rate"))
+	_ = viper.BindPFlag("compare.max_download_rate", compareCmd.Flags().Lookup("max-download-rate"))
+}
+
+// validateRateLimits checks the --max-db-rows-per-sec limit
+func (c *Config) validateRateLimits() error {
+	if c.MaxDBRowsPerSec < 0 {
+		return fmt.Errorf("%w, got %d", ErrRowRateInvalid, c.MaxDBRowsPerSec)
+	}
+	return nil
+}
+
+// sharedLimiter returns the process-wide limiter in slot for rate, so every
+// table of a multi-table run draws on the same budget. A different rate
+// replaces the limiter; nil means unlimited.
+func sharedLimiter(slot **bandwidthLimiter, rate int64) *bandwidthLimiter {
+	processRateLimit.mu.Lock()
+	defer processRateLimit.mu.Unlock()
+	if rate <= 0 {
+		return nil
+	}
+	if *slot == nil || int64((*slot).rate) != rate {
+		*slot = newBandwidthLimiter(rate)
+	}
+	return *slot
+}
+
+// uploadLimiter returns the limiter paced by the archiver's uploads: the
+// table's --max-table-bandwidth quota, then --max-upload-rate for the whole
+// run. Either may be unset.
+func (a *Archiver) uploadLimiter() *bandwidthLimiter {
+	global := sharedLimiter(&processRateLimit.upload, a.config.MaxUploadRate)
+	table := newBandwidthLimiter(a.config.Quota.MaxBandwidth)
+	if table == nil {
+		return global
+	}
+	table.parent = global
+	return table
+}
+
+// paceRows waits until rowRateBatch more rows may be read from the database
+func (a *Archiver) paceRows() error {
+	if a.rowRate == nil {
+		return nil
+	}
+	ctx := a.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return a.rowRate.wait(ctx, rowRateBatch)
+}
+
+// throttledReader paces reads through a bandwidthLimiter, for download bodies
+type throttledReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *bandwidthLimiter
+}
+
+// throttleDownload wraps body with the limiter, or returns it unchanged when unlimited
+func throttleDownload(ctx context.Context, body io.Reader, limiter *bandwidthLimiter) io.Reader {
+	if limiter == nil {
+		return body
+	}
+	return &throttledReader{ctx: ctx, r: body, limiter: limiter}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if waitErr := t.limiter.wait(t.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadLimiterChainsRunLimit(t *testing.T) {
+	t.Cleanup(func() { processRateLimit.upload = nil })
+
+	if limiter := NewArchiver(&Config{}, newTestLogger()).bandwidth; limiter != nil {
+		t.Fatal("expected no limiter without limits")
+	}
+
+	first := NewArchiver(&Config{MaxUploadRate: 1000, Quota: TableQuota{MaxBandwidth: 5000}}, newTestLogger())
+	second := NewArchiver(&Config{MaxUploadRate: 1000}, newTestLogger())
+	if first.bandwidth.parent == nil || first.bandwidth.parent != second.bandwidth {
+		t.Fatal("tables of one run should share the --max-upload-rate limiter")
+	}
+
+	var slept time.Duration
+	second.bandwidth.sleep = func(_ context.Context, d time.Duration) error {
+		slept += d
+		return nil
+	}
+	first.bandwidth.sleep = second.bandwidth.sleep
+	// Within the table's quota, but together the tables exceed the run's limit
+	if err := first.bandwidth.wait(context.Background(), 1000); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.bandwidth.wait(context.Background(), 500); err != nil {
+		t.Fatal(err)
+	}
+	if slept < 450*time.Millisecond || slept > 550*time.Millisecond {
+		t.Errorf("expected about 500ms of delay, slept %v", slept)
+	}
+}
+
+func TestPaceRows(t *testing.T) {
+	t.Cleanup(func() { processRateLimit.rows = nil })
+
+	if err := (&Config{MaxDBRowsPerSec: -1}).validateRateLimits(); !errors.Is(err, ErrRowRateInvalid) {
+		t.Errorf("expected ErrRowRateInvalid, got %v", err)
+	}
+
+	archiver := NewArchiver(&Config{MaxDBRowsPerSec: 200}, newTestLogger())
+	var slept time.Duration
+	archiver.rowRate.sleep = func(_ context.Context, d time.Duration) error {
+		slept += d
+		return nil
+	}
+	for i := 0; i < 4; i++ {
+		if err := archiver.paceRows(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 400 rows at 200 rows/s: one second of burst, then a second of waiting
+	if slept < 950*time.Millisecond || slept > 1050*time.Millisecond {
+		t.Errorf("expected about 1s of delay, slept %v", slept)
+	}
+	if err := NewArchiver(&Config{}, newTestLogger()).paceRows(); err != nil {
+		t.Errorf("paceRows() without a limit = %v", err)
+	}
+}
+
+func TestRangeDownloaderThrottled(t *testing.T) {
+	ctx := context.Background()
+	bucket := t.TempDir()
+	store := &localObjectStore{}
+	data := strings.Repeat("x", 3072)
+	if _, err := store.Put(ctx, bucket, "events/events.jsonl", strings.NewReader(data), PutOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	downloader := newRangeDownloader(store, bucket, t.TempDir(), 1, 0, newTestLogger())
+	downloader.rate = newBandwidthLimiter(1024)
+	var slept time.Duration
+	downloader.rate.sleep = func(_ context.Context, d time.Duration) error {
+		slept += d
+		return nil
+	}
+	path, size, err := downloader.Download(ctx, "events/events.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+	if size != int64(len(data)) {
+		t.Errorf("size = %d, want %d", size, len(data))
+	}
+	// 3KB at 1KB/s: one second of burst, then two seconds of waiting
+	if slept < 1900*time.Millisecond || slept > 2100*time.Millisecond {
+		t.Errorf("expected about 2s of delay, slept %v", slept)
+	}
+	if got, _ := os.ReadFile(filepath.Clean(path)); string(got) != data {
+		t.Error("downloaded file differs from the object")
+	}
+
+	body := bytes.NewReader([]byte(data))
+	if throttleDownload(ctx, body, nil) != io.Reader(body) {
+		t.Error("expected body to be returned unchanged without a limit")
+	}
+}
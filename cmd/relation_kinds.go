@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/spf13/viper"
+)
+
+// Relation kinds accepted by --include-relation-kinds
+const (
+	RelationKindMatview = "matview" // Materialized views (relkind 'm')
+	RelationKindForeign = "foreign" // Foreign tables (relkind 'f')
+)
+
+// pg_class.relkind values of the relations archive reads
+const (
+	relkindTable            = "r"
+	relkindPartitionedTable = "p"
+	relkindMatview          = "m"
+	relkindForeignTable     = "f"
+)
+
+// Static errors for relation kinds
+var (
+	ErrRelationKindInvalid = errors.New("relation kind must be one of: matview, foreign")
+	ErrMatviewTruncate     = errors.New("materialized views cannot be truncated; prune them with --action drop")
+)
+
+var includeRelationKinds []string
+
+func init() {
+	archiveCmd.Flags().StringSliceVar(&includeRelationKinds, "include-relation-kinds", nil, "also archive relations of these kinds matching the partition naming pattern: matview, foreign (comma-separated)")
+	_ = viper.BindPFlag("include_relation_kinds", archiveCmd.Flags().Lookup("include-relation-kinds"))
+}
+
+// validateRelationKinds checks the --include-relation-kinds values
+func validateRelationKinds(kinds []string) error {
+	for _, kind := range kinds {
+		switch kind {
+		case RelationKindMatview, RelationKindForeign:
+		default:
+			return fmt.Errorf("%w, got '%s'", ErrRelationKindInvalid, kind)
+		}
+	}
+	return nil
+}
+
+// parseRelationKinds splits --include-relation-kinds values (or the config
+// file list) into lower-case kinds
+func parseRelationKinds(values []string) []string {
+	var kinds []string
+	for _, value := range values {
+		for _, kind := range strings.Split(value, ",") {
+			if kind = strings.ToLower(strings.TrimSpace(kind)); kind != "" {
+				kinds = append(kinds, kind)
+			}
+		}
+	}
+	return kinds
+}
+
+// includesRelationKind reports whether kind was requested with --include-relation-kinds
+func (c *Config) includesRelationKind(kind string) bool {
+	for _, k := range c.IncludeRelationKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// partitionRelkinds returns the relkinds of leaf partitions to archive:
+// tables, and foreign tables attached as partitions when requested
+func (c *Config) partitionRelkinds() []string {
+	kinds := []string{relkindTable}
+	if c.includesRelationKind(RelationKindForeign) {
+		kinds = append(kinds, relkindForeignTable)
+	}
+	return kinds
+}
+
+// standaloneRelkinds returns the relkinds of relations outside any partition
+// tree that are archived when their names match the partition pattern:
+// tables with --include-non-partition-tables, plus the requested matviews
+// and foreign tables. An empty result means none are listed.
+func (c *Config) standaloneRelkinds() []string {
+	var kinds []string
+	if c.IncludeNonPartitionTables {
+		kinds = append(kinds, relkindTable)
+	}
+	if c.includesRelationKind(RelationKindMatview) {
+		kinds = append(kinds, relkindMatview)
+	}
+	if c.includesRelationKind(RelationKindForeign) {
+		kinds = append(kinds, relkindForeignTable)
+	}
+	return kinds
+}
+
+// baseRelkinds returns the relkinds --table itself may be
+func (c *Config) baseRelkinds() []string {
+	kinds := []string{relkindTable, relkindPartitionedTable}
+	if c.includesRelationKind(RelationKindMatview) {
+		kinds = append(kinds, relkindMatview)
+	}
+	if c.includesRelationKind(RelationKindForeign) {
+		kinds = append(kinds, relkindForeignTable)
+	}
+	return kinds
+}
+
+// dropRelationSQL returns the DROP statement for a relation of the given relkind
+func dropRelationSQL(relkind, name string) string {
+	switch relkind {
+	case relkindMatview:
+		return fmt.Sprintf("DROP MATERIALIZED VIEW %s", pq.QuoteIdentifier(name))
+	case relkindForeignTable:
+		return fmt.Sprintf("DROP FOREIGN TABLE %s", pq.QuoteIdentifier(name))
+	default:
+		return fmt.Sprintf("DROP TABLE %s", pq.QuoteIdentifier(name))
+	}
+}
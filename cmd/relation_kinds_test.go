@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// relkindsArg matches the relkind array passed to discovery queries
+type relkindsArg string
+
+func (r relkindsArg) Match(v driver.Value) bool {
+	return v == string(r)
+}
+
+func TestValidateRelationKinds(t *testing.T) {
+	if err := validateRelationKinds(parseRelationKinds([]string{"MatView, foreign"})); err != nil {
+		t.Errorf("validateRelationKinds() = %v", err)
+	}
+	if err := validateRelationKinds([]string{"view"}); !errors.Is(err, ErrRelationKindInvalid) {
+		t.Errorf("expected ErrRelationKindInvalid, got %v", err)
+	}
+}
+
+func TestRelkinds(t *testing.T) {
+	config := &Config{}
+	if got := config.partitionRelkinds(); !reflect.DeepEqual(got, []string{"r"}) {
+		t.Errorf("partitionRelkinds() = %v", got)
+	}
+	if got := config.standaloneRelkinds(); got != nil {
+		t.Errorf("standaloneRelkinds() = %v, want none", got)
+	}
+
+	config = &Config{IncludeNonPartitionTables: true, IncludeRelationKinds: []string{RelationKindMatview, RelationKindForeign}}
+	if got := config.partitionRelkinds(); !reflect.DeepEqual(got, []string{"r", "f"}) {
+		t.Errorf("partitionRelkinds() = %v", got)
+	}
+	if got := config.standaloneRelkinds(); !reflect.DeepEqual(got, []string{"r", "m", "f"}) {
+		t.Errorf("standaloneRelkinds() = %v", got)
+	}
+	if got := config.baseRelkinds(); !reflect.DeepEqual(got, []string{"r", "p", "m", "f"}) {
+		t.Errorf("baseRelkinds() = %v", got)
+	}
+}
+
+func TestDropRelationSQL(t *testing.T) {
+	tests := map[string]string{
+		relkindTable:        `DROP TABLE "flights_20240105"`,
+		relkindMatview:      `DROP MATERIALIZED VIEW "flights_20240105"`,
+		relkindForeignTable: `DROP FOREIGN TABLE "flights_20240105"`,
+	}
+	for relkind, want := range tests {
+		if got := dropRelationSQL(relkind, "flights_20240105"); got != want {
+			t.Errorf("dropRelationSQL(%s) = %s, want %s", relkind, got, want)
+		}
+	}
+}
+
+func TestFindPartitionsIncludesMatviews(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	archiver := NewArchiver(&Config{Table: "events", IncludeRelationKinds: []string{RelationKindMatview}}, newTestLogger())
+	archiver.db = db
+
+	mock.ExpectQuery(`FROM leaf_partitions`).WithArgs("public", "events", relkindsArg(`{"r"}`)).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename"}).AddRow("events_20240101"))
+	mock.ExpectQuery(regexp.QuoteMeta("has_table_privilege")).WithArgs("events_20240101").
+		WillReturnRows(sqlmock.NewRows([]string{"has_table_privilege"}).AddRow(true))
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events_20240101").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).AddRow("id", "bigint", "int8"))
+	// Only matviews are listed outside the partition tree without --include-non-partition-tables
+	mock.ExpectQuery(`FROM pg_class c`).WithArgs("public", "events", relkindsArg(`{"m"}`)).
+		WillReturnRows(sqlmock.NewRows([]string{"relname"}).AddRow("events_20240102"))
+	mock.ExpectQuery(regexp.QuoteMeta("has_table_privilege")).WithArgs("events_20240102").
+		WillReturnRows(sqlmock.NewRows([]string{"has_table_privilege"}).AddRow(true))
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events_20240102").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).AddRow("id", "bigint", "int8"))
+
+	partitions, err := archiver.findPartitions(context.Background())
+	if err != nil {
+		t.Fatalf("findPartitions() error = %v", err)
+	}
+	if len(partitions) != 2 || partitions[1].TableName != "events_20240102" {
+		t.Errorf("expected the partition and the matview, got %+v", partitions)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
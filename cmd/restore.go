@@ -3,25 +3,22 @@ package cmd
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
-	"github.com/airframesio/data-archiver/cmd/compressors"
 	"github.com/airframesio/data-archiver/cmd/formatters"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/lib/pq"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -40,12 +37,43 @@ var (
 	restoreMode                   string // schema-only, data-only, schema-and-data
 	restoreSchemaSource           string // pg_dump, inferred, auto, db
 	restoreSchemaPath             string // S3 path for schema files (pg_dump)
+	restoreSSHHost                string
+	restoreSSHPort                int
+	restoreSSHUser                string
+	restoreSSHKey                 string
+	restoreSSHKnownHosts          string
+	restoreCSVNoHeader            bool
+	restoreCSVColumns             string
+	restoreCSVSchemaFile          string
+	restoreFieldRename            map[string]string
+	restoreCamelCaseFields        bool
+	restoreFlattenFields          string
+	restoreFlattenSeparator       string
+	restoreDownloadPartSize       int
+	restoreDownloadRetries        int
+	restoreDownloadDir            string
+	restoreSkipSchemaCheck        bool
+	restoreForce                  bool
+	restoreNoComments             bool
+	restoreLargeObjects           bool
+	restoreBatchSize              int
+	restoreMaxBatchSize           int
+	restoreBatchTarget            time.Duration
+	restoreInsertMethod           string
+	restoreReadChunkRows          int
+	restoreWorkers                int
+	restoreTempDiskLimit          int
+	restoreWhere                  []string
+	restoreTargetURL              string
+	restoreVerifyRowCounts        bool
+	restoreRowCountReport         string
+	restoreRunManifestPrefix      string
 )
 
 var restoreCmd = &cobra.Command{
 	Use:   "restore",
 	Short: "Restore database tables from S3 archives",
-	Long:  `Restore database tables from S3 archives. Downloads files, decompresses, parses formats, and inserts data into PostgreSQL tables with automatic table/partition creation.`,
+	Long:  `Restore database tables from S3 archives. Downloads files, decompresses, parses formats, and inserts data into PostgreSQL tables with automatic table/partition creation, or bulk-loads it into ClickHouse or MySQL with --target.`,
 	Run: func(cmd *cobra.Command, _ []string) {
 		runRestore(cmd)
 	},
@@ -65,6 +93,15 @@ func init() {
 	restoreCmd.Flags().IntVar(&dbMaxRetries, "db-max-retries", 3, "Maximum number of retry attempts for failed queries")
 	restoreCmd.Flags().IntVar(&dbRetryDelay, "db-retry-delay", 5, "Delay in seconds between retry attempts")
 
+	restoreCmd.Flags().StringVar(&restoreTargetURL, "target", "", "restore target URL: postgres://, clickhouse:// (HTTP interface) or mysql:// (via the mysql client); default uses the --db-* flags")
+
+	// SSH tunnel flags
+	restoreCmd.Flags().StringVar(&restoreSSHHost, "ssh-host", "", "SSH jump host for reaching the database (empty = direct connection)")
+	restoreCmd.Flags().IntVar(&restoreSSHPort, "ssh-port", 22, "SSH port")
+	restoreCmd.Flags().StringVar(&restoreSSHUser, "ssh-user", "", "SSH user")
+	restoreCmd.Flags().StringVar(&restoreSSHKey, "ssh-key", "", "SSH private key path")
+	restoreCmd.Flags().StringVar(&restoreSSHKnownHosts, "ssh-known-hosts", "", "SSH known_hosts file (default: ~/.ssh/known_hosts)")
+
 	// S3 flags
 	restoreCmd.Flags().StringVar(&s3Endpoint, "s3-endpoint", "", "S3-compatible endpoint URL")
 	restoreCmd.Flags().StringVar(&s3Bucket, "s3-bucket", "", "S3 bucket name")
@@ -74,17 +111,44 @@ func init() {
 
 	// Restore-specific flags
 	restoreCmd.Flags().StringVar(&restoreTable, "table", "", "base table name (required)")
-	restoreCmd.Flags().StringVar(&restorePathTemplate, "path-template", "", "S3 path template with placeholders: {table}, {YYYY}, {MM}, {DD}, {HH} (required)")
+	restoreCmd.Flags().StringVar(&restorePathTemplate, "path-template", "", "S3 path template with placeholders: {table}, {format}, {compression}, {YYYY}, {MM}, {DD}, {HH}, {WW} (ISO week), {Q} (quarter); date placeholders may be lowercase (required)")
 	restoreCmd.Flags().StringVar(&restoreStartDate, "start-date", "", "start date (YYYY-MM-DD)")
 	restoreCmd.Flags().StringVar(&restoreEndDate, "end-date", "", "end date (YYYY-MM-DD)")
-	restoreCmd.Flags().StringVar(&restoreTablePartitionRange, "table-partition-range", "", "partition range: hourly, daily, monthly, quarterly, yearly")
-	restoreCmd.Flags().StringVar(&restoreTablePartitionTemplate, "table-partition-template", "", "partition name template with placeholders: {table}, {YYYY}, {MM}, {DD}, {HH}, {Q} (quarter)")
+	restoreCmd.Flags().StringVar(&restoreTablePartitionRange, "table-partition-range", "", "partition range: hourly, daily, weekly, monthly, quarterly, yearly")
+	restoreCmd.Flags().StringVar(&restoreTablePartitionTemplate, "table-partition-template", "", "partition name template with placeholders: {table}, {YYYY}, {MM}, {DD}, {HH}, {WW} (ISO week), {Q} (quarter)")
 	restoreCmd.Flags().StringVar(&restoreDateColumn, "date-column", "", "timestamp column name for splitting rows into partitions (required for hourly partitioning of daily files)")
 	restoreCmd.Flags().StringVar(&restoreOutputFormat, "output-format", "", "override format detection (jsonl, csv, parquet)")
-	restoreCmd.Flags().StringVar(&restoreCompression, "compression", "", "override compression detection (zstd, lz4, gzip, none)")
+	restoreCmd.Flags().StringVar(&restoreCompression, "compression", "", "override compression detection (zstd, lz4, gzip, brotli, xz, none)")
 	restoreCmd.Flags().StringVar(&restoreMode, "restore-mode", "schema-and-data", "Restore mode: schema-only, data-only, schema-and-data")
 	restoreCmd.Flags().StringVar(&restoreSchemaSource, "schema-source", "auto", "Schema source: pg_dump, inferred, auto, db")
 	restoreCmd.Flags().StringVar(&restoreSchemaPath, "schema-path", "", "S3 path for schema files (pg_dump) - defaults to path-template if not specified")
+	restoreCmd.Flags().BoolVar(&restoreCSVNoHeader, "csv-no-header", false, "CSV files have no header row (requires --csv-columns or --csv-schema-file)")
+	restoreCmd.Flags().StringVar(&restoreCSVColumns, "csv-columns", "", "comma-separated column names for header-less CSV files, in file order")
+	restoreCmd.Flags().StringVar(&restoreCSVSchemaFile, "csv-schema-file", "", "file listing column names for header-less CSV files, one per line")
+	restoreCmd.Flags().StringToStringVar(&restoreFieldRename, "field-rename", nil, "reverse a JSONL field rename used at archive time, as column=field pairs")
+	restoreCmd.Flags().BoolVar(&restoreCamelCaseFields, "camel-case-fields", false, "JSONL fields were written in camelCase; convert them back to snake_case columns")
+	restoreCmd.Flags().StringVar(&restoreFlattenFields, "flatten-fields", "", "comma-separated columns that were flattened at archive time; nest their fields back into JSON objects")
+	restoreCmd.Flags().StringVar(&restoreFlattenSeparator, "flatten-separator", formatters.DefaultFlattenSeparator, "separator used between a flattened column and its nested keys")
+	restoreCmd.Flags().IntVar(&restoreDownloadPartSize, "download-part-size", defaultDownloadPartSizeMB, "size in MB of each ranged GET when downloading archive files")
+	restoreCmd.Flags().IntVar(&restoreDownloadRetries, "download-retries", defaultDownloadRetries, "retries per download part (with exponential backoff) before a file is skipped")
+	restoreCmd.Flags().StringVar(&restoreDownloadDir, "download-dir", "", "directory for partial downloads kept for resuming (default: <tmp>/data-archiver/downloads)")
+	restoreCmd.Flags().BoolVar(&restoreForce, "force", false, "restore files again even if they were already restored into this database")
+	restoreCmd.Flags().BoolVar(&restoreLargeObjects, "large-objects", false, "recreate the large objects archived with --include-large-objects that restored rows reference")
+	restoreCmd.Flags().BoolVar(&restoreNoComments, "no-comments", false, "do not re-apply table and column comments archived with --include-comments")
+	restoreCmd.Flags().BoolVar(&restoreSkipSchemaCheck, "skip-schema-check", false, "insert without first checking each file's columns and values against the target table")
+	restoreCmd.Flags().StringVar(&restoreInsertMethod, "insert-method", insertMethodCopy, "how rows are loaded into PostgreSQL: copy (COPY FROM STDIN), insert (INSERT ... ON CONFLICT DO NOTHING, slower but skips rows already present)")
+	restoreCmd.Flags().IntVar(&restoreBatchSize, "batch-size", defaultInsertBatchSize, "rows per COPY or INSERT batch at the start of the restore; adjusted as inserts run")
+	restoreCmd.Flags().IntVar(&restoreMaxBatchSize, "max-batch-size", defaultInsertBatchMaxSize, "largest batch the restore grows to (set equal to --batch-size for fixed batches)")
+	restoreCmd.Flags().DurationVar(&restoreBatchTarget, "batch-target-latency", defaultInsertBatchTarget, "target time per COPY or INSERT batch; faster batches grow, slower ones shrink")
+	restoreCmd.Flags().IntVar(&restoreReadChunkRows, "read-chunk-rows", defaultReadChunkRows, "rows of a file read into memory at a time; files are streamed through validation and inserts in chunks of this size")
+	restoreCmd.Flags().IntVar(&restoreWorkers, "restore-workers", defaultRestoreWorkers, "files downloaded, decompressed and inserted at once, each over its own database connection")
+	restoreCmd.Flags().IntVar(&restoreTempDiskLimit, "temp-disk-limit", defaultTempDiskLimitMB, "most MB of downloaded files kept on disk at once across restore workers (0 = no limit)")
+	restoreCmd.Flags().StringArrayVar(&restoreWhere, "where", nil, "restore only rows matching a predicate, e.g. \"aircraft_id = 42 AND seen_at >= '2024-01-01 06:00'\" (repeatable; all must match; alias --filter)")
+	restoreCmd.Flags().StringArrayVar(&restoreWhere, "filter", nil, "alias for --where")
+	_ = restoreCmd.Flags().MarkHidden("filter")
+	restoreCmd.Flags().BoolVar(&restoreVerifyRowCounts, "verify-row-counts", false, "compare each file's inserted rows with the rows its archive manifest records (or that were read from it) and fail the restore on a mismatch")
+	restoreCmd.Flags().StringVar(&restoreRowCountReport, "row-count-report", "", "write the --verify-row-counts report to this file as JSON")
+	restoreCmd.Flags().StringVar(&restoreRunManifestPrefix, "run-manifest-prefix", defaultRunManifestPrefix, "bucket prefix of the run manifests --verify-row-counts reads row counts from")
 
 	// Bind database flags to viper
 	_ = viper.BindPFlag("db.host", restoreCmd.Flags().Lookup("db-host"))
@@ -96,6 +160,14 @@ func init() {
 	_ = viper.BindPFlag("db.statement_timeout", restoreCmd.Flags().Lookup("db-statement-timeout"))
 	_ = viper.BindPFlag("db.max_retries", restoreCmd.Flags().Lookup("db-max-retries"))
 	_ = viper.BindPFlag("db.retry_delay", restoreCmd.Flags().Lookup("db-retry-delay"))
+	_ = viper.BindPFlag("restore.target", restoreCmd.Flags().Lookup("target"))
+
+	// Bind SSH tunnel flags to viper
+	_ = viper.BindPFlag("restore.ssh.host", restoreCmd.Flags().Lookup("ssh-host"))
+	_ = viper.BindPFlag("restore.ssh.port", restoreCmd.Flags().Lookup("ssh-port"))
+	_ = viper.BindPFlag("restore.ssh.user", restoreCmd.Flags().Lookup("ssh-user"))
+	_ = viper.BindPFlag("restore.ssh.key", restoreCmd.Flags().Lookup("ssh-key"))
+	_ = viper.BindPFlag("restore.ssh.known_hosts", restoreCmd.Flags().Lookup("ssh-known-hosts"))
 
 	// Bind S3 flags to viper
 	_ = viper.BindPFlag("s3.endpoint", restoreCmd.Flags().Lookup("s3-endpoint"))
@@ -117,6 +189,31 @@ func init() {
 	_ = viper.BindPFlag("restore.mode", restoreCmd.Flags().Lookup("restore-mode"))
 	_ = viper.BindPFlag("restore.schema_source", restoreCmd.Flags().Lookup("schema-source"))
 	_ = viper.BindPFlag("restore.schema_path", restoreCmd.Flags().Lookup("schema-path"))
+	_ = viper.BindPFlag("restore.csv_no_header", restoreCmd.Flags().Lookup("csv-no-header"))
+	_ = viper.BindPFlag("restore.csv_columns", restoreCmd.Flags().Lookup("csv-columns"))
+	_ = viper.BindPFlag("restore.csv_schema_file", restoreCmd.Flags().Lookup("csv-schema-file"))
+	_ = viper.BindPFlag("restore.field_mapping.rename", restoreCmd.Flags().Lookup("field-rename"))
+	_ = viper.BindPFlag("restore.field_mapping.camel_case", restoreCmd.Flags().Lookup("camel-case-fields"))
+	_ = viper.BindPFlag("restore.field_mapping.flatten", restoreCmd.Flags().Lookup("flatten-fields"))
+	_ = viper.BindPFlag("restore.field_mapping.separator", restoreCmd.Flags().Lookup("flatten-separator"))
+	_ = viper.BindPFlag("restore.download.part_size", restoreCmd.Flags().Lookup("download-part-size"))
+	_ = viper.BindPFlag("restore.download.retries", restoreCmd.Flags().Lookup("download-retries"))
+	_ = viper.BindPFlag("restore.download.dir", restoreCmd.Flags().Lookup("download-dir"))
+	_ = viper.BindPFlag("restore.force", restoreCmd.Flags().Lookup("force"))
+	_ = viper.BindPFlag("restore.large_objects", restoreCmd.Flags().Lookup("large-objects"))
+	_ = viper.BindPFlag("restore.no_comments", restoreCmd.Flags().Lookup("no-comments"))
+	_ = viper.BindPFlag("restore.skip_schema_check", restoreCmd.Flags().Lookup("skip-schema-check"))
+	_ = viper.BindPFlag("restore.insert_method", restoreCmd.Flags().Lookup("insert-method"))
+	_ = viper.BindPFlag("restore.batch.size", restoreCmd.Flags().Lookup("batch-size"))
+	_ = viper.BindPFlag("restore.batch.max_size", restoreCmd.Flags().Lookup("max-batch-size"))
+	_ = viper.BindPFlag("restore.batch.target_latency", restoreCmd.Flags().Lookup("batch-target-latency"))
+	_ = viper.BindPFlag("restore.read_chunk_rows", restoreCmd.Flags().Lookup("read-chunk-rows"))
+	_ = viper.BindPFlag("restore.workers", restoreCmd.Flags().Lookup("restore-workers"))
+	_ = viper.BindPFlag("restore.temp_disk_limit", restoreCmd.Flags().Lookup("temp-disk-limit"))
+	_ = viper.BindPFlag("restore.where", restoreCmd.Flags().Lookup("where"))
+	_ = viper.BindPFlag("restore.verify_row_counts", restoreCmd.Flags().Lookup("verify-row-counts"))
+	_ = viper.BindPFlag("restore.row_count_report", restoreCmd.Flags().Lookup("row-count-report"))
+	_ = viper.BindPFlag("restore.run_manifest_prefix", restoreCmd.Flags().Lookup("run-manifest-prefix"))
 }
 
 // S3File represents a file found in S3
@@ -124,6 +221,7 @@ type S3File struct {
 	Key                 string
 	Size                int64
 	LastModified        time.Time
+	ETag                string
 	DetectedFormat      string
 	DetectedCompression string
 	Date                time.Time // Extracted from filename
@@ -133,17 +231,48 @@ type S3File struct {
 type Restorer struct {
 	config       *Config
 	db           *sql.DB
-	s3Client     *s3.S3
-	s3Downloader *s3manager.Downloader
+	s3Client     StorageBackend
+	downloader   *rangeDownloader
+	downloadOpts downloadOptions
+	tunnel       *sshTunnel
 	logger       *slog.Logger
 	ctx          context.Context
+	csvColumns   []string                 // Column names for header-less CSV files (nil = use header row)
+	fieldMapping *formatters.FieldMapping // Reversed on JSONL rows (nil = fields are column names)
+
+	skipSchemaCheck bool                      // Insert without validating files against the target table
+	targetColumns   map[string][]targetColumn // Target table columns, loaded once per table
+	force           bool                      // Restore files the ledger already records as restored
+	ledger          *restoreLedger            // Files already restored into the target database
+	noComments      bool                      // Leave archived table and column comments unapplied
+	largeObjects    bool                      // Recreate archived large objects the rows reference
+	largeObjectPath string                    // Schema path template the large objects are under
+	batching        batchOptions              // Insert batch sizing
+	tuner           *batchTuner               // Adapts the batch size across files
+	fileStats       insertStats               // Insert throughput for the current file
+	readChunkRows   int                       // Rows of a file held in memory at a time
+	rowFilter       *rowFilter                // Rows to restore (nil = all)
+	workers         int                       // Files restored at once
+	tempDiskLimitMB int                       // Most MB of downloaded files on disk at once (0 = no limit)
+	shared          *restoreShared            // Locks shared by the restore workers
+	progress        *restoreProgress          // Combined progress of the workers (nil = one file at a time)
+	writer          targetWriter              // ClickHouse or MySQL target (nil = PostgreSQL)
+	pgTools         *pgClientTools            // pg_restore and psql paths, detected on first use
+	rowCounts       *rowCountCheck            // Row counts of the restored files (nil = --verify-row-counts off)
+	rowCountReport  string                    // File the row count report is written to ("" = log only)
+	manifestPrefix  string                    // Bucket prefix of run manifests, for expected row counts
 }
 
 // NewRestorer creates a new Restorer instance
 func NewRestorer(config *Config, logger *slog.Logger) *Restorer {
 	return &Restorer{
-		config: config,
-		logger: logger,
+		config:        config,
+		logger:        logger,
+		downloadOpts:  downloadOptions{PartSizeMB: defaultDownloadPartSizeMB, Retries: defaultDownloadRetries},
+		batching:      batchOptions{Method: insertMethodCopy, Size: defaultInsertBatchSize, MaxSize: defaultInsertBatchMaxSize, TargetLatency: defaultInsertBatchTarget},
+		readChunkRows: defaultReadChunkRows,
+		workers:       defaultRestoreWorkers,
+		shared:        &restoreShared{},
 	}
 }
 
@@ -183,6 +312,13 @@ func runRestore(cmd *cobra.Command) {
 			StatementTimeout: getIntConfig(dbStatementTimeout, "db-statement-timeout", "db.statement_timeout"),
 			MaxRetries:       getIntConfig(dbMaxRetries, "db-max-retries", "db.max_retries"),
 			RetryDelay:       getIntConfig(dbRetryDelay, "db-retry-delay", "db.retry_delay"),
+			SSHTunnel: SSHTunnelConfig{
+				Host:           getStringConfig(restoreSSHHost, "ssh-host", "restore.ssh.host"),
+				Port:           getIntConfig(restoreSSHPort, "ssh-port", "restore.ssh.port"),
+				User:           getStringConfig(restoreSSHUser, "ssh-user", "restore.ssh.user"),
+				KeyPath:        getStringConfig(restoreSSHKey, "ssh-key", "restore.ssh.key"),
+				KnownHostsPath: getStringConfig(restoreSSHKnownHosts, "ssh-known-hosts", "restore.ssh.known_hosts"),
+			},
 		},
 		S3: S3Config{
 			Endpoint:     getStringConfig(s3Endpoint, "s3-endpoint", "s3.endpoint"),
@@ -219,6 +355,9 @@ func runRestore(cmd *cobra.Command) {
 		restoreCompressionVal = viper.GetString("restore.compression")
 	}
 
+	var s3Err error
+	config.S3, s3Err = newS3Config(config.S3, endpointProfileName("restore"))
+
 	// Initialize logger
 	initLogger(config.Debug, config.LogFormat)
 
@@ -259,10 +398,101 @@ func runRestore(cmd *cobra.Command) {
 	}
 
 	logger.Debug("Validating configuration...")
-	if err := validateRestoreConfig(config, restoreTablePartitionRangeVal, restoreModeVal, restoreSchemaSourceVal); err != nil {
+	if s3Err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", s3Err.Error()))
+		os.Exit(1)
+	}
+	writer, err := parseRestoreTarget(getStringConfig(restoreTargetURL, "target", "restore.target"), &config.Database)
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+		os.Exit(1)
+	}
+	if err := validateRestoreConfig(config, restoreTablePartitionRangeVal, restoreTablePartitionTemplateVal, restoreModeVal, restoreSchemaSourceVal); err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+		os.Exit(1)
+	}
+	if err := validateTargetOptions(writer, restoreTablePartitionRangeVal, restoreSchemaSourceVal); err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+		os.Exit(1)
+	}
+	if writer != nil {
+		logger.Info(fmt.Sprintf("🎯 Restore target: %s", writer))
+	}
+	for _, warning := range partitionTemplateWarnings(restoreTablePartitionTemplateVal, restoreTablePartitionRangeVal) {
+		logger.Warn(fmt.Sprintf("⚠️  %s", warning))
+	}
+	csvColumns, err := resolveCSVColumns(viper.GetBool("restore.csv_no_header"), viper.GetString("restore.csv_columns"), viper.GetString("restore.csv_schema_file"))
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+		os.Exit(1)
+	}
+	if csvColumns != nil {
+		logger.Debug(fmt.Sprintf("Header-less CSV columns: %s", strings.Join(csvColumns, ", ")))
+	}
+	fieldMapping := buildFieldMapping(
+		viper.GetStringMapString("restore.field_mapping.rename"),
+		viper.GetBool("restore.field_mapping.camel_case"),
+		viper.GetString("restore.field_mapping.flatten"),
+		viper.GetString("restore.field_mapping.separator"),
+	)
+	if fieldMapping != nil {
+		if err := validateFieldMapping(fieldMapping); err != nil {
+			logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+			os.Exit(1)
+		}
+	}
+	downloadOpts := downloadOptions{
+		Dir:        getStringConfig(restoreDownloadDir, "download-dir", "restore.download.dir"),
+		PartSizeMB: getIntConfig(restoreDownloadPartSize, "download-part-size", "restore.download.part_size"),
+		Retries:    getIntConfig(restoreDownloadRetries, "download-retries", "restore.download.retries"),
+	}
+	downloadRate, err := parseByteRate(getStringConfig(maxDownloadRate, "max-download-rate", "restore.download.max_rate"))
+	if err == nil {
+		downloadOpts.MaxRate = downloadRate
+		err = downloadOpts.validate()
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+		os.Exit(1)
+	}
+	batching := batchOptions{
+		Method:        getStringConfig(restoreInsertMethod, "insert-method", "restore.insert_method"),
+		Size:          getIntConfig(restoreBatchSize, "batch-size", "restore.batch.size"),
+		MaxSize:       getIntConfig(restoreMaxBatchSize, "max-batch-size", "restore.batch.max_size"),
+		TargetLatency: viper.GetDuration("restore.batch.target_latency"),
+	}
+	if err := batching.validate(); err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+		os.Exit(1)
+	}
+	readChunkRows := getIntConfig(restoreReadChunkRows, "read-chunk-rows", "restore.read_chunk_rows")
+	if readChunkRows < 1 {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s, got %d", ErrReadChunkRowsInvalid, readChunkRows))
+		os.Exit(1)
+	}
+	workers := getIntConfig(restoreWorkers, "restore-workers", "restore.workers")
+	if workers < 1 {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s, got %d", ErrRestoreWorkersInvalid, workers))
+		os.Exit(1)
+	}
+	tempDiskLimit := getIntConfig(restoreTempDiskLimit, "temp-disk-limit", "restore.temp_disk_limit")
+	if tempDiskLimit < 0 {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s, got %d", ErrTempDiskLimitInvalid, tempDiskLimit))
+		os.Exit(1)
+	}
+	// The flag is read directly: viper would split each predicate at its commas
+	whereClauses := viper.GetStringSlice("restore.where")
+	if cmd.Flags().Changed("where") || cmd.Flags().Changed("filter") {
+		whereClauses = restoreWhere
+	}
+	filter, err := newRowFilter(whereClauses)
+	if err != nil {
 		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
 		os.Exit(1)
 	}
+	if filter != nil {
+		logger.Info(fmt.Sprintf("🔎 Restoring only rows where %s", filter))
+	}
 	logger.Debug("Configuration validated successfully")
 
 	ctx := signalContext
@@ -272,9 +502,38 @@ func runRestore(cmd *cobra.Command) {
 		defer stop()
 	}
 
+	stopStopFileWatcher := startStopFileWatcher("restore", config.Table)
+	defer stopStopFileWatcher()
+	logStopFileHint("restore")
+
 	// Get schema source and path (already retrieved above for config display)
 
 	restorer := NewRestorer(config, logger)
+	restorer.csvColumns = csvColumns
+	restorer.fieldMapping = fieldMapping
+	restorer.downloadOpts = downloadOpts
+	restorer.batching = batching
+	restorer.readChunkRows = readChunkRows
+	restorer.rowFilter = filter
+	restorer.workers = workers
+	restorer.tempDiskLimitMB = tempDiskLimit
+	restorer.skipSchemaCheck = viper.GetBool("restore.skip_schema_check")
+	restorer.force = viper.GetBool("restore.force")
+	restorer.noComments = viper.GetBool("restore.no_comments")
+	restorer.largeObjects = viper.GetBool("restore.large_objects")
+	restorer.writer = writer
+	if viper.GetBool("restore.verify_row_counts") {
+		if config.DryRun {
+			logger.Warn("⚠️  --verify-row-counts has nothing to check in a dry run")
+		} else {
+			restorer.rowCounts = newRowCountCheck()
+			restorer.rowCountReport = viper.GetString("restore.row_count_report")
+			restorer.manifestPrefix = viper.GetString("restore.run_manifest_prefix")
+		}
+	}
+	if writer == nil && (restoreSchemaSourceVal == "pg_dump" || restoreSchemaSourceVal == "auto") {
+		restorer.logClientTools()
+	}
 
 	// Store restore-specific config in a way we can access it
 	restoreConfig := map[string]string{
@@ -288,7 +547,7 @@ func runRestore(cmd *cobra.Command) {
 		"schema_path":              restoreSchemaPathVal,
 	}
 
-	err := restorer.Run(ctx, restoreConfig)
+	err = restorer.Run(ctx, restoreConfig)
 	if err != nil {
 		if errors.Is(err, context.Canceled) {
 			logger.Info("")
@@ -319,6 +578,9 @@ func printRestoreConfig(config *Config, partitionRange, tablePartitionTemplate,
 	logger.Debug(fmt.Sprintf("    Statement Timeout: %d seconds", config.Database.StatementTimeout))
 	logger.Debug(fmt.Sprintf("    Max Retries:       %d", config.Database.MaxRetries))
 	logger.Debug(fmt.Sprintf("    Retry Delay:       %d seconds", config.Database.RetryDelay))
+	if config.Database.SSHTunnel.Enabled() {
+		logger.Debug(fmt.Sprintf("    SSH Tunnel:        %s@%s", config.Database.SSHTunnel.User, config.Database.SSHTunnel.address()))
+	}
 
 	// S3 configuration
 	logger.Debug("  S3:")
@@ -383,16 +645,28 @@ func maskString(s string) string {
 }
 
 // validateRestoreConfig validates restore-specific configuration
-func validateRestoreConfig(config *Config, partitionRange string, restoreMode string, schemaSource string) error {
+func validateRestoreConfig(config *Config, partitionRange string, partitionTemplate string, restoreMode string, schemaSource string) error {
 	if config.Table == "" {
 		return errors.New("table name is required for restore")
 	}
 	if config.S3.PathTemplate == "" {
 		return errors.New("path template is required for restore")
 	}
+	if _, err := parseTemplate("path template", config.S3.PathTemplate, pathTemplatePlaceholders); err != nil {
+		return err
+	}
+	if _, err := parseTemplate("partition template", partitionTemplate, partitionTemplatePlaceholders); err != nil {
+		return err
+	}
 	if !isValidTableName(config.Table) {
 		return fmt.Errorf("%w: '%s'", ErrTableNameInvalid, config.Table)
 	}
+	if err := config.Database.SSHTunnel.Validate(); err != nil {
+		return err
+	}
+	if err := config.S3.HTTP.Validate(); err != nil {
+		return err
+	}
 
 	// Validate restore mode
 	validModes := map[string]bool{
@@ -424,12 +698,13 @@ func validateRestoreConfig(config *Config, partitionRange string, restoreMode st
 		validRanges := map[string]bool{
 			"hourly":    true,
 			"daily":     true,
+			"weekly":    true,
 			"monthly":   true,
 			"quarterly": true,
 			"yearly":    true,
 		}
 		if !validRanges[partitionRange] {
-			return fmt.Errorf("invalid partition range: %s (must be: hourly, daily, monthly, quarterly, yearly)", partitionRange)
+			return fmt.Errorf("invalid partition range: %s (must be: hourly, daily, weekly, monthly, quarterly, yearly)", partitionRange)
 		}
 	}
 	return nil
@@ -437,14 +712,27 @@ func validateRestoreConfig(config *Config, partitionRange string, restoreMode st
 
 // connect connects to database and S3
 func (r *Restorer) connect(ctx context.Context) error {
+	if r.writer != nil {
+		if err := r.writer.Ping(ctx); err != nil {
+			return fmt.Errorf("failed to reach %s: %w", r.writer, err)
+		}
+		return r.connectS3()
+	}
+
 	sslMode := r.config.Database.SSLMode
 	if sslMode == "" {
 		sslMode = "disable"
 	}
 
+	tunnel, host, port, err := openDatabaseTunnel(ctx, r.config.Database)
+	if err != nil {
+		return fmt.Errorf("failed to open SSH tunnel: %w", err)
+	}
+	r.tunnel = tunnel
+
 	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		r.config.Database.Host,
-		r.config.Database.Port,
+		host,
+		port,
 		r.config.Database.User,
 		r.config.Database.Password,
 		r.config.Database.Name,
@@ -466,22 +754,29 @@ func (r *Restorer) connect(ctx context.Context) error {
 		return err
 	}
 
+	// Keep a connection per restore worker open between batches
+	db.SetMaxIdleConns(max(r.workers, 2))
 	r.db = db
 
-	sess, err := session.NewSession(&aws.Config{
-		Endpoint:         aws.String(r.config.S3.Endpoint),
-		Region:           aws.String(r.config.S3.Region),
-		Credentials:      credentials.NewStaticCredentials(r.config.S3.AccessKey, r.config.S3.SecretKey, ""),
-		S3ForcePathStyle: aws.Bool(true),
-	})
-	if err != nil {
+	if err := r.connectS3(); err != nil {
 		db.Close()
 		r.db = nil
-		return fmt.Errorf("failed to create S3 session: %w", err)
+		return err
 	}
 
-	r.s3Client = s3.New(sess)
-	r.s3Downloader = s3manager.NewDownloader(sess)
+	return nil
+}
+
+// connectS3 creates the S3 client and downloader
+func (r *Restorer) connectS3() error {
+	client, err := newStorageClient(r.config.S3)
+	if err != nil {
+		return fmt.Errorf("failed to create storage client: %w", err)
+	}
+
+	r.s3Client = client
+	r.downloader = newRangeDownloader(r.s3Client, r.config.S3.Bucket, r.downloadOpts.Dir, r.downloadOpts.PartSizeMB, r.downloadOpts.Retries, r.logger)
+	r.downloader.rate = newBandwidthLimiter(r.downloadOpts.MaxRate)
 
 	return nil
 }
@@ -506,6 +801,8 @@ func detectFormatAndCompression(filename string, overrideFormat, overrideCompres
 			baseExt := strings.TrimSuffix(filename, ".zst")
 			baseExt = strings.TrimSuffix(baseExt, ".lz4")
 			baseExt = strings.TrimSuffix(baseExt, ".gz")
+			baseExt = strings.TrimSuffix(baseExt, ".br")
+			baseExt = strings.TrimSuffix(baseExt, ".xz")
 			baseExt = filepath.Ext(baseExt)
 			switch baseExt {
 			case ".jsonl":
@@ -531,6 +828,10 @@ func detectFormatAndCompression(filename string, overrideFormat, overrideCompres
 			compression = "lz4"
 		} else if strings.HasSuffix(lowerFilename, ".gz") {
 			compression = "gzip"
+		} else if strings.HasSuffix(lowerFilename, ".br") {
+			compression = "brotli"
+		} else if strings.HasSuffix(lowerFilename, ".xz") {
+			compression = "xz"
 		} else {
 			compression = "none"
 		}
@@ -541,6 +842,20 @@ func detectFormatAndCompression(filename string, overrideFormat, overrideCompres
 
 // extractDateFromFilename extracts date from filename patterns
 func extractDateFromFilename(filename string) (time.Time, bool) {
+	// Weekly and quarterly files: table-YYYY-Www (ISO week) or table-YYYY-Qn
+	if matches := regexp.MustCompile(`(\d{4})-(W(\d{2})|Q([1-4]))(?:[-.]|$)`).FindStringSubmatch(filename); matches != nil {
+		year, _ := strconv.Atoi(matches[1])
+		if matches[3] != "" {
+			week, _ := strconv.Atoi(matches[3])
+			if week >= 1 && week <= 53 {
+				return isoWeekStart(year, week, time.UTC), true
+			}
+		} else {
+			quarter, _ := strconv.Atoi(matches[4])
+			return time.Date(year, time.Month((quarter-1)*3+1), 1, 0, 0, 0, 0, time.UTC), true
+		}
+	}
+
 	// Pattern 1: table-YYYY-MM-DD or table-YYYY-MM-DD-HH
 	re1 := regexp.MustCompile(`(\d{4})-(\d{2})-(\d{2})(?:-(\d{2}))?`)
 	matches := re1.FindStringSubmatch(filename)
@@ -589,12 +904,15 @@ func extractDateFromFilename(filename string) (time.Time, bool) {
 // discoverS3Files discovers files in S3 matching the path template and date range
 func (r *Restorer) discoverS3Files(ctx context.Context, tableName string, startDate, endDate *time.Time, partitionRange string) ([]S3File, error) {
 	// Build base path from template (replace {table} placeholder)
-	basePath := strings.ReplaceAll(r.config.S3.PathTemplate, "{table}", tableName)
+	basePath := strings.ReplaceAll(r.config.S3.PathTemplate, "{table}", objectKeyComponent(tableName))
 
-	// Remove date placeholders for listing (we'll match files by pattern)
+	// List from the static part of the template, up to its first placeholder
+	// (we'll match files by pattern), so Hive-style segments like
+	// dt={YYYY}-{MM}-{DD} don't leave their separators in the prefix
 	listPrefix := basePath
-	// Remove date placeholders to get a prefix for listing
-	listPrefix = regexp.MustCompile(`\{YYYY\}|\{MM\}|\{DD\}|\{HH\}`).ReplaceAllString(listPrefix, "")
+	if i := strings.Index(listPrefix, "{"); i >= 0 {
+		listPrefix = listPrefix[:i]
+	}
 
 	// Clean up double slashes and ensure proper prefix format
 	listPrefix = regexp.MustCompile(`/+`).ReplaceAllString(listPrefix, "/")
@@ -603,85 +921,75 @@ func (r *Restorer) discoverS3Files(ctx context.Context, tableName string, startD
 
 	r.logger.Debug(fmt.Sprintf("Discovering files in S3 with prefix: %s", listPrefix))
 
-	var files []S3File
-	var continuationToken *string
+	var objects []ObjectInfo
+	err := r.s3Client.List(ctx, r.config.S3.Bucket, listPrefix, func(object ObjectInfo) bool {
+		objects = append(objects, object)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list S3 objects: %w", err)
+	}
 
-	for {
-		listInput := &s3.ListObjectsV2Input{
-			Bucket:            aws.String(r.config.S3.Bucket),
-			Prefix:            aws.String(listPrefix),
-			ContinuationToken: continuationToken,
-		}
+	r.logger.Debug(fmt.Sprintf("Listing returned %d objects", len(objects)))
 
-		result, err := r.s3Client.ListObjectsV2WithContext(ctx, listInput)
-		if err != nil {
-			return nil, fmt.Errorf("failed to list S3 objects: %w", err)
-		}
+	var files []S3File
+	for _, obj := range objects {
+		key := obj.Key
 
-		r.logger.Debug(fmt.Sprintf("ListObjectsV2 returned %d objects", len(result.Contents)))
+		r.logger.Debug(fmt.Sprintf("Found S3 object: %s", key))
 
-		for _, obj := range result.Contents {
-			key := aws.StringValue(obj.Key)
+		// Skip directories
+		if strings.HasSuffix(key, "/") {
+			r.logger.Debug(fmt.Sprintf("Skipping directory: %s", key))
+			continue
+		}
 
-			r.logger.Debug(fmt.Sprintf("Found S3 object: %s", key))
+		filename := filepath.Base(key)
 
-			// Skip directories
-			if strings.HasSuffix(key, "/") {
-				r.logger.Debug(fmt.Sprintf("Skipping directory: %s", key))
-				continue
-			}
+		// Try to extract date from filename (optional for non-partitioned tables)
+		fileDate, hasDate := extractDateFromFilename(filename)
 
-			filename := filepath.Base(key)
+		// If partition range is set, we require dates; otherwise dates are optional
+		if partitionRange != "" && !hasDate {
+			r.logger.Debug(fmt.Sprintf("Skipping file %s: partitioned table requires date in filename %s", key, filename))
+			continue
+		}
 
-			// Try to extract date from filename (optional for non-partitioned tables)
-			fileDate, hasDate := extractDateFromFilename(filename)
+		// If date was extracted, filter by date range (if provided)
+		if hasDate {
+			r.logger.Debug(fmt.Sprintf("Extracted date %s from filename %s", fileDate.Format("2006-01-02"), filename))
 
-			// If partition range is set, we require dates; otherwise dates are optional
-			if partitionRange != "" && !hasDate {
-				r.logger.Debug(fmt.Sprintf("Skipping file %s: partitioned table requires date in filename %s", key, filename))
+			// Filter by date range (only if dates are provided)
+			if startDate != nil && fileDate.Before(*startDate) {
+				r.logger.Debug(fmt.Sprintf("Skipping file %s: date %s is before start date %s", key, fileDate.Format("2006-01-02"), startDate.Format("2006-01-02")))
 				continue
 			}
-
-			// If date was extracted, filter by date range (if provided)
-			if hasDate {
-				r.logger.Debug(fmt.Sprintf("Extracted date %s from filename %s", fileDate.Format("2006-01-02"), filename))
-
-				// Filter by date range (only if dates are provided)
-				if startDate != nil && fileDate.Before(*startDate) {
-					r.logger.Debug(fmt.Sprintf("Skipping file %s: date %s is before start date %s", key, fileDate.Format("2006-01-02"), startDate.Format("2006-01-02")))
-					continue
-				}
-				if endDate != nil && fileDate.After(*endDate) {
-					r.logger.Debug(fmt.Sprintf("Skipping file %s: date %s is after end date %s", key, fileDate.Format("2006-01-02"), endDate.Format("2006-01-02")))
-					continue
-				}
-			} else {
-				// For non-partitioned tables without dates, use file's last modified time as date
-				fileDate = aws.TimeValue(obj.LastModified)
-				r.logger.Debug(fmt.Sprintf("No date in filename %s, using last modified time: %s", filename, fileDate.Format("2006-01-02")))
-			}
-
-			// Detect format and compression
-			format, compression, err := detectFormatAndCompression(filename, "", "")
-			if err != nil {
-				r.logger.Debug(fmt.Sprintf("Skipping file %s: %v", key, err))
+			if endDate != nil && fileDate.After(*endDate) {
+				r.logger.Debug(fmt.Sprintf("Skipping file %s: date %s is after end date %s", key, fileDate.Format("2006-01-02"), endDate.Format("2006-01-02")))
 				continue
 			}
-
-			files = append(files, S3File{
-				Key:                 key,
-				Size:                aws.Int64Value(obj.Size),
-				LastModified:        aws.TimeValue(obj.LastModified),
-				DetectedFormat:      format,
-				DetectedCompression: compression,
-				Date:                fileDate,
-			})
+		} else {
+			// For non-partitioned tables without dates, use file's last modified time as date
+			fileDate = obj.LastModified
+			r.logger.Debug(fmt.Sprintf("No date in filename %s, using last modified time: %s", filename, fileDate.Format("2006-01-02")))
 		}
 
-		if !aws.BoolValue(result.IsTruncated) {
-			break
+		// Detect format and compression
+		format, compression, err := detectFormatAndCompression(filename, "", "")
+		if err != nil {
+			r.logger.Debug(fmt.Sprintf("Skipping file %s: %v", key, err))
+			continue
 		}
-		continuationToken = result.NextContinuationToken
+
+		files = append(files, S3File{
+			Key:                 key,
+			Size:                obj.Size,
+			LastModified:        obj.LastModified,
+			ETag:                strings.Trim(obj.ETag, `"`),
+			DetectedFormat:      format,
+			DetectedCompression: compression,
+			Date:                fileDate,
+		})
 	}
 
 	r.logger.Info(fmt.Sprintf("Found %d files to restore", len(files)))
@@ -759,6 +1067,8 @@ func inferPostgreSQLType(value interface{}) string {
 		return "bytea"
 	case time.Time:
 		return "timestamptz"
+	case map[string]interface{}, []interface{}:
+		return "jsonb"
 	default:
 		return "text"
 	}
@@ -778,6 +1088,9 @@ func sortColumns(columns []ColumnInfo) {
 
 // ensureTableExists ensures the table exists, creating it if necessary
 func (r *Restorer) ensureTableExists(ctx context.Context, tableName string, schema *TableSchema) error {
+	r.shared.schema.Lock()
+	defer r.shared.schema.Unlock()
+
 	// Check if table exists
 	var exists bool
 	checkQuery := `
@@ -859,20 +1172,14 @@ func mapPostgreSQLTypeToSQLType(udtName string) string {
 
 // generatePartitionName generates a partition name from a template or default pattern
 func generatePartitionName(baseTable string, partitionDate time.Time, partitionRange string, template string) string {
+	// Name the partition after the start of its period, whichever date of it was given
+	if partitionRange != "" {
+		partitionDate, _ = GetTimeRangeForDuration(partitionDate, partitionRange)
+	}
+
 	// If template is provided, use it
 	if template != "" {
-		result := template
-		result = strings.ReplaceAll(result, "{table}", baseTable)
-		result = strings.ReplaceAll(result, "{YYYY}", partitionDate.Format("2006"))
-		result = strings.ReplaceAll(result, "{MM}", partitionDate.Format("01"))
-		result = strings.ReplaceAll(result, "{DD}", partitionDate.Format("02"))
-		result = strings.ReplaceAll(result, "{HH}", partitionDate.Format("15"))
-
-		// Calculate quarter
-		quarter := (int(partitionDate.Month())-1)/3 + 1
-		result = strings.ReplaceAll(result, "{Q}", fmt.Sprintf("%d", quarter))
-
-		return result
+		return renderDatePlaceholders(strings.ReplaceAll(template, "{table}", baseTable), partitionDate)
 	}
 
 	// Otherwise use default pattern based on range
@@ -881,11 +1188,13 @@ func generatePartitionName(baseTable string, partitionDate time.Time, partitionR
 		return fmt.Sprintf("%s_%s", baseTable, partitionDate.Format("2006010215"))
 	case "daily":
 		return fmt.Sprintf("%s_%s", baseTable, partitionDate.Format("20060102"))
+	case DurationWeekly:
+		year, week := partitionDate.ISOWeek()
+		return fmt.Sprintf("%s_%dW%02d", baseTable, year, week)
 	case "monthly":
 		return fmt.Sprintf("%s_%s", baseTable, partitionDate.Format("200601"))
 	case "quarterly":
-		quarter := (int(partitionDate.Month())-1)/3 + 1
-		return fmt.Sprintf("%s_%dQ%d", baseTable, partitionDate.Year(), quarter)
+		return fmt.Sprintf("%s_%dQ%d", baseTable, partitionDate.Year(), quarterOf(partitionDate))
 	case "yearly":
 		return fmt.Sprintf("%s_%d", baseTable, partitionDate.Year())
 	default:
@@ -903,6 +1212,13 @@ func (r *Restorer) ensurePartitionExists(ctx context.Context, baseTable string,
 
 	// Generate partition name
 	partitionName := generatePartitionName(baseTable, partitionDate, partitionRange, partitionTemplate)
+	if err := checkIdentifierLength(partitionName); err != nil {
+		return fmt.Errorf("partition name for %s: %w", partitionDate.Format("2006-01-02 15:04"), err)
+	}
+
+	// Workers restoring files of the same partition create it once
+	r.shared.schema.Lock()
+	defer r.shared.schema.Unlock()
 
 	// Check if partition exists
 	var exists bool
@@ -975,6 +1291,13 @@ func (r *Restorer) createPartitionsForDateRange(ctx context.Context, baseTable s
 			partitionDates = append(partitionDates, current)
 			current = current.AddDate(0, 0, 1)
 		}
+	case DurationWeekly:
+		// ISO weeks start on Monday
+		current, _ = GetTimeRangeForDuration(current, DurationWeekly)
+		for !current.After(*endDate) {
+			partitionDates = append(partitionDates, current)
+			current = current.AddDate(0, 0, 7)
+		}
 	case "monthly":
 		current = time.Date(current.Year(), current.Month(), 1, 0, 0, 0, 0, current.Location())
 		for !current.After(*endDate) {
@@ -983,8 +1306,7 @@ func (r *Restorer) createPartitionsForDateRange(ctx context.Context, baseTable s
 		}
 	case "quarterly":
 		// Quarters: Jan-Mar, Apr-Jun, Jul-Sep, Oct-Dec
-		quarter := (int(current.Month()) - 1) / 3
-		current = time.Date(current.Year(), time.Month(quarter*3+1), 1, 0, 0, 0, 0, current.Location())
+		current, _ = GetTimeRangeForDuration(current, DurationQuarterly)
 		for !current.After(*endDate) {
 			partitionDates = append(partitionDates, current)
 			current = current.AddDate(0, 3, 0)
@@ -1145,68 +1467,83 @@ func (r *Restorer) insertRowsByHour(ctx context.Context, baseTable string, rows
 	return nil
 }
 
-// insertRows inserts rows into the table with ON CONFLICT DO NOTHING
+// insertRows loads rows into the table in batches sized by the restorer's
+// batch tuner, with COPY or with INSERT ... ON CONFLICT DO NOTHING. When COPY
+// hits rows already present, the rest go in with INSERT, which skips them. A
+// failed batch is retried at a smaller size until a single row fails.
 func (r *Restorer) insertRows(ctx context.Context, tableName string, rows []map[string]interface{}, schema *TableSchema) error {
 	if len(rows) == 0 {
 		return nil
 	}
 
-	// Build column names
-	columnNames := make([]string, len(schema.Columns))
-	for i, col := range schema.Columns {
-		columnNames[i] = pq.QuoteIdentifier(col.Name)
-	}
-
-	// Build INSERT statement with ON CONFLICT DO NOTHING
-	// Note: This assumes there's a primary key or unique constraint
-	// For now, we'll use a simple approach and let PostgreSQL handle conflicts
-	placeholders := make([]string, len(columnNames))
-	for i := range placeholders {
-		placeholders[i] = fmt.Sprintf("$%d", i+1)
-	}
-
-	insertQuery := fmt.Sprintf(
-		"INSERT INTO %s (%s) VALUES (%s) ON CONFLICT DO NOTHING",
-		pq.QuoteIdentifier(tableName),
-		strings.Join(columnNames, ", "),
-		strings.Join(placeholders, ", "),
-	)
-
 	if r.config.DryRun {
 		r.logger.Info(fmt.Sprintf("[DRY RUN] Would insert %d rows into %s", len(rows), tableName))
 		return nil
 	}
 
-	// Use batch inserts for performance
-	batchSize := 1000
-	totalInserted := 0
+	if r.tuner == nil {
+		r.tuner = newBatchTuner(r.batching)
+	}
 
-	for i := 0; i < len(rows); i += batchSize {
-		end := i + batchSize
-		if end > len(rows) {
-			end = len(rows)
+	// Once a file's rows conflict with existing ones, the rest of the file is
+	// inserted with ON CONFLICT DO NOTHING
+	useCopy := r.batching.Method == insertMethodCopy && !r.fileStats.FellBack
+	var totalInserted int64
+	if r.fileStats.reported.IsZero() {
+		r.fileStats.reported = time.Now()
+	}
+	for i := 0; i < len(rows); {
+		// COPY has no bind parameters to stay within
+		columns := len(schema.Columns)
+		if useCopy {
+			columns = 0
 		}
-
+		end := min(i+r.tuner.next(columns), len(rows))
 		batch := rows[i:end]
-		inserted := 0
 
-		for _, row := range batch {
-			values := make([]interface{}, len(columnNames))
-			for j, col := range schema.Columns {
-				val := row[col.Name]
-				values[j] = convertValueForPostgreSQL(val, col.UDTName)
+		start := time.Now()
+		var inserted int64
+		var err error
+		if useCopy {
+			inserted, err = r.copyBatch(ctx, tableName, batch, schema)
+		} else {
+			inserted, err = r.insertBatch(ctx, tableName, batch, schema)
+		}
+		elapsed := time.Since(start)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
 			}
-
-			result, err := r.db.ExecContext(ctx, insertQuery, values...)
-			if err != nil {
+			if useCopy && isUniqueViolation(err) {
+				useCopy = false
+				r.fileStats.FellBack = true
+				r.logger.Info(fmt.Sprintf("Some rows are already in %s; inserting the rest with ON CONFLICT DO NOTHING", tableName))
+				continue
+			}
+			if len(batch) == 1 {
 				return fmt.Errorf("failed to insert row: %w", err)
 			}
-
-			rowsAffected, _ := result.RowsAffected()
-			inserted += int(rowsAffected)
+			r.tuner.failed()
+			r.fileStats.Retries++
+			r.logger.Debug(fmt.Sprintf("Batch of %d rows into %s failed, retrying with %d: %v", len(batch), tableName, r.tuner.next(len(schema.Columns)), err))
+			continue
 		}
 
+		r.fileStats.add(len(batch), inserted, elapsed)
+		if !useCopy {
+			r.fileStats.Existing += int64(len(batch)) - inserted
+		}
+		r.progress.addRows(len(batch))
+		r.tuner.observe(len(batch), elapsed)
 		totalInserted += inserted
+		i = end
+
+		// Progress covers the whole file, which arrives over several calls.
+		// Concurrent workers report their progress together instead.
+		if r.progress == nil && time.Since(r.fileStats.reported) >= insertProgressInterval {
+			r.fileStats.reported = time.Now()
+			r.logger.Info(fmt.Sprintf("  %s: %d rows so far (%.0f rows/s)", tableName, r.fileStats.Rows, r.fileStats.RowsPerSecond()))
+		}
 	}
 
 	r.logger.Debug(fmt.Sprintf("Inserted %d/%d rows into %s", totalInserted, len(rows), tableName))
@@ -1233,9 +1570,35 @@ func convertValueForPostgreSQL(value interface{}, pgType string) interface{} {
 		}
 	}
 
+	// Handle JSON objects and arrays (e.g. re-nested flattened fields)
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		if data, err := json.Marshal(value); err == nil {
+			return string(data)
+		}
+	}
+
 	return value
 }
 
+// readFileRows decompresses and parses a whole archived file into rows,
+// reversing any JSONL field mapping. Restore streams large files with
+// openFileRows instead.
+func (r *Restorer) readFileRows(file io.Reader, format, compression string) ([]map[string]interface{}, error) {
+	stream, err := r.openFileRows(file, format, compression)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var rows []map[string]interface{}
+	err = stream.each(func(chunk []map[string]interface{}) error {
+		rows = append(rows, chunk...)
+		return nil
+	})
+	return rows, err
+}
+
 // extractSchema extracts schema based on the specified source
 func (r *Restorer) extractSchema(ctx context.Context, schemaSource, schemaPath string, files []S3File, overrideFormat, overrideCompression string) (*TableSchema, error) {
 	switch schemaSource {
@@ -1272,45 +1635,27 @@ func (r *Restorer) extractSchemaFromPgDump(ctx context.Context, schemaPath strin
 
 	r.logger.Debug(fmt.Sprintf("Looking for pg_dump files in S3 path: %s", prefix))
 
-	var allObjects []*s3.Object
-	var continuationToken *string
-
-	// Handle pagination
-	for {
-		listInput := &s3.ListObjectsV2Input{
-			Bucket:            aws.String(r.config.S3.Bucket),
-			Prefix:            aws.String(prefix),
-			ContinuationToken: continuationToken,
-		}
-
-		result, err := r.s3Client.ListObjectsV2WithContext(ctx, listInput)
-		if err != nil {
-			return nil, fmt.Errorf("failed to list S3 objects: %w", err)
-		}
-
-		r.logger.Debug(fmt.Sprintf("ListObjectsV2 returned %d objects", len(result.Contents)))
-
-		allObjects = append(allObjects, result.Contents...)
-
-		// Check if there are more pages
-		if !aws.BoolValue(result.IsTruncated) {
-			break
-		}
-		continuationToken = result.NextContinuationToken
+	var allObjects []ObjectInfo
+	err := r.s3Client.List(ctx, r.config.S3.Bucket, prefix, func(object ObjectInfo) bool {
+		allObjects = append(allObjects, object)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list S3 objects: %w", err)
 	}
 
 	r.logger.Debug(fmt.Sprintf("Found %d total objects in %s", len(allObjects), prefix))
 
 	// Log all files found for debugging
 	for _, obj := range allObjects {
-		key := aws.StringValue(obj.Key)
+		key := obj.Key
 		r.logger.Debug(fmt.Sprintf("Found S3 object: %s", key))
 	}
 
-	// Find pg_dump files (typically .sql, .sql.gz, .sql.zst, .sql.lz4, or .dump)
+	// Find pg_dump files (typically .sql, .sql.gz, .sql.zst, .sql.lz4, .sql.br, .sql.xz, or .dump)
 	var pgDumpFiles []string
 	for _, obj := range allObjects {
-		key := aws.StringValue(obj.Key)
+		key := obj.Key
 		// Skip directories
 		if strings.HasSuffix(key, "/") {
 			continue
@@ -1320,6 +1665,8 @@ func (r *Restorer) extractSchemaFromPgDump(ctx context.Context, schemaPath strin
 			strings.HasSuffix(key, ".sql.gz") ||
 			strings.HasSuffix(key, ".sql.zst") ||
 			strings.HasSuffix(key, ".sql.lz4") ||
+			strings.HasSuffix(key, ".sql.br") ||
+			strings.HasSuffix(key, ".sql.xz") ||
 			strings.HasSuffix(key, ".dump") ||
 			strings.HasSuffix(key, "-schema.dump")
 
@@ -1363,8 +1710,8 @@ func (r *Restorer) extractSchemaFromPgDump(ctx context.Context, schemaPath strin
 	// Check S3 object size before downloading
 	var fileSize int64
 	for _, obj := range allObjects {
-		if aws.StringValue(obj.Key) == pgDumpFile {
-			fileSize = aws.Int64Value(obj.Size)
+		if obj.Key == pgDumpFile {
+			fileSize = obj.Size
 			r.logger.Debug(fmt.Sprintf("S3 object size: %d bytes", fileSize))
 			if fileSize == 0 {
 				return nil, fmt.Errorf("pg_dump file %s is empty (0 bytes) in S3", pgDumpFile)
@@ -1374,21 +1721,18 @@ func (r *Restorer) extractSchemaFromPgDump(ctx context.Context, schemaPath strin
 	}
 
 	// Download the dump file
-	tempFile, err := os.CreateTemp("", "pg_dump-*.dump")
+	r.logger.Debug(fmt.Sprintf("Downloading pg_dump file: %s", pgDumpFile))
+	dumpPath, downloadedBytes, err := r.downloader.Download(ctx, pgDumpFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %w", err)
+		return nil, fmt.Errorf("failed to download pg_dump file: %w", err)
 	}
-	defer os.Remove(tempFile.Name())
-	defer tempFile.Close()
+	defer os.Remove(dumpPath)
 
-	r.logger.Debug(fmt.Sprintf("Downloading pg_dump file: %s", pgDumpFile))
-	downloadedBytes, err := r.s3Downloader.DownloadWithContext(ctx, tempFile, &s3.GetObjectInput{
-		Bucket: aws.String(r.config.S3.Bucket),
-		Key:    aws.String(pgDumpFile),
-	})
+	tempFile, err := os.Open(dumpPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to download pg_dump file: %w", err)
+		return nil, fmt.Errorf("failed to open downloaded pg_dump file: %w", err)
 	}
+	defer tempFile.Close()
 
 	// Check downloaded file size
 	if downloadedBytes == 0 {
@@ -1408,6 +1752,17 @@ func (r *Restorer) extractSchemaFromPgDump(ctx context.Context, schemaPath strin
 
 	tempFile.Close()
 
+	// Without pg_restore a custom-format dump cannot be read at all, so fail
+	// before dropping anything
+	tools := r.clientTools()
+	customFormat, err := isCustomFormatDump(tempFile.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read downloaded pg_dump file: %w", err)
+	}
+	if customFormat && tools.PgRestore == "" {
+		return nil, fmt.Errorf("%w: %s", ErrPgRestoreMissing, pgDumpFile)
+	}
+
 	// Drop the table and sequences if they exist (to avoid conflicts)
 	// We do this manually to ensure everything is clean before pg_restore
 	if r.db != nil {
@@ -1437,6 +1792,9 @@ func (r *Restorer) extractSchemaFromPgDump(ctx context.Context, schemaPath strin
 	if sslMode == "" {
 		sslMode = "disable"
 	}
+	if !customFormat && tools.PgRestore == "" {
+		return r.restoreTextSchema(ctx, tools, tempFile.Name(), sslMode)
+	}
 
 	// First, try pg_restore (for custom format dumps)
 	r.logger.Debug("Attempting to restore using pg_restore (custom format)...")
@@ -1455,7 +1813,7 @@ func (r *Restorer) extractSchemaFromPgDump(ctx context.Context, schemaPath strin
 		tempFile.Name(),
 	}
 
-	cmd := exec.CommandContext(ctx, "pg_restore", connArgs...)
+	cmd := exec.CommandContext(ctx, tools.PgRestore, connArgs...)
 	env := os.Environ()
 	env = append(env,
 		fmt.Sprintf("PGPASSWORD=%s", r.config.Database.Password),
@@ -1472,8 +1830,7 @@ func (r *Restorer) extractSchemaFromPgDump(ctx context.Context, schemaPath strin
 			strings.Contains(outputStr, "input file is too short") ||
 			strings.Contains(outputStr, "too short") {
 			r.logger.Debug(fmt.Sprintf("pg_restore failed (likely text format or corrupted custom format): %s", outputStr))
-			r.logger.Debug("Attempting to restore using psql instead...")
-			return r.restoreSchemaWithPsql(ctx, tempFile.Name(), sslMode)
+			return r.restoreTextSchema(ctx, tools, tempFile.Name(), sslMode)
 		}
 
 		// Check if the error is about a missing sequence
@@ -1493,7 +1850,7 @@ func (r *Restorer) extractSchemaFromPgDump(ctx context.Context, schemaPath strin
 					// Retry pg_restore after creating the sequence
 					r.logger.Debug("Retrying pg_restore after creating sequence...")
 					// Recreate the command for retry
-					cmd2 := exec.CommandContext(ctx, "pg_restore", connArgs...)
+					cmd2 := exec.CommandContext(ctx, tools.PgRestore, connArgs...)
 					cmd2.Env = env
 					output2, err2 := cmd2.CombinedOutput()
 					if err2 != nil {
@@ -1530,8 +1887,18 @@ getSchema:
 	return schema, nil
 }
 
+// restoreTextSchema restores a text format SQL dump with psql, or with the
+// built-in parser when psql is not installed
+func (r *Restorer) restoreTextSchema(ctx context.Context, tools pgClientTools, dumpFile string, sslMode string) (*TableSchema, error) {
+	if tools.Psql == "" {
+		return r.restoreSchemaWithoutClientTools(ctx, dumpFile)
+	}
+	r.logger.Debug("Attempting to restore using psql instead...")
+	return r.restoreSchemaWithPsql(ctx, tools.Psql, dumpFile, sslMode)
+}
+
 // restoreSchemaWithPsql restores schema from a text format SQL dump using psql
-func (r *Restorer) restoreSchemaWithPsql(ctx context.Context, dumpFile string, sslMode string) (*TableSchema, error) {
+func (r *Restorer) restoreSchemaWithPsql(ctx context.Context, psql string, dumpFile string, sslMode string) (*TableSchema, error) {
 	r.logger.Info("Restoring schema using psql...")
 
 	// Build connection string for psql
@@ -1553,7 +1920,7 @@ func (r *Restorer) restoreSchemaWithPsql(ctx context.Context, dumpFile string, s
 	defer dumpReader.Close()
 
 	// Run psql to execute the SQL dump
-	cmd := exec.CommandContext(ctx, "psql", connStr)
+	cmd := exec.CommandContext(ctx, psql, connStr)
 	cmd.Stdin = dumpReader
 	cmd.Env = append(os.Environ(),
 		fmt.Sprintf("PGPASSWORD=%s", r.config.Database.Password),
@@ -1594,23 +1961,13 @@ func (r *Restorer) extractSchemaFromDataFiles(ctx context.Context, files []S3Fil
 	r.logger.Debug(fmt.Sprintf("Inferring schema from file: %s", file.Key))
 
 	// Download file
-	tempFile, err := os.CreateTemp("", "restore-schema-*.tmp")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %w", err)
-	}
-	defer os.Remove(tempFile.Name())
-	defer tempFile.Close()
-
-	_, err = r.s3Downloader.DownloadWithContext(ctx, tempFile, &s3.GetObjectInput{
-		Bucket: aws.String(r.config.S3.Bucket),
-		Key:    aws.String(file.Key),
-	})
+	tempPath, _, err := r.downloader.Download(ctx, file.Key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to download file: %w", err)
 	}
+	defer os.Remove(tempPath)
 
-	tempFile.Close()
-	fileReader, err := os.Open(tempFile.Name())
+	fileReader, err := os.Open(tempPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open temp file: %w", err)
 	}
@@ -1626,40 +1983,17 @@ func (r *Restorer) extractSchemaFromDataFiles(ctx context.Context, files []S3Fil
 		compression = overrideCompression
 	}
 
-	// Decompress
-	compressor, err := compressors.GetCompressor(compression)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get compressor: %w", err)
+	if err := r.downloader.loadZstdDictionary(ctx, file.Key, fileReader); err != nil {
+		return nil, err
 	}
 
-	decompressedReader, err := compressor.NewReader(fileReader)
+	// Sample the file's first chunk of rows
+	stream, err := r.openFileRows(fileReader, format, compression)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create decompression reader: %w", err)
-	}
-	defer decompressedReader.Close()
-
-	// Parse format and get sample rows
-	var rows []map[string]interface{}
-	switch format {
-	case "jsonl":
-		reader := formatters.NewJSONLReaderWithCloser(decompressedReader)
-		rows, err = reader.ReadAll()
-	case "csv":
-		var csvReader *formatters.CSVReader
-		csvReader, err = formatters.NewCSVReaderWithCloser(decompressedReader)
-		if err == nil {
-			rows, err = csvReader.ReadAll()
-		}
-	case "parquet":
-		var parquetReader *formatters.ParquetReader
-		parquetReader, err = formatters.NewParquetReaderWithCloser(decompressedReader)
-		if err == nil {
-			rows, err = parquetReader.ReadAll()
-		}
-	default:
-		return nil, fmt.Errorf("unsupported format: %s", format)
+		return nil, err
 	}
-
+	defer stream.Close()
+	rows, err := stream.peek()
 	if err != nil {
 		return nil, fmt.Errorf("failed to read data: %w", err)
 	}
@@ -1684,14 +2018,20 @@ func (r *Restorer) Run(ctx context.Context, restoreConfig map[string]string) err
 
 	// Connect to database and S3
 	r.logger.Debug("Connecting to database and S3...")
-	if err := r.connect(ctx); err != nil {
-		return fmt.Errorf("failed to connect: %w", err)
-	}
 	defer func() {
 		if r.db != nil {
 			r.db.Close()
 		}
+		if r.tunnel != nil {
+			r.tunnel.Close()
+		}
+		if r.writer != nil {
+			r.writer.Close()
+		}
 	}()
+	if err := r.connect(ctx); err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
 
 	r.logger.Info("✅ Connected to database and S3")
 
@@ -1726,6 +2066,9 @@ func (r *Restorer) Run(ctx context.Context, restoreConfig map[string]string) err
 		r.logger.Info("No files found to restore")
 		return nil
 	}
+	if r.rowCounts != nil && restoreMode != "schema-only" {
+		r.rowCounts.loadExpected(ctx, r.s3Client, r.config.S3.Bucket, r.manifestPrefix, r.config.Table, files, r.logger)
+	}
 
 	switch restoreMode {
 	case "schema-only":
@@ -1749,6 +2092,21 @@ func (r *Restorer) Run(ctx context.Context, restoreConfig map[string]string) err
 	if schemaPath == "" {
 		schemaPath = r.config.S3.PathTemplate
 	}
+	r.largeObjectPath = schemaPath
+
+	// Other engines load each file with their own bulk loader
+	if r.writer != nil {
+		if r.workers > 1 {
+			r.logger.Warn(fmt.Sprintf("⚠️  --restore-workers applies to PostgreSQL; files are loaded into %s one at a time", r.writer))
+		}
+		if r.largeObjects {
+			r.logger.Warn(fmt.Sprintf("⚠️  --large-objects applies to PostgreSQL; large objects are not loaded into %s", r.writer))
+		}
+		if err := r.restoreIntoTarget(ctx, files, restoreMode, overrideFormat, overrideCompression); err != nil {
+			return err
+		}
+		return r.finishRowCountCheck()
+	}
 
 	var inferredSchema *TableSchema
 
@@ -1776,198 +2134,268 @@ func (r *Restorer) Run(ctx context.Context, restoreConfig map[string]string) err
 			return fmt.Errorf("failed to create partitions: %w", err)
 		}
 		r.logger.Info("✅ All partitions created")
+		r.applyComments(ctx, schemaPath)
 		return nil
 	}
 
+	// Files already restored into this database are skipped unless --force
+	if restoreMode != "schema-only" {
+		ledger, err := loadRestoreLedger(getRestoreLedgerPath(r.config), restoreTarget(r.config))
+		if err != nil {
+			r.logger.Warn(fmt.Sprintf("⚠️  %v; restored files will not be skipped", err))
+		}
+		r.ledger = ledger
+	}
+	skipped := 0
+	var queue []queuedFile
 	for i, file := range files {
+		if r.ledger != nil && !r.force {
+			if record, ok := r.ledger.restored(file); ok {
+				r.logger.Info(fmt.Sprintf("⏭️  Skipping %s: already restored into %s on %s (%d rows; use --force to restore again)",
+					file.Key, strings.Join(record.Tables, ", "), record.RestoredAt.Format("2006-01-02 15:04"), record.Rows))
+				skipped++
+				continue
+			}
+		}
+		queue = append(queue, queuedFile{file: file, index: i})
+	}
+
+	// Files are restored one at a time until the table's schema is known;
+	// with --restore-workers the rest are then restored concurrently
+	for len(queue) > 0 && (r.workers == 1 || inferredSchema == nil) {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
 		}
 
-		r.logger.Info(fmt.Sprintf("Processing file %d/%d: %s", i+1, len(files), file.Key))
-
-		// Download file
-		r.logger.Debug(fmt.Sprintf("Downloading %s", file.Key))
-		tempFile, err := os.CreateTemp("", "restore-*.tmp")
-		if err != nil {
-			r.logger.Error(fmt.Sprintf("Failed to create temp file: %v", err))
-			continue
+		job := queue[0]
+		queue = queue[1:]
+		r.logger.Info(fmt.Sprintf("Processing file %d/%d: %s", job.index+1, len(files), job.file.Key))
+		inferredSchema = r.restoreFile(ctx, job.file, inferredSchema, restoreMode, restoreConfig)
+	}
+	if len(queue) > 0 {
+		if err := r.restoreFilesConcurrently(ctx, queue, len(files), inferredSchema, restoreMode, restoreConfig); err != nil {
+			return err
 		}
-		tempPath := tempFile.Name()
-		defer os.Remove(tempPath)
-		defer tempFile.Close()
+	}
 
-		_, err = r.s3Downloader.DownloadWithContext(ctx, tempFile, &s3.GetObjectInput{
-			Bucket: aws.String(r.config.S3.Bucket),
-			Key:    aws.String(file.Key),
-		})
-		if err != nil {
-			r.logger.Error(fmt.Sprintf("Failed to download %s: %v", file.Key, err))
-			continue
-		}
+	// Archived comments describe the table, so they go on with its schema
+	if restoreMode != "data-only" && inferredSchema != nil {
+		r.applyComments(ctx, schemaPath)
+	}
 
-		// Reopen file for reading
-		tempFile.Close()
-		fileReader, err := os.Open(tempPath)
-		if err != nil {
-			r.logger.Error(fmt.Sprintf("Failed to open temp file: %v", err))
-			continue
-		}
-		defer fileReader.Close()
+	if skipped > 0 {
+		summaryLogger(r.logger).Info(fmt.Sprintf("✅ Restored %d files (%d already restored, skipped)", len(files)-skipped, skipped))
+	} else {
+		summaryLogger(r.logger).Info(fmt.Sprintf("✅ Restored %d files", len(files)))
+	}
+	return r.finishRowCountCheck()
+}
 
-		// Detect format/compression (use detected or override)
-		format := file.DetectedFormat
-		compression := file.DetectedCompression
-		if overrideFormat != "" {
-			format = overrideFormat
-		}
-		if overrideCompression != "" {
-			compression = overrideCompression
+// restoreFile downloads an archived file and streams its rows into the table
+// or its partitions one chunk at a time, so memory use is bounded by
+// --read-chunk-rows rather than the file's size. Problems are logged and leave
+// the file out. It returns the table schema, inferred from the file's first
+// chunk when there was none yet.
+func (r *Restorer) restoreFile(ctx context.Context, file S3File, schema *TableSchema, restoreMode string, restoreConfig map[string]string) *TableSchema {
+	partitionRange := restoreConfig["table_partition_range"]
+	partitionTemplate := restoreConfig["table_partition_template"]
+
+	// Files that stop short leave their count incomplete
+	var count *fileRowCount
+	if restoreMode != "schema-only" {
+		count = r.rowCounts.start(file)
+	}
+
+	// Download file
+	r.logger.Debug(fmt.Sprintf("Downloading %s", file.Key))
+	// Interrupted downloads keep their verified parts and resume on the next run
+	tempPath, _, err := r.downloader.Download(ctx, file.Key)
+	if err != nil {
+		r.logger.Error(fmt.Sprintf("Failed to download %s: %v", file.Key, err))
+		return schema
+	}
+	defer os.Remove(tempPath)
+	r.warnMasked(file.Key)
+
+	fileReader, err := os.Open(tempPath)
+	if err != nil {
+		r.logger.Error(fmt.Sprintf("Failed to open temp file: %v", err))
+		return schema
+	}
+	defer fileReader.Close()
+
+	// Detect format/compression (use detected or override)
+	format := file.DetectedFormat
+	compression := file.DetectedCompression
+	if override := restoreConfig["output_format"]; override != "" {
+		format = override
+	}
+	if override := restoreConfig["compression"]; override != "" {
+		compression = override
+	}
+
+	if err := r.downloader.loadZstdDictionary(ctx, file.Key, fileReader); err != nil {
+		r.logger.Error(fmt.Sprintf("Failed to read %s: %v", file.Key, err))
+		return schema
+	}
+	stream, err := r.openFileRows(fileReader, format, compression)
+	if err != nil {
+		r.logger.Error(fmt.Sprintf("Failed to read %s: %v", file.Key, err))
+		return schema
+	}
+	defer stream.Close()
+
+	first, err := stream.peek()
+	if err != nil {
+		r.logger.Error(fmt.Sprintf("Failed to read %s: %v", file.Key, err))
+		return schema
+	}
+	if len(first) == 0 {
+		count.finish(0, stream.filtered, insertStats{}, true)
+		if stream.filtered > 0 {
+			r.logFilteredOut(file, stream.filtered, schema)
+			return schema
 		}
+		r.logger.Debug(fmt.Sprintf("No rows in file %s", file.Key))
+		return schema
+	}
 
-		// Decompress
-		compressor, err := compressors.GetCompressor(compression)
+	// Infer schema from the first chunk of the first file (skip in data-only mode)
+	if schema == nil && restoreMode != "data-only" {
+		r.logger.Debug("Inferring table schema from data...")
+		schema, err = r.inferTableSchema(first)
 		if err != nil {
-			r.logger.Error(fmt.Sprintf("Failed to get compressor: %v", err))
-			continue
+			r.logger.Error(fmt.Sprintf("Failed to infer schema: %v", err))
+			return nil
 		}
 
-		decompressedReader, err := compressor.NewReader(fileReader)
-		if err != nil {
-			r.logger.Error(fmt.Sprintf("Failed to create decompression reader: %v", err))
-			continue
+		// Ensure base table exists
+		if err := r.ensureTableExists(ctx, r.config.Table, schema); err != nil {
+			r.logger.Error(fmt.Sprintf("Failed to ensure table exists: %v", err))
+			return schema
 		}
-		defer decompressedReader.Close()
+	}
 
-		// Parse format
-		var rows []map[string]interface{}
-		switch format {
-		case "jsonl":
-			reader := formatters.NewJSONLReaderWithCloser(decompressedReader)
-			rows, err = reader.ReadAll()
-			if err != nil {
-				r.logger.Error(fmt.Sprintf("Failed to read JSONL: %v", err))
-				continue
-			}
-		case "csv":
-			reader, err := formatters.NewCSVReaderWithCloser(decompressedReader)
-			if err != nil {
-				r.logger.Error(fmt.Sprintf("Failed to create CSV reader: %v", err))
-				continue
+	// In schema-only mode, skip data insertion but ensure partitions exist
+	if restoreMode == "schema-only" {
+		if partitionRange != "" {
+			// Ensure partition exists for this file's date
+			if err := r.ensurePartitionExists(ctx, r.config.Table, file.Date, partitionRange, partitionTemplate); err != nil {
+				r.logger.Error(fmt.Sprintf("Failed to ensure partition exists: %v", err))
+				return schema
 			}
-			rows, err = reader.ReadAll()
-			if err != nil {
-				r.logger.Error(fmt.Sprintf("Failed to read CSV: %v", err))
-				continue
-			}
-		case "parquet":
-			reader, err := formatters.NewParquetReaderWithCloser(decompressedReader)
-			if err != nil {
-				r.logger.Error(fmt.Sprintf("Failed to create Parquet reader: %v", err))
-				continue
-			}
-			rows, err = reader.ReadAll()
-			if err != nil {
-				r.logger.Error(fmt.Sprintf("Failed to read Parquet: %v", err))
-				continue
-			}
-		default:
-			r.logger.Error(fmt.Sprintf("Unsupported format: %s", format))
-			continue
+			targetTable := generatePartitionName(r.config.Table, file.Date, partitionRange, partitionTemplate)
+			r.logger.Info(fmt.Sprintf("✅ Created partition %s for date %s", targetTable, file.Date.Format("2006-01-02")))
+		} else {
+			r.logger.Info(fmt.Sprintf("✅ Schema inferred from %s (no partitions needed)", file.Key))
 		}
+		return schema
+	}
 
-		if len(rows) == 0 {
-			r.logger.Debug(fmt.Sprintf("No rows in file %s", file.Key))
-			continue
+	// In data-only mode, get schema from existing table
+	if restoreMode == "data-only" && schema == nil {
+		schema, err = r.getTableSchema(ctx, r.config.Table)
+		if err != nil {
+			r.logger.Error(fmt.Sprintf("Failed to get table schema (table may not exist): %v", err))
+			return nil
 		}
+	}
 
-		// Infer schema if first file (skip in data-only mode)
-		if inferredSchema == nil && restoreMode != "data-only" {
-			r.logger.Debug("Inferring table schema from data...")
-			inferredSchema, err = r.inferTableSchema(rows)
-			if err != nil {
-				r.logger.Error(fmt.Sprintf("Failed to infer schema: %v", err))
-				continue
-			}
-
-			// Ensure base table exists
-			if err := r.ensureTableExists(ctx, r.config.Table, inferredSchema); err != nil {
-				r.logger.Error(fmt.Sprintf("Failed to ensure table exists: %v", err))
-				continue
-			}
+	if r.rowFilter != nil {
+		if err := r.rowFilter.checkColumns(schema); err != nil {
+			r.logger.Error(fmt.Sprintf("Skipping %s: %v", file.Key, err))
+			return schema
 		}
+	}
 
-		// In schema-only mode, skip data insertion but ensure partitions exist
-		if restoreMode == "schema-only" {
-			if partitionRange != "" {
-				partitionTemplate := restoreConfig["table_partition_template"]
-				// Ensure partition exists for this file's date
-				if err := r.ensurePartitionExists(ctx, r.config.Table, file.Date, partitionRange, partitionTemplate); err != nil {
-					r.logger.Error(fmt.Sprintf("Failed to ensure partition exists: %v", err))
-					continue
-				}
-				targetTable := generatePartitionName(r.config.Table, file.Date, partitionRange, partitionTemplate)
-				r.logger.Info(fmt.Sprintf("✅ Created partition %s for date %s", targetTable, file.Date.Format("2006-01-02")))
-			} else {
-				r.logger.Info(fmt.Sprintf("✅ Schema inferred from %s (no partitions needed)", file.Key))
-			}
-			// Skip data insertion in schema-only mode
-			continue
+	// Fail this file early with a precise report rather than mid-insert. The
+	// file is read through once to validate it and again to insert it.
+	if !r.skipSchemaCheck {
+		if err := r.validateFileSchema(ctx, file.Key, stream, schema); err != nil {
+			r.logger.Error(fmt.Sprintf("Skipping %s: %v", file.Key, err))
+			return schema
+		}
+		if err := stream.rewind(); err != nil {
+			r.logger.Error(fmt.Sprintf("Failed to read %s: %v", file.Key, err))
+			return schema
 		}
+	}
 
-		// In data-only mode, get schema from existing table
-		if restoreMode == "data-only" && inferredSchema == nil {
-			var err error
-			inferredSchema, err = r.getTableSchema(ctx, r.config.Table)
-			if err != nil {
-				r.logger.Error(fmt.Sprintf("Failed to get table schema (table may not exist): %v", err))
-				continue
+	// Determine target table (base or partition)
+	r.fileStats = insertStats{}
+	dateColumn := restoreConfig["date_column"]
+	hourly := partitionRange == "hourly" && dateColumn != ""
+	targetTable := r.config.Table
+	if partitionRange != "" && !hourly {
+		// Ensure partition exists (skip in data-only mode)
+		if restoreMode != "data-only" {
+			if err := r.ensurePartitionExists(ctx, r.config.Table, file.Date, partitionRange, partitionTemplate); err != nil {
+				r.logger.Error(fmt.Sprintf("Failed to ensure partition exists: %v", err))
+				return schema
 			}
 		}
+		// Generate partition name using template or default
+		targetTable = generatePartitionName(r.config.Table, file.Date, partitionRange, partitionTemplate)
+	}
 
-		// Skip data insertion in schema-only mode
-		if restoreMode == "schema-only" {
-			continue
+	// Insert rows a chunk at a time; hourly partitions split each chunk by timestamp
+	if !hourly {
+		r.logger.Info(fmt.Sprintf("Inserting rows into %s", targetTable))
+	}
+	rows, largeObjects := 0, 0
+	err = stream.each(func(chunk []map[string]interface{}) error {
+		// Large objects go in first, so no row points at a missing one
+		created, err := r.restoreLargeObjects(ctx, chunk)
+		largeObjects += created
+		if err != nil {
+			return err
 		}
-
-		// Determine target table (base or partition)
-		dateColumn := restoreConfig["date_column"]
-		if partitionRange != "" && dateColumn != "" && partitionRange == "hourly" {
-			// Split rows by timestamp into hourly partitions
-			if err := r.insertRowsByHour(ctx, r.config.Table, rows, inferredSchema, partitionRange, restoreConfig["table_partition_template"], dateColumn); err != nil {
-				r.logger.Error(fmt.Sprintf("Failed to insert rows by hour: %v", err))
-				continue
-			}
-			r.logger.Info(fmt.Sprintf("✅ Processed %s (%d rows)", file.Key, len(rows)))
+		if hourly {
+			err = r.insertRowsByHour(ctx, r.config.Table, chunk, schema, partitionRange, partitionTemplate, dateColumn)
 		} else {
-			// Single partition or no date column - insert all rows into one partition
-			targetTable := r.config.Table
-			if partitionRange != "" {
-				partitionTemplate := restoreConfig["table_partition_template"]
-				// Ensure partition exists (skip in data-only mode)
-				if restoreMode != "data-only" {
-					if err := r.ensurePartitionExists(ctx, r.config.Table, file.Date, partitionRange, partitionTemplate); err != nil {
-						r.logger.Error(fmt.Sprintf("Failed to ensure partition exists: %v", err))
-						continue
-					}
-				}
-				// Generate partition name using template or default
-				targetTable = generatePartitionName(r.config.Table, file.Date, partitionRange, partitionTemplate)
-			}
-
-			// Insert rows
-			r.logger.Info(fmt.Sprintf("Inserting %d rows into %s", len(rows), targetTable))
-			if err := r.insertRows(ctx, targetTable, rows, inferredSchema); err != nil {
-				r.logger.Error(fmt.Sprintf("Failed to insert rows: %v", err))
-				continue
-			}
-
-			r.logger.Info(fmt.Sprintf("✅ Processed %s (%d rows)", file.Key, len(rows)))
+			err = r.insertRows(ctx, targetTable, chunk, schema)
 		}
+		if err != nil {
+			return err
+		}
+		rows += len(chunk)
+		return nil
+	})
+	count.finish(rows, stream.filtered, r.fileStats, err == nil)
+	if err != nil {
+		r.logger.Error(fmt.Sprintf("Failed to restore %s after %d rows: %v", file.Key, rows, err))
+		return schema
 	}
+	if largeObjects > 0 {
+		r.logger.Info(fmt.Sprintf("📎 Created %d large objects referenced by %s", largeObjects, file.Key))
+	}
+	if stream.filtered > 0 {
+		r.logger.Info(fmt.Sprintf("🔎 Left out %d rows of %s that do not match --where", stream.filtered, file.Key))
+	}
+	r.recordRestored(file, []string{targetTable}, rows)
+	r.logProcessed(file, rows)
+	return schema
+}
 
-	r.logger.Info(fmt.Sprintf("✅ Restored %d files", len(files)))
-	return nil
+// logProcessed reports a restored file with its insert throughput
+func (r *Restorer) logProcessed(file S3File, rows int) {
+	if r.config.DryRun {
+		r.logger.Info(fmt.Sprintf("✅ Processed %s (%d rows)", file.Key, rows))
+		return
+	}
+	r.logger.Info(fmt.Sprintf("✅ Processed %s (%d rows; %s)", file.Key, rows, r.fileStats.String()))
+}
+
+// recordRestored adds a fully inserted file to the restore ledger. A file
+// restored through --where is not recorded, as a later restore of its other
+// rows must not skip it.
+func (r *Restorer) recordRestored(file S3File, tables []string, rows int) {
+	if r.ledger == nil || r.config.DryRun || r.rowFilter != nil {
+		return
+	}
+	if err := r.ledger.record(file, tables, int64(rows)); err != nil {
+		r.logger.Warn(fmt.Sprintf("⚠️  Failed to record %s as restored: %v", file.Key, err))
+	}
 }
@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	defaultInsertBatchSize    = 1000
+	defaultInsertBatchMaxSize = 20000
+	defaultInsertBatchTarget  = 500 * time.Millisecond
+	// PostgreSQL's wire protocol allows at most 65535 bind parameters per statement
+	maxInsertParameters = 65535
+	// insertProgressInterval is how often a long insert reports its progress
+	insertProgressInterval = 10 * time.Second
+)
+
+// Insert methods for restoring into PostgreSQL
+const (
+	insertMethodCopy   = "copy"   // COPY FROM STDIN, one transaction per batch (default)
+	insertMethodInsert = "insert" // Multi-row INSERT ... ON CONFLICT DO NOTHING
+)
+
+// uniqueViolation is the SQLSTATE of a duplicate key
+const uniqueViolation = "23505"
+
+// Static errors for restore insert batching
+var (
+	ErrInsertBatchSizeInvalid   = errors.New("batch size must be at least 1")
+	ErrInsertBatchMaxInvalid    = errors.New("max batch size must be >= batch size")
+	ErrInsertBatchTargetInvalid = errors.New("batch target latency must be positive")
+	ErrInsertMethodInvalid      = errors.New("insert method must be one of: copy, insert")
+)
+
+// batchOptions configures how restore inserts are batched
+type batchOptions struct {
+	Method        string        // copy or insert
+	Size          int           // Rows in the first batch
+	MaxSize       int           // Upper bound when growing batches (= Size disables tuning)
+	TargetLatency time.Duration // Batches faster than half this grow, slower ones shrink
+}
+
+// validate checks the insert method, batch sizes and target latency
+func (o batchOptions) validate() error {
+	if o.Method != insertMethodCopy && o.Method != insertMethodInsert {
+		return fmt.Errorf("%w, got '%s'", ErrInsertMethodInvalid, o.Method)
+	}
+	if o.Size < 1 {
+		return fmt.Errorf("%w, got %d", ErrInsertBatchSizeInvalid, o.Size)
+	}
+	if o.MaxSize < o.Size {
+		return fmt.Errorf("%w, got %d < %d", ErrInsertBatchMaxInvalid, o.MaxSize, o.Size)
+	}
+	if o.TargetLatency <= 0 {
+		return fmt.Errorf("%w, got %s", ErrInsertBatchTargetInvalid, o.TargetLatency)
+	}
+	return nil
+}
+
+// batchTuner adapts the insert batch size to observed statement latency and
+// failures: batches grow while they finish well under the target, shrink in
+// proportion when they overrun it, and halve when a statement fails. The
+// learned size carries over from one file to the next.
+type batchTuner struct {
+	size    int
+	maxSize int
+	target  time.Duration
+}
+
+func newBatchTuner(opts batchOptions) *batchTuner {
+	return &batchTuner{size: opts.Size, maxSize: opts.MaxSize, target: opts.TargetLatency}
+}
+
+// next returns the number of rows for the next batch of a table with columns
+// columns, capped so the statement stays within the bind parameter limit
+func (t *batchTuner) next(columns int) int {
+	size := t.size
+	if columns > 0 {
+		size = min(size, maxInsertParameters/columns)
+	}
+	return max(size, 1)
+}
+
+// observe adjusts the batch size after a batch of rows succeeded in elapsed
+func (t *batchTuner) observe(rows int, elapsed time.Duration) {
+	switch {
+	case elapsed > t.target:
+		// Scale down to the size that would have met the target
+		scaled := int(float64(rows) * float64(t.target) / float64(elapsed))
+		t.size = max(min(scaled, t.size), 1)
+	case elapsed < t.target/2 && rows >= t.size:
+		t.size = min(t.size*2, t.maxSize)
+	}
+}
+
+// failed halves the batch size after a failed statement
+func (t *batchTuner) failed() {
+	t.size = max(t.size/2, 1)
+}
+
+// insertStats records the insert throughput for one file
+type insertStats struct {
+	Rows     int64
+	Inserted int64 // Rows not skipped by ON CONFLICT DO NOTHING
+	Existing int64 // Rows ON CONFLICT DO NOTHING skipped as already present
+	Batches  int
+	Retries  int  // Batches retried at a smaller size after an error
+	FellBack bool // COPY hit rows already present, so INSERT loaded the rest
+	MinBatch int
+	MaxBatch int
+	Elapsed  time.Duration
+
+	reported time.Time // Last progress report (zero = none yet)
+}
+
+// add records a successful batch
+func (s *insertStats) add(rows int, inserted int64, elapsed time.Duration) {
+	if s.Batches == 0 || rows < s.MinBatch {
+		s.MinBatch = rows
+	}
+	if rows > s.MaxBatch {
+		s.MaxBatch = rows
+	}
+	s.Batches++
+	s.Rows += int64(rows)
+	s.Inserted += inserted
+	s.Elapsed += elapsed
+}
+
+// RowsPerSecond returns the insert throughput
+func (s *insertStats) RowsPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Rows) / s.Elapsed.Seconds()
+}
+
+func (s *insertStats) String() string {
+	if s.Batches == 0 {
+		return "no rows inserted"
+	}
+	summary := fmt.Sprintf("%.0f rows/s, %d batches of %d-%d rows", s.RowsPerSecond(), s.Batches, s.MinBatch, s.MaxBatch)
+	if s.Inserted < s.Rows {
+		summary += fmt.Sprintf(", %d already present", s.Rows-s.Inserted)
+	}
+	if s.Retries > 0 {
+		summary += fmt.Sprintf(", %d retried", s.Retries)
+	}
+	if s.FellBack {
+		summary += ", switched from COPY to INSERT"
+	}
+	return summary
+}
+
+// buildInsertQuery returns a multi-row INSERT ... ON CONFLICT DO NOTHING for
+// rowCount rows. It is slower than COPY, but skips rows already present.
+func buildInsertQuery(tableName string, columns []ColumnInfo, rowCount int) string {
+	columnNames := make([]string, len(columns))
+	for i, col := range columns {
+		columnNames[i] = pq.QuoteIdentifier(col.Name)
+	}
+
+	var values strings.Builder
+	placeholders := make([]string, len(columns))
+	for row := 0; row < rowCount; row++ {
+		for i := range placeholders {
+			placeholders[i] = fmt.Sprintf("$%d", row*len(columns)+i+1)
+		}
+		if row > 0 {
+			values.WriteString(", ")
+		}
+		values.WriteString("(" + strings.Join(placeholders, ", ") + ")")
+	}
+
+	return fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES %s ON CONFLICT DO NOTHING",
+		pq.QuoteIdentifier(tableName),
+		strings.Join(columnNames, ", "),
+		values.String(),
+	)
+}
+
+// insertBatch inserts rows with a single statement and returns the number of
+// rows inserted
+func (r *Restorer) insertBatch(ctx context.Context, tableName string, rows []map[string]interface{}, schema *TableSchema) (int64, error) {
+	values := make([]interface{}, 0, len(rows)*len(schema.Columns))
+	for _, row := range rows {
+		for _, col := range schema.Columns {
+			values = append(values, convertValueForPostgreSQL(row[col.Name], col.UDTName))
+		}
+	}
+
+	result, err := r.db.ExecContext(ctx, buildInsertQuery(tableName, schema.Columns, len(rows)), values...)
+	if err != nil {
+		return 0, err
+	}
+	inserted, _ := result.RowsAffected()
+	return inserted, nil
+}
+
+// copyBatch loads rows with COPY FROM STDIN in a transaction of its own, so a
+// failed batch leaves nothing behind, and returns the number of rows loaded
+func (r *Restorer) copyBatch(ctx context.Context, tableName string, rows []map[string]interface{}, schema *TableSchema) (int64, error) {
+	columnNames := make([]string, len(schema.Columns))
+	for i, col := range schema.Columns {
+		columnNames[i] = col.Name
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(tableName, columnNames...))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	values := make([]interface{}, len(schema.Columns))
+	for _, row := range rows {
+		for i, col := range schema.Columns {
+			values[i] = convertValueForPostgreSQL(row[col.Name], col.UDTName)
+		}
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return 0, err
+		}
+	}
+	// Executing without values ends the COPY and reports the rows loaded
+	result, err := stmt.ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	loaded, _ := result.RowsAffected()
+	return loaded, nil
+}
+
+// isUniqueViolation reports whether err is a duplicate key error
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
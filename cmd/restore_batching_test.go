@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestBatchOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    batchOptions
+		wantErr error
+	}{
+		{"Defaults", batchOptions{Method: insertMethodCopy, Size: defaultInsertBatchSize, MaxSize: defaultInsertBatchMaxSize, TargetLatency: defaultInsertBatchTarget}, nil},
+		{"Fixed", batchOptions{Method: insertMethodInsert, Size: 500, MaxSize: 500, TargetLatency: time.Second}, nil},
+		{"UnknownMethod", batchOptions{Method: "upsert", Size: 500, MaxSize: 500, TargetLatency: time.Second}, ErrInsertMethodInvalid},
+		{"ZeroSize", batchOptions{Method: insertMethodCopy, Size: 0, MaxSize: 10, TargetLatency: time.Second}, ErrInsertBatchSizeInvalid},
+		{"MaxBelowSize", batchOptions{Method: insertMethodCopy, Size: 100, MaxSize: 10, TargetLatency: time.Second}, ErrInsertBatchMaxInvalid},
+		{"NoTarget", batchOptions{Method: insertMethodCopy, Size: 100, MaxSize: 100}, ErrInsertBatchTargetInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.validate(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("validate() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBatchTuner(t *testing.T) {
+	tuner := newBatchTuner(batchOptions{Size: 1000, MaxSize: 4000, TargetLatency: 100 * time.Millisecond})
+
+	// Fast full batches grow up to the maximum
+	tuner.observe(1000, 10*time.Millisecond)
+	if got := tuner.next(5); got != 2000 {
+		t.Errorf("after fast batch: next = %d, want 2000", got)
+	}
+	tuner.observe(2000, 10*time.Millisecond)
+	tuner.observe(4000, 10*time.Millisecond)
+	if got := tuner.next(5); got != 4000 {
+		t.Errorf("after growing: next = %d, want capped 4000", got)
+	}
+
+	// A partial final batch does not grow the size
+	tuner.observe(10, time.Millisecond)
+	if got := tuner.next(5); got != 4000 {
+		t.Errorf("after partial batch: next = %d, want 4000", got)
+	}
+
+	// A slow batch scales down to what would have met the target
+	tuner.observe(4000, 400*time.Millisecond)
+	if got := tuner.next(5); got != 1000 {
+		t.Errorf("after slow batch: next = %d, want 1000", got)
+	}
+
+	// Failures halve, down to a single row
+	tuner.failed()
+	if got := tuner.next(5); got != 500 {
+		t.Errorf("after failure: next = %d, want 500", got)
+	}
+	for i := 0; i < 20; i++ {
+		tuner.failed()
+	}
+	if got := tuner.next(5); got != 1 {
+		t.Errorf("after repeated failures: next = %d, want 1", got)
+	}
+
+	// Wide tables are capped by the bind parameter limit
+	wide := newBatchTuner(batchOptions{Size: 20000, MaxSize: 20000, TargetLatency: time.Second})
+	if got := wide.next(100); got != maxInsertParameters/100 {
+		t.Errorf("wide table: next = %d, want %d", got, maxInsertParameters/100)
+	}
+}
+
+func TestBuildInsertQuery(t *testing.T) {
+	columns := []ColumnInfo{{Name: "id"}, {Name: "callsign"}}
+	got := buildInsertQuery("flights", columns, 2)
+	want := `INSERT INTO "flights" ("id", "callsign") VALUES ($1, $2), ($3, $4) ON CONFLICT DO NOTHING`
+	if got != want {
+		t.Errorf("buildInsertQuery() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestInsertRowsRetriesFailedBatchSmaller(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	schema := &TableSchema{Columns: []ColumnInfo{{Name: "id", UDTName: "int8"}}}
+	rows := []map[string]interface{}{{"id": int64(1)}, {"id": int64(2)}, {"id": int64(3)}, {"id": int64(4)}}
+
+	insert := regexp.QuoteMeta(`INSERT INTO "flights"`)
+	mock.ExpectExec(insert).WithArgs(int64(1), int64(2), int64(3), int64(4)).WillReturnError(errors.New("connection reset"))
+	mock.ExpectExec(insert).WithArgs(int64(1), int64(2)).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(insert).WithArgs(int64(3), int64(4)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	cfg := newTestConfig()
+	restorer := NewRestorer(cfg, newTestLogger())
+	restorer.db = db
+	restorer.batching = batchOptions{Size: 4, MaxSize: 4, TargetLatency: time.Hour}
+
+	if err := restorer.insertRows(context.Background(), "flights", rows, schema); err != nil {
+		t.Fatalf("insertRows() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+
+	stats := restorer.fileStats
+	if stats.Rows != 4 || stats.Inserted != 3 || stats.Batches != 2 || stats.Retries != 1 {
+		t.Errorf("stats = %+v, want 4 rows, 3 inserted, 2 batches, 1 retry", stats)
+	}
+}
+
+func TestInsertRowsFailsOnSingleRow(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	schema := &TableSchema{Columns: []ColumnInfo{{Name: "id", UDTName: "int8"}}}
+	rows := []map[string]interface{}{{"id": int64(1)}, {"id": int64(2)}}
+
+	insert := regexp.QuoteMeta(`INSERT INTO "flights"`)
+	mock.ExpectExec(insert).WithArgs(int64(1), int64(2)).WillReturnError(errors.New("invalid input"))
+	mock.ExpectExec(insert).WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(insert).WithArgs(int64(2)).WillReturnError(errors.New("invalid input"))
+
+	restorer := NewRestorer(newTestConfig(), newTestLogger())
+	restorer.db = db
+	restorer.batching = batchOptions{Size: 2, MaxSize: 2, TargetLatency: time.Hour}
+
+	if err := restorer.insertRows(context.Background(), "flights", rows, schema); err == nil {
+		t.Fatal("expected an error when a single row cannot be inserted")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestInsertRowsCopiesAndFallsBackOnConflict(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	schema := &TableSchema{Columns: []ColumnInfo{{Name: "id", UDTName: "int8"}}}
+	rows := []map[string]interface{}{{"id": int64(1)}, {"id": int64(2)}, {"id": int64(3)}, {"id": int64(4)}}
+
+	// Each batch is copied in a transaction of its own
+	copyIn := regexp.QuoteMeta(`COPY "flights" ("id") FROM STDIN`)
+	mock.ExpectBegin()
+	first := mock.ExpectPrepare(copyIn)
+	first.ExpectExec().WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 0))
+	first.ExpectExec().WithArgs(int64(2)).WillReturnResult(sqlmock.NewResult(0, 0))
+	first.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	// A duplicate key rolls the batch back, and INSERT loads the rest
+	mock.ExpectBegin()
+	second := mock.ExpectPrepare(copyIn)
+	second.ExpectExec().WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(0, 0))
+	second.ExpectExec().WithArgs(int64(4)).WillReturnError(&pq.Error{Code: uniqueViolation})
+	mock.ExpectRollback()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "flights"`)).WithArgs(int64(3), int64(4)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	restorer := NewRestorer(newTestConfig(), newTestLogger())
+	restorer.db = db
+	restorer.batching = batchOptions{Method: insertMethodCopy, Size: 2, MaxSize: 2, TargetLatency: time.Hour}
+
+	if err := restorer.insertRows(context.Background(), "flights", rows, schema); err != nil {
+		t.Fatalf("insertRows() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+
+	stats := restorer.fileStats
+	if stats.Rows != 4 || stats.Inserted != 3 || stats.Batches != 2 || stats.Retries != 0 || !stats.FellBack {
+		t.Errorf("stats = %+v, want 4 rows, 3 inserted, 2 batches, fallen back to INSERT", stats)
+	}
+	if !strings.HasSuffix(stats.String(), "switched from COPY to INSERT") {
+		t.Errorf("String() = %q", stats.String())
+	}
+}
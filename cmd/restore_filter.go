@@ -0,0 +1,682 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Static errors for restore row filters
+var (
+	ErrRowFilterInvalid       = errors.New("invalid --where filter")
+	ErrRowFilterColumnMissing = errors.New("--where filter refers to a column the table does not have")
+)
+
+// rowFilter keeps the rows matching every --where predicate. Predicates use
+// a small SQL subset: comparisons (=, <>, !=, <, <=, >, >=) of a column with a
+// literal, [NOT] IN (...), [NOT] BETWEEN ... AND ..., IS [NOT] NULL, AND, OR,
+// NOT and parentheses. As in SQL, a comparison with a missing or null value
+// is unknown, and only rows the filter holds true for are kept.
+type rowFilter struct {
+	text      string
+	predicate filterNode
+	columns   []string // Columns the predicates refer to, in first-use order
+}
+
+// newRowFilter parses the --where predicates, which must all hold. It
+// returns nil when there are none.
+func newRowFilter(clauses []string) (*rowFilter, error) {
+	filter := &rowFilter{}
+	var nodes []filterNode
+	var texts []string
+	for _, clause := range clauses {
+		clause = strings.TrimSpace(clause)
+		if clause == "" {
+			continue
+		}
+		parser := &filterParser{input: clause}
+		node, err := parser.parse()
+		if err != nil {
+			return nil, err
+		}
+		for _, column := range parser.columns {
+			if !containsString(filter.columns, column) {
+				filter.columns = append(filter.columns, column)
+			}
+		}
+		nodes = append(nodes, node)
+		texts = append(texts, clause)
+	}
+	switch len(nodes) {
+	case 0:
+		return nil, nil
+	case 1:
+		filter.predicate = nodes[0]
+		filter.text = texts[0]
+	default:
+		filter.predicate = filterAnd(nodes)
+		filter.text = "(" + strings.Join(texts, ") AND (") + ")"
+	}
+	return filter, nil
+}
+
+// String returns the filter as given
+func (f *rowFilter) String() string {
+	return f.text
+}
+
+// apply returns the rows the filter keeps, reusing the slice's storage
+func (f *rowFilter) apply(rows []map[string]interface{}) []map[string]interface{} {
+	kept := rows[:0]
+	for _, row := range rows {
+		if f.predicate.eval(row) == filterTrue {
+			kept = append(kept, row)
+		}
+	}
+	// Let the dropped rows be collected
+	for i := len(kept); i < len(rows); i++ {
+		rows[i] = nil
+	}
+	return kept
+}
+
+// checkColumns reports a predicate on a column the table does not have,
+// which would otherwise silently restore nothing
+func (f *rowFilter) checkColumns(schema *TableSchema) error {
+	if schema == nil {
+		return nil
+	}
+	for _, column := range f.columns {
+		found := false
+		for _, c := range schema.Columns {
+			if c.Name == column {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: %s", ErrRowFilterColumnMissing, column)
+		}
+	}
+	return nil
+}
+
+// filterResult is SQL's three-valued logic: a comparison with null is unknown
+type filterResult int
+
+const (
+	filterFalse filterResult = iota
+	filterTrue
+	filterUnknown
+)
+
+func (r filterResult) not() filterResult {
+	switch r {
+	case filterTrue:
+		return filterFalse
+	case filterFalse:
+		return filterTrue
+	default:
+		return filterUnknown
+	}
+}
+
+// filterNode is a parsed predicate
+type filterNode interface {
+	eval(row map[string]interface{}) filterResult
+}
+
+type filterAnd []filterNode
+
+func (n filterAnd) eval(row map[string]interface{}) filterResult {
+	result := filterTrue
+	for _, node := range n {
+		switch node.eval(row) {
+		case filterFalse:
+			return filterFalse
+		case filterUnknown:
+			result = filterUnknown
+		}
+	}
+	return result
+}
+
+type filterOr []filterNode
+
+func (n filterOr) eval(row map[string]interface{}) filterResult {
+	result := filterFalse
+	for _, node := range n {
+		switch node.eval(row) {
+		case filterTrue:
+			return filterTrue
+		case filterUnknown:
+			result = filterUnknown
+		}
+	}
+	return result
+}
+
+type filterNot struct{ node filterNode }
+
+func (n filterNot) eval(row map[string]interface{}) filterResult {
+	return n.node.eval(row).not()
+}
+
+// filterCompare compares a column with a literal
+type filterCompare struct {
+	column string
+	op     string
+	value  filterLiteral
+}
+
+func (n filterCompare) eval(row map[string]interface{}) filterResult {
+	cmp, ok := n.value.compare(row[n.column])
+	if !ok {
+		return filterUnknown
+	}
+	var result bool
+	switch n.op {
+	case "=":
+		result = cmp == 0
+	case "<>":
+		result = cmp != 0
+	case "<":
+		result = cmp < 0
+	case "<=":
+		result = cmp <= 0
+	case ">":
+		result = cmp > 0
+	default: // >=
+		result = cmp >= 0
+	}
+	if result {
+		return filterTrue
+	}
+	return filterFalse
+}
+
+// filterIsNull tests a column for null; a missing column is null
+type filterIsNull struct{ column string }
+
+func (n filterIsNull) eval(row map[string]interface{}) filterResult {
+	if row[n.column] == nil {
+		return filterTrue
+	}
+	return filterFalse
+}
+
+// filterLiteral is a number, string or boolean in a predicate
+type filterLiteral struct {
+	text    string
+	number  bool
+	boolean bool
+}
+
+// compare returns how value orders against the literal (negative when value
+// is smaller), or false when value is null or cannot be compared. Numbers
+// compare numerically, strings that parse as timestamps compare as times,
+// and other strings compare as text.
+func (l filterLiteral) compare(value interface{}) (int, bool) {
+	if value == nil {
+		return 0, false
+	}
+	switch {
+	case l.number:
+		return compareFilterNumber(value, l.text)
+	case l.boolean:
+		b, ok := filterBool(value)
+		if !ok {
+			return 0, false
+		}
+		want := strings.EqualFold(l.text, "true")
+		switch {
+		case b == want:
+			return 0, true
+		case !b:
+			return -1, true
+		default:
+			return 1, true
+		}
+	}
+	if want, ok := parseFilterTime(l.text); ok {
+		if got, ok := filterTime(value); ok {
+			return got.Compare(want), true
+		}
+	}
+	return strings.Compare(filterText(value), l.text), true
+}
+
+// compareFilterNumber compares a row value with a numeric literal, exactly
+// for integers and as float64 otherwise
+func compareFilterNumber(value interface{}, literal string) (int, bool) {
+	if want, err := strconv.ParseInt(literal, 10, 64); err == nil {
+		if got, ok := filterInt(value); ok {
+			switch {
+			case got < want:
+				return -1, true
+			case got > want:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	}
+	want, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		return 0, false
+	}
+	got, ok := filterFloat(value)
+	if !ok || math.IsNaN(got) {
+		return 0, false
+	}
+	switch {
+	case got < want:
+		return -1, true
+	case got > want:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+func filterInt(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v), true
+		}
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func filterFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func filterBool(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "t":
+			return true, true
+		case "false", "f":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// filterTimeLayouts are the timestamp layouts filter literals and archived
+// string values are parsed with; a timestamp without a zone is UTC
+var filterTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func parseFilterTime(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) < len("2006-01-02") || s[4] != '-' {
+		return time.Time{}, false
+	}
+	for _, layout := range filterTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// filterTime reads a row value as a timestamp. Integers follow the hourly
+// restore's convention: microseconds (Parquet) above 1e12, otherwise seconds.
+func filterTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		return parseFilterTime(v)
+	case int64:
+		if v > 1e12 {
+			return time.UnixMicro(v), true
+		}
+		return time.Unix(v, 0), true
+	}
+	return time.Time{}, false
+}
+
+func filterText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// filterParser is a recursive descent parser for --where predicates
+type filterParser struct {
+	input   string
+	pos     int
+	columns []string
+}
+
+// fail returns a syntax error in the predicate being parsed
+func (p *filterParser) fail(format string, args ...interface{}) error {
+	return fmt.Errorf("%w %q: %s", ErrRowFilterInvalid, p.input, fmt.Sprintf(format, args...))
+}
+
+func (p *filterParser) parse() (filterNode, error) {
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.input) {
+		return nil, p.fail("unexpected %q", p.input[p.pos:])
+	}
+	return node, nil
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	nodes := filterOr{node}
+	for p.keyword("OR") {
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	node, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	nodes := filterAnd{node}
+	for p.keyword("AND") {
+		node, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *filterParser) parseNot() (filterNode, error) {
+	if p.keyword("NOT") {
+		node, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{node}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (filterNode, error) {
+	if p.symbol("(") {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.symbol(")") {
+			return nil, p.fail("missing closing parenthesis")
+		}
+		return node, nil
+	}
+
+	column, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	if !containsString(p.columns, column) {
+		p.columns = append(p.columns, column)
+	}
+
+	if p.keyword("IS") {
+		negate := p.keyword("NOT")
+		if !p.keyword("NULL") {
+			return nil, p.fail("expected NULL after IS for %s", column)
+		}
+		return negateIf(negate, filterIsNull{column}), nil
+	}
+
+	negate := p.keyword("NOT")
+	switch {
+	case p.keyword("IN"):
+		if !p.symbol("(") {
+			return nil, p.fail("expected ( after IN for %s", column)
+		}
+		var options filterOr
+		for {
+			value, err := p.literal()
+			if err != nil {
+				return nil, err
+			}
+			options = append(options, filterCompare{column: column, op: "=", value: value})
+			if p.symbol(")") {
+				break
+			}
+			if !p.symbol(",") {
+				return nil, p.fail("expected , or ) in the IN list of %s", column)
+			}
+		}
+		return negateIf(negate, options), nil
+	case p.keyword("BETWEEN"):
+		low, err := p.literal()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword("AND") {
+			return nil, p.fail("expected AND in BETWEEN for %s", column)
+		}
+		high, err := p.literal()
+		if err != nil {
+			return nil, err
+		}
+		return negateIf(negate, filterAnd{
+			filterCompare{column: column, op: ">=", value: low},
+			filterCompare{column: column, op: "<=", value: high},
+		}), nil
+	case negate:
+		return nil, p.fail("expected IN or BETWEEN after NOT for %s", column)
+	}
+
+	op := p.operator()
+	if op == "" {
+		return nil, p.fail("expected a comparison after %s", column)
+	}
+	value, err := p.literal()
+	if err != nil {
+		return nil, err
+	}
+	return filterCompare{column: column, op: op, value: value}, nil
+}
+
+func negateIf(negate bool, node filterNode) filterNode {
+	if negate {
+		return filterNot{node}
+	}
+	return node
+}
+
+func (p *filterParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+// keyword consumes word (case-insensitive) when it comes next as a whole word
+func (p *filterParser) keyword(word string) bool {
+	p.skipSpace()
+	end := p.pos + len(word)
+	if end > len(p.input) || !strings.EqualFold(p.input[p.pos:end], word) {
+		return false
+	}
+	if end < len(p.input) && isFilterIdentifierChar(p.input[end]) {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+// symbol consumes s when it comes next
+func (p *filterParser) symbol(s string) bool {
+	p.skipSpace()
+	if !strings.HasPrefix(p.input[p.pos:], s) {
+		return false
+	}
+	p.pos += len(s)
+	return true
+}
+
+// operator consumes a comparison operator, with != read as <>
+func (p *filterParser) operator() string {
+	for _, op := range []string{"<=", ">=", "<>", "!=", "=", "<", ">"} {
+		if p.symbol(op) {
+			if op == "!=" {
+				return "<>"
+			}
+			return op
+		}
+	}
+	return ""
+}
+
+// identifier consumes a column name, bare or in double quotes
+func (p *filterParser) identifier() (string, error) {
+	p.skipSpace()
+	if p.pos < len(p.input) && p.input[p.pos] == '"' {
+		name, err := p.quoted('"')
+		if err != nil {
+			return "", err
+		}
+		if name == "" {
+			return "", p.fail("empty column name")
+		}
+		return name, nil
+	}
+	start := p.pos
+	for p.pos < len(p.input) && isFilterIdentifierChar(p.input[p.pos]) {
+		p.pos++
+	}
+	if start == p.pos || unicode.IsDigit(rune(p.input[start])) {
+		p.pos = start
+		return "", p.fail("expected a column name at %q", p.input[start:])
+	}
+	return p.input[start:p.pos], nil
+}
+
+// literal consumes a 'string', a number, TRUE or FALSE
+func (p *filterParser) literal() (filterLiteral, error) {
+	p.skipSpace()
+	if p.pos < len(p.input) && p.input[p.pos] == '\'' {
+		text, err := p.quoted('\'')
+		return filterLiteral{text: text}, err
+	}
+	for _, word := range []string{"TRUE", "FALSE"} {
+		if p.keyword(word) {
+			return filterLiteral{text: strings.ToLower(word), boolean: true}, nil
+		}
+	}
+	start := p.pos
+	for p.pos < len(p.input) && strings.ContainsRune("+-.0123456789eE", rune(p.input[p.pos])) {
+		p.pos++
+	}
+	text := p.input[start:p.pos]
+	if _, err := strconv.ParseFloat(text, 64); text == "" || err != nil {
+		p.pos = start
+		return filterLiteral{}, p.fail("expected a 'string', number, TRUE or FALSE at %q", p.input[start:])
+	}
+	return filterLiteral{text: text, number: true}, nil
+}
+
+// quoted consumes text between quote characters, where a doubled quote
+// stands for one
+func (p *filterParser) quoted(quote byte) (string, error) {
+	var b strings.Builder
+	for p.pos++; p.pos < len(p.input); p.pos++ {
+		c := p.input[p.pos]
+		if c != quote {
+			b.WriteByte(c)
+			continue
+		}
+		if p.pos+1 < len(p.input) && p.input[p.pos+1] == quote {
+			b.WriteByte(quote)
+			p.pos++
+			continue
+		}
+		p.pos++
+		return b.String(), nil
+	}
+	return "", p.fail("unterminated %c", quote)
+}
+
+func isFilterIdentifierChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// logFilteredOut reports a file none of whose rows match --where. A filter on
+// a column the table lacks is reported as such rather than as no match.
+func (r *Restorer) logFilteredOut(file S3File, filtered int, schema *TableSchema) {
+	if err := r.rowFilter.checkColumns(schema); err != nil {
+		r.logger.Error(fmt.Sprintf("Skipping %s: %v", file.Key, err))
+		return
+	}
+	r.logger.Info(fmt.Sprintf("⏭️  No rows of %s match --where (%d left out)", file.Key, filtered))
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRowFilterMatches(t *testing.T) {
+	row := map[string]interface{}{
+		"aircraft_id": float64(42),
+		"tail":        "N123'AB",
+		"seen_at":     "2024-01-01T08:30:00Z",
+		"altitude":    "35000.5",
+		"on_ground":   false,
+		"squawk":      nil,
+		"flight_id":   int64(9007199254740993), // Beyond float64's exact integers
+		"recorded":    time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		where string
+		want  bool
+	}{
+		{"aircraft_id = 42", true},
+		{"aircraft_id <> 42", false},
+		{"aircraft_id != 41", true},
+		{"aircraft_id IN (1, 2, 42)", true},
+		{"aircraft_id NOT IN (1, 2)", true},
+		{"aircraft_id BETWEEN 40 AND 45", true},
+		{"aircraft_id NOT BETWEEN 40 AND 45", false},
+		{"aircraft_id = '42'", true},
+		{"tail = 'N123''AB'", true},
+		{`"tail" > 'N1'`, true},
+		{"seen_at >= '2024-01-01 06:00' AND seen_at < '2024-01-01 12:00'", true},
+		{"seen_at >= '2024-01-01T09:00:00+01:00'", true},
+		{"seen_at > '2024-01-02'", false},
+		{"recorded < '2024-01-01 12:00:01'", true},
+		{"altitude > 35000", true},
+		{"on_ground = false AND NOT on_ground = true", true},
+		{"on_ground = FALSE", true},
+		{"flight_id = 9007199254740993", true},
+		{"flight_id = 9007199254740992", false},
+		{"squawk IS NULL", true},
+		{"squawk IS NOT NULL", false},
+		{"missing IS NULL", true},
+		// Comparisons with null are unknown, and so is their negation
+		{"squawk = 7700", false},
+		{"NOT (squawk = 7700)", false},
+		{"squawk = 7700 OR aircraft_id = 42", true},
+		{"(aircraft_id = 1 OR aircraft_id = 42) and tail = 'N123''AB'", true},
+	}
+	for _, tt := range tests {
+		filter, err := newRowFilter([]string{tt.where})
+		if err != nil {
+			t.Errorf("newRowFilter(%q) error = %v", tt.where, err)
+			continue
+		}
+		if got := len(filter.apply([]map[string]interface{}{row})) == 1; got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.where, got, tt.want)
+		}
+	}
+}
+
+func TestNewRowFilter(t *testing.T) {
+	if filter, err := newRowFilter([]string{"", "  "}); filter != nil || err != nil {
+		t.Errorf("newRowFilter() without predicates = %v, %v", filter, err)
+	}
+
+	filter, err := newRowFilter([]string{"aircraft_id = 42", "seen_at >= '2024-01-01'"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.String() != "(aircraft_id = 42) AND (seen_at >= '2024-01-01')" {
+		t.Errorf("String() = %q", filter)
+	}
+	if strings.Join(filter.columns, ",") != "aircraft_id,seen_at" {
+		t.Errorf("columns = %v", filter.columns)
+	}
+	schema := &TableSchema{Columns: []ColumnInfo{{Name: "aircraft_id"}}}
+	if err := filter.checkColumns(schema); !errors.Is(err, ErrRowFilterColumnMissing) || !strings.Contains(err.Error(), "seen_at") {
+		t.Errorf("checkColumns() = %v", err)
+	}
+
+	for _, where := range []string{
+		"aircraft_id",
+		"aircraft_id = ",
+		"aircraft_id = 'open",
+		"(aircraft_id = 1",
+		"aircraft_id IN 1",
+		"aircraft_id BETWEEN 1 OR 2",
+		"aircraft_id IS 1",
+		"aircraft_id NOT 1",
+		"aircraft_id = 1 extra",
+		"1 = aircraft_id",
+		`"" = 1`,
+	} {
+		if _, err := newRowFilter([]string{where}); !errors.Is(err, ErrRowFilterInvalid) {
+			t.Errorf("newRowFilter(%q) = %v, want ErrRowFilterInvalid", where, err)
+		}
+	}
+}
+
+func TestFileRowStreamFiltersRows(t *testing.T) {
+	var jsonl strings.Builder
+	for i := 1; i <= 10; i++ {
+		fmt.Fprintf(&jsonl, "{\"id\": %d}\n", i)
+	}
+	file := writeArchiveFile(t, []byte(jsonl.String()), "gzip")
+
+	r := NewRestorer(newTestConfig(), newTestLogger())
+	r.readChunkRows = 3
+	var err error
+	r.rowFilter, err = newRowFilter([]string{"id = 2 OR id >= 9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := r.openFileRows(file, "jsonl", "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	// The chunk of rows 4-6 matches nothing and is skipped
+	if got := chunkSizes(t, stream); fmt.Sprint(got) != "[1 1 1]" {
+		t.Errorf("chunk sizes = %v", got)
+	}
+	if stream.filtered != 7 {
+		t.Errorf("filtered = %d, want 7", stream.filtered)
+	}
+	if err := stream.rewind(); err != nil {
+		t.Fatal(err)
+	}
+	if stream.filtered != 0 {
+		t.Errorf("filtered after rewind = %d", stream.filtered)
+	}
+}
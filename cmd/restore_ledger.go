@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// restoredFile records an archive file that was fully applied to the target database
+type restoredFile struct {
+	ETag       string    `json:"etag"`
+	Size       int64     `json:"size"`
+	Tables     []string  `json:"tables"`
+	Rows       int64     `json:"rows"`
+	RestoredAt time.Time `json:"restored_at"`
+}
+
+// restoreLedger tracks the files restored from one archive location into one
+// target database, so re-running a restore skips files already applied
+type restoreLedger struct {
+	Database string                  `json:"database"`
+	Files    map[string]restoredFile `json:"files"` // By S3 key
+
+	path string
+	mu   sync.Mutex // Restore workers record files concurrently
+}
+
+// restoreTarget identifies the database a restore writes to
+func restoreTarget(cfg *Config) string {
+	target := fmt.Sprintf("%s:%d/%s", cfg.Database.Host, cfg.Database.Port, cfg.Database.Name)
+	if cfg.Database.SSHTunnel.Host != "" {
+		target = fmt.Sprintf("%s via %s", target, cfg.Database.SSHTunnel.Host)
+	}
+	return target
+}
+
+// getRestoreLedgerPath returns the ledger file for restoring cfg's archive
+// location into cfg's database. The same files restored into another database
+// are tracked separately.
+func getRestoreLedgerPath(cfg *Config) string {
+	return getRestoreLedgerPathFor(cfg, restoreTarget(cfg))
+}
+
+// getRestoreLedgerPathFor returns the ledger file for restoring cfg's archive
+// location into target
+func getRestoreLedgerPathFor(cfg *Config, target string) string {
+	scope := NewCacheScope("restore", cfg).normalize()
+	hash := sha1.Sum([]byte(scope.OutputPath + "\x00" + target))
+	name := fmt.Sprintf("restore_%s_%s_restored.json", tableFileComponent(scope.Table, "table"), hex.EncodeToString(hash[:])[:16])
+
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".data-archiver", "cache", name)
+}
+
+// loadRestoreLedger reads the ledger at path, or returns an empty one
+func loadRestoreLedger(path, database string) (*restoreLedger, error) {
+	ledger := &restoreLedger{Database: database, Files: make(map[string]restoredFile), path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ledger, nil
+		}
+		return ledger, fmt.Errorf("failed to read restore ledger: %w", err)
+	}
+	if err := json.Unmarshal(data, ledger); err != nil {
+		return &restoreLedger{Database: database, Files: make(map[string]restoredFile), path: path},
+			fmt.Errorf("failed to parse restore ledger %s: %w", path, err)
+	}
+	if ledger.Files == nil {
+		ledger.Files = make(map[string]restoredFile)
+	}
+	return ledger, nil
+}
+
+// restored returns the record for file when that exact object version (same
+// ETag and size) was already restored. A rewritten object is restored again.
+func (l *restoreLedger) restored(file S3File) (restoredFile, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	record, ok := l.Files[file.Key]
+	if !ok || record.ETag != file.ETag || record.Size != file.Size {
+		return restoredFile{}, false
+	}
+	return record, true
+}
+
+// record marks file as restored into tables and saves the ledger
+func (l *restoreLedger) record(file S3File, tables []string, rows int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Files[file.Key] = restoredFile{
+		ETag:       file.ETag,
+		Size:       file.Size,
+		Tables:     tables,
+		Rows:       rows,
+		RestoredAt: time.Now(),
+	}
+	return l.save()
+}
+
+// save writes the ledger atomically
+func (l *restoreLedger) save() error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	tempPath := l.path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, l.path)
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRestoreLedgerRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.json")
+	ledger, err := loadRestoreLedger(path, "localhost:5432/testdb")
+	if err != nil {
+		t.Fatalf("loading a missing ledger should succeed: %v", err)
+	}
+
+	file := S3File{Key: "flights/2024/01/flights-2024-01-01.jsonl.zst", Size: 1024, ETag: "abc123"}
+	if _, ok := ledger.restored(file); ok {
+		t.Fatal("expected file not to be restored yet")
+	}
+	if err := ledger.record(file, []string{"flights_20240101"}, 500); err != nil {
+		t.Fatalf("record failed: %v", err)
+	}
+
+	reloaded, err := loadRestoreLedger(path, "localhost:5432/testdb")
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	record, ok := reloaded.restored(file)
+	if !ok {
+		t.Fatal("expected file to be recorded as restored")
+	}
+	if record.Rows != 500 || len(record.Tables) != 1 || record.Tables[0] != "flights_20240101" {
+		t.Errorf("unexpected record: %+v", record)
+	}
+
+	// A rewritten object (new ETag or size) is restored again
+	changed := file
+	changed.ETag = "def456"
+	if _, ok := reloaded.restored(changed); ok {
+		t.Error("expected a changed ETag to not match")
+	}
+	changed = file
+	changed.Size = 2048
+	if _, ok := reloaded.restored(changed); ok {
+		t.Error("expected a changed size to not match")
+	}
+}
+
+func TestLoadRestoreLedgerCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.json")
+	_ = os.WriteFile(path, []byte("{not json"), 0o600)
+
+	ledger, err := loadRestoreLedger(path, "db")
+	if err == nil {
+		t.Fatal("expected an error for a corrupt ledger")
+	}
+	if ledger == nil || len(ledger.Files) != 0 {
+		t.Errorf("expected an empty usable ledger, got %+v", ledger)
+	}
+}
+
+func TestGetRestoreLedgerPathPerDatabase(t *testing.T) {
+	config := newTestConfig()
+	other := newTestConfig()
+	other.Database.Name = "staging"
+
+	if getRestoreLedgerPath(config) == getRestoreLedgerPath(other) {
+		t.Error("expected different ledgers for different target databases")
+	}
+	if getRestoreLedgerPath(config) != getRestoreLedgerPath(newTestConfig()) {
+		t.Error("expected the same ledger for the same archive and database")
+	}
+
+	tunneled := newTestConfig()
+	tunneled.Database.SSHTunnel.Host = "bastion"
+	if getRestoreLedgerPath(config) == getRestoreLedgerPath(tunneled) {
+		t.Error("expected an SSH-tunneled database to be tracked separately")
+	}
+}
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrRowCountMismatch is returned by a restore whose row counts don't add up
+var ErrRowCountMismatch = errors.New("restored row counts do not match the archive")
+
+// Where a file's expected row count came from
+const (
+	rowCountSourceSplitManifest = "split-manifest" // The manifest of a --max-rows-per-file archive
+	rowCountSourceRunManifest   = "run-manifest"   // A manifest written by --run-manifest
+	rowCountSourceFile          = "file"           // Rows read from the file itself
+)
+
+// fileRowCount compares the rows of one restored file with the rows the
+// archive says it holds
+type fileRowCount struct {
+	Key      string `json:"key"`
+	Source   string `json:"source"`   // Where Expected came from
+	Expected int64  `json:"expected"` // Rows the file holds
+	Read     int64  `json:"read"`     // Rows read from the file, --where filtered ones included
+	Inserted int64  `json:"inserted"` // Rows the database reported inserted
+	Existing int64  `json:"existing"` // Rows INSERT ... ON CONFLICT DO NOTHING found already present
+	Filtered int64  `json:"filtered"` // Rows --where left out
+	Complete bool   `json:"complete"` // The file was restored to the end
+}
+
+// problem describes how the counts disagree, or "" when they match
+func (c *fileRowCount) problem() string {
+	switch {
+	case !c.Complete:
+		return fmt.Sprintf("not fully restored: %d of %d rows inserted", c.Inserted, c.Expected)
+	case c.Read != c.Expected:
+		return fmt.Sprintf("%d rows read, %s records %d", c.Read, c.Source, c.Expected)
+	case c.Inserted+c.Existing+c.Filtered != c.Expected:
+		return fmt.Sprintf("%d rows inserted, %d already present and %d filtered out of %d", c.Inserted, c.Existing, c.Filtered, c.Expected)
+	}
+	return ""
+}
+
+// rowCountCheck collects the row counts of a restore's files. It is shared by
+// the restore workers.
+type rowCountCheck struct {
+	mu       sync.Mutex
+	expected map[string]fileRowCount // Row counts recorded in manifests, by key
+	results  []*fileRowCount
+}
+
+func newRowCountCheck() *rowCountCheck {
+	return &rowCountCheck{expected: make(map[string]fileRowCount)}
+}
+
+// loadExpected reads the row counts the archive recorded for files: the
+// manifests of split archives, then the table's run manifests in the order
+// the runs started, so the latest upload of a key wins. Unreadable manifests
+// are skipped, leaving those files to be counted as they're read.
+func (c *rowCountCheck) loadExpected(ctx context.Context, client StorageBackend, bucket, manifestPrefix, table string, files []S3File, logger *slog.Logger) {
+	split := make(map[string]bool)
+	for _, file := range files {
+		manifestKey := partManifestKey(file.Key)
+		if manifestKey == "" || split[manifestKey] {
+			continue
+		}
+		split[manifestKey] = true
+		manifest, err := readSplitManifest(ctx, client, bucket, manifestKey)
+		if err != nil {
+			logger.Warn(fmt.Sprintf("⚠️  Row counts of %s's parts will be counted as they're read: %v", manifestKey, err))
+			continue
+		}
+		for _, part := range manifest.Parts {
+			c.expected[part.Key] = fileRowCount{Key: part.Key, Source: rowCountSourceSplitManifest, Expected: part.Rows}
+		}
+	}
+
+	if strings.Trim(manifestPrefix, "/") == "" {
+		return
+	}
+	dir := path.Join(strings.Trim(manifestPrefix, "/"), objectKeyComponent(table)) + "/"
+	var keys []string
+	err := client.List(ctx, bucket, dir+"manifest-", func(object ObjectInfo) bool {
+		keys = append(keys, object.Key)
+		return true
+	})
+	if err != nil {
+		logger.Warn(fmt.Sprintf("⚠️  Run manifests not read: %v", err))
+		return
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		manifest, err := readRunManifest(ctx, client, bucket, key)
+		if err != nil {
+			logger.Warn(fmt.Sprintf("⚠️  %v", err))
+			continue
+		}
+		for _, file := range manifest.Files {
+			if _, ok := c.expected[file.Key]; ok && c.expected[file.Key].Source == rowCountSourceSplitManifest {
+				continue
+			}
+			c.expected[file.Key] = fileRowCount{Key: file.Key, Source: rowCountSourceRunManifest, Expected: file.Rows}
+		}
+	}
+}
+
+// start begins counting a file's rows
+func (c *rowCountCheck) start(file S3File) *fileRowCount {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	count, ok := c.expected[file.Key]
+	if !ok {
+		count = fileRowCount{Key: file.Key, Source: rowCountSourceFile}
+	}
+	result := &count
+	c.results = append(c.results, result)
+	return result
+}
+
+// finish records what happened to a file's rows. Without a recorded count,
+// the rows read are what the file is expected to hold.
+func (c *fileRowCount) finish(read, filtered int, stats insertStats, complete bool) {
+	if c == nil {
+		return
+	}
+	c.Read = int64(read + filtered)
+	c.Filtered = int64(filtered)
+	c.Inserted = stats.Inserted
+	c.Existing = stats.Existing
+	c.Complete = complete
+	if c.Source == rowCountSourceFile {
+		c.Expected = c.Read
+	}
+}
+
+// rowCountReport is the outcome of --verify-row-counts
+type rowCountReport struct {
+	Files       int            `json:"files"`
+	Expected    int64          `json:"expected_rows"`
+	Inserted    int64          `json:"inserted_rows"`
+	Mismatched  int            `json:"mismatched_files"`
+	Discrepancy []rowCountDiff `json:"discrepancies"`
+}
+
+// rowCountDiff is a file whose rows don't add up
+type rowCountDiff struct {
+	fileRowCount
+	Problem string `json:"problem"`
+}
+
+// report summarizes the files restored so far
+func (c *rowCountCheck) report() rowCountReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	report := rowCountReport{Files: len(c.results), Discrepancy: []rowCountDiff{}}
+	for _, count := range c.results {
+		report.Expected += count.Expected
+		report.Inserted += count.Inserted
+		if problem := count.problem(); problem != "" {
+			report.Discrepancy = append(report.Discrepancy, rowCountDiff{fileRowCount: *count, Problem: problem})
+		}
+	}
+	sort.Slice(report.Discrepancy, func(i, j int) bool { return report.Discrepancy[i].Key < report.Discrepancy[j].Key })
+	report.Mismatched = len(report.Discrepancy)
+	return report
+}
+
+// writeRowCountText renders a report for the log
+func writeRowCountText(w io.Writer, report rowCountReport) {
+	fmt.Fprintf(w, "Row counts: %d files, %d rows expected, %d inserted\n", report.Files, report.Expected, report.Inserted)
+	for _, diff := range report.Discrepancy {
+		fmt.Fprintf(w, "  ❌ %s: %s\n", diff.Key, diff.Problem)
+	}
+}
+
+// finishRowCountCheck reports the restore's row counts, writes the report
+// to --row-count-report, and fails the restore when a file's counts disagree
+func (r *Restorer) finishRowCountCheck() error {
+	if r.rowCounts == nil {
+		return nil
+	}
+	report := r.rowCounts.report()
+	if report.Files == 0 {
+		return nil
+	}
+	var text strings.Builder
+	writeRowCountText(&text, report)
+	for _, line := range strings.Split(strings.TrimRight(text.String(), "\n"), "\n") {
+		if report.Mismatched > 0 {
+			r.logger.Error(line)
+		} else {
+			r.logger.Info(line)
+		}
+	}
+	if r.rowCountReport != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = os.WriteFile(r.rowCountReport, append(data, '\n'), 0o600)
+		}
+		if err != nil {
+			r.logger.Warn(fmt.Sprintf("⚠️  Failed to write row count report: %v", err))
+		}
+	}
+	if report.Mismatched > 0 {
+		return fmt.Errorf("%w: %d of %d files", ErrRowCountMismatch, report.Mismatched, report.Files)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRowCountCheckLoadsExpected(t *testing.T) {
+	store := &fakeObjectStore{objects: map[string][]byte{}}
+	split, _ := json.Marshal(splitManifest{Parts: []manifestPart{
+		{Part: 1, Key: "events/events-2024-01-01-part-0001.jsonl", Rows: 5},
+		{Part: 2, Key: "events/events-2024-01-01-part-0002.jsonl", Rows: 2},
+	}})
+	store.objects["events/events-2024-01-01.manifest.json"] = split
+	older, _ := json.Marshal(runManifest{Files: []runManifestFile{
+		{Key: "events/events-2024-01-02.jsonl", Rows: 9},
+		{Key: "events/events-2024-01-01-part-0001.jsonl", Rows: 1},
+	}})
+	newer, _ := json.Marshal(runManifest{Files: []runManifestFile{{Key: "events/events-2024-01-02.jsonl", Rows: 10}}})
+	store.objects["_data-archiver/manifests/events/manifest-20240101-01A.json"] = older
+	store.objects["_data-archiver/manifests/events/manifest-20240102-01B.json"] = newer
+
+	check := newRowCountCheck()
+	files := []S3File{
+		{Key: "events/events-2024-01-01-part-0001.jsonl"},
+		{Key: "events/events-2024-01-01-part-0002.jsonl"},
+		{Key: "events/events-2024-01-02.jsonl"},
+		{Key: "events/events-2024-01-03.jsonl"},
+	}
+	check.loadExpected(context.Background(), newS3ObjectStore(store), "bucket", defaultRunManifestPrefix, "events", files, newTestLogger())
+
+	want := map[string]fileRowCount{
+		files[0].Key: {Source: rowCountSourceSplitManifest, Expected: 5},
+		files[1].Key: {Source: rowCountSourceSplitManifest, Expected: 2},
+		files[2].Key: {Source: rowCountSourceRunManifest, Expected: 10},
+		files[3].Key: {Source: rowCountSourceFile},
+	}
+	for _, file := range files {
+		got := check.start(file)
+		if got.Source != want[file.Key].Source || got.Expected != want[file.Key].Expected {
+			t.Errorf("%s: expected %d rows from %s, want %d from %s", file.Key, got.Expected, got.Source, want[file.Key].Expected, want[file.Key].Source)
+		}
+	}
+}
+
+func TestFileRowCountProblem(t *testing.T) {
+	tests := []struct {
+		name     string
+		count    fileRowCount
+		read     int
+		filtered int
+		stats    insertStats
+		complete bool
+		want     string
+	}{
+		{"Matches", fileRowCount{Source: rowCountSourceSplitManifest, Expected: 5}, 3, 2, insertStats{Inserted: 2, Existing: 1}, true, ""},
+		{"CountedFromFile", fileRowCount{Source: rowCountSourceFile}, 4, 0, insertStats{Inserted: 4}, true, ""},
+		{"ShortFile", fileRowCount{Source: rowCountSourceRunManifest, Expected: 5}, 4, 0, insertStats{Inserted: 4}, true, "4 rows read, run-manifest records 5"},
+		{"SilentLoss", fileRowCount{Source: rowCountSourceFile}, 4, 0, insertStats{Inserted: 3}, true, "3 rows inserted, 0 already present and 0 filtered out of 4"},
+		{"Incomplete", fileRowCount{Source: rowCountSourceSplitManifest, Expected: 5}, 2, 0, insertStats{Inserted: 2}, false, "not fully restored: 2 of 5 rows inserted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count := tt.count
+			count.finish(tt.read, tt.filtered, tt.stats, tt.complete)
+			if got := count.problem(); got != tt.want {
+				t.Errorf("problem() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	var none *fileRowCount
+	none.finish(1, 0, insertStats{}, true) // Without --verify-row-counts nothing is counted
+}
+
+func TestRestoreVerifiesRowCounts(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+
+	ctx := context.Background()
+	bucket := t.TempDir()
+	store := &localObjectStore{}
+	var files []queuedFile
+	for i := 0; i < 2; i++ {
+		key := fmt.Sprintf("flights/flights-2024-01-%02d.jsonl", i+1)
+		body := fmt.Sprintf(`{"id": %d}`+"\n"+`{"id": %d}`+"\n", 2*i, 2*i+1)
+		if _, err := store.Put(ctx, bucket, key, strings.NewReader(body), PutOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, queuedFile{file: S3File{Key: key, Size: int64(len(body)), ETag: fmt.Sprint(i), DetectedFormat: "jsonl", DetectedCompression: "none"}, index: i})
+		mock.ExpectExec(`INSERT INTO "flights"`).WillReturnResult(sqlmock.NewResult(0, 2))
+	}
+
+	config := newTestConfig()
+	config.Table = "flights"
+	r := NewRestorer(config, newTestLogger())
+	r.db = db
+	r.downloader = newRangeDownloader(store, bucket, t.TempDir(), 1, 0, newTestLogger())
+	r.batching.Method = insertMethodInsert
+	r.skipSchemaCheck = true
+	r.workers = 2
+	r.rowCounts = newRowCountCheck()
+	r.rowCountReport = filepath.Join(t.TempDir(), "row-counts.json")
+	// The second file's run recorded a row the file no longer holds
+	r.rowCounts.expected[files[1].file.Key] = fileRowCount{Key: files[1].file.Key, Source: rowCountSourceRunManifest, Expected: 3}
+
+	schema := &TableSchema{TableName: "flights", Columns: []ColumnInfo{{Name: "id", UDTName: "int8"}}}
+	if err := r.restoreFilesConcurrently(ctx, files, len(files), schema, "data-only", map[string]string{}); err != nil {
+		t.Fatalf("restoreFilesConcurrently() = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	if err := r.finishRowCountCheck(); !errors.Is(err, ErrRowCountMismatch) {
+		t.Fatalf("finishRowCountCheck() = %v, want ErrRowCountMismatch", err)
+	}
+	data, err := os.ReadFile(r.rowCountReport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report rowCountReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Files != 2 || report.Expected != 5 || report.Inserted != 4 || report.Mismatched != 1 ||
+		report.Discrepancy[0].Key != files[1].file.Key || report.Discrepancy[0].Problem != "2 rows read, run-manifest records 3" {
+		t.Errorf("report = %+v", report)
+	}
+}
@@ -0,0 +1,333 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRestoreSchemaIncompatible is returned when a file cannot be inserted into the target table
+var ErrRestoreSchemaIncompatible = errors.New("file is not compatible with the target table")
+
+var uuidPattern = regexp.MustCompile(`^(?i)\{?[0-9a-f]{8}-?[0-9a-f]{4}-?[0-9a-f]{4}-?[0-9a-f]{4}-?[0-9a-f]{12}\}?$`)
+
+// Timestamp layouts PostgreSQL accepts that archive files commonly contain
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// targetColumn is a column of the restore target table with its constraints
+type targetColumn struct {
+	Name       string
+	UDTName    string
+	Nullable   bool
+	HasDefault bool // Default or identity; only used when the file lacks the column
+	Generated  bool // GENERATED ALWAYS AS (...) STORED, which cannot be inserted
+}
+
+// schemaIssue is one incompatibility between a file and the target table
+type schemaIssue struct {
+	Column  string
+	Problem string
+	Rows    int    // Offending rows (0 = the column as a whole)
+	Row     int    // 1-based index of the first offending row
+	Example string // First offending value
+}
+
+func (i schemaIssue) String() string {
+	if i.Rows == 0 {
+		return fmt.Sprintf("column %s: %s", i.Column, i.Problem)
+	}
+	return fmt.Sprintf("column %s: %s in %d row(s), first at row %d: %s", i.Column, i.Problem, i.Rows, i.Row, i.Example)
+}
+
+// loadTargetColumns reads the target table's columns and constraints. The
+// result is cached once the table exists.
+func (r *Restorer) loadTargetColumns(ctx context.Context, tableName string) ([]targetColumn, error) {
+	r.shared.columns.Lock()
+	defer r.shared.columns.Unlock()
+	if columns, ok := r.targetColumns[tableName]; ok {
+		return columns, nil
+	}
+
+	query := `
+		SELECT column_name, udt_name, is_nullable = 'YES',
+			column_default IS NOT NULL OR is_identity = 'YES',
+			is_generated = 'ALWAYS'
+		FROM information_schema.columns
+		WHERE table_schema = 'public' AND table_name = $1
+		ORDER BY ordinal_position
+	`
+	rows, err := r.db.QueryContext(ctx, query, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query target table columns: %w", err)
+	}
+	defer rows.Close()
+
+	var columns []targetColumn
+	for rows.Next() {
+		var col targetColumn
+		if err := rows.Scan(&col.Name, &col.UDTName, &col.Nullable, &col.HasDefault, &col.Generated); err != nil {
+			return nil, fmt.Errorf("failed to scan target column: %w", err)
+		}
+		columns = append(columns, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating target columns: %w", err)
+	}
+
+	if len(columns) > 0 {
+		if r.targetColumns == nil {
+			r.targetColumns = make(map[string][]targetColumn)
+		}
+		r.targetColumns[tableName] = columns
+	}
+	return columns, nil
+}
+
+// validateFileSchema checks a file's rows against the target table before any
+// row is inserted, logging a per-column report when they are incompatible.
+// The rows are read from stream a chunk at a time, which leaves it at the end
+// of the file.
+func (r *Restorer) validateFileSchema(ctx context.Context, key string, stream *fileRowStream, schema *TableSchema) error {
+	target, err := r.loadTargetColumns(ctx, r.config.Table)
+	if err != nil {
+		return err
+	}
+	if len(target) == 0 {
+		// Table not created yet (dry run); nothing to validate against
+		r.logger.Debug(fmt.Sprintf("Skipping schema validation for %s: table %s does not exist", key, r.config.Table))
+		return nil
+	}
+
+	var issues []schemaIssue
+	offset := 0
+	err = stream.each(func(rows []map[string]interface{}) error {
+		issues = mergeSchemaIssues(issues, checkSchemaCompatibility(rows, schema.Columns, target), offset)
+		offset += len(rows)
+		return ctx.Err()
+	})
+	if err != nil {
+		return err
+	}
+	if len(issues) == 0 {
+		return nil
+	}
+
+	r.logger.Error(fmt.Sprintf("❌ %s is not compatible with table %s:", key, r.config.Table))
+	for _, issue := range issues {
+		r.logger.Error(fmt.Sprintf("   • %s", issue))
+	}
+	return fmt.Errorf("%w: %s has %d schema problem(s)", ErrRestoreSchemaIncompatible, key, len(issues))
+}
+
+// checkSchemaCompatibility compares the columns that would be inserted, and
+// every value in them, against the target table's types and NOT NULL constraints
+func checkSchemaCompatibility(rows []map[string]interface{}, insertColumns []ColumnInfo, target []targetColumn) []schemaIssue {
+	targetByName := make(map[string]targetColumn, len(target))
+	for _, col := range target {
+		targetByName[col.Name] = col
+	}
+
+	var issues []schemaIssue
+	inserted := make(map[string]bool, len(insertColumns))
+	for _, col := range insertColumns {
+		inserted[col.Name] = true
+		tc, ok := targetByName[col.Name]
+		if !ok {
+			issues = append(issues, schemaIssue{Column: col.Name, Problem: "does not exist in the target table"})
+			continue
+		}
+		if tc.Generated {
+			issues = append(issues, schemaIssue{Column: col.Name, Problem: "is a generated column and cannot be restored"})
+			continue
+		}
+
+		nulls := schemaIssue{Column: col.Name, Problem: "NULL in a NOT NULL column"}
+		mismatches := schemaIssue{Column: col.Name, Problem: fmt.Sprintf("value not compatible with %s", tc.UDTName)}
+		for i, row := range rows {
+			value := row[col.Name]
+			switch {
+			case value == nil:
+				if !tc.Nullable {
+					nulls.add(i, "NULL")
+				}
+			case !valueCompatible(value, tc.UDTName):
+				mismatches.add(i, describeValue(value))
+			}
+		}
+		if nulls.Rows > 0 {
+			issues = append(issues, nulls)
+		}
+		if mismatches.Rows > 0 {
+			issues = append(issues, mismatches)
+		}
+	}
+
+	for _, tc := range target {
+		if !inserted[tc.Name] && !tc.Nullable && !tc.HasDefault && !tc.Generated {
+			issues = append(issues, schemaIssue{Column: tc.Name, Problem: "is NOT NULL without a default but missing from the file"})
+		}
+	}
+	return issues
+}
+
+// mergeSchemaIssues adds the issues found in a chunk of rows starting at row
+// offset of the file to those found in earlier chunks
+func mergeSchemaIssues(issues, chunk []schemaIssue, offset int) []schemaIssue {
+	for _, issue := range chunk {
+		found := false
+		for i := range issues {
+			if issues[i].Column == issue.Column && issues[i].Problem == issue.Problem {
+				issues[i].Rows += issue.Rows
+				found = true
+				break
+			}
+		}
+		if !found {
+			if issue.Rows > 0 {
+				issue.Row += offset
+			}
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// add counts an offending row, keeping the first one as the example
+func (i *schemaIssue) add(rowIndex int, example string) {
+	if i.Rows == 0 {
+		i.Row = rowIndex + 1
+		i.Example = example
+	}
+	i.Rows++
+}
+
+// describeValue renders a value and its type for the schema report
+func describeValue(value interface{}) string {
+	text := fmt.Sprintf("%v", value)
+	if len(text) > 40 {
+		text = text[:37] + "..."
+	}
+	return fmt.Sprintf("%q (%T)", text, value)
+}
+
+// valueCompatible reports whether PostgreSQL will accept value for a column of
+// type udtName. Types without a rule (text, arrays, enums, ...) accept anything.
+func valueCompatible(value interface{}, udtName string) bool {
+	switch udtName {
+	case "int2":
+		n, ok := integerValue(value)
+		return ok && n >= math.MinInt16 && n <= math.MaxInt16
+	case "int4":
+		n, ok := integerValue(value)
+		return ok && n >= math.MinInt32 && n <= math.MaxInt32
+	case "int8":
+		_, ok := integerValue(value)
+		return ok
+	case "float4", "float8", "numeric":
+		return isNumeric(value)
+	case "bool":
+		return isBoolean(value)
+	case "timestamp", "timestamptz", "date":
+		return isTimestamp(value)
+	case "json", "jsonb":
+		if s, ok := value.(string); ok {
+			return json.Valid([]byte(s))
+		}
+		return true
+	case "uuid":
+		switch v := value.(type) {
+		case string:
+			return uuidPattern.MatchString(v)
+		case []byte:
+			return len(v) == 16 || uuidPattern.Match(v)
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// integerValue returns value as an integer when it is one, including
+// whole-number floats (JSON numbers) and numeric strings
+func integerValue(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case float32:
+		return integerValue(float64(v))
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) || v != math.Trunc(v) || math.Abs(v) > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func isNumeric(value interface{}) bool {
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint8, uint16, uint32, uint64, float32, float64:
+		return true
+	case string:
+		_, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return err == nil
+	}
+	return false
+}
+
+func isBoolean(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return true
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "t", "f", "true", "false", "y", "n", "yes", "no", "on", "off", "1", "0":
+			return true
+		}
+	}
+	return false
+}
+
+func isTimestamp(value interface{}) bool {
+	switch v := value.(type) {
+	case time.Time:
+		return true
+	case string:
+		for _, layout := range timestampLayouts {
+			if _, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
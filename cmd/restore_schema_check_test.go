@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestValueCompatible(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		udt   string
+		want  bool
+	}{
+		{float64(42), "int4", true},
+		{float64(4.5), "int4", false},
+		{float64(3e9), "int4", false},
+		{float64(3e9), "int8", true},
+		{"123", "int2", true},
+		{"40000", "int2", false},
+		{"abc", "int8", false},
+		{"1.5e3", "numeric", true},
+		{"n/a", "float8", false},
+		{true, "bool", true},
+		{"yes", "bool", true},
+		{float64(1), "bool", false},
+		{"2024-01-15T10:00:00Z", "timestamptz", true},
+		{"2024-01-15 10:00:00", "timestamp", true},
+		{"2024-01-15", "date", true},
+		{"yesterday-ish", "timestamptz", false},
+		{time.Now(), "timestamptz", true},
+		{map[string]interface{}{"a": 1}, "jsonb", true},
+		{`{"a":1}`, "json", true},
+		{"not json", "jsonb", false},
+		{"6ba7b810-9dad-11d1-80b4-00c04fd430c8", "uuid", true},
+		{"not-a-uuid", "uuid", false},
+		{float64(7), "text", true},
+		{"anything", "_int4", true},
+	}
+
+	for _, tt := range tests {
+		if got := valueCompatible(tt.value, tt.udt); got != tt.want {
+			t.Errorf("valueCompatible(%#v, %s) = %v, want %v", tt.value, tt.udt, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSchemaCompatibility(t *testing.T) {
+	target := []targetColumn{
+		{Name: "id", UDTName: "int8"},
+		{Name: "callsign", UDTName: "text", Nullable: true},
+		{Name: "seen_at", UDTName: "timestamptz"},
+		{Name: "created_at", UDTName: "timestamptz", HasDefault: true},
+		{Name: "tail", UDTName: "text"},
+		{Name: "search", UDTName: "tsvector", Nullable: true, Generated: true},
+	}
+
+	t.Run("Compatible", func(t *testing.T) {
+		rows := []map[string]interface{}{
+			{"id": float64(1), "callsign": "UAL1", "seen_at": "2024-01-15T10:00:00Z", "tail": "N1"},
+			{"id": float64(2), "callsign": nil, "seen_at": "2024-01-15T11:00:00Z", "tail": "N2"},
+		}
+		columns := []ColumnInfo{{Name: "id"}, {Name: "callsign"}, {Name: "seen_at"}, {Name: "tail"}}
+		if issues := checkSchemaCompatibility(rows, columns, target); len(issues) != 0 {
+			t.Errorf("expected no issues, got %v", issues)
+		}
+	})
+
+	t.Run("Incompatible", func(t *testing.T) {
+		rows := []map[string]interface{}{
+			{"id": float64(1), "seen_at": "2024-01-15T10:00:00Z", "altitude": float64(35000), "search": "x"},
+			{"id": "abc", "seen_at": nil, "altitude": float64(36000), "search": "y"},
+			{"id": float64(2.5), "seen_at": nil, "altitude": float64(37000), "search": "z"},
+		}
+		columns := []ColumnInfo{{Name: "altitude"}, {Name: "id"}, {Name: "search"}, {Name: "seen_at"}}
+
+		var report []string
+		for _, issue := range checkSchemaCompatibility(rows, columns, target) {
+			report = append(report, issue.String())
+		}
+		want := []string{
+			"column altitude: does not exist in the target table",
+			`column id: value not compatible with int8 in 2 row(s), first at row 2: "abc" (string)`,
+			"column search: is a generated column and cannot be restored",
+			"column seen_at: NULL in a NOT NULL column in 2 row(s), first at row 2: NULL",
+			"column tail: is NOT NULL without a default but missing from the file",
+		}
+		if strings.Join(report, "\n") != strings.Join(want, "\n") {
+			t.Errorf("unexpected report:\n%s\nwant:\n%s", strings.Join(report, "\n"), strings.Join(want, "\n"))
+		}
+	})
+}
+
+func TestValidateFileSchema(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	config := newTestConfig()
+	config.Table = "flights"
+	r := NewRestorer(config, newTestLogger())
+	r.db = db
+
+	mock.ExpectQuery(`FROM information_schema.columns`).
+		WithArgs("flights").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "udt_name", "nullable", "has_default", "generated"}).
+			AddRow("id", "int8", false, false, false).
+			AddRow("callsign", "text", true, false, false))
+
+	schema := &TableSchema{Columns: []ColumnInfo{{Name: "id"}, {Name: "callsign"}}}
+	r.readChunkRows = 1 // Each row is its own chunk
+	stream := func(jsonl string) *fileRowStream {
+		s, err := r.openFileRows(strings.NewReader(jsonl), "jsonl", "none")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	if err := r.validateFileSchema(context.Background(), "good.jsonl", stream(`{"id": 1, "callsign": "UAL1"}`+"\n"+`{"id": 2, "callsign": null}`), schema); err != nil {
+		t.Errorf("expected compatible file, got %v", err)
+	}
+	// Target columns are cached, so no second query is expected. Problems in
+	// later chunks are reported once per column.
+	bad := `{"id": 1, "callsign": "UAL1"}` + "\n" + `{"id": "two", "callsign": "UAL2"}` + "\n" + `{"id": "three", "callsign": null}`
+	if err := r.validateFileSchema(context.Background(), "bad.jsonl", stream(bad), schema); !errors.Is(err, ErrRestoreSchemaIncompatible) || !strings.Contains(err.Error(), "1 schema problem(s)") {
+		t.Errorf("expected ErrRestoreSchemaIncompatible, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestMergeSchemaIssues(t *testing.T) {
+	var issues []schemaIssue
+	issues = mergeSchemaIssues(issues, []schemaIssue{{Column: "id", Problem: "NULL in a NOT NULL column", Rows: 2, Row: 3, Example: "NULL"}}, 0)
+	issues = mergeSchemaIssues(issues, []schemaIssue{
+		{Column: "id", Problem: "NULL in a NOT NULL column", Rows: 1, Row: 1, Example: "NULL"},
+		{Column: "speed", Problem: "value not compatible with int4", Rows: 1, Row: 2, Example: `"fast" (string)`},
+		{Column: "origin", Problem: "is NOT NULL without a default but missing from the file"},
+	}, 100)
+	issues = mergeSchemaIssues(issues, []schemaIssue{{Column: "origin", Problem: "is NOT NULL without a default but missing from the file"}}, 200)
+
+	want := []string{
+		"column id: NULL in a NOT NULL column in 3 row(s), first at row 3: NULL",
+		`column speed: value not compatible with int4 in 1 row(s), first at row 102: "fast" (string)`,
+		"column origin: is NOT NULL without a default but missing from the file",
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("merged issues = %v", issues)
+	}
+	for i, issue := range issues {
+		if issue.String() != want[i] {
+			t.Errorf("issue %d = %q, want %q", i, issue.String(), want[i])
+		}
+	}
+}
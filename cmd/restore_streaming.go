@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/airframesio/data-archiver/cmd/compressors"
+	"github.com/airframesio/data-archiver/cmd/formatters"
+)
+
+// defaultReadChunkRows is how many rows of a file restore holds in memory at a time
+const defaultReadChunkRows = 50000
+
+// Static errors for streaming restore
+var (
+	ErrReadChunkRowsInvalid = errors.New("read chunk rows must be at least 1")
+	ErrRowStreamNotRewound  = errors.New("file cannot be read a second time")
+)
+
+// rowChunkReader is a format reader that hands out rows a chunk at a time
+type rowChunkReader interface {
+	ReadChunk(chunkSize int) ([]map[string]interface{}, error)
+	Close() error
+}
+
+// fileRowStream reads an archived file's rows a chunk at a time, through
+// decompression and the format reader, so restore holds at most one chunk of
+// a file in memory whatever the file's size. A stream over a seekable file
+// can be rewound to read the file again.
+type fileRowStream struct {
+	file        io.Reader
+	format      string
+	compression string
+	chunkRows   int
+	restorer    *Restorer
+	reader      rowChunkReader
+	pending     []map[string]interface{} // Chunk read ahead by peek
+	opened      bool
+	filtered    int // Rows read so far that --where left out
+}
+
+// openFileRows starts streaming the rows of an archived file. Parquet needs
+// random access, so compressed Parquet is decompressed to a temporary file
+// first; an uncompressed Parquet *os.File is read in place.
+func (r *Restorer) openFileRows(file io.Reader, format, compression string) (*fileRowStream, error) {
+	chunkRows := r.readChunkRows
+	if chunkRows <= 0 {
+		chunkRows = defaultReadChunkRows
+	}
+	stream := &fileRowStream{file: file, format: format, compression: compression, chunkRows: chunkRows, restorer: r}
+	if err := stream.open(); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// open creates the decompression and format readers from the start of the file
+func (s *fileRowStream) open() error {
+	if s.opened {
+		seeker, ok := s.file.(io.Seeker)
+		if !ok {
+			return ErrRowStreamNotRewound
+		}
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to rewind file: %w", err)
+		}
+	}
+	s.opened = true
+	s.filtered = 0
+
+	if s.format == "parquet" {
+		reader, err := openParquetRows(s.file, s.compression)
+		if err != nil {
+			return err
+		}
+		s.reader = reader
+		return nil
+	}
+
+	compressor, err := compressors.GetCompressor(s.compression)
+	if err != nil {
+		return fmt.Errorf("failed to get compressor: %w", err)
+	}
+	decompressedReader, err := compressor.NewReader(s.file)
+	if err != nil {
+		return fmt.Errorf("failed to create decompression reader: %w", err)
+	}
+
+	switch s.format {
+	case "jsonl":
+		s.reader = formatters.NewJSONLReaderWithCloser(decompressedReader)
+	case "csv":
+		reader, err := newCSVReader(decompressedReader, s.restorer.csvColumns)
+		if err != nil {
+			decompressedReader.Close()
+			return fmt.Errorf("failed to create CSV reader: %w", err)
+		}
+		s.reader = reader
+	default:
+		decompressedReader.Close()
+		return fmt.Errorf("%w: %s", ErrOutputFormatInvalid, s.format)
+	}
+	return nil
+}
+
+// openParquetRows opens a Parquet file for reading row group by row group
+func openParquetRows(file io.Reader, compression string) (*formatters.ParquetReader, error) {
+	if f, ok := file.(*os.File); ok && compression == "none" {
+		info, err := f.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat Parquet file: %w", err)
+		}
+		reader, err := formatters.NewParquetReaderAt(f, info.Size(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Parquet reader: %w", err)
+		}
+		return reader, nil
+	}
+
+	compressor, err := compressors.GetCompressor(compression)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get compressor: %w", err)
+	}
+	decompressedReader, err := compressor.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create decompression reader: %w", err)
+	}
+	defer decompressedReader.Close()
+
+	spool, err := os.CreateTemp("", "restore-*.parquet")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	size, err := io.Copy(spool, decompressedReader)
+	if err != nil {
+		spool.Close()
+		os.Remove(spool.Name())
+		return nil, fmt.Errorf("failed to decompress Parquet file: %w", err)
+	}
+	reader, err := formatters.NewParquetReaderAt(spool, size, removeOnClose{spool})
+	if err != nil {
+		spool.Close()
+		os.Remove(spool.Name())
+		return nil, fmt.Errorf("failed to create Parquet reader: %w", err)
+	}
+	return reader, nil
+}
+
+// removeOnClose deletes a temporary file when it is closed
+type removeOnClose struct{ *os.File }
+
+func (f removeOnClose) Close() error {
+	err := f.File.Close()
+	if removeErr := os.Remove(f.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// next returns the next chunk of rows, or no rows at the end of the file.
+// JSONL field mapping is reversed on each chunk, and rows --where does not
+// match are left out; chunks left empty are skipped.
+func (s *fileRowStream) next() ([]map[string]interface{}, error) {
+	if s.pending != nil {
+		rows := s.pending
+		s.pending = nil
+		return rows, nil
+	}
+	for {
+		rows, err := s.reader.ReadChunk(s.chunkRows)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to read %s: %w", s.format, err)
+		}
+		if s.format == "jsonl" && s.restorer.fieldMapping != nil {
+			s.restorer.fieldMapping.ReverseRows(rows)
+		}
+		if len(rows) == 0 || s.restorer.rowFilter == nil {
+			return rows, nil
+		}
+		read := len(rows)
+		rows = s.restorer.rowFilter.apply(rows)
+		s.filtered += read - len(rows)
+		if len(rows) > 0 {
+			return rows, nil
+		}
+	}
+}
+
+// peek returns the first chunk without consuming it
+func (s *fileRowStream) peek() ([]map[string]interface{}, error) {
+	if s.pending == nil {
+		rows, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+		s.pending = rows
+	}
+	return s.pending, nil
+}
+
+// each calls fn with every remaining chunk of rows in order
+func (s *fileRowStream) each(fn func(rows []map[string]interface{}) error) error {
+	for {
+		rows, err := s.next()
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+}
+
+// rewind starts the stream over from the first row
+func (s *fileRowStream) rewind() error {
+	if err := s.reader.Close(); err != nil {
+		return err
+	}
+	s.reader, s.pending = nil, nil
+	return s.open()
+}
+
+// Close closes the format and decompression readers. The file itself is left
+// open for its owner to close.
+func (s *fileRowStream) Close() error {
+	if s.reader == nil {
+		return nil
+	}
+	err := s.reader.Close()
+	s.reader = nil
+	return err
+}
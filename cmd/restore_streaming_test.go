@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/airframesio/data-archiver/cmd/compressors"
+	"github.com/airframesio/data-archiver/cmd/formatters"
+)
+
+// writeArchiveFile writes data compressed with compression to a temp file
+func writeArchiveFile(t *testing.T, data []byte, compression string) *os.File {
+	t.Helper()
+	compressor, err := compressors.GetCompressor(compression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := compressor.Compress(data, compressor.DefaultLevel())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "archive")
+	if err := os.WriteFile(path, compressed, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return file
+}
+
+// chunkSizes drains a stream and returns the size of each chunk
+func chunkSizes(t *testing.T, stream *fileRowStream) []int {
+	t.Helper()
+	var sizes []int
+	if err := stream.each(func(rows []map[string]interface{}) error {
+		sizes = append(sizes, len(rows))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return sizes
+}
+
+func TestFileRowStreamReadsChunksAndRewinds(t *testing.T) {
+	var jsonl strings.Builder
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(&jsonl, "{\"id\": %d}\n", i)
+	}
+	file := writeArchiveFile(t, []byte(jsonl.String()), "gzip")
+
+	r := NewRestorer(newTestConfig(), newTestLogger())
+	r.readChunkRows = 2
+	stream, err := r.openFileRows(file, "jsonl", "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	// Peeking leaves the first chunk in the stream
+	first, err := stream.peek()
+	if err != nil || len(first) != 2 || first[0]["id"] != float64(1) {
+		t.Fatalf("peek() = %v, %v", first, err)
+	}
+	if got := chunkSizes(t, stream); fmt.Sprint(got) != "[2 2 1]" {
+		t.Errorf("chunks = %v", got)
+	}
+
+	if err := stream.rewind(); err != nil {
+		t.Fatal(err)
+	}
+	if got := chunkSizes(t, stream); fmt.Sprint(got) != "[2 2 1]" {
+		t.Errorf("chunks after rewind = %v", got)
+	}
+
+	// A stream over a reader that cannot seek is read once
+	once, err := r.openFileRows(io.MultiReader(strings.NewReader(jsonl.String())), "jsonl", "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := once.rewind(); !errors.Is(err, ErrRowStreamNotRewound) {
+		t.Errorf("expected ErrRowStreamNotRewound, got %v", err)
+	}
+}
+
+func TestFileRowStreamParquet(t *testing.T) {
+	rows := make([]map[string]interface{}, 2500)
+	for i := range rows {
+		rows[i] = map[string]interface{}{"id": int64(i + 1), "callsign": fmt.Sprintf("UAL%d", i+1)}
+	}
+	data, err := formatters.NewParquetFormatter().Format(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, compression := range []string{"none", "zstd"} {
+		t.Run(compression, func(t *testing.T) {
+			spoolDir := t.TempDir()
+			t.Setenv("TMPDIR", spoolDir)
+			file := writeArchiveFile(t, data, compression)
+
+			r := NewRestorer(newTestConfig(), newTestLogger())
+			r.readChunkRows = 1000
+			stream, err := r.openFileRows(file, "parquet", compression)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Each chunk continues where the previous one stopped
+			var ids []int64
+			var sizes []int
+			if err := stream.each(func(chunk []map[string]interface{}) error {
+				sizes = append(sizes, len(chunk))
+				for _, row := range chunk {
+					ids = append(ids, row["id"].(int64))
+				}
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(sizes) != "[1000 1000 500]" || ids[0] != 1 || ids[1000] != 1001 || ids[2499] != 2500 {
+				t.Errorf("chunks = %v, ids %d..%d", sizes, ids[0], ids[len(ids)-1])
+			}
+
+			if err := stream.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if spooled, _ := os.ReadDir(spoolDir); len(spooled) != 0 {
+				t.Errorf("decompressed Parquet left behind: %v", spooled)
+			}
+		})
+	}
+}
+
+func TestFileRowStreamBrotliAndXZ(t *testing.T) {
+	r := NewRestorer(newTestConfig(), newTestLogger())
+	for _, tt := range []struct {
+		key         string
+		compression string
+	}{
+		{"flights/flights-2024-01-01.jsonl.br", "brotli"},
+		{"flights/flights-2024-01-01.jsonl.xz", "xz"},
+	} {
+		format, compression, err := detectFormatAndCompression(tt.key, "", "")
+		if err != nil || format != "jsonl" || compression != tt.compression {
+			t.Fatalf("detectFormatAndCompression(%q) = %q, %q, %v", tt.key, format, compression, err)
+		}
+		if !isValidCompressionLevel(compression, compressorDefaultLevel(t, compression)) {
+			t.Errorf("default %s level is not valid", compression)
+		}
+
+		file := writeArchiveFile(t, []byte("{\"id\": 1}\n{\"id\": 2}\n{\"id\": 3}\n"), compression)
+		stream, err := r.openFileRows(file, format, compression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := chunkSizes(t, stream); fmt.Sprint(got) != "[3]" {
+			t.Errorf("%s chunks = %v", compression, got)
+		}
+		stream.Close()
+	}
+}
+
+// compressorDefaultLevel returns the default level of a compression
+func compressorDefaultLevel(t *testing.T, compression string) int {
+	t.Helper()
+	compressor, err := compressors.GetCompressor(compression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return compressor.DefaultLevel()
+}
@@ -0,0 +1,688 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Static errors for restore targets
+var (
+	ErrRestoreTargetScheme       = errors.New("unsupported restore target, expected postgres://, clickhouse:// or mysql://")
+	ErrRestoreTargetInvalid      = errors.New("invalid restore target")
+	ErrRestoreTargetPartitions   = errors.New("table partitions are only created when restoring into PostgreSQL")
+	ErrRestoreTargetSchemaSource = errors.New("schema-source must be inferred or auto when restoring into ClickHouse or MySQL")
+	ErrClickHouseRequest         = errors.New("ClickHouse request failed")
+	ErrMySQLClientNotFound       = errors.New("mysql client not found in PATH (required for mysql:// targets)")
+)
+
+const (
+	defaultClickHousePort       = 8123
+	defaultClickHouseSecurePort = 8443
+	defaultMySQLPort            = 3306
+	clickHouseRequestTimeout    = 30 * time.Minute
+)
+
+// targetWriter loads restored rows into a database engine other than
+// PostgreSQL, using that engine's bulk loader. PostgreSQL restores keep using
+// the Restorer's own connection, partitioning, and batched inserts.
+type targetWriter interface {
+	// String identifies the target without credentials
+	String() string
+	// Ping checks that the target is reachable
+	Ping(ctx context.Context) error
+	// CreateTable creates the table for schema if it doesn't exist yet
+	CreateTable(ctx context.Context, tableName string, schema *TableSchema) error
+	// Load bulk-loads rows and returns the number of rows written
+	Load(ctx context.Context, tableName string, rows []map[string]interface{}, schema *TableSchema) (int64, error)
+	Close() error
+}
+
+// parseRestoreTarget parses a --target URL. postgres:// URLs update db in
+// place and return no writer; clickhouse:// and mysql:// URLs return the
+// writer for that engine. An empty target keeps the --db-* settings.
+func parseRestoreTarget(target string, db *DatabaseConfig) (targetWriter, error) {
+	if target == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("%w: '%s'", ErrRestoreTargetInvalid, redactTargetURL(target))
+	}
+	database := strings.TrimPrefix(u.Path, "/")
+	password, _ := u.User.Password()
+
+	switch u.Scheme {
+	case "postgres", "postgresql":
+		port, err := targetPort(u, db.Port)
+		if err != nil {
+			return nil, err
+		}
+		db.Host = u.Hostname()
+		db.Port = port
+		if u.User != nil {
+			db.User = u.User.Username()
+			db.Password = password
+		}
+		if database != "" {
+			db.Name = database
+		}
+		if sslMode := u.Query().Get("sslmode"); sslMode != "" {
+			db.SSLMode = sslMode
+		}
+		return nil, nil
+	case "clickhouse":
+		secure := u.Query().Get("secure") == "true"
+		defaultPort := defaultClickHousePort
+		scheme := "http"
+		if secure {
+			defaultPort = defaultClickHouseSecurePort
+			scheme = "https"
+		}
+		port, err := targetPort(u, defaultPort)
+		if err != nil {
+			return nil, err
+		}
+		if database == "" {
+			database = "default"
+		}
+		return &clickHouseWriter{
+			endpoint: fmt.Sprintf("%s://%s:%d/", scheme, u.Hostname(), port),
+			database: database,
+			user:     u.User.Username(),
+			password: password,
+			client:   &http.Client{Timeout: clickHouseRequestTimeout},
+		}, nil
+	case "mysql":
+		port, err := targetPort(u, defaultMySQLPort)
+		if err != nil {
+			return nil, err
+		}
+		if database == "" {
+			return nil, fmt.Errorf("%w: '%s' has no database name", ErrRestoreTargetInvalid, redactTargetURL(target))
+		}
+		return &mysqlWriter{
+			client:   "mysql",
+			host:     u.Hostname(),
+			port:     port,
+			user:     u.User.Username(),
+			password: password,
+			database: database,
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w, got '%s'", ErrRestoreTargetScheme, u.Scheme)
+	}
+}
+
+// targetPort returns the URL's port, or fallback if it has none
+func targetPort(u *url.URL, fallback int) (int, error) {
+	if u.Port() == "" {
+		return fallback, nil
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return 0, fmt.Errorf("%w: port '%s'", ErrRestoreTargetInvalid, u.Port())
+	}
+	return port, nil
+}
+
+// targetPassword matches the password in a URL's user info
+var targetPassword = regexp.MustCompile(`(://[^:/@]*:)[^@/]*@`)
+
+// redactTargetURL hides the password in a target URL for logs and errors,
+// including URLs that fail to parse
+func redactTargetURL(target string) string {
+	return targetPassword.ReplaceAllString(target, "${1}xxxxx@")
+}
+
+// validateTargetOptions rejects restore options that only apply to PostgreSQL
+func validateTargetOptions(writer targetWriter, partitionRange, schemaSource string) error {
+	if writer == nil {
+		return nil
+	}
+	if partitionRange != "" {
+		return fmt.Errorf("%w: %s", ErrRestoreTargetPartitions, writer)
+	}
+	if schemaSource != "inferred" && schemaSource != "auto" {
+		return fmt.Errorf("%w, got '%s'", ErrRestoreTargetSchemaSource, schemaSource)
+	}
+	return nil
+}
+
+// quoteBacktickIdentifier quotes a ClickHouse or MySQL identifier
+func quoteBacktickIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// targetColumnList returns the quoted column names of schema
+func targetColumnList(schema *TableSchema) string {
+	names := make([]string, len(schema.Columns))
+	for i, col := range schema.Columns {
+		names[i] = quoteBacktickIdentifier(col.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// targetValue normalizes an archived value for a non-PostgreSQL target:
+// timestamps become UTC "YYYY-MM-DD hh:mm:ss.ffffff" strings, JSON objects
+// and arrays become JSON text, and bytes become strings
+func targetValue(value interface{}, udtName string) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		if udtName == "date" {
+			return v.UTC().Format("2006-01-02")
+		}
+		return v.UTC().Format("2006-01-02 15:04:05.999999")
+	case string:
+		if udtName == "timestamptz" || udtName == "timestamp" {
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return t.UTC().Format("2006-01-02 15:04:05.999999")
+			}
+		}
+		return v
+	case []byte:
+		return string(v)
+	case map[string]interface{}, []interface{}:
+		if data, err := json.Marshal(v); err == nil {
+			return string(data)
+		}
+	}
+	return value
+}
+
+// clickHouseWriter loads rows through ClickHouse's HTTP interface with
+// INSERT ... FORMAT JSONEachRow
+type clickHouseWriter struct {
+	endpoint string // http(s)://host:port/
+	database string
+	user     string
+	password string
+	client   *http.Client
+}
+
+func (w *clickHouseWriter) String() string {
+	return "clickhouse://" + strings.TrimSuffix(strings.SplitN(w.endpoint, "://", 2)[1], "/") + "/" + w.database
+}
+
+func (w *clickHouseWriter) Ping(ctx context.Context) error {
+	return w.exec(ctx, "SELECT 1", nil)
+}
+
+func (w *clickHouseWriter) CreateTable(ctx context.Context, tableName string, schema *TableSchema) error {
+	return w.exec(ctx, clickHouseCreateTable(tableName, schema), nil)
+}
+
+func (w *clickHouseWriter) Load(ctx context.Context, tableName string, rows []map[string]interface{}, schema *TableSchema) (int64, error) {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, row := range rows {
+		record := make(map[string]interface{}, len(schema.Columns))
+		for _, col := range schema.Columns {
+			record[col.Name] = targetValue(row[col.Name], col.UDTName)
+		}
+		if err := encoder.Encode(record); err != nil {
+			return 0, fmt.Errorf("failed to encode row: %w", err)
+		}
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) FORMAT JSONEachRow", quoteBacktickIdentifier(tableName), targetColumnList(schema))
+	if err := w.exec(ctx, query, &body); err != nil {
+		return 0, err
+	}
+	return int64(len(rows)), nil
+}
+
+func (w *clickHouseWriter) Close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
+
+// exec runs query. With a body the query goes in the URL and the body is the
+// INSERT's data; otherwise the query itself is posted.
+func (w *clickHouseWriter) exec(ctx context.Context, query string, body io.Reader) error {
+	params := url.Values{}
+	params.Set("database", w.database)
+	params.Set("date_time_input_format", "best_effort")
+	if body == nil {
+		body = strings.NewReader(query)
+	} else {
+		params.Set("query", query)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.endpoint+"?"+params.Encode(), body)
+	if err != nil {
+		return err
+	}
+	if w.user != "" {
+		req.Header.Set("X-ClickHouse-User", w.user)
+		req.Header.Set("X-ClickHouse-Key", w.password)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%w (%s): %s", ErrClickHouseRequest, resp.Status, strings.TrimSpace(string(message)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// clickHouseCreateTable returns the CREATE TABLE statement for schema. Every
+// column is Nullable since archives don't record NOT NULL constraints.
+func clickHouseCreateTable(tableName string, schema *TableSchema) string {
+	columns := make([]string, len(schema.Columns))
+	for i, col := range schema.Columns {
+		columns[i] = fmt.Sprintf("  %s Nullable(%s)", quoteBacktickIdentifier(col.Name), clickHouseType(col.UDTName))
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s\n) ENGINE = MergeTree ORDER BY tuple()",
+		quoteBacktickIdentifier(tableName), strings.Join(columns, ",\n"))
+}
+
+// clickHouseType maps a PostgreSQL type to a ClickHouse type
+func clickHouseType(udtName string) string {
+	switch udtName {
+	case "int2":
+		return "Int16"
+	case "int4":
+		return "Int32"
+	case "int8":
+		return "Int64"
+	case "float4":
+		return "Float32"
+	case "float8":
+		return "Float64"
+	case "numeric":
+		return "Decimal(38, 10)"
+	case "bool":
+		return "Bool"
+	case "timestamp", "timestamptz":
+		return "DateTime64(6, 'UTC')"
+	case "date":
+		return "Date32"
+	case "uuid":
+		return "UUID"
+	default:
+		return "String"
+	}
+}
+
+// mysqlWriter loads rows with LOAD DATA LOCAL INFILE through the mysql
+// command-line client, the same way pg_dump schemas are applied with psql
+type mysqlWriter struct {
+	client   string // mysql client binary
+	host     string
+	port     int
+	user     string
+	password string
+	database string
+}
+
+func (w *mysqlWriter) String() string {
+	return fmt.Sprintf("mysql://%s:%d/%s", w.host, w.port, w.database)
+}
+
+func (w *mysqlWriter) Ping(ctx context.Context) error {
+	if _, err := exec.LookPath(w.client); err != nil {
+		return ErrMySQLClientNotFound
+	}
+	_, err := w.exec(ctx, "SELECT 1")
+	return err
+}
+
+func (w *mysqlWriter) CreateTable(ctx context.Context, tableName string, schema *TableSchema) error {
+	_, err := w.exec(ctx, mysqlCreateTable(tableName, schema))
+	return err
+}
+
+func (w *mysqlWriter) Load(ctx context.Context, tableName string, rows []map[string]interface{}, schema *TableSchema) (int64, error) {
+	tempFile, err := os.CreateTemp("", "data-archiver-restore-*.tsv")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	writer := bufio.NewWriter(tempFile)
+	if err := writeMySQLRows(writer, rows, schema); err != nil {
+		tempFile.Close()
+		return 0, err
+	}
+	if err := writer.Flush(); err != nil {
+		tempFile.Close()
+		return 0, fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	output, err := w.exec(ctx, mysqlLoadData(tempFile.Name(), tableName, schema))
+	if err != nil {
+		return 0, err
+	}
+	// ROW_COUNT() excludes rows skipped by IGNORE as duplicates
+	loaded, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	if err != nil {
+		return int64(len(rows)), nil
+	}
+	return loaded, nil
+}
+
+func (w *mysqlWriter) Close() error {
+	return nil
+}
+
+// args returns the mysql client arguments for running query
+func (w *mysqlWriter) args(query string) []string {
+	args := []string{
+		"--host=" + w.host,
+		"--port=" + strconv.Itoa(w.port),
+		"--database=" + w.database,
+		"--local-infile=1",
+		"--batch",
+		"--skip-column-names",
+		"--execute=" + query,
+	}
+	if w.user != "" {
+		args = append([]string{"--user=" + w.user}, args...)
+	}
+	return args
+}
+
+// exec runs query with the mysql client and returns its output. The password
+// is passed in the environment rather than on the command line.
+func (w *mysqlWriter) exec(ctx context.Context, query string) (string, error) {
+	cmd := exec.CommandContext(ctx, w.client, w.args(query)...)
+	cmd.Env = append(os.Environ(), "MYSQL_PWD="+w.password)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to run mysql: %w (output: %s)", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// mysqlCreateTable returns the CREATE TABLE statement for schema
+func mysqlCreateTable(tableName string, schema *TableSchema) string {
+	columns := make([]string, len(schema.Columns))
+	for i, col := range schema.Columns {
+		columns[i] = fmt.Sprintf("  %s %s NULL", quoteBacktickIdentifier(col.Name), mysqlType(col.UDTName))
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s\n) CHARACTER SET utf8mb4",
+		quoteBacktickIdentifier(tableName), strings.Join(columns, ",\n"))
+}
+
+// mysqlType maps a PostgreSQL type to a MySQL type
+func mysqlType(udtName string) string {
+	switch udtName {
+	case "int2":
+		return "SMALLINT"
+	case "int4":
+		return "INT"
+	case "int8":
+		return "BIGINT"
+	case "float4":
+		return "FLOAT"
+	case "float8":
+		return "DOUBLE"
+	case "numeric":
+		return "DECIMAL(65, 10)"
+	case "bool":
+		return "BOOLEAN"
+	case "timestamp", "timestamptz":
+		return "DATETIME(6)"
+	case "date":
+		return "DATE"
+	case "uuid":
+		return "CHAR(36)"
+	case "json", "jsonb":
+		return "JSON"
+	case "bytea":
+		return "LONGBLOB"
+	default:
+		return "LONGTEXT"
+	}
+}
+
+// mysqlLoadData returns the LOAD DATA statement for a file written by
+// writeMySQLRows. IGNORE skips rows that duplicate a unique key, like the
+// PostgreSQL restore's ON CONFLICT DO NOTHING.
+func mysqlLoadData(path, tableName string, schema *TableSchema) string {
+	quotedPath := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(path)
+	return fmt.Sprintf("LOAD DATA LOCAL INFILE '%s' IGNORE INTO TABLE %s CHARACTER SET utf8mb4 "+
+		`FIELDS TERMINATED BY '\t' ESCAPED BY '\\' LINES TERMINATED BY '\n' (%s); SELECT ROW_COUNT();`,
+		quotedPath, quoteBacktickIdentifier(tableName), targetColumnList(schema))
+}
+
+// mysqlFieldEscaper escapes the characters LOAD DATA treats specially
+var mysqlFieldEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`, "\x00", `\0`)
+
+// writeMySQLRows writes rows as tab-separated LOAD DATA input, with \N for NULL
+func writeMySQLRows(w io.Writer, rows []map[string]interface{}, schema *TableSchema) error {
+	fields := make([]string, len(schema.Columns))
+	for _, row := range rows {
+		for i, col := range schema.Columns {
+			fields[i] = mysqlField(targetValue(row[col.Name], col.UDTName))
+		}
+		if _, err := io.WriteString(w, strings.Join(fields, "\t")+"\n"); err != nil {
+			return fmt.Errorf("failed to write temp file: %w", err)
+		}
+	}
+	return nil
+}
+
+// mysqlField formats a normalized value as one LOAD DATA field
+func mysqlField(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return `\N`
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case string:
+		return mysqlFieldEscaper.Replace(v)
+	default:
+		return mysqlFieldEscaper.Replace(fmt.Sprint(v))
+	}
+}
+
+// restoreIntoTarget restores files into a ClickHouse or MySQL target. Each
+// file's columns are inferred from its first chunk of rows; unless restoring
+// data only, the first file's schema creates the table.
+func (r *Restorer) restoreIntoTarget(ctx context.Context, files []S3File, restoreMode, overrideFormat, overrideCompression string) error {
+	if restoreMode != "schema-only" {
+		ledger, err := loadRestoreLedger(getRestoreLedgerPathFor(r.config, r.writer.String()), r.writer.String())
+		if err != nil {
+			r.logger.Warn(fmt.Sprintf("⚠️  %v; restored files will not be skipped", err))
+		}
+		r.ledger = ledger
+	}
+
+	tableCreated := restoreMode == "data-only"
+	skipped := 0
+	for i, file := range files {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if r.ledger != nil && !r.force {
+			if record, ok := r.ledger.restored(file); ok {
+				r.logger.Info(fmt.Sprintf("⏭️  Skipping %s: already restored into %s on %s (%d rows; use --force to restore again)",
+					file.Key, strings.Join(record.Tables, ", "), record.RestoredAt.Format("2006-01-02 15:04"), record.Rows))
+				skipped++
+				continue
+			}
+		}
+
+		r.logger.Info(fmt.Sprintf("Processing file %d/%d: %s", i+1, len(files), file.Key))
+		created, err := r.restoreFileIntoTarget(ctx, file, tableCreated, restoreMode, overrideFormat, overrideCompression)
+		if err != nil {
+			return err
+		}
+		if created && !tableCreated && restoreMode == "schema-only" {
+			return nil
+		}
+		tableCreated = created
+	}
+
+	if skipped > 0 {
+		summaryLogger(r.logger).Info(fmt.Sprintf("✅ Restored %d files into %s (%d already restored, skipped)", len(files)-skipped, r.writer, skipped))
+		return nil
+	}
+	summaryLogger(r.logger).Info(fmt.Sprintf("✅ Restored %d files into %s", len(files), r.writer))
+	return nil
+}
+
+// restoreFileIntoTarget streams one file into the target a chunk at a time.
+// Its columns are inferred from its first chunk, which also creates the table
+// unless created is set. It reports whether the table exists afterwards.
+// Problems with the file are logged and leave it out; only cancellation is
+// returned.
+func (r *Restorer) restoreFileIntoTarget(ctx context.Context, file S3File, created bool, restoreMode, overrideFormat, overrideCompression string) (bool, error) {
+	// Files that stop short leave their count incomplete
+	var count *fileRowCount
+	if restoreMode != "schema-only" {
+		count = r.rowCounts.start(file)
+	}
+
+	stream, cleanup, err := r.downloadFileStream(ctx, file, overrideFormat, overrideCompression)
+	if err != nil {
+		r.logger.Error(fmt.Sprintf("Failed to read %s: %v", file.Key, err))
+		return created, nil
+	}
+	defer cleanup()
+
+	first, err := stream.peek()
+	if err != nil {
+		r.logger.Error(fmt.Sprintf("Failed to read %s: %v", file.Key, err))
+		return created, nil
+	}
+	if len(first) == 0 {
+		count.finish(0, stream.filtered, insertStats{}, true)
+		if stream.filtered > 0 {
+			r.logFilteredOut(file, stream.filtered, nil)
+			return created, nil
+		}
+		r.logger.Debug(fmt.Sprintf("No rows in file %s", file.Key))
+		return created, nil
+	}
+	schema, err := r.inferTableSchema(first)
+	if err != nil {
+		r.logger.Error(fmt.Sprintf("Failed to infer schema: %v", err))
+		return created, nil
+	}
+
+	if !created {
+		if r.config.DryRun {
+			r.logger.Info(fmt.Sprintf("[DRY RUN] Would create table %s in %s (%d columns)", r.config.Table, r.writer, len(schema.Columns)))
+		} else if err := r.writer.CreateTable(ctx, r.config.Table, schema); err != nil {
+			return false, fmt.Errorf("failed to create table %s in %s: %w", r.config.Table, r.writer, err)
+		}
+		created = true
+		if restoreMode == "schema-only" {
+			r.logger.Info(fmt.Sprintf("✅ Created table %s in %s from %s", r.config.Table, r.writer, file.Key))
+			return created, nil
+		}
+	}
+
+	r.fileStats = insertStats{}
+	if !r.config.DryRun {
+		r.logger.Info(fmt.Sprintf("Loading rows into %s", r.config.Table))
+	}
+	rows := 0
+	err = stream.each(func(chunk []map[string]interface{}) error {
+		if !r.config.DryRun {
+			start := time.Now()
+			loaded, err := r.writer.Load(ctx, r.config.Table, chunk, schema)
+			if err != nil {
+				return err
+			}
+			r.fileStats.add(len(chunk), loaded, time.Since(start))
+		}
+		rows += len(chunk)
+		return nil
+	})
+	count.finish(rows, stream.filtered, r.fileStats, err == nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return created, ctx.Err()
+		}
+		r.logger.Error(fmt.Sprintf("Failed to load %s after %d rows: %v", file.Key, rows, err))
+		return created, nil
+	}
+	if r.config.DryRun {
+		r.logger.Info(fmt.Sprintf("[DRY RUN] Would load %d rows into %s in %s", rows, r.config.Table, r.writer))
+	}
+	if stream.filtered > 0 {
+		r.logger.Info(fmt.Sprintf("🔎 Left out %d rows of %s that do not match --where", stream.filtered, file.Key))
+	}
+	r.recordRestored(file, []string{r.config.Table}, rows)
+	r.logProcessed(file, rows)
+	return created, nil
+}
+
+// downloadFileStream downloads an archive file and starts streaming its rows.
+// cleanup closes the stream and removes the download.
+func (r *Restorer) downloadFileStream(ctx context.Context, file S3File, overrideFormat, overrideCompression string) (*fileRowStream, func(), error) {
+	tempPath, _, err := r.downloader.Download(ctx, file.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to download: %w", err)
+	}
+	r.warnMasked(file.Key)
+
+	fileReader, err := os.Open(tempPath)
+	if err != nil {
+		os.Remove(tempPath)
+		return nil, nil, fmt.Errorf("failed to open temp file: %w", err)
+	}
+
+	format := file.DetectedFormat
+	compression := file.DetectedCompression
+	if overrideFormat != "" {
+		format = overrideFormat
+	}
+	if overrideCompression != "" {
+		compression = overrideCompression
+	}
+	if err := r.downloader.loadZstdDictionary(ctx, file.Key, fileReader); err != nil {
+		fileReader.Close()
+		os.Remove(tempPath)
+		return nil, nil, err
+	}
+	stream, err := r.openFileRows(fileReader, format, compression)
+	if err != nil {
+		fileReader.Close()
+		os.Remove(tempPath)
+		return nil, nil, err
+	}
+	return stream, func() {
+		stream.Close()
+		fileReader.Close()
+		os.Remove(tempPath)
+	}, nil
+}
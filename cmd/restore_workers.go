@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultRestoreWorkers = 1
+	// defaultTempDiskLimitMB bounds the downloaded files on disk at once
+	defaultTempDiskLimitMB = 4096
+)
+
+// Static errors for restore workers
+var (
+	ErrRestoreWorkersInvalid = errors.New("restore workers must be at least 1")
+	ErrTempDiskLimitInvalid  = errors.New("temp disk limit must be >= 0")
+)
+
+// restoreShared holds the locks the restore workers share
+type restoreShared struct {
+	schema  sync.Mutex // Serializes table and partition creation
+	columns sync.Mutex // Guards the target column cache
+
+	largeObjects sync.Map // OIDs of large objects handled this run
+	maskWarnings sync.Map // Masked column lists already warned about
+}
+
+// queuedFile is a file waiting to be restored, with its place in the listing
+type queuedFile struct {
+	file  S3File
+	index int
+}
+
+// worker returns a restorer for one restore worker. It shares the
+// connection pool, ledger and locks, but tunes its own batch size and keeps
+// its own file statistics.
+func (r *Restorer) worker(progress *restoreProgress) *Restorer {
+	if r.targetColumns == nil {
+		r.targetColumns = make(map[string][]targetColumn)
+	}
+	w := *r
+	w.tuner = nil
+	w.fileStats = insertStats{}
+	w.progress = progress
+	return &w
+}
+
+// restoreFilesConcurrently restores files with up to r.workers workers, each
+// downloading, decompressing and inserting a file of its own. Downloads wait
+// while the files already on disk would exceed --temp-disk-limit, and the
+// workers' progress is reported as one line.
+func (r *Restorer) restoreFilesConcurrently(ctx context.Context, files []queuedFile, total int, schema *TableSchema, restoreMode string, restoreConfig map[string]string) error {
+	workers := min(r.workers, len(files))
+	r.logger.Info(fmt.Sprintf("Restoring %d files with %d workers", len(files), workers))
+
+	budget := newDiskBudget(int64(r.tempDiskLimitMB) << 20)
+	progress := newRestoreProgress(len(files))
+	stopReporting := progress.report(r.logger, insertProgressInterval)
+	defer stopReporting()
+
+	jobs := make(chan queuedFile)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		worker := r.worker(progress)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if err := budget.acquire(ctx, job.file.Size); err != nil {
+					return
+				}
+				progress.active.Add(1)
+				worker.logger.Info(fmt.Sprintf("Processing file %d/%d: %s", job.index+1, total, job.file.Key))
+				worker.restoreFile(ctx, job.file, schema, restoreMode, restoreConfig)
+				progress.active.Add(-1)
+				progress.done.Add(1)
+				budget.release(job.file.Size)
+			}
+		}()
+	}
+
+dispatch:
+	for _, job := range files {
+		select {
+		case jobs <- job:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return ctx.Err()
+}
+
+// diskBudget bounds the bytes of downloaded files on disk at once. A file
+// larger than the whole budget is let through when nothing else is held, so
+// it restores on its own rather than never.
+type diskBudget struct {
+	mu    sync.Mutex
+	limit int64 // 0 = no limit
+	used  int64
+	freed chan struct{} // Closed when bytes are released
+}
+
+func newDiskBudget(limit int64) *diskBudget {
+	return &diskBudget{limit: limit, freed: make(chan struct{})}
+}
+
+// acquire waits until size bytes fit within the budget
+func (b *diskBudget) acquire(ctx context.Context, size int64) error {
+	for {
+		b.mu.Lock()
+		if b.limit <= 0 || b.used == 0 || b.used+size <= b.limit {
+			b.used += size
+			b.mu.Unlock()
+			return nil
+		}
+		freed := b.freed
+		b.mu.Unlock()
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release returns size bytes to the budget
+func (b *diskBudget) release(size int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= size
+	close(b.freed)
+	b.freed = make(chan struct{})
+}
+
+// restoreProgress is the combined progress of the restore workers
+type restoreProgress struct {
+	total   int
+	started time.Time
+	done    atomic.Int64
+	active  atomic.Int64
+	rows    atomic.Int64
+}
+
+func newRestoreProgress(total int) *restoreProgress {
+	return &restoreProgress{total: total, started: time.Now()}
+}
+
+// addRows records inserted rows; a nil progress records nothing
+func (p *restoreProgress) addRows(rows int) {
+	if p != nil {
+		p.rows.Add(int64(rows))
+	}
+}
+
+func (p *restoreProgress) String() string {
+	rows := p.rows.Load()
+	rate := 0.0
+	if elapsed := time.Since(p.started).Seconds(); elapsed > 0 {
+		rate = float64(rows) / elapsed
+	}
+	return fmt.Sprintf("%d/%d files, %d in progress, %d rows (%.0f rows/s)", p.done.Load(), p.total, p.active.Load(), rows, rate)
+}
+
+// report logs the progress every interval until the returned func is called
+func (p *restoreProgress) report(logger *slog.Logger, interval time.Duration) func() {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				logger.Info(fmt.Sprintf("📊 Restore progress: %s", p))
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
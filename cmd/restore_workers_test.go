@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDiskBudget(t *testing.T) {
+	ctx := context.Background()
+	budget := newDiskBudget(100)
+	if err := budget.acquire(ctx, 60); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file that does not fit waits for space to be released
+	acquired := make(chan error, 1)
+	go func() { acquired <- budget.acquire(ctx, 50) }()
+	select {
+	case err := <-acquired:
+		t.Fatalf("acquire() over the limit returned early: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	budget.release(60)
+	if err := <-acquired; err != nil {
+		t.Fatalf("acquire() after release = %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := budget.acquire(cancelled, 60); !errors.Is(err, context.Canceled) {
+		t.Errorf("acquire() with a cancelled context = %v", err)
+	}
+
+	// A file larger than the whole budget goes through on its own
+	budget.release(50)
+	if err := budget.acquire(ctx, 500); err != nil {
+		t.Errorf("acquire() of an oversized file = %v", err)
+	}
+}
+
+func TestRestoreFilesConcurrently(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+
+	ctx := context.Background()
+	bucket := t.TempDir()
+	store := &localObjectStore{}
+	var files []queuedFile
+	for i := 0; i < 6; i++ {
+		key := fmt.Sprintf("flights/flights-2024-01-%02d.jsonl", i+1)
+		body := fmt.Sprintf(`{"id": %d, "callsign": "UAL%d"}`+"\n", i, i)
+		if _, err := store.Put(ctx, bucket, key, strings.NewReader(body), PutOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, queuedFile{file: S3File{Key: key, Size: int64(len(body)), ETag: fmt.Sprint(i), DetectedFormat: "jsonl", DetectedCompression: "none"}, index: i})
+		mock.ExpectExec(`INSERT INTO "flights"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	config := newTestConfig()
+	config.Table = "flights"
+	r := NewRestorer(config, newTestLogger())
+	r.db = db
+	r.downloader = newRangeDownloader(store, bucket, t.TempDir(), 1, 0, newTestLogger())
+	r.batching.Method = insertMethodInsert
+	r.skipSchemaCheck = true
+	r.workers = 3
+	r.tempDiskLimitMB = 1
+	r.ledger, err = loadRestoreLedger(filepath.Join(t.TempDir(), "ledger.json"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema := &TableSchema{TableName: "flights", Columns: []ColumnInfo{{Name: "id", UDTName: "int8"}, {Name: "callsign", UDTName: "text"}}}
+	if err := r.restoreFilesConcurrently(ctx, files, len(files), schema, "data-only", map[string]string{}); err != nil {
+		t.Fatalf("restoreFilesConcurrently() = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	for _, job := range files {
+		if record, ok := r.ledger.restored(job.file); !ok || record.Rows != 1 {
+			t.Errorf("%s not recorded as restored: %+v", job.file.Key, record)
+		}
+	}
+}
+
+func TestRestoreProgressString(t *testing.T) {
+	progress := newRestoreProgress(10)
+	progress.done.Add(4)
+	progress.active.Add(2)
+	progress.addRows(1500)
+	if got := progress.String(); !strings.HasPrefix(got, "4/10 files, 2 in progress, 1500 rows (") {
+		t.Errorf("String() = %q", got)
+	}
+	var sequential *restoreProgress
+	sequential.addRows(10) // A restore without workers records nothing
+}
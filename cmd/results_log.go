@@ -0,0 +1,361 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errResultsLogNoStart = errors.New("results log has no run_start record")
+
+// Results log record types
+const (
+	resultsRecordStart  = "run_start"
+	resultsRecordResult = "result"
+	resultsRecordEnd    = "run_end"
+)
+
+// Run statuses reconstructed from a results log
+const (
+	runStatusRunning     = "running"
+	runStatusCompleted   = "completed"
+	runStatusFailed      = "failed"
+	runStatusCancelled   = "cancelled"
+	runStatusInterrupted = "interrupted" // No run_end record and the process is gone
+)
+
+// resultsRecord is one line of a results log. Every ProcessResult is appended
+// as soon as it completes so a run that dies midway still leaves its partial summary.
+type resultsRecord struct {
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	RunID      string    `json:"run_id,omitempty"`
+	Command    string    `json:"command,omitempty"`
+	Table      string    `json:"table,omitempty"`
+	PID        int       `json:"pid,omitempty"`
+	StartDate  string    `json:"start_date,omitempty"`
+	EndDate    string    `json:"end_date,omitempty"`
+	Partition  string    `json:"partition,omitempty"`
+	S3Key      string    `json:"s3_key,omitempty"`
+	Rows       int64     `json:"rows,omitempty"`
+	Bytes      int64     `json:"bytes,omitempty"`
+	Skipped    bool      `json:"skipped,omitempty"`
+	SkipReason string    `json:"skip_reason,omitempty"`
+	Denied     bool      `json:"permission_denied,omitempty"`
+	Stage      string    `json:"stage,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms,omitempty"`
+	Status     string    `json:"status,omitempty"`
+
+	// Partition date cross-check (--check-partition-dates)
+	DataMinDate    *time.Time `json:"data_min_date,omitempty"`
+	DataMaxDate    *time.Time `json:"data_max_date,omitempty"`
+	OutOfRangeRows int64      `json:"out_of_range_rows,omitempty"`
+
+	// Values fixed or rows quarantined (--invalid-values)
+	InvalidUTF8     int64 `json:"invalid_utf8,omitempty"`
+	NonFinite       int64 `json:"non_finite,omitempty"`
+	QuarantinedRows int64 `json:"quarantined_rows,omitempty"`
+
+	// Extraction SQL of each archive file (--record-sql)
+	Queries []extractionQuery `json:"queries,omitempty"`
+
+	// Compression ratio of each archive file written
+	Ratios []compressionRatio `json:"compression_ratios,omitempty"`
+}
+
+// RunFailure is a failed partition in a run summary
+type RunFailure struct {
+	Partition string `json:"partition"`
+	Stage     string `json:"stage,omitempty"`
+	Error     string `json:"error"`
+}
+
+// RunSummary is a run's summary reconstructed from its results log
+type RunSummary struct {
+	RunID          string       `json:"run_id"`
+	Command        string       `json:"command"`
+	Table          string       `json:"table"`
+	PID            int          `json:"pid"`
+	StartTime      time.Time    `json:"start_time"`
+	EndTime        *time.Time   `json:"end_time,omitempty"`
+	LastUpdate     time.Time    `json:"last_update"`
+	Status         string       `json:"status"`
+	Error          string       `json:"error,omitempty"`
+	Successful     int          `json:"successful"`
+	Skipped        int          `json:"skipped"`
+	Failed         int          `json:"failed"`
+	Rows           int64        `json:"rows"`
+	Bytes          int64        `json:"bytes"`
+	Failures       []RunFailure `json:"failures,omitempty"`
+	DateMismatches []string     `json:"date_mismatches,omitempty"`   // Partitions with rows outside the date in their name
+	Denied         []string     `json:"permission_denied,omitempty"` // Partitions skipped for lack of SELECT permission
+	Path           string       `json:"-"`
+}
+
+// Processed returns the number of partitions with a recorded result
+func (s RunSummary) Processed() int {
+	return s.Successful + s.Skipped + s.Failed
+}
+
+// resultsLog appends run records to an ndjson file
+type resultsLog struct {
+	mu   sync.Mutex
+	file *os.File
+	path string
+}
+
+// getResultsLogDir returns the directory holding results logs
+func getResultsLogDir() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".data-archiver", "runs")
+}
+
+// openResultsLog creates the results log for a run and writes its start record
+func openResultsLog(dir, command, runID string, config *Config, startTime time.Time) (*resultsLog, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create results log directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%s_%s_%s.ndjson", command, sanitizeCacheComponent(config.Table, "global"), runID)
+	path := filepath.Join(dir, name)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create results log: %w", err)
+	}
+
+	log := &resultsLog{file: file, path: path}
+	err = log.write(resultsRecord{
+		Type:      resultsRecordStart,
+		Time:      startTime,
+		RunID:     runID,
+		Command:   command,
+		Table:     config.Table,
+		PID:       os.Getpid(),
+		StartDate: config.StartDate,
+		EndDate:   config.EndDate,
+	})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return log, nil
+}
+
+// appendResult records a completed partition
+func (l *resultsLog) appendResult(result ProcessResult) error {
+	if l == nil {
+		return nil
+	}
+	record := resultsRecord{
+		Type:       resultsRecordResult,
+		Time:       time.Now(),
+		Partition:  result.Partition.TableName,
+		S3Key:      result.S3Key,
+		Rows:       result.Partition.RowCount,
+		Bytes:      result.BytesWritten,
+		Skipped:    result.Skipped,
+		SkipReason: result.SkipReason,
+		Denied:     result.PermissionDenied,
+		Stage:      result.Stage,
+		DurationMs: result.Duration.Milliseconds(),
+	}
+	if result.Error != nil {
+		record.Error = result.Error.Error()
+	}
+	if check := result.DateCheck; check != nil && !check.MinDate.IsZero() {
+		record.DataMinDate = &check.MinDate
+		record.DataMaxDate = &check.MaxDate
+		record.OutOfRangeRows = check.OutOfRange
+	}
+	record.InvalidUTF8 = result.ValueIssues.InvalidUTF8
+	record.NonFinite = result.ValueIssues.NonFinite
+	record.QuarantinedRows = result.ValueIssues.Quarantined
+	record.Queries = result.Queries
+	record.Ratios = result.Ratios
+	return l.write(record)
+}
+
+// close writes the end record with the run's final status and closes the file
+func (l *resultsLog) close(status string, runErr error) error {
+	if l == nil {
+		return nil
+	}
+	record := resultsRecord{Type: resultsRecordEnd, Time: time.Now(), Status: status}
+	if runErr != nil {
+		record.Error = runErr.Error()
+	}
+	writeErr := l.write(record)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.file.Close(); err != nil && writeErr == nil {
+		writeErr = err
+	}
+	return writeErr
+}
+
+// write appends one record and syncs it to disk so it survives a crash
+func (l *resultsLog) write(record resultsRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write results log: %w", err)
+	}
+	return l.file.Sync()
+}
+
+// readRunSummary reconstructs a run summary from a results log. A truncated
+// last line (from a crash mid-write) is ignored.
+func readRunSummary(path string, isRunning func(int) bool) (RunSummary, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return RunSummary{}, err
+	}
+	defer file.Close()
+
+	summary := RunSummary{Path: path}
+	ended := false
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record resultsRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if record.Time.After(summary.LastUpdate) {
+			summary.LastUpdate = record.Time
+		}
+
+		switch record.Type {
+		case resultsRecordStart:
+			summary.RunID = record.RunID
+			summary.Command = record.Command
+			summary.Table = record.Table
+			summary.PID = record.PID
+			summary.StartTime = record.Time
+		case resultsRecordResult:
+			if record.OutOfRangeRows > 0 {
+				summary.DateMismatches = append(summary.DateMismatches, record.Partition)
+			}
+			switch {
+			case record.Denied:
+				summary.Denied = append(summary.Denied, record.Partition)
+			case record.Error != "":
+				summary.Failed++
+				summary.Failures = append(summary.Failures, RunFailure{Partition: record.Partition, Stage: record.Stage, Error: record.Error})
+			case record.Skipped:
+				summary.Skipped++
+			default:
+				summary.Successful++
+				summary.Rows += record.Rows
+				summary.Bytes += record.Bytes
+			}
+		case resultsRecordEnd:
+			ended = true
+			endTime := record.Time
+			summary.EndTime = &endTime
+			summary.Status = record.Status
+			summary.Error = record.Error
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return summary, err
+	}
+	if summary.RunID == "" {
+		return summary, fmt.Errorf("%w: %s", errResultsLogNoStart, path)
+	}
+
+	if !ended {
+		summary.Status = runStatusInterrupted
+		if summary.PID > 0 && isRunning(summary.PID) {
+			summary.Status = runStatusRunning
+		}
+	}
+	return summary, nil
+}
+
+// listRunSummaries reads every results log in dir, newest first. An empty
+// table returns runs for all tables.
+func listRunSummaries(dir, table string, isRunning func(int) bool) ([]RunSummary, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read results log directory: %w", err)
+	}
+
+	var runs []RunSummary
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".ndjson") {
+			continue
+		}
+		summary, err := readRunSummary(filepath.Join(dir, file.Name()), isRunning)
+		if err != nil {
+			continue
+		}
+		if table != "" && summary.Table != table {
+			continue
+		}
+		runs = append(runs, summary)
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartTime.After(runs[j].StartTime)
+	})
+	return runs, nil
+}
+
+// startResultsLog opens the archiver's results log. Failure to create it is
+// logged and the run continues without one.
+func (a *Archiver) startResultsLog(command string) {
+	log, err := openResultsLog(getResultsLogDir(), command, currentRunID, a.config, time.Now())
+	if err != nil {
+		a.logger.Warn(fmt.Sprintf("⚠️  Results log disabled: %v", err))
+		return
+	}
+	a.logger.Debug(fmt.Sprintf("Recording results to %s", log.path))
+	a.results = log
+}
+
+// recordResult appends a finished partition to the results log
+func (a *Archiver) recordResult(result ProcessResult) {
+	if err := a.results.appendResult(result); err != nil {
+		a.logger.Debug(fmt.Sprintf("Failed to record result for %s: %v", result.Partition.TableName, err))
+	}
+}
+
+// finishResultsLog writes the run's final status and closes the results log
+func (a *Archiver) finishResultsLog(runErr error) {
+	if err := a.results.close(runStatusFromError(runErr), runErr); err != nil {
+		a.logger.Debug(fmt.Sprintf("Failed to close results log: %v", err))
+	}
+	a.results = nil
+}
+
+// runStatusFromError maps a run's final error to its recorded status
+func runStatusFromError(err error) string {
+	switch {
+	case err == nil:
+		return runStatusCompleted
+	case errors.Is(err, context.Canceled):
+		return runStatusCancelled
+	default:
+		return runStatusFailed
+	}
+}
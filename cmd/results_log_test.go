@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func notRunning(int) bool { return false }
+
+func TestResultsLogRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	config := newTestConfig()
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	runID := newRunID(start)
+	log, err := openResultsLog(dir, "archive", runID, config, start)
+	if err != nil {
+		t.Fatalf("openResultsLog failed: %v", err)
+	}
+	results := []ProcessResult{
+		{Partition: PartitionInfo{TableName: "test_table_20240101", RowCount: 10}, Uploaded: true, BytesWritten: 100},
+		{Partition: PartitionInfo{TableName: "test_table_20240102"}, Skipped: true, SkipReason: "already exists"},
+		{Partition: PartitionInfo{TableName: "test_table_20240103"}, Stage: "Upload", Error: errors.New("access denied")},
+		{Partition: PartitionInfo{TableName: "test_table_20240104", RowCount: 5}, Uploaded: true, BytesWritten: 50},
+	}
+	for _, result := range results {
+		if err := log.appendResult(result); err != nil {
+			t.Fatalf("appendResult failed: %v", err)
+		}
+	}
+	if err := log.close(runStatusFromError(nil), nil); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	summary, err := readRunSummary(log.path, notRunning)
+	if err != nil {
+		t.Fatalf("readRunSummary failed: %v", err)
+	}
+	if summary.RunID != runID || summary.Table != "test_table" || summary.Command != "archive" {
+		t.Errorf("unexpected run identity: %+v", summary)
+	}
+	if summary.Status != runStatusCompleted || summary.EndTime == nil {
+		t.Errorf("expected completed run with end time, got %s", summary.Status)
+	}
+	if summary.Successful != 2 || summary.Skipped != 1 || summary.Failed != 1 || summary.Processed() != 4 {
+		t.Errorf("unexpected counts: %+v", summary)
+	}
+	if summary.Rows != 15 || summary.Bytes != 150 {
+		t.Errorf("expected 15 rows and 150 bytes, got %d and %d", summary.Rows, summary.Bytes)
+	}
+	if len(summary.Failures) != 1 || summary.Failures[0].Partition != "test_table_20240103" ||
+		summary.Failures[0].Stage != "Upload" || summary.Failures[0].Error != "access denied" {
+		t.Errorf("unexpected failures: %+v", summary.Failures)
+	}
+}
+
+func TestReadRunSummaryInterruptedRun(t *testing.T) {
+	dir := t.TempDir()
+	log, err := openResultsLog(dir, "archive", currentRunID, newTestConfig(), time.Now())
+	if err != nil {
+		t.Fatalf("openResultsLog failed: %v", err)
+	}
+	_ = log.appendResult(ProcessResult{Partition: PartitionInfo{TableName: "p1", RowCount: 3}, BytesWritten: 30})
+	log.file.Close()
+
+	// Simulate a crash partway through writing the next record
+	file, _ := os.OpenFile(log.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	_, _ = file.WriteString(`{"type":"result","partition":"p2","by`)
+	file.Close()
+
+	summary, err := readRunSummary(log.path, notRunning)
+	if err != nil {
+		t.Fatalf("readRunSummary failed: %v", err)
+	}
+	if summary.Status != runStatusInterrupted || summary.EndTime != nil {
+		t.Errorf("expected interrupted run, got %s", summary.Status)
+	}
+	if summary.Successful != 1 || summary.Rows != 3 {
+		t.Errorf("expected the partial result to be kept, got %+v", summary)
+	}
+
+	summary, _ = readRunSummary(log.path, func(pid int) bool { return pid == os.Getpid() })
+	if summary.Status != runStatusRunning {
+		t.Errorf("expected running status while the process is alive, got %s", summary.Status)
+	}
+}
+
+func TestReadRunSummaryCancelled(t *testing.T) {
+	log, err := openResultsLog(t.TempDir(), "archive", currentRunID, newTestConfig(), time.Now())
+	if err != nil {
+		t.Fatalf("openResultsLog failed: %v", err)
+	}
+	runErr := context.Canceled
+	_ = log.close(runStatusFromError(runErr), runErr)
+
+	summary, _ := readRunSummary(log.path, notRunning)
+	if summary.Status != runStatusCancelled || summary.Error != "context canceled" {
+		t.Errorf("expected cancelled run, got %s (%s)", summary.Status, summary.Error)
+	}
+}
+
+func TestListRunSummaries(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, table := range []string{"flights", "messages", "flights"} {
+		config := newTestConfig()
+		config.Table = table
+		start := base.Add(time.Duration(i) * time.Hour)
+		log, err := openResultsLog(dir, "archive", newRunID(start), config, start)
+		if err != nil {
+			t.Fatalf("openResultsLog failed: %v", err)
+		}
+		_ = log.close(runStatusCompleted, nil)
+	}
+	_ = os.WriteFile(filepath.Join(dir, "garbage.ndjson"), []byte("not json\n"), 0o600)
+	_ = os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0o600)
+
+	runs, err := listRunSummaries(dir, "", notRunning)
+	if err != nil {
+		t.Fatalf("listRunSummaries failed: %v", err)
+	}
+	if len(runs) != 3 {
+		t.Fatalf("expected 3 runs, got %d", len(runs))
+	}
+	if !runs[0].StartTime.Equal(base.Add(2*time.Hour)) || !runs[2].StartTime.Equal(base) {
+		t.Errorf("expected newest run first, got %v then %v", runs[0].StartTime, runs[2].StartTime)
+	}
+
+	runs, _ = listRunSummaries(dir, "flights", notRunning)
+	if len(runs) != 2 {
+		t.Errorf("expected 2 flights runs, got %d", len(runs))
+	}
+
+	runs, err = listRunSummaries(filepath.Join(dir, "missing"), "", notRunning)
+	if err != nil || runs != nil {
+		t.Errorf("expected no runs for a missing directory, got %v, %v", runs, err)
+	}
+}
+
+func TestAttachRunSummaries(t *testing.T) {
+	report := FleetReport{Tables: []FleetTableStatus{{Table: "flights"}, {Table: "messages"}}}
+	runs := []RunSummary{
+		{RunID: "new", Command: "archive", Table: "flights", Status: runStatusInterrupted},
+		{RunID: "old", Command: "archive", Table: "flights", Status: runStatusCompleted},
+		{RunID: "restore", Command: "restore", Table: "messages", Status: runStatusFailed},
+	}
+
+	attachRunSummaries(&report, runs)
+
+	if report.Tables[0].LastRun == nil || report.Tables[0].LastRun.RunID != "new" {
+		t.Errorf("expected newest flights run, got %+v", report.Tables[0].LastRun)
+	}
+	if report.Tables[1].LastRun != nil {
+		t.Errorf("expected non-archive runs to be ignored, got %+v", report.Tables[1].LastRun)
+	}
+	if report.InterruptedRuns != 1 {
+		t.Errorf("expected 1 interrupted run, got %d", report.InterruptedRuns)
+	}
+}
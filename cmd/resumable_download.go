@@ -0,0 +1,422 @@
+package cmd
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // MD5 used for checksums, not cryptography
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	defaultDownloadPartSizeMB = 16
+	defaultDownloadRetries    = 5
+	downloadRetryBaseDelay    = time.Second
+	downloadRetryMaxDelay     = 30 * time.Second
+	downloadChecksumAttempts  = 3 // Whole-file downloads before an object is reported corrupt
+)
+
+// Static errors for resumable downloads
+var (
+	ErrDownloadPartSizeInvalid = errors.New("download part size must be at least 1 MB")
+	ErrDownloadRetriesInvalid  = errors.New("download retries must be >= 0")
+	ErrDownloadShortPart       = errors.New("ranged GET returned fewer bytes than requested")
+	ErrDownloadChecksum        = errors.New("downloaded file checksum does not match S3 ETag")
+	ErrDownloadCorrupt         = errors.New("object may be corrupt in S3")
+)
+
+// downloadOptions configures the restore downloader
+type downloadOptions struct {
+	Dir        string // Directory for partial downloads (default: <tmp>/data-archiver/downloads)
+	PartSizeMB int    // Size of each ranged GET
+	Retries    int    // Retries per part before the file is given up on
+	MaxRate    int64  // Download bytes per second across workers (0 = unlimited)
+}
+
+// validate checks the download part size and retry count
+func (o downloadOptions) validate() error {
+	if o.PartSizeMB < 1 {
+		return fmt.Errorf("%w, got %d", ErrDownloadPartSizeInvalid, o.PartSizeMB)
+	}
+	if o.Retries < 0 {
+		return fmt.Errorf("%w, got %d", ErrDownloadRetriesInvalid, o.Retries)
+	}
+	return nil
+}
+
+// downloadState is persisted next to a partial download so an interrupted
+// transfer can resume from the last verified part
+type downloadState struct {
+	Bucket   string   `json:"bucket"`
+	Key      string   `json:"key"`
+	ETag     string   `json:"etag"`
+	Size     int64    `json:"size"`
+	PartSize int64    `json:"part_size"`
+	PartMD5s []string `json:"part_md5s"` // Hex MD5 per completed part ("" = not downloaded yet)
+}
+
+// matches reports whether a saved state belongs to the same object version and layout
+func (s *downloadState) matches(bucket, key, etag string, size, partSize int64) bool {
+	return s.Bucket == bucket && s.Key == key && s.ETag == etag && s.Size == size &&
+		s.PartSize == partSize && len(s.PartMD5s) == partCount(size, partSize)
+}
+
+func partCount(size, partSize int64) int {
+	if size == 0 {
+		return 0
+	}
+	return int((size + partSize - 1) / partSize)
+}
+
+// rangeDownloader downloads S3 objects with ranged GETs. Each part is retried
+// with exponential backoff and checksummed; progress is saved to a state file
+// so a restarted restore only fetches the parts that are still missing.
+type rangeDownloader struct {
+	client     StorageBackend
+	bucket     string
+	dir        string
+	partSize   int64
+	maxRetries int
+	baseDelay  time.Duration
+	logger     *slog.Logger
+	rate       *bandwidthLimiter // Paces downloaded bytes (nil = unlimited)
+	masked     sync.Map          // Key → masked columns from the object's metadata
+}
+
+// newRangeDownloader creates a downloader that keeps partial files in dir
+// (default: <tmp>/data-archiver/downloads)
+func newRangeDownloader(client StorageBackend, bucket, dir string, partSizeMB, maxRetries int, logger *slog.Logger) *rangeDownloader {
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "data-archiver", "downloads")
+	}
+	return &rangeDownloader{
+		client:     client,
+		bucket:     bucket,
+		dir:        dir,
+		partSize:   int64(partSizeMB) * 1024 * 1024,
+		maxRetries: maxRetries,
+		baseDelay:  downloadRetryBaseDelay,
+		logger:     logger,
+	}
+}
+
+// downloadPaths returns the partial, state, and completed file paths for a key.
+// The names are stable across runs so an interrupted download can be found again.
+func (d *rangeDownloader) downloadPaths(key string) (partPath, statePath, donePath string) {
+	sum := sha256.Sum256([]byte(d.bucket + "/" + key))
+	base := filepath.Join(d.dir, hex.EncodeToString(sum[:8])+"-"+sanitizeCacheComponent(filepath.Base(key), "object"))
+	return base + ".part", base + ".part.json", base
+}
+
+// Download fetches key and returns the path of the completed file and its size.
+// The caller owns the returned file and should remove it when done. A file
+// whose checksum does not match the S3 ETag is downloaded again from scratch,
+// and reported as corrupt when every attempt fails verification.
+func (d *rangeDownloader) Download(ctx context.Context, key string) (string, int64, error) {
+	var lastErr error
+	for attempt := 1; attempt <= downloadChecksumAttempts; attempt++ {
+		path, size, err := d.download(ctx, key)
+		if !errors.Is(err, ErrDownloadChecksum) {
+			return path, size, err
+		}
+		lastErr = err
+		if attempt < downloadChecksumAttempts {
+			d.logger.Warn(fmt.Sprintf("⚠️  %v; downloading again (attempt %d/%d)", err, attempt+1, downloadChecksumAttempts))
+		}
+	}
+	return "", 0, fmt.Errorf("%w: %d downloads failed verification: %w", ErrDownloadCorrupt, downloadChecksumAttempts, lastErr)
+}
+
+// maskedColumns returns the masked columns listed in the metadata of a
+// downloaded object, or "" when it isn't masked
+func (d *rangeDownloader) maskedColumns(key string) string {
+	columns, _ := d.masked.Load(key)
+	s, _ := columns.(string)
+	return s
+}
+
+// download fetches key once, resuming from any verified parts on disk
+func (d *rangeDownloader) download(ctx context.Context, key string) (string, int64, error) {
+	if err := os.MkdirAll(d.dir, 0o755); err != nil {
+		return "", 0, fmt.Errorf("failed to create download directory: %w", err)
+	}
+
+	head, err := d.client.Head(ctx, d.bucket, key)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to stat %s: %w", key, err)
+	}
+	size := head.Size
+	etag := strings.Trim(head.ETag, `"`)
+	if columns := maskedColumns(head.Metadata); columns != "" {
+		d.masked.Store(key, columns)
+	}
+
+	partPath, statePath, donePath := d.downloadPaths(key)
+	state := d.loadState(statePath, partPath, key, etag, size)
+
+	file, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open partial download: %w", err)
+	}
+	defer file.Close()
+
+	resumed := 0
+	for i, sum := range state.PartMD5s {
+		if sum != "" {
+			resumed++
+			continue
+		}
+		start := int64(i) * d.partSize
+		end := min(start+d.partSize, size) - 1
+
+		sum, err := d.downloadPartWithRetry(ctx, key, etag, file, start, end)
+		if err != nil {
+			return "", 0, err
+		}
+		state.PartMD5s[i] = sum
+		if err := saveDownloadState(statePath, state); err != nil {
+			d.logger.Debug(fmt.Sprintf("Failed to save download state for %s: %v", key, err))
+		}
+	}
+	if resumed > 0 {
+		d.logger.Info(fmt.Sprintf("Resumed download of %s (%d/%d parts already on disk)", key, resumed, len(state.PartMD5s)))
+	}
+
+	if etagIsChecksum(head) {
+		if err := verifyDownloadETag(file, state); err != nil {
+			d.discard(partPath, statePath)
+			return "", 0, fmt.Errorf("%s: %w", key, err)
+		}
+	} else {
+		d.logger.Debug(fmt.Sprintf("%s is encrypted with SSE-KMS or SSE-C, whose ETag is not a checksum; relying on per-part checksums", key))
+	}
+
+	if err := file.Close(); err != nil {
+		return "", 0, fmt.Errorf("failed to close partial download: %w", err)
+	}
+	if err := os.Rename(partPath, donePath); err != nil {
+		return "", 0, fmt.Errorf("failed to finalize download: %w", err)
+	}
+	_ = os.Remove(statePath)
+
+	return donePath, size, nil
+}
+
+// loadState returns the saved state for key when it still describes the same
+// object and every recorded part checks out on disk; otherwise a fresh state
+func (d *rangeDownloader) loadState(statePath, partPath, key, etag string, size int64) *downloadState {
+	fresh := &downloadState{
+		Bucket:   d.bucket,
+		Key:      key,
+		ETag:     etag,
+		Size:     size,
+		PartSize: d.partSize,
+		PartMD5s: make([]string, partCount(size, d.partSize)),
+	}
+
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		d.discard(partPath, statePath)
+		return fresh
+	}
+	var state downloadState
+	if err := json.Unmarshal(data, &state); err != nil || !state.matches(d.bucket, key, etag, size, d.partSize) {
+		d.logger.Debug(fmt.Sprintf("Discarding stale partial download of %s", key))
+		d.discard(partPath, statePath)
+		return fresh
+	}
+
+	// Re-verify parts already on disk; a torn write is simply fetched again
+	file, err := os.Open(partPath)
+	if err != nil {
+		d.discard(partPath, statePath)
+		return fresh
+	}
+	defer file.Close()
+
+	for i, want := range state.PartMD5s {
+		if want == "" {
+			continue
+		}
+		start := int64(i) * d.partSize
+		length := min(d.partSize, size-start)
+		hasher := md5.New() //nolint:gosec // MD5 used for checksums, not cryptography
+		if n, err := io.Copy(hasher, io.NewSectionReader(file, start, length)); err != nil || n != length ||
+			hex.EncodeToString(hasher.Sum(nil)) != want {
+			d.logger.Debug(fmt.Sprintf("Part %d of %s failed verification, downloading it again", i+1, key))
+			state.PartMD5s[i] = ""
+		}
+	}
+	return &state
+}
+
+// downloadPartWithRetry fetches bytes [start, end] with exponential backoff
+func (d *rangeDownloader) downloadPartWithRetry(ctx context.Context, key, etag string, file *os.File, start, end int64) (string, error) {
+	delay := d.baseDelay
+	var lastErr error
+	for attempt := 0; attempt <= d.maxRetries; attempt++ {
+		if attempt > 0 {
+			d.logger.Warn(fmt.Sprintf("Retrying bytes %d-%d of %s (attempt %d/%d) after error: %v", start, end, key, attempt, d.maxRetries, lastErr))
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, downloadRetryMaxDelay)
+		}
+
+		sum, err := d.downloadPart(ctx, key, etag, file, start, end)
+		if err == nil {
+			return sum, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		lastErr = err
+	}
+	return "", fmt.Errorf("failed to download bytes %d-%d of %s after %d attempts: %w", start, end, key, d.maxRetries+1, lastErr)
+}
+
+// downloadPart fetches one byte range into file and returns its MD5
+func (d *rangeDownloader) downloadPart(ctx context.Context, key, etag string, file *os.File, start, end int64) (string, error) {
+	opts := GetOptions{Range: fmt.Sprintf("bytes=%d-%d", start, end)}
+	// Fail rather than mix bytes from two object versions
+	if etag != "" {
+		opts.IfMatch = `"` + etag + `"`
+	}
+
+	output, _, err := d.client.Get(ctx, d.bucket, key, opts)
+	if err != nil {
+		return "", err
+	}
+	defer output.Close()
+
+	hasher := md5.New() //nolint:gosec // MD5 used for checksums, not cryptography
+	length := end - start + 1
+	body := throttleDownload(ctx, io.LimitReader(output, length), d.rate)
+	written, err := io.Copy(io.MultiWriter(io.NewOffsetWriter(file, start), hasher), body)
+	if err != nil {
+		return "", err
+	}
+	if written != length {
+		return "", fmt.Errorf("%w: got %d of %d", ErrDownloadShortPart, written, length)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// etagIsChecksum reports whether an object's ETag is derived from its bytes.
+// The ETags of objects encrypted with SSE-KMS or SSE-C are not.
+func etagIsChecksum(head ObjectInfo) bool {
+	switch head.ServerSideEncryption {
+	case s3.ServerSideEncryptionAwsKms, s3.ServerSideEncryptionAwsKmsDsse:
+		return false
+	}
+	return head.SSECustomerAlgorithm == ""
+}
+
+// verifyDownloadETag checks the assembled file against the S3 ETag. Single-part
+// ETags are the object MD5. A multipart ETag is the MD5 of the part MD5s, so it
+// is recomputed for each part size the archiver and common tools upload with
+// that gives the same part count; when none does, the object relies on the
+// per-part checksums alone.
+func verifyDownloadETag(file *os.File, state *downloadState) error {
+	if state.ETag == "" {
+		return nil
+	}
+
+	if strings.Contains(state.ETag, "-") {
+		var parts int
+		if _, err := fmt.Sscanf(state.ETag[strings.LastIndex(state.ETag, "-")+1:], "%d", &parts); err != nil || parts < 1 {
+			return nil
+		}
+		candidates := multipartPartSizes(state.Size, parts)
+		for _, partSize := range candidates {
+			actual, err := multipartETag(file, state.Size, partSize)
+			if err != nil {
+				return fmt.Errorf("failed to checksum download: %w", err)
+			}
+			if actual == state.ETag {
+				return nil
+			}
+		}
+		if len(candidates) > 0 {
+			return fmt.Errorf("%w: expected %s", ErrDownloadChecksum, state.ETag)
+		}
+		return nil
+	}
+
+	var actual string
+	if len(state.PartMD5s) == 1 {
+		actual = state.PartMD5s[0]
+	} else {
+		hasher := md5.New() //nolint:gosec // MD5 used for checksums, not cryptography
+		if _, err := io.Copy(hasher, io.NewSectionReader(file, 0, state.Size)); err != nil {
+			return fmt.Errorf("failed to checksum download: %w", err)
+		}
+		actual = hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	if actual != state.ETag {
+		return fmt.Errorf("%w: expected %s, got %s", ErrDownloadChecksum, state.ETag, actual)
+	}
+	return nil
+}
+
+// multipartPartSizes returns the upload part sizes that split size bytes into
+// exactly parts parts: the archiver's own, then the AWS SDK and CLI defaults
+func multipartPartSizes(size int64, parts int) []int64 {
+	const mb = 1024 * 1024
+	var sizes []int64
+	for _, partSize := range []int64{uploadPartSize(size), minUploadPartSize, 8 * mb, 16 * mb} {
+		if partCount(size, partSize) != parts || slices.Contains(sizes, partSize) {
+			continue
+		}
+		sizes = append(sizes, partSize)
+	}
+	return sizes
+}
+
+// multipartETag computes the S3 multipart ETag of a file uploaded in partSize parts
+func multipartETag(file *os.File, size, partSize int64) (string, error) {
+	var sums []byte
+	parts := partCount(size, partSize)
+	for i := 0; i < parts; i++ {
+		start := int64(i) * partSize
+		hasher := md5.New() //nolint:gosec // MD5 used for checksums, not cryptography
+		if _, err := io.Copy(hasher, io.NewSectionReader(file, start, min(partSize, size-start))); err != nil {
+			return "", err
+		}
+		sums = hasher.Sum(sums)
+	}
+	sum := md5.Sum(sums) //nolint:gosec // MD5 used for checksums, not cryptography
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), parts), nil
+}
+
+func saveDownloadState(path string, state *downloadState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+func (d *rangeDownloader) discard(partPath, statePath string) {
+	_ = os.Remove(partPath)
+	_ = os.Remove(statePath)
+}
@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+func newTestRangeDownloader(t *testing.T, client s3iface.S3API, partSize int64, retries int) *rangeDownloader {
+	t.Helper()
+	d := newRangeDownloader(newS3ObjectStore(client), "bucket", t.TempDir(), 1, retries, newTestLogger())
+	d.partSize = partSize
+	d.baseDelay = 0
+	return d
+}
+
+func TestRangeDownloaderAssemblesParts(t *testing.T) {
+	data := []byte("0123456789abcdefghij-xyz")
+	client := newFakeObjectStore(map[string][]byte{"flights/2024/01/flights-2024-01-01.jsonl.zst": data})
+	d := newTestRangeDownloader(t, client, 5, 0)
+
+	path, size, err := d.Download(context.Background(), "flights/2024/01/flights-2024-01-01.jsonl.zst")
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	defer os.Remove(path)
+
+	got, _ := os.ReadFile(path)
+	if size != int64(len(data)) || !bytes.Equal(got, data) {
+		t.Fatalf("expected %q (%d bytes), got %q (%d bytes)", data, len(data), got, size)
+	}
+	if want := "bytes=0-4,bytes=5-9,bytes=10-14,bytes=15-19,bytes=20-23"; strings.Join(client.gets, ",") != want {
+		t.Errorf("unexpected ranges %v", client.gets)
+	}
+
+	partPath, statePath, _ := d.downloadPaths("flights/2024/01/flights-2024-01-01.jsonl.zst")
+	for _, leftover := range []string{partPath, statePath} {
+		if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be cleaned up", leftover)
+		}
+	}
+}
+
+func TestRangeDownloaderRetriesTransientErrors(t *testing.T) {
+	data := []byte("0123456789")
+	client := newFakeObjectStore(map[string][]byte{"key": data})
+	client.failGets[1] = true
+	client.truncated[3] = true
+	d := newTestRangeDownloader(t, client, 5, 2)
+
+	path, _, err := d.Download(context.Background(), "key")
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	defer os.Remove(path)
+
+	got, _ := os.ReadFile(path)
+	if !bytes.Equal(got, data) {
+		t.Fatalf("expected %q, got %q", data, got)
+	}
+	if len(client.gets) != 4 {
+		t.Errorf("expected 4 GETs (1 failed, 1 truncated), got %d: %v", len(client.gets), client.gets)
+	}
+}
+
+func TestRangeDownloaderResumesAfterFailure(t *testing.T) {
+	data := []byte("aaaaabbbbbcccccddddd")
+	client := newFakeObjectStore(map[string][]byte{"key": data})
+	client.failGets[3] = true
+	d := newTestRangeDownloader(t, client, 5, 0)
+
+	if _, _, err := d.Download(context.Background(), "key"); !errors.Is(err, errFakeNetwork) {
+		t.Fatalf("expected network error, got %v", err)
+	}
+
+	client.gets = nil
+	client.failGets = map[int]bool{}
+	path, _, err := d.Download(context.Background(), "key")
+	if err != nil {
+		t.Fatalf("resumed Download failed: %v", err)
+	}
+	defer os.Remove(path)
+
+	got, _ := os.ReadFile(path)
+	if !bytes.Equal(got, data) {
+		t.Fatalf("expected %q, got %q", data, got)
+	}
+	if want := "bytes=10-14,bytes=15-19"; strings.Join(client.gets, ",") != want {
+		t.Errorf("expected only the missing parts to be fetched, got %v", client.gets)
+	}
+}
+
+func TestRangeDownloaderRefetchesCorruptParts(t *testing.T) {
+	data := []byte("aaaaabbbbbcccccddddd")
+	client := newFakeObjectStore(map[string][]byte{"key": data})
+	client.failGets[4] = true
+	d := newTestRangeDownloader(t, client, 5, 0)
+
+	if _, _, err := d.Download(context.Background(), "key"); err == nil {
+		t.Fatal("expected first download to fail")
+	}
+
+	// Corrupt the second part on disk; it should be detected and downloaded again
+	partPath, _, _ := d.downloadPaths("key")
+	file, err := os.OpenFile(partPath, os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("failed to open partial file: %v", err)
+	}
+	_, _ = file.WriteAt([]byte("XXXXX"), 5)
+	file.Close()
+
+	client.gets = nil
+	client.failGets = map[int]bool{}
+	path, _, err := d.Download(context.Background(), "key")
+	if err != nil {
+		t.Fatalf("resumed Download failed: %v", err)
+	}
+	defer os.Remove(path)
+
+	got, _ := os.ReadFile(path)
+	if !bytes.Equal(got, data) {
+		t.Fatalf("expected %q, got %q", data, got)
+	}
+	if want := "bytes=5-9,bytes=15-19"; strings.Join(client.gets, ",") != want {
+		t.Errorf("expected corrupt and missing parts to be fetched, got %v", client.gets)
+	}
+}
+
+func TestRangeDownloaderDiscardsStateForChangedObject(t *testing.T) {
+	client := newFakeObjectStore(map[string][]byte{"key": []byte("aaaaabbbbbccccc")})
+	client.failGets[2] = true
+	d := newTestRangeDownloader(t, client, 5, 0)
+
+	if _, _, err := d.Download(context.Background(), "key"); err == nil {
+		t.Fatal("expected first download to fail")
+	}
+
+	// The object is rewritten with different content before the retry
+	client.objects["key"] = []byte("zzzzzyyyyyxxxxx")
+	client.gets = nil
+	client.failGets = map[int]bool{}
+	path, _, err := d.Download(context.Background(), "key")
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	defer os.Remove(path)
+
+	got, _ := os.ReadFile(path)
+	if string(got) != "zzzzzyyyyyxxxxx" {
+		t.Errorf("expected new object content, got %q", got)
+	}
+	if len(client.gets) != 3 {
+		t.Errorf("expected a full re-download, got %v", client.gets)
+	}
+}
+
+func TestRangeDownloaderChecksumMismatch(t *testing.T) {
+	client := newFakeObjectStore(map[string][]byte{"key": []byte("0123456789")})
+	client.etags = map[string]string{"key": "00000000000000000000000000000000"}
+	d := newTestRangeDownloader(t, client, 4, 0)
+
+	if _, _, err := d.Download(context.Background(), "key"); !errors.Is(err, ErrDownloadChecksum) {
+		t.Fatalf("expected ErrDownloadChecksum, got %v", err)
+	}
+
+	partPath, statePath, _ := d.downloadPaths("key")
+	if _, err := os.Stat(partPath); !os.IsNotExist(err) {
+		t.Error("expected corrupt partial file to be discarded")
+	}
+	if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+		t.Error("expected download state to be discarded")
+	}
+}
+
+func TestRangeDownloaderEncryptedObjects(t *testing.T) {
+	data := []byte("0123456789")
+	for _, tt := range []struct{ name, sse, sseC string }{
+		{"SSE-KMS", s3.ServerSideEncryptionAwsKms, ""},
+		{"SSE-C", "", "AES256"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// The ETag of an encrypted object is not its MD5
+			client := newFakeObjectStore(map[string][]byte{"key": data})
+			client.etags = map[string]string{"key": "5d41402abc4b2a76b9719d911017c592"}
+			client.sse, client.sseC = tt.sse, tt.sseC
+			d := newTestRangeDownloader(t, client, 4, 0)
+
+			path, _, err := d.Download(context.Background(), "key")
+			if err != nil {
+				t.Fatalf("Download() error = %v", err)
+			}
+			if got, _ := os.ReadFile(path); !bytes.Equal(got, data) || len(client.gets) != 3 {
+				t.Errorf("downloaded %q in %d GETs", got, len(client.gets))
+			}
+		})
+	}
+
+	// SSE-S3 ETags are still checked
+	client := newFakeObjectStore(map[string][]byte{"key": data})
+	client.etags = map[string]string{"key": "00000000000000000000000000000000"}
+	client.sse = s3.ServerSideEncryptionAes256
+	if _, _, err := newTestRangeDownloader(t, client, 4, 0).Download(context.Background(), "key"); !errors.Is(err, ErrDownloadCorrupt) {
+		t.Errorf("expected ErrDownloadCorrupt for SSE-S3, got %v", err)
+	}
+}
+
+func TestDownloadOptionsValidate(t *testing.T) {
+	if err := (downloadOptions{PartSizeMB: 16, Retries: 5}).validate(); err != nil {
+		t.Errorf("expected valid options, got %v", err)
+	}
+	if err := (downloadOptions{PartSizeMB: 0}).validate(); !errors.Is(err, ErrDownloadPartSizeInvalid) {
+		t.Errorf("expected ErrDownloadPartSizeInvalid, got %v", err)
+	}
+	if err := (downloadOptions{PartSizeMB: 1, Retries: -1}).validate(); !errors.Is(err, ErrDownloadRetriesInvalid) {
+		t.Errorf("expected ErrDownloadRetriesInvalid, got %v", err)
+	}
+}
+
+func TestRangeDownloaderRetriesCorruptDownloads(t *testing.T) {
+	data := []byte("0123456789")
+	client := newFakeObjectStore(map[string][]byte{"key": data})
+	client.truncated[1] = true
+	d := newTestRangeDownloader(t, client, 16, 0)
+
+	// A short body fails the part, not the checksum; corrupt bytes fail the checksum
+	if _, _, err := d.Download(context.Background(), "key"); errors.Is(err, ErrDownloadCorrupt) {
+		t.Fatalf("a transport error should not be reported as corruption: %v", err)
+	}
+
+	client.gets = nil
+	client.truncated = map[int]bool{}
+	client.etags = map[string]string{"key": "00000000000000000000000000000000"}
+	_, _, err := d.Download(context.Background(), "key")
+	if !errors.Is(err, ErrDownloadCorrupt) || !errors.Is(err, ErrDownloadChecksum) {
+		t.Fatalf("expected ErrDownloadCorrupt wrapping ErrDownloadChecksum, got %v", err)
+	}
+	if len(client.gets) != downloadChecksumAttempts {
+		t.Errorf("expected %d downloads, got %d", downloadChecksumAttempts, len(client.gets))
+	}
+}
+
+func TestVerifyDownloadETagMultipart(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), (minUploadPartSize*2+1024)/16)
+	path := filepath.Join(t.TempDir(), "object")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	size := int64(len(data))
+	etag, err := multipartETag(file, size, minUploadPartSize)
+	if err != nil {
+		t.Fatalf("multipartETag failed: %v", err)
+	}
+	if !strings.HasSuffix(etag, "-3") {
+		t.Fatalf("expected a 3-part ETag, got %s", etag)
+	}
+
+	if err := verifyDownloadETag(file, &downloadState{ETag: etag, Size: size}); err != nil {
+		t.Errorf("expected matching multipart ETag to verify, got %v", err)
+	}
+	wrong := "00000000000000000000000000000000-3"
+	if err := verifyDownloadETag(file, &downloadState{ETag: wrong, Size: size}); !errors.Is(err, ErrDownloadChecksum) {
+		t.Errorf("expected ErrDownloadChecksum, got %v", err)
+	}
+	// No known part size splits the file into 40 parts, so it cannot be checked
+	unknown := "00000000000000000000000000000000-40"
+	if err := verifyDownloadETag(file, &downloadState{ETag: unknown, Size: size}); err != nil {
+		t.Errorf("expected unverifiable ETag to pass, got %v", err)
+	}
+}
@@ -0,0 +1,300 @@
+package cmd
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // MD5 used for checksums, not cryptography
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const (
+	multipartUploadThreshold = 100 * 1024 * 1024 // Files above this size are uploaded in parts
+	minUploadPartSize        = 5 * 1024 * 1024   // S3 minimum, and the part size of most uploads
+	maxUploadParts           = 10000             // S3 limit on parts per upload
+)
+
+// uploadPartSize returns the default part size used for a file of the given
+// size: 5MB, or more for files too large for 10,000 5MB parts
+func uploadPartSize(size int64) int64 {
+	return partSizeFor(size, minUploadPartSize)
+}
+
+// partSizeFor returns the part size of an upload of size bytes with parts of
+// base bytes: base, or the smallest whole number of MB that keeps the upload
+// within 10,000 parts. Multipart ETags depend on it, so
+// calculateMultipartETagFromFile uses it too.
+func partSizeFor(size, base int64) int64 {
+	const mb = 1024 * 1024
+	partSize := base
+	if needed := (size + maxUploadParts - 1) / maxUploadParts; needed > partSize {
+		partSize = (needed + mb - 1) / mb * mb
+	}
+	return partSize
+}
+
+// uploadState is saved while a multipart upload is in progress so a run that
+// is interrupted can resume it instead of uploading the whole file again
+type uploadState struct {
+	Bucket   string         `json:"bucket"`
+	Key      string         `json:"key"`
+	UploadID string         `json:"upload_id"`
+	Size     int64          `json:"size"`
+	PartSize int64          `json:"part_size"`
+	Parts    []uploadedPart `json:"parts"` // Completed parts, in upload order
+}
+
+// uploadedPart is a part S3 has acknowledged
+type uploadedPart struct {
+	Number int64  `json:"number"`
+	ETag   string `json:"etag"`
+	MD5    string `json:"md5"` // Hex MD5 of the part's bytes
+}
+
+// matches reports whether a saved state is for the same object and part layout
+func (s *uploadState) matches(bucket, key string, size, partSize int64) bool {
+	return s.Bucket == bucket && s.Key == key && s.Size == size && s.PartSize == partSize && s.UploadID != ""
+}
+
+// getUploadStatePath returns where the state of an upload to bucket/key is kept.
+// The name is stable across runs so an interrupted upload can be found again.
+func getUploadStatePath(bucket, key string) string {
+	sum := sha256.Sum256([]byte(bucket + "/" + key))
+	name := hex.EncodeToString(sum[:8]) + "-" + sanitizeCacheComponent(filepath.Base(key), "object") + ".json"
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".data-archiver", "uploads", name)
+}
+
+// resumableUploader uploads large files as S3 multipart uploads, saving the
+// upload ID and the ETag of each completed part. When an upload of the same
+// key and size was interrupted, parts whose bytes are unchanged are kept and
+// only the rest are sent.
+type resumableUploader struct {
+	client    StorageBackend
+	bucket    string
+	bandwidth *bandwidthLimiter
+	logger    *slog.Logger
+	partSize  func(size int64) int64
+	parallel  int        // Parts uploaded at once
+	options   PutOptions // Content headers, metadata, and tags of the uploaded object
+}
+
+func newResumableUploader(client StorageBackend, bucket string, bandwidth *bandwidthLimiter, logger *slog.Logger) *resumableUploader {
+	return &resumableUploader{
+		client:    client,
+		bucket:    bucket,
+		bandwidth: bandwidth,
+		logger:    logger,
+		partSize:  uploadPartSize,
+		parallel:  1,
+		options:   PutOptions{ContentType: "application/octet-stream", Metadata: runIDMetadata()},
+	}
+}
+
+// Upload sends the file at path to key and removes the saved state once the
+// upload is complete. On failure the state is kept for the next attempt.
+func (u *resumableUploader) Upload(ctx context.Context, path, key string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open temp file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat temp file: %w", err)
+	}
+	size := info.Size()
+	partSize := u.partSize(size)
+	statePath := getUploadStatePath(u.bucket, key)
+
+	state, done, err := u.resume(ctx, statePath, file, key, size, partSize)
+	if err != nil {
+		return err
+	}
+
+	if err := u.uploadParts(ctx, file, key, statePath, state, done, size, partSize); err != nil {
+		return err
+	}
+
+	completed := make([]CompletedPart, 0, len(done))
+	for _, part := range done {
+		completed = append(completed, CompletedPart{Number: part.Number, ETag: part.ETag})
+	}
+	sort.Slice(completed, func(i, j int) bool { return completed[i].Number < completed[j].Number })
+	if _, err := u.client.CompleteMultipartUpload(ctx, u.bucket, key, state.UploadID, completed); err != nil {
+		return fmt.Errorf("failed to complete upload of %s: %w", key, err)
+	}
+	_ = os.Remove(statePath)
+	return nil
+}
+
+// uploadParts sends the parts not yet done, u.parallel at a time, saving the
+// state after each one. The first failure stops parts not yet started.
+func (u *resumableUploader) uploadParts(ctx context.Context, file *os.File, key, statePath string, state *uploadState, done map[int64]uploadedPart, size, partSize int64) error {
+	var pending []int64
+	for i := 0; i < partCount(size, partSize); i++ {
+		if _, ok := done[int64(i+1)]; !ok {
+			pending = append(pending, int64(i+1))
+		}
+	}
+
+	work := make(chan int64)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed error
+	for i := 0; i < min(max(u.parallel, 1), len(pending)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for number := range work {
+				start := (number - 1) * partSize
+				part, err := u.uploadPart(ctx, file, key, state.UploadID, number, start, min(partSize, size-start))
+
+				mu.Lock()
+				if err != nil {
+					if failed == nil {
+						failed = fmt.Errorf("failed to upload part %d of %s: %w", number, key, err)
+					}
+				} else {
+					state.Parts = append(state.Parts, part)
+					done[number] = part
+					if err := saveUploadState(statePath, state); err != nil {
+						u.logger.Debug(fmt.Sprintf("Failed to save upload state for %s: %v", key, err))
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, number := range pending {
+		mu.Lock()
+		stop := failed != nil
+		mu.Unlock()
+		if stop {
+			break
+		}
+		work <- number
+	}
+	close(work)
+	wg.Wait()
+	return failed
+}
+
+// resume returns the upload to continue and the parts that can be kept. A
+// saved upload is continued when it is still open in S3 and is for the same
+// size and part layout; each saved part is kept when S3 still lists it with the
+// same ETag and the bytes at its offset in the new file have the same MD5.
+// Anything else starts a new upload, aborting the stale one.
+func (u *resumableUploader) resume(ctx context.Context, statePath string, file *os.File, key string, size, partSize int64) (*uploadState, map[int64]uploadedPart, error) {
+	done := make(map[int64]uploadedPart)
+
+	var saved uploadState
+	if data, err := os.ReadFile(statePath); err == nil && json.Unmarshal(data, &saved) == nil && saved.UploadID != "" {
+		if saved.matches(u.bucket, key, size, partSize) {
+			listed, err := u.listParts(ctx, key, saved.UploadID)
+			if err == nil {
+				state := &saved
+				savedParts := saved.Parts
+				state.Parts = nil
+				for _, part := range savedParts {
+					if listed[part.Number] != part.ETag || part.Number < 1 || part.Number > int64(partCount(size, partSize)) {
+						continue
+					}
+					start := (part.Number - 1) * partSize
+					if sum, err := sectionMD5(file, start, min(partSize, size-start)); err != nil || sum != part.MD5 {
+						continue
+					}
+					state.Parts = append(state.Parts, part)
+					done[part.Number] = part
+				}
+				if len(done) > 0 {
+					u.logger.Info(fmt.Sprintf("Resuming upload of %s (%d/%d parts already uploaded)", key, len(done), partCount(size, partSize)))
+				}
+				return state, done, nil
+			}
+			if !errors.Is(err, ErrUploadNotFound) {
+				return nil, nil, fmt.Errorf("failed to list parts of interrupted upload of %s: %w", key, err)
+			}
+		}
+		u.logger.Debug(fmt.Sprintf("Discarding interrupted upload of %s", key))
+		_ = u.client.AbortMultipartUpload(ctx, u.bucket, saved.Key, saved.UploadID)
+	}
+
+	uploadID, err := u.client.CreateMultipartUpload(ctx, u.bucket, key, u.options)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start upload of %s: %w", key, err)
+	}
+	state := &uploadState{
+		Bucket:   u.bucket,
+		Key:      key,
+		UploadID: uploadID,
+		Size:     size,
+		PartSize: partSize,
+	}
+	if err := os.MkdirAll(filepath.Dir(statePath), 0o755); err != nil {
+		u.logger.Debug(fmt.Sprintf("Failed to create upload state directory: %v", err))
+	} else if err := saveUploadState(statePath, state); err != nil {
+		u.logger.Debug(fmt.Sprintf("Failed to save upload state for %s: %v", key, err))
+	}
+	return state, done, nil
+}
+
+// uploadPart sends bytes [start, start+length) as one part
+func (u *resumableUploader) uploadPart(ctx context.Context, file *os.File, key, uploadID string, number, start, length int64) (uploadedPart, error) {
+	sum, err := sectionMD5(file, start, length)
+	if err != nil {
+		return uploadedPart{}, err
+	}
+	etag, err := u.client.UploadPart(ctx, u.bucket, key, uploadID, number, throttleUpload(ctx, io.NewSectionReader(file, start, length), u.bandwidth))
+	if err != nil {
+		return uploadedPart{}, err
+	}
+	return uploadedPart{Number: number, ETag: etag, MD5: sum}, nil
+}
+
+// listParts returns the ETag of each part S3 holds for an open upload
+func (u *resumableUploader) listParts(ctx context.Context, key, uploadID string) (map[int64]string, error) {
+	listed, err := u.client.ListParts(ctx, u.bucket, key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	parts := make(map[int64]string, len(listed))
+	for _, part := range listed {
+		parts[part.Number] = part.ETag
+	}
+	return parts, nil
+}
+
+// sectionMD5 returns the hex MD5 of length bytes of file starting at start
+func sectionMD5(file *os.File, start, length int64) (string, error) {
+	hasher := md5.New() //nolint:gosec // MD5 used for checksums, not cryptography
+	n, err := io.Copy(hasher, io.NewSectionReader(file, start, length))
+	if err != nil {
+		return "", fmt.Errorf("failed to read temp file: %w", err)
+	}
+	if n != length {
+		return "", fmt.Errorf("failed to read temp file: %w", io.ErrUnexpectedEOF)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func saveUploadState(path string, state *uploadState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
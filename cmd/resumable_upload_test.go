@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResumableUpload(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx := context.Background()
+	store := newFakeObjectStore(nil)
+	uploader := newResumableUploader(newS3ObjectStore(store), "archive", nil, newTestLogger())
+	uploader.partSize = func(int64) int64 { return 4 }
+	key := "events/2024/01/events-2024-01-01.jsonl.zst"
+	path := filepath.Join(t.TempDir(), "upload.tmp")
+	writeFile := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The third part fails; the first two are saved
+	writeFile("aaaabbbbcc")
+	store.failPart = 3
+	if err := uploader.Upload(ctx, path, key); err == nil {
+		t.Fatal("expected the upload to fail")
+	}
+	if _, err := os.Stat(getUploadStatePath("archive", key)); err != nil {
+		t.Fatalf("expected saved upload state: %v", err)
+	}
+
+	// The next run re-extracts a file whose first part differs: only the
+	// unchanged second part is kept
+	writeFile("AAAAbbbbcc")
+	store.failPart = 0
+	store.sent = nil
+	if err := uploader.Upload(ctx, path, key); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if fmt.Sprint(store.sent) != "[1 3]" {
+		t.Errorf("expected parts 1 and 3 sent again, got %v", store.sent)
+	}
+	if !bytes.Equal(store.objects[key], []byte("AAAAbbbbcc")) || store.nextID != 1 {
+		t.Errorf("expected one upload assembling the new file, got %q after %d uploads", store.objects[key], store.nextID)
+	}
+	if _, err := os.Stat(getUploadStatePath("archive", key)); !os.IsNotExist(err) {
+		t.Errorf("expected the upload state removed, got %v", err)
+	}
+
+	// An upload S3 no longer knows, or one for a different size, starts over
+	store.failPart = 2
+	_ = uploader.Upload(ctx, path, key)
+	for id := range store.uploads {
+		delete(store.uploads, id)
+	}
+	store.failPart = 0
+	store.sent = nil
+	if err := uploader.Upload(ctx, path, key); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if fmt.Sprint(store.sent) != "[1 2 3]" || store.nextID != 3 {
+		t.Errorf("expected a new upload of every part, got %v after %d uploads", store.sent, store.nextID)
+	}
+
+	store.failPart = 3
+	_ = uploader.Upload(ctx, path, key)
+	writeFile("aaaabbbbccc")
+	store.failPart = 0
+	if err := uploader.Upload(ctx, path, key); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if len(store.uploads) != 0 {
+		t.Errorf("expected the stale upload aborted, %d still open", len(store.uploads))
+	}
+}
+
+func TestUploadPartSize(t *testing.T) {
+	const mb = 1024 * 1024
+	tests := []struct {
+		size int64
+		want int64
+	}{
+		{200 * mb, 5 * mb},
+		{50000 * mb, 5 * mb},
+		{60 * 1024 * mb, 7 * mb},
+	}
+	for _, tt := range tests {
+		got := uploadPartSize(tt.size)
+		if got != tt.want || partCount(tt.size, got) > maxUploadParts {
+			t.Errorf("uploadPartSize(%d) = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,516 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Retention actions
+const (
+	RetentionActionDelete     = "delete"
+	RetentionActionTransition = "transition"
+)
+
+// Retention statuses
+const (
+	RetentionStatusDeleted      = "deleted"
+	RetentionStatusTransitioned = "transitioned"
+	RetentionStatusPlanned      = "planned"
+	RetentionStatusInClass      = "already-in-class"
+	RetentionStatusError        = "error"
+)
+
+// maxCopyObjectSize is the largest object a single CopyObject can transition
+const maxCopyObjectSize = 5 * 1024 * 1024 * 1024
+
+// Static errors for retention
+var (
+	ErrRetentionAgeRequired     = errors.New("retention needs exactly one of --older-than-days or --older-than-months (at least 1)")
+	ErrRetentionActionInvalid   = errors.New("retention action must be one of: delete, transition")
+	ErrRetentionStorageClass    = errors.New("--storage-class is required for the transition action")
+	ErrRetentionObjectTooLarge  = errors.New("object is larger than 5GiB and cannot be transitioned with a single copy")
+	ErrRetentionFailed          = errors.New("retention left expired objects in place")
+	ErrRetentionTemplateInvalid = errors.New("path template must contain {table}")
+)
+
+var (
+	retentionTable        string
+	retentionDays         int
+	retentionMonths       int
+	retentionAction       string
+	retentionStorageClass string
+	retentionConfirm      bool
+)
+
+var retentionCmd = &cobra.Command{
+	Use:   "retention",
+	Short: "Delete or transition archived objects older than a retention window",
+	Long: `Apply a retention policy to the archived objects of a table. Objects under the path template whose
+file name carries the period they hold (as written by archive, including split-archive parts and manifests)
+are expired once that whole period is older than --older-than-days or --older-than-months. Expired objects
+are deleted, or copied onto themselves with a new --storage-class when --action transition is used.
+With --soft-delete-days, deleted objects are moved to the trash, where undelete can recover them. When
+the table has an integrity ledger, each deletion is recorded in it.
+Nothing is changed without --confirm; with --dry-run or without --confirm, the expired objects are listed.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		runRetention(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(retentionCmd)
+
+	// S3 flags
+	retentionCmd.Flags().StringVar(&s3Endpoint, "s3-endpoint", "", "S3-compatible endpoint URL")
+	retentionCmd.Flags().StringVar(&s3Bucket, "s3-bucket", "", "S3 bucket name")
+	retentionCmd.Flags().StringVar(&s3AccessKey, "s3-access-key", "", "S3 access key")
+	retentionCmd.Flags().StringVar(&s3SecretKey, "s3-secret-key", "", "S3 secret key")
+	retentionCmd.Flags().StringVar(&s3Region, "s3-region", "auto", "S3 region")
+
+	// Retention-specific flags
+	retentionCmd.Flags().StringVar(&retentionTable, "table", "", "base table name (required)")
+	retentionCmd.Flags().StringVar(&pathTemplate, "path-template", "", "S3 path template used when archiving (required)")
+	retentionCmd.Flags().IntVar(&retentionDays, "older-than-days", 0, "expire objects holding data older than N days")
+	retentionCmd.Flags().IntVar(&retentionMonths, "older-than-months", 0, "expire objects holding data older than N months")
+	retentionCmd.Flags().StringVar(&retentionAction, "action", RetentionActionDelete, "what to do with an expired object: delete or transition")
+	retentionCmd.Flags().StringVar(&retentionStorageClass, "storage-class", s3.StorageClassGlacier, "storage class expired objects are transitioned to")
+	retentionCmd.Flags().BoolVar(&retentionConfirm, "confirm", false, "actually delete or transition objects (without it, only the plan is printed)")
+	retentionCmd.Flags().IntVar(&softDeleteDays, "soft-delete-days", 0, "move deleted objects to a trash prefix and remove them permanently after this many days (0 = delete immediately)")
+	retentionCmd.Flags().StringVar(&trashPrefix, "trash-prefix", defaultTrashPrefix, "bucket prefix for soft-deleted archive files")
+	retentionCmd.Flags().StringVar(&integrityPrefix, "integrity-prefix", defaultIntegrityPrefix, "bucket prefix for integrity ledgers")
+	retentionCmd.Flags().StringVar(&integrityKeyFile, "integrity-key-file", "", "file holding the secret integrity ledger entries are signed with (empty = unsigned)")
+
+	_ = viper.BindPFlag("retention.older_than_days", retentionCmd.Flags().Lookup("older-than-days"))
+	_ = viper.BindPFlag("retention.older_than_months", retentionCmd.Flags().Lookup("older-than-months"))
+	_ = viper.BindPFlag("retention.action", retentionCmd.Flags().Lookup("action"))
+	_ = viper.BindPFlag("retention.storage_class", retentionCmd.Flags().Lookup("storage-class"))
+	_ = viper.BindPFlag("retention.confirm", retentionCmd.Flags().Lookup("confirm"))
+}
+
+// RetentionPolicy selects and handles expired archive objects
+type RetentionPolicy struct {
+	Table        string
+	PathTemplate string
+	Days         int
+	Months       int
+	Action       string
+	StorageClass string
+	Execute      bool // Delete or transition; otherwise only plan
+
+	SoftDeleteDays   int    // Days deleted objects stay in the trash (0 = delete immediately)
+	TrashPrefix      string // Bucket prefix for soft-deleted objects
+	IntegrityKeyFile string // Secret for signing integrity ledger entries ("" = unsigned)
+}
+
+// cutoff returns the time before which an object's whole period must end
+func (p RetentionPolicy) cutoff(now time.Time) time.Time {
+	return now.UTC().Truncate(24*time.Hour).AddDate(0, -p.Months, -p.Days)
+}
+
+// RetentionResult is the outcome for one expired object
+type RetentionResult struct {
+	Key          string
+	Size         int64
+	PeriodEnd    time.Time
+	StorageClass string
+	Status       string
+	Message      string
+}
+
+// archiveObjectMatcher recognizes a table's archive objects under a path template
+type archiveObjectMatcher struct {
+	prefix string
+	dir    *regexp.Regexp
+	file   *regexp.Regexp
+}
+
+// newArchiveObjectMatcher builds a matcher for the keys archive writes for
+// table: {path template}/{table}-{period}[-part-NNNN]{extensions}, and for
+// --split-by-column files, {path template}/{column}={value}/{table}-{period}[-NNNN]{extensions}
+func newArchiveObjectMatcher(template, table string) (*archiveObjectMatcher, error) {
+	if !strings.Contains(template, "{table}") {
+		return nil, ErrRetentionTemplateInvalid
+	}
+	component := objectKeyComponent(table)
+	template = strings.Trim(template, "/")
+
+	dir := regexp.QuoteMeta(template)
+	dir = strings.ReplaceAll(dir, regexp.QuoteMeta("{table}"), regexp.QuoteMeta(component))
+	dir = templateDatePattern(dir)
+
+	// List from the static part of the template, up to its first placeholder
+	prefix := strings.ReplaceAll(template, "{table}", component)
+	if i := strings.Index(prefix, "{"); i >= 0 {
+		prefix = prefix[:i]
+	} else {
+		prefix += "/"
+	}
+
+	return &archiveObjectMatcher{
+		prefix: prefix,
+		dir:    regexp.MustCompile(`^` + dir + `(?:/[^/=]+=[^/]+)?$`),
+		file: regexp.MustCompile(`^` + regexp.QuoteMeta(component) +
+			`-(\d{4})(?:-W(\d{2})|-Q([1-4])|-(\d{2})(?:-(\d{2})(?:-(\d{2}))?)?)?(?:-part-\d+|-\d{4,})?\.`),
+	}, nil
+}
+
+// periodEnd returns the end of the period an archive object holds, or false
+// when key is not one of the table's archive objects
+func (m *archiveObjectMatcher) periodEnd(key string) (time.Time, bool) {
+	_, end, ok := m.period(key)
+	return end, ok
+}
+
+// period returns the start and end of the period an archive object holds, or
+// false when key is not one of the table's archive objects
+func (m *archiveObjectMatcher) period(key string) (time.Time, time.Time, bool) {
+	dir, file := path.Split(key)
+	if !m.dir.MatchString(strings.TrimSuffix(dir, "/")) {
+		return time.Time{}, time.Time{}, false
+	}
+	match := m.file.FindStringSubmatch(file)
+	if match == nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	atoi := func(s string) int {
+		n := 0
+		for _, c := range s {
+			n = n*10 + int(c-'0')
+		}
+		return n
+	}
+	year, week, quarter, month, day, hour := atoi(match[1]), match[2], match[3], match[4], match[5], match[6]
+	switch {
+	case week != "":
+		monday := isoWeekStart(year, atoi(week), time.UTC)
+		return monday, monday.AddDate(0, 0, 7), true
+	case quarter != "":
+		start := time.Date(year, time.Month((atoi(quarter)-1)*3+1), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 3, 0), true
+	case month == "":
+		start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(1, 0, 0), true
+	case day == "":
+		start := time.Date(year, time.Month(atoi(month)), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0), true
+	case hour == "":
+		start := time.Date(year, time.Month(atoi(month)), atoi(day), 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 1), true
+	default:
+		start := time.Date(year, time.Month(atoi(month)), atoi(day), atoi(hour), 0, 0, 0, time.UTC)
+		return start, start.Add(time.Hour), true
+	}
+}
+
+// applyRetention lists the table's archive objects and deletes or
+// transitions those whose period ended before the policy's cutoff. Deletions
+// are recorded in ledger when the table has one.
+func applyRetention(ctx context.Context, client StorageBackend, bucket string, policy RetentionPolicy, ledger *integrityLedger, now time.Time) ([]RetentionResult, error) {
+	matcher, err := newArchiveObjectMatcher(policy.PathTemplate, policy.Table)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := policy.cutoff(now)
+
+	var expired []RetentionResult
+	err = client.List(ctx, bucket, matcher.prefix, func(object ObjectInfo) bool {
+		end, ok := matcher.periodEnd(object.Key)
+		if !ok || end.After(cutoff) {
+			return true
+		}
+		storageClass := object.StorageClass
+		if storageClass == "" {
+			storageClass = s3.StorageClassStandard
+		}
+		expired = append(expired, RetentionResult{
+			Key:          object.Key,
+			Size:         object.Size,
+			PeriodEnd:    end,
+			StorageClass: storageClass,
+		})
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archived objects: %w", err)
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].Key < expired[j].Key })
+
+	for i := range expired {
+		if err := ctx.Err(); err != nil {
+			return expired[:i], err
+		}
+		result := &expired[i]
+		switch {
+		case policy.Action == RetentionActionTransition && result.StorageClass == policy.StorageClass:
+			result.Status = RetentionStatusInClass
+			result.Message = "already in " + policy.StorageClass
+		case !policy.Execute:
+			result.Status = RetentionStatusPlanned
+			result.Message = "would " + policy.Action
+			if policy.Action == RetentionActionTransition {
+				result.Message += " to " + policy.StorageClass
+			}
+		case policy.Action == RetentionActionTransition:
+			if err := transitionObject(ctx, client, bucket, *result, policy.StorageClass); err != nil {
+				result.Status = RetentionStatusError
+				result.Message = err.Error()
+				continue
+			}
+			result.Status = RetentionStatusTransitioned
+			result.Message = "transitioned to " + policy.StorageClass
+		default:
+			trashed, err := removeArchiveObject(ctx, client, bucket, policy.TrashPrefix, policy.Table, result.Key, policy.SoftDeleteDays, now)
+			if err != nil {
+				result.Status = RetentionStatusError
+				result.Message = fmt.Sprintf("failed to delete: %v", err)
+				continue
+			}
+			result.Status = RetentionStatusDeleted
+			result.Message = "deleted"
+			if trashed != "" {
+				result.Message = "moved to " + trashed
+			}
+			if ledger != nil {
+				entry := integrityEntry{Event: IntegrityEventDeleted, Key: result.Key}
+				if err := ledger.append(ctx, client, policy.IntegrityKeyFile, entry); err != nil {
+					result.Message += fmt.Sprintf(" (integrity ledger not updated: %v)", err)
+				}
+			}
+		}
+	}
+	return expired, nil
+}
+
+// transitionObject copies an object onto itself with a new storage class,
+// keeping its metadata
+func transitionObject(ctx context.Context, client StorageBackend, bucket string, object RetentionResult, storageClass string) error {
+	if object.Size > maxCopyObjectSize {
+		return ErrRetentionObjectTooLarge
+	}
+	if err := client.Copy(ctx, bucket, object.Key, object.Key, storageClass); err != nil {
+		return fmt.Errorf("failed to transition: %w", err)
+	}
+	return nil
+}
+
+func runRetention(cmd *cobra.Command) {
+	getStringConfig := func(flagValue string, flagName string, viperKey string) string {
+		if flag := cmd.Flags().Lookup(flagName); flag != nil && flag.Changed {
+			return flagValue
+		}
+		if viperValue := viper.GetString(viperKey); viperValue != "" {
+			return viperValue
+		}
+		return flagValue
+	}
+
+	s3Config, s3Err := newS3Config(S3Config{
+		Endpoint:     getStringConfig(s3Endpoint, "s3-endpoint", "s3.endpoint"),
+		Bucket:       getStringConfig(s3Bucket, "s3-bucket", "s3.bucket"),
+		AccessKey:    getStringConfig(s3AccessKey, "s3-access-key", "s3.access_key"),
+		SecretKey:    getStringConfig(s3SecretKey, "s3-secret-key", "s3.secret_key"),
+		Region:       getStringConfig(s3Region, "s3-region", "s3.region"),
+		PathTemplate: getStringConfig(pathTemplate, "path-template", "s3.path_template"),
+	}, endpointProfileName("retention"))
+	policy := RetentionPolicy{
+		Table:        getStringConfig(retentionTable, "table", "table"),
+		PathTemplate: s3Config.PathTemplate,
+		Days:         viper.GetInt("retention.older_than_days"),
+		Months:       viper.GetInt("retention.older_than_months"),
+		Action:       viper.GetString("retention.action"),
+		StorageClass: viper.GetString("retention.storage_class"),
+		Execute:      viper.GetBool("retention.confirm") && !viper.GetBool("dry_run"),
+
+		SoftDeleteDays:   viper.GetInt("soft_delete.days"),
+		TrashPrefix:      getStringConfig(trashPrefix, "trash-prefix", "soft_delete.prefix"),
+		IntegrityKeyFile: getStringConfig(integrityKeyFile, "integrity-key-file", "integrity.key_file"),
+	}
+	if flag := cmd.Flags().Lookup("soft-delete-days"); flag.Changed {
+		policy.SoftDeleteDays = softDeleteDays
+	}
+	ledgerPrefix := getStringConfig(integrityPrefix, "integrity-prefix", "integrity.prefix")
+
+	initLogger(viper.GetBool("debug"), viper.GetString("log_format"))
+
+	logger.Info("")
+	logger.Info(fmt.Sprintf("🗄️  Archive Retention v%s", Version))
+	logger.Info("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+	if s3Err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", s3Err.Error()))
+		os.Exit(1)
+	}
+	if err := validateRetentionConfig(s3Config, policy); err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+		os.Exit(1)
+	}
+	if !policy.Execute {
+		logger.Info("Dry run: no object will be changed (pass --confirm to apply the policy)")
+	}
+
+	ctx := signalContext
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	client, err := newStorageClient(s3Config)
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ Failed to create storage client: %v", err))
+		os.Exit(1)
+	}
+
+	var ledger *integrityLedger
+	if policy.Execute && policy.Action == RetentionActionDelete {
+		ledger, err = openExistingIntegrityLedger(ctx, client, s3Config.Bucket, ledgerPrefix, policy.Table, policy.IntegrityKeyFile)
+		if err != nil {
+			logger.Error(fmt.Sprintf("❌ %v", err))
+			os.Exit(1)
+		}
+	}
+
+	now := time.Now()
+	logger.Info(fmt.Sprintf("Expiring objects holding data older than %s...", policy.cutoff(now).Format("2006-01-02")))
+	results, err := applyRetention(ctx, client, s3Config.Bucket, policy, ledger, now)
+	for _, result := range results {
+		switch result.Status {
+		case RetentionStatusError:
+			logger.Warn(fmt.Sprintf("  ❌ %s: %s", result.Key, result.Message))
+		case RetentionStatusInClass:
+			logger.Debug(fmt.Sprintf("  ⏭️  %s: %s", result.Key, result.Message))
+		default:
+			logger.Info(fmt.Sprintf("  🗄️  %s (%s): %s", result.Key, formatBytesForSummary(result.Size), result.Message))
+		}
+	}
+	if ledger != nil {
+		// Copy the entries even if the run was cancelled
+		flushCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		if flushErr := ledger.flush(flushCtx, client); flushErr != nil {
+			logger.Warn(fmt.Sprintf("⚠️  Integrity ledger not copied to S3 (kept in %s): %v", ledger.path, flushErr))
+		}
+		cancel()
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			logger.Info("")
+			logger.Info("⚠️  Retention cancelled by user")
+			os.Exit(130)
+		}
+		logger.Error(fmt.Sprintf("❌ %v", err))
+		os.Exit(1)
+	}
+
+	if err := printRetentionSummary(results, policy); err != nil {
+		logger.Error(fmt.Sprintf("❌ %s", err.Error()))
+		os.Exit(1)
+	}
+
+	if policy.Execute && policy.SoftDeleteDays > 0 {
+		purged, err := purgeTrash(ctx, client, s3Config.Bucket, policy.TrashPrefix, policy.Table, policy.SoftDeleteDays, now)
+		if err != nil {
+			logger.Warn(fmt.Sprintf("⚠️  Trash not purged: %v", err))
+		}
+		if purged > 0 {
+			logger.Info(fmt.Sprintf("🗑️  Permanently deleted %d file(s) soft-deleted more than %d days ago", purged, policy.SoftDeleteDays))
+		}
+	}
+
+	logger.Info("")
+	logger.Info("✅ Retention completed successfully!")
+}
+
+// validateRetentionConfig checks the S3 settings and the policy
+func validateRetentionConfig(s3Config S3Config, policy RetentionPolicy) error {
+	if s3Config.Bucket == "" {
+		return ErrS3BucketRequired
+	}
+	if err := s3Config.validateCredentials(); err != nil {
+		return err
+	}
+	if policy.Table == "" {
+		return ErrTableNameRequired
+	}
+	if policy.PathTemplate == "" {
+		return ErrPathTemplateRequired
+	}
+	if !strings.Contains(policy.PathTemplate, "{table}") {
+		return ErrRetentionTemplateInvalid
+	}
+	if policy.Days < 0 || policy.Months < 0 || (policy.Days > 0) == (policy.Months > 0) {
+		return ErrRetentionAgeRequired
+	}
+	if policy.SoftDeleteDays < 0 {
+		return fmt.Errorf("%w, got %d", ErrSoftDeleteDaysInvalid, policy.SoftDeleteDays)
+	}
+	if policy.SoftDeleteDays > 0 && strings.Trim(policy.TrashPrefix, "/") == "" {
+		return ErrTrashPrefixRequired
+	}
+	switch policy.Action {
+	case RetentionActionDelete:
+	case RetentionActionTransition:
+		if policy.StorageClass == "" {
+			return ErrRetentionStorageClass
+		}
+	default:
+		return fmt.Errorf("%w, got '%s'", ErrRetentionActionInvalid, policy.Action)
+	}
+	return s3Config.HTTP.Validate()
+}
+
+// printRetentionSummary logs per-status totals and the bytes reclaimed, and
+// returns an error if any expired object could not be handled
+func printRetentionSummary(results []RetentionResult, policy RetentionPolicy) error {
+	counts := make(map[string]int)
+	var bytes int64
+	failed := 0
+	for _, result := range results {
+		counts[result.Status]++
+		switch result.Status {
+		case RetentionStatusError:
+			failed++
+		case RetentionStatusDeleted, RetentionStatusTransitioned, RetentionStatusPlanned:
+			bytes += result.Size
+		}
+	}
+
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	logger.Info("")
+	logger.Info("📊 Retention Summary")
+	logger.Info("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	logger.Info(fmt.Sprintf("  Expired objects:    %d", len(results)))
+	for _, status := range statuses {
+		logger.Info(fmt.Sprintf("  %-19s %d", status+":", counts[status]))
+	}
+	label := "Bytes reclaimed:   "
+	switch {
+	case !policy.Execute && policy.Action == RetentionActionTransition:
+		label = "Bytes to move:     "
+	case !policy.Execute:
+		label = "Bytes to reclaim:  "
+	case policy.Action == RetentionActionTransition:
+		label = "Bytes moved:       "
+	}
+	logger.Info(fmt.Sprintf("  %s %s", label, formatBytesForSummary(bytes)))
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d object(s)", ErrRetentionFailed, failed, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestArchiveObjectMatcherPeriodEnd(t *testing.T) {
+	matcher, err := newArchiveObjectMatcher("archives/{table}/{YYYY}/{MM}/", "events")
+	if err != nil {
+		t.Fatalf("newArchiveObjectMatcher() error = %v", err)
+	}
+	if matcher.prefix != "archives/events/" {
+		t.Errorf("prefix = %q, want archives/events/", matcher.prefix)
+	}
+
+	tests := []struct {
+		key  string
+		want time.Time
+		ok   bool
+	}{
+		{"archives/events/2024/01/events-2024-01-05.jsonl.zst", time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC), true},
+		{"archives/events/2024/01/events-2024-01-31-23.csv", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), true},
+		{"archives/events/2024/01/events-2024-01.parquet", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), true},
+		{"archives/events/2024/12/events-2024-12.parquet", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{"archives/events/2024/01/events-2024.jsonl", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{"archives/events/2024/01/events-2024-W01.jsonl", time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), true},
+		{"archives/events/2024/10/events-2024-Q4.jsonl.gz", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{"archives/events/2024/01/events-2024-01-05-part-0002.jsonl.zst", time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC), true},
+		{"archives/events/2024/01/events-2024-01-05" + manifestSuffix, time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC), true},
+		{"archives/events/2024/01/tenant_id=42/events-2024-01-05.jsonl.zst", time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC), true},
+		{"archives/events/2024/01/tenant_id=42/events-2024-01-05-0002.jsonl.zst", time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC), true},
+		{"archives/events/2024/01/region=eu%2Fwest/events-2024-01.parquet", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), true},
+		{"archives/events/2024/01/tenant_id=42/x=1/events-2024-01-05.jsonl", time.Time{}, false}, // One split level only
+		{"archives/events/2024/01/tenant/events-2024-01-05.jsonl", time.Time{}, false},           // Not a split directory
+		{"archives/events/2024/01/events_log-2024-01-05.jsonl", time.Time{}, false},              // Another table
+		{"archives/events/2024/events-2024-01-05.jsonl", time.Time{}, false},                     // Outside the template
+		{"archives/events/2024/01/notes.txt", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := matcher.periodEnd(tt.key)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("periodEnd(%q) = %v, %v; want %v, %v", tt.key, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	if _, err := newArchiveObjectMatcher("archives/{YYYY}", "events"); !errors.Is(err, ErrRetentionTemplateInvalid) {
+		t.Errorf("expected ErrRetentionTemplateInvalid, got %v", err)
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	ctx := context.Background()
+	newStore := func() *fakeObjectStore {
+		return &fakeObjectStore{objects: map[string][]byte{
+			"events/2024/01/events-2024-01-30.jsonl.zst": []byte("old"),
+			"events/2024/01/events-2024-01-31.jsonl.zst": []byte("older than cutoff"),
+			"events/2024/02/events-2024-02-01.jsonl.zst": []byte("recent"),
+			"events/2024/01/notes.txt":                   []byte("not an archive"),
+		}}
+	}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) // Cutoff 2024-02-01
+	policy := RetentionPolicy{Table: "events", PathTemplate: "{table}/{YYYY}/{MM}", Days: 29, Action: RetentionActionDelete}
+
+	// Without Execute only the plan is returned
+	store := newStore()
+	results, err := applyRetention(ctx, newS3ObjectStore(store), "bucket", policy, nil, now)
+	if err != nil {
+		t.Fatalf("applyRetention() error = %v", err)
+	}
+	if len(results) != 2 || results[0].Status != RetentionStatusPlanned || len(store.objects) != 4 {
+		t.Fatalf("dry run results = %+v, objects = %d", results, len(store.objects))
+	}
+
+	policy.Execute = true
+	results, err = applyRetention(ctx, newS3ObjectStore(store), "bucket", policy, nil, now)
+	if err != nil {
+		t.Fatalf("applyRetention() error = %v", err)
+	}
+	if len(results) != 2 || results[0].Status != RetentionStatusDeleted || results[1].Status != RetentionStatusDeleted {
+		t.Fatalf("results = %+v", results)
+	}
+	if _, ok := store.objects["events/2024/02/events-2024-02-01.jsonl.zst"]; !ok {
+		t.Error("object within retention was deleted")
+	}
+	if _, ok := store.objects["events/2024/01/notes.txt"]; !ok {
+		t.Error("object outside the template was deleted")
+	}
+	if len(store.objects) != 2 {
+		t.Errorf("objects left = %d, want 2", len(store.objects))
+	}
+
+	// Transitioning keeps the objects; listed objects are STANDARD unless reported otherwise
+	store = newStore()
+	policy.Action = RetentionActionTransition
+	policy.StorageClass = s3.StorageClassGlacier
+	results, err = applyRetention(ctx, newS3ObjectStore(store), "bucket", policy, nil, now)
+	if err != nil {
+		t.Fatalf("applyRetention() error = %v", err)
+	}
+	if len(results) != 2 || results[0].Status != RetentionStatusTransitioned || len(store.objects) != 4 {
+		t.Fatalf("transition results = %+v, objects = %d", results, len(store.objects))
+	}
+	policy.StorageClass = s3.StorageClassStandard
+	results, _ = applyRetention(ctx, newS3ObjectStore(store), "bucket", policy, nil, now)
+	if len(results) != 2 || results[0].Status != RetentionStatusInClass {
+		t.Errorf("results = %+v, want %s", results, RetentionStatusInClass)
+	}
+}
+
+func TestApplyRetentionSplitByColumn(t *testing.T) {
+	store := &fakeObjectStore{objects: map[string][]byte{
+		"events/2024/01/events-2024-01-31.manifest.json":                []byte("{}"),
+		"events/2024/01/tenant_id=42/events-2024-01-31.jsonl.zst":       []byte("tenant 42"),
+		"events/2024/01/tenant_id=42/events-2024-01-31-0002.jsonl.zst":  []byte("tenant 42, second file"),
+		"events/2024/01/tenant_id=__null__/events-2024-01-31.jsonl.zst": []byte("no tenant"),
+		"events/2024/02/tenant_id=42/events-2024-02-01.jsonl.zst":       []byte("recent"),
+	}}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) // Cutoff 2024-02-01
+	policy := RetentionPolicy{Table: "events", PathTemplate: "{table}/{YYYY}/{MM}", Days: 29, Action: RetentionActionDelete, Execute: true}
+
+	results, err := applyRetention(context.Background(), newS3ObjectStore(store), "bucket", policy, nil, now)
+	if err != nil {
+		t.Fatalf("applyRetention() error = %v", err)
+	}
+	if len(results) != 4 || len(store.objects) != 1 {
+		t.Fatalf("results = %+v, objects left = %v", results, store.objects)
+	}
+	if _, ok := store.objects["events/2024/02/tenant_id=42/events-2024-02-01.jsonl.zst"]; !ok {
+		t.Error("per-tenant object within retention was deleted")
+	}
+}
+
+func TestApplyRetentionSoftDeleteRecordsLedger(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx := context.Background()
+	store := &fakeObjectStore{objects: map[string][]byte{}}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) // Cutoff 2024-02-01
+
+	if ledger, err := openExistingIntegrityLedger(ctx, newS3ObjectStore(store), "bucket", defaultIntegrityPrefix, "events", ""); err != nil || ledger != nil {
+		t.Fatalf("openExistingIntegrityLedger() = %v, %v; want no ledger", ledger, err)
+	}
+
+	config := &Config{Table: "events", S3: S3Config{Bucket: "bucket"}, IntegrityPrefix: defaultIntegrityPrefix}
+	archived := newIntegrityLedger(config)
+	for _, key := range []string{"events/2024/01/events-2024-01-31.jsonl.zst", "events/2024/02/events-2024-02-01.jsonl.zst"} {
+		md5Hash := putArchiveObject(store, key, "rows of "+key)
+		entry := integrityEntry{Event: IntegrityEventArchived, Key: key, MD5: md5Hash, Size: int64(len("rows of " + key))}
+		if err := archived.append(ctx, newS3ObjectStore(store), "", entry); err != nil {
+			t.Fatalf("append() error = %v", err)
+		}
+	}
+	if err := archived.flush(ctx, newS3ObjectStore(store)); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+
+	ledger, err := openExistingIntegrityLedger(ctx, newS3ObjectStore(store), "bucket", defaultIntegrityPrefix, "events", "")
+	if err != nil || ledger == nil {
+		t.Fatalf("openExistingIntegrityLedger() = %v, %v", ledger, err)
+	}
+	policy := RetentionPolicy{Table: "events", PathTemplate: "{table}/{YYYY}/{MM}", Days: 29, Action: RetentionActionDelete, Execute: true,
+		SoftDeleteDays: 7, TrashPrefix: defaultTrashPrefix}
+	results, err := applyRetention(ctx, newS3ObjectStore(store), "bucket", policy, ledger, now)
+	if err != nil {
+		t.Fatalf("applyRetention() error = %v", err)
+	}
+	wantMessage := "moved to _data-archiver/trash/events/2024-03-01/events/2024/01/events-2024-01-31.jsonl.zst"
+	if len(results) != 1 || results[0].Status != RetentionStatusDeleted || results[0].Message != wantMessage {
+		t.Fatalf("results = %+v", results)
+	}
+	if err := ledger.flush(ctx, newS3ObjectStore(store)); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+
+	// The expired file is recorded as deleted, so the ledger still verifies
+	report, err := verifyIntegrityLedger(ctx, newS3ObjectStore(store), ledgerVerifyOptions{
+		Bucket: "bucket", Table: "events", Prefix: defaultIntegrityPrefix,
+		LocalPath: ledger.path, PathTemplate: "{table}/{YYYY}/{MM}",
+	})
+	if err != nil {
+		t.Fatalf("verifyIntegrityLedger() error = %v", err)
+	}
+	if report.Entries != 3 || len(report.Issues) != 0 {
+		t.Fatalf("expected a clean ledger with the deletion, got %+v", report)
+	}
+
+	// And it can be recovered from the trash
+	restored, err := undeleteObjects(ctx, newS3ObjectStore(store), "bucket", defaultTrashPrefix, "events", "events/2024/01/", false, false)
+	if err != nil || len(restored) != 1 || !restored[0].Restored {
+		t.Fatalf("undeleteObjects() = %+v, %v", restored, err)
+	}
+}
+
+func TestValidateRetentionConfig(t *testing.T) {
+	s3Config := S3Config{Endpoint: "https://s3.example.com", Bucket: "bucket", AccessKey: "key", SecretKey: "secret"}
+	valid := RetentionPolicy{Table: "events", PathTemplate: "{table}/{YYYY}", Months: 6, Action: RetentionActionDelete}
+	if err := validateRetentionConfig(s3Config, valid); err != nil {
+		t.Fatalf("validateRetentionConfig() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(*RetentionPolicy)
+		want   error
+	}{
+		{"no age", func(p *RetentionPolicy) { p.Months = 0 }, ErrRetentionAgeRequired},
+		{"both ages", func(p *RetentionPolicy) { p.Days = 30 }, ErrRetentionAgeRequired},
+		{"bad action", func(p *RetentionPolicy) { p.Action = "archive" }, ErrRetentionActionInvalid},
+		{"no storage class", func(p *RetentionPolicy) { p.Action = RetentionActionTransition }, ErrRetentionStorageClass},
+		{"no table placeholder", func(p *RetentionPolicy) { p.PathTemplate = "archive/{YYYY}" }, ErrRetentionTemplateInvalid},
+		{"negative soft delete", func(p *RetentionPolicy) { p.SoftDeleteDays = -1 }, ErrSoftDeleteDaysInvalid},
+		{"no trash prefix", func(p *RetentionPolicy) { p.SoftDeleteDays = 7 }, ErrTrashPrefixRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := valid
+			tt.mutate(&policy)
+			if err := validateRetentionConfig(s3Config, policy); !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
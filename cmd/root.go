@@ -11,6 +11,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/airframesio/data-archiver/cmd/formatters"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -20,6 +21,9 @@ var (
 	// Version information - set via ldflags during build
 	// Example: go build -ldflags "-X github.com/airframesio/data-archiver/cmd.Version=1.2.3"
 	Version = "dev" // Default to "dev" if not set during build
+	// Commit and BuildDate are injected the same way (cmd.Commit, cmd.BuildDate)
+	Commit    = ""
+	BuildDate = ""
 
 	// signalContext is set by main() before Cobra initialization
 	// This ensures signal handling is set up before any library can interfere
@@ -30,40 +34,54 @@ var (
 	// This is shared between the startup check and TUI display
 	versionCheckResult *VersionCheckResult
 
-	cfgFile            string
-	debug              bool
-	logFormat          string
-	dbHost             string
-	dbPort             int
-	dbUser             string
-	dbPassword         string
-	dbName             string
-	dbSSLMode          string
-	dbStatementTimeout int
-	dbMaxRetries       int
-	dbRetryDelay       int
-	s3Endpoint         string
-	s3Bucket           string
-	s3AccessKey        string
-	s3SecretKey        string
-	s3Region           string
-	baseTable          string
-	startDate          string
-	endDate            string
-	workers            int
-	dryRun             bool
-	skipCount                bool
+	cfgFile                   string
+	debug                     bool
+	logFormat                 string
+	dbHost                    string
+	dbPort                    int
+	dbUser                    string
+	dbPassword                string
+	dbName                    string
+	dbSSLMode                 string
+	dbStatementTimeout        int
+	dbMaxRetries              int
+	dbRetryDelay              int
+	s3Endpoint                string
+	s3Bucket                  string
+	s3AccessKey               string
+	s3SecretKey               string
+	s3Region                  string
+	baseTable                 string
+	startDate                 string
+	endDate                   string
+	workers                   int
+	dryRun                    bool
+	skipCount                 bool
+	checkPartitionDates       bool
 	cacheViewer               bool
 	viewerPort                int
 	chunkSize                 int
 	includeNonPartitionTables bool
 	pathTemplate              string
-	outputDuration     string
-	outputFormat       string
-	compression        string
-	compressionLevel   int
-	dateColumn         string
-	dumpMode           string
+	outputDuration            string
+	outputFormat              string
+	compression               string
+	compressionLevel          int
+	adaptiveCompression       bool
+	compressionLevelMin       int
+	compressionLevelMax       int
+	adaptiveCPUTarget         int
+	fieldRename               map[string]string
+	camelCaseFields           bool
+	flattenFields             string
+	flattenSeparator          string
+	tableList                 string
+	tableConcurrency          int
+	maxParallelParts          int
+	maxTableBandwidth         string
+	outputTarget              string
+	dateColumn                string
+	dumpMode                  string
 
 	titleStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#7D56F4")).
@@ -74,13 +92,17 @@ var (
 			Foreground(lipgloss.Color("#00D9FF"))
 
 	logger *slog.Logger
+
+	// logOutput is where initLogger writes; stderr when archive output goes to stdout
+	logOutput io.Writer = os.Stdout
 )
 
-// SetSignalContext stores the signal-aware context created in main()
+// SetSignalContext stores the signal-aware context created in main() and its
+// cancel function, which the optional stop file watcher uses to request a stop.
 // This must be called before Execute() to ensure proper signal handling
-func SetSignalContext(ctx context.Context, stopFile string) {
+func SetSignalContext(ctx context.Context, cancel context.CancelFunc) {
 	signalContext = ctx
-	stopFilePath = stopFile
+	signalCancel = cancel
 }
 
 // broadcastLogHandler wraps a slog handler and broadcasts logs to WebSocket clients
@@ -182,25 +204,34 @@ func initLogger(isDebug bool, format string) {
 		opts.Level = slog.LevelDebug
 	}
 
+	output, sink, targetErr := openLogTarget(viper.GetString("log_target"), viper.GetString("log_file"))
+
 	var handler slog.Handler
-	switch format {
-	case "json":
-		handler = slog.NewJSONHandler(os.Stdout, opts)
-	case "logfmt":
+	switch {
+	case sink != nil:
+		// Syslog and journald entries are structured lines with a severity
+		handler = newSinkHandler(sink, format, opts)
+	case format == "json":
+		handler = slog.NewJSONHandler(output, opts)
+	case format == "logfmt":
 		// logfmt uses slog.TextHandler which outputs key=value pairs
-		handler = slog.NewTextHandler(os.Stdout, opts)
+		handler = slog.NewTextHandler(output, opts)
 	default: // "text" or anything else
 		// For human-readable text output, we'll use a custom handler
 		// that formats messages more naturally without key=value pairs
-		handler = newTextOnlyHandler(os.Stdout, opts)
+		handler = newTextOnlyHandler(output, opts)
 	}
 
 	// Wrap handler to broadcast logs if logBroadcast channel exists (cache viewer mode)
 	// Note: logBroadcast is only initialized in cache viewer mode
 	// We'll check for it at runtime in the handler
-	handler = newBroadcastLogHandler(handler)
+	handler = newBroadcastLogHandler(newQuietHandler(handler, viper.GetInt("quiet")))
 
-	logger = slog.New(handler)
+	// Every structured log line carries the run ID; text output shows it once at startup
+	logger = slog.New(handler).With("run_id", currentRunID)
+	if targetErr != nil {
+		logger.Warn(fmt.Sprintf("⚠️  Logging to the terminal instead: %v", targetErr))
+	}
 }
 
 var rootCmd = &cobra.Command{
@@ -211,8 +242,24 @@ var rootCmd = &cobra.Command{
 
 A CLI tool to efficiently archive database data to object storage.
 Currently supports PostgreSQL input (partitioned tables) and S3-compatible storage output.
-Extracts data by day, converts to JSONL/CSV/Parquet, compresses with zstd/lz4/gzip, and uploads.
+Extracts data by day, converts to JSONL/CSV/Parquet, compresses with zstd/lz4/gzip/brotli/xz, and uploads.
 Also supports pg_dump for full database dumps with custom format and heavy compression.`,
+	PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+		if err := validateLogTarget(viper.GetString("log_target"), viper.GetString("log_file")); err != nil {
+			return err
+		}
+		if err := validateOutputMode(viper.GetInt("quiet"), viper.GetString("progress"), viper.GetBool("debug")); err != nil {
+			return err
+		}
+		memoryLimits, err := loadMemoryLimits()
+		if err != nil {
+			return err
+		}
+		if err := applyMemoryLimits(memoryLimits); err != nil {
+			return err
+		}
+		return applyCPULimits(loadCPULimits())
+	},
 	Run: func(cmd *cobra.Command, _ []string) {
 		// Show help when no subcommand is specified
 		cmd.Help()
@@ -264,6 +311,8 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "enable debug output")
 	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "log format (text, logfmt, json)")
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "perform a dry run without uploading")
+	rootCmd.PersistentFlags().BoolVar(&enableStopFile, "enable-stop-file", false, "watch for a stop file to request a graceful stop (for terminals where CTRL-C doesn't work)")
+	rootCmd.PersistentFlags().StringVar(&stopFileFlag, "stop-file", "", "stop file path (default: <tmp>/data-archiver/<command>-<table>.stop)")
 
 	// Archive-specific flags
 	archiveCmd.Flags().StringVar(&dbHost, "db-host", "localhost", "PostgreSQL host")
@@ -283,6 +332,10 @@ func init() {
 	archiveCmd.Flags().StringVar(&s3Region, "s3-region", "auto", "S3 region")
 
 	archiveCmd.Flags().StringVar(&baseTable, "table", "", "base table name (required)")
+	archiveCmd.Flags().StringVar(&tableList, "tables", "", "comma-separated base tables to archive in one run (instead of --table)")
+	archiveCmd.Flags().IntVar(&tableConcurrency, "table-concurrency", 1, "number of tables archived at once with --tables")
+	archiveCmd.Flags().IntVar(&maxParallelParts, "max-parallel-partitions", 1, "partitions of a table processed at once (default quota; TUI mode processes one at a time)")
+	archiveCmd.Flags().StringVar(&maxTableBandwidth, "max-table-bandwidth", "", "upload bandwidth limit per table, e.g. 50MB (default quota; empty = unlimited)")
 	archiveCmd.Flags().StringVar(&startDate, "start-date", "", "start date (YYYY-MM-DD)")
 	archiveCmd.Flags().StringVar(&endDate, "end-date", time.Now().Format("2006-01-02"), "end date (YYYY-MM-DD)")
 	archiveCmd.Flags().IntVar(&workers, "workers", 4, "number of parallel workers")
@@ -293,12 +346,22 @@ func init() {
 	archiveCmd.Flags().BoolVar(&includeNonPartitionTables, "include-non-partition-tables", false, "include regular tables matching partition naming pattern (not just actual partitions)")
 
 	// Output configuration flags
-	archiveCmd.Flags().StringVar(&pathTemplate, "path-template", "", "S3 path template with placeholders: {table}, {YYYY}, {MM}, {DD}, {HH} (required)")
-	archiveCmd.Flags().StringVar(&outputDuration, "output-duration", "daily", "output file duration: hourly, daily, weekly, monthly, yearly")
+	archiveCmd.Flags().StringVar(&pathTemplate, "path-template", "", "S3 path template with placeholders: {table}, {format}, {compression}, {YYYY}, {MM}, {DD}, {HH}, {WW} (ISO week), {Q} (quarter); date placeholders may be lowercase (required)")
+	archiveCmd.Flags().StringVar(&outputDuration, "output-duration", "daily", "output file duration: hourly, daily, weekly, monthly, quarterly, yearly, a custom interval such as 6h or 3d, or auto (match the partition period)")
 	archiveCmd.Flags().StringVar(&outputFormat, "output-format", "jsonl", "output format: jsonl, csv, parquet")
-	archiveCmd.Flags().StringVar(&compression, "compression", "zstd", "compression type: zstd, lz4, gzip, none")
-	archiveCmd.Flags().IntVar(&compressionLevel, "compression-level", 3, "compression level (zstd: 1-22, lz4/gzip: 1-9, none: 0)")
+	archiveCmd.Flags().StringVar(&compression, "compression", "zstd", "compression type: zstd, lz4, gzip, brotli, xz, none")
+	archiveCmd.Flags().IntVar(&compressionLevel, "compression-level", 3, "compression level (zstd: 1-22, lz4/gzip/xz: 1-9, brotli: 0-11, none: 0)")
+	archiveCmd.Flags().BoolVar(&adaptiveCompression, "adaptive-compression", false, "step compression level up/down based on CPU load and throughput")
+	archiveCmd.Flags().IntVar(&compressionLevelMin, "compression-level-min", 1, "lowest compression level used by --adaptive-compression")
+	archiveCmd.Flags().IntVar(&compressionLevelMax, "compression-level-max", 0, "highest compression level used by --adaptive-compression (0 = --compression-level)")
+	archiveCmd.Flags().IntVar(&adaptiveCPUTarget, "adaptive-cpu-target", 75, "CPU usage percent above which --adaptive-compression steps the level down")
+	archiveCmd.Flags().StringToStringVar(&fieldRename, "field-rename", nil, "rename JSONL fields as column=field pairs (e.g. flight_id=flightId)")
+	archiveCmd.Flags().BoolVar(&camelCaseFields, "camel-case-fields", false, "convert snake_case column names to camelCase JSONL fields")
+	archiveCmd.Flags().StringVar(&flattenFields, "flatten-fields", "", "comma-separated json/jsonb columns whose keys are written as top-level JSONL fields")
+	archiveCmd.Flags().StringVar(&flattenSeparator, "flatten-separator", formatters.DefaultFlattenSeparator, "separator between a flattened column and its nested keys")
 	archiveCmd.Flags().StringVar(&dateColumn, "date-column", "", "timestamp column name for duration-based splitting (optional)")
+	archiveCmd.Flags().BoolVar(&checkPartitionDates, "check-partition-dates", false, "compare the date column's min/max in each partition against the date in its name and flag rows outside it (requires --date-column)")
+	archiveCmd.Flags().StringVar(&outputTarget, "output", "", "'-' writes a single partition/slice to stdout instead of uploading to S3 (logs go to stderr)")
 
 	// Dump-specific flags
 	dumpCmd.Flags().StringVar(&dbHost, "db-host", "localhost", "PostgreSQL host")
@@ -312,14 +375,14 @@ func init() {
 	dumpCmd.Flags().StringVar(&s3AccessKey, "s3-access-key", "", "S3 access key")
 	dumpCmd.Flags().StringVar(&s3SecretKey, "s3-secret-key", "", "S3 secret key")
 	dumpCmd.Flags().StringVar(&s3Region, "s3-region", "auto", "S3 region")
-	dumpCmd.Flags().StringVar(&pathTemplate, "path-template", "", "S3 path template with placeholders: {table}, {YYYY}, {MM}, {DD}, {HH} (required)")
+	dumpCmd.Flags().StringVar(&pathTemplate, "path-template", "", "S3 path template with placeholders: {table}, {format}, {compression}, {YYYY}, {MM}, {DD}, {HH}, {WW} (ISO week), {Q} (quarter); date placeholders may be lowercase (required)")
 	dumpCmd.Flags().StringVar(&baseTable, "table", "", "table name to dump (optional, dumps entire database if not specified)")
 	dumpCmd.Flags().IntVar(&workers, "workers", 4, "number of parallel jobs for pg_dump")
 	dumpCmd.Flags().StringVar(&dumpMode, "dump-mode", "schema-and-data", "dump mode: schema-only, data-only, schema-and-data")
 	dumpCmd.Flags().StringVar(&startDate, "start-date", "", "start date (YYYY-MM-DD) for filtering partitions/data")
 	dumpCmd.Flags().StringVar(&endDate, "end-date", "", "end date (YYYY-MM-DD) for filtering partitions/data")
 	dumpCmd.Flags().StringVar(&dateColumn, "date-column", "", "timestamp column name for date-based filtering (required for data dumps with date ranges)")
-	dumpCmd.Flags().StringVar(&outputDuration, "output-duration", "daily", "output file duration: hourly, daily, weekly, monthly, yearly (for data dumps)")
+	dumpCmd.Flags().StringVar(&outputDuration, "output-duration", "daily", "output file duration: hourly, daily, weekly, monthly, quarterly, yearly (for data dumps)")
 
 	// Hybrid dump-specific flags (shares same variables as dump)
 	dumpHybridCmd.Flags().StringVar(&dbHost, "db-host", "localhost", "PostgreSQL host")
@@ -333,13 +396,13 @@ func init() {
 	dumpHybridCmd.Flags().StringVar(&s3AccessKey, "s3-access-key", "", "S3 access key")
 	dumpHybridCmd.Flags().StringVar(&s3SecretKey, "s3-secret-key", "", "S3 secret key")
 	dumpHybridCmd.Flags().StringVar(&s3Region, "s3-region", "auto", "S3 region")
-	dumpHybridCmd.Flags().StringVar(&pathTemplate, "path-template", "", "S3 path template with placeholders: {table}, {YYYY}, {MM}, {DD}, {HH} (required)")
+	dumpHybridCmd.Flags().StringVar(&pathTemplate, "path-template", "", "S3 path template with placeholders: {table}, {format}, {compression}, {YYYY}, {MM}, {DD}, {HH}, {WW} (ISO week), {Q} (quarter); date placeholders may be lowercase (required)")
 	dumpHybridCmd.Flags().StringVar(&baseTable, "table", "", "table name to dump (required)")
 	dumpHybridCmd.Flags().IntVar(&workers, "workers", 4, "number of parallel jobs for pg_dump")
 	dumpHybridCmd.Flags().StringVar(&startDate, "start-date", "", "start date (YYYY-MM-DD) for filtering partitions/data (required for hybrid data dumps)")
 	dumpHybridCmd.Flags().StringVar(&endDate, "end-date", "", "end date (YYYY-MM-DD) for filtering partitions/data")
 	dumpHybridCmd.Flags().StringVar(&dateColumn, "date-column", "", "timestamp column name for date-based filtering (required)")
-	dumpHybridCmd.Flags().StringVar(&outputDuration, "output-duration", "daily", "output file duration: hourly, daily, weekly, monthly, yearly (required)")
+	dumpHybridCmd.Flags().StringVar(&outputDuration, "output-duration", "daily", "output file duration: hourly, daily, weekly, monthly, quarterly, yearly (required)")
 
 	// Note: We don't use MarkFlagRequired because it checks before viper loads the config file.
 	// Instead, validation happens in config.Validate() which runs after all config sources are loaded.
@@ -348,6 +411,8 @@ func init() {
 	_ = viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
 	_ = viper.BindPFlag("log_format", rootCmd.PersistentFlags().Lookup("log-format"))
 	_ = viper.BindPFlag("dry_run", rootCmd.PersistentFlags().Lookup("dry-run"))
+	_ = viper.BindPFlag("enable_stop_file", rootCmd.PersistentFlags().Lookup("enable-stop-file"))
+	_ = viper.BindPFlag("stop_file", rootCmd.PersistentFlags().Lookup("stop-file"))
 
 	// Bind archive flags
 	_ = viper.BindPFlag("db.host", archiveCmd.Flags().Lookup("db-host"))
@@ -368,6 +433,11 @@ func init() {
 	_ = viper.BindPFlag("s3.secret_key", archiveCmd.Flags().Lookup("s3-secret-key"))
 	_ = viper.BindPFlag("s3.region", archiveCmd.Flags().Lookup("s3-region"))
 	_ = viper.BindPFlag("table", archiveCmd.Flags().Lookup("table"))
+	_ = viper.BindPFlag("tables", archiveCmd.Flags().Lookup("tables"))
+	_ = viper.BindPFlag("table_concurrency", archiveCmd.Flags().Lookup("table-concurrency"))
+	_ = viper.BindPFlag("max_parallel_partitions", archiveCmd.Flags().Lookup("max-parallel-partitions"))
+	_ = viper.BindPFlag("max_table_bandwidth", archiveCmd.Flags().Lookup("max-table-bandwidth"))
+	_ = viper.BindPFlag("output", archiveCmd.Flags().Lookup("output"))
 	_ = viper.BindPFlag("start_date", archiveCmd.Flags().Lookup("start-date"))
 	_ = viper.BindPFlag("end_date", archiveCmd.Flags().Lookup("end-date"))
 	_ = viper.BindPFlag("workers", archiveCmd.Flags().Lookup("workers"))
@@ -378,7 +448,16 @@ func init() {
 	_ = viper.BindPFlag("output_format", archiveCmd.Flags().Lookup("output-format"))
 	_ = viper.BindPFlag("compression", archiveCmd.Flags().Lookup("compression"))
 	_ = viper.BindPFlag("compression_level", archiveCmd.Flags().Lookup("compression-level"))
+	_ = viper.BindPFlag("adaptive_compression", archiveCmd.Flags().Lookup("adaptive-compression"))
+	_ = viper.BindPFlag("compression_level_min", archiveCmd.Flags().Lookup("compression-level-min"))
+	_ = viper.BindPFlag("compression_level_max", archiveCmd.Flags().Lookup("compression-level-max"))
+	_ = viper.BindPFlag("adaptive_cpu_target", archiveCmd.Flags().Lookup("adaptive-cpu-target"))
+	_ = viper.BindPFlag("field_mapping.rename", archiveCmd.Flags().Lookup("field-rename"))
+	_ = viper.BindPFlag("field_mapping.camel_case", archiveCmd.Flags().Lookup("camel-case-fields"))
+	_ = viper.BindPFlag("field_mapping.flatten", archiveCmd.Flags().Lookup("flatten-fields"))
+	_ = viper.BindPFlag("field_mapping.separator", archiveCmd.Flags().Lookup("flatten-separator"))
 	_ = viper.BindPFlag("date_column", archiveCmd.Flags().Lookup("date-column"))
+	_ = viper.BindPFlag("check_partition_dates", archiveCmd.Flags().Lookup("check-partition-dates"))
 
 	// Bind dump flags
 	_ = viper.BindPFlag("db.host", dumpCmd.Flags().Lookup("db-host"))
@@ -437,6 +516,10 @@ func initConfig() {
 	viper.SetEnvPrefix("ARCHIVE")
 	viper.AutomaticEnv()
 
+	if outputTarget == StdoutOutput {
+		logOutput = os.Stderr
+	}
+
 	if err := viper.ReadInConfig(); err == nil && debug {
 		// Initialize logger early if reading config in debug mode
 		if logger == nil {
@@ -456,15 +539,18 @@ func runArchive() {
 	}()
 
 	config := &Config{
-		Debug:       viper.GetBool("debug"),
-		LogFormat:   viper.GetString("log_format"),
-		DryRun:      viper.GetBool("dry_run"),
-		Workers:                  viper.GetInt("workers"),
-		SkipCount:                viper.GetBool("skip_count"),
-		CacheViewer:              viper.GetBool("viewer"),
-		ViewerPort:               viper.GetInt("viewer_port"),
-		ChunkSize:                viper.GetInt("chunk_size"),
+		Debug:                     viper.GetBool("debug"),
+		LogFormat:                 viper.GetString("log_format"),
+		Quiet:                     viper.GetInt("quiet"),
+		Progress:                  viper.GetString("progress"),
+		DryRun:                    viper.GetBool("dry_run"),
+		Workers:                   viper.GetInt("workers"),
+		SkipCount:                 viper.GetBool("skip_count"),
+		CacheViewer:               viper.GetBool("viewer"),
+		ViewerPort:                viper.GetInt("viewer_port"),
+		ChunkSize:                 viper.GetInt("chunk_size"),
 		IncludeNonPartitionTables: viper.GetBool("include_non_partition_tables"),
+		IncludeRelationKinds:      parseRelationKinds(viper.GetStringSlice("include_relation_kinds")),
 		Database: DatabaseConfig{
 			Host:             viper.GetString("db.host"),
 			Port:             viper.GetInt("db.port"),
@@ -475,6 +561,11 @@ func runArchive() {
 			StatementTimeout: viper.GetInt("db.statement_timeout"),
 			MaxRetries:       viper.GetInt("db.max_retries"),
 			RetryDelay:       viper.GetInt("db.retry_delay"),
+
+			WorkMem:            viper.GetString("db.work_mem"),
+			MaxParallelWorkers: viper.GetInt("db.max_parallel_workers"),
+			ApplicationName:    viper.GetString("db.application_name"),
+			QueryComments:      viper.GetBool("db.query_comments"),
 		},
 		S3: S3Config{
 			Endpoint:     viper.GetString("s3.endpoint"),
@@ -483,27 +574,129 @@ func runArchive() {
 			SecretKey:    viper.GetString("s3.secret_key"),
 			Region:       viper.GetString("s3.region"),
 			PathTemplate: viper.GetString("s3.path_template"),
+			Tags:         viper.GetStringMapString("s3.tags"),
+			Metadata:     viper.GetStringMapString("s3.metadata"),
 		},
-		Table:            viper.GetString("table"),
-		StartDate:        viper.GetString("start_date"),
-		EndDate:          viper.GetString("end_date"),
-		OutputDuration:   viper.GetString("output_duration"),
-		OutputFormat:     viper.GetString("output_format"),
-		Compression:      viper.GetString("compression"),
-		CompressionLevel: viper.GetInt("compression_level"),
-		DateColumn:       viper.GetString("date_column"),
+		Table:              viper.GetString("table"),
+		StartDate:          viper.GetString("start_date"),
+		EndDate:            viper.GetString("end_date"),
+		StartFrom:          viper.GetString("start_from"),
+		StartFromDate:      viper.GetString("start_from_date"),
+		OutputDuration:     viper.GetString("output_duration"),
+		OutputFormat:       viper.GetString("output_format"),
+		Compression:        viper.GetString("compression"),
+		CompressionLevel:   viper.GetInt("compression_level"),
+		DateColumn:         viper.GetString("date_column"),
+		DateColumnType:     viper.GetString("date_column_type"),
+		DateColumnFormat:   viper.GetString("date_column_format"),
+		SplitColumn:        viper.GetString("split_column"),
+		SplitSize:          viper.GetInt64("split_size"),
+		WatermarkColumn:    viper.GetString("watermark_column"),
+		RatioAnomalyFactor: viper.GetFloat64("ratio_anomaly_factor"),
+		TUILogLines:        viper.GetInt("tui_log_lines"),
+		IncludeSchema:      viper.GetBool("include_schema"),
+		IncludeComments:    viper.GetBool("include_comments"),
+		SchemaPathTemplate: viper.GetString("schema_path_template"),
+
+		IncludeLargeObjects: viper.GetBool("include_large_objects"),
+		CheckPartitionDates: viper.GetBool("check_partition_dates"),
+		UsageLedger:         viper.GetBool("usage.enabled"),
+		UsagePrefix:         viper.GetString("usage.prefix"),
+		AdaptiveCompression: viper.GetBool("adaptive_compression"),
+		CompressionLevelMin: viper.GetInt("compression_level_min"),
+		CompressionLevelMax: viper.GetInt("compression_level_max"),
+		AdaptiveCPUTarget:   viper.GetInt("adaptive_cpu_target"),
+
+		FieldMapping: buildFieldMapping(
+			viper.GetStringMapString("field_mapping.rename"),
+			viper.GetBool("field_mapping.camel_case"),
+			viper.GetString("field_mapping.flatten"),
+			viper.GetString("field_mapping.separator"),
+		),
+		Tables:           parseTableList(viper.GetStringSlice("tables")),
+		TableConcurrency: viper.GetInt("table_concurrency"),
+		TableQueries:     loadTableQueries(),
+		Output:           viper.GetString("output"),
+
+		FailOnPermissionDenied: viper.GetBool("fail_on_permission_denied"),
+		InvalidValues:          viper.GetString("invalid_values"),
+		QuarantineDir:          viper.GetString("quarantine_dir"),
+		FormatMigration:        viper.GetString("format_migration"),
+		CacheAudit:             viper.GetString("cache_audit"),
+		RediscoverInterval:     viper.GetDuration("rediscover_interval"),
+		MaxParallelQueries:     viper.GetInt("max_parallel_queries"),
+		IntegrityLedger:        viper.GetBool("integrity.enabled"),
+		IntegrityPrefix:        viper.GetString("integrity.prefix"),
+		IntegrityKeyFile:       viper.GetString("integrity.key_file"),
+		RunManifest:            viper.GetBool("run_manifest.enabled"),
+		RunManifestPrefix:      viper.GetString("run_manifest.prefix"),
+		ContentType:            viper.GetString("content_type"),
+		ContentEncoding:        viper.GetString("content_encoding"),
+		MaxDBRowsPerSec:        viper.GetInt64("max_db_rows_per_sec"),
+		ZstdWindowLog:          viper.GetInt("zstd.window_log"),
+		ZstdTrainDictionary:    viper.GetBool("zstd.train_dictionary"),
+		ZstdDictionarySizeKB:   viper.GetInt("zstd.dictionary_size"),
+		Parquet:                loadParquetOptions(),
+		Masking:                viper.GetStringMapString("masking.columns"),
+		MaskKeyFile:            viper.GetString("masking.key_file"),
+		Filter:                 viper.GetString("filter"),
+		SplitByColumn:          viper.GetString("split_by_column"),
+		SplitMaxWriters:        viper.GetInt("split_max_writers"),
+		SoftDeleteDays:         viper.GetInt("soft_delete.days"),
+		TrashPrefix:            viper.GetString("soft_delete.prefix"),
+		MaxRowsPerFile:         viper.GetInt64("max_rows_per_file"),
+		LimitRowsPerSlice:      viper.GetInt64("limit_rows_per_slice"),
+		ExtractMethod:          viper.GetString("extract_method"),
+		HeadConcurrency:        viper.GetInt("s3.head_concurrency"),
+		RecordSQL:              viper.GetBool("record_sql"),
+		TableFormat:            viper.GetString("table_format.format"),
+		TableCatalogPath:       viper.GetString("table_format.catalog_path"),
+		Hooks:                  loadInvalidationHooksConfig(),
 	}
 
+	// Per-table quotas: flags give the defaults, table_quotas overrides per table
+	defaultQuota := TableQuota{MaxParallelPartitions: viper.GetInt("max_parallel_partitions")}
+	var tableQuotas map[string]TableQuota
+	quotaErr := defaultQuota.validate()
+	if quotaErr == nil {
+		defaultQuota.MaxBandwidth, quotaErr = parseByteRate(viper.GetString("max_table_bandwidth"))
+	}
+	if quotaErr == nil {
+		config.MaxUploadRate, quotaErr = parseByteRate(viper.GetString("max_upload_rate"))
+	}
+	if quotaErr == nil {
+		tableQuotas, quotaErr = loadTableQuotas(defaultQuota)
+	}
+	config.Quota = quotaForTable(tableQuotas, defaultQuota, config.Table)
+	calendar, calendarErr := loadSkipCalendar()
+	config.Calendar = calendar
+	defaultTimezone, tableTimezones, timezoneErr := loadTableTimezones()
+	config.Timezone = timezoneForTable(tableTimezones, defaultTimezone, config.Table)
+	config.TableTimezones = tableTimezones
+	var s3Err error
+	config.S3, s3Err = newS3Config(config.S3, endpointProfileName("archive"))
+
 	config.CacheScope = NewCacheScope("archive", config)
 
+	// Keep stdout clean for the archive data when streaming it
+	if config.Output == StdoutOutput {
+		logOutput = os.Stderr
+	}
+
 	// Initialize logger
 	initLogger(config.Debug, config.LogFormat)
 
 	// Log startup banner
 	logger.Info("")
 	logger.Info(fmt.Sprintf("🚀 Data Archiver v%s", Version))
+	logger.Info(fmt.Sprintf("   Run ID: %s", currentRunID))
 	logger.Info("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 
+	stopStopFileWatcher := startStopFileWatcher("archive", config.Table)
+	defer stopStopFileWatcher()
+	stopPauseWatcher := startPauseWatcher(config.Table)
+	defer stopPauseWatcher()
+
 	// Display stop instructions (for Warp terminal compatibility) - only in debug mode
 	// In TUI mode, printing to stderr corrupts the display
 	if config.Debug && stopFilePath != "" {
@@ -512,12 +705,45 @@ func runArchive() {
 	}
 
 	logger.Debug("Validating configuration...")
-	if err := config.Validate(); err != nil {
+	if quotaErr != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", quotaErr.Error()))
+		os.Exit(1)
+	}
+	if calendarErr != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", calendarErr.Error()))
+		os.Exit(1)
+	}
+	if timezoneErr != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", timezoneErr.Error()))
+		os.Exit(1)
+	}
+	if s3Err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", s3Err.Error()))
+		os.Exit(1)
+	}
+	var tableConfigs []*Config
+	if len(config.Tables) > 0 {
+		var err error
+		if tableConfigs, err = buildTableConfigs(config, tableQuotas, defaultQuota); err != nil {
+			logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+			os.Exit(1)
+		}
+	} else if err := config.Validate(); err != nil {
 		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
 		os.Exit(1)
 	}
+	for _, warning := range config.pathTemplateWarnings() {
+		logger.Warn(fmt.Sprintf("⚠️  %s", warning))
+	}
 	logger.Debug("Configuration validated successfully")
 
+	events, eventsErr := openProgressEventLog(viper.GetString("progress_file"))
+	if eventsErr != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", eventsErr.Error()))
+		os.Exit(1)
+	}
+	defer events.close()
+
 	// Check for updates in background (non-blocking)
 	updateCheckDone := make(chan struct{})
 	go func() {
@@ -571,11 +797,18 @@ func runArchive() {
 		}
 	}()
 
-	logger.Debug("Creating archiver...")
-	archiver := NewArchiver(config, logger)
-	logger.Debug("Starting archival process...")
-
-	err := archiver.Run(ctx)
+	var err error
+	if tableConfigs != nil {
+		err = runTables(ctx, tableConfigs, config.TableConcurrency, events, logger)
+	} else if config.Output == StdoutOutput {
+		err = NewArchiver(config, logger).RunToWriter(ctx, os.Stdout)
+	} else {
+		logger.Debug("Creating archiver...")
+		archiver := NewArchiver(config, logger)
+		archiver.events = events
+		logger.Debug("Starting archival process...")
+		err = archiver.Run(ctx)
+	}
 	close(exited) // Signal that the archival process has exited
 
 	if err != nil {
@@ -628,8 +861,14 @@ func runDump() {
 		EndDate:        viper.GetString("end_date"),
 		DateColumn:     viper.GetString("date_column"),
 		OutputDuration: viper.GetString("output_duration"),
+
+		DateColumnType:   viper.GetString("date_column_type"),
+		DateColumnFormat: viper.GetString("date_column_format"),
 	}
 
+	var s3Err error
+	config.S3, s3Err = newS3Config(config.S3, endpointProfileName("dump"))
+
 	config.CacheScope = NewCacheScope("dump", config)
 
 	// Initialize logger
@@ -638,9 +877,14 @@ func runDump() {
 	// Log startup banner
 	logger.Info("")
 	logger.Info(fmt.Sprintf("🚀 Data Archiver v%s - pg_dump Mode", Version))
+	logger.Info(fmt.Sprintf("   Run ID: %s", currentRunID))
 	logger.Info("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 
 	logger.Debug("Validating configuration...")
+	if s3Err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", s3Err.Error()))
+		os.Exit(1)
+	}
 	if err := config.Validate(); err != nil {
 		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
 		os.Exit(1)
@@ -657,6 +901,10 @@ func runDump() {
 		defer stop()
 	}
 
+	stopStopFileWatcher := startStopFileWatcher("dump", config.Table)
+	defer stopStopFileWatcher()
+	logStopFileHint("dump")
+
 	logger.Debug("Creating pg_dump executor...")
 	executor := NewPgDumpExecutor(config, logger)
 	logger.Debug("Starting pg_dump process...")
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimitQuery(t *testing.T) {
+	archiver := NewArchiver(&Config{}, newTestLogger())
+	if got := archiver.limitQuery("SELECT id FROM events"); got != "SELECT id FROM events" {
+		t.Errorf("limitQuery() without a limit = %q", got)
+	}
+	if _, ok := archiver.objectMetadata("events/events.jsonl")[rowLimitMetadataKey]; ok {
+		t.Error("full archives should not carry row-limit metadata")
+	}
+
+	archiver.config.LimitRowsPerSlice = 500
+	if got := archiver.limitQuery("SELECT id FROM events ORDER BY id LIMIT 10000"); got != "SELECT * FROM (SELECT id FROM events ORDER BY id LIMIT 10000) AS row_limit LIMIT 500" {
+		t.Errorf("limitQuery() = %q", got)
+	}
+	metadata := archiver.objectMetadata("events/events.jsonl")
+	if metadata[rowLimitMetadataKey] != "500" || metadata[runIDMetadataKey] != currentRunID {
+		t.Errorf("objectMetadata() = %v", metadata)
+	}
+}
+
+func TestValidateRowLimit(t *testing.T) {
+	config := newTestConfig()
+	config.LimitRowsPerSlice = -1
+	if err := config.Validate(); !errors.Is(err, ErrRowLimitInvalid) {
+		t.Errorf("expected ErrRowLimitInvalid, got %v", err)
+	}
+}
+
+func TestRowLimitedRunKeepsFullArchives(t *testing.T) {
+	store := &fakeObjectStore{objects: map[string][]byte{
+		"events/events-2024-01-01.jsonl": []byte("full archive"),
+		"events/events-2024-01-02.jsonl": []byte("sample"),
+	}}
+	archiver := NewArchiver(&Config{Table: "events", LimitRowsPerSlice: 100, S3: S3Config{Bucket: "bucket"}}, newTestLogger())
+	archiver.s3Client = newS3ObjectStore(store)
+	archiver.ctx = context.Background()
+
+	cache := &PartitionCache{Entries: map[string]PartitionCacheEntry{
+		"events/events-2024-01-02.jsonl": {S3Key: "events/events-2024-01-02.jsonl", S3Uploaded: true, RowLimit: 50},
+	}}
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	slice := PartitionInfo{TableName: "events", Date: date, RangeStart: date, RangeEnd: date.AddDate(0, 0, 1)}
+
+	skip, result := archiver.checkCachedMetadata(slice, "events/events-2024-01-01.jsonl", cache, func(string) {})
+	if !skip || result.Stage != StageSkipped {
+		t.Errorf("full archive should be kept, got %v, %+v", skip, result)
+	}
+	if skip, _ := archiver.checkCachedMetadata(slice, "events/events-2024-01-02.jsonl", cache, func(string) {}); skip {
+		t.Error("a sample with another row limit should be replaced")
+	}
+	if skip, _ := archiver.checkCachedMetadata(slice, "events/events-2024-01-03.jsonl", cache, func(string) {}); skip {
+		t.Error("missing objects should be archived")
+	}
+}
+
+func TestFullRunReplacesSamples(t *testing.T) {
+	store := &fakeObjectStore{objects: map[string][]byte{}}
+	md5Hash := putArchiveObject(store, "events/events-2024-01-01.jsonl", "sample")
+	archiver := NewArchiver(&Config{Table: "events", S3: S3Config{Bucket: "bucket"}}, newTestLogger())
+	archiver.s3Client = newS3ObjectStore(store)
+	archiver.ctx = context.Background()
+
+	cache := &PartitionCache{Entries: map[string]PartitionCacheEntry{}}
+	cache.setFileMetadataWithETagAndStartTime("events_20240101", "events/events-2024-01-01.jsonl", 6, 6, md5Hash, "", true, time.Time{})
+	cache.setArchivedContent("events_20240101", "events_20240101", time.Time{}, time.Time{}, 3, 0, archiveFormat{Format: "jsonl"}, 3)
+
+	partition := PartitionInfo{TableName: "events_20240101", Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if skip, _ := archiver.checkCachedMetadata(partition, "events/events-2024-01-01.jsonl", cache, func(string) {}); skip {
+		t.Error("a full run should not skip a cached sample")
+	}
+
+	archiver.config.LimitRowsPerSlice = 3
+	if skip, _ := archiver.checkCachedMetadata(partition, "events/events-2024-01-01.jsonl", cache, func(string) {}); !skip {
+		t.Error("a run with the same row limit should skip the matching sample")
+	}
+}
+
+func TestVerifyReportsSamples(t *testing.T) {
+	verifier := NewVerifier(&Config{Table: "events"}, true, newTestLogger())
+	result := verifier.verifyCounts(context.Background(), nil, PartitionCacheEntry{S3Key: "events/events-2024-01-01.jsonl", SourceTable: "events_20240101", RowLimit: 100})
+	if result.Status != VerifyStatusRowLimited || !result.IsDiscrepancy() {
+		t.Errorf("verifyCounts() = %+v", result)
+	}
+}
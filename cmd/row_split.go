@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/airframesio/data-archiver/cmd/formatters"
+	"github.com/spf13/viper"
+)
+
+// Static errors for row-based splitting
+var ErrMaxRowsPerFileInvalid = errors.New("max rows per file must be >= 0")
+
+var maxRowsPerFile int64
+
+func init() {
+	archiveCmd.Flags().Int64Var(&maxRowsPerFile, "max-rows-per-file", 0, "write each archive as numbered part files holding at most this many rows, listed in a manifest (0 = one file per archive)")
+	_ = viper.BindPFlag("max_rows_per_file", archiveCmd.Flags().Lookup("max-rows-per-file"))
+}
+
+// archivePart is one output file of an archive split by --max-rows-per-file
+// or --split-by-column. Rows are numbered from 1 in extraction order, counting
+// only the rows of the part's value with --split-by-column.
+type archivePart struct {
+	Number           int
+	Path             string // Temp file holding the part
+	Size             int64
+	MD5              string
+	UncompressedSize int64
+	FirstRow         int64
+	LastRow          int64
+	SplitDir         string // column=value directory of a --split-by-column part ("" = numbered part)
+	SplitFile        int    // Number of the part among those of its value
+}
+
+// Rows returns how many rows the part holds
+func (p archivePart) Rows() int64 {
+	return p.LastRow - p.FirstRow + 1
+}
+
+// splitManifest lists the parts of a split archive in order, with the rows
+// each one holds. It contains nothing run-specific, so re-archiving the same
+// rows produces the same manifest and the usual size and MD5 checks apply to it.
+// The run that uploaded it is recorded in the object's run-id metadata instead.
+type splitManifest struct {
+	Table          string           `json:"table"`
+	Partition      string           `json:"partition"`
+	Format         string           `json:"format"`
+	Compression    string           `json:"compression,omitempty"`
+	MaxRowsPerFile int64            `json:"max_rows_per_file"`
+	SplitByColumn  string           `json:"split_by_column,omitempty"` // Column whose values the parts hold
+	TotalRows      int64            `json:"total_rows"`
+	RowLimit       int64            `json:"row_limit,omitempty"` // Set when the archive is a --limit-rows-per-slice sample
+	Parts          []manifestPart   `json:"parts"`
+	Query          *extractionQuery `json:"query,omitempty"`   // Extraction SQL with --record-sql
+	Masking        *maskingRecord   `json:"masking,omitempty"` // Columns masked with --mask
+	Filter         string           `json:"filter,omitempty"`  // --filter condition the rows matched
+}
+
+// manifestPart describes one part file. FirstRow and LastRow are inclusive
+// and omitted for the single empty part of an archive without rows.
+type manifestPart struct {
+	Part     int    `json:"part"`
+	Key      string `json:"key"`
+	Split    string `json:"split,omitempty"` // column=value of a --split-by-column part
+	FirstRow int64  `json:"first_row,omitempty"`
+	LastRow  int64  `json:"last_row,omitempty"`
+	Rows     int64  `json:"rows"`
+	Size     int64  `json:"size"`
+	MD5      string `json:"md5"`
+
+	UncompressedSize int64 `json:"uncompressed_size,omitempty"`
+}
+
+// partObjectKey numbers a part of the archive at objectKey, keeping its
+// extension last: events-2024-01-01.jsonl.zst → events-2024-01-01-part-0001.jsonl.zst
+func partObjectKey(objectKey, ext string, number int) string {
+	return fmt.Sprintf("%s-part-%04d%s", strings.TrimSuffix(objectKey, ext), number, ext)
+}
+
+// manifestSuffix ends the key of every split archive's manifest
+const manifestSuffix = ".manifest.json"
+
+// manifestObjectKey returns the key of the manifest for the archive at objectKey
+func manifestObjectKey(objectKey, ext string) string {
+	return strings.TrimSuffix(objectKey, ext) + manifestSuffix
+}
+
+// newSplitManifest describes the parts extracted for the archive at objectKey
+func (a *Archiver) newSplitManifest(partition PartitionInfo, objectKey, ext string, parts []archivePart) splitManifest {
+	manifest := splitManifest{
+		Table:          a.config.Table,
+		Partition:      partition.TableName,
+		Format:         a.config.OutputFormat,
+		Compression:    a.config.Compression,
+		MaxRowsPerFile: a.config.MaxRowsPerFile,
+		SplitByColumn:  a.config.SplitByColumn,
+		RowLimit:       a.config.LimitRowsPerSlice,
+		Parts:          make([]manifestPart, len(parts)),
+		Masking:        a.config.maskingRecord(),
+		Filter:         a.config.Filter,
+	}
+	if formatters.UsesInternalCompression(manifest.Format) || manifest.Compression == "none" {
+		manifest.Compression = ""
+	}
+	for i, part := range parts {
+		entry := manifestPart{
+			Part:  part.Number,
+			Key:   partObjectKey(objectKey, ext, part.Number),
+			Split: part.SplitDir,
+			Rows:  part.Rows(),
+			Size:  part.Size,
+			MD5:   part.MD5,
+
+			UncompressedSize: part.UncompressedSize,
+		}
+		if part.SplitDir != "" {
+			entry.Key = fanOutObjectKey(objectKey, ext, part.SplitDir, part.SplitFile)
+		}
+		if entry.Rows > 0 {
+			entry.FirstRow, entry.LastRow = part.FirstRow, part.LastRow
+		}
+		manifest.Parts[i] = entry
+		manifest.TotalRows += entry.Rows
+	}
+	return manifest
+}
+
+// encode renders the manifest as the JSON object uploaded next to the parts
+func (m splitManifest) encode() ([]byte, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// manifestChecksum returns the size and MD5 of an encoded manifest, which
+// stand in for the archive's own in the cache and the S3 comparison
+func manifestChecksum(data []byte) (int64, string) {
+	sum := md5.Sum(data) //nolint:gosec // MD5 used for checksums, not cryptography
+	return int64(len(data)), hex.EncodeToString(sum[:])
+}
+
+// uploadParts uploads the parts of a split archive and then its manifest, so
+// the manifest never lists a part that is not in the bucket. Each part is
+// counted in the usage ledger and recorded in the integrity ledger.
+func (a *Archiver) uploadParts(manifestKey string, manifest splitManifest, data []byte, parts []archivePart) error {
+	for i, part := range parts {
+		key := manifest.Parts[i].Key
+		labels := a.labelsFor(manifestKey)
+		labels.Rows, labels.UncompressedSize = part.Rows(), part.UncompressedSize
+		a.labels.set(key, labels)
+		err := a.uploadTempFileToS3(part.Path, key)
+		a.labels.forget(key)
+		if err != nil {
+			return fmt.Errorf("part %d: %w", part.Number, err)
+		}
+		a.usage.add(time.Now(), part.Size, part.UncompressedSize, part.Rows())
+		if err := a.recordIntegrity(IntegrityEventArchived, key, part.MD5, part.Size, part.Rows()); err != nil {
+			return err
+		}
+		if a.manifest != nil {
+			a.recordRunManifest(key, part.MD5, a.runManifestETag(part.Path, part.Size), part.Size, part.UncompressedSize, part.Rows())
+		}
+		cleanupTempFile(part.Path)
+	}
+
+	if err := a.uploadToS3(manifestKey, data); err != nil {
+		return fmt.Errorf("manifest: %w", err)
+	}
+	size, md5Hash := manifestChecksum(data)
+	a.recordRunManifest(manifestKey, md5Hash, "", size, size, 0)
+	return a.recordIntegrity(IntegrityEventArchived, manifestKey, md5Hash, size, 0)
+}
+
+// cleanupParts removes the temp files of a split archive
+func cleanupParts(parts []archivePart) {
+	for _, part := range parts {
+		cleanupTempFile(part.Path)
+	}
+}
+
+// totalPartSize returns the combined size of the parts of a split archive
+func totalPartSize(parts []archivePart) int64 {
+	var size int64
+	for _, part := range parts {
+		size += part.Size
+	}
+	return size
+}
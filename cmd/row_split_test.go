@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPartObjectKeys(t *testing.T) {
+	objectKey := "events/2024/01/events-2024-01-01.jsonl.zst"
+	if got := partObjectKey(objectKey, ".jsonl.zst", 12); got != "events/2024/01/events-2024-01-01-part-0012.jsonl.zst" {
+		t.Errorf("partObjectKey() = %s", got)
+	}
+	if got := manifestObjectKey(objectKey, ".jsonl.zst"); got != "events/2024/01/events-2024-01-01.manifest.json" {
+		t.Errorf("manifestObjectKey() = %s", got)
+	}
+	if got := partObjectKey("events/events-2024-01.parquet", ".parquet", 1); got != "events/events-2024-01-part-0001.parquet" {
+		t.Errorf("partObjectKey() = %s", got)
+	}
+}
+
+func TestExtractPartitionDataStreamingMaxRows(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	tests := []struct {
+		rows      int
+		wantParts []int64 // Rows per part
+	}{
+		{5, []int64{2, 2, 1}},
+		{4, []int64{2, 2}},
+		{0, []int64{0}},
+	}
+	for _, tt := range tests {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create sqlmock: %v", err)
+		}
+
+		archiver := NewArchiver(&Config{
+			Table:          "events",
+			OutputFormat:   "jsonl",
+			Compression:    "none",
+			MaxRowsPerFile: 2,
+		}, newTestLogger())
+		archiver.db = db
+		archiver.ctx = context.Background()
+
+		mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events_20240101").
+			WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).
+				AddRow("id", "bigint", "int8"))
+		rows := sqlmock.NewRows([]string{"id"})
+		for i := 1; i <= tt.rows; i++ {
+			rows.AddRow(int64(i))
+		}
+		mock.ExpectQuery(`SELECT "id" FROM "events_20240101"`).WillReturnRows(rows)
+
+		var parts []archivePart
+		cache := &PartitionCache{Entries: make(map[string]PartitionCacheEntry)}
+		partition := PartitionInfo{TableName: "events_20240101", RowCount: int64(tt.rows)}
+		_, _, _, _, total, err := archiver.extractPartitionDataStreaming(partition, nil, cache, func(string) {}, time.Time{}, time.Time{}, 0, nil, &parts, nil)
+		db.Close()
+		if err != nil {
+			t.Fatalf("extraction failed: %v", err)
+		}
+		if total != int64(tt.rows) || len(parts) != len(tt.wantParts) {
+			t.Fatalf("%d rows: got %d rows in %d parts, want %d parts", tt.rows, total, len(parts), len(tt.wantParts))
+		}
+
+		next := int64(1)
+		for i, part := range parts {
+			data, err := os.ReadFile(part.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if part.Number != i+1 || part.Rows() != tt.wantParts[i] || int64(bytes.Count(data, []byte("\n"))) != tt.wantParts[i] {
+				t.Errorf("%d rows: part %d = %+v holding %q", tt.rows, i+1, part, data)
+			}
+			if part.Rows() > 0 && (part.FirstRow != next || !strings.HasPrefix(string(data), `{"id":`)) {
+				t.Errorf("%d rows: part %d starts at row %d, want %d", tt.rows, i+1, part.FirstRow, next)
+			}
+			next = part.LastRow + 1
+		}
+
+		manifest := archiver.newSplitManifest(partition, "events/events-2024-01-01.jsonl", ".jsonl", parts)
+		if manifest.TotalRows != int64(tt.rows) || manifest.Compression != "" || manifest.Parts[0].Key != "events/events-2024-01-01-part-0001.jsonl" {
+			t.Errorf("%d rows: unexpected manifest %+v", tt.rows, manifest)
+		}
+		first, _ := manifest.encode()
+		second, _ := archiver.newSplitManifest(partition, "events/events-2024-01-01.jsonl", ".jsonl", parts).encode()
+		if !bytes.Equal(first, second) {
+			t.Errorf("%d rows: manifest is not deterministic", tt.rows)
+		}
+		if tt.rows == 0 && bytes.Contains(first, []byte("first_row")) {
+			t.Errorf("empty part should have no row range: %s", first)
+		}
+		cleanupParts(parts)
+	}
+}
+
+func TestMaxRowsPerFileConfig(t *testing.T) {
+	config := newTestConfig()
+	config.MaxRowsPerFile = -1
+	if err := config.Validate(); !errors.Is(err, ErrMaxRowsPerFileInvalid) {
+		t.Errorf("expected ErrMaxRowsPerFileInvalid, got %v", err)
+	}
+	config.MaxRowsPerFile = 1000000
+	if err := config.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
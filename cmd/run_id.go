@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+)
+
+// runIDMetadataKey is the user metadata key (x-amz-meta-run-id) recording
+// which run uploaded an object
+const runIDMetadataKey = "run-id"
+
+// crockfordBase32 is the ULID alphabet
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// currentRunID identifies this process's run. Every table of a multi-table
+// run shares it, so logs, cache entries, results logs, progress events,
+// table commits, and uploaded objects from one invocation can be correlated
+// across hosts.
+var currentRunID = newRunID(time.Now())
+
+// newRunID returns a ULID for a run started at t: a 48-bit millisecond
+// timestamp followed by 80 random bits, as 26 Crockford base32 characters.
+// ULIDs sort by start time and do not collide across hosts.
+func newRunID(t time.Time) string {
+	var id [16]byte
+	ms := uint64(t.UnixMilli())
+	binary.BigEndian.PutUint16(id[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(ms))
+	_, _ = rand.Read(id[6:])
+
+	// 128 bits encode to 26 characters of 5 bits, the first holding only 3
+	var out [26]byte
+	hi := binary.BigEndian.Uint64(id[0:8])
+	lo := binary.BigEndian.Uint64(id[8:16])
+	for i := 25; i >= 0; i-- {
+		out[i] = crockfordBase32[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// runIDMetadata returns object metadata tagging an upload with this run's ID
+func runIDMetadata() map[string]string {
+	return map[string]string{runIDMetadataKey: currentRunID}
+}
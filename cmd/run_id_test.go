@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestNewRunID(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	id := newRunID(start)
+	if len(id) != 26 || strings.Trim(id, crockfordBase32) != "" {
+		t.Fatalf("newRunID() = %q, want 26 Crockford base32 characters", id)
+	}
+	// The first ten characters encode the millisecond timestamp
+	if id[:10] != newRunID(start)[:10] || id == newRunID(start) {
+		t.Errorf("IDs for the same start should share a timestamp and differ in randomness")
+	}
+	if later := newRunID(start.Add(time.Millisecond)); later <= id {
+		t.Errorf("later run ID %q should sort after %q", later, id)
+	}
+	if id := newRunID(time.UnixMilli(0)); !strings.HasPrefix(id, "0000000000") {
+		t.Errorf("epoch run ID = %q", id)
+	}
+}
+
+func TestCacheEntriesRecordRunID(t *testing.T) {
+	cache := &PartitionCache{Entries: make(map[string]PartitionCacheEntry)}
+	cache.setRowCount("events_20240101", 10)
+	cache.setError("events_20240102", "boom")
+	for name, entry := range cache.Entries {
+		if entry.RunID != currentRunID {
+			t.Errorf("%s: RunID = %q, want %q", name, entry.RunID, currentRunID)
+		}
+	}
+}
+
+func TestUploadsCarryRunID(t *testing.T) {
+	store := newFakeObjectStore(nil)
+	archiver := NewArchiver(&Config{S3: S3Config{Bucket: "bucket"}}, newTestLogger())
+	archiver.s3Client = newS3ObjectStore(store)
+
+	if err := archiver.uploadToS3("events/events.manifest.json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	tempFile := filepath.Join(t.TempDir(), "events.jsonl.zst")
+	if err := os.WriteFile(tempFile, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.uploadTempFileToS3(tempFile, "events/events.jsonl.zst"); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"events/events.manifest.json", "events/events.jsonl.zst"} {
+		if got := aws.StringValue(store.puts[key].Metadata[runIDMetadataKey]); got != currentRunID {
+			t.Errorf("%s: run-id metadata = %q, want %q", key, got, currentRunID)
+		}
+	}
+}
+
+func TestProgressEventsRecordRunID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "progress.jsonl")
+	events, err := openProgressEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events.emit(progressEvent{Type: progressEventRunStart})
+	_ = events.close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event progressEvent
+	if err := json.Unmarshal(bytes.TrimSpace(data), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.RunID != currentRunID {
+		t.Errorf("RunID = %q, want %q", event.RunID, currentRunID)
+	}
+}
@@ -0,0 +1,415 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// defaultRunManifestPrefix is where run manifests are written in the bucket
+const defaultRunManifestPrefix = "_data-archiver/manifests"
+
+// Static errors for run manifests
+var (
+	ErrRunManifestPrefixRequired = errors.New("run manifest prefix is required")
+	ErrRunManifestNotFound       = errors.New("no run manifest found")
+	ErrRunManifestTampered       = errors.New("run manifest does not match its checksum")
+	ErrRunManifestVerifyFailed   = errors.New("bucket contents do not match the run manifest")
+	ErrRunManifestTargetRequired = errors.New("--manifest or --table is required")
+	ErrRunManifestOutputFormat   = errors.New("verify-manifest output format must be one of: text, json")
+)
+
+var (
+	runManifestEnabled      bool
+	runManifestPrefix       string
+	verifyManifestKey       string
+	verifyManifestTable     string
+	verifyManifestOutFormat string
+)
+
+var verifyManifestCmd = &cobra.Command{
+	Use:   "verify-manifest",
+	Short: "Check the bucket against the manifest of an archive run",
+	Long: `Check that every file an archive run with --run-manifest uploaded is still in the bucket with the
+size and checksum the run recorded. Give the manifest's key with --manifest, or a --table to check
+the table's latest manifest. The manifest's own checksum is checked first, so an edited manifest
+is reported rather than trusted.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		runVerifyManifest(cmd)
+	},
+}
+
+func init() {
+	archiveCmd.Flags().BoolVar(&runManifestEnabled, "run-manifest", false, "write a manifest of every file the run uploads (key, MD5 or multipart ETag, sizes, rows) to the bucket")
+	archiveCmd.Flags().StringVar(&runManifestPrefix, "run-manifest-prefix", defaultRunManifestPrefix, "bucket prefix for run manifests")
+	_ = viper.BindPFlag("run_manifest.enabled", archiveCmd.Flags().Lookup("run-manifest"))
+	_ = viper.BindPFlag("run_manifest.prefix", archiveCmd.Flags().Lookup("run-manifest-prefix"))
+
+	rootCmd.AddCommand(verifyManifestCmd)
+
+	// S3 flags
+	verifyManifestCmd.Flags().StringVar(&s3Endpoint, "s3-endpoint", "", "S3-compatible endpoint URL")
+	verifyManifestCmd.Flags().StringVar(&s3Bucket, "s3-bucket", "", "S3 bucket name")
+	verifyManifestCmd.Flags().StringVar(&s3AccessKey, "s3-access-key", "", "S3 access key")
+	verifyManifestCmd.Flags().StringVar(&s3SecretKey, "s3-secret-key", "", "S3 secret key")
+	verifyManifestCmd.Flags().StringVar(&s3Region, "s3-region", "auto", "S3 region")
+
+	// Manifest-specific flags
+	verifyManifestCmd.Flags().StringVar(&verifyManifestKey, "manifest", "", "key of the run manifest to check")
+	verifyManifestCmd.Flags().StringVar(&verifyManifestTable, "table", "", "check the latest run manifest of this table")
+	verifyManifestCmd.Flags().StringVar(&runManifestPrefix, "run-manifest-prefix", defaultRunManifestPrefix, "bucket prefix for run manifests")
+	verifyManifestCmd.Flags().StringVar(&verifyManifestOutFormat, "output-format", "text", "Output format: text, json")
+}
+
+// runManifestFile is one uploaded file in a run manifest
+type runManifestFile struct {
+	Key              string `json:"key"`
+	MD5              string `json:"md5"`
+	MultipartETag    string `json:"multipart_etag,omitempty"` // ETag S3 gives the file when uploaded in parts
+	Size             int64  `json:"size"`
+	UncompressedSize int64  `json:"uncompressed_size"`
+	Rows             int64  `json:"rows"`
+}
+
+// runManifest lists the files one archive run uploaded for a table. Checksum
+// is the SHA-256 of the files list, so a manifest edited after the run is
+// caught by verify-manifest.
+type runManifest struct {
+	RunID      string            `json:"run_id"`
+	Table      string            `json:"table"`
+	Bucket     string            `json:"bucket"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	Masking    *maskingRecord    `json:"masking,omitempty"` // Columns masked with --mask
+	Filter     string            `json:"filter,omitempty"`  // --filter condition the archived rows matched
+	Files      []runManifestFile `json:"files"`
+	Checksum   string            `json:"checksum"`
+
+	mu sync.Mutex
+}
+
+func newRunManifest(config *Config, started time.Time) *runManifest {
+	return &runManifest{
+		RunID:     currentRunID,
+		Table:     config.Table,
+		Bucket:    config.S3.Bucket,
+		StartedAt: started.UTC(),
+		Masking:   config.maskingRecord(),
+		Filter:    config.Filter,
+		Files:     []runManifestFile{},
+	}
+}
+
+// add records an uploaded file; a key uploaded again replaces its entry
+func (m *runManifest) add(file runManifestFile) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.Files {
+		if m.Files[i].Key == file.Key {
+			m.Files[i] = file
+			return
+		}
+	}
+	m.Files = append(m.Files, file)
+}
+
+// filesChecksum returns the SHA-256 of the files list as it is encoded
+func filesChecksum(files []runManifestFile) (string, error) {
+	data, err := json.Marshal(files)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode manifest files: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// encode sorts the files by key, sets the checksum, and renders the manifest
+func (m *runManifest) encode(finished time.Time) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Key < m.Files[j].Key })
+	m.FinishedAt = finished.UTC()
+	checksum, err := filesChecksum(m.Files)
+	if err != nil {
+		return nil, err
+	}
+	m.Checksum = checksum
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode run manifest: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// runManifestKey returns the object key of a run's manifest for table:
+// <prefix>/<table>/manifest-YYYYMMDD-<run id>.json. Run IDs sort by start
+// time, so a table's manifests list in the order the runs started.
+func runManifestKey(prefix, table string, started time.Time, runID string) string {
+	name := fmt.Sprintf("manifest-%s-%s.json", started.UTC().Format("20060102"), runID)
+	return path.Join(strings.Trim(prefix, "/"), objectKeyComponent(table), name)
+}
+
+// recordRunManifest adds an uploaded file to the run manifest. Without
+// --run-manifest it does nothing.
+func (a *Archiver) recordRunManifest(objectKey, md5Hash, multipartETag string, size, uncompressedSize, rows int64) {
+	a.manifest.add(runManifestFile{
+		Key:              objectKey,
+		MD5:              md5Hash,
+		MultipartETag:    multipartETag,
+		Size:             size,
+		UncompressedSize: uncompressedSize,
+		Rows:             rows,
+	})
+}
+
+// runManifestETag returns the multipart ETag of a temp file uploaded in
+// parts, or "" when it's uploaded whole
+func (a *Archiver) runManifestETag(filePath string, size int64) string {
+	if size <= multipartUploadThreshold {
+		return ""
+	}
+	etag, err := a.tempFileMultipartETag(filePath)
+	a.etags.forget(filePath)
+	if err != nil {
+		a.logger.Debug(fmt.Sprintf("      ⚠️  Failed to calculate multipart ETag for the run manifest: %v", err))
+		return ""
+	}
+	return etag
+}
+
+// writeRunManifest uploads the run manifest once the run ends, cancelled runs
+// included. A run that uploaded nothing writes no manifest.
+func (a *Archiver) writeRunManifest() {
+	if a.manifest == nil || a.s3Client == nil || a.config.DryRun {
+		return
+	}
+	a.manifest.mu.Lock()
+	empty := len(a.manifest.Files) == 0
+	a.manifest.mu.Unlock()
+	if empty {
+		return
+	}
+
+	data, err := a.manifest.encode(time.Now())
+	if err != nil {
+		a.logger.Warn(fmt.Sprintf("⚠️  Run manifest not written: %v", err))
+		return
+	}
+	key := runManifestKey(a.config.RunManifestPrefix, a.config.Table, a.manifest.StartedAt, a.manifest.RunID)
+
+	// Write the manifest even if the run was cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, err = a.s3Client.Put(ctx, a.config.S3.Bucket, key, bytes.NewReader(data), PutOptions{ContentType: "application/json", Metadata: runIDMetadata()})
+	if err != nil {
+		a.logger.Warn(fmt.Sprintf("⚠️  Run manifest not written to s3://%s/%s: %v", a.config.S3.Bucket, key, err))
+		return
+	}
+	a.logger.Info(fmt.Sprintf("🧾 Run manifest of %d files at s3://%s/%s", len(a.manifest.Files), a.config.S3.Bucket, key))
+}
+
+// readRunManifest downloads and decodes a run manifest
+func readRunManifest(ctx context.Context, client StorageBackend, bucket, key string) (*runManifest, error) {
+	body, _, err := client.Get(ctx, bucket, key, GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run manifest %s: %w", key, err)
+	}
+	defer body.Close()
+	var manifest runManifest
+	if err := json.NewDecoder(body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse run manifest %s: %w", key, err)
+	}
+	return &manifest, nil
+}
+
+// latestRunManifestKey returns the key of the newest manifest of table
+func latestRunManifestKey(ctx context.Context, client StorageBackend, bucket, prefix, table string) (string, error) {
+	dir := path.Join(strings.Trim(prefix, "/"), objectKeyComponent(table)) + "/"
+	latest := ""
+	err := client.List(ctx, bucket, dir+"manifest-", func(object ObjectInfo) bool {
+		if key := object.Key; strings.HasSuffix(key, ".json") && key > latest {
+			latest = key
+		}
+		return true
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list run manifests: %w", err)
+	}
+	if latest == "" {
+		return "", fmt.Errorf("%w for table '%s' under s3://%s/%s", ErrRunManifestNotFound, table, bucket, dir)
+	}
+	return latest, nil
+}
+
+// manifestVerifyReport is the outcome of verify-manifest
+type manifestVerifyReport struct {
+	Manifest string           `json:"manifest"`
+	RunID    string           `json:"run_id"`
+	Table    string           `json:"table"`
+	Files    int              `json:"files"`
+	Verified int              `json:"verified"`
+	Issues   []integrityIssue `json:"issues"`
+}
+
+// verifyRunManifest checks the manifest's checksum and then each file it
+// lists: the object must exist with the recorded size, and its ETag must be
+// the recorded MD5, or for multipart uploads the recorded multipart ETag
+func verifyRunManifest(ctx context.Context, client StorageBackend, bucket, key string, manifest *runManifest) (*manifestVerifyReport, error) {
+	report := &manifestVerifyReport{Manifest: key, RunID: manifest.RunID, Table: manifest.Table, Files: len(manifest.Files), Issues: []integrityIssue{}}
+	checksum, err := filesChecksum(manifest.Files)
+	if err != nil {
+		return nil, err
+	}
+	if checksum != manifest.Checksum {
+		report.Issues = append(report.Issues, integrityIssue{Kind: IntegrityIssueModified, Key: key,
+			Message: fmt.Sprintf("%v: %s", ErrRunManifestTampered, key)})
+	}
+
+	for _, file := range manifest.Files {
+		head, definite, err := headObject(ctx, client, bucket, file.Key)
+		if err != nil || !definite {
+			return report, fmt.Errorf("failed to check %s: %w", file.Key, err)
+		}
+		etag := strings.Trim(head.ETag, `"`)
+		switch {
+		case !head.Exists:
+			report.Issues = append(report.Issues, integrityIssue{Kind: IntegrityIssueObjectMissing, Key: file.Key,
+				Message: fmt.Sprintf("%s is not in the bucket", file.Key)})
+		case head.Size != file.Size:
+			report.Issues = append(report.Issues, integrityIssue{Kind: IntegrityIssueObjectChanged, Key: file.Key,
+				Message: fmt.Sprintf("%s is %d bytes, recorded as %d", file.Key, head.Size, file.Size)})
+		case strings.Contains(etag, "-") && file.MultipartETag != "" && etag != file.MultipartETag:
+			report.Issues = append(report.Issues, integrityIssue{Kind: IntegrityIssueObjectChanged, Key: file.Key,
+				Message: fmt.Sprintf("%s has ETag %s, recorded as %s", file.Key, etag, file.MultipartETag)})
+		case !strings.Contains(etag, "-") && etag != file.MD5:
+			report.Issues = append(report.Issues, integrityIssue{Kind: IntegrityIssueObjectChanged, Key: file.Key,
+				Message: fmt.Sprintf("%s has MD5 %s, recorded as %s", file.Key, etag, file.MD5)})
+		default:
+			report.Verified++
+		}
+	}
+	return report, nil
+}
+
+// writeManifestVerifyText renders a report for the terminal
+func writeManifestVerifyText(w io.Writer, report *manifestVerifyReport) {
+	fmt.Fprintf(w, "%s (run %s, table %s): %d of %d files verified\n", report.Manifest, report.RunID, report.Table, report.Verified, report.Files)
+	if len(report.Issues) == 0 {
+		fmt.Fprintln(w, "  ✅ No problems found")
+		return
+	}
+	for _, issue := range report.Issues {
+		fmt.Fprintf(w, "  ❌ %-18s %s\n", issue.Kind, issue)
+	}
+}
+
+func runVerifyManifest(cmd *cobra.Command) {
+	getStringConfig := func(flagValue string, flagName string, viperKey string) string {
+		if flag := cmd.Flags().Lookup(flagName); flag != nil && flag.Changed {
+			return flagValue
+		}
+		if viperValue := viper.GetString(viperKey); viperValue != "" {
+			return viperValue
+		}
+		return flagValue
+	}
+
+	s3Config, s3Err := newS3Config(S3Config{
+		Endpoint:  getStringConfig(s3Endpoint, "s3-endpoint", "s3.endpoint"),
+		Bucket:    getStringConfig(s3Bucket, "s3-bucket", "s3.bucket"),
+		AccessKey: getStringConfig(s3AccessKey, "s3-access-key", "s3.access_key"),
+		SecretKey: getStringConfig(s3SecretKey, "s3-secret-key", "s3.secret_key"),
+		Region:    getStringConfig(s3Region, "s3-region", "s3.region"),
+	}, endpointProfileName("verify-manifest"))
+	table := verifyManifestTable
+	prefix := getStringConfig(runManifestPrefix, "run-manifest-prefix", "run_manifest.prefix")
+
+	initLogger(viper.GetBool("debug"), viper.GetString("log_format"))
+
+	if s3Err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", s3Err.Error()))
+		os.Exit(1)
+	}
+	if err := validateVerifyManifestConfig(s3Config, verifyManifestKey, table, prefix); err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	ctx := signalContext
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	client, err := newStorageClient(s3Config)
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ Failed to create storage client: %v", err))
+		os.Exit(1)
+	}
+	key := verifyManifestKey
+	if key == "" {
+		if key, err = latestRunManifestKey(ctx, client, s3Config.Bucket, prefix, table); err != nil {
+			logger.Error(fmt.Sprintf("❌ %v", err))
+			os.Exit(1)
+		}
+	}
+	manifest, err := readRunManifest(ctx, client, s3Config.Bucket, key)
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ %v", err))
+		os.Exit(1)
+	}
+	report, err := verifyRunManifest(ctx, client, s3Config.Bucket, key, manifest)
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ %v", err))
+		os.Exit(1)
+	}
+
+	if verifyManifestOutFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(report)
+	} else {
+		writeManifestVerifyText(os.Stdout, report)
+	}
+	if len(report.Issues) > 0 {
+		logger.Error(fmt.Sprintf("❌ %v: %d", ErrRunManifestVerifyFailed, len(report.Issues)))
+		os.Exit(1)
+	}
+}
+
+// validateVerifyManifestConfig checks the S3 settings and report options
+func validateVerifyManifestConfig(s3Config S3Config, key, table, prefix string) error {
+	if s3Config.Bucket == "" {
+		return ErrS3BucketRequired
+	}
+	if err := s3Config.validateCredentials(); err != nil {
+		return err
+	}
+	if key == "" && table == "" {
+		return ErrRunManifestTargetRequired
+	}
+	if key == "" && strings.Trim(prefix, "/") == "" {
+		return ErrRunManifestPrefixRequired
+	}
+	if verifyManifestOutFormat != "text" && verifyManifestOutFormat != "json" {
+		return fmt.Errorf("%w: '%s'", ErrRunManifestOutputFormat, verifyManifestOutFormat)
+	}
+	return s3Config.HTTP.Validate()
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunManifestKey(t *testing.T) {
+	started := time.Date(2024, 3, 9, 23, 30, 0, 0, time.FixedZone("EST", -5*3600))
+	key := runManifestKey("/"+defaultRunManifestPrefix+"/", "Flights", started, "01HQ")
+	if key != "_data-archiver/manifests/Flights/manifest-20240310-01HQ.json" {
+		t.Errorf("runManifestKey() = %s", key)
+	}
+}
+
+func TestRunManifestWriteAndVerify(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeObjectStore{objects: map[string][]byte{}}
+	config := newTestConfig()
+	config.Table = "events"
+	config.RunManifest = true
+	config.RunManifestPrefix = defaultRunManifestPrefix
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	archiver := NewArchiver(config, newTestLogger())
+	archiver.s3Client = newS3ObjectStore(store)
+
+	// A run that uploaded nothing writes no manifest
+	archiver.writeRunManifest()
+	if len(store.objects) != 0 {
+		t.Fatalf("empty run wrote %v", store.objects)
+	}
+
+	first := putArchiveObject(store, "events/2024/01/01/events-2024-01-01.jsonl.zst", "first day")
+	second := putArchiveObject(store, "events/2024/01/02/events-2024-01-02.jsonl.zst", "second day")
+	archiver.recordRunManifest("events/2024/01/02/events-2024-01-02.jsonl.zst", "stale", "", 1, 1, 1)
+	archiver.recordRunManifest("events/2024/01/02/events-2024-01-02.jsonl.zst", second, "", 10, 100, 2)
+	archiver.recordRunManifest("events/2024/01/01/events-2024-01-01.jsonl.zst", first, "", 9, 90, 1)
+	archiver.writeRunManifest()
+
+	key, err := latestRunManifestKey(ctx, newS3ObjectStore(store), "test-bucket", defaultRunManifestPrefix, "events")
+	if err != nil {
+		t.Fatalf("latestRunManifestKey() error = %v", err)
+	}
+	if !strings.HasPrefix(key, "_data-archiver/manifests/events/manifest-") || !strings.HasSuffix(key, "-"+currentRunID+".json") {
+		t.Errorf("manifest key = %s", key)
+	}
+	manifest, err := readRunManifest(ctx, newS3ObjectStore(store), "test-bucket", key)
+	if err != nil {
+		t.Fatalf("readRunManifest() error = %v", err)
+	}
+	if manifest.RunID != currentRunID || manifest.Table != "events" || len(manifest.Files) != 2 {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+	if got := manifest.Files[1]; got.MD5 != second || got.Size != 10 || got.UncompressedSize != 100 || got.Rows != 2 {
+		t.Errorf("re-uploaded file = %+v", got)
+	}
+
+	report, err := verifyRunManifest(ctx, newS3ObjectStore(store), "test-bucket", key, manifest)
+	if err != nil {
+		t.Fatalf("verifyRunManifest() error = %v", err)
+	}
+	if report.Verified != 2 || len(report.Issues) != 0 {
+		t.Errorf("report = %+v", report)
+	}
+
+	// A changed object, a missing object, and an edited manifest are reported
+	store.objects["events/2024/01/01/events-2024-01-01.jsonl.zst"] = []byte("first dax")
+	delete(store.objects, "events/2024/01/02/events-2024-01-02.jsonl.zst")
+	manifest.Files[0].Rows = 100
+	report, err = verifyRunManifest(ctx, newS3ObjectStore(store), "test-bucket", key, manifest)
+	if err != nil {
+		t.Fatalf("verifyRunManifest() error = %v", err)
+	}
+	kinds := []string{}
+	for _, issue := range report.Issues {
+		kinds = append(kinds, issue.Kind)
+	}
+	want := []string{IntegrityIssueModified, IntegrityIssueObjectChanged, IntegrityIssueObjectMissing}
+	if strings.Join(kinds, ",") != strings.Join(want, ",") || report.Verified != 0 {
+		t.Errorf("issues = %v, want %v", kinds, want)
+	}
+	if _, err := json.Marshal(report); err != nil {
+		t.Errorf("report does not encode: %v", err)
+	}
+
+	if _, err := latestRunManifestKey(ctx, newS3ObjectStore(store), "test-bucket", defaultRunManifestPrefix, "flights"); !errors.Is(err, ErrRunManifestNotFound) {
+		t.Errorf("expected ErrRunManifestNotFound, got %v", err)
+	}
+}
+
+func TestRunManifestConfigValidation(t *testing.T) {
+	config := newTestConfig()
+	config.RunManifest = true
+	config.RunManifestPrefix = "/"
+	if err := config.Validate(); !errors.Is(err, ErrRunManifestPrefixRequired) {
+		t.Errorf("expected ErrRunManifestPrefixRequired, got %v", err)
+	}
+
+	s3Config := newTestConfig().S3
+	verifyManifestOutFormat = "text"
+	if err := validateVerifyManifestConfig(s3Config, "", "", defaultRunManifestPrefix); !errors.Is(err, ErrRunManifestTargetRequired) {
+		t.Errorf("expected ErrRunManifestTargetRequired, got %v", err)
+	}
+	verifyManifestOutFormat = "yaml"
+	defer func() { verifyManifestOutFormat = "text" }()
+	if err := validateVerifyManifestConfig(s3Config, "", "events", defaultRunManifestPrefix); !errors.Is(err, ErrRunManifestOutputFormat) {
+		t.Errorf("expected ErrRunManifestOutputFormat, got %v", err)
+	}
+}
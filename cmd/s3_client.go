@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/spf13/viper"
+)
+
+// Defaults match net/http's DefaultTransport, except for idle connections per
+// host: the default of 2 forces parallel uploads to reconnect constantly.
+const (
+	defaultS3DialTimeout         = 30 * time.Second
+	defaultS3KeepAlive           = 30 * time.Second
+	defaultS3TLSHandshakeTimeout = 10 * time.Second
+	defaultS3IdleConnTimeout     = 90 * time.Second
+	defaultS3MaxIdleConnsPerHost = 100
+)
+
+// S3 addressing styles: path puts the bucket in the URL path
+// (endpoint/bucket/key), virtual in the host name (bucket.endpoint/key), and
+// auto picks virtual for AWS endpoints and path for everything else
+const (
+	S3AddressingPath    = "path"
+	S3AddressingVirtual = "virtual"
+	S3AddressingAuto    = "auto"
+)
+
+// Static errors for S3 HTTP client configuration
+var (
+	ErrS3TimeoutInvalid      = errors.New("S3 HTTP timeouts must be >= 0")
+	ErrS3MaxIdleConnsInvalid = errors.New("S3 max idle connections per host must be >= 0")
+	ErrS3MaxRetriesInvalid   = errors.New("S3 max retries must be >= -1")
+	ErrS3AddressingInvalid   = errors.New("S3 addressing style must be one of: path, virtual, auto")
+	ErrS3RegionUndetected    = errors.New("S3 region could not be detected; set --s3-region, AWS_REGION, or a region in the AWS profile")
+)
+
+// regionLookupTimeout bounds the request that asks S3 for a bucket's region
+const regionLookupTimeout = 30 * time.Second
+
+// S3HTTPConfig tunes the HTTP client used for S3 requests. A zero timeout
+// disables that timeout.
+type S3HTTPConfig struct {
+	DialTimeout           time.Duration
+	KeepAlive             time.Duration // TCP keep-alive probe interval (negative disables)
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration // Time to wait for response headers after sending a request
+	IdleConnTimeout       time.Duration // How long idle keep-alive connections stay pooled
+	MaxIdleConnsPerHost   int           // 0 uses defaultS3MaxIdleConnsPerHost
+	MaxRetries            int           // SDK retries per request (-1 uses the SDK default)
+	AddressingStyle       string        // path, virtual, or auto ("" = path)
+	RequesterPays         bool          // Send x-amz-request-payer: requester with every request
+}
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.Duration("s3-dial-timeout", defaultS3DialTimeout, "S3 connection timeout (0 = none)")
+	flags.Duration("s3-keep-alive", defaultS3KeepAlive, "S3 TCP keep-alive interval (negative disables)")
+	flags.Duration("s3-tls-handshake-timeout", defaultS3TLSHandshakeTimeout, "S3 TLS handshake timeout (0 = none)")
+	flags.Duration("s3-response-header-timeout", 0, "time to wait for S3 response headers after a request is sent (0 = none)")
+	flags.Duration("s3-idle-conn-timeout", defaultS3IdleConnTimeout, "how long idle S3 connections are kept for reuse (0 = no limit)")
+	flags.Int("s3-max-idle-conns-per-host", defaultS3MaxIdleConnsPerHost, "idle S3 connections kept per host for reuse; raise with parallel uploads")
+	flags.Int("s3-max-retries", -1, "retries per failed S3 request (-1 = SDK default)")
+	flags.String("s3-addressing-style", S3AddressingPath, "S3 bucket addressing: path (endpoint/bucket), virtual (bucket.endpoint), auto (virtual for AWS endpoints, path otherwise)")
+	flags.Bool("s3-requester-pays", false, "accept requester-pays charges on every S3 request (x-amz-request-payer: requester)")
+	flags.String("s3-profile", "", "AWS shared config profile for S3 credentials and region (used when no static access key is set)")
+
+	_ = viper.BindPFlag("s3.http.dial_timeout", flags.Lookup("s3-dial-timeout"))
+	_ = viper.BindPFlag("s3.http.keep_alive", flags.Lookup("s3-keep-alive"))
+	_ = viper.BindPFlag("s3.http.tls_handshake_timeout", flags.Lookup("s3-tls-handshake-timeout"))
+	_ = viper.BindPFlag("s3.http.response_header_timeout", flags.Lookup("s3-response-header-timeout"))
+	_ = viper.BindPFlag("s3.http.idle_conn_timeout", flags.Lookup("s3-idle-conn-timeout"))
+	_ = viper.BindPFlag("s3.http.max_idle_conns_per_host", flags.Lookup("s3-max-idle-conns-per-host"))
+	_ = viper.BindPFlag("s3.http.max_retries", flags.Lookup("s3-max-retries"))
+	_ = viper.BindPFlag("s3.addressing_style", flags.Lookup("s3-addressing-style"))
+	_ = viper.BindPFlag("s3.requester_pays", flags.Lookup("s3-requester-pays"))
+	_ = viper.BindPFlag("s3.profile", flags.Lookup("s3-profile"))
+}
+
+// loadS3HTTPConfig reads the S3 HTTP and addressing settings shared by every
+// command
+func loadS3HTTPConfig() S3HTTPConfig {
+	return S3HTTPConfig{
+		DialTimeout:           viper.GetDuration("s3.http.dial_timeout"),
+		KeepAlive:             viper.GetDuration("s3.http.keep_alive"),
+		TLSHandshakeTimeout:   viper.GetDuration("s3.http.tls_handshake_timeout"),
+		ResponseHeaderTimeout: viper.GetDuration("s3.http.response_header_timeout"),
+		IdleConnTimeout:       viper.GetDuration("s3.http.idle_conn_timeout"),
+		MaxIdleConnsPerHost:   viper.GetInt("s3.http.max_idle_conns_per_host"),
+		MaxRetries:            viper.GetInt("s3.http.max_retries"),
+		AddressingStyle:       viper.GetString("s3.addressing_style"),
+		RequesterPays:         viper.GetBool("s3.requester_pays"),
+	}
+}
+
+// Validate checks the timeouts, pool size, retry count, and addressing style
+func (c S3HTTPConfig) Validate() error {
+	for name, timeout := range map[string]time.Duration{
+		"dial":            c.DialTimeout,
+		"TLS handshake":   c.TLSHandshakeTimeout,
+		"response header": c.ResponseHeaderTimeout,
+		"idle connection": c.IdleConnTimeout,
+	} {
+		if timeout < 0 {
+			return fmt.Errorf("%w, got %s timeout %s", ErrS3TimeoutInvalid, name, timeout)
+		}
+	}
+	if c.MaxIdleConnsPerHost < 0 {
+		return fmt.Errorf("%w, got %d", ErrS3MaxIdleConnsInvalid, c.MaxIdleConnsPerHost)
+	}
+	if c.MaxRetries < -1 {
+		return fmt.Errorf("%w, got %d", ErrS3MaxRetriesInvalid, c.MaxRetries)
+	}
+	switch c.AddressingStyle {
+	case "", S3AddressingPath, S3AddressingVirtual, S3AddressingAuto:
+	default:
+		return fmt.Errorf("%w, got '%s'", ErrS3AddressingInvalid, c.AddressingStyle)
+	}
+	return nil
+}
+
+// forcePathStyle reports whether requests to endpoint address the bucket in
+// the URL path
+func (c S3HTTPConfig) forcePathStyle(endpoint string) bool {
+	switch c.AddressingStyle {
+	case S3AddressingVirtual:
+		return false
+	case S3AddressingAuto:
+		return !isAWSEndpoint(endpoint)
+	default:
+		return true
+	}
+}
+
+// isAWSEndpoint reports whether endpoint is AWS S3; an empty endpoint uses
+// the regional AWS endpoint
+func isAWSEndpoint(endpoint string) bool {
+	if endpoint == "" {
+		return true
+	}
+	host := endpoint
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		host = u.Hostname()
+	}
+	return strings.HasSuffix(strings.ToLower(host), ".amazonaws.com")
+}
+
+// newHTTPClient builds the HTTP client for S3 requests. There is no overall
+// request timeout, since a single multipart upload can legitimately take a
+// long time; the dial, TLS, and response header timeouts catch stalled peers.
+func (c S3HTTPConfig) newHTTPClient() *http.Client {
+	maxIdlePerHost := c.MaxIdleConnsPerHost
+	if maxIdlePerHost == 0 {
+		maxIdlePerHost = defaultS3MaxIdleConnsPerHost
+	}
+	dialer := &net.Dialer{
+		Timeout:   c.DialTimeout,
+		KeepAlive: c.KeepAlive,
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			TLSHandshakeTimeout:   c.TLSHandshakeTimeout,
+			ResponseHeaderTimeout: c.ResponseHeaderTimeout,
+			IdleConnTimeout:       c.IdleConnTimeout,
+			MaxIdleConns:          max(maxIdlePerHost, 100),
+			MaxIdleConnsPerHost:   maxIdlePerHost,
+			ExpectContinueTimeout: time.Second,
+			// gzip archives are uploaded with Content-Encoding: gzip; read
+			// them back as stored instead of transparently decompressed
+			DisableCompression: true,
+		},
+	}
+}
+
+// requesterPaysHandler marks every request as accepting requester-pays
+// charges. It runs for all operations, including multipart upload parts.
+var requesterPaysHandler = request.NamedHandler{
+	Name: "data-archiver.RequesterPays",
+	Fn: func(r *request.Request) {
+		r.HTTPRequest.Header.Set("x-amz-request-payer", s3.RequestPayerRequester)
+	},
+}
+
+// validateCredentials checks the storage backend and that static keys are
+// given together. Without them, credentials come from the AWS default chain.
+func (c S3Config) validateCredentials() error {
+	if err := validateStorageBackend(c.Backend); err != nil {
+		return err
+	}
+	if c.AccessKey == "" && c.SecretKey != "" {
+		return ErrS3AccessKeyRequired
+	}
+	if c.AccessKey != "" && c.SecretKey == "" {
+		return ErrS3SecretKeyRequired
+	}
+	return nil
+}
+
+// newAWSSession creates a session from awsConfig. Unless awsConfig carries
+// static credentials, they come from the AWS default chain: environment
+// variables, the shared config and credentials files (for profile, or
+// AWS_PROFILE), web identity tokens (EKS IRSA), and ECS or EC2 instance roles.
+func newAWSSession(awsConfig *aws.Config, profile string) (*session.Session, error) {
+	return session.NewSessionWithOptions(session.Options{
+		Config:            *awsConfig,
+		Profile:           profile,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+}
+
+// newS3Session creates an AWS session for S3 or an S3-compatible endpoint
+// using static keys when set, or else the AWS default credential chain, and
+// the configured HTTP client settings
+func newS3Session(cfg S3Config) (*session.Session, error) {
+	awsConfig := &aws.Config{
+		S3ForcePathStyle: aws.Bool(cfg.HTTP.forcePathStyle(cfg.Endpoint)),
+		HTTPClient:       cfg.HTTP.newHTTPClient(),
+	}
+	if cfg.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(cfg.Endpoint)
+	}
+	if cfg.AccessKey != "" {
+		awsConfig.Credentials = credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, "")
+	}
+	// Explicit endpoints take the region as given ("auto" for R2); without
+	// one (AWS), auto comes from the environment, the profile, or the bucket
+	detectRegion := cfg.Endpoint == "" && (cfg.Region == "" || cfg.Region == regionAuto)
+	if !detectRegion {
+		awsConfig.Region = aws.String(cfg.Region)
+	}
+	if cfg.HTTP.MaxRetries >= 0 {
+		awsConfig.MaxRetries = aws.Int(cfg.HTTP.MaxRetries)
+	}
+	sess, err := newAWSSession(awsConfig, cfg.Profile)
+	if err != nil {
+		return nil, err
+	}
+	if detectRegion && aws.StringValue(sess.Config.Region) == "" {
+		region, err := lookupBucketRegion(sess, cfg.Bucket)
+		if err != nil {
+			return nil, err
+		}
+		sess.Config.Region = aws.String(region)
+	}
+	if cfg.HTTP.RequesterPays {
+		// Build runs before signing, so the header is covered by the signature
+		sess.Handlers.Build.PushBackNamed(requesterPaysHandler)
+	}
+	return sess, nil
+}
+
+// lookupBucketRegion asks S3 which region bucket is in
+func lookupBucketRegion(sess *session.Session, bucket string) (string, error) {
+	if bucket == "" {
+		return "", ErrS3RegionUndetected
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), regionLookupTimeout)
+	defer cancel()
+	region, err := s3manager.GetBucketRegion(ctx, sess, bucket, "us-east-1")
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrS3RegionUndetected, err)
+	}
+	return region, nil
+}
@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestS3HTTPConfigValidate(t *testing.T) {
+	valid := S3HTTPConfig{
+		DialTimeout:         defaultS3DialTimeout,
+		TLSHandshakeTimeout: defaultS3TLSHandshakeTimeout,
+		IdleConnTimeout:     defaultS3IdleConnTimeout,
+		MaxIdleConnsPerHost: defaultS3MaxIdleConnsPerHost,
+		MaxRetries:          -1,
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(c *S3HTTPConfig)
+		wantErr error
+	}{
+		{"Defaults", func(_ *S3HTTPConfig) {}, nil},
+		{"ZeroValue", func(c *S3HTTPConfig) { *c = S3HTTPConfig{} }, nil},
+		{"NegativeKeepAliveDisables", func(c *S3HTTPConfig) { c.KeepAlive = -1 }, nil},
+		{"NegativeDial", func(c *S3HTTPConfig) { c.DialTimeout = -time.Second }, ErrS3TimeoutInvalid},
+		{"NegativeResponseHeader", func(c *S3HTTPConfig) { c.ResponseHeaderTimeout = -time.Second }, ErrS3TimeoutInvalid},
+		{"NegativeIdleConns", func(c *S3HTTPConfig) { c.MaxIdleConnsPerHost = -1 }, ErrS3MaxIdleConnsInvalid},
+		{"RetriesBelowDefault", func(c *S3HTTPConfig) { c.MaxRetries = -2 }, ErrS3MaxRetriesInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid
+			tt.modify(&c)
+			if err := c.Validate(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestS3HTTPConfigNewHTTPClient(t *testing.T) {
+	c := S3HTTPConfig{
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+		IdleConnTimeout:       2 * time.Minute,
+		MaxIdleConnsPerHost:   256,
+	}
+	transport, ok := c.newHTTPClient().Transport.(*http.Transport)
+	if !ok {
+		t.Fatal("expected an *http.Transport")
+	}
+	if transport.TLSHandshakeTimeout != 5*time.Second || transport.ResponseHeaderTimeout != time.Minute || transport.IdleConnTimeout != 2*time.Minute {
+		t.Errorf("timeouts not applied: %+v", transport)
+	}
+	if transport.MaxIdleConnsPerHost != 256 || transport.MaxIdleConns < 256 {
+		t.Errorf("pool sizes = %d/%d, want 256 per host", transport.MaxIdleConnsPerHost, transport.MaxIdleConns)
+	}
+	if !transport.DisableCompression {
+		t.Error("gzip-encoded archives should be downloaded as stored")
+	}
+
+	transport = S3HTTPConfig{}.newHTTPClient().Transport.(*http.Transport)
+	if transport.MaxIdleConnsPerHost != defaultS3MaxIdleConnsPerHost {
+		t.Errorf("zero value: MaxIdleConnsPerHost = %d, want %d", transport.MaxIdleConnsPerHost, defaultS3MaxIdleConnsPerHost)
+	}
+}
+
+func TestNewS3SessionMaxRetries(t *testing.T) {
+	cfg := S3Config{Endpoint: "http://localhost:9000", Region: "auto", HTTP: S3HTTPConfig{MaxRetries: 7}}
+	sess, err := newS3Session(cfg)
+	if err != nil {
+		t.Fatalf("newS3Session() error = %v", err)
+	}
+	if got := *sess.Config.MaxRetries; got != 7 {
+		t.Errorf("MaxRetries = %d, want 7", got)
+	}
+
+	cfg.HTTP.MaxRetries = -1
+	sess, err = newS3Session(cfg)
+	if err != nil {
+		t.Fatalf("newS3Session() error = %v", err)
+	}
+	if sess.Config.HTTPClient == nil {
+		t.Error("expected the tuned HTTP client to be used")
+	}
+	if sess.Config.MaxRetries != nil && *sess.Config.MaxRetries != -1 {
+		t.Errorf("MaxRetries = %d, want SDK default", *sess.Config.MaxRetries)
+	}
+}
+
+func TestNewS3SessionAddressing(t *testing.T) {
+	headRequest := func(cfg S3Config) *http.Request {
+		t.Helper()
+		sess, err := newS3Session(cfg)
+		if err != nil {
+			t.Fatalf("newS3Session() error = %v", err)
+		}
+		req, _ := s3.New(sess).HeadObjectRequest(&s3.HeadObjectInput{Bucket: aws.String("archives"), Key: aws.String("events/a.jsonl")})
+		if err := req.Build(); err != nil {
+			t.Fatalf("Build() error = %v", err)
+		}
+		return req.HTTPRequest
+	}
+
+	cfg := S3Config{Endpoint: "https://fsn1.your-objectstorage.com", Region: "auto"}
+	if req := headRequest(cfg); req.URL.Host != "fsn1.your-objectstorage.com" || req.URL.Path != "/archives/events/a.jsonl" {
+		t.Errorf("default should be path style, got %s", req.URL)
+	}
+	if req := headRequest(cfg); req.Header.Get("x-amz-request-payer") != "" {
+		t.Error("requester pays header sent without --s3-requester-pays")
+	}
+
+	cfg.HTTP = S3HTTPConfig{AddressingStyle: S3AddressingVirtual, RequesterPays: true}
+	req := headRequest(cfg)
+	if req.URL.Host != "archives.fsn1.your-objectstorage.com" || req.URL.Path != "/events/a.jsonl" {
+		t.Errorf("expected virtual-hosted style, got %s", req.URL)
+	}
+	if req.Header.Get("x-amz-request-payer") != "requester" {
+		t.Errorf("expected requester pays header, got %v", req.Header)
+	}
+
+	cfg.HTTP = S3HTTPConfig{AddressingStyle: S3AddressingAuto}
+	if req := headRequest(cfg); req.URL.Host != "fsn1.your-objectstorage.com" {
+		t.Errorf("auto should use path style for other providers, got %s", req.URL)
+	}
+	cfg.Endpoint = "https://s3.ap-southeast-5.amazonaws.com"
+	if req := headRequest(cfg); req.URL.Host != "archives.s3.ap-southeast-5.amazonaws.com" {
+		t.Errorf("auto should use virtual-hosted style for AWS, got %s", req.URL)
+	}
+
+	if err := (S3HTTPConfig{AddressingStyle: "dns"}).Validate(); !errors.Is(err, ErrS3AddressingInvalid) {
+		t.Errorf("expected ErrS3AddressingInvalid, got %v", err)
+	}
+}
+
+func TestNewS3SessionCredentialChain(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config")
+	credentialsFile := filepath.Join(dir, "credentials")
+	if err := os.WriteFile(configFile, []byte("[profile archiver]\nregion = eu-central-1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(credentialsFile, []byte("[archiver]\naws_access_key_id = PROFILEKEY\naws_secret_access_key = PROFILESECRET\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_PROFILE", "")
+
+	// Without static keys or an endpoint, the profile supplies credentials and region
+	sess, err := newS3Session(S3Config{Bucket: "archives", Region: regionAuto, Profile: "archiver"})
+	if err != nil {
+		t.Fatalf("newS3Session() error = %v", err)
+	}
+	if region := aws.StringValue(sess.Config.Region); region != "eu-central-1" {
+		t.Errorf("region = %q, want eu-central-1", region)
+	}
+	creds, err := sess.Config.Credentials.Get()
+	if err != nil {
+		t.Fatalf("Credentials.Get() error = %v", err)
+	}
+	if creds.AccessKeyID != "PROFILEKEY" {
+		t.Errorf("access key = %q, want the profile's", creds.AccessKeyID)
+	}
+
+	// Static keys and an explicit region win over the profile
+	sess, err = newS3Session(S3Config{Bucket: "archives", Region: "us-west-2", Profile: "archiver", AccessKey: "KEY", SecretKey: "SECRET"})
+	if err != nil {
+		t.Fatalf("newS3Session() error = %v", err)
+	}
+	creds, _ = sess.Config.Credentials.Get()
+	if creds.AccessKeyID != "KEY" || aws.StringValue(sess.Config.Region) != "us-west-2" {
+		t.Errorf("got key %q region %q, want the static settings", creds.AccessKeyID, aws.StringValue(sess.Config.Region))
+	}
+}
+
+func TestS3ConfigValidateCredentials(t *testing.T) {
+	if err := (S3Config{}).validateCredentials(); err != nil {
+		t.Errorf("no keys should use the default chain, got %v", err)
+	}
+	if err := (S3Config{AccessKey: "KEY", SecretKey: "SECRET"}).validateCredentials(); err != nil {
+		t.Errorf("static keys should be valid, got %v", err)
+	}
+	if err := (S3Config{AccessKey: "KEY"}).validateCredentials(); !errors.Is(err, ErrS3SecretKeyRequired) {
+		t.Errorf("expected ErrS3SecretKeyRequired, got %v", err)
+	}
+	if err := (S3Config{SecretKey: "SECRET"}).validateCredentials(); !errors.Is(err, ErrS3AccessKeyRequired) {
+		t.Errorf("expected ErrS3AccessKeyRequired, got %v", err)
+	}
+}
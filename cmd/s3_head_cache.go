@@ -0,0 +1,326 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/airframesio/data-archiver/cmd/compressors"
+	"github.com/airframesio/data-archiver/cmd/formatters"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/viper"
+)
+
+// Static errors for existence checks
+var ErrHeadConcurrencyInvalid = errors.New("s3 head concurrency must be >= 0")
+
+// defaultHeadConcurrency is how many existence checks a partition runs at once
+const defaultHeadConcurrency = 8
+
+// headListThreshold is how many keys sharing a directory are checked with one
+// listing instead of a HEAD each
+const headListThreshold = 10
+
+func init() {
+	archiveCmd.Flags().Int("s3-head-concurrency", defaultHeadConcurrency, "existence checks run at once when a split partition's slices are checked up front (0 = check each slice when it is processed)")
+	_ = viper.BindPFlag("s3.head_concurrency", archiveCmd.Flags().Lookup("s3-head-concurrency"))
+}
+
+// objectHead is what an existence check found for a key
+type objectHead struct {
+	Exists bool
+	Size   int64
+	ETag   string
+}
+
+// objectHeadCache keeps the existence checks of a run, so a key is looked up
+// in S3 once however many times the run asks about it. Uploading a key
+// forgets it.
+type objectHeadCache struct {
+	mu    sync.Mutex
+	heads map[string]objectHead
+}
+
+func newObjectHeadCache() *objectHeadCache {
+	return &objectHeadCache{heads: make(map[string]objectHead)}
+}
+
+func (c *objectHeadCache) get(key string) (objectHead, bool) {
+	if c == nil {
+		return objectHead{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	head, ok := c.heads[key]
+	return head, ok
+}
+
+func (c *objectHeadCache) set(key string, head objectHead) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.heads[key] = head
+}
+
+func (c *objectHeadCache) forget(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.heads, key)
+}
+
+// headObject checks key in S3. Only answers S3 gave, found or not found, are
+// definite; throttling and other errors leave the key to be checked again.
+func headObject(ctx context.Context, client StorageBackend, bucket, key string) (objectHead, bool, error) {
+	output, err := client.Head(ctx, bucket, key)
+	if err != nil {
+		if isObjectNotFound(err) {
+			return objectHead{}, true, nil
+		}
+		return objectHead{}, false, err
+	}
+	return objectHead{Exists: true, Size: output.Size, ETag: output.ETag}, true, nil
+}
+
+// isThrottleError reports whether S3 asked for fewer requests: SlowDown, a 503
+// or 429, or one of the SDK's throttling codes
+func isThrottleError(err error) bool {
+	if request.IsErrorThrottle(err) {
+		return true
+	}
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == "SlowDown" {
+		return true
+	}
+	var rerr awserr.RequestFailure
+	return errors.As(err, &rerr) && (rerr.StatusCode() == http.StatusServiceUnavailable || rerr.StatusCode() == http.StatusTooManyRequests)
+}
+
+// prefetchObjectHeads checks keys before their slices are processed and
+// caches the answers. Keys sharing a directory with headListThreshold or more
+// others are found with a listing narrowed to their common prefix; the rest
+// are checked with HEAD requests, concurrency at a time. When S3 throttles,
+// the remaining keys are left to be checked one at a time as their slices
+// come up.
+func prefetchObjectHeads(ctx context.Context, client StorageBackend, bucket string, keys []string, concurrency int, cache *objectHeadCache) error {
+	var pending []string
+	for _, key := range keys {
+		if _, ok := cache.get(key); !ok {
+			pending = append(pending, key)
+		}
+	}
+	if len(pending) == 0 || concurrency <= 0 {
+		return nil
+	}
+
+	byDir := make(map[string][]string)
+	for _, key := range pending {
+		byDir[path.Dir(key)] = append(byDir[path.Dir(key)], key)
+	}
+	var heads []string
+	for _, dirKeys := range byDir {
+		if len(dirKeys) < headListThreshold {
+			heads = append(heads, dirKeys...)
+			continue
+		}
+		if err := listObjectHeads(ctx, client, bucket, dirKeys, cache); err != nil {
+			if isThrottleError(err) {
+				return err
+			}
+			heads = append(heads, dirKeys...) // Listing denied or failed; fall back to HEAD
+		}
+	}
+	sort.Strings(heads)
+
+	work := make(chan string)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var throttled error
+	stop := make(chan struct{})
+	for i := 0; i < min(concurrency, len(heads)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range work {
+				head, definite, err := headObject(ctx, client, bucket, key)
+				if definite {
+					cache.set(key, head)
+				} else if isThrottleError(err) {
+					once.Do(func() {
+						throttled = err
+						close(stop)
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for _, key := range heads {
+		select {
+		case work <- key:
+		case <-stop:
+			break feed
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+	return throttled
+}
+
+// listObjectHeads lists the keys of one directory, starting at their common
+// prefix and stopping after the last of them, and caches each key as found or
+// missing
+func listObjectHeads(ctx context.Context, client StorageBackend, bucket string, keys []string, cache *objectHeadCache) error {
+	sort.Strings(keys)
+	last := keys[len(keys)-1]
+	prefix := keys[0]
+	for _, key := range keys[1:] {
+		for !strings.HasPrefix(key, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	found := make(map[string]objectHead)
+	err := client.List(ctx, bucket, prefix, func(object ObjectInfo) bool {
+		if object.Key > last {
+			return false
+		}
+		found[object.Key] = objectHead{Exists: true, Size: object.Size, ETag: object.ETag}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		cache.set(key, found[key])
+	}
+	return nil
+}
+
+// sliceObjectKey returns the key processSinglePartitionSlice checks for the
+// slice starting at start: the archive itself, or its manifest when split
+// by --max-rows-per-file
+func (a *Archiver) sliceObjectKey(start time.Time) (string, error) {
+	formatter := formatters.GetFormatterWithCompression(a.config.OutputFormat, a.config.Compression)
+	var compressionExt string
+	if !formatters.UsesInternalCompression(a.config.OutputFormat) {
+		compressor, err := compressors.GetCompressor(a.config.Compression)
+		if err != nil {
+			return "", err
+		}
+		compressionExt = compressor.Extension()
+	}
+	start = a.keyTime(start)
+	basePath := NewPathTemplate(a.config.S3.PathTemplate).WithOutput(a.config.OutputFormat, a.config.Compression).Generate(a.config.Table, start)
+	objectKey := basePath + "/" + GenerateFilename(a.config.Table, start, a.config.OutputDuration, formatter.Extension(), compressionExt)
+	if a.config.splitsArchives() {
+		objectKey = manifestObjectKey(objectKey, formatter.Extension()+compressionExt)
+	}
+	return objectKey, nil
+}
+
+// prefetchSliceHeads checks the slices of a split partition up front
+func (a *Archiver) prefetchSliceHeads(partition PartitionInfo, ranges []struct {
+	Start time.Time
+	End   time.Time
+}) {
+	if a.s3Client == nil || a.config.HeadConcurrency <= 0 || len(ranges) < 2 {
+		return
+	}
+	keys := make([]string, 0, len(ranges))
+	for _, timeRange := range ranges {
+		if _, skip := a.config.Calendar.skipReason(timeRange.Start, timeRange.End); skip {
+			continue
+		}
+		key, err := a.sliceObjectKey(timeRange.Start)
+		if err != nil {
+			return
+		}
+		keys = append(keys, key)
+	}
+	if err := prefetchObjectHeads(a.ctx, a.s3Client, a.config.S3.Bucket, keys, a.config.HeadConcurrency, a.heads); err != nil {
+		a.logger.Debug(fmt.Sprintf("  S3 is throttling existence checks for %s; checking the remaining slices one at a time: %v", partition.TableName, err))
+	}
+}
+
+// s3RequestCounts counts the HTTP requests a client sends to S3, retries
+// included, by operation
+type s3RequestCounts struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// countS3Requests counts the requests client sends from now on. Clients that
+// are not backed by the AWS SDK (local and Azure storage) send none and
+// return nil.
+func countS3Requests(client StorageBackend) *s3RequestCounts {
+	store, ok := client.(*s3ObjectStore)
+	if !ok {
+		return nil
+	}
+	sdkClient, ok := store.client.(*s3.S3)
+	if !ok {
+		return nil
+	}
+	counts := &s3RequestCounts{counts: make(map[string]int64)}
+	sdkClient.Handlers.Send.PushFrontNamed(request.NamedHandler{
+		Name: "data-archiver.countRequests",
+		Fn: func(r *request.Request) {
+			counts.mu.Lock()
+			counts.counts[r.Operation.Name]++
+			counts.mu.Unlock()
+		},
+	})
+	return counts
+}
+
+// total returns the requests sent so far
+func (c *s3RequestCounts) total() int64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var total int64
+	for _, n := range c.counts {
+		total += n
+	}
+	return total
+}
+
+// String lists the requests sent so far, most frequent operation first
+func (c *s3RequestCounts) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	operations := make([]string, 0, len(c.counts))
+	var total int64
+	for operation, n := range c.counts {
+		operations = append(operations, operation)
+		total += n
+	}
+	sort.Slice(operations, func(i, j int) bool {
+		if c.counts[operations[i]] != c.counts[operations[j]] {
+			return c.counts[operations[i]] > c.counts[operations[j]]
+		}
+		return operations[i] < operations[j]
+	})
+	parts := make([]string, len(operations))
+	for i, operation := range operations {
+		parts[i] = fmt.Sprintf("%s %s", operation, formatNumberForSummary(c.counts[operation]))
+	}
+	return fmt.Sprintf("%s (%s)", formatNumberForSummary(total), strings.Join(parts, ", "))
+}
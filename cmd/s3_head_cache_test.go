@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPrefetchObjectHeads(t *testing.T) {
+	ctx := context.Background()
+	objects := map[string][]byte{
+		"events/2024/01/events-2024-01-02.jsonl": []byte("archived"),
+		"events/2024/02/events-2024-02-01.jsonl": []byte("archived"),
+		"events/2024/02/other.txt":               []byte("not ours"),
+	}
+	var keys []string
+	for day := 1; day <= 31; day++ {
+		keys = append(keys, fmt.Sprintf("events/2024/01/events-2024-01-%02d.jsonl", day))
+	}
+	keys = append(keys, "events/2024/02/events-2024-02-01.jsonl", "events/2024/02/events-2024-02-02.jsonl")
+
+	// January's 31 keys take one listing; February's two take a HEAD each
+	store := newFakeObjectStore(objects)
+	cache := newObjectHeadCache()
+	if err := prefetchObjectHeads(ctx, newS3ObjectStore(store), "bucket", keys, 4, cache); err != nil {
+		t.Fatalf("prefetchObjectHeads() error = %v", err)
+	}
+	if store.lists.Load() != 1 || store.heads.Load() != 2 {
+		t.Errorf("requests = %d lists, %d heads; want 1, 2", store.lists.Load(), store.heads.Load())
+	}
+	for _, key := range keys {
+		head, ok := cache.get(key)
+		if _, want := objects[key]; !ok || head.Exists != want {
+			t.Errorf("%s: cached %+v (%v), want exists = %v", key, head, ok, want)
+		}
+	}
+	if head, _ := cache.get("events/2024/01/events-2024-01-02.jsonl"); head.Size != 8 || head.ETag == "" {
+		t.Errorf("listed head = %+v", head)
+	}
+
+	// Cached keys are not checked again
+	if err := prefetchObjectHeads(ctx, newS3ObjectStore(store), "bucket", keys, 4, cache); err != nil || store.heads.Load() != 2 || store.lists.Load() != 1 {
+		t.Errorf("second prefetch sent requests: %d lists, %d heads, err = %v", store.lists.Load(), store.heads.Load(), err)
+	}
+
+	// Throttling stops the batch and leaves keys unchecked
+	store = newFakeObjectStore(objects)
+	store.throttle = true
+	cache = newObjectHeadCache()
+	err := prefetchObjectHeads(ctx, newS3ObjectStore(store), "bucket", keys[:8], 1, cache)
+	if !isThrottleError(err) {
+		t.Fatalf("expected a throttling error, got %v", err)
+	}
+	if store.heads.Load() > 2 {
+		t.Errorf("sent %d HEADs after being throttled", store.heads.Load())
+	}
+	if _, ok := cache.get(keys[0]); ok {
+		t.Error("a throttled check should not be cached")
+	}
+}
+
+func TestCheckObjectExistsUsesCache(t *testing.T) {
+	store := newFakeObjectStore(map[string][]byte{"events/a.jsonl": []byte("data")})
+	archiver := NewArchiver(&Config{S3: S3Config{Bucket: "bucket"}}, newTestLogger())
+	archiver.s3Client = newS3ObjectStore(store)
+
+	for i := 0; i < 3; i++ {
+		if exists, size, _ := archiver.checkObjectExists("events/a.jsonl"); !exists || size != 4 {
+			t.Fatalf("checkObjectExists() = %v, %d", exists, size)
+		}
+		if exists, _, _ := archiver.checkObjectExists("events/b.jsonl"); exists {
+			t.Fatal("missing object reported as existing")
+		}
+	}
+	if store.heads.Load() != 2 {
+		t.Errorf("HEAD requests = %d, want 2", store.heads.Load())
+	}
+
+	// Uploading forgets the key
+	archiver.heads.forget("events/b.jsonl")
+	store.objects["events/b.jsonl"] = []byte("new")
+	if exists, _, _ := archiver.checkObjectExists("events/b.jsonl"); !exists {
+		t.Error("uploaded object should be checked again")
+	}
+}
+
+func TestSliceObjectKey(t *testing.T) {
+	archiver := NewArchiver(&Config{
+		Table:          "events",
+		OutputFormat:   "jsonl",
+		Compression:    "zstd",
+		OutputDuration: "daily",
+		S3:             S3Config{PathTemplate: "archives/{table}/{YYYY}/{MM}"},
+	}, newTestLogger())
+	start := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+	key, err := archiver.sliceObjectKey(start)
+	if err != nil || key != "archives/events/2024/01/events-2024-01-05.jsonl.zst" {
+		t.Errorf("sliceObjectKey() = %q, %v", key, err)
+	}
+	archiver.config.MaxRowsPerFile = 100
+	if key, _ := archiver.sliceObjectKey(start); key != "archives/events/2024/01/events-2024-01-05"+manifestSuffix {
+		t.Errorf("split sliceObjectKey() = %q", key)
+	}
+}
+
+func TestS3RequestCounts(t *testing.T) {
+	client, err := newStorageClient(S3Config{Endpoint: "http://127.0.0.1:1", Bucket: "bucket", Region: "us-east-1", AccessKey: "key", SecretKey: "secret", HTTP: S3HTTPConfig{MaxRetries: 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := countS3Requests(client)
+	for i := 0; i < 2; i++ {
+		_, _ = client.Head(context.Background(), "bucket", "key")
+	}
+	_ = client.List(context.Background(), "bucket", "", func(ObjectInfo) bool { return true })
+	if counts.total() != 3 || counts.String() != "3 (HeadObject 2, ListObjectsV2 1)" {
+		t.Errorf("counts = %s", counts)
+	}
+
+	if countS3Requests(&localObjectStore{}) != nil {
+		t.Error("local storage should not be counted")
+	}
+}
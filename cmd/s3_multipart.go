@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/spf13/viper"
+)
+
+// Multipart upload limits (--s3-part-size is in MB)
+const (
+	defaultS3PartSizeMB = minUploadPartSize / (1024 * 1024)
+	maxS3PartSizeMB     = 5 * 1024 // S3 maximum of 5GB per part
+)
+
+// Static errors for multipart upload tuning
+var (
+	ErrS3PartSizeInvalid          = errors.New("s3 part size must be between 5 and 5120 MB")
+	ErrS3UploadConcurrencyInvalid = errors.New("s3 upload concurrency must be at least 1")
+)
+
+func init() {
+	archiveCmd.Flags().Int("s3-part-size", defaultS3PartSizeMB, "size in MB of each part of multipart uploads (5-5120); multipart ETags are computed with it, and the cache records it for later skip checks")
+	archiveCmd.Flags().Int("s3-upload-concurrency", s3manager.DefaultUploadConcurrency, "parts of one multipart upload sent at once")
+	_ = viper.BindPFlag("s3.part_size", archiveCmd.Flags().Lookup("s3-part-size"))
+	_ = viper.BindPFlag("s3.upload_concurrency", archiveCmd.Flags().Lookup("s3-upload-concurrency"))
+}
+
+// validateUploadTuning checks the multipart part size and upload concurrency.
+// Zero leaves the defaults.
+func (c S3Config) validateUploadTuning() error {
+	if c.PartSizeMB != 0 && (c.PartSizeMB < defaultS3PartSizeMB || c.PartSizeMB > maxS3PartSizeMB) {
+		return fmt.Errorf("%w, got %d", ErrS3PartSizeInvalid, c.PartSizeMB)
+	}
+	if c.UploadConcurrency < 0 {
+		return fmt.Errorf("%w, got %d", ErrS3UploadConcurrencyInvalid, c.UploadConcurrency)
+	}
+	return nil
+}
+
+// basePartSize returns the configured part size in bytes
+func (c S3Config) basePartSize() int64 {
+	if c.PartSizeMB <= 0 {
+		return minUploadPartSize
+	}
+	return int64(c.PartSizeMB) * 1024 * 1024
+}
+
+// uploadConcurrency returns how many parts of an upload are sent at once
+func (c S3Config) uploadConcurrency() int {
+	if c.UploadConcurrency <= 0 {
+		return s3manager.DefaultUploadConcurrency
+	}
+	return c.UploadConcurrency
+}
+
+// objectPartSize returns the part size the archiver uploads a file of size
+// bytes with: --s3-part-size, grown for files too large for 10,000 parts
+func (a *Archiver) objectPartSize(size int64) int64 {
+	return partSizeFor(size, a.config.S3.basePartSize())
+}
+
+// putOptions returns the content headers, metadata, and tags of the object
+// uploaded to key
+func (a *Archiver) putOptions(key string) PutOptions {
+	contentType, contentEncoding := a.contentHeaders(key)
+	return PutOptions{
+		ContentType:     contentType,
+		ContentEncoding: contentEncoding,
+		Metadata:        a.objectMetadata(key),
+		Tagging:         a.objectTagging(key),
+	}
+}
+
+// uploadOptions returns how a stream of about size bytes is uploaded to key:
+// its putOptions, split with the part size and concurrency
+func (a *Archiver) uploadOptions(key string, size int64) UploadOptions {
+	return UploadOptions{
+		PutOptions:  a.putOptions(key),
+		PartSize:    a.objectPartSize(size),
+		Concurrency: a.config.S3.uploadConcurrency(),
+	}
+}
+
+// existingObjectETag returns the multipart ETag of a temp file as an object
+// uploaded with parts of recordedPartSize would have it, and that part size.
+// Objects uploaded before --s3-part-size changed keep matching their files.
+// Without a recorded part size, the object may have been uploaded before
+// part sizes were recorded, with the 5MB default: that size is tried too
+// when it gives s3ETag's part count.
+func (a *Archiver) existingObjectETag(filePath string, recordedPartSize int64, s3ETag string) (string, int64, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to stat file: %w", err)
+	}
+	partSize := a.objectPartSize(info.Size())
+	if recordedPartSize > 0 && recordedPartSize != partSize {
+		etag, err := fileMultipartETag(filePath, info.Size(), recordedPartSize)
+		return etag, recordedPartSize, err
+	}
+
+	etag, err := a.tempFileMultipartETag(filePath)
+	if err != nil || recordedPartSize > 0 || etag == s3ETag {
+		return etag, partSize, err
+	}
+	legacy := uploadPartSize(info.Size())
+	if legacy == partSize || !strings.HasSuffix(s3ETag, fmt.Sprintf("-%d", partCount(info.Size(), legacy))) {
+		return etag, partSize, nil
+	}
+	legacyETag, err := fileMultipartETag(filePath, info.Size(), legacy)
+	if err != nil {
+		return "", 0, err
+	}
+	if legacyETag != s3ETag {
+		return etag, partSize, nil
+	}
+	return legacyETag, legacy, nil
+}
+
+// fileMultipartETag computes the multipart ETag of the file at filePath as
+// uploaded in partSize parts
+func fileMultipartETag(filePath string, size, partSize int64) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	etag, err := multipartETag(file, size, partSize)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash file: %w", err)
+	}
+	return etag, nil
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestValidateUploadTuning(t *testing.T) {
+	tests := []struct {
+		name   string
+		config S3Config
+		want   error
+	}{
+		{"Defaults", S3Config{}, nil},
+		{"Custom", S3Config{PartSizeMB: 64, UploadConcurrency: 8}, nil},
+		{"PartTooSmall", S3Config{PartSizeMB: 4}, ErrS3PartSizeInvalid},
+		{"PartTooLarge", S3Config{PartSizeMB: 5121}, ErrS3PartSizeInvalid},
+		{"NegativeConcurrency", S3Config{UploadConcurrency: -1}, ErrS3UploadConcurrencyInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.validateUploadTuning(); !errors.Is(err, tt.want) {
+				t.Errorf("validateUploadTuning() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestObjectPartSize(t *testing.T) {
+	const mb = 1024 * 1024
+	archiver := NewArchiver(&Config{S3: S3Config{PartSizeMB: 16}}, newTestLogger())
+	tests := []struct {
+		size int64
+		want int64
+	}{
+		{200 * mb, 16 * mb},
+		// 10,000 parts of 16MB hold about 156GB; larger files grow the parts
+		{200 * 1024 * mb, 21 * mb},
+	}
+	for _, tt := range tests {
+		if got := archiver.objectPartSize(tt.size); got != tt.want {
+			t.Errorf("objectPartSize(%d) = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+	if got := NewArchiver(&Config{}, newTestLogger()).objectPartSize(200 * mb); got != minUploadPartSize {
+		t.Errorf("default objectPartSize() = %d, want %d", got, minUploadPartSize)
+	}
+}
+
+func TestExistingObjectETag(t *testing.T) {
+	const mb = 1024 * 1024
+	data := bytes.Repeat([]byte("0123456789abcdef"), 13*mb/16)
+	path := filepath.Join(t.TempDir(), "archive.jsonl.zst")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	archiver := NewArchiver(&Config{S3: S3Config{PartSizeMB: 8}}, newTestLogger())
+
+	// Without a recorded part size, the configured one is used
+	etag, partSize, err := archiver.existingObjectETag(path, 0, expectedMultipartETag(data, 8*mb))
+	if err != nil || partSize != 8*mb || etag != expectedMultipartETag(data, 8*mb) {
+		t.Errorf("existingObjectETag(0) = %s, %d, %v", etag, partSize, err)
+	}
+
+	// An object uploaded before part sizes were recorded used the 5MB default
+	legacy := expectedMultipartETag(data, minUploadPartSize)
+	etag, partSize, err = archiver.existingObjectETag(path, 0, legacy)
+	if err != nil || partSize != minUploadPartSize || etag != legacy {
+		t.Errorf("existingObjectETag(0, legacy) = %s, %d, %v", etag, partSize, err)
+	}
+
+	// A different object keeps the configured part size's ETag, so it mismatches
+	etag, partSize, err = archiver.existingObjectETag(path, 0, "0123456789abcdef0123456789abcdef-3")
+	if err != nil || partSize != 8*mb || etag != expectedMultipartETag(data, 8*mb) {
+		t.Errorf("existingObjectETag(0, other) = %s, %d, %v", etag, partSize, err)
+	}
+
+	// An object uploaded before --s3-part-size changed is hashed as uploaded
+	etag, partSize, err = archiver.existingObjectETag(path, minUploadPartSize, legacy)
+	if err != nil || partSize != minUploadPartSize || etag != expectedMultipartETag(data, minUploadPartSize) {
+		t.Errorf("existingObjectETag(5MB) = %s, %d, %v", etag, partSize, err)
+	}
+}
+
+func TestResumableUploadConcurrentParts(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store := newFakeObjectStore(nil)
+	uploader := newResumableUploader(newS3ObjectStore(store), "archive", nil, newTestLogger())
+	uploader.partSize = func(int64) int64 { return 4 }
+	uploader.parallel = 3
+	key := "events/events-2024-01-01.jsonl.zst"
+	path := filepath.Join(t.TempDir(), "upload.tmp")
+	content := []byte("aaaabbbbccccddddeeeefff")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := uploader.Upload(context.Background(), path, key); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	sent := append([]int64(nil), store.sent...)
+	sort.Slice(sent, func(i, j int) bool { return sent[i] < sent[j] })
+	if len(sent) != 6 || sent[0] != 1 || sent[5] != 6 {
+		t.Errorf("expected parts 1-6 sent once, got %v", store.sent)
+	}
+	if !bytes.Equal(store.objects[key], content) {
+		t.Errorf("assembled object = %q", store.objects[key])
+	}
+}
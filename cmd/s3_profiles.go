@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// S3-compatible providers with quirks handled for endpoint profiles
+const (
+	S3ProviderAWS   = "aws"
+	S3ProviderR2    = "r2"    // Cloudflare R2
+	S3ProviderB2    = "b2"    // Backblaze B2
+	S3ProviderMinIO = "minio" // MinIO and other self-hosted gateways
+	S3ProviderGCS   = "gcs"   // Google Cloud Storage XML API with HMAC keys
+)
+
+// minioDefaultRegion is the region MinIO signs requests for unless configured otherwise
+const minioDefaultRegion = "us-east-1"
+
+// Static errors for endpoint profiles
+var (
+	ErrS3EndpointProfileUnknown = errors.New("unknown S3 endpoint profile")
+	ErrS3ProviderInvalid        = errors.New("S3 provider must be one of: aws, r2, b2, minio, gcs")
+	ErrS3ProviderRequesterPays  = errors.New("requester-pays buckets are only supported by AWS S3")
+	ErrS3ProviderRegion         = errors.New("S3 region could not be derived from the endpoint; set a region in the endpoint profile")
+)
+
+// S3EndpointProfile is a named endpoint from the s3_profiles section of the
+// config file: where a storage provider is and how to sign in to it. Buckets
+// and path templates stay with each command.
+type S3EndpointProfile struct {
+	Provider        string `mapstructure:"provider"`
+	Endpoint        string `mapstructure:"endpoint"`
+	Region          string `mapstructure:"region"`
+	AccessKey       string `mapstructure:"access_key"`
+	SecretKey       string `mapstructure:"secret_key"`
+	AWSProfile      string `mapstructure:"aws_profile"` // AWS shared config profile, used without static keys
+	Backend         string `mapstructure:"backend"`
+	AddressingStyle string `mapstructure:"addressing_style"`
+	RequesterPays   bool   `mapstructure:"requester_pays"`
+}
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.String("s3-endpoint-profile", "", "named endpoint from the s3_profiles config section to use for S3 (overrides <command>.s3_endpoint_profile and s3.endpoint_profile)")
+	_ = viper.BindPFlag("s3.endpoint_profile", flags.Lookup("s3-endpoint-profile"))
+}
+
+// loadS3EndpointProfiles reads the s3_profiles section of the config file
+func loadS3EndpointProfiles() (map[string]S3EndpointProfile, error) {
+	profiles := make(map[string]S3EndpointProfile)
+	if err := viper.UnmarshalKey("s3_profiles", &profiles); err != nil {
+		return nil, fmt.Errorf("invalid s3_profiles: %w", err)
+	}
+	return profiles, nil
+}
+
+// endpointProfileName returns the endpoint profile a command uses:
+// --s3-endpoint-profile, else <command>.s3_endpoint_profile, else
+// s3.endpoint_profile from the config file
+func endpointProfileName(command string) string {
+	if flag := rootCmd.PersistentFlags().Lookup("s3-endpoint-profile"); flag != nil && flag.Changed {
+		return flag.Value.String()
+	}
+	if name := viper.GetString(command + ".s3_endpoint_profile"); name != "" {
+		return name
+	}
+	return viper.GetString("s3.endpoint_profile")
+}
+
+// newS3Config completes the S3 settings a command resolved from its own flags
+// and config keys (endpoint, bucket, keys, region, path template) with the
+// settings shared by every command (AWS profile, storage backend, HTTP
+// tuning) and applies the named endpoint profile, if any. The result is what
+// newStorageClient connects with.
+func newS3Config(cfg S3Config, endpointProfile string) (S3Config, error) {
+	cfg.Profile = viper.GetString("s3.profile")
+	cfg.Backend = viper.GetString("storage.backend")
+	cfg.HTTP = loadS3HTTPConfig()
+	cfg.PartSizeMB = viper.GetInt("s3.part_size")
+	cfg.UploadConcurrency = viper.GetInt("s3.upload_concurrency")
+	if endpointProfile == "" {
+		return cfg, nil
+	}
+
+	profiles, err := loadS3EndpointProfiles()
+	if err != nil {
+		return cfg, err
+	}
+	profile, ok := profiles[endpointProfile]
+	if !ok {
+		// viper lower-cases map keys read from the config file
+		profile, ok = profiles[strings.ToLower(endpointProfile)]
+	}
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return cfg, fmt.Errorf("%w '%s' (defined: %s)", ErrS3EndpointProfileUnknown, endpointProfile, strings.Join(names, ", "))
+	}
+	return profile.apply(cfg)
+}
+
+// apply overlays the profile on cfg: every setting the profile gives replaces
+// the command's, and the rest are kept. Provider quirks are applied last.
+func (p S3EndpointProfile) apply(cfg S3Config) (S3Config, error) {
+	for _, field := range []struct {
+		value  string
+		target *string
+	}{
+		{p.Endpoint, &cfg.Endpoint},
+		{p.Region, &cfg.Region},
+		{p.Backend, &cfg.Backend},
+		{p.AddressingStyle, &cfg.HTTP.AddressingStyle},
+	} {
+		if field.value != "" {
+			*field.target = field.value
+		}
+	}
+	// Credentials are replaced as a whole: static keys, or an AWS profile
+	// that the command's static keys would otherwise override
+	switch {
+	case p.AccessKey != "" || p.SecretKey != "":
+		cfg.AccessKey, cfg.SecretKey = p.AccessKey, p.SecretKey
+	case p.AWSProfile != "":
+		cfg.AccessKey, cfg.SecretKey, cfg.Profile = "", "", p.AWSProfile
+	}
+	if p.RequesterPays {
+		cfg.HTTP.RequesterPays = true
+	}
+	return applyProviderQuirks(cfg, p.Provider)
+}
+
+// regionUnset reports whether region leaves the choice to the provider
+func regionUnset(region string) bool {
+	return region == "" || region == regionAuto
+}
+
+// applyProviderQuirks adjusts cfg for what an S3-compatible provider expects:
+//   - r2: region "auto"; no requester pays
+//   - b2: region taken from the endpoint (s3.<region>.backblazeb2.com); no requester pays
+//   - minio: path-style addressing and us-east-1 signing unless a region is set; no requester pays
+//   - gcs: the gcs storage backend, path-style addressing; no requester pays
+func applyProviderQuirks(cfg S3Config, provider string) (S3Config, error) {
+	switch strings.ToLower(provider) {
+	case "", S3ProviderAWS:
+		return cfg, nil
+	case S3ProviderR2:
+		if regionUnset(cfg.Region) {
+			cfg.Region = regionAuto
+		}
+	case S3ProviderB2:
+		if regionUnset(cfg.Region) {
+			region, ok := b2EndpointRegion(cfg.Endpoint)
+			if !ok {
+				return cfg, fmt.Errorf("%w: %s", ErrS3ProviderRegion, cfg.Endpoint)
+			}
+			cfg.Region = region
+		}
+	case S3ProviderMinIO:
+		cfg.HTTP.AddressingStyle = S3AddressingPath
+		if regionUnset(cfg.Region) {
+			cfg.Region = minioDefaultRegion
+		}
+	case S3ProviderGCS:
+		cfg.Backend = StorageBackendGCS
+		cfg.HTTP.AddressingStyle = S3AddressingPath
+	default:
+		return cfg, fmt.Errorf("%w, got '%s'", ErrS3ProviderInvalid, provider)
+	}
+	if cfg.HTTP.RequesterPays {
+		return cfg, fmt.Errorf("%w, not %s", ErrS3ProviderRequesterPays, provider)
+	}
+	return cfg, nil
+}
+
+// b2EndpointRegion returns the region in a Backblaze B2 S3 endpoint such as
+// https://s3.us-west-004.backblazeb2.com
+func b2EndpointRegion(endpoint string) (string, bool) {
+	host := endpoint
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		host = u.Hostname()
+	}
+	labels := strings.Split(strings.ToLower(host), ".")
+	if len(labels) != 4 || labels[0] != "s3" || labels[2] != "backblazeb2" || labels[3] != "com" {
+		return "", false
+	}
+	return labels[1], labels[1] != ""
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func setTestEndpointProfiles() {
+	viper.Set("s3_profiles", map[string]interface{}{
+		"r2": map[string]interface{}{
+			"provider":   "r2",
+			"endpoint":   "https://account.r2.cloudflarestorage.com",
+			"access_key": "r2-key",
+			"secret_key": "r2-secret",
+		},
+		"b2": map[string]interface{}{
+			"provider": "b2",
+			"endpoint": "https://s3.us-west-004.backblazeb2.com",
+		},
+		"minio-lab": map[string]interface{}{
+			"provider": "minio",
+			"endpoint": "http://minio.lab:9000",
+		},
+		"gcs": map[string]interface{}{"provider": "gcs"},
+		"aws-prod": map[string]interface{}{
+			"aws_profile": "prod",
+			"region":      "eu-west-1",
+		},
+	})
+}
+
+func TestNewS3ConfigSharedSettings(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("s3.profile", "archive")
+	viper.Set("storage.backend", StorageBackendGCS)
+	viper.Set("s3.addressing_style", S3AddressingVirtual)
+
+	cfg, err := newS3Config(S3Config{Endpoint: "https://s3.example.com", Bucket: "archives"}, "")
+	if err != nil {
+		t.Fatalf("newS3Config() error = %v", err)
+	}
+	if cfg.Profile != "archive" || cfg.Backend != StorageBackendGCS || cfg.HTTP.AddressingStyle != S3AddressingVirtual || cfg.Endpoint != "https://s3.example.com" {
+		t.Errorf("newS3Config() = %+v", cfg)
+	}
+}
+
+func TestNewS3ConfigEndpointProfiles(t *testing.T) {
+	defer viper.Reset()
+	setTestEndpointProfiles()
+	base := S3Config{Endpoint: "https://s3.example.com", Bucket: "archives", AccessKey: "key", SecretKey: "secret", Region: regionAuto, PathTemplate: "{table}/{YYYY}"}
+
+	cfg, err := newS3Config(base, "r2")
+	if err != nil {
+		t.Fatalf("newS3Config(r2) error = %v", err)
+	}
+	if cfg.Endpoint != "https://account.r2.cloudflarestorage.com" || cfg.AccessKey != "r2-key" || cfg.Region != regionAuto || cfg.Bucket != "archives" || cfg.PathTemplate != "{table}/{YYYY}" {
+		t.Errorf("r2 = %+v", cfg)
+	}
+
+	// Profiles without keys keep the command's
+	if cfg, err = newS3Config(base, "b2"); err != nil || cfg.Region != "us-west-004" || cfg.AccessKey != "key" {
+		t.Errorf("b2 = %+v, %v", cfg, err)
+	}
+
+	viper.Set("s3.addressing_style", S3AddressingVirtual)
+	if cfg, err = newS3Config(base, "minio-lab"); err != nil || cfg.HTTP.AddressingStyle != S3AddressingPath || cfg.Region != minioDefaultRegion {
+		t.Errorf("minio = %+v, %v", cfg, err)
+	}
+	if cfg, err = newS3Config(base, "gcs"); err != nil || cfg.Backend != StorageBackendGCS {
+		t.Errorf("gcs = %+v, %v", cfg, err)
+	}
+
+	// An AWS profile replaces the command's static keys
+	if cfg, err = newS3Config(base, "aws-prod"); err != nil || cfg.Profile != "prod" || cfg.AccessKey != "" || cfg.SecretKey != "" || cfg.Region != "eu-west-1" {
+		t.Errorf("aws-prod = %+v, %v", cfg, err)
+	}
+
+	_, err = newS3Config(base, "wasabi")
+	if !errors.Is(err, ErrS3EndpointProfileUnknown) || !strings.Contains(err.Error(), "aws-prod, b2, gcs, minio-lab, r2") {
+		t.Errorf("expected ErrS3EndpointProfileUnknown listing the profiles, got %v", err)
+	}
+}
+
+func TestApplyProviderQuirks(t *testing.T) {
+	if _, err := applyProviderQuirks(S3Config{HTTP: S3HTTPConfig{RequesterPays: true}}, S3ProviderR2); !errors.Is(err, ErrS3ProviderRequesterPays) {
+		t.Errorf("expected ErrS3ProviderRequesterPays, got %v", err)
+	}
+	if cfg, err := applyProviderQuirks(S3Config{HTTP: S3HTTPConfig{RequesterPays: true}}, S3ProviderAWS); err != nil || !cfg.HTTP.RequesterPays {
+		t.Errorf("aws requester pays = %+v, %v", cfg, err)
+	}
+	if _, err := applyProviderQuirks(S3Config{Endpoint: "https://b2.example.com"}, S3ProviderB2); !errors.Is(err, ErrS3ProviderRegion) {
+		t.Errorf("expected ErrS3ProviderRegion, got %v", err)
+	}
+	if cfg, _ := applyProviderQuirks(S3Config{Endpoint: "https://s3.eu-central-003.backblazeb2.com", Region: "custom"}, S3ProviderB2); cfg.Region != "custom" {
+		t.Errorf("explicit region replaced: %s", cfg.Region)
+	}
+	if _, err := applyProviderQuirks(S3Config{}, "ceph"); !errors.Is(err, ErrS3ProviderInvalid) {
+		t.Errorf("expected ErrS3ProviderInvalid, got %v", err)
+	}
+}
+
+func TestEndpointProfileName(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("s3.endpoint_profile", "r2")
+	if got := endpointProfileName("restore"); got != "r2" {
+		t.Errorf("endpointProfileName() = %q, want r2", got)
+	}
+	viper.Set("restore.s3_endpoint_profile", "minio-lab")
+	if got := endpointProfileName("restore"); got != "minio-lab" {
+		t.Errorf("endpointProfileName() = %q, want minio-lab", got)
+	}
+	if got := endpointProfileName("archive"); got != "r2" {
+		t.Errorf("endpointProfileName() = %q, want r2", got)
+	}
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" //nolint:gosec // MD5 used for checksum comparisons only
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Static errors for schema export
+var (
+	ErrIncludeSchemaConflict = errors.New("--include-schema uploads to S3, so it cannot be used with --output -")
+	ErrPgDumpNotFound        = errors.New("pg_dump not found in PATH; --include-schema runs pg_dump --schema-only")
+	ErrSchemaDumpEmpty       = errors.New("pg_dump wrote no schema")
+)
+
+// schemaPathPlaceholders are the placeholders a schema path template may use;
+// the schema is not tied to a date
+var schemaPathPlaceholders = []string{"table"}
+
+// pgDumpCommand is the client that dumps the table's schema
+var pgDumpCommand = "pg_dump"
+
+var (
+	includeSchema      bool
+	schemaPathTemplate string
+)
+
+func init() {
+	archiveCmd.Flags().BoolVar(&includeSchema, "include-schema", false, "upload a pg_dump --schema-only dump of the table with each run, for restore --schema-source pg_dump")
+	archiveCmd.Flags().StringVar(&schemaPathTemplate, "schema-path-template", "", "S3 path template for --include-schema dumps; only {table} is replaced (default: --path-template without its date placeholders)")
+	_ = viper.BindPFlag("include_schema", archiveCmd.Flags().Lookup("include-schema"))
+	_ = viper.BindPFlag("schema_path_template", archiveCmd.Flags().Lookup("schema-path-template"))
+}
+
+// validateSchemaExport checks --include-schema, --include-comments,
+// --include-large-objects and --schema-path-template
+func (c *Config) validateSchemaExport() error {
+	if !c.IncludeSchema && !c.IncludeComments && !c.IncludeLargeObjects {
+		return nil
+	}
+	if c.Output == StdoutOutput {
+		switch {
+		case c.IncludeSchema:
+			return ErrIncludeSchemaConflict
+		case c.IncludeComments:
+			return ErrIncludeCommentsConflict
+		default:
+			return ErrIncludeLargeObjectsConflict
+		}
+	}
+	if c.SchemaPathTemplate != "" {
+		if _, err := parseTemplate("schema path template", c.SchemaPathTemplate, schemaPathPlaceholders); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaObjectKey returns where the table's schema dump is uploaded. The file
+// is named <table>-schema.dump, which restore's pg_dump schema source finds
+// when its --schema-path is the same template.
+func (c *Config) schemaObjectKey() string {
+	return schemaArtifactKey(c.schemaTemplate(), c.Table, "-schema.dump")
+}
+
+// schemaTemplate returns the path template of the table's schema artifacts
+func (c *Config) schemaTemplate() string {
+	if c.SchemaPathTemplate != "" {
+		return c.SchemaPathTemplate
+	}
+	return c.S3.PathTemplate
+}
+
+// schemaArtifactKey returns the key of a schema artifact named <table><suffix>
+// under template, without the template's date placeholders
+func schemaArtifactKey(template, table, suffix string) string {
+	table = objectKeyComponent(table)
+	base := strings.Trim(strings.ReplaceAll(stripDatePlaceholders(template), "{table}", table), "/")
+	if base == "" {
+		return table + suffix
+	}
+	return fmt.Sprintf("%s/%s%s", base, table, suffix)
+}
+
+// dumpSchema runs pg_dump --schema-only for the base table. Partitions are
+// left out, as restore creates them itself. The dump is plain SQL, which
+// carries no timestamp, so an unchanged schema dumps to the same bytes.
+func (a *Archiver) dumpSchema(ctx context.Context) ([]byte, error) {
+	path, err := exec.LookPath(pgDumpCommand)
+	if err != nil {
+		return nil, ErrPgDumpNotFound
+	}
+
+	//nolint:gosec // G204: pg_dump is run with a fixed argument layout and a quoted table name
+	cmd := exec.CommandContext(ctx, path,
+		"--schema-only",
+		"--format=plain",
+		"--no-owner",
+		"--no-password",
+		"--table="+qualifiedTableName(a.config.Table),
+		"--dbname="+a.config.Database.psqlConnInfo(""),
+	)
+	cmd.Env = append(os.Environ(), "PGPASSWORD="+a.config.Database.Password)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	data, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("pg_dump failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, fmt.Errorf("%w for %s", ErrSchemaDumpEmpty, a.config.Table)
+	}
+	return data, nil
+}
+
+// exportSchema uploads the table's schema dump when --include-schema is set,
+// and its comments when --include-comments is set. It returns a line
+// describing what was done, or "" when both are off.
+func (a *Archiver) exportSchema(ctx context.Context) (string, error) {
+	var statuses []string
+	if a.config.IncludeSchema {
+		data, err := a.dumpSchema(ctx)
+		if err != nil {
+			return "", err
+		}
+		status, err := a.uploadSchemaArtifact(a.config.schemaObjectKey(), "schema", data)
+		if err != nil {
+			return "", err
+		}
+		statuses = append(statuses, status)
+	}
+	if a.config.IncludeComments {
+		status, err := a.exportComments(ctx)
+		if err != nil {
+			return "", err
+		}
+		statuses = append(statuses, status)
+	}
+	return strings.Join(statuses, "; "), nil
+}
+
+// uploadSchemaArtifact uploads data to key, leaving an object already holding
+// the same bytes alone, and returns a line describing what was done
+func (a *Archiver) uploadSchemaArtifact(key, what string, data []byte) (string, error) {
+	if a.config.DryRun {
+		return fmt.Sprintf("📐 Would upload %s to %s (%s)", what, key, formatBytes(int64(len(data)))), nil
+	}
+
+	sum := md5.Sum(data) //nolint:gosec // MD5 used for checksum comparisons only
+	if exists, _, etag := a.checkObjectExists(key); exists && strings.Trim(etag, "\"") == hex.EncodeToString(sum[:]) {
+		return fmt.Sprintf("📐 %s unchanged at %s", strings.ToUpper(what[:1])+what[1:], key), nil
+	}
+	if err := a.uploadToS3(key, data); err != nil {
+		return "", fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return fmt.Sprintf("📐 Uploaded %s to %s", what, key), nil
+}
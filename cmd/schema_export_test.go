@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakePgDump points pgDumpCommand at a script standing in for pg_dump
+func fakePgDump(t *testing.T, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake pg_dump is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "pg_dump")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil { //nolint:gosec // test script must be executable
+		t.Fatal(err)
+	}
+	original := pgDumpCommand
+	pgDumpCommand = path
+	t.Cleanup(func() { pgDumpCommand = original })
+}
+
+func TestSchemaObjectKey(t *testing.T) {
+	tests := []struct {
+		pathTemplate, schemaTemplate, want string
+	}{
+		{"archives/{table}/{YYYY}/{MM}", "", "archives/events/events-schema.dump"},
+		{"{YYYY}/{MM}", "", "events-schema.dump"},
+		{"archives/{table}/{YYYY}", "schemas/{table}/", "schemas/events/events-schema.dump"},
+	}
+	for _, tt := range tests {
+		config := &Config{Table: "events", SchemaPathTemplate: tt.schemaTemplate, S3: S3Config{PathTemplate: tt.pathTemplate}}
+		if got := config.schemaObjectKey(); got != tt.want {
+			t.Errorf("schemaObjectKey(%q, %q) = %q, want %q", tt.pathTemplate, tt.schemaTemplate, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSchemaExport(t *testing.T) {
+	config := newTestConfig()
+	config.IncludeSchema = true
+	config.SchemaPathTemplate = "schemas/{table}"
+	if err := config.validateSchemaExport(); err != nil {
+		t.Errorf("validateSchemaExport() = %v", err)
+	}
+
+	config.SchemaPathTemplate = "schemas/{table}/{YYYY}"
+	if err := config.validateSchemaExport(); !errors.Is(err, ErrTemplatePlaceholderUnknown) {
+		t.Errorf("expected ErrTemplatePlaceholderUnknown, got %v", err)
+	}
+
+	config.SchemaPathTemplate = ""
+	config.Output = StdoutOutput
+	if err := config.validateSchemaExport(); !errors.Is(err, ErrIncludeSchemaConflict) {
+		t.Errorf("expected ErrIncludeSchemaConflict, got %v", err)
+	}
+}
+
+func TestExportSchemaUploadsChangedSchemaOnly(t *testing.T) {
+	argsFile := filepath.Join(t.TempDir(), "args")
+	fakePgDump(t, `printf '%s\n' "$@" > `+argsFile+`
+echo "CREATE TABLE public.events (id bigint, created_at timestamptz) PARTITION BY RANGE (created_at);"
+`)
+
+	store := &fakeObjectStore{objects: map[string][]byte{}}
+	archiver := NewArchiver(&Config{
+		Table:         "events",
+		IncludeSchema: true,
+		Database:      DatabaseConfig{Host: "localhost", Port: 5432, Name: "analytics", MaxParallelWorkers: -1},
+		S3:            S3Config{Bucket: "bucket", PathTemplate: "archives/{table}/{YYYY}/{MM}"},
+	}, newTestLogger())
+	archiver.s3Client = newS3ObjectStore(store)
+	ctx := context.Background()
+
+	status, err := archiver.exportSchema(ctx)
+	if err != nil || status != "📐 Uploaded schema to archives/events/events-schema.dump" {
+		t.Fatalf("exportSchema() = %q, %v", status, err)
+	}
+	if !strings.HasPrefix(string(store.objects["archives/events/events-schema.dump"]), "CREATE TABLE public.events") {
+		t.Errorf("uploaded schema = %q", store.objects["archives/events/events-schema.dump"])
+	}
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"--schema-only", "--format=plain", `--table="public"."events"`, "--dbname=host='localhost' port=5432"} {
+		if !strings.Contains(string(args), want) {
+			t.Errorf("pg_dump args missing %q:\n%s", want, args)
+		}
+	}
+
+	// The same schema is not uploaded again
+	if status, err := archiver.exportSchema(ctx); err != nil || status != "📐 Schema unchanged at archives/events/events-schema.dump" {
+		t.Errorf("exportSchema() again = %q, %v", status, err)
+	}
+
+	pgDumpCommand = filepath.Join(t.TempDir(), "missing")
+	if _, err := archiver.exportSchema(ctx); !errors.Is(err, ErrPgDumpNotFound) {
+		t.Errorf("expected ErrPgDumpNotFound, got %v", err)
+	}
+
+	archiver.config.IncludeSchema = false
+	if status, err := archiver.exportSchema(ctx); status != "" || err != nil {
+		t.Errorf("exportSchema() when off = %q, %v", status, err)
+	}
+}
@@ -0,0 +1,586 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/airframesio/data-archiver/cmd/compressors"
+	"github.com/airframesio/data-archiver/cmd/formatters"
+	"github.com/lib/pq"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Static errors for the self-test
+var (
+	ErrSelftestFailed       = errors.New("self-test failed")
+	ErrSelftestNoFormats    = errors.New("self-test needs at least one format and compression")
+	ErrSelftestS3Incomplete = errors.New("--s3-prefix needs --s3-endpoint, --s3-bucket, --s3-access-key, and --s3-secret-key")
+)
+
+var (
+	selftestFormats      string
+	selftestCompressions string
+	selftestS3Prefix     string
+	selftestOutputDir    string
+	selftestKeep         bool
+)
+
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Round-trip every output format and compression through archive and restore",
+	Long: `Create a scratch table holding every column type the archiver supports, archive it in each
+format and compression, restore each file into a second scratch table, and compare the two.
+Files go to a local directory, or through the bucket when --s3-prefix is set, so an installation
+can be validated end to end against its real database and storage in minutes.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		runSelftest(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(selftestCmd)
+
+	// Database flags
+	selftestCmd.Flags().StringVar(&dbHost, "db-host", "localhost", "PostgreSQL host")
+	selftestCmd.Flags().IntVar(&dbPort, "db-port", 5432, "PostgreSQL port")
+	selftestCmd.Flags().StringVar(&dbUser, "db-user", "", "PostgreSQL user")
+	selftestCmd.Flags().StringVar(&dbPassword, "db-password", "", "PostgreSQL password")
+	selftestCmd.Flags().StringVar(&dbName, "db-name", "", "PostgreSQL database name")
+	selftestCmd.Flags().StringVar(&dbSSLMode, "db-sslmode", "disable", "PostgreSQL SSL mode (disable, require, verify-ca, verify-full)")
+
+	// S3 flags
+	selftestCmd.Flags().StringVar(&s3Endpoint, "s3-endpoint", "", "S3-compatible endpoint URL")
+	selftestCmd.Flags().StringVar(&s3Bucket, "s3-bucket", "", "S3 bucket name")
+	selftestCmd.Flags().StringVar(&s3AccessKey, "s3-access-key", "", "S3 access key")
+	selftestCmd.Flags().StringVar(&s3SecretKey, "s3-secret-key", "", "S3 secret key")
+	selftestCmd.Flags().StringVar(&s3Region, "s3-region", "auto", "S3 region")
+
+	// Self-test flags
+	selftestCmd.Flags().StringVar(&selftestFormats, "formats", "jsonl,csv,parquet", "comma-separated output formats to test")
+	selftestCmd.Flags().StringVar(&selftestCompressions, "compressions", "zstd,lz4,gzip,none", "comma-separated compressions to test (parquet compresses internally and is tested once)")
+	selftestCmd.Flags().StringVar(&selftestS3Prefix, "s3-prefix", "", "scratch bucket prefix to upload and download the test files through (default: local files only)")
+	selftestCmd.Flags().StringVar(&selftestOutputDir, "output-dir", "", "keep the test files in this directory (default: a temp directory that is removed)")
+	selftestCmd.Flags().BoolVar(&selftestKeep, "keep", false, "keep the scratch tables and uploaded objects for inspection")
+}
+
+// selftestColumn is a column of the self-test table with one SQL literal per test row
+type selftestColumn struct {
+	Name   string
+	Type   string
+	Values []string
+}
+
+// selftestColumns cover every type the restorer maps, with edge values in the
+// second row and NULLs in the third
+var selftestColumns = []selftestColumn{
+	{"c_smallint", "smallint", []string{"1", "-32768", "NULL"}},
+	{"c_integer", "integer", []string{"42", "2147483647", "NULL"}},
+	{"c_bigint", "bigint", []string{"9007199254740993", "-9223372036854775808", "NULL"}},
+	{"c_real", "real", []string{"1.5", "-3.25e-5", "NULL"}},
+	{"c_double", "double precision", []string{"3.141592653589793", "-1e+300", "NULL"}},
+	{"c_numeric", "numeric(20,6)", []string{"12345.678901", "-0.000001", "NULL"}},
+	{"c_boolean", "boolean", []string{"true", "false", "NULL"}},
+	{"c_text", "text", []string{"'plain text'", `'quotes " and '', commas;' || chr(10) || 'newlines ✈'`, "NULL"}},
+	{"c_varchar", "varchar(32)", []string{"'ABC123'", "'ünïcødé'", "NULL"}},
+	{"c_char", "char(4)", []string{"'AB'", "'WXYZ'", "NULL"}},
+	{"c_date", "date", []string{"'2024-01-15'", "'1999-12-31'", "NULL"}},
+	{"c_timestamp", "timestamp", []string{"'2024-01-15 10:30:00.123456'", "'2000-02-29 23:59:59'", "NULL"}},
+	{"c_timestamptz", "timestamptz", []string{"'2024-01-15 10:30:00.123456+00'", "'2024-06-30 23:59:59.5-07'", "NULL"}},
+	{"c_json", "json", []string{`'{"a": 1, "b": [true, null]}'`, `'[1, "two", {"three": 3.5}]'`, "NULL"}},
+	{"c_jsonb", "jsonb", []string{`'{"nested": {"k": "v"}}'`, `'"just a string"'`, "NULL"}},
+	{"c_uuid", "uuid", []string{"'6f1c1e5e-0f35-4c1b-9d8e-1a2b3c4d5e6f'", "'00000000-0000-0000-0000-000000000000'", "NULL"}},
+	{"c_bytea", "bytea", []string{`'\x00ff10'::bytea`, `'\xdeadbeef'::bytea`, "NULL"}},
+}
+
+// selftestCreateSQL creates a self-test table; the id column matches source
+// and restored rows when comparing
+func selftestCreateSQL(table string) string {
+	definitions := []string{"id bigint PRIMARY KEY"}
+	for _, column := range selftestColumns {
+		definitions = append(definitions, fmt.Sprintf("%s %s", column.Name, column.Type))
+	}
+	return fmt.Sprintf("CREATE TABLE %s (%s)", pq.QuoteIdentifier(table), strings.Join(definitions, ", "))
+}
+
+// selftestInsertSQL fills the source table with the test rows
+func selftestInsertSQL(table string) string {
+	names := []string{"id"}
+	for _, column := range selftestColumns {
+		names = append(names, column.Name)
+	}
+	rows := make([]string, len(selftestColumns[0].Values))
+	for i := range rows {
+		values := []string{fmt.Sprintf("%d", i+1)}
+		for _, column := range selftestColumns {
+			values = append(values, column.Values[i])
+		}
+		rows[i] = "(" + strings.Join(values, ", ") + ")"
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", pq.QuoteIdentifier(table), strings.Join(names, ", "), strings.Join(rows, ", "))
+}
+
+// selftestCompareSQL counts source rows missing from the restored table,
+// restored rows not in the source, and per column the rows whose values
+// differ. json has no equality operator, so it is compared as jsonb.
+func selftestCompareSQL(source, restored string) string {
+	counts := []string{
+		"count(*) FILTER (WHERE d.id IS NULL)",
+		"count(*) FILTER (WHERE s.id IS NULL)",
+	}
+	for _, column := range selftestColumns {
+		name := pq.QuoteIdentifier(column.Name)
+		left, right := "s."+name, "d."+name
+		if column.Type == "json" {
+			left, right = left+"::jsonb", right+"::jsonb"
+		}
+		counts = append(counts, fmt.Sprintf("count(*) FILTER (WHERE s.id IS NOT NULL AND d.id IS NOT NULL AND %s IS DISTINCT FROM %s)", left, right))
+	}
+	return fmt.Sprintf("SELECT %s FROM %s s FULL JOIN %s d ON d.id = s.id",
+		strings.Join(counts, ", "), pq.QuoteIdentifier(source), pq.QuoteIdentifier(restored))
+}
+
+// selftestCombo is one format and compression to round-trip
+type selftestCombo struct {
+	Format      string
+	Compression string
+}
+
+// String names the combination in the report
+func (c selftestCombo) String() string {
+	if formatters.UsesInternalCompression(c.Format) {
+		return c.Format + " (internal)"
+	}
+	return c.Format + "+" + c.Compression
+}
+
+// selftestCombos pairs each format with each compression, except formats
+// that compress internally, which are tested once
+func selftestCombos(formats, compressions []string) []selftestCombo {
+	var combos []selftestCombo
+	for _, format := range formats {
+		if formatters.UsesInternalCompression(format) {
+			combos = append(combos, selftestCombo{Format: format, Compression: "none"})
+			continue
+		}
+		for _, compression := range compressions {
+			combos = append(combos, selftestCombo{Format: format, Compression: compression})
+		}
+	}
+	return combos
+}
+
+// parseSelftestList splits a comma-separated flag and checks each value
+func parseSelftestList(value string, valid map[string]bool, errInvalid error) ([]string, error) {
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		if !valid[item] {
+			return nil, fmt.Errorf("%w, got '%s'", errInvalid, item)
+		}
+		values = append(values, item)
+	}
+	return values, nil
+}
+
+// selftestResult is the outcome of one combination
+type selftestResult struct {
+	Combo      selftestCombo
+	Bytes      int64
+	Rows       int64
+	Duration   time.Duration
+	Mismatches []string // Differences between the source and restored tables
+	Err        error
+}
+
+// Passed reports whether the combination round-tripped exactly
+func (r selftestResult) Passed() bool {
+	return r.Err == nil && len(r.Mismatches) == 0
+}
+
+// selfTester archives and restores the scratch tables
+type selfTester struct {
+	archiver  *Archiver
+	restorer  *Restorer
+	source    string
+	restored  string
+	outputDir string
+	s3Prefix  string
+	uploaded  []string
+}
+
+// setup creates and fills the source table and creates the empty restore table
+func (t *selfTester) setup(ctx context.Context) error {
+	db := t.archiver.db
+	for _, statement := range []string{
+		selftestCreateSQL(t.source),
+		selftestInsertSQL(t.source),
+		selftestCreateSQL(t.restored),
+	} {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to create scratch tables: %w", err)
+		}
+	}
+	return nil
+}
+
+// cleanup drops the scratch tables and deletes uploaded objects
+func (t *selfTester) cleanup() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for _, table := range []string{t.source, t.restored} {
+		if _, err := t.archiver.db.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", pq.QuoteIdentifier(table))); err != nil {
+			t.archiver.logger.Warn(fmt.Sprintf("⚠️  Failed to drop scratch table %s: %v", table, err))
+		}
+	}
+	for _, key := range t.uploaded {
+		if err := t.archiver.s3Client.Delete(ctx, t.archiver.config.S3.Bucket, key); err != nil {
+			t.archiver.logger.Warn(fmt.Sprintf("⚠️  Failed to delete s3://%s/%s: %v", t.archiver.config.S3.Bucket, key, err))
+		}
+	}
+}
+
+// run archives the source table in combo, restores the file, and compares
+func (t *selfTester) run(ctx context.Context, combo selftestCombo) (result selftestResult) {
+	result.Combo = combo
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	config := t.archiver.config
+	config.OutputFormat = combo.Format
+	config.Compression = combo.Compression
+	compressor, err := compressors.GetCompressor(combo.Compression)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	config.CompressionLevel = compressor.DefaultLevel()
+
+	cache := &PartitionCache{Entries: make(map[string]PartitionCacheEntry)}
+	partition := PartitionInfo{TableName: t.source, Date: time.Now().UTC().Truncate(24 * time.Hour)}
+	tempPath, size, _, _, rows, err := t.archiver.extractPartitionDataWithRetry(partition, nil, cache, func(string) {}, t.archiver.compressionLevel(), nil, nil, nil)
+	if err != nil {
+		result.Err = fmt.Errorf("archive: %w", err)
+		return result
+	}
+	defer cleanupTempFile(tempPath)
+	result.Bytes, result.Rows = size, rows
+
+	filename := combo.Format + "-" + combo.Compression + formatters.GetStreamingFormatter(combo.Format).Extension()
+	if !formatters.UsesInternalCompression(combo.Format) {
+		filename += compressor.Extension()
+	}
+	filePath, err := t.store(ctx, tempPath, filename)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if filePath != tempPath {
+		defer func() {
+			if t.outputDir == "" || filepath.Dir(filePath) != t.outputDir {
+				cleanupTempFile(filePath)
+			}
+		}()
+	}
+
+	if err := t.restore(ctx, filePath, combo); err != nil {
+		result.Err = fmt.Errorf("restore: %w", err)
+		return result
+	}
+	result.Mismatches, err = t.compare(ctx)
+	if err != nil {
+		result.Err = fmt.Errorf("compare: %w", err)
+	}
+	return result
+}
+
+// store keeps the archived file in the output directory, or sends it through
+// the bucket and downloads it again, and returns the path to restore from
+func (t *selfTester) store(ctx context.Context, tempPath, filename string) (string, error) {
+	if t.s3Prefix != "" {
+		key := path.Join(t.s3Prefix, filename)
+		if err := t.archiver.uploadTempFileToS3(tempPath, key); err != nil {
+			return "", fmt.Errorf("upload: %w", err)
+		}
+		t.uploaded = append(t.uploaded, key)
+
+		body, _, err := t.archiver.s3Client.Get(ctx, t.archiver.config.S3.Bucket, key, GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("download: %w", err)
+		}
+		defer body.Close()
+		return t.writeFile(body, filename)
+	}
+
+	if t.outputDir == "" {
+		return tempPath, nil
+	}
+	file, err := os.Open(tempPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return t.writeFile(file, filename)
+}
+
+// writeFile copies r into the output directory, or a temp file without one
+func (t *selfTester) writeFile(r io.Reader, filename string) (string, error) {
+	var file *os.File
+	var err error
+	if t.outputDir != "" {
+		file, err = os.Create(filepath.Join(t.outputDir, filename))
+	} else {
+		file, err = createTempFile()
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create test file: %w", err)
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, r); err != nil {
+		cleanupTempFile(file.Name())
+		return "", fmt.Errorf("failed to write test file: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// restore empties the restore table and loads the file into it the way the
+// restore command does
+func (t *selfTester) restore(ctx context.Context, filePath string, combo selftestCombo) error {
+	if _, err := t.restorer.db.ExecContext(ctx, fmt.Sprintf("TRUNCATE %s", pq.QuoteIdentifier(t.restored))); err != nil {
+		return err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rows, err := t.restorer.readFileRows(file, combo.Format, combo.Compression)
+	if err != nil {
+		return err
+	}
+	schema, err := t.restorer.getTableSchema(ctx, t.restored)
+	if err != nil {
+		return err
+	}
+	return t.restorer.insertRows(ctx, t.restored, rows, schema)
+}
+
+// compare describes each difference between the source and restored tables
+func (t *selfTester) compare(ctx context.Context) ([]string, error) {
+	counts := make([]int64, len(selftestColumns)+2)
+	targets := make([]interface{}, len(counts))
+	for i := range counts {
+		targets[i] = &counts[i]
+	}
+	if err := t.archiver.db.QueryRowContext(ctx, selftestCompareSQL(t.source, t.restored)).Scan(targets...); err != nil {
+		return nil, err
+	}
+
+	var mismatches []string
+	if counts[0] > 0 {
+		mismatches = append(mismatches, fmt.Sprintf("%d rows missing", counts[0]))
+	}
+	if counts[1] > 0 {
+		mismatches = append(mismatches, fmt.Sprintf("%d unexpected rows", counts[1]))
+	}
+	for i, column := range selftestColumns {
+		if count := counts[i+2]; count > 0 {
+			mismatches = append(mismatches, fmt.Sprintf("%s (%s) differs in %d rows", column.Name, column.Type, count))
+		}
+	}
+	return mismatches, nil
+}
+
+func runSelftest(cmd *cobra.Command) {
+	getStringConfig := func(flagValue string, flagName string, viperKey string) string {
+		if flag := cmd.Flags().Lookup(flagName); flag != nil && flag.Changed {
+			return flagValue
+		}
+		if viperValue := viper.GetString(viperKey); viperValue != "" {
+			return viperValue
+		}
+		return flagValue
+	}
+	getIntConfig := func(flagValue int, flagName string, viperKey string) int {
+		if flag := cmd.Flags().Lookup(flagName); flag != nil && flag.Changed {
+			return flagValue
+		}
+		if viperValue := viper.GetInt(viperKey); viperValue != 0 {
+			return viperValue
+		}
+		return flagValue
+	}
+
+	suffix := time.Now().UTC().Format("20060102150405")
+	config := &Config{
+		Debug:     viper.GetBool("debug"),
+		LogFormat: viper.GetString("log_format"),
+		Database: DatabaseConfig{
+			Host:     getStringConfig(dbHost, "db-host", "db.host"),
+			Port:     getIntConfig(dbPort, "db-port", "db.port"),
+			User:     getStringConfig(dbUser, "db-user", "db.user"),
+			Password: getStringConfig(dbPassword, "db-password", "db.password"),
+			Name:     getStringConfig(dbName, "db-name", "db.name"),
+			SSLMode:  getStringConfig(dbSSLMode, "db-sslmode", "db.sslmode"),
+		},
+		S3: S3Config{
+			Endpoint:  getStringConfig(s3Endpoint, "s3-endpoint", "s3.endpoint"),
+			Bucket:    getStringConfig(s3Bucket, "s3-bucket", "s3.bucket"),
+			AccessKey: getStringConfig(s3AccessKey, "s3-access-key", "s3.access_key"),
+			SecretKey: getStringConfig(s3SecretKey, "s3-secret-key", "s3.secret_key"),
+			Region:    getStringConfig(s3Region, "s3-region", "s3.region"),
+		},
+		Table:     "data_archiver_selftest_" + suffix,
+		ChunkSize: 1000,
+	}
+	config.CacheScope = NewCacheScope("selftest", config)
+	var s3Err error
+	config.S3, s3Err = newS3Config(config.S3, endpointProfileName("selftest"))
+
+	initLogger(config.Debug, config.LogFormat)
+
+	logger.Info("")
+	logger.Info(fmt.Sprintf("🧪 Data Archiver Self-Test v%s", Version))
+	logger.Info("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+	if s3Err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", s3Err.Error()))
+		os.Exit(1)
+	}
+
+	formats, err := parseSelftestList(selftestFormats, map[string]bool{"jsonl": true, "csv": true, "parquet": true}, ErrOutputFormatInvalid)
+	var compressionList []string
+	if err == nil {
+		compressionList, err = parseSelftestList(selftestCompressions, map[string]bool{"zstd": true, "lz4": true, "gzip": true, "brotli": true, "xz": true, "none": true}, ErrCompressionInvalid)
+	}
+	if err == nil {
+		err = validateSelftestConfig(config, formats, compressionList)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	ctx := signalContext
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	restorer := NewRestorer(config, logger)
+	if err := restorer.connect(ctx); err != nil {
+		logger.Error(fmt.Sprintf("❌ Failed to connect: %v", err))
+		os.Exit(1)
+	}
+	defer restorer.db.Close()
+	if restorer.tunnel != nil {
+		defer restorer.tunnel.Close()
+	}
+
+	archiver := NewArchiver(config, logger)
+	archiver.ctx = ctx
+	archiver.db = restorer.db
+	archiver.s3Client = restorer.s3Client
+
+	tester := &selfTester{
+		archiver:  archiver,
+		restorer:  restorer,
+		source:    config.Table,
+		restored:  config.Table + "_restored",
+		outputDir: selftestOutputDir,
+	}
+	if selftestS3Prefix != "" {
+		tester.s3Prefix = path.Join(strings.Trim(selftestS3Prefix, "/"), "selftest-"+suffix)
+	}
+	if tester.outputDir != "" {
+		if err := os.MkdirAll(tester.outputDir, 0o755); err != nil {
+			logger.Error(fmt.Sprintf("❌ Failed to create output directory: %v", err))
+			os.Exit(1)
+		}
+	}
+
+	if err := tester.setup(ctx); err != nil {
+		logger.Error(fmt.Sprintf("❌ %v", err))
+		tester.cleanup()
+		os.Exit(1)
+	}
+	if selftestKeep {
+		logger.Info(fmt.Sprintf("Scratch tables %s and %s will be kept", tester.source, tester.restored))
+	} else {
+		defer tester.cleanup()
+	}
+
+	var results []selftestResult
+	for _, combo := range selftestCombos(formats, compressionList) {
+		if ctx.Err() != nil {
+			break
+		}
+		result := tester.run(ctx, combo)
+		results = append(results, result)
+		switch {
+		case result.Err != nil:
+			logger.Error(fmt.Sprintf("   ❌ %-18s %v", combo, result.Err))
+		case len(result.Mismatches) > 0:
+			logger.Error(fmt.Sprintf("   ❌ %-18s %s", combo, strings.Join(result.Mismatches, "; ")))
+		default:
+			logger.Info(fmt.Sprintf("   ✅ %-18s %d rows, %s, %v", combo, result.Rows, formatBytes(result.Bytes), result.Duration.Round(time.Millisecond)))
+		}
+	}
+
+	if err := selftestOutcome(results); err != nil {
+		logger.Error(fmt.Sprintf("❌ %v", err))
+		if !selftestKeep {
+			tester.cleanup()
+		}
+		os.Exit(1)
+	}
+	logger.Info("")
+	logger.Info(fmt.Sprintf("✅ All %d format and compression combinations round-tripped exactly", len(results)))
+}
+
+// selftestOutcome returns ErrSelftestFailed naming the failed combinations
+func selftestOutcome(results []selftestResult) error {
+	var failed []string
+	for _, result := range results {
+		if !result.Passed() {
+			failed = append(failed, result.Combo.String())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %d of %d combinations (%s)", ErrSelftestFailed, len(failed), len(results), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// validateSelftestConfig checks the database settings, and the S3 settings
+// when files go through the bucket
+func validateSelftestConfig(config *Config, formats, compressionList []string) error {
+	if config.Database.User == "" {
+		return ErrDatabaseUserRequired
+	}
+	if config.Database.Name == "" {
+		return ErrDatabaseNameRequired
+	}
+	if len(formats) == 0 || len(compressionList) == 0 {
+		return ErrSelftestNoFormats
+	}
+	if selftestS3Prefix != "" {
+		s3Config := config.S3
+		if s3Config.Endpoint == "" || s3Config.Bucket == "" || s3Config.AccessKey == "" || s3Config.SecretKey == "" {
+			return ErrSelftestS3Incomplete
+		}
+		return s3Config.HTTP.Validate()
+	}
+	return nil
+}
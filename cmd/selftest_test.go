@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSelftestCombos(t *testing.T) {
+	combos := selftestCombos([]string{"jsonl", "parquet"}, []string{"zstd", "none"})
+
+	var names []string
+	for _, combo := range combos {
+		names = append(names, combo.String())
+	}
+	if got, want := strings.Join(names, ","), "jsonl+zstd,jsonl+none,parquet (internal)"; got != want {
+		t.Errorf("combos = %s, want %s", got, want)
+	}
+}
+
+func TestParseSelftestList(t *testing.T) {
+	valid := map[string]bool{"jsonl": true, "csv": true}
+	got, err := parseSelftestList(" JSONL, ,csv", valid, ErrOutputFormatInvalid)
+	if err != nil || strings.Join(got, ",") != "jsonl,csv" {
+		t.Errorf("parseSelftestList() = %v, %v", got, err)
+	}
+	if _, err := parseSelftestList("jsonl,xml", valid, ErrOutputFormatInvalid); !errors.Is(err, ErrOutputFormatInvalid) {
+		t.Errorf("expected ErrOutputFormatInvalid, got %v", err)
+	}
+}
+
+func TestSelftestSQL(t *testing.T) {
+	insert := selftestInsertSQL("src")
+	if !strings.Contains(insert, `INSERT INTO "src" (id, c_smallint`) || strings.Count(insert, "), (") != len(selftestColumns[0].Values)-1 {
+		t.Errorf("unexpected insert: %s", insert)
+	}
+	for _, column := range selftestColumns {
+		if len(column.Values) != len(selftestColumns[0].Values) {
+			t.Errorf("column %s has %d values", column.Name, len(column.Values))
+		}
+	}
+
+	compare := selftestCompareSQL("src", "dst")
+	if !strings.Contains(compare, `s."c_json"::jsonb IS DISTINCT FROM d."c_json"::jsonb`) {
+		t.Errorf("json should be compared as jsonb: %s", compare)
+	}
+	if !strings.Contains(compare, `FROM "src" s FULL JOIN "dst" d ON d.id = s.id`) {
+		t.Errorf("unexpected join: %s", compare)
+	}
+}
+
+func TestSelftestCompare(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	archiver := NewArchiver(&Config{}, newTestLogger())
+	archiver.db = db
+	tester := &selfTester{archiver: archiver, source: "src", restored: "dst"}
+
+	columns := []string{"missing", "extra"}
+	values := []driver.Value{int64(0), int64(1)}
+	for _, column := range selftestColumns {
+		columns = append(columns, column.Name)
+		count := int64(0)
+		if column.Name == "c_bytea" {
+			count = 2
+		}
+		values = append(values, count)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`FULL JOIN "dst" d`)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(values...))
+
+	mismatches, err := tester.compare(context.Background())
+	if err != nil {
+		t.Fatalf("compare() error = %v", err)
+	}
+	if got, want := strings.Join(mismatches, "; "), "1 unexpected rows; c_bytea (bytea) differs in 2 rows"; got != want {
+		t.Errorf("mismatches = %q, want %q", got, want)
+	}
+
+	result := selftestResult{Combo: selftestCombo{Format: "csv", Compression: "gzip"}, Mismatches: mismatches}
+	err = selftestOutcome([]selftestResult{{Combo: selftestCombo{Format: "jsonl", Compression: "zstd"}}, result})
+	if !errors.Is(err, ErrSelftestFailed) || !strings.Contains(err.Error(), "1 of 2 combinations (csv+gzip)") {
+		t.Errorf("unexpected outcome %v", err)
+	}
+}
@@ -0,0 +1,342 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// defaultTrashPrefix is where soft-deleted archives wait in the bucket
+const defaultTrashPrefix = "_data-archiver/trash"
+
+// trashDateLayout names the per-day folders of the trash
+const trashDateLayout = "2006-01-02"
+
+// Static errors for soft deletes
+var (
+	ErrSoftDeleteDaysInvalid   = errors.New("soft delete days must be >= 0")
+	ErrTrashPrefixRequired     = errors.New("trash prefix is required")
+	ErrUndeleteSelectionNeeded = errors.New("undelete needs --prefix or --all")
+)
+
+var (
+	softDeleteDays   int
+	trashPrefix      string
+	undeleteTable    string
+	undeletePrefix   string
+	undeleteAll      bool
+	undeleteDryRun   bool
+	undeleteOverride bool
+)
+
+var undeleteCmd = &cobra.Command{
+	Use:   "undelete",
+	Short: "Recover archives from the soft-delete trash",
+	Long: `Move archive files that archive runs with --soft-delete-days moved to the trash back to their
+original keys. Files stay recoverable until the soft-delete window has passed and a later archive
+run purges them. When a key was deleted more than once, its most recent copy is restored.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		runUndelete(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(undeleteCmd)
+
+	archiveCmd.Flags().IntVar(&softDeleteDays, "soft-delete-days", 0, "move archive files the archiver deletes to a trash prefix and remove them permanently after this many days (0 = delete immediately)")
+	archiveCmd.Flags().StringVar(&trashPrefix, "trash-prefix", defaultTrashPrefix, "bucket prefix for soft-deleted archive files")
+	_ = viper.BindPFlag("soft_delete.days", archiveCmd.Flags().Lookup("soft-delete-days"))
+	_ = viper.BindPFlag("soft_delete.prefix", archiveCmd.Flags().Lookup("trash-prefix"))
+
+	// S3 flags
+	undeleteCmd.Flags().StringVar(&s3Endpoint, "s3-endpoint", "", "S3-compatible endpoint URL")
+	undeleteCmd.Flags().StringVar(&s3Bucket, "s3-bucket", "", "S3 bucket name")
+	undeleteCmd.Flags().StringVar(&s3AccessKey, "s3-access-key", "", "S3 access key")
+	undeleteCmd.Flags().StringVar(&s3SecretKey, "s3-secret-key", "", "S3 secret key")
+	undeleteCmd.Flags().StringVar(&s3Region, "s3-region", "auto", "S3 region")
+
+	// Undelete-specific flags
+	undeleteCmd.Flags().StringVar(&undeleteTable, "table", "", "base table name (required)")
+	undeleteCmd.Flags().StringVar(&trashPrefix, "trash-prefix", defaultTrashPrefix, "bucket prefix for soft-deleted archive files")
+	undeleteCmd.Flags().StringVar(&undeletePrefix, "prefix", "", "restore files whose original key starts with this prefix")
+	undeleteCmd.Flags().BoolVar(&undeleteAll, "all", false, "restore every file in the table's trash")
+	undeleteCmd.Flags().BoolVar(&undeleteDryRun, "dry-run", false, "list the files that would be restored")
+	undeleteCmd.Flags().BoolVar(&undeleteOverride, "force", false, "overwrite files that exist again at their original key")
+}
+
+// trashTablePrefix returns the trash folder of a table
+func trashTablePrefix(prefix, table string) string {
+	return path.Join(strings.Trim(prefix, "/"), objectKeyComponent(table)) + "/"
+}
+
+// trashKey returns where key is kept when deleted on day
+func trashKey(prefix, table, key string, day time.Time) string {
+	return trashTablePrefix(prefix, table) + day.UTC().Format(trashDateLayout) + "/" + key
+}
+
+// trashedObject is an archive file in the trash
+type trashedObject struct {
+	TrashKey    string
+	OriginalKey string
+	DeletedOn   time.Time // UTC day the file was moved to the trash
+}
+
+// parseTrashKey splits a trash key under tablePrefix into its deletion day and
+// original key
+func parseTrashKey(tablePrefix, key string) (trashedObject, bool) {
+	rest, ok := strings.CutPrefix(key, tablePrefix)
+	if !ok {
+		return trashedObject{}, false
+	}
+	day, original, ok := strings.Cut(rest, "/")
+	if !ok || original == "" {
+		return trashedObject{}, false
+	}
+	deletedOn, err := time.Parse(trashDateLayout, day)
+	if err != nil {
+		return trashedObject{}, false
+	}
+	return trashedObject{TrashKey: key, OriginalKey: original, DeletedOn: deletedOn}, true
+}
+
+// moveToTrash copies key into the table's trash folder for today, then
+// deletes the original
+func moveToTrash(ctx context.Context, client StorageBackend, bucket, prefix, table, key string, now time.Time) (string, error) {
+	target := trashKey(prefix, table, key, now)
+	if err := client.Copy(ctx, bucket, key, target, ""); err != nil {
+		return "", fmt.Errorf("failed to copy %s to the trash: %w", key, err)
+	}
+	if err := client.Delete(ctx, bucket, key); err != nil {
+		return target, fmt.Errorf("copied %s to the trash but failed to delete it: %w", key, err)
+	}
+	return target, nil
+}
+
+// removeArchiveObject deletes key, or with softDeleteDays > 0 moves it to the
+// table's trash folder for today. It returns the trash key, or "" when the
+// object was deleted outright.
+func removeArchiveObject(ctx context.Context, client StorageBackend, bucket, prefix, table, key string, softDeleteDays int, now time.Time) (string, error) {
+	if softDeleteDays > 0 {
+		return moveToTrash(ctx, client, bucket, prefix, table, key, now)
+	}
+	err := client.Delete(ctx, bucket, key)
+	return "", err
+}
+
+// listTrash returns the table's trashed files, oldest deletion first
+func listTrash(ctx context.Context, client StorageBackend, bucket, prefix, table string) ([]trashedObject, error) {
+	tablePrefix := trashTablePrefix(prefix, table)
+	var objects []trashedObject
+	err := client.List(ctx, bucket, tablePrefix, func(object ObjectInfo) bool {
+		if trashed, ok := parseTrashKey(tablePrefix, object.Key); ok {
+			objects = append(objects, trashed)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trash: %w", err)
+	}
+	sort.SliceStable(objects, func(i, j int) bool { return objects[i].DeletedOn.Before(objects[j].DeletedOn) })
+	return objects, nil
+}
+
+// purgeTrash permanently deletes the table's trashed files whose soft-delete
+// window of days has passed
+func purgeTrash(ctx context.Context, client StorageBackend, bucket, prefix, table string, days int, now time.Time) (int, error) {
+	objects, err := listTrash(ctx, client, bucket, prefix, table)
+	if err != nil {
+		return 0, err
+	}
+	today := now.UTC().Truncate(24 * time.Hour)
+	purged := 0
+	for _, object := range objects {
+		if object.DeletedOn.AddDate(0, 0, days).After(today) {
+			continue
+		}
+		if err := client.Delete(ctx, bucket, object.TrashKey); err != nil {
+			return purged, fmt.Errorf("failed to purge %s: %w", object.TrashKey, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// purgeExpiredTrash removes soft-deleted files whose window has passed. A
+// failure is logged; the files are purged by a later run.
+func (a *Archiver) purgeExpiredTrash() {
+	if a.config.SoftDeleteDays <= 0 || a.s3Client == nil || a.config.DryRun {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	purged, err := purgeTrash(ctx, a.s3Client, a.config.S3.Bucket, a.config.TrashPrefix, a.config.Table, a.config.SoftDeleteDays, time.Now())
+	if err != nil {
+		a.logger.Warn(fmt.Sprintf("⚠️  Trash not purged: %v", err))
+	}
+	if purged > 0 {
+		a.logger.Info(fmt.Sprintf("🗑️  Permanently deleted %d file(s) soft-deleted more than %d days ago", purged, a.config.SoftDeleteDays))
+	}
+}
+
+// objectExists reports whether key is in bucket
+func objectExists(ctx context.Context, client StorageBackend, bucket, key string) (bool, error) {
+	_, err := client.Head(ctx, bucket, key)
+	if err == nil {
+		return true, nil
+	}
+	if isObjectNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// undeleteResult is the outcome for one trashed file
+type undeleteResult struct {
+	trashedObject
+	Restored bool
+	Skipped  string // Why the file was left in the trash
+}
+
+// undeleteObjects moves the most recent trashed copy of each selected file
+// back to its original key. Files that exist again are left in the trash
+// unless force is set.
+func undeleteObjects(ctx context.Context, client StorageBackend, bucket, prefix, table, keyPrefix string, dryRun, force bool) ([]undeleteResult, error) {
+	objects, err := listTrash(ctx, client, bucket, prefix, table)
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]trashedObject)
+	for _, object := range objects {
+		if strings.HasPrefix(object.OriginalKey, keyPrefix) {
+			latest[object.OriginalKey] = object
+		}
+	}
+	keys := make([]string, 0, len(latest))
+	for key := range latest {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	results := make([]undeleteResult, 0, len(keys))
+	for _, key := range keys {
+		result := undeleteResult{trashedObject: latest[key]}
+		if !force {
+			exists, err := objectExists(ctx, client, bucket, key)
+			if err != nil {
+				return results, fmt.Errorf("failed to check %s: %w", key, err)
+			}
+			if exists {
+				result.Skipped = "exists again at its original key (use --force to overwrite)"
+				results = append(results, result)
+				continue
+			}
+		}
+		if !dryRun {
+			if err := client.Copy(ctx, bucket, result.TrashKey, key, ""); err != nil {
+				return results, fmt.Errorf("failed to restore %s: %w", key, err)
+			}
+			if err := client.Delete(ctx, bucket, result.TrashKey); err != nil {
+				return results, fmt.Errorf("restored %s but failed to remove %s: %w", key, result.TrashKey, err)
+			}
+		}
+		result.Restored = true
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func runUndelete(cmd *cobra.Command) {
+	getStringConfig := func(flagValue string, flagName string, viperKey string) string {
+		if flag := cmd.Flags().Lookup(flagName); flag != nil && flag.Changed {
+			return flagValue
+		}
+		if viperValue := viper.GetString(viperKey); viperValue != "" {
+			return viperValue
+		}
+		return flagValue
+	}
+
+	s3Config, s3Err := newS3Config(S3Config{
+		Endpoint:  getStringConfig(s3Endpoint, "s3-endpoint", "s3.endpoint"),
+		Bucket:    getStringConfig(s3Bucket, "s3-bucket", "s3.bucket"),
+		AccessKey: getStringConfig(s3AccessKey, "s3-access-key", "s3.access_key"),
+		SecretKey: getStringConfig(s3SecretKey, "s3-secret-key", "s3.secret_key"),
+		Region:    getStringConfig(s3Region, "s3-region", "s3.region"),
+	}, endpointProfileName("undelete"))
+	table := getStringConfig(undeleteTable, "table", "table")
+	prefix := getStringConfig(trashPrefix, "trash-prefix", "soft_delete.prefix")
+
+	initLogger(viper.GetBool("debug"), viper.GetString("log_format"))
+
+	if s3Err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", s3Err.Error()))
+		os.Exit(1)
+	}
+	if err := validateUndeleteConfig(s3Config, table, prefix); err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	ctx := signalContext
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	client, err := newStorageClient(s3Config)
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ Failed to create storage client: %v", err))
+		os.Exit(1)
+	}
+	results, err := undeleteObjects(ctx, client, s3Config.Bucket, prefix, table, undeletePrefix, undeleteDryRun, undeleteOverride)
+	restored := 0
+	for _, result := range results {
+		switch {
+		case result.Skipped != "":
+			logger.Warn(fmt.Sprintf("⚠️  Skipped %s: %s", result.OriginalKey, result.Skipped))
+		case undeleteDryRun:
+			logger.Info(fmt.Sprintf("[DRY RUN] Would restore %s (deleted %s)", result.OriginalKey, result.DeletedOn.Format(trashDateLayout)))
+			restored++
+		default:
+			logger.Info(fmt.Sprintf("♻️  Restored %s (deleted %s)", result.OriginalKey, result.DeletedOn.Format(trashDateLayout)))
+			restored++
+		}
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ %v", err))
+		os.Exit(1)
+	}
+	if len(results) == 0 {
+		logger.Info("No matching files in the trash")
+		return
+	}
+	logger.Info(fmt.Sprintf("✅ %d of %d file(s) restored", restored, len(results)))
+}
+
+// validateUndeleteConfig checks the S3 settings and which files to restore
+func validateUndeleteConfig(s3Config S3Config, table, prefix string) error {
+	if s3Config.Bucket == "" {
+		return ErrS3BucketRequired
+	}
+	if err := s3Config.validateCredentials(); err != nil {
+		return err
+	}
+	if table == "" {
+		return ErrTableNameRequired
+	}
+	if strings.Trim(prefix, "/") == "" {
+		return ErrTrashPrefixRequired
+	}
+	if undeletePrefix == "" && !undeleteAll {
+		return ErrUndeleteSelectionNeeded
+	}
+	return s3Config.HTTP.Validate()
+}
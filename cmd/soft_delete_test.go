@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSoftDeleteAndUndelete(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeObjectStore{objects: map[string][]byte{
+		"events/2024/01/events 2024-01-01.jsonl.zst": []byte("first"),
+		"events/2024/01/events 2024-01-02.jsonl.zst": []byte("second"),
+		"orders/2024/01/orders-2024-01-01.jsonl.zst": []byte("other table"),
+	}}
+	day := time.Date(2024, 3, 1, 15, 0, 0, 0, time.UTC)
+
+	trashed, err := moveToTrash(ctx, newS3ObjectStore(store), "archive", defaultTrashPrefix, "events", "events/2024/01/events 2024-01-01.jsonl.zst", day)
+	if err != nil {
+		t.Fatalf("moveToTrash() error = %v", err)
+	}
+	if trashed != "_data-archiver/trash/events/2024-03-01/events/2024/01/events 2024-01-01.jsonl.zst" {
+		t.Errorf("unexpected trash key %s", trashed)
+	}
+	if _, ok := store.objects["events/2024/01/events 2024-01-01.jsonl.zst"]; ok {
+		t.Error("original should be removed")
+	}
+	// A later delete of the same key keeps both copies; undelete takes the newest
+	store.objects["events/2024/01/events 2024-01-01.jsonl.zst"] = []byte("first, rewritten")
+	if _, err := moveToTrash(ctx, newS3ObjectStore(store), "archive", defaultTrashPrefix, "events", "events/2024/01/events 2024-01-01.jsonl.zst", day.AddDate(0, 0, 5)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := moveToTrash(ctx, newS3ObjectStore(store), "archive", defaultTrashPrefix, "events", "events/2024/01/events 2024-01-02.jsonl.zst", day.AddDate(0, 0, 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first copy's 7-day window has passed 7 days later; the others remain
+	purged, err := purgeTrash(ctx, newS3ObjectStore(store), "archive", defaultTrashPrefix, "events", 7, day.AddDate(0, 0, 7))
+	if err != nil || purged != 1 {
+		t.Fatalf("purgeTrash() = %d, %v; want 1 purged", purged, err)
+	}
+	if _, ok := store.objects[trashed]; ok {
+		t.Error("expired trash copy should be purged")
+	}
+
+	// A dry run and a file that exists again leave the trash alone
+	store.objects["events/2024/01/events 2024-01-02.jsonl.zst"] = []byte("archived again")
+	results, err := undeleteObjects(ctx, newS3ObjectStore(store), "archive", defaultTrashPrefix, "events", "events/2024/01/", true, false)
+	if err != nil || len(results) != 2 || !results[0].Restored || results[1].Skipped == "" {
+		t.Fatalf("unexpected dry run %+v, %v", results, err)
+	}
+	if _, ok := store.objects["events/2024/01/events 2024-01-01.jsonl.zst"]; ok {
+		t.Error("dry run should not restore")
+	}
+
+	results, err = undeleteObjects(ctx, newS3ObjectStore(store), "archive", defaultTrashPrefix, "events", "events/2024/01/events 2024-01-01", false, false)
+	if err != nil || len(results) != 1 || !results[0].Restored {
+		t.Fatalf("unexpected undelete %+v, %v", results, err)
+	}
+	if string(store.objects["events/2024/01/events 2024-01-01.jsonl.zst"]) != "first, rewritten" {
+		t.Errorf("expected the newest copy restored, got %q", store.objects["events/2024/01/events 2024-01-01.jsonl.zst"])
+	}
+	if trash, _ := listTrash(ctx, newS3ObjectStore(store), "archive", defaultTrashPrefix, "events"); len(trash) != 1 || trash[0].OriginalKey != "events/2024/01/events 2024-01-02.jsonl.zst" {
+		t.Errorf("expected only the skipped file left in the trash, got %+v", trash)
+	}
+	if _, ok := store.objects["orders/2024/01/orders-2024-01-01.jsonl.zst"]; !ok {
+		t.Error("other tables must not be touched")
+	}
+}
+
+func TestSoftDeleteConfig(t *testing.T) {
+	config := newTestConfig()
+	config.SoftDeleteDays = -1
+	if err := config.Validate(); !errors.Is(err, ErrSoftDeleteDaysInvalid) {
+		t.Errorf("expected ErrSoftDeleteDaysInvalid, got %v", err)
+	}
+	config.SoftDeleteDays = 30
+	if err := config.Validate(); !errors.Is(err, ErrTrashPrefixRequired) {
+		t.Errorf("expected ErrTrashPrefixRequired, got %v", err)
+	}
+	config.TrashPrefix = defaultTrashPrefix
+	if err := config.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
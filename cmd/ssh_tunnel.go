@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Static errors for SSH tunnel configuration
+var (
+	ErrSSHUserRequired = errors.New("SSH user is required when an SSH host is set")
+	ErrSSHKeyRequired  = errors.New("SSH private key path is required when an SSH host is set")
+	ErrSSHPortInvalid  = errors.New("SSH port must be between 1 and 65535")
+)
+
+const (
+	defaultSSHPort        = 22
+	sshTunnelDialTimeout  = 30 * time.Second
+	sshTunnelLocalAddress = "127.0.0.1"
+)
+
+// SSHTunnelConfig describes an SSH jump host used to reach a database that is
+// not directly reachable. The tunnel is disabled when Host is empty.
+type SSHTunnelConfig struct {
+	Host           string
+	Port           int
+	User           string
+	KeyPath        string // Path to the private key used for authentication
+	KnownHostsPath string // Path to known_hosts (default: ~/.ssh/known_hosts)
+}
+
+// Enabled reports whether an SSH tunnel should be used
+func (c SSHTunnelConfig) Enabled() bool {
+	return c.Host != ""
+}
+
+// Validate checks that the tunnel has everything needed for key authentication
+func (c SSHTunnelConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if c.User == "" {
+		return ErrSSHUserRequired
+	}
+	if c.KeyPath == "" {
+		return ErrSSHKeyRequired
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return ErrSSHPortInvalid
+	}
+	return nil
+}
+
+// address returns the host:port of the SSH server
+func (c SSHTunnelConfig) address() string {
+	port := c.Port
+	if port == 0 {
+		port = defaultSSHPort
+	}
+	return net.JoinHostPort(c.Host, strconv.Itoa(port))
+}
+
+// clientConfig builds the SSH client configuration using key auth and known_hosts verification
+func (c SSHTunnelConfig) clientConfig() (*ssh.ClientConfig, error) {
+	keyPath := expandHomePath(c.KeyPath)
+	keyData, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH key %s: %w", keyPath, err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(keyData)
+	if err != nil {
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			passphrase := os.Getenv("ARCHIVE_SSH_KEY_PASSPHRASE")
+			if passphrase == "" {
+				return nil, fmt.Errorf("SSH key %s is encrypted; set ARCHIVE_SSH_KEY_PASSPHRASE", keyPath)
+			}
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(keyData, []byte(passphrase))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SSH key %s: %w", keyPath, err)
+		}
+	}
+
+	knownHostsPath := c.KnownHostsPath
+	if knownHostsPath == "" {
+		homeDir, _ := os.UserHomeDir()
+		knownHostsPath = filepath.Join(homeDir, ".ssh", "known_hosts")
+	}
+	hostKeyCallback, err := knownhosts.New(expandHomePath(knownHostsPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known hosts from %s: %w", knownHostsPath, err)
+	}
+
+	return &ssh.ClientConfig{
+		User:            c.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         sshTunnelDialTimeout,
+	}, nil
+}
+
+// expandHomePath expands a leading ~/ to the user's home directory
+func expandHomePath(path string) string {
+	if len(path) >= 2 && path[:2] == "~/" {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(homeDir, path[2:])
+		}
+	}
+	return path
+}
+
+// sshTunnel forwards connections from a local listener to a remote address
+// through an SSH connection
+type sshTunnel struct {
+	client   *ssh.Client
+	listener net.Listener
+	remote   string
+	wg       sync.WaitGroup
+}
+
+// startSSHTunnel connects to the SSH host and starts forwarding a local
+// ephemeral port to remoteHost:remotePort as seen from the SSH host
+func startSSHTunnel(ctx context.Context, config SSHTunnelConfig, remoteHost string, remotePort int) (*sshTunnel, error) {
+	clientConfig, err := config.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := net.Dialer{Timeout: sshTunnelDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", config.address())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SSH host %s: %w", config.address(), err)
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, config.address(), clientConfig)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SSH handshake with %s failed: %w", config.address(), err)
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(sshTunnelLocalAddress, "0"))
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to open local tunnel listener: %w", err)
+	}
+
+	tunnel := &sshTunnel{
+		client:   client,
+		listener: listener,
+		remote:   net.JoinHostPort(remoteHost, strconv.Itoa(remotePort)),
+	}
+	tunnel.wg.Add(1)
+	go tunnel.acceptLoop()
+
+	return tunnel, nil
+}
+
+// LocalHost returns the host the tunnel listens on
+func (t *sshTunnel) LocalHost() string {
+	return sshTunnelLocalAddress
+}
+
+// LocalPort returns the ephemeral port the tunnel listens on
+func (t *sshTunnel) LocalPort() int {
+	return t.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Close stops accepting connections and tears down the SSH session
+func (t *sshTunnel) Close() error {
+	err := t.listener.Close()
+	if clientErr := t.client.Close(); err == nil {
+		err = clientErr
+	}
+	t.wg.Wait()
+	return err
+}
+
+func (t *sshTunnel) acceptLoop() {
+	defer t.wg.Done()
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		t.wg.Add(1)
+		go t.forward(local)
+	}
+}
+
+func (t *sshTunnel) forward(local net.Conn) {
+	defer t.wg.Done()
+	defer local.Close()
+
+	remote, err := t.client.Dial("tcp", t.remote)
+	if err != nil {
+		if logger != nil {
+			logger.Warn(fmt.Sprintf("⚠️  SSH tunnel failed to reach %s: %v", t.remote, err))
+		}
+		return
+	}
+	defer remote.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// openDatabaseTunnel starts an SSH tunnel for the database when configured and
+// returns the host and port the PostgreSQL driver should connect to. The
+// returned tunnel is nil when no tunnel is configured.
+func openDatabaseTunnel(ctx context.Context, db DatabaseConfig) (*sshTunnel, string, int, error) {
+	if !db.SSHTunnel.Enabled() {
+		return nil, db.Host, db.Port, nil
+	}
+
+	tunnel, err := startSSHTunnel(ctx, db.SSHTunnel, db.Host, db.Port)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	if logger != nil {
+		logger.Debug(fmt.Sprintf("🔐 SSH tunnel %s:%d -> %s -> %s:%d",
+			tunnel.LocalHost(), tunnel.LocalPort(), db.SSHTunnel.address(), db.Host, db.Port))
+	}
+	return tunnel, tunnel.LocalHost(), tunnel.LocalPort(), nil
+}
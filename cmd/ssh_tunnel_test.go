@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestSSHTunnelConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  SSHTunnelConfig
+		wantErr error
+	}{
+		{"Disabled", SSHTunnelConfig{}, nil},
+		{"Valid", SSHTunnelConfig{Host: "bastion", Port: 22, User: "ops", KeyPath: "~/.ssh/id_ed25519"}, nil},
+		{"MissingUser", SSHTunnelConfig{Host: "bastion", Port: 22, KeyPath: "key"}, ErrSSHUserRequired},
+		{"MissingKey", SSHTunnelConfig{Host: "bastion", Port: 22, User: "ops"}, ErrSSHKeyRequired},
+		{"InvalidPort", SSHTunnelConfig{Host: "bastion", Port: 70000, User: "ops", KeyPath: "key"}, ErrSSHPortInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestExpandHomePath(t *testing.T) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+	if got := expandHomePath("~/.ssh/id_rsa"); got != filepath.Join(homeDir, ".ssh", "id_rsa") {
+		t.Errorf("unexpected expansion: %s", got)
+	}
+	if got := expandHomePath("/etc/key"); got != "/etc/key" {
+		t.Errorf("absolute path should be unchanged, got %s", got)
+	}
+}
+
+// startTestSSHServer starts a minimal SSH server that accepts the given client key
+// and services direct-tcpip channels, returning its address and host key
+func startTestSSHServer(t *testing.T, clientKey ssh.PublicKey) (string, ssh.PublicKey) {
+	t.Helper()
+
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate host key: %v", err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatalf("failed to create host signer: %v", err)
+	}
+
+	serverConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) == string(clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	serverConfig.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, serverConfig)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for newChannel := range chans {
+					if newChannel.ChannelType() != "direct-tcpip" {
+						_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+						continue
+					}
+					// direct-tcpip payload: host string, port uint32, origin host string, origin port uint32
+					payload := newChannel.ExtraData()
+					hostLen := binary.BigEndian.Uint32(payload[:4])
+					host := string(payload[4 : 4+hostLen])
+					port := binary.BigEndian.Uint32(payload[4+hostLen : 8+hostLen])
+
+					target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+					if err != nil {
+						_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+						continue
+					}
+					channel, requests, err := newChannel.Accept()
+					if err != nil {
+						target.Close()
+						continue
+					}
+					go ssh.DiscardRequests(requests)
+					go func() {
+						defer channel.Close()
+						defer target.Close()
+						go func() { _, _ = io.Copy(target, channel) }()
+						_, _ = io.Copy(channel, target)
+					}()
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), hostSigner.PublicKey()
+}
+
+func TestSSHTunnelForwardsTraffic(t *testing.T) {
+	dir := t.TempDir()
+
+	// Client key written as an OpenSSH private key file
+	_, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate client key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		t.Fatalf("failed to marshal client key: %v", err)
+	}
+	keyPath := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	clientSigner, err := ssh.NewSignerFromKey(clientPriv)
+	if err != nil {
+		t.Fatalf("failed to create client signer: %v", err)
+	}
+
+	sshAddr, hostKey := startTestSSHServer(t, clientSigner.PublicKey())
+	sshHost, sshPortStr, _ := net.SplitHostPort(sshAddr)
+	sshPort, _ := strconv.Atoi(sshPortStr)
+
+	knownHostsPath := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(sshAddr)}, hostKey)
+	if err := os.WriteFile(knownHostsPath, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatalf("failed to write known_hosts: %v", err)
+	}
+
+	// Echo server standing in for PostgreSQL
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer echo.Close()
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	echoPort := echo.Addr().(*net.TCPAddr).Port
+
+	db := DatabaseConfig{
+		Host: "127.0.0.1",
+		Port: echoPort,
+		SSHTunnel: SSHTunnelConfig{
+			Host:           sshHost,
+			Port:           sshPort,
+			User:           "archiver",
+			KeyPath:        keyPath,
+			KnownHostsPath: knownHostsPath,
+		},
+	}
+
+	tunnel, host, port, err := openDatabaseTunnel(context.Background(), db)
+	if err != nil {
+		t.Fatalf("failed to open tunnel: %v", err)
+	}
+	defer tunnel.Close()
+
+	if host != "127.0.0.1" || port == echoPort {
+		t.Fatalf("expected local tunnel address, got %s:%d", host, port)
+	}
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("failed to dial tunnel: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if string(buf) != "ping" {
+		t.Fatalf("expected echo of ping, got %q", buf)
+	}
+}
+
+func TestOpenDatabaseTunnelDisabled(t *testing.T) {
+	tunnel, host, port, err := openDatabaseTunnel(context.Background(), DatabaseConfig{Host: "db.internal", Port: 5432})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tunnel != nil {
+		t.Fatal("expected no tunnel when SSH host is empty")
+	}
+	if host != "db.internal" || port != 5432 {
+		t.Fatalf("expected original address, got %s:%d", host, port)
+	}
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateStartFrom(t *testing.T) {
+	if err := (&Config{StartFrom: "flights_20240115"}).validateStartFrom(); err != nil {
+		t.Errorf("validateStartFrom() = %v", err)
+	}
+	if err := (&Config{StartFrom: "flights_20240115", StartFromDate: "2024-01-15"}).validateStartFrom(); !errors.Is(err, ErrStartFromConflict) {
+		t.Errorf("expected ErrStartFromConflict, got %v", err)
+	}
+	if err := (&Config{StartFromDate: "2024/01/15"}).validateStartFrom(); !errors.Is(err, ErrStartFromDateInvalid) {
+		t.Errorf("expected ErrStartFromDateInvalid, got %v", err)
+	}
+}
+
+func plannedFlights() []PartitionInfo {
+	return []PartitionInfo{
+		{TableName: "flights_2023_12", Date: time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)},
+		{TableName: "flights_20240114", Date: time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)},
+		{TableName: "flights_20240115", Date: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{TableName: "flights_20240116", Date: time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)},
+	}
+}
+
+func partitionNames(partitions []PartitionInfo) []string {
+	names := make([]string, len(partitions))
+	for i, partition := range partitions {
+		names[i] = partition.TableName
+	}
+	return names
+}
+
+func TestApplyStartFrom(t *testing.T) {
+	archiver := NewArchiver(&Config{Table: "flights"}, newTestLogger())
+	if planned, err := archiver.applyStartFrom(plannedFlights()); err != nil || len(planned) != 4 {
+		t.Errorf("applyStartFrom() without flags = %v, %v", partitionNames(planned), err)
+	}
+
+	archiver.config.StartFrom = "flights_20240115"
+	planned, err := archiver.applyStartFrom(plannedFlights())
+	if err != nil || len(planned) != 2 || planned[0].TableName != "flights_20240115" {
+		t.Errorf("applyStartFrom() = %v, %v", partitionNames(planned), err)
+	}
+
+	archiver.config.StartFrom = "flights_20240201"
+	if _, err := archiver.applyStartFrom(plannedFlights()); !errors.Is(err, ErrStartFromNotFound) {
+		t.Errorf("expected ErrStartFromNotFound, got %v", err)
+	}
+}
+
+func TestApplyStartFromDate(t *testing.T) {
+	archiver := NewArchiver(&Config{Table: "flights", StartFromDate: "2024-01-15"}, newTestLogger())
+	planned, err := archiver.applyStartFrom(plannedFlights())
+	if err != nil || len(planned) != 2 || planned[0].TableName != "flights_20240115" {
+		t.Errorf("applyStartFrom() = %v, %v", partitionNames(planned), err)
+	}
+
+	// A monthly partition holding the date is kept; its earlier slices are skipped
+	archiver.config.StartFromDate = "2023-12-20"
+	planned, _ = archiver.applyStartFrom(plannedFlights())
+	if len(planned) != 4 {
+		t.Errorf("applyStartFrom() = %v", partitionNames(planned))
+	}
+	if !archiver.beforeStartFrom(time.Date(2023, 12, 20, 0, 0, 0, 0, time.UTC)) || archiver.beforeStartFrom(time.Date(2023, 12, 21, 0, 0, 0, 0, time.UTC)) {
+		t.Error("slices ending on or before the date should be skipped")
+	}
+}
+
+func TestBuildTableConfigsStartFrom(t *testing.T) {
+	base := newTestConfig()
+	base.Table = ""
+	base.Tables = []string{"events", "events_archive"}
+	base.TableConcurrency = 1
+	base.StartFrom = "events_archive_20240115"
+
+	configs, err := buildTableConfigs(base, nil, TableQuota{MaxParallelPartitions: 1})
+	if err != nil {
+		t.Fatalf("buildTableConfigs failed: %v", err)
+	}
+	for _, cfg := range configs {
+		if owns := cfg.StartFrom != ""; owns != (cfg.Table == "events_archive") {
+			t.Errorf("table %s start from = %q", cfg.Table, cfg.StartFrom)
+		}
+	}
+
+	base.StartFrom = "alerts_20240115"
+	if _, err := buildTableConfigs(base, nil, TableQuota{MaxParallelPartitions: 1}); !errors.Is(err, ErrStartFromNotFound) {
+		t.Errorf("expected ErrStartFromNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// ErrStatusOutputFormatInvalid is returned for an unknown status output format
+var ErrStatusOutputFormatInvalid = errors.New("status output format must be one of: text, json")
+
+var (
+	statusOutputFormat string
+	statusOutputFile   string
+	statusStaleAfter   time.Duration
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show a fleet summary of every archived table",
+	Long: `Show a consolidated report across all tables archived on this host, built from the archive caches:
+which tables are up to date, how far behind each table's latest archived data is, and the total bytes
+uploaded in the last 24 hours. Use --output-format json for a machine-readable report.`,
+	RunE: runStatus,
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+	statusCmd.Flags().StringVar(&statusOutputFormat, "output-format", "text", "Output format: text, json")
+	statusCmd.Flags().StringVar(&statusOutputFile, "output-file", "", "Output file path (default: stdout)")
+	statusCmd.Flags().DurationVar(&statusStaleAfter, "stale-after", defaultFleetStaleAfter, "a table is behind when its latest archived data is older than this")
+
+	_ = viper.BindPFlag("status.output_format", statusCmd.Flags().Lookup("output-format"))
+	_ = viper.BindPFlag("status.output_file", statusCmd.Flags().Lookup("output-file"))
+	_ = viper.BindPFlag("status.stale_after", statusCmd.Flags().Lookup("stale-after"))
+}
+
+func runStatus(_ *cobra.Command, _ []string) error {
+	outputFormat := viper.GetString("status.output_format")
+	if outputFormat != "text" && outputFormat != "json" {
+		return fmt.Errorf("%w: '%s'", ErrStatusOutputFormatInvalid, outputFormat)
+	}
+
+	report, err := buildLocalFleetReport(viper.GetDuration("status.stale_after"))
+	if err != nil {
+		return err
+	}
+
+	var output io.Writer = os.Stdout
+	if path := viper.GetString("status.output_file"); path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer file.Close()
+		output = file
+	}
+
+	if outputFormat == "json" {
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	writeFleetText(output, report)
+	return nil
+}
+
+// writeFleetText renders the fleet report in human-readable form
+func writeFleetText(w io.Writer, report FleetReport) {
+	fmt.Fprintf(w, "\n")
+	fmt.Fprintf(w, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Fprintf(w, "FLEET STATUS\n")
+	fmt.Fprintf(w, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Fprintf(w, "\n")
+
+	if report.ArchiverRunning {
+		fmt.Fprintf(w, "🏃 Archiver running (PID %d)", report.PID)
+		if report.CurrentTable != "" {
+			fmt.Fprintf(w, " on %s", report.CurrentTable)
+		}
+		fmt.Fprintf(w, "\n\n")
+	}
+
+	if len(report.Tables) == 0 {
+		fmt.Fprintf(w, "No archived tables found in the cache.\n")
+		return
+	}
+
+	fmt.Fprintf(w, "Tables up to date:   %d\n", report.TablesUpToDate)
+	fmt.Fprintf(w, "Tables behind:       %d\n", report.TablesBehind)
+	if report.FurthestBehind != "" {
+		fmt.Fprintf(w, "Furthest behind:     %s\n", report.FurthestBehind)
+	}
+	fmt.Fprintf(w, "Uploaded (last 24h): %s\n", formatBytes(report.BytesLast24h))
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "%-3s %-32s %-12s %-10s %8s %12s %7s\n", "", "TABLE", "LATEST", "BEHIND", "FILES", "LAST 24H", "ERRORS")
+	fmt.Fprintf(w, "─────────────────────────────────────────────────────────────────────────────────────\n")
+	for _, table := range report.Tables {
+		icon := "✅"
+		if !table.UpToDate {
+			icon = "⚠️ "
+		}
+		latest, behind := "never", "-"
+		if table.LatestArchived != nil {
+			latest = table.LatestArchived.Format("2006-01-02")
+			behind = formatFleetLag(time.Duration(table.BehindHours * float64(time.Hour)))
+		}
+		fmt.Fprintf(w, "%-3s %-32s %-12s %-10s %8d %12s %7d\n",
+			icon, table.Table, latest, behind, table.Files, formatBytes(table.BytesLast24h), table.Errors)
+	}
+
+	writeLastRuns(w, report)
+}
+
+// writeLastRuns lists each table's most recent run, including partial
+// summaries of runs that died before finishing
+func writeLastRuns(w io.Writer, report FleetReport) {
+	var header bool
+	for _, table := range report.Tables {
+		run := table.LastRun
+		if run == nil {
+			continue
+		}
+		if !header {
+			fmt.Fprintf(w, "\nLast runs:\n")
+			header = true
+		}
+		fmt.Fprintf(w, "   %-32s %-12s %s  %d processed (%d ok, %d skipped, %d failed), %s\n",
+			table.Table, run.Status, run.StartTime.Local().Format("2006-01-02 15:04"),
+			run.Processed(), run.Successful, run.Skipped, run.Failed, formatBytes(run.Bytes))
+	}
+	if report.InterruptedRuns > 0 {
+		fmt.Fprintf(w, "\n⚠️  %d run(s) ended without a summary; see 'data-archiver history' for their partial results\n", report.InterruptedRuns)
+	}
+}
+
+// formatFleetLag formats a lag as days and hours
+func formatFleetLag(lag time.Duration) string {
+	if lag < 0 {
+		return "0h"
+	}
+	days := int(lag.Hours()) / 24
+	hours := int(lag.Hours()) % 24
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh", days, hours)
+	}
+	return fmt.Sprintf("%dh", hours)
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// StdoutOutput is the --output value that streams archive output to stdout
+const StdoutOutput = "-"
+
+// Static errors for streaming to stdout
+var (
+	ErrStdoutNoOutput        = errors.New("no partition or slice matches the date range")
+	ErrStdoutMultipleOutputs = errors.New("--output - writes a single partition or slice; narrow --start-date/--end-date")
+	ErrStdoutTables          = errors.New("--output - cannot be used with --tables")
+)
+
+// outputUnit is one output file: a whole partition, or a time slice of one
+// when the partition is split by --output-duration
+type outputUnit struct {
+	Partition PartitionInfo
+	Start     time.Time // Zero for a whole partition
+	End       time.Time
+}
+
+// name describes the unit in log messages
+func (u outputUnit) name() string {
+	if u.Partition.HasKeyRange() {
+		return fmt.Sprintf("%s [%s]", u.Partition.TableName, u.Partition.Keys)
+	}
+	if u.Start.IsZero() {
+		return u.Partition.TableName
+	}
+	return fmt.Sprintf("%s [%s, %s)", u.Partition.TableName, u.Start.Format(time.RFC3339), u.End.Format(time.RFC3339))
+}
+
+// outputUnits expands partitions into the output files a run would write,
+// keeping only those within --start-date/--end-date
+func (a *Archiver) outputUnits(partitions []PartitionInfo) []outputUnit {
+	var windowStart, windowEnd time.Time
+	if a.config.StartDate != "" {
+		windowStart, _ = a.parseDate(a.config.StartDate)
+	}
+	if a.config.EndDate != "" {
+		end, _ := a.parseDate(a.config.EndDate)
+		windowEnd = addLocalDate(end, 0, 0, 1)
+	}
+	inWindow := func(start, end time.Time) bool {
+		return (windowStart.IsZero() || end.After(windowStart)) && (windowEnd.IsZero() || start.Before(windowEnd))
+	}
+
+	var units []outputUnit
+	for _, partition := range partitions {
+		if !a.config.canSliceByTime() || !a.shouldSplitPartition(partition) {
+			if inWindow(partition.Date, partition.Date.Add(time.Nanosecond)) {
+				units = append(units, outputUnit{Partition: partition})
+			}
+			continue
+		}
+
+		partitionStart, partitionEnd := a.partitionPeriodRange(partition)
+		ranges := SplitPartitionByDuration(partitionStart, partitionEnd, a.config.OutputDuration)
+		if len(ranges) > 0 && ranges[0].Start.Before(partitionStart) {
+			ranges[0].Start = partitionStart
+		}
+		for _, r := range ranges {
+			if inWindow(r.Start, r.End) {
+				units = append(units, outputUnit{Partition: partition, Start: r.Start, End: r.End})
+			}
+		}
+	}
+	return units
+}
+
+// RunToWriter extracts a single partition or slice and writes its formatted,
+// compressed output to w instead of uploading it. The output is staged in a
+// temp file first so extraction retries never emit duplicate data.
+func (a *Archiver) RunToWriter(ctx context.Context, w io.Writer) error {
+	a.ctx = ctx
+	defer func() {
+		if a.db != nil {
+			a.db.Close()
+			a.db = nil
+		}
+	}()
+
+	if err := a.connect(ctx); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if err := a.checkTablePermissions(ctx); err != nil {
+		return fmt.Errorf("permission check failed: %w", err)
+	}
+
+	partitions, err := a.findPartitions(ctx)
+	if err != nil {
+		return err
+	}
+	units := a.outputUnits(partitions)
+	switch {
+	case len(units) == 0:
+		return ErrStdoutNoOutput
+	case len(units) > 1:
+		return fmt.Errorf("%w (%d outputs match, from %s to %s)", ErrStdoutMultipleOutputs, len(units), units[0].name(), units[len(units)-1].name())
+	}
+	unit := units[0]
+
+	a.logger.Info(fmt.Sprintf("Streaming %s to stdout", unit.name()))
+	cache, _ := loadPartitionCache(a.config.CacheScope)
+	updateTaskStage := func(stage string) {
+		a.logger.Debug(stage)
+	}
+
+	var tempFilePath string
+	var fileSize, rowCount int64
+	if unit.Start.IsZero() {
+		tempFilePath, fileSize, _, _, rowCount, err = a.extractPartitionDataWithRetry(unit.Partition, nil, cache, updateTaskStage, a.compressionLevel(), nil, nil, nil)
+	} else {
+		tempFilePath, fileSize, _, _, rowCount, err = a.extractPartitionDataStreaming(unit.Partition, nil, cache, updateTaskStage, unit.Start, unit.End, a.compressionLevel(), nil, nil, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", unit.name(), err)
+	}
+	defer cleanupTempFile(tempFilePath)
+
+	file, err := os.Open(tempFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open extracted data: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(w, file); err != nil {
+		return fmt.Errorf("failed to write to stdout: %w", err)
+	}
+
+	a.logger.Info(fmt.Sprintf("✅ Wrote %d rows (%s) to stdout", rowCount, formatBytes(fileSize)))
+	return nil
+}
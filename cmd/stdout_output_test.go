@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConfigValidation_StdoutOutput(t *testing.T) {
+	config := newTestConfig()
+	config.Output = StdoutOutput
+	config.S3 = S3Config{}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("stdout output should not require S3 configuration: %v", err)
+	}
+
+	config.Output = "/tmp/out.jsonl"
+	if err := config.Validate(); !errors.Is(err, ErrOutputTargetInvalid) {
+		t.Errorf("expected ErrOutputTargetInvalid, got %v", err)
+	}
+
+	config = newTestConfig()
+	config.S3.PathTemplate = ""
+	if err := config.Validate(); !errors.Is(err, ErrPathTemplateRequired) {
+		t.Errorf("expected path template to stay required for S3 output, got %v", err)
+	}
+}
+
+func TestBuildTableConfigsRejectsStdout(t *testing.T) {
+	base := newTestConfig()
+	base.Table = ""
+	base.Tables = []string{"flights", "messages"}
+	base.TableConcurrency = 1
+	base.Output = StdoutOutput
+
+	if _, err := buildTableConfigs(base, nil, TableQuota{MaxParallelPartitions: 1}); !errors.Is(err, ErrStdoutTables) {
+		t.Errorf("expected ErrStdoutTables, got %v", err)
+	}
+}
+
+func TestOutputUnits(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	t.Run("DailyPartitionsFilteredByDateRange", func(t *testing.T) {
+		config := newTestConfig()
+		config.StartDate = "2024-01-02"
+		config.EndDate = "2024-01-02"
+		archiver := NewArchiver(config, newTestLogger())
+
+		units := archiver.outputUnits([]PartitionInfo{
+			{TableName: "test_table_20240101", Date: day(1)},
+			{TableName: "test_table_20240102", Date: day(2)},
+			{TableName: "test_table_20240103", Date: day(3)},
+		})
+		if len(units) != 1 || units[0].Partition.TableName != "test_table_20240102" || !units[0].Start.IsZero() {
+			t.Fatalf("expected the whole 2024-01-02 partition, got %+v", units)
+		}
+	})
+
+	t.Run("MonthlyPartitionSplitIntoDailySlices", func(t *testing.T) {
+		config := newTestConfig()
+		config.DateColumn = "created_at"
+		config.StartDate = "2024-01-15"
+		config.EndDate = "2024-01-15"
+		archiver := NewArchiver(config, newTestLogger())
+
+		units := archiver.outputUnits([]PartitionInfo{{TableName: "test_table_2024_01", Date: day(1)}})
+		if len(units) != 1 {
+			t.Fatalf("expected a single slice, got %d", len(units))
+		}
+		if !units[0].Start.Equal(day(15)) || !units[0].End.Equal(day(16)) {
+			t.Errorf("expected the 2024-01-15 slice, got %s to %s", units[0].Start, units[0].End)
+		}
+	})
+
+	t.Run("WideRangeMatchesSeveralSlices", func(t *testing.T) {
+		config := newTestConfig()
+		config.DateColumn = "created_at"
+		archiver := NewArchiver(config, newTestLogger())
+
+		units := archiver.outputUnits([]PartitionInfo{{TableName: "test_table_2024_01", Date: day(1)}})
+		if len(units) != 31 {
+			t.Errorf("expected 31 daily slices, got %d", len(units))
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// stopFilePollInterval is how often the stop file is checked for
+const stopFilePollInterval = 100 * time.Millisecond
+
+var (
+	enableStopFile bool
+	stopFileFlag   string
+
+	// signalCancel cancels signalContext; set by main() via SetSignalContext
+	signalCancel func()
+)
+
+// getStopFilePath returns the well-known stop file path for a command and table.
+// The path is stable across runs so external tooling can request a stop without
+// knowing the PID: <tmp>/data-archiver/<command>[-<table>].stop
+func getStopFilePath(command, table string) string {
+	name := sanitizeCacheComponent(command, "cmd")
+	if table != "" {
+		name += "-" + tableFileComponent(table, "table")
+	}
+	return filepath.Join(os.TempDir(), "data-archiver", name+".stop")
+}
+
+// startStopFileWatcher polls for a stop file and cancels the signal context
+// when it appears. This is a workaround for terminals (e.g. Warp) where CTRL-C
+// doesn't send SIGINT to processes (warpdotdev/Warp#6762, #7745, #4806).
+// It is opt-in via --enable-stop-file; the returned function stops the watcher
+// and removes the stop file.
+func startStopFileWatcher(command, table string) func() {
+	if !viper.GetBool("enable_stop_file") || signalContext == nil || signalCancel == nil {
+		stopFilePath = ""
+		return func() {}
+	}
+
+	path := viper.GetString("stop_file")
+	if path == "" {
+		path = getStopFilePath(command, table)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		logger.Warn(fmt.Sprintf("⚠️  Stop file disabled: failed to create %s: %v", filepath.Dir(path), err))
+		return func() {}
+	}
+
+	// A stop file left behind by an earlier run must not cancel this one
+	if err := os.Remove(path); err == nil {
+		logger.Debug(fmt.Sprintf("Removed stale stop file: %s", path))
+	}
+
+	stopFilePath = path
+	ctx, cancel := signalContext, signalCancel
+	done := make(chan struct{})
+	exited := make(chan struct{})
+
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(stopFilePollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := os.Stat(path); err == nil {
+					// Cancel silently; the command reports the cancellation itself
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-exited
+		_ = os.Remove(path)
+	}
+}
+
+// logStopFileHint tells the user how to request a stop via the stop file
+func logStopFileHint(action string) {
+	if stopFilePath == "" {
+		return
+	}
+	logger.Info(fmt.Sprintf("💡 To stop %s: Press CTRL-C, or run: touch %s", action, stopFilePath))
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestGetStopFilePath(t *testing.T) {
+	dir := filepath.Join(os.TempDir(), "data-archiver")
+
+	if got := getStopFilePath("archive", "flights"); got != filepath.Join(dir, "archive-flights.stop") {
+		t.Errorf("unexpected path: %s", got)
+	}
+	if got := getStopFilePath("compare", ""); got != filepath.Join(dir, "compare.stop") {
+		t.Errorf("unexpected path without table: %s", got)
+	}
+	if got := getStopFilePath("restore", "../etc/passwd"); filepath.Dir(got) != dir {
+		t.Errorf("table name must not escape the stop file directory: %s", got)
+	}
+}
+
+func TestStartStopFileWatcher(t *testing.T) {
+	origCtx, origCancel, origPath, origLogger := signalContext, signalCancel, stopFilePath, logger
+	defer func() {
+		signalContext, signalCancel, stopFilePath, logger = origCtx, origCancel, origPath, origLogger
+		viper.Set("enable_stop_file", false)
+		viper.Set("stop_file", "")
+	}()
+	logger = newTestLogger()
+
+	path := filepath.Join(t.TempDir(), "job.stop")
+
+	t.Run("Disabled", func(t *testing.T) {
+		viper.Set("enable_stop_file", false)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		signalContext, signalCancel = ctx, cancel
+
+		stop := startStopFileWatcher("archive", "flights")
+		defer stop()
+		if stopFilePath != "" {
+			t.Errorf("expected no stop file path when disabled, got %s", stopFilePath)
+		}
+	})
+
+	t.Run("StaleFileRemoved", func(t *testing.T) {
+		viper.Set("enable_stop_file", true)
+		viper.Set("stop_file", path)
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatalf("failed to write stale stop file: %v", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		signalContext, signalCancel = ctx, cancel
+
+		stop := startStopFileWatcher("archive", "flights")
+		time.Sleep(3 * stopFilePollInterval)
+		if ctx.Err() != nil {
+			t.Fatal("stale stop file should not cancel a new run")
+		}
+		stop()
+	})
+
+	t.Run("CancelsOnStopFile", func(t *testing.T) {
+		viper.Set("enable_stop_file", true)
+		viper.Set("stop_file", path)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		signalContext, signalCancel = ctx, cancel
+
+		stop := startStopFileWatcher("restore", "flights")
+		if stopFilePath != path {
+			t.Errorf("expected stop file path %s, got %s", path, stopFilePath)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatalf("failed to write stop file: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected context to be cancelled by stop file")
+		}
+
+		stop()
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Error("stop file should be removed on cleanup")
+		}
+	})
+}
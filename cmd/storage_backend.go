@@ -0,0 +1,839 @@
+package cmd
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // MD5 matches S3 ETags, not used for security
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/spf13/viper"
+)
+
+// Storage backends. Every backend is used through StorageBackend, which all
+// upload, download, list, and multipart code is written against.
+const (
+	StorageBackendS3    = "s3"
+	StorageBackendGCS   = "gcs"
+	StorageBackendAzure = "azure"
+	StorageBackendLocal = "local"
+)
+
+// ObjectInfo describes a stored object. ETags are quoted, as S3 returns
+// them: the MD5 of a single upload, or the multipart ETag of one committed
+// from parts.
+type ObjectInfo struct {
+	Key             string
+	Size            int64
+	ETag            string
+	LastModified    time.Time
+	StorageClass    string // "" when the backend doesn't report one
+	ContentType     string
+	ContentEncoding string
+	Metadata        map[string]string
+
+	// Server-side encryption; the ETags of SSE-KMS and SSE-C objects are not
+	// their MD5s
+	ServerSideEncryption string
+	SSECustomerAlgorithm string
+}
+
+// PutOptions are the properties an object is written with
+type PutOptions struct {
+	ContentType     string
+	ContentEncoding string
+	Metadata        map[string]string
+	Tagging         string // URL-encoded tag set
+	StorageClass    string // "" = the bucket's default
+}
+
+// UploadOptions are the properties of an object written from a stream, and
+// how its multipart upload is split
+type UploadOptions struct {
+	PutOptions
+	PartSize    int64 // 0 = s3manager.DefaultUploadPartSize
+	Concurrency int   // Parts sent at once (0 = s3manager.DefaultUploadConcurrency)
+}
+
+// GetOptions narrow a read
+type GetOptions struct {
+	Range   string // HTTP byte range, e.g. "bytes=0-99" ("" = the whole object)
+	IfMatch string // Fail unless the object still has this quoted ETag
+}
+
+// CompletedPart is an uploaded part of a multipart upload
+type CompletedPart struct {
+	Number int64
+	ETag   string
+}
+
+// StorageBackend is the object storage every command reads and writes
+// through: whole-object and ranged reads, writes, listing, copies, deletes,
+// and multipart uploads. s3ObjectStore implements it for S3 and GCS,
+// azureObjectStore for Azure Blob Storage, and localObjectStore for a
+// directory. Missing objects and uploads are reported as ErrObjectNotFound
+// and ErrUploadNotFound.
+type StorageBackend interface {
+	Head(ctx context.Context, bucket, key string) (ObjectInfo, error)
+	Get(ctx context.Context, bucket, key string, opts GetOptions) (io.ReadCloser, ObjectInfo, error)
+	Put(ctx context.Context, bucket, key string, body io.ReadSeeker, opts PutOptions) (string, error)
+	// Upload writes a stream of unknown length, in parts when it is longer
+	// than one
+	Upload(ctx context.Context, bucket, key string, body io.Reader, opts UploadOptions) (string, error)
+	// Copy copies from to to within bucket, keeping its metadata. A copy
+	// onto itself changes the storage class.
+	Copy(ctx context.Context, bucket, from, to, storageClass string) error
+	// Delete removes key; deleting a missing key succeeds
+	Delete(ctx context.Context, bucket, key string) error
+	// List calls fn for every object under prefix, in key order, until fn
+	// returns false
+	List(ctx context.Context, bucket, prefix string, fn func(ObjectInfo) bool) error
+
+	CreateMultipartUpload(ctx context.Context, bucket, key string, opts PutOptions) (string, error)
+	UploadPart(ctx context.Context, bucket, key, uploadID string, number int64, body io.ReadSeeker) (string, error)
+	ListParts(ctx context.Context, bucket, key, uploadID string) ([]CompletedPart, error)
+	CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []CompletedPart) (string, error)
+	AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error
+}
+
+// gcsEndpoint is Google Cloud Storage's S3-compatible XML API, used with HMAC keys
+const gcsEndpoint = "https://storage.googleapis.com"
+
+// localUploadsDir holds the parts of open multipart uploads in a local bucket
+const localUploadsDir = ".data-archiver-uploads"
+
+// Static errors for storage backends
+var (
+	ErrStorageBackendInvalid = errors.New("storage backend must be one of: s3, gcs, azure, local")
+	ErrObjectNotFound        = errors.New("object not found")
+	ErrUploadNotFound        = errors.New("multipart upload not found")
+	ErrLocalKeyInvalid       = errors.New("object key escapes the storage directory")
+	ErrLocalRangeInvalid     = errors.New("invalid byte range")
+)
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.String("storage-backend", StorageBackendS3, "where archives are stored: s3 (or any S3-compatible endpoint), gcs (Google Cloud Storage with HMAC keys), azure (Azure Blob Storage with the account name and key), local (a directory, given as --s3-bucket)")
+	_ = viper.BindPFlag("storage.backend", flags.Lookup("storage-backend"))
+}
+
+// validateStorageBackend checks the backend name
+func validateStorageBackend(backend string) error {
+	switch backend {
+	case "", StorageBackendS3, StorageBackendGCS, StorageBackendAzure, StorageBackendLocal:
+		return nil
+	default:
+		return fmt.Errorf("%w, got '%s'", ErrStorageBackendInvalid, backend)
+	}
+}
+
+// newStorageClient returns the client for the configured storage backend
+func newStorageClient(cfg S3Config) (StorageBackend, error) {
+	if err := validateStorageBackend(cfg.Backend); err != nil {
+		return nil, err
+	}
+	switch cfg.Backend {
+	case StorageBackendLocal:
+		return &localObjectStore{}, nil
+	case StorageBackendAzure:
+		store, err := newAzureObjectStore(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case StorageBackendGCS:
+		if cfg.Endpoint == "" {
+			cfg.Endpoint = gcsEndpoint
+		}
+	}
+	sess, err := newS3Session(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newS3ObjectStore(s3.New(sess)), nil
+}
+
+// isObjectNotFound reports whether err is a missing object
+func isObjectNotFound(err error) bool {
+	return errors.Is(err, ErrObjectNotFound)
+}
+
+// s3ObjectStore is the StorageBackend of S3 and S3-compatible services,
+// including GCS through its XML API
+type s3ObjectStore struct {
+	client s3iface.S3API
+}
+
+var _ StorageBackend = (*s3ObjectStore)(nil)
+
+func newS3ObjectStore(client s3iface.S3API) *s3ObjectStore {
+	return &s3ObjectStore{client: client}
+}
+
+// s3StoreError marks the errors S3 returns for missing objects and uploads
+func s3StoreError(err error) error {
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		switch aerr.Code() {
+		case s3.ErrCodeNoSuchKey, "NotFound":
+			return fmt.Errorf("%w: %w", ErrObjectNotFound, err)
+		case s3.ErrCodeNoSuchUpload:
+			return fmt.Errorf("%w: %w", ErrUploadNotFound, err)
+		}
+	}
+	return err
+}
+
+// optionalString returns nil for an empty string, leaving the field unset
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+// sdkMetadata converts metadata to the SDK's form, leaving it unset when empty
+func sdkMetadata(metadata map[string]string) map[string]*string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	return aws.StringMap(metadata)
+}
+
+func (s *s3ObjectStore) Head(ctx context.Context, bucket, key string) (ObjectInfo, error) {
+	head, err := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return ObjectInfo{}, s3StoreError(err)
+	}
+	return ObjectInfo{
+		Key:                  key,
+		Size:                 aws.Int64Value(head.ContentLength),
+		ETag:                 aws.StringValue(head.ETag),
+		LastModified:         aws.TimeValue(head.LastModified),
+		StorageClass:         aws.StringValue(head.StorageClass),
+		ContentType:          aws.StringValue(head.ContentType),
+		ContentEncoding:      aws.StringValue(head.ContentEncoding),
+		Metadata:             aws.StringValueMap(head.Metadata),
+		ServerSideEncryption: aws.StringValue(head.ServerSideEncryption),
+		SSECustomerAlgorithm: aws.StringValue(head.SSECustomerAlgorithm),
+	}, nil
+}
+
+func (s *s3ObjectStore) Get(ctx context.Context, bucket, key string, opts GetOptions) (io.ReadCloser, ObjectInfo, error) {
+	output, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket:  aws.String(bucket),
+		Key:     aws.String(key),
+		Range:   optionalString(opts.Range),
+		IfMatch: optionalString(opts.IfMatch),
+	})
+	if err != nil {
+		return nil, ObjectInfo{}, s3StoreError(err)
+	}
+	return output.Body, ObjectInfo{
+		Key:                  key,
+		Size:                 aws.Int64Value(output.ContentLength),
+		ETag:                 aws.StringValue(output.ETag),
+		LastModified:         aws.TimeValue(output.LastModified),
+		StorageClass:         aws.StringValue(output.StorageClass),
+		ContentType:          aws.StringValue(output.ContentType),
+		ContentEncoding:      aws.StringValue(output.ContentEncoding),
+		Metadata:             aws.StringValueMap(output.Metadata),
+		ServerSideEncryption: aws.StringValue(output.ServerSideEncryption),
+		SSECustomerAlgorithm: aws.StringValue(output.SSECustomerAlgorithm),
+	}, nil
+}
+
+func (s *s3ObjectStore) Put(ctx context.Context, bucket, key string, body io.ReadSeeker, opts PutOptions) (string, error) {
+	output, err := s.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		Body:            body,
+		ContentType:     optionalString(opts.ContentType),
+		ContentEncoding: optionalString(opts.ContentEncoding),
+		Metadata:        sdkMetadata(opts.Metadata),
+		Tagging:         optionalString(opts.Tagging),
+		StorageClass:    optionalString(opts.StorageClass),
+	})
+	if err != nil {
+		return "", s3StoreError(err)
+	}
+	return aws.StringValue(output.ETag), nil
+}
+
+func (s *s3ObjectStore) Upload(ctx context.Context, bucket, key string, body io.Reader, opts UploadOptions) (string, error) {
+	uploader := s3manager.NewUploaderWithClient(s.client, func(u *s3manager.Uploader) {
+		if opts.PartSize > 0 {
+			u.PartSize = opts.PartSize
+		}
+		if opts.Concurrency > 0 {
+			u.Concurrency = opts.Concurrency
+		}
+	})
+	output, err := uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		Body:            body,
+		ContentType:     optionalString(opts.ContentType),
+		ContentEncoding: optionalString(opts.ContentEncoding),
+		Metadata:        sdkMetadata(opts.Metadata),
+		Tagging:         optionalString(opts.Tagging),
+		StorageClass:    optionalString(opts.StorageClass),
+	})
+	if err != nil {
+		return "", s3StoreError(err)
+	}
+	return aws.StringValue(output.ETag), nil
+}
+
+func (s *s3ObjectStore) Copy(ctx context.Context, bucket, from, to, storageClass string) error {
+	if from == to && storageClass == "" {
+		return nil // S3 rejects a copy onto itself that changes nothing
+	}
+	_, err := s.client.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String(bucket),
+		CopySource:        aws.String(url.PathEscape(bucket) + "/" + (&url.URL{Path: from}).EscapedPath()),
+		Key:               aws.String(to),
+		MetadataDirective: aws.String(s3.MetadataDirectiveCopy),
+		StorageClass:      optionalString(storageClass),
+	})
+	return s3StoreError(err)
+}
+
+func (s *s3ObjectStore) Delete(ctx context.Context, bucket, key string) error {
+	_, err := s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	return s3StoreError(err)
+}
+
+func (s *s3ObjectStore) List(ctx context.Context, bucket, prefix string, fn func(ObjectInfo) bool) error {
+	err := s.client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, object := range page.Contents {
+			if !fn(ObjectInfo{
+				Key:          aws.StringValue(object.Key),
+				Size:         aws.Int64Value(object.Size),
+				ETag:         aws.StringValue(object.ETag),
+				LastModified: aws.TimeValue(object.LastModified),
+				StorageClass: aws.StringValue(object.StorageClass),
+			}) {
+				return false
+			}
+		}
+		return true
+	})
+	return s3StoreError(err)
+}
+
+func (s *s3ObjectStore) CreateMultipartUpload(ctx context.Context, bucket, key string, opts PutOptions) (string, error) {
+	output, err := s.client.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		ContentType:     optionalString(opts.ContentType),
+		ContentEncoding: optionalString(opts.ContentEncoding),
+		Metadata:        sdkMetadata(opts.Metadata),
+		Tagging:         optionalString(opts.Tagging),
+		StorageClass:    optionalString(opts.StorageClass),
+	})
+	if err != nil {
+		return "", s3StoreError(err)
+	}
+	return aws.StringValue(output.UploadId), nil
+}
+
+// readSeekerMD5 returns the MD5 and length of body, leaving it where it was
+func readSeekerMD5(body io.ReadSeeker) ([]byte, int64, error) {
+	// A throttled body is hashed without spending its bandwidth
+	if throttled, ok := body.(*throttledReadSeeker); ok {
+		body = throttled.rs
+	}
+	start, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, 0, err
+	}
+	hasher := md5.New() //nolint:gosec // MD5 matches S3 ETags
+	n, err := io.Copy(hasher, body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := body.Seek(start, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	return hasher.Sum(nil), n, nil
+}
+
+// UploadPart sends the part with its Content-MD5, so S3 rejects a part
+// corrupted on the way
+func (s *s3ObjectStore) UploadPart(ctx context.Context, bucket, key, uploadID string, number int64, body io.ReadSeeker) (string, error) {
+	sum, length, err := readSeekerMD5(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read part body: %w", err)
+	}
+	output, err := s.client.UploadPartWithContext(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(bucket),
+		Key:           aws.String(key),
+		UploadId:      aws.String(uploadID),
+		PartNumber:    aws.Int64(number),
+		Body:          body,
+		ContentLength: aws.Int64(length),
+		ContentMD5:    aws.String(base64.StdEncoding.EncodeToString(sum)),
+	})
+	if err != nil {
+		return "", s3StoreError(err)
+	}
+	return aws.StringValue(output.ETag), nil
+}
+
+func (s *s3ObjectStore) ListParts(ctx context.Context, bucket, key, uploadID string) ([]CompletedPart, error) {
+	var parts []CompletedPart
+	err := s.client.ListPartsPagesWithContext(ctx, &s3.ListPartsInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	}, func(page *s3.ListPartsOutput, _ bool) bool {
+		for _, part := range page.Parts {
+			parts = append(parts, CompletedPart{Number: aws.Int64Value(part.PartNumber), ETag: aws.StringValue(part.ETag)})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, s3StoreError(err)
+	}
+	return parts, nil
+}
+
+func (s *s3ObjectStore) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []CompletedPart) (string, error) {
+	completed := make([]*s3.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, &s3.CompletedPart{PartNumber: aws.Int64(part.Number), ETag: aws.String(part.ETag)})
+	}
+	output, err := s.client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return "", s3StoreError(err)
+	}
+	return aws.StringValue(output.ETag), nil
+}
+
+func (s *s3ObjectStore) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	_, err := s.client.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	return s3StoreError(err)
+}
+
+// localObjectStore keeps objects as files under a directory, with each
+// request's bucket naming the directory, so the archive, restore, compare,
+// and verify commands can use a local disk or NFS mount. ETags are the MD5
+// of the file, computed when an object is inspected or listed.
+type localObjectStore struct{}
+
+var _ StorageBackend = (*localObjectStore)(nil)
+
+// objectPath returns the file holding key in bucket
+func (l *localObjectStore) objectPath(bucket, key string) (string, error) {
+	root := filepath.Clean(bucket)
+	if key == "" || strings.HasSuffix(key, "/") {
+		return "", fmt.Errorf("%w: %q", ErrLocalKeyInvalid, key)
+	}
+	path := filepath.Join(root, filepath.FromSlash(key))
+	if rel, err := filepath.Rel(root, path); err != nil || rel == "." || strings.HasPrefix(rel, "..") ||
+		strings.HasPrefix(filepath.ToSlash(rel), localUploadsDir+"/") {
+		return "", fmt.Errorf("%w: %q", ErrLocalKeyInvalid, key)
+	}
+	return path, nil
+}
+
+// localNotFound reports a missing file as a missing object
+func localNotFound(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %w", ErrObjectNotFound, err)
+	}
+	return err
+}
+
+// fileETag returns the quoted MD5 of the file at path
+func fileETag(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := md5.New() //nolint:gosec // MD5 matches S3 ETags
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil)) + `"`, nil
+}
+
+// writeFileAtomic writes r to path through a temporary file, so readers never
+// see a partial object
+func writeFileAtomic(path string, r io.Reader) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New() //nolint:gosec // MD5 matches S3 ETags
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), r); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil)) + `"`, nil
+}
+
+// localObjectInfo describes the file at path, which holds key
+func localObjectInfo(key, path string, info fs.FileInfo) (ObjectInfo, error) {
+	etag, err := fileETag(path)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{
+		Key:          key,
+		Size:         info.Size(),
+		ETag:         etag,
+		LastModified: info.ModTime(),
+		StorageClass: s3.StorageClassStandard,
+	}, nil
+}
+
+func (l *localObjectStore) Head(_ context.Context, bucket, key string) (ObjectInfo, error) {
+	path, err := l.objectPath(bucket, key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return ObjectInfo{}, localNotFound(err)
+	}
+	return localObjectInfo(key, path, info)
+}
+
+// localRange parses an HTTP byte range ("bytes=start-end" or "bytes=start-")
+// against an object of size bytes
+func localRange(header string, size int64) (int64, int64, error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: %q", ErrLocalRangeInvalid, header)
+	}
+	first, last, ok := strings.Cut(spec, "-")
+	start, err := strconv.ParseInt(first, 10, 64)
+	if !ok || err != nil || start < 0 || start >= size {
+		return 0, 0, fmt.Errorf("%w: %q", ErrLocalRangeInvalid, header)
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, 0, fmt.Errorf("%w: %q", ErrLocalRangeInvalid, header)
+		}
+		end = min(end, size-1)
+	}
+	return start, end, nil
+}
+
+// sectionReadCloser reads a section of a file and closes the file
+type sectionReadCloser struct {
+	*io.SectionReader
+	file *os.File
+}
+
+func (s sectionReadCloser) Close() error { return s.file.Close() }
+
+// Get reads the file. An IfMatch ETag is checked against the file's MD5
+// before it is opened.
+func (l *localObjectStore) Get(ctx context.Context, bucket, key string, opts GetOptions) (io.ReadCloser, ObjectInfo, error) {
+	if opts.IfMatch != "" {
+		head, err := l.Head(ctx, bucket, key)
+		if err != nil {
+			return nil, ObjectInfo{}, err
+		}
+		if head.ETag != opts.IfMatch {
+			return nil, ObjectInfo{}, fmt.Errorf("%s changed: ETag %s, expected %s", key, head.ETag, opts.IfMatch)
+		}
+	}
+	path, err := l.objectPath(bucket, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, ObjectInfo{}, localNotFound(err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, ObjectInfo{}, err
+	}
+
+	object := ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime(), StorageClass: s3.StorageClassStandard}
+	if opts.Range == "" {
+		return file, object, nil
+	}
+	start, end, err := localRange(opts.Range, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, ObjectInfo{}, err
+	}
+	object.Size = end - start + 1
+	return sectionReadCloser{SectionReader: io.NewSectionReader(file, start, object.Size), file: file}, object, nil
+}
+
+func (l *localObjectStore) Put(ctx context.Context, bucket, key string, body io.ReadSeeker, opts PutOptions) (string, error) {
+	var r io.Reader = strings.NewReader("")
+	if body != nil {
+		r = body
+	}
+	return l.Upload(ctx, bucket, key, r, UploadOptions{PutOptions: opts})
+}
+
+// Upload writes a stream of unknown size straight to its file. Local files
+// keep no content headers, metadata, tags, or storage class.
+func (l *localObjectStore) Upload(ctx context.Context, bucket, key string, body io.Reader, _ UploadOptions) (string, error) {
+	path, err := l.objectPath(bucket, key)
+	if err != nil {
+		return "", err
+	}
+	return writeFileAtomic(path, contextReader{ctx: ctx, r: body})
+}
+
+func (l *localObjectStore) Delete(_ context.Context, bucket, key string) error {
+	path, err := l.objectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+	// Like S3, deleting a missing key succeeds
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *localObjectStore) Copy(_ context.Context, bucket, from, to, _ string) error {
+	source, err := l.objectPath(bucket, from)
+	if err != nil {
+		return err
+	}
+	target, err := l.objectPath(bucket, to)
+	if err != nil {
+		return err
+	}
+	if source == target {
+		// A copy onto itself only changes the storage class, which local files don't have
+		return nil
+	}
+	file, err := os.Open(source)
+	if err != nil {
+		return localNotFound(err)
+	}
+	defer file.Close()
+	_, err = writeFileAtomic(target, file)
+	return err
+}
+
+func (l *localObjectStore) List(_ context.Context, bucket, prefix string, fn func(ObjectInfo) bool) error {
+	root := filepath.Clean(bucket)
+	var keys []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == root {
+				return fs.SkipAll
+			}
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		key := filepath.ToSlash(rel)
+		if entry.IsDir() {
+			// Skip upload staging and directories that cannot hold the prefix
+			if key == localUploadsDir || (key != "." && !strings.HasPrefix(key+"/", prefix) && !strings.HasPrefix(prefix, key+"/")) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil // In-progress write
+		}
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", root, err)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path := filepath.Join(root, filepath.FromSlash(key))
+		info, err := os.Stat(path)
+		if err != nil {
+			continue // Removed since the walk
+		}
+		object, err := localObjectInfo(key, path, info)
+		if err != nil {
+			return err
+		}
+		if !fn(object) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// uploadDir returns where the parts of a multipart upload are staged
+func (l *localObjectStore) uploadDir(bucket, uploadID string) (string, error) {
+	if uploadID == "" || strings.ContainsAny(uploadID, `/\.`) {
+		return "", fmt.Errorf("%w: %q", ErrUploadNotFound, uploadID)
+	}
+	return filepath.Join(filepath.Clean(bucket), localUploadsDir, uploadID), nil
+}
+
+// openUploadDir returns the staging directory of an upload that exists
+func (l *localObjectStore) openUploadDir(bucket, uploadID string) (string, error) {
+	dir, err := l.uploadDir(bucket, uploadID)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("%w: %w", ErrUploadNotFound, err)
+		}
+		return "", err
+	}
+	return dir, nil
+}
+
+func (l *localObjectStore) CreateMultipartUpload(_ context.Context, bucket, key string, _ PutOptions) (string, error) {
+	if _, err := l.objectPath(bucket, key); err != nil {
+		return "", err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	uploadID := hex.EncodeToString(id)
+	dir, _ := l.uploadDir(bucket, uploadID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return uploadID, nil
+}
+
+func (l *localObjectStore) UploadPart(_ context.Context, bucket, _, uploadID string, number int64, body io.ReadSeeker) (string, error) {
+	dir, err := l.openUploadDir(bucket, uploadID)
+	if err != nil {
+		return "", err
+	}
+	return writeFileAtomic(filepath.Join(dir, fmt.Sprintf("%05d", number)), body)
+}
+
+func (l *localObjectStore) ListParts(_ context.Context, bucket, _, uploadID string) ([]CompletedPart, error) {
+	dir, err := l.openUploadDir(bucket, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var parts []CompletedPart
+	for _, entry := range entries {
+		number, err := strconv.ParseInt(entry.Name(), 10, 64)
+		if err != nil {
+			continue // In-progress write
+		}
+		etag, err := fileETag(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, CompletedPart{Number: number, ETag: etag})
+	}
+	return parts, nil
+}
+
+func (l *localObjectStore) CompleteMultipartUpload(_ context.Context, bucket, key, uploadID string, parts []CompletedPart) (string, error) {
+	dir, err := l.openUploadDir(bucket, uploadID)
+	if err != nil {
+		return "", err
+	}
+	path, err := l.objectPath(bucket, key)
+	if err != nil {
+		return "", err
+	}
+
+	var files []*os.File
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	readers := make([]io.Reader, 0, len(parts))
+	for _, part := range parts {
+		file, err := os.Open(filepath.Join(dir, fmt.Sprintf("%05d", part.Number)))
+		if err != nil {
+			return "", fmt.Errorf("%w: part %d was not uploaded", ErrUploadNotFound, part.Number)
+		}
+		files = append(files, file)
+		readers = append(readers, file)
+	}
+	etag, err := writeFileAtomic(path, io.MultiReader(readers...))
+	if err != nil {
+		return "", err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	return etag, nil
+}
+
+func (l *localObjectStore) AbortMultipartUpload(_ context.Context, bucket, _, uploadID string) error {
+	dir, err := l.openUploadDir(bucket, uploadID)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// contextReader stops reading once ctx is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
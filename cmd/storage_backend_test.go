@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestNewStorageClient(t *testing.T) {
+	client, err := newStorageClient(S3Config{Backend: StorageBackendLocal, Bucket: t.TempDir()})
+	if err != nil {
+		t.Fatalf("newStorageClient(local) error = %v", err)
+	}
+	if _, ok := client.(*localObjectStore); !ok {
+		t.Errorf("local backend client = %T, want *localObjectStore", client)
+	}
+
+	client, err = newStorageClient(S3Config{Backend: StorageBackendGCS, Bucket: "bucket", Region: regionAuto, AccessKey: "key", SecretKey: "secret"})
+	if err != nil {
+		t.Fatalf("newStorageClient(gcs) error = %v", err)
+	}
+	store, ok := client.(*s3ObjectStore)
+	if !ok {
+		t.Fatalf("gcs backend client = %T, want *s3ObjectStore", client)
+	}
+	if endpoint := store.client.(*s3.S3).Endpoint; endpoint != gcsEndpoint {
+		t.Errorf("gcs endpoint = %q, want %q", endpoint, gcsEndpoint)
+	}
+
+	t.Setenv("AZURE_STORAGE_ACCOUNT", "")
+	t.Setenv("AZURE_STORAGE_KEY", "")
+	if _, err := newStorageClient(S3Config{Backend: StorageBackendAzure}); !errors.Is(err, ErrAzureCredentialsRequired) {
+		t.Errorf("azure error = %v, want ErrAzureCredentialsRequired", err)
+	}
+	if _, err := newStorageClient(S3Config{Backend: StorageBackendAzure, AccessKey: "account", SecretKey: "not base64!"}); !errors.Is(err, ErrAzureAccountKeyInvalid) {
+		t.Errorf("azure key error = %v, want ErrAzureAccountKeyInvalid", err)
+	}
+	t.Setenv("AZURE_STORAGE_ACCOUNT", "account")
+	t.Setenv("AZURE_STORAGE_KEY", "c2VjcmV0")
+	client, err = newStorageClient(S3Config{Backend: StorageBackendAzure})
+	if err != nil {
+		t.Fatalf("newStorageClient(azure) error = %v", err)
+	}
+	if endpoint := client.(*azureObjectStore).endpoint.String(); endpoint != "https://account.blob.core.windows.net" {
+		t.Errorf("azure endpoint = %q", endpoint)
+	}
+	if uri, _ := storageBaseURI(S3Config{Backend: StorageBackendAzure, Bucket: "archives"}); uri != "abfss://archives@account.dfs.core.windows.net" {
+		t.Errorf("azure base URI = %q", uri)
+	}
+	if err := (S3Config{Backend: "ftp"}).validateCredentials(); !errors.Is(err, ErrStorageBackendInvalid) {
+		t.Errorf("ftp error = %v, want ErrStorageBackendInvalid", err)
+	}
+}
+
+func TestLocalObjectStore(t *testing.T) {
+	ctx := context.Background()
+	bucket := t.TempDir()
+	store := &localObjectStore{}
+	put := func(key, body string) {
+		t.Helper()
+		if _, err := store.Put(ctx, bucket, key, strings.NewReader(body), PutOptions{}); err != nil {
+			t.Fatalf("Put(%s) error = %v", key, err)
+		}
+	}
+	put("events/2024/01/events-2024-01-01.jsonl", "0123456789")
+	put("events/2024/01/events-2024-01-02.jsonl", "second")
+	put("logs/2024/logs-2024.jsonl", "other table")
+
+	head, err := store.Head(ctx, bucket, "events/2024/01/events-2024-01-01.jsonl")
+	if err != nil {
+		t.Fatalf("Head() error = %v", err)
+	}
+	if head.Size != 10 || head.ETag != quotedMD5([]byte("0123456789")) {
+		t.Errorf("head = %d bytes, ETag %s", head.Size, head.ETag)
+	}
+	if _, err := store.Head(ctx, bucket, "missing"); !isObjectNotFound(err) {
+		t.Errorf("missing object error = %v, want ErrObjectNotFound", err)
+	}
+
+	get, _, err := store.Get(ctx, bucket, "events/2024/01/events-2024-01-01.jsonl", GetOptions{Range: "bytes=2-5"})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	body, _ := io.ReadAll(get)
+	get.Close()
+	if string(body) != "2345" {
+		t.Errorf("ranged body = %q, want 2345", body)
+	}
+	if _, _, err := store.Get(ctx, bucket, "events/2024/01/events-2024-01-01.jsonl", GetOptions{IfMatch: `"stale"`}); err == nil {
+		t.Error("Get() with a stale If-Match should fail")
+	}
+
+	var keys []string
+	if err := store.List(ctx, bucket, "events/", func(object ObjectInfo) bool {
+		keys = append(keys, object.Key)
+		return true
+	}); err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if strings.Join(keys, ",") != "events/2024/01/events-2024-01-01.jsonl,events/2024/01/events-2024-01-02.jsonl" {
+		t.Errorf("listed keys = %v", keys)
+	}
+
+	if err := store.Copy(ctx, bucket, "logs/2024/logs-2024.jsonl", "trash/logs-2024.jsonl", ""); err != nil {
+		t.Fatalf("Copy() error = %v", err)
+	}
+	if err := store.Delete(ctx, bucket, "logs/2024/logs-2024.jsonl"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(bucket, "trash", "logs-2024.jsonl")); err != nil || string(data) != "other table" {
+		t.Errorf("copied object = %q, %v", data, err)
+	}
+
+	for _, key := range []string{"../escape", "events/../../escape", "", "dir/", localUploadsDir + "/x"} {
+		if _, err := store.Put(ctx, bucket, key, strings.NewReader("x"), PutOptions{}); !errors.Is(err, ErrLocalKeyInvalid) {
+			t.Errorf("Put(%q) error = %v, want ErrLocalKeyInvalid", key, err)
+		}
+	}
+
+	etag, err := store.Upload(ctx, bucket, "stream/data.jsonl", strings.NewReader("streamed"), UploadOptions{})
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if etag != quotedMD5([]byte("streamed")) {
+		t.Errorf("upload ETag = %s", etag)
+	}
+}
+
+func TestLocalObjectStoreResumableTransfers(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx := context.Background()
+	bucket := t.TempDir()
+	store := &localObjectStore{}
+	key := "events/2024/01/events-2024-01-01.jsonl.zst"
+	path := filepath.Join(t.TempDir(), "upload.tmp")
+	content := strings.Repeat("0123456789", 1000)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Multipart uploads are staged and joined into one file
+	uploader := newResumableUploader(store, bucket, nil, newTestLogger())
+	uploader.partSize = func(int64) int64 { return 4096 }
+	if err := uploader.Upload(ctx, path, key); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(bucket, localUploadsDir)); len(entries) != 0 {
+		t.Errorf("staged uploads left behind: %d", len(entries))
+	}
+
+	// Ranged downloads are verified against the MD5 ETag
+	downloader := newRangeDownloader(store, bucket, t.TempDir(), 1, 0, newTestLogger())
+	downloaded, size, err := downloader.Download(ctx, key)
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	data, err := os.ReadFile(downloaded)
+	if err != nil || size != int64(len(content)) || string(data) != content {
+		t.Errorf("downloaded %d bytes, match = %v, err = %v", size, string(data) == content, err)
+	}
+
+	if _, err := store.UploadPart(ctx, bucket, key, "unknown", 1, strings.NewReader("x")); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("unknown upload error = %v, want ErrUploadNotFound", err)
+	}
+}
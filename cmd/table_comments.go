@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lib/pq"
+	"github.com/spf13/viper"
+)
+
+// Static errors for table comments
+var (
+	ErrIncludeCommentsConflict = errors.New("--include-comments uploads to S3, so it cannot be used with --output -")
+	ErrCommentsTableNotFound   = errors.New("table not found while reading its comments")
+)
+
+// commentsSuffix ends the key of a table's schema JSON, which holds its comments
+const commentsSuffix = "-schema.json"
+
+var includeComments bool
+
+func init() {
+	archiveCmd.Flags().BoolVar(&includeComments, "include-comments", false, "upload the table's COMMENT ON descriptions (table and columns) as <table>-schema.json with each run; restore applies them")
+	_ = viper.BindPFlag("include_comments", archiveCmd.Flags().Lookup("include-comments"))
+}
+
+// tableComments is the schema JSON of a table: its columns with their types
+// and COMMENT ON descriptions. It is archived next to the schema dump so the
+// meaning of the data travels with it.
+type tableComments struct {
+	Table   string          `json:"table"`
+	Comment string          `json:"comment,omitempty"`
+	Columns []columnComment `json:"columns"`
+}
+
+type columnComment struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// commented returns how many of the table and its columns have a comment
+func (t tableComments) commented() int {
+	count := 0
+	if t.Comment != "" {
+		count++
+	}
+	for _, col := range t.Columns {
+		if col.Comment != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// commentsObjectKey returns where a table's schema JSON is kept under template
+func commentsObjectKey(template, table string) string {
+	return schemaArtifactKey(template, table, commentsSuffix)
+}
+
+// readTableComments reads the comments of the base table and its columns
+func (a *Archiver) readTableComments(ctx context.Context) (tableComments, error) {
+	query := `
+		SELECT obj_description(a.attrelid, 'pg_class'), a.attname,
+			format_type(a.atttypid, a.atttypmod), col_description(a.attrelid, a.attnum)
+		FROM pg_attribute a
+		WHERE a.attrelid = to_regclass($1) AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum
+	`
+	rows, err := a.db.QueryContext(ctx, query, qualifiedTableName(a.config.Table))
+	if err != nil {
+		return tableComments{}, fmt.Errorf("failed to query comments: %w", err)
+	}
+	defer rows.Close()
+
+	comments := tableComments{Table: a.config.Table}
+	for rows.Next() {
+		var tableComment, columnDescription sql.NullString
+		var col columnComment
+		if err := rows.Scan(&tableComment, &col.Name, &col.Type, &columnDescription); err != nil {
+			return tableComments{}, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		comments.Comment = tableComment.String
+		col.Comment = columnDescription.String
+		comments.Columns = append(comments.Columns, col)
+	}
+	if err := rows.Err(); err != nil {
+		return tableComments{}, fmt.Errorf("error iterating comments: %w", err)
+	}
+	if len(comments.Columns) == 0 {
+		return tableComments{}, fmt.Errorf("%w: %s", ErrCommentsTableNotFound, a.config.Table)
+	}
+	return comments, nil
+}
+
+// exportComments uploads the table's schema JSON. The JSON is the same for
+// unchanged comments, so an object already holding it is left alone.
+func (a *Archiver) exportComments(ctx context.Context) (string, error) {
+	comments, err := a.readTableComments(ctx)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(comments, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode comments: %w", err)
+	}
+	status, err := a.uploadSchemaArtifact(commentsObjectKey(a.config.schemaTemplate(), a.config.Table), "comments", append(data, '\n'))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s (%d commented)", status, comments.commented()), nil
+}
+
+// loadTableComments downloads the schema JSON archived for the table. It
+// returns false when the table was archived without --include-comments.
+func (r *Restorer) loadTableComments(ctx context.Context, key string) (tableComments, bool, error) {
+	body, _, err := r.s3Client.Get(ctx, r.config.S3.Bucket, key, GetOptions{})
+	if err != nil {
+		if isObjectNotFound(err) {
+			return tableComments{}, false, nil
+		}
+		return tableComments{}, false, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return tableComments{}, false, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	var comments tableComments
+	if err := json.Unmarshal(data, &comments); err != nil {
+		return tableComments{}, false, fmt.Errorf("failed to parse %s: %w", key, err)
+	}
+	return comments, true, nil
+}
+
+// applyComments re-applies the archived table and column comments to the
+// restored table. Columns the table does not have are skipped. Problems are
+// logged as warnings; comments never fail a restore.
+func (r *Restorer) applyComments(ctx context.Context, schemaPath string) {
+	if r.noComments {
+		return
+	}
+	key := commentsObjectKey(schemaPath, r.config.Table)
+	comments, ok, err := r.loadTableComments(ctx, key)
+	if err != nil {
+		r.logger.Warn(fmt.Sprintf("⚠️  Comments not restored: %v", err))
+		return
+	}
+	if !ok {
+		r.logger.Debug(fmt.Sprintf("No archived comments at %s", key))
+		return
+	}
+
+	statements := r.commentStatements(ctx, comments)
+	if len(statements) == 0 {
+		return
+	}
+	if r.config.DryRun {
+		r.logger.Info(fmt.Sprintf("[DRY RUN] Would restore %d comment(s) on %s from %s", len(statements), r.config.Table, key))
+		return
+	}
+	for _, statement := range statements {
+		if _, err := r.db.ExecContext(ctx, statement); err != nil {
+			r.logger.Warn(fmt.Sprintf("⚠️  Failed to restore comment: %v", err))
+			return
+		}
+	}
+	r.logger.Info(fmt.Sprintf("💬 Restored %d comment(s) on %s", len(statements), r.config.Table))
+}
+
+// commentStatements returns the COMMENT ON statements for the archived
+// comments, leaving out columns the restored table does not have
+func (r *Restorer) commentStatements(ctx context.Context, comments tableComments) []string {
+	table := pq.QuoteIdentifier(r.config.Table)
+	var statements []string
+	if comments.Comment != "" {
+		statements = append(statements, fmt.Sprintf("COMMENT ON TABLE %s IS %s", table, pq.QuoteLiteral(comments.Comment)))
+	}
+
+	target, err := r.loadTargetColumns(ctx, r.config.Table)
+	if err != nil {
+		r.logger.Warn(fmt.Sprintf("⚠️  Column comments not restored: %v", err))
+		return statements
+	}
+	existing := make(map[string]bool, len(target))
+	for _, col := range target {
+		existing[col.Name] = true
+	}
+	for _, col := range comments.Columns {
+		if col.Comment == "" {
+			continue
+		}
+		if !existing[col.Name] {
+			r.logger.Debug(fmt.Sprintf("Skipping comment on %s: column not in %s", col.Name, r.config.Table))
+			continue
+		}
+		statements = append(statements, fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s", table, pq.QuoteIdentifier(col.Name), pq.QuoteLiteral(col.Comment)))
+	}
+	return statements
+}
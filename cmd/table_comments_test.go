@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func commentRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"table_comment", "attname", "type", "column_comment"}).
+		AddRow("Flight events from ADS-B", "id", "bigint", nil).
+		AddRow("Flight events from ADS-B", "callsign", "text", "ICAO callsign, e.g. O'Hare's UAL1").
+		AddRow("Flight events from ADS-B", "created_at", "timestamp with time zone", "Time the message was received")
+}
+
+func TestExportCommentsUploadsChangedCommentsOnly(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := &fakeObjectStore{objects: map[string][]byte{}}
+	archiver := NewArchiver(&Config{
+		Table:           "flights",
+		IncludeComments: true,
+		S3:              S3Config{Bucket: "bucket", PathTemplate: "archives/{table}/{YYYY}/{MM}"},
+	}, newTestLogger())
+	archiver.db = db
+	archiver.s3Client = newS3ObjectStore(store)
+	ctx := context.Background()
+
+	mock.ExpectQuery(`FROM pg_attribute`).WithArgs(`"public"."flights"`).WillReturnRows(commentRows())
+	status, err := archiver.exportSchema(ctx)
+	if err != nil || status != "📐 Uploaded comments to archives/flights/flights-schema.json (3 commented)" {
+		t.Fatalf("exportSchema() = %q, %v", status, err)
+	}
+	uploaded := string(store.objects["archives/flights/flights-schema.json"])
+	for _, want := range []string{`"comment": "Flight events from ADS-B"`, `"name": "created_at"`, `"type": "timestamp with time zone"`} {
+		if !strings.Contains(uploaded, want) {
+			t.Errorf("schema JSON missing %s:\n%s", want, uploaded)
+		}
+	}
+
+	// The same comments are not uploaded again
+	mock.ExpectQuery(`FROM pg_attribute`).WithArgs(`"public"."flights"`).WillReturnRows(commentRows())
+	if status, err := archiver.exportSchema(ctx); err != nil || status != "📐 Comments unchanged at archives/flights/flights-schema.json (3 commented)" {
+		t.Errorf("exportSchema() again = %q, %v", status, err)
+	}
+
+	mock.ExpectQuery(`FROM pg_attribute`).WithArgs(`"public"."flights"`).
+		WillReturnRows(sqlmock.NewRows([]string{"table_comment", "attname", "type", "column_comment"}))
+	if _, err := archiver.exportSchema(ctx); !errors.Is(err, ErrCommentsTableNotFound) {
+		t.Errorf("expected ErrCommentsTableNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestApplyComments(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	config := newTestConfig()
+	config.Table = "flights"
+	store := &fakeObjectStore{objects: map[string][]byte{
+		"flights/flights-schema.json": []byte(`{"table": "flights", "comment": "Flight events", "columns": [
+			{"name": "id", "type": "bigint"},
+			{"name": "callsign", "type": "text", "comment": "O'Hare's callsign"},
+			{"name": "dropped", "type": "text", "comment": "No longer in the table"}]}`),
+	}}
+	r := NewRestorer(config, newTestLogger())
+	r.db = db
+	r.s3Client = newS3ObjectStore(store)
+
+	mock.ExpectQuery(`FROM information_schema.columns`).
+		WithArgs("flights").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "udt_name", "nullable", "has_default", "generated"}).
+			AddRow("id", "int8", false, false, false).
+			AddRow("callsign", "text", true, false, false))
+	mock.ExpectExec(`COMMENT ON TABLE "flights" IS 'Flight events'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`COMMENT ON COLUMN "flights"."callsign" IS 'O''Hare''s callsign'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	r.applyComments(context.Background(), config.S3.PathTemplate)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	// Tables archived without comments, and --no-comments, leave the table alone
+	r.applyComments(context.Background(), "other/{table}")
+	r.noComments = true
+	r.applyComments(context.Background(), config.S3.PathTemplate)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidateIncludeComments(t *testing.T) {
+	config := newTestConfig()
+	config.IncludeComments = true
+	if err := config.validateSchemaExport(); err != nil {
+		t.Errorf("validateSchemaExport() = %v", err)
+	}
+	config.Output = StdoutOutput
+	if err := config.validateSchemaExport(); !errors.Is(err, ErrIncludeCommentsConflict) {
+		t.Errorf("expected ErrIncludeCommentsConflict, got %v", err)
+	}
+	if got := commentsObjectKey("archives/{table}/{YYYY}/{MM}", "flights"); got != "archives/flights/flights-schema.json" {
+		t.Errorf("commentsObjectKey() = %q", got)
+	}
+}
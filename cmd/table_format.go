@@ -0,0 +1,461 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/spf13/viper"
+)
+
+// Table formats --table-format can write metadata for
+const (
+	TableFormatIceberg = "iceberg"
+	TableFormatDelta   = "delta"
+)
+
+// tablePartPattern matches the end of the key of a split archive's part
+var tablePartPattern = regexp.MustCompile(`-part-\d{4,}\.parquet$`)
+
+// Static errors for table format metadata
+var (
+	ErrTableFormatInvalid    = errors.New("table format must be one of: iceberg, delta")
+	ErrTableFormatParquet    = errors.New("table format metadata requires the parquet output format")
+	ErrTableFormatStdout     = errors.New("table format metadata cannot be written when archiving to stdout")
+	ErrTableColumnType       = errors.New("column type has no table format equivalent")
+	ErrTableColumnConflict   = errors.New("column type differs from the table schema")
+	ErrTableChangedElsewhere = errors.New("table was changed by another writer")
+)
+
+func init() {
+	archiveCmd.Flags().String("table-format", "", "after each run, write table metadata covering the table's Parquet archives so Spark, Trino, and Athena can query them as one table: iceberg or delta (empty = none)")
+	archiveCmd.Flags().String("table-catalog-path", "", "bucket prefix of the table root holding metadata/ (iceberg) or _delta_log/ (delta); supports {table} (default: the path template up to its first date placeholder)")
+	_ = viper.BindPFlag("table_format.format", archiveCmd.Flags().Lookup("table-format"))
+	_ = viper.BindPFlag("table_format.catalog_path", archiveCmd.Flags().Lookup("table-catalog-path"))
+}
+
+// validateTableFormat checks --table-format
+func (c *Config) validateTableFormat() error {
+	switch c.TableFormat {
+	case "":
+		return nil
+	case TableFormatIceberg, TableFormatDelta:
+	default:
+		return fmt.Errorf("%w, got '%s'", ErrTableFormatInvalid, c.TableFormat)
+	}
+	if c.OutputFormat != "parquet" {
+		return fmt.Errorf("%w, got '%s'", ErrTableFormatParquet, c.OutputFormat)
+	}
+	if c.Output == StdoutOutput {
+		return ErrTableFormatStdout
+	}
+	return nil
+}
+
+// tableColumn is a column of a table's schema, typed with Iceberg's primitive
+// type names
+type tableColumn struct {
+	Name string
+	Type string
+}
+
+// tableDataFile is a Parquet archive in the bucket. Rows and Columns are read
+// from its footer only when the file is new to the table.
+type tableDataFile struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	Rows         int64
+	Columns      []tableColumn
+}
+
+// tableTarget is where a table's data and metadata live
+type tableTarget struct {
+	Client     StorageBackend
+	Bucket     string
+	BaseURI    string // Storage URI of the bucket, e.g. s3://bucket
+	Root       string // Table root under the bucket, without a trailing slash
+	DataPrefix string // Prefix listed for the table's archives
+	Table      string
+}
+
+// uri returns the storage URI of key
+func (t tableTarget) uri(key string) string {
+	return t.BaseURI + "/" + key
+}
+
+// location returns the storage URI of the table root
+func (t tableTarget) location() string {
+	if t.Root == "" {
+		return t.BaseURI
+	}
+	return t.uri(t.Root)
+}
+
+// metadataKey returns the key of name under the table root
+func (t tableTarget) metadataKey(name string) string {
+	if t.Root == "" {
+		return name
+	}
+	return t.Root + "/" + name
+}
+
+// tableCommitResult describes the table version a commit wrote
+type tableCommitResult struct {
+	Version int64 // Iceberg metadata version or Delta log version
+	Added   int
+	Removed int
+	Files   int
+	Rows    int64
+}
+
+// newTableTarget resolves the table root and the prefix holding its archives
+func newTableTarget(client StorageBackend, cfg *Config) (tableTarget, error) {
+	baseURI, err := storageBaseURI(cfg.S3)
+	if err != nil {
+		return tableTarget{}, err
+	}
+	dataPrefix := tableDataPrefix(cfg.S3.PathTemplate, cfg.Table)
+	root := strings.ReplaceAll(cfg.TableCatalogPath, "{table}", objectKeyComponent(cfg.Table))
+	if root == "" {
+		root = defaultTableRoot(dataPrefix, cfg.Table)
+	}
+	return tableTarget{
+		Client:     client,
+		Bucket:     cfg.S3.Bucket,
+		BaseURI:    baseURI,
+		Root:       strings.Trim(root, "/"),
+		DataPrefix: dataPrefix,
+		Table:      cfg.Table,
+	}, nil
+}
+
+// storageBaseURI returns the URI query engines use for the bucket
+func storageBaseURI(cfg S3Config) (string, error) {
+	switch cfg.Backend {
+	case StorageBackendGCS:
+		return "gs://" + cfg.Bucket, nil
+	case StorageBackendAzure:
+		account, _ := azureCredentials(cfg)
+		return "abfss://" + cfg.Bucket + "@" + account + ".dfs.core.windows.net", nil
+	case StorageBackendLocal:
+		dir, err := filepath.Abs(cfg.Bucket)
+		if err != nil {
+			return "", err
+		}
+		return "file://" + filepath.ToSlash(dir), nil
+	default:
+		return "s3://" + cfg.Bucket, nil
+	}
+}
+
+// tableDataPrefix returns the part of the path template before its first
+// date placeholder, cut back to a directory: every archive of the table is
+// under it
+func tableDataPrefix(template, table string) string {
+	prefix := strings.ReplaceAll(template, "{table}", objectKeyComponent(table))
+	i := strings.Index(prefix, "{")
+	if i < 0 {
+		return strings.Trim(prefix, "/") + "/"
+	}
+	prefix = prefix[:i]
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		return prefix[:i+1]
+	}
+	return ""
+}
+
+// defaultTableRoot is the data prefix when it names the table, and a
+// directory named after the table under it otherwise, so tables sharing a
+// prefix never share metadata
+func defaultTableRoot(dataPrefix, table string) string {
+	component := objectKeyComponent(table)
+	for _, segment := range strings.Split(strings.Trim(dataPrefix, "/"), "/") {
+		if segment == component {
+			return strings.Trim(dataPrefix, "/")
+		}
+	}
+	return dataPrefix + component
+}
+
+// listTableDataFiles lists the table's Parquet archives, including the parts
+// of split archives that their manifest lists. Files under hidden directories
+// (names starting with _ or ., such as the trash) are not part of the table.
+func listTableDataFiles(ctx context.Context, t tableTarget) ([]tableDataFile, error) {
+	component := objectKeyComponent(t.Table)
+	var files []tableDataFile
+	manifests := make(map[string]bool)
+	err := t.Client.List(ctx, t.Bucket, t.DataPrefix, func(object ObjectInfo) bool {
+		switch key := object.Key; {
+		case isTableDataKey(key, component, ".parquet"):
+			files = append(files, tableDataFile{
+				Key:          key,
+				Size:         object.Size,
+				ETag:         strings.Trim(object.ETag, `"`),
+				LastModified: object.LastModified,
+			})
+		case isTableDataKey(key, component, manifestSuffix):
+			manifests[key] = true
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archives under %s: %w", t.DataPrefix, err)
+	}
+	if files, err = dropUnlistedParts(ctx, t, files, manifests); err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Key < files[j].Key })
+	return files, nil
+}
+
+// dropUnlistedParts removes parts that their archive's manifest no longer
+// lists: a split archive rewritten with fewer parts leaves the extra parts of
+// the earlier run in the bucket
+func dropUnlistedParts(ctx context.Context, t tableTarget, files []tableDataFile, manifests map[string]bool) ([]tableDataFile, error) {
+	listed := make(map[string]map[string]bool)
+	kept := files[:0]
+	for _, file := range files {
+		match := tablePartPattern.FindStringIndex(file.Key)
+		if match == nil {
+			kept = append(kept, file)
+			continue
+		}
+		manifestKey := file.Key[:match[0]] + manifestSuffix
+		if !manifests[manifestKey] {
+			kept = append(kept, file) // Manifest not uploaded yet, or removed
+			continue
+		}
+		parts, ok := listed[manifestKey]
+		if !ok {
+			manifest, err := getObjectJSON[splitManifest](ctx, t, manifestKey)
+			if err != nil {
+				return nil, err
+			}
+			parts = make(map[string]bool, len(manifest.Parts))
+			for _, part := range manifest.Parts {
+				parts[part.Key] = true
+			}
+			listed[manifestKey] = parts
+		}
+		if parts[file.Key] {
+			kept = append(kept, file)
+		}
+	}
+	return kept, nil
+}
+
+// isTableDataKey reports whether key is a file of the table whose file names
+// start with component and end with ext
+func isTableDataKey(key, component, ext string) bool {
+	dir, name := path.Split(key)
+	if !strings.HasPrefix(name, component+"-") || !strings.HasSuffix(name, ext) {
+		return false
+	}
+	for _, segment := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if segment != component && (strings.HasPrefix(segment, "_") || strings.HasPrefix(segment, ".")) {
+			return false
+		}
+	}
+	return true
+}
+
+// readTableDataFile reads the row count and columns of file from its Parquet
+// footer, fetching only the byte ranges the footer needs
+func readTableDataFile(ctx context.Context, t tableTarget, file *tableDataFile) error {
+	reader := &objectRangeReader{ctx: ctx, client: t.Client, bucket: t.Bucket, key: file.Key}
+	parquetFile, err := parquet.OpenFile(reader, file.Size, parquet.SkipPageIndex(true), parquet.SkipBloomFilters(true))
+	if err != nil {
+		return fmt.Errorf("failed to read Parquet footer of %s: %w", file.Key, err)
+	}
+	columns, err := parquetTableColumns(parquetFile.Schema())
+	if err != nil {
+		return fmt.Errorf("%s: %w", file.Key, err)
+	}
+	file.Rows = parquetFile.NumRows()
+	file.Columns = columns
+	return nil
+}
+
+// objectRangeReader reads byte ranges of an object with ranged GETs
+type objectRangeReader struct {
+	ctx    context.Context
+	client StorageBackend
+	bucket string
+	key    string
+}
+
+func (r *objectRangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	body, _, err := r.client.Get(r.ctx, r.bucket, r.key, GetOptions{Range: fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1)})
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	n, err := io.ReadFull(body, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// parquetTableColumns maps the columns of a Parquet schema to table columns.
+// Archives are flat, so nested columns are not supported.
+func parquetTableColumns(schema *parquet.Schema) ([]tableColumn, error) {
+	fields := schema.Fields()
+	columns := make([]tableColumn, 0, len(fields))
+	for _, field := range fields {
+		if !field.Leaf() {
+			return nil, fmt.Errorf("%w: %s is nested", ErrTableColumnType, field.Name())
+		}
+		columnType, err := parquetColumnType(field.Type())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field.Name(), err)
+		}
+		columns = append(columns, tableColumn{Name: field.Name(), Type: columnType})
+	}
+	return columns, nil
+}
+
+// parquetColumnType returns the Iceberg type of a Parquet leaf column
+func parquetColumnType(t parquet.Type) (string, error) {
+	if logical := t.LogicalType(); logical != nil {
+		switch {
+		case logical.UTF8 != nil, logical.Json != nil, logical.Enum != nil:
+			return "string", nil
+		case logical.Date != nil:
+			return "date", nil
+		case logical.Timestamp != nil:
+			if logical.Timestamp.Unit.Micros == nil {
+				return "", fmt.Errorf("%w: timestamps must be in microseconds", ErrTableColumnType)
+			}
+			if logical.Timestamp.IsAdjustedToUTC {
+				return "timestamptz", nil
+			}
+			return "timestamp", nil
+		case logical.Integer != nil:
+			if logical.Integer.BitWidth == 64 {
+				return "long", nil
+			}
+			return "int", nil
+		}
+	}
+	switch t.Kind() {
+	case parquet.Boolean:
+		return "boolean", nil
+	case parquet.Int32:
+		return "int", nil
+	case parquet.Int64:
+		return "long", nil
+	case parquet.Float:
+		return "float", nil
+	case parquet.Double:
+		return "double", nil
+	case parquet.ByteArray:
+		return "binary", nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrTableColumnType, t)
+}
+
+// mergeTableColumns adds the columns of added missing from columns, keeping
+// the order of columns. A column whose type changed is an error: query
+// engines can't read both types as one column.
+func mergeTableColumns(columns, added []tableColumn) ([]tableColumn, error) {
+	types := make(map[string]string, len(columns))
+	for _, column := range columns {
+		types[column.Name] = column.Type
+	}
+	merged := columns
+	for _, column := range added {
+		existing, ok := types[column.Name]
+		if !ok {
+			types[column.Name] = column.Type
+			merged = append(merged, column)
+			continue
+		}
+		if existing != column.Type {
+			return nil, fmt.Errorf("%w: %s is %s in the table and %s in the archive", ErrTableColumnConflict, column.Name, existing, column.Type)
+		}
+	}
+	return merged, nil
+}
+
+// newTableUUID returns a random version 4 UUID
+func newTableUUID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	s := hex.EncodeToString(id)
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:], nil
+}
+
+// newSnapshotID returns a random positive snapshot ID
+func newSnapshotID() (int64, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(id) >> 1), nil
+}
+
+// updateTableFormat brings the --table-format metadata in line with the
+// table's archives in the bucket: files uploaded by this or earlier runs are
+// added and files deleted since (by prune or retention) are removed. A
+// failure is logged; the next run catches up.
+func (a *Archiver) updateTableFormat() {
+	if a.config.TableFormat == "" || a.s3Client == nil || a.config.DryRun {
+		return
+	}
+	ctx := a.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if ctx.Err() != nil {
+		a.logger.Warn("⚠️  Table metadata not updated because the run was cancelled; the next run will add its files")
+		return
+	}
+
+	target, err := newTableTarget(a.s3Client, a.config)
+	if err != nil {
+		a.logger.Warn(fmt.Sprintf("⚠️  Table metadata not updated: %v", err))
+		return
+	}
+	var result tableCommitResult
+	var name string
+	switch a.config.TableFormat {
+	case TableFormatIceberg:
+		name = "Iceberg"
+		result, err = commitIcebergTable(ctx, target, time.Now())
+	case TableFormatDelta:
+		name = "Delta"
+		result, err = commitDeltaTable(ctx, target, time.Now())
+	}
+	if err != nil {
+		a.logger.Warn(fmt.Sprintf("⚠️  %s table metadata not updated at %s: %v", name, target.location(), err))
+		return
+	}
+	if result.Added == 0 && result.Removed == 0 {
+		a.logger.Debug(fmt.Sprintf("%s table at %s is up to date", name, target.location()))
+		return
+	}
+	a.logger.Info(fmt.Sprintf("📚 %s table at %s updated to version %d: %d file(s) added, %d removed (%s files, %s rows)",
+		name, target.location(), result.Version, result.Added, result.Removed,
+		formatNumberForSummary(int64(result.Files)), formatNumberForSummary(result.Rows)))
+}
@@ -0,0 +1,373 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// deltaCommitPattern matches the JSON commits of a Delta log
+var deltaCommitPattern = regexp.MustCompile(`/(\d{20})\.json$`)
+
+// Delta column types by Iceberg type name. Timestamps without a time zone
+// need a Delta table feature and are not supported.
+var deltaColumnTypes = map[string]string{
+	"boolean":     "boolean",
+	"int":         "integer",
+	"long":        "long",
+	"float":       "float",
+	"double":      "double",
+	"date":        "date",
+	"timestamptz": "timestamp",
+	"string":      "string",
+	"binary":      "binary",
+}
+
+// deltaAction is one line of a Delta commit
+type deltaAction struct {
+	CommitInfo *deltaCommitInfo `json:"commitInfo,omitempty"`
+	Protocol   *deltaProtocol   `json:"protocol,omitempty"`
+	MetaData   *deltaMetaData   `json:"metaData,omitempty"`
+	Add        *deltaAdd        `json:"add,omitempty"`
+	Remove     *deltaRemove     `json:"remove,omitempty"`
+}
+
+type deltaCommitInfo struct {
+	Timestamp           int64             `json:"timestamp"`
+	Operation           string            `json:"operation"`
+	OperationParameters map[string]string `json:"operationParameters"`
+	EngineInfo          string            `json:"engineInfo"`
+	RunID               string            `json:"runId,omitempty"` // data-archiver run that made the commit
+}
+
+type deltaProtocol struct {
+	MinReaderVersion int `json:"minReaderVersion"`
+	MinWriterVersion int `json:"minWriterVersion"`
+}
+
+type deltaMetaData struct {
+	ID               string            `json:"id"`
+	Format           deltaFormat       `json:"format"`
+	SchemaString     string            `json:"schemaString"`
+	PartitionColumns []string          `json:"partitionColumns"`
+	Configuration    map[string]string `json:"configuration"`
+	CreatedTime      int64             `json:"createdTime"`
+}
+
+type deltaFormat struct {
+	Provider string            `json:"provider"`
+	Options  map[string]string `json:"options"`
+}
+
+type deltaAdd struct {
+	Path             string            `json:"path"`
+	PartitionValues  map[string]string `json:"partitionValues"`
+	Size             int64             `json:"size"`
+	ModificationTime int64             `json:"modificationTime"`
+	DataChange       bool              `json:"dataChange"`
+	Stats            string            `json:"stats,omitempty"`
+}
+
+type deltaRemove struct {
+	Path              string `json:"path"`
+	DeletionTimestamp int64  `json:"deletionTimestamp"`
+	DataChange        bool   `json:"dataChange"`
+	Size              int64  `json:"size,omitempty"`
+}
+
+// deltaSchema is a Delta table schema
+type deltaSchema struct {
+	Type   string             `json:"type"`
+	Fields []deltaSchemaField `json:"fields"`
+}
+
+type deltaSchemaField struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Nullable bool              `json:"nullable"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// deltaTable is the state of a Delta table replayed from its log
+type deltaTable struct {
+	Version  int64 // -1 before the first commit
+	MetaData *deltaMetaData
+	Files    map[string]deltaAdd // By path
+}
+
+// commitDeltaTable appends a commit to the Delta log under t.Root/_delta_log
+// when the table's archives differ from the files in the log. The table is
+// unpartitioned; files under the table root are added by relative path and
+// others by absolute URI. Only data-archiver should write the log: S3 has no
+// atomic put-if-absent, so concurrent writers could overwrite a commit.
+func commitDeltaTable(ctx context.Context, t tableTarget, now time.Time) (tableCommitResult, error) {
+	table, err := loadDeltaTable(ctx, t)
+	if err != nil {
+		return tableCommitResult{}, err
+	}
+	files, err := listTableDataFiles(ctx, t)
+	if err != nil {
+		return tableCommitResult{}, err
+	}
+
+	// Files with an unchanged size and modification time are already in the
+	// log; the rest are new or were re-uploaded
+	remaining := make(map[string]deltaAdd, len(table.Files))
+	for path, add := range table.Files {
+		remaining[path] = add
+	}
+	var added []tableDataFile
+	result := tableCommitResult{Version: table.Version, Files: len(files)}
+	for _, file := range files {
+		path := deltaPath(t, file.Key)
+		if add, ok := remaining[path]; ok {
+			delete(remaining, path)
+			if add.Size == file.Size && add.ModificationTime == file.LastModified.UnixMilli() {
+				result.Rows += deltaRows(add)
+				continue
+			}
+		}
+		added = append(added, file)
+	}
+	result.Added, result.Removed = len(added), len(remaining)
+	if len(added) == 0 && len(remaining) == 0 {
+		return result, nil
+	}
+	if table.MetaData == nil && len(added) == 0 {
+		return result, nil
+	}
+
+	var columns []tableColumn
+	if table.MetaData != nil {
+		if columns, err = deltaTableColumns(table.MetaData.SchemaString); err != nil {
+			return tableCommitResult{}, err
+		}
+	}
+	known := len(columns)
+	for i := range added {
+		if err := readTableDataFile(ctx, t, &added[i]); err != nil {
+			return tableCommitResult{}, err
+		}
+		if columns, err = mergeTableColumns(columns, added[i].Columns); err != nil {
+			return tableCommitResult{}, fmt.Errorf("%s: %w", added[i].Key, err)
+		}
+		result.Rows += added[i].Rows
+	}
+
+	actions := []deltaAction{{CommitInfo: &deltaCommitInfo{
+		Timestamp:           now.UnixMilli(),
+		Operation:           "WRITE",
+		OperationParameters: map[string]string{"mode": "Append"},
+		EngineInfo:          "data-archiver/" + Version,
+		RunID:               currentRunID,
+	}}}
+	if table.MetaData == nil {
+		tableID, err := newTableUUID()
+		if err != nil {
+			return tableCommitResult{}, err
+		}
+		table.MetaData = &deltaMetaData{
+			ID:               tableID,
+			Format:           deltaFormat{Provider: "parquet", Options: map[string]string{}},
+			PartitionColumns: []string{},
+			Configuration:    map[string]string{},
+			CreatedTime:      now.UnixMilli(),
+		}
+		actions = append(actions, deltaAction{Protocol: &deltaProtocol{MinReaderVersion: 1, MinWriterVersion: 2}})
+	}
+	if len(columns) > known {
+		schema, err := deltaSchemaString(columns)
+		if err != nil {
+			return tableCommitResult{}, err
+		}
+		metaData := *table.MetaData
+		metaData.SchemaString = schema
+		actions = append(actions, deltaAction{MetaData: &metaData})
+	}
+
+	removed := make([]string, 0, len(remaining))
+	for path := range remaining {
+		removed = append(removed, path)
+	}
+	sort.Strings(removed)
+	for _, path := range removed {
+		actions = append(actions, deltaAction{Remove: &deltaRemove{
+			Path:              path,
+			DeletionTimestamp: now.UnixMilli(),
+			DataChange:        true,
+			Size:              remaining[path].Size,
+		}})
+	}
+	for _, file := range added {
+		actions = append(actions, deltaAction{Add: &deltaAdd{
+			Path:             deltaPath(t, file.Key),
+			PartitionValues:  map[string]string{},
+			Size:             file.Size,
+			ModificationTime: file.LastModified.UnixMilli(),
+			DataChange:       true,
+			Stats:            fmt.Sprintf(`{"numRecords":%d}`, file.Rows),
+		}})
+	}
+
+	var commit bytes.Buffer
+	encoder := json.NewEncoder(&commit)
+	for _, action := range actions {
+		if err := encoder.Encode(action); err != nil {
+			return tableCommitResult{}, err
+		}
+	}
+	result.Version = table.Version + 1
+	key := t.metadataKey(fmt.Sprintf("_delta_log/%020d.json", result.Version))
+	if err := putTableObject(ctx, t, key, commit.Bytes(), "application/json"); err != nil {
+		return tableCommitResult{}, err
+	}
+	return result, nil
+}
+
+// loadDeltaTable replays the table's Delta log. Logs with checkpoints were
+// written by another engine and are not read.
+func loadDeltaTable(ctx context.Context, t tableTarget) (*deltaTable, error) {
+	var commits []string
+	checkpoint := ""
+	err := t.Client.List(ctx, t.Bucket, t.metadataKey("_delta_log/"), func(object ObjectInfo) bool {
+		switch key := object.Key; {
+		case deltaCommitPattern.MatchString(key):
+			commits = append(commits, key)
+		case strings.Contains(key, ".checkpoint") || strings.HasSuffix(key, "_last_checkpoint"):
+			checkpoint = key
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the Delta log: %w", err)
+	}
+	if checkpoint != "" {
+		return nil, fmt.Errorf("%w: %s", ErrTableChangedElsewhere, checkpoint)
+	}
+	sort.Strings(commits)
+
+	table := &deltaTable{Version: -1, Files: make(map[string]deltaAdd)}
+	for i, key := range commits {
+		version, _ := strconv.ParseInt(deltaCommitPattern.FindStringSubmatch(key)[1], 10, 64)
+		if version != int64(i) {
+			return nil, fmt.Errorf("%w: the Delta log is missing version %d", ErrTableChangedElsewhere, i)
+		}
+		data, err := getTableObject(ctx, t, key)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			var action deltaAction
+			if err := json.Unmarshal(line, &action); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", key, err)
+			}
+			switch {
+			case action.MetaData != nil:
+				table.MetaData = action.MetaData
+			case action.Add != nil:
+				table.Files[action.Add.Path] = *action.Add
+			case action.Remove != nil:
+				delete(table.Files, action.Remove.Path)
+			}
+		}
+		table.Version = version
+	}
+	return table, nil
+}
+
+// getTableObject reads the object at key
+func getTableObject(ctx context.Context, t tableTarget, key string) ([]byte, error) {
+	body, _, err := t.Client.Get(ctx, t.Bucket, key, GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	defer body.Close()
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(body); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// deltaPath returns the path of key in the Delta log: relative to the table
+// root when under it, an absolute URI otherwise, URI-encoded either way
+func deltaPath(t tableTarget, key string) string {
+	root := t.Root + "/"
+	if t.Root == "" {
+		root = ""
+	}
+	if rel, ok := strings.CutPrefix(key, root); ok {
+		return escapeDeltaPath(rel)
+	}
+	return t.BaseURI + "/" + escapeDeltaPath(key)
+}
+
+// escapeDeltaPath URI-encodes each segment of a key
+func escapeDeltaPath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// deltaRows returns the row count recorded in an add action's stats
+func deltaRows(add deltaAdd) int64 {
+	var stats struct {
+		NumRecords int64 `json:"numRecords"`
+	}
+	_ = json.Unmarshal([]byte(add.Stats), &stats)
+	return stats.NumRecords
+}
+
+// deltaSchemaString encodes columns as a Delta schema
+func deltaSchemaString(columns []tableColumn) (string, error) {
+	schema := deltaSchema{Type: "struct", Fields: make([]deltaSchemaField, len(columns))}
+	for i, column := range columns {
+		columnType, ok := deltaColumnTypes[column.Type]
+		if !ok {
+			return "", fmt.Errorf("%w: %s is %s", ErrTableColumnType, column.Name, column.Type)
+		}
+		schema.Fields[i] = deltaSchemaField{Name: column.Name, Type: columnType, Nullable: true, Metadata: map[string]string{}}
+	}
+	data, err := json.Marshal(schema)
+	return string(data), err
+}
+
+// deltaTableColumns decodes a Delta schema into columns
+func deltaTableColumns(schemaString string) ([]tableColumn, error) {
+	var schema struct {
+		Fields []struct {
+			Name string          `json:"name"`
+			Type json.RawMessage `json:"type"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(schemaString), &schema); err != nil {
+		return nil, fmt.Errorf("failed to parse the Delta schema: %w", err)
+	}
+	icebergTypes := make(map[string]string, len(deltaColumnTypes))
+	for icebergType, deltaType := range deltaColumnTypes {
+		icebergTypes[deltaType] = icebergType
+	}
+	columns := make([]tableColumn, len(schema.Fields))
+	for i, field := range schema.Fields {
+		var deltaType string
+		_ = json.Unmarshal(field.Type, &deltaType)
+		columnType, ok := icebergTypes[deltaType]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is %s", ErrTableColumnType, field.Name, field.Type)
+		}
+		columns[i] = tableColumn{Name: field.Name, Type: columnType}
+	}
+	return columns, nil
+}
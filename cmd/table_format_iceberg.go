@@ -0,0 +1,687 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// icebergFilesSummaryKey is the snapshot summary property naming the file
+// list data-archiver keeps next to each snapshot it writes. A current
+// snapshot without it was written by another engine.
+const icebergFilesSummaryKey = "data-archiver.files"
+
+// icebergRunIDSummaryKey is the snapshot summary property naming the run
+// that committed the snapshot
+const icebergRunIDSummaryKey = "data-archiver.run-id"
+
+// Manifest entry statuses
+const (
+	icebergStatusExisting = 0
+	icebergStatusAdded    = 1
+)
+
+// icebergMetadataPattern matches the metadata files of a Hadoop-style table
+var icebergMetadataPattern = regexp.MustCompile(`/v(\d+)\.metadata\.json$`)
+
+// icebergMetadata is an Iceberg v2 table metadata file
+type icebergMetadata struct {
+	FormatVersion      int                    `json:"format-version"`
+	TableUUID          string                 `json:"table-uuid"`
+	Location           string                 `json:"location"`
+	LastSequenceNumber int64                  `json:"last-sequence-number"`
+	LastUpdatedMs      int64                  `json:"last-updated-ms"`
+	LastColumnID       int                    `json:"last-column-id"`
+	CurrentSchemaID    int                    `json:"current-schema-id"`
+	Schemas            []icebergSchema        `json:"schemas"`
+	DefaultSpecID      int                    `json:"default-spec-id"`
+	PartitionSpecs     []icebergPartitionSpec `json:"partition-specs"`
+	LastPartitionID    int                    `json:"last-partition-id"`
+	DefaultSortOrderID int                    `json:"default-sort-order-id"`
+	SortOrders         []icebergSortOrder     `json:"sort-orders"`
+	Properties         map[string]string      `json:"properties"`
+	CurrentSnapshotID  int64                  `json:"current-snapshot-id"`
+	Refs               map[string]icebergRef  `json:"refs"`
+	Snapshots          []icebergSnapshot      `json:"snapshots"`
+	SnapshotLog        []icebergSnapshotLog   `json:"snapshot-log"`
+	MetadataLog        []icebergMetadataLog   `json:"metadata-log"`
+}
+
+type icebergSchema struct {
+	Type     string         `json:"type"`
+	SchemaID int            `json:"schema-id"`
+	Fields   []icebergField `json:"fields"`
+}
+
+type icebergField struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+	Type     string `json:"type"`
+}
+
+type icebergPartitionSpec struct {
+	SpecID int               `json:"spec-id"`
+	Fields []json.RawMessage `json:"fields"`
+}
+
+type icebergSortOrder struct {
+	OrderID int               `json:"order-id"`
+	Fields  []json.RawMessage `json:"fields"`
+}
+
+type icebergRef struct {
+	SnapshotID int64  `json:"snapshot-id"`
+	Type       string `json:"type"`
+}
+
+type icebergSnapshot struct {
+	SnapshotID       int64             `json:"snapshot-id"`
+	ParentSnapshotID *int64            `json:"parent-snapshot-id,omitempty"`
+	SequenceNumber   int64             `json:"sequence-number"`
+	TimestampMs      int64             `json:"timestamp-ms"`
+	ManifestList     string            `json:"manifest-list"`
+	Summary          map[string]string `json:"summary"`
+	SchemaID         int               `json:"schema-id"`
+}
+
+type icebergSnapshotLog struct {
+	TimestampMs int64 `json:"timestamp-ms"`
+	SnapshotID  int64 `json:"snapshot-id"`
+}
+
+type icebergMetadataLog struct {
+	TimestampMs  int64  `json:"timestamp-ms"`
+	MetadataFile string `json:"metadata-file"`
+}
+
+// currentSnapshot returns the table's current snapshot, or nil
+func (m *icebergMetadata) currentSnapshot() *icebergSnapshot {
+	for i := range m.Snapshots {
+		if m.Snapshots[i].SnapshotID == m.CurrentSnapshotID {
+			return &m.Snapshots[i]
+		}
+	}
+	return nil
+}
+
+// currentSchema returns the table's current schema
+func (m *icebergMetadata) currentSchema() icebergSchema {
+	for _, schema := range m.Schemas {
+		if schema.SchemaID == m.CurrentSchemaID {
+			return schema
+		}
+	}
+	return icebergSchema{Type: "struct", SchemaID: m.CurrentSchemaID}
+}
+
+// icebergFileList is the list of data files in a snapshot that
+// data-archiver wrote. Iceberg keeps this in Avro manifests; keeping a JSON
+// copy lets the next commit carry existing files forward without reading them.
+type icebergFileList struct {
+	SnapshotID int64              `json:"snapshot_id"`
+	Files      []icebergFileEntry `json:"files"`
+}
+
+// icebergFileEntry is a data file of a snapshot, with the snapshot and
+// sequence number that added it
+type icebergFileEntry struct {
+	Key            string `json:"key"`
+	Size           int64  `json:"size"`
+	ETag           string `json:"etag"`
+	Rows           int64  `json:"rows"`
+	SnapshotID     int64  `json:"snapshot_id"`
+	SequenceNumber int64  `json:"sequence_number"`
+}
+
+// commitIcebergTable writes a new snapshot of an Iceberg v2 table under
+// t.Root/metadata when the table's archives differ from its current snapshot.
+// The table is unpartitioned; its files have no Iceberg field IDs, so the
+// schema.name-mapping.default property maps columns to fields by name. Each
+// snapshot lists every file in one manifest. The metadata is laid out like a
+// Hadoop catalog table (vN.metadata.json and version-hint.text), which
+// engines can read directly or register with a catalog.
+func commitIcebergTable(ctx context.Context, t tableTarget, now time.Time) (tableCommitResult, error) {
+	current, version, err := loadIcebergMetadata(ctx, t)
+	if err != nil {
+		return tableCommitResult{}, err
+	}
+	var previous []icebergFileEntry
+	if current != nil {
+		if snapshot := current.currentSnapshot(); snapshot != nil {
+			listKey, ok := snapshot.Summary[icebergFilesSummaryKey]
+			if !ok {
+				return tableCommitResult{}, fmt.Errorf("%w: snapshot %d", ErrTableChangedElsewhere, snapshot.SnapshotID)
+			}
+			list, err := getObjectJSON[icebergFileList](ctx, t, listKey)
+			if err != nil {
+				return tableCommitResult{}, err
+			}
+			if list.SnapshotID != snapshot.SnapshotID {
+				return tableCommitResult{}, fmt.Errorf("%w: %s lists snapshot %d, not %d", ErrTableChangedElsewhere, listKey, list.SnapshotID, snapshot.SnapshotID)
+			}
+			previous = list.Files
+		}
+	}
+
+	files, err := listTableDataFiles(ctx, t)
+	if err != nil {
+		return tableCommitResult{}, err
+	}
+
+	// Files whose size and ETag are unchanged are carried forward; the rest
+	// are new or were re-uploaded
+	previousByKey := make(map[string]icebergFileEntry, len(previous))
+	for _, entry := range previous {
+		previousByKey[entry.Key] = entry
+	}
+	var kept []icebergFileEntry
+	var added []tableDataFile
+	for _, file := range files {
+		if entry, ok := previousByKey[file.Key]; ok && entry.Size == file.Size && entry.ETag == file.ETag {
+			kept = append(kept, entry)
+			delete(previousByKey, file.Key)
+			continue
+		}
+		added = append(added, file)
+	}
+	result := tableCommitResult{Version: version, Added: len(added), Removed: len(previousByKey), Files: len(files)}
+	for _, entry := range kept {
+		result.Rows += entry.Rows
+	}
+	if len(added) == 0 && len(previousByKey) == 0 {
+		return result, nil
+	}
+
+	if current == nil {
+		if len(added) == 0 {
+			return result, nil
+		}
+		if current, err = newIcebergMetadata(t); err != nil {
+			return tableCommitResult{}, err
+		}
+	}
+	schema := current.currentSchema()
+	columns := make([]tableColumn, len(schema.Fields))
+	for i, field := range schema.Fields {
+		columns[i] = tableColumn{Name: field.Name, Type: field.Type}
+	}
+	for i := range added {
+		if err := readTableDataFile(ctx, t, &added[i]); err != nil {
+			return tableCommitResult{}, err
+		}
+		if columns, err = mergeTableColumns(columns, added[i].Columns); err != nil {
+			return tableCommitResult{}, fmt.Errorf("%s: %w", added[i].Key, err)
+		}
+		result.Rows += added[i].Rows
+	}
+	if len(columns) > len(schema.Fields) {
+		schema = current.addSchema(columns)
+	}
+
+	snapshotID, err := newSnapshotID()
+	if err != nil {
+		return tableCommitResult{}, err
+	}
+	sequence := current.LastSequenceNumber + 1
+	entries := kept
+	for _, file := range added {
+		entries = append(entries, icebergFileEntry{
+			Key:            file.Key,
+			Size:           file.Size,
+			ETag:           file.ETag,
+			Rows:           file.Rows,
+			SnapshotID:     snapshotID,
+			SequenceNumber: sequence,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+
+	// Data files first, then the manifest list, the file list, and last the
+	// metadata that makes the snapshot current
+	prefix := t.metadataKey("metadata/")
+	commitUUID, err := newTableUUID()
+	if err != nil {
+		return tableCommitResult{}, err
+	}
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return tableCommitResult{}, err
+	}
+	var manifests [][]byte
+	if len(entries) > 0 {
+		manifestKey := prefix + commitUUID + "-m0.avro"
+		manifest, err := icebergManifest(t, string(schemaJSON), schema.SchemaID, entries, snapshotID)
+		if err != nil {
+			return tableCommitResult{}, err
+		}
+		if err := putTableObject(ctx, t, manifestKey, manifest, "application/avro"); err != nil {
+			return tableCommitResult{}, err
+		}
+		manifests = append(manifests, icebergManifestFile(t.uri(manifestKey), int64(len(manifest)), entries, snapshotID, sequence))
+	}
+	var parent *int64
+	if snapshot := current.currentSnapshot(); snapshot != nil {
+		parent = aws.Int64(snapshot.SnapshotID)
+	}
+	manifestListKey := fmt.Sprintf("%ssnap-%d-1-%s.avro", prefix, snapshotID, commitUUID)
+	manifestList, err := icebergManifestList(manifests, snapshotID, parent, sequence)
+	if err != nil {
+		return tableCommitResult{}, err
+	}
+	if err := putTableObject(ctx, t, manifestListKey, manifestList, "application/avro"); err != nil {
+		return tableCommitResult{}, err
+	}
+	fileListKey := fmt.Sprintf("%sdata-archiver-files-%d.json", prefix, snapshotID)
+	fileList, err := json.MarshalIndent(icebergFileList{SnapshotID: snapshotID, Files: entries}, "", "  ")
+	if err != nil {
+		return tableCommitResult{}, err
+	}
+	if err := putTableObject(ctx, t, fileListKey, fileList, "application/json"); err != nil {
+		return tableCommitResult{}, err
+	}
+
+	summary := icebergSummary(added, previousByKey, entries)
+	summary[icebergFilesSummaryKey] = fileListKey
+	summary[icebergRunIDSummaryKey] = currentRunID
+	if version > 0 {
+		current.MetadataLog = append(current.MetadataLog, icebergMetadataLog{
+			TimestampMs:  current.LastUpdatedMs,
+			MetadataFile: t.uri(fmt.Sprintf("%sv%d.metadata.json", prefix, version)),
+		})
+	}
+	current.Snapshots = append(current.Snapshots, icebergSnapshot{
+		SnapshotID:       snapshotID,
+		ParentSnapshotID: parent,
+		SequenceNumber:   sequence,
+		TimestampMs:      now.UnixMilli(),
+		ManifestList:     t.uri(manifestListKey),
+		Summary:          summary,
+		SchemaID:         schema.SchemaID,
+	})
+	current.SnapshotLog = append(current.SnapshotLog, icebergSnapshotLog{TimestampMs: now.UnixMilli(), SnapshotID: snapshotID})
+	current.CurrentSnapshotID = snapshotID
+	current.Refs = map[string]icebergRef{"main": {SnapshotID: snapshotID, Type: "branch"}}
+	current.LastSequenceNumber = sequence
+	current.LastUpdatedMs = now.UnixMilli()
+
+	version++
+	metadata, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return tableCommitResult{}, err
+	}
+	if err := putTableObject(ctx, t, fmt.Sprintf("%sv%d.metadata.json", prefix, version), metadata, "application/json"); err != nil {
+		return tableCommitResult{}, err
+	}
+	if err := putTableObject(ctx, t, prefix+"version-hint.text", []byte(strconv.FormatInt(version, 10)), "text/plain"); err != nil {
+		return tableCommitResult{}, err
+	}
+	result.Version = version
+	return result, nil
+}
+
+// newIcebergMetadata returns the metadata of an empty, unpartitioned table
+func newIcebergMetadata(t tableTarget) (*icebergMetadata, error) {
+	tableUUID, err := newTableUUID()
+	if err != nil {
+		return nil, err
+	}
+	return &icebergMetadata{
+		FormatVersion:     2,
+		TableUUID:         tableUUID,
+		Location:          t.location(),
+		Schemas:           []icebergSchema{{Type: "struct", SchemaID: 0, Fields: []icebergField{}}},
+		PartitionSpecs:    []icebergPartitionSpec{{SpecID: 0, Fields: []json.RawMessage{}}},
+		LastPartitionID:   999,
+		SortOrders:        []icebergSortOrder{{OrderID: 0, Fields: []json.RawMessage{}}},
+		Properties:        map[string]string{"write.format.default": "parquet"},
+		CurrentSnapshotID: -1,
+		Snapshots:         []icebergSnapshot{},
+		SnapshotLog:       []icebergSnapshotLog{},
+		MetadataLog:       []icebergMetadataLog{},
+	}, nil
+}
+
+// addSchema makes a schema with columns current, keeping the field IDs of
+// columns already in the table, and maps the columns by name for files
+// without field IDs
+func (m *icebergMetadata) addSchema(columns []tableColumn) icebergSchema {
+	ids := make(map[string]int)
+	for _, schema := range m.Schemas {
+		for _, field := range schema.Fields {
+			ids[field.Name] = field.ID
+		}
+	}
+	schema := icebergSchema{Type: "struct", Fields: make([]icebergField, len(columns))}
+	for _, existing := range m.Schemas {
+		if existing.SchemaID >= schema.SchemaID {
+			schema.SchemaID = existing.SchemaID + 1
+		}
+	}
+	if len(m.currentSchema().Fields) == 0 && m.CurrentSnapshotID == -1 {
+		// An empty new table's placeholder schema is replaced
+		schema.SchemaID = m.CurrentSchemaID
+		m.Schemas = nil
+	}
+	for i, column := range columns {
+		id, ok := ids[column.Name]
+		if !ok {
+			m.LastColumnID++
+			id = m.LastColumnID
+			ids[column.Name] = id
+		}
+		schema.Fields[i] = icebergField{ID: id, Name: column.Name, Type: column.Type}
+	}
+	m.Schemas = append(m.Schemas, schema)
+	m.CurrentSchemaID = schema.SchemaID
+
+	type mappedField struct {
+		FieldID int      `json:"field-id"`
+		Names   []string `json:"names"`
+	}
+	mapping := make([]mappedField, len(schema.Fields))
+	for i, field := range schema.Fields {
+		mapping[i] = mappedField{FieldID: field.ID, Names: []string{field.Name}}
+	}
+	data, _ := json.Marshal(mapping)
+	m.Properties["schema.name-mapping.default"] = string(data)
+	return schema
+}
+
+// icebergSummary returns the snapshot summary of a commit adding added and
+// removing removed, leaving entries in the table
+func icebergSummary(added []tableDataFile, removed map[string]icebergFileEntry, entries []icebergFileEntry) map[string]string {
+	operation := "append"
+	switch {
+	case len(removed) > 0 && len(added) == 0:
+		operation = "delete"
+	case len(removed) > 0:
+		operation = "overwrite"
+	}
+	var addedRows, addedSize, removedRows, removedSize, totalRows, totalSize int64
+	for _, file := range added {
+		addedRows += file.Rows
+		addedSize += file.Size
+	}
+	for _, entry := range removed {
+		removedRows += entry.Rows
+		removedSize += entry.Size
+	}
+	for _, entry := range entries {
+		totalRows += entry.Rows
+		totalSize += entry.Size
+	}
+	format := func(n int64) string { return strconv.FormatInt(n, 10) }
+	return map[string]string{
+		"operation":          operation,
+		"added-data-files":   format(int64(len(added))),
+		"deleted-data-files": format(int64(len(removed))),
+		"added-records":      format(addedRows),
+		"deleted-records":    format(removedRows),
+		"added-files-size":   format(addedSize),
+		"removed-files-size": format(removedSize),
+		"total-data-files":   format(int64(len(entries))),
+		"total-records":      format(totalRows),
+		"total-files-size":   format(totalSize),
+		"total-delete-files": "0",
+	}
+}
+
+// loadIcebergMetadata reads the table's latest metadata file. A table that
+// does not exist yet returns nil and version 0.
+func loadIcebergMetadata(ctx context.Context, t tableTarget) (*icebergMetadata, int64, error) {
+	var latestKey string
+	var latest int64
+	foreign := ""
+	err := t.Client.List(ctx, t.Bucket, t.metadataKey("metadata/"), func(object ObjectInfo) bool {
+		key := object.Key
+		if !strings.HasSuffix(key, ".metadata.json") {
+			return true
+		}
+		match := icebergMetadataPattern.FindStringSubmatch(key)
+		if match == nil {
+			foreign = key
+			return true
+		}
+		if version, err := strconv.ParseInt(match[1], 10, 64); err == nil && version > latest {
+			latest, latestKey = version, key
+		}
+		return true
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list Iceberg metadata: %w", err)
+	}
+	if foreign != "" {
+		return nil, 0, fmt.Errorf("%w: %s", ErrTableChangedElsewhere, foreign)
+	}
+	if latestKey == "" {
+		return nil, 0, nil
+	}
+	metadata, err := getObjectJSON[icebergMetadata](ctx, t, latestKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	if metadata.FormatVersion != 2 {
+		return nil, 0, fmt.Errorf("%w: %s has format version %d", ErrTableChangedElsewhere, latestKey, metadata.FormatVersion)
+	}
+	if metadata.Properties == nil {
+		metadata.Properties = make(map[string]string)
+	}
+	return metadata, latest, nil
+}
+
+// getObjectJSON reads and decodes the JSON object at key
+func getObjectJSON[T any](ctx context.Context, t tableTarget, key string) (*T, error) {
+	data, err := getTableObject(ctx, t, key)
+	if err != nil {
+		return nil, err
+	}
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", key, err)
+	}
+	return &value, nil
+}
+
+// putTableObject writes a table metadata object
+func putTableObject(ctx context.Context, t tableTarget, key string, data []byte, contentType string) error {
+	if _, err := t.Client.Put(ctx, t.Bucket, key, bytes.NewReader(data), PutOptions{ContentType: contentType}); err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	return nil
+}
+
+// Avro schemas of Iceberg v2 manifests and manifest lists, limited to the
+// required fields. Field IDs are Iceberg's, which readers resolve fields by.
+const (
+	icebergManifestSchema = `{"type":"record","name":"manifest_entry","fields":[` +
+		`{"name":"status","type":"int","field-id":0},` +
+		`{"name":"snapshot_id","type":["null","long"],"default":null,"field-id":1},` +
+		`{"name":"sequence_number","type":["null","long"],"default":null,"field-id":3},` +
+		`{"name":"file_sequence_number","type":["null","long"],"default":null,"field-id":4},` +
+		`{"name":"data_file","type":{"type":"record","name":"r2","fields":[` +
+		`{"name":"content","type":"int","field-id":134},` +
+		`{"name":"file_path","type":"string","field-id":100},` +
+		`{"name":"file_format","type":"string","field-id":101},` +
+		`{"name":"partition","type":{"type":"record","name":"r102","fields":[]},"field-id":102},` +
+		`{"name":"record_count","type":"long","field-id":103},` +
+		`{"name":"file_size_in_bytes","type":"long","field-id":104}` +
+		`]},"field-id":2}]}`
+	icebergManifestListSchema = `{"type":"record","name":"manifest_file","fields":[` +
+		`{"name":"manifest_path","type":"string","field-id":500},` +
+		`{"name":"manifest_length","type":"long","field-id":501},` +
+		`{"name":"partition_spec_id","type":"int","field-id":502},` +
+		`{"name":"content","type":"int","field-id":517},` +
+		`{"name":"sequence_number","type":"long","field-id":515},` +
+		`{"name":"min_sequence_number","type":"long","field-id":516},` +
+		`{"name":"added_snapshot_id","type":"long","field-id":503},` +
+		`{"name":"added_files_count","type":"int","field-id":504},` +
+		`{"name":"existing_files_count","type":"int","field-id":505},` +
+		`{"name":"deleted_files_count","type":"int","field-id":506},` +
+		`{"name":"added_rows_count","type":"long","field-id":512},` +
+		`{"name":"existing_rows_count","type":"long","field-id":513},` +
+		`{"name":"deleted_rows_count","type":"long","field-id":514}]}`
+)
+
+// icebergManifest encodes entries as a manifest. Files added by snapshotID
+// leave their sequence numbers null to inherit the manifest's.
+func icebergManifest(t tableTarget, schemaJSON string, schemaID int, entries []icebergFileEntry, snapshotID int64) ([]byte, error) {
+	records := make([][]byte, len(entries))
+	for i, entry := range entries {
+		var e avroEncoder
+		if entry.SnapshotID == snapshotID {
+			e.long(icebergStatusAdded)
+			e.optionalLong(&entry.SnapshotID)
+			e.optionalLong(nil)
+			e.optionalLong(nil)
+		} else {
+			e.long(icebergStatusExisting)
+			e.optionalLong(&entry.SnapshotID)
+			e.optionalLong(&entry.SequenceNumber)
+			e.optionalLong(&entry.SequenceNumber)
+		}
+		e.long(0) // Data content
+		e.string(t.uri(entry.Key))
+		e.string("PARQUET")
+		e.long(entry.Rows)
+		e.long(entry.Size)
+		records[i] = e.buf.Bytes()
+	}
+	return writeAvroFile(icebergManifestSchema, map[string]string{
+		"schema":            schemaJSON,
+		"schema-id":         strconv.Itoa(schemaID),
+		"partition-spec":    "[]",
+		"partition-spec-id": "0",
+		"format-version":    "2",
+		"content":           "data",
+	}, records)
+}
+
+// icebergManifestFile encodes the manifest list entry of a manifest holding
+// entries
+func icebergManifestFile(uri string, length int64, entries []icebergFileEntry, snapshotID, sequence int64) []byte {
+	var added, existing int64
+	var addedRows, existingRows int64
+	minSequence := sequence
+	for _, entry := range entries {
+		if entry.SnapshotID == snapshotID {
+			added++
+			addedRows += entry.Rows
+		} else {
+			existing++
+			existingRows += entry.Rows
+			minSequence = min(minSequence, entry.SequenceNumber)
+		}
+	}
+	var e avroEncoder
+	e.string(uri)
+	e.long(length)
+	e.long(0) // Partition spec
+	e.long(0) // Data manifest
+	e.long(sequence)
+	e.long(minSequence)
+	e.long(snapshotID)
+	e.long(added)
+	e.long(existing)
+	e.long(0)
+	e.long(addedRows)
+	e.long(existingRows)
+	e.long(0)
+	return e.buf.Bytes()
+}
+
+// icebergManifestList encodes a snapshot's manifest list
+func icebergManifestList(manifests [][]byte, snapshotID int64, parent *int64, sequence int64) ([]byte, error) {
+	parentID := "null"
+	if parent != nil {
+		parentID = strconv.FormatInt(*parent, 10)
+	}
+	return writeAvroFile(icebergManifestListSchema, map[string]string{
+		"snapshot-id":        strconv.FormatInt(snapshotID, 10),
+		"parent-snapshot-id": parentID,
+		"sequence-number":    strconv.FormatInt(sequence, 10),
+		"format-version":     "2",
+	}, manifests)
+}
+
+// avroEncoder writes Avro's binary encoding
+type avroEncoder struct {
+	buf bytes.Buffer
+}
+
+// long writes an int or long as a zig-zag varint
+func (e *avroEncoder) long(v int64) {
+	u := uint64(v<<1) ^ uint64(v>>63)
+	for u >= 0x80 {
+		e.buf.WriteByte(byte(u) | 0x80)
+		u >>= 7
+	}
+	e.buf.WriteByte(byte(u))
+}
+
+func (e *avroEncoder) bytes(b []byte) {
+	e.long(int64(len(b)))
+	e.buf.Write(b)
+}
+
+func (e *avroEncoder) string(s string) {
+	e.bytes([]byte(s))
+}
+
+// optionalLong writes a ["null","long"] union
+func (e *avroEncoder) optionalLong(v *int64) {
+	if v == nil {
+		e.long(0)
+		return
+	}
+	e.long(1)
+	e.long(*v)
+}
+
+// writeAvroFile writes records, already encoded with schema, as an
+// uncompressed Avro object container file with metadata in its header
+func writeAvroFile(schema string, metadata map[string]string, records [][]byte) ([]byte, error) {
+	sync := make([]byte, 16)
+	if _, err := rand.Read(sync); err != nil {
+		return nil, err
+	}
+	metadata["avro.schema"] = schema
+	metadata["avro.codec"] = "null"
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var e avroEncoder
+	e.buf.WriteString("Obj\x01")
+	e.long(int64(len(keys)))
+	for _, key := range keys {
+		e.string(key)
+		e.string(metadata[key])
+	}
+	e.long(0)
+	e.buf.Write(sync)
+	if len(records) > 0 {
+		var size int
+		for _, record := range records {
+			size += len(record)
+		}
+		e.long(int64(len(records)))
+		e.long(int64(size))
+		for _, record := range records {
+			e.buf.Write(record)
+		}
+		e.buf.Write(sync)
+	}
+	return e.buf.Bytes(), nil
+}
@@ -0,0 +1,450 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/airframesio/data-archiver/cmd/formatters"
+)
+
+// writeTestParquet writes rows as a Parquet object of the local bucket
+func writeTestParquet(t *testing.T, bucket, key string, rows []map[string]interface{}) {
+	t.Helper()
+	data, err := formatters.NewParquetFormatter().Format(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(bucket, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestTableTarget returns the target of the events table in a local bucket
+func newTestTableTarget(t *testing.T, bucket string) tableTarget {
+	t.Helper()
+	target, err := newTableTarget(&localObjectStore{}, &Config{
+		Table: "events",
+		S3:    S3Config{Bucket: bucket, Backend: StorageBackendLocal, PathTemplate: "archives/{table}/{YYYY}/{MM}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return target
+}
+
+// readAvroFile decodes the header metadata of an Avro file written by
+// writeAvroFile, and its records with decode
+func readAvroFile(t *testing.T, data []byte, decode func(*avroDecoder) []interface{}) (map[string]string, [][]interface{}) {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("Obj\x01")) {
+		t.Fatalf("not an Avro file: %q", data[:min(len(data), 8)])
+	}
+	d := &avroDecoder{data: data[4:]}
+	metadata := make(map[string]string)
+	for count := d.long(); count != 0; count = d.long() {
+		for i := int64(0); i < count; i++ {
+			key := d.string()
+			metadata[key] = d.string()
+		}
+	}
+	sync := d.take(16)
+	var records [][]interface{}
+	for len(d.data) > 0 {
+		count := d.long()
+		d.long() // Block size
+		for i := int64(0); i < count; i++ {
+			records = append(records, decode(d))
+		}
+		if !bytes.Equal(d.take(16), sync) {
+			t.Fatal("block sync marker differs from the header's")
+		}
+	}
+	return metadata, records
+}
+
+type avroDecoder struct {
+	data []byte
+}
+
+func (d *avroDecoder) long() int64 {
+	var u uint64
+	for shift := 0; ; shift += 7 {
+		b := d.data[0]
+		d.data = d.data[1:]
+		u |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			break
+		}
+	}
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (d *avroDecoder) take(n int) []byte {
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *avroDecoder) string() string {
+	return string(d.take(int(d.long())))
+}
+
+func (d *avroDecoder) optionalLong() interface{} {
+	if d.long() == 0 {
+		return nil
+	}
+	return d.long()
+}
+
+// decodeManifestEntry decodes status, snapshot_id, sequence_number,
+// file_sequence_number, content, file_path, file_format, record_count, and
+// file_size_in_bytes
+func decodeManifestEntry(d *avroDecoder) []interface{} {
+	return []interface{}{d.long(), d.optionalLong(), d.optionalLong(), d.optionalLong(), d.long(), d.string(), d.string(), d.long(), d.long()}
+}
+
+func decodeManifestFile(d *avroDecoder) []interface{} {
+	record := []interface{}{d.string()}
+	for i := 0; i < 12; i++ {
+		record = append(record, d.long())
+	}
+	return record
+}
+
+func readLocalObject(t *testing.T, bucket, key string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(bucket, filepath.FromSlash(key)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestTableDataPrefix(t *testing.T) {
+	tests := []struct {
+		template, prefix, root string
+	}{
+		{"archives/{table}/{YYYY}/{MM}", "archives/events/", "archives/events"},
+		{"archives/{table}", "archives/events/", "archives/events"},
+		{"archives/{table}-{YYYY}/{MM}", "archives/", "archives/events"},
+		{"{YYYY}/{table}", "", "events"},
+		{"{table}/{YYYY}", "events/", "events"},
+	}
+	for _, tt := range tests {
+		prefix := tableDataPrefix(tt.template, "events")
+		if prefix != tt.prefix {
+			t.Errorf("tableDataPrefix(%q) = %q, want %q", tt.template, prefix, tt.prefix)
+		}
+		if root := defaultTableRoot(prefix, "events"); root != tt.root {
+			t.Errorf("defaultTableRoot(%q) = %q, want %q", prefix, root, tt.root)
+		}
+	}
+
+	for key, want := range map[string]bool{
+		"archives/events/2024/01/events-2024-01-05.parquet":           true,
+		"archives/events/2024/01/events-2024-01-05-part-0001.parquet": true,
+		"archives/events/2024/01/events-2024-01-05.jsonl.zst":         false,
+		"archives/events/2024/01/events_log-2024-01-05.parquet":       false,
+		"archives/events/_trash/2024/events-2024-01-05.parquet":       false,
+		"archives/events/metadata/snap-1.avro":                        false,
+	} {
+		if got := isTableDataKey(key, "events", ".parquet"); got != want {
+			t.Errorf("isTableDataKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestValidateTableFormat(t *testing.T) {
+	cfg := &Config{TableFormat: TableFormatIceberg, OutputFormat: "parquet"}
+	if err := cfg.validateTableFormat(); err != nil {
+		t.Errorf("iceberg with parquet: %v", err)
+	}
+	cfg.OutputFormat = "jsonl"
+	if err := cfg.validateTableFormat(); !errors.Is(err, ErrTableFormatParquet) {
+		t.Errorf("iceberg with jsonl: %v", err)
+	}
+	cfg.TableFormat = "hudi"
+	if err := cfg.validateTableFormat(); !errors.Is(err, ErrTableFormatInvalid) {
+		t.Errorf("hudi: %v", err)
+	}
+}
+
+func TestCommitIcebergTable(t *testing.T) {
+	ctx := context.Background()
+	bucket := t.TempDir()
+	target := newTestTableTarget(t, bucket)
+	now := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	jan1 := "archives/events/2024/01/events-2024-01-01.parquet"
+	jan2 := "archives/events/2024/01/events-2024-01-02.parquet"
+	writeTestParquet(t, bucket, jan1, []map[string]interface{}{{"id": int64(1), "name": "a"}, {"id": int64(2), "name": "b"}})
+	writeTestParquet(t, bucket, jan2, []map[string]interface{}{{"id": int64(3), "name": "c"}})
+
+	result, err := commitIcebergTable(ctx, target, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Version != 1 || result.Added != 2 || result.Removed != 0 || result.Files != 2 || result.Rows != 3 {
+		t.Fatalf("first commit = %+v", result)
+	}
+	if hint := readLocalObject(t, bucket, "archives/events/metadata/version-hint.text"); string(hint) != "1" {
+		t.Errorf("version hint = %q", hint)
+	}
+	var metadata icebergMetadata
+	if err := json.Unmarshal(readLocalObject(t, bucket, "archives/events/metadata/v1.metadata.json"), &metadata); err != nil {
+		t.Fatal(err)
+	}
+	schema := metadata.currentSchema()
+	if len(schema.Fields) != 2 || schema.Fields[0] != (icebergField{ID: 1, Name: "id", Type: "long"}) || schema.Fields[1] != (icebergField{ID: 2, Name: "name", Type: "string"}) {
+		t.Errorf("schema = %+v", schema)
+	}
+	if mapping := metadata.Properties["schema.name-mapping.default"]; mapping != `[{"field-id":1,"names":["id"]},{"field-id":2,"names":["name"]}]` {
+		t.Errorf("name mapping = %s", mapping)
+	}
+	if !strings.HasPrefix(metadata.Location, "file://") || !strings.HasSuffix(metadata.Location, "/archives/events") {
+		t.Errorf("location = %s", metadata.Location)
+	}
+	first := metadata.currentSnapshot()
+	if first == nil || first.Summary["operation"] != "append" || first.Summary["total-records"] != "3" || first.SequenceNumber != 1 ||
+		first.Summary[icebergRunIDSummaryKey] != currentRunID {
+		t.Fatalf("snapshot = %+v", first)
+	}
+
+	// The manifest list points at one manifest listing both files as added
+	listKey := strings.TrimPrefix(first.ManifestList, target.BaseURI+"/")
+	listMetadata, manifests := readAvroFile(t, readLocalObject(t, bucket, listKey), decodeManifestFile)
+	if listMetadata["format-version"] != "2" || listMetadata["parent-snapshot-id"] != "null" || len(manifests) != 1 {
+		t.Fatalf("manifest list metadata = %v, manifests = %v", listMetadata, manifests)
+	}
+	manifestKey := strings.TrimPrefix(manifests[0][0].(string), target.BaseURI+"/")
+	manifestData := readLocalObject(t, bucket, manifestKey)
+	if manifests[0][1].(int64) != int64(len(manifestData)) || manifests[0][7].(int64) != 2 || manifests[0][10].(int64) != 3 {
+		t.Errorf("manifest file = %v", manifests[0])
+	}
+	manifestMetadata, entries := readAvroFile(t, manifestData, decodeManifestEntry)
+	if manifestMetadata["content"] != "data" || manifestMetadata["schema-id"] != "0" || len(entries) != 2 {
+		t.Fatalf("manifest metadata = %v, entries = %v", manifestMetadata, entries)
+	}
+	if entries[0][0].(int64) != icebergStatusAdded || entries[0][1].(int64) != first.SnapshotID || entries[0][2] != nil ||
+		entries[0][5] != target.uri(jan1) || entries[0][6] != "PARQUET" || entries[0][7].(int64) != 2 {
+		t.Errorf("manifest entry = %v", entries[0])
+	}
+
+	// Nothing changed: no new version
+	if result, err := commitIcebergTable(ctx, target, now); err != nil || result.Version != 1 || result.Added != 0 || result.Rows != 3 {
+		t.Fatalf("unchanged commit = %+v, %v", result, err)
+	}
+
+	// A new file with a new column is added and a deleted file removed
+	feb1 := "archives/events/2024/02/events-2024-02-01.parquet"
+	writeTestParquet(t, bucket, feb1, []map[string]interface{}{{"id": int64(4), "name": "d", "ok": true}})
+	if err := os.Remove(filepath.Join(bucket, filepath.FromSlash(jan2))); err != nil {
+		t.Fatal(err)
+	}
+	result, err = commitIcebergTable(ctx, target, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Version != 2 || result.Added != 1 || result.Removed != 1 || result.Files != 2 || result.Rows != 3 {
+		t.Fatalf("second commit = %+v", result)
+	}
+	metadata = icebergMetadata{}
+	if err := json.Unmarshal(readLocalObject(t, bucket, "archives/events/metadata/v2.metadata.json"), &metadata); err != nil {
+		t.Fatal(err)
+	}
+	second := metadata.currentSnapshot()
+	if second.Summary["operation"] != "overwrite" || *second.ParentSnapshotID != first.SnapshotID || second.SequenceNumber != 2 {
+		t.Errorf("second snapshot = %+v", second)
+	}
+	if schema := metadata.currentSchema(); schema.SchemaID != 1 || len(schema.Fields) != 3 || schema.Fields[2] != (icebergField{ID: 3, Name: "ok", Type: "boolean"}) {
+		t.Errorf("evolved schema = %+v", schema)
+	}
+	if len(metadata.MetadataLog) != 1 || !strings.HasSuffix(metadata.MetadataLog[0].MetadataFile, "/v1.metadata.json") {
+		t.Errorf("metadata log = %+v", metadata.MetadataLog)
+	}
+	_, manifests = readAvroFile(t, readLocalObject(t, bucket, strings.TrimPrefix(second.ManifestList, target.BaseURI+"/")), decodeManifestFile)
+	_, entries = readAvroFile(t, readLocalObject(t, bucket, strings.TrimPrefix(manifests[0][0].(string), target.BaseURI+"/")), decodeManifestEntry)
+	if len(entries) != 2 || entries[0][5] != target.uri(jan1) || entries[0][0].(int64) != icebergStatusExisting ||
+		entries[0][1].(int64) != first.SnapshotID || entries[0][2].(int64) != 1 || entries[1][0].(int64) != icebergStatusAdded {
+		t.Errorf("second manifest entries = %v", entries)
+	}
+	if manifests[0][5].(int64) != 1 || manifests[0][7].(int64) != 1 || manifests[0][8].(int64) != 1 {
+		t.Errorf("second manifest file = %v", manifests[0])
+	}
+
+	// A column whose type changed can't be added
+	writeTestParquet(t, bucket, "archives/events/2024/02/events-2024-02-02.parquet", []map[string]interface{}{{"id": "five"}})
+	if _, err := commitIcebergTable(ctx, target, now); !errors.Is(err, ErrTableColumnConflict) {
+		t.Errorf("expected a column conflict, got %v", err)
+	}
+}
+
+func TestArchiveTableUpdatesTableFormat(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	bucket := t.TempDir()
+	config := &Config{
+		Table:        "events",
+		OutputFormat: "parquet",
+		Compression:  "zstd",
+		TableFormat:  TableFormatIceberg,
+		S3:           S3Config{Bucket: bucket, Backend: StorageBackendLocal, PathTemplate: "archives/{table}/{YYYY}/{MM}"},
+	}
+	archiver := NewArchiver(config, newTestLogger())
+
+	// Every table of a --tables run finishes through archiveTable, so each
+	// one's table metadata picks up the files it archived
+	err := archiver.archiveTable(context.Background(), func(context.Context) error {
+		archiver.s3Client = &localObjectStore{}
+		writeTestParquet(t, bucket, "archives/events/2024/01/events-2024-01-01.parquet", []map[string]interface{}{{"id": int64(1)}})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hint := readLocalObject(t, bucket, "archives/events/metadata/version-hint.text"); string(hint) != "1" {
+		t.Errorf("version hint = %q", hint)
+	}
+}
+
+func TestCommitIcebergTableChangedElsewhere(t *testing.T) {
+	bucket := t.TempDir()
+	target := newTestTableTarget(t, bucket)
+	writeTestParquet(t, bucket, "archives/events/2024/01/events-2024-01-01.parquet", []map[string]interface{}{{"id": int64(1)}})
+	path := filepath.Join(bucket, "archives", "events", "metadata", "00001-0a1b.metadata.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := commitIcebergTable(context.Background(), target, time.Now()); !errors.Is(err, ErrTableChangedElsewhere) {
+		t.Errorf("expected ErrTableChangedElsewhere, got %v", err)
+	}
+}
+
+// readDeltaCommit decodes the actions of a Delta commit
+func readDeltaCommit(t *testing.T, bucket string, version int) []deltaAction {
+	t.Helper()
+	data := readLocalObject(t, bucket, fmt.Sprintf("archives/events/_delta_log/%020d.json", version))
+	var actions []deltaAction
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var action deltaAction
+		if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+			t.Fatalf("invalid action %q: %v", scanner.Text(), err)
+		}
+		actions = append(actions, action)
+	}
+	return actions
+}
+
+func TestCommitDeltaTable(t *testing.T) {
+	ctx := context.Background()
+	bucket := t.TempDir()
+	target := newTestTableTarget(t, bucket)
+	now := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	jan1 := "archives/events/2024/01/events-2024-01-01.parquet"
+	jan2 := "archives/events/2024/01/events-2024-01-02.parquet"
+	writeTestParquet(t, bucket, jan1, []map[string]interface{}{{"id": int64(1), "seen": "2024-01-01"}, {"id": int64(2), "seen": "2024-01-01"}})
+	writeTestParquet(t, bucket, jan2, []map[string]interface{}{{"id": int64(3), "seen": "2024-01-02"}})
+
+	result, err := commitDeltaTable(ctx, target, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Version != 0 || result.Added != 2 || result.Rows != 3 {
+		t.Fatalf("first commit = %+v", result)
+	}
+	actions := readDeltaCommit(t, bucket, 0)
+	if len(actions) != 5 || actions[0].CommitInfo == nil || actions[1].Protocol == nil || actions[2].MetaData == nil {
+		t.Fatalf("first commit actions = %+v", actions)
+	}
+	if actions[0].CommitInfo.RunID != currentRunID {
+		t.Errorf("commitInfo runId = %q", actions[0].CommitInfo.RunID)
+	}
+	if schema := actions[2].MetaData.SchemaString; schema != `{"type":"struct","fields":[{"name":"id","type":"long","nullable":true,"metadata":{}},{"name":"seen","type":"string","nullable":true,"metadata":{}}]}` {
+		t.Errorf("schema = %s", schema)
+	}
+	if add := actions[3].Add; add == nil || add.Path != "2024/01/events-2024-01-01.parquet" || add.Stats != `{"numRecords":2}` || !add.DataChange {
+		t.Errorf("add = %+v", add)
+	}
+
+	// Nothing changed: no new version
+	if result, err := commitDeltaTable(ctx, target, now); err != nil || result.Version != 0 || result.Added != 0 || result.Rows != 3 {
+		t.Fatalf("unchanged commit = %+v, %v", result, err)
+	}
+
+	// A deleted file is removed and a new file with a new column added
+	if err := os.Remove(filepath.Join(bucket, filepath.FromSlash(jan2))); err != nil {
+		t.Fatal(err)
+	}
+	writeTestParquet(t, bucket, "archives/events/2024/02/events-2024-02-01.parquet", []map[string]interface{}{{"id": int64(4), "score": 1.5}})
+	result, err = commitDeltaTable(ctx, target, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Version != 1 || result.Added != 1 || result.Removed != 1 || result.Rows != 3 {
+		t.Fatalf("second commit = %+v", result)
+	}
+	actions = readDeltaCommit(t, bucket, 1)
+	if len(actions) != 4 || actions[1].MetaData == nil || actions[2].Remove == nil || actions[3].Add == nil {
+		t.Fatalf("second commit actions = %+v", actions)
+	}
+	if actions[1].MetaData.ID != readDeltaCommit(t, bucket, 0)[2].MetaData.ID || !strings.Contains(actions[1].MetaData.SchemaString, `"name":"score","type":"double"`) {
+		t.Errorf("evolved metadata = %+v", actions[1].MetaData)
+	}
+	if actions[2].Remove.Path != "2024/01/events-2024-01-02.parquet" {
+		t.Errorf("remove = %+v", actions[2].Remove)
+	}
+}
+
+func TestDeltaPath(t *testing.T) {
+	target := tableTarget{BaseURI: "s3://bucket", Root: "archives/events"}
+	if got := deltaPath(target, "archives/events/2024/events 1.parquet"); got != "2024/events%201.parquet" {
+		t.Errorf("relative path = %q", got)
+	}
+	if got := deltaPath(target, "other/events-2024.parquet"); got != "s3://bucket/other/events-2024.parquet" {
+		t.Errorf("absolute path = %q", got)
+	}
+}
+
+func TestListTableDataFilesDropsUnlistedParts(t *testing.T) {
+	bucket := t.TempDir()
+	target := newTestTableTarget(t, bucket)
+	rows := []map[string]interface{}{{"id": int64(1)}}
+	part1 := "archives/events/2024/01/events-2024-01-01-part-0001.parquet"
+	writeTestParquet(t, bucket, part1, rows)
+	writeTestParquet(t, bucket, "archives/events/2024/01/events-2024-01-01-part-0002.parquet", rows) // Left by an earlier run
+	writeTestParquet(t, bucket, "archives/events/2024/01/events-2024-01-02.parquet", rows)
+	manifest, err := splitManifest{Table: "events", Parts: []manifestPart{{Part: 1, Key: part1, Rows: 1}}}.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bucket, "archives", "events", "2024", "01", "events-2024-01-01"+manifestSuffix), manifest, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := listTableDataFiles(context.Background(), target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, file := range files {
+		keys = append(keys, file.Key)
+	}
+	if want := part1 + ",archives/events/2024/01/events-2024-01-02.parquet"; strings.Join(keys, ",") != want {
+		t.Errorf("files = %v, want %s", keys, want)
+	}
+}
@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Static errors for multi-table runs and per-table quotas
+var (
+	ErrTableConcurrencyInvalid      = errors.New("table concurrency must be at least 1")
+	ErrMaxParallelPartitionsInvalid = errors.New("max parallel partitions must be at least 1")
+	ErrByteRateInvalid              = errors.New("byte rate must be a positive size such as 512KB, 50MB or 1GB (per second)")
+	ErrTableQuotaNiceInvalid        = errors.New("table quota nice must be between -20 and 19")
+	ErrTablesConflict               = errors.New("--table and --tables cannot be used together")
+)
+
+// TableQuota limits the resources a single table may use during a run
+type TableQuota struct {
+	MaxParallelPartitions int   // Partitions of this table processed at once
+	MaxBandwidth          int64 // Upload bytes per second (0 = unlimited)
+	Nice                  int   // Scheduling priority in multi-table runs: lower values start first
+}
+
+// tableQuotaConfig is the config file form of a TableQuota (table_quotas.<table>)
+type tableQuotaConfig struct {
+	MaxParallelPartitions int    `mapstructure:"max_parallel_partitions"`
+	MaxBandwidth          string `mapstructure:"max_bandwidth"`
+	Nice                  int    `mapstructure:"nice"`
+}
+
+// validate checks the quota limits
+func (q TableQuota) validate() error {
+	if q.MaxParallelPartitions < 1 {
+		return fmt.Errorf("%w, got %d", ErrMaxParallelPartitionsInvalid, q.MaxParallelPartitions)
+	}
+	if q.MaxBandwidth < 0 {
+		return fmt.Errorf("%w, got %d", ErrByteRateInvalid, q.MaxBandwidth)
+	}
+	if q.Nice < -20 || q.Nice > 19 {
+		return fmt.Errorf("%w, got %d", ErrTableQuotaNiceInvalid, q.Nice)
+	}
+	return nil
+}
+
+// parseByteRate parses a per-second size such as "50MB", "50MB/s", "1.5G" or
+// "524288". Units are binary (1KB = 1024 bytes). An empty string means unlimited.
+func parseByteRate(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(s, "/S")
+	if s == "" {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "IB"), "B")
+	switch {
+	case strings.HasSuffix(s, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+
+	number, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("%w: '%s'", ErrByteRateInvalid, value)
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
+// loadTableQuotas reads table_quotas from the config file. Limits left unset
+// for a table fall back to the defaults from --max-parallel-partitions and
+// --max-table-bandwidth.
+func loadTableQuotas(defaults TableQuota) (map[string]TableQuota, error) {
+	var raw map[string]tableQuotaConfig
+	if err := viper.UnmarshalKey("table_quotas", &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse table_quotas: %w", err)
+	}
+
+	quotas := make(map[string]TableQuota, len(raw))
+	for table, entry := range raw {
+		quota := defaults
+		if entry.MaxParallelPartitions != 0 {
+			quota.MaxParallelPartitions = entry.MaxParallelPartitions
+		}
+		if entry.MaxBandwidth != "" {
+			rate, err := parseByteRate(entry.MaxBandwidth)
+			if err != nil {
+				return nil, fmt.Errorf("table_quotas.%s.max_bandwidth: %w", table, err)
+			}
+			quota.MaxBandwidth = rate
+		}
+		quota.Nice = entry.Nice
+		if err := quota.validate(); err != nil {
+			return nil, fmt.Errorf("table_quotas.%s: %w", table, err)
+		}
+		quotas[table] = quota
+	}
+	return quotas, nil
+}
+
+// quotaForTable returns the quota configured for table, or the defaults
+func quotaForTable(quotas map[string]TableQuota, defaults TableQuota, table string) TableQuota {
+	if quota, ok := quotas[table]; ok {
+		return quota
+	}
+	return defaults
+}
+
+// parseTableList splits the --tables value (or the tables list from the config
+// file) into unique table names
+func parseTableList(values []string) []string {
+	var tables []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		for _, table := range strings.Split(value, ",") {
+			table = strings.TrimSpace(table)
+			if table == "" || seen[table] {
+				continue
+			}
+			seen[table] = true
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+// orderTablesByNice returns tables sorted by their quota's nice value, keeping
+// the configured order for ties, so high-priority tables are scheduled first
+func orderTablesByNice(tables []string, quotas map[string]TableQuota, defaults TableQuota) []string {
+	ordered := append([]string(nil), tables...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return quotaForTable(quotas, defaults, ordered[i]).Nice < quotaForTable(quotas, defaults, ordered[j]).Nice
+	})
+	return ordered
+}
+
+// bandwidthLimiter is a token bucket shared by every upload of one table
+type bandwidthLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	tokens float64
+	last   time.Time
+	sleep  func(context.Context, time.Duration) error
+	parent *bandwidthLimiter // Run-wide limit also paced by (nil = none)
+}
+
+// newBandwidthLimiter returns a limiter for bytesPerSecond, or nil when unlimited
+func newBandwidthLimiter(bytesPerSecond int64) *bandwidthLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &bandwidthLimiter{
+		rate:   float64(bytesPerSecond),
+		tokens: float64(bytesPerSecond),
+		last:   time.Now(),
+		sleep:  sleepContext,
+	}
+}
+
+// wait blocks until n bytes may be sent, here and in the parent limiter.
+// Bursts are capped at one second of traffic.
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	if err := l.take(ctx, n); err != nil {
+		return err
+	}
+	if l.parent != nil {
+		return l.parent.wait(ctx, n)
+	}
+	return nil
+}
+
+func (l *bandwidthLimiter) take(ctx context.Context, n int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens = min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return nil
+	}
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	if err := l.sleep(ctx, delay); err != nil {
+		return err
+	}
+	l.tokens = 0
+	l.last = time.Now()
+	return nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttledReadSeeker paces reads through a bandwidthLimiter. It keeps the
+// io.Seeker interface so it can be used as an S3 PutObject body.
+type throttledReadSeeker struct {
+	ctx     context.Context
+	rs      io.ReadSeeker
+	limiter *bandwidthLimiter
+}
+
+// throttleUpload wraps body with the limiter, or returns it unchanged when unlimited
+func throttleUpload(ctx context.Context, body io.ReadSeeker, limiter *bandwidthLimiter) io.ReadSeeker {
+	if limiter == nil {
+		return body
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &throttledReadSeeker{ctx: ctx, rs: body, limiter: limiter}
+}
+
+func (t *throttledReadSeeker) Read(p []byte) (int, error) {
+	n, err := t.rs.Read(p)
+	if n > 0 {
+		if waitErr := t.limiter.wait(t.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+func (t *throttledReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return t.rs.Seek(offset, whence)
+}
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestParseByteRate(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"524288", 524288, false},
+		{"512KB", 512 << 10, false},
+		{"50MB/s", 50 << 20, false},
+		{"50mib", 50 << 20, false},
+		{"1.5G", 3 << 29, false},
+		{"fast", 0, true},
+		{"-5MB", 0, true},
+		{"0", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseByteRate(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, ErrByteRateInvalid) {
+					t.Fatalf("expected ErrByteRateInvalid, got %v", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("expected %d, got %d (err %v)", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestLoadTableQuotas(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("table_quotas", map[string]interface{}{
+		"flights": map[string]interface{}{"max_parallel_partitions": 4, "max_bandwidth": "20MB", "nice": 10},
+		"alerts":  map[string]interface{}{"nice": -5},
+	})
+
+	defaults := TableQuota{MaxParallelPartitions: 1, MaxBandwidth: 1 << 20}
+	quotas, err := loadTableQuotas(defaults)
+	if err != nil {
+		t.Fatalf("loadTableQuotas failed: %v", err)
+	}
+
+	if got := quotas["flights"]; got != (TableQuota{MaxParallelPartitions: 4, MaxBandwidth: 20 << 20, Nice: 10}) {
+		t.Errorf("unexpected flights quota %+v", got)
+	}
+	if got := quotas["alerts"]; got != (TableQuota{MaxParallelPartitions: 1, MaxBandwidth: 1 << 20, Nice: -5}) {
+		t.Errorf("expected alerts to inherit defaults, got %+v", got)
+	}
+	if got := quotaForTable(quotas, defaults, "messages"); got != defaults {
+		t.Errorf("expected unlisted table to use defaults, got %+v", got)
+	}
+
+	order := orderTablesByNice([]string{"flights", "messages", "alerts"}, quotas, defaults)
+	if strings.Join(order, ",") != "alerts,messages,flights" {
+		t.Errorf("unexpected scheduling order %v", order)
+	}
+}
+
+func TestLoadTableQuotasInvalid(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("table_quotas", map[string]interface{}{
+		"flights": map[string]interface{}{"nice": 40},
+	})
+
+	if _, err := loadTableQuotas(TableQuota{MaxParallelPartitions: 1}); !errors.Is(err, ErrTableQuotaNiceInvalid) {
+		t.Errorf("expected ErrTableQuotaNiceInvalid, got %v", err)
+	}
+}
+
+func TestParseTableList(t *testing.T) {
+	got := parseTableList([]string{"flights, messages", "alerts,flights", ""})
+	if strings.Join(got, ",") != "flights,messages,alerts" {
+		t.Errorf("unexpected tables %v", got)
+	}
+}
+
+func TestBandwidthLimiter(t *testing.T) {
+	if newBandwidthLimiter(0) != nil {
+		t.Fatal("expected no limiter for an unlimited rate")
+	}
+
+	limiter := newBandwidthLimiter(1000)
+	var slept time.Duration
+	limiter.sleep = func(_ context.Context, d time.Duration) error {
+		slept += d
+		return nil
+	}
+
+	// The first second of traffic is allowed as a burst
+	if err := limiter.wait(context.Background(), 1000); err != nil {
+		t.Fatal(err)
+	}
+	if slept != 0 {
+		t.Fatalf("expected no delay within the burst, slept %v", slept)
+	}
+
+	if err := limiter.wait(context.Background(), 500); err != nil {
+		t.Fatal(err)
+	}
+	if slept < 450*time.Millisecond || slept > 550*time.Millisecond {
+		t.Errorf("expected about 500ms of delay, slept %v", slept)
+	}
+}
+
+func TestThrottledReadSeeker(t *testing.T) {
+	data := []byte(strings.Repeat("x", 4096))
+	if body := throttleUpload(context.Background(), bytes.NewReader(data), nil); body == nil {
+		t.Fatal("expected body to be returned unchanged")
+	}
+
+	limiter := newBandwidthLimiter(1024)
+	var slept time.Duration
+	limiter.sleep = func(_ context.Context, d time.Duration) error {
+		slept += d
+		return nil
+	}
+
+	body := throttleUpload(context.Background(), bytes.NewReader(data), limiter)
+	got, err := io.ReadAll(body)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("unexpected read result (%d bytes, err %v)", len(got), err)
+	}
+	if slept < 2900*time.Millisecond {
+		t.Errorf("expected about 3s of pacing for 4KB at 1KB/s, slept %v", slept)
+	}
+
+	if pos, err := body.Seek(0, io.SeekStart); err != nil || pos != 0 {
+		t.Errorf("expected seek to pass through, got %d, %v", pos, err)
+	}
+}
+
+func TestThrottledReadSeekerCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	limiter := newBandwidthLimiter(1)
+	body := throttleUpload(ctx, bytes.NewReader([]byte("abc")), limiter)
+	if _, err := io.ReadAll(body); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestBuildTableConfigs(t *testing.T) {
+	base := newTestConfig()
+	base.Table = ""
+	base.Tables = []string{"flights", "alerts"}
+	base.TableConcurrency = 2
+
+	defaults := TableQuota{MaxParallelPartitions: 1}
+	quotas := map[string]TableQuota{"alerts": {MaxParallelPartitions: 1, Nice: -10}}
+
+	configs, err := buildTableConfigs(base, quotas, defaults)
+	if err != nil {
+		t.Fatalf("buildTableConfigs failed: %v", err)
+	}
+	if len(configs) != 2 || configs[0].Table != "alerts" || configs[1].Table != "flights" {
+		t.Fatalf("expected alerts to be scheduled first, got %v", configs)
+	}
+	if configs[0].CacheScope.Table != "alerts" || configs[0].Quota.Nice != -10 {
+		t.Errorf("expected per-table scope and quota, got %+v", configs[0])
+	}
+
+	base.Table = "flights"
+	if _, err := buildTableConfigs(base, quotas, defaults); !errors.Is(err, ErrTablesConflict) {
+		t.Errorf("expected ErrTablesConflict, got %v", err)
+	}
+
+	base.Table = ""
+	base.Tables = []string{"flights", "bad-name"}
+	if _, err := buildTableConfigs(base, quotas, defaults); !errors.Is(err, ErrTableNameInvalid) {
+		t.Errorf("expected ErrTableNameInvalid, got %v", err)
+	}
+}
@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Static errors for time zones
+var (
+	ErrTimezoneInvalid   = errors.New("timezone must be an IANA zone name such as UTC or America/New_York")
+	ErrKeyTimezoneRepeat = errors.New("key time zone sets its clocks back in the date range, so two slices shorter than a day would get the same key; key them in the slice zone or a zone without DST, or use daily or longer output")
+)
+
+var (
+	sliceTimezone string
+	keyTimezone   string
+)
+
+func init() {
+	archiveCmd.Flags().StringVar(&sliceTimezone, "timezone", "UTC", "time zone partitions are sliced in: partition name dates, --start-date/--end-date and slice boundaries")
+	archiveCmd.Flags().StringVar(&keyTimezone, "key-timezone", "", "time zone of the {YYYY}/{MM}/{DD}/{HH} values in object paths and filenames (default: --timezone)")
+	_ = viper.BindPFlag("timezone", archiveCmd.Flags().Lookup("timezone"))
+	_ = viper.BindPFlag("key_timezone", archiveCmd.Flags().Lookup("key-timezone"))
+}
+
+// TableTimezone sets the time zones a table is sliced and keyed in. Nil
+// locations mean UTC.
+type TableTimezone struct {
+	Slice *time.Location // Partition name dates, the date range, and slice WHERE boundaries
+	Key   *time.Location // {YYYY}/{MM}/{DD}/{HH} values in object paths and filenames
+}
+
+// tableTimezoneConfig is the config file form of a TableTimezone (table_timezones.<table>)
+type tableTimezoneConfig struct {
+	Timezone    string `mapstructure:"timezone"`
+	KeyTimezone string `mapstructure:"key_timezone"`
+}
+
+// sliceLocation returns the zone slices are cut in
+func (z TableTimezone) sliceLocation() *time.Location {
+	if z.Slice == nil {
+		return time.UTC
+	}
+	return z.Slice
+}
+
+// keyLocation returns the zone object keys are rendered in
+func (z TableTimezone) keyLocation() *time.Location {
+	if z.Key == nil {
+		return time.UTC
+	}
+	return z.Key
+}
+
+// converts reports whether slice starts change zone when they become keys
+func (z TableTimezone) converts() bool {
+	return z.sliceLocation().String() != z.keyLocation().String()
+}
+
+// loadLocation loads an IANA time zone; an empty name is UTC
+func loadLocation(name string) (*time.Location, error) {
+	location, err := time.LoadLocation(strings.TrimSpace(name))
+	if err != nil {
+		return nil, fmt.Errorf("%w: '%s'", ErrTimezoneInvalid, name)
+	}
+	return location, nil
+}
+
+// newTableTimezone loads a slice zone and a key zone; an empty key zone
+// follows the slice zone
+func newTableTimezone(slice, key string) (TableTimezone, error) {
+	sliceLocation, err := loadLocation(slice)
+	if err != nil {
+		return TableTimezone{}, err
+	}
+	if strings.TrimSpace(key) == "" {
+		return TableTimezone{Slice: sliceLocation, Key: sliceLocation}, nil
+	}
+	keyLocation, err := loadLocation(key)
+	if err != nil {
+		return TableTimezone{}, err
+	}
+	return TableTimezone{Slice: sliceLocation, Key: keyLocation}, nil
+}
+
+// loadTableTimezones reads --timezone and --key-timezone as the defaults and
+// table_timezones from the config file. A table's key zone falls back to
+// --key-timezone, then to the table's own slice zone.
+func loadTableTimezones() (TableTimezone, map[string]TableTimezone, error) {
+	defaults, err := newTableTimezone(viper.GetString("timezone"), viper.GetString("key_timezone"))
+	if err != nil {
+		return TableTimezone{}, nil, err
+	}
+
+	var raw map[string]tableTimezoneConfig
+	if err := viper.UnmarshalKey("table_timezones", &raw); err != nil {
+		return TableTimezone{}, nil, fmt.Errorf("failed to parse table_timezones: %w", err)
+	}
+
+	zones := make(map[string]TableTimezone, len(raw))
+	for table, entry := range raw {
+		slice := entry.Timezone
+		if slice == "" {
+			slice = viper.GetString("timezone")
+		}
+		key := entry.KeyTimezone
+		if key == "" {
+			key = viper.GetString("key_timezone")
+		}
+		zone, err := newTableTimezone(slice, key)
+		if err != nil {
+			return TableTimezone{}, nil, fmt.Errorf("table_timezones.%s: %w", table, err)
+		}
+		zones[table] = zone
+	}
+	return defaults, zones, nil
+}
+
+// timezoneForTable returns the zones configured for table, or the defaults
+func timezoneForTable(zones map[string]TableTimezone, defaults TableTimezone, table string) TableTimezone {
+	if zone, ok := zones[table]; ok {
+		return zone
+	}
+	return defaults
+}
+
+// validateTimezone checks that slices shorter than a day get distinct keys.
+// A slice zone's repeated hour is one slice, but when slices are keyed in
+// another zone, the two slices that start in that zone's repeated hour
+// would be written to the same object.
+func (c *Config) validateTimezone() error {
+	zone := c.Timezone
+	if !zone.converts() || keyedDuration(c.OutputDuration) != DurationHourly {
+		return nil
+	}
+	from := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	if start, err := parseLocalDate("2006-01-02", c.StartDate, zone.sliceLocation()); err == nil {
+		from = start
+	}
+	to := time.Now()
+	if end, err := parseLocalDate("2006-01-02", c.EndDate, zone.sliceLocation()); err == nil {
+		to = addLocalDate(end, 0, 0, 1)
+	}
+	if clockTurnsBack(zone.keyLocation(), from, to) {
+		return fmt.Errorf("%w (%s output keyed in %s)", ErrKeyTimezoneRepeat, c.OutputDuration, zone.keyLocation())
+	}
+	return nil
+}
+
+// clockTurnsBack reports whether loc sets its clocks back between from and
+// to, so that an hour of its wall clock happens twice
+func clockTurnsBack(loc *time.Location, from, to time.Time) bool {
+	for t := from.In(loc); t.Before(to); {
+		_, end := t.ZoneBounds()
+		if end.IsZero() || !end.Before(to) {
+			return false
+		}
+		_, before := t.Zone()
+		t = end.In(loc)
+		if _, after := t.Zone(); after < before {
+			return true
+		}
+	}
+	return false
+}
+
+// wallClockTime returns the first instant in loc whose wall clock reads the
+// given hour. time.Date picks no particular instant for a repeated hour and
+// moves a skipped one back before the DST gap, which would key the day after a
+// transition at midnight (as in America/Santiago) with the day before; here a
+// repeated hour starts at its first occurrence and a skipped one at the end of
+// the gap. Out-of-range values are normalized like time.Date.
+func wallClockTime(year int, month time.Month, day, hour int, loc *time.Location) time.Time {
+	want := time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+	t := time.Date(year, month, day, hour, 0, 0, 0, loc)
+	if !sameWallClock(t, want) {
+		// In a gap: t is before it, in the zone period the gap ends
+		if _, end := t.ZoneBounds(); !end.IsZero() {
+			return end
+		}
+		return t
+	}
+	// A clock turned back at the start of t's zone period repeats the hour
+	// before it; the first occurrence is in the previous period
+	if start, _ := t.ZoneBounds(); !start.IsZero() {
+		_, before := start.Add(-time.Nanosecond).Zone()
+		_, offset := t.Zone()
+		if earlier := t.Add(time.Duration(offset-before) * time.Second); before > offset && earlier.Before(start) && sameWallClock(earlier, want) {
+			return earlier
+		}
+	}
+	return t
+}
+
+// sameWallClock reports whether t reads the date and hour of want
+func sameWallClock(t, want time.Time) bool {
+	return t.Year() == want.Year() && t.YearDay() == want.YearDay() && t.Hour() == want.Hour()
+}
+
+// addLocalDate adds years, months and days to the day t falls on, returning
+// the start of the resulting day in t's zone. Unlike t.AddDate, days whose
+// midnight is skipped by DST start after the gap instead of the day before.
+func addLocalDate(t time.Time, years, months, days int) time.Time {
+	return wallClockTime(t.Year()+years, t.Month()+time.Month(months), t.Day()+days, 0, t.Location())
+}
+
+// parseLocalDate parses a date in layout as the start of that day in loc
+func parseLocalDate(layout, value string, loc *time.Location) (time.Time, error) {
+	date, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return wallClockTime(date.Year(), date.Month(), date.Day(), 0, loc), nil
+}
+
+// parseDate parses a YYYY-MM-DD date (--start-date, --end-date) as the start
+// of that day in the table's slice zone
+func (a *Archiver) parseDate(value string) (time.Time, error) {
+	return parseLocalDate("2006-01-02", value, a.config.Timezone.sliceLocation())
+}
+
+// keyTime converts a slice start to the key zone, where its
+// {YYYY}/{MM}/{DD}/{HH} values for paths and filenames are read
+func (a *Archiver) keyTime(t time.Time) time.Time {
+	return t.In(a.config.Timezone.keyLocation())
+}
+
+// logTimezones states how slices are cut and keyed when either zone is not
+// UTC, with a worked conversion of the first day of the run
+func (a *Archiver) logTimezones() {
+	zone := a.config.Timezone
+	slice, key := zone.sliceLocation(), zone.keyLocation()
+	if slice == time.UTC && key == time.UTC {
+		return
+	}
+	day, err := a.parseDate(a.config.StartDate)
+	if err != nil {
+		now := time.Now().In(slice)
+		day = wallClockTime(now.Year(), now.Month(), now.Day(), 0, slice)
+	}
+	if !zone.converts() {
+		a.logger.Info(fmt.Sprintf("🕐 Slices cut and keyed in %s", slice))
+		return
+	}
+	const layout = "2006-01-02 15:04 MST"
+	a.logger.Info(fmt.Sprintf("🕐 Slices cut at %s midnight, keyed in %s (%s → %s)",
+		slice, key, day.Format(layout), a.keyTime(day).Format(layout)))
+}
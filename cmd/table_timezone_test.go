@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/spf13/viper"
+)
+
+func loadTestLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	return location
+}
+
+func TestNewTableTimezone(t *testing.T) {
+	newYork := loadTestLocation(t, "America/New_York")
+
+	zone, err := newTableTimezone("America/New_York", "")
+	if err != nil || zone.sliceLocation().String() != newYork.String() || zone.keyLocation().String() != newYork.String() || zone.converts() {
+		t.Errorf("key zone should follow the slice zone, got %+v, %v", zone, err)
+	}
+	zone, err = newTableTimezone("America/New_York", "UTC")
+	if err != nil || zone.keyLocation() != time.UTC || !zone.converts() {
+		t.Errorf("newTableTimezone() = %+v, %v", zone, err)
+	}
+	if _, err := newTableTimezone("Mars/Olympus_Mons", ""); !errors.Is(err, ErrTimezoneInvalid) {
+		t.Errorf("expected ErrTimezoneInvalid, got %v", err)
+	}
+	if zone := (TableTimezone{}); zone.sliceLocation() != time.UTC || zone.keyLocation() != time.UTC {
+		t.Error("zero value should be UTC")
+	}
+}
+
+func TestLoadTableTimezones(t *testing.T) {
+	loadTestLocation(t, "America/New_York")
+	defer viper.Reset()
+	viper.Set("timezone", "UTC")
+	viper.Set("table_timezones", map[string]interface{}{
+		"events": map[string]interface{}{"timezone": "America/New_York"},
+		"orders": map[string]interface{}{"timezone": "America/New_York", "key_timezone": "UTC"},
+	})
+
+	defaults, zones, err := loadTableTimezones()
+	if err != nil {
+		t.Fatalf("loadTableTimezones() failed: %v", err)
+	}
+	if defaults.sliceLocation() != time.UTC || defaults.keyLocation() != time.UTC {
+		t.Errorf("defaults = %+v", defaults)
+	}
+	if events := zones["events"]; events.sliceLocation().String() != "America/New_York" || events.converts() {
+		t.Errorf("events = %+v", events)
+	}
+	if orders := zones["orders"]; orders.keyLocation() != time.UTC || !orders.converts() {
+		t.Errorf("orders = %+v", orders)
+	}
+
+	// --key-timezone applies to tables that set only their slice zone
+	viper.Set("key_timezone", "UTC")
+	if _, zones, _ := loadTableTimezones(); zones["events"].keyLocation() != time.UTC {
+		t.Errorf("events key zone = %s, want UTC", zones["events"].keyLocation())
+	}
+
+	viper.Set("table_timezones", map[string]interface{}{"events": map[string]interface{}{"timezone": "Nowhere/Special"}})
+	if _, _, err := loadTableTimezones(); !errors.Is(err, ErrTimezoneInvalid) {
+		t.Errorf("expected ErrTimezoneInvalid, got %v", err)
+	}
+}
+
+func TestLocalPartitionsKeyedInUTC(t *testing.T) {
+	newYork := loadTestLocation(t, "America/New_York")
+	archiver := NewArchiver(&Config{
+		Table:          "events",
+		OutputFormat:   "jsonl",
+		Compression:    "zstd",
+		OutputDuration: DurationHourly,
+		StartDate:      "2024-01-15",
+		EndDate:        "2024-01-15",
+		S3:             S3Config{PathTemplate: "archives/{table}/{YYYY}/{MM}/{DD}/{HH}"},
+		Timezone:       TableTimezone{Slice: newYork, Key: time.UTC},
+	}, newTestLogger())
+
+	// Partition names are local days
+	date, ok := archiver.extractDateFromTableName("events_20240115")
+	if !ok || !date.Equal(time.Date(2024, 1, 15, 5, 0, 0, 0, time.UTC)) {
+		t.Fatalf("extractDateFromTableName() = %s, %v", date, ok)
+	}
+	if !archiver.inDateRange(date) {
+		t.Error("local partition should be within the local date range")
+	}
+
+	// Slice boundaries stay local; keys are rendered in UTC
+	ranges := SplitPartitionByDuration(date, date.AddDate(0, 0, 1), DurationHourly)
+	if len(ranges) != 24 || ranges[0].Start.Location() != newYork {
+		t.Fatalf("expected 24 local hourly slices, got %d", len(ranges))
+	}
+	key, err := archiver.sliceObjectKey(ranges[0].Start)
+	if err != nil || key != "archives/events/2024/01/15/05/events-2024-01-15-05.jsonl.zst" {
+		t.Errorf("sliceObjectKey() = %q, %v", key, err)
+	}
+
+	// Date columns compare local wall-clock days
+	spec := DateColumnSpec{Name: "day", Type: DateColumnDate}
+	if _, args := spec.rangeCondition(ranges[0].Start, ranges[1].Start); args[0] != "2024-01-15 00:00:00" || args[1] != "2024-01-15 01:00:00" {
+		t.Errorf("date args = %v", args)
+	}
+	spec = DateColumnSpec{Name: "stamp", Type: DateColumnText, Format: "2006-01-02 15:04"}
+	if _, args := spec.rangeCondition(ranges[0].Start, ranges[1].Start); args[0] != "2024-01-15 00:00" {
+		t.Errorf("text args = %v", args)
+	}
+}
+
+func TestSlicesAcrossDSTTransitions(t *testing.T) {
+	newYork := loadTestLocation(t, "America/New_York")
+
+	// Spring forward: 02:00 is skipped, so the day has 23 hourly slices
+	day := wallClockTime(2024, time.March, 10, 0, newYork)
+	ranges := SplitPartitionByDuration(day, addLocalDate(day, 0, 0, 1), DurationHourly)
+	if len(ranges) != 23 || ranges[2].Start.Hour() != 3 || !ranges[1].End.Equal(ranges[2].Start) {
+		t.Errorf("spring forward: %d slices, third at %s", len(ranges), ranges[2].Start)
+	}
+
+	// Fall back: 01:00 repeats, and its two occurrences are one slice, so
+	// no two slices share a key and no hour is skipped
+	day = wallClockTime(2024, time.November, 3, 0, newYork)
+	ranges = SplitPartitionByDuration(day, addLocalDate(day, 0, 0, 1), DurationHourly)
+	if len(ranges) != 24 || ranges[1].End.Sub(ranges[1].Start) != 2*time.Hour || !ranges[1].Start.Equal(time.Date(2024, 11, 3, 5, 0, 0, 0, time.UTC)) {
+		t.Errorf("fall back: %d slices, second %s to %s", len(ranges), ranges[1].Start, ranges[1].End)
+	}
+	if _, end := GetTimeRangeForDuration(day, DurationDaily); end.Sub(day) != 25*time.Hour {
+		t.Errorf("fall back day lasts %s, want 25h", end.Sub(day))
+	}
+
+	// Santiago skips midnight when DST starts: September 8th starts at
+	// 01:00, not at 23:00 on the 7th, and keeps its own key
+	santiago := loadTestLocation(t, "America/Santiago")
+	archiver := NewArchiver(&Config{
+		Table:          "events",
+		OutputFormat:   "jsonl",
+		Compression:    "none",
+		OutputDuration: DurationDaily,
+		S3:             S3Config{PathTemplate: "{table}/{YYYY}/{MM}/{DD}"},
+		Timezone:       TableTimezone{Slice: santiago, Key: santiago},
+	}, newTestLogger())
+	date, ok := archiver.extractDateFromTableName("events_20240908")
+	if !ok || date.Day() != 8 || date.Hour() != 1 {
+		t.Fatalf("extractDateFromTableName() = %s, %v", date, ok)
+	}
+	start, end := GetTimeRangeForDuration(wallClockTime(2024, time.September, 7, 12, santiago), DurationDaily)
+	if !end.Equal(date) || end.Sub(start) != 24*time.Hour {
+		t.Errorf("September 7th = %s to %s", start, end)
+	}
+	key, err := archiver.sliceObjectKey(date)
+	if err != nil || key != "events/2024/09/08/events-2024-09-08.jsonl" {
+		t.Errorf("sliceObjectKey() = %q, %v", key, err)
+	}
+}
+
+func TestValidateTimezoneRepeatedKeyHour(t *testing.T) {
+	newYork := loadTestLocation(t, "America/New_York")
+	config := &Config{
+		Table:          "flights",
+		OutputFormat:   "jsonl",
+		Compression:    "zstd",
+		OutputDuration: DurationHourly,
+		StartDate:      "2024-11-03",
+		EndDate:        "2024-11-03",
+		S3:             S3Config{PathTemplate: "{table}/{YYYY}/{MM}/{DD}"},
+		Timezone:       TableTimezone{Slice: time.UTC, Key: newYork},
+	}
+
+	// UTC slices at 05:00 and 06:00 both start at 01:00 in New York
+	archiver := NewArchiver(config, newTestLogger())
+	first, _ := archiver.sliceObjectKey(time.Date(2024, 11, 3, 5, 0, 0, 0, time.UTC))
+	second, _ := archiver.sliceObjectKey(time.Date(2024, 11, 3, 6, 0, 0, 0, time.UTC))
+	if first != second {
+		t.Fatalf("expected the repeated hour to share a key, got %s and %s", first, second)
+	}
+	if err := config.validateTimezone(); !errors.Is(err, ErrKeyTimezoneRepeat) {
+		t.Errorf("expected ErrKeyTimezoneRepeat, got %v", err)
+	}
+
+	// Ranges without a repeated hour, daily output, and keys in the slice
+	// zone (whose repeated hour is one slice) are accepted
+	config.StartDate, config.EndDate = "2024-11-04", "2024-11-30"
+	if err := config.validateTimezone(); err != nil {
+		t.Errorf("range after the transition: %v", err)
+	}
+	config.StartDate, config.EndDate = "2024-11-03", "2024-11-03"
+	config.OutputDuration = DurationDaily
+	if err := config.validateTimezone(); err != nil {
+		t.Errorf("daily output: %v", err)
+	}
+	config.OutputDuration = "6h"
+	if err := config.validateTimezone(); !errors.Is(err, ErrKeyTimezoneRepeat) {
+		t.Errorf("6h output: expected ErrKeyTimezoneRepeat, got %v", err)
+	}
+	config.Timezone = TableTimezone{Slice: newYork, Key: newYork}
+	if err := config.validateTimezone(); err != nil {
+		t.Errorf("keys in the slice zone: %v", err)
+	}
+}
+
+func TestTUIDiscoveryDateRangeAcrossSkippedMidnight(t *testing.T) {
+	santiago := loadTestLocation(t, "America/Santiago")
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	config := &Config{
+		Table:          "events",
+		OutputDuration: DurationDaily,
+		StartDate:      "2024-09-08",
+		EndDate:        "2024-09-08",
+		Timezone:       TableTimezone{Slice: santiago, Key: santiago},
+	}
+	archiver := NewArchiver(config, newTestLogger())
+	archiver.db = db
+	m := &progressModel{config: config, archiver: archiver, log: newLogPane(10)}
+
+	// The end date's day starts at 01:00, after the skipped midnight, and
+	// is kept as in plain discovery
+	mock.ExpectQuery(`SELECT tablename`).WithArgs("public", "events", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename"}).AddRow("events_20240907").AddRow("events_20240908"))
+	mock.ExpectQuery(`has_table_privilege`).WithArgs("events_20240908").
+		WillReturnRows(sqlmock.NewRows([]string{"has_table_privilege"}).AddRow(true))
+	mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events_20240908").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).AddRow("id", "bigint", "int8"))
+
+	msg, ok := m.doDiscover()().(discoveredTablesMsg)
+	if !ok || len(msg.tables) != 1 || msg.tables[0].name != "events_20240908" {
+		t.Fatalf("expected events_20240908 to be discovered, got %#v", msg)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestUTCSlicesKeyedLocally(t *testing.T) {
+	tokyo := loadTestLocation(t, "Asia/Tokyo")
+	archiver := NewArchiver(&Config{
+		Table:          "events",
+		OutputFormat:   "jsonl",
+		Compression:    "none",
+		OutputDuration: DurationDaily,
+		StartDate:      "2024-01-15",
+		EndDate:        "2024-01-16",
+		S3:             S3Config{PathTemplate: "{table}/{YYYY}/{MM}/{DD}"},
+		DateColumn:     "created_at",
+		Timezone:       TableTimezone{Key: tokyo},
+	}, newTestLogger())
+
+	partitions, err := archiver.buildDateRangePartition()
+	if err != nil || partitions[0].RangeStart.Location() != time.UTC {
+		t.Fatalf("buildDateRangePartition() = %v, %v", partitions, err)
+	}
+	key, err := archiver.sliceObjectKey(partitions[0].RangeStart)
+	if err != nil || key != "events/2024/01/15/events-2024-01-15.jsonl" {
+		t.Errorf("sliceObjectKey() = %q, %v", key, err)
+	}
+}
+
+func TestBuildTableConfigsTimezones(t *testing.T) {
+	newYork := loadTestLocation(t, "America/New_York")
+	base := newTestConfig()
+	base.Table = ""
+	base.Tables = []string{"flights", "alerts"}
+	base.TableConcurrency = 2
+	base.TableTimezones = map[string]TableTimezone{"alerts": {Slice: newYork, Key: time.UTC}}
+
+	configs, err := buildTableConfigs(base, nil, TableQuota{MaxParallelPartitions: 1})
+	if err != nil {
+		t.Fatalf("buildTableConfigs failed: %v", err)
+	}
+	for _, cfg := range configs {
+		if converts := cfg.Timezone.converts(); converts != (cfg.Table == "alerts") {
+			t.Errorf("table %s zones = %+v", cfg.Table, cfg.Timezone)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Static errors for template parsing
+var (
+	ErrTemplatePlaceholderUnknown = errors.New("unknown placeholder")
+	ErrTemplateBraceUnmatched     = errors.New("unmatched brace")
+)
+
+// Placeholders supported by each kind of template
+var (
+	pathTemplatePlaceholders      = []string{"table", "format", "compression", "YYYY", "MM", "DD", "HH", "WW", "Q", "yyyy", "mm", "dd", "hh", "ww", "q"}
+	partitionTemplatePlaceholders = []string{"table", "YYYY", "MM", "DD", "HH", "WW", "Q"}
+)
+
+// constantPlaceholders lists, per period, the date placeholders that render
+// the same value for every period start (e.g. {HH} is always 00 for daily)
+var constantPlaceholders = map[string][]string{
+	DurationDaily:     {"HH"},
+	DurationWeekly:    {"HH"},
+	DurationMonthly:   {"DD", "HH"},
+	DurationQuarterly: {"DD", "HH"},
+	DurationYearly:    {"Q", "MM", "DD", "HH"},
+}
+
+// requiredPartitionPlaceholders lists, per partition range, the placeholders a
+// partition name template needs to give every partition a distinct name
+var requiredPartitionPlaceholders = map[string][][]string{
+	"hourly":    {{"YYYY"}, {"MM"}, {"DD"}, {"HH"}},
+	"daily":     {{"YYYY"}, {"MM"}, {"DD"}},
+	"weekly":    {{"YYYY"}, {"WW", "DD"}},
+	"monthly":   {{"YYYY"}, {"MM"}},
+	"quarterly": {{"YYYY"}, {"Q", "MM"}},
+	"yearly":    {{"YYYY"}},
+}
+
+// parseTemplate returns the placeholders used in template, rejecting unknown
+// placeholders and unmatched braces. kind names the template in errors.
+func parseTemplate(kind, template string, allowed []string) ([]string, error) {
+	var placeholders []string
+	rest := template
+	for {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			return placeholders, nil
+		}
+		if rest[open] == '}' {
+			return nil, fmt.Errorf("%w '}' in %s '%s'", ErrTemplateBraceUnmatched, kind, template)
+		}
+		end := strings.IndexAny(rest[open+1:], "{}")
+		if end < 0 || rest[open+1+end] == '{' {
+			return nil, fmt.Errorf("%w '{' in %s '%s'", ErrTemplateBraceUnmatched, kind, template)
+		}
+
+		name := rest[open+1 : open+1+end]
+		if !containsString(allowed, name) {
+			err := fmt.Errorf("%w {%s} in %s '%s' (supported: %s)", ErrTemplatePlaceholderUnknown, name, kind, template, formatPlaceholders(allowed, ", "))
+			if suggestion := suggestPlaceholder(name, allowed); suggestion != "" {
+				err = fmt.Errorf("%w; did you mean {%s}?", err, suggestion)
+			}
+			return nil, err
+		}
+		placeholders = append(placeholders, name)
+		rest = rest[open+1+end+1:]
+	}
+}
+
+// unusedPlaceholders returns the placeholders in placeholders that render a
+// constant for period, so they add nothing to the generated names
+func unusedPlaceholders(placeholders []string, period string) []string {
+	var unused []string
+	for _, name := range constantPlaceholders[period] {
+		if containsString(placeholders, name) {
+			unused = append(unused, name)
+		}
+	}
+	return unused
+}
+
+// pathTemplateWarnings reports placeholders in the path template that are the
+// same for every output file of the configured --output-duration
+func (c *Config) pathTemplateWarnings() []string {
+	// An auto duration is checked once it is resolved from the partitions
+	if c.OutputDuration == "" || c.OutputDuration == DurationAuto || c.S3.PathTemplate == "" {
+		return nil
+	}
+	placeholders, err := parseTemplate("path template", canonicalDatePlaceholders(c.S3.PathTemplate), pathTemplatePlaceholders)
+	if err != nil {
+		return nil
+	}
+	var warnings []string
+	for _, name := range unusedPlaceholders(placeholders, keyedDuration(c.OutputDuration)) {
+		warnings = append(warnings, fmt.Sprintf("path template placeholder {%s} is the same for every %s output file", name, c.OutputDuration))
+	}
+	return warnings
+}
+
+// partitionTemplateWarnings reports placeholders in a partition name template
+// that are constant for the partition range, and missing ones that would give
+// several partitions the same name
+func partitionTemplateWarnings(template, partitionRange string) []string {
+	if template == "" || partitionRange == "" {
+		return nil
+	}
+	placeholders, err := parseTemplate("partition template", template, partitionTemplatePlaceholders)
+	if err != nil {
+		return nil
+	}
+
+	var warnings []string
+	for _, name := range unusedPlaceholders(placeholders, partitionRange) {
+		warnings = append(warnings, fmt.Sprintf("partition template placeholder {%s} is the same for every %s partition", name, partitionRange))
+	}
+	for _, options := range requiredPartitionPlaceholders[partitionRange] {
+		found := false
+		for _, name := range options {
+			found = found || containsString(placeholders, name)
+		}
+		if !found {
+			warnings = append(warnings, fmt.Sprintf("partition template has no %s placeholder, so %s partitions will share names", formatPlaceholders(options, " or "), partitionRange))
+		}
+	}
+	return warnings
+}
+
+// suggestPlaceholder returns the supported placeholder closest to name, or ""
+// when none is close enough to be a likely typo
+func suggestPlaceholder(name string, allowed []string) string {
+	best, bestDistance := "", 3
+	for _, candidate := range allowed {
+		if strings.EqualFold(name, candidate) {
+			return candidate
+		}
+		// Allow fewer edits for short names, so {Q} doesn't suggest {MM}
+		d := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if d < bestDistance && d <= max(len(candidate)/2, 1) {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// formatPlaceholders renders placeholder names in braces, joined by sep
+func formatPlaceholders(names []string, sep string) string {
+	formatted := make([]string, len(names))
+	for i, name := range names {
+		formatted[i] = "{" + name + "}"
+	}
+	return strings.Join(formatted, sep)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
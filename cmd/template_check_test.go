@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTemplate(t *testing.T) {
+	tests := []struct {
+		name        string
+		template    string
+		want        []string
+		wantErr     error
+		wantSuggest string
+	}{
+		{"Valid", "archives/{table}/{YYYY}/{MM}", []string{"table", "YYYY", "MM"}, nil, ""},
+		{"NoPlaceholders", "archives/static", nil, nil, ""},
+		{"Typo", "archives/{table}/{YYY}/{MM}", nil, ErrTemplatePlaceholderUnknown, "{YYYY}"},
+		{"WrongCase", "archives/{Table}/{YYYY}", nil, ErrTemplatePlaceholderUnknown, "{table}"},
+		{"LowercaseDate", "archives/{table}/{yyyy}", []string{"table", "yyyy"}, nil, ""},
+		{"MixedCaseDate", "archives/{table}/{Yyyy}", nil, ErrTemplatePlaceholderUnknown, "{YYYY}"},
+		{"HiveStyle", "archives/{table}/format={format}/dt={YYYY}-{MM}-{DD}", []string{"table", "format", "YYYY", "MM", "DD"}, nil, ""},
+		{"Compression", "archives/{table}/{compression}/{yyyy}/{mm}", []string{"table", "compression", "yyyy", "mm"}, nil, ""},
+		{"Unrelated", "archives/{table}/{region}", nil, ErrTemplatePlaceholderUnknown, ""},
+		{"WeekAndQuarter", "archives/{table}/{YYYY}/Q{Q}/W{WW}", []string{"table", "YYYY", "Q", "WW"}, nil, ""},
+		{"UnclosedBrace", "archives/{table/{YYYY}", nil, ErrTemplateBraceUnmatched, ""},
+		{"StrayClose", "archives/table}/{YYYY}", nil, ErrTemplateBraceUnmatched, ""},
+		{"TrailingOpen", "archives/{table}/{", nil, ErrTemplateBraceUnmatched, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTemplate("path template", tt.template, pathTemplatePlaceholders)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseTemplate() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				hasSuggestion := strings.Contains(err.Error(), "did you mean")
+				if tt.wantSuggest == "" && hasSuggestion {
+					t.Errorf("unexpected suggestion in %q", err)
+				}
+				if tt.wantSuggest != "" && !strings.Contains(err.Error(), "did you mean "+tt.wantSuggest) {
+					t.Errorf("error %q should suggest %s", err, tt.wantSuggest)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTemplate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigValidateRejectsUnknownPlaceholder(t *testing.T) {
+	config := newTestConfig()
+	config.S3.PathTemplate = "archives/{table}/{YYYY}/{MN}"
+	err := config.Validate()
+	if !errors.Is(err, ErrTemplatePlaceholderUnknown) {
+		t.Fatalf("expected ErrTemplatePlaceholderUnknown, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "did you mean {MM}") {
+		t.Errorf("expected a {MM} suggestion, got %q", err)
+	}
+}
+
+func TestPathTemplateWarnings(t *testing.T) {
+	config := newTestConfig()
+	config.S3.PathTemplate = "archives/{table}/{YYYY}/{MM}/{DD}/{HH}"
+
+	config.OutputDuration = DurationHourly
+	if warnings := config.pathTemplateWarnings(); len(warnings) != 0 {
+		t.Errorf("hourly: unexpected warnings %v", warnings)
+	}
+
+	config.OutputDuration = DurationMonthly
+	warnings := config.pathTemplateWarnings()
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "{DD}") || !strings.Contains(warnings[1], "{HH}") {
+		t.Errorf("monthly: warnings = %v, want {DD} and {HH}", warnings)
+	}
+}
+
+func TestHiveStylePathTemplate(t *testing.T) {
+	date := time.Date(2024, 3, 5, 7, 0, 0, 0, time.UTC)
+	path := NewPathTemplate("{table}/format={format}/codec={compression}/dt={yyyy}-{mm}-{dd}/hour={hh}").
+		WithOutput("parquet", "zstd").
+		Generate("events", date)
+	if path != "events/format=parquet/codec=zstd/dt=2024-03-05/hour=07" {
+		t.Errorf("Generate() = %q", path)
+	}
+
+	pattern := regexp.MustCompile("^" + templateDatePattern(regexp.QuoteMeta("events/format={format}/dt={yyyy}-{MM}-{dd}")) + "$")
+	if !pattern.MatchString("events/format=parquet/dt=2024-03-05") || pattern.MatchString("events/format=parquet/dt=2024-03") {
+		t.Errorf("templateDatePattern() = %s", pattern)
+	}
+	if got := stripDatePlaceholders("archives/{table}/{format}/{yyyy}/{mm}"); got != "archives/{table}" {
+		t.Errorf("stripDatePlaceholders() = %q", got)
+	}
+
+	// Aliases are checked like the placeholders they stand for
+	config := newTestConfig()
+	config.S3.PathTemplate = "archives/{table}/dt={yyyy}-{mm}-{dd}"
+	config.OutputDuration = DurationMonthly
+	if warnings := config.pathTemplateWarnings(); len(warnings) != 1 || !strings.Contains(warnings[0], "{DD}") {
+		t.Errorf("monthly: warnings = %v, want {DD}", warnings)
+	}
+}
+
+func TestPartitionTemplateWarnings(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		rng      string
+		want     []string
+	}{
+		{"Complete", "{table}_{YYYY}{MM}{DD}", "daily", nil},
+		{"QuarterByQ", "{table}_{YYYY}q{Q}", "quarterly", nil},
+		{"QuarterByMonth", "{table}_{YYYY}_{MM}", "quarterly", nil},
+		{"MissingHour", "{table}_{YYYY}{MM}{DD}", "hourly", []string{"no {HH}"}},
+		{"ConstantHour", "{table}_{YYYY}{MM}{DD}{HH}", "daily", []string{"{HH} is the same"}},
+		{"MissingQuarter", "{table}_{YYYY}", "quarterly", []string{"no {Q} or {MM}"}},
+		{"NoTemplate", "", "daily", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := partitionTemplateWarnings(tt.template, tt.rng)
+			if len(got) != len(tt.want) {
+				t.Fatalf("warnings = %v, want %d", got, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("warning %q should contain %q", got[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,397 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// defaultUsagePrefix is where usage ledgers are stored in the bucket
+const defaultUsagePrefix = "_data-archiver/usage"
+
+// usageMonthLayout keys ledger months (UTC calendar months)
+const usageMonthLayout = "2006-01"
+
+// Static errors for usage accounting
+var (
+	ErrUsagePrefixRequired      = errors.New("usage ledger prefix is required")
+	ErrUsageOutputFormatInvalid = errors.New("usage output format must be one of: text, json")
+	ErrUsageMonthsInvalid       = errors.New("usage months must be >= 0")
+	ErrUsageLedgerTableMismatch = errors.New("usage ledger belongs to another table")
+)
+
+var (
+	usageLedgerEnabled bool
+	usagePrefix        string
+	usageTable         string
+	usageMonths        int
+	usageOutputFormat  string
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report archived storage per table and month",
+	Long: `Report the bytes and objects uploaded per table and calendar month, read from the usage
+ledgers that archive runs with --usage-ledger keep in the bucket. Shows each month's growth and
+the running total for capacity planning and chargeback.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		runUsage(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(usageCmd)
+
+	archiveCmd.Flags().BoolVar(&usageLedgerEnabled, "usage-ledger", false, "record bytes and objects uploaded per calendar month in a usage ledger in the bucket")
+	archiveCmd.Flags().StringVar(&usagePrefix, "usage-prefix", defaultUsagePrefix, "bucket prefix for usage ledgers")
+	_ = viper.BindPFlag("usage.enabled", archiveCmd.Flags().Lookup("usage-ledger"))
+	_ = viper.BindPFlag("usage.prefix", archiveCmd.Flags().Lookup("usage-prefix"))
+
+	// S3 flags
+	usageCmd.Flags().StringVar(&s3Endpoint, "s3-endpoint", "", "S3-compatible endpoint URL")
+	usageCmd.Flags().StringVar(&s3Bucket, "s3-bucket", "", "S3 bucket name")
+	usageCmd.Flags().StringVar(&s3AccessKey, "s3-access-key", "", "S3 access key")
+	usageCmd.Flags().StringVar(&s3SecretKey, "s3-secret-key", "", "S3 secret key")
+	usageCmd.Flags().StringVar(&s3Region, "s3-region", "auto", "S3 region")
+
+	// Usage-specific flags
+	usageCmd.Flags().StringVar(&usagePrefix, "usage-prefix", defaultUsagePrefix, "bucket prefix for usage ledgers")
+	usageCmd.Flags().StringVar(&usageTable, "table", "", "only report this table")
+	usageCmd.Flags().IntVar(&usageMonths, "months", 12, "number of most recent months to show (0 = all)")
+	usageCmd.Flags().StringVar(&usageOutputFormat, "output-format", "text", "Output format: text, json")
+}
+
+// UsageMonth is the storage a table gained in one calendar month
+type UsageMonth struct {
+	Bytes             int64 `json:"bytes"` // Compressed bytes uploaded
+	UncompressedBytes int64 `json:"uncompressed_bytes"`
+	Objects           int64 `json:"objects"`
+	Rows              int64 `json:"rows"`
+}
+
+func (m *UsageMonth) add(other UsageMonth) {
+	m.Bytes += other.Bytes
+	m.UncompressedBytes += other.UncompressedBytes
+	m.Objects += other.Objects
+	m.Rows += other.Rows
+}
+
+// UsageLedger is a table's uploads aggregated by the calendar month (UTC) they
+// happened in. Re-uploaded files count again, since each upload is billed.
+type UsageLedger struct {
+	Table     string                `json:"table"`
+	Months    map[string]UsageMonth `json:"months"` // By YYYY-MM
+	UpdatedAt time.Time             `json:"updated_at"`
+}
+
+// usageTally collects an archiver's uploads until they are added to the ledger
+type usageTally struct {
+	mu     sync.Mutex
+	months map[string]UsageMonth
+}
+
+func newUsageTally() *usageTally {
+	return &usageTally{months: make(map[string]UsageMonth)}
+}
+
+// add records one uploaded object; safe to call on a nil tally
+func (t *usageTally) add(uploadedAt time.Time, size, uncompressedSize, rows int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	month := uploadedAt.UTC().Format(usageMonthLayout)
+	usage := t.months[month]
+	usage.add(UsageMonth{Bytes: size, UncompressedBytes: uncompressedSize, Objects: 1, Rows: rows})
+	t.months[month] = usage
+}
+
+// take returns the tallied months and resets the tally
+func (t *usageTally) take() map[string]UsageMonth {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	months := t.months
+	t.months = make(map[string]UsageMonth)
+	return months
+}
+
+// usageLedgerKey returns the object key of a table's usage ledger
+func usageLedgerKey(prefix, table string) string {
+	return path.Join(strings.Trim(prefix, "/"), objectKeyComponent(table)+".json")
+}
+
+// loadUsageLedger reads a table's ledger, or returns an empty one if none exists yet
+func loadUsageLedger(ctx context.Context, client StorageBackend, bucket, key, table string) (*UsageLedger, error) {
+	ledger := &UsageLedger{Table: table, Months: make(map[string]UsageMonth)}
+
+	body, _, err := client.Get(ctx, bucket, key, GetOptions{})
+	if err != nil {
+		if isObjectNotFound(err) {
+			return ledger, nil
+		}
+		return nil, fmt.Errorf("failed to read usage ledger %s: %w", key, err)
+	}
+	defer body.Close()
+
+	if err := json.NewDecoder(body).Decode(ledger); err != nil {
+		return nil, fmt.Errorf("failed to parse usage ledger %s: %w", key, err)
+	}
+	if ledger.Table != table {
+		return nil, fmt.Errorf("%w: %s holds '%s'", ErrUsageLedgerTableMismatch, key, ledger.Table)
+	}
+	if ledger.Months == nil {
+		ledger.Months = make(map[string]UsageMonth)
+	}
+	return ledger, nil
+}
+
+// addToUsageLedger merges months into a table's ledger. Concurrent runs for
+// the same table can lose each other's update, so run one archiver per table.
+func addToUsageLedger(ctx context.Context, client StorageBackend, bucket, key, table string, months map[string]UsageMonth) error {
+	ledger, err := loadUsageLedger(ctx, client, bucket, key, table)
+	if err != nil {
+		return err
+	}
+	for month, usage := range months {
+		total := ledger.Months[month]
+		total.add(usage)
+		ledger.Months[month] = total
+	}
+	ledger.UpdatedAt = time.Now().UTC()
+
+	data, err := json.MarshalIndent(ledger, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode usage ledger: %w", err)
+	}
+	_, err = client.Put(ctx, bucket, key, bytes.NewReader(data), PutOptions{ContentType: "application/json"})
+	if err != nil {
+		return fmt.Errorf("failed to write usage ledger %s: %w", key, err)
+	}
+	return nil
+}
+
+// flushUsageLedger adds the run's uploads to the table's usage ledger. A
+// failure is logged; the archived files themselves are unaffected.
+func (a *Archiver) flushUsageLedger() {
+	months := a.usage.take()
+	if len(months) == 0 || a.s3Client == nil {
+		return
+	}
+
+	// Record the run even if it was cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	key := usageLedgerKey(a.config.UsagePrefix, a.config.Table)
+	if err := addToUsageLedger(ctx, a.s3Client, a.config.S3.Bucket, key, a.config.Table, months); err != nil {
+		a.logger.Warn(fmt.Sprintf("⚠️  Usage ledger not updated: %v", err))
+		return
+	}
+	a.logger.Debug(fmt.Sprintf("Updated usage ledger s3://%s/%s", a.config.S3.Bucket, key))
+}
+
+// listUsageLedgers reads every ledger under prefix, sorted by table
+func listUsageLedgers(ctx context.Context, client StorageBackend, bucket, prefix string) ([]*UsageLedger, error) {
+	var keys []string
+	err := client.List(ctx, bucket, strings.Trim(prefix, "/")+"/", func(object ObjectInfo) bool {
+		if strings.HasSuffix(object.Key, ".json") {
+			keys = append(keys, object.Key)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list usage ledgers: %w", err)
+	}
+
+	ledgers := make([]*UsageLedger, 0, len(keys))
+	for _, key := range keys {
+		body, _, err := client.Get(ctx, bucket, key, GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read usage ledger %s: %w", key, err)
+		}
+		var ledger UsageLedger
+		err = json.NewDecoder(body).Decode(&ledger)
+		body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse usage ledger %s: %w", key, err)
+		}
+		ledgers = append(ledgers, &ledger)
+	}
+	sort.Slice(ledgers, func(i, j int) bool { return ledgers[i].Table < ledgers[j].Table })
+	return ledgers, nil
+}
+
+// UsageReportMonth is one month of a table's usage report
+type UsageReportMonth struct {
+	Month string `json:"month"`
+	UsageMonth
+	TotalBytes int64 `json:"total_bytes"` // All bytes uploaded up to and including this month
+}
+
+// UsageReport summarizes a table's storage growth
+type UsageReport struct {
+	Table           string             `json:"table"`
+	TotalBytes      int64              `json:"total_bytes"`
+	TotalObjects    int64              `json:"total_objects"`
+	AvgMonthlyBytes int64              `json:"avg_monthly_bytes"` // Average over the months shown
+	Months          []UsageReportMonth `json:"months"`
+}
+
+// buildUsageReport turns a ledger into a report of its most recent months
+// (0 = all). Totals cover every month in the ledger.
+func buildUsageReport(ledger *UsageLedger, recentMonths int) UsageReport {
+	report := UsageReport{Table: ledger.Table, Months: []UsageReportMonth{}}
+
+	months := make([]string, 0, len(ledger.Months))
+	for month := range ledger.Months {
+		months = append(months, month)
+	}
+	sort.Strings(months)
+
+	all := make([]UsageReportMonth, 0, len(months))
+	for _, month := range months {
+		usage := ledger.Months[month]
+		report.TotalBytes += usage.Bytes
+		report.TotalObjects += usage.Objects
+		all = append(all, UsageReportMonth{Month: month, UsageMonth: usage, TotalBytes: report.TotalBytes})
+	}
+	if recentMonths > 0 && len(all) > recentMonths {
+		all = all[len(all)-recentMonths:]
+	}
+	report.Months = append(report.Months, all...)
+
+	if len(report.Months) > 0 {
+		var shown int64
+		for _, month := range report.Months {
+			shown += month.Bytes
+		}
+		report.AvgMonthlyBytes = shown / int64(len(report.Months))
+	}
+	return report
+}
+
+// writeUsageText renders each table's months with their growth and running total
+func writeUsageText(w io.Writer, reports []UsageReport) {
+	if len(reports) == 0 {
+		fmt.Fprintln(w, "No usage ledgers found")
+		return
+	}
+
+	for i, report := range reports {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s: %s in %d objects (avg %s/month)\n",
+			report.Table, formatBytes(report.TotalBytes), report.TotalObjects, formatBytes(report.AvgMonthlyBytes))
+		fmt.Fprintf(w, "  %-8s %8s %12s %12s %14s %12s %8s\n",
+			"MONTH", "OBJECTS", "BYTES", "RAW BYTES", "ROWS", "TOTAL", "GROWTH")
+		for _, month := range report.Months {
+			growth := "-"
+			if previous := month.TotalBytes - month.Bytes; previous > 0 {
+				growth = fmt.Sprintf("%+.1f%%", float64(month.Bytes)/float64(previous)*100)
+			}
+			fmt.Fprintf(w, "  %-8s %8d %12s %12s %14d %12s %8s\n",
+				month.Month, month.Objects, formatBytes(month.Bytes), formatBytes(month.UncompressedBytes),
+				month.Rows, formatBytes(month.TotalBytes), growth)
+		}
+	}
+}
+
+func runUsage(cmd *cobra.Command) {
+	getStringConfig := func(flagValue string, flagName string, viperKey string) string {
+		if flag := cmd.Flags().Lookup(flagName); flag != nil && flag.Changed {
+			return flagValue
+		}
+		if viperValue := viper.GetString(viperKey); viperValue != "" {
+			return viperValue
+		}
+		return flagValue
+	}
+
+	s3Config, s3Err := newS3Config(S3Config{
+		Endpoint:  getStringConfig(s3Endpoint, "s3-endpoint", "s3.endpoint"),
+		Bucket:    getStringConfig(s3Bucket, "s3-bucket", "s3.bucket"),
+		AccessKey: getStringConfig(s3AccessKey, "s3-access-key", "s3.access_key"),
+		SecretKey: getStringConfig(s3SecretKey, "s3-secret-key", "s3.secret_key"),
+		Region:    getStringConfig(s3Region, "s3-region", "s3.region"),
+	}, endpointProfileName("usage"))
+	prefix := getStringConfig(usagePrefix, "usage-prefix", "usage.prefix")
+
+	initLogger(viper.GetBool("debug"), viper.GetString("log_format"))
+
+	if s3Err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", s3Err.Error()))
+		os.Exit(1)
+	}
+	if err := validateUsageConfig(s3Config, prefix); err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	ctx := signalContext
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	client, err := newStorageClient(s3Config)
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ Failed to create storage client: %v", err))
+		os.Exit(1)
+	}
+	ledgers, err := listUsageLedgers(ctx, client, s3Config.Bucket, prefix)
+	if err != nil {
+		logger.Error(fmt.Sprintf("❌ %v", err))
+		os.Exit(1)
+	}
+
+	reports := []UsageReport{}
+	for _, ledger := range ledgers {
+		if usageTable == "" || ledger.Table == usageTable {
+			reports = append(reports, buildUsageReport(ledger, usageMonths))
+		}
+	}
+
+	if usageOutputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(reports)
+		return
+	}
+	writeUsageText(os.Stdout, reports)
+}
+
+// validateUsageConfig checks the S3 settings and report options
+func validateUsageConfig(s3Config S3Config, prefix string) error {
+	if s3Config.Bucket == "" {
+		return ErrS3BucketRequired
+	}
+	if err := s3Config.validateCredentials(); err != nil {
+		return err
+	}
+	if strings.Trim(prefix, "/") == "" {
+		return ErrUsagePrefixRequired
+	}
+	if usageMonths < 0 {
+		return fmt.Errorf("%w, got %d", ErrUsageMonthsInvalid, usageMonths)
+	}
+	if usageOutputFormat != "text" && usageOutputFormat != "json" {
+		return fmt.Errorf("%w: '%s'", ErrUsageOutputFormatInvalid, usageOutputFormat)
+	}
+	return s3Config.HTTP.Validate()
+}
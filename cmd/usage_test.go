@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUsageLedgerAccumulates(t *testing.T) {
+	store := &fakeObjectStore{objects: map[string][]byte{}}
+	ctx := context.Background()
+	key := usageLedgerKey("/"+defaultUsagePrefix+"/", "Flights")
+	if key != "_data-archiver/usage/Flights.json" {
+		t.Fatalf("usageLedgerKey() = %s", key)
+	}
+
+	tally := newUsageTally()
+	tally.add(time.Date(2024, 1, 31, 23, 0, 0, 0, time.UTC), 100, 1000, 10)
+	tally.add(time.Date(2024, 2, 1, 1, 0, 0, 0, time.UTC), 50, 500, 5)
+	tally.add(time.Date(2024, 2, 2, 1, 0, 0, 0, time.UTC), 50, 500, 5)
+	if err := addToUsageLedger(ctx, newS3ObjectStore(store), "bucket", key, "Flights", tally.take()); err != nil {
+		t.Fatalf("addToUsageLedger() error = %v", err)
+	}
+	if len(tally.take()) != 0 {
+		t.Error("take() should reset the tally")
+	}
+
+	// A second run adds to the existing months
+	second := map[string]UsageMonth{"2024-02": {Bytes: 200, UncompressedBytes: 2000, Objects: 1, Rows: 20}}
+	if err := addToUsageLedger(ctx, newS3ObjectStore(store), "bucket", key, "Flights", second); err != nil {
+		t.Fatalf("addToUsageLedger() error = %v", err)
+	}
+
+	ledger, err := loadUsageLedger(ctx, newS3ObjectStore(store), "bucket", key, "Flights")
+	if err != nil {
+		t.Fatalf("loadUsageLedger() error = %v", err)
+	}
+	if got := ledger.Months["2024-01"]; got != (UsageMonth{Bytes: 100, UncompressedBytes: 1000, Objects: 1, Rows: 10}) {
+		t.Errorf("2024-01 = %+v", got)
+	}
+	if got := ledger.Months["2024-02"]; got != (UsageMonth{Bytes: 300, UncompressedBytes: 3000, Objects: 3, Rows: 30}) {
+		t.Errorf("2024-02 = %+v", got)
+	}
+
+	if _, err := loadUsageLedger(ctx, newS3ObjectStore(store), "bucket", key, "flights"); !errors.Is(err, ErrUsageLedgerTableMismatch) {
+		t.Errorf("expected ErrUsageLedgerTableMismatch, got %v", err)
+	}
+}
+
+func TestBuildUsageReport(t *testing.T) {
+	ledger := &UsageLedger{Table: "flights", Months: map[string]UsageMonth{
+		"2024-03": {Bytes: 300, Objects: 3},
+		"2024-01": {Bytes: 100, Objects: 1},
+		"2024-02": {Bytes: 100, Objects: 1},
+	}}
+
+	report := buildUsageReport(ledger, 2)
+	if report.TotalBytes != 500 || report.TotalObjects != 5 {
+		t.Errorf("totals = %d bytes, %d objects", report.TotalBytes, report.TotalObjects)
+	}
+	if len(report.Months) != 2 || report.Months[0].Month != "2024-02" || report.Months[1].TotalBytes != 500 {
+		t.Errorf("months = %+v", report.Months)
+	}
+	if report.AvgMonthlyBytes != 200 {
+		t.Errorf("AvgMonthlyBytes = %d, want 200", report.AvgMonthlyBytes)
+	}
+
+	var out bytes.Buffer
+	writeUsageText(&out, []UsageReport{report})
+	if !strings.Contains(out.String(), "2024-03") || !strings.Contains(out.String(), "+150.0%") {
+		t.Errorf("unexpected text report:\n%s", out.String())
+	}
+}
+
+func TestListUsageLedgers(t *testing.T) {
+	store := &fakeObjectStore{objects: map[string][]byte{}}
+	ctx := context.Background()
+	for _, table := range []string{"messages", "flights"} {
+		months := map[string]UsageMonth{"2024-01": {Bytes: 1, Objects: 1}}
+		if err := addToUsageLedger(ctx, newS3ObjectStore(store), "bucket", usageLedgerKey(defaultUsagePrefix, table), table, months); err != nil {
+			t.Fatalf("addToUsageLedger() error = %v", err)
+		}
+	}
+	store.objects["flights/2024/01/flights-2024-01-01.jsonl.zst"] = []byte("data")
+
+	ledgers, err := listUsageLedgers(ctx, newS3ObjectStore(store), "bucket", defaultUsagePrefix)
+	if err != nil {
+		t.Fatalf("listUsageLedgers() error = %v", err)
+	}
+	if len(ledgers) != 2 || ledgers[0].Table != "flights" || ledgers[1].Table != "messages" {
+		t.Errorf("unexpected ledgers: %+v", ledgers)
+	}
+}
+
+func TestUsageLedgerRequiresPrefix(t *testing.T) {
+	config := newTestConfig()
+	config.UsageLedger = true
+	config.UsagePrefix = "/"
+	if err := config.Validate(); !errors.Is(err, ErrUsagePrefixRequired) {
+		t.Errorf("Validate() = %v, want ErrUsagePrefixRequired", err)
+	}
+}
@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+)
+
+// ErrInvalidValuesPolicy is returned for an unknown --invalid-values policy
+var ErrInvalidValuesPolicy = errors.New("invalid values policy must be one of: off, replace, quarantine")
+
+// Policies for values that formatters cannot encode
+const (
+	InvalidValuesOff        = "off"        // Write values as-is (a NaN fails the file)
+	InvalidValuesReplace    = "replace"    // Replace invalid UTF-8 bytes with U+FFFD and write NaN/Inf as null
+	InvalidValuesQuarantine = "quarantine" // Move rows holding invalid values to a quarantine file
+)
+
+var (
+	invalidValues string
+	quarantineDir string
+)
+
+func init() {
+	archiveCmd.Flags().StringVar(&invalidValues, "invalid-values", InvalidValuesOff, "handling of invalid UTF-8 strings and NaN/Inf floats: off, replace (U+FFFD and null), quarantine (move the row to a side file)")
+	archiveCmd.Flags().StringVar(&quarantineDir, "quarantine-dir", "", "directory for rows quarantined by --invalid-values quarantine (default: ~/.data-archiver/quarantine)")
+	_ = viper.BindPFlag("invalid_values", archiveCmd.Flags().Lookup("invalid-values"))
+	_ = viper.BindPFlag("quarantine_dir", archiveCmd.Flags().Lookup("quarantine-dir"))
+}
+
+// validateInvalidValuesPolicy checks an --invalid-values policy ("" = off)
+func validateInvalidValuesPolicy(policy string) error {
+	switch policy {
+	case "", InvalidValuesOff, InvalidValuesReplace, InvalidValuesQuarantine:
+		return nil
+	default:
+		return fmt.Errorf("%w, got '%s'", ErrInvalidValuesPolicy, policy)
+	}
+}
+
+// getQuarantineDir returns the default directory for quarantined rows
+func getQuarantineDir() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".data-archiver", "quarantine")
+}
+
+// valueIssueCounts tallies the values and rows changed by --invalid-values
+type valueIssueCounts struct {
+	InvalidUTF8 int64 // Strings whose invalid bytes were replaced
+	NonFinite   int64 // NaN/Inf floats written as null
+	Quarantined int64 // Rows moved to the quarantine file
+}
+
+// add accumulates other into c
+func (c *valueIssueCounts) add(other valueIssueCounts) {
+	c.InvalidUTF8 += other.InvalidUTF8
+	c.NonFinite += other.NonFinite
+	c.Quarantined += other.Quarantined
+}
+
+// any reports whether any value or row was changed
+func (c valueIssueCounts) any() bool {
+	return c != valueIssueCounts{}
+}
+
+func (c valueIssueCounts) String() string {
+	var parts []string
+	if c.InvalidUTF8 > 0 {
+		parts = append(parts, fmt.Sprintf("%d invalid UTF-8 value(s) replaced", c.InvalidUTF8))
+	}
+	if c.NonFinite > 0 {
+		parts = append(parts, fmt.Sprintf("%d NaN/Inf value(s) written as null", c.NonFinite))
+	}
+	if c.Quarantined > 0 {
+		parts = append(parts, fmt.Sprintf("%d row(s) quarantined", c.Quarantined))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// quarantineRecord is one line of a quarantine file
+type quarantineRecord struct {
+	Partition string                 `json:"partition"`
+	Problems  []string               `json:"problems"`      // "column: problem", sorted by column
+	Row       map[string]interface{} `json:"row"`           // Invalid bytes replaced, NaN/Inf as strings
+	Raw       map[string][]byte      `json:"raw,omitempty"` // Original bytes of invalid strings (base64)
+}
+
+// valueSanitizer applies the --invalid-values policy to the rows of one
+// output file before they reach the formatter
+type valueSanitizer struct {
+	policy    string
+	partition string
+	path      string // Quarantine file, created on the first quarantined row
+	file      *os.File
+	encoder   *json.Encoder
+	counts    valueIssueCounts
+}
+
+// newValueSanitizer returns the sanitizer for one partition or slice, or nil
+// when --invalid-values is off
+func (a *Archiver) newValueSanitizer(partition PartitionInfo, startTime time.Time) *valueSanitizer {
+	policy := a.config.InvalidValues
+	if policy == "" || policy == InvalidValuesOff {
+		return nil
+	}
+
+	dir := a.config.QuarantineDir
+	if dir == "" {
+		dir = getQuarantineDir()
+	}
+	name := partition.TableName
+	if !startTime.IsZero() {
+		name += "_" + startTime.UTC().Format("20060102T150405Z")
+	}
+	return &valueSanitizer{
+		policy:    policy,
+		partition: partition.TableName,
+		path:      filepath.Join(dir, sanitizeCacheComponent(a.config.Table, "global"), name+".jsonl"),
+	}
+}
+
+// valueProblem describes why value cannot be encoded, or returns "" if it can
+func valueProblem(value interface{}) string {
+	var f float64
+	switch v := value.(type) {
+	case string:
+		if !utf8.ValidString(v) {
+			return "invalid UTF-8"
+		}
+		return ""
+	case float64:
+		f = v
+	case float32:
+		f = float64(v)
+	default:
+		return ""
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return ""
+}
+
+// sanitize fixes row in place, or quarantines it. It returns false when the
+// row was quarantined and must not be written to the archive.
+func (s *valueSanitizer) sanitize(row map[string]interface{}) (bool, error) {
+	if s == nil {
+		return true, nil
+	}
+	var columns []string
+	for column, value := range row {
+		if valueProblem(value) != "" {
+			columns = append(columns, column)
+		}
+	}
+	if len(columns) == 0 {
+		return true, nil
+	}
+	sort.Strings(columns)
+
+	if s.policy == InvalidValuesQuarantine {
+		return false, s.quarantine(row, columns)
+	}
+	for _, column := range columns {
+		switch v := row[column].(type) {
+		case string:
+			row[column] = strings.ToValidUTF8(v, "\uFFFD")
+			s.counts.InvalidUTF8++
+		default:
+			row[column] = nil
+			s.counts.NonFinite++
+		}
+	}
+	return true, nil
+}
+
+// quarantine appends row to the quarantine file with its problem columns
+func (s *valueSanitizer) quarantine(row map[string]interface{}, columns []string) error {
+	record := quarantineRecord{Partition: s.partition, Row: make(map[string]interface{}, len(row))}
+	for column, value := range row {
+		record.Row[column] = value
+	}
+	for _, column := range columns {
+		problem := valueProblem(row[column])
+		record.Problems = append(record.Problems, fmt.Sprintf("%s: %s", column, problem))
+		if v, ok := row[column].(string); ok {
+			if record.Raw == nil {
+				record.Raw = make(map[string][]byte)
+			}
+			record.Raw[column] = []byte(v)
+			record.Row[column] = strings.ToValidUTF8(v, "\uFFFD")
+		} else {
+			record.Row[column] = problem
+		}
+	}
+
+	if s.file == nil {
+		if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+			return fmt.Errorf("failed to create quarantine directory: %w", err)
+		}
+		file, err := os.Create(s.path)
+		if err != nil {
+			return fmt.Errorf("failed to create quarantine file: %w", err)
+		}
+		s.file = file
+		s.encoder = json.NewEncoder(file)
+	}
+	if err := s.encoder.Encode(record); err != nil {
+		return fmt.Errorf("failed to write quarantine file: %w", err)
+	}
+	s.counts.Quarantined++
+	return nil
+}
+
+// close closes the quarantine file, if one was written
+func (s *valueSanitizer) close() error {
+	if s == nil || s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestValidateInvalidValuesPolicy(t *testing.T) {
+	for _, policy := range []string{"", InvalidValuesOff, InvalidValuesReplace, InvalidValuesQuarantine} {
+		if err := validateInvalidValuesPolicy(policy); err != nil {
+			t.Errorf("validateInvalidValuesPolicy(%q) = %v", policy, err)
+		}
+	}
+	if err := validateInvalidValuesPolicy("drop"); !errors.Is(err, ErrInvalidValuesPolicy) {
+		t.Errorf("expected ErrInvalidValuesPolicy, got %v", err)
+	}
+}
+
+func TestValueSanitizerReplace(t *testing.T) {
+	archiver := NewArchiver(&Config{Table: "events", InvalidValues: InvalidValuesReplace}, newTestLogger())
+	sanitizer := archiver.newValueSanitizer(PartitionInfo{TableName: "events_20240101"}, time.Time{})
+
+	row := map[string]interface{}{
+		"name":  "caf\xe9",
+		"score": math.NaN(),
+		"ratio": float32(math.Inf(1)),
+		"ok":    "fine",
+		"raw":   []byte{0xff},
+	}
+	keep, err := sanitizer.sanitize(row)
+	if err != nil || !keep {
+		t.Fatalf("sanitize() = %v, %v", keep, err)
+	}
+	if row["name"] != "caf\uFFFD" || row["score"] != nil || row["ratio"] != nil || row["ok"] != "fine" {
+		t.Errorf("unexpected sanitized row %v", row)
+	}
+	if _, err := json.Marshal(row); err != nil {
+		t.Errorf("sanitized row does not encode: %v", err)
+	}
+	want := valueIssueCounts{InvalidUTF8: 1, NonFinite: 2}
+	if sanitizer.counts != want {
+		t.Errorf("counts = %+v, want %+v", sanitizer.counts, want)
+	}
+	if got := sanitizer.counts.String(); got != "1 invalid UTF-8 value(s) replaced, 2 NaN/Inf value(s) written as null" {
+		t.Errorf("String() = %q", got)
+	}
+
+	// Off leaves values alone
+	off := NewArchiver(&Config{Table: "events"}, newTestLogger()).newValueSanitizer(PartitionInfo{TableName: "events_20240101"}, time.Time{})
+	if off != nil {
+		t.Fatal("expected no sanitizer when the policy is off")
+	}
+	row = map[string]interface{}{"score": math.NaN()}
+	if keep, err := off.sanitize(row); !keep || err != nil || !math.IsNaN(row["score"].(float64)) {
+		t.Errorf("nil sanitizer changed the row: %v", row)
+	}
+}
+
+func TestValueSanitizerQuarantine(t *testing.T) {
+	dir := t.TempDir()
+	archiver := NewArchiver(&Config{Table: "events", InvalidValues: InvalidValuesQuarantine, QuarantineDir: dir}, newTestLogger())
+	start := time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)
+	sanitizer := archiver.newValueSanitizer(PartitionInfo{TableName: "events_20240101"}, start)
+
+	if keep, err := sanitizer.sanitize(map[string]interface{}{"name": "ok", "score": 1.5}); !keep || err != nil {
+		t.Fatalf("clean row was not kept: %v, %v", keep, err)
+	}
+	if keep, err := sanitizer.sanitize(map[string]interface{}{"id": int64(7), "name": "caf\xe9", "score": math.Inf(-1)}); keep || err != nil {
+		t.Fatalf("bad row was not quarantined: %v, %v", keep, err)
+	}
+	if err := sanitizer.close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if sanitizer.counts != (valueIssueCounts{Quarantined: 1}) {
+		t.Errorf("unexpected counts %+v", sanitizer.counts)
+	}
+
+	wantPath := filepath.Join(dir, "events", "events_20240101_20240101T060000Z.jsonl")
+	data, err := os.ReadFile(wantPath)
+	if err != nil {
+		t.Fatalf("quarantine file not written: %v", err)
+	}
+	var record quarantineRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("invalid quarantine record %q: %v", data, err)
+	}
+	if record.Partition != "events_20240101" || strings.Join(record.Problems, "; ") != "name: invalid UTF-8; score: -Inf" {
+		t.Errorf("unexpected record %+v", record)
+	}
+	if string(record.Raw["name"]) != "caf\xe9" || record.Row["score"] != "-Inf" || record.Row["id"] != float64(7) {
+		t.Errorf("quarantine record lost the original values: %+v", record)
+	}
+}
+
+func TestExtractPartitionDataStreamingInvalidValues(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	tests := []struct {
+		policy    string
+		wantRows  int64
+		wantCount valueIssueCounts
+	}{
+		{InvalidValuesReplace, 3, valueIssueCounts{InvalidUTF8: 1, NonFinite: 1}},
+		{InvalidValuesQuarantine, 1, valueIssueCounts{Quarantined: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			archiver := NewArchiver(&Config{
+				Table:         "events",
+				OutputFormat:  "jsonl",
+				Compression:   "none",
+				InvalidValues: tt.policy,
+				QuarantineDir: t.TempDir(),
+			}, newTestLogger())
+			archiver.db = db
+			archiver.ctx = context.Background()
+
+			mock.ExpectQuery(`FROM information_schema.columns`).WithArgs("events_20240101").
+				WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).
+					AddRow("id", "bigint", "int8").
+					AddRow("name", "text", "text").
+					AddRow("score", "double precision", "float8"))
+			mock.ExpectQuery(`SELECT "id", "name", "score" FROM "events_20240101"`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "score"}).
+					AddRow(int64(1), "ok", 1.5).
+					AddRow(int64(2), "caf\xe9", 2.5).
+					AddRow(int64(3), "ok", driver.Value(math.NaN())))
+
+			var issues valueIssueCounts
+			cache := &PartitionCache{Entries: make(map[string]PartitionCacheEntry)}
+			partition := PartitionInfo{TableName: "events_20240101", RowCount: 3}
+			path, _, _, _, rows, err := archiver.extractPartitionDataStreaming(partition, nil, cache, func(string) {}, time.Time{}, time.Time{}, 0, &issues, nil, nil)
+			if err != nil {
+				t.Fatalf("extraction failed: %v", err)
+			}
+			defer cleanupTempFile(path)
+
+			if rows != tt.wantRows || issues != tt.wantCount {
+				t.Errorf("rows = %d, issues = %+v; want %d, %+v", rows, issues, tt.wantRows, tt.wantCount)
+			}
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			var lines int64
+			for scanner := bufio.NewScanner(file); scanner.Scan(); lines++ {
+				if !json.Valid(scanner.Bytes()) {
+					t.Errorf("invalid JSONL line %q", scanner.Text())
+				}
+			}
+			if lines != tt.wantRows {
+				t.Errorf("archive holds %d rows, want %d", lines, tt.wantRows)
+			}
+		})
+	}
+}
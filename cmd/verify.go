@@ -0,0 +1,782 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5" //nolint:gosec // MD5 used for checksum comparisons only
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/airframesio/data-archiver/cmd/compressors"
+	"github.com/airframesio/data-archiver/cmd/formatters"
+	"github.com/lib/pq"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Verification statuses reported per archived file
+const (
+	VerifyStatusOK               = "ok"
+	VerifyStatusCountMismatch    = "count-mismatch"
+	VerifyStatusPartitionDropped = "partition-dropped"
+	VerifyStatusNoArchivedCount  = "no-archived-count"
+	VerifyStatusObjectMissing    = "object-missing"
+	VerifyStatusSizeMismatch     = "size-mismatch"
+	VerifyStatusChecksumMismatch = "checksum-mismatch"
+	VerifyStatusFileRowMismatch  = "file-row-mismatch"
+	VerifyStatusRowLimited       = "row-limited"
+	VerifyStatusError            = "error"
+)
+
+// Static errors for verify
+var (
+	ErrVerifyDiscrepancies       = errors.New("verification found discrepancies")
+	ErrVerifyOutputFormatInvalid = errors.New("verify output format must be one of: text, json")
+)
+
+var (
+	verifyCountsOnly   bool
+	verifyDownload     bool
+	verifyOutputFormat string
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify archived files against the live database",
+	Long: `Verify archived files using the archive cache and path template, without any manual source configuration.
+The row count recorded for each archived file is compared against a SELECT count(*) on the corresponding
+live partition and date range, and each object is checked in S3: its size and checksum with a HEAD request,
+or with --download, by downloading it and counting its rows. With --counts-only, S3 is not touched.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		runVerify(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	// Database flags
+	verifyCmd.Flags().StringVar(&dbHost, "db-host", "localhost", "PostgreSQL host")
+	verifyCmd.Flags().IntVar(&dbPort, "db-port", 5432, "PostgreSQL port")
+	verifyCmd.Flags().StringVar(&dbUser, "db-user", "", "PostgreSQL user")
+	verifyCmd.Flags().StringVar(&dbPassword, "db-password", "", "PostgreSQL password")
+	verifyCmd.Flags().StringVar(&dbName, "db-name", "", "PostgreSQL database name")
+	verifyCmd.Flags().StringVar(&dbSSLMode, "db-sslmode", "disable", "PostgreSQL SSL mode (disable, require, verify-ca, verify-full)")
+	verifyCmd.Flags().IntVar(&dbStatementTimeout, "db-statement-timeout", 300, "PostgreSQL statement timeout in seconds (0 = no timeout)")
+
+	// S3 flags
+	verifyCmd.Flags().StringVar(&s3Endpoint, "s3-endpoint", "", "S3-compatible endpoint URL")
+	verifyCmd.Flags().StringVar(&s3Bucket, "s3-bucket", "", "S3 bucket name")
+	verifyCmd.Flags().StringVar(&s3AccessKey, "s3-access-key", "", "S3 access key")
+	verifyCmd.Flags().StringVar(&s3SecretKey, "s3-secret-key", "", "S3 secret key")
+	verifyCmd.Flags().StringVar(&s3Region, "s3-region", "auto", "S3 region")
+
+	// Verify-specific flags
+	verifyCmd.Flags().StringVar(&baseTable, "table", "", "base table name (required)")
+	verifyCmd.Flags().StringVar(&pathTemplate, "path-template", "", "S3 path template used when archiving (required, selects the archive cache)")
+	verifyCmd.Flags().StringVar(&dateColumn, "date-column", "", "timestamp column used when archiving sliced files")
+	verifyCmd.Flags().BoolVar(&verifyCountsOnly, "counts-only", false, "only compare archived row counts against live partitions (no S3 requests)")
+	verifyCmd.Flags().BoolVar(&verifyDownload, "download", false, "download each archived file to check its MD5 and count its rows, instead of checking size and ETag only")
+	verifyCmd.Flags().StringVar(&verifyOutputFormat, "output-format", "text", "Output format: text, json")
+
+	_ = viper.BindPFlag("verify.counts_only", verifyCmd.Flags().Lookup("counts-only"))
+	_ = viper.BindPFlag("verify.download", verifyCmd.Flags().Lookup("download"))
+	_ = viper.BindPFlag("verify.output_format", verifyCmd.Flags().Lookup("output-format"))
+}
+
+// VerifyResult is the verification outcome for a single archived file
+type VerifyResult struct {
+	S3Key        string    `json:"s3_key"`
+	SourceTable  string    `json:"source_table"`
+	RangeStart   time.Time `json:"range_start,omitempty"`
+	RangeEnd     time.Time `json:"range_end,omitempty"`
+	ArchivedRows int64     `json:"archived_rows"`
+	LiveRows     int64     `json:"live_rows"`
+	FileRows     int64     `json:"file_rows,omitempty"` // Rows counted in the downloaded file (--download)
+	Status       string    `json:"status"`
+	Message      string    `json:"message,omitempty"`
+}
+
+// IsDiscrepancy reports whether the result should fail verification
+func (r VerifyResult) IsDiscrepancy() bool {
+	switch r.Status {
+	case VerifyStatusCountMismatch, VerifyStatusObjectMissing, VerifyStatusSizeMismatch,
+		VerifyStatusChecksumMismatch, VerifyStatusFileRowMismatch, VerifyStatusRowLimited, VerifyStatusError:
+		return true
+	}
+	return false
+}
+
+// Verifier checks archived files recorded in the archive cache
+type Verifier struct {
+	config     *Config
+	countsOnly bool
+	download   bool           // Download objects to check MD5 and row counts
+	client     StorageBackend // Set from the archiver's connection when nil
+	archiver   *Archiver
+	logger     *slog.Logger
+}
+
+// NewVerifier creates a new Verifier instance
+func NewVerifier(config *Config, countsOnly bool, logger *slog.Logger) *Verifier {
+	return &Verifier{
+		config:     config,
+		countsOnly: countsOnly,
+		archiver:   NewArchiver(config, logger),
+		logger:     logger,
+	}
+}
+
+func runVerify(cmd *cobra.Command) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "\n❌ PANIC: %v\n", r)
+			os.Exit(1)
+		}
+	}()
+
+	// Helper function to get config value: use flag if set, otherwise use viper
+	getStringConfig := func(flagValue string, flagName string, viperKey string) string {
+		if flag := cmd.Flags().Lookup(flagName); flag != nil && flag.Changed {
+			return flagValue
+		}
+		if viperValue := viper.GetString(viperKey); viperValue != "" {
+			return viperValue
+		}
+		return flagValue
+	}
+	getIntConfig := func(flagValue int, flagName string, viperKey string) int {
+		if flag := cmd.Flags().Lookup(flagName); flag != nil && flag.Changed {
+			return flagValue
+		}
+		if viperValue := viper.GetInt(viperKey); viperValue != 0 {
+			return viperValue
+		}
+		return flagValue
+	}
+
+	config := &Config{
+		Debug:     viper.GetBool("debug"),
+		LogFormat: viper.GetString("log_format"),
+		Database: DatabaseConfig{
+			Host:             getStringConfig(dbHost, "db-host", "db.host"),
+			Port:             getIntConfig(dbPort, "db-port", "db.port"),
+			User:             getStringConfig(dbUser, "db-user", "db.user"),
+			Password:         getStringConfig(dbPassword, "db-password", "db.password"),
+			Name:             getStringConfig(dbName, "db-name", "db.name"),
+			SSLMode:          getStringConfig(dbSSLMode, "db-sslmode", "db.sslmode"),
+			StatementTimeout: getIntConfig(dbStatementTimeout, "db-statement-timeout", "db.statement_timeout"),
+		},
+		S3: S3Config{
+			Endpoint:     getStringConfig(s3Endpoint, "s3-endpoint", "s3.endpoint"),
+			Bucket:       getStringConfig(s3Bucket, "s3-bucket", "s3.bucket"),
+			AccessKey:    getStringConfig(s3AccessKey, "s3-access-key", "s3.access_key"),
+			SecretKey:    getStringConfig(s3SecretKey, "s3-secret-key", "s3.secret_key"),
+			Region:       getStringConfig(s3Region, "s3-region", "s3.region"),
+			PathTemplate: getStringConfig(pathTemplate, "path-template", "s3.path_template"),
+		},
+		Table:        getStringConfig(baseTable, "table", "table"),
+		DateColumn:   getStringConfig(dateColumn, "date-column", "date_column"),
+		TableQueries: loadTableQueries(),
+
+		DateColumnType:   getStringConfig(dateColumnType, "date-column-type", "date_column_type"),
+		DateColumnFormat: getStringConfig(dateColumnFormat, "date-column-format", "date_column_format"),
+	}
+	countsOnly := viper.GetBool("verify.counts_only")
+	outputFormat := viper.GetString("verify.output_format")
+
+	var s3Err error
+	config.S3, s3Err = newS3Config(config.S3, endpointProfileName("verify"))
+
+	// Verify reads the cache written by the archive command
+	config.CacheScope = NewCacheScope("archive", config)
+
+	initLogger(config.Debug, config.LogFormat)
+
+	logger.Info("")
+	logger.Info(fmt.Sprintf("🔎 Data Verifier v%s", Version))
+	logger.Info("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+	if s3Err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", s3Err.Error()))
+		os.Exit(1)
+	}
+	if err := validateVerifyConfig(config); err != nil {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %s", err.Error()))
+		os.Exit(1)
+	}
+	if outputFormat != "text" && outputFormat != "json" {
+		logger.Error(fmt.Sprintf("❌ Configuration error: %v: '%s'", ErrVerifyOutputFormatInvalid, outputFormat))
+		os.Exit(1)
+	}
+
+	ctx := signalContext
+	if ctx == nil {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+	}
+
+	stopStopFileWatcher := startStopFileWatcher("verify", config.Table)
+	defer stopStopFileWatcher()
+	logStopFileHint("verify")
+
+	verifier := NewVerifier(config, countsOnly, logger)
+	verifier.download = viper.GetBool("verify.download")
+	results, err := verifier.Run(ctx)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			logger.Info("")
+			logger.Info("⚠️  Verification cancelled by user")
+			os.Exit(130)
+		}
+		logger.Error(fmt.Sprintf("❌ Verification failed: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	report := newVerifyReport(config.Table, results)
+	if outputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(report)
+	} else {
+		writeVerifyReportText(os.Stdout, report)
+	}
+
+	if err := printVerifySummary(results); err != nil {
+		logger.Error(fmt.Sprintf("❌ %s", err.Error()))
+		os.Exit(1)
+	}
+
+	logger.Info("")
+	logger.Info("✅ Verification completed successfully!")
+}
+
+// validateVerifyConfig validates the settings needed to locate the archive cache
+func validateVerifyConfig(config *Config) error {
+	if config.Table == "" {
+		return ErrTableNameRequired
+	}
+	if !isValidTableName(config.Table) {
+		return fmt.Errorf("%w: '%s'", ErrTableNameInvalid, config.Table)
+	}
+	if config.S3.PathTemplate == "" {
+		return ErrPathTemplateRequired
+	}
+	if config.Database.User == "" {
+		return ErrDatabaseUserRequired
+	}
+	if config.Database.Name == "" {
+		return ErrDatabaseNameRequired
+	}
+	if config.DateColumn != "" && !validPostgreSQLIdentifier.MatchString(config.DateColumn) {
+		return ErrDateColumnInvalid
+	}
+	if err := config.dateColumnSpec().Validate(); err != nil {
+		return err
+	}
+	return config.S3.HTTP.Validate()
+}
+
+// Run verifies every uploaded file recorded in the archive cache
+func (v *Verifier) Run(ctx context.Context) ([]VerifyResult, error) {
+	v.archiver.ctx = ctx
+
+	cache, err := loadPartitionCache(v.config.CacheScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load archive cache: %w", err)
+	}
+
+	if err := v.archiver.connect(ctx); err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer v.archiver.db.Close()
+	if v.client == nil {
+		v.client = v.archiver.s3Client
+	}
+
+	keys := make([]string, 0, len(cache.Entries))
+	for key, entry := range cache.Entries {
+		if entry.S3Uploaded && entry.S3Key != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	if len(keys) == 0 {
+		v.logger.Info("No archived files found in cache for this table and path template")
+		return nil, nil
+	}
+
+	mode := "full"
+	if v.countsOnly {
+		mode = "counts-only"
+	} else if v.download {
+		mode = "full, downloading files"
+	}
+	v.logger.Info(fmt.Sprintf("Verifying %d archived file(s) (%s)...", len(keys), mode))
+
+	results := make([]VerifyResult, 0, len(keys))
+	for _, key := range keys {
+		select {
+		case <-ctx.Done():
+			return results, ctx.Err()
+		default:
+		}
+
+		result := v.verifyCounts(ctx, v.archiver.db, cache.Entries[key])
+		if !v.countsOnly && (result.Status == VerifyStatusOK || result.Status == VerifyStatusPartitionDropped) {
+			v.verifyObject(ctx, cache.Entries[key], &result)
+		}
+		results = append(results, result)
+
+		if result.IsDiscrepancy() {
+			v.logger.Warn(fmt.Sprintf("  ❌ %s: %s", result.S3Key, result.Message))
+		} else {
+			v.logger.Debug(fmt.Sprintf("  ✅ %s: %s", result.S3Key, result.Status))
+		}
+	}
+
+	return results, nil
+}
+
+// verifyCounts compares the archived row count for an entry against the live partition
+func (v *Verifier) verifyCounts(ctx context.Context, db *sql.DB, entry PartitionCacheEntry) VerifyResult {
+	result := VerifyResult{
+		S3Key:        entry.S3Key,
+		SourceTable:  entry.SourceTable,
+		RangeStart:   entry.RangeStart,
+		RangeEnd:     entry.RangeEnd,
+		ArchivedRows: entry.ArchivedRowCount,
+	}
+
+	if entry.SourceTable == "" {
+		result.Status = VerifyStatusNoArchivedCount
+		result.Message = "cache entry predates row count tracking; re-archive to record counts"
+		return result
+	}
+	if entry.RowLimit > 0 {
+		result.Status = VerifyStatusRowLimited
+		result.Message = fmt.Sprintf("sample of at most %d rows written with --limit-rows-per-slice; re-archive without it", entry.RowLimit)
+		return result
+	}
+
+	var exists bool
+	if err := db.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", pq.QuoteIdentifier(entry.SourceTable)).Scan(&exists); err != nil {
+		result.Status = VerifyStatusError
+		result.Message = fmt.Sprintf("failed to check partition %s: %v", entry.SourceTable, err)
+		return result
+	}
+	if !exists {
+		result.Status = VerifyStatusPartitionDropped
+		result.Message = fmt.Sprintf("partition %s no longer exists", entry.SourceTable)
+		return result
+	}
+
+	var liveRows int64
+	var err error
+	switch customSQL := v.config.customQuery(); {
+	case customSQL != "":
+		liveRows, err = v.countCustomQueryRows(ctx, db, customSQL, entry)
+	case entry.Filter != "":
+		liveRows, err = countFilteredRows(ctx, db, entry, v.config.dateColumnSpec())
+	case entry.WatermarkColumn != "":
+		liveRows, err = countIncrementRows(ctx, db, entry.SourceTable, Increment{Column: entry.WatermarkColumn, After: entry.WatermarkAfter, Through: entry.WatermarkThrough})
+	case entry.KeyColumn != "":
+		liveRows, err = countKeyRangeRows(ctx, db, entry.SourceTable, KeyRange{Column: entry.KeyColumn, Start: entry.KeyStart, End: entry.KeyEnd})
+	default:
+		liveRows, err = countLiveRows(ctx, db, entry.SourceTable, v.config.dateColumnSpec(), entry.RangeStart, entry.RangeEnd)
+	}
+	if err != nil {
+		result.Status = VerifyStatusError
+		result.Message = err.Error()
+		return result
+	}
+	result.LiveRows = liveRows
+
+	if liveRows != entry.ArchivedRowCount {
+		result.Status = VerifyStatusCountMismatch
+		result.Message = fmt.Sprintf("archived %d rows but %s has %d", entry.ArchivedRowCount, entry.SourceTable, liveRows)
+		return result
+	}
+
+	result.Status = VerifyStatusOK
+	return result
+}
+
+// verifyObject checks an archived object in S3: its size and checksum with a
+// HEAD request, or with --download, its MD5 and row count from its contents.
+// A split archive's manifest is checked along with every part it lists.
+func (v *Verifier) verifyObject(ctx context.Context, entry PartitionCacheEntry, result *VerifyResult) {
+	if !v.checkObject(ctx, entry.S3Key, entry.FileSize, entry.FileMD5, entry.MultipartETag, result) {
+		return
+	}
+	if strings.HasSuffix(entry.S3Key, manifestSuffix) {
+		v.verifySplitArchive(ctx, entry, result)
+		return
+	}
+	if !v.download {
+		return
+	}
+
+	sum, rows, err := v.downloadObject(ctx, entry.S3Key)
+	if !v.checkDownload(entry.S3Key, sum, entry.FileMD5, rows, entry.ArchivedRowCount, entry.SourceTable != "", err, result) {
+		return
+	}
+	result.FileRows = rows
+}
+
+// verifySplitArchive checks each part listed in a split archive's manifest
+func (v *Verifier) verifySplitArchive(ctx context.Context, entry PartitionCacheEntry, result *VerifyResult) {
+	out, _, err := v.client.Get(ctx, v.config.S3.Bucket, entry.S3Key, GetOptions{})
+	if err != nil {
+		result.Status = VerifyStatusError
+		result.Message = fmt.Sprintf("failed to download manifest: %v", err)
+		return
+	}
+	var manifest splitManifest
+	err = json.NewDecoder(out).Decode(&manifest)
+	out.Close()
+	if err != nil {
+		result.Status = VerifyStatusError
+		result.Message = fmt.Sprintf("failed to read manifest: %v", err)
+		return
+	}
+
+	var fileRows int64
+	for _, part := range manifest.Parts {
+		if !v.checkObject(ctx, part.Key, part.Size, part.MD5, "", result) {
+			return
+		}
+		if !v.download {
+			continue
+		}
+		sum, rows, err := v.downloadObject(ctx, part.Key)
+		if !v.checkDownload(part.Key, sum, part.MD5, rows, part.Rows, true, err, result) {
+			return
+		}
+		fileRows += rows
+	}
+	if v.download {
+		if entry.SourceTable != "" && fileRows != entry.ArchivedRowCount {
+			result.Status = VerifyStatusFileRowMismatch
+			result.Message = fmt.Sprintf("parts hold %d rows, archived %d", fileRows, entry.ArchivedRowCount)
+			return
+		}
+		result.FileRows = fileRows
+	}
+}
+
+// checkObject HEADs key and compares its size and ETag with the uploaded
+// file's. Multipart ETags are compared only when one was recorded, and ETags
+// of SSE-KMS and SSE-C objects, which are not checksums, not at all.
+func (v *Verifier) checkObject(ctx context.Context, key string, size int64, md5Hash, multipartETag string, result *VerifyResult) bool {
+	head, err := v.client.Head(ctx, v.config.S3.Bucket, key)
+	if err != nil {
+		if isObjectNotFound(err) {
+			result.Status = VerifyStatusObjectMissing
+			result.Message = fmt.Sprintf("%s not found in S3", key)
+			return false
+		}
+		result.Status = VerifyStatusError
+		result.Message = fmt.Sprintf("failed to check %s: %v", key, err)
+		return false
+	}
+
+	if got := head.Size; got != size {
+		result.Status = VerifyStatusSizeMismatch
+		result.Message = fmt.Sprintf("%s: S3 size %d does not match cached size %d", key, got, size)
+		return false
+	}
+	etag := strings.Trim(head.ETag, `"`)
+	want := md5Hash
+	if strings.Contains(etag, "-") {
+		want = strings.Trim(multipartETag, `"`)
+	}
+	if want != "" && etagIsChecksum(head) && etag != want {
+		result.Status = VerifyStatusChecksumMismatch
+		result.Message = fmt.Sprintf("%s: S3 ETag %s does not match %s", key, etag, want)
+		return false
+	}
+	return true
+}
+
+// checkDownload compares a downloaded file's MD5 and row count with the
+// recorded ones; rows are only compared when a count was recorded
+func (v *Verifier) checkDownload(key, sum, md5Hash string, rows, wantRows int64, rowsRecorded bool, err error, result *VerifyResult) bool {
+	switch {
+	case err != nil:
+		result.Status = VerifyStatusError
+		result.Message = fmt.Sprintf("failed to read %s: %v", key, err)
+	case md5Hash != "" && sum != md5Hash:
+		result.Status = VerifyStatusChecksumMismatch
+		result.Message = fmt.Sprintf("%s: downloaded MD5 %s does not match %s", key, sum, md5Hash)
+	case rowsRecorded && rows != wantRows:
+		result.Status = VerifyStatusFileRowMismatch
+		result.Message = fmt.Sprintf("%s holds %d rows, archived %d", key, rows, wantRows)
+	default:
+		return true
+	}
+	return false
+}
+
+// downloadObject streams an object, returning its MD5 and the rows it holds
+func (v *Verifier) downloadObject(ctx context.Context, key string) (string, int64, error) {
+	format, compression, err := detectFormatAndCompression(key, "", "")
+	if err != nil {
+		return "", 0, err
+	}
+	out, _, err := v.client.Get(ctx, v.config.S3.Bucket, key, GetOptions{})
+	if err != nil {
+		return "", 0, err
+	}
+	defer out.Close()
+
+	hasher := md5.New() //nolint:gosec // MD5 used for checksum comparisons only
+	body, _, err := loadStreamZstdDictionary(ctx, v.client, v.config.S3.Bucket, key, io.TeeReader(out, hasher))
+	if err != nil {
+		return "", 0, err
+	}
+	rows, err := countArchivedRows(body, format, compression)
+	if err != nil {
+		return "", 0, err
+	}
+	// Hash whatever the reader did not need to consume
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), rows, nil
+}
+
+// countArchivedRows counts the rows of an archived file without keeping them:
+// lines of JSONL, records after the header of CSV, and rows of Parquet
+func countArchivedRows(r io.Reader, format, compression string) (int64, error) {
+	compressor, err := compressors.GetCompressor(compression)
+	if err != nil {
+		return 0, err
+	}
+	decompressed, err := compressor.NewReader(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create decompression reader: %w", err)
+	}
+	defer decompressed.Close()
+
+	var rows int64
+	switch format {
+	case formatters.FormatJSONL:
+		reader := bufio.NewReaderSize(decompressed, 1024*1024)
+		inLine := false
+		for {
+			chunk, err := reader.ReadSlice('\n')
+			if len(chunk) > 0 {
+				inLine = chunk[len(chunk)-1] != '\n'
+				if !inLine {
+					rows++
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+				return 0, err
+			}
+		}
+		if inLine {
+			rows++ // Last line without a newline
+		}
+	case formatters.FormatCSV:
+		reader := csv.NewReader(decompressed)
+		reader.FieldsPerRecord = -1
+		reader.ReuseRecord = true
+		for {
+			if _, err := reader.Read(); err == io.EOF {
+				break
+			} else if err != nil {
+				return 0, err
+			}
+			rows++
+		}
+		rows = max(rows-1, 0) // Header row
+	case formatters.FormatParquet:
+		reader, err := formatters.NewParquetReaderWithCloser(decompressed)
+		if err != nil {
+			return 0, err
+		}
+		for {
+			chunk, err := reader.ReadChunk(10000)
+			if err != nil {
+				return 0, err
+			}
+			if len(chunk) == 0 {
+				break
+			}
+			rows += int64(len(chunk))
+		}
+	default:
+		return 0, fmt.Errorf("%w: %s", ErrOutputFormatInvalid, format)
+	}
+	return rows, nil
+}
+
+// verifyPartitionReport is the pass/fail outcome of one source partition
+type verifyPartitionReport struct {
+	Partition string `json:"partition"`
+	Files     int    `json:"files"`
+	Passed    bool   `json:"passed"`
+	Failures  int    `json:"failures"`
+}
+
+// verifyReport is the full verify report written to stdout
+type verifyReport struct {
+	Table      string                  `json:"table"`
+	Passed     bool                    `json:"passed"`
+	Partitions []verifyPartitionReport `json:"partitions"`
+	Files      []VerifyResult          `json:"files"`
+}
+
+// newVerifyReport groups file results by source partition; a partition fails
+// when any of its files has a discrepancy
+func newVerifyReport(table string, results []VerifyResult) *verifyReport {
+	report := &verifyReport{Table: table, Passed: true, Partitions: []verifyPartitionReport{}, Files: results}
+	if report.Files == nil {
+		report.Files = []VerifyResult{}
+	}
+	index := make(map[string]int)
+	for _, result := range results {
+		name := result.SourceTable
+		if name == "" {
+			name = "(unknown)"
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(report.Partitions)
+			index[name] = i
+			report.Partitions = append(report.Partitions, verifyPartitionReport{Partition: name, Passed: true})
+		}
+		report.Partitions[i].Files++
+		if result.IsDiscrepancy() {
+			report.Partitions[i].Failures++
+			report.Partitions[i].Passed = false
+			report.Passed = false
+		}
+	}
+	sort.Slice(report.Partitions, func(i, j int) bool {
+		return report.Partitions[i].Partition < report.Partitions[j].Partition
+	})
+	return report
+}
+
+// writeVerifyReportText renders a report for the terminal, one line per
+// partition followed by each failing file
+func writeVerifyReportText(w io.Writer, report *verifyReport) {
+	for _, partition := range report.Partitions {
+		if partition.Passed {
+			fmt.Fprintf(w, "✅ PASS  %s (%d files)\n", partition.Partition, partition.Files)
+			continue
+		}
+		fmt.Fprintf(w, "❌ FAIL  %s (%d of %d files)\n", partition.Partition, partition.Failures, partition.Files)
+		for _, result := range report.Files {
+			if result.SourceTable == partition.Partition && result.IsDiscrepancy() {
+				fmt.Fprintf(w, "        %-18s %s: %s\n", result.Status, result.S3Key, result.Message)
+			}
+		}
+	}
+}
+
+// countCustomQueryRows counts the rows the table's custom extraction query
+// returns for an entry, since that is what was archived rather than the
+// partition's own rows
+func (v *Verifier) countCustomQueryRows(ctx context.Context, db *sql.DB, customSQL string, entry PartitionCacheEntry) (int64, error) {
+	start, end := entry.RangeStart, entry.RangeEnd
+	if start.IsZero() || end.IsZero() {
+		date, ok := v.archiver.extractDateFromTableName(entry.SourceTable)
+		if !ok {
+			return 0, fmt.Errorf("cannot determine the time range of %s for its custom query", entry.SourceTable)
+		}
+		start, end = v.archiver.partitionTimeRange(PartitionInfo{TableName: entry.SourceTable, Date: date})
+	}
+
+	query, args := renderCustomQuery(customSQL, entry.SourceTable, start, end)
+	var count int64
+	//nolint:gosec // G201: the custom query comes from the operator's config file
+	if err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM (%s) AS custom_query", query), args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("custom query count on %s failed: %w", entry.SourceTable, err)
+	}
+	return count, nil
+}
+
+// countLiveRows counts rows in a table, optionally restricted to [start, end) on the date column
+func countLiveRows(ctx context.Context, db *sql.DB, table string, dateColumn DateColumnSpec, start, end time.Time) (int64, error) {
+	//nolint:gosec // G201: identifiers are quoted via pq.QuoteIdentifier
+	query := fmt.Sprintf("SELECT count(*) FROM %s", pq.QuoteIdentifier(table))
+	var args []interface{}
+
+	if !start.IsZero() && !end.IsZero() {
+		if dateColumn.Name == "" {
+			return 0, fmt.Errorf("file covers %s to %s but no --date-column was given", start.Format(time.RFC3339), end.Format(time.RFC3339))
+		}
+		var condition string
+		condition, args = dateColumn.rangeCondition(start, end)
+		query += " WHERE " + condition
+	}
+
+	var count int64
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count query on %s failed: %w", table, err)
+	}
+	return count, nil
+}
+
+// printVerifySummary logs per-status totals and returns an error if any discrepancy was found
+func printVerifySummary(results []VerifyResult) error {
+	counts := make(map[string]int)
+	discrepancies := 0
+	for _, result := range results {
+		counts[result.Status]++
+		if result.IsDiscrepancy() {
+			discrepancies++
+		}
+	}
+
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	logger.Info("")
+	logger.Info("📊 Verification Summary")
+	logger.Info("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	logger.Info(fmt.Sprintf("  Files checked:     %d", len(results)))
+	for _, status := range statuses {
+		logger.Info(fmt.Sprintf("  %-18s %d", status+":", counts[status]))
+	}
+
+	if discrepancies > 0 {
+		var keys []string
+		for _, result := range results {
+			if result.IsDiscrepancy() {
+				keys = append(keys, result.S3Key)
+			}
+		}
+		logger.Info("")
+		logger.Info("  Files with discrepancies:")
+		for _, key := range keys {
+			logger.Info(fmt.Sprintf("    • %s", key))
+		}
+		return fmt.Errorf("%w: %d of %d file(s)", ErrVerifyDiscrepancies, discrepancies, len(results))
+	}
+	return nil
+}
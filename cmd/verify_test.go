@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestCountLiveRows(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	t.Run("WholePartition", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "flights_20240101"$`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(42))
+
+		count, err := countLiveRows(context.Background(), db, "flights_20240101", DateColumnSpec{}, time.Time{}, time.Time{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if count != 42 {
+			t.Errorf("expected 42, got %d", count)
+		}
+	})
+
+	t.Run("DateRange", func(t *testing.T) {
+		start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		end := start.Add(time.Hour)
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "flights_20240101" WHERE "created_at" >= \$1 AND "created_at" < \$2`).
+			WithArgs(start, end).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+
+		count, err := countLiveRows(context.Background(), db, "flights_20240101", DateColumnSpec{Name: "created_at"}, start, end)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if count != 7 {
+			t.Errorf("expected 7, got %d", count)
+		}
+	})
+
+	t.Run("DateRangeWithoutColumn", func(t *testing.T) {
+		start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		if _, err := countLiveRows(context.Background(), db, "flights_20240101", DateColumnSpec{}, start, start.Add(time.Hour)); err == nil {
+			t.Fatal("expected error when range is set without a date column")
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestVerifierVerifyCounts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	verifier := NewVerifier(&Config{Table: "flights"}, true, newTestLogger())
+	ctx := context.Background()
+
+	t.Run("NoArchivedCount", func(t *testing.T) {
+		result := verifier.verifyCounts(ctx, db, PartitionCacheEntry{S3Key: "flights/old.jsonl.zst"})
+		if result.Status != VerifyStatusNoArchivedCount {
+			t.Errorf("expected %s, got %s", VerifyStatusNoArchivedCount, result.Status)
+		}
+		if result.IsDiscrepancy() {
+			t.Error("missing archived count should not be a discrepancy")
+		}
+	})
+
+	t.Run("PartitionDropped", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT to_regclass`).
+			WithArgs(`"flights_20240101"`).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		result := verifier.verifyCounts(ctx, db, PartitionCacheEntry{S3Key: "k", SourceTable: "flights_20240101", ArchivedRowCount: 10})
+		if result.Status != VerifyStatusPartitionDropped {
+			t.Errorf("expected %s, got %s", VerifyStatusPartitionDropped, result.Status)
+		}
+	})
+
+	t.Run("Match", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT to_regclass`).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "flights_20240101"`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+
+		result := verifier.verifyCounts(ctx, db, PartitionCacheEntry{S3Key: "k", SourceTable: "flights_20240101", ArchivedRowCount: 10})
+		if result.Status != VerifyStatusOK {
+			t.Errorf("expected %s, got %s (%s)", VerifyStatusOK, result.Status, result.Message)
+		}
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT to_regclass`).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "flights_20240101"`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+
+		result := verifier.verifyCounts(ctx, db, PartitionCacheEntry{S3Key: "k", SourceTable: "flights_20240101", ArchivedRowCount: 10})
+		if result.Status != VerifyStatusCountMismatch {
+			t.Errorf("expected %s, got %s", VerifyStatusCountMismatch, result.Status)
+		}
+		if result.LiveRows != 12 || !result.IsDiscrepancy() {
+			t.Errorf("expected discrepancy with 12 live rows, got %+v", result)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestPartitionCacheSetArchivedContent(t *testing.T) {
+	cache := &PartitionCache{Entries: make(map[string]PartitionCacheEntry)}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+
+	cache.setFileMetadataWithETagAndStartTime("key", "key", 100, 200, "abc", "", true, time.Time{})
+	cache.setArchivedContent("key", "flights_202401", start, end, 55, 7, archiveFormat{Format: "jsonl", Compression: "zstd"}, 0)
+
+	entry := cache.Entries["key"]
+	if entry.ArchivedRowCount != 55 || entry.SourceTable != "flights_202401" || entry.CompressionLevel != 7 {
+		t.Errorf("unexpected archived content: %+v", entry)
+	}
+	if !entry.RangeStart.Equal(start) || !entry.RangeEnd.Equal(end) {
+		t.Errorf("unexpected range: %v - %v", entry.RangeStart, entry.RangeEnd)
+	}
+	if entry.FileMD5 != "abc" {
+		t.Error("file metadata should be preserved")
+	}
+}
+
+func TestVerifierVerifyObject(t *testing.T) {
+	data := []byte("{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n")
+	client := newFakeObjectStore(map[string][]byte{"flights/2024-01-01.jsonl": data})
+	entry := PartitionCacheEntry{
+		S3Key:            "flights/2024-01-01.jsonl",
+		SourceTable:      "flights_20240101",
+		FileSize:         int64(len(data)),
+		FileMD5:          md5Hex(data),
+		ArchivedRowCount: 3,
+	}
+	verifier := NewVerifier(&Config{Table: "flights", S3: S3Config{Bucket: "bucket"}}, false, newTestLogger())
+	verifier.client = newS3ObjectStore(client)
+	ctx := context.Background()
+
+	t.Run("Head", func(t *testing.T) {
+		result := VerifyResult{Status: VerifyStatusOK}
+		verifier.verifyObject(ctx, entry, &result)
+		if result.Status != VerifyStatusOK {
+			t.Errorf("expected %s, got %s (%s)", VerifyStatusOK, result.Status, result.Message)
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		missing := entry
+		missing.S3Key = "flights/2024-01-02.jsonl"
+		result := VerifyResult{Status: VerifyStatusOK}
+		verifier.verifyObject(ctx, missing, &result)
+		if result.Status != VerifyStatusObjectMissing {
+			t.Errorf("expected %s, got %s", VerifyStatusObjectMissing, result.Status)
+		}
+	})
+
+	t.Run("ChecksumMismatch", func(t *testing.T) {
+		changed := entry
+		changed.FileMD5 = strings.Repeat("0", 32)
+		result := VerifyResult{Status: VerifyStatusOK}
+		verifier.verifyObject(ctx, changed, &result)
+		if result.Status != VerifyStatusChecksumMismatch || !result.IsDiscrepancy() {
+			t.Errorf("expected %s, got %s", VerifyStatusChecksumMismatch, result.Status)
+		}
+	})
+
+	t.Run("EncryptedWithKMS", func(t *testing.T) {
+		// The ETag of an SSE-KMS object is not its MD5
+		client.sse, client.etags = s3.ServerSideEncryptionAwsKms, map[string]string{entry.S3Key: strings.Repeat("f", 32)}
+		defer func() { client.sse, client.etags = "", nil }()
+		result := VerifyResult{Status: VerifyStatusOK}
+		verifier.verifyObject(ctx, entry, &result)
+		if result.Status != VerifyStatusOK {
+			t.Errorf("expected %s, got %s (%s)", VerifyStatusOK, result.Status, result.Message)
+		}
+	})
+
+	verifier.download = true
+
+	t.Run("Download", func(t *testing.T) {
+		result := VerifyResult{Status: VerifyStatusOK}
+		verifier.verifyObject(ctx, entry, &result)
+		if result.Status != VerifyStatusOK || result.FileRows != 3 {
+			t.Errorf("expected ok with 3 file rows, got %s with %d (%s)", result.Status, result.FileRows, result.Message)
+		}
+	})
+
+	t.Run("DownloadRowMismatch", func(t *testing.T) {
+		changed := entry
+		changed.ArchivedRowCount = 4
+		result := VerifyResult{Status: VerifyStatusOK}
+		verifier.verifyObject(ctx, changed, &result)
+		if result.Status != VerifyStatusFileRowMismatch {
+			t.Errorf("expected %s, got %s", VerifyStatusFileRowMismatch, result.Status)
+		}
+	})
+}
+
+func TestCountArchivedRows(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   string
+		want   int64
+	}{
+		{"JSONL", "jsonl", "{\"a\":1}\n{\"a\":2}\n", 2},
+		{"JSONLWithoutTrailingNewline", "jsonl", "{\"a\":1}\n{\"a\":2}", 2},
+		{"JSONLEmpty", "jsonl", "", 0},
+		{"CSV", "csv", "a,b\n1,\"x\ny\"\n2,z\n", 2},
+		{"CSVHeaderOnly", "csv", "a,b\n", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := countArchivedRows(strings.NewReader(tt.data), tt.format, "none")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rows != tt.want {
+				t.Errorf("expected %d rows, got %d", tt.want, rows)
+			}
+		})
+	}
+}
+
+func TestNewVerifyReport(t *testing.T) {
+	results := []VerifyResult{
+		{S3Key: "b1", SourceTable: "flights_20240102", Status: VerifyStatusOK},
+		{S3Key: "a1", SourceTable: "flights_20240101", Status: VerifyStatusOK},
+		{S3Key: "b2", SourceTable: "flights_20240102", Status: VerifyStatusSizeMismatch, Message: "short"},
+		{S3Key: "c1", SourceTable: "flights_20240103", Status: VerifyStatusPartitionDropped},
+	}
+	report := newVerifyReport("flights", results)
+	if report.Passed {
+		t.Error("report with a size mismatch should fail")
+	}
+	if len(report.Partitions) != 3 || report.Partitions[0].Partition != "flights_20240101" {
+		t.Fatalf("unexpected partitions: %+v", report.Partitions)
+	}
+	if p := report.Partitions[1]; p.Passed || p.Files != 2 || p.Failures != 1 {
+		t.Errorf("unexpected failing partition: %+v", p)
+	}
+	if !report.Partitions[2].Passed {
+		t.Error("a dropped partition should not fail")
+	}
+
+	var buf bytes.Buffer
+	writeVerifyReportText(&buf, report)
+	if !strings.Contains(buf.String(), "FAIL  flights_20240102") || !strings.Contains(buf.String(), "b2: short") {
+		t.Errorf("unexpected text report:\n%s", buf.String())
+	}
+}
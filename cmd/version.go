@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	runtimedebug "runtime/debug"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var versionJSON bool
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Show version, build, and capability information",
+	Long: `Show the archiver version, git commit, build date, Go version, and platform, along with the
+output formats and compressions this build supports. Use --json for a machine-readable report that
+fleet tooling can use to audit deployed archivers.`,
+	Args: cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		info, _ := runtimedebug.ReadBuildInfo() // nil when the binary has no build info
+		return writeVersionInfo(os.Stdout, newVersionInfo(info), versionJSON)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "print version information as JSON")
+}
+
+// VersionInfo describes a build of the archiver and what it supports
+type VersionInfo struct {
+	Version      string   `json:"version"`
+	Commit       string   `json:"commit,omitempty"`
+	BuildDate    string   `json:"build_date,omitempty"`
+	GoVersion    string   `json:"go_version"`
+	Platform     string   `json:"platform"` // GOOS/GOARCH
+	Formats      []string `json:"formats"`
+	Compressions []string `json:"compressions"`
+}
+
+// newVersionInfo reports the ldflags-injected build variables. Builds without
+// them (go install, go build from a checkout) fall back to the module version
+// and VCS stamps that Go records in the binary.
+func newVersionInfo(build *runtimedebug.BuildInfo) VersionInfo {
+	info := VersionInfo{
+		Version:      Version,
+		Commit:       Commit,
+		BuildDate:    BuildDate,
+		GoVersion:    runtime.Version(),
+		Platform:     runtime.GOOS + "/" + runtime.GOARCH,
+		Formats:      supportedOutputFormats,
+		Compressions: supportedCompressions,
+	}
+	if build == nil {
+		return info
+	}
+
+	if info.Version == "dev" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+		info.Version = strings.TrimPrefix(build.Main.Version, "v")
+	}
+	var revision, modified, vcsTime string
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value
+		case "vcs.time":
+			vcsTime = setting.Value
+		}
+	}
+	if info.Commit == "" && revision != "" {
+		info.Commit = revision[:min(len(revision), 7)]
+		if modified == "true" {
+			info.Commit += "-dirty"
+		}
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = vcsTime
+	}
+	return info
+}
+
+// writeVersionInfo renders version information as text or indented JSON
+func writeVersionInfo(w io.Writer, info VersionInfo, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	}
+
+	unknown := func(value string) string {
+		if value == "" {
+			return "unknown"
+		}
+		return value
+	}
+	fmt.Fprintf(w, "data-archiver %s\n", info.Version)
+	fmt.Fprintf(w, "  Commit:       %s\n", unknown(info.Commit))
+	fmt.Fprintf(w, "  Built:        %s\n", unknown(info.BuildDate))
+	fmt.Fprintf(w, "  Go:           %s\n", info.GoVersion)
+	fmt.Fprintf(w, "  Platform:     %s\n", info.Platform)
+	fmt.Fprintf(w, "  Formats:      %s\n", strings.Join(info.Formats, ", "))
+	fmt.Fprintf(w, "  Compressions: %s\n", strings.Join(info.Compressions, ", "))
+	return nil
+}
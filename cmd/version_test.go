@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	runtimedebug "runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestNewVersionInfoUsesInjectedValues(t *testing.T) {
+	oldVersion, oldCommit, oldDate := Version, Commit, BuildDate
+	defer func() { Version, Commit, BuildDate = oldVersion, oldCommit, oldDate }()
+	Version, Commit, BuildDate = "1.2.3", "abc1234", "2024-03-15T00:00:00Z"
+
+	build := &runtimedebug.BuildInfo{
+		Main:     runtimedebug.Module{Version: "v9.9.9"},
+		Settings: []runtimedebug.BuildSetting{{Key: "vcs.revision", Value: "ffffffffffff"}},
+	}
+	info := newVersionInfo(build)
+	if info.Version != "1.2.3" || info.Commit != "abc1234" || info.BuildDate != "2024-03-15T00:00:00Z" {
+		t.Errorf("injected values not preferred: %+v", info)
+	}
+	if !containsString(info.Formats, "parquet") || !containsString(info.Compressions, "zstd") {
+		t.Errorf("capabilities missing: %+v", info)
+	}
+}
+
+func TestNewVersionInfoFallsBackToBuildInfo(t *testing.T) {
+	oldVersion, oldCommit, oldDate := Version, Commit, BuildDate
+	defer func() { Version, Commit, BuildDate = oldVersion, oldCommit, oldDate }()
+	Version, Commit, BuildDate = "dev", "", ""
+
+	build := &runtimedebug.BuildInfo{
+		Main: runtimedebug.Module{Version: "v1.4.0"},
+		Settings: []runtimedebug.BuildSetting{
+			{Key: "vcs.revision", Value: "0123456789abcdef"},
+			{Key: "vcs.modified", Value: "true"},
+			{Key: "vcs.time", Value: "2024-03-15T12:00:00Z"},
+		},
+	}
+	info := newVersionInfo(build)
+	if info.Version != "1.4.0" {
+		t.Errorf("Version = %q, want 1.4.0", info.Version)
+	}
+	if info.Commit != "0123456-dirty" {
+		t.Errorf("Commit = %q, want 0123456-dirty", info.Commit)
+	}
+	if info.BuildDate != "2024-03-15T12:00:00Z" {
+		t.Errorf("BuildDate = %q", info.BuildDate)
+	}
+
+	// Local builds report "(devel)" and keep the "dev" version
+	info = newVersionInfo(&runtimedebug.BuildInfo{Main: runtimedebug.Module{Version: "(devel)"}})
+	if info.Version != "dev" {
+		t.Errorf("Version = %q, want dev", info.Version)
+	}
+}
+
+func TestWriteVersionInfo(t *testing.T) {
+	info := VersionInfo{
+		Version:      "1.2.3",
+		GoVersion:    "go1.24.0",
+		Platform:     "linux/arm64",
+		Formats:      []string{"jsonl", "csv"},
+		Compressions: []string{"zstd"},
+	}
+
+	var buf bytes.Buffer
+	if err := writeVersionInfo(&buf, info, true); err != nil {
+		t.Fatalf("writeVersionInfo() error = %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, key := range []string{"version", "go_version", "platform", "formats", "compressions"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("JSON missing %q: %s", key, buf.String())
+		}
+	}
+	if _, ok := decoded["commit"]; ok {
+		t.Errorf("empty commit should be omitted: %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := writeVersionInfo(&buf, info, false); err != nil {
+		t.Fatalf("writeVersionInfo() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "Commit:       unknown") || !strings.Contains(buf.String(), "linux/arm64") {
+		t.Errorf("unexpected text output:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestIncrementCondition(t *testing.T) {
+	first := Increment{Column: "id", Through: "500"}
+	if condition, args := first.condition(); condition != `"id" <= $1` || len(args) != 1 || args[0] != "500" {
+		t.Errorf("condition() = %q, %v", condition, args)
+	}
+	next := Increment{Column: "id", After: "500", Through: "900"}
+	if condition, args := next.condition(); condition != `"id" > $1 AND "id" <= $2` || len(args) != 2 || args[0] != "500" || args[1] != "900" {
+		t.Errorf("condition() = %q, %v", condition, args)
+	}
+	if got := incrementFilename("events", ".jsonl", ".zst"); got != "events-"+currentRunID+".jsonl.zst" {
+		t.Errorf("incrementFilename() = %q", got)
+	}
+}
+
+func TestValidateWatermark(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   error
+	}{
+		{"valid", func(c *Config) { c.WatermarkColumn = "updated_at" }, nil},
+		{"invalid column", func(c *Config) { c.WatermarkColumn = "updated at" }, ErrWatermarkColumnInvalid},
+		{"split column", func(c *Config) { c.WatermarkColumn, c.SplitColumn, c.SplitSize = "id", "id", 1000 }, ErrWatermarkColumnConflict},
+		{"row limit", func(c *Config) { c.WatermarkColumn, c.LimitRowsPerSlice = "id", 10 }, ErrWatermarkColumnConflict},
+		{"stdout", func(c *Config) { c.WatermarkColumn, c.Output = "id", StdoutOutput }, ErrWatermarkColumnConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig()
+			tt.modify(config)
+			if err := config.validateWatermark(); !errors.Is(err, tt.want) && (tt.want != nil || err != nil) {
+				t.Errorf("validateWatermark() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildIncrementContinuesFromWatermark(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	archiver := NewArchiver(&Config{Table: "events", WatermarkColumn: "id", S3: S3Config{Bucket: "bucket"}}, newTestLogger())
+	archiver.db = db
+
+	// The first run archives every row
+	mock.ExpectQuery(`SELECT MAX\("id"\)::text, count\(\*\) FROM "events"$`).
+		WillReturnRows(sqlmock.NewRows([]string{"max", "count"}).AddRow("500", 480))
+	partitions, err := archiver.buildIncrement(ctx)
+	if err != nil || len(partitions) != 1 {
+		t.Fatalf("buildIncrement() = %+v, %v", partitions, err)
+	}
+	increment := partitions[0].Increment
+	if increment != (Increment{Column: "id", Through: "500"}) || partitions[0].RowCount != 480 || !partitions[0].HasIncrement() {
+		t.Fatalf("first increment = %+v", partitions[0])
+	}
+
+	// Uploading it moves the watermark, which survives a merge with the file
+	cache, _ := loadPartitionCache(archiver.config.CacheScope)
+	cache.setIncrement("events/events-run.jsonl", increment)
+	if err := cache.save(archiver.config.CacheScope); err != nil {
+		t.Fatalf("save() = %v", err)
+	}
+	other, _ := loadPartitionCache(archiver.config.CacheScope)
+	other.setRowCount("events", 1)
+	if err := other.save(archiver.config.CacheScope); err != nil {
+		t.Fatalf("save() = %v", err)
+	}
+
+	mock.ExpectQuery(`SELECT MAX\("id"\)::text, count\(\*\) FROM "events" WHERE "id" > \$1$`).WithArgs("500").
+		WillReturnRows(sqlmock.NewRows([]string{"max", "count"}).AddRow("900", 400))
+	partitions, err = archiver.buildIncrement(ctx)
+	if err != nil || len(partitions) != 1 || partitions[0].Increment != (Increment{Column: "id", After: "500", Through: "900"}) {
+		t.Fatalf("next increment = %+v, %v", partitions, err)
+	}
+
+	// Nothing past the watermark plans nothing
+	mock.ExpectQuery(`FROM "events" WHERE "id" > \$1$`).WithArgs("500").
+		WillReturnRows(sqlmock.NewRows([]string{"max", "count"}).AddRow(nil, 0))
+	if partitions, err = archiver.buildIncrement(ctx); err != nil || len(partitions) != 0 {
+		t.Errorf("buildIncrement() without new rows = %+v, %v", partitions, err)
+	}
+
+	archiver.config.WatermarkColumn = "updated_at"
+	if _, err := archiver.buildIncrement(ctx); !errors.Is(err, ErrWatermarkColumnChanged) {
+		t.Errorf("expected ErrWatermarkColumnChanged, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -562,7 +562,7 @@ function updateTaskPanel(status) {
             const task = status.currentTask;
 
             // Update current task and partition info
-            const currentTaskText = task.current_step || task.current_task || 'Processing...';
+            const currentTaskText = task.paused ? 'Paused' : (task.current_step || task.current_task || 'Processing...');
             const statusElement = document.getElementById('task-status-text');
 
             if (task.current_partition) {
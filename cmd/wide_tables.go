@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"sync"
+
+	"github.com/airframesio/data-archiver/cmd/formatters"
+)
+
+// rowBuffer holds the scan targets and row maps of one extraction. A table
+// with hundreds of columns would otherwise allocate a map the width of the
+// table for every row: the scan targets here are shared by every row, and
+// the maps of a written chunk are handed out again for the next one.
+type rowBuffer struct {
+	names    []string      // Column names, in SELECT order
+	types    []string      // Column types, for value conversion
+	values   []interface{} // Scan targets, overwritten by each row
+	pointers []interface{} // Pointers to values, passed to Scan
+	spare    []map[string]interface{}
+}
+
+func newRowBuffer(columns []formatters.ColumnSchema) *rowBuffer {
+	b := &rowBuffer{
+		names:    make([]string, len(columns)),
+		types:    make([]string, len(columns)),
+		values:   make([]interface{}, len(columns)),
+		pointers: make([]interface{}, len(columns)),
+	}
+	for i, col := range columns {
+		b.names[i] = col.GetName()
+		b.types[i] = col.GetType()
+		b.pointers[i] = &b.values[i]
+	}
+	return b
+}
+
+// scan reads the current row into the scan targets
+func (b *rowBuffer) scan(rows extractRows) error {
+	return rows.Scan(b.pointers...)
+}
+
+// row converts the scanned values into a row map, reusing a recycled map
+// when one is spare. Every column is set, so a reused map holds nothing of
+// its previous row.
+func (b *rowBuffer) row() map[string]interface{} {
+	var row map[string]interface{}
+	if n := len(b.spare); n > 0 {
+		row = b.spare[n-1]
+		b.spare = b.spare[:n-1]
+	} else {
+		row = make(map[string]interface{}, len(b.names))
+	}
+	for i, name := range b.names {
+		// Convert PostgreSQL driver types to appropriate Go types for formatters
+		row[name] = convertPostgreSQLValue(b.values[i], b.types[i])
+	}
+	return row
+}
+
+// recycle takes back the maps of rows that have been written and are no
+// longer referenced
+func (b *rowBuffer) recycle(rows ...map[string]interface{}) {
+	b.spare = append(b.spare, rows...)
+}
+
+// partitionSchemaCache keeps the column lists of the partitions extracted
+// this run, so a partition split into many slices or files is described by
+// one schema query instead of one per slice
+type partitionSchemaCache struct {
+	mu      sync.Mutex
+	schemas map[string]*TableSchema
+}
+
+func newPartitionSchemaCache() *partitionSchemaCache {
+	return &partitionSchemaCache{schemas: make(map[string]*TableSchema)}
+}
+
+func (c *partitionSchemaCache) get(table string) (*TableSchema, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	schema, ok := c.schemas[table]
+	return schema, ok
+}
+
+func (c *partitionSchemaCache) put(table string, schema *TableSchema) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schemas[table] = schema
+}
+
+// partitionSchema returns a partition's schema, querying it the first time
+// the run asks
+func (a *Archiver) partitionSchema(ctx context.Context, table string) (*TableSchema, error) {
+	if schema, ok := a.schemas.get(table); ok {
+		return schema, nil
+	}
+	schema, err := a.getTableSchema(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	a.schemas.put(table, schema)
+	return schema, nil
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// fakeExtractRows returns the same values for every row
+type fakeExtractRows struct {
+	values []interface{}
+	rows   int
+	read   int
+}
+
+func (r *fakeExtractRows) Next() bool {
+	r.read++
+	return r.read <= r.rows
+}
+
+func (r *fakeExtractRows) Scan(dest ...interface{}) error {
+	for i, d := range dest {
+		*(d.(*interface{})) = r.values[i]
+	}
+	return nil
+}
+
+func (r *fakeExtractRows) Err() error   { return nil }
+func (r *fakeExtractRows) Close() error { return nil }
+
+// wideSchema returns a table of the given width cycling through common types
+func wideSchema(width int) (*TableSchema, []interface{}) {
+	types := []string{"int8", "text", "float8", "bool", "timestamptz"}
+	samples := []interface{}{int64(42), "some text value", 3.14159, true, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	schema := &TableSchema{TableName: "wide"}
+	values := make([]interface{}, width)
+	for i := 0; i < width; i++ {
+		schema.Columns = append(schema.Columns, ColumnInfo{Name: fmt.Sprintf("col_%03d", i), UDTName: types[i%len(types)]})
+		values[i] = samples[i%len(samples)]
+	}
+	return schema, values
+}
+
+func TestRowBufferReusesRowMaps(t *testing.T) {
+	schema, values := wideSchema(5)
+	buffer := newRowBuffer(schema.GetColumns())
+	rows := &fakeExtractRows{values: values, rows: 3}
+
+	var chunk []map[string]interface{}
+	for rows.Next() && len(chunk) < 2 {
+		if err := buffer.scan(rows); err != nil {
+			t.Fatal(err)
+		}
+		chunk = append(chunk, buffer.row())
+	}
+	if chunk[0]["col_001"] != "some text value" || chunk[1]["col_000"] != int64(42) {
+		t.Fatalf("rows = %v", chunk)
+	}
+
+	// A written chunk's maps are handed out again, refilled with the new row
+	first := reflect.ValueOf(chunk[1]).Pointer()
+	buffer.recycle(chunk...)
+	values[0] = int64(7)
+	if err := buffer.scan(rows); err != nil {
+		t.Fatal(err)
+	}
+	row := buffer.row()
+	if reflect.ValueOf(row).Pointer() != first || row["col_000"] != int64(7) || len(row) != 5 {
+		t.Errorf("reused row = %v", row)
+	}
+}
+
+func TestPartitionSchemaQueriedOncePerRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	archiver := NewArchiver(newTestConfig(), newTestLogger())
+	archiver.db = db
+	for _, table := range []string{"events_202401", "events_202402"} {
+		mock.ExpectQuery(`FROM information_schema.columns`).WithArgs(table).
+			WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).AddRow("id", "bigint", "int8"))
+	}
+
+	// Slices of a partition share its schema
+	for _, table := range []string{"events_202401", "events_202401", "events_202402", "events_202401"} {
+		schema, err := archiver.partitionSchema(context.Background(), table)
+		if err != nil || len(schema.Columns) != 1 || schema.TableName != table {
+			t.Fatalf("partitionSchema(%s) = %+v, %v", table, schema, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+// BenchmarkWideTableRows compares building a fresh map for every row of a
+// 500-column table with the extraction's reused scan targets and row maps.
+// Compare allocs/op and B/op:
+//
+//	go test ./cmd -run '^$' -bench WideTableRows -benchmem
+func BenchmarkWideTableRows(b *testing.B) {
+	const chunkSize = 1000
+	schema, values := wideSchema(500)
+	columns := schema.GetColumns()
+
+	b.Run("map-per-row", func(b *testing.B) {
+		b.ReportAllocs()
+		scanValues := make([]interface{}, len(columns))
+		scanPointers := make([]interface{}, len(columns))
+		for i := range scanValues {
+			scanPointers[i] = &scanValues[i]
+		}
+		chunk := make([]map[string]interface{}, 0, chunkSize)
+		for n := 0; n < b.N; n++ {
+			rows := &fakeExtractRows{values: values, rows: chunkSize}
+			for rows.Next() {
+				if err := rows.Scan(scanPointers...); err != nil {
+					b.Fatal(err)
+				}
+				row := make(map[string]interface{}, len(columns))
+				for i, col := range columns {
+					row[col.GetName()] = convertPostgreSQLValue(scanValues[i], col.GetType())
+				}
+				chunk = append(chunk, row)
+			}
+			chunk = chunk[:0]
+		}
+	})
+
+	b.Run("reused", func(b *testing.B) {
+		b.ReportAllocs()
+		buffer := newRowBuffer(columns)
+		chunk := make([]map[string]interface{}, 0, chunkSize)
+		for n := 0; n < b.N; n++ {
+			rows := &fakeExtractRows{values: values, rows: chunkSize}
+			for rows.Next() {
+				if err := buffer.scan(rows); err != nil {
+					b.Fatal(err)
+				}
+				chunk = append(chunk, buffer.row())
+			}
+			buffer.recycle(chunk...)
+			chunk = chunk[:0]
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/airframesio/data-archiver/cmd/compressors"
+	"github.com/airframesio/data-archiver/cmd/formatters"
+	"github.com/spf13/viper"
+)
+
+// zstdDictionarySuffix ends the key of the dictionary stored alongside an
+// archive compressed with one, and the path of its temp file
+const zstdDictionarySuffix = ".dict"
+
+// Zstandard tuning limits
+const (
+	minZstdWindowLog      = 10 // 1KB
+	maxZstdWindowLog      = 29 // 512MB, the most decoders accept by default
+	maxZstdDictionarySize = 1024 * 1024
+	zstdFrameHeaderBytes  = 32 // Enough for any frame header
+)
+
+// Static errors for zstd tuning
+var (
+	ErrZstdWindowLogInvalid      = errors.New("zstd window log must be between 10 and 29")
+	ErrZstdDictionarySizeInvalid = errors.New("zstd dictionary size must be between 1 and 1024 KB")
+	ErrZstdOptionsNeedZstd       = errors.New("zstd options require --compression zstd and a jsonl or csv output format")
+	ErrZstdDictionaryMismatch    = errors.New("zstd dictionary does not match the archive")
+)
+
+var (
+	zstdWindowLog       int
+	zstdTrainDictionary bool
+	zstdDictionarySize  int
+)
+
+func init() {
+	archiveCmd.Flags().IntVar(&zstdWindowLog, "zstd-window-log", 0, "long-range mode: zstd match window of 2^N bytes (10-29, e.g. 27 for 128MB) to find repeats far apart in large files (0 = chosen by the level)")
+	archiveCmd.Flags().BoolVar(&zstdTrainDictionary, "zstd-train-dictionary", false, "train a zstd dictionary on the start of each archive file, compress the file with it, and upload it alongside as <key>.dict")
+	archiveCmd.Flags().IntVar(&zstdDictionarySize, "zstd-dictionary-size", compressors.DefaultZstdDictionarySize/1024, "largest trained zstd dictionary in KB")
+	_ = viper.BindPFlag("zstd.window_log", archiveCmd.Flags().Lookup("zstd-window-log"))
+	_ = viper.BindPFlag("zstd.train_dictionary", archiveCmd.Flags().Lookup("zstd-train-dictionary"))
+	_ = viper.BindPFlag("zstd.dictionary_size", archiveCmd.Flags().Lookup("zstd-dictionary-size"))
+}
+
+// validateZstdTuning checks the long-range window and dictionary options
+func (c *Config) validateZstdTuning() error {
+	if c.ZstdWindowLog == 0 && !c.ZstdTrainDictionary {
+		return nil
+	}
+	if c.Compression != "zstd" || formatters.UsesInternalCompression(c.OutputFormat) {
+		return ErrZstdOptionsNeedZstd
+	}
+	if c.ZstdWindowLog != 0 && (c.ZstdWindowLog < minZstdWindowLog || c.ZstdWindowLog > maxZstdWindowLog) {
+		return fmt.Errorf("%w, got %d", ErrZstdWindowLogInvalid, c.ZstdWindowLog)
+	}
+	if c.ZstdTrainDictionary && (c.ZstdDictionarySizeKB < 1 || c.ZstdDictionarySizeKB*1024 > maxZstdDictionarySize) {
+		return fmt.Errorf("%w, got %d", ErrZstdDictionarySizeInvalid, c.ZstdDictionarySizeKB)
+	}
+	return nil
+}
+
+// zstdDictionaryKey returns the key of the dictionary an archive was compressed with
+func zstdDictionaryKey(objectKey string) string {
+	return objectKey + zstdDictionarySuffix
+}
+
+// newCompressorWriter creates the compressing writer of a temp file. With
+// --zstd-train-dictionary the dictionary trained for the file is written
+// next to it on Close, for uploadTempFileToS3 to store alongside the archive.
+func (a *Archiver) newCompressorWriter(w io.Writer, level int, tempFilePath string) (io.WriteCloser, error) {
+	compressor, err := compressors.GetCompressor(a.config.Compression)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get compressor: %w", err)
+	}
+	zstdCompressor, ok := compressor.(*compressors.ZstdCompressor)
+	if !ok {
+		return compressor.NewWriter(w, level), nil
+	}
+	zstdCompressor.WithWindowLog(a.config.ZstdWindowLog)
+	if !a.config.ZstdTrainDictionary {
+		return zstdCompressor.NewWriter(w, level), nil
+	}
+	return &dictionaryFileWriter{
+		ZstdTrainingWriter: zstdCompressor.NewTrainingWriter(w, level, a.config.ZstdDictionarySizeKB*1024),
+		path:               tempFilePath + zstdDictionarySuffix,
+	}, nil
+}
+
+// dictionaryFileWriter saves the dictionary a temp file was compressed with
+type dictionaryFileWriter struct {
+	*compressors.ZstdTrainingWriter
+	path string
+}
+
+func (d *dictionaryFileWriter) Close() error {
+	if err := d.ZstdTrainingWriter.Close(); err != nil {
+		return err
+	}
+	dictionary := d.Dictionary()
+	if dictionary == nil {
+		return nil
+	}
+	if err := os.WriteFile(d.path, dictionary, 0o600); err != nil {
+		return fmt.Errorf("failed to save zstd dictionary: %w", err)
+	}
+	return nil
+}
+
+// uploadZstdDictionary uploads the dictionary of a temp file, if it has one,
+// to the key next to its archive. It runs before the archive's upload, so an
+// archive is never in the bucket without the dictionary it needs.
+func (a *Archiver) uploadZstdDictionary(tempFilePath, objectKey string) error {
+	dictionary, err := os.ReadFile(tempFilePath + zstdDictionarySuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read zstd dictionary: %w", err)
+	}
+	if err := a.uploadToS3(zstdDictionaryKey(objectKey), dictionary); err != nil {
+		return fmt.Errorf("failed to upload zstd dictionary: %w", err)
+	}
+	return nil
+}
+
+// loadZstdDictionary registers the dictionary the zstd archive at key was
+// compressed with, given the archive's first bytes, by downloading it from
+// alongside the archive. Archives compressed without one need nothing.
+func loadZstdDictionary(ctx context.Context, client StorageBackend, bucket, key string, header []byte) (bool, error) {
+	id := compressors.ZstdFrameDictionaryID(header)
+	if id == 0 {
+		return false, nil
+	}
+	if compressors.HasZstdDictionary(id) {
+		return true, nil
+	}
+	dictKey := zstdDictionaryKey(key)
+	out, _, err := client.Get(ctx, bucket, dictKey, GetOptions{})
+	if err != nil {
+		return true, fmt.Errorf("failed to download zstd dictionary %s: %w", dictKey, err)
+	}
+	defer out.Close()
+	dictionary, err := io.ReadAll(io.LimitReader(out, maxZstdDictionarySize+1024))
+	if err != nil {
+		return true, fmt.Errorf("failed to download zstd dictionary %s: %w", dictKey, err)
+	}
+	registered, err := compressors.RegisterZstdDictionary(dictionary)
+	if err != nil {
+		return true, fmt.Errorf("%s: %w", dictKey, err)
+	}
+	if registered != id {
+		return true, fmt.Errorf("%w: %s has ID %d, the archive needs %d", ErrZstdDictionaryMismatch, dictKey, registered, id)
+	}
+	return true, nil
+}
+
+// loadZstdDictionary registers the dictionary of a downloaded archive file
+func (d *rangeDownloader) loadZstdDictionary(ctx context.Context, key string, file *os.File) error {
+	header := make([]byte, zstdFrameHeaderBytes)
+	n, err := file.ReadAt(header, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	_, err = loadZstdDictionary(ctx, d.client, d.bucket, key, header[:n])
+	return err
+}
+
+// loadStreamZstdDictionary registers the dictionary of an archive read as a
+// stream, returning a reader that still starts at the archive's first byte
+// and whether the archive uses a dictionary
+func loadStreamZstdDictionary(ctx context.Context, client StorageBackend, bucket, key string, body io.Reader) (io.Reader, bool, error) {
+	buffered := bufio.NewReader(body)
+	header, err := buffered.Peek(zstdFrameHeaderBytes)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, false, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	used, err := loadZstdDictionary(ctx, client, bucket, key, header)
+	return buffered, used, err
+}
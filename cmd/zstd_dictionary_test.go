@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/airframesio/data-archiver/cmd/compressors"
+)
+
+func TestValidateZstdTuning(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   error
+	}{
+		{"Off", Config{Compression: "gzip", OutputFormat: "jsonl"}, nil},
+		{"LongRange", Config{Compression: "zstd", OutputFormat: "jsonl", ZstdWindowLog: 27}, nil},
+		{"Dictionary", Config{Compression: "zstd", OutputFormat: "csv", ZstdTrainDictionary: true, ZstdDictionarySizeKB: 112}, nil},
+		{"WindowTooLarge", Config{Compression: "zstd", OutputFormat: "jsonl", ZstdWindowLog: 30}, ErrZstdWindowLogInvalid},
+		{"DictionaryTooLarge", Config{Compression: "zstd", OutputFormat: "jsonl", ZstdTrainDictionary: true, ZstdDictionarySizeKB: 2048}, ErrZstdDictionarySizeInvalid},
+		{"NotZstd", Config{Compression: "lz4", OutputFormat: "jsonl", ZstdWindowLog: 24}, ErrZstdOptionsNeedZstd},
+		{"Parquet", Config{Compression: "zstd", OutputFormat: "parquet", ZstdTrainDictionary: true, ZstdDictionarySizeKB: 112}, ErrZstdOptionsNeedZstd},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.validateZstdTuning(); !errors.Is(err, tt.want) {
+				t.Errorf("validateZstdTuning() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestZstdDictionaryArchiveRoundTrip(t *testing.T) {
+	store := &fakeObjectStore{objects: map[string][]byte{}}
+	archiver := NewArchiver(&Config{
+		Compression:          "zstd",
+		OutputFormat:         "jsonl",
+		ZstdWindowLog:        20,
+		ZstdTrainDictionary:  true,
+		ZstdDictionarySizeKB: 4,
+		S3:                   S3Config{Bucket: "archive"},
+	}, newTestLogger())
+	archiver.s3Client = newS3ObjectStore(store)
+
+	// Rows unique to this run, so the dictionary isn't already registered
+	var rows bytes.Buffer
+	run := time.Now().UnixNano()
+	for i := 0; rows.Len() < 600*1024; i++ {
+		fmt.Fprintf(&rows, `{"run":%d,"id":%d,"flight":"UAL%d","altitude":%d,"message":"position report"}`+"\n", run, i, i%500, i%41000)
+	}
+	tempFile, err := createTempFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanupTempFile(tempFile.Name())
+	writer, err := archiver.newCompressorWriter(tempFile, 3, tempFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write(rows.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tempFile.Close()
+	if _, err := os.Stat(tempFile.Name() + zstdDictionarySuffix); err != nil {
+		t.Fatalf("expected a dictionary next to the temp file: %v", err)
+	}
+
+	key := "events/events-2024-03-15.jsonl.zst"
+	if err := archiver.uploadTempFileToS3(tempFile.Name(), key); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.objects[zstdDictionaryKey(key)]; !ok {
+		t.Fatalf("expected %s uploaded, got %v", zstdDictionaryKey(key), store.objects)
+	}
+
+	// Restore and compare download the dictionary before decompressing
+	archived, err := os.Open(tempFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archived.Close()
+	header := make([]byte, zstdFrameHeaderBytes)
+	if _, err := archived.ReadAt(header, 0); err != nil {
+		t.Fatal(err)
+	}
+	if compressors.HasZstdDictionary(compressors.ZstdFrameDictionaryID(header)) {
+		t.Fatal("dictionary registered before it was downloaded")
+	}
+	downloader := newRangeDownloader(newS3ObjectStore(store), "archive", t.TempDir(), 5, 0, newTestLogger())
+	if err := downloader.loadZstdDictionary(context.Background(), key, archived); err != nil {
+		t.Fatalf("loadZstdDictionary() error = %v", err)
+	}
+	compressor, _ := compressors.GetCompressor("zstd")
+	reader, err := compressor.NewReader(archived)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	restored, err := io.ReadAll(reader)
+	if err != nil || !bytes.Equal(restored, rows.Bytes()) {
+		t.Fatalf("restored %d bytes, want %d: %v", len(restored), rows.Len(), err)
+	}
+
+	cleanupTempFile(tempFile.Name())
+	if _, err := os.Stat(tempFile.Name() + zstdDictionarySuffix); !os.IsNotExist(err) {
+		t.Errorf("expected the dictionary removed with the temp file, got %v", err)
+	}
+}
+
+func TestLoadStreamZstdDictionary(t *testing.T) {
+	store := &fakeObjectStore{objects: map[string][]byte{}}
+
+	// Archives without a dictionary read as before, from their first byte
+	body, used, err := loadStreamZstdDictionary(context.Background(), newS3ObjectStore(store), "archive", "events/a.jsonl", strings.NewReader(`{"id":1}`+"\n"))
+	if err != nil || used {
+		t.Fatalf("loadStreamZstdDictionary() = %v, %v", used, err)
+	}
+	if data, _ := io.ReadAll(body); string(data) != `{"id":1}`+"\n" {
+		t.Errorf("body = %q", data)
+	}
+
+	// Samples with nothing to learn from fail to train instead of panicking
+	if _, err := compressors.TrainZstdDictionary(bytes.Repeat([]byte(`{"id":1,"flight":"UAL1"}`+"\n"), 2000), 1024, 3); !errors.Is(err, compressors.ErrZstdSamplesUntrained) {
+		t.Errorf("TrainZstdDictionary(identical records) = %v", err)
+	}
+
+	// An archive whose dictionary is missing fails clearly
+	var archive bytes.Buffer
+	var samples bytes.Buffer
+	for i := 0; samples.Len() < 64*1024; i++ {
+		fmt.Fprintf(&samples, `{"id":%d,"flight":"DAL%d","note":"missing dictionary"}`+"\n", i, i*7%311)
+	}
+	dictionary, err := compressors.TrainZstdDictionary(samples.Bytes(), 1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := compressors.NewZstdCompressor().WithDictionary(dictionary).NewWriter(&archive, 3)
+	writer.Write(samples.Bytes())
+	writer.Close()
+	if _, _, err := loadStreamZstdDictionary(context.Background(), newS3ObjectStore(store), "archive", "events/b.jsonl.zst", &archive); err == nil || !strings.Contains(err.Error(), "events/b.jsonl.zst.dict") {
+		t.Errorf("expected a missing dictionary error, got %v", err)
+	}
+}